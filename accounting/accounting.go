@@ -0,0 +1,240 @@
+package accounting
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// TenantKey is the key used in the state bag to pass the tenant of
+	// the request to the proxy.
+	TenantKey = "accounting:tenant"
+
+	// OtherTenant receives the usage of the tenants above MaxTenants.
+	OtherTenant = "_other"
+
+	defaultExportInterval = time.Minute
+	defaultMaxTenants     = 10000
+)
+
+// Usage contains the accounted counters of a tenant.
+type Usage struct {
+	Requests    int64
+	BytesIn     int64
+	BytesOut    int64
+	BackendTime time.Duration
+}
+
+// Record contains the usage of a tenant during an export interval.
+type Record struct {
+	Tenant         string    `json:"tenant"`
+	Start          time.Time `json:"start"`
+	End            time.Time `json:"end"`
+	Requests       int64     `json:"requests"`
+	BytesIn        int64     `json:"bytesIn"`
+	BytesOut       int64     `json:"bytesOut"`
+	BackendSeconds float64   `json:"backendSeconds"`
+}
+
+// Sink receives the exported records.
+type Sink interface {
+	Export([]Record) error
+	Close() error
+}
+
+// Options for the Registry.
+type Options struct {
+
+	// Sink, when set, receives the usage of the tenants periodically.
+	Sink Sink
+
+	// ExportInterval sets how often the usage is exported to the
+	// Sink. Defaults to 1m.
+	ExportInterval time.Duration
+
+	// MaxTenants limits the number of the tracked tenants. Defaults to
+	// 10000.
+	MaxTenants int
+}
+
+// Registry accumulates the usage of the tenants.
+type Registry struct {
+	options Options
+
+	mu       sync.Mutex
+	current  map[string]*Usage
+	totals   map[string]*Usage
+	start    time.Time
+	quit     chan struct{}
+	done     chan struct{}
+	once     sync.Once
+	requests *prometheus.Desc
+	bytesIn  *prometheus.Desc
+	bytesOut *prometheus.Desc
+	backend  *prometheus.Desc
+}
+
+var _ prometheus.Collector = &Registry{}
+
+// NewRegistry creates a registry, and starts exporting the usage, when
+// a Sink is set.
+func NewRegistry(o Options) *Registry {
+	if o.ExportInterval <= 0 {
+		o.ExportInterval = defaultExportInterval
+	}
+
+	if o.MaxTenants <= 0 {
+		o.MaxTenants = defaultMaxTenants
+	}
+
+	tenant := []string{"tenant"}
+	r := &Registry{
+		options:  o,
+		current:  make(map[string]*Usage),
+		totals:   make(map[string]*Usage),
+		start:    time.Now(),
+		quit:     make(chan struct{}),
+		done:     make(chan struct{}),
+		requests: prometheus.NewDesc("skipper_accounting_requests_total", "Number of the requests of the tenant.", tenant, nil),
+		bytesIn:  prometheus.NewDesc("skipper_accounting_bytes_in_total", "Bytes received from the clients of the tenant.", tenant, nil),
+		bytesOut: prometheus.NewDesc("skipper_accounting_bytes_out_total", "Bytes sent to the clients of the tenant.", tenant, nil),
+		backend:  prometheus.NewDesc("skipper_accounting_backend_seconds_total", "Time spent waiting for the backend responses of the tenant.", tenant, nil),
+	}
+
+	if o.Sink == nil {
+		close(r.done)
+	} else {
+		go r.run()
+	}
+
+	return r
+}
+
+func add(m map[string]*Usage, tenant string, u Usage) {
+	v, ok := m[tenant]
+	if !ok {
+		v = &Usage{}
+		m[tenant] = v
+	}
+
+	v.Requests += u.Requests
+	v.BytesIn += u.BytesIn
+	v.BytesOut += u.BytesOut
+	v.BackendTime += u.BackendTime
+}
+
+// Add accounts the usage of a tenant.
+func (r *Registry) Add(tenant string, u Usage) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.totals[tenant]; !ok && len(r.totals) >= r.options.MaxTenants {
+		tenant = OtherTenant
+	}
+
+	add(r.current, tenant, u)
+	add(r.totals, tenant, u)
+}
+
+// Totals returns the usage of the tenants since the registry was
+// created.
+func (r *Registry) Totals() map[string]Usage {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	t := make(map[string]Usage, len(r.totals))
+	for tenant, u := range r.totals {
+		t[tenant] = *u
+	}
+
+	return t
+}
+
+// takes the usage since the previous export, sorted by the tenant
+func (r *Registry) take(now time.Time) []Record {
+	r.mu.Lock()
+	current, start := r.current, r.start
+	r.current = make(map[string]*Usage)
+	r.start = now
+	r.mu.Unlock()
+
+	records := make([]Record, 0, len(current))
+	for tenant, u := range current {
+		records = append(records, Record{
+			Tenant:         tenant,
+			Start:          start,
+			End:            now,
+			Requests:       u.Requests,
+			BytesIn:        u.BytesIn,
+			BytesOut:       u.BytesOut,
+			BackendSeconds: u.BackendTime.Seconds(),
+		})
+	}
+
+	sort.Slice(records, func(i, j int) bool { return records[i].Tenant < records[j].Tenant })
+	return records
+}
+
+func (r *Registry) export() {
+	records := r.take(time.Now())
+	if len(records) == 0 {
+		return
+	}
+
+	if err := r.options.Sink.Export(records); err != nil {
+		log.Errorf("Failed to export the usage of %d tenants: %v", len(records), err)
+	}
+}
+
+func (r *Registry) run() {
+	defer close(r.done)
+
+	ticker := time.NewTicker(r.options.ExportInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			r.export()
+		case <-r.quit:
+			r.export()
+			return
+		}
+	}
+}
+
+// Describe implements the prometheus.Collector interface.
+func (r *Registry) Describe(ch chan<- *prometheus.Desc) {
+	ch <- r.requests
+	ch <- r.bytesIn
+	ch <- r.bytesOut
+	ch <- r.backend
+}
+
+// Collect implements the prometheus.Collector interface, reporting the
+// total usage of the tenants.
+func (r *Registry) Collect(ch chan<- prometheus.Metric) {
+	for tenant, u := range r.Totals() {
+		ch <- prometheus.MustNewConstMetric(r.requests, prometheus.CounterValue, float64(u.Requests), tenant)
+		ch <- prometheus.MustNewConstMetric(r.bytesIn, prometheus.CounterValue, float64(u.BytesIn), tenant)
+		ch <- prometheus.MustNewConstMetric(r.bytesOut, prometheus.CounterValue, float64(u.BytesOut), tenant)
+		ch <- prometheus.MustNewConstMetric(r.backend, prometheus.CounterValue, u.BackendTime.Seconds(), tenant)
+	}
+}
+
+// Close exports the remaining usage, and closes the sink.
+func (r *Registry) Close() {
+	r.once.Do(func() {
+		close(r.quit)
+		<-r.done
+		if r.options.Sink != nil {
+			if err := r.options.Sink.Close(); err != nil {
+				log.Errorf("Failed to close the accounting sink: %v", err)
+			}
+		}
+	})
+}
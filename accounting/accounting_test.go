@@ -0,0 +1,123 @@
+package accounting
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+type testSink struct {
+	records []Record
+	closed  bool
+}
+
+func (s *testSink) Export(r []Record) error {
+	s.records = append(s.records, r...)
+	return nil
+}
+
+func (s *testSink) Close() error {
+	s.closed = true
+	return nil
+}
+
+func TestRegistry(t *testing.T) {
+	sink := &testSink{}
+	r := NewRegistry(Options{Sink: sink, ExportInterval: time.Hour, MaxTenants: 2})
+
+	r.Add("foo", Usage{Requests: 1, BytesIn: 10, BytesOut: 100, BackendTime: time.Second})
+	r.Add("bar", Usage{Requests: 1, BytesOut: 50})
+	r.Add("foo", Usage{Requests: 1, BytesIn: 5, BytesOut: 20, BackendTime: time.Second})
+	r.Add("baz", Usage{Requests: 1})
+
+	totals := r.Totals()
+	if u := totals["foo"]; u.Requests != 2 || u.BytesIn != 15 || u.BytesOut != 120 || u.BackendTime != 2*time.Second {
+		t.Errorf("unexpected usage of foo: %+v", u)
+	}
+
+	if _, ok := totals["baz"]; ok {
+		t.Error("failed to limit the tenants")
+	}
+
+	if u := totals[OtherTenant]; u.Requests != 1 {
+		t.Errorf("unexpected usage of the other tenants: %+v", u)
+	}
+
+	r.Close()
+	if !sink.closed {
+		t.Error("failed to close the sink")
+	}
+
+	if len(sink.records) != 3 {
+		t.Fatalf("unexpected records: %+v", sink.records)
+	}
+
+	// sorted by the tenant
+	for i, tenant := range []string{OtherTenant, "bar", "foo"} {
+		if sink.records[i].Tenant != tenant {
+			t.Errorf("unexpected record: %+v, expected tenant: %s", sink.records[i], tenant)
+		}
+	}
+
+	if rec := sink.records[2]; rec.Requests != 2 || rec.BackendSeconds != 2 || !rec.End.After(rec.Start) {
+		t.Errorf("unexpected record: %+v", rec)
+	}
+}
+
+func TestRegistryExportsIntervals(t *testing.T) {
+	sink := &testSink{}
+	r := NewRegistry(Options{Sink: sink, ExportInterval: time.Hour})
+
+	r.Add("foo", Usage{Requests: 1})
+	first := r.take(time.Now())
+	r.Add("foo", Usage{Requests: 2})
+	second := r.take(time.Now())
+	r.Close()
+
+	if len(first) != 1 || first[0].Requests != 1 || len(second) != 1 || second[0].Requests != 2 {
+		t.Errorf("unexpected records: %+v, %+v", first, second)
+	}
+
+	if len(sink.records) != 0 {
+		t.Errorf("unexpected records exported: %+v", sink.records)
+	}
+
+	if r.Totals()["foo"].Requests != 3 {
+		t.Errorf("unexpected totals: %+v", r.Totals())
+	}
+}
+
+func TestRegistryCollector(t *testing.T) {
+	r := NewRegistry(Options{})
+	defer r.Close()
+
+	r.Add("foo", Usage{Requests: 3, BytesIn: 1, BytesOut: 2, BackendTime: time.Second})
+
+	pr := prometheus.NewRegistry()
+	pr.MustRegister(r)
+	families, err := pr.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	values := make(map[string]float64)
+	for _, f := range families {
+		for _, m := range f.GetMetric() {
+			if m.GetLabel()[0].GetValue() == "foo" {
+				values[f.GetName()] = m.GetCounter().GetValue()
+			}
+		}
+	}
+
+	for name, expected := range map[string]float64{
+		"skipper_accounting_requests_total":        3,
+		"skipper_accounting_bytes_in_total":        1,
+		"skipper_accounting_bytes_out_total":       2,
+		"skipper_accounting_backend_seconds_total": 1,
+	} {
+		if values[name] != expected {
+			t.Errorf("unexpected value of %s: %v", name, values[name])
+		}
+	}
+}
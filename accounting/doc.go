@@ -0,0 +1,29 @@
+/*
+Package accounting implements the per-tenant cost accounting of the
+proxied requests, for chargeback.
+
+The tenant of a request is set by the tenantKey filter, e.g. from a
+header or from the claims of a validated token. For the requests with a
+tenant, the proxy counts the number of the requests, the bytes received
+from the client, the bytes sent to the client, and the time spent waiting
+for the backend responses.
+
+The Registry accumulates the counters, and periodically exports the
+aggregates of the past interval to a Sink, as one Record for each
+tenant. The builtin sinks append the records to a file as JSON lines, or
+post them to an HTTP endpoint as a JSON array. Besides, the Registry
+implements the prometheus.Collector interface, and can expose the total
+counters of each tenant as Prometheus metrics.
+
+Example:
+
+	skipper -cost-accounting -cost-accounting-export=/var/log/skipper/costs.log -cost-accounting-export-interval=1m
+
+with the route:
+
+	api: Path("/api") -> tenantKey("${request.header.X-Tenant-Id}") -> "https://api.example.org";
+
+The number of the tracked tenants is limited, and the usage of the
+tenants above the limit is accounted to the tenant "_other".
+*/
+package accounting
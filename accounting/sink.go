@@ -0,0 +1,95 @@
+package accounting
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+const httpSinkTimeout = 10 * time.Second
+
+type fileSink struct {
+	file *os.File
+}
+
+type httpSink struct {
+	url    string
+	client *http.Client
+}
+
+// NewSink creates a sink from its destination: an http:// or https://
+// URL receiving the records as a JSON array in POST requests, or a file
+// path, where the records are appended as JSON lines.
+func NewSink(dest string) (Sink, error) {
+	if strings.HasPrefix(dest, "http://") || strings.HasPrefix(dest, "https://") {
+		return NewHTTPSink(dest), nil
+	}
+
+	return NewFileSink(dest)
+}
+
+// NewFileSink creates a sink appending the records to a file as JSON
+// lines.
+func NewFileSink(name string) (Sink, error) {
+	f, err := os.OpenFile(name, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, err
+	}
+
+	return &fileSink{file: f}, nil
+}
+
+// NewHTTPSink creates a sink posting the records to url as a JSON array.
+func NewHTTPSink(url string) Sink {
+	return &httpSink{
+		url:    url,
+		client: &http.Client{Timeout: httpSinkTimeout},
+	}
+}
+
+func (s *fileSink) Export(records []Record) error {
+	var b bytes.Buffer
+	enc := json.NewEncoder(&b)
+	for _, r := range records {
+		if err := enc.Encode(r); err != nil {
+			return err
+		}
+	}
+
+	_, err := s.file.Write(b.Bytes())
+	return err
+}
+
+func (s *fileSink) Close() error {
+	return s.file.Close()
+}
+
+func (s *httpSink) Export(records []Record) error {
+	b, err := json.Marshal(records)
+	if err != nil {
+		return err
+	}
+
+	rsp, err := s.client.Post(s.url, "application/json", bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+
+	defer rsp.Body.Close()
+	io.Copy(io.Discard, rsp.Body)
+	if rsp.StatusCode >= http.StatusMultipleChoices {
+		return fmt.Errorf("unexpected status: %d", rsp.StatusCode)
+	}
+
+	return nil
+}
+
+func (s *httpSink) Close() error {
+	s.client.CloseIdleConnections()
+	return nil
+}
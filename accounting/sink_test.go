@@ -0,0 +1,82 @@
+package accounting
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var testRecords = []Record{{Tenant: "foo", Requests: 1}, {Tenant: "bar", Requests: 2}}
+
+func TestFileSink(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "costs.log")
+	s, err := NewSink(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.Export(testRecords); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := os.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(string(b)), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("unexpected content: %s", b)
+	}
+
+	var r Record
+	if err := json.Unmarshal([]byte(lines[1]), &r); err != nil || r.Tenant != "bar" || r.Requests != 2 {
+		t.Errorf("unexpected record: %+v, %v", r, err)
+	}
+}
+
+func TestHTTPSink(t *testing.T) {
+	var received []Record
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" || r.Header.Get("Content-Type") != "application/json" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		json.NewDecoder(r.Body).Decode(&received)
+	}))
+	defer s.Close()
+
+	sink, err := NewSink(s.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer sink.Close()
+	if err := sink.Export(testRecords); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(received) != 2 || received[0].Tenant != "foo" {
+		t.Errorf("unexpected records: %+v", received)
+	}
+}
+
+func TestHTTPSinkFails(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer s.Close()
+
+	if err := NewHTTPSink(s.URL).Export(testRecords); err == nil {
+		t.Error("failed to fail")
+	}
+}
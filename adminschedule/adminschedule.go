@@ -0,0 +1,320 @@
+/*
+Package adminschedule implements the scheduling of the admin API requests
+of the support listener, e.g. switching the maintenance mode or draining
+endpoints, for planned cutovers during low traffic time windows.
+
+The actions are scheduled with a POST request, containing the time of the
+execution, and the method, path and optional body of the admin request:
+
+	curl -X POST localhost:9911/schedule -d '{
+		"at": "2026-10-17T02:00:00Z",
+		"method": "POST",
+		"path": "/maintenance?route=app&enabled=true"
+	}'
+
+The scheduled actions and their audit records are listed with a GET
+request, and the pending actions can be cancelled with a DELETE request:
+
+	curl localhost:9911/schedule
+	curl -X DELETE 'localhost:9911/schedule?id=3'
+
+The schedule is not persisted, and it is lost when skipper is restarted.
+*/
+package adminschedule
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// Path is the path of the schedule API on the support listener.
+	Path = "/schedule"
+
+	// UserHeader is the optional header of the scheduling requests,
+	// identifying the user in the audit records.
+	UserHeader = "X-Audit-User"
+
+	defaultMaxRecords = 1000
+)
+
+// Status of a scheduled action.
+type Status string
+
+const (
+	StatusScheduled Status = "scheduled"
+	StatusDone      Status = "done"
+	StatusFailed    Status = "failed"
+	StatusCancelled Status = "cancelled"
+)
+
+// Action is an admin API request scheduled for a future time, together
+// with its audit record.
+type Action struct {
+	Id     int       `json:"id"`
+	At     time.Time `json:"at"`
+	Method string    `json:"method"`
+	Path   string    `json:"path"`
+	Body   string    `json:"body,omitempty"`
+
+	Status      Status     `json:"status"`
+	CreatedAt   time.Time  `json:"createdAt"`
+	CreatedBy   string     `json:"createdBy"`
+	CancelledBy string     `json:"cancelledBy,omitempty"`
+	ExecutedAt  *time.Time `json:"executedAt,omitempty"`
+	Result      int        `json:"result,omitempty"`
+	Response    string     `json:"response,omitempty"`
+
+	timer *time.Timer
+}
+
+// Options for the schedule.
+type Options struct {
+	// Handler executes the scheduled admin requests, typically the mux
+	// of the support listener.
+	Handler http.Handler
+
+	// MaxRecords limits the number of the finished actions kept for
+	// auditing. The oldest ones are dropped first. Defaults to 1000.
+	MaxRecords int
+}
+
+// Schedule stores the scheduled actions, executes them at the set time,
+// and serves the schedule API.
+type Schedule struct {
+	options Options
+	mu      sync.Mutex
+	lastId  int
+	actions map[int]*Action
+	now     func() time.Time
+}
+
+// New creates a schedule executing the actions with the handler set in
+// the options.
+func New(o Options) *Schedule {
+	if o.MaxRecords <= 0 {
+		o.MaxRecords = defaultMaxRecords
+	}
+
+	return &Schedule{
+		options: o,
+		actions: make(map[int]*Action),
+		now:     time.Now,
+	}
+}
+
+func auditUser(r *http.Request) string {
+	if u := r.Header.Get(UserHeader); u != "" {
+		return fmt.Sprintf("%s (%s)", u, r.RemoteAddr)
+	}
+
+	return r.RemoteAddr
+}
+
+func (s *Schedule) validate(a *Action) error {
+	switch a.Method {
+	case "POST", "PUT", "DELETE":
+	default:
+		return fmt.Errorf("invalid method: %q", a.Method)
+	}
+
+	u, err := url.ParseRequestURI(a.Path)
+	if err != nil || u.Host != "" || !strings.HasPrefix(u.Path, "/") || strings.HasPrefix(u.Path, Path) {
+		return fmt.Errorf("invalid path: %q", a.Path)
+	}
+
+	if a.At.IsZero() || a.At.Before(s.now()) {
+		return fmt.Errorf("invalid time: %v", a.At)
+	}
+
+	return nil
+}
+
+// Add schedules an action. The At, Method, Path and Body fields of the
+// action are used, the rest is set by the schedule. It returns the id of
+// the scheduled action.
+func (s *Schedule) Add(a Action, user string) (int, error) {
+	if err := s.validate(&a); err != nil {
+		return 0, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.lastId++
+	action := &Action{
+		Id:        s.lastId,
+		At:        a.At,
+		Method:    a.Method,
+		Path:      a.Path,
+		Body:      a.Body,
+		Status:    StatusScheduled,
+		CreatedAt: s.now(),
+		CreatedBy: user,
+	}
+
+	s.actions[action.Id] = action
+	action.timer = time.AfterFunc(action.At.Sub(s.now()), func() { s.execute(action) })
+	log.Infof(
+		"audit: admin action %d scheduled by %s at %v: %s %s",
+		action.Id, user, action.At, action.Method, action.Path,
+	)
+
+	return action.Id, nil
+}
+
+// Cancel cancels a pending action. It returns false when the action
+// doesn't exist or it was already executed.
+func (s *Schedule) Cancel(id int, user string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	a, ok := s.actions[id]
+	if !ok || a.Status != StatusScheduled || !a.timer.Stop() {
+		return false
+	}
+
+	a.Status = StatusCancelled
+	a.CancelledBy = user
+	s.dropRecords()
+	log.Infof("audit: admin action %d cancelled by %s", id, user)
+	return true
+}
+
+// List returns the scheduled actions and the audit records of the
+// finished ones, ordered by id.
+func (s *Schedule) List() []Action {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	l := make([]Action, 0, len(s.actions))
+	for _, a := range s.actions {
+		c := *a
+		c.timer = nil
+		l = append(l, c)
+	}
+
+	sort.Slice(l, func(i, j int) bool { return l[i].Id < l[j].Id })
+	return l
+}
+
+// Close stops the pending actions without recording them as cancelled.
+func (s *Schedule) Close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, a := range s.actions {
+		if a.Status == StatusScheduled {
+			a.timer.Stop()
+		}
+	}
+}
+
+func (s *Schedule) execute(a *Action) {
+	rsp := httptest.NewRecorder()
+	req, err := http.NewRequest(a.Method, a.Path, bytes.NewBufferString(a.Body))
+	if err != nil {
+		rsp.WriteHeader(http.StatusBadRequest)
+		rsp.WriteString(err.Error())
+	} else {
+		req.RemoteAddr = "schedule"
+		s.options.Handler.ServeHTTP(rsp, req)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	a.ExecutedAt = &now
+	a.Result = rsp.Code
+	a.Response = strings.TrimSpace(rsp.Body.String())
+	if rsp.Code < http.StatusBadRequest {
+		a.Status = StatusDone
+		log.Infof("audit: admin action %d executed: %s %s, result: %d", a.Id, a.Method, a.Path, a.Result)
+	} else {
+		a.Status = StatusFailed
+		log.Errorf("audit: admin action %d failed: %s %s, result: %d, %s", a.Id, a.Method, a.Path, a.Result, a.Response)
+	}
+
+	s.dropRecords()
+}
+
+// drops the oldest finished actions above the limit
+func (s *Schedule) dropRecords() {
+	var finished []int
+	for id, a := range s.actions {
+		if a.Status != StatusScheduled {
+			finished = append(finished, id)
+		}
+	}
+
+	if len(finished) <= s.options.MaxRecords {
+		return
+	}
+
+	sort.Ints(finished)
+	for _, id := range finished[:len(finished)-s.options.MaxRecords] {
+		delete(s.actions, id)
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(b)
+}
+
+// ServeHTTP serves the schedule API. GET lists the actions, POST schedules
+// an action with a JSON body, and DELETE cancels the action set by the id
+// query parameter.
+func (s *Schedule) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "GET":
+		writeJSON(w, http.StatusOK, s.List())
+	case "POST":
+		var a Action
+		if err := json.NewDecoder(r.Body).Decode(&a); err != nil {
+			http.Error(w, "invalid action: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		id, err := s.Add(a, auditUser(r))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		writeJSON(w, http.StatusCreated, map[string]int{"id": id})
+	case "DELETE":
+		id, err := strconv.Atoi(r.URL.Query().Get("id"))
+		if err != nil {
+			http.Error(w, "invalid id parameter", http.StatusBadRequest)
+			return
+		}
+
+		if !s.Cancel(id, auditUser(r)) {
+			http.Error(w, "action not found or not pending", http.StatusNotFound)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
@@ -0,0 +1,225 @@
+package adminschedule
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type recordingHandler struct {
+	mu       sync.Mutex
+	requests []string
+}
+
+func (h *recordingHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	b, _ := io.ReadAll(r.Body)
+	h.mu.Lock()
+	h.requests = append(h.requests, fmt.Sprintf("%s %s %s", r.Method, r.URL.RequestURI(), b))
+	h.mu.Unlock()
+
+	if r.URL.Path == "/fail" {
+		http.Error(w, "failed", http.StatusBadRequest)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *recordingHandler) received() []string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return append([]string(nil), h.requests...)
+}
+
+func waitStatus(t *testing.T, s *Schedule, id int, status Status) Action {
+	deadline := time.Now().Add(time.Second)
+	for {
+		for _, a := range s.List() {
+			if a.Id == id && a.Status == status {
+				return a
+			}
+		}
+
+		if time.Now().After(deadline) {
+			t.Fatalf("timeout waiting for action %d to be %s: %+v", id, status, s.List())
+		}
+
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestAddValidation(t *testing.T) {
+	s := New(Options{Handler: &recordingHandler{}})
+	defer s.Close()
+
+	at := time.Now().Add(time.Hour)
+	for _, a := range []Action{
+		{At: at, Method: "GET", Path: "/maintenance"},
+		{At: at, Method: "POST", Path: "maintenance"},
+		{At: at, Method: "POST", Path: "http://example.org/maintenance"},
+		{At: at, Method: "POST", Path: "/schedule"},
+		{At: time.Now().Add(-time.Minute), Method: "POST", Path: "/maintenance"},
+		{Method: "POST", Path: "/maintenance"},
+	} {
+		if _, err := s.Add(a, "test"); err == nil {
+			t.Errorf("failed to fail: %+v", a)
+		}
+	}
+}
+
+func TestExecute(t *testing.T) {
+	h := &recordingHandler{}
+	s := New(Options{Handler: h})
+	defer s.Close()
+
+	at := time.Now().Add(20 * time.Millisecond)
+	id, err := s.Add(Action{At: at, Method: "POST", Path: "/maintenance?route=r&enabled=true", Body: "foo"}, "jdoe")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	failing, err := s.Add(Action{At: at, Method: "DELETE", Path: "/fail"}, "jdoe")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a := waitStatus(t, s, id, StatusDone)
+	if a.Result != http.StatusNoContent || a.ExecutedAt == nil || a.ExecutedAt.Before(at) || a.CreatedBy != "jdoe" {
+		t.Errorf("unexpected audit record: %+v", a)
+	}
+
+	a = waitStatus(t, s, failing, StatusFailed)
+	if a.Result != http.StatusBadRequest || a.Response != "failed" {
+		t.Errorf("unexpected audit record: %+v", a)
+	}
+
+	received := strings.Join(h.received(), "\n")
+	if !strings.Contains(received, "POST /maintenance?route=r&enabled=true foo") || !strings.Contains(received, "DELETE /fail") {
+		t.Errorf("unexpected requests: %v", received)
+	}
+}
+
+func TestCancel(t *testing.T) {
+	h := &recordingHandler{}
+	s := New(Options{Handler: h})
+	defer s.Close()
+
+	id, err := s.Add(Action{At: time.Now().Add(50 * time.Millisecond), Method: "POST", Path: "/maintenance"}, "jdoe")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !s.Cancel(id, "admin") {
+		t.Fatal("failed to cancel")
+	}
+
+	if s.Cancel(id, "admin") || s.Cancel(id+1, "admin") {
+		t.Error("unexpected cancel")
+	}
+
+	time.Sleep(100 * time.Millisecond)
+	a := waitStatus(t, s, id, StatusCancelled)
+	if a.CancelledBy != "admin" || a.ExecutedAt != nil || len(h.received()) != 0 {
+		t.Errorf("failed to cancel: %+v, %v", a, h.received())
+	}
+}
+
+func TestMaxRecords(t *testing.T) {
+	s := New(Options{Handler: &recordingHandler{}, MaxRecords: 2})
+	defer s.Close()
+
+	pending, err := s.Add(Action{At: time.Now().Add(time.Hour), Method: "POST", Path: "/maintenance"}, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 3; i++ {
+		id, err := s.Add(Action{At: time.Now().Add(time.Hour), Method: "POST", Path: "/maintenance"}, "test")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		s.Cancel(id, "test")
+	}
+
+	l := s.List()
+	if len(l) != 3 || l[0].Id != pending || l[1].Id != pending+2 || l[2].Id != pending+3 {
+		t.Errorf("unexpected records: %+v", l)
+	}
+}
+
+func TestAPI(t *testing.T) {
+	h := &recordingHandler{}
+	s := New(Options{Handler: h})
+	defer s.Close()
+
+	server := httptest.NewServer(s)
+	defer server.Close()
+
+	body := fmt.Sprintf(
+		`{"at": %q, "method": "POST", "path": "/endpoints?endpoint=http://10.0.0.1&state=drained"}`,
+		time.Now().Add(time.Hour).Format(time.RFC3339),
+	)
+
+	req, _ := http.NewRequest("POST", server.URL+Path, strings.NewReader(body))
+	req.Header.Set(UserHeader, "jdoe")
+	rsp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var created struct{ Id int }
+	err = json.NewDecoder(rsp.Body).Decode(&created)
+	rsp.Body.Close()
+	if err != nil || rsp.StatusCode != http.StatusCreated || created.Id != 1 {
+		t.Fatalf("failed to schedule: %d, %v, %+v", rsp.StatusCode, err, created)
+	}
+
+	rsp, err = http.Post(server.URL+Path, "application/json", strings.NewReader(`{"method": "POST"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rsp.Body.Close()
+	if rsp.StatusCode != http.StatusBadRequest {
+		t.Errorf("failed to reject invalid action: %d", rsp.StatusCode)
+	}
+
+	rsp, err = http.Get(server.URL + Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var l []Action
+	err = json.NewDecoder(rsp.Body).Decode(&l)
+	rsp.Body.Close()
+	if err != nil || len(l) != 1 || l[0].Status != StatusScheduled || !strings.HasPrefix(l[0].CreatedBy, "jdoe (") {
+		t.Fatalf("unexpected list: %v, %+v", err, l)
+	}
+
+	for _, tt := range []struct {
+		id     string
+		status int
+	}{
+		{"foo", http.StatusBadRequest},
+		{"1", http.StatusNoContent},
+		{"1", http.StatusNotFound},
+	} {
+		req, _ := http.NewRequest("DELETE", server.URL+Path+"?id="+tt.id, nil)
+		rsp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rsp.Body.Close()
+		if rsp.StatusCode != tt.status {
+			t.Errorf("unexpected status for cancelling %s: %d", tt.id, rsp.StatusCode)
+		}
+	}
+}
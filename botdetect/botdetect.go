@@ -0,0 +1,303 @@
+/*
+Package botdetect implements scoring the requests by how likely they were
+sent by bots or scrapers.
+
+The score is between 0 and 100, calculated from the following signals:
+
+  - the User-Agent header: missing, automation tools, headless browsers or
+    self-declared crawlers
+  - the header fingerprint: the missing headers that the browsers always
+    send, or the inconsistency between the claimed browser and the headers
+  - the TLS fingerprint: when a TLS terminating load balancer forwards the
+    JA3 fingerprint of the client in a header, the fingerprints of known
+    bots
+  - the request rate of the client: more requests in a time window than
+    expected from a human user
+
+The order of the headers is not available from the Go HTTP server, so it
+is not used as a signal.
+*/
+package botdetect
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	snet "github.com/zalando/skipper/net"
+)
+
+const (
+	defaultRateWindow    = 10 * time.Second
+	defaultRateThreshold = 50
+	defaultMaxClients    = 100000
+
+	maxScore = 100
+)
+
+// Names of the signals contributing to the score.
+const (
+	SignalMissingUserAgent      = "missing-user-agent"
+	SignalAutomationTool        = "automation-tool"
+	SignalHeadlessBrowser       = "headless-browser"
+	SignalDeclaredBot           = "declared-bot"
+	SignalMissingAccept         = "missing-accept"
+	SignalMissingAcceptLanguage = "missing-accept-language"
+	SignalMissingAcceptEncoding = "missing-accept-encoding"
+	SignalInconsistentBrowser   = "inconsistent-browser"
+	SignalKnownTLSFingerprint   = "known-tls-fingerprint"
+	SignalRateExceeded          = "rate-exceeded"
+)
+
+var signalScores = map[string]int{
+	SignalMissingUserAgent:      40,
+	SignalAutomationTool:        40,
+	SignalHeadlessBrowser:       50,
+	SignalDeclaredBot:           30,
+	SignalMissingAccept:         10,
+	SignalMissingAcceptLanguage: 15,
+	SignalMissingAcceptEncoding: 10,
+	SignalInconsistentBrowser:   15,
+	SignalKnownTLSFingerprint:   50,
+	SignalRateExceeded:          30,
+}
+
+var (
+	automationTools = []string{
+		"curl/", "wget/", "python-requests", "python-urllib", "aiohttp", "go-http-client",
+		"java/", "okhttp", "apache-httpclient", "libwww-perl", "scrapy", "node-fetch",
+		"axios/", "httpie", "powershell",
+	}
+
+	headlessBrowsers = []string{"headlesschrome", "phantomjs", "selenium", "puppeteer", "playwright"}
+
+	declaredBots = []string{"bot", "crawler", "spider", "slurp", "scraper"}
+)
+
+// Options for the bot detection.
+type Options struct {
+
+	// RateWindow sets the time window of counting the requests of
+	// the clients. Defaults to 10s.
+	RateWindow time.Duration
+
+	// RateThreshold sets how many requests of a client are expected
+	// in a time window, before it is considered a signal of a bot.
+	// Defaults to 50.
+	RateThreshold int
+
+	// MaxClients sets the maximum number of clients whose request
+	// rate is tracked at the same time. Defaults to 100000.
+	MaxClients int
+
+	// JA3Header, when set, is the name of the header containing the
+	// JA3 TLS fingerprint of the client, set by a TLS terminating
+	// load balancer.
+	JA3Header string
+
+	// JA3Fingerprints contains the JA3 fingerprints of known bots.
+	JA3Fingerprints []string
+}
+
+// Result contains the score of a request, and the signals contributing
+// to it.
+type Result struct {
+	Score   int
+	Signals []string
+}
+
+type counter struct {
+	start time.Time
+	count int
+}
+
+// Detector scores the requests, and tracks the request rate of the
+// clients.
+type Detector struct {
+	options  Options
+	ja3      map[string]bool
+	mu       sync.Mutex
+	counters map[string]*counter
+	now      func() time.Time
+	quit     chan struct{}
+	once     sync.Once
+}
+
+// New creates a detector. The detector needs to be closed when not used
+// anymore.
+func New(o Options) *Detector {
+	if o.RateWindow <= 0 {
+		o.RateWindow = defaultRateWindow
+	}
+
+	if o.RateThreshold <= 0 {
+		o.RateThreshold = defaultRateThreshold
+	}
+
+	if o.MaxClients <= 0 {
+		o.MaxClients = defaultMaxClients
+	}
+
+	ja3 := make(map[string]bool)
+	for _, f := range o.JA3Fingerprints {
+		ja3[strings.ToLower(f)] = true
+	}
+
+	d := &Detector{
+		options:  o,
+		ja3:      ja3,
+		counters: make(map[string]*counter),
+		now:      time.Now,
+		quit:     make(chan struct{}),
+	}
+
+	go d.cleanup()
+	return d
+}
+
+func containsAny(s string, tokens []string) bool {
+	for _, t := range tokens {
+		if strings.Contains(s, t) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (d *Detector) headerSignals(r *http.Request) []string {
+	var signals []string
+	ua := strings.ToLower(r.UserAgent())
+	switch {
+	case ua == "":
+		signals = append(signals, SignalMissingUserAgent)
+	case containsAny(ua, headlessBrowsers):
+		signals = append(signals, SignalHeadlessBrowser)
+	case containsAny(ua, automationTools):
+		signals = append(signals, SignalAutomationTool)
+	case containsAny(ua, declaredBots):
+		signals = append(signals, SignalDeclaredBot)
+	}
+
+	if r.Header.Get("Accept") == "" {
+		signals = append(signals, SignalMissingAccept)
+	}
+
+	if r.Header.Get("Accept-Language") == "" {
+		signals = append(signals, SignalMissingAcceptLanguage)
+	}
+
+	if r.Header.Get("Accept-Encoding") == "" {
+		signals = append(signals, SignalMissingAcceptEncoding)
+	}
+
+	// the current browsers claiming to be Chrome send the client hints
+	if strings.Contains(ua, "chrome/") && r.Header.Get("Sec-Ch-Ua") == "" &&
+		r.Header.Get("Sec-Fetch-Mode") == "" {
+		signals = append(signals, SignalInconsistentBrowser)
+	}
+
+	if d.options.JA3Header != "" {
+		if f := r.Header.Get(d.options.JA3Header); f != "" && d.ja3[strings.ToLower(f)] {
+			signals = append(signals, SignalKnownTLSFingerprint)
+		}
+	}
+
+	return signals
+}
+
+func result(signals []string) Result {
+	var score int
+	for _, s := range signals {
+		score += signalScores[s]
+	}
+
+	if score > maxScore {
+		score = maxScore
+	}
+
+	return Result{Score: score, Signals: signals}
+}
+
+// returns the request count of the client in the current window, when
+// observe is set, counting the current request, too
+func (d *Detector) count(client string, observe bool) int {
+	now := d.now()
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	c, ok := d.counters[client]
+	if ok && now.Sub(c.start) >= d.options.RateWindow {
+		c.start, c.count = now, 0
+	}
+
+	if !observe {
+		if !ok {
+			return 0
+		}
+
+		return c.count
+	}
+
+	if !ok {
+		if len(d.counters) >= d.options.MaxClients {
+			return 0
+		}
+
+		c = &counter{start: now}
+		d.counters[client] = c
+	}
+
+	c.count++
+	return c.count
+}
+
+func (d *Detector) score(r *http.Request, observe bool) Result {
+	signals := d.headerSignals(r)
+	if d.count(snet.RemoteHost(r).String(), observe) > d.options.RateThreshold {
+		signals = append(signals, SignalRateExceeded)
+	}
+
+	return result(signals)
+}
+
+// Score returns the score of a request, without counting it for the
+// request rate of the client.
+func (d *Detector) Score(r *http.Request) Result {
+	return d.score(r, false)
+}
+
+// Observe counts the request for the request rate of the client, and
+// returns its score.
+func (d *Detector) Observe(r *http.Request) Result {
+	return d.score(r, true)
+}
+
+func (d *Detector) cleanup() {
+	ticker := time.NewTicker(d.options.RateWindow)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			now := d.now()
+			d.mu.Lock()
+			for client, c := range d.counters {
+				if now.Sub(c.start) >= d.options.RateWindow {
+					delete(d.counters, client)
+				}
+			}
+
+			d.mu.Unlock()
+		case <-d.quit:
+			return
+		}
+	}
+}
+
+// Close stops tracking the request rate of the clients.
+func (d *Detector) Close() {
+	d.once.Do(func() { close(d.quit) })
+}
@@ -0,0 +1,143 @@
+package botdetect
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func browserRequest() *http.Request {
+	r := httptest.NewRequest("GET", "/", nil)
+	r.Header.Set("User-Agent", "Mozilla/5.0 (X11; Linux x86_64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/118.0.0.0 Safari/537.36")
+	r.Header.Set("Accept", "text/html")
+	r.Header.Set("Accept-Language", "en-US")
+	r.Header.Set("Accept-Encoding", "gzip, br")
+	r.Header.Set("Sec-Fetch-Mode", "navigate")
+	return r
+}
+
+func TestScore(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		modify  func(*http.Request)
+		score   int
+		signals []string
+	}{{
+		name:   "browser",
+		modify: func(*http.Request) {},
+	}, {
+		name: "curl",
+		modify: func(r *http.Request) {
+			r.Header = http.Header{"User-Agent": []string{"curl/8.1.2"}, "Accept": []string{"*/*"}}
+		},
+		score:   65,
+		signals: []string{SignalAutomationTool, SignalMissingAcceptLanguage, SignalMissingAcceptEncoding},
+	}, {
+		name:    "missing user agent",
+		modify:  func(r *http.Request) { r.Header.Del("User-Agent") },
+		score:   40,
+		signals: []string{SignalMissingUserAgent},
+	}, {
+		name: "headless browser",
+		modify: func(r *http.Request) {
+			r.Header.Set("User-Agent", "Mozilla/5.0 HeadlessChrome/118.0.0.0")
+		},
+		score:   50,
+		signals: []string{SignalHeadlessBrowser},
+	}, {
+		name:    "declared crawler",
+		modify:  func(r *http.Request) { r.Header.Set("User-Agent", "Mozilla/5.0 (compatible; Googlebot/2.1)") },
+		score:   30,
+		signals: []string{SignalDeclaredBot},
+	}, {
+		name:    "chrome without client hints",
+		modify:  func(r *http.Request) { r.Header.Del("Sec-Fetch-Mode") },
+		score:   15,
+		signals: []string{SignalInconsistentBrowser},
+	}, {
+		name:    "known tls fingerprint",
+		modify:  func(r *http.Request) { r.Header.Set("X-Ja3", "E7D705A3286E19EA42F587B344EE6865") },
+		score:   50,
+		signals: []string{SignalKnownTLSFingerprint},
+	}, {
+		name: "score capped",
+		modify: func(r *http.Request) {
+			r.Header = http.Header{"User-Agent": []string{"python-requests/2.31"}, "X-Ja3": []string{"e7d705a3286e19ea42f587b344ee6865"}}
+		},
+		score:   100,
+		signals: []string{SignalAutomationTool, SignalMissingAccept, SignalMissingAcceptLanguage, SignalMissingAcceptEncoding, SignalKnownTLSFingerprint},
+	}} {
+		t.Run(tt.name, func(t *testing.T) {
+			d := New(Options{JA3Header: "X-Ja3", JA3Fingerprints: []string{"e7d705a3286e19ea42f587b344ee6865"}})
+			defer d.Close()
+
+			r := browserRequest()
+			tt.modify(r)
+
+			result := d.Score(r)
+			if result.Score != tt.score || !reflect.DeepEqual(result.Signals, tt.signals) {
+				t.Errorf("unexpected result: %+v, expected: %d, %v", result, tt.score, tt.signals)
+			}
+		})
+	}
+}
+
+func TestRate(t *testing.T) {
+	d := New(Options{RateWindow: time.Minute, RateThreshold: 3})
+	defer d.Close()
+
+	now := time.Now()
+	d.now = func() time.Time { return now }
+
+	r := browserRequest()
+	r.RemoteAddr = "192.0.2.1:1234"
+	for i := 0; i < 3; i++ {
+		if result := d.Observe(r); result.Score != 0 {
+			t.Fatalf("unexpected score before reaching the threshold: %d", result.Score)
+		}
+	}
+
+	if result := d.Score(r); result.Score != 0 {
+		t.Errorf("unexpected score without observing: %d", result.Score)
+	}
+
+	if result := d.Observe(r); result.Score != 30 || result.Signals[0] != SignalRateExceeded {
+		t.Errorf("failed to detect the rate: %+v", result)
+	}
+
+	other := browserRequest()
+	other.RemoteAddr = "192.0.2.2:1234"
+	if result := d.Observe(other); result.Score != 0 {
+		t.Errorf("unexpected score of another client: %d", result.Score)
+	}
+
+	now = now.Add(time.Minute)
+	if result := d.Observe(r); result.Score != 0 {
+		t.Errorf("failed to reset the rate in a new window: %d", result.Score)
+	}
+}
+
+func TestMaxClients(t *testing.T) {
+	d := New(Options{RateThreshold: 1, MaxClients: 1})
+	defer d.Close()
+
+	r1 := browserRequest()
+	r1.RemoteAddr = "192.0.2.1:1234"
+	r2 := browserRequest()
+	r2.RemoteAddr = "192.0.2.2:1234"
+
+	for i := 0; i < 2; i++ {
+		d.Observe(r1)
+		d.Observe(r2)
+	}
+
+	if len(d.counters) != 1 {
+		t.Errorf("unexpected number of tracked clients: %d", len(d.counters))
+	}
+
+	if result := d.Observe(r2); result.Score != 0 {
+		t.Errorf("unexpected score of an untracked client: %d", result.Score)
+	}
+}
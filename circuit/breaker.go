@@ -53,6 +53,7 @@ type BreakerSettings struct {
 
 type breakerImplementation interface {
 	Allow() (func(bool), bool)
+	State() string
 }
 
 type voidBreaker struct{}
@@ -143,6 +144,10 @@ func (b voidBreaker) Allow() (func(bool), bool) {
 	return func(bool) {}, true
 }
 
+func (b voidBreaker) State() string {
+	return "closed"
+}
+
 func newBreaker(s BreakerSettings) *Breaker {
 	var impl breakerImplementation
 	switch s.Type {
@@ -167,6 +172,12 @@ func (b *Breaker) Allow() (func(bool), bool) {
 	return b.impl.Allow()
 }
 
+// State returns the current state of the breaker: closed, half-open or
+// open.
+func (b *Breaker) State() string {
+	return b.impl.State()
+}
+
 func (b *Breaker) idle(now time.Time) bool {
 	return now.Sub(b.ts) > b.settings.IdleTTL
 }
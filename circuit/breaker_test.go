@@ -184,6 +184,37 @@ func TestRateBreakerFuzzy(t *testing.T) {
 	<-stop
 }
 
+func TestBreakerState(t *testing.T) {
+	for _, typ := range []BreakerType{ConsecutiveFailures, FailureRate} {
+		s := BreakerSettings{
+			Type:             typ,
+			Window:           6,
+			Failures:         3,
+			HalfOpenRequests: 3,
+			Timeout:          15 * time.Millisecond,
+		}
+
+		b := newBreaker(s)
+		if st := b.State(); st != "closed" {
+			t.Errorf("%v: unexpected state of a new breaker: %s", typ, st)
+		}
+
+		times(s.Failures, fail(t, b))
+		if st := b.State(); st != "open" {
+			t.Errorf("%v: unexpected state after failures: %s", typ, st)
+		}
+
+		time.Sleep(s.Timeout)
+		if st := b.State(); st != "half-open" {
+			t.Errorf("%v: unexpected state after the timeout: %s", typ, st)
+		}
+	}
+
+	if st := newBreaker(BreakerSettings{}).State(); st != "closed" {
+		t.Errorf("unexpected state of a void breaker: %s", st)
+	}
+}
+
 func TestSettingsString(t *testing.T) {
 	s := BreakerSettings{
 		Type:             FailureRate,
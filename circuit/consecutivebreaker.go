@@ -43,3 +43,7 @@ func (b *consecutiveBreaker) Allow() (func(bool), bool) {
 	}
 	return done, true
 }
+
+func (b *consecutiveBreaker) State() string {
+	return b.gb.State().String()
+}
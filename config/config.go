@@ -19,6 +19,7 @@ import (
 	"github.com/zalando/skipper"
 	"github.com/zalando/skipper/dataclients/kubernetes"
 	"github.com/zalando/skipper/eskip"
+	"github.com/zalando/skipper/filters/limits"
 	"github.com/zalando/skipper/net"
 	"github.com/zalando/skipper/proxy"
 	routesrv "github.com/zalando/skipper/routesrv"
@@ -31,7 +32,9 @@ type Config struct {
 	// generic:
 	Address                         string         `yaml:"address"`
 	InsecureAddress                 string         `yaml:"insecure-address"`
+	Listeners                       listenerFlags  `yaml:"listeners"`
 	EnableTCPQueue                  bool           `yaml:"enable-tcp-queue"`
+	ReusePortAcceptors              int            `yaml:"reuse-port-acceptors"`
 	ExpectedBytesPerRequest         int            `yaml:"expected-bytes-per-request"`
 	MaxTCPListenerConcurrency       int            `yaml:"max-tcp-listener-concurrency"`
 	MaxTCPListenerQueue             int            `yaml:"max-tcp-listener-queue"`
@@ -46,6 +49,8 @@ type Config struct {
 	StatusChecks                    *listFlag      `yaml:"status-checks"`
 	PrintVersion                    bool           `yaml:"version"`
 	MaxLoopbacks                    int            `yaml:"max-loopbacks"`
+	RetryBudget                     float64        `yaml:"retry-budget"`
+	RetryAfterMaxBackoff            time.Duration  `yaml:"retry-after-max-backoff"`
 	DefaultHTTPStatus               int            `yaml:"default-http-status"`
 	PluginDir                       string         `yaml:"plugindir"`
 	LoadBalancerHealthCheckInterval time.Duration  `yaml:"lb-healthcheck-interval"`
@@ -54,64 +59,106 @@ type Config struct {
 	RfcPatchPath                    bool           `yaml:"rfc-patch-path"`
 	MaxAuditBody                    int            `yaml:"max-audit-body"`
 	MaxMatcherBufferSize            uint64         `yaml:"max-matcher-buffer-size"`
+	WAFMaxBodySize                  int64          `yaml:"waf-max-body-size"`
+	XMLMaxBodySize                  int64          `yaml:"xml-max-body-size"`
+	CacheStore                      string         `yaml:"cache-store"`
+	CacheMemorySize                 int64          `yaml:"cache-memory-size"`
+	CacheMaxBodySize                int64          `yaml:"cache-max-body-size"`
+	AsyncStore                      string         `yaml:"async-store"`
+	AsyncMaxBodySize                int64          `yaml:"async-max-body-size"`
+	AsyncResultTTL                  time.Duration  `yaml:"async-result-ttl"`
 	EnableBreakers                  bool           `yaml:"enable-breakers"`
 	Breakers                        breakerFlags   `yaml:"breaker"`
 	EnableRatelimiters              bool           `yaml:"enable-ratelimits"`
 	Ratelimits                      ratelimitFlags `yaml:"ratelimits"`
 	EnableRouteFIFOMetrics          bool           `yaml:"enable-route-fifo-metrics"`
 	EnableRouteLIFOMetrics          bool           `yaml:"enable-route-lifo-metrics"`
+	EnableMemoryWatchdog            bool           `yaml:"enable-memory-watchdog"`
+	MemoryWatchdogThreshold         float64        `yaml:"memory-watchdog-threshold"`
 	MetricsFlavour                  *listFlag      `yaml:"metrics-flavour"`
 	FilterPlugins                   *pluginFlag    `yaml:"filter-plugin"`
 	PredicatePlugins                *pluginFlag    `yaml:"predicate-plugin"`
 	DataclientPlugins               *pluginFlag    `yaml:"dataclient-plugin"`
 	MultiPlugins                    *pluginFlag    `yaml:"multi-plugin"`
 	CompressEncodings               *listFlag      `yaml:"compress-encodings"`
+	CompressMinSize                 int            `yaml:"compress-min-size"`
+	CompressMinRatio                float64        `yaml:"compress-min-ratio"`
+	CompressBufferSize              int            `yaml:"compress-buffer-size"`
 
 	// logging, metrics, profiling, tracing:
-	EnablePrometheusMetrics             bool      `yaml:"enable-prometheus-metrics"`
-	OpenTracing                         string    `yaml:"opentracing"`
-	OpenTracingInitialSpan              string    `yaml:"opentracing-initial-span"`
-	OpenTracingExcludedProxyTags        string    `yaml:"opentracing-excluded-proxy-tags"`
-	OpenTracingDisableFilterSpans       bool      `yaml:"opentracing-disable-filter-spans"`
-	OpentracingLogFilterLifecycleEvents bool      `yaml:"opentracing-log-filter-lifecycle-events"`
-	OpentracingLogStreamEvents          bool      `yaml:"opentracing-log-stream-events"`
-	OpentracingBackendNameTag           bool      `yaml:"opentracing-backend-name-tag"`
-	MetricsListener                     string    `yaml:"metrics-listener"`
-	MetricsPrefix                       string    `yaml:"metrics-prefix"`
-	EnableProfile                       bool      `yaml:"enable-profile"`
-	BlockProfileRate                    int       `yaml:"block-profile-rate"`
-	MutexProfileFraction                int       `yaml:"mutex-profile-fraction"`
-	MemProfileRate                      int       `yaml:"memory-profile-rate"`
-	DebugGcMetrics                      bool      `yaml:"debug-gc-metrics"`
-	RuntimeMetrics                      bool      `yaml:"runtime-metrics"`
-	ServeRouteMetrics                   bool      `yaml:"serve-route-metrics"`
-	ServeRouteCounter                   bool      `yaml:"serve-route-counter"`
-	ServeHostMetrics                    bool      `yaml:"serve-host-metrics"`
-	ServeHostCounter                    bool      `yaml:"serve-host-counter"`
-	ServeMethodMetric                   bool      `yaml:"serve-method-metric"`
-	ServeStatusCodeMetric               bool      `yaml:"serve-status-code-metric"`
-	BackendHostMetrics                  bool      `yaml:"backend-host-metrics"`
-	AllFiltersMetrics                   bool      `yaml:"all-filters-metrics"`
-	CombinedResponseMetrics             bool      `yaml:"combined-response-metrics"`
-	RouteResponseMetrics                bool      `yaml:"route-response-metrics"`
-	RouteBackendErrorCounters           bool      `yaml:"route-backend-error-counters"`
-	RouteStreamErrorCounters            bool      `yaml:"route-stream-error-counters"`
-	RouteBackendMetrics                 bool      `yaml:"route-backend-metrics"`
-	RouteCreationMetrics                bool      `yaml:"route-creation-metrics"`
-	MetricsUseExpDecaySample            bool      `yaml:"metrics-exp-decay-sample"`
-	HistogramMetricBucketsString        string    `yaml:"histogram-metric-buckets"`
-	HistogramMetricBuckets              []float64 `yaml:"-"`
-	DisableMetricsCompat                bool      `yaml:"disable-metrics-compat"`
-	ApplicationLog                      string    `yaml:"application-log"`
-	ApplicationLogLevel                 log.Level `yaml:"-"`
-	ApplicationLogLevelString           string    `yaml:"application-log-level"`
-	ApplicationLogPrefix                string    `yaml:"application-log-prefix"`
-	ApplicationLogJSONEnabled           bool      `yaml:"application-log-json-enabled"`
-	AccessLog                           string    `yaml:"access-log"`
-	AccessLogDisabled                   bool      `yaml:"access-log-disabled"`
-	AccessLogJSONEnabled                bool      `yaml:"access-log-json-enabled"`
-	AccessLogStripQuery                 bool      `yaml:"access-log-strip-query"`
-	SuppressRouteUpdateLogs             bool      `yaml:"suppress-route-update-logs"`
+	EnablePrometheusMetrics             bool          `yaml:"enable-prometheus-metrics"`
+	OpenTracing                         string        `yaml:"opentracing"`
+	OpenTracingInitialSpan              string        `yaml:"opentracing-initial-span"`
+	OpenTracingExcludedProxyTags        string        `yaml:"opentracing-excluded-proxy-tags"`
+	OpenTracingDisableFilterSpans       bool          `yaml:"opentracing-disable-filter-spans"`
+	OpentracingLogFilterLifecycleEvents bool          `yaml:"opentracing-log-filter-lifecycle-events"`
+	OpentracingLogStreamEvents          bool          `yaml:"opentracing-log-stream-events"`
+	OpentracingBackendNameTag           bool          `yaml:"opentracing-backend-name-tag"`
+	MetricsListener                     string        `yaml:"metrics-listener"`
+	MetricsPrefix                       string        `yaml:"metrics-prefix"`
+	EnableProfile                       bool          `yaml:"enable-profile"`
+	BlockProfileRate                    int           `yaml:"block-profile-rate"`
+	MutexProfileFraction                int           `yaml:"mutex-profile-fraction"`
+	MemProfileRate                      int           `yaml:"memory-profile-rate"`
+	DebugGcMetrics                      bool          `yaml:"debug-gc-metrics"`
+	RuntimeMetrics                      bool          `yaml:"runtime-metrics"`
+	ServeRouteMetrics                   bool          `yaml:"serve-route-metrics"`
+	ServeRouteCounter                   bool          `yaml:"serve-route-counter"`
+	ServeHostMetrics                    bool          `yaml:"serve-host-metrics"`
+	ServeHostCounter                    bool          `yaml:"serve-host-counter"`
+	ServeMethodMetric                   bool          `yaml:"serve-method-metric"`
+	ServeStatusCodeMetric               bool          `yaml:"serve-status-code-metric"`
+	BackendHostMetrics                  bool          `yaml:"backend-host-metrics"`
+	AllFiltersMetrics                   bool          `yaml:"all-filters-metrics"`
+	CombinedResponseMetrics             bool          `yaml:"combined-response-metrics"`
+	RouteResponseMetrics                bool          `yaml:"route-response-metrics"`
+	RouteBackendErrorCounters           bool          `yaml:"route-backend-error-counters"`
+	RouteStreamErrorCounters            bool          `yaml:"route-stream-error-counters"`
+	RouteBackendMetrics                 bool          `yaml:"route-backend-metrics"`
+	RouteCreationMetrics                bool          `yaml:"route-creation-metrics"`
+	RequestAnomalyMetrics               bool          `yaml:"request-anomaly-metrics"`
+	RequestAnomalyMaxHeaderSize         int           `yaml:"request-anomaly-max-header-size"`
+	MetricsUseExpDecaySample            bool          `yaml:"metrics-exp-decay-sample"`
+	HistogramMetricBucketsString        string        `yaml:"histogram-metric-buckets"`
+	HistogramMetricBuckets              []float64     `yaml:"-"`
+	DisableMetricsCompat                bool          `yaml:"disable-metrics-compat"`
+	ApplicationLog                      string        `yaml:"application-log"`
+	ApplicationLogLevel                 log.Level     `yaml:"-"`
+	ApplicationLogLevelString           string        `yaml:"application-log-level"`
+	ApplicationLogPrefix                string        `yaml:"application-log-prefix"`
+	ApplicationLogJSONEnabled           bool          `yaml:"application-log-json-enabled"`
+	AccessLog                           string        `yaml:"access-log"`
+	AccessLogDisabled                   bool          `yaml:"access-log-disabled"`
+	AccessLogJSONEnabled                bool          `yaml:"access-log-json-enabled"`
+	AccessLogStripQuery                 bool          `yaml:"access-log-strip-query"`
+	AccessLogIdentityFields             *listFlag     `yaml:"access-log-identity-fields"`
+	AccessLogMaxSize                    int64         `yaml:"access-log-max-size"`
+	AccessLogMaxAge                     time.Duration `yaml:"access-log-max-age"`
+	AccessLogMaxBackups                 int           `yaml:"access-log-max-backups"`
+	AccessLogBatchSize                  int           `yaml:"access-log-batch-size"`
+	AccessLogFlushInterval              time.Duration `yaml:"access-log-flush-interval"`
+	CostAccounting                      bool          `yaml:"cost-accounting"`
+	CostAccountingExport                string        `yaml:"cost-accounting-export"`
+	CostAccountingExportInterval        time.Duration `yaml:"cost-accounting-export-interval"`
+	CostAccountingMaxTenants            int           `yaml:"cost-accounting-max-tenants"`
+	SuppressRouteUpdateLogs             bool          `yaml:"suppress-route-update-logs"`
+	LazyFilters                         *listFlag     `yaml:"lazy-filters"`
+	WarmUpLazyFilters                   bool          `yaml:"warm-up-lazy-filters"`
+	AtomicRouteUpdates                  bool          `yaml:"atomic-route-updates"`
+	UnusedRouteThreshold                time.Duration `yaml:"unused-route-threshold"`
+
+	// event sink:
+	EventSinkKafkaBrokers          *listFlag     `yaml:"event-sink-kafka-brokers"`
+	EventSinkKafkaTopic            string        `yaml:"event-sink-kafka-topic"`
+	EventSinkKafkaTLS              bool          `yaml:"event-sink-kafka-tls"`
+	EventSinkKafkaTLSCAFile        string        `yaml:"event-sink-kafka-tls-ca-file"`
+	EventSinkKafkaSASLMechanism    string        `yaml:"event-sink-kafka-sasl-mechanism"`
+	EventSinkKafkaSASLUsername     string        `yaml:"event-sink-kafka-sasl-username"`
+	EventSinkKafkaSASLPasswordFile string        `yaml:"event-sink-kafka-sasl-password-file"`
+	EventSinkBufferSize            int           `yaml:"event-sink-buffer-size"`
+	EventSinkBatchSize             int           `yaml:"event-sink-batch-size"`
+	EventSinkFlushInterval         time.Duration `yaml:"event-sink-flush-interval"`
+	EventSinkBlockTimeout          time.Duration `yaml:"event-sink-block-timeout"`
 
 	// route sources:
 	EtcdUrls                  string               `yaml:"etcd-urls"`
@@ -128,24 +175,77 @@ type Config struct {
 	RoutesFile                string               `yaml:"routes-file"`
 	RoutesURLs                *listFlag            `yaml:"routes-urls"`
 	InlineRoutes              string               `yaml:"inline-routes"`
+	EnableRouteSnapshotImport bool                 `yaml:"enable-route-snapshot-import"`
 	AppendFilters             *defaultFiltersFlags `yaml:"default-filters-append"`
 	PrependFilters            *defaultFiltersFlags `yaml:"default-filters-prepend"`
 	DisabledFilters           *listFlag            `yaml:"disabled-filters"`
+	PredicateCostsString      mapFlags             `yaml:"predicate-costs"`
+	PredicateCosts            map[string]int       `yaml:"-"`
+	DefaultAllowedMethods     *listFlag            `yaml:"default-allowed-methods"`
+	DefaultMaxURLLength       int                  `yaml:"default-max-url-length"`
+	DefaultMaxHeaderCount     int                  `yaml:"default-max-header-count"`
+	DefaultMaxHeaderSize      int                  `yaml:"default-max-header-size"`
+	MaxRequestBodySize        int64                `yaml:"max-request-body-size"`
+	ForwardEarlyHints         bool                 `yaml:"forward-early-hints"`
+	BackendStripHeaders       *listFlag            `yaml:"backend-strip-headers"`
+	BackendMaxHeaderSize      int                  `yaml:"backend-max-header-size"`
+	BackendFoldHeaders        bool                 `yaml:"backend-fold-duplicate-headers"`
+	ClientDisconnectGrace     time.Duration        `yaml:"client-disconnect-grace-period"`
+	DetectStreaming           bool                 `yaml:"detect-streaming-requests"`
+	StreamingHeaderTimeout    time.Duration        `yaml:"streaming-response-header-timeout"`
+	StreamingIdleTimeout      time.Duration        `yaml:"streaming-idle-timeout"`
 	EditRoute                 routeChangerConfig   `yaml:"edit-route"`
 	CloneRoute                routeChangerConfig   `yaml:"clone-route"`
+	RouteRulesFile            string               `yaml:"route-rules-file"`
+	ProfilesFile              string               `yaml:"profiles-file"`
 	SourcePollTimeout         int64                `yaml:"source-poll-timeout"`
 	WaitFirstRouteLoad        bool                 `yaml:"wait-first-route-load"`
 
+	// GeoIP:
+	GeoIPDatabases      *listFlag     `yaml:"geoip-databases"`
+	GeoIPReloadInterval time.Duration `yaml:"geoip-reload-interval"`
+
+	// Bot detection:
+	EnableBotDetection          bool          `yaml:"enable-bot-detection"`
+	BotDetectionRateWindow      time.Duration `yaml:"bot-detection-rate-window"`
+	BotDetectionRateThreshold   int           `yaml:"bot-detection-rate-threshold"`
+	BotDetectionJA3Header       string        `yaml:"bot-detection-ja3-header"`
+	BotDetectionJA3Fingerprints *listFlag     `yaml:"bot-detection-ja3-fingerprints"`
+	ChallengeSecretFile         string        `yaml:"challenge-secret-file"`
+
+	// Source from file:
+	SourceFromFileReloadInterval time.Duration `yaml:"source-from-file-reload-interval"`
+
 	// Forwarded headers
 	ForwardedHeadersList            *listFlag            `yaml:"forwarded-headers"`
 	ForwardedHeaders                net.ForwardedHeaders `yaml:"-"`
 	ForwardedHeadersExcludeCIDRList *listFlag            `yaml:"forwarded-headers-exclude-cidrs"`
 	ForwardedHeadersExcludeCIDRs    net.IPNets           `yaml:"-"`
 
+	// client IP resolution:
+	ClientIPTrustedProxiesList *listFlag  `yaml:"client-ip-trusted-proxies"`
+	ClientIPTrustedProxies     net.IPNets `yaml:"-"`
+	ClientIPTrustedHops        int        `yaml:"client-ip-trusted-hops"`
+	ClientIPHeader             string     `yaml:"client-ip-header"`
+
 	// host patch:
 	NormalizeHost bool          `yaml:"normalize-host"`
 	HostPatch     net.HostPatch `yaml:"-"`
 
+	// request canonicalization:
+	CanonicalizeRequestList *listFlag            `yaml:"canonicalize-request"`
+	Canonicalization        net.Canonicalization `yaml:"-"`
+
+	// strict HTTP parsing:
+	StrictHTTPParsingString string                 `yaml:"strict-http-parsing"`
+	StrictHTTPParsing       net.StrictParsingLevel `yaml:"-"`
+
+	// PROXY protocol:
+	ProxyProtocol                bool       `yaml:"proxy-protocol"`
+	ProxyProtocolTrustedCIDRList *listFlag  `yaml:"proxy-protocol-trusted-cidrs"`
+	ProxyProtocolTrustedNetworks net.IPNets `yaml:"-"`
+	EnableTLSPassthrough         bool       `yaml:"enable-tls-passthrough"`
+
 	ValidateQuery    bool      `yaml:"validate-query"`
 	ValidateQueryLog bool      `yaml:"validate-query-log"`
 	RefusePayload    multiFlag `yaml:"refuse-payload"`
@@ -213,6 +313,11 @@ type Config struct {
 	// TLS version
 	TLSMinVersion string `yaml:"tls-min-version"`
 
+	// TLS client authentication
+	ClientCAPathTLS     string             `yaml:"tls-client-ca"`
+	ClientAuthTLSString string             `yaml:"tls-client-auth"`
+	ClientAuthTLS       tls.ClientAuthType `yaml:"-"`
+
 	// TLS Config
 	KubernetesEnableTLS bool `yaml:"kubernetes-enable-tls"`
 
@@ -225,8 +330,20 @@ type Config struct {
 
 	// connections, timeouts:
 	WaitForHealthcheckInterval   time.Duration `yaml:"wait-for-healthcheck-interval"`
+	DrainDuration                time.Duration `yaml:"drain-duration"`
+	DrainCloseConnections        bool          `yaml:"drain-close-connections"`
+	DrainRejectRequests          bool          `yaml:"drain-reject-requests"`
+	DrainRetryAfter              time.Duration `yaml:"drain-retry-after"`
 	IdleConnsPerHost             int           `yaml:"idle-conns-num"`
 	CloseIdleConnsPeriod         time.Duration `yaml:"close-idle-conns-period"`
+	FastCgiMaxIdleConns          int           `yaml:"fastcgi-max-idle-conns"`
+	FastCgiIdleConnTimeout       time.Duration `yaml:"fastcgi-idle-conn-timeout"`
+	DNSCacheTTL                  time.Duration `yaml:"dns-cache-ttl"`
+	DNSStaleTTL                  time.Duration `yaml:"dns-stale-ttl"`
+	DNSServers                   *listFlag     `yaml:"dns-servers"`
+	DNSRespectTTL                bool          `yaml:"dns-respect-ttl"`
+	ConnectionPrewarming         bool          `yaml:"connection-prewarming"`
+	PrewarmMaxIdle               time.Duration `yaml:"prewarm-max-idle"`
 	BackendFlushInterval         time.Duration `yaml:"backend-flush-interval"`
 	ExperimentalUpgrade          bool          `yaml:"experimental-upgrade"`
 	ExperimentalUpgradeAudit     bool          `yaml:"experimental-upgrade-audit"`
@@ -239,10 +356,16 @@ type Config struct {
 	TimeoutBackend               time.Duration `yaml:"timeout-backend"`
 	KeepaliveBackend             time.Duration `yaml:"keepalive-backend"`
 	EnableDualstackBackend       bool          `yaml:"enable-dualstack-backend"`
+	HappyEyeballsBackend         bool          `yaml:"happy-eyeballs-backend"`
+	PreferIPv4Backend            bool          `yaml:"prefer-ipv4-backend"`
+	DialFallbackDelayBackend     time.Duration `yaml:"dial-fallback-delay-backend"`
 	TlsHandshakeTimeoutBackend   time.Duration `yaml:"tls-timeout-backend"`
 	ResponseHeaderTimeoutBackend time.Duration `yaml:"response-header-timeout-backend"`
 	ExpectContinueTimeoutBackend time.Duration `yaml:"expect-continue-timeout-backend"`
 	MaxIdleConnsBackend          int           `yaml:"max-idle-connection-backend"`
+	MaxConnsPerHostBackend       int           `yaml:"max-conns-per-host-backend"`
+	SSEKeepAliveInterval         time.Duration `yaml:"sse-keepalive-interval"`
+	BackendConnectionMetrics     bool          `yaml:"backend-connection-metrics"`
 	DisableHTTPKeepalives        bool          `yaml:"disable-http-keepalives"`
 
 	// swarm:
@@ -293,12 +416,27 @@ func NewConfig() *Config {
 	cfg.AppendFilters = &defaultFiltersFlags{}
 	cfg.PrependFilters = &defaultFiltersFlags{}
 	cfg.DisabledFilters = commaListFlag()
+	cfg.BackendStripHeaders = commaListFlag()
+	cfg.DNSServers = commaListFlag()
+	cfg.DefaultAllowedMethods = commaListFlag()
+	cfg.GeoIPDatabases = commaListFlag()
+	cfg.BotDetectionJA3Fingerprints = commaListFlag()
+	cfg.LazyFilters = commaListFlag()
+	cfg.EventSinkKafkaBrokers = commaListFlag()
+	cfg.AccessLogIdentityFields = commaListFlag(
+		"subject", "subject:hash", "subject:mask",
+		"realm", "realm:hash", "realm:mask",
+		"client-id", "client-id:hash", "client-id:mask",
+	)
 	cfg.CloneRoute = routeChangerConfig{}
 	cfg.EditRoute = routeChangerConfig{}
 	cfg.KubernetesEastWestRangeDomains = commaListFlag()
 	cfg.RoutesURLs = commaListFlag()
 	cfg.ForwardedHeadersList = commaListFlag()
 	cfg.ForwardedHeadersExcludeCIDRList = commaListFlag()
+	cfg.CanonicalizeRequestList = commaListFlag()
+	cfg.ClientIPTrustedProxiesList = commaListFlag()
+	cfg.ProxyProtocolTrustedCIDRList = commaListFlag()
 	cfg.CompressEncodings = commaListFlag("gzip", "deflate", "br")
 	cfg.LuaModules = commaListFlag()
 
@@ -307,7 +445,9 @@ func NewConfig() *Config {
 	// generic:
 	flag.StringVar(&cfg.Address, "address", ":9090", "network address that skipper should listen on")
 	flag.StringVar(&cfg.InsecureAddress, "insecure-address", "", "insecure network address that skipper should listen on when TLS is enabled")
+	flag.Var(&cfg.Listeners, "listener", listenerUsage)
 	flag.BoolVar(&cfg.EnableTCPQueue, "enable-tcp-queue", false, "enable the TCP listener queue")
+	flag.IntVar(&cfg.ReusePortAcceptors, "reuse-port-acceptors", 0, "when greater than 1, opens the set number of sockets with SO_REUSEPORT on the address of each proxy listener, each with its own accept loop, to spread the accepting of the connections across the CPU cores")
 	flag.IntVar(&cfg.ExpectedBytesPerRequest, "expected-bytes-per-request", 50*1024, "bytes per request, that is used to calculate concurrency limits to buffer connection spikes")
 	flag.IntVar(&cfg.MaxTCPListenerConcurrency, "max-tcp-listener-concurrency", 0, "sets hardcoded max for TCP listener concurrency, normally calculated based on available memory cgroups with max TODO")
 	flag.IntVar(&cfg.MaxTCPListenerQueue, "max-tcp-listener-queue", 0, "sets hardcoded max queue size for TCP listener, normally calculated 10x concurrency with max TODO:50k")
@@ -319,9 +459,13 @@ func NewConfig() *Config {
 	flag.StringVar(&cfg.DebugListener, "debug-listener", "", "when this address is set, skipper starts an additional listener returning the original and transformed requests")
 	flag.StringVar(&cfg.CertPathTLS, "tls-cert", "", "the path on the local filesystem to the certificate file(s) (including any intermediates), multiple may be given comma separated")
 	flag.StringVar(&cfg.KeyPathTLS, "tls-key", "", "the path on the local filesystem to the certificate's private key file(s), multiple keys may be given comma separated - the order must match the certs")
+	flag.StringVar(&cfg.ClientCAPathTLS, "tls-client-ca", "", "the path on the local filesystem to the CA certificate file(s) used to verify the TLS client certificates, multiple may be given comma separated")
+	flag.StringVar(&cfg.ClientAuthTLSString, "tls-client-auth", "", "policy for the TLS client certificates, possible values: none, request, require-any, verify-if-given, require-and-verify, defaults to verify-if-given when -tls-client-ca is set, otherwise to none")
 	flag.Var(cfg.StatusChecks, "status-checks", "experimental URLs to check before reporting healthy on startup")
 	flag.BoolVar(&cfg.PrintVersion, "version", false, "print Skipper version")
 	flag.IntVar(&cfg.MaxLoopbacks, "max-loopbacks", proxy.DefaultMaxLoopbacks, "maximum number of loopbacks for an incoming request, set to -1 to disable loopbacks")
+	flag.Float64Var(&cfg.RetryBudget, "retry-budget", proxy.DefaultRetryBudget, "maximum ratio of the retries to the requests of the routes with the retryPolicy filter, set to -1 to disable the retry budget")
+	flag.DurationVar(&cfg.RetryAfterMaxBackoff, "retry-after-max-backoff", 0, "enables avoiding the load balanced endpoints that respond with 429 or 503 and a Retry-After header for the requested time, at most for the given duration, 0 disables it")
 	flag.IntVar(&cfg.DefaultHTTPStatus, "default-http-status", http.StatusNotFound, "default HTTP status used when no route is found for a request")
 	flag.StringVar(&cfg.PluginDir, "plugindir", "", "set the directory to load plugins from, default is ./")
 	flag.DurationVar(&cfg.LoadBalancerHealthCheckInterval, "lb-healthcheck-interval", 0, "use to set the health checker interval to check healthiness of former dead or unhealthy routes")
@@ -330,18 +474,31 @@ func NewConfig() *Config {
 	flag.BoolVar(&cfg.RfcPatchPath, "rfc-patch-path", false, "patches the incoming request path to preserve uncoded reserved characters according to RFC 2616 and RFC 3986")
 	flag.IntVar(&cfg.MaxAuditBody, "max-audit-body", 1024, "sets the max body to read to log in the audit log body")
 	flag.Uint64Var(&cfg.MaxMatcherBufferSize, "max-matcher-buffer-size", 2097152, "sets the maximum read size of the body read by the block filter, default is 2MiB")
+	flag.Int64Var(&cfg.WAFMaxBodySize, "waf-max-body-size", 0, "sets how many bytes of the request bodies are inspected by the waf filter, default is 8KiB")
+	flag.Int64Var(&cfg.XMLMaxBodySize, "xml-max-body-size", 0, "sets the maximum size of the bodies processed by the XML filters, default is 1MiB")
+	flag.StringVar(&cfg.CacheStore, "cache-store", "memory", "sets the store of the responses cached by the cache filter, <memory|redis>, redis requires the swarm redis settings")
+	flag.Int64Var(&cfg.CacheMemorySize, "cache-memory-size", 0, "sets the maximum total size of the responses cached in memory by the cache filter, default is 64MiB")
+	flag.Int64Var(&cfg.CacheMaxBodySize, "cache-max-body-size", 0, "sets the maximum size of the responses cached by the cache filter, default is 1MiB")
+	flag.StringVar(&cfg.AsyncStore, "async-store", "memory", "sets the store of the results of the asyncRequest filter, <memory|redis>, redis requires the swarm redis settings")
+	flag.Int64Var(&cfg.AsyncMaxBodySize, "async-max-body-size", 0, "sets the maximum size of the requests accepted and the responses stored by the asyncRequest filter, default is 1MiB")
+	flag.DurationVar(&cfg.AsyncResultTTL, "async-result-ttl", 0, "sets how long the results of the asyncRequest filter are kept, default is 1h")
 	flag.BoolVar(&cfg.EnableBreakers, "enable-breakers", false, enableBreakersUsage)
 	flag.Var(&cfg.Breakers, "breaker", breakerUsage)
 	flag.BoolVar(&cfg.EnableRatelimiters, "enable-ratelimits", false, enableRatelimitsUsage)
 	flag.Var(&cfg.Ratelimits, "ratelimits", ratelimitsUsage)
 	flag.BoolVar(&cfg.EnableRouteFIFOMetrics, "enable-route-fifo-metrics", false, "enable metrics for the individual route FIFO queues")
 	flag.BoolVar(&cfg.EnableRouteLIFOMetrics, "enable-route-lifo-metrics", false, "enable metrics for the individual route LIFO queues")
+	flag.BoolVar(&cfg.EnableMemoryWatchdog, "enable-memory-watchdog", false, "enables rejecting the queued requests and releasing the cached responses and pooled buffers when the memory usage gets close to the limit set by GOMEMLIMIT")
+	flag.Float64Var(&cfg.MemoryWatchdogThreshold, "memory-watchdog-threshold", 0.9, "ratio of the memory limit above which the memory is considered under pressure")
 	flag.Var(cfg.MetricsFlavour, "metrics-flavour", "Metrics flavour is used to change the exposed metrics format. Supported metric formats: 'codahale' and 'prometheus', you can select both of them")
 	flag.Var(cfg.FilterPlugins, "filter-plugin", "set a custom filter plugins to load, a comma separated list of name and arguments")
 	flag.Var(cfg.PredicatePlugins, "predicate-plugin", "set a custom predicate plugins to load, a comma separated list of name and arguments")
 	flag.Var(cfg.DataclientPlugins, "dataclient-plugin", "set a custom dataclient plugins to load, a comma separated list of name and arguments")
 	flag.Var(cfg.MultiPlugins, "multi-plugin", "set a custom multitype plugins to load, a comma separated list of name and arguments")
 	flag.Var(cfg.CompressEncodings, "compress-encodings", "set encodings supported for compression, the order defines priority when Accept-Header has equal quality values, see RFC 7231 section 5.3.1")
+	flag.IntVar(&cfg.CompressMinSize, "compress-min-size", 0, "sets the default minimum size of the response bodies compressed by the compress filter")
+	flag.Float64Var(&cfg.CompressMinRatio, "compress-min-ratio", 0, "sets the default minimum ratio of the size saved by the compress filter, e.g. 0.1, measured on the beginning of the response bodies, zero disables the check")
+	flag.IntVar(&cfg.CompressBufferSize, "compress-buffer-size", 8192, "sets the maximum number of bytes buffered from the response bodies by the compress filter to decide whether to compress them")
 
 	// logging, metrics, tracing:
 	flag.BoolVar(&cfg.EnablePrometheusMetrics, "enable-prometheus-metrics", false, "*Deprecated*: use metrics-flavour. Switch to Prometheus metrics format to expose metrics")
@@ -374,6 +531,8 @@ func NewConfig() *Config {
 	flag.BoolVar(&cfg.RouteStreamErrorCounters, "route-stream-error-counters", false, "enables counting streaming errors for each route")
 	flag.BoolVar(&cfg.RouteBackendMetrics, "route-backend-metrics", false, "enables reporting backend response time metrics for each route")
 	flag.BoolVar(&cfg.RouteCreationMetrics, "route-creation-metrics", false, "enables reporting for route creation times")
+	flag.BoolVar(&cfg.RequestAnomalyMetrics, "request-anomaly-metrics", false, "enables counting the malformed or abnormal requests by the reason and the route")
+	flag.IntVar(&cfg.RequestAnomalyMaxHeaderSize, "request-anomaly-max-header-size", 0, "size of the request headers in bytes, above which the requests are counted as anomalies, 8192 when not set")
 	flag.BoolVar(&cfg.MetricsUseExpDecaySample, "metrics-exp-decay-sample", false, "use exponentially decaying sample in metrics")
 	flag.StringVar(&cfg.HistogramMetricBucketsString, "histogram-metric-buckets", "", "use custom buckets for prometheus histograms, must be a comma-separated list of numbers")
 	flag.BoolVar(&cfg.DisableMetricsCompat, "disable-metrics-compat", false, "disables the default true value for all-filters-metrics, route-response-metrics, route-backend-errorCounters and route-stream-error-counters")
@@ -381,11 +540,39 @@ func NewConfig() *Config {
 	flag.StringVar(&cfg.ApplicationLogLevelString, "application-log-level", "INFO", "log level for application logs, possible values: PANIC, FATAL, ERROR, WARN, INFO, DEBUG")
 	flag.StringVar(&cfg.ApplicationLogPrefix, "application-log-prefix", "[APP]", "prefix for each log entry")
 	flag.BoolVar(&cfg.ApplicationLogJSONEnabled, "application-log-json-enabled", false, "when this flag is set, log in JSON format is used")
-	flag.StringVar(&cfg.AccessLog, "access-log", "", "output file for the access log, When not set, /dev/stderr is used")
+	flag.StringVar(&cfg.AccessLog, "access-log", "", "output for the access log: a file, syslog://host:port, syslog+tcp://host:port, syslog+unix:///path, or an http or https URL receiving the entries in batches. When not set, /dev/stderr is used")
 	flag.BoolVar(&cfg.AccessLogDisabled, "access-log-disabled", false, "when this flag is set, no access log is printed")
 	flag.BoolVar(&cfg.AccessLogJSONEnabled, "access-log-json-enabled", false, "when this flag is set, log in JSON format is used")
 	flag.BoolVar(&cfg.AccessLogStripQuery, "access-log-strip-query", false, "when this flag is set, the access log strips the query strings from the access log")
+	flag.Var(cfg.AccessLogIdentityFields, "access-log-identity-fields", "comma separated list of the caller identity fields published by the auth filters to include in the access log: subject, realm, client-id, optionally masked with the :hash or :mask suffix, e.g. subject:hash,realm")
+	flag.Int64Var(&cfg.AccessLogMaxSize, "access-log-max-size", 0, "when set, the access log file is rotated when its size would exceed this number of bytes")
+	flag.DurationVar(&cfg.AccessLogMaxAge, "access-log-max-age", 0, "when set, the access log file is rotated after it was written for this duration")
+	flag.IntVar(&cfg.AccessLogMaxBackups, "access-log-max-backups", 0, "maximum number of the rotated access log files kept, all of them are kept when 0")
+	flag.IntVar(&cfg.AccessLogBatchSize, "access-log-batch-size", 0, "maximum number of the access log entries posted at once to an http or https access log destination, defaults to 100 when 0")
+	flag.DurationVar(&cfg.AccessLogFlushInterval, "access-log-flush-interval", 0, "interval of posting the buffered access log entries to an http or https access log destination, defaults to 1s when 0")
+	flag.BoolVar(&cfg.CostAccounting, "cost-accounting", false, "enables accounting the requests, the bytes in and out, and the backend time of the tenants set by the tenantKey filter")
+	flag.StringVar(&cfg.CostAccountingExport, "cost-accounting-export", "", "file or http or https URL receiving the usage of the tenants periodically")
+	flag.DurationVar(&cfg.CostAccountingExportInterval, "cost-accounting-export-interval", 0, "interval of exporting the usage of the tenants, defaults to 1m when 0")
+	flag.IntVar(&cfg.CostAccountingMaxTenants, "cost-accounting-max-tenants", 0, "maximum number of the accounted tenants, the usage of the rest is accounted as _other, defaults to 10000 when 0")
 	flag.BoolVar(&cfg.SuppressRouteUpdateLogs, "suppress-route-update-logs", false, "print only summaries on route updates/deletes")
+	flag.Var(cfg.LazyFilters, "lazy-filters", "comma separated list of filters whose instances are created only when their route first matches, e.g. lua,jwtValidation")
+	flag.BoolVar(&cfg.WarmUpLazyFilters, "warm-up-lazy-filters", false, "create the instances of the lazy filters in the background after the routing updates")
+	flag.BoolVar(&cfg.AtomicRouteUpdates, "atomic-route-updates", false, "reject the routing updates containing invalid routes, and keep the previous routing table instead")
+	flag.DurationVar(&cfg.UnusedRouteThreshold, "unused-route-threshold", 0, "when set, the number of the routes that didn't handle any request for longer than this duration is reported as the routes.unused metric, and it is the default threshold of the unused routes listed by the routes API")
+
+	// Event sink:
+	flag.Var(cfg.EventSinkKafkaBrokers, "event-sink-kafka-brokers", "comma separated list of Kafka brokers, when set, the access and audit events are streamed to the topic set by -event-sink-kafka-topic")
+	flag.StringVar(&cfg.EventSinkKafkaTopic, "event-sink-kafka-topic", "", "Kafka topic receiving the access and audit events")
+	flag.BoolVar(&cfg.EventSinkKafkaTLS, "event-sink-kafka-tls", false, "enables TLS for the connections to the Kafka brokers")
+	flag.StringVar(&cfg.EventSinkKafkaTLSCAFile, "event-sink-kafka-tls-ca-file", "", "file with the PEM encoded CA certificates used to verify the Kafka brokers, the system CAs are used when not set")
+	flag.StringVar(&cfg.EventSinkKafkaSASLMechanism, "event-sink-kafka-sasl-mechanism", "", "SASL mechanism used to authenticate with the Kafka brokers: PLAIN, SCRAM-SHA-256 or SCRAM-SHA-512")
+	flag.StringVar(&cfg.EventSinkKafkaSASLUsername, "event-sink-kafka-sasl-username", "", "SASL username used to authenticate with the Kafka brokers")
+	flag.StringVar(&cfg.EventSinkKafkaSASLPasswordFile, "event-sink-kafka-sasl-password-file", "", "file containing the SASL password used to authenticate with the Kafka brokers")
+	flag.IntVar(&cfg.EventSinkBufferSize, "event-sink-buffer-size", 0, "maximum number of events buffered before they are sent, defaults to 1024 when 0")
+	flag.IntVar(&cfg.EventSinkBatchSize, "event-sink-batch-size", 0, "maximum number of events sent at once, defaults to 100 when 0")
+	flag.DurationVar(&cfg.EventSinkFlushInterval, "event-sink-flush-interval", 0, "interval of sending the buffered events, defaults to 1s when 0")
+	flag.DurationVar(&cfg.EventSinkBlockTimeout, "event-sink-block-timeout", 0, "maximum time to wait for free space when the event buffer is full, before dropping the event. Dropping immediately when 0")
+	flag.Var(&cfg.PredicateCostsString, "predicate-costs", "relative evaluation costs of the custom predicates as key-value pairs, e.g. Cookie=1,QueryParam=20, the cheaper predicates are evaluated first")
 
 	// route sources:
 	flag.StringVar(&cfg.EtcdUrls, "etcd-urls", "", "urls of nodes in an etcd cluster, storing route definitions")
@@ -402,14 +589,44 @@ func NewConfig() *Config {
 	flag.StringVar(&cfg.RoutesFile, "routes-file", "", "file containing route definitions")
 	flag.Var(cfg.RoutesURLs, "routes-urls", "comma separated URLs to route definitions in eskip format")
 	flag.StringVar(&cfg.InlineRoutes, "inline-routes", "", "inline routes in eskip format")
+	flag.BoolVar(&cfg.EnableRouteSnapshotImport, "enable-route-snapshot-import", false, "enables importing route table snapshots, exported by another instance, on the support listener at /routes/snapshot")
 	flag.Int64Var(&cfg.SourcePollTimeout, "source-poll-timeout", int64(3000), "polling timeout of the routing data sources, in milliseconds")
 	flag.Var(cfg.AppendFilters, "default-filters-append", "set of default filters to apply to append to all filters of all routes")
 	flag.Var(cfg.PrependFilters, "default-filters-prepend", "set of default filters to apply to prepend to all filters of all routes")
 	flag.Var(cfg.DisabledFilters, "disabled-filters", "comma separated list of filters unavailable for use")
+	flag.Var(cfg.DefaultAllowedMethods, "default-allowed-methods", "comma separated list of HTTP methods allowed by default for all routes, routes can override it with the allowedMethods() filter")
+	flag.IntVar(&cfg.DefaultMaxURLLength, "default-max-url-length", 0, "max length of the request URL by default for all routes, routes can override it with the maxURLLength() filter")
+	flag.IntVar(&cfg.DefaultMaxHeaderCount, "default-max-header-count", 0, "max number of request header fields by default for all routes, routes can override it with the maxHeaderCount() filter")
+	flag.IntVar(&cfg.DefaultMaxHeaderSize, "default-max-header-size", 0, "max size of the request header fields in bytes by default for all routes, routes can override it with the maxHeaderSize() filter")
+	flag.Int64Var(&cfg.MaxRequestBodySize, "max-request-body-size", 0, "max size of the request bodies in bytes forwarded to the backends, longer requests are rejected with 413, routes can override it with the maxRequestBodySize() filter")
+	flag.BoolVar(&cfg.ForwardEarlyHints, "forward-early-hints", false, "forwards the 103 Early Hints responses of the backends to the clients")
+	flag.Var(cfg.BackendStripHeaders, "backend-strip-headers", "comma separated list of headers removed from the backend requests, a name ending with * removes the headers with the given prefix")
+	flag.IntVar(&cfg.BackendMaxHeaderSize, "backend-max-header-size", 0, "max size of the backend request header in bytes, larger requests are rejected with 431, routes can override it with the backendHeaderPolicy() filter")
+	flag.BoolVar(&cfg.BackendFoldHeaders, "backend-fold-duplicate-headers", false, "joins the values of the repeated header fields of the backend requests into a single field")
+	flag.BoolVar(&cfg.DetectStreaming, "detect-streaming-requests", false, "treats the requests accepting text/event-stream as streaming requests, like the routes with the streaming() filter")
+	flag.DurationVar(&cfg.StreamingHeaderTimeout, "streaming-response-header-timeout", 0, "response header timeout of the streaming requests, 0 means no timeout")
+	flag.DurationVar(&cfg.StreamingIdleTimeout, "streaming-idle-timeout", 0, "max time of waiting for the next data of a streaming response, it replaces the backend timeout for the streaming requests, 0 means no timeout")
+	flag.DurationVar(&cfg.ClientDisconnectGrace, "client-disconnect-grace-period", 0, "time after a client disconnect, when the backend request is canceled, 0 cancels it immediately, a negative value doesn't cancel it, routes can override it with the clientDisconnect() filter")
 	flag.Var(&cfg.EditRoute, "edit-route", "match and edit filters and predicates of all routes")
 	flag.Var(&cfg.CloneRoute, "clone-route", "clone all matching routes and replace filters and predicates of all matched routes")
+	flag.StringVar(&cfg.RouteRulesFile, "route-rules-file", "", "YAML file with rules prepending or appending filters to the routes matching an expression, reloaded on change")
+	flag.StringVar(&cfg.ProfilesFile, "profiles-file", "", "YAML file with named configuration profiles of timeouts, filters and TLS policy, attached to routes, hosts or route groups, reloaded on change")
 	flag.BoolVar(&cfg.WaitFirstRouteLoad, "wait-first-route-load", false, "prevent starting the listener before the first batch of routes were loaded")
 
+	// GeoIP
+	flag.Var(cfg.GeoIPDatabases, "geoip-databases", "comma separated list of GeoIP database files in the MaxMind DB format, enables the ClientGeo() predicate and the geoEnrich() filter")
+	flag.DurationVar(&cfg.GeoIPReloadInterval, "geoip-reload-interval", time.Minute, "how often the GeoIP database files are checked for changes")
+
+	// Bot detection
+	flag.BoolVar(&cfg.EnableBotDetection, "enable-bot-detection", false, "enables the BotScore() predicate and the botScore() and botBlock() filters")
+	flag.DurationVar(&cfg.BotDetectionRateWindow, "bot-detection-rate-window", 0, "time window of counting the requests of the clients for the bot detection, defaults to 10s when 0")
+	flag.IntVar(&cfg.BotDetectionRateThreshold, "bot-detection-rate-threshold", 0, "number of requests of a client in a time window, above which the client is considered a likely bot, defaults to 50 when 0")
+	flag.StringVar(&cfg.BotDetectionJA3Header, "bot-detection-ja3-header", "", "request header containing the JA3 TLS fingerprint of the client, set by a TLS terminating load balancer")
+	flag.Var(cfg.BotDetectionJA3Fingerprints, "bot-detection-ja3-fingerprints", "comma separated list of JA3 TLS fingerprints of known bots")
+	flag.StringVar(&cfg.ChallengeSecretFile, "challenge-secret-file", "", "path of the file with the secret used to sign the challenges and the pass cookies of the challenge filter, random when not set")
+
+	flag.DurationVar(&cfg.SourceFromFileReloadInterval, "source-from-file-reload-interval", 10*time.Second, "how often the files of the SourceFromFile() predicates are checked for changes")
+
 	// Forwarded headers
 	flag.Var(cfg.ForwardedHeadersList, "forwarded-headers", "comma separated list of headers to add to the incoming request before routing\n"+
 		"X-Forwarded-For sets or appends with comma the remote IP of the request to the X-Forwarded-For header value\n"+
@@ -420,7 +637,23 @@ func NewConfig() *Config {
 		"X-Forwarded-Proto=<http|https> sets X-Forwarded-Proto value")
 	flag.Var(cfg.ForwardedHeadersExcludeCIDRList, "forwarded-headers-exclude-cidrs", "disables addition of forwarded headers for the remote host IPs from the comma separated list of CIDRs")
 
+	// Client IP resolution
+	flag.Var(cfg.ClientIPTrustedProxiesList, "client-ip-trusted-proxies", "comma separated list of CIDRs of the proxies trusted to report the client IP in the forwarded header, enables the client IP resolution used consistently by all components")
+	flag.IntVar(&cfg.ClientIPTrustedHops, "client-ip-trusted-hops", 0, "number of proxies in front of skipper trusted to report the client IP in the forwarded header, enables the client IP resolution and takes precedence over -client-ip-trusted-proxies")
+	flag.StringVar(&cfg.ClientIPHeader, "client-ip-header", "X-Forwarded-For", "header used to resolve the client IP, possible values: X-Forwarded-For, Forwarded")
+
 	flag.BoolVar(&cfg.NormalizeHost, "normalize-host", false, "converts request host to lowercase and removes port and trailing dot if any")
+	flag.Var(cfg.CanonicalizeRequestList, "canonicalize-request", "comma separated list of canonicalization steps applied to the incoming request target before routing\n"+
+		"merge-slashes replaces consecutive slashes in the path with a single one\n"+
+		"remove-dot-segments resolves the . and .. segments of the path\n"+
+		"normalize-percent-encoding decodes percent-encoded unreserved characters and uppercases the remaining percent-encodings\n"+
+		"lowercase-host converts the request host to lowercase\n"+
+		"all enables all the steps\n"+
+		"Routes can opt out forwarding the canonical path with the preserveOriginalPath() filter")
+	flag.StringVar(&cfg.StrictHTTPParsingString, "strict-http-parsing", "off", "checks the raw HTTP/1.x requests on the plain HTTP listeners for request smuggling vectors, like conflicting Content-Length and Transfer-Encoding headers or obsolete line folding, possible values: off, log, reject")
+	flag.BoolVar(&cfg.ProxyProtocol, "proxy-protocol", false, "enables reading the PROXY protocol v1 and v2 headers on the main proxy listener, sent by the L4 load balancers, and using the client address of the header as the remote address of the requests")
+	flag.BoolVar(&cfg.EnableTLSPassthrough, "enable-tls-passthrough", false, "enables forwarding the TLS connections on the main proxy listener to the tcp:// backends of the routes matching the server name (SNI), without terminating TLS")
+	flag.Var(cfg.ProxyProtocolTrustedCIDRList, "proxy-protocol-trusted-cidrs", "comma separated list of CIDRs of the load balancers trusted to send the PROXY protocol header, required when the PROXY protocol is enabled")
 	flag.BoolVar(&cfg.ValidateQuery, "validate-query", true, "Validates the HTTP Query of a request and if invalid responds with status code 400")
 	flag.BoolVar(&cfg.ValidateQueryLog, "validate-query-log", true, "Enable looging for validate query logs")
 
@@ -495,8 +728,20 @@ func NewConfig() *Config {
 
 	// Connections, timeouts:
 	flag.DurationVar(&cfg.WaitForHealthcheckInterval, "wait-for-healthcheck-interval", (10+5)*3*time.Second, "period waiting to become unhealthy in the loadbalancer pool in front of this instance, before shutdown triggered by SIGINT or SIGTERM") // kube-ingress-aws-controller default
+	flag.DurationVar(&cfg.DrainDuration, "drain-duration", 0, "how long the main listener keeps serving the requests after the shutdown signal or a drain request on the support listener, before shutting down, defaults to -wait-for-healthcheck-interval")
+	flag.BoolVar(&cfg.DrainCloseConnections, "drain-close-connections", false, "enables closing the client connections of the main listener after the current requests while draining")
+	flag.BoolVar(&cfg.DrainRejectRequests, "drain-reject-requests", false, "enables responding to the requests of the main listener with 503 Service Unavailable while draining")
+	flag.DurationVar(&cfg.DrainRetryAfter, "drain-retry-after", 0, "sets the Retry-After header of the requests rejected while draining")
 	flag.IntVar(&cfg.IdleConnsPerHost, "idle-conns-num", proxy.DefaultIdleConnsPerHost, "maximum idle connections per backend host")
 	flag.DurationVar(&cfg.CloseIdleConnsPeriod, "close-idle-conns-period", proxy.DefaultCloseIdleConnsPeriod, "sets the time interval of closing all idle connections. Not closing when 0")
+	flag.IntVar(&cfg.FastCgiMaxIdleConns, "fastcgi-max-idle-conns", 0, "maximum idle connections per FastCGI backend, reused by the subsequent requests. Not reusing the connections when 0")
+	flag.DurationVar(&cfg.FastCgiIdleConnTimeout, "fastcgi-idle-conn-timeout", 30*time.Second, "time after the idle FastCGI connections are closed. Not closing when 0")
+	flag.DurationVar(&cfg.DNSCacheTTL, "dns-cache-ttl", 0, "time the resolved addresses of the backend hosts are cached. Not caching when 0")
+	flag.DurationVar(&cfg.DNSStaleTTL, "dns-stale-ttl", 0, "time the cached addresses of the backend hosts are used after the cache TTL, when the resolution fails. 10m when not set")
+	flag.Var(cfg.DNSServers, "dns-servers", "comma separated list of the DNS servers resolving the backend hosts, instead of the ones of the host, given as IP addresses, optionally with the port, tried in order")
+	flag.BoolVar(&cfg.DNSRespectTTL, "dns-respect-ttl", false, "caches the addresses of the backend hosts for the TTL of their DNS records, when resolved with the -dns-servers or with the backendResolver filter")
+	flag.BoolVar(&cfg.ConnectionPrewarming, "connection-prewarming", false, "enables establishing the backend connections in advance for the routes with the prewarmConnections filter")
+	flag.DurationVar(&cfg.PrewarmMaxIdle, "prewarm-max-idle", 0, "time the prewarmed backend connections are kept when not used. 30s when not set")
 	flag.DurationVar(&cfg.BackendFlushInterval, "backend-flush-interval", 20*time.Millisecond, "flush interval for upgraded proxy connections")
 	flag.BoolVar(&cfg.ExperimentalUpgrade, "experimental-upgrade", false, "enable experimental feature to handle upgrade protocol requests")
 	flag.BoolVar(&cfg.ExperimentalUpgradeAudit, "experimental-upgrade-audit", false, "enable audit logging of the request line and the messages during the experimental web socket upgrades")
@@ -509,10 +754,16 @@ func NewConfig() *Config {
 	flag.DurationVar(&cfg.TimeoutBackend, "timeout-backend", 60*time.Second, "sets the TCP client connection timeout for backend connections")
 	flag.DurationVar(&cfg.KeepaliveBackend, "keepalive-backend", 30*time.Second, "sets the keepalive for backend connections")
 	flag.BoolVar(&cfg.EnableDualstackBackend, "enable-dualstack-backend", true, "enables DualStack for backend connections")
+	flag.BoolVar(&cfg.HappyEyeballsBackend, "happy-eyeballs-backend", false, "enables connecting to the backends with both IPv4 and IPv6 addresses with the Happy Eyeballs algorithm of RFC 8305")
+	flag.BoolVar(&cfg.PreferIPv4Backend, "prefer-ipv4-backend", false, "makes the Happy Eyeballs dialing try the IPv4 addresses of the backends first, instead of the IPv6 ones")
+	flag.DurationVar(&cfg.DialFallbackDelayBackend, "dial-fallback-delay-backend", 0, "time waited for a connection attempt of the Happy Eyeballs dialing, before trying the next address, defaults to 250ms")
 	flag.DurationVar(&cfg.TlsHandshakeTimeoutBackend, "tls-timeout-backend", 60*time.Second, "sets the TLS handshake timeout for backend connections")
 	flag.DurationVar(&cfg.ResponseHeaderTimeoutBackend, "response-header-timeout-backend", 60*time.Second, "sets the HTTP response header timeout for backend connections")
 	flag.DurationVar(&cfg.ExpectContinueTimeoutBackend, "expect-continue-timeout-backend", 30*time.Second, "sets the HTTP expect continue timeout for backend connections")
 	flag.IntVar(&cfg.MaxIdleConnsBackend, "max-idle-connection-backend", 0, "sets the maximum idle connections for all backend connections")
+	flag.IntVar(&cfg.MaxConnsPerHostBackend, "max-conns-per-host-backend", 0, "sets the maximum number of connections, including the ones in use, for each backend host, 0 means no limit")
+	flag.DurationVar(&cfg.SSEKeepAliveInterval, "sse-keepalive-interval", 0, "when set, a comment is sent to the clients of the Server-Sent Events responses, when the backend didn't send an event for this duration")
+	flag.BoolVar(&cfg.BackendConnectionMetrics, "backend-connection-metrics", false, "enables reporting the open, in-use and idle connections, and the dial failures, for each backend host")
 	flag.BoolVar(&cfg.DisableHTTPKeepalives, "disable-http-keepalives", false, "forces backend to always create a new connection")
 	flag.BoolVar(&cfg.KubernetesEnableTLS, "kubernetes-enable-tls", false, "enable using kubnernetes resources to terminate tls")
 
@@ -594,10 +845,16 @@ func (c *Config) Parse() error {
 		return err
 	}
 
+	predicateCosts, err := c.parsePredicateCosts()
+	if err != nil {
+		return err
+	}
+
 	c.ApplicationLogLevel = logLevel
 	c.KubernetesPathMode = kubernetesPathMode
 	c.KubernetesEastWestRangePredicates = kubernetesEastWestRangePredicates
 	c.HistogramMetricBuckets = histogramBuckets
+	c.PredicateCosts = predicateCosts
 
 	if c.ClientKeyFile != "" && c.ClientCertFile != "" {
 		certsFiles := strings.Split(c.ClientCertFile, ",")
@@ -621,6 +878,31 @@ func (c *Config) Parse() error {
 		return err
 	}
 
+	err = c.parseClientIP()
+	if err != nil {
+		return err
+	}
+
+	err = c.parseCanonicalization()
+	if err != nil {
+		return err
+	}
+
+	c.StrictHTTPParsing, err = net.ParseStrictParsingLevel(c.StrictHTTPParsingString)
+	if err != nil {
+		return err
+	}
+
+	err = c.parseProxyProtocol()
+	if err != nil {
+		return err
+	}
+
+	c.ClientAuthTLS, err = c.parseClientAuthTLS()
+	if err != nil {
+		return err
+	}
+
 	if c.NormalizeHost || c.KubernetesIngress {
 		c.HostPatch = net.HostPatch{
 			ToLower:           true,
@@ -699,29 +981,49 @@ func (c *Config) ToOptions() skipper.Options {
 		// generic:
 		Address:                         c.Address,
 		InsecureAddress:                 c.InsecureAddress,
+		Listeners:                       c.Listeners,
 		StatusChecks:                    c.StatusChecks.values,
 		EnableTCPQueue:                  c.EnableTCPQueue,
+		ReusePortAcceptors:              c.ReusePortAcceptors,
 		ExpectedBytesPerRequest:         c.ExpectedBytesPerRequest,
 		MaxTCPListenerConcurrency:       c.MaxTCPListenerConcurrency,
 		MaxTCPListenerQueue:             c.MaxTCPListenerQueue,
+		StrictHTTPParsing:               c.StrictHTTPParsing,
+		ProxyProtocol:                   c.ProxyProtocol,
+		ProxyProtocolTrustedNetworks:    c.ProxyProtocolTrustedNetworks,
+		EnableTLSPassthrough:            c.EnableTLSPassthrough,
 		IgnoreTrailingSlash:             c.IgnoreTrailingSlash,
 		DevMode:                         c.DevMode,
 		SupportListener:                 c.SupportListener,
 		DebugListener:                   c.DebugListener,
 		CertPathTLS:                     c.CertPathTLS,
 		KeyPathTLS:                      c.KeyPathTLS,
+		ClientCAPathTLS:                 c.ClientCAPathTLS,
+		ClientAuthTLS:                   c.ClientAuthTLS,
 		MaxLoopbacks:                    c.MaxLoopbacks,
+		RetryBudget:                     c.RetryBudget,
+		RetryAfterMaxBackoff:            c.RetryAfterMaxBackoff,
 		DefaultHTTPStatus:               c.DefaultHTTPStatus,
 		LoadBalancerHealthCheckInterval: c.LoadBalancerHealthCheckInterval,
 		ReverseSourcePredicate:          c.ReverseSourcePredicate,
 		MaxAuditBody:                    c.MaxAuditBody,
 		MaxMatcherBufferSize:            c.MaxMatcherBufferSize,
+		WAFMaxBodySize:                  c.WAFMaxBodySize,
+		XMLMaxBodySize:                  c.XMLMaxBodySize,
+		CacheStore:                      c.CacheStore,
+		CacheMemorySize:                 c.CacheMemorySize,
+		CacheMaxBodySize:                c.CacheMaxBodySize,
+		AsyncStore:                      c.AsyncStore,
+		AsyncMaxBodySize:                c.AsyncMaxBodySize,
+		AsyncResultTTL:                  c.AsyncResultTTL,
 		EnableBreakers:                  c.EnableBreakers,
 		BreakerSettings:                 c.Breakers,
 		EnableRatelimiters:              c.EnableRatelimiters,
 		RatelimitSettings:               c.Ratelimits,
 		EnableRouteFIFOMetrics:          c.EnableRouteFIFOMetrics,
 		EnableRouteLIFOMetrics:          c.EnableRouteLIFOMetrics,
+		EnableMemoryWatchdog:            c.EnableMemoryWatchdog,
+		MemoryWatchdogThreshold:         c.MemoryWatchdogThreshold,
 		MetricsFlavours:                 c.MetricsFlavour.values,
 		FilterPlugins:                   c.FilterPlugins.values,
 		PredicatePlugins:                c.PredicatePlugins.values,
@@ -729,6 +1031,9 @@ func (c *Config) ToOptions() skipper.Options {
 		Plugins:                         c.MultiPlugins.values,
 		PluginDirs:                      []string{skipper.DefaultPluginDir},
 		CompressEncodings:               c.CompressEncodings.values,
+		CompressMinSize:                 c.CompressMinSize,
+		CompressMinRatio:                c.CompressMinRatio,
+		CompressBufferSize:              c.CompressBufferSize,
 
 		// logging, metrics, profiling, tracing:
 		EnablePrometheusMetrics:             c.EnablePrometheusMetrics,
@@ -759,6 +1064,8 @@ func (c *Config) ToOptions() skipper.Options {
 		EnableRouteStreamingErrorsCounters:  c.RouteStreamErrorCounters,
 		EnableRouteBackendMetrics:           c.RouteBackendMetrics,
 		EnableRouteCreationMetrics:          c.RouteCreationMetrics,
+		EnableRequestAnomalyMetrics:         c.RequestAnomalyMetrics,
+		RequestAnomalyMaxHeaderSize:         c.RequestAnomalyMaxHeaderSize,
 		MetricsUseExpDecaySample:            c.MetricsUseExpDecaySample,
 		HistogramMetricBuckets:              c.HistogramMetricBuckets,
 		DisableMetricsCompatibilityDefaults: c.DisableMetricsCompat,
@@ -769,7 +1076,33 @@ func (c *Config) ToOptions() skipper.Options {
 		AccessLogDisabled:                   c.AccessLogDisabled,
 		AccessLogJSONEnabled:                c.AccessLogJSONEnabled,
 		AccessLogStripQuery:                 c.AccessLogStripQuery,
+		AccessLogIdentityFields:             c.AccessLogIdentityFields.values,
+		AccessLogMaxSize:                    c.AccessLogMaxSize,
+		AccessLogMaxAge:                     c.AccessLogMaxAge,
+		AccessLogMaxBackups:                 c.AccessLogMaxBackups,
+		AccessLogBatchSize:                  c.AccessLogBatchSize,
+		AccessLogFlushInterval:              c.AccessLogFlushInterval,
+		CostAccounting:                      c.CostAccounting,
+		CostAccountingExport:                c.CostAccountingExport,
+		CostAccountingExportInterval:        c.CostAccountingExportInterval,
+		CostAccountingMaxTenants:            c.CostAccountingMaxTenants,
 		SuppressRouteUpdateLogs:             c.SuppressRouteUpdateLogs,
+		LazyFilters:                         c.LazyFilters.values,
+		WarmUpLazyFilters:                   c.WarmUpLazyFilters,
+		EventSinkKafkaBrokers:               c.EventSinkKafkaBrokers.values,
+		EventSinkKafkaTopic:                 c.EventSinkKafkaTopic,
+		EventSinkKafkaTLS:                   c.EventSinkKafkaTLS,
+		EventSinkKafkaTLSCAFile:             c.EventSinkKafkaTLSCAFile,
+		EventSinkKafkaSASLMechanism:         c.EventSinkKafkaSASLMechanism,
+		EventSinkKafkaSASLUsername:          c.EventSinkKafkaSASLUsername,
+		EventSinkKafkaSASLPasswordFile:      c.EventSinkKafkaSASLPasswordFile,
+		EventSinkBufferSize:                 c.EventSinkBufferSize,
+		EventSinkBatchSize:                  c.EventSinkBatchSize,
+		EventSinkFlushInterval:              c.EventSinkFlushInterval,
+		EventSinkBlockTimeout:               c.EventSinkBlockTimeout,
+		PredicateCosts:                      c.PredicateCosts,
+		AtomicRouteUpdates:                  c.AtomicRouteUpdates,
+		UnusedRouteThreshold:                c.UnusedRouteThreshold,
 
 		// route sources:
 		EtcdUrls:                  eus,
@@ -786,6 +1119,14 @@ func (c *Config) ToOptions() skipper.Options {
 		WatchRoutesFile:           c.RoutesFile,
 		RoutesURLs:                c.RoutesURLs.values,
 		InlineRoutes:              c.InlineRoutes,
+		EnableRouteSnapshotImport: c.EnableRouteSnapshotImport,
+		RouteRulesFile:            c.RouteRulesFile,
+		ProfilesFile:              c.ProfilesFile,
+		MaxRequestBodySize:        c.MaxRequestBodySize,
+		ForwardEarlyHints:         c.ForwardEarlyHints,
+		BackendStripHeaders:       c.BackendStripHeaders.values,
+		BackendMaxHeaderSize:      c.BackendMaxHeaderSize,
+		BackendFoldHeaders:        c.BackendFoldHeaders,
 		DefaultFilters: &eskip.DefaultFilters{
 			Prepend: c.PrependFilters.filters,
 			Append:  c.AppendFilters.filters,
@@ -793,6 +1134,21 @@ func (c *Config) ToOptions() skipper.Options {
 		DisabledFilters:    c.DisabledFilters.values,
 		SourcePollTimeout:  time.Duration(c.SourcePollTimeout) * time.Millisecond,
 		WaitFirstRouteLoad: c.WaitFirstRouteLoad,
+		RequestLimits: &limits.Defaults{
+			AllowedMethods: c.DefaultAllowedMethods.values,
+			MaxURLLength:   c.DefaultMaxURLLength,
+			MaxHeaderCount: c.DefaultMaxHeaderCount,
+			MaxHeaderSize:  c.DefaultMaxHeaderSize,
+		},
+		GeoIPDatabases:               c.GeoIPDatabases.values,
+		GeoIPReloadInterval:          c.GeoIPReloadInterval,
+		EnableBotDetection:           c.EnableBotDetection,
+		BotDetectionRateWindow:       c.BotDetectionRateWindow,
+		BotDetectionRateThreshold:    c.BotDetectionRateThreshold,
+		BotDetectionJA3Header:        c.BotDetectionJA3Header,
+		BotDetectionJA3Fingerprints:  c.BotDetectionJA3Fingerprints.values,
+		ChallengeSecretFile:          c.ChallengeSecretFile,
+		SourceFromFileReloadInterval: c.SourceFromFileReloadInterval,
 
 		// Kubernetes:
 		Kubernetes:                         c.KubernetesIngress,
@@ -855,8 +1211,20 @@ func (c *Config) ToOptions() skipper.Options {
 
 		// connections, timeouts:
 		WaitForHealthcheckInterval:   c.WaitForHealthcheckInterval,
+		DrainDuration:                c.DrainDuration,
+		DrainCloseConnections:        c.DrainCloseConnections,
+		DrainRejectRequests:          c.DrainRejectRequests,
+		DrainRetryAfter:              c.DrainRetryAfter,
 		IdleConnectionsPerHost:       c.IdleConnsPerHost,
 		CloseIdleConnsPeriod:         c.CloseIdleConnsPeriod,
+		FastCgiMaxIdleConns:          c.FastCgiMaxIdleConns,
+		FastCgiIdleConnTimeout:       c.FastCgiIdleConnTimeout,
+		DNSCacheTTL:                  c.DNSCacheTTL,
+		DNSStaleTTL:                  c.DNSStaleTTL,
+		DNSServers:                   c.DNSServers.values,
+		DNSRespectTTL:                c.DNSRespectTTL,
+		ConnectionPrewarming:         c.ConnectionPrewarming,
+		PrewarmMaxIdle:               c.PrewarmMaxIdle,
 		BackendFlushInterval:         c.BackendFlushInterval,
 		ExperimentalUpgrade:          c.ExperimentalUpgrade,
 		ExperimentalUpgradeAudit:     c.ExperimentalUpgradeAudit,
@@ -869,10 +1237,20 @@ func (c *Config) ToOptions() skipper.Options {
 		TimeoutBackend:               c.TimeoutBackend,
 		KeepAliveBackend:             c.KeepaliveBackend,
 		DualStackBackend:             c.EnableDualstackBackend,
+		HappyEyeballsBackend:         c.HappyEyeballsBackend,
+		PreferIPv4Backend:            c.PreferIPv4Backend,
+		DialFallbackDelayBackend:     c.DialFallbackDelayBackend,
 		TLSHandshakeTimeoutBackend:   c.TlsHandshakeTimeoutBackend,
 		ResponseHeaderTimeoutBackend: c.ResponseHeaderTimeoutBackend,
+		StreamingHeaderTimeout:       c.StreamingHeaderTimeout,
 		ExpectContinueTimeoutBackend: c.ExpectContinueTimeoutBackend,
+		ClientDisconnectGracePeriod:  c.ClientDisconnectGrace,
+		DetectStreaming:              c.DetectStreaming,
+		StreamingIdleTimeout:         c.StreamingIdleTimeout,
 		MaxIdleConnsBackend:          c.MaxIdleConnsBackend,
+		MaxConnsPerHostBackend:       c.MaxConnsPerHostBackend,
+		SSEKeepAliveInterval:         c.SSEKeepAliveInterval,
+		BackendConnectionMetrics:     c.BackendConnectionMetrics,
 		DisableHTTPKeepalives:        c.DisableHTTPKeepalives,
 		KubernetesEnableTLS:          c.KubernetesEnableTLS,
 
@@ -957,6 +1335,25 @@ func (c *Config) ToOptions() skipper.Options {
 		})
 	}
 
+	// the client IP options are validated by Parse
+	if resolver, err := c.clientIPResolver(); err == nil && resolver != nil {
+		wrappers = append(wrappers, func(handler http.Handler) http.Handler {
+			return &net.ClientIPHandler{
+				Resolver: resolver,
+				Handler:  handler,
+			}
+		})
+	}
+
+	if c.Canonicalization != (net.Canonicalization{}) {
+		wrappers = append(wrappers, func(handler http.Handler) http.Handler {
+			return &net.CanonicalizationHandler{
+				Canonicalization: c.Canonicalization,
+				Handler:          handler,
+			}
+		})
+	}
+
 	if c.HostPatch != (net.HostPatch{}) {
 		wrappers = append(wrappers, func(handler http.Handler) http.Handler {
 			return &net.HostPatchHandler{
@@ -1011,6 +1408,29 @@ func (c *Config) getMinTLSVersion() uint16 {
 	return tlsVersionTable[defaultMinTLSVersion]
 }
 
+func (c *Config) parseClientAuthTLS() (tls.ClientAuthType, error) {
+	switch c.ClientAuthTLSString {
+	case "":
+		if c.ClientCAPathTLS != "" {
+			return tls.VerifyClientCertIfGiven, nil
+		}
+
+		return tls.NoClientCert, nil
+	case "none":
+		return tls.NoClientCert, nil
+	case "request":
+		return tls.RequestClientCert, nil
+	case "require-any":
+		return tls.RequireAnyClientCert, nil
+	case "verify-if-given":
+		return tls.VerifyClientCertIfGiven, nil
+	case "require-and-verify":
+		return tls.RequireAndVerifyClientCert, nil
+	default:
+		return tls.NoClientCert, fmt.Errorf("invalid TLS client auth: %s", c.ClientAuthTLSString)
+	}
+}
+
 func (c *Config) parseHistogramBuckets() ([]float64, error) {
 	if c.HistogramMetricBucketsString == "" {
 		return prometheus.DefBuckets, nil
@@ -1029,6 +1449,92 @@ func (c *Config) parseHistogramBuckets() ([]float64, error) {
 	return result, nil
 }
 
+func (c *Config) parsePredicateCosts() (map[string]int, error) {
+	if len(c.PredicateCostsString.values) == 0 {
+		return nil, nil
+	}
+
+	costs := make(map[string]int)
+	for name, v := range c.PredicateCostsString.values {
+		cost, err := strconv.Atoi(v)
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse predicate-costs: %w", err)
+		}
+
+		costs[name] = cost
+	}
+
+	return costs, nil
+}
+
+func (c *Config) parseClientIP() error {
+	cidrs, err := net.ParseCIDRs(c.ClientIPTrustedProxiesList.values)
+	if err != nil {
+		return fmt.Errorf("invalid client IP trusted proxies: %w", err)
+	}
+
+	c.ClientIPTrustedProxies = cidrs
+	_, err = c.clientIPResolver()
+	return err
+}
+
+func (c *Config) parseProxyProtocol() error {
+	cidrs, err := net.ParseCIDRs(c.ProxyProtocolTrustedCIDRList.values)
+	if err != nil {
+		return fmt.Errorf("invalid PROXY protocol trusted CIDRs: %w", err)
+	}
+
+	c.ProxyProtocolTrustedNetworks = cidrs
+	enabled := c.ProxyProtocol
+	for _, l := range c.Listeners {
+		enabled = enabled || l.ProxyProtocol
+	}
+
+	if enabled && len(cidrs) == 0 {
+		return fmt.Errorf("the PROXY protocol requires the proxy-protocol-trusted-cidrs")
+	}
+
+	return nil
+}
+
+func (c *Config) clientIPResolver() (*net.ClientIPResolver, error) {
+	if len(c.ClientIPTrustedProxies) == 0 && c.ClientIPTrustedHops == 0 {
+		return nil, nil
+	}
+
+	return net.NewClientIPResolver(net.ClientIPOptions{
+		TrustedProxies: c.ClientIPTrustedProxies,
+		TrustedHops:    c.ClientIPTrustedHops,
+		Header:         c.ClientIPHeader,
+	})
+}
+
+func (c *Config) parseCanonicalization() error {
+	for _, step := range c.CanonicalizeRequestList.values {
+		switch step {
+		case "merge-slashes":
+			c.Canonicalization.MergeSlashes = true
+		case "remove-dot-segments":
+			c.Canonicalization.RemoveDotSegments = true
+		case "normalize-percent-encoding":
+			c.Canonicalization.NormalizePercentEncoding = true
+		case "lowercase-host":
+			c.Canonicalization.LowercaseHost = true
+		case "all":
+			c.Canonicalization = net.Canonicalization{
+				MergeSlashes:             true,
+				RemoveDotSegments:        true,
+				NormalizePercentEncoding: true,
+				LowercaseHost:            true,
+			}
+		default:
+			return fmt.Errorf("invalid request canonicalization: %s", step)
+		}
+	}
+
+	return nil
+}
+
 func (c *Config) parseForwardedHeaders() error {
 	for _, header := range c.ForwardedHeadersList.values {
 		switch {
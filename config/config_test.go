@@ -84,37 +84,56 @@ func Test_NewConfig(t *testing.T) {
 			name: "test only valid flag overwrite yaml file",
 			args: []string{"skipper", "-config-file=testdata/test.yaml", "-address=localhost:8080", "-refuse-payload=baz"},
 			want: &Config{
-				ConfigFile:                              "testdata/test.yaml",
-				Address:                                 "localhost:8080",
-				StatusChecks:                            nil,
-				ExpectedBytesPerRequest:                 50 * 1024,
-				SupportListener:                         ":9911",
-				MaxLoopbacks:                            12,
-				DefaultHTTPStatus:                       404,
-				MaxAuditBody:                            1024,
-				MaxMatcherBufferSize:                    2097152,
-				MetricsFlavour:                          commaListFlag("codahale", "prometheus"),
-				FilterPlugins:                           newPluginFlag(),
-				PredicatePlugins:                        newPluginFlag(),
-				DataclientPlugins:                       newPluginFlag(),
-				MultiPlugins:                            newPluginFlag(),
-				CompressEncodings:                       commaListFlag("gzip", "deflate", "br"),
-				OpenTracing:                             "noop",
-				OpenTracingInitialSpan:                  "ingress",
-				OpentracingLogFilterLifecycleEvents:     true,
-				OpentracingLogStreamEvents:              true,
-				MetricsListener:                         ":9911",
-				MetricsPrefix:                           "skipper.",
-				RuntimeMetrics:                          true,
-				HistogramMetricBuckets:                  []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10},
-				ApplicationLogLevel:                     log.InfoLevel,
-				ApplicationLogLevelString:               "INFO",
-				ApplicationLogPrefix:                    "[APP]",
-				EtcdPrefix:                              "/skipper",
-				EtcdTimeout:                             2 * time.Second,
-				AppendFilters:                           &defaultFiltersFlags{},
-				PrependFilters:                          &defaultFiltersFlags{},
-				DisabledFilters:                         commaListFlag(),
+				ConfigFile:                          "testdata/test.yaml",
+				Address:                             "localhost:8080",
+				StatusChecks:                        nil,
+				ExpectedBytesPerRequest:             50 * 1024,
+				SupportListener:                     ":9911",
+				MaxLoopbacks:                        12,
+				RetryBudget:                         0.2,
+				DefaultHTTPStatus:                   404,
+				MaxAuditBody:                        1024,
+				CacheStore:                          "memory",
+				AsyncStore:                          "memory",
+				MaxMatcherBufferSize:                2097152,
+				MemoryWatchdogThreshold:             0.9,
+				MetricsFlavour:                      commaListFlag("codahale", "prometheus"),
+				FilterPlugins:                       newPluginFlag(),
+				PredicatePlugins:                    newPluginFlag(),
+				DataclientPlugins:                   newPluginFlag(),
+				MultiPlugins:                        newPluginFlag(),
+				CompressEncodings:                   commaListFlag("gzip", "deflate", "br"),
+				CompressBufferSize:                  8192,
+				OpenTracing:                         "noop",
+				OpenTracingInitialSpan:              "ingress",
+				OpentracingLogFilterLifecycleEvents: true,
+				OpentracingLogStreamEvents:          true,
+				MetricsListener:                     ":9911",
+				MetricsPrefix:                       "skipper.",
+				RuntimeMetrics:                      true,
+				HistogramMetricBuckets:              []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10},
+				ApplicationLogLevel:                 log.InfoLevel,
+				ApplicationLogLevelString:           "INFO",
+				ApplicationLogPrefix:                "[APP]",
+				EtcdPrefix:                          "/skipper",
+				EtcdTimeout:                         2 * time.Second,
+				AppendFilters:                       &defaultFiltersFlags{},
+				PrependFilters:                      &defaultFiltersFlags{},
+				DisabledFilters:                     commaListFlag(),
+				BackendStripHeaders:                 commaListFlag(),
+				DNSServers:                          commaListFlag(),
+				DefaultAllowedMethods:               commaListFlag(),
+				GeoIPDatabases:                      commaListFlag(),
+				BotDetectionJA3Fingerprints:         commaListFlag(),
+				GeoIPReloadInterval:                 time.Minute,
+				SourceFromFileReloadInterval:        10 * time.Second,
+				LazyFilters:                         commaListFlag(),
+				EventSinkKafkaBrokers:               commaListFlag(),
+				AccessLogIdentityFields: commaListFlag(
+					"subject", "subject:hash", "subject:mask",
+					"realm", "realm:hash", "realm:mask",
+					"client-id", "client-id:hash", "client-id:mask",
+				),
 				CloneRoute:                              routeChangerConfig{},
 				EditRoute:                               routeChangerConfig{},
 				SourcePollTimeout:                       3000,
@@ -137,6 +156,7 @@ func Test_NewConfig(t *testing.T) {
 				WaitForHealthcheckInterval:              45 * time.Second,
 				IdleConnsPerHost:                        64,
 				CloseIdleConnsPeriod:                    20 * time.Second,
+				FastCgiIdleConnTimeout:                  30 * time.Second,
 				BackendFlushInterval:                    20 * time.Millisecond,
 				ReadTimeoutServer:                       5 * time.Minute,
 				ReadHeaderTimeoutServer:                 1 * time.Minute,
@@ -169,6 +189,11 @@ func Test_NewConfig(t *testing.T) {
 				RoutesURLs:                              commaListFlag(),
 				ForwardedHeadersList:                    commaListFlag(),
 				ForwardedHeadersExcludeCIDRList:         commaListFlag(),
+				CanonicalizeRequestList:                 commaListFlag(),
+				ClientIPTrustedProxiesList:              commaListFlag(),
+				ProxyProtocolTrustedCIDRList:            commaListFlag(),
+				ClientIPHeader:                          "X-Forwarded-For",
+				StrictHTTPParsingString:                 "off",
 				ClusterRatelimitMaxGroupShards:          1,
 				RefusePayload:                           multiFlag{"foo", "bar", "baz"},
 				ValidateQuery:                           true,
@@ -229,6 +254,44 @@ func Test_parseHistogramBuckets(t *testing.T) {
 	}
 }
 
+func Test_parseProxyProtocol(t *testing.T) {
+	for _, tt := range []struct {
+		name      string
+		enabled   bool
+		listener  bool
+		cidrs     string
+		wantError bool
+	}{
+		{name: "disabled"},
+		{name: "enabled", enabled: true, cidrs: "10.0.0.0/8,192.168.0.1"},
+		{name: "enabled without cidrs", enabled: true, wantError: true},
+		{name: "enabled on listener without cidrs", listener: true, wantError: true},
+		{name: "invalid cidr", cidrs: "10.0.0.0/33", wantError: true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := new(Config)
+			cfg.ProxyProtocol = tt.enabled
+			cfg.ProxyProtocolTrustedCIDRList = commaListFlag()
+			if tt.cidrs != "" {
+				cfg.ProxyProtocolTrustedCIDRList.Set(tt.cidrs)
+			}
+
+			if tt.listener {
+				cfg.Listeners = listenerFlags{{Name: "nlb", Address: ":9191", ProxyProtocol: true}}
+			}
+
+			err := cfg.parseProxyProtocol()
+			if tt.wantError != (err != nil) {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if tt.cidrs != "" && err == nil && len(cfg.ProxyProtocolTrustedNetworks) != 2 {
+				t.Errorf("unexpected trusted networks: %v", cfg.ProxyProtocolTrustedNetworks)
+			}
+		})
+	}
+}
+
 func TestMinTLSVersion(t *testing.T) {
 	t.Run("test default", func(t *testing.T) {
 		cfg := new(Config)
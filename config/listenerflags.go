@@ -0,0 +1,107 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/zalando/skipper"
+)
+
+const listenerUsage = `set an additional proxy listener with its own settings, serving the same routes unless its own routes file is set, e.g. -listener name=internal,address=:9191,max-header-bytes=65536,read-timeout=5s
+	possible listener properties:
+	name: the name of the listener, matched by the Listener predicate (required)
+	address: the network address of the listener (required)
+	max-header-bytes: the maximum size of the request headers
+	read-timeout, read-header-timeout, write-timeout, idle-timeout: duration strings of the server timeouts
+	tls-cert, tls-key: comma separated lists of certificate and key files, enabling TLS
+	disable-http2: true to disable HTTP/2 on the TLS listener
+	proxy-protocol: true to read the PROXY protocol headers sent by the networks of -proxy-protocol-trusted-cidrs, not inherited from the main listener
+	drain-duration, drain-retry-after: duration strings of the drain settings, see -drain-duration and -drain-retry-after
+	drain-close-connections, drain-reject-requests: true to close the connections or reject the requests while draining, not inherited from the main listener
+	routes-file: eskip file loaded into the own routing table of the listener, not inherited from the main listener
+	the settings not set default to the settings of the main listener`
+
+type listenerFlags []skipper.ListenerOptions
+
+var errInvalidListenerConfig = errors.New("invalid listener config")
+
+func (l listenerFlags) String() string {
+	s := make([]string, len(l))
+	for i, li := range l {
+		s[i] = fmt.Sprintf("name=%s,address=%s", li.Name, li.Address)
+	}
+
+	return strings.Join(s, "\n")
+}
+
+func (l *listenerFlags) Set(value string) error {
+	var o skipper.ListenerOptions
+	for _, vi := range strings.Split(value, ",") {
+		k, v, found := strings.Cut(vi, "=")
+		if !found {
+			return errInvalidListenerConfig
+		}
+
+		var err error
+		switch k {
+		case "name":
+			o.Name = v
+		case "address":
+			o.Address = v
+		case "max-header-bytes":
+			o.MaxHeaderBytes, err = strconv.Atoi(v)
+		case "read-timeout":
+			o.ReadTimeout, err = time.ParseDuration(v)
+		case "read-header-timeout":
+			o.ReadHeaderTimeout, err = time.ParseDuration(v)
+		case "write-timeout":
+			o.WriteTimeout, err = time.ParseDuration(v)
+		case "idle-timeout":
+			o.IdleTimeout, err = time.ParseDuration(v)
+		case "tls-cert":
+			o.CertPathTLS = v
+		case "tls-key":
+			o.KeyPathTLS = v
+		case "disable-http2":
+			o.DisableHTTP2, err = strconv.ParseBool(v)
+		case "proxy-protocol":
+			o.ProxyProtocol, err = strconv.ParseBool(v)
+		case "drain-duration":
+			o.DrainDuration, err = time.ParseDuration(v)
+		case "drain-close-connections":
+			o.DrainCloseConnections, err = strconv.ParseBool(v)
+		case "drain-reject-requests":
+			o.DrainRejectRequests, err = strconv.ParseBool(v)
+		case "drain-retry-after":
+			o.DrainRetryAfter, err = time.ParseDuration(v)
+		case "routes-file":
+			o.RoutesFile = v
+		default:
+			return errInvalidListenerConfig
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	if o.Name == "" || o.Address == "" {
+		return errInvalidListenerConfig
+	}
+
+	*l = append(*l, o)
+	return nil
+}
+
+func (l *listenerFlags) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var listeners []skipper.ListenerOptions
+	if err := unmarshal(&listeners); err != nil {
+		return err
+	}
+
+	*l = append(*l, listeners...)
+	return nil
+}
@@ -0,0 +1,121 @@
+package config
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"gopkg.in/yaml.v2"
+
+	"github.com/zalando/skipper"
+)
+
+func Test_listenerFlags_Set(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		args    string
+		wantErr bool
+		want    skipper.ListenerOptions
+	}{{
+		name: "all settings",
+		args: "name=internal,address=:9191,max-header-bytes=65536,read-timeout=5s,read-header-timeout=1s,write-timeout=30s,idle-timeout=1m,tls-cert=a.crt,tls-key=a.key,disable-http2=true,proxy-protocol=true",
+		want: skipper.ListenerOptions{
+			Name:              "internal",
+			Address:           ":9191",
+			MaxHeaderBytes:    65536,
+			ReadTimeout:       5 * time.Second,
+			ReadHeaderTimeout: time.Second,
+			WriteTimeout:      30 * time.Second,
+			IdleTimeout:       time.Minute,
+			CertPathTLS:       "a.crt",
+			KeyPathTLS:        "a.key",
+			DisableHTTP2:      true,
+			ProxyProtocol:     true,
+		},
+	}, {
+		name: "drain settings",
+		args: "name=internal,address=:9191,drain-duration=10s,drain-close-connections=true,drain-reject-requests=true,drain-retry-after=30s",
+		want: skipper.ListenerOptions{
+			Name:                  "internal",
+			Address:               ":9191",
+			DrainDuration:         10 * time.Second,
+			DrainCloseConnections: true,
+			DrainRejectRequests:   true,
+			DrainRetryAfter:       30 * time.Second,
+		},
+	}, {
+		name: "own routes",
+		args: "name=internal,address=:9191,routes-file=internal.eskip",
+		want: skipper.ListenerOptions{
+			Name:       "internal",
+			Address:    ":9191",
+			RoutesFile: "internal.eskip",
+		},
+	}, {
+		name:    "missing name",
+		args:    "address=:9191",
+		wantErr: true,
+	}, {
+		name:    "missing address",
+		args:    "name=internal",
+		wantErr: true,
+	}, {
+		name:    "invalid timeout",
+		args:    "name=internal,address=:9191,read-timeout=5",
+		wantErr: true,
+	}, {
+		name:    "unknown setting",
+		args:    "name=internal,address=:9191,foo=bar",
+		wantErr: true,
+	}} {
+		t.Run(tt.name, func(t *testing.T) {
+			var l listenerFlags
+			err := l.Set(tt.args)
+			if tt.wantErr {
+				if err == nil {
+					t.Error("failed to fail")
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(l) != 1 || !cmp.Equal(l[0], tt.want) {
+				t.Errorf("unexpected listeners: %s", cmp.Diff(listenerFlags{tt.want}, l))
+			}
+		})
+	}
+}
+
+func Test_listenerFlags_YAML(t *testing.T) {
+	var c struct {
+		Listeners listenerFlags `yaml:"listeners"`
+	}
+
+	err := yaml.Unmarshal([]byte(`
+listeners:
+- name: internal
+  address: ":9191"
+  read-timeout: 5s
+- name: public
+  address: ":9292"
+  disable-http2: true
+  proxy-protocol: true
+  inline-routes: 'r: * -> status(204) -> <shunt>'
+`), &c)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := listenerFlags{
+		{Name: "internal", Address: ":9191", ReadTimeout: 5 * time.Second},
+		{Name: "public", Address: ":9292", DisableHTTP2: true, ProxyProtocol: true, InlineRoutes: "r: * -> status(204) -> <shunt>"},
+	}
+
+	if !cmp.Equal(c.Listeners, want) {
+		t.Errorf("unexpected listeners: %s", cmp.Diff(want, c.Listeners))
+	}
+}
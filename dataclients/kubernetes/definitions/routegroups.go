@@ -28,6 +28,7 @@ var (
 	errInvalidPredicate         = errors.New("invalid predicate")
 	errInvalidFilter            = errors.New("invalid filter")
 	errInvalidMethod            = errors.New("invalid method")
+	errInvalidPriority          = errors.New("invalid priority")
 	errBothPathAndPathSubtree   = errors.New("path and path subtree in the same route")
 	errMissingBackendReference  = errors.New("missing backend reference")
 	errUnnamedBackend           = errors.New("unnamed backend")
@@ -150,6 +151,14 @@ type RouteSpec struct {
 
 	// Methods defines valid HTTP methods for the specified RouteSpec
 	Methods []string `json:"methods,omitempty"`
+
+	// Priority sets the explicit priority of the route. The routes with
+	// a higher priority are matched first, regardless of their predicates
+	Priority int `json:"priority,omitempty"`
+
+	// Metadata sets key-value metadata on the route, see the annotate
+	// filter
+	Metadata map[string]string `json:"metadata,omitempty"`
 }
 
 func backendsWithDuplicateName(name string) error {
@@ -392,6 +401,10 @@ func (r *RouteSpec) validate(hasDefault bool, backends map[string]bool) error {
 		return errInvalidMethod
 	}
 
+	if r.Priority < 0 {
+		return errInvalidPriority
+	}
+
 	return nil
 }
 
@@ -3,12 +3,14 @@ package kubernetes
 import (
 	"fmt"
 	"regexp"
+	"sort"
 	"strings"
 
 	log "github.com/sirupsen/logrus"
 
 	"github.com/zalando/skipper/dataclients/kubernetes/definitions"
 	"github.com/zalando/skipper/eskip"
+	"github.com/zalando/skipper/filters"
 	"github.com/zalando/skipper/loadbalancer"
 )
 
@@ -424,7 +426,7 @@ func implicitGroupRoutes(ctx *routeGroupContext) ([]*eskip.Route, error) {
 
 func transformExplicitGroupRoute(ctx *routeContext) (*eskip.Route, error) {
 	gr := ctx.groupRoute
-	r := &eskip.Route{Id: ctx.id}
+	r := &eskip.Route{Id: ctx.id, Priority: gr.Priority}
 
 	// Path or PathSubtree, prefer Path if we have, because it is more specifc
 	if gr.Path != "" {
@@ -455,6 +457,16 @@ func transformExplicitGroupRoute(ctx *routeContext) (*eskip.Route, error) {
 	}
 
 	var f []*eskip.Filter
+	metadataKeys := make([]string, 0, len(gr.Metadata))
+	for k := range gr.Metadata {
+		metadataKeys = append(metadataKeys, k)
+	}
+
+	sort.Strings(metadataKeys)
+	for _, k := range metadataKeys {
+		f = append(f, &eskip.Filter{Name: filters.AnnotateName, Args: []interface{}{k, gr.Metadata[k]}})
+	}
+
 	for _, fi := range gr.Filters {
 		ffi, err := eskip.ParseFilters(fi)
 		if err != nil {
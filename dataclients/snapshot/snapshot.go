@@ -0,0 +1,234 @@
+/*
+Package snapshot implements the export and the import of routing table
+snapshots, for disaster recovery and for the offline analysis of the
+routes.
+
+The exported snapshot contains the valid routes of the routing table,
+after the pre-processing, i.e. with the default filters and the route
+editing applied. It is served by the support listener in eskip format:
+
+	curl localhost:9911/routes/snapshot > snapshot.eskip
+
+or as JSON, containing the creation time of the routing table:
+
+	curl -H 'Accept: application/json' localhost:9911/routes/snapshot > snapshot.json
+
+When the import is enabled, the routes of a snapshot can be loaded into a
+standby instance, replacing the routes of the previous import:
+
+	curl -X POST --data-binary @snapshot.eskip localhost:9911/routes/snapshot
+	curl -X POST -H 'Content-Type: application/json' --data-binary @snapshot.json localhost:9911/routes/snapshot
+
+The imported routes are applied with the next poll of the data clients.
+Since the snapshot already contains the pre-processed routes, the standby
+instance should be started without default filters and route editing,
+otherwise they are applied again.
+*/
+package snapshot
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/zalando/skipper/eskip"
+	"github.com/zalando/skipper/routing"
+)
+
+// Path is the path of the snapshot API on the support listener.
+const Path = "/routes/snapshot"
+
+const (
+	defaultMaxSize = 64 << 20
+
+	createdHeader = "X-Snapshot-Created"
+	countHeader   = "X-Count"
+)
+
+// Source provides the snapshots of the routing table. It is implemented
+// by *routing.Routing.
+type Source interface {
+	Snapshot() *routing.Snapshot
+}
+
+// Client is a data client serving the routes of the last imported
+// snapshot.
+type Client struct {
+	mu      sync.Mutex
+	routes  []*eskip.Route
+	version int
+	loaded  int
+	ids     map[string]struct{}
+}
+
+// Handler implements the snapshot API. GET exports the snapshot of the
+// routing table. POST and PUT import a snapshot, when the Client is set.
+type Handler struct {
+
+	// Source of the exported snapshots.
+	Source Source
+
+	// Client receives the imported snapshots. When nil, the import is
+	// disabled.
+	Client *Client
+
+	// MaxSize limits the size of the imported snapshots. Defaults to
+	// 64MB.
+	MaxSize int64
+}
+
+var _ routing.DataClient = &Client{}
+
+// New creates a data client for the imported snapshots.
+func New() *Client {
+	return &Client{ids: make(map[string]struct{})}
+}
+
+// Import replaces the routes of the previous import. The routes need
+// unique ids.
+func (c *Client) Import(routes []*eskip.Route) error {
+	ids := make(map[string]struct{}, len(routes))
+	for _, r := range routes {
+		if r.Id == "" {
+			return errors.New("missing route id")
+		}
+
+		if _, ok := ids[r.Id]; ok {
+			return fmt.Errorf("duplicate route id: %s", r.Id)
+		}
+
+		ids[r.Id] = struct{}{}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.routes = routes
+	c.version++
+	return nil
+}
+
+// LoadAll returns the routes of the last import.
+func (c *Client) LoadAll() ([]*eskip.Route, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.loaded = c.version
+	c.ids = make(map[string]struct{}, len(c.routes))
+	for _, r := range c.routes {
+		c.ids[r.Id] = struct{}{}
+	}
+
+	return c.routes, nil
+}
+
+// LoadUpdate returns the routes of the import since the last load, and
+// the ids of the routes that it doesn't contain anymore.
+func (c *Client) LoadUpdate() ([]*eskip.Route, []string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.loaded == c.version {
+		return nil, nil, nil
+	}
+
+	ids := make(map[string]struct{}, len(c.routes))
+	for _, r := range c.routes {
+		ids[r.Id] = struct{}{}
+	}
+
+	var deleted []string
+	for id := range c.ids {
+		if _, ok := ids[id]; !ok {
+			deleted = append(deleted, id)
+		}
+	}
+
+	c.loaded = c.version
+	c.ids = ids
+	return c.routes, deleted, nil
+}
+
+func isJSON(h string) bool {
+	return strings.Contains(h, "application/json")
+}
+
+func (h *Handler) export(w http.ResponseWriter, r *http.Request) {
+	s := h.Source.Snapshot()
+	w.Header().Set(createdHeader, s.Created.UTC().Format(time.RFC3339Nano))
+	w.Header().Set(countHeader, strconv.Itoa(len(s.Routes)))
+	if isJSON(r.Header.Get("Accept")) {
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(s); err != nil {
+			log.Errorf("Failed to export the route snapshot: %v", err)
+		}
+
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain")
+	eskip.Fprint(w, eskip.PrettyPrintInfo{Pretty: true, IndentStr: "  "}, s.Routes...)
+}
+
+func parse(contentType string, b []byte) ([]*eskip.Route, error) {
+	if !isJSON(contentType) {
+		return eskip.Parse(string(b))
+	}
+
+	var s routing.Snapshot
+	if err := json.Unmarshal(b, &s); err != nil {
+		return nil, err
+	}
+
+	return s.Routes, nil
+}
+
+func (h *Handler) importSnapshot(w http.ResponseWriter, r *http.Request) {
+	maxSize := h.MaxSize
+	if maxSize <= 0 {
+		maxSize = defaultMaxSize
+	}
+
+	b, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxSize))
+	if err != nil {
+		http.Error(w, "failed to read the snapshot", http.StatusBadRequest)
+		return
+	}
+
+	routes, err := parse(r.Header.Get("Content-Type"), b)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("invalid snapshot: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	if err := h.Client.Import(routes); err != nil {
+		http.Error(w, fmt.Sprintf("invalid snapshot: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	log.Infof("Route snapshot imported with %d routes", len(routes))
+	w.Header().Set(countHeader, strconv.Itoa(len(routes)))
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+		h.export(w, r)
+	case http.MethodPost, http.MethodPut:
+		if h.Client == nil {
+			http.Error(w, "snapshot import disabled", http.StatusForbidden)
+			return
+		}
+
+		h.importSnapshot(w, r)
+	default:
+		w.Header().Set("Allow", "GET, HEAD, POST, PUT")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+	}
+}
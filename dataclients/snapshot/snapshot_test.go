@@ -0,0 +1,177 @@
+package snapshot
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/zalando/skipper/eskip"
+	"github.com/zalando/skipper/routing"
+)
+
+type testSource struct {
+	snapshot *routing.Snapshot
+}
+
+func (s testSource) Snapshot() *routing.Snapshot { return s.snapshot }
+
+func testSnapshot(t *testing.T) *routing.Snapshot {
+	routes, err := eskip.Parse(`
+		foo: Path("/foo") -> setPath("/bar") -> "https://foo.example.org";
+		baz: * -> status(404) -> <shunt>;
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &routing.Snapshot{Created: time.Date(2023, 1, 2, 3, 4, 5, 0, time.UTC), Routes: routes}
+}
+
+func do(h http.Handler, method, contentType, accept, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, Path, strings.NewReader(body))
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+
+	if accept != "" {
+		req.Header.Set("Accept", accept)
+	}
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	return w
+}
+
+func ids(routes []*eskip.Route) []string {
+	var s []string
+	for _, r := range routes {
+		s = append(s, r.Id)
+	}
+
+	sort.Strings(s)
+	return s
+}
+
+func TestExport(t *testing.T) {
+	s := testSnapshot(t)
+	h := &Handler{Source: testSource{s}}
+
+	w := do(h, "GET", "", "", "")
+	if w.Code != http.StatusOK || w.Header().Get("X-Count") != "2" || w.Header().Get("X-Snapshot-Created") != "2023-01-02T03:04:05Z" {
+		t.Fatalf("unexpected response: %d, %v", w.Code, w.Header())
+	}
+
+	routes, err := eskip.Parse(w.Body.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !eskip.EqLists(routes, s.Routes) {
+		t.Errorf("unexpected routes: %s", w.Body.String())
+	}
+
+	w = do(h, "GET", "", "application/json", "")
+	var js routing.Snapshot
+	if err := json.Unmarshal(w.Body.Bytes(), &js); err != nil {
+		t.Fatal(err)
+	}
+
+	if !js.Created.Equal(s.Created) || !eskip.EqLists(js.Routes, s.Routes) {
+		t.Errorf("unexpected snapshot: %s", w.Body.String())
+	}
+}
+
+func TestImport(t *testing.T) {
+	s := testSnapshot(t)
+	c := New()
+	h := &Handler{Source: testSource{s}, Client: c}
+
+	routes, err := c.LoadAll()
+	if err != nil || len(routes) != 0 {
+		t.Fatalf("unexpected initial routes: %v, %v", routes, err)
+	}
+
+	if upsert, deleted, err := c.LoadUpdate(); err != nil || len(upsert) != 0 || len(deleted) != 0 {
+		t.Fatalf("unexpected update: %v, %v, %v", upsert, deleted, err)
+	}
+
+	w := do(h, "POST", "text/plain", "", eskip.Print(eskip.PrettyPrintInfo{}, s.Routes...))
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("failed to import: %d, %s", w.Code, w.Body.String())
+	}
+
+	upsert, deleted, err := c.LoadUpdate()
+	if err != nil || !eskip.EqLists(upsert, s.Routes) || len(deleted) != 0 {
+		t.Fatalf("unexpected update: %v, %v, %v", upsert, deleted, err)
+	}
+
+	b, err := json.Marshal(&routing.Snapshot{Routes: s.Routes[:1]})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w = do(h, "PUT", "application/json", "", string(b))
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("failed to import: %d, %s", w.Code, w.Body.String())
+	}
+
+	upsert, deleted, err = c.LoadUpdate()
+	if err != nil || len(ids(upsert)) != 1 || upsert[0].Id != "foo" || len(deleted) != 1 || deleted[0] != "baz" {
+		t.Fatalf("unexpected update: %v, %v, %v", ids(upsert), deleted, err)
+	}
+
+	if upsert, deleted, err := c.LoadUpdate(); err != nil || len(upsert) != 0 || len(deleted) != 0 {
+		t.Fatalf("unexpected update: %v, %v, %v", upsert, deleted, err)
+	}
+
+	routes, err = c.LoadAll()
+	if err != nil || len(routes) != 1 {
+		t.Errorf("unexpected routes: %v, %v", ids(routes), err)
+	}
+}
+
+func TestImportInvalid(t *testing.T) {
+	h := &Handler{Source: testSource{testSnapshot(t)}, Client: New(), MaxSize: 256}
+	for _, ti := range []struct {
+		msg         string
+		contentType string
+		body        string
+	}{{
+		msg:  "invalid eskip",
+		body: "foo",
+	}, {
+		msg:         "invalid json",
+		contentType: "application/json",
+		body:        "{",
+	}, {
+		msg:  "missing id",
+		body: `* -> <shunt>`,
+	}, {
+		msg:  "duplicate id",
+		body: `foo: * -> <shunt>; foo: Path("/foo") -> <shunt>`,
+	}, {
+		msg:  "too large",
+		body: strings.Repeat(`foo: * -> <shunt>;`, 32),
+	}} {
+		t.Run(ti.msg, func(t *testing.T) {
+			if w := do(h, "POST", ti.contentType, "", ti.body); w.Code != http.StatusBadRequest {
+				t.Errorf("unexpected status: %d", w.Code)
+			}
+		})
+	}
+}
+
+func TestImportDisabled(t *testing.T) {
+	h := &Handler{Source: testSource{testSnapshot(t)}}
+	if w := do(h, "POST", "", "", `foo: * -> <shunt>`); w.Code != http.StatusForbidden {
+		t.Errorf("unexpected status: %d", w.Code)
+	}
+
+	if w := do(h, "DELETE", "", "", ""); w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("unexpected status: %d", w.Code)
+	}
+}
@@ -0,0 +1,265 @@
+/*
+Package drain implements the graceful draining of the proxy listeners,
+with settings for each listener.
+
+When a listener is drained, it keeps serving the requests for the drain
+duration, so that the load balancers in front of skipper can detect it
+as unhealthy, and then it is shut down gracefully. During the drain, the
+listener can close the client connections after the current requests,
+by responding with the Connection: close header, and it can reject the
+new requests with 503 Service Unavailable and a Retry-After header.
+
+The draining is started by the shutdown signal, or with the drain API of
+the support listener, either for all the listeners or for a single one:
+
+	curl -X POST localhost:9911/drain
+	curl -X POST 'localhost:9911/drain?listener=internal'
+
+The state of the listeners is returned by a GET request:
+
+	curl localhost:9911/drain
+*/
+package drain
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Path is the path of the drain API on the support listener.
+const Path = "/drain"
+
+// Options of draining a listener.
+type Options struct {
+
+	// Duration sets how long the listener keeps serving the requests
+	// after the drain was started, before it is shut down.
+	Duration time.Duration
+
+	// CloseConnections enables closing the client connections after
+	// the current requests during the drain.
+	CloseConnections bool
+
+	// RejectRequests enables responding to the requests during the
+	// drain with 503 Service Unavailable.
+	RejectRequests bool
+
+	// RetryAfter, when set, is sent in the Retry-After header of the
+	// rejected requests, rounded up to seconds.
+	RetryAfter time.Duration
+}
+
+// Listener controls the draining of a listener.
+type Listener struct {
+	name     string
+	options  Options
+	server   *http.Server
+	draining int32
+	mu       sync.Mutex
+	since    time.Time
+	once     sync.Once
+	done     chan struct{}
+}
+
+// State of a listener, as returned by the drain API.
+type State struct {
+	Name     string     `json:"name"`
+	Draining bool       `json:"draining"`
+	Since    *time.Time `json:"since,omitempty"`
+	Closed   bool       `json:"closed"`
+}
+
+type handler struct {
+	listener *Listener
+	next     http.Handler
+}
+
+// Registry contains the drained listeners, and implements the drain API.
+type Registry struct {
+	mu        sync.Mutex
+	listeners []*Listener
+	triggered chan struct{}
+	once      sync.Once
+}
+
+// NewRegistry creates a registry for the drained listeners.
+func NewRegistry() *Registry {
+	return &Registry{triggered: make(chan struct{})}
+}
+
+// Add registers the server of a listener, and wraps its handler. It needs
+// to be called before the server is started.
+func (r *Registry) Add(name string, server *http.Server, o Options) *Listener {
+	l := &Listener{
+		name:    name,
+		options: o,
+		server:  server,
+		done:    make(chan struct{}),
+	}
+
+	server.Handler = &handler{listener: l, next: server.Handler}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.listeners = append(r.listeners, l)
+	return l
+}
+
+// Triggered is closed when the drain of all the listeners was requested
+// with the drain API.
+func (r *Registry) Triggered() <-chan struct{} {
+	return r.triggered
+}
+
+func (r *Registry) get(name string) *Listener {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, l := range r.listeners {
+		if l.name == name {
+			return l
+		}
+	}
+
+	return nil
+}
+
+// DrainAll drains all the listeners concurrently, and returns when all
+// of them were shut down.
+func (r *Registry) DrainAll() {
+	r.mu.Lock()
+	listeners := append([]*Listener(nil), r.listeners...)
+	r.mu.Unlock()
+
+	var wg sync.WaitGroup
+	for _, l := range listeners {
+		wg.Add(1)
+		go func(l *Listener) {
+			defer wg.Done()
+			l.Drain()
+		}(l)
+	}
+
+	wg.Wait()
+}
+
+// States returns the state of the listeners.
+func (r *Registry) States() []State {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	s := make([]State, len(r.listeners))
+	for i, l := range r.listeners {
+		s[i] = l.State()
+	}
+
+	return s
+}
+
+// ServeHTTP implements the drain API. GET returns the state of the
+// listeners, POST starts draining the listener set in the listener query
+// parameter, or, without the parameter, all the listeners, followed by
+// the shutdown of skipper.
+func (r *Registry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	switch req.Method {
+	case http.MethodGet:
+	case http.MethodPost:
+		if name := req.URL.Query().Get("listener"); name != "" {
+			l := r.get(name)
+			if l == nil {
+				http.Error(w, "listener not found", http.StatusNotFound)
+				return
+			}
+
+			log.Infof("Drain of listener %s requested", name)
+			go l.Drain()
+		} else {
+			r.once.Do(func() {
+				log.Info("Drain of all listeners requested")
+				close(r.triggered)
+			})
+		}
+
+		w.WriteHeader(http.StatusAccepted)
+		return
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(r.States())
+}
+
+// Draining tells whether the listener is being drained.
+func (l *Listener) Draining() bool {
+	return atomic.LoadInt32(&l.draining) == 1
+}
+
+// State returns the drain state of the listener.
+func (l *Listener) State() State {
+	s := State{Name: l.name, Draining: l.Draining()}
+	l.mu.Lock()
+	if !l.since.IsZero() {
+		since := l.since
+		s.Since = &since
+	}
+	l.mu.Unlock()
+
+	select {
+	case <-l.done:
+		s.Closed = true
+	default:
+	}
+
+	return s
+}
+
+// Drain starts draining the listener, when it is not draining yet, and
+// returns when its server was shut down.
+func (l *Listener) Drain() {
+	l.once.Do(func() { go l.drain() })
+	<-l.done
+}
+
+func (l *Listener) drain() {
+	defer close(l.done)
+
+	l.mu.Lock()
+	l.since = time.Now()
+	l.mu.Unlock()
+	atomic.StoreInt32(&l.draining, 1)
+
+	if l.options.CloseConnections {
+		l.server.SetKeepAlivesEnabled(false)
+	}
+
+	log.Infof("Draining listener %s, shutdown in %v", l.name, l.options.Duration)
+	time.Sleep(l.options.Duration)
+
+	log.Infof("Shutting down listener %s", l.name)
+	if err := l.server.Shutdown(context.Background()); err != nil {
+		log.Errorf("Failed to graceful shutdown listener %s: %v", l.name, err)
+	}
+}
+
+func (h *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !h.listener.Draining() || !h.listener.options.RejectRequests {
+		h.next.ServeHTTP(w, r)
+		return
+	}
+
+	if ra := h.listener.options.RetryAfter; ra > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(ra.Seconds()))))
+	}
+
+	http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+}
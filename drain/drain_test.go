@@ -0,0 +1,186 @@
+package drain
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func startServer(r *Registry, name string, o Options) (*httptest.Server, *Listener) {
+	s := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("OK"))
+	}))
+
+	l := r.Add(name, s.Config, o)
+	s.Start()
+	return s, l
+}
+
+func waitDraining(t *testing.T, l *Listener) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !l.Draining() {
+		if time.Now().After(deadline) {
+			t.Fatal("timeout waiting for the drain")
+		}
+
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestDrainRejectRequests(t *testing.T) {
+	r := NewRegistry()
+	s, l := startServer(r, "main", Options{
+		Duration:         300 * time.Millisecond,
+		CloseConnections: true,
+		RejectRequests:   true,
+		RetryAfter:       1500 * time.Millisecond,
+	})
+	defer s.Close()
+
+	rsp, err := http.Get(s.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rsp.Body.Close()
+	if rsp.StatusCode != http.StatusOK || rsp.Close {
+		t.Fatalf("unexpected response before the drain: %d, close: %v", rsp.StatusCode, rsp.Close)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		l.Drain()
+		close(done)
+	}()
+
+	waitDraining(t, l)
+	rsp, err = http.Get(s.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rsp.Body.Close()
+	if rsp.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("unexpected status while draining: %d", rsp.StatusCode)
+	}
+
+	if ra := rsp.Header.Get("Retry-After"); ra != "2" {
+		t.Errorf("unexpected Retry-After: %q", ra)
+	}
+
+	if !rsp.Close {
+		t.Error("failed to close the connection")
+	}
+
+	select {
+	case <-done:
+	case <-time.After(3 * time.Second):
+		t.Fatal("timeout waiting for the shutdown")
+	}
+
+	if _, err := http.Get(s.URL); err == nil {
+		t.Error("failed to shut down the server")
+	}
+
+	if st := l.State(); !st.Draining || !st.Closed || st.Since == nil {
+		t.Errorf("unexpected state: %+v", st)
+	}
+}
+
+func TestDrainServesRequests(t *testing.T) {
+	r := NewRegistry()
+	s, l := startServer(r, "main", Options{Duration: time.Second})
+	defer s.Close()
+
+	go l.Drain()
+	waitDraining(t, l)
+
+	rsp, err := http.Get(s.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rsp.Body.Close()
+	if rsp.StatusCode != http.StatusOK || rsp.Close {
+		t.Errorf("unexpected response while draining: %d, close: %v", rsp.StatusCode, rsp.Close)
+	}
+}
+
+func TestAPI(t *testing.T) {
+	r := NewRegistry()
+	main, _ := startServer(r, "main", Options{Duration: time.Hour})
+	defer main.Close()
+
+	internal, il := startServer(r, "internal", Options{})
+	defer internal.Close()
+
+	api := httptest.NewServer(r)
+	defer api.Close()
+
+	request := func(method, query string) *http.Response {
+		req, err := http.NewRequest(method, api.URL+Path+query, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rsp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return rsp
+	}
+
+	rsp := request("POST", "?listener=internal")
+	rsp.Body.Close()
+	if rsp.StatusCode != http.StatusAccepted {
+		t.Fatalf("unexpected status: %d", rsp.StatusCode)
+	}
+
+	il.Drain()
+
+	rsp = request("GET", "")
+	var states []State
+	err := json.NewDecoder(rsp.Body).Decode(&states)
+	rsp.Body.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(states) != 2 ||
+		states[0].Name != "main" || states[0].Draining || states[0].Closed ||
+		states[1].Name != "internal" || !states[1].Draining || !states[1].Closed {
+		t.Errorf("unexpected states: %+v", states)
+	}
+
+	for _, test := range []struct {
+		method, query string
+		status        int
+	}{
+		{"POST", "?listener=foo", http.StatusNotFound},
+		{"PUT", "", http.StatusMethodNotAllowed},
+	} {
+		rsp := request(test.method, test.query)
+		rsp.Body.Close()
+		if rsp.StatusCode != test.status {
+			t.Errorf("%s %s: unexpected status: %d", test.method, test.query, rsp.StatusCode)
+		}
+	}
+
+	select {
+	case <-r.Triggered():
+		t.Fatal("unexpected trigger")
+	default:
+	}
+
+	rsp = request("POST", "")
+	rsp.Body.Close()
+	select {
+	case <-r.Triggered():
+	case <-time.After(time.Second):
+		t.Fatal("failed to trigger the drain")
+	}
+}
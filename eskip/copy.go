@@ -60,6 +60,7 @@ func Copy(r *Route) *Route {
 	r = Canonical(r)
 	c := &Route{}
 	c.Id = r.Id
+	c.Priority = r.Priority
 	c.Predicates = CopyPredicates(r.Predicates)
 	c.Filters = CopyFilters(r.Filters)
 	c.BackendType = r.BackendType
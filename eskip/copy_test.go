@@ -119,7 +119,8 @@ func TestCopy(t *testing.T) {
 
 		t.Run("single", func(t *testing.T) {
 			r := &Route{
-				Id: "route1",
+				Id:       "route1",
+				Priority: 10,
 				Predicates: []*Predicate{
 					{Name: "pfoo", Args: []interface{}{"hello1", 42, 3.14}},
 					{Name: "pbar", Args: []interface{}{"hello2", 2 * 42, 2 * 3.14}},
@@ -217,6 +217,15 @@ dynamic:
 The dynamic backend means that a filter must be present in the filter chain which
 must set the target url explicitly.
 
+# Route priority
+
+A route expression can start with an explicit priority, a non-negative
+integer in square brackets. The routes with a higher priority are
+matched before the routes with a lower priority, regardless of their
+predicates. The default priority is 0:
+
+	maintenance: [10] Host(/^www[.]example[.]org$/) -> status(503) -> <shunt>
+
 # Comments
 
 An eskip document can contain comments. The rule for comments is simple:
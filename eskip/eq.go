@@ -69,6 +69,10 @@ func eq2(left, right *Route) bool {
 		return false
 	}
 
+	if lc.Priority != rc.Priority {
+		return false
+	}
+
 	if len(lc.Predicates) != len(rc.Predicates) {
 		return false
 	}
@@ -180,6 +184,7 @@ func Canonical(r *Route) *Route {
 
 	c := &Route{}
 	c.Id = r.Id
+	c.Priority = r.Priority
 
 	c.Predicates = make([]*Predicate, len(r.Predicates))
 	copy(c.Predicates, r.Predicates)
@@ -252,6 +257,8 @@ func Canonical(r *Route) *Route {
 		} else {
 			c.Backend = r.Backend
 		}
+	case TCPBackend:
+		c.Backend = r.Backend
 	case LBBackend:
 		// using the LB fields only when apply:
 		c.LBAlgorithm = r.LBAlgorithm
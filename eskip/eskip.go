@@ -5,6 +5,7 @@ package eskip
 import (
 	"errors"
 	"fmt"
+	"math"
 	"net/url"
 	"regexp"
 	"strings"
@@ -218,9 +219,19 @@ const (
 	LoopBackend
 	DynamicBackend
 	LBBackend
+
+	// TCPBackend routes the raw TLS connections by their SNI to a TCP
+	// address, without terminating TLS, e.g. "tcp://10.0.0.1:5432".
+	TCPBackend
 )
 
-var errMixedProtocols = errors.New("loadbalancer endpoints cannot have mixed protocols")
+// TCPScheme is the scheme of the TCP backend addresses.
+const TCPScheme = "tcp"
+
+var (
+	errMixedProtocols  = errors.New("loadbalancer endpoints cannot have mixed protocols")
+	errInvalidPriority = errors.New("route priority must be a non-negative integer")
+)
 
 // Route definition used during the parser processes the raw routing
 // document.
@@ -235,6 +246,7 @@ type parsedRoute struct {
 	backend     string
 	lbAlgorithm string
 	lbEndpoints []string
+	priority    float64
 }
 
 // A Predicate object represents a parsed, in-memory, route matching predicate
@@ -330,6 +342,12 @@ type Route struct {
 	// load balancing backends.
 	LBEndpoints []string
 
+	// Explicit priority of the route. The routes with a higher
+	// priority are matched before the routes with a lower priority,
+	// regardless of their predicates. The default is 0.
+	// E.g. [10] Path("/api")
+	Priority int
+
 	// Name is deprecated and not used.
 	Name string
 
@@ -429,6 +447,8 @@ func BackendTypeFromString(s string) (BackendType, error) {
 		return DynamicBackend, nil
 	case "lb":
 		return LBBackend, nil
+	case "tcp":
+		return TCPBackend, nil
 	default:
 		return -1, fmt.Errorf("unsupported backend type: %s", s)
 	}
@@ -447,6 +467,8 @@ func (t BackendType) String() string {
 		return "dynamic"
 	case LBBackend:
 		return "lb"
+	case TCPBackend:
+		return "tcp"
 	default:
 		return "unknown"
 	}
@@ -564,8 +586,13 @@ func newRouteDefinition(r *parsedRoute) (*Route, error) {
 		}
 	}
 
+	if r.priority != math.Trunc(r.priority) || r.priority > math.MaxInt32 {
+		return nil, errInvalidPriority
+	}
+
 	rd := &Route{}
 	rd.Id = r.id
+	rd.Priority = int(r.priority)
 	rd.Filters = r.filters
 	rd.Shunt = r.shunt
 	rd.Backend = r.backend
@@ -581,6 +608,8 @@ func newRouteDefinition(r *parsedRoute) (*Route, error) {
 		rd.BackendType = DynamicBackend
 	case r.lbBackend:
 		rd.BackendType = LBBackend
+	case strings.HasPrefix(r.backend, TCPScheme+"://"):
+		rd.BackendType = TCPBackend
 	default:
 		rd.BackendType = NetworkBackend
 	}
@@ -617,23 +646,16 @@ func predicatesToRoute(p string) string {
 }
 
 // Parses a route expression or a routing document to a set of route definitions.
+//
+// Large documents are parsed concurrently, split at the route boundaries.
+// The order of the routes is preserved.
 func Parse(code string) ([]*Route, error) {
-	parsedRoutes, err := parse(code)
-	if err != nil {
-		return nil, err
-	}
-
-	routeDefinitions := make([]*Route, len(parsedRoutes))
-	for i, r := range parsedRoutes {
-		rd, err := newRouteDefinition(r)
-		if err != nil {
-			return nil, err
-		}
-
-		routeDefinitions[i] = rd
+	if routes, ok := parseParallel(code); ok {
+		return routes, nil
 	}
 
-	return routeDefinitions, nil
+	p := parsePart(code, false)
+	return p.routes, p.err
 }
 
 func partialParse(f string, partialToRoute func(string) string) (*parsedRoute, error) {
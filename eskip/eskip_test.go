@@ -78,6 +78,21 @@ func TestParseRouteExpression(t *testing.T) {
 		`Method("HEAD") -> "https://www.example.org"`,
 		&Route{Method: "HEAD", Backend: "https://www.example.org"},
 		false,
+	}, {
+		"priority",
+		`[10] Path("/some/path") -> "https://www.example.org"`,
+		&Route{Path: "/some/path", Backend: "https://www.example.org", Priority: 10},
+		false,
+	}, {
+		"fractional priority",
+		`[1.5] * -> "https://www.example.org"`,
+		nil,
+		true,
+	}, {
+		"empty priority",
+		`[] * -> "https://www.example.org"`,
+		nil,
+		true,
 	}, {
 		"invalid method predicate",
 		`Path("/endpoint") && Method("GET", "POST") -> "https://www.example.org"`,
@@ -297,6 +312,10 @@ func TestParseRouteExpression(t *testing.T) {
 			if r.Backend != ti.check.Backend {
 				t.Error("backend", r.Backend, ti.check.Backend)
 			}
+
+			if r.Priority != ti.check.Priority {
+				t.Error("priority", r.Priority, ti.check.Priority)
+			}
 		})
 	}
 }
@@ -22,6 +22,7 @@ type jsonRoute struct {
 	Backend    *jsonBackend `json:"backend,omitempty"`
 	Predicates []*Predicate `json:"predicates,omitempty"`
 	Filters    []*Filter    `json:"filters,omitempty"`
+	Priority   int          `json:"priority,omitempty"`
 }
 
 func newJSONRoute(r *Route) *jsonRoute {
@@ -30,6 +31,7 @@ func newJSONRoute(r *Route) *jsonRoute {
 		ID:         cr.Id,
 		Predicates: cr.Predicates,
 		Filters:    cr.Filters,
+		Priority:   cr.Priority,
 	}
 
 	if cr.BackendType != NetworkBackend || cr.Backend != "" {
@@ -74,7 +76,12 @@ func (r *Route) UnmarshalJSON(b []byte) error {
 		return err
 	}
 
+	if jr.Priority < 0 {
+		return errInvalidPriority
+	}
+
 	r.Id = jr.ID
+	r.Priority = jr.Priority
 
 	var bts string
 	if jr.Backend != nil {
@@ -88,7 +95,7 @@ func (r *Route) UnmarshalJSON(b []byte) error {
 
 	r.BackendType = bt
 	switch bt {
-	case NetworkBackend:
+	case NetworkBackend, TCPBackend:
 		if jr.Backend != nil {
 			r.Backend = jr.Backend.Address
 		}
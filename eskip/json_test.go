@@ -96,6 +96,16 @@ func TestMarshalUnmarshalJSON(t *testing.T) {
 			[]*Route{{Id: "shunty", BackendType: ShuntBackend}},
 			`[{"id":"shunty","backend":{"type":"shunt"}}]`,
 		},
+		{
+			"priority",
+			[]*Route{{Id: "api", BackendType: ShuntBackend, Priority: 10}},
+			`[{"id":"api","backend":{"type":"shunt"},"priority":10}]`,
+		},
+		{
+			"tcp backend",
+			[]*Route{{Id: "db", BackendType: TCPBackend, Backend: "tcp://10.0.0.1:5432"}},
+			`[{"id":"db","backend":{"type":"tcp","address":"tcp://10.0.0.1:5432"}}]`,
+		},
 		{
 			"predicates and filters",
 			[]*Route{
@@ -221,6 +231,17 @@ func TestMarshalUnmarshalJSON(t *testing.T) {
 				}]
 			}`,
 		},
+		{
+			"tcp backend",
+			&Route{HostRegexps: []string{"^db[.]example[.]org$"}, BackendType: TCPBackend, Backend: "tcp://10.0.0.1:5432"},
+			`{
+				"backend": {"type": "tcp", "address": "tcp://10.0.0.1:5432"},
+				"predicates": [{
+					"name": "Host",
+					"args": ["^db[.]example[.]org$"]
+				}]
+			}`,
+		},
 		{
 			"dynamic backend",
 			&Route{Method: "GET", BackendType: DynamicBackend},
@@ -310,6 +331,7 @@ func TestInvalidJSON(t *testing.T) {
 	for name, input := range map[string]string{
 		"invalid":              "{\\",
 		"invalid backend type": `{"backend": {"type": "foo"}}`,
+		"negative priority":    `{"priority": -1}`,
 	} {
 		t.Run(name, func(t *testing.T) {
 			var r *Route
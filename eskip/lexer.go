@@ -63,6 +63,8 @@ var fixedTokens = []fixedScanner{
 	"<dynamic>",
 	"<",
 	">",
+	"[",
+	"]",
 }
 
 var fixedTokenIDs = map[fixedScanner]int{
@@ -79,6 +81,8 @@ var fixedTokenIDs = map[fixedScanner]int{
 	"<dynamic>":  dynamic,
 	"<":          openarrow,
 	">":          closearrow,
+	"[":          openbracket,
+	"]":          closebracket,
 }
 
 func (t token) String() string { return t.val }
@@ -0,0 +1,121 @@
+package eskip
+
+import (
+	"runtime"
+	"sync"
+)
+
+// documents smaller than this are always parsed sequentially
+const minParallelParseSize = 1 << 16
+
+// scans the document for the route separators outside of the string,
+// regexp literals and comments, and splits it into at most n parts of
+// similar size. Every part contains only complete route definitions.
+func splitDocument(code string, n int) []string {
+	target := len(code) / n
+	if target == 0 {
+		return []string{code}
+	}
+
+	var (
+		parts []string
+		start int
+	)
+
+	for i := 0; i < len(code); i++ {
+		switch code[i] {
+		case '"', '`':
+			_, rest := scanEscaped(code[i], code[i+1:])
+			i = len(code) - len(rest)
+		case '/':
+			if i+1 < len(code) && code[i+1] == '/' {
+				rest := scanComment(code[i:])
+				i = len(code) - len(rest)
+			} else {
+				_, rest := scanRegexp(code[i+1:])
+				i = len(code) - len(rest)
+			}
+		case ';':
+			if i+1-start >= target && len(parts) < n-1 {
+				parts = append(parts, code[start:i+1])
+				start = i + 1
+			}
+		}
+	}
+
+	return append(parts, code[start:])
+}
+
+type parsedPart struct {
+	routes []*Route
+	err    error
+}
+
+func parsePart(code string, multipart bool) parsedPart {
+	parsedRoutes, err := parse(code)
+	if err != nil {
+		return parsedPart{err: err}
+	}
+
+	routes := make([]*Route, len(parsedRoutes))
+	for i, r := range parsedRoutes {
+		// a route without an id is only valid when it is the only
+		// route of the complete document
+		if multipart && r.id == "" {
+			return parsedPart{err: unexpectedToken}
+		}
+
+		rd, err := newRouteDefinition(r)
+		if err != nil {
+			return parsedPart{err: err}
+		}
+
+		routes[i] = rd
+	}
+
+	return parsedPart{routes: routes}
+}
+
+// parses the parts of a large document concurrently, preserving the
+// order of the routes. When any of the parts fails, it returns false,
+// and the document needs to be parsed sequentially in order to report
+// the exact position of the error.
+func parseParallel(code string) ([]*Route, bool) {
+	n := runtime.GOMAXPROCS(0)
+	if n < 2 || len(code) < minParallelParseSize {
+		return nil, false
+	}
+
+	parts := splitDocument(code, n)
+	if len(parts) < 2 {
+		return nil, false
+	}
+
+	results := make([]parsedPart, len(parts))
+	var wg sync.WaitGroup
+	for i := range parts {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i] = parsePart(parts[i], true)
+		}(i)
+	}
+
+	wg.Wait()
+
+	var count int
+	for _, r := range results {
+		if r.err != nil {
+			return nil, false
+		}
+
+		count += len(r.routes)
+	}
+
+	routes := make([]*Route, 0, count)
+	for _, r := range results {
+		routes = append(routes, r.routes...)
+	}
+
+	return routes, true
+}
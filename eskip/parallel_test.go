@@ -0,0 +1,85 @@
+package eskip
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func largeDocument(n int) string {
+	var b strings.Builder
+	for i := 0; i < n; i++ {
+		fmt.Fprintf(&b, "// comment; with separator\n")
+		fmt.Fprintf(&b, "route%d: Path(\"/foo%d;bar\") && PathRegexp(/^\\/api;[;]v%d/) && Header(\"X-Test\", `a;b\\`c`)\n", i, i, i)
+		fmt.Fprintf(&b, "  -> setRequestHeader(\"X-Route\", \"route%d;\") -> \"https://backend%d.example.org\";\n", i, i)
+	}
+
+	return b.String()
+}
+
+func TestSplitDocument(t *testing.T) {
+	doc := largeDocument(100)
+	parts := splitDocument(doc, 8)
+	if len(parts) < 2 || len(parts) > 8 {
+		t.Fatalf("unexpected number of parts: %d", len(parts))
+	}
+
+	if strings.Join(parts, "") != doc {
+		t.Fatal("parts don't add up to the document")
+	}
+
+	for _, p := range parts[:len(parts)-1] {
+		if !strings.HasSuffix(p, "\";\n") && !strings.HasSuffix(p, "\";") {
+			t.Errorf("part not split at a route boundary: %q", p[len(p)-20:])
+		}
+	}
+}
+
+func TestParseParallelSameAsSequential(t *testing.T) {
+	doc := largeDocument(2000)
+	if len(doc) < minParallelParseSize {
+		t.Fatal("test document too small")
+	}
+
+	parallel, ok := parseParallel(doc)
+	if !ok {
+		t.Skip("parallel parsing not available")
+	}
+
+	sequential := parsePart(doc, false)
+	if sequential.err != nil {
+		t.Fatal(sequential.err)
+	}
+
+	if !cmp.Equal(parallel, sequential.routes) {
+		t.Error(cmp.Diff(parallel, sequential.routes))
+	}
+}
+
+func TestParseParallelFallsBackOnError(t *testing.T) {
+	for name, doc := range map[string]string{
+		"invalid route":   largeDocument(1000) + "invalid: Path(\"/foo\") -> ;" + largeDocument(1000),
+		"anonymous route": largeDocument(1000) + "* -> <shunt>;" + largeDocument(1000),
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := Parse(doc)
+			expected := parsePart(doc, false).err
+			if err == nil || expected == nil || err.Error() != expected.Error() {
+				t.Errorf("unexpected error: %v, expected: %v", err, expected)
+			}
+		})
+	}
+}
+
+func BenchmarkParseLargeDocument(b *testing.B) {
+	doc := largeDocument(10000)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := Parse(doc); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -60,6 +60,8 @@ const stringliteral = 57359
 const symbol = 57360
 const openarrow = 57361
 const closearrow = 57362
+const openbracket = 57363
+const closebracket = 57364
 
 var eskipToknames = [...]string{
 	"$end",
@@ -82,6 +84,8 @@ var eskipToknames = [...]string{
 	"symbol",
 	"openarrow",
 	"closearrow",
+	"openbracket",
+	"closebracket",
 }
 
 var eskipStatenames = [...]string{}
@@ -90,7 +94,7 @@ const eskipEofCode = 1
 const eskipErrCode = 2
 const eskipInitialStackSize = 16
 
-//line parser.y:287
+//line parser.y:299
 
 //line yacctab:1
 var eskipExca = [...]int{
@@ -101,62 +105,66 @@ var eskipExca = [...]int{
 
 const eskipPrivate = 57344
 
-const eskipLast = 62
+const eskipLast = 71
 
 var eskipAct = [...]int{
-	34, 40, 32, 31, 24, 17, 20, 21, 22, 25,
-	27, 26, 19, 48, 36, 9, 37, 25, 41, 9,
-	16, 25, 25, 3, 10, 7, 14, 42, 29, 43,
-	4, 55, 8, 45, 44, 49, 45, 30, 28, 19,
-	50, 15, 13, 47, 46, 38, 23, 51, 52, 39,
-	53, 42, 54, 12, 35, 11, 33, 18, 5, 6,
-	2, 1,
+	39, 45, 37, 36, 31, 24, 27, 28, 29, 32,
+	34, 33, 11, 11, 5, 21, 53, 26, 32, 46,
+	14, 20, 41, 10, 11, 23, 9, 32, 6, 6,
+	32, 3, 4, 12, 47, 48, 42, 23, 18, 14,
+	60, 35, 50, 54, 26, 19, 55, 22, 52, 51,
+	15, 43, 56, 57, 30, 58, 47, 59, 49, 17,
+	50, 16, 38, 44, 40, 25, 8, 7, 2, 13,
+	1,
 }
 
 var eskipPact = [...]int{
-	14, -1000, 11, -1000, -1000, 49, 34, -1000, 15, -1000,
-	2, -8, 10, 10, 4, -1000, -1000, -1000, 39, -1000,
-	-1000, -1000, -1000, -1000, -1000, -1000, 0, 18, -1000, 15,
-	-1000, 27, -1000, -1000, -1000, -1000, -1000, -1000, -8, -7,
-	26, 31, -1000, 4, -1000, 4, -1000, -1000, -1000, 5,
-	5, 24, -1000, -1000, 26, -1000,
+	8, -1000, 20, -1000, -1000, -1000, 29, 42, 55, 27,
+	-1000, -1000, 3, -7, -1000, 7, -8, 19, 10, -1000,
+	-1000, 19, -1000, 27, -1000, 45, -1000, -1000, -1000, -1000,
+	-1000, -1000, -1000, 1, 24, -1000, 51, -1000, -1000, -1000,
+	-1000, -1000, -1000, -8, -4, 34, 37, -1000, 10, -1000,
+	10, -1000, -1000, -1000, 13, 13, 33, -1000, -1000, 34,
+	-1000,
 }
 
 var eskipPgo = [...]int{
-	0, 61, 60, 23, 30, 59, 58, 5, 57, 25,
-	3, 4, 2, 56, 0, 54, 1, 49, 46,
+	0, 70, 68, 31, 32, 67, 14, 62, 66, 5,
+	65, 23, 3, 4, 2, 0, 64, 1, 63, 54,
 }
 
 var eskipR1 = [...]int{
 	0, 1, 1, 2, 2, 2, 2, 4, 5, 3,
-	3, 6, 6, 9, 9, 8, 8, 11, 10, 10,
-	10, 12, 12, 12, 16, 16, 17, 17, 18, 7,
-	7, 7, 7, 7, 13, 14, 15,
+	3, 6, 6, 8, 8, 11, 11, 10, 10, 13,
+	12, 12, 12, 14, 14, 14, 17, 17, 18, 18,
+	19, 9, 9, 9, 9, 9, 7, 15, 16,
 }
 
 var eskipR2 = [...]int{
-	0, 1, 1, 0, 1, 3, 2, 3, 1, 3,
-	5, 1, 3, 1, 4, 1, 3, 4, 0, 1,
-	3, 1, 1, 1, 1, 3, 1, 3, 3, 1,
-	1, 1, 1, 1, 1, 1, 1,
+	0, 1, 1, 0, 1, 3, 2, 3, 1, 1,
+	4, 3, 5, 1, 3, 1, 4, 1, 3, 4,
+	0, 1, 3, 1, 1, 1, 1, 3, 1, 3,
+	3, 1, 1, 1, 1, 1, 1, 1, 1,
 }
 
 var eskipChk = [...]int{
-	-1000, -1, -2, -3, -4, -6, -5, -9, 18, 5,
-	13, 6, 4, 8, 11, -4, 18, -7, -8, -14,
-	14, 15, 16, -18, -11, 17, 19, 18, -9, 18,
-	-3, -10, -12, -13, -14, -15, 10, 12, 6, -17,
-	-16, 18, -14, 11, 7, 9, -7, -11, 20, 9,
-	9, -10, -12, -14, -16, 7,
+	-1000, -1, -2, -3, -4, -6, 21, -5, -8, 18,
+	-11, 5, 13, -7, 10, 8, 6, 4, 11, -4,
+	18, 22, -3, 18, -9, -10, -15, 14, 15, 16,
+	-19, -13, 17, 19, 18, -11, -12, -14, -7, -15,
+	-16, 12, -6, 6, -18, -17, 18, -15, 11, 7,
+	9, -9, -13, 20, 9, 9, -12, -14, -15, -17,
+	7,
 }
 
 var eskipDef = [...]int{
-	3, -2, 1, 2, 4, 0, 0, 11, 8, 13,
-	6, 0, 0, 0, 18, 5, 8, 9, 0, 29,
-	30, 31, 32, 33, 15, 35, 0, 0, 12, 0,
-	7, 0, 19, 21, 22, 23, 34, 36, 0, 0,
-	26, 0, 24, 18, 14, 0, 10, 16, 28, 0,
-	0, 0, 20, 25, 27, 17,
+	3, -2, 1, 2, 4, 9, 0, 0, 0, 8,
+	13, 15, 6, 0, 36, 0, 0, 0, 20, 5,
+	8, 0, 7, 0, 11, 0, 31, 32, 33, 34,
+	35, 17, 37, 0, 0, 14, 0, 21, 23, 24,
+	25, 38, 10, 0, 0, 28, 0, 26, 20, 16,
+	0, 12, 18, 30, 0, 0, 0, 22, 27, 29,
+	19,
 }
 
 var eskipTok1 = [...]int{
@@ -165,7 +173,8 @@ var eskipTok1 = [...]int{
 
 var eskipTok2 = [...]int{
 	2, 3, 4, 5, 6, 7, 8, 9, 10, 11,
-	12, 13, 14, 15, 16, 17, 18, 19, 20,
+	12, 13, 14, 15, 16, 17, 18, 19, 20, 21,
+	22,
 }
 
 var eskipTok3 = [...]int{
@@ -511,54 +520,67 @@ eskipdefault:
 
 	case 1:
 		eskipDollar = eskipS[eskippt-1 : eskippt+1]
-//line parser.y:75
+//line parser.y:77
 		{
 			eskipVAL.routes = eskipDollar[1].routes
 			eskiplex.(*eskipLex).routes = eskipVAL.routes
 		}
 	case 2:
 		eskipDollar = eskipS[eskippt-1 : eskippt+1]
-//line parser.y:80
+//line parser.y:82
 		{
 			eskipVAL.routes = []*parsedRoute{eskipDollar[1].route}
 			eskiplex.(*eskipLex).routes = eskipVAL.routes
 		}
 	case 4:
 		eskipDollar = eskipS[eskippt-1 : eskippt+1]
-//line parser.y:87
+//line parser.y:89
 		{
 			eskipVAL.routes = []*parsedRoute{eskipDollar[1].route}
 		}
 	case 5:
 		eskipDollar = eskipS[eskippt-3 : eskippt+1]
-//line parser.y:91
+//line parser.y:93
 		{
 			eskipVAL.routes = eskipDollar[1].routes
 			eskipVAL.routes = append(eskipVAL.routes, eskipDollar[3].route)
 		}
 	case 6:
 		eskipDollar = eskipS[eskippt-2 : eskippt+1]
-//line parser.y:96
+//line parser.y:98
 		{
 			eskipVAL.routes = eskipDollar[1].routes
 		}
 	case 7:
 		eskipDollar = eskipS[eskippt-3 : eskippt+1]
-//line parser.y:101
+//line parser.y:103
 		{
 			eskipVAL.route = eskipDollar[3].route
 			eskipVAL.route.id = eskipDollar[1].token
 		}
 	case 8:
 		eskipDollar = eskipS[eskippt-1 : eskippt+1]
-//line parser.y:107
+//line parser.y:109
 		{
 			eskipVAL.token = eskipDollar[1].token
 			eskiplex.(*eskipLex).lastRouteID = eskipDollar[1].token
 		}
 	case 9:
+		eskipDollar = eskipS[eskippt-1 : eskippt+1]
+//line parser.y:115
+		{
+			eskipVAL.route = eskipDollar[1].route
+		}
+	case 10:
+		eskipDollar = eskipS[eskippt-4 : eskippt+1]
+//line parser.y:119
+		{
+			eskipVAL.route = eskipDollar[4].route
+			eskipVAL.route.priority = eskipDollar[2].numval
+		}
+	case 11:
 		eskipDollar = eskipS[eskippt-3 : eskippt+1]
-//line parser.y:113
+//line parser.y:125
 		{
 			eskipVAL.route = &parsedRoute{
 				matchers:    eskipDollar[1].matchers,
@@ -573,9 +595,9 @@ eskipdefault:
 			eskipDollar[1].matchers = nil
 			eskipDollar[3].lbEndpoints = nil
 		}
-	case 10:
+	case 12:
 		eskipDollar = eskipS[eskippt-5 : eskippt+1]
-//line parser.y:128
+//line parser.y:140
 		{
 			eskipVAL.route = &parsedRoute{
 				matchers:    eskipDollar[1].matchers,
@@ -592,121 +614,121 @@ eskipdefault:
 			eskipDollar[3].filters = nil
 			eskipDollar[5].lbEndpoints = nil
 		}
-	case 11:
+	case 13:
 		eskipDollar = eskipS[eskippt-1 : eskippt+1]
-//line parser.y:146
+//line parser.y:158
 		{
 			eskipVAL.matchers = []*matcher{eskipDollar[1].matcher}
 		}
-	case 12:
+	case 14:
 		eskipDollar = eskipS[eskippt-3 : eskippt+1]
-//line parser.y:150
+//line parser.y:162
 		{
 			eskipVAL.matchers = eskipDollar[1].matchers
 			eskipVAL.matchers = append(eskipVAL.matchers, eskipDollar[3].matcher)
 		}
-	case 13:
+	case 15:
 		eskipDollar = eskipS[eskippt-1 : eskippt+1]
-//line parser.y:156
+//line parser.y:168
 		{
 			eskipVAL.matcher = &matcher{"*", nil}
 		}
-	case 14:
+	case 16:
 		eskipDollar = eskipS[eskippt-4 : eskippt+1]
-//line parser.y:160
+//line parser.y:172
 		{
 			eskipVAL.matcher = &matcher{eskipDollar[1].token, eskipDollar[3].args}
 			eskipDollar[3].args = nil
 		}
-	case 15:
+	case 17:
 		eskipDollar = eskipS[eskippt-1 : eskippt+1]
-//line parser.y:166
+//line parser.y:178
 		{
 			eskipVAL.filters = []*Filter{eskipDollar[1].filter}
 		}
-	case 16:
+	case 18:
 		eskipDollar = eskipS[eskippt-3 : eskippt+1]
-//line parser.y:170
+//line parser.y:182
 		{
 			eskipVAL.filters = eskipDollar[1].filters
 			eskipVAL.filters = append(eskipVAL.filters, eskipDollar[3].filter)
 		}
-	case 17:
+	case 19:
 		eskipDollar = eskipS[eskippt-4 : eskippt+1]
-//line parser.y:176
+//line parser.y:188
 		{
 			eskipVAL.filter = &Filter{
 				Name: eskipDollar[1].token,
 				Args: eskipDollar[3].args}
 			eskipDollar[3].args = nil
 		}
-	case 19:
+	case 21:
 		eskipDollar = eskipS[eskippt-1 : eskippt+1]
-//line parser.y:185
+//line parser.y:197
 		{
 			eskipVAL.args = []interface{}{eskipDollar[1].arg}
 		}
-	case 20:
+	case 22:
 		eskipDollar = eskipS[eskippt-3 : eskippt+1]
-//line parser.y:189
+//line parser.y:201
 		{
 			eskipVAL.args = eskipDollar[1].args
 			eskipVAL.args = append(eskipVAL.args, eskipDollar[3].arg)
 		}
-	case 21:
+	case 23:
 		eskipDollar = eskipS[eskippt-1 : eskippt+1]
-//line parser.y:195
+//line parser.y:207
 		{
 			eskipVAL.arg = eskipDollar[1].numval
 		}
-	case 22:
+	case 24:
 		eskipDollar = eskipS[eskippt-1 : eskippt+1]
-//line parser.y:199
+//line parser.y:211
 		{
 			eskipVAL.arg = eskipDollar[1].stringval
 		}
-	case 23:
+	case 25:
 		eskipDollar = eskipS[eskippt-1 : eskippt+1]
-//line parser.y:203
+//line parser.y:215
 		{
 			eskipVAL.arg = eskipDollar[1].regexpval
 		}
-	case 24:
+	case 26:
 		eskipDollar = eskipS[eskippt-1 : eskippt+1]
-//line parser.y:208
+//line parser.y:220
 		{
 			eskipVAL.stringvals = []string{eskipDollar[1].stringval}
 		}
-	case 25:
+	case 27:
 		eskipDollar = eskipS[eskippt-3 : eskippt+1]
-//line parser.y:212
+//line parser.y:224
 		{
 			eskipVAL.stringvals = eskipDollar[1].stringvals
 			eskipVAL.stringvals = append(eskipVAL.stringvals, eskipDollar[3].stringval)
 		}
-	case 26:
+	case 28:
 		eskipDollar = eskipS[eskippt-1 : eskippt+1]
-//line parser.y:218
+//line parser.y:230
 		{
 			eskipVAL.lbEndpoints = eskipDollar[1].stringvals
 		}
-	case 27:
+	case 29:
 		eskipDollar = eskipS[eskippt-3 : eskippt+1]
-//line parser.y:222
+//line parser.y:234
 		{
 			eskipVAL.lbAlgorithm = eskipDollar[1].token
 			eskipVAL.lbEndpoints = eskipDollar[3].stringvals
 		}
-	case 28:
+	case 30:
 		eskipDollar = eskipS[eskippt-3 : eskippt+1]
-//line parser.y:228
+//line parser.y:240
 		{
 			eskipVAL.lbAlgorithm = eskipDollar[2].lbAlgorithm
 			eskipVAL.lbEndpoints = eskipDollar[2].lbEndpoints
 		}
-	case 29:
+	case 31:
 		eskipDollar = eskipS[eskippt-1 : eskippt+1]
-//line parser.y:234
+//line parser.y:246
 		{
 			eskipVAL.backend = eskipDollar[1].stringval
 			eskipVAL.shunt = false
@@ -714,36 +736,36 @@ eskipdefault:
 			eskipVAL.dynamic = false
 			eskipVAL.lbBackend = false
 		}
-	case 30:
+	case 32:
 		eskipDollar = eskipS[eskippt-1 : eskippt+1]
-//line parser.y:242
+//line parser.y:254
 		{
 			eskipVAL.shunt = true
 			eskipVAL.loopback = false
 			eskipVAL.dynamic = false
 			eskipVAL.lbBackend = false
 		}
-	case 31:
+	case 33:
 		eskipDollar = eskipS[eskippt-1 : eskippt+1]
-//line parser.y:249
+//line parser.y:261
 		{
 			eskipVAL.shunt = false
 			eskipVAL.loopback = true
 			eskipVAL.dynamic = false
 			eskipVAL.lbBackend = false
 		}
-	case 32:
+	case 34:
 		eskipDollar = eskipS[eskippt-1 : eskippt+1]
-//line parser.y:256
+//line parser.y:268
 		{
 			eskipVAL.shunt = false
 			eskipVAL.loopback = false
 			eskipVAL.dynamic = true
 			eskipVAL.lbBackend = false
 		}
-	case 33:
+	case 35:
 		eskipDollar = eskipS[eskippt-1 : eskippt+1]
-//line parser.y:263
+//line parser.y:275
 		{
 			eskipVAL.shunt = false
 			eskipVAL.loopback = false
@@ -752,21 +774,21 @@ eskipdefault:
 			eskipVAL.lbAlgorithm = eskipDollar[1].lbAlgorithm
 			eskipVAL.lbEndpoints = eskipDollar[1].lbEndpoints
 		}
-	case 34:
+	case 36:
 		eskipDollar = eskipS[eskippt-1 : eskippt+1]
-//line parser.y:273
+//line parser.y:285
 		{
 			eskipVAL.numval = convertNumber(eskipDollar[1].token)
 		}
-	case 35:
+	case 37:
 		eskipDollar = eskipS[eskippt-1 : eskippt+1]
-//line parser.y:278
+//line parser.y:290
 		{
 			eskipVAL.stringval = eskipDollar[1].token
 		}
-	case 36:
+	case 38:
 		eskipDollar = eskipS[eskippt-1 : eskippt+1]
-//line parser.y:283
+//line parser.y:295
 		{
 			eskipVAL.regexpval = eskipDollar[1].token
 		}
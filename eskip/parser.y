@@ -68,6 +68,8 @@ func convertNumber(s string) float64 {
 %token symbol
 %token openarrow
 %token closearrow
+%token openbracket
+%token closebracket
 
 %%
 
@@ -110,6 +112,16 @@ routeid:
 	}
 
 route:
+	routebody {
+		$$.route = $1.route
+	}
+	|
+	openbracket numval closebracket routebody {
+		$$.route = $4.route
+		$$.route.priority = $2.numval
+	}
+
+routebody:
 	frontend arrow backend {
 		$$.route = &parsedRoute{
 			matchers: $1.matchers,
@@ -332,3 +332,27 @@ func TestLBBackend(t *testing.T) {
 		})
 	}
 }
+
+func TestTCPBackend(t *testing.T) {
+	r, err := Parse(`db: Host(/^db[.]example[.]org$/) -> "tcp://10.0.0.1:5432"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(r) != 1 || r[0].BackendType != TCPBackend || r[0].Backend != "tcp://10.0.0.1:5432" {
+		t.Fatalf("failed to parse the TCP backend: %v", r)
+	}
+
+	if s := r[0].String(); s != `Host(/^db[.]example[.]org$/) -> "tcp://10.0.0.1:5432"` {
+		t.Errorf("unexpected route string: %s", s)
+	}
+
+	r, err = Parse(`* -> "https://www.example.org"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if r[0].BackendType != NetworkBackend {
+		t.Errorf("unexpected backend type: %v", r[0].BackendType)
+	}
+}
@@ -166,7 +166,7 @@ func lbBackendString(r *Route) string {
 func (r *Route) backendStringQuoted() string {
 	s := r.backendString()
 	switch {
-	case r.BackendType == NetworkBackend && !r.Shunt:
+	case r.BackendType == NetworkBackend && !r.Shunt, r.BackendType == TCPBackend:
 		return fmt.Sprintf(`"%s"`, s)
 	case r.BackendType == LBBackend:
 		return lbBackendString(r)
@@ -193,6 +193,11 @@ func (r *Route) Print(prettyPrintInfo PrettyPrintInfo) string {
 	if prettyPrintInfo.Pretty {
 		separator = "\n" + prettyPrintInfo.IndentStr + "-> "
 	}
+
+	if r.Priority != 0 {
+		return fmt.Sprintf("[%d] %s", r.Priority, strings.Join(s, separator))
+	}
+
 	return strings.Join(s, separator)
 }
 
@@ -53,6 +53,9 @@ func TestRouteString(t *testing.T) {
 	}, {
 		&Route{Method: "GET", Backend: "https://www.example.org"},
 		`Method("GET") -> "https://www.example.org"`,
+	}, {
+		&Route{Method: "GET", Priority: 10, Backend: "https://www.example.org"},
+		`[10] Method("GET") -> "https://www.example.org"`,
 	}, {
 		&Route{
 			Path:        `/some/"/path`,
@@ -236,7 +239,8 @@ func testPrinting(routestr string, expected string, t *testing.T, i int, prettyP
 
 func TestParseAndStringAndParse(t *testing.T) {
 	doc := `route1: Method("GET") -> filter("expression") -> <shunt>;` + "\n" +
-		`route2: Path("/some/path") -> "https://www.example.org";`
+		`route2: Path("/some/path") -> "https://www.example.org";` + "\n" +
+		`route3: [10] Path("/some/path") -> "https://priority.example.org";`
 	doc = testDoc(t, doc)
 	doc = testDoc(t, doc)
 	_ = testDoc(t, doc)
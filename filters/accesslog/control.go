@@ -14,8 +14,20 @@ const (
 
 	// AccessLogAdditionalDataKey is the key used in the state bag to pass extra data to access log
 	AccessLogAdditionalDataKey = "statebag:access_log:additional"
+
+	// AccessLogIdentityKey is the key used in the state bag by the auth filters to pass the identity of the
+	// caller to the access log, as *Identity
+	AccessLogIdentityKey = "statebag:access_log:identity"
 )
 
+// Identity contains the identity fields of the authenticated caller, published by the auth filters. The
+// fields included in the access log, and their masking, are set by the access log options.
+type Identity struct {
+	Subject  string
+	Realm    string
+	ClientID string
+}
+
 // Common filter struct for holding access log state
 type AccessLogFilter struct {
 	Enable   bool
@@ -0,0 +1,48 @@
+/*
+Package accounting provides the tenantKey filter, that sets the tenant of
+the request for the cost accounting of the proxy.
+*/
+package accounting
+
+import (
+	"github.com/zalando/skipper/accounting"
+	"github.com/zalando/skipper/eskip"
+	"github.com/zalando/skipper/filters"
+)
+
+type tenantKey struct {
+	template *eskip.Template
+}
+
+// NewTenantKey creates the filter specification of the tenantKey filter,
+// whose instances set the tenant of the request from a template, whose
+// usage is accounted by the proxy. When the template can't be resolved,
+// the request is not accounted.
+//
+// Example:
+//
+//	tenantKey("${request.header.X-Tenant-Id}")
+func NewTenantKey() filters.Spec { return &tenantKey{} }
+
+func (*tenantKey) Name() string { return filters.TenantKeyName }
+
+func (*tenantKey) CreateFilter(args []interface{}) (filters.Filter, error) {
+	if len(args) != 1 {
+		return nil, filters.ErrInvalidFilterParameters
+	}
+
+	value, ok := args[0].(string)
+	if !ok || value == "" {
+		return nil, filters.ErrInvalidFilterParameters
+	}
+
+	return &tenantKey{eskip.NewTemplate(value)}, nil
+}
+
+func (t *tenantKey) Request(ctx filters.FilterContext) {
+	if tenant, ok := t.template.ApplyContext(ctx); ok && tenant != "" {
+		ctx.StateBag()[accounting.TenantKey] = tenant
+	}
+}
+
+func (*tenantKey) Response(filters.FilterContext) {}
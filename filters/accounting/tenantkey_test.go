@@ -0,0 +1,42 @@
+package accounting
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/zalando/skipper/accounting"
+	"github.com/zalando/skipper/filters/filtertest"
+)
+
+func TestTenantKey(t *testing.T) {
+	spec := NewTenantKey()
+	if _, err := spec.CreateFilter(nil); err == nil {
+		t.Error("failed to fail without arguments")
+	}
+
+	f, err := spec.CreateFilter([]interface{}{"${request.header.X-Tenant-Id}"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		header   string
+		expected string
+	}{
+		{"", ""},
+		{"foo", "foo"},
+	} {
+		req, _ := http.NewRequest("GET", "https://www.example.org", nil)
+		if tt.header != "" {
+			req.Header.Set("X-Tenant-Id", tt.header)
+		}
+
+		ctx := &filtertest.Context{FRequest: req, FStateBag: make(map[string]interface{})}
+		f.Request(ctx)
+
+		tenant, _ := ctx.StateBag()[accounting.TenantKey].(string)
+		if tenant != tt.expected {
+			t.Errorf("unexpected tenant: %q, expected: %q", tenant, tt.expected)
+		}
+	}
+}
@@ -0,0 +1,110 @@
+/*
+Package annotate provides the annotate filter, that attaches key-value
+metadata to a route, e.g. the owner team or the SLO class of the service.
+
+The metadata is available to the other filters of the route via the
+GetAnnotations function, and it is added to the access log as the
+route_metadata field.
+
+Example:
+
+	r: * -> annotate("team", "checkout") -> annotate("slo", "gold") -> "https://checkout.example.org";
+
+The routing collects the annotations of the route definitions, and the
+proxy sets them before executing the filters of a matching route, so they
+are available to all the filters of the route, independent of the order
+of the filters. The routing also reports them as gauges with the value 1,
+named route.metadata.<route id>.<key>.<value>, to group the route metrics
+by the metadata.
+
+When the filter is used without the routing, e.g. in tests, the
+annotations are set during the request processing, in the order of the
+filters, and they are available only to the subsequent filters.
+*/
+package annotate
+
+import (
+	"github.com/zalando/skipper/filters"
+	al "github.com/zalando/skipper/filters/accesslog"
+)
+
+const (
+	stateBagKey = "filter." + filters.AnnotateName
+
+	// AccessLogField is the name of the access log field, that contains
+	// the annotations of the route.
+	AccessLogField = "route_metadata"
+)
+
+type spec struct{}
+
+type filter struct {
+	key, value string
+}
+
+// New creates the annotate filter specification. It expects two string
+// arguments, the key and the value.
+func New() filters.Spec { return spec{} }
+
+func (spec) Name() string { return filters.AnnotateName }
+
+func (spec) CreateFilter(args []interface{}) (filters.Filter, error) {
+	if len(args) != 2 {
+		return nil, filters.ErrInvalidFilterParameters
+	}
+
+	key, ok := args[0].(string)
+	if !ok || key == "" {
+		return nil, filters.ErrInvalidFilterParameters
+	}
+
+	value, ok := args[1].(string)
+	if !ok {
+		return nil, filters.ErrInvalidFilterParameters
+	}
+
+	return &filter{key: key, value: value}, nil
+}
+
+// returns the annotations of the request, initializing them, and adding
+// them to the access log, when necessary
+func bagAnnotations(bag map[string]interface{}) map[string]string {
+	annotations, ok := bag[stateBagKey].(map[string]string)
+	if !ok {
+		annotations = make(map[string]string)
+		bag[stateBagKey] = annotations
+
+		data, _ := bag[al.AccessLogAdditionalDataKey].(map[string]interface{})
+		if data == nil {
+			data = make(map[string]interface{})
+			bag[al.AccessLogAdditionalDataKey] = data
+		}
+
+		data[AccessLogField] = annotations
+	}
+
+	return annotations
+}
+
+// Set adds the annotations of a route to the state bag of a request. It
+// is called by the proxy, before the filters of the route are executed.
+func Set(stateBag map[string]interface{}, annotations map[string]string) {
+	a := bagAnnotations(stateBag)
+	for k, v := range annotations {
+		a[k] = v
+	}
+}
+
+func (f *filter) Request(ctx filters.FilterContext) {
+	bagAnnotations(ctx.StateBag())[f.key] = f.value
+}
+
+func (*filter) Response(filters.FilterContext) {}
+
+// GetAnnotations returns the annotations of the current route, set by the
+// annotate filters preceding the caller. The returned map must not be
+// modified.
+func GetAnnotations(ctx filters.FilterContext) map[string]string {
+	annotations, _ := ctx.StateBag()[stateBagKey].(map[string]string)
+	return annotations
+}
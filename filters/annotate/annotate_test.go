@@ -0,0 +1,85 @@
+package annotate
+
+import (
+	"testing"
+
+	"github.com/zalando/skipper/filters"
+	al "github.com/zalando/skipper/filters/accesslog"
+	"github.com/zalando/skipper/filters/filtertest"
+)
+
+func TestCreateFilter(t *testing.T) {
+	for _, test := range []struct {
+		title string
+		args  []interface{}
+		fail  bool
+	}{{
+		title: "no args",
+		fail:  true,
+	}, {
+		title: "only key",
+		args:  []interface{}{"team"},
+		fail:  true,
+	}, {
+		title: "empty key",
+		args:  []interface{}{"", "checkout"},
+		fail:  true,
+	}, {
+		title: "value not a string",
+		args:  []interface{}{"slo", 99.9},
+		fail:  true,
+	}, {
+		title: "too many args",
+		args:  []interface{}{"team", "checkout", "foo"},
+		fail:  true,
+	}, {
+		title: "key and value",
+		args:  []interface{}{"team", "checkout"},
+	}} {
+		t.Run(test.title, func(t *testing.T) {
+			_, err := New().CreateFilter(test.args)
+			if test.fail && err == nil {
+				t.Fatal("failed to fail")
+			} else if !test.fail && err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestAnnotations(t *testing.T) {
+	create := func(key, value string) filters.Filter {
+		f, err := New().CreateFilter([]interface{}{key, value})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return f
+	}
+
+	ctx := &filtertest.Context{FStateBag: make(map[string]interface{})}
+	if a := GetAnnotations(ctx); len(a) != 0 {
+		t.Fatalf("unexpected annotations: %v", a)
+	}
+
+	// other access log data is preserved
+	ctx.FStateBag[al.AccessLogAdditionalDataKey] = map[string]interface{}{"foo": "bar"}
+
+	create("team", "checkout").Request(ctx)
+	create("slo", "silver").Request(ctx)
+	create("slo", "gold").Request(ctx)
+
+	a := GetAnnotations(ctx)
+	if len(a) != 2 || a["team"] != "checkout" || a["slo"] != "gold" {
+		t.Fatalf("unexpected annotations: %v", a)
+	}
+
+	data := ctx.FStateBag[al.AccessLogAdditionalDataKey].(map[string]interface{})
+	if data["foo"] != "bar" {
+		t.Fatalf("access log data overwritten: %v", data)
+	}
+
+	if logged, ok := data[AccessLogField].(map[string]string); !ok || logged["team"] != "checkout" || logged["slo"] != "gold" {
+		t.Fatalf("unexpected access log data: %v", data)
+	}
+}
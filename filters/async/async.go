@@ -0,0 +1,317 @@
+/*
+Package async provides the asyncRequest and asyncResult filters, that
+decouple the very slow backend operations from the clients that can't wait
+for them.
+
+The asyncRequest filter accepts the request, and responds immediately with
+202 Accepted, and with the URL of the result in the Location header. The
+request is then sent to the backend in the background, and its response is
+stored. The asyncResult filter serves the stored responses, and 202
+Accepted while the backend call is in progress.
+
+The results are stored in a store shared by the routes, by default in
+memory. The proxy can be configured to use the Redis ring instead, shared
+by the skipper instances.
+
+Example:
+
+	jobs: Path("/jobs") -> asyncRequest("/jobs/results/") -> "https://jobs.example.org";
+	results: PathSubtree("/jobs/results") -> asyncResult() -> <shunt>;
+*/
+package async
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"path"
+	"strconv"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/zalando/skipper/filters"
+)
+
+const (
+	defaultMaxBodySize = 1 << 20
+	defaultTTL         = time.Hour
+	stateKey           = "async:state"
+	idLength           = 16
+)
+
+// Options for the async filters. The asyncRequest and asyncResult filters
+// need to be created with the same store.
+type Options struct {
+	// Store is the backing store of the results. Defaults to a new
+	// in-memory store.
+	Store Store
+
+	// MaxBodySize limits the size of the request bodies accepted, and
+	// of the response bodies stored. Defaults to 1MiB.
+	MaxBodySize int64
+
+	// TTL sets how long the results are kept. Defaults to 1h.
+	TTL time.Duration
+}
+
+type requestSpec struct {
+	options Options
+}
+
+type requestFilter struct {
+	options    Options
+	resultPath string
+}
+
+type resultSpec struct {
+	options Options
+}
+
+type state struct {
+	id   string
+	ttl  time.Duration
+	done bool
+}
+
+type accepted struct {
+	ID       string `json:"id"`
+	Location string `json:"location"`
+}
+
+func (o Options) withDefaults() Options {
+	if o.Store == nil {
+		o.Store = NewMemoryStore()
+	}
+
+	if o.MaxBodySize <= 0 {
+		o.MaxBodySize = defaultMaxBodySize
+	}
+
+	if o.TTL <= 0 {
+		o.TTL = defaultTTL
+	}
+
+	return o
+}
+
+// NewRequest creates the asyncRequest filter specification. The filter
+// expects the path prefix of the result URLs, that are served by the
+// asyncResult filter using the same store, and accepts an optional second
+// argument, the time the result is kept, as a duration string.
+func NewRequest(o Options) filters.Spec {
+	return &requestSpec{options: o.withDefaults()}
+}
+
+// NewResult creates the asyncResult filter specification. The filter
+// serves the result identified by the last segment of the request path.
+func NewResult(o Options) filters.Spec {
+	return &resultSpec{options: o.withDefaults()}
+}
+
+func (*requestSpec) Name() string { return filters.AsyncRequestName }
+
+func (s *requestSpec) CreateFilter(args []interface{}) (filters.Filter, error) {
+	if len(args) == 0 || len(args) > 2 {
+		return nil, filters.ErrInvalidFilterParameters
+	}
+
+	resultPath, ok := args[0].(string)
+	if !ok || resultPath == "" {
+		return nil, filters.ErrInvalidFilterParameters
+	}
+
+	f := &requestFilter{options: s.options, resultPath: resultPath}
+	if len(args) == 2 {
+		v, ok := args[1].(string)
+		if !ok {
+			return nil, filters.ErrInvalidFilterParameters
+		}
+
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			return nil, filters.ErrInvalidFilterParameters
+		}
+
+		f.options.TTL = d
+	}
+
+	return f, nil
+}
+
+func newID() (string, error) {
+	b := make([]byte, idLength)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(b), nil
+}
+
+func serveStatus(ctx filters.FilterContext, code int) {
+	ctx.Serve(&http.Response{StatusCode: code, Header: make(http.Header)})
+}
+
+func (f *requestFilter) store(id string, r *Result, ttl time.Duration) error {
+	return f.options.Store.Set(context.Background(), id, r, ttl)
+}
+
+func (f *requestFilter) Request(ctx filters.FilterContext) {
+	// the background request, sent through the proxy again
+	if _, ok := ctx.StateBag()[stateKey].(*state); ok {
+		return
+	}
+
+	req := ctx.Request()
+	var body []byte
+	if req.Body != nil {
+		b, err := io.ReadAll(io.LimitReader(req.Body, f.options.MaxBodySize+1))
+		if err != nil {
+			log.Errorf("async: failed to read the request body: %v", err)
+			serveStatus(ctx, http.StatusBadRequest)
+			return
+		}
+
+		if int64(len(b)) > f.options.MaxBodySize {
+			serveStatus(ctx, http.StatusRequestEntityTooLarge)
+			return
+		}
+
+		body = b
+	}
+
+	id, err := newID()
+	if err != nil {
+		log.Errorf("async: failed to generate the request id: %v", err)
+		serveStatus(ctx, http.StatusInternalServerError)
+		return
+	}
+
+	st := &state{id: id, ttl: f.options.TTL}
+	if err := f.store(id, &Result{}, st.ttl); err != nil {
+		log.Errorf("async: failed to store the request state: %v", err)
+		serveStatus(ctx, http.StatusServiceUnavailable)
+		return
+	}
+
+	cc, err := ctx.Split()
+	if err != nil {
+		log.Errorf("async: failed to create the background request: %v", err)
+		serveStatus(ctx, http.StatusInternalServerError)
+		return
+	}
+
+	// the body was buffered, the split request doesn't need to wait
+	// for the original one to be read
+	cc.Request().Body = io.NopCloser(bytes.NewReader(body))
+	cc.Request().ContentLength = int64(len(body))
+	cc.StateBag()[stateKey] = st
+	go func() {
+		cc.Loopback()
+
+		// the response filters were not executed, e.g. the backend
+		// was not reachable
+		if !st.done {
+			if err := f.store(id, &Result{Done: true, StatusCode: http.StatusBadGateway}, st.ttl); err != nil {
+				log.Errorf("async: failed to store the result: %v", err)
+			}
+		}
+	}()
+
+	location := path.Join(f.resultPath, id)
+	b, _ := json.Marshal(accepted{ID: id, Location: location})
+	ctx.Serve(&http.Response{
+		StatusCode: http.StatusAccepted,
+		Header: http.Header{
+			"Content-Type":   []string{"application/json"},
+			"Content-Length": []string{strconv.Itoa(len(b))},
+			"Location":       []string{location},
+		},
+		ContentLength: int64(len(b)),
+		Body:          io.NopCloser(bytes.NewReader(b)),
+	})
+}
+
+func (f *requestFilter) Response(ctx filters.FilterContext) {
+	st, ok := ctx.StateBag()[stateKey].(*state)
+	if !ok {
+		return
+	}
+
+	delete(ctx.StateBag(), stateKey)
+	st.done = true
+
+	rsp := ctx.Response()
+	r := &Result{Done: true, StatusCode: rsp.StatusCode, Header: rsp.Header.Clone()}
+	r.Header.Del("Content-Length")
+	if rsp.Body != nil {
+		b, err := io.ReadAll(io.LimitReader(rsp.Body, f.options.MaxBodySize+1))
+		rsp.Body.Close()
+		rsp.Body = io.NopCloser(bytes.NewReader(b))
+
+		switch {
+		case err != nil:
+			log.Errorf("async: failed to read the response body: %v", err)
+			r = &Result{Done: true, StatusCode: http.StatusBadGateway}
+		case int64(len(b)) > f.options.MaxBodySize:
+			log.Errorf("async: the response of %s is too large to store", st.id)
+			r = &Result{Done: true, StatusCode: http.StatusInsufficientStorage}
+		default:
+			r.Body = b
+		}
+	}
+
+	if err := f.store(st.id, r, st.ttl); err != nil {
+		log.Errorf("async: failed to store the result: %v", err)
+	}
+}
+
+func (*resultSpec) Name() string { return filters.AsyncResultName }
+
+func (s *resultSpec) CreateFilter(args []interface{}) (filters.Filter, error) {
+	if len(args) != 0 {
+		return nil, filters.ErrInvalidFilterParameters
+	}
+
+	return s, nil
+}
+
+func (s *resultSpec) Request(ctx filters.FilterContext) {
+	req := ctx.Request()
+	id := path.Base(req.URL.Path)
+	if _, err := hex.DecodeString(id); err != nil || len(id) != 2*idLength {
+		serveStatus(ctx, http.StatusNotFound)
+		return
+	}
+
+	r, err := s.options.Store.Get(req.Context(), id)
+	if err != nil {
+		log.Errorf("async: failed to get the result: %v", err)
+		serveStatus(ctx, http.StatusServiceUnavailable)
+		return
+	}
+
+	switch {
+	case r == nil:
+		serveStatus(ctx, http.StatusNotFound)
+	case !r.Done:
+		serveStatus(ctx, http.StatusAccepted)
+	default:
+		h := r.Header.Clone()
+		if h == nil {
+			h = make(http.Header)
+		}
+
+		ctx.Serve(&http.Response{
+			StatusCode:    r.StatusCode,
+			Header:        h,
+			ContentLength: int64(len(r.Body)),
+			Body:          io.NopCloser(bytes.NewReader(r.Body)),
+		})
+	}
+}
+
+func (*resultSpec) Response(filters.FilterContext) {}
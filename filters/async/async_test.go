@@ -0,0 +1,231 @@
+package async
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/zalando/skipper/eskip"
+	"github.com/zalando/skipper/filters"
+	"github.com/zalando/skipper/proxy/proxytest"
+)
+
+func startProxy(t *testing.T, o Options, handler http.HandlerFunc) (string, func()) {
+	backend := httptest.NewServer(handler)
+	routes, err := eskip.Parse(fmt.Sprintf(`
+		jobs: Path("/jobs") -> asyncRequest("/results/") -> "%s";
+		results: PathSubtree("/results") -> asyncResult() -> <shunt>;
+	`, backend.URL))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if o.Store == nil {
+		o.Store = NewMemoryStore()
+	}
+
+	fr := make(filters.Registry)
+	fr.Register(NewRequest(o))
+	fr.Register(NewResult(o))
+	p := proxytest.New(fr, routes...)
+	return p.URL, func() {
+		p.Close()
+		backend.Close()
+	}
+}
+
+func poll(t *testing.T, url string) *http.Response {
+	t.Helper()
+	timeout := time.After(3 * time.Second)
+	for {
+		rsp, err := http.Get(url)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if rsp.StatusCode != http.StatusAccepted {
+			return rsp
+		}
+
+		rsp.Body.Close()
+		select {
+		case <-timeout:
+			t.Fatal("timeout while waiting for the result")
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+}
+
+func TestCreateFilter(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		args []interface{}
+		err  bool
+	}{{
+		name: "no args",
+		err:  true,
+	}, {
+		name: "result path",
+		args: []interface{}{"/results/"},
+	}, {
+		name: "result path and ttl",
+		args: []interface{}{"/results/", "10m"},
+	}, {
+		name: "invalid result path",
+		args: []interface{}{42},
+		err:  true,
+	}, {
+		name: "invalid ttl",
+		args: []interface{}{"/results/", "foo"},
+		err:  true,
+	}, {
+		name: "too many args",
+		args: []interface{}{"/results/", "10m", "foo"},
+		err:  true,
+	}} {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewRequest(Options{}).CreateFilter(tt.args)
+			if tt.err != (err != nil) {
+				t.Errorf("unexpected error: %v", err)
+			}
+		})
+	}
+
+	if _, err := NewResult(Options{}).CreateFilter([]interface{}{"foo"}); err == nil {
+		t.Error("failed to fail on the args of the result filter")
+	}
+}
+
+func TestAsync(t *testing.T) {
+	release := make(chan struct{})
+	u, stop := startProxy(t, Options{}, func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		<-release
+		w.Header().Set("X-Test", "foo")
+		w.WriteHeader(http.StatusCreated)
+		w.Write(append([]byte("created "), b...))
+	})
+	defer stop()
+
+	rsp, err := http.Post(u+"/jobs", "text/plain", strings.NewReader("job"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer rsp.Body.Close()
+	if rsp.StatusCode != http.StatusAccepted {
+		t.Fatalf("unexpected status: %d", rsp.StatusCode)
+	}
+
+	var a accepted
+	if err := json.NewDecoder(rsp.Body).Decode(&a); err != nil {
+		t.Fatal(err)
+	}
+
+	location := rsp.Header.Get("Location")
+	if a.Location != location || !strings.HasPrefix(location, "/results/") || !strings.HasSuffix(location, a.ID) {
+		t.Fatalf("unexpected location: %s, %v", location, a)
+	}
+
+	pending, err := http.Get(u + location)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pending.Body.Close()
+	if pending.StatusCode != http.StatusAccepted {
+		t.Errorf("unexpected status of the pending result: %d", pending.StatusCode)
+	}
+
+	close(release)
+	result := poll(t, u+location)
+	defer result.Body.Close()
+	b, err := io.ReadAll(result.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if result.StatusCode != http.StatusCreated || result.Header.Get("X-Test") != "foo" || string(b) != "created job" {
+		t.Errorf("unexpected result: %d, %v, %s", result.StatusCode, result.Header, b)
+	}
+}
+
+func TestAsyncResultNotFound(t *testing.T) {
+	u, stop := startProxy(t, Options{}, func(http.ResponseWriter, *http.Request) {})
+	defer stop()
+
+	for _, p := range []string{"/results/foo", "/results/" + strings.Repeat("0", 2*idLength)} {
+		rsp, err := http.Get(u + p)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rsp.Body.Close()
+		if rsp.StatusCode != http.StatusNotFound {
+			t.Errorf("unexpected status for %s: %d", p, rsp.StatusCode)
+		}
+	}
+}
+
+func TestAsyncBodyLimits(t *testing.T) {
+	u, stop := startProxy(t, Options{MaxBodySize: 8}, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("larger than the limit"))
+	})
+	defer stop()
+
+	rsp, err := http.Post(u+"/jobs", "text/plain", strings.NewReader("larger than the limit"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rsp.Body.Close()
+	if rsp.StatusCode != http.StatusRequestEntityTooLarge {
+		t.Errorf("unexpected status of the large request: %d", rsp.StatusCode)
+	}
+
+	rsp, err = http.Get(u + "/jobs")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rsp.Body.Close()
+	result := poll(t, u+rsp.Header.Get("Location"))
+	result.Body.Close()
+	if result.StatusCode != http.StatusInsufficientStorage {
+		t.Errorf("unexpected status of the large result: %d", result.StatusCode)
+	}
+}
+
+func TestAsyncBackendUnreachable(t *testing.T) {
+	routes, err := eskip.Parse(`
+		jobs: Path("/jobs") -> asyncRequest("/results/") -> "http://127.0.0.1:1";
+		results: PathSubtree("/results") -> asyncResult() -> <shunt>;
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	o := Options{Store: NewMemoryStore()}
+	fr := make(filters.Registry)
+	fr.Register(NewRequest(o))
+	fr.Register(NewResult(o))
+	p := proxytest.New(fr, routes...)
+	defer p.Close()
+
+	rsp, err := http.Get(p.URL + "/jobs")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rsp.Body.Close()
+	result := poll(t, p.URL+rsp.Header.Get("Location"))
+	result.Body.Close()
+	if result.StatusCode != http.StatusBadGateway {
+		t.Errorf("unexpected status: %d", result.StatusCode)
+	}
+}
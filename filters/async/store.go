@@ -0,0 +1,135 @@
+package async
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v9"
+	"github.com/zalando/skipper/net"
+)
+
+const (
+	redisKeyPrefix = "skipper.async."
+
+	// the expired results are removed from the memory store at most
+	// this often
+	sweepInterval = time.Minute
+)
+
+// Result is the state of an async request, and its response once the
+// backend call completed.
+type Result struct {
+	// Done tells whether the backend call completed.
+	Done bool `json:"done"`
+
+	StatusCode int         `json:"status,omitempty"`
+	Header     http.Header `json:"header,omitempty"`
+	Body       []byte      `json:"body,omitempty"`
+}
+
+// Store is the backing store of the async results. The implementations
+// need to be safe for concurrent use.
+type Store interface {
+	// Get returns the result stored with the id, or nil when not found.
+	Get(ctx context.Context, id string) (*Result, error)
+
+	// Set stores the result with the id, for the time set by ttl.
+	Set(ctx context.Context, id string, r *Result, ttl time.Duration) error
+}
+
+type memoryItem struct {
+	result  *Result
+	expires time.Time
+}
+
+type memoryStore struct {
+	mu        sync.Mutex
+	items     map[string]memoryItem
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+type redisStore struct {
+	ring *net.RedisRingClient
+}
+
+// NewMemoryStore creates an in-memory store. The results are not shared
+// by the skipper instances, so the results need to be polled from the
+// same instance that accepted the request.
+func NewMemoryStore() Store {
+	return &memoryStore{
+		items: make(map[string]memoryItem),
+		now:   time.Now,
+	}
+}
+
+func (s *memoryStore) Get(_ context.Context, id string) (*Result, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	item, ok := s.items[id]
+	if !ok {
+		return nil, nil
+	}
+
+	if !s.now().Before(item.expires) {
+		delete(s.items, id)
+		return nil, nil
+	}
+
+	return item.result, nil
+}
+
+func (s *memoryStore) Set(_ context.Context, id string, r *Result, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	if now.Sub(s.lastSweep) >= sweepInterval {
+		for id, item := range s.items {
+			if !now.Before(item.expires) {
+				delete(s.items, id)
+			}
+		}
+
+		s.lastSweep = now
+	}
+
+	s.items[id] = memoryItem{result: r, expires: now.Add(ttl)}
+	return nil
+}
+
+// NewRedisStore creates a store using the Redis ring, shared by the
+// skipper instances connected to the same ring.
+func NewRedisStore(ring *net.RedisRingClient) Store {
+	return &redisStore{ring: ring}
+}
+
+func (s *redisStore) Get(ctx context.Context, id string) (*Result, error) {
+	v, err := s.ring.Get(ctx, redisKeyPrefix+id)
+	if err == redis.Nil {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	var r Result
+	if err := json.Unmarshal([]byte(v), &r); err != nil {
+		return nil, err
+	}
+
+	return &r, nil
+}
+
+func (s *redisStore) Set(ctx context.Context, id string, r *Result, ttl time.Duration) error {
+	b, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.ring.Set(ctx, redisKeyPrefix+id, b, ttl)
+	return err
+}
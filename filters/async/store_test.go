@@ -0,0 +1,36 @@
+package async
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestMemoryStore(t *testing.T) {
+	now := time.Now()
+	s := NewMemoryStore().(*memoryStore)
+	s.now = func() time.Time { return now }
+
+	ctx := context.Background()
+	s.Set(ctx, "foo", &Result{Done: true, Body: []byte("foo")}, time.Minute)
+	s.Set(ctx, "bar", &Result{}, time.Hour)
+
+	if r, _ := s.Get(ctx, "foo"); r == nil || !r.Done || string(r.Body) != "foo" {
+		t.Errorf("failed to get result: %v", r)
+	}
+
+	if r, _ := s.Get(ctx, "baz"); r != nil {
+		t.Errorf("unexpected result: %v", r)
+	}
+
+	now = now.Add(2 * time.Minute)
+	if r, _ := s.Get(ctx, "foo"); r != nil {
+		t.Error("failed to expire result")
+	}
+
+	now = now.Add(2 * time.Hour)
+	s.Set(ctx, "baz", &Result{}, time.Hour)
+	if len(s.items) != 1 {
+		t.Errorf("failed to remove the expired results: %d", len(s.items))
+	}
+}
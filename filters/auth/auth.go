@@ -8,6 +8,7 @@ import (
 
 	log "github.com/sirupsen/logrus"
 	"github.com/zalando/skipper/filters"
+	al "github.com/zalando/skipper/filters/accesslog"
 	logfilter "github.com/zalando/skipper/filters/log"
 )
 
@@ -145,6 +146,18 @@ func authorized(ctx filters.FilterContext, username string) {
 	ctx.StateBag()[logfilter.AuthUserKey] = username
 }
 
+// publishes the identity of the authorized caller for the access log, taking
+// the realm and the client id from the token claims, when present
+func setIdentity(ctx filters.FilterContext, subject string, claims map[string]interface{}) {
+	id := &al.Identity{Subject: subject}
+	id.Realm, _ = claims["realm"].(string)
+	if id.ClientID, _ = claims["client_id"].(string); id.ClientID == "" {
+		id.ClientID, _ = claims["azp"].(string)
+	}
+
+	ctx.StateBag()[al.AccessLogIdentityKey] = id
+}
+
 func getStrings(args []interface{}) ([]string, error) {
 	s := make([]string, len(args))
 	var ok bool
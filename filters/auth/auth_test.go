@@ -2,6 +2,9 @@ package auth
 
 import (
 	"testing"
+
+	al "github.com/zalando/skipper/filters/accesslog"
+	"github.com/zalando/skipper/filters/filtertest"
 )
 
 const (
@@ -99,3 +102,35 @@ func Test_all(t *testing.T) {
 
 	}
 }
+
+func TestSetIdentity(t *testing.T) {
+	for _, ti := range []struct {
+		msg      string
+		claims   map[string]interface{}
+		expected al.Identity
+	}{{
+		msg:      "no claims",
+		expected: al.Identity{Subject: testSub},
+	}, {
+		msg:      "realm and client id",
+		claims:   map[string]interface{}{"realm": testRealm, "client_id": "foo", "azp": "bar"},
+		expected: al.Identity{Subject: testSub, Realm: testRealm, ClientID: "foo"},
+	}, {
+		msg:      "authorized party",
+		claims:   map[string]interface{}{"azp": "bar"},
+		expected: al.Identity{Subject: testSub, ClientID: "bar"},
+	}, {
+		msg:      "invalid claim types",
+		claims:   map[string]interface{}{"realm": 42, "client_id": true},
+		expected: al.Identity{Subject: testSub},
+	}} {
+		t.Run(ti.msg, func(t *testing.T) {
+			ctx := &filtertest.Context{FStateBag: make(map[string]interface{})}
+			setIdentity(ctx, testSub, ti.claims)
+			id, ok := ctx.StateBag()[al.AccessLogIdentityKey].(*al.Identity)
+			if !ok || *id != ti.expected {
+				t.Errorf("unexpected identity: %+v, expected: %+v", id, ti.expected)
+			}
+		})
+	}
+}
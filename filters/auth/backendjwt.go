@@ -0,0 +1,316 @@
+package auth
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	jwt "github.com/golang-jwt/jwt/v4"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/net/http/httpguts"
+
+	"github.com/zalando/skipper/filters"
+	logfilter "github.com/zalando/skipper/filters/log"
+	"github.com/zalando/skipper/routing"
+	"github.com/zalando/skipper/secrets"
+)
+
+const (
+	// BackendJWTIssuer is set as the issuer of the tokens minted by
+	// the backendJWT filter.
+	BackendJWTIssuer = "skipper"
+
+	// DefaultBackendJWTHeader is the header carrying the tokens minted
+	// by the backendJWT filter, unless specified otherwise.
+	DefaultBackendJWTHeader = "X-Skipper-Identity"
+
+	defaultBackendJWTTTL = time.Minute
+	kidLength            = 16
+)
+
+type (
+	// BackendJWTSpec creates the backendJWT filters.
+	BackendJWTSpec struct {
+		secretsReader secrets.SecretsReader
+		now           func() time.Time
+		mu            sync.Mutex
+		keys          map[string]*signingKey
+	}
+
+	backendJWTFilter struct {
+		spec    *BackendJWTSpec
+		keyName string
+		ttl     time.Duration
+		header  string
+		routeId string
+	}
+
+	backendJWTPostProcessor struct{}
+
+	signingKey struct {
+		raw    []byte
+		method jwt.SigningMethod
+		key    interface{}
+		kid    string
+	}
+)
+
+// NewBackendJWT creates a filter spec, whose filters mint short-lived
+// tokens, signed by skipper, describing the client identity verified
+// by the preceding auth filters and the route. The tokens are
+// attached to the backend requests, so that the internal services can
+// authorize the calls without validating the user tokens again.
+//
+// The signing keys are read from the secrets reader. PEM encoded RSA,
+// ECDSA P-256 and Ed25519 private keys are used for RS256, ES256 and
+// EdDSA signatures, any other secret is used as an HS256 key. The key
+// id of the tokens is derived from the key, so replacing the key file
+// rotates the key id, too.
+//
+// Example:
+//
+//	oauthTokeninfoAnyScope("read") -> backendJWT("backend-signing-key") -> "https://internal.example.org"
+func NewBackendJWT(sr secrets.SecretsReader) *BackendJWTSpec {
+	return &BackendJWTSpec{
+		secretsReader: sr,
+		now:           time.Now,
+		keys:          make(map[string]*signingKey),
+	}
+}
+
+func (*BackendJWTSpec) Name() string { return filters.BackendJWTName }
+
+func (s *BackendJWTSpec) CreateFilter(args []interface{}) (filters.Filter, error) {
+	if len(args) < 1 || len(args) > 3 {
+		return nil, filters.ErrInvalidFilterParameters
+	}
+
+	keyName, ok := args[0].(string)
+	if !ok || keyName == "" {
+		return nil, filters.ErrInvalidFilterParameters
+	}
+
+	f := &backendJWTFilter{
+		spec:    s,
+		keyName: keyName,
+		ttl:     defaultBackendJWTTTL,
+		header:  DefaultBackendJWTHeader,
+	}
+
+	if len(args) > 1 {
+		ttl, ok := args[1].(string)
+		if !ok {
+			return nil, filters.ErrInvalidFilterParameters
+		}
+
+		d, err := time.ParseDuration(ttl)
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("invalid token ttl: %s", ttl)
+		}
+
+		f.ttl = d
+	}
+
+	if len(args) > 2 {
+		header, ok := args[2].(string)
+		if !ok || !httpguts.ValidHeaderFieldName(header) {
+			return nil, filters.ErrInvalidFilterParameters
+		}
+
+		f.header = header
+	}
+
+	return f, nil
+}
+
+// PostProcessor returns the route post-processor, that makes the route
+// ids available to the filters for the route claim of the tokens.
+func (*BackendJWTSpec) PostProcessor() routing.PostProcessor {
+	return backendJWTPostProcessor{}
+}
+
+// InspectedFilters implements routing.FilterInspector.
+func (backendJWTPostProcessor) InspectedFilters() []string {
+	return []string{filters.BackendJWTName}
+}
+
+func (backendJWTPostProcessor) Do(routes []*routing.Route) []*routing.Route {
+	for _, r := range routes {
+		for _, f := range r.Filters {
+			if bf, ok := f.Filter.(*backendJWTFilter); ok {
+				bf.routeId = r.Id
+			}
+		}
+	}
+
+	return routes
+}
+
+func keyID(b []byte) string {
+	h := sha256.Sum256(b)
+	return base64.RawURLEncoding.EncodeToString(h[:])[:kidLength]
+}
+
+func parseSigningKey(raw []byte) (*signingKey, error) {
+	block, _ := pem.Decode(raw)
+	if block == nil {
+		if len(raw) == 0 {
+			return nil, errors.New("empty signing key")
+		}
+
+		return &signingKey{raw: raw, method: jwt.SigningMethodHS256, key: raw, kid: keyID(raw)}, nil
+	}
+
+	var (
+		key interface{}
+		err error
+	)
+
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		key, err = x509.ParseECPrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("unsupported signing key type: %s", block.Type)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	sk := &signingKey{raw: raw, key: key}
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		sk.method = jwt.SigningMethodRS256
+	case *ecdsa.PrivateKey:
+		if k.Curve != elliptic.P256() {
+			return nil, errors.New("unsupported elliptic curve, only P-256 is supported")
+		}
+
+		sk.method = jwt.SigningMethodES256
+	case ed25519.PrivateKey:
+		sk.method = jwt.SigningMethodEdDSA
+	default:
+		return nil, fmt.Errorf("unsupported signing key: %T", key)
+	}
+
+	// the key id is derived from the public key, so that the backends
+	// can derive it the same way
+	pub, err := x509.MarshalPKIXPublicKey(key.(crypto.Signer).Public())
+	if err != nil {
+		return nil, err
+	}
+
+	sk.kid = keyID(pub)
+	return sk, nil
+}
+
+// returns the parsed key, and parses it again only when the secret has
+// changed
+func (s *BackendJWTSpec) signingKey(name string) (*signingKey, error) {
+	raw, ok := s.secretsReader.GetSecret(name)
+	if !ok {
+		return nil, fmt.Errorf("signing key not found: %s", name)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if k, ok := s.keys[name]; ok && bytes.Equal(k.raw, raw) {
+		return k, nil
+	}
+
+	k, err := parseSigningKey(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	s.keys[name] = k
+	return k, nil
+}
+
+func audience(ctx filters.FilterContext) string {
+	if u, err := url.Parse(ctx.BackendUrl()); err == nil && u.Host != "" {
+		return u.Host
+	}
+
+	return ctx.OutgoingHost()
+}
+
+func newJTI() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func (f *backendJWTFilter) token(ctx filters.FilterContext) (string, error) {
+	k, err := f.spec.signingKey(f.keyName)
+	if err != nil {
+		return "", err
+	}
+
+	jti, err := newJTI()
+	if err != nil {
+		return "", err
+	}
+
+	now := f.spec.now()
+	claims := jwt.MapClaims{
+		"iss": BackendJWTIssuer,
+		"aud": audience(ctx),
+		"iat": now.Unix(),
+		"exp": now.Add(f.ttl).Unix(),
+		"jti": jti,
+	}
+
+	if f.routeId != "" {
+		claims["route"] = f.routeId
+	}
+
+	// only set, when one of the auth filters verified the client
+	if sub, ok := ctx.StateBag()[logfilter.AuthUserKey].(string); ok && sub != "" {
+		claims["sub"] = sub
+	}
+
+	t := jwt.NewWithClaims(k.method, claims)
+	t.Header["kid"] = k.kid
+	return t.SignedString(k.key)
+}
+
+func (f *backendJWTFilter) Request(ctx filters.FilterContext) {
+	h := ctx.Request().Header
+
+	// never forward a token received from the client
+	h.Del(f.header)
+
+	t, err := f.token(ctx)
+	if err != nil {
+		log.Errorf("Failed to create backend token: %v.", err)
+		ctx.Serve(&http.Response{StatusCode: http.StatusInternalServerError})
+		return
+	}
+
+	h.Set(f.header, t)
+}
+
+func (*backendJWTFilter) Response(filters.FilterContext) {}
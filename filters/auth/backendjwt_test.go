@@ -0,0 +1,220 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	jwt "github.com/golang-jwt/jwt/v4"
+
+	"github.com/zalando/skipper/eskip"
+	"github.com/zalando/skipper/filters/filtertest"
+	logfilter "github.com/zalando/skipper/filters/log"
+	"github.com/zalando/skipper/routing"
+)
+
+type testSecrets map[string][]byte
+
+func (s testSecrets) GetSecret(name string) ([]byte, bool) {
+	b, ok := s[name]
+	return b, ok
+}
+
+func (testSecrets) Close() {}
+
+func pemKey(t *testing.T, key crypto.Signer) []byte {
+	b, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: b})
+}
+
+func TestBackendJWTCreateFilter(t *testing.T) {
+	for _, args := range [][]interface{}{
+		nil,
+		{""},
+		{42},
+		{"key", "foo"},
+		{"key", "-1m"},
+		{"key", "1m", "invalid header"},
+		{"key", "1m", "X-Foo", "bar"},
+	} {
+		if _, err := NewBackendJWT(testSecrets{}).CreateFilter(args); err == nil {
+			t.Errorf("%v: failed to fail", args)
+		}
+	}
+}
+
+func TestBackendJWT(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		name   string
+		secret []byte
+		alg    string
+		verify interface{}
+	}{
+		{name: "rsa", secret: pemKey(t, rsaKey), alg: "RS256", verify: rsaKey.Public()},
+		{name: "ecdsa", secret: pemKey(t, ecKey), alg: "ES256", verify: ecKey.Public()},
+		{name: "ed25519", secret: pemKey(t, edKey), alg: "EdDSA", verify: edKey.Public()},
+		{name: "hmac", secret: []byte("shared secret"), alg: "HS256", verify: []byte("shared secret")},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			now := time.Now()
+			s := NewBackendJWT(testSecrets{"key": tt.secret})
+			s.now = func() time.Time { return now }
+
+			f, err := s.CreateFilter([]interface{}{"key", "30s"})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			s.PostProcessor().Do([]*routing.Route{{
+				Route:   eskip.Route{Id: "route1"},
+				Filters: []*routing.RouteFilter{{Filter: f}},
+			}})
+
+			req := httptest.NewRequest("GET", "http://www.example.org/foo", nil)
+			req.Header.Set(DefaultBackendJWTHeader, "spoofed")
+			ctx := &filtertest.Context{
+				FRequest:    req,
+				FStateBag:   map[string]interface{}{logfilter.AuthUserKey: "jdoe"},
+				FBackendUrl: "https://backend.example.org",
+			}
+
+			f.Request(ctx)
+			if ctx.FServed {
+				t.Fatal("unexpected response")
+			}
+
+			claims := jwt.MapClaims{}
+			token, err := jwt.ParseWithClaims(req.Header.Get(DefaultBackendJWTHeader), claims, func(token *jwt.Token) (interface{}, error) {
+				return tt.verify, nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if token.Method.Alg() != tt.alg {
+				t.Errorf("unexpected algorithm: %s", token.Method.Alg())
+			}
+
+			if token.Header["kid"] != s.keys["key"].kid || len(s.keys["key"].kid) != kidLength {
+				t.Errorf("unexpected key id: %v", token.Header["kid"])
+			}
+
+			for name, expected := range map[string]interface{}{
+				"iss":   BackendJWTIssuer,
+				"sub":   "jdoe",
+				"aud":   "backend.example.org",
+				"route": "route1",
+				"iat":   float64(now.Unix()),
+				"exp":   float64(now.Add(30 * time.Second).Unix()),
+			} {
+				if claims[name] != expected {
+					t.Errorf("unexpected %s claim: %v, expected: %v", name, claims[name], expected)
+				}
+			}
+		})
+	}
+}
+
+func TestBackendJWTNoIdentity(t *testing.T) {
+	f, err := NewBackendJWT(testSecrets{"key": []byte("secret")}).CreateFilter([]interface{}{"key", "1m", "X-Backend-Token"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest("GET", "http://www.example.org/foo", nil)
+	f.Request(&filtertest.Context{
+		FRequest:      req,
+		FStateBag:     make(map[string]interface{}),
+		FOutgoingHost: "www.example.org",
+	})
+
+	claims := jwt.MapClaims{}
+	if _, err := jwt.ParseWithClaims(req.Header.Get("X-Backend-Token"), claims, func(*jwt.Token) (interface{}, error) {
+		return []byte("secret"), nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := claims["sub"]; ok {
+		t.Error("unexpected subject")
+	}
+
+	if claims["aud"] != "www.example.org" {
+		t.Errorf("unexpected audience: %v", claims["aud"])
+	}
+}
+
+func TestBackendJWTKeyRotation(t *testing.T) {
+	secrets := testSecrets{"key": []byte("secret1")}
+	s := NewBackendJWT(secrets)
+	k1, err := s.signingKey("key")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if k, _ := s.signingKey("key"); k != k1 {
+		t.Error("failed to reuse the parsed key")
+	}
+
+	secrets["key"] = []byte("secret2")
+	k2, err := s.signingKey("key")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if k2.kid == k1.kid {
+		t.Error("failed to rotate the key id")
+	}
+}
+
+func TestBackendJWTMissingKey(t *testing.T) {
+	for _, sr := range []testSecrets{
+		{},
+		{"key": []byte("-----BEGIN CERTIFICATE-----\nMIIB\n-----END CERTIFICATE-----\n")},
+	} {
+		f, err := NewBackendJWT(sr).CreateFilter([]interface{}{"key"})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req := httptest.NewRequest("GET", "http://www.example.org/foo", nil)
+		req.Header.Set(DefaultBackendJWTHeader, "spoofed")
+		ctx := &filtertest.Context{FRequest: req, FStateBag: make(map[string]interface{})}
+		f.Request(ctx)
+		if !ctx.FServed || ctx.FResponse.StatusCode != http.StatusInternalServerError {
+			t.Error("failed to reject the request")
+		}
+
+		if req.Header.Get(DefaultBackendJWTHeader) != "" {
+			t.Error("failed to remove the header")
+		}
+	}
+}
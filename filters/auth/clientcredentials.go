@@ -0,0 +1,324 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/opentracing/opentracing-go"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/sync/singleflight"
+
+	"github.com/zalando/skipper/filters"
+	"github.com/zalando/skipper/net"
+	"github.com/zalando/skipper/secrets"
+)
+
+const (
+	clientCredentialsSpanName = "clientcredentials"
+
+	defaultClientCredentialsTimeout       = 5 * time.Second
+	defaultClientCredentialsRefreshBefore = 30 * time.Second
+
+	// used when the token response doesn't tell the expiry
+	defaultClientCredentialsTTL = 5 * time.Minute
+
+	maxTokenResponseSize = 1 << 16
+)
+
+// ClientCredentialsOptions configures the ClientCredentialsProvider.
+type ClientCredentialsOptions struct {
+	// Timeout of the token requests, defaults to 5s.
+	Timeout time.Duration
+
+	// MaxIdleConns per token endpoint host.
+	MaxIdleConns int
+
+	// Tracer used for the spans of the token requests.
+	Tracer opentracing.Tracer
+
+	// RefreshBefore tells how long before their expiry the tokens are
+	// refreshed, defaults to 30s. For the short-lived tokens, it is at
+	// most half of their lifetime.
+	RefreshBefore time.Duration
+}
+
+type (
+	// ClientCredentialsProvider fetches OAuth2 tokens with the client
+	// credentials grant, and caches them until shortly before they
+	// expire. The cache is shared by all the routes, so the routes using
+	// the same client and audience use the same token, and concurrent
+	// requests needing a new token trigger only a single token request.
+	ClientCredentialsProvider struct {
+		secretsReader secrets.SecretsReader
+		options       ClientCredentialsOptions
+		client        *net.Client
+		now           func() time.Time
+		group         singleflight.Group
+
+		mu     sync.Mutex
+		tokens map[clientCredentialsKey]*clientCredentialsToken
+	}
+
+	// identifies a client and an audience
+	clientCredentialsKey struct {
+		tokenURL, clientIDName, clientSecretName, audience, scope string
+	}
+
+	clientCredentialsToken struct {
+		accessToken            string
+		clientID, clientSecret string
+		refresh, expiry        time.Time
+	}
+
+	clientCredentialsResponse struct {
+		AccessToken string `json:"access_token"`
+		TokenType   string `json:"token_type"`
+		ExpiresIn   int64  `json:"expires_in"`
+	}
+
+	clientCredentialsSpec struct {
+		provider *ClientCredentialsProvider
+	}
+
+	clientCredentialsFilter struct {
+		provider *ClientCredentialsProvider
+		key      clientCredentialsKey
+	}
+)
+
+// NewClientCredentialsProvider creates a token provider, reading the
+// client ids and secrets from the secrets reader.
+func NewClientCredentialsProvider(sr secrets.SecretsReader, o ClientCredentialsOptions) *ClientCredentialsProvider {
+	if o.Timeout <= 0 {
+		o.Timeout = defaultClientCredentialsTimeout
+	}
+
+	if o.MaxIdleConns <= 0 {
+		o.MaxIdleConns = defaultMaxIdleConns
+	}
+
+	if o.Tracer == nil {
+		o.Tracer = opentracing.NoopTracer{}
+	}
+
+	if o.RefreshBefore <= 0 {
+		o.RefreshBefore = defaultClientCredentialsRefreshBefore
+	}
+
+	return &ClientCredentialsProvider{
+		secretsReader: sr,
+		options:       o,
+		client: net.NewClient(net.Options{
+			ResponseHeaderTimeout:   o.Timeout,
+			TLSHandshakeTimeout:     o.Timeout,
+			MaxIdleConnsPerHost:     o.MaxIdleConns,
+			Tracer:                  o.Tracer,
+			OpentracingComponentTag: "skipper",
+			OpentracingSpanName:     clientCredentialsSpanName,
+		}),
+		now:    time.Now,
+		tokens: make(map[clientCredentialsKey]*clientCredentialsToken),
+	}
+}
+
+// Close releases the connections of the provider.
+func (p *ClientCredentialsProvider) Close() {
+	p.client.Close()
+}
+
+func (p *ClientCredentialsProvider) credentials(k clientCredentialsKey) (string, string, error) {
+	id, ok := p.secretsReader.GetSecret(k.clientIDName)
+	if !ok {
+		return "", "", fmt.Errorf("client id not found: %s", k.clientIDName)
+	}
+
+	secret, ok := p.secretsReader.GetSecret(k.clientSecretName)
+	if !ok {
+		return "", "", fmt.Errorf("client secret not found: %s", k.clientSecretName)
+	}
+
+	return strings.TrimSpace(string(id)), strings.TrimSpace(string(secret)), nil
+}
+
+func (p *ClientCredentialsProvider) cached(k clientCredentialsKey) *clientCredentialsToken {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.tokens[k]
+}
+
+// token returns the cached token of the client, or fetches a new one when
+// the cached token needs to be refreshed, or the credentials have changed.
+// When refreshing fails, the cached token is used until it expires.
+func (p *ClientCredentialsProvider) token(k clientCredentialsKey) (string, error) {
+	id, secret, err := p.credentials(k)
+	if err != nil {
+		return "", err
+	}
+
+	now := p.now()
+	t := p.cached(k)
+	valid := t != nil && t.clientID == id && t.clientSecret == secret && now.Before(t.expiry)
+	if valid && now.Before(t.refresh) {
+		return t.accessToken, nil
+	}
+
+	v, err, _ := p.group.Do(fmt.Sprintf("%q", k), func() (interface{}, error) {
+		t, err := p.fetch(k, id, secret)
+		if err != nil {
+			return nil, err
+		}
+
+		p.mu.Lock()
+		p.tokens[k] = t
+		p.mu.Unlock()
+		return t.accessToken, nil
+	})
+
+	if err != nil {
+		if valid {
+			log.Warnf("Failed to refresh client credentials token, using the cached one: %v.", err)
+			return t.accessToken, nil
+		}
+
+		return "", err
+	}
+
+	return v.(string), nil
+}
+
+func (p *ClientCredentialsProvider) fetch(k clientCredentialsKey, id, secret string) (*clientCredentialsToken, error) {
+	form := url.Values{"grant_type": {"client_credentials"}}
+	if k.audience != "" {
+		form.Set("audience", k.audience)
+	}
+
+	if k.scope != "" {
+		form.Set("scope", k.scope)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), p.options.Timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, "POST", k.tokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+
+	req.SetBasicAuth(url.QueryEscape(id), url.QueryEscape(secret))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	start := p.now()
+	rsp, err := p.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rsp.Body.Close()
+	if rsp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to get client credentials token, status: %d", rsp.StatusCode)
+	}
+
+	b, err := io.ReadAll(io.LimitReader(rsp.Body, maxTokenResponseSize))
+	if err != nil {
+		return nil, err
+	}
+
+	var r clientCredentialsResponse
+	if err := json.Unmarshal(b, &r); err != nil {
+		return nil, err
+	}
+
+	if r.AccessToken == "" {
+		return nil, errors.New("missing access token in the client credentials token response")
+	}
+
+	ttl := defaultClientCredentialsTTL
+	if r.ExpiresIn > 0 {
+		ttl = time.Duration(r.ExpiresIn) * time.Second
+	}
+
+	refreshBefore := p.options.RefreshBefore
+	if refreshBefore > ttl/2 {
+		refreshBefore = ttl / 2
+	}
+
+	expiry := start.Add(ttl)
+	return &clientCredentialsToken{
+		accessToken:  r.AccessToken,
+		clientID:     id,
+		clientSecret: secret,
+		refresh:      expiry.Add(-refreshBefore),
+		expiry:       expiry,
+	}, nil
+}
+
+// NewOAuthClientCredentials creates a filter spec, whose filters set the
+// Authorization header of the backend requests to a bearer token acquired
+// with the OAuth2 client credentials grant. The tokens are provided by the
+// shared token provider.
+//
+// Example:
+//
+//	oauthClientCredentials("https://auth.example.org/oauth2/token", "app-client-id", "app-client-secret", "https://api.example.org") -> "https://api.example.org"
+func NewOAuthClientCredentials(p *ClientCredentialsProvider) filters.Spec {
+	return &clientCredentialsSpec{provider: p}
+}
+
+func (*clientCredentialsSpec) Name() string { return filters.OAuthClientCredentialsName }
+
+func (s *clientCredentialsSpec) CreateFilter(args []interface{}) (filters.Filter, error) {
+	if len(args) < 3 || len(args) > 5 {
+		return nil, filters.ErrInvalidFilterParameters
+	}
+
+	sargs := make([]string, 5)
+	for i, a := range args {
+		s, ok := a.(string)
+		if !ok {
+			return nil, filters.ErrInvalidFilterParameters
+		}
+
+		sargs[i] = s
+	}
+
+	u, err := url.Parse(sargs[0])
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return nil, fmt.Errorf("invalid token url: %s", sargs[0])
+	}
+
+	if sargs[1] == "" || sargs[2] == "" {
+		return nil, filters.ErrInvalidFilterParameters
+	}
+
+	return &clientCredentialsFilter{
+		provider: s.provider,
+		key: clientCredentialsKey{
+			tokenURL:         sargs[0],
+			clientIDName:     sargs[1],
+			clientSecretName: sargs[2],
+			audience:         sargs[3],
+			scope:            sargs[4],
+		},
+	}, nil
+}
+
+func (f *clientCredentialsFilter) Request(ctx filters.FilterContext) {
+	t, err := f.provider.token(f.key)
+	if err != nil {
+		log.Errorf("Failed to get client credentials token: %v.", err)
+		ctx.Serve(&http.Response{StatusCode: http.StatusInternalServerError})
+		return
+	}
+
+	ctx.Request().Header.Set(authHeaderName, authHeaderPrefix+t)
+}
+
+func (*clientCredentialsFilter) Response(filters.FilterContext) {}
@@ -0,0 +1,200 @@
+package auth
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/zalando/skipper/filters"
+	"github.com/zalando/skipper/filters/filtertest"
+)
+
+type testTokenServer struct {
+	*httptest.Server
+	requests  int32
+	failing   int32
+	expiresIn int
+	delay     time.Duration
+}
+
+func newTestTokenServer(t *testing.T, expiresIn int) *testTokenServer {
+	s := &testTokenServer{expiresIn: expiresIn}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&s.requests, 1)
+		time.Sleep(s.delay)
+
+		if atomic.LoadInt32(&s.failing) != 0 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+
+		id, secret, ok := r.BasicAuth()
+		if !ok || r.FormValue("grant_type") != "client_credentials" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(
+			w,
+			`{"access_token": "%s:%s:%s:%s:%d", "token_type": "Bearer", "expires_in": %d}`,
+			id, secret, r.FormValue("audience"), r.FormValue("scope"), n, s.expiresIn,
+		)
+	}))
+
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *testTokenServer) count() int {
+	return int(atomic.LoadInt32(&s.requests))
+}
+
+func testClientCredentialsFilter(t *testing.T, p *ClientCredentialsProvider, args ...interface{}) filters.Filter {
+	f, err := NewOAuthClientCredentials(p).CreateFilter(args)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return f
+}
+
+// returns the injected token, or the status of the served response
+func requestToken(f filters.Filter) (string, int) {
+	req, _ := http.NewRequest("GET", "http://www.example.org", nil)
+	ctx := &filtertest.Context{FRequest: req, FStateBag: make(map[string]interface{})}
+	f.Request(ctx)
+	if ctx.FServed {
+		return "", ctx.FResponse.StatusCode
+	}
+
+	return req.Header.Get("Authorization"), http.StatusOK
+}
+
+func TestOAuthClientCredentialsCreateFilter(t *testing.T) {
+	for _, args := range [][]interface{}{
+		nil,
+		{"https://auth.example.org/token", "client-id"},
+		{"auth.example.org", "client-id", "client-secret"},
+		{"https://auth.example.org/token", "", "client-secret"},
+		{"https://auth.example.org/token", "client-id", 42},
+		{"https://auth.example.org/token", "client-id", "client-secret", "audience", "scope", "foo"},
+	} {
+		if _, err := NewOAuthClientCredentials(nil).CreateFilter(args); err == nil {
+			t.Errorf("%v: failed to fail", args)
+		}
+	}
+}
+
+func TestOAuthClientCredentials(t *testing.T) {
+	s := newTestTokenServer(t, 3600)
+	sr := testSecrets{"client-id": []byte("foo\n"), "client-secret": []byte("bar\n")}
+	p := NewClientCredentialsProvider(sr, ClientCredentialsOptions{})
+	defer p.Close()
+
+	f1 := testClientCredentialsFilter(t, p, s.URL, "client-id", "client-secret", "https://api.example.org", "read write")
+	f2 := testClientCredentialsFilter(t, p, s.URL, "client-id", "client-secret", "https://api.example.org", "read write")
+	f3 := testClientCredentialsFilter(t, p, s.URL, "client-id", "client-secret")
+
+	for _, f := range []filters.Filter{f1, f2, f1} {
+		if token, _ := requestToken(f); token != "Bearer foo:bar:https://api.example.org:read write:1" {
+			t.Fatalf("unexpected token: %s", token)
+		}
+	}
+
+	if token, _ := requestToken(f3); token != "Bearer foo:bar:::2" {
+		t.Fatalf("unexpected token for the other audience: %s", token)
+	}
+
+	if s.count() != 2 {
+		t.Errorf("unexpected token requests: %d", s.count())
+	}
+
+	// changing the credentials requires a new token
+	sr["client-secret"] = []byte("baz")
+	if token, _ := requestToken(f1); token != "Bearer foo:baz:https://api.example.org:read write:3" {
+		t.Errorf("unexpected token after changing the secret: %s", token)
+	}
+}
+
+func TestOAuthClientCredentialsSingleRefresh(t *testing.T) {
+	s := newTestTokenServer(t, 3600)
+	s.delay = 50 * time.Millisecond
+	p := NewClientCredentialsProvider(testSecrets{"id": []byte("foo"), "secret": []byte("bar")}, ClientCredentialsOptions{})
+	defer p.Close()
+
+	f := testClientCredentialsFilter(t, p, s.URL, "id", "secret")
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if token, _ := requestToken(f); token != "Bearer foo:bar:::1" {
+				t.Errorf("unexpected token: %s", token)
+			}
+		}()
+	}
+
+	wg.Wait()
+	if s.count() != 1 {
+		t.Errorf("unexpected token requests: %d", s.count())
+	}
+}
+
+func TestOAuthClientCredentialsExpiry(t *testing.T) {
+	s := newTestTokenServer(t, 60)
+	p := NewClientCredentialsProvider(testSecrets{"id": []byte("foo"), "secret": []byte("bar")}, ClientCredentialsOptions{
+		RefreshBefore: 10 * time.Second,
+	})
+	defer p.Close()
+
+	now := time.Now()
+	p.now = func() time.Time { return now }
+
+	f := testClientCredentialsFilter(t, p, s.URL, "id", "secret")
+	if token, _ := requestToken(f); token != "Bearer foo:bar:::1" {
+		t.Fatalf("unexpected token: %s", token)
+	}
+
+	now = now.Add(45 * time.Second)
+	if token, _ := requestToken(f); token != "Bearer foo:bar:::1" {
+		t.Fatalf("unexpected token before the refresh: %s", token)
+	}
+
+	now = now.Add(10 * time.Second)
+	if token, _ := requestToken(f); token != "Bearer foo:bar:::2" {
+		t.Fatalf("failed to refresh the token: %s", token)
+	}
+
+	// the cached token is used while it is valid, when refreshing fails
+	atomic.StoreInt32(&s.failing, 1)
+	now = now.Add(55 * time.Second)
+	if token, _ := requestToken(f); token != "Bearer foo:bar:::2" {
+		t.Fatalf("failed to use the cached token: %s", token)
+	}
+
+	now = now.Add(10 * time.Second)
+	if _, status := requestToken(f); status != http.StatusInternalServerError {
+		t.Errorf("unexpected status with an expired token: %d", status)
+	}
+}
+
+func TestOAuthClientCredentialsMissingSecret(t *testing.T) {
+	s := newTestTokenServer(t, 60)
+	p := NewClientCredentialsProvider(testSecrets{"id": []byte("foo")}, ClientCredentialsOptions{})
+	defer p.Close()
+
+	f := testClientCredentialsFilter(t, p, s.URL, "id", "secret")
+	if _, status := requestToken(f); status != http.StatusInternalServerError {
+		t.Errorf("unexpected status: %d", status)
+	}
+
+	if s.count() != 0 {
+		t.Errorf("unexpected token requests: %d", s.count())
+	}
+}
@@ -46,16 +46,30 @@ func (s *jwtValidationSpec) Name() string {
 	return filters.JwtValidationName
 }
 
-func (s *jwtValidationSpec) CreateFilter(args []interface{}) (filters.Filter, error) {
+func jwtValidationIssuer(args []interface{}) (string, error) {
 	if len(args) != 1 {
-		return nil, filters.ErrInvalidFilterParameters
+		return "", filters.ErrInvalidFilterParameters
 	}
 	sargs, err := getStrings(args)
 	if err != nil {
-		return nil, err
+		return "", err
 	}
 
-	issuerURL := sargs[0]
+	return sargs[0], nil
+}
+
+// ValidateArgs checks the arguments, without fetching the OpenID
+// configuration and the keys of the issuer.
+func (s *jwtValidationSpec) ValidateArgs(args []interface{}) error {
+	_, err := jwtValidationIssuer(args)
+	return err
+}
+
+func (s *jwtValidationSpec) CreateFilter(args []interface{}) (filters.Filter, error) {
+	issuerURL, err := jwtValidationIssuer(args)
+	if err != nil {
+		return nil, err
+	}
 
 	cfg, err := getOpenIDConfig(issuerURL)
 	if err != nil {
@@ -120,6 +134,34 @@ func getKeyFunction(url string) (jwks *keyfunc.JWKS) {
 	return jwksMap[url]
 }
 
+// RegisterIssuerKeys resolves the JWKS URL of an issuer from its OpenID
+// configuration, and registers the keys in the JWKS cache shared with the
+// jwtValidation filter. It returns the JWKS URL, that can be used to verify
+// the tokens of the issuer with ParseVerifiedToken.
+func RegisterIssuerKeys(issuerURL string) (string, error) {
+	cfg, err := getOpenIDConfig(issuerURL)
+	if err != nil {
+		return "", err
+	}
+
+	if err := registerKeyFunction(cfg.JwksURI); err != nil {
+		return "", err
+	}
+
+	return cfg.JwksURI, nil
+}
+
+// ParseVerifiedToken verifies the signature and the time based claims of
+// a JWT token with the keys registered for the JWKS URL, and returns the
+// claims of the token.
+func ParseVerifiedToken(token, jwksURL string) (map[string]interface{}, error) {
+	if !hasKeyFunction(jwksURL) {
+		return nil, fmt.Errorf("no keys registered for %s", jwksURL)
+	}
+
+	return parseToken(token, jwksURL)
+}
+
 func (f *jwtValidationFilter) Request(ctx filters.FilterContext) {
 	r := ctx.Request()
 
@@ -151,6 +193,7 @@ func (f *jwtValidationFilter) Request(ctx filters.FilterContext) {
 	}
 
 	authorized(ctx, sub)
+	setIdentity(ctx, sub, info.Claims)
 
 	ctx.StateBag()[oidcClaimsCacheKey] = info
 }
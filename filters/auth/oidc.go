@@ -812,6 +812,8 @@ func (f *tokenOidcFilter) Request(ctx filters.FilterContext) {
 		return
 	}
 
+	setIdentity(ctx, container.Subject, container.Claims)
+
 	// saving token info for chained filter
 	ctx.StateBag()[oidcClaimsCacheKey] = container
 
@@ -356,6 +356,7 @@ func (f *tokeninfoFilter) Request(ctx filters.FilterContext) {
 	}
 
 	authorized(ctx, uid)
+	setIdentity(ctx, uid, authMap)
 	ctx.StateBag()[tokeninfoCacheKey] = authMap
 }
 
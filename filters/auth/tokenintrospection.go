@@ -478,6 +478,7 @@ func (f *tokenintrospectFilter) Request(ctx filters.FilterContext) {
 	}
 
 	authorized(ctx, sub)
+	setIdentity(ctx, sub, info)
 	ctx.StateBag()[tokenintrospectionCacheKey] = info
 }
 
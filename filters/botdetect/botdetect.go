@@ -0,0 +1,133 @@
+/*
+Package botdetect provides the botScore and the botBlock filters.
+
+The botScore filter scores the requests by how likely they were sent by
+bots or scrapers, see the botdetect package for the signals. It passes
+the score to the backend and to the subsequent filters:
+
+  - in the X-Bot-Score request header, replacing the header sent by the
+    client
+  - in the state bag, with the ScoreStateBagKey key
+  - in the access log, as the bot_score and bot_signals fields
+
+The botBlock filter rejects the requests scored by a preceding botScore
+filter at or above a threshold, optionally with a custom status code:
+
+	r: * -> botScore() -> botBlock(80) -> "https://app.example.org";
+	r: * -> botScore() -> botBlock(80, 429) -> "https://app.example.org";
+
+To redirect the likely bots to a challenge instead, see the BotScore
+predicate.
+*/
+package botdetect
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/zalando/skipper/botdetect"
+	"github.com/zalando/skipper/filters"
+	al "github.com/zalando/skipper/filters/accesslog"
+)
+
+const (
+	// ScoreHeader is the request header containing the score.
+	ScoreHeader = "X-Bot-Score"
+
+	// ScoreStateBagKey is the state bag key of the score, set as an
+	// int.
+	ScoreStateBagKey = "botdetect:score"
+)
+
+type scoreSpec struct {
+	detector *botdetect.Detector
+}
+
+type scoreFilter struct {
+	detector *botdetect.Detector
+}
+
+type blockSpec struct{}
+
+type blockFilter struct {
+	threshold int
+	status    int
+}
+
+// NewScore creates the botScore filter specification, using the
+// provided detector.
+func NewScore(d *botdetect.Detector) filters.Spec { return &scoreSpec{detector: d} }
+
+func (*scoreSpec) Name() string { return filters.BotScoreName }
+
+func (s *scoreSpec) CreateFilter(args []interface{}) (filters.Filter, error) {
+	if len(args) != 0 {
+		return nil, filters.ErrInvalidFilterParameters
+	}
+
+	return &scoreFilter{detector: s.detector}, nil
+}
+
+func (f *scoreFilter) Request(ctx filters.FilterContext) {
+	r := ctx.Request()
+	r.Header.Del(ScoreHeader)
+
+	result := f.detector.Observe(r)
+	r.Header.Set(ScoreHeader, strconv.Itoa(result.Score))
+
+	bag := ctx.StateBag()
+	bag[ScoreStateBagKey] = result.Score
+
+	data, _ := bag[al.AccessLogAdditionalDataKey].(map[string]interface{})
+	if data == nil {
+		data = make(map[string]interface{})
+		bag[al.AccessLogAdditionalDataKey] = data
+	}
+
+	data["bot_score"] = result.Score
+	if len(result.Signals) > 0 {
+		data["bot_signals"] = strings.Join(result.Signals, ",")
+	}
+}
+
+func (*scoreFilter) Response(filters.FilterContext) {}
+
+// NewBlock creates the botBlock filter specification.
+func NewBlock() filters.Spec { return blockSpec{} }
+
+func (blockSpec) Name() string { return filters.BotBlockName }
+
+func (blockSpec) CreateFilter(args []interface{}) (filters.Filter, error) {
+	if len(args) == 0 || len(args) > 2 {
+		return nil, filters.ErrInvalidFilterParameters
+	}
+
+	threshold, ok := args[0].(float64)
+	if !ok || threshold < 0 || threshold > 100 {
+		return nil, filters.ErrInvalidFilterParameters
+	}
+
+	f := &blockFilter{threshold: int(threshold), status: http.StatusForbidden}
+	if len(args) == 2 {
+		status, ok := args[1].(float64)
+		if !ok || status < 100 || status > 599 {
+			return nil, filters.ErrInvalidFilterParameters
+		}
+
+		f.status = int(status)
+	}
+
+	return f, nil
+}
+
+func (f *blockFilter) Request(ctx filters.FilterContext) {
+	score, ok := ctx.StateBag()[ScoreStateBagKey].(int)
+	if !ok || score < f.threshold {
+		return
+	}
+
+	ctx.Serve(&http.Response{StatusCode: f.status, Header: make(http.Header)})
+}
+
+func (*blockFilter) Response(filters.FilterContext) {}
@@ -0,0 +1,105 @@
+package botdetect
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/zalando/skipper/botdetect"
+	"github.com/zalando/skipper/filters"
+	al "github.com/zalando/skipper/filters/accesslog"
+	"github.com/zalando/skipper/filters/filtertest"
+)
+
+func TestBotScore(t *testing.T) {
+	d := botdetect.New(botdetect.Options{})
+	defer d.Close()
+
+	spec := NewScore(d)
+	if spec.Name() != filters.BotScoreName {
+		t.Errorf("invalid name: %s", spec.Name())
+	}
+
+	if _, err := spec.CreateFilter([]interface{}{"foo"}); err == nil {
+		t.Error("failed to fail")
+	}
+
+	f, err := spec.CreateFilter(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := httptest.NewRequest("GET", "/", nil)
+	r.Header.Set("User-Agent", "curl/8.1.2")
+	r.Header.Set(ScoreHeader, "0")
+
+	ctx := &filtertest.Context{FRequest: r, FStateBag: make(map[string]interface{})}
+	f.Request(ctx)
+
+	if h := r.Header.Get(ScoreHeader); h != "75" {
+		t.Errorf("unexpected score header: %s", h)
+	}
+
+	if score := ctx.FStateBag[ScoreStateBagKey]; score != 75 {
+		t.Errorf("unexpected score in the state bag: %v", score)
+	}
+
+	data := ctx.FStateBag[al.AccessLogAdditionalDataKey].(map[string]interface{})
+	if data["bot_score"] != 75 || data["bot_signals"] != "automation-tool,missing-accept,missing-accept-language,missing-accept-encoding" {
+		t.Errorf("unexpected access log data: %v", data)
+	}
+}
+
+func TestBotBlock(t *testing.T) {
+	spec := NewBlock()
+	if spec.Name() != filters.BotBlockName {
+		t.Errorf("invalid name: %s", spec.Name())
+	}
+
+	for _, tt := range []struct {
+		name   string
+		args   []interface{}
+		score  interface{}
+		err    bool
+		status int
+	}{
+		{name: "no args", err: true},
+		{name: "invalid threshold", args: []interface{}{"80"}, err: true},
+		{name: "threshold out of range", args: []interface{}{101.0}, err: true},
+		{name: "invalid status", args: []interface{}{80.0, 42.0}, err: true},
+		{name: "too many args", args: []interface{}{80.0, 403.0, 1.0}, err: true},
+		{name: "no score", args: []interface{}{80.0}},
+		{name: "below threshold", args: []interface{}{80.0}, score: 79},
+		{name: "at threshold", args: []interface{}{80.0}, score: 80, status: http.StatusForbidden},
+		{name: "custom status", args: []interface{}{50.0, 429.0}, score: 100, status: http.StatusTooManyRequests},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := spec.CreateFilter(tt.args)
+			if tt.err {
+				if err == nil {
+					t.Error("failed to fail")
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			ctx := &filtertest.Context{FRequest: httptest.NewRequest("GET", "/", nil), FStateBag: make(map[string]interface{})}
+			if tt.score != nil {
+				ctx.FStateBag[ScoreStateBagKey] = tt.score
+			}
+
+			f.Request(ctx)
+			if tt.status == 0 {
+				if ctx.FServed {
+					t.Error("unexpected response")
+				}
+			} else if !ctx.FServed || ctx.FResponse.StatusCode != tt.status {
+				t.Errorf("failed to block the request")
+			}
+		})
+	}
+}
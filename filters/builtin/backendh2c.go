@@ -0,0 +1,37 @@
+package builtin
+
+import "github.com/zalando/skipper/filters"
+
+type backendH2C struct{}
+
+// NewBackendH2C returns a filter specification that makes the proxy send
+// the requests of the route to the backend over HTTP/2. For the http
+// backends, it uses HTTP/2 without TLS (h2c) with prior knowledge, so
+// the backends inside the cluster can multiplex the streaming requests
+// on a single connection without TLS. For the https backends, it uses
+// HTTP/2 over TLS.
+//
+// The gRPC requests use HTTP/2 also without this filter.
+//
+// Example:
+//
+//	r: * -> backendH2C() -> "http://10.0.0.1:8080";
+func NewBackendH2C() filters.Spec {
+	return backendH2C{}
+}
+
+func (backendH2C) Name() string { return filters.BackendH2CName }
+
+func (backendH2C) CreateFilter(args []interface{}) (filters.Filter, error) {
+	if len(args) != 0 {
+		return nil, filters.ErrInvalidFilterParameters
+	}
+
+	return backendH2C{}, nil
+}
+
+func (backendH2C) Request(ctx filters.FilterContext) {
+	ctx.StateBag()[filters.BackendH2CKey] = true
+}
+
+func (backendH2C) Response(filters.FilterContext) {}
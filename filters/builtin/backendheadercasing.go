@@ -0,0 +1,79 @@
+package builtin
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/zalando/skipper/filters"
+)
+
+type backendHeaderCasingSpec struct{}
+
+type backendHeaderCasingFilter struct {
+	casing map[string]string
+}
+
+// the header names handled by the transport, whose casing cannot be
+// changed
+var fixedCasingHeaders = map[string]bool{
+	"Host":              true,
+	"Content-Length":    true,
+	"Transfer-Encoding": true,
+	"Trailer":           true,
+	"User-Agent":        true,
+	"Connection":        true,
+}
+
+// NewBackendHeaderCasing returns a filter specification that is used to
+// force the casing of the header names in the requests sent to the backend,
+// for the backends that don't handle the header names case insensitively.
+// The header names are otherwise sent in the canonical format, e.g.
+// X-Api-Key.
+//
+// Example:
+//
+//	r: * -> backendHeaderCasing("X-API-KEY", "content-md5") -> "http://legacy.example.org";
+func NewBackendHeaderCasing() filters.Spec {
+	return &backendHeaderCasingSpec{}
+}
+
+func (*backendHeaderCasingSpec) Name() string {
+	return filters.BackendHeaderCasingName
+}
+
+func (*backendHeaderCasingSpec) CreateFilter(args []interface{}) (filters.Filter, error) {
+	if len(args) == 0 {
+		return nil, filters.ErrInvalidFilterParameters
+	}
+
+	f := &backendHeaderCasingFilter{casing: make(map[string]string)}
+	for _, a := range args {
+		name, ok := a.(string)
+		if !ok || name == "" || strings.ContainsAny(name, " \t\r\n:") {
+			return nil, filters.ErrInvalidFilterParameters
+		}
+
+		canonical := http.CanonicalHeaderKey(name)
+		if fixedCasingHeaders[canonical] {
+			return nil, filters.ErrInvalidFilterParameters
+		}
+
+		f.casing[canonical] = name
+	}
+
+	return f, nil
+}
+
+func (f *backendHeaderCasingFilter) Request(ctx filters.FilterContext) {
+	casing, ok := ctx.StateBag()[filters.BackendHeaderCasingKey].(map[string]string)
+	if !ok {
+		casing = make(map[string]string, len(f.casing))
+		ctx.StateBag()[filters.BackendHeaderCasingKey] = casing
+	}
+
+	for canonical, name := range f.casing {
+		casing[canonical] = name
+	}
+}
+
+func (*backendHeaderCasingFilter) Response(filters.FilterContext) {}
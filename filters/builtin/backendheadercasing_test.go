@@ -0,0 +1,44 @@
+package builtin
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/zalando/skipper/filters"
+	"github.com/zalando/skipper/filters/filtertest"
+)
+
+func TestBackendHeaderCasing(t *testing.T) {
+	spec := NewBackendHeaderCasing()
+	for _, args := range [][]interface{}{
+		nil,
+		{""},
+		{42},
+		{"X API KEY"},
+		{"content-length"},
+		{"X-API-KEY", "HOST"},
+	} {
+		if _, err := spec.CreateFilter(args); err == nil {
+			t.Errorf("%v: failed to fail", args)
+		}
+	}
+
+	f1, err := spec.CreateFilter([]interface{}{"X-API-KEY", "content-md5"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f2, err := spec.CreateFilter([]interface{}{"x-api-key"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := &filtertest.Context{FStateBag: make(map[string]interface{})}
+	f1.Request(ctx)
+	f2.Request(ctx)
+
+	expected := map[string]string{"X-Api-Key": "x-api-key", "Content-Md5": "content-md5"}
+	if casing := ctx.FStateBag[filters.BackendHeaderCasingKey]; !reflect.DeepEqual(casing, expected) {
+		t.Errorf("unexpected casing: %v, expected: %v", casing, expected)
+	}
+}
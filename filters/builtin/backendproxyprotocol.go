@@ -0,0 +1,38 @@
+package builtin
+
+import "github.com/zalando/skipper/filters"
+
+type backendProxyProtocol struct{}
+
+// NewBackendProxyProtocol returns a filter specification that makes the
+// proxy send a PROXY protocol v2 header on the backend connections of
+// the route, carrying the address of the client and the address of the
+// listener that accepted the incoming connection. It allows the L4
+// backends to see the original client address without trusting the
+// X-Forwarded-For header.
+//
+// The backend connections of these routes are not reused, because they
+// are bound to a single client address.
+//
+// Example:
+//
+//	r: * -> backendProxyProtocol() -> "http://10.0.0.1:8080";
+func NewBackendProxyProtocol() filters.Spec {
+	return backendProxyProtocol{}
+}
+
+func (backendProxyProtocol) Name() string { return filters.BackendProxyProtocolName }
+
+func (backendProxyProtocol) CreateFilter(args []interface{}) (filters.Filter, error) {
+	if len(args) != 0 {
+		return nil, filters.ErrInvalidFilterParameters
+	}
+
+	return backendProxyProtocol{}, nil
+}
+
+func (backendProxyProtocol) Request(ctx filters.FilterContext) {
+	ctx.StateBag()[filters.BackendProxyProtocolKey] = true
+}
+
+func (backendProxyProtocol) Response(filters.FilterContext) {}
@@ -0,0 +1,25 @@
+package builtin
+
+import (
+	"testing"
+
+	"github.com/zalando/skipper/filters"
+	"github.com/zalando/skipper/filters/filtertest"
+)
+
+func TestBackendProxyProtocol(t *testing.T) {
+	if _, err := NewBackendProxyProtocol().CreateFilter([]interface{}{"v2"}); err == nil {
+		t.Error("failed to fail")
+	}
+
+	f, err := NewBackendProxyProtocol().CreateFilter(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := &filtertest.Context{FStateBag: make(map[string]interface{})}
+	f.Request(ctx)
+	if ctx.FStateBag[filters.BackendProxyProtocolKey] != true {
+		t.Error("failed to set the state bag key")
+	}
+}
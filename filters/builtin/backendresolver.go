@@ -0,0 +1,53 @@
+package builtin
+
+import (
+	"github.com/zalando/skipper/filters"
+	"github.com/zalando/skipper/net/dnscache"
+)
+
+type backendResolverSpec struct{}
+
+type backendResolverFilter struct {
+	server string
+}
+
+// NewBackendResolver returns a filter specification that is used to
+// resolve the backend host of a route with a specific DNS server, given
+// as host or host:port, where the port defaults to 53. The resolved
+// addresses are cached, and used also when the resolution fails, see
+// the package net/dnscache.
+//
+// Example:
+//
+//	r: * -> backendResolver("10.0.0.53") -> "http://app.internal.example.org";
+func NewBackendResolver() filters.Spec {
+	return &backendResolverSpec{}
+}
+
+func (*backendResolverSpec) Name() string {
+	return filters.BackendResolverName
+}
+
+func (*backendResolverSpec) CreateFilter(args []interface{}) (filters.Filter, error) {
+	if len(args) != 1 {
+		return nil, filters.ErrInvalidFilterParameters
+	}
+
+	server, ok := args[0].(string)
+	if !ok || server == "" {
+		return nil, filters.ErrInvalidFilterParameters
+	}
+
+	address, ok := dnscache.ServerAddress(server)
+	if !ok {
+		return nil, filters.ErrInvalidFilterParameters
+	}
+
+	return &backendResolverFilter{server: address}, nil
+}
+
+func (f *backendResolverFilter) Request(ctx filters.FilterContext) {
+	ctx.StateBag()[filters.BackendResolverKey] = f.server
+}
+
+func (*backendResolverFilter) Response(filters.FilterContext) {}
@@ -0,0 +1,45 @@
+package builtin
+
+import (
+	"testing"
+
+	"github.com/zalando/skipper/filters"
+	"github.com/zalando/skipper/filters/filtertest"
+)
+
+func TestBackendResolver(t *testing.T) {
+	for _, tt := range []struct {
+		args   []interface{}
+		server string
+	}{
+		{args: nil},
+		{args: []interface{}{""}},
+		{args: []interface{}{42}},
+		{args: []interface{}{"dns.example.org"}},
+		{args: []interface{}{"10.0.0.53", "10.0.0.54"}},
+		{args: []interface{}{"10.0.0.53"}, server: "10.0.0.53:53"},
+		{args: []interface{}{"10.0.0.53:5353"}, server: "10.0.0.53:5353"},
+		{args: []interface{}{"fd00::53"}, server: "[fd00::53]:53"},
+		{args: []interface{}{"[fd00::53]:5353"}, server: "[fd00::53]:5353"},
+	} {
+		f, err := NewBackendResolver().CreateFilter(tt.args)
+		if tt.server == "" {
+			if err == nil {
+				t.Errorf("%v: failed to fail", tt.args)
+			}
+
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("%v: %v", tt.args, err)
+			continue
+		}
+
+		ctx := &filtertest.Context{FStateBag: make(map[string]interface{})}
+		f.Request(ctx)
+		if s := ctx.FStateBag[filters.BackendResolverKey]; s != tt.server {
+			t.Errorf("%v: unexpected server: %v, expected: %s", tt.args, s, tt.server)
+		}
+	}
+}
@@ -0,0 +1,36 @@
+package builtin
+
+import "github.com/zalando/skipper/filters"
+
+type backendZeroCopy struct{}
+
+// NewBackendZeroCopy returns a filter specification that makes the proxy
+// copy the response bodies of the route from the backend connection to
+// the client connection without copying them through userspace buffers,
+// using splice on Linux. It applies to the http backends, and to the
+// responses of known length, that are not changed by the filters, e.g.
+// large file downloads. The backend connections of these requests are
+// not reused.
+//
+// Example:
+//
+//	r: Path("/downloads/*") -> backendZeroCopy() -> "http://10.0.0.1:8080";
+func NewBackendZeroCopy() filters.Spec {
+	return backendZeroCopy{}
+}
+
+func (backendZeroCopy) Name() string { return filters.BackendZeroCopyName }
+
+func (backendZeroCopy) CreateFilter(args []interface{}) (filters.Filter, error) {
+	if len(args) != 0 {
+		return nil, filters.ErrInvalidFilterParameters
+	}
+
+	return backendZeroCopy{}, nil
+}
+
+func (backendZeroCopy) Request(ctx filters.FilterContext) {
+	ctx.StateBag()[filters.BackendZeroCopyKey] = true
+}
+
+func (backendZeroCopy) Response(filters.FilterContext) {}
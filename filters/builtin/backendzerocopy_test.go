@@ -0,0 +1,25 @@
+package builtin
+
+import (
+	"testing"
+
+	"github.com/zalando/skipper/filters"
+	"github.com/zalando/skipper/filters/filtertest"
+)
+
+func TestBackendZeroCopy(t *testing.T) {
+	if _, err := NewBackendZeroCopy().CreateFilter([]interface{}{"true"}); err == nil {
+		t.Error("failed to fail")
+	}
+
+	f, err := NewBackendZeroCopy().CreateFilter(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := &filtertest.Context{FStateBag: make(map[string]interface{})}
+	f.Request(ctx)
+	if ctx.FStateBag[filters.BackendZeroCopyKey] != true {
+		t.Error("failed to set the state bag key")
+	}
+}
@@ -6,20 +6,28 @@ package builtin
 import (
 	"github.com/zalando/skipper/filters"
 	"github.com/zalando/skipper/filters/accesslog"
+	"github.com/zalando/skipper/filters/accounting"
+	"github.com/zalando/skipper/filters/annotate"
 	"github.com/zalando/skipper/filters/auth"
 	"github.com/zalando/skipper/filters/circuit"
+	"github.com/zalando/skipper/filters/coalesce"
 	"github.com/zalando/skipper/filters/consistenthash"
 	"github.com/zalando/skipper/filters/cookie"
 	"github.com/zalando/skipper/filters/cors"
 	"github.com/zalando/skipper/filters/diag"
 	"github.com/zalando/skipper/filters/fadein"
 	"github.com/zalando/skipper/filters/flowid"
+	"github.com/zalando/skipper/filters/headerpolicy"
+	"github.com/zalando/skipper/filters/limits"
 	logfilter "github.com/zalando/skipper/filters/log"
+	"github.com/zalando/skipper/filters/retry"
 	"github.com/zalando/skipper/filters/rfc"
 	"github.com/zalando/skipper/filters/scheduler"
 	"github.com/zalando/skipper/filters/sed"
 	"github.com/zalando/skipper/filters/tee"
 	"github.com/zalando/skipper/filters/tracing"
+	"github.com/zalando/skipper/filters/transport"
+	"github.com/zalando/skipper/filters/websocket"
 	"github.com/zalando/skipper/filters/xforward"
 	"github.com/zalando/skipper/script"
 )
@@ -115,6 +123,24 @@ const (
 func Filters() []filters.Spec {
 	return []filters.Spec{
 		NewBackendIsProxy(),
+		NewBackendHeaderCasing(),
+		NewLegacyBackend(),
+		NewBackendResolver(),
+		NewRetryOnResponse(),
+		NewHedgeRequests(),
+		retry.NewRetryPolicy(),
+		coalesce.New(),
+		NewBackendProxyProtocol(),
+		NewGRPCHealthCheck(),
+		NewBackendH2C(),
+		transport.NewBackendTransport(),
+		headerpolicy.NewBackendHeaderPolicy(),
+		NewDeadlinePropagation(),
+		NewBackendZeroCopy(),
+		websocket.NewMaxMessageSize(),
+		websocket.NewMessageRate(),
+		accounting.NewTenantKey(),
+		NewPrewarmConnections(),
 		NewRequestHeader(),
 		NewSetRequestHeader(),
 		NewAppendRequestHeader(),
@@ -123,6 +149,10 @@ func Filters() []filters.Spec {
 		NewSetResponseHeader(),
 		NewAppendResponseHeader(),
 		NewDropResponseHeader(),
+		NewKeepRequestHeaders(),
+		NewKeepResponseHeaders(),
+		NewDropRequestHeadersRegexp(),
+		NewDropResponseHeadersRegexp(),
 		NewSetContextRequestHeader(),
 		NewAppendContextRequestHeader(),
 		NewSetContextResponseHeader(),
@@ -149,13 +179,19 @@ func Filters() []filters.Spec {
 		xforward.New(),
 		xforward.NewFirst(),
 		PreserveHost(),
+		NewPreserveOriginalPath(),
 		NewSetFastCgiFilename(),
+		NewSetFastCgiParam(),
 		NewStatus(),
 		NewCompress(),
 		NewDecompress(),
 		NewHeaderToQuery(),
 		NewQueryToHeader(),
 		NewBackendTimeout(),
+		NewEarlyHints(),
+		NewClientDisconnect(),
+		NewStreaming(),
+		NewMinTLSVersion(),
 		NewSetDynamicBackendHostFromHeader(),
 		NewSetDynamicBackendSchemeFromHeader(),
 		NewSetDynamicBackendUrlFromHeader(),
@@ -178,10 +214,12 @@ func Filters() []filters.Spec {
 		diag.NewNormalRequestLatency(),
 		diag.NewUniformResponseLatency(),
 		diag.NewNormalResponseLatency(),
+		diag.NewPercentileLatency(),
 		tee.NewTee(),
 		tee.NewTeeDeprecated(),
 		tee.NewTeeNoFollow(),
 		tee.NewTeeLoopback(),
+		tee.NewMirrorTraffic(),
 		sed.New(),
 		sed.NewDelimited(),
 		sed.NewRequest(),
@@ -207,14 +245,22 @@ func Filters() []filters.Spec {
 		auth.NewForwardToken(),
 		auth.NewForwardTokenField(),
 		scheduler.NewFifo(),
+		scheduler.NewQueueHeaders(),
 		scheduler.NewLIFO(),
 		scheduler.NewLIFOGroup(),
+		scheduler.NewBulkhead(),
 		rfc.NewPath(),
 		rfc.NewHost(),
 		fadein.NewFadeIn(),
 		fadein.NewEndpointCreated(),
 		consistenthash.NewConsistentHashKey(),
 		consistenthash.NewConsistentHashBalanceFactor(),
+		limits.NewAllowedMethods(),
+		limits.NewMaxURLLength(),
+		limits.NewMaxHeaderCount(),
+		limits.NewMaxHeaderSize(),
+		limits.NewMaxRequestBodySize(),
+		annotate.New(),
 	}
 }
 
@@ -0,0 +1,82 @@
+package builtin
+
+import (
+	"time"
+
+	"github.com/zalando/skipper/filters"
+)
+
+type clientDisconnect struct {
+	gracePeriod time.Duration
+}
+
+// NewClientDisconnect creates a filter specification, whose instances
+// define how the proxy handles the backend request of the route, when the
+// client disconnects before receiving the response:
+//
+//   - "cancel" cancels the backend request immediately, this is the
+//     default
+//   - "grace" followed by a duration cancels the backend request when it
+//     has not finished within the grace period after the disconnect
+//   - "ignore" doesn't cancel the backend request, it only ends with its
+//     own timeouts
+//
+// Example:
+//
+//	clientDisconnect("ignore")
+//	clientDisconnect("grace", "10s")
+func NewClientDisconnect() filters.Spec {
+	return &clientDisconnect{}
+}
+
+func (*clientDisconnect) Name() string { return filters.ClientDisconnectName }
+
+func (*clientDisconnect) CreateFilter(args []interface{}) (filters.Filter, error) {
+	if len(args) == 0 {
+		return nil, filters.ErrInvalidFilterParameters
+	}
+
+	mode, ok := args[0].(string)
+	if !ok {
+		return nil, filters.ErrInvalidFilterParameters
+	}
+
+	switch mode {
+	case "cancel":
+		if len(args) != 1 {
+			return nil, filters.ErrInvalidFilterParameters
+		}
+
+		return &clientDisconnect{}, nil
+	case "ignore":
+		if len(args) != 1 {
+			return nil, filters.ErrInvalidFilterParameters
+		}
+
+		return &clientDisconnect{gracePeriod: -1}, nil
+	case "grace":
+		if len(args) != 2 {
+			return nil, filters.ErrInvalidFilterParameters
+		}
+
+		s, ok := args[1].(string)
+		if !ok {
+			return nil, filters.ErrInvalidFilterParameters
+		}
+
+		d, err := time.ParseDuration(s)
+		if err != nil || d <= 0 {
+			return nil, filters.ErrInvalidFilterParameters
+		}
+
+		return &clientDisconnect{gracePeriod: d}, nil
+	default:
+		return nil, filters.ErrInvalidFilterParameters
+	}
+}
+
+func (f *clientDisconnect) Request(ctx filters.FilterContext) {
+	ctx.StateBag()[filters.ClientDisconnectKey] = f.gracePeriod
+}
+
+func (*clientDisconnect) Response(filters.FilterContext) {}
@@ -0,0 +1,53 @@
+package builtin
+
+import (
+	"testing"
+	"time"
+
+	"github.com/zalando/skipper/filters"
+	"github.com/zalando/skipper/filters/filtertest"
+)
+
+func TestClientDisconnect(t *testing.T) {
+	spec := NewClientDisconnect()
+	if spec.Name() != filters.ClientDisconnectName {
+		t.Error("wrong name")
+	}
+
+	for _, args := range [][]interface{}{
+		nil,
+		{"abort"},
+		{42.0},
+		{"cancel", "1s"},
+		{"ignore", "1s"},
+		{"grace"},
+		{"grace", 1.0},
+		{"grace", "soon"},
+		{"grace", "-1s"},
+		{"grace", "1s", "2s"},
+	} {
+		if _, err := spec.CreateFilter(args); err == nil {
+			t.Errorf("failed to fail: %v", args)
+		}
+	}
+
+	for _, tc := range []struct {
+		args     []interface{}
+		expected time.Duration
+	}{
+		{args: []interface{}{"cancel"}, expected: 0},
+		{args: []interface{}{"ignore"}, expected: -1},
+		{args: []interface{}{"grace", "3s"}, expected: 3 * time.Second},
+	} {
+		f, err := spec.CreateFilter(tc.args)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		c := &filtertest.Context{FStateBag: make(map[string]interface{})}
+		f.Request(c)
+		if d := c.FStateBag[filters.ClientDisconnectKey]; d != tc.expected {
+			t.Errorf("%v: expected %v, got %v", tc.args, tc.expected, d)
+		}
+	}
+}
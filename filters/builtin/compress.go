@@ -1,6 +1,7 @@
 package builtin
 
 import (
+	"bytes"
 	"compress/flate"
 	"compress/gzip"
 	"errors"
@@ -18,7 +19,13 @@ import (
 	"github.com/zalando/skipper/filters"
 )
 
-const bufferSize = 8192
+const (
+	bufferSize = 8192
+
+	// the default size of the beginning of the response bodies buffered
+	// to decide whether to compress them
+	defaultCompressBufferSize = 8192
+)
 
 type encoding struct {
 	name string
@@ -32,11 +39,30 @@ type compress struct {
 	mime             []string
 	level            int
 	encodingPriority map[string]int
+	minSize          int
+	minRatio         float64
+	bufferSize       int
 }
 
 type CompressOptions struct {
 	// Specifies encodings supported for compression, the order defines priority when Accept-Header has equal quality values, see RFC 7231 section 5.3.1
 	Encodings []string
+
+	// MinSize is the default minimum size of the response bodies to
+	// compress. The routes can override it.
+	MinSize int
+
+	// MinRatio is the default minimum ratio of the size saved by the
+	// compression, measured on the beginning of the response body, e.g.
+	// 0.1 means at least 10%. Zero disables the check. The routes can
+	// override it.
+	MinRatio float64
+
+	// BufferSize is the maximum number of bytes buffered from the
+	// beginning of the response bodies to decide whether to compress
+	// them, when the Content-Length is not known, or when checking the
+	// compression ratio. Defaults to 8192.
+	BufferSize int
 }
 
 type encoder interface {
@@ -62,6 +88,26 @@ var defaultCompressMIME = []string{
 	"application/octet-stream",
 }
 
+// these content types are not compressed, even when configured, because
+// their content is already compressed
+var compressedMIME = []string{
+	"application/gzip",
+	"application/x-gzip",
+	"application/zip",
+	"application/x-bzip2",
+	"application/x-xz",
+	"application/x-7z-compressed",
+	"application/x-rar-compressed",
+	"application/zstd",
+	"font/woff",
+	"font/woff2",
+	"image/png",
+	"image/jpeg",
+	"image/gif",
+	"image/webp",
+	"image/avif",
+}
+
 var (
 	brotliPool = &sync.Pool{New: func() interface{} {
 		ge, err := newEncoder("br", flate.BestSpeed)
@@ -131,6 +177,19 @@ func (e encodings) Swap(i, j int) { e[i], e[j] = e[j], e[i] }
 //
 //	r: * -> compress(9, "image/tiff") -> "https://www.example.org";
 //
+// The second and the third numeric arguments set the minimum size of the
+// response bodies to compress, and the minimum ratio of the size saved by
+// the compression, e.g. to compress only the bodies of at least 1KB, that
+// shrink by at least 10%:
+//
+//	r: * -> compress(1, 1024, 0.1) -> "https://www.example.org";
+//
+// When the Content-Length of the response is not known, or the ratio needs to
+// be checked, the filter buffers the beginning of the body, up to the
+// configured buffer size, to decide. The buffered content is also checked
+// for already compressed formats. The audio, video and already compressed
+// content types, e.g. image/png or application/zip, are never compressed.
+//
 // The filter also checks the incoming request, if it accepts the supported
 // encodings, explicitly stated in the Accept-Encoding header. The filter currently
 // supports brotli, gzip and deflate. It does not assume that the client accepts any
@@ -148,7 +207,7 @@ func (e encodings) Swap(i, j int) { e[i], e[j] = e[j], e[i] }
 //
 // The compression happens in a streaming way, using only a small internal buffer.
 func NewCompress() filters.Spec {
-	c, err := NewCompressWithOptions(CompressOptions{supportedEncodings})
+	c, err := NewCompressWithOptions(CompressOptions{Encodings: supportedEncodings})
 	if err != nil {
 		log.Warningf("Failed to create compress filter: %v", err)
 	}
@@ -163,7 +222,20 @@ func NewCompressWithOptions(options CompressOptions) (filters.Spec, error) {
 		}
 		m[v] = i
 	}
-	return &compress{encodingPriority: m}, nil
+	if options.MinSize < 0 || options.MinRatio < 0 || options.MinRatio >= 1 || options.BufferSize < 0 {
+		return nil, filters.ErrInvalidFilterParameters
+	}
+
+	if options.BufferSize == 0 {
+		options.BufferSize = defaultCompressBufferSize
+	}
+
+	return &compress{
+		encodingPriority: m,
+		minSize:          options.MinSize,
+		minRatio:         options.MinRatio,
+		bufferSize:       options.BufferSize,
+	}, nil
 }
 
 func (c *compress) Name() string {
@@ -175,6 +247,9 @@ func (c *compress) CreateFilter(args []interface{}) (filters.Filter, error) {
 		mime:             defaultCompressMIME,
 		level:            flate.BestSpeed,
 		encodingPriority: c.encodingPriority,
+		minSize:          c.minSize,
+		minRatio:         c.minRatio,
+		bufferSize:       c.bufferSize,
 	}
 
 	if len(args) == 0 {
@@ -190,6 +265,28 @@ func (c *compress) CreateFilter(args []interface{}) (filters.Filter, error) {
 		args = args[1:]
 	}
 
+	if len(args) > 0 {
+		if ms, ok := args[0].(float64); ok {
+			if ms < 0 || math.Trunc(ms) != ms {
+				return nil, filters.ErrInvalidFilterParameters
+			}
+
+			f.minSize = int(ms)
+			args = args[1:]
+		}
+	}
+
+	if len(args) > 0 {
+		if mr, ok := args[0].(float64); ok {
+			if mr < 0 || mr >= 1 {
+				return nil, filters.ErrInvalidFilterParameters
+			}
+
+			f.minRatio = mr
+			args = args[1:]
+		}
+	}
+
 	if len(args) == 0 {
 		return f, nil
 	}
@@ -237,18 +334,102 @@ func canEncodeEntity(r *http.Response, mime []string) bool {
 		return false
 	}
 
-	ct := r.Header.Get("Content-Type")
+	ct := mediaType(r.Header.Get("Content-Type"))
+	if !stringsContain(mime, ct) || isCompressedMIME(ct) {
+		return false
+	}
+
+	return true
+}
+
+func mediaType(ct string) string {
 	if i := strings.Index(ct, ";"); i >= 0 {
 		ct = ct[:i]
 	}
 
-	if !stringsContain(mime, ct) {
+	return ct
+}
+
+func isCompressedMIME(ct string) bool {
+	ct = strings.ToLower(strings.TrimSpace(ct))
+	return strings.HasPrefix(ct, "audio/") ||
+		strings.HasPrefix(ct, "video/") ||
+		stringsContain(compressedMIME, ct)
+}
+
+type bufferedBody struct {
+	io.Reader
+	io.Closer
+}
+
+type countingWriter int
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	*w += countingWriter(len(p))
+	return len(p), nil
+}
+
+// returns the ratio of the size saved by compressing the sample with the
+// fastest compression
+func savedRatio(sample []byte) float64 {
+	var n countingWriter
+	e, err := flate.NewWriter(&n, flate.BestSpeed)
+	if err != nil {
+		return 0
+	}
+
+	e.Write(sample)
+	e.Close()
+	return 1 - float64(n)/float64(len(sample))
+}
+
+// worthCompressing decides whether to compress the response body, based on
+// its size, its content and its expected compression ratio. When necessary,
+// it buffers the beginning of the body, and replaces the body of the
+// response with one that starts with the buffered content.
+func (c *compress) worthCompressing(rsp *http.Response) bool {
+	contentLength := int64(-1)
+	if cl, err := strconv.ParseInt(rsp.Header.Get("Content-Length"), 10, 64); err == nil {
+		contentLength = cl
+	}
+
+	if contentLength >= 0 && contentLength < int64(c.minSize) {
 		return false
 	}
 
-	return true
+	if c.minRatio <= 0 && (c.minSize <= 0 || contentLength >= 0) {
+		return true
+	}
+
+	sample, err := io.ReadAll(io.LimitReader(rsp.Body, int64(c.bufferSize)))
+	rest := rsp.Body
+	if err != nil {
+		rest = io.NopCloser(&failingReader{err: err})
+	}
+
+	rsp.Body = bufferedBody{Reader: io.MultiReader(bytes.NewReader(sample), rest), Closer: rsp.Body}
+	if err != nil {
+		// let the client receive the error with the uncompressed content
+		return false
+	}
+
+	if len(sample) < c.bufferSize && len(sample) < c.minSize {
+		return false
+	}
+
+	if len(sample) == 0 || isCompressedMIME(mediaType(http.DetectContentType(sample))) {
+		return false
+	}
+
+	return c.minRatio <= 0 || savedRatio(sample) >= c.minRatio
+}
+
+type failingReader struct {
+	err error
 }
 
+func (r *failingReader) Read([]byte) (int, error) { return 0, r.err }
+
 func (c *compress) acceptedEncoding(r *http.Request) string {
 	var encs encodings
 	for _, s := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
@@ -421,6 +602,10 @@ func (c *compress) Response(ctx filters.FilterContext) {
 		return
 	}
 
+	if !c.worthCompressing(rsp) {
+		return
+	}
+
 	responseHeader(rsp, enc)
 	responseBody(rsp, enc, c.level)
 }
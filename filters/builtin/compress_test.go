@@ -758,3 +758,150 @@ func BenchmarkCompressBrotli2(b *testing.B) { benchmarkCompress(b, 100, []string
 func BenchmarkCompressBrotli4(b *testing.B) { benchmarkCompress(b, 10000, []string{"br"}) }
 func BenchmarkCompressBrotli6(b *testing.B) { benchmarkCompress(b, 1000000, []string{"br"}) }
 func BenchmarkCompressBrotli8(b *testing.B) { benchmarkCompress(b, 100000000, []string{"br"}) }
+
+func TestCompressSizeArgs(t *testing.T) {
+	for _, args := range [][]interface{}{
+		{1.0, -1.0},
+		{1.0, 10.5},
+		{1.0, 1024.0, 1.0},
+		{1.0, 1024.0, -0.1},
+	} {
+		if _, err := NewCompress().CreateFilter(args); err == nil {
+			t.Errorf("%v: failed to fail", args)
+		}
+	}
+
+	f, err := NewCompress().CreateFilter([]interface{}{6.0, 1024.0, 0.1, "...", "x/custom"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := f.(*compress)
+	if c.level != 6 || c.minSize != 1024 || c.minRatio != 0.1 || len(c.mime) != len(defaultCompressMIME)+1 {
+		t.Errorf("unexpected settings: %+v", c)
+	}
+
+	spec, err := NewCompressWithOptions(CompressOptions{Encodings: supportedEncodings, MinSize: 256, MinRatio: 0.2})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f, err = spec.CreateFilter([]interface{}{1.0, 512.0})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c = f.(*compress)
+	if c.minSize != 512 || c.minRatio != 0.2 || c.bufferSize != defaultCompressBufferSize {
+		t.Errorf("unexpected settings: %+v", c)
+	}
+
+	if _, err := NewCompressWithOptions(CompressOptions{Encodings: supportedEncodings, MinRatio: 1}); err == nil {
+		t.Error("failed to fail")
+	}
+}
+
+func TestCompressDecision(t *testing.T) {
+	compressible := bytes.Repeat([]byte("skipper "), 4096)
+	gzipped := &bytes.Buffer{}
+	gw := gzip.NewWriter(gzipped)
+	gw.Write(compressible)
+	gw.Close()
+
+	for _, tt := range []struct {
+		title         string
+		args          []interface{}
+		contentType   string
+		contentLength int
+		body          []byte
+		compressed    bool
+	}{{
+		title:       "default, small body",
+		contentType: "text/plain",
+		body:        []byte("foo"),
+		compressed:  true,
+	}, {
+		title:         "known small size",
+		args:          []interface{}{1.0, 1024.0},
+		contentType:   "text/plain",
+		contentLength: 3,
+		body:          []byte("foo"),
+	}, {
+		title:       "unknown small size",
+		args:        []interface{}{1.0, 1024.0},
+		contentType: "text/plain",
+		body:        []byte("foo"),
+	}, {
+		title:         "known large size",
+		args:          []interface{}{1.0, 1024.0},
+		contentType:   "text/plain",
+		contentLength: len(compressible),
+		body:          compressible,
+		compressed:    true,
+	}, {
+		title:       "unknown large size",
+		args:        []interface{}{1.0, 1024.0},
+		contentType: "text/plain",
+		body:        compressible,
+		compressed:  true,
+	}, {
+		title:       "poor ratio",
+		args:        []interface{}{1.0, 0.0, 0.1},
+		contentType: "application/octet-stream",
+		body:        testContent[:3*8192],
+	}, {
+		title:       "good ratio",
+		args:        []interface{}{1.0, 0.0, 0.1},
+		contentType: "application/octet-stream",
+		body:        compressible,
+		compressed:  true,
+	}, {
+		title:       "sniffed compressed content",
+		args:        []interface{}{1.0, 1024.0},
+		contentType: "application/octet-stream",
+		body:        gzipped.Bytes(),
+	}, {
+		title:       "compressed content type",
+		args:        []interface{}{"image/png", "video/mp4"},
+		contentType: "video/mp4",
+		body:        compressible,
+	}} {
+		t.Run(tt.title, func(t *testing.T) {
+			f, err := NewCompress().CreateFilter(tt.args)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			rsp := &http.Response{
+				Header: http.Header{"Content-Type": []string{tt.contentType}},
+				Body:   io.NopCloser(bytes.NewReader(tt.body)),
+			}
+
+			if tt.contentLength > 0 {
+				rsp.Header.Set("Content-Length", strconv.Itoa(tt.contentLength))
+			}
+
+			req := &http.Request{Header: http.Header{"Accept-Encoding": []string{"gzip"}}}
+			f.Response(&filtertest.Context{FRequest: req, FResponse: rsp})
+
+			enc := rsp.Header.Get("Content-Encoding")
+			if (enc == "gzip") != tt.compressed {
+				t.Fatalf("unexpected encoding: %q", enc)
+			}
+
+			var body io.Reader = rsp.Body
+			if tt.compressed {
+				body = decoder(enc, body)
+			}
+
+			b, err := io.ReadAll(body)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !bytes.Equal(b, tt.body) {
+				t.Error("unexpected body")
+			}
+		})
+	}
+}
@@ -61,6 +61,11 @@ func removeOriginMarkers(r []*routing.Route) {
 	}
 }
 
+// InspectedFilters implements routing.FilterInspector.
+func (*RouteCreationMetrics) InspectedFilters() []string {
+	return []string{filters.OriginMarkerName}
+}
+
 // Do implements routing.PostProcessor and records the filter creation time.
 func (m *RouteCreationMetrics) Do(routes []*routing.Route) []*routing.Route {
 	return m.reportRouteCreationTimes(routes)
@@ -0,0 +1,115 @@
+package builtin
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/zalando/skipper/filters"
+)
+
+const (
+	grpcTimeoutHeader = "Grpc-Timeout"
+
+	// the remaining time of the request in seconds, e.g. 1.5
+	requestTimeoutHeader = "X-Request-Timeout"
+)
+
+type deadlinePropagation struct{}
+
+// NewDeadlinePropagation returns a filter specification, whose filters
+// propagate the deadline of the requests between the clients and the
+// backends.
+//
+// On the request path, the filter reads the deadline of the client from
+// the grpc-timeout and the X-Request-Timeout headers, and the backend
+// request is cancelled when the deadline is reached, even when the
+// backend timeout of the route is longer. The proxy sets both headers
+// on the backend request to the remaining time of the request, the
+// grpc-timeout header only on the gRPC requests.
+//
+// Example:
+//
+//	r: * -> backendTimeout("10s") -> deadlinePropagation() -> "http://10.0.0.1:8080";
+func NewDeadlinePropagation() filters.Spec {
+	return deadlinePropagation{}
+}
+
+func (deadlinePropagation) Name() string { return filters.DeadlinePropagationName }
+
+func (deadlinePropagation) CreateFilter(args []interface{}) (filters.Filter, error) {
+	if len(args) != 0 {
+		return nil, filters.ErrInvalidFilterParameters
+	}
+
+	return deadlinePropagation{}, nil
+}
+
+// parses the value of the grpc-timeout header, e.g. 100m for 100
+// milliseconds
+func parseGRPCTimeout(v string) (time.Duration, bool) {
+	if len(v) < 2 || len(v) > 9 {
+		return 0, false
+	}
+
+	n, err := strconv.ParseUint(v[:len(v)-1], 10, 64)
+	if err != nil {
+		return 0, false
+	}
+
+	var unit time.Duration
+	switch v[len(v)-1] {
+	case 'H':
+		unit = time.Hour
+	case 'M':
+		unit = time.Minute
+	case 'S':
+		unit = time.Second
+	case 'm':
+		unit = time.Millisecond
+	case 'u':
+		unit = time.Microsecond
+	case 'n':
+		unit = time.Nanosecond
+	default:
+		return 0, false
+	}
+
+	return time.Duration(n) * unit, true
+}
+
+func parseRequestTimeout(v string) (time.Duration, bool) {
+	s, err := strconv.ParseFloat(v, 64)
+	if err != nil || s < 0 || s > float64(1<<33) {
+		return 0, false
+	}
+
+	return time.Duration(s * float64(time.Second)), true
+}
+
+// clientTimeout returns the shortest timeout set by the client
+func clientTimeout(ctx filters.FilterContext) (time.Duration, bool) {
+	var (
+		timeout time.Duration
+		found   bool
+	)
+
+	h := ctx.Request().Header
+	if d, ok := parseGRPCTimeout(h.Get(grpcTimeoutHeader)); ok {
+		timeout, found = d, true
+	}
+
+	if d, ok := parseRequestTimeout(h.Get(requestTimeoutHeader)); ok && (!found || d < timeout) {
+		timeout, found = d, true
+	}
+
+	return timeout, found
+}
+
+func (deadlinePropagation) Request(ctx filters.FilterContext) {
+	ctx.StateBag()[filters.DeadlinePropagationKey] = true
+	if d, ok := clientTimeout(ctx); ok {
+		ctx.StateBag()[filters.ClientDeadlineKey] = time.Now().Add(d)
+	}
+}
+
+func (deadlinePropagation) Response(filters.FilterContext) {}
@@ -0,0 +1,93 @@
+package builtin
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/zalando/skipper/filters"
+	"github.com/zalando/skipper/filters/filtertest"
+)
+
+func TestParseGRPCTimeout(t *testing.T) {
+	for _, tt := range []struct {
+		value    string
+		expected time.Duration
+		ok       bool
+	}{
+		{"", 0, false},
+		{"1", 0, false},
+		{"S", 0, false},
+		{"100m", 100 * time.Millisecond, true},
+		{"5S", 5 * time.Second, true},
+		{"2M", 2 * time.Minute, true},
+		{"1H", time.Hour, true},
+		{"300u", 300 * time.Microsecond, true},
+		{"99999999n", 99999999, true},
+		{"123456789n", 0, false},
+		{"10x", 0, false},
+		{"-1S", 0, false},
+	} {
+		if d, ok := parseGRPCTimeout(tt.value); ok != tt.ok || d != tt.expected {
+			t.Errorf("%q: unexpected result: %v, %v", tt.value, d, ok)
+		}
+	}
+}
+
+func TestDeadlinePropagation(t *testing.T) {
+	if _, err := NewDeadlinePropagation().CreateFilter([]interface{}{"1s"}); err == nil {
+		t.Error("failed to fail")
+	}
+
+	f, err := NewDeadlinePropagation().CreateFilter(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		title    string
+		header   http.Header
+		expected time.Duration
+	}{{
+		title: "no client deadline",
+	}, {
+		title:    "grpc-timeout",
+		header:   http.Header{"Grpc-Timeout": []string{"2S"}},
+		expected: 2 * time.Second,
+	}, {
+		title:    "request timeout",
+		header:   http.Header{"X-Request-Timeout": []string{"1.5"}},
+		expected: 1500 * time.Millisecond,
+	}, {
+		title:    "shortest timeout",
+		header:   http.Header{"Grpc-Timeout": []string{"500m"}, "X-Request-Timeout": []string{"3"}},
+		expected: 500 * time.Millisecond,
+	}, {
+		title:  "invalid timeouts",
+		header: http.Header{"Grpc-Timeout": []string{"soon"}, "X-Request-Timeout": []string{"-1"}},
+	}} {
+		t.Run(tt.title, func(t *testing.T) {
+			req := &http.Request{Header: tt.header}
+			if req.Header == nil {
+				req.Header = make(http.Header)
+			}
+
+			ctx := &filtertest.Context{FRequest: req, FStateBag: make(map[string]interface{})}
+			start := time.Now()
+			f.Request(ctx)
+
+			if ctx.FStateBag[filters.DeadlinePropagationKey] != true {
+				t.Error("failed to enable the propagation")
+			}
+
+			deadline, ok := ctx.FStateBag[filters.ClientDeadlineKey].(time.Time)
+			if ok != (tt.expected > 0) {
+				t.Fatalf("unexpected client deadline: %v", deadline)
+			}
+
+			if ok && (deadline.Before(start.Add(tt.expected)) || deadline.After(time.Now().Add(tt.expected))) {
+				t.Errorf("unexpected client deadline: %v", deadline.Sub(start))
+			}
+		})
+	}
+}
@@ -0,0 +1,51 @@
+package builtin
+
+import (
+	"github.com/zalando/skipper/filters"
+)
+
+type earlyHints struct {
+	links []string
+}
+
+// NewEarlyHints creates a filter specification, whose instances make the
+// proxy send a 103 Early Hints response with the Link header values set
+// in the arguments, before the backend request. The links of multiple
+// instances in the filter chain are combined.
+//
+// Example:
+//
+//	earlyHints("</style.css>; rel=preload; as=style", "</app.js>; rel=preload; as=script")
+func NewEarlyHints() filters.Spec {
+	return &earlyHints{}
+}
+
+func (*earlyHints) Name() string { return filters.EarlyHintsName }
+
+func (*earlyHints) CreateFilter(args []interface{}) (filters.Filter, error) {
+	if len(args) == 0 {
+		return nil, filters.ErrInvalidFilterParameters
+	}
+
+	f := &earlyHints{}
+	for _, a := range args {
+		s, ok := a.(string)
+		if !ok || s == "" {
+			return nil, filters.ErrInvalidFilterParameters
+		}
+
+		f.links = append(f.links, s)
+	}
+
+	return f, nil
+}
+
+func (f *earlyHints) Request(ctx filters.FilterContext) {
+	links, _ := ctx.StateBag()[filters.EarlyHintsKey].([]string)
+
+	// copied, because the state bag may contain the links of another
+	// instance
+	ctx.StateBag()[filters.EarlyHintsKey] = append(append([]string(nil), links...), f.links...)
+}
+
+func (*earlyHints) Response(filters.FilterContext) {}
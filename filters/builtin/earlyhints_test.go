@@ -0,0 +1,51 @@
+package builtin
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/zalando/skipper/filters"
+	"github.com/zalando/skipper/filters/filtertest"
+)
+
+func TestEarlyHints(t *testing.T) {
+	spec := NewEarlyHints()
+	if spec.Name() != filters.EarlyHintsName {
+		t.Error("wrong name")
+	}
+
+	for _, args := range [][]interface{}{
+		nil,
+		{""},
+		{"</style.css>; rel=preload", 42},
+	} {
+		if _, err := spec.CreateFilter(args); err == nil {
+			t.Errorf("failed to fail: %v", args)
+		}
+	}
+
+	f1, err := spec.CreateFilter([]interface{}{"</style.css>; rel=preload; as=style"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f2, err := spec.CreateFilter([]interface{}{"</app.js>; rel=preload; as=script", "</font.woff2>; rel=preload; as=font"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := &filtertest.Context{FRequest: &http.Request{}, FStateBag: make(map[string]interface{})}
+	f1.Request(c)
+	f2.Request(c)
+
+	expected := []string{
+		"</style.css>; rel=preload; as=style",
+		"</app.js>; rel=preload; as=script",
+		"</font.woff2>; rel=preload; as=font",
+	}
+
+	if links := c.FStateBag[filters.EarlyHintsKey]; !reflect.DeepEqual(links, expected) {
+		t.Errorf("expected %v, got %v", expected, links)
+	}
+}
@@ -45,3 +45,45 @@ func (s setFastCgiFilenameSpec) Response(_ filters.FilterContext) {}
 func (s setFastCgiFilenameSpec) Request(ctx filters.FilterContext) {
 	ctx.StateBag()["fastCgiFilename"] = s.fileName
 }
+
+type setFastCgiParamSpec struct {
+	name, value string
+}
+
+// NewSetFastCgiParam returns a filter spec that sets an additional FastCGI
+// param sent to the backend, e.g. PHP_VALUE to override PHP settings for
+// the route. Instances expect two parameters: the name and the value of
+// the param.
+func NewSetFastCgiParam() filters.Spec { return &setFastCgiParamSpec{} }
+
+func (s *setFastCgiParamSpec) Name() string { return filters.SetFastCgiParamName }
+
+func (s *setFastCgiParamSpec) CreateFilter(args []interface{}) (filters.Filter, error) {
+	if len(args) != 2 {
+		return nil, filters.ErrInvalidFilterParameters
+	}
+
+	name, ok := args[0].(string)
+	if !ok || name == "" {
+		return nil, filters.ErrInvalidFilterParameters
+	}
+
+	value, ok := args[1].(string)
+	if !ok {
+		return nil, filters.ErrInvalidFilterParameters
+	}
+
+	return setFastCgiParamSpec{name: name, value: value}, nil
+}
+
+func (s setFastCgiParamSpec) Response(_ filters.FilterContext) {}
+
+func (s setFastCgiParamSpec) Request(ctx filters.FilterContext) {
+	params, ok := ctx.StateBag()[filters.FastCgiParams].(map[string]string)
+	if !ok {
+		params = make(map[string]string)
+		ctx.StateBag()[filters.FastCgiParams] = params
+	}
+
+	params[s.name] = s.value
+}
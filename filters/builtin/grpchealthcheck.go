@@ -0,0 +1,42 @@
+package builtin
+
+import "github.com/zalando/skipper/filters"
+
+type grpcHealthCheck struct{}
+
+// NewGRPCHealthCheck returns a filter specification that marks the
+// backend of the route as a gRPC backend for the active health checker
+// of the load balancer. The backend is checked with the standard
+// grpc.health.v1.Health/Check call instead of an HTTP GET request. The
+// optional argument is the name of the service that is checked, by
+// default the overall health of the server is checked.
+//
+// The filter doesn't change the requests, it only configures the health
+// checks.
+//
+// Example:
+//
+//	r: * -> grpcHealthCheck("helloworld.Greeter") -> "http://10.0.0.1:50051";
+func NewGRPCHealthCheck() filters.Spec {
+	return grpcHealthCheck{}
+}
+
+func (grpcHealthCheck) Name() string { return filters.GRPCHealthCheckName }
+
+func (grpcHealthCheck) CreateFilter(args []interface{}) (filters.Filter, error) {
+	switch len(args) {
+	case 0:
+	case 1:
+		if _, ok := args[0].(string); !ok {
+			return nil, filters.ErrInvalidFilterParameters
+		}
+	default:
+		return nil, filters.ErrInvalidFilterParameters
+	}
+
+	return grpcHealthCheck{}, nil
+}
+
+func (grpcHealthCheck) Request(filters.FilterContext) {}
+
+func (grpcHealthCheck) Response(filters.FilterContext) {}
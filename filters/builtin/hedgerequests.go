@@ -0,0 +1,51 @@
+package builtin
+
+import (
+	"time"
+
+	"github.com/zalando/skipper/filters"
+)
+
+type hedgeRequests struct {
+	delay time.Duration
+}
+
+// NewHedgeRequests returns a filter specification that is used to
+// reduce the tail latency of load balanced routes. When the backend
+// doesn't respond successfully within the delay, the proxy sends a
+// copy of the request to another endpoint, returns the first
+// successful response, and cancels the other request. Only the
+// requests with an idempotent method and without a body are hedged.
+//
+// Example:
+//
+//	r: Method("GET") -> hedgeRequests("50ms") -> <roundRobin, "http://app1", "http://app2">;
+func NewHedgeRequests() filters.Spec {
+	return &hedgeRequests{}
+}
+
+func (*hedgeRequests) Name() string { return filters.HedgeRequestsName }
+
+func (*hedgeRequests) CreateFilter(args []interface{}) (filters.Filter, error) {
+	if len(args) != 1 {
+		return nil, filters.ErrInvalidFilterParameters
+	}
+
+	s, ok := args[0].(string)
+	if !ok {
+		return nil, filters.ErrInvalidFilterParameters
+	}
+
+	d, err := time.ParseDuration(s)
+	if err != nil || d <= 0 {
+		return nil, filters.ErrInvalidFilterParameters
+	}
+
+	return &hedgeRequests{delay: d}, nil
+}
+
+func (h *hedgeRequests) Request(ctx filters.FilterContext) {
+	ctx.StateBag()[filters.HedgeDelayKey] = h.delay
+}
+
+func (*hedgeRequests) Response(filters.FilterContext) {}
@@ -0,0 +1,35 @@
+package builtin
+
+import (
+	"testing"
+	"time"
+
+	"github.com/zalando/skipper/filters"
+	"github.com/zalando/skipper/filters/filtertest"
+)
+
+func TestHedgeRequests(t *testing.T) {
+	for _, args := range [][]interface{}{
+		nil,
+		{"50ms", "100ms"},
+		{50.0},
+		{"fast"},
+		{"0s"},
+		{"-10ms"},
+	} {
+		if _, err := NewHedgeRequests().CreateFilter(args); err == nil {
+			t.Errorf("%v: failed to fail", args)
+		}
+	}
+
+	f, err := NewHedgeRequests().CreateFilter([]interface{}{"50ms"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := &filtertest.Context{FStateBag: make(map[string]interface{})}
+	f.Request(ctx)
+	if ctx.FStateBag[filters.HedgeDelayKey] != 50*time.Millisecond {
+		t.Errorf("unexpected delay: %v", ctx.FStateBag[filters.HedgeDelayKey])
+	}
+}
@@ -0,0 +1,91 @@
+package builtin
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/zalando/skipper/filters"
+)
+
+const defaultLegacyBackendMaxBodySize = 1 << 20
+
+type legacyBackendSpec struct{}
+
+type legacyBackendFilter struct {
+	maxBodySize int64
+}
+
+// NewLegacyBackend returns a filter specification that is used to send the
+// requests in a way that the old, e.g. HTTP/1.0, backends can handle:
+//
+//   - the request bodies of unknown length are read, up to the maximum
+//     body size, and sent with a Content-Length header instead of the
+//     chunked transfer encoding. Longer bodies are rejected with 413.
+//   - the Expect: 100-continue header is not forwarded.
+//   - the backend connection is closed after the request.
+//
+// The optional argument sets the maximum body size in bytes, it defaults
+// to 1MiB.
+//
+// Example:
+//
+//	r: * -> legacyBackend() -> "http://legacy.example.org";
+func NewLegacyBackend() filters.Spec {
+	return &legacyBackendSpec{}
+}
+
+func (*legacyBackendSpec) Name() string {
+	return filters.LegacyBackendName
+}
+
+func (*legacyBackendSpec) CreateFilter(args []interface{}) (filters.Filter, error) {
+	f := &legacyBackendFilter{maxBodySize: defaultLegacyBackendMaxBodySize}
+	switch len(args) {
+	case 0:
+	case 1:
+		size, ok := args[0].(float64)
+		if !ok || size < 1 {
+			return nil, filters.ErrInvalidFilterParameters
+		}
+
+		f.maxBodySize = int64(size)
+	default:
+		return nil, filters.ErrInvalidFilterParameters
+	}
+
+	return f, nil
+}
+
+func (f *legacyBackendFilter) Request(ctx filters.FilterContext) {
+	req := ctx.Request()
+	req.Header.Del("Expect")
+	ctx.StateBag()[filters.BackendCloseConnectionKey] = struct{}{}
+
+	if req.ContentLength >= 0 || req.Body == nil || req.Body == http.NoBody {
+		return
+	}
+
+	b, err := io.ReadAll(io.LimitReader(req.Body, f.maxBodySize+1))
+	if err != nil {
+		log.Errorf("Error reading the request body for a legacy backend: %v", err)
+		ctx.Serve(&http.Response{StatusCode: http.StatusBadRequest})
+		return
+	}
+
+	if int64(len(b)) > f.maxBodySize {
+		ctx.Serve(&http.Response{StatusCode: http.StatusRequestEntityTooLarge})
+		return
+	}
+
+	req.Body = &struct {
+		io.Reader
+		io.Closer
+	}{bytes.NewReader(b), req.Body}
+
+	req.ContentLength = int64(len(b))
+	req.TransferEncoding = nil
+}
+
+func (*legacyBackendFilter) Response(filters.FilterContext) {}
@@ -0,0 +1,79 @@
+package builtin
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/zalando/skipper/filters"
+	"github.com/zalando/skipper/filters/filtertest"
+)
+
+func TestLegacyBackendArgs(t *testing.T) {
+	for _, args := range [][]interface{}{
+		{"1MB"},
+		{0.0},
+		{1024.0, 2048.0},
+	} {
+		if _, err := NewLegacyBackend().CreateFilter(args); err == nil {
+			t.Errorf("%v: failed to fail", args)
+		}
+	}
+}
+
+func TestLegacyBackend(t *testing.T) {
+	for _, tt := range []struct {
+		name          string
+		body          string
+		contentLength int64
+		status        int
+	}{
+		{name: "no body", contentLength: 0},
+		{name: "known length", body: "foo", contentLength: 3},
+		{name: "unknown length", body: "foobar", contentLength: -1},
+		{name: "too large", body: strings.Repeat("x", 9), contentLength: -1, status: http.StatusRequestEntityTooLarge},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := NewLegacyBackend().CreateFilter([]interface{}{8.0})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			req := httptest.NewRequest("POST", "/", strings.NewReader(tt.body))
+			req.ContentLength = tt.contentLength
+			req.Header.Set("Expect", "100-continue")
+			if tt.contentLength < 0 {
+				req.TransferEncoding = []string{"chunked"}
+			}
+
+			ctx := &filtertest.Context{FRequest: req, FStateBag: make(map[string]interface{})}
+			f.Request(ctx)
+
+			if tt.status != 0 {
+				if !ctx.FServed || ctx.FResponse.StatusCode != tt.status {
+					t.Errorf("failed to respond with %d", tt.status)
+				}
+
+				return
+			}
+
+			if req.Header.Get("Expect") != "" {
+				t.Error("failed to drop the Expect header")
+			}
+
+			if _, ok := ctx.FStateBag[filters.BackendCloseConnectionKey]; !ok {
+				t.Error("failed to close the backend connection")
+			}
+
+			if req.ContentLength != int64(len(tt.body)) || len(req.TransferEncoding) != 0 {
+				t.Errorf("unexpected content length: %d, transfer encoding: %v", req.ContentLength, req.TransferEncoding)
+			}
+
+			if b, err := io.ReadAll(req.Body); err != nil || string(b) != tt.body {
+				t.Errorf("failed to preserve the body: %s, %v", b, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,36 @@
+package builtin
+
+import (
+	"github.com/zalando/skipper/filters"
+	snet "github.com/zalando/skipper/net"
+)
+
+type preserveOriginalPath struct{}
+
+// NewPreserveOriginalPath returns a filter Spec, whose instances opt out a
+// route from the request target canonicalization, and forward the request
+// path to the backend as it was received. The route matching is always done
+// on the canonical path.
+//
+// Name: "preserveOriginalPath".
+func NewPreserveOriginalPath() filters.Spec { return preserveOriginalPath{} }
+
+func (preserveOriginalPath) Name() string { return filters.PreserveOriginalPathName }
+
+func (preserveOriginalPath) CreateFilter(args []interface{}) (filters.Filter, error) {
+	if len(args) != 0 {
+		return nil, filters.ErrInvalidFilterParameters
+	}
+
+	return preserveOriginalPath{}, nil
+}
+
+func (preserveOriginalPath) Request(ctx filters.FilterContext) {
+	r := ctx.Request()
+	if u, ok := snet.OriginalURL(r); ok {
+		r.URL.Path = u.Path
+		r.URL.RawPath = u.RawPath
+	}
+}
+
+func (preserveOriginalPath) Response(filters.FilterContext) {}
@@ -0,0 +1,45 @@
+package builtin
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/zalando/skipper/filters/filtertest"
+	snet "github.com/zalando/skipper/net"
+)
+
+func TestPreserveOriginalPath(t *testing.T) {
+	if _, err := NewPreserveOriginalPath().CreateFilter([]interface{}{"foo"}); err == nil {
+		t.Error("failed to fail on invalid arguments")
+	}
+
+	f, err := NewPreserveOriginalPath().CreateFilter(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h := &snet.CanonicalizationHandler{
+		Canonicalization: snet.Canonicalization{MergeSlashes: true, NormalizePercentEncoding: true},
+		Handler: http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+			if r.URL.EscapedPath() != "/foo/bar%2F" {
+				t.Errorf("request not canonicalized: %s", r.URL.EscapedPath())
+			}
+
+			ctx := &filtertest.Context{FRequest: r}
+			f.Request(ctx)
+			if r.URL.EscapedPath() != "/foo//bar%2f" {
+				t.Errorf("failed to restore the original path: %s", r.URL.EscapedPath())
+			}
+		}),
+	}
+
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/foo//bar%2f", nil))
+
+	// not canonicalized request is left unchanged
+	r := httptest.NewRequest("GET", "/foo/bar", nil)
+	f.Request(&filtertest.Context{FRequest: r})
+	if r.URL.Path != "/foo/bar" {
+		t.Errorf("unexpected path: %s", r.URL.Path)
+	}
+}
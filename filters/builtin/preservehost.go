@@ -17,6 +17,7 @@ package builtin
 import (
 	log "github.com/sirupsen/logrus"
 	"github.com/zalando/skipper/filters"
+	"github.com/zalando/skipper/routing"
 	"net/url"
 )
 
@@ -59,9 +60,15 @@ func (preserve filter) Request(ctx filters.FilterContext) {
 		return
 	}
 
-	if preserve && ctx.OutgoingHost() == u.Host {
+	host, err := routing.EndpointHost(u)
+	if err != nil {
+		log.Error("failed to parse backend host in preserveHost filter", err)
+		return
+	}
+
+	if preserve && ctx.OutgoingHost() == host {
 		ctx.SetOutgoingHost(ctx.Request().Host)
 	} else if !preserve && ctx.OutgoingHost() == ctx.Request().Host {
-		ctx.SetOutgoingHost(u.Host)
+		ctx.SetOutgoingHost(host)
 	}
 }
@@ -0,0 +1,54 @@
+package builtin
+
+import (
+	"github.com/zalando/skipper/filters"
+)
+
+const maxPrewarmConnections = 100
+
+type prewarmConnectionsSpec struct{}
+
+type prewarmConnectionsFilter struct {
+	count int
+}
+
+// NewPrewarmConnections returns a filter specification that is used to
+// set the number of the connections, that the proxy establishes in
+// advance to each endpoint of the route, right after the route is
+// loaded, and when its endpoints change. The filter has no effect on
+// the requests, and it is ignored unless the connection prewarming is
+// enabled, see proxy.Prewarmer.
+//
+// Example:
+//
+//	r: * -> prewarmConnections(8) -> <roundRobin, "https://app1.example.org", "https://app2.example.org">;
+func NewPrewarmConnections() filters.Spec {
+	return &prewarmConnectionsSpec{}
+}
+
+func (*prewarmConnectionsSpec) Name() string {
+	return filters.PrewarmConnectionsName
+}
+
+func (*prewarmConnectionsSpec) CreateFilter(args []interface{}) (filters.Filter, error) {
+	if len(args) != 1 {
+		return nil, filters.ErrInvalidFilterParameters
+	}
+
+	n, ok := args[0].(float64)
+	if !ok || n != float64(int(n)) || n < 1 || n > maxPrewarmConnections {
+		return nil, filters.ErrInvalidFilterParameters
+	}
+
+	return &prewarmConnectionsFilter{count: int(n)}, nil
+}
+
+// PrewarmConnections returns the number of the connections to be
+// established to each endpoint of the route.
+func (f *prewarmConnectionsFilter) PrewarmConnections() int {
+	return f.count
+}
+
+func (*prewarmConnectionsFilter) Request(filters.FilterContext) {}
+
+func (*prewarmConnectionsFilter) Response(filters.FilterContext) {}
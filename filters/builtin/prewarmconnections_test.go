@@ -0,0 +1,27 @@
+package builtin
+
+import "testing"
+
+func TestPrewarmConnections(t *testing.T) {
+	for _, args := range [][]interface{}{
+		nil,
+		{"3"},
+		{0.0},
+		{2.5},
+		{101.0},
+		{3.0, 4.0},
+	} {
+		if _, err := NewPrewarmConnections().CreateFilter(args); err == nil {
+			t.Errorf("%v: failed to fail", args)
+		}
+	}
+
+	f, err := NewPrewarmConnections().CreateFilter([]interface{}{3.0})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if n := f.(interface{ PrewarmConnections() int }).PrewarmConnections(); n != 3 {
+		t.Errorf("unexpected number of connections: %d", n)
+	}
+}
@@ -0,0 +1,143 @@
+package builtin
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/zalando/skipper/filters"
+	"golang.org/x/net/http/httpguts"
+)
+
+type retryOnResponseSpec struct{}
+
+type retryOnResponseFilter struct {
+	statusCodes []int
+	headers     []string
+	backend     string
+	allowed     map[string]bool
+}
+
+// NewRetryOnResponse returns a filter specification that is used to
+// retry the backend request once, when the response has one of the
+// given status codes, or carries one of the given headers. The
+// arguments are the status codes as numbers, the header names as
+// strings, and optionally backend URLs, where the first one is the
+// secondary backend. The request is retried:
+//
+//   - against the URL found in the matching header, when its value is
+//     an absolute http or https URL, or else
+//   - against the secondary backend, when set, or else
+//   - against the route backend, which in case of load balanced
+//     backends means the next selected endpoint.
+//
+// The URL found in the header is accepted only when it is one of the
+// backend URLs of the arguments, or one of the backends of the route.
+// Otherwise the header is ignored.
+//
+// Only the requests without a body are retried.
+//
+// Example:
+//
+//	r: * -> retryOnResponse(421, "X-Shard-Moved") -> <roundRobin, "http://shard1", "http://shard2">;
+//	r: * -> retryOnResponse(503, "https://secondary.example.org") -> "https://primary.example.org";
+func NewRetryOnResponse() filters.Spec {
+	return &retryOnResponseSpec{}
+}
+
+func (*retryOnResponseSpec) Name() string {
+	return filters.RetryOnResponseName
+}
+
+func (*retryOnResponseSpec) CreateFilter(args []interface{}) (filters.Filter, error) {
+	f := &retryOnResponseFilter{allowed: make(map[string]bool)}
+	for _, a := range args {
+		switch v := a.(type) {
+		case float64:
+			if v != float64(int(v)) || v < 100 || v > 599 {
+				return nil, filters.ErrInvalidFilterParameters
+			}
+
+			f.statusCodes = append(f.statusCodes, int(v))
+		case string:
+			if strings.Contains(v, "://") {
+				b, ok := headerBackend(v)
+				if !ok {
+					return nil, filters.ErrInvalidFilterParameters
+				}
+
+				if f.backend == "" {
+					f.backend = b
+				}
+
+				f.allowed[b] = true
+				continue
+			}
+
+			if !httpguts.ValidHeaderFieldName(v) {
+				return nil, filters.ErrInvalidFilterParameters
+			}
+
+			f.headers = append(f.headers, http.CanonicalHeaderKey(v))
+		default:
+			return nil, filters.ErrInvalidFilterParameters
+		}
+	}
+
+	if len(f.statusCodes) == 0 && len(f.headers) == 0 {
+		return nil, filters.ErrInvalidFilterParameters
+	}
+
+	return f, nil
+}
+
+func headerBackend(v string) (string, bool) {
+	u, err := url.Parse(strings.TrimSpace(v))
+	if err != nil || u.Host == "" || u.Scheme != "http" && u.Scheme != "https" {
+		return "", false
+	}
+
+	return u.Scheme + "://" + u.Host, true
+}
+
+// the backends received from the response headers are accepted only when
+// they are listed in the filter arguments, or they are backends of the
+// route, so that the backends can't redirect the requests, with the
+// credentials of the client, to arbitrary hosts
+func (f *retryOnResponseFilter) retry(rsp *http.Response, routeBackend func(string) bool) (string, bool) {
+	matched := false
+	for _, name := range f.headers {
+		v, ok := rsp.Header[name]
+		if !ok {
+			continue
+		}
+
+		b, ok := headerBackend(v[0])
+		if !ok {
+			matched = true
+			continue
+		}
+
+		if f.allowed[b] || routeBackend(b) {
+			return b, true
+		}
+	}
+
+	for _, code := range f.statusCodes {
+		if rsp.StatusCode == code {
+			matched = true
+		}
+	}
+
+	if !matched {
+		return "", false
+	}
+
+	return f.backend, true
+}
+
+func (f *retryOnResponseFilter) Request(ctx filters.FilterContext) {
+	ctx.StateBag()[filters.RetryOnResponseKey] = f.retry
+}
+
+func (*retryOnResponseFilter) Response(filters.FilterContext) {}
@@ -0,0 +1,57 @@
+package builtin
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestRetryOnResponseCreateFilter(t *testing.T) {
+	for _, args := range [][]interface{}{
+		nil,
+		{"https://secondary.example.org"},
+		{99.0},
+		{600.0},
+		{503.5},
+		{"invalid header"},
+		{503.0, "ftp://secondary.example.org"},
+		{true},
+	} {
+		if _, err := NewRetryOnResponse().CreateFilter(args); err == nil {
+			t.Errorf("%v: failed to fail", args)
+		}
+	}
+}
+
+func TestRetryOnResponseDecision(t *testing.T) {
+	f, err := NewRetryOnResponse().CreateFilter([]interface{}{503.0, "x-shard-moved", "https://secondary.example.org/foo", "https://shard3.example.org"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	routeBackend := func(b string) bool { return b == "http://shard2.example.org:9090" }
+	retry := f.(*retryOnResponseFilter).retry
+	for _, tt := range []struct {
+		name     string
+		status   int
+		header   http.Header
+		retry    bool
+		expected string
+	}{
+		{name: "no match", status: http.StatusOK},
+		{name: "status", status: http.StatusServiceUnavailable, retry: true, expected: "https://secondary.example.org"},
+		{name: "header", status: http.StatusOK, header: http.Header{"X-Shard-Moved": {"1"}}, retry: true, expected: "https://secondary.example.org"},
+		{name: "header url", status: http.StatusOK, header: http.Header{"X-Shard-Moved": {"http://shard2.example.org:9090/bar"}}, retry: true, expected: "http://shard2.example.org:9090"},
+		{name: "header allowed url", status: http.StatusOK, header: http.Header{"X-Shard-Moved": {"https://shard3.example.org/bar"}}, retry: true, expected: "https://shard3.example.org"},
+		{name: "header secondary url", status: http.StatusOK, header: http.Header{"X-Shard-Moved": {"https://secondary.example.org"}}, retry: true, expected: "https://secondary.example.org"},
+		{name: "header rejected url", status: http.StatusOK, header: http.Header{"X-Shard-Moved": {"https://attacker.example.org"}}},
+		{name: "header rejected url with status", status: http.StatusServiceUnavailable, header: http.Header{"X-Shard-Moved": {"https://attacker.example.org"}}, retry: true, expected: "https://secondary.example.org"},
+		{name: "header invalid url", status: http.StatusOK, header: http.Header{"X-Shard-Moved": {"file:///etc/passwd"}}, retry: true, expected: "https://secondary.example.org"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			backend, ok := retry(&http.Response{StatusCode: tt.status, Header: tt.header}, routeBackend)
+			if ok != tt.retry || backend != tt.expected {
+				t.Errorf("unexpected result: %q, %t, expected: %q, %t", backend, ok, tt.expected, tt.retry)
+			}
+		})
+	}
+}
@@ -0,0 +1,147 @@
+package builtin
+
+import (
+	"net/http"
+	"regexp"
+
+	"github.com/zalando/skipper/filters"
+)
+
+type scrubHeadersType int
+
+const (
+	keepRequestHeaders scrubHeadersType = iota
+	keepResponseHeaders
+	dropRequestHeadersRegexp
+	dropResponseHeadersRegexp
+)
+
+// common structure for the specifications and the filters removing
+// multiple headers at once
+type scrubHeaders struct {
+	typ  scrubHeadersType
+	keep map[string]struct{}
+	rxs  []*regexp.Regexp
+}
+
+// NewKeepRequestHeaders returns a filter specification whose instances
+// remove all the request headers except the ones listed in their
+// arguments, e.g. to avoid leaking the client headers to third-party
+// backends. Instances expect one or more header names.
+// Name: "keepRequestHeaders".
+func NewKeepRequestHeaders() filters.Spec {
+	return &scrubHeaders{typ: keepRequestHeaders}
+}
+
+// NewKeepResponseHeaders returns a filter specification whose instances
+// remove all the response headers except the ones listed in their
+// arguments, e.g. to avoid leaking internal headers to the clients.
+// Instances expect one or more header names.
+// Name: "keepResponseHeaders".
+func NewKeepResponseHeaders() filters.Spec {
+	return &scrubHeaders{typ: keepResponseHeaders}
+}
+
+// NewDropRequestHeadersRegexp returns a filter specification whose
+// instances remove the request headers whose name matches any of the
+// regular expressions in their arguments. The header names are matched
+// in their canonical form, e.g. X-Internal-Id.
+// Name: "dropRequestHeadersRegexp".
+func NewDropRequestHeadersRegexp() filters.Spec {
+	return &scrubHeaders{typ: dropRequestHeadersRegexp}
+}
+
+// NewDropResponseHeadersRegexp returns a filter specification whose
+// instances remove the response headers whose name matches any of the
+// regular expressions in their arguments. The header names are matched
+// in their canonical form, e.g. X-Internal-Id.
+// Name: "dropResponseHeadersRegexp".
+func NewDropResponseHeadersRegexp() filters.Spec {
+	return &scrubHeaders{typ: dropResponseHeadersRegexp}
+}
+
+func (spec *scrubHeaders) Name() string {
+	switch spec.typ {
+	case keepRequestHeaders:
+		return filters.KeepRequestHeadersName
+	case keepResponseHeaders:
+		return filters.KeepResponseHeadersName
+	case dropRequestHeadersRegexp:
+		return filters.DropRequestHeadersRegexpName
+	case dropResponseHeadersRegexp:
+		return filters.DropResponseHeadersRegexpName
+	default:
+		panic("invalid scrub headers type")
+	}
+}
+
+//lint:ignore ST1016 "spec" makes sense here and we reuse the type for the filter
+func (spec *scrubHeaders) CreateFilter(config []interface{}) (filters.Filter, error) {
+	if len(config) == 0 {
+		return nil, filters.ErrInvalidFilterParameters
+	}
+
+	f := &scrubHeaders{typ: spec.typ}
+	switch spec.typ {
+	case keepRequestHeaders, keepResponseHeaders:
+		f.keep = make(map[string]struct{}, len(config))
+	}
+
+	for _, c := range config {
+		s, ok := c.(string)
+		if !ok || s == "" {
+			return nil, filters.ErrInvalidFilterParameters
+		}
+
+		if f.keep != nil {
+			f.keep[http.CanonicalHeaderKey(s)] = struct{}{}
+			continue
+		}
+
+		rx, err := regexp.Compile(s)
+		if err != nil {
+			return nil, err
+		}
+
+		f.rxs = append(f.rxs, rx)
+	}
+
+	return f, nil
+}
+
+func (f *scrubHeaders) drop(name string) bool {
+	if f.keep != nil {
+		_, ok := f.keep[name]
+		return !ok
+	}
+
+	for _, rx := range f.rxs {
+		if rx.MatchString(name) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (f *scrubHeaders) scrub(h http.Header) {
+	for name := range h {
+		if f.drop(name) {
+			delete(h, name)
+		}
+	}
+}
+
+func (f *scrubHeaders) Request(ctx filters.FilterContext) {
+	switch f.typ {
+	case keepRequestHeaders, dropRequestHeadersRegexp:
+		f.scrub(ctx.Request().Header)
+	}
+}
+
+func (f *scrubHeaders) Response(ctx filters.FilterContext) {
+	switch f.typ {
+	case keepResponseHeaders, dropResponseHeadersRegexp:
+		f.scrub(ctx.Response().Header)
+	}
+}
@@ -0,0 +1,107 @@
+package builtin
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/zalando/skipper/filters"
+	"github.com/zalando/skipper/filters/filtertest"
+)
+
+func TestScrubHeadersArgs(t *testing.T) {
+	for _, spec := range []filters.Spec{
+		NewKeepRequestHeaders(),
+		NewKeepResponseHeaders(),
+		NewDropRequestHeadersRegexp(),
+		NewDropResponseHeadersRegexp(),
+	} {
+		for _, args := range [][]interface{}{
+			nil,
+			{""},
+			{"X-Foo", 42},
+		} {
+			if _, err := spec.CreateFilter(args); err == nil {
+				t.Errorf("%s: failed to fail for %v", spec.Name(), args)
+			}
+		}
+	}
+
+	if _, err := NewDropRequestHeadersRegexp().CreateFilter([]interface{}{"["}); err == nil {
+		t.Error("failed to fail for an invalid regexp")
+	}
+}
+
+func TestScrubHeaders(t *testing.T) {
+	header := func() http.Header {
+		return http.Header{
+			"Accept":        []string{"application/json"},
+			"Authorization": []string{"Bearer foo"},
+			"X-Internal-Id": []string{"42"},
+			"X-Debug-Trace": []string{"on"},
+		}
+	}
+
+	for _, tt := range []struct {
+		msg      string
+		spec     filters.Spec
+		args     []interface{}
+		expected []string
+	}{{
+		"keep request headers",
+		NewKeepRequestHeaders(),
+		[]interface{}{"accept", "x-internal-id", "X-Missing"},
+		[]string{"Accept", "X-Internal-Id"},
+	}, {
+		"keep response headers",
+		NewKeepResponseHeaders(),
+		[]interface{}{"Authorization"},
+		[]string{"Authorization"},
+	}, {
+		"drop request headers by regexp",
+		NewDropRequestHeadersRegexp(),
+		[]interface{}{"^X-Internal-", "^X-Debug-"},
+		[]string{"Accept", "Authorization"},
+	}, {
+		"drop response headers by regexp",
+		NewDropResponseHeadersRegexp(),
+		[]interface{}{"^X-"},
+		[]string{"Accept", "Authorization"},
+	}} {
+		t.Run(tt.msg, func(t *testing.T) {
+			f, err := tt.spec.CreateFilter(tt.args)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			ctx := &filtertest.Context{
+				FRequest:  &http.Request{Header: header()},
+				FResponse: &http.Response{Header: header()},
+			}
+
+			f.Request(ctx)
+			f.Response(ctx)
+
+			scrubbed, untouched := ctx.FRequest.Header, ctx.FResponse.Header
+			switch tt.spec.Name() {
+			case filters.KeepResponseHeadersName, filters.DropResponseHeadersRegexpName:
+				scrubbed, untouched = untouched, scrubbed
+			}
+
+			var names []string
+			for _, name := range []string{"Accept", "Authorization", "X-Internal-Id", "X-Debug-Trace"} {
+				if _, ok := scrubbed[name]; ok {
+					names = append(names, name)
+				}
+			}
+
+			if !reflect.DeepEqual(names, tt.expected) {
+				t.Errorf("unexpected headers: %v, expected: %v", names, tt.expected)
+			}
+
+			if len(untouched) != len(header()) {
+				t.Errorf("headers of the other direction changed: %v", untouched)
+			}
+		})
+	}
+}
@@ -0,0 +1,36 @@
+package builtin
+
+import (
+	"github.com/zalando/skipper/filters"
+)
+
+type streaming struct{}
+
+// NewStreaming creates a filter specification, whose instances mark the
+// route as serving long-lived responses, e.g. Server-Sent Events or
+// long-polling. For these routes, the proxy applies the streaming
+// timeouts instead of the response header timeout and the backend
+// timeout, and flushes the response body after every write.
+//
+// Example:
+//
+//	streaming()
+func NewStreaming() filters.Spec {
+	return streaming{}
+}
+
+func (streaming) Name() string { return filters.StreamingName }
+
+func (streaming) CreateFilter(args []interface{}) (filters.Filter, error) {
+	if len(args) != 0 {
+		return nil, filters.ErrInvalidFilterParameters
+	}
+
+	return streaming{}, nil
+}
+
+func (streaming) Request(ctx filters.FilterContext) {
+	ctx.StateBag()[filters.StreamingKey] = true
+}
+
+func (streaming) Response(filters.FilterContext) {}
@@ -0,0 +1,30 @@
+package builtin
+
+import (
+	"testing"
+
+	"github.com/zalando/skipper/filters"
+	"github.com/zalando/skipper/filters/filtertest"
+)
+
+func TestStreamingFilter(t *testing.T) {
+	spec := NewStreaming()
+	if spec.Name() != filters.StreamingName {
+		t.Error("wrong name")
+	}
+
+	if _, err := spec.CreateFilter([]interface{}{"sse"}); err == nil {
+		t.Error("failed to fail")
+	}
+
+	f, err := spec.CreateFilter(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := &filtertest.Context{FStateBag: make(map[string]interface{})}
+	f.Request(c)
+	if s, _ := c.FStateBag[filters.StreamingKey].(bool); !s {
+		t.Error("failed to mark the route as streaming")
+	}
+}
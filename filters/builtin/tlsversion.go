@@ -0,0 +1,58 @@
+package builtin
+
+import (
+	"crypto/tls"
+	"net/http"
+
+	"github.com/zalando/skipper/filters"
+)
+
+type minTLSVersion struct {
+	version uint16
+}
+
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// NewMinTLSVersion creates a filter specification, whose instances reject
+// the requests received over a TLS connection with a lower version than
+// the argument with 403 Forbidden. The requests without TLS, e.g. behind
+// a load balancer terminating TLS, are not checked.
+//
+// Example:
+//
+//	minTLSVersion("1.2")
+func NewMinTLSVersion() filters.Spec {
+	return &minTLSVersion{}
+}
+
+func (*minTLSVersion) Name() string { return filters.MinTLSVersionName }
+
+func (*minTLSVersion) CreateFilter(args []interface{}) (filters.Filter, error) {
+	if len(args) != 1 {
+		return nil, filters.ErrInvalidFilterParameters
+	}
+
+	s, _ := args[0].(string)
+	v, ok := tlsVersions[s]
+	if !ok {
+		return nil, filters.ErrInvalidFilterParameters
+	}
+
+	return &minTLSVersion{version: v}, nil
+}
+
+func (f *minTLSVersion) Request(ctx filters.FilterContext) {
+	r := ctx.Request()
+	if r.TLS == nil || r.TLS.Version >= f.version {
+		return
+	}
+
+	ctx.Serve(&http.Response{StatusCode: http.StatusForbidden, Header: make(http.Header)})
+}
+
+func (*minTLSVersion) Response(filters.FilterContext) {}
@@ -0,0 +1,51 @@
+package builtin
+
+import (
+	"crypto/tls"
+	"net/http"
+	"testing"
+
+	"github.com/zalando/skipper/filters"
+	"github.com/zalando/skipper/filters/filtertest"
+)
+
+func TestMinTLSVersion(t *testing.T) {
+	spec := NewMinTLSVersion()
+	if spec.Name() != filters.MinTLSVersionName {
+		t.Error("wrong name")
+	}
+
+	for _, args := range [][]interface{}{nil, {"1.4"}, {1.2}, {"1.2", "1.3"}} {
+		if _, err := spec.CreateFilter(args); err == nil {
+			t.Errorf("failed to fail: %v", args)
+		}
+	}
+
+	f, err := spec.CreateFilter([]interface{}{"1.2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		name   string
+		tls    *tls.ConnectionState
+		reject bool
+	}{
+		{name: "no tls"},
+		{name: "tls 1.1", tls: &tls.ConnectionState{Version: tls.VersionTLS11}, reject: true},
+		{name: "tls 1.2", tls: &tls.ConnectionState{Version: tls.VersionTLS12}},
+		{name: "tls 1.3", tls: &tls.ConnectionState{Version: tls.VersionTLS13}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := &filtertest.Context{FRequest: &http.Request{TLS: tc.tls}}
+			f.Request(ctx)
+			if ctx.FServed != tc.reject {
+				t.Fatalf("expected rejected: %v, got: %v", tc.reject, ctx.FServed)
+			}
+
+			if tc.reject && ctx.FResponse.StatusCode != http.StatusForbidden {
+				t.Errorf("expected 403, got %d", ctx.FResponse.StatusCode)
+			}
+		})
+	}
+}
@@ -0,0 +1,499 @@
+/*
+Package cache provides the cache filter, that stores the responses of the
+GET requests, and serves the identical requests from the cache while the
+responses are fresh, following the Cache-Control, Expires, ETag and
+Last-Modified headers of the backend responses.
+
+The responses are stored in a store shared by the routes, by default in
+memory. The proxy can be configured to use the Redis ring instead, shared
+by the skipper instances.
+
+Example:
+
+	r: * -> cache("60s") -> "https://origin.example.org";
+
+The optional argument sets the time while the responses without explicit
+freshness information are fresh. Without it, only the responses with the
+max-age or s-maxage Cache-Control directives, or with the Expires header,
+are stored.
+
+When a stored response is stale, but still within the time set by its
+stale-while-revalidate Cache-Control directive, it is served, and the
+response is revalidated in the background. Once stale, the stored
+responses with an ETag or Last-Modified header are revalidated with a
+conditional request to the backend.
+*/
+package cache
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/zalando/skipper/filters"
+)
+
+const (
+	defaultMaxBodySize  = 1 << 20
+	defaultValidatorTTL = time.Hour
+	stateKey            = "cache:state"
+
+	// marks the background revalidation requests, with a random value
+	// known only by the filter
+	revalidateHeader = "X-Skipper-Cache-Revalidate"
+)
+
+// the status codes that are cacheable by default, RFC 9110 15.1
+var cacheableStatus = map[int]bool{
+	http.StatusOK:                   true,
+	http.StatusNonAuthoritativeInfo: true,
+	http.StatusNoContent:            true,
+	http.StatusMultipleChoices:      true,
+	http.StatusMovedPermanently:     true,
+	http.StatusNotFound:             true,
+	http.StatusMethodNotAllowed:     true,
+	http.StatusGone:                 true,
+	http.StatusRequestURITooLong:    true,
+	http.StatusNotImplemented:       true,
+}
+
+// Options for the cache filter.
+type Options struct {
+	// Store is the backing store of the cached responses. Defaults to
+	// an in-memory store of 64MiB.
+	Store Store
+
+	// MaxBodySize limits the size of the stored responses. Defaults to
+	// 1MiB.
+	MaxBodySize int64
+
+	// ValidatorTTL sets how long the stale responses with an ETag or
+	// Last-Modified header are kept for the conditional revalidation.
+	// Defaults to 1h.
+	ValidatorTTL time.Duration
+
+	// MemoryPressure, when set, tells whether the memory is under
+	// pressure. Under pressure, the response bodies are not buffered to
+	// be stored, they are streamed to the clients unchanged.
+	MemoryPressure func() bool
+}
+
+type spec struct {
+	options      Options
+	secret       string
+	mu           sync.Mutex
+	revalidating map[string]bool
+	now          func() time.Time
+}
+
+type filter struct {
+	spec       *spec
+	defaultTTL time.Duration
+}
+
+type state struct {
+	key string
+
+	// the stale entry revalidated with a conditional request
+	entry *Entry
+}
+
+type cacheControl map[string]string
+
+// New creates the cache filter specification, storing the responses in
+// memory.
+func New() filters.Spec {
+	return NewWithOptions(Options{})
+}
+
+// NewWithOptions creates the cache filter specification. The filter
+// accepts an optional argument, the time while the responses without
+// explicit freshness information are fresh, as a duration string.
+func NewWithOptions(o Options) filters.Spec {
+	if o.Store == nil {
+		o.Store = NewMemoryStore(0)
+	}
+
+	if o.MaxBodySize <= 0 {
+		o.MaxBodySize = defaultMaxBodySize
+	}
+
+	if o.ValidatorTTL <= 0 {
+		o.ValidatorTTL = defaultValidatorTTL
+	}
+
+	secret := make([]byte, 16)
+	if _, err := rand.Read(secret); err != nil {
+		log.Errorf("cache: failed to generate the revalidation secret: %v", err)
+	}
+
+	return &spec{
+		options:      o,
+		secret:       hex.EncodeToString(secret),
+		revalidating: make(map[string]bool),
+		now:          time.Now,
+	}
+}
+
+func (*spec) Name() string { return filters.CacheName }
+
+func (s *spec) CreateFilter(args []interface{}) (filters.Filter, error) {
+	f := &filter{spec: s}
+	switch len(args) {
+	case 0:
+	case 1:
+		v, ok := args[0].(string)
+		if !ok {
+			return nil, filters.ErrInvalidFilterParameters
+		}
+
+		d, err := time.ParseDuration(v)
+		if err != nil || d < 0 {
+			return nil, filters.ErrInvalidFilterParameters
+		}
+
+		f.defaultTTL = d
+	default:
+		return nil, filters.ErrInvalidFilterParameters
+	}
+
+	return f, nil
+}
+
+func parseCacheControl(h http.Header) cacheControl {
+	cc := make(cacheControl)
+	for _, v := range h.Values("Cache-Control") {
+		for _, d := range strings.Split(v, ",") {
+			name, value, _ := strings.Cut(strings.TrimSpace(d), "=")
+			if name != "" {
+				cc[strings.ToLower(name)] = strings.Trim(value, `"`)
+			}
+		}
+	}
+
+	return cc
+}
+
+func (cc cacheControl) has(name string) bool {
+	_, ok := cc[name]
+	return ok
+}
+
+func (cc cacheControl) seconds(name string) (time.Duration, bool) {
+	v, ok := cc[name]
+	if !ok {
+		return 0, false
+	}
+
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 0 {
+		return 0, false
+	}
+
+	return time.Duration(n) * time.Second, true
+}
+
+func hasValidators(h http.Header) bool {
+	return h.Get("ETag") != "" || h.Get("Last-Modified") != ""
+}
+
+// returns the freshness of a response, or false when it must not be
+// stored
+func freshness(h http.Header, now time.Time, defaultTTL time.Duration) (maxAge, swr time.Duration, ok bool) {
+	cc := parseCacheControl(h)
+	if cc.has("no-store") || cc.has("private") {
+		return 0, 0, false
+	}
+
+	swr, _ = cc.seconds("stale-while-revalidate")
+	if cc.has("no-cache") {
+		return 0, 0, true
+	}
+
+	if maxAge, ok = cc.seconds("s-maxage"); ok {
+		return maxAge, swr, true
+	}
+
+	if maxAge, ok = cc.seconds("max-age"); ok {
+		return maxAge, swr, true
+	}
+
+	if v := h.Get("Expires"); v != "" {
+		expires, err := http.ParseTime(v)
+		if err != nil || expires.Before(now) {
+			return 0, swr, true
+		}
+
+		return expires.Sub(now), swr, true
+	}
+
+	return defaultTTL, swr, true
+}
+
+func varyHeaders(h http.Header) []string {
+	var names []string
+	for _, v := range h.Values("Vary") {
+		for _, name := range strings.Split(v, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				names = append(names, http.CanonicalHeaderKey(name))
+			}
+		}
+	}
+
+	return names
+}
+
+func (e *Entry) varyMatches(req *http.Request) bool {
+	for name, value := range e.Vary {
+		if strings.Join(req.Header.Values(name), ",") != value {
+			return false
+		}
+	}
+
+	return true
+}
+
+// creates an entry from a response, without the body, or returns nil
+// when the response cannot be stored
+func newEntry(req *http.Request, rsp *http.Response, now time.Time, defaultTTL time.Duration) *Entry {
+	if !cacheableStatus[rsp.StatusCode] || rsp.Header.Get("Set-Cookie") != "" {
+		return nil
+	}
+
+	maxAge, swr, ok := freshness(rsp.Header, now, defaultTTL)
+	if !ok || maxAge+swr <= 0 && !hasValidators(rsp.Header) {
+		return nil
+	}
+
+	e := &Entry{
+		StatusCode:           rsp.StatusCode,
+		Header:               rsp.Header.Clone(),
+		Stored:               now,
+		MaxAge:               maxAge,
+		StaleWhileRevalidate: swr,
+	}
+
+	for _, name := range varyHeaders(rsp.Header) {
+		if name == "*" {
+			return nil
+		}
+
+		if e.Vary == nil {
+			e.Vary = make(map[string]string)
+		}
+
+		e.Vary[name] = strings.Join(req.Header.Values(name), ",")
+	}
+
+	return e
+}
+
+// returns a copy of the entry updated with the headers of a 304 Not
+// Modified response
+func (e *Entry) refreshed(h http.Header, now time.Time, defaultTTL time.Duration) *Entry {
+	c := *e
+	c.Header = e.Header.Clone()
+	for _, name := range []string{"Cache-Control", "Expires", "ETag", "Last-Modified", "Date", "Vary"} {
+		if v, ok := h[name]; ok {
+			c.Header[name] = v
+		}
+	}
+
+	c.Stored = now
+	c.MaxAge, c.StaleWhileRevalidate, _ = freshness(c.Header, now, defaultTTL)
+	return &c
+}
+
+func (e *Entry) response(req *http.Request, age time.Duration) *http.Response {
+	h := e.Header.Clone()
+	h.Set("Age", strconv.Itoa(int(age.Seconds())))
+
+	if etag := e.Header.Get("ETag"); etag != "" && req.Header.Get("If-None-Match") == etag {
+		return &http.Response{StatusCode: http.StatusNotModified, Header: h, Body: http.NoBody}
+	}
+
+	rsp := &http.Response{StatusCode: e.StatusCode, Header: h, Body: http.NoBody}
+	if req.Method != "HEAD" {
+		rsp.ContentLength = int64(len(e.Body))
+		rsp.Body = io.NopCloser(bytes.NewReader(e.Body))
+	}
+
+	return rsp
+}
+
+func (s *spec) ttl(e *Entry) time.Duration {
+	ttl := e.MaxAge + e.StaleWhileRevalidate
+	if hasValidators(e.Header) {
+		ttl += s.options.ValidatorTTL
+	}
+
+	return ttl
+}
+
+func (s *spec) store(req *http.Request, key string, e *Entry) {
+	if err := s.options.Store.Set(req.Context(), key, e, s.ttl(e)); err != nil {
+		log.Errorf("cache: failed to store response: %v", err)
+	}
+}
+
+// revalidates the entry in the background, by sending a copy of the
+// request through the proxy again, marked as a revalidation request
+func (s *spec) revalidate(ctx filters.FilterContext, key string) {
+	s.mu.Lock()
+	if s.revalidating[key] {
+		s.mu.Unlock()
+		return
+	}
+
+	s.revalidating[key] = true
+	s.mu.Unlock()
+
+	done := func() {
+		s.mu.Lock()
+		delete(s.revalidating, key)
+		s.mu.Unlock()
+	}
+
+	cc, err := ctx.Split()
+	if err != nil {
+		log.Errorf("cache: failed to create the revalidation request: %v", err)
+		done()
+		return
+	}
+
+	cc.Request().Method = "GET"
+	cc.Request().Header.Set(revalidateHeader, s.secret)
+	go func() {
+		defer done()
+		cc.Loopback()
+	}()
+}
+
+func (f *filter) Request(ctx filters.FilterContext) {
+	req := ctx.Request()
+	revalidation := req.Header.Get(revalidateHeader) == f.spec.secret
+	req.Header.Del(revalidateHeader)
+
+	if req.Method != "GET" && req.Method != "HEAD" || req.Header.Get("Authorization") != "" {
+		return
+	}
+
+	cc := parseCacheControl(req.Header)
+	if cc.has("no-store") {
+		return
+	}
+
+	s := f.spec
+	key := req.Host + req.URL.RequestURI()
+	e, err := s.options.Store.Get(req.Context(), key)
+	if err != nil {
+		log.Errorf("cache: failed to get response: %v", err)
+	}
+
+	st := &state{key: key}
+	if e == nil || !e.varyMatches(req) {
+		ctx.StateBag()[stateKey] = st
+		return
+	}
+
+	age := s.now().Sub(e.Stored)
+	if !revalidation && !cc.has("no-cache") {
+		if age <= e.MaxAge {
+			ctx.Serve(e.response(req, age))
+			return
+		}
+
+		if age <= e.MaxAge+e.StaleWhileRevalidate {
+			// the context needs to be split before serving the stale
+			// response
+			s.revalidate(ctx, key)
+			ctx.Serve(e.response(req, age))
+			return
+		}
+	}
+
+	if hasValidators(e.Header) && req.Header.Get("If-None-Match") == "" && req.Header.Get("If-Modified-Since") == "" {
+		if etag := e.Header.Get("ETag"); etag != "" {
+			req.Header.Set("If-None-Match", etag)
+		}
+
+		if lm := e.Header.Get("Last-Modified"); lm != "" {
+			req.Header.Set("If-Modified-Since", lm)
+		}
+
+		st.entry = e
+	}
+
+	ctx.StateBag()[stateKey] = st
+}
+
+func (f *filter) Response(ctx filters.FilterContext) {
+	st, ok := ctx.StateBag()[stateKey].(*state)
+	if !ok {
+		return
+	}
+
+	delete(ctx.StateBag(), stateKey)
+	s := f.spec
+	req := ctx.Request()
+	rsp := ctx.Response()
+	now := s.now()
+
+	if rsp.StatusCode == http.StatusNotModified && st.entry != nil {
+		if rsp.Body != nil {
+			rsp.Body.Close()
+		}
+
+		e := st.entry.refreshed(rsp.Header, now, f.defaultTTL)
+		s.store(req, st.key, e)
+
+		c := e.response(&http.Request{Method: req.Method, Header: http.Header{}}, 0)
+		rsp.StatusCode = c.StatusCode
+		rsp.Header = c.Header
+		rsp.ContentLength = c.ContentLength
+		rsp.Body = c.Body
+		return
+	}
+
+	if req.Method != "GET" {
+		return
+	}
+
+	e := newEntry(req, rsp, now, f.defaultTTL)
+	if e == nil {
+		return
+	}
+
+	if rsp.Body == nil {
+		s.store(req, st.key, e)
+		return
+	}
+
+	if s.options.MemoryPressure != nil && s.options.MemoryPressure() {
+		return
+	}
+
+	body := rsp.Body
+	b, err := io.ReadAll(io.LimitReader(body, s.options.MaxBodySize+1))
+	if err != nil || int64(len(b)) > s.options.MaxBodySize {
+		// the response is streamed to the client unchanged
+		rsp.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(bytes.NewReader(b), body), body}
+
+		return
+	}
+
+	body.Close()
+	rsp.Body = io.NopCloser(bytes.NewReader(b))
+	e.Body = b
+	s.store(req, st.key, e)
+}
@@ -0,0 +1,367 @@
+package cache
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/zalando/skipper/eskip"
+	"github.com/zalando/skipper/filters"
+	"github.com/zalando/skipper/proxy/proxytest"
+)
+
+type testBackend struct {
+	mu       sync.Mutex
+	requests []*http.Request
+	handler  func(w http.ResponseWriter, r *http.Request, n int)
+}
+
+func (b *testBackend) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	b.mu.Lock()
+	b.requests = append(b.requests, r)
+	n := len(b.requests)
+	b.mu.Unlock()
+	b.handler(w, r, n)
+}
+
+func (b *testBackend) count() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.requests)
+}
+
+func (b *testBackend) request(i int) *http.Request {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.requests[i]
+}
+
+type testClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *testClock) get() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *testClock) add(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+func startProxy(t *testing.T, handler func(w http.ResponseWriter, r *http.Request, n int), args string) (*testBackend, *testClock, string, func()) {
+	return startProxyWithOptions(t, Options{}, handler, args)
+}
+
+func startProxyWithOptions(t *testing.T, o Options, handler func(w http.ResponseWriter, r *http.Request, n int), args string) (*testBackend, *testClock, string, func()) {
+	b := &testBackend{handler: handler}
+	backend := httptest.NewServer(b)
+
+	routes, err := eskip.Parse(fmt.Sprintf(`* -> cache(%s) -> "%s"`, args, backend.URL))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	clock := &testClock{now: time.Now()}
+	s := NewWithOptions(o).(*spec)
+	s.now = clock.get
+
+	fr := make(filters.Registry)
+	fr.Register(s)
+	p := proxytest.New(fr, routes...)
+	return b, clock, p.URL, func() {
+		p.Close()
+		backend.Close()
+	}
+}
+
+func get(t *testing.T, method, url string, header ...string) (*http.Response, string) {
+	req, _ := http.NewRequest(method, url, nil)
+	for i := 0; i+1 < len(header); i += 2 {
+		req.Header.Set(header[i], header[i+1])
+	}
+
+	rsp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer rsp.Body.Close()
+	b, err := io.ReadAll(rsp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return rsp, string(b)
+}
+
+func TestCreateFilter(t *testing.T) {
+	for _, args := range [][]interface{}{
+		{60.0},
+		{"foo"},
+		{"-1s"},
+		{"1s", "2s"},
+	} {
+		if _, err := New().CreateFilter(args); err == nil {
+			t.Errorf("%v: failed to fail", args)
+		}
+	}
+
+	f, err := New().CreateFilter([]interface{}{"90s"})
+	if err != nil || f.(*filter).defaultTTL != 90*time.Second {
+		t.Errorf("failed to create filter: %v", err)
+	}
+}
+
+func TestFreshness(t *testing.T) {
+	now := time.Now().Truncate(time.Second)
+	for _, tt := range []struct {
+		header     http.Header
+		defaultTTL time.Duration
+		maxAge     time.Duration
+		swr        time.Duration
+		ok         bool
+	}{
+		{header: http.Header{"Cache-Control": {"no-store"}}},
+		{header: http.Header{"Cache-Control": {"private, max-age=60"}}},
+		{header: http.Header{"Cache-Control": {"max-age=60"}}, maxAge: time.Minute, ok: true},
+		{header: http.Header{"Cache-Control": {"max-age=60, s-maxage=120"}}, maxAge: 2 * time.Minute, ok: true},
+		{header: http.Header{"Cache-Control": {"max-age=10", "stale-while-revalidate=30"}}, maxAge: 10 * time.Second, swr: 30 * time.Second, ok: true},
+		{header: http.Header{"Cache-Control": {"no-cache, max-age=60"}}, ok: true},
+		{header: http.Header{"Expires": {now.Add(time.Hour).UTC().Format(http.TimeFormat)}}, maxAge: time.Hour, ok: true},
+		{header: http.Header{"Expires": {"0"}}, ok: true},
+		{header: http.Header{}, defaultTTL: time.Minute, maxAge: time.Minute, ok: true},
+	} {
+		maxAge, swr, ok := freshness(tt.header, now, tt.defaultTTL)
+		if ok != tt.ok || swr != tt.swr || maxAge.Round(time.Second) != tt.maxAge {
+			t.Errorf("%v: unexpected freshness: %v, %v, %v", tt.header, maxAge, swr, ok)
+		}
+	}
+}
+
+func TestCache(t *testing.T) {
+	b, clock, url, closeAll := startProxy(t, func(w http.ResponseWriter, r *http.Request, n int) {
+		switch r.URL.Path {
+		case "/fresh":
+			w.Header().Set("Cache-Control", "max-age=60")
+		case "/private":
+			w.Header().Set("Cache-Control", "private, max-age=60")
+		case "/cookie":
+			w.Header().Set("Cache-Control", "max-age=60")
+			w.Header().Set("Set-Cookie", "foo=bar")
+		case "/error":
+			w.Header().Set("Cache-Control", "max-age=60")
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+
+		fmt.Fprintf(w, "response %d", n)
+	}, "")
+	defer closeAll()
+
+	if _, body := get(t, "GET", url+"/fresh"); body != "response 1" {
+		t.Fatalf("unexpected response: %s", body)
+	}
+
+	clock.add(30 * time.Second)
+	rsp, body := get(t, "GET", url+"/fresh")
+	if body != "response 1" || rsp.Header.Get("Age") != "30" {
+		t.Errorf("failed to serve from the cache: %s, age: %s", body, rsp.Header.Get("Age"))
+	}
+
+	if rsp, body := get(t, "HEAD", url+"/fresh"); rsp.StatusCode != http.StatusOK || body != "" {
+		t.Errorf("failed to serve HEAD from the cache: %d %s", rsp.StatusCode, body)
+	}
+
+	if _, body := get(t, "GET", url+"/fresh", "Cache-Control", "no-cache"); body != "response 2" {
+		t.Errorf("failed to bypass the cache: %s", body)
+	}
+
+	if _, body := get(t, "GET", url+"/fresh", "Authorization", "Bearer foo"); body != "response 3" {
+		t.Errorf("served authorized request from the cache: %s", body)
+	}
+
+	if _, body := get(t, "GET", url+"/fresh"); body != "response 2" {
+		t.Errorf("failed to store the response of the request bypassing the cache: %s", body)
+	}
+
+	clock.add(2 * time.Minute)
+	if _, body := get(t, "GET", url+"/fresh"); body != "response 4" {
+		t.Errorf("served expired response: %s", body)
+	}
+
+	for _, path := range []string{"/private", "/cookie", "/error", "/none"} {
+		get(t, "GET", url+path)
+		n := b.count()
+		if _, body := get(t, "GET", url+path); body != fmt.Sprintf("response %d", n+1) {
+			t.Errorf("%s: unexpected response from the cache: %s", path, body)
+		}
+	}
+}
+
+func TestDefaultTTL(t *testing.T) {
+	_, clock, url, closeAll := startProxy(t, func(w http.ResponseWriter, r *http.Request, n int) {
+		fmt.Fprintf(w, "response %d", n)
+	}, `"10s"`)
+	defer closeAll()
+
+	get(t, "GET", url)
+	if _, body := get(t, "GET", url); body != "response 1" {
+		t.Errorf("failed to serve from the cache: %s", body)
+	}
+
+	clock.add(11 * time.Second)
+	if _, body := get(t, "GET", url); body != "response 2" {
+		t.Errorf("served expired response: %s", body)
+	}
+}
+
+func TestMemoryPressure(t *testing.T) {
+	var pressure int32
+	_, _, url, closeAll := startProxyWithOptions(t, Options{
+		MemoryPressure: func() bool { return atomic.LoadInt32(&pressure) == 1 },
+	}, func(w http.ResponseWriter, r *http.Request, n int) {
+		w.Header().Set("Cache-Control", "max-age=60")
+		fmt.Fprintf(w, "response %d", n)
+	}, "")
+	defer closeAll()
+
+	atomic.StoreInt32(&pressure, 1)
+	if _, body := get(t, "GET", url); body != "response 1" {
+		t.Fatalf("unexpected response: %s", body)
+	}
+
+	if _, body := get(t, "GET", url); body != "response 2" {
+		t.Errorf("stored the response under memory pressure: %s", body)
+	}
+
+	atomic.StoreInt32(&pressure, 0)
+	get(t, "GET", url)
+	if _, body := get(t, "GET", url); body != "response 3" {
+		t.Errorf("failed to serve from the cache: %s", body)
+	}
+}
+
+func TestVary(t *testing.T) {
+	_, _, url, closeAll := startProxy(t, func(w http.ResponseWriter, r *http.Request, n int) {
+		w.Header().Set("Cache-Control", "max-age=60")
+		w.Header().Set("Vary", "Accept-Language")
+		fmt.Fprintf(w, "response %d", n)
+	}, "")
+	defer closeAll()
+
+	get(t, "GET", url, "Accept-Language", "en")
+	if _, body := get(t, "GET", url, "Accept-Language", "en"); body != "response 1" {
+		t.Errorf("failed to serve from the cache: %s", body)
+	}
+
+	if _, body := get(t, "GET", url, "Accept-Language", "de"); body != "response 2" {
+		t.Errorf("served response of a different variant: %s", body)
+	}
+}
+
+func TestConditionalRevalidation(t *testing.T) {
+	b, clock, url, closeAll := startProxy(t, func(w http.ResponseWriter, r *http.Request, n int) {
+		w.Header().Set("Cache-Control", "max-age=10")
+		w.Header().Set("ETag", `"v1"`)
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+
+		fmt.Fprintf(w, "response %d", n)
+	}, "")
+	defer closeAll()
+
+	get(t, "GET", url)
+	clock.add(20 * time.Second)
+
+	rsp, body := get(t, "GET", url)
+	if rsp.StatusCode != http.StatusOK || body != "response 1" || b.count() != 2 {
+		t.Fatalf("failed to revalidate: %d %s, backend requests: %d", rsp.StatusCode, body, b.count())
+	}
+
+	if inm := b.request(1).Header.Get("If-None-Match"); inm != `"v1"` {
+		t.Errorf("failed to send conditional request: %s", inm)
+	}
+
+	if _, body := get(t, "GET", url); body != "response 1" || b.count() != 2 {
+		t.Errorf("failed to refresh the revalidated response: %s, backend requests: %d", body, b.count())
+	}
+
+	if rsp, _ := get(t, "GET", url, "If-None-Match", `"v1"`); rsp.StatusCode != http.StatusNotModified {
+		t.Errorf("failed to serve not modified: %d", rsp.StatusCode)
+	}
+}
+
+func TestStaleWhileRevalidate(t *testing.T) {
+	b, clock, url, closeAll := startProxy(t, func(w http.ResponseWriter, r *http.Request, n int) {
+		w.Header().Set("Cache-Control", "max-age=10, stale-while-revalidate=60")
+		fmt.Fprintf(w, "response %d", n)
+	}, "")
+	defer closeAll()
+
+	get(t, "GET", url)
+	clock.add(20 * time.Second)
+
+	if _, body := get(t, "GET", url); body != "response 1" {
+		t.Fatalf("failed to serve stale response: %s", body)
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for b.count() != 2 {
+		if time.Now().After(deadline) {
+			t.Fatalf("failed to revalidate in the background, backend requests: %d", b.count())
+		}
+
+		time.Sleep(time.Millisecond)
+	}
+
+	if h := b.request(1).Header.Get(revalidateHeader); h != "" {
+		t.Errorf("revalidation header sent to the backend: %s", h)
+	}
+
+	deadline = time.Now().Add(time.Second)
+	for {
+		_, body := get(t, "GET", url)
+		if body == "response 2" {
+			break
+		}
+
+		if time.Now().After(deadline) {
+			t.Fatalf("failed to store the revalidated response: %s", body)
+		}
+
+		time.Sleep(time.Millisecond)
+	}
+
+	if b.count() != 2 {
+		t.Errorf("unexpected backend requests: %d", b.count())
+	}
+
+	clock.add(2 * time.Minute)
+	if _, body := get(t, "GET", url); body != "response 3" {
+		t.Errorf("served response stale for too long: %s", body)
+	}
+}
+
+func TestRevalidateHeaderFromClient(t *testing.T) {
+	_, _, url, closeAll := startProxy(t, func(w http.ResponseWriter, r *http.Request, n int) {
+		w.Header().Set("Cache-Control", "max-age=60")
+		fmt.Fprintf(w, "response %d", n)
+	}, "")
+	defer closeAll()
+
+	get(t, "GET", url)
+	if _, body := get(t, "GET", url, revalidateHeader, "foo"); body != "response 1" {
+		t.Errorf("failed to serve from the cache: %s", body)
+	}
+}
@@ -0,0 +1,188 @@
+package cache
+
+import (
+	"container/list"
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v9"
+	"github.com/zalando/skipper/net"
+)
+
+const (
+	defaultMemoryStoreSize = 64 << 20
+	redisKeyPrefix         = "skipper.cache."
+)
+
+// Entry is a cached response.
+type Entry struct {
+	StatusCode int         `json:"status"`
+	Header     http.Header `json:"header"`
+	Body       []byte      `json:"body"`
+
+	// Vary contains the values of the request headers listed in the Vary
+	// header of the response.
+	Vary map[string]string `json:"vary,omitempty"`
+
+	// Stored is the time when the response was received or last
+	// revalidated.
+	Stored time.Time `json:"stored"`
+
+	// MaxAge is the time while the response is fresh.
+	MaxAge time.Duration `json:"maxAge"`
+
+	// StaleWhileRevalidate is the time after MaxAge while the stale
+	// response is served, and revalidated in the background.
+	StaleWhileRevalidate time.Duration `json:"staleWhileRevalidate,omitempty"`
+}
+
+// Store is the backing store of the cached responses. The
+// implementations need to be safe for concurrent use.
+type Store interface {
+	// Get returns the entry stored with the key, or nil when not found.
+	Get(ctx context.Context, key string) (*Entry, error)
+
+	// Set stores the entry with the key, for the time set by ttl.
+	Set(ctx context.Context, key string, e *Entry, ttl time.Duration) error
+}
+
+// Releaser is implemented by the stores keeping the entries in the memory
+// of the process.
+type Releaser interface {
+	// Release drops all the stored entries, e.g. when the memory is
+	// under pressure.
+	Release()
+}
+
+type memoryItem struct {
+	key     string
+	entry   *Entry
+	expires time.Time
+	size    int64
+}
+
+type memoryStore struct {
+	maxSize int64
+	mu      sync.Mutex
+	size    int64
+	items   map[string]*list.Element
+	lru     *list.List
+	now     func() time.Time
+}
+
+type redisStore struct {
+	ring *net.RedisRingClient
+}
+
+// NewMemoryStore creates an in-memory store, evicting the least recently
+// used entries when the total size of the stored bodies exceeds maxSize.
+// maxSize defaults to 64MiB.
+func NewMemoryStore(maxSize int64) Store {
+	if maxSize <= 0 {
+		maxSize = defaultMemoryStoreSize
+	}
+
+	return &memoryStore{
+		maxSize: maxSize,
+		items:   make(map[string]*list.Element),
+		lru:     list.New(),
+		now:     time.Now,
+	}
+}
+
+func (s *memoryStore) remove(e *list.Element) {
+	item := s.lru.Remove(e).(*memoryItem)
+	delete(s.items, item.key)
+	s.size -= item.size
+}
+
+func (s *memoryStore) Get(_ context.Context, key string) (*Entry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e, ok := s.items[key]
+	if !ok {
+		return nil, nil
+	}
+
+	item := e.Value.(*memoryItem)
+	if !s.now().Before(item.expires) {
+		s.remove(e)
+		return nil, nil
+	}
+
+	s.lru.MoveToFront(e)
+	return item.entry, nil
+}
+
+func (s *memoryStore) Set(_ context.Context, key string, entry *Entry, ttl time.Duration) error {
+	size := int64(len(entry.Body))
+	if size > s.maxSize {
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if e, ok := s.items[key]; ok {
+		s.remove(e)
+	}
+
+	s.items[key] = s.lru.PushFront(&memoryItem{
+		key:     key,
+		entry:   entry,
+		expires: s.now().Add(ttl),
+		size:    size,
+	})
+
+	s.size += size
+	for s.size > s.maxSize {
+		s.remove(s.lru.Back())
+	}
+
+	return nil
+}
+
+func (s *memoryStore) Release() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.items = make(map[string]*list.Element)
+	s.lru.Init()
+	s.size = 0
+}
+
+// NewRedisStore creates a store using the Redis ring, shared by the
+// skipper instances connected to the same ring.
+func NewRedisStore(ring *net.RedisRingClient) Store {
+	return &redisStore{ring: ring}
+}
+
+func (s *redisStore) Get(ctx context.Context, key string) (*Entry, error) {
+	v, err := s.ring.Get(ctx, redisKeyPrefix+key)
+	if err == redis.Nil {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	var e Entry
+	if err := json.Unmarshal([]byte(v), &e); err != nil {
+		return nil, err
+	}
+
+	return &e, nil
+}
+
+func (s *redisStore) Set(ctx context.Context, key string, e *Entry, ttl time.Duration) error {
+	b, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.ring.Set(ctx, redisKeyPrefix+key, b, ttl)
+	return err
+}
@@ -0,0 +1,60 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestMemoryStore(t *testing.T) {
+	now := time.Now()
+	s := NewMemoryStore(10).(*memoryStore)
+	s.now = func() time.Time { return now }
+
+	ctx := context.Background()
+	s.Set(ctx, "foo", &Entry{Body: []byte("foo")}, time.Minute)
+	s.Set(ctx, "bar", &Entry{Body: []byte("bar")}, time.Hour)
+	s.Set(ctx, "large", &Entry{Body: []byte("larger than the limit")}, time.Hour)
+
+	if e, _ := s.Get(ctx, "foo"); e == nil || string(e.Body) != "foo" {
+		t.Errorf("failed to get entry: %v", e)
+	}
+
+	if e, _ := s.Get(ctx, "large"); e != nil {
+		t.Error("stored entry larger than the limit")
+	}
+
+	// evicts bar, the least recently used
+	s.Set(ctx, "baz", &Entry{Body: []byte("bazqux")}, time.Hour)
+	if e, _ := s.Get(ctx, "bar"); e != nil {
+		t.Error("failed to evict the least recently used entry")
+	}
+
+	if e, _ := s.Get(ctx, "foo"); e == nil {
+		t.Error("evicted recently used entry")
+	}
+
+	now = now.Add(2 * time.Minute)
+	if e, _ := s.Get(ctx, "foo"); e != nil {
+		t.Error("failed to expire entry")
+	}
+
+	if e, _ := s.Get(ctx, "baz"); e == nil || s.size != 6 || len(s.items) != 1 {
+		t.Errorf("unexpected state: %v, %d, %d", e, s.size, len(s.items))
+	}
+}
+
+func TestMemoryStoreRelease(t *testing.T) {
+	s := NewMemoryStore(0)
+	ctx := context.Background()
+	s.Set(ctx, "foo", &Entry{Body: []byte("foo")}, time.Minute)
+	s.(Releaser).Release()
+	if e, _ := s.Get(ctx, "foo"); e != nil {
+		t.Error("failed to release the entries")
+	}
+
+	s.Set(ctx, "bar", &Entry{Body: []byte("bar")}, time.Minute)
+	if e, _ := s.Get(ctx, "bar"); e == nil || s.(*memoryStore).size != 3 {
+		t.Error("failed to store an entry after the release")
+	}
+}
@@ -0,0 +1,385 @@
+/*
+Package challenge provides the challenge filter, that lets through only
+the clients solving a proof-of-work challenge in JavaScript, to raise the
+cost of the scraping without blocking the clients.
+
+The clients without a valid pass cookie receive a page, that computes a
+SHA-256 hash with the required number of leading zero bits, stores the
+solution in a cookie, and reloads the page. When the solution is valid,
+the filter lets the request through, and sets a pass cookie, valid for
+the configured time, one hour by default.
+
+	r: * -> challenge(16) -> "https://app.example.org";
+	r: * -> challenge(20, "24h") -> "https://app.example.org";
+
+The difficulty is the number of the required leading zero bits, between
+1 and 32. Every additional bit doubles the expected work of the clients.
+
+The filter is typically used only for the likely bots, together with the
+BotScore predicate:
+
+	bots: BotScore(50) -> challenge(18) -> "https://app.example.org";
+
+The challenges and the pass cookies are signed with a secret. To accept
+the cookies by every instance of a fleet, the instances need to share the
+secret, see the Options.
+
+The challenges are bound to the User-Agent and the address of the client,
+and each solution is accepted only once by an instance. The pass cookies
+are bound to the User-Agent, and they are accepted only by the challenge
+filters with the same or lower difficulty, for the TTL of the filter
+accepting them.
+*/
+package challenge
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"html/template"
+	"math/bits"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/zalando/skipper/filters"
+	snet "github.com/zalando/skipper/net"
+)
+
+const (
+	// PassCookie is the name of the cookie set for the clients that
+	// solved a challenge.
+	PassCookie = "skipper-challenge-pass"
+
+	// SolutionCookie is the name of the cookie containing the solution
+	// of a challenge.
+	SolutionCookie = "skipper-challenge"
+
+	defaultTTL          = time.Hour
+	challengeTTL        = 5 * time.Minute
+	maxDifficulty       = 32
+	setPassCookieBagKey = "filter:challenge:pass"
+)
+
+var page = template.Must(template.New("challenge").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>Checking your browser</title></head>
+<body>
+<p>Checking your browser, please wait...</p>
+<noscript><p>Please enable JavaScript to continue.</p></noscript>
+<script>
+(async function() {
+	const challenge = {{.Challenge}};
+	const difficulty = {{.Difficulty}};
+	const encoder = new TextEncoder();
+	const leadingZeros = function(b) {
+		let n = 0;
+		for (const x of b) {
+			if (x === 0) { n += 8; continue; }
+			n += Math.clz32(x) - 24;
+			break;
+		}
+		return n;
+	};
+	for (let nonce = 0; ; nonce++) {
+		const h = new Uint8Array(await crypto.subtle.digest("SHA-256", encoder.encode(challenge + ":" + nonce)));
+		if (leadingZeros(h) >= difficulty) {
+			document.cookie = {{.Cookie}} + "=" + challenge + ":" + nonce + "; path=/; max-age=300; SameSite=Lax";
+			location.reload();
+			return;
+		}
+	}
+})();
+</script>
+</body>
+</html>
+`))
+
+// Options for the challenge filter specification.
+type Options struct {
+
+	// Secret is used to sign the challenges and the pass cookies.
+	// When empty, a random secret is generated, and the cookies are
+	// accepted only by the same instance, until it is restarted.
+	Secret []byte
+}
+
+type spec struct {
+	secret []byte
+	now    func() time.Time
+
+	// the used challenges, until they expire
+	mu     sync.Mutex
+	used   map[string]int64
+	pruned time.Time
+}
+
+type filter struct {
+	spec       *spec
+	difficulty int
+	ttl        time.Duration
+}
+
+// New creates the challenge filter specification.
+func New(o Options) (filters.Spec, error) {
+	secret := o.Secret
+	if len(secret) == 0 {
+		secret = make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			return nil, err
+		}
+	}
+
+	return &spec{secret: secret, now: time.Now, used: make(map[string]int64)}, nil
+}
+
+func (*spec) Name() string { return filters.ChallengeName }
+
+func (s *spec) CreateFilter(args []interface{}) (filters.Filter, error) {
+	if len(args) == 0 || len(args) > 2 {
+		return nil, filters.ErrInvalidFilterParameters
+	}
+
+	d, ok := args[0].(float64)
+	if !ok || d < 1 || d > maxDifficulty {
+		return nil, filters.ErrInvalidFilterParameters
+	}
+
+	f := &filter{spec: s, difficulty: int(d), ttl: defaultTTL}
+	if len(args) == 2 {
+		ttl, ok := args[1].(string)
+		if !ok {
+			return nil, filters.ErrInvalidFilterParameters
+		}
+
+		var err error
+		if f.ttl, err = time.ParseDuration(ttl); err != nil || f.ttl <= 0 {
+			return nil, filters.ErrInvalidFilterParameters
+		}
+	}
+
+	return f, nil
+}
+
+// signs the parts, binding them to the user agent of the client
+func (s *spec) sign(userAgent string, parts ...string) string {
+	m := hmac.New(sha256.New, s.secret)
+	m.Write([]byte(userAgent))
+	for _, p := range parts {
+		m.Write([]byte{0})
+		m.Write([]byte(p))
+	}
+
+	return base64.RawURLEncoding.EncodeToString(m.Sum(nil))
+}
+
+func (s *spec) verify(userAgent, signature string, parts ...string) bool {
+	return hmac.Equal([]byte(signature), []byte(s.sign(userAgent, parts...)))
+}
+
+func (s *spec) expired(unix string) bool {
+	t, err := strconv.ParseInt(unix, 10, 64)
+	return err != nil || s.now().Unix() > t
+}
+
+// marks a challenge as used, and returns false when it was already used
+func (s *spec) use(random, expiry string) bool {
+	t, err := strconv.ParseInt(expiry, 10, 64)
+	if err != nil {
+		return false
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	if now.Sub(s.pruned) > challengeTTL {
+		for r, e := range s.used {
+			if now.Unix() > e {
+				delete(s.used, r)
+			}
+		}
+
+		s.pruned = now
+	}
+
+	if _, ok := s.used[random]; ok {
+		return false
+	}
+
+	s.used[random] = t
+	return true
+}
+
+func clientAddress(req *http.Request) string {
+	if ip := snet.RemoteHost(req); ip != nil {
+		return ip.String()
+	}
+
+	return ""
+}
+
+// the challenge format: <expiry>.<difficulty>.<random>.<signature>, the
+// signature binds it to the user agent and the address of the client
+func (f *filter) newChallenge(userAgent, address string) (string, error) {
+	r := make([]byte, 12)
+	if _, err := rand.Read(r); err != nil {
+		return "", err
+	}
+
+	expiry := strconv.FormatInt(f.spec.now().Add(challengeTTL).Unix(), 10)
+	difficulty := strconv.Itoa(f.difficulty)
+	random := hex.EncodeToString(r)
+	return strings.Join([]string{expiry, difficulty, random, f.spec.sign(userAgent, address, expiry, difficulty, random)}, "."), nil
+}
+
+func leadingZeros(b []byte) int {
+	var n int
+	for _, x := range b {
+		if x == 0 {
+			n += 8
+			continue
+		}
+
+		return n + bits.LeadingZeros8(x)
+	}
+
+	return n
+}
+
+// the solution format: <challenge>:<nonce>. A solution is accepted only
+// once.
+func (f *filter) validSolution(userAgent, address, solution string) bool {
+	challenge, nonce, ok := strings.Cut(solution, ":")
+	if !ok {
+		return false
+	}
+
+	if _, err := strconv.ParseUint(nonce, 10, 64); err != nil {
+		return false
+	}
+
+	parts := strings.Split(challenge, ".")
+	if len(parts) != 4 || parts[1] != strconv.Itoa(f.difficulty) {
+		return false
+	}
+
+	if f.spec.expired(parts[0]) || !f.spec.verify(userAgent, parts[3], address, parts[0], parts[1], parts[2]) {
+		return false
+	}
+
+	h := sha256.Sum256([]byte(solution))
+	return leadingZeros(h[:]) >= f.difficulty && f.spec.use(parts[2], parts[0])
+}
+
+// the pass cookie format: <issued>.<difficulty>.<signature>. It is valid
+// for the filters with the same or lower difficulty, for their TTL.
+func (f *filter) validPass(userAgent, pass string) bool {
+	parts := strings.Split(pass, ".")
+	if len(parts) != 3 {
+		return false
+	}
+
+	issued, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil || f.spec.now().Sub(time.Unix(issued, 0)) > f.ttl {
+		return false
+	}
+
+	difficulty, err := strconv.Atoi(parts[1])
+	if err != nil || difficulty < f.difficulty {
+		return false
+	}
+
+	return f.spec.verify(userAgent, parts[2], "pass", parts[0], parts[1])
+}
+
+func (f *filter) newPass(userAgent string) string {
+	issued := strconv.FormatInt(f.spec.now().Unix(), 10)
+	difficulty := strconv.Itoa(f.difficulty)
+	return issued + "." + difficulty + "." + f.spec.sign(userAgent, "pass", issued, difficulty)
+}
+
+func (f *filter) serveChallenge(ctx filters.FilterContext, userAgent, address string) {
+	challenge, err := f.newChallenge(userAgent, address)
+	if err != nil {
+		ctx.Serve(&http.Response{StatusCode: http.StatusInternalServerError})
+		return
+	}
+
+	var b strings.Builder
+	if err := page.Execute(&b, map[string]interface{}{
+		"Challenge":  challenge,
+		"Difficulty": f.difficulty,
+		"Cookie":     SolutionCookie,
+	}); err != nil {
+		ctx.Serve(&http.Response{StatusCode: http.StatusInternalServerError})
+		return
+	}
+
+	ctx.Serve(&http.Response{
+		StatusCode: http.StatusForbidden,
+		Header: http.Header{
+			"Content-Type":  []string{"text/html; charset=utf-8"},
+			"Cache-Control": []string{"no-store"},
+		},
+		Body: &body{strings.NewReader(b.String())},
+	})
+}
+
+func (f *filter) Request(ctx filters.FilterContext) {
+	req := ctx.Request()
+	userAgent := req.UserAgent()
+
+	if c, err := req.Cookie(PassCookie); err == nil && f.validPass(userAgent, c.Value) {
+		return
+	}
+
+	address := clientAddress(req)
+	if c, err := req.Cookie(SolutionCookie); err == nil && f.validSolution(userAgent, address, c.Value) {
+		ctx.StateBag()[setPassCookieBagKey] = true
+		return
+	}
+
+	f.serveChallenge(ctx, userAgent, address)
+}
+
+func (f *filter) Response(ctx filters.FilterContext) {
+	if set, _ := ctx.StateBag()[setPassCookieBagKey].(bool); !set {
+		return
+	}
+
+	c := &http.Cookie{
+		Name:     PassCookie,
+		Value:    f.newPass(ctx.Request().UserAgent()),
+		Path:     "/",
+		MaxAge:   int(f.ttl / time.Second),
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	}
+
+	ctx.Response().Header.Add("Set-Cookie", c.String())
+	ctx.Response().Header.Add("Set-Cookie", (&http.Cookie{Name: SolutionCookie, Path: "/", MaxAge: -1}).String())
+}
+
+type body struct {
+	*strings.Reader
+}
+
+func (body) Close() error { return nil }
+
+// Solve returns the solution of a challenge, to be set as the value of
+// the SolutionCookie. It can be used by the clients that are allowed to
+// pass the challenges without running JavaScript.
+func Solve(challenge string, difficulty int) string {
+	for nonce := uint64(0); ; nonce++ {
+		solution := challenge + ":" + strconv.FormatUint(nonce, 10)
+		h := sha256.Sum256([]byte(solution))
+		if leadingZeros(h[:]) >= difficulty {
+			return solution
+		}
+	}
+}
@@ -0,0 +1,221 @@
+package challenge
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/zalando/skipper/filters"
+	"github.com/zalando/skipper/filters/filtertest"
+)
+
+var challengeExp = regexp.MustCompile(`const challenge = "([^"]+)"`)
+
+func newSpec(t *testing.T) *spec {
+	s, err := New(Options{Secret: []byte("secret")})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return s.(*spec)
+}
+
+func request(userAgent string, cookies ...*http.Cookie) *http.Request {
+	r := httptest.NewRequest("GET", "/", nil)
+	r.Header.Set("User-Agent", userAgent)
+	for _, c := range cookies {
+		r.AddCookie(c)
+	}
+
+	return r
+}
+
+func serve(f filters.Filter, r *http.Request) *filtertest.Context {
+	ctx := &filtertest.Context{FRequest: r, FStateBag: make(map[string]interface{})}
+	f.Request(ctx)
+	if !ctx.FServed {
+		ctx.FResponse = &http.Response{StatusCode: http.StatusOK, Header: make(http.Header)}
+		f.Response(ctx)
+	}
+
+	return ctx
+}
+
+func challengeOf(t *testing.T, ctx *filtertest.Context) string {
+	if !ctx.FServed || ctx.FResponse.StatusCode != http.StatusForbidden {
+		t.Fatal("failed to serve the challenge")
+	}
+
+	page, err := io.ReadAll(ctx.FResponse.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m := challengeExp.FindSubmatch(page)
+	if m == nil {
+		t.Fatalf("challenge not found: %s", page)
+	}
+
+	return string(m[1])
+}
+
+func passOf(t *testing.T, ctx *filtertest.Context) *http.Cookie {
+	for _, c := range ctx.FResponse.Cookies() {
+		if c.Name == PassCookie {
+			return c
+		}
+	}
+
+	t.Fatal("pass cookie not set")
+	return nil
+}
+
+func TestCreateFilter(t *testing.T) {
+	s := newSpec(t)
+	if s.Name() != filters.ChallengeName {
+		t.Errorf("invalid name: %s", s.Name())
+	}
+
+	for _, tt := range []struct {
+		name string
+		args []interface{}
+		err  bool
+	}{
+		{name: "no args", err: true},
+		{name: "invalid difficulty", args: []interface{}{"16"}, err: true},
+		{name: "difficulty out of range", args: []interface{}{33.0}, err: true},
+		{name: "invalid ttl", args: []interface{}{16.0, "foo"}, err: true},
+		{name: "too many args", args: []interface{}{16.0, "1h", 1.0}, err: true},
+		{name: "difficulty", args: []interface{}{16.0}},
+		{name: "ttl", args: []interface{}{16.0, "24h"}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := s.CreateFilter(tt.args)
+			if tt.err && err == nil {
+				t.Error("failed to fail")
+			} else if !tt.err && err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestChallenge(t *testing.T) {
+	const userAgent = "Mozilla/5.0"
+	s := newSpec(t)
+	f, err := s.CreateFilter([]interface{}{8.0, "10m"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	challenge := challengeOf(t, serve(f, request(userAgent)))
+	solution := Solve(challenge, 8)
+
+	t.Run("invalid solution", func(t *testing.T) {
+		challengeOf(t, serve(f, request(userAgent, &http.Cookie{Name: SolutionCookie, Value: challenge + ":foo"})))
+	})
+
+	t.Run("solution of another client", func(t *testing.T) {
+		challengeOf(t, serve(f, request("curl/8.1.2", &http.Cookie{Name: SolutionCookie, Value: solution})))
+	})
+
+	t.Run("solution of another client address", func(t *testing.T) {
+		r := request(userAgent, &http.Cookie{Name: SolutionCookie, Value: solution})
+		r.RemoteAddr = "198.51.100.1:1234"
+		challengeOf(t, serve(f, r))
+	})
+
+	t.Run("solution of another difficulty", func(t *testing.T) {
+		other, err := s.CreateFilter([]interface{}{9.0})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		challengeOf(t, serve(other, request(userAgent, &http.Cookie{Name: SolutionCookie, Value: solution})))
+	})
+
+	ctx := serve(f, request(userAgent, &http.Cookie{Name: SolutionCookie, Value: solution}))
+	if ctx.FServed {
+		t.Fatal("failed to accept the solution")
+	}
+
+	pass := passOf(t, ctx)
+	if pass.MaxAge != 600 {
+		t.Errorf("unexpected max age: %d", pass.MaxAge)
+	}
+
+	t.Run("solution used again", func(t *testing.T) {
+		challengeOf(t, serve(f, request(userAgent, &http.Cookie{Name: SolutionCookie, Value: solution})))
+	})
+
+	t.Run("pass", func(t *testing.T) {
+		ctx := serve(f, request(userAgent, pass))
+		if ctx.FServed || len(ctx.FResponse.Cookies()) != 0 {
+			t.Error("failed to accept the pass cookie")
+		}
+	})
+
+	t.Run("pass of another client", func(t *testing.T) {
+		challengeOf(t, serve(f, request("curl/8.1.2", pass)))
+	})
+
+	t.Run("pass of a lower difficulty", func(t *testing.T) {
+		harder, err := s.CreateFilter([]interface{}{9.0, "10m"})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		challengeOf(t, serve(harder, request(userAgent, pass)))
+	})
+
+	t.Run("pass of a higher difficulty", func(t *testing.T) {
+		easier, err := s.CreateFilter([]interface{}{7.0, "10m"})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if ctx := serve(easier, request(userAgent, pass)); ctx.FServed {
+			t.Error("failed to accept the pass cookie")
+		}
+	})
+
+	t.Run("pass beyond the ttl of the route", func(t *testing.T) {
+		short, err := s.CreateFilter([]interface{}{8.0, "1m"})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		s.now = func() time.Time { return time.Now().Add(2 * time.Minute) }
+		defer func() { s.now = time.Now }()
+
+		challengeOf(t, serve(short, request(userAgent, pass)))
+		if ctx := serve(f, request(userAgent, pass)); ctx.FServed {
+			t.Error("failed to accept the pass cookie")
+		}
+	})
+
+	t.Run("pass of another secret", func(t *testing.T) {
+		other, err := New(Options{})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		of, err := other.CreateFilter([]interface{}{8.0})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		challengeOf(t, serve(of, request(userAgent, pass)))
+	})
+
+	t.Run("expired", func(t *testing.T) {
+		s.now = func() time.Time { return time.Now().Add(time.Hour) }
+		defer func() { s.now = time.Now }()
+
+		challengeOf(t, serve(f, request(userAgent, pass)))
+		challengeOf(t, serve(f, request(userAgent, &http.Cookie{Name: SolutionCookie, Value: solution})))
+	})
+}
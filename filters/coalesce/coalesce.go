@@ -0,0 +1,261 @@
+/*
+Package coalesce provides the coalesceRequests filter, that deduplicates
+the concurrent identical GET and HEAD requests, sending only one of them
+to the backend, and serving its response to all of them. It protects the
+backends from the bursts of requests for the same resource, e.g. when a
+popular cache entry expires.
+
+The requests are identical when their method, host, path, query, and the
+values of the headers listed in the filter arguments are the same:
+
+	r: * -> coalesceRequests("Accept", "Accept-Encoding") -> "https://origin.example.org";
+
+The requests with the Authorization or Cookie headers are not coalesced,
+unless these headers are part of the key. The responses with the
+Set-Cookie header, and the responses larger than the maximum body size,
+are not shared, and the waiting requests are sent to the backend
+themselves in this case.
+*/
+package coalesce
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/zalando/skipper/filters"
+	"golang.org/x/net/http/httpguts"
+)
+
+const (
+	defaultMaxWait     = 5 * time.Second
+	defaultMaxBodySize = 1 << 20
+	leaderKey          = "coalesce:leader"
+)
+
+// Options for the coalesceRequests filter.
+type Options struct {
+	// MaxWait limits the time that the requests wait for the response
+	// of the identical request in flight. After that, they are sent to
+	// the backend themselves. Defaults to 5s.
+	MaxWait time.Duration
+
+	// MaxBodySize limits the size of the shared responses. Defaults to
+	// 1MiB.
+	MaxBodySize int64
+}
+
+type sharedResponse struct {
+	status int
+	header http.Header
+	body   []byte
+}
+
+type flight struct {
+	once     sync.Once
+	done     chan struct{}
+	response *sharedResponse // nil when not shared
+}
+
+type spec struct {
+	options Options
+	mu      sync.Mutex
+	flights map[string]*flight
+}
+
+type filter struct {
+	spec    *spec
+	headers []string
+}
+
+type leader struct {
+	key    string
+	flight *flight
+}
+
+// New creates the coalesceRequests filter specification with the default
+// options.
+func New() filters.Spec {
+	return NewWithOptions(Options{})
+}
+
+// NewWithOptions creates the coalesceRequests filter specification. The
+// filter arguments are the names of the headers that are part of the key
+// of the requests, besides the method, host, path and query.
+func NewWithOptions(o Options) filters.Spec {
+	if o.MaxWait <= 0 {
+		o.MaxWait = defaultMaxWait
+	}
+
+	if o.MaxBodySize <= 0 {
+		o.MaxBodySize = defaultMaxBodySize
+	}
+
+	return &spec{
+		options: o,
+		flights: make(map[string]*flight),
+	}
+}
+
+func (*spec) Name() string { return filters.CoalesceRequestsName }
+
+func (s *spec) CreateFilter(args []interface{}) (filters.Filter, error) {
+	f := &filter{spec: s}
+	for _, a := range args {
+		name, ok := a.(string)
+		if !ok || !httpguts.ValidHeaderFieldName(name) {
+			return nil, filters.ErrInvalidFilterParameters
+		}
+
+		name = http.CanonicalHeaderKey(name)
+		f.headers = append(f.headers, name)
+	}
+
+	return f, nil
+}
+
+func (f *filter) hasKeyHeader(name string) bool {
+	for _, h := range f.headers {
+		if h == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+// returns the key of the request, or false when the request cannot be
+// coalesced
+func (f *filter) key(req *http.Request) (string, bool) {
+	if req.Method != "GET" && req.Method != "HEAD" {
+		return "", false
+	}
+
+	if req.Body != nil && req.Body != http.NoBody && req.ContentLength != 0 {
+		return "", false
+	}
+
+	for _, h := range []string{"Authorization", "Cookie"} {
+		if _, ok := req.Header[h]; ok && !f.hasKeyHeader(h) {
+			return "", false
+		}
+	}
+
+	var b strings.Builder
+	b.WriteString(req.Method)
+	b.WriteByte(0)
+	b.WriteString(req.Host)
+	b.WriteByte(0)
+	b.WriteString(req.URL.RequestURI())
+	for _, h := range f.headers {
+		b.WriteByte(0)
+		b.WriteString(strings.Join(req.Header[h], ","))
+	}
+
+	return b.String(), true
+}
+
+// finishes the flight, sharing the response, or not when it is nil. It
+// is safe to call multiple times, only the first call has an effect.
+func (s *spec) finish(key string, fl *flight, rsp *sharedResponse) {
+	fl.once.Do(func() {
+		fl.response = rsp
+		s.mu.Lock()
+		if s.flights[key] == fl {
+			delete(s.flights, key)
+		}
+
+		s.mu.Unlock()
+		close(fl.done)
+	})
+}
+
+func (r *sharedResponse) httpResponse() *http.Response {
+	return &http.Response{
+		StatusCode:    r.status,
+		Header:        r.header.Clone(),
+		ContentLength: int64(len(r.body)),
+		Body:          io.NopCloser(bytes.NewReader(r.body)),
+	}
+}
+
+func (f *filter) Request(ctx filters.FilterContext) {
+	req := ctx.Request()
+	key, ok := f.key(req)
+	if !ok {
+		return
+	}
+
+	s := f.spec
+	s.mu.Lock()
+	if fl, ok := s.flights[key]; ok {
+		s.mu.Unlock()
+
+		t := time.NewTimer(s.options.MaxWait)
+		defer t.Stop()
+		select {
+		case <-fl.done:
+		case <-t.C:
+			return
+		case <-req.Context().Done():
+			return
+		}
+
+		if fl.response != nil {
+			ctx.Serve(fl.response.httpResponse())
+		}
+
+		return
+	}
+
+	fl := &flight{done: make(chan struct{})}
+	s.flights[key] = fl
+	s.mu.Unlock()
+
+	ctx.StateBag()[leaderKey] = &leader{key: key, flight: fl}
+
+	// releases the waiting requests when the response filters are not
+	// executed, e.g. because the backend request failed
+	go func() {
+		<-req.Context().Done()
+		s.finish(key, fl, nil)
+	}()
+}
+
+func (f *filter) Response(ctx filters.FilterContext) {
+	l, ok := ctx.StateBag()[leaderKey].(*leader)
+	if !ok {
+		return
+	}
+
+	delete(ctx.StateBag(), leaderKey)
+	rsp := ctx.Response()
+	if _, ok := rsp.Header["Set-Cookie"]; ok || rsp.Body == nil {
+		f.spec.finish(l.key, l.flight, nil)
+		return
+	}
+
+	body := rsp.Body
+	b, err := io.ReadAll(io.LimitReader(body, f.spec.options.MaxBodySize+1))
+	if err != nil || int64(len(b)) > f.spec.options.MaxBodySize {
+		// the response is streamed to the client unchanged
+		rsp.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(bytes.NewReader(b), body), body}
+
+		f.spec.finish(l.key, l.flight, nil)
+		return
+	}
+
+	body.Close()
+	rsp.Body = io.NopCloser(bytes.NewReader(b))
+	f.spec.finish(l.key, l.flight, &sharedResponse{
+		status: rsp.StatusCode,
+		header: rsp.Header.Clone(),
+		body:   b,
+	})
+}
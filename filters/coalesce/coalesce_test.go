@@ -0,0 +1,202 @@
+package coalesce
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/zalando/skipper/eskip"
+	"github.com/zalando/skipper/filters"
+	"github.com/zalando/skipper/proxy/proxytest"
+)
+
+func TestCreateFilter(t *testing.T) {
+	for _, args := range [][]interface{}{
+		{42.0},
+		{"Accept", "invalid header"},
+	} {
+		if _, err := New().CreateFilter(args); err == nil {
+			t.Errorf("%v: failed to fail", args)
+		}
+	}
+
+	f, err := New().CreateFilter([]interface{}{"accept-encoding"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if h := f.(*filter).headers; len(h) != 1 || h[0] != "Accept-Encoding" {
+		t.Errorf("unexpected headers: %v", h)
+	}
+}
+
+func TestKey(t *testing.T) {
+	f := &filter{headers: []string{"Accept", "Cookie"}}
+	req := func(method, url string, header ...string) *http.Request {
+		r, _ := http.NewRequest(method, url, nil)
+		for i := 0; i+1 < len(header); i += 2 {
+			r.Header.Add(header[i], header[i+1])
+		}
+
+		return r
+	}
+
+	key := func(r *http.Request) string {
+		k, ok := f.key(r)
+		if !ok {
+			t.Fatalf("failed to get the key of %s %s", r.Method, r.URL)
+		}
+
+		return k
+	}
+
+	base := key(req("GET", "https://www.example.org/foo?bar=baz", "Accept", "text/html"))
+	for _, r := range []*http.Request{
+		req("HEAD", "https://www.example.org/foo?bar=baz", "Accept", "text/html"),
+		req("GET", "https://api.example.org/foo?bar=baz", "Accept", "text/html"),
+		req("GET", "https://www.example.org/bar?bar=baz", "Accept", "text/html"),
+		req("GET", "https://www.example.org/foo?bar=qux", "Accept", "text/html"),
+		req("GET", "https://www.example.org/foo?bar=baz", "Accept", "application/json"),
+		req("GET", "https://www.example.org/foo?bar=baz", "Accept", "text/html", "Cookie", "session=1"),
+	} {
+		if key(r) == base {
+			t.Errorf("unexpected identical key for %s %s %v", r.Method, r.URL, r.Header)
+		}
+	}
+
+	if key(req("GET", "https://www.example.org/foo?bar=baz", "Accept", "text/html", "X-Other", "1")) != base {
+		t.Error("unexpected different key")
+	}
+
+	post, _ := http.NewRequest("POST", "https://www.example.org/foo", strings.NewReader("foo"))
+	for _, r := range []*http.Request{
+		post,
+		req("GET", "https://www.example.org/foo", "Authorization", "Bearer foo"),
+	} {
+		if _, ok := f.key(r); ok {
+			t.Errorf("unexpected key for %s %s %v", r.Method, r.URL, r.Header)
+		}
+	}
+}
+
+// sends n concurrent requests, and returns the responses
+func concurrentGET(t *testing.T, n int, url string, header ...string) []string {
+	var wg sync.WaitGroup
+	responses := make([]string, n)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			req, _ := http.NewRequest("GET", url, nil)
+			for j := 0; j+1 < len(header); j += 2 {
+				req.Header.Set(header[j], header[j+1])
+			}
+
+			rsp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+
+			defer rsp.Body.Close()
+			b, _ := io.ReadAll(rsp.Body)
+			responses[i] = fmt.Sprintf("%d %s %s", rsp.StatusCode, rsp.Header.Get("X-Test"), b)
+		}(i)
+	}
+
+	wg.Wait()
+	return responses
+}
+
+func TestCoalesceRequests(t *testing.T) {
+	var requests int32
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		time.Sleep(100 * time.Millisecond)
+		if r.URL.Path == "/cookie" {
+			w.Header().Set("Set-Cookie", "session=1")
+		}
+
+		w.Header().Set("X-Test", "test-value")
+		w.Write([]byte(strings.Repeat("x", len(r.URL.Path))))
+	}))
+	defer backend.Close()
+
+	fr := make(filters.Registry)
+	fr.Register(NewWithOptions(Options{MaxBodySize: 8}))
+
+	routes, err := eskip.Parse(fmt.Sprintf(`* -> coalesceRequests() -> "%s"`, backend.URL))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p := proxytest.New(fr, routes...)
+	defer p.Close()
+
+	for _, tt := range []struct {
+		path     string
+		header   []string
+		requests int32
+		body     string
+	}{
+		{path: "/foo", requests: 1, body: "xxxx"},
+		{path: "/cookie", requests: 5, body: "xxxxxxx"},
+		{path: "/too-large", requests: 5, body: "xxxxxxxxxx"},
+		{path: "/auth", header: []string{"Authorization", "Bearer foo"}, requests: 5, body: "xxxxx"},
+	} {
+		t.Run(tt.path, func(t *testing.T) {
+			atomic.StoreInt32(&requests, 0)
+			for _, r := range concurrentGET(t, 5, p.URL+tt.path, tt.header...) {
+				if r != "200 test-value "+tt.body {
+					t.Errorf("unexpected response: %s", r)
+				}
+			}
+
+			if n := atomic.LoadInt32(&requests); n != tt.requests {
+				t.Errorf("unexpected number of backend requests: %d, expected: %d", n, tt.requests)
+			}
+		})
+	}
+}
+
+func TestCoalesceRequestsFailingLeader(t *testing.T) {
+	var requests int32
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&requests, 1) == 1 {
+			time.Sleep(100 * time.Millisecond)
+			panic(http.ErrAbortHandler)
+		}
+
+		w.Write([]byte("ok"))
+	}))
+	defer backend.Close()
+
+	fr := make(filters.Registry)
+	fr.Register(New())
+
+	routes, err := eskip.Parse(fmt.Sprintf(`* -> coalesceRequests() -> "%s"`, backend.URL))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p := proxytest.New(fr, routes...)
+	defer p.Close()
+
+	var ok int
+	for _, r := range concurrentGET(t, 3, p.URL) {
+		if r == "200  ok" {
+			ok++
+		}
+	}
+
+	// the waiting requests are sent to the backend themselves
+	if ok < 2 {
+		t.Errorf("failed to release the waiting requests: %d", ok)
+	}
+}
@@ -0,0 +1,322 @@
+/*
+Package conditional provides the conditionalRequest filter, answering the
+conditional requests with 304 Not Modified at the edge, without sending
+them to the backend.
+
+The filter remembers the validators, the ETag and the Last-Modified
+headers, of the successful GET responses of the backend, for the
+configured duration. When a following GET or HEAD request to the same
+host and URI has an If-None-Match or If-Modified-Since header matching
+the remembered validators, the filter responds with 304 Not Modified.
+The unsafe requests, e.g. PUT or DELETE, to the same URI, and the failed
+responses, make the filter forget the validators:
+
+	r: PathSubtree("/api/products") -> conditionalRequest("30s") -> "https://api.example.org";
+
+The responses with Cache-Control: no-store or no-cache, or Vary: *, are
+not remembered. When the response varies on request headers, the
+validators are used only for the requests with the same values of these
+headers. The validators are shared between the routes, and at most 10000
+URIs are remembered.
+
+The locally answered requests are counted with the
+conditional.notmodified filter metric.
+*/
+package conditional
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/zalando/skipper/filters"
+)
+
+const (
+	maxEntries  = 10000
+	stateBagKey = "filter:" + filters.ConditionalRequestName
+)
+
+type entry struct {
+	etag         string
+	lastModified time.Time
+	header       http.Header
+	vary         []string
+	varyValues   []string
+	expires      time.Time
+}
+
+type store struct {
+	mu      sync.Mutex
+	entries map[string]*entry
+}
+
+type spec struct {
+	store *store
+	now   func() time.Time
+}
+
+type filter struct {
+	maxAge time.Duration
+	store  *store
+	now    func() time.Time
+}
+
+// headers sent with the 304 responses, RFC 9110 15.4.5, except for the
+// Date, which is set by the server
+var notModifiedHeaders = []string{"Cache-Control", "Content-Location", "ETag", "Expires", "Last-Modified", "Vary"}
+
+// New creates the conditionalRequest filter specification.
+func New() filters.Spec {
+	return &spec{store: &store{entries: make(map[string]*entry)}, now: time.Now}
+}
+
+func (*spec) Name() string { return filters.ConditionalRequestName }
+
+func (s *spec) CreateFilter(args []interface{}) (filters.Filter, error) {
+	if len(args) != 1 {
+		return nil, filters.ErrInvalidFilterParameters
+	}
+
+	a, ok := args[0].(string)
+	if !ok {
+		return nil, filters.ErrInvalidFilterParameters
+	}
+
+	d, err := time.ParseDuration(a)
+	if err != nil || d <= 0 {
+		return nil, filters.ErrInvalidFilterParameters
+	}
+
+	return &filter{maxAge: d, store: s.store, now: s.now}, nil
+}
+
+func (s *store) get(key string, now time.Time) *entry {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e, ok := s.entries[key]
+	if !ok {
+		return nil
+	}
+
+	if !now.Before(e.expires) {
+		delete(s.entries, key)
+		return nil
+	}
+
+	return e
+}
+
+func (s *store) set(key string, e *entry, now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.entries[key]; !ok && len(s.entries) >= maxEntries {
+		for k, ei := range s.entries {
+			if !now.Before(ei.expires) {
+				delete(s.entries, k)
+			}
+		}
+
+		// when all the entries are valid, dropping an arbitrary one
+		for k := range s.entries {
+			if len(s.entries) < maxEntries {
+				break
+			}
+
+			delete(s.entries, k)
+		}
+	}
+
+	s.entries[key] = e
+}
+
+func (s *store) delete(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.entries, key)
+}
+
+func requestKey(r *http.Request) string {
+	return r.Host + r.URL.RequestURI()
+}
+
+func (e *entry) matchesVary(r *http.Request) bool {
+	for i, name := range e.vary {
+		if strings.Join(r.Header.Values(name), ",") != e.varyValues[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// parses the list of the entity tags of the If-None-Match header,
+// returning the opaque tags without the weakness indicator, RFC 9110 8.8.3
+func parseETags(h string) []string {
+	var tags []string
+	for {
+		h = strings.TrimLeft(h, " \t,")
+		if h == "" {
+			return tags
+		}
+
+		if h[0] == '*' {
+			tags = append(tags, "*")
+			h = h[1:]
+			continue
+		}
+
+		h = strings.TrimPrefix(h, "W/")
+		if h == "" || h[0] != '"' {
+			return tags
+		}
+
+		end := strings.IndexByte(h[1:], '"')
+		if end < 0 {
+			return tags
+		}
+
+		tags = append(tags, h[:end+2])
+		h = h[end+2:]
+	}
+}
+
+// evaluates the preconditions of a GET or HEAD request, RFC 9110 13.2.2,
+// using the weak comparison of the entity tags
+func (e *entry) notModified(r *http.Request) bool {
+	if inm := r.Header.Values("If-None-Match"); len(inm) > 0 {
+		if e.etag == "" {
+			return false
+		}
+
+		etag := strings.TrimPrefix(e.etag, "W/")
+		for _, t := range parseETags(strings.Join(inm, ",")) {
+			if t == "*" || t == etag {
+				return true
+			}
+		}
+
+		return false
+	}
+
+	ims := r.Header.Get("If-Modified-Since")
+	if ims == "" || e.lastModified.IsZero() {
+		return false
+	}
+
+	t, err := http.ParseTime(ims)
+	if err != nil {
+		return false
+	}
+
+	return !e.lastModified.After(t)
+}
+
+func (f *filter) Request(ctx filters.FilterContext) {
+	req := ctx.Request()
+	key := requestKey(req)
+	switch req.Method {
+	case http.MethodGet, http.MethodHead:
+	case http.MethodOptions, http.MethodTrace:
+		return
+	default:
+		f.store.delete(key)
+		return
+	}
+
+	ctx.StateBag()[stateBagKey] = key
+	e := f.store.get(key, f.now())
+	if e == nil || !e.matchesVary(req) || !e.notModified(req) {
+		return
+	}
+
+	ctx.Metrics().IncCounter("conditional.notmodified")
+	ctx.Serve(&http.Response{
+		StatusCode: http.StatusNotModified,
+		Header:     e.header.Clone(),
+	})
+}
+
+func hasDirective(cacheControl []string, directives ...string) bool {
+	for _, h := range cacheControl {
+		for _, d := range strings.Split(h, ",") {
+			d, _, _ = strings.Cut(strings.TrimSpace(d), "=")
+			for _, di := range directives {
+				if strings.EqualFold(d, di) {
+					return true
+				}
+			}
+		}
+	}
+
+	return false
+}
+
+func parseVary(h http.Header) ([]string, bool) {
+	var names []string
+	for _, v := range h.Values("Vary") {
+		for _, name := range strings.Split(v, ",") {
+			name = strings.TrimSpace(name)
+			switch name {
+			case "":
+			case "*":
+				return nil, false
+			default:
+				names = append(names, http.CanonicalHeaderKey(name))
+			}
+		}
+	}
+
+	return names, true
+}
+
+func (f *filter) Response(ctx filters.FilterContext) {
+	key, ok := ctx.StateBag()[stateBagKey].(string)
+	if !ok || ctx.Request().Method != http.MethodGet {
+		return
+	}
+
+	rsp := ctx.Response()
+	switch {
+	case rsp.StatusCode == http.StatusNotModified:
+		return
+	case rsp.StatusCode != http.StatusOK:
+		f.store.delete(key)
+		return
+	}
+
+	etag := rsp.Header.Get("ETag")
+	lastModified, _ := http.ParseTime(rsp.Header.Get("Last-Modified"))
+	if etag == "" && lastModified.IsZero() || hasDirective(rsp.Header.Values("Cache-Control"), "no-store", "no-cache") {
+		f.store.delete(key)
+		return
+	}
+
+	vary, ok := parseVary(rsp.Header)
+	if !ok {
+		f.store.delete(key)
+		return
+	}
+
+	e := &entry{
+		etag:         etag,
+		lastModified: lastModified,
+		header:       make(http.Header),
+		vary:         vary,
+		expires:      f.now().Add(f.maxAge),
+	}
+
+	for _, name := range vary {
+		e.varyValues = append(e.varyValues, strings.Join(ctx.Request().Header.Values(name), ","))
+	}
+
+	for _, name := range notModifiedHeaders {
+		for _, v := range rsp.Header.Values(name) {
+			e.header.Add(name, v)
+		}
+	}
+	f.store.set(key, e, f.now())
+}
@@ -0,0 +1,251 @@
+package conditional
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/zalando/skipper/filters"
+	"github.com/zalando/skipper/filters/filtertest"
+	"github.com/zalando/skipper/metrics/metricstest"
+)
+
+const lastModified = "Wed, 21 Oct 2026 07:28:00 GMT"
+
+func TestCreateFilter(t *testing.T) {
+	for _, args := range [][]interface{}{
+		nil,
+		{"foo"},
+		{"-1s"},
+		{30},
+		{"30s", "foo"},
+	} {
+		if _, err := New().CreateFilter(args); err == nil {
+			t.Errorf("%v: failed to fail", args)
+		}
+	}
+}
+
+type testFilter struct {
+	t      *testing.T
+	filter filters.Filter
+}
+
+func newTestFilter(t *testing.T, s *spec) testFilter {
+	f, err := s.CreateFilter([]interface{}{"1m"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return testFilter{t: t, filter: f}
+}
+
+// runs the filter, and returns the response, and whether it was served
+// by the filter
+func (tf testFilter) roundTrip(req *http.Request, backend *http.Response) (*http.Response, bool) {
+	ctx := &filtertest.Context{
+		FRequest:  req,
+		FStateBag: make(map[string]interface{}),
+		FMetrics:  &metricstest.MockMetrics{},
+	}
+
+	tf.filter.Request(ctx)
+	if ctx.FServed {
+		return ctx.FResponse, true
+	}
+
+	if backend.Header == nil {
+		backend.Header = make(http.Header)
+	}
+
+	ctx.FResponse = backend
+	tf.filter.Response(ctx)
+	return backend, false
+}
+
+func request(method, uri string, header ...string) *http.Request {
+	r := httptest.NewRequest(method, uri, nil)
+	for i := 0; i+1 < len(header); i += 2 {
+		r.Header.Add(header[i], header[i+1])
+	}
+
+	return r
+}
+
+func ok(header ...string) *http.Response {
+	rsp := &http.Response{StatusCode: http.StatusOK, Header: make(http.Header)}
+	for i := 0; i+1 < len(header); i += 2 {
+		rsp.Header.Add(header[i], header[i+1])
+	}
+
+	return rsp
+}
+
+func TestNotModified(t *testing.T) {
+	for _, tt := range []struct {
+		name     string
+		response *http.Response
+		request  *http.Request
+		served   bool
+	}{{
+		name:     "matching etag",
+		response: ok("ETag", `"v1"`, "Cache-Control", "max-age=60"),
+		request:  request("GET", "/foo", "If-None-Match", `"v0", "v1"`),
+		served:   true,
+	}, {
+		name:     "weak etag",
+		response: ok("ETag", `W/"v1"`),
+		request:  request("HEAD", "/foo", "If-None-Match", `"v1"`),
+		served:   true,
+	}, {
+		name:     "any etag",
+		response: ok("ETag", `"v1"`),
+		request:  request("GET", "/foo", "If-None-Match", "*"),
+		served:   true,
+	}, {
+		name:     "different etag",
+		response: ok("ETag", `"v1"`),
+		request:  request("GET", "/foo", "If-None-Match", `"v2"`),
+	}, {
+		name:     "different uri",
+		response: ok("ETag", `"v1"`),
+		request:  request("GET", "/foo?bar=baz", "If-None-Match", `"v1"`),
+	}, {
+		name:     "not modified since",
+		response: ok("Last-Modified", lastModified),
+		request:  request("GET", "/foo", "If-Modified-Since", lastModified),
+		served:   true,
+	}, {
+		name:     "modified since",
+		response: ok("Last-Modified", lastModified),
+		request:  request("GET", "/foo", "If-Modified-Since", "Wed, 21 Oct 2026 07:27:59 GMT"),
+	}, {
+		name:     "if-none-match takes precedence",
+		response: ok("ETag", `"v1"`, "Last-Modified", lastModified),
+		request:  request("GET", "/foo", "If-None-Match", `"v2"`, "If-Modified-Since", lastModified),
+	}, {
+		name:     "unconditional",
+		response: ok("ETag", `"v1"`),
+		request:  request("GET", "/foo"),
+	}, {
+		name:     "no-store",
+		response: ok("ETag", `"v1"`, "Cache-Control", "private, no-store"),
+		request:  request("GET", "/foo", "If-None-Match", `"v1"`),
+	}, {
+		name:     "vary any",
+		response: ok("ETag", `"v1"`, "Vary", "*"),
+		request:  request("GET", "/foo", "If-None-Match", `"v1"`),
+	}, {
+		name:     "same vary value",
+		response: ok("ETag", `"v1"`, "Vary", "accept-encoding"),
+		request:  request("GET", "/foo", "If-None-Match", `"v1"`, "Accept-Encoding", "gzip"),
+		served:   true,
+	}, {
+		name:     "different vary value",
+		response: ok("ETag", `"v1"`, "Vary", "Accept-Encoding"),
+		request:  request("GET", "/foo", "If-None-Match", `"v1"`, "Accept-Encoding", "br"),
+	}, {
+		name:     "not ok",
+		response: &http.Response{StatusCode: http.StatusNotFound, Header: http.Header{"Etag": []string{`"v1"`}}},
+		request:  request("GET", "/foo", "If-None-Match", `"v1"`),
+	}} {
+		t.Run(tt.name, func(t *testing.T) {
+			tf := newTestFilter(t, New().(*spec))
+			tf.roundTrip(request("GET", "/foo", "Accept-Encoding", "gzip"), tt.response)
+
+			rsp, served := tf.roundTrip(tt.request, ok())
+			if served != tt.served {
+				t.Fatalf("unexpected served: %t, expected: %t", served, tt.served)
+			}
+
+			if !served {
+				return
+			}
+
+			if rsp.StatusCode != http.StatusNotModified {
+				t.Errorf("unexpected status: %d", rsp.StatusCode)
+			}
+
+			for _, name := range []string{"ETag", "Last-Modified", "Cache-Control", "Vary"} {
+				if rsp.Header.Get(name) != tt.response.Header.Get(name) {
+					t.Errorf("unexpected %s header: %q, expected: %q", name, rsp.Header.Get(name), tt.response.Header.Get(name))
+				}
+			}
+		})
+	}
+}
+
+func TestExpiration(t *testing.T) {
+	now := time.Now()
+	s := New().(*spec)
+	s.now = func() time.Time { return now }
+	tf := newTestFilter(t, s)
+
+	tf.roundTrip(request("GET", "/foo"), ok("ETag", `"v1"`))
+	if _, served := tf.roundTrip(request("GET", "/foo", "If-None-Match", `"v1"`), ok()); !served {
+		t.Fatal("failed to serve")
+	}
+
+	now = now.Add(time.Minute)
+	if _, served := tf.roundTrip(request("GET", "/foo", "If-None-Match", `"v1"`), ok()); served {
+		t.Error("failed to expire the validators")
+	}
+}
+
+func TestInvalidation(t *testing.T) {
+	for _, tt := range []struct {
+		name     string
+		request  *http.Request
+		response *http.Response
+	}{
+		{name: "unsafe request", request: request("PUT", "/foo"), response: &http.Response{StatusCode: http.StatusNoContent}},
+		{name: "failed response", request: request("GET", "/foo"), response: &http.Response{StatusCode: http.StatusInternalServerError}},
+		{name: "no validators", request: request("GET", "/foo"), response: ok()},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			tf := newTestFilter(t, New().(*spec))
+			tf.roundTrip(request("GET", "/foo"), ok("ETag", `"v1"`))
+			tf.roundTrip(tt.request, tt.response)
+			if _, served := tf.roundTrip(request("GET", "/foo", "If-None-Match", `"v1"`), ok()); served {
+				t.Error("failed to invalidate the validators")
+			}
+		})
+	}
+}
+
+func TestSharedBetweenRoutes(t *testing.T) {
+	s := New().(*spec)
+	tf1, tf2 := newTestFilter(t, s), newTestFilter(t, s)
+	tf1.roundTrip(request("GET", "/foo"), ok("ETag", `"v1"`))
+	if _, served := tf2.roundTrip(request("GET", "/foo", "If-None-Match", `"v1"`), ok()); !served {
+		t.Error("failed to share the validators")
+	}
+}
+
+func TestMaxEntries(t *testing.T) {
+	tf := newTestFilter(t, New().(*spec))
+	for i := 0; i < maxEntries+10; i++ {
+		tf.roundTrip(request("GET", fmt.Sprintf("/foo/%d", i)), ok("ETag", `"v1"`))
+	}
+
+	st := tf.filter.(*filter).store
+	if len(st.entries) != maxEntries {
+		t.Errorf("unexpected number of entries: %d", len(st.entries))
+	}
+}
+
+func TestParseETags(t *testing.T) {
+	for h, expected := range map[string][]string{
+		`"v1"`:                {`"v1"`},
+		`W/"v1", "v,2"`:       {`"v1"`, `"v,2"`},
+		` * `:                 {"*"},
+		`"v1", invalid, "v2"`: {`"v1"`},
+		`"v1`:                 nil,
+	} {
+		if tags := parseETags(h); fmt.Sprint(tags) != fmt.Sprint(expected) {
+			t.Errorf("%q: unexpected tags: %v, expected: %v", h, tags, expected)
+		}
+	}
+}
@@ -0,0 +1,132 @@
+package diag
+
+import (
+	"sync"
+	"time"
+
+	"github.com/zalando/skipper/filters"
+)
+
+const (
+	percentileLatencyStartKey = "diag:percentilelatency:start"
+
+	defaultPercentileLatencyWindow = 1000
+)
+
+type percentileLatencySpec struct{}
+
+type percentileLatency struct {
+	target time.Duration
+
+	// the share of the requests that need to reach the target, for the
+	// percentile of the latency to reach it
+	share float64
+
+	mu      sync.Mutex
+	samples []bool
+	next    int
+	reached int
+}
+
+// NewPercentileLatency creates a filter specification, whose filter
+// instances inject artificial latency to the responses of a route, just
+// enough to make the given percentile of the response latency reach the
+// target. It measures the latency of the route in a sliding window of
+// the last requests, and delays only as many responses, as many are
+// needed. It can be used to validate alerting and autoscaling based on
+// the latency objectives, e.g. in test environments.
+//
+// The first argument is the percentile, the second one is the target
+// latency, and the optional third one is the number of the requests in
+// the sliding window, defaulting to 1000.
+//
+// Eskip example:
+//
+//	r: * -> percentileLatency(99, "800ms") -> "https://www.example.org";
+func NewPercentileLatency() filters.Spec { return percentileLatencySpec{} }
+
+func (percentileLatencySpec) Name() string { return filters.PercentileLatencyName }
+
+func (percentileLatencySpec) CreateFilter(args []interface{}) (filters.Filter, error) {
+	if len(args) < 2 || len(args) > 3 {
+		return nil, filters.ErrInvalidFilterParameters
+	}
+
+	p, ok := args[0].(float64)
+	if !ok || p <= 0 || p >= 100 {
+		return nil, filters.ErrInvalidFilterParameters
+	}
+
+	target, err := parseDuration(args[1])
+	if err != nil || target == 0 {
+		return nil, filters.ErrInvalidFilterParameters
+	}
+
+	window := defaultPercentileLatencyWindow
+	if len(args) == 3 {
+		w, ok := args[2].(float64)
+		if !ok || w < 1 || w != float64(int(w)) {
+			return nil, filters.ErrInvalidFilterParameters
+		}
+
+		window = int(w)
+	}
+
+	return &percentileLatency{
+		target:  target,
+		share:   1 - p/100,
+		samples: make([]bool, 0, window),
+	}, nil
+}
+
+func (f *percentileLatency) Request(ctx filters.FilterContext) {
+	ctx.StateBag()[percentileLatencyStartKey] = time.Now()
+}
+
+// delay returns the latency to be injected for a response with the
+// measured latency, and records the sample.
+func (f *percentileLatency) delay(d time.Duration) time.Duration {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	// the oldest sample is dropped when the window is full
+	full := len(f.samples) == cap(f.samples)
+	if full && f.samples[f.next] {
+		f.reached--
+	}
+
+	n := len(f.samples)
+	if !full {
+		n++
+	}
+
+	// delaying while the share of the requests reaching the target is
+	// not above the required share, tolerating the rounding errors
+	var delay time.Duration
+	if d < f.target && float64(f.reached) <= f.share*float64(n)+1e-9 {
+		delay = f.target - d
+	}
+
+	reached := d+delay >= f.target
+	if full {
+		f.samples[f.next] = reached
+		f.next = (f.next + 1) % n
+	} else {
+		f.samples = append(f.samples, reached)
+	}
+
+	if reached {
+		f.reached++
+	}
+
+	return delay
+}
+
+func (f *percentileLatency) Response(ctx filters.FilterContext) {
+	start, ok := ctx.StateBag()[percentileLatencyStartKey].(time.Time)
+	if !ok {
+		return
+	}
+
+	time.Sleep(f.delay(time.Since(start)))
+}
@@ -0,0 +1,103 @@
+package diag
+
+import (
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestPercentileLatencyArgs(t *testing.T) {
+	for _, args := range [][]interface{}{
+		nil,
+		{99.0},
+		{0.0, "1s"},
+		{100.0, "1s"},
+		{"99", "1s"},
+		{99.0, "foo"},
+		{99.0, "0s"},
+		{99.0, "1s", 0.0},
+		{99.0, "1s", 1.5},
+		{99.0, "1s", 100.0, "foo"},
+	} {
+		if _, err := NewPercentileLatency().CreateFilter(args); err == nil {
+			t.Errorf("%v: failed to fail", args)
+		}
+	}
+
+	f, err := NewPercentileLatency().CreateFilter([]interface{}{99.9, 800.0, 50.0})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if pl := f.(*percentileLatency); pl.target != 800*time.Millisecond || cap(pl.samples) != 50 {
+		t.Errorf("unexpected settings: %v, %d", pl.target, cap(pl.samples))
+	}
+}
+
+func percentileOf(samples []time.Duration, p float64) time.Duration {
+	s := append([]time.Duration(nil), samples...)
+	sort.Slice(s, func(i, j int) bool { return s[i] < s[j] })
+	return s[int(p/100*float64(len(s)-1))]
+}
+
+func TestPercentileLatency(t *testing.T) {
+	f, err := NewPercentileLatency().CreateFilter([]interface{}{90.0, "100ms", 100.0})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pl := f.(*percentileLatency)
+
+	var (
+		total   []time.Duration
+		delayed int
+	)
+
+	for i := 0; i < 1000; i++ {
+		d := 10 * time.Millisecond
+		delay := pl.delay(d)
+		if delay > 0 {
+			delayed++
+		}
+
+		total = append(total, d+delay)
+	}
+
+	if delayed < 100 || delayed > 120 {
+		t.Errorf("unexpected number of delayed responses: %d", delayed)
+	}
+
+	if p := percentileOf(total, 90); p != 100*time.Millisecond {
+		t.Errorf("failed to reach the target, p90: %v", p)
+	}
+
+	if p := percentileOf(total, 80); p != 10*time.Millisecond {
+		t.Errorf("unexpected p80: %v", p)
+	}
+}
+
+func TestPercentileLatencySlowBackend(t *testing.T) {
+	f, err := NewPercentileLatency().CreateFilter([]interface{}{90.0, "100ms", 100.0})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pl := f.(*percentileLatency)
+
+	// every fifth response is slow, so the target is reached without delays
+	var delayed int
+	for i := 0; i < 1000; i++ {
+		d := 10 * time.Millisecond
+		if i%5 == 0 {
+			d = 200 * time.Millisecond
+		}
+
+		if pl.delay(d) > 0 {
+			delayed++
+		}
+	}
+
+	if delayed != 0 {
+		t.Errorf("unexpected delayed responses: %d", delayed)
+	}
+}
@@ -0,0 +1,361 @@
+/*
+Package dictionary provides filters for the Compression Dictionary
+Transport (RFC 9842), compressing the responses with a dictionary shared
+with the clients. It is effective for the routes serving similar
+payloads, e.g. JSON API responses, when most of the content can be found
+in the dictionary.
+
+The useAsDictionary filter marks a response as usable as a dictionary by
+the clients, for the requests matching the URL pattern:
+
+	dict: Path("/dictionaries/users.json") -> useAsDictionary("/api/users/*") -> "https://static.example.org";
+
+The dictionaryCompress filter compresses the responses with the content
+of a dictionary file, when the client announces the same dictionary with
+the Available-Dictionary header, and accepts the dcz encoding. The file
+must contain the same bytes that the clients received as the dictionary.
+Optionally, the filter advertises the dictionary with a Link header:
+
+	api: Path("/api/users/*") -> dictionaryCompress("/etc/skipper/dictionaries/users.json", "/dictionaries/users.json") -> "https://api.example.org";
+
+Only the dcz encoding (Zstandard) is supported, the dcb encoding (Brotli)
+is not.
+*/
+package dictionary
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/zalando/skipper/filters"
+)
+
+const (
+	dczEncoding = "dcz"
+	bufferSize  = 8192
+
+	stateBagKey = "filter:" + filters.DictionaryCompressName
+)
+
+var (
+	// RFC 9842 4, a skippable frame containing the hash of the dictionary
+	dczHeader = []byte{0x5e, 0x2a, 0x4d, 0x18, 0x20, 0x00, 0x00, 0x00}
+
+	compressMIME = []string{
+		"text/plain",
+		"text/html",
+		"text/css",
+		"text/javascript",
+		"application/json",
+		"application/javascript",
+		"application/x-javascript",
+		"image/svg+xml",
+	}
+)
+
+type useAsDictionarySpec struct{}
+
+type useAsDictionaryFilter struct {
+	header string
+}
+
+type cachedDictionary struct {
+	modTime    time.Time
+	dictionary *dictionary
+}
+
+type dictionary struct {
+	hash      []byte
+	available string
+	zstd      *zstdDictionary
+}
+
+type compressSpec struct {
+	mu    sync.Mutex
+	cache map[string]cachedDictionary
+}
+
+type compressFilter struct {
+	dictionary *dictionary
+	link       string
+}
+
+// NewUseAsDictionary creates the useAsDictionary filter specification.
+func NewUseAsDictionary() filters.Spec { return useAsDictionarySpec{} }
+
+func (useAsDictionarySpec) Name() string { return filters.UseAsDictionaryName }
+
+func validString(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] < 0x20 || s[i] > 0x7e {
+			return false
+		}
+	}
+
+	return true
+}
+
+// RFC 8941 3.3.3, the structured field string
+func quote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
+
+func (useAsDictionarySpec) CreateFilter(args []interface{}) (filters.Filter, error) {
+	if len(args) == 0 || len(args) > 2 {
+		return nil, filters.ErrInvalidFilterParameters
+	}
+
+	match, ok := args[0].(string)
+	if !ok || match == "" || !validString(match) {
+		return nil, filters.ErrInvalidFilterParameters
+	}
+
+	header := "match=" + quote(match)
+	if len(args) == 2 {
+		id, ok := args[1].(string)
+		if !ok || id == "" || len(id) > 1024 || !validString(id) {
+			return nil, filters.ErrInvalidFilterParameters
+		}
+
+		header += ", id=" + quote(id)
+	}
+
+	return useAsDictionaryFilter{header: header}, nil
+}
+
+func (useAsDictionaryFilter) Request(filters.FilterContext) {}
+
+func (f useAsDictionaryFilter) Response(ctx filters.FilterContext) {
+	if ctx.Response().StatusCode == http.StatusOK {
+		ctx.Response().Header.Set("Use-As-Dictionary", f.header)
+	}
+}
+
+// NewCompress creates the dictionaryCompress filter specification.
+func NewCompress() filters.Spec {
+	return &compressSpec{cache: make(map[string]cachedDictionary)}
+}
+
+func (*compressSpec) Name() string { return filters.DictionaryCompressName }
+
+// the dictionaries are shared between the routes, and they are loaded
+// again only when the file changes
+func (s *compressSpec) loadDictionary(path string) (*dictionary, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if c, ok := s.cache[path]; ok && c.modTime.Equal(info.ModTime()) {
+		return c.dictionary, nil
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(content) == 0 {
+		return nil, fmt.Errorf("%s: empty dictionary", path)
+	}
+
+	hash := sha256.Sum256(content)
+	d := &dictionary{
+		hash:      hash[:],
+		available: ":" + base64.StdEncoding.EncodeToString(hash[:]) + ":",
+		zstd:      newZstdDictionary(content),
+	}
+
+	s.cache[path] = cachedDictionary{modTime: info.ModTime(), dictionary: d}
+	return d, nil
+}
+
+func (s *compressSpec) CreateFilter(args []interface{}) (filters.Filter, error) {
+	if len(args) == 0 || len(args) > 2 {
+		return nil, filters.ErrInvalidFilterParameters
+	}
+
+	path, ok := args[0].(string)
+	if !ok || path == "" {
+		return nil, filters.ErrInvalidFilterParameters
+	}
+
+	f := &compressFilter{}
+	if len(args) == 2 {
+		link, ok := args[1].(string)
+		if !ok || link == "" || strings.ContainsAny(link, "<> \t\r\n") {
+			return nil, filters.ErrInvalidFilterParameters
+		}
+
+		f.link = link
+	}
+
+	d, err := s.loadDictionary(path)
+	if err != nil {
+		return nil, err
+	}
+
+	f.dictionary = d
+	return f, nil
+}
+
+func acceptsDCZ(r *http.Request) bool {
+	for _, s := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		name, params, _ := strings.Cut(s, ";")
+		if !strings.EqualFold(strings.TrimSpace(name), dczEncoding) {
+			continue
+		}
+
+		params = strings.ToLower(strings.TrimSpace(params))
+		if !strings.HasPrefix(params, "q=") {
+			return true
+		}
+
+		q, err := strconv.ParseFloat(params[2:], 32)
+		return err == nil && q > 0
+	}
+
+	return false
+}
+
+// the negotiation is evaluated on the incoming request, so that the
+// following filters can drop the Accept-Encoding header, e.g. to prevent
+// the backend from compressing the response
+func (f *compressFilter) Request(ctx filters.FilterContext) {
+	req := ctx.Request()
+	if acceptsDCZ(req) && strings.TrimSpace(req.Header.Get("Available-Dictionary")) == f.dictionary.available {
+		ctx.StateBag()[stateBagKey] = true
+	}
+}
+
+func addVary(h http.Header, name string) {
+	for _, v := range h.Values("Vary") {
+		for _, vi := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(vi), name) {
+				return
+			}
+		}
+	}
+
+	h.Add("Vary", name)
+}
+
+func canEncode(rsp *http.Response) bool {
+	if rsp.StatusCode != http.StatusOK {
+		return false
+	}
+
+	if ce := rsp.Header.Get("Content-Encoding"); ce != "" && ce != "identity" {
+		return false
+	}
+
+	for _, cc := range strings.Split(rsp.Header.Get("Cache-Control"), ",") {
+		if strings.EqualFold(strings.TrimSpace(cc), "no-transform") {
+			return false
+		}
+	}
+
+	ct, _, _ := strings.Cut(rsp.Header.Get("Content-Type"), ";")
+	ct = strings.ToLower(strings.TrimSpace(ct))
+	for _, m := range compressMIME {
+		if ct == m {
+			return true
+		}
+	}
+
+	return false
+}
+
+func encode(out *io.PipeWriter, in io.ReadCloser, d *dictionary) {
+	var err error
+	defer func() {
+		if err == nil {
+			err = io.EOF
+		}
+
+		out.CloseWithError(err)
+		in.Close()
+	}()
+
+	if _, err = out.Write(dczHeader); err != nil {
+		return
+	}
+
+	if _, err = out.Write(d.hash); err != nil {
+		return
+	}
+
+	z, err := d.zstd.writer(out)
+	if err != nil {
+		log.Errorf("Error creating dictionary compressor: %v", err)
+		return
+	}
+
+	b := make([]byte, bufferSize)
+	for {
+		n, rerr := in.Read(b)
+		if n > 0 {
+			if _, err = z.Write(b[:n]); err != nil {
+				log.Errorf("Error writing dictionary compressed response: %v", err)
+				return
+			}
+
+			if err = z.Flush(); err != nil {
+				log.Errorf("Error flushing dictionary compressed response: %v", err)
+				return
+			}
+		}
+
+		if rerr == io.EOF {
+			if err = z.Close(); err == nil {
+				d.zstd.release(z)
+			}
+
+			return
+		}
+
+		if rerr != nil {
+			err = rerr
+			return
+		}
+	}
+}
+
+func (f *compressFilter) Response(ctx filters.FilterContext) {
+	rsp := ctx.Response()
+	if !canEncode(rsp) {
+		return
+	}
+
+	addVary(rsp.Header, "Accept-Encoding")
+	addVary(rsp.Header, "Available-Dictionary")
+	if f.link != "" {
+		rsp.Header.Add("Link", "<"+f.link+`>; rel="compression-dictionary"`)
+	}
+
+	if negotiated, _ := ctx.StateBag()[stateBagKey].(bool); !negotiated {
+		return
+	}
+
+	rsp.Header.Del("Content-Length")
+	rsp.Header.Set("Content-Encoding", dczEncoding)
+	rsp.ContentLength = -1
+
+	in := rsp.Body
+	r, w := io.Pipe()
+	rsp.Body = r
+	go encode(w, in, f.dictionary)
+}
@@ -0,0 +1,220 @@
+package dictionary
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/zalando/skipper/filters/filtertest"
+)
+
+func TestUseAsDictionary(t *testing.T) {
+	for _, tt := range []struct {
+		args   []interface{}
+		header string
+		err    bool
+	}{
+		{err: true},
+		{args: []interface{}{""}, err: true},
+		{args: []interface{}{42}, err: true},
+		{args: []interface{}{"/api/*\n"}, err: true},
+		{args: []interface{}{"/api/*", "v1", "foo"}, err: true},
+		{args: []interface{}{"/api/*"}, header: `match="/api/*"`},
+		{args: []interface{}{"/api/*", `users "v1"`}, header: `match="/api/*", id="users \"v1\""`},
+	} {
+		f, err := NewUseAsDictionary().CreateFilter(tt.args)
+		if tt.err {
+			if err == nil {
+				t.Errorf("%v: failed to fail", tt.args)
+			}
+
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("%v: %v", tt.args, err)
+			continue
+		}
+
+		rsp := &http.Response{StatusCode: http.StatusOK, Header: make(http.Header)}
+		f.Response(&filtertest.Context{FResponse: rsp})
+		if h := rsp.Header.Get("Use-As-Dictionary"); h != tt.header {
+			t.Errorf("%v: unexpected header: %s, expected: %s", tt.args, h, tt.header)
+		}
+	}
+}
+
+func TestAcceptsDCZ(t *testing.T) {
+	for header, expected := range map[string]bool{
+		"":                   false,
+		"gzip, br":           false,
+		"gzip, br, dcz":      true,
+		"gzip, DCZ;q=0.5":    true,
+		"dcz; q=0":           false,
+		"dcz;q=0.000, gzip":  false,
+		"dcz;q=foo":          false,
+		"gzip;q=1, dczx;q=1": false,
+	} {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set("Accept-Encoding", header)
+		if acceptsDCZ(req) != expected {
+			t.Errorf("%q: expected: %t", header, expected)
+		}
+	}
+}
+
+func writeDictionary(t *testing.T, content []byte) string {
+	path := filepath.Join(t.TempDir(), "dictionary.json")
+	if err := os.WriteFile(path, content, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+func TestCreateCompress(t *testing.T) {
+	path := writeDictionary(t, []byte(`{"foo": "bar"}`))
+	for _, tt := range []struct {
+		args []interface{}
+		err  bool
+	}{
+		{err: true},
+		{args: []interface{}{""}, err: true},
+		{args: []interface{}{"/does/not/exist"}, err: true},
+		{args: []interface{}{writeDictionary(t, nil)}, err: true},
+		{args: []interface{}{path, "<foo>"}, err: true},
+		{args: []interface{}{path, "/dict.json", "foo"}, err: true},
+		{args: []interface{}{path}},
+		{args: []interface{}{path, "/dict.json"}},
+	} {
+		_, err := NewCompress().CreateFilter(tt.args)
+		if tt.err && err == nil {
+			t.Errorf("%v: failed to fail", tt.args)
+		} else if !tt.err && err != nil {
+			t.Errorf("%v: %v", tt.args, err)
+		}
+	}
+}
+
+func TestDictionaryCache(t *testing.T) {
+	path := writeDictionary(t, []byte(`{"foo": "bar"}`))
+	spec := NewCompress()
+	f1, _ := spec.CreateFilter([]interface{}{path})
+	f2, _ := spec.CreateFilter([]interface{}{path})
+	if f1.(*compressFilter).dictionary != f2.(*compressFilter).dictionary {
+		t.Error("failed to share the dictionary")
+	}
+}
+
+func TestCompress(t *testing.T) {
+	r := rand.New(rand.NewSource(42))
+	dictionary := []byte(testJSON(r, 256))
+	hash := sha256.Sum256(dictionary)
+	available := ":" + base64.StdEncoding.EncodeToString(hash[:]) + ":"
+	body := testJSON(r, 128)
+
+	f, err := NewCompress().CreateFilter([]interface{}{writeDictionary(t, dictionary), "/dict.json"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		name           string
+		acceptEncoding string
+		available      string
+		status         int
+		contentType    string
+		encoding       string
+		compressed     bool
+	}{
+		{name: "compressed", acceptEncoding: "gzip, br, dcz", available: available, compressed: true},
+		{name: "not accepted", acceptEncoding: "gzip, br", available: available},
+		{name: "no dictionary", acceptEncoding: "gzip, br, dcz"},
+		{name: "different dictionary", acceptEncoding: "dcz", available: ":" + base64.StdEncoding.EncodeToString(make([]byte, 32)) + ":"},
+		{name: "not compressible", acceptEncoding: "dcz", available: available, contentType: "image/png"},
+		{name: "already encoded", acceptEncoding: "dcz", available: available, encoding: "gzip"},
+		{name: "not ok", acceptEncoding: "dcz", available: available, status: http.StatusNotFound},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/api/users", nil)
+			req.Header.Set("Accept-Encoding", tt.acceptEncoding)
+			if tt.available != "" {
+				req.Header.Set("Available-Dictionary", tt.available)
+			}
+
+			status := http.StatusOK
+			if tt.status != 0 {
+				status = tt.status
+			}
+
+			contentType := "application/json; charset=utf-8"
+			if tt.contentType != "" {
+				contentType = tt.contentType
+			}
+
+			rsp := &http.Response{
+				StatusCode: status,
+				Header:     http.Header{"Content-Type": []string{contentType}},
+				Body:       io.NopCloser(strings.NewReader(body)),
+			}
+
+			if tt.encoding != "" {
+				rsp.Header.Set("Content-Encoding", tt.encoding)
+			}
+
+			ctx := &filtertest.Context{FRequest: req, FResponse: rsp, FStateBag: make(map[string]interface{})}
+			f.Request(ctx)
+			f.Response(ctx)
+
+			b, err := io.ReadAll(rsp.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !tt.compressed {
+				if rsp.Header.Get("Content-Encoding") == dczEncoding || string(b) != body {
+					t.Error("unexpected compression")
+				}
+
+				return
+			}
+
+			if rsp.Header.Get("Content-Encoding") != dczEncoding {
+				t.Errorf("unexpected encoding: %s", rsp.Header.Get("Content-Encoding"))
+			}
+
+			if vary := rsp.Header.Values("Vary"); len(vary) != 2 || vary[0] != "Accept-Encoding" || vary[1] != "Available-Dictionary" {
+				t.Errorf("unexpected vary header: %v", vary)
+			}
+
+			if link := rsp.Header.Get("Link"); link != `</dict.json>; rel="compression-dictionary"` {
+				t.Errorf("unexpected link header: %s", link)
+			}
+
+			if !bytes.HasPrefix(b, dczHeader) || !bytes.Equal(b[len(dczHeader):len(dczHeader)+32], hash[:]) {
+				t.Fatal("invalid dcz header")
+			}
+
+			decoded, err := decodeZstd(b[len(dczHeader)+32:], dictionary)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if string(decoded) != body {
+				t.Error("failed to decode the original body")
+			}
+
+			if len(b) >= len(body)/2 {
+				t.Errorf("insufficient compression: %d, original: %d", len(b), len(body))
+			}
+		})
+	}
+}
@@ -0,0 +1,50 @@
+package dictionary
+
+import (
+	"io"
+	"sync"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// RFC 9842 4, the clients need to support at least 8MB window size for the
+// dcz encoding
+const zstdWindowSize = 8 << 20
+
+// zstdDictionary pools the Zstandard encoders using the same raw content
+// dictionary, because preparing the dictionary is expensive compared to
+// compressing a typical response.
+type zstdDictionary struct {
+	options []zstd.EOption
+	pool    sync.Pool
+}
+
+func newZstdDictionary(content []byte) *zstdDictionary {
+	options := []zstd.EOption{
+		zstd.WithEncoderConcurrency(1),
+		zstd.WithWindowSize(zstdWindowSize),
+	}
+
+	if len(content) > 0 {
+		// the dcz frames don't contain a dictionary id
+		options = append(options, zstd.WithEncoderDictRaw(0, content))
+	}
+
+	return &zstdDictionary{options: options}
+}
+
+// returns an encoder writing a single frame to w
+func (d *zstdDictionary) writer(w io.Writer) (*zstd.Encoder, error) {
+	if e, ok := d.pool.Get().(*zstd.Encoder); ok {
+		e.Reset(w)
+		return e, nil
+	}
+
+	return zstd.NewWriter(w, d.options...)
+}
+
+// returns a closed encoder to the pool
+func (d *zstdDictionary) release(e *zstd.Encoder) {
+	e.Reset(nil)
+	d.pool.Put(e)
+}
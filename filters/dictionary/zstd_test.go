@@ -0,0 +1,187 @@
+package dictionary
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+func decodeZstd(frame, dictionary []byte) ([]byte, error) {
+	var options []zstd.DOption
+	if len(dictionary) > 0 {
+		options = append(options, zstd.WithDecoderDictRaw(0, dictionary))
+	}
+
+	d, err := zstd.NewReader(nil, options...)
+	if err != nil {
+		return nil, err
+	}
+
+	defer d.Close()
+	return d.DecodeAll(frame, nil)
+}
+
+func compressZstd(t *testing.T, d *zstdDictionary, content []byte, chunk int) []byte {
+	var buf bytes.Buffer
+	w, err := d.writer(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for chunk > 0 && len(content) > chunk {
+		if _, err := w.Write(content[:chunk]); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := w.Flush(); err != nil {
+			t.Fatal(err)
+		}
+
+		content = content[chunk:]
+	}
+
+	if _, err := w.Write(content); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	d.release(w)
+	return buf.Bytes()
+}
+
+func testJSON(r *rand.Rand, n int) string {
+	var b strings.Builder
+	b.WriteString("[")
+	for i := 0; i < n; i++ {
+		if i > 0 {
+			b.WriteString(",")
+		}
+
+		fmt.Fprintf(&b, `{"id":%d,"name":"user-%d","active":%t,"score":%d}`, r.Intn(1000000), r.Intn(1000), r.Intn(2) == 0, r.Intn(100))
+	}
+
+	b.WriteString("]")
+	return b.String()
+}
+
+func TestZstdRoundTrip(t *testing.T) {
+	r := rand.New(rand.NewSource(42))
+	dictionary := []byte(testJSON(r, 64))
+
+	random := make([]byte, 300<<10)
+	r.Read(random)
+
+	for _, tt := range []struct {
+		name       string
+		dictionary []byte
+		content    []byte
+		chunk      int
+		smaller    bool
+	}{
+		{name: "empty", dictionary: dictionary},
+		{name: "short", dictionary: dictionary, content: []byte("[]")},
+		{name: "json", dictionary: dictionary, content: []byte(testJSON(r, 16)), smaller: true},
+		{name: "no dictionary", content: []byte(testJSON(r, 16)), smaller: true},
+		{name: "multiple blocks", dictionary: dictionary, content: []byte(testJSON(r, 8000)), smaller: true},
+		{name: "flushed chunks", dictionary: dictionary, content: []byte(testJSON(r, 1000)), chunk: 1000, smaller: true},
+		{name: "incompressible", dictionary: dictionary, content: random},
+		{name: "long repetition", content: bytes.Repeat([]byte("a"), 200<<10), smaller: true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			d := newZstdDictionary(tt.dictionary)
+
+			// the second round uses the pooled encoder
+			for i := 0; i < 2; i++ {
+				b := compressZstd(t, d, tt.content, tt.chunk)
+				if tt.smaller && len(b) >= len(tt.content) {
+					t.Errorf("failed to compress: %d, original: %d", len(b), len(tt.content))
+				}
+
+				decoded, err := decodeZstd(b, tt.dictionary)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				if !bytes.Equal(decoded, tt.content) {
+					t.Error("failed to decode the original content")
+				}
+			}
+		})
+	}
+}
+
+func TestZstdDictionaryImprovesCompression(t *testing.T) {
+	r := rand.New(rand.NewSource(42))
+	dictionary := []byte(testJSON(r, 256))
+	content := []byte(testJSON(r, 4))
+
+	with := compressZstd(t, newZstdDictionary(dictionary), content, 0)
+	without := compressZstd(t, newZstdDictionary(nil), content, 0)
+	if len(with) >= len(without) {
+		t.Errorf("failed to improve compression with the dictionary: %d, without: %d", len(with), len(without))
+	}
+}
+
+func readTestData(t *testing.T, name string) []byte {
+	b, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return b
+}
+
+// testdata/users.json.zst was created by the reference implementation:
+//
+//	zstd -D users-dictionary.json users.json -o users.json.zst
+func TestZstdReferenceVector(t *testing.T) {
+	dictionary := readTestData(t, "users-dictionary.json")
+	content := readTestData(t, "users.json")
+
+	decoded, err := decodeZstd(readTestData(t, "users.json.zst"), dictionary)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(decoded, content) {
+		t.Error("failed to decode the reference vector")
+	}
+
+	b := compressZstd(t, newZstdDictionary(dictionary), content, 0)
+	if len(b) > 2*len(readTestData(t, "users.json.zst")) {
+		t.Errorf("insufficient compression compared to the reference: %d", len(b))
+	}
+}
+
+// checks the compressed content with the reference implementation, when
+// it is installed
+func TestZstdReferenceDecoder(t *testing.T) {
+	if _, err := exec.LookPath("zstd"); err != nil {
+		t.Skip("zstd not found")
+	}
+
+	dictionaryFile := filepath.Join("testdata", "users-dictionary.json")
+	content := readTestData(t, "users.json")
+	b := compressZstd(t, newZstdDictionary(readTestData(t, "users-dictionary.json")), content, 512)
+
+	cmd := exec.Command("zstd", "-d", "-c", "-D", dictionaryFile)
+	cmd.Stdin = bytes.NewReader(b)
+	decoded, err := cmd.Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(decoded, content) {
+		t.Error("the reference implementation failed to decode the original content")
+	}
+}
@@ -200,6 +200,11 @@ func NewPostProcessor() routing.PostProcessor {
 	}
 }
 
+// InspectedFilters implements routing.FilterInspector.
+func (*postProcessor) InspectedFilters() []string {
+	return []string{filters.FadeInName, filters.EndpointCreatedName}
+}
+
 func (p *postProcessor) Do(r []*routing.Route) []*routing.Route {
 	const configErrFmt = "Error while processing endpoint fade-in settings: %s, %s, %v."
 	now := time.Now()
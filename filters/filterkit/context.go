@@ -0,0 +1,252 @@
+package filterkit
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	"github.com/opentracing/opentracing-go"
+
+	"github.com/zalando/skipper/filters"
+	"github.com/zalando/skipper/metrics/metricstest"
+	"github.com/zalando/skipper/tracing/tracingtest"
+)
+
+// Phase tells which filter methods are executed.
+type Phase int
+
+const (
+	// PhaseNone is the phase before and after running the filters.
+	PhaseNone Phase = iota
+
+	// PhaseRequest is the phase of the request filters.
+	PhaseRequest
+
+	// PhaseResponse is the phase of the response filters.
+	PhaseResponse
+)
+
+// Backend creates the response of the backend. When it returns an
+// error, Run responds with 502 Bad Gateway, like the proxy.
+type Backend func(*http.Request) (*http.Response, error)
+
+// Context implements filters.FilterContext, and records the calls of the
+// filters. It can be used directly with the filter methods, or executed
+// with Run. The exported fields can be set before running the filters.
+type Context struct {
+
+	// Params contains the path parameters returned by PathParam.
+	Params map[string]string
+
+	// BackendURL is returned by BackendUrl.
+	BackendURL string
+
+	// Backend, when set, is called by Run when the request was not
+	// served by the filters. Defaults to an empty 200 OK response.
+	Backend Backend
+
+	// OnLoopback, when set, is called by Loopback. It can set the
+	// response of the loopback request.
+	OnLoopback func(*Context)
+
+	// FMetrics records the custom metrics of the filters. When executed
+	// with Run, the keys are prefixed with the filter name, as in the
+	// proxy.
+	FMetrics *metricstest.MockMetrics
+
+	// FTracer records the spans finished by the filters.
+	FTracer *tracingtest.Tracer
+
+	// Recorder is returned by the deprecated ResponseWriter method.
+	Recorder *httptest.ResponseRecorder
+
+	// Loopbacks counts the calls of Loopback.
+	Loopbacks int
+
+	// Splits contains the contexts created by Split.
+	Splits []*Context
+
+	// Panics contains the panics of the filters recovered by Run.
+	Panics []interface{}
+
+	request          *http.Request
+	response         *http.Response
+	originalRequest  *http.Request
+	originalResponse *http.Response
+	outgoingHost     string
+	stateBag         map[string]interface{}
+	phase            Phase
+	served           bool
+	servedResponse   *http.Response
+	servedPhase      Phase
+	parentSpan       opentracing.Span
+}
+
+var _ filters.FilterContext = &Context{}
+
+// NewContext creates a context for the request.
+func NewContext(req *http.Request) *Context {
+	return &Context{
+		Params:          make(map[string]string),
+		FMetrics:        &metricstest.MockMetrics{},
+		FTracer:         &tracingtest.Tracer{},
+		Recorder:        httptest.NewRecorder(),
+		request:         req,
+		originalRequest: cloneHeader(req),
+		outgoingHost:    req.Host,
+		stateBag:        make(map[string]interface{}),
+	}
+}
+
+// NewResponse creates a response with a text body.
+func NewResponse(status int, body string) *http.Response {
+	return &http.Response{
+		StatusCode:    status,
+		Header:        make(http.Header),
+		Body:          io.NopCloser(strings.NewReader(body)),
+		ContentLength: int64(len(body)),
+	}
+}
+
+// the deep copy of the request without the body, as the proxy does
+func cloneHeader(req *http.Request) *http.Request {
+	c := req.Clone(req.Context())
+	c.Body = nil
+	c.ContentLength = 0
+	return c
+}
+
+func cloneResponseHeader(rsp *http.Response) *http.Response {
+	c := *rsp
+	c.Header = rsp.Header.Clone()
+	c.Body = nil
+	c.ContentLength = 0
+	return &c
+}
+
+func (c *Context) ResponseWriter() http.ResponseWriter { return c.Recorder }
+func (c *Context) Request() *http.Request              { return c.request }
+func (c *Context) Response() *http.Response            { return c.response }
+func (c *Context) OriginalRequest() *http.Request      { return c.originalRequest }
+func (c *Context) OriginalResponse() *http.Response    { return c.originalResponse }
+func (c *Context) PathParam(key string) string         { return c.Params[key] }
+func (c *Context) StateBag() map[string]interface{}    { return c.stateBag }
+func (c *Context) BackendUrl() string                  { return c.BackendURL }
+func (c *Context) OutgoingHost() string                { return c.outgoingHost }
+func (c *Context) SetOutgoingHost(h string)            { c.outgoingHost = h }
+func (c *Context) Metrics() filters.Metrics            { return c.FMetrics }
+func (c *Context) Tracer() opentracing.Tracer          { return c.FTracer }
+
+// Phase returns the current phase of the context.
+func (c *Context) Phase() Phase { return c.phase }
+
+// SetPhase sets the phase, when the filter methods are called directly.
+func (c *Context) SetPhase(p Phase) { c.phase = p }
+
+// SetResponse sets the response, when the response filters are called
+// directly.
+func (c *Context) SetResponse(rsp *http.Response) {
+	c.response = rsp
+	c.originalResponse = cloneResponseHeader(rsp)
+}
+
+// MarkServed is deprecated, but recorded.
+func (c *Context) MarkServed() {
+	c.served = true
+	if c.servedPhase == PhaseNone {
+		c.servedPhase = c.phase
+	}
+}
+
+// Served tells whether the request was served by a filter.
+func (c *Context) Served() bool { return c.served }
+
+// ServedResponse returns the response passed to Serve, or nil.
+func (c *Context) ServedResponse() *http.Response { return c.servedResponse }
+
+// ServedPhase returns the phase when the request was first served.
+func (c *Context) ServedPhase() Phase { return c.servedPhase }
+
+// Serve records the response, and sets it the same way as the proxy.
+func (c *Context) Serve(rsp *http.Response) {
+	rsp.Request = c.request
+	if rsp.Header == nil {
+		rsp.Header = make(http.Header)
+	}
+
+	if rsp.Body == nil {
+		rsp.Body = http.NoBody
+	}
+
+	c.MarkServed()
+	c.servedResponse = rsp
+	c.response = rsp
+}
+
+// ParentSpan returns the span of the context. It is started on the first
+// call, and finished by Run.
+func (c *Context) ParentSpan() opentracing.Span {
+	if c.parentSpan == nil {
+		c.parentSpan = c.FTracer.StartSpan("ingress")
+	}
+
+	return c.parentSpan
+}
+
+// Loopback counts the calls, and calls OnLoopback when set.
+func (c *Context) Loopback() {
+	c.Loopbacks++
+	if c.OnLoopback != nil {
+		c.OnLoopback(c)
+	}
+}
+
+// Split creates a context with a copy of the request. As in the proxy,
+// the body of the new request receives the data read from the body of
+// the original request, and the state bag is not shared.
+func (c *Context) Split() (filters.FilterContext, error) {
+	r := c.request.Clone(c.request.Context())
+	if c.request.Body != nil && c.request.Body != http.NoBody {
+		s := NewStream()
+		c.request.Body = &teeBody{body: c.request.Body, stream: s}
+		r.Body = s
+	}
+
+	cc := NewContext(r)
+	cc.Params = make(map[string]string, len(c.Params))
+	for k, v := range c.Params {
+		cc.Params[k] = v
+	}
+
+	cc.BackendURL = c.BackendURL
+	cc.outgoingHost = c.outgoingHost
+	cc.FMetrics = c.FMetrics
+	cc.FTracer = c.FTracer
+	cc.phase = c.phase
+	c.Splits = append(c.Splits, cc)
+	return cc, nil
+}
+
+type teeBody struct {
+	body   io.ReadCloser
+	stream *Stream
+}
+
+func (b *teeBody) Read(p []byte) (int, error) {
+	n, err := b.body.Read(p)
+	if n > 0 {
+		b.stream.Write(p[:n])
+	}
+
+	if err != nil {
+		b.stream.CloseWithError(err)
+	}
+
+	return n, err
+}
+
+func (b *teeBody) Close() error {
+	b.stream.Finish()
+	return b.body.Close()
+}
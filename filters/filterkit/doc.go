@@ -0,0 +1,34 @@
+/*
+Package filterkit helps testing filters without starting the proxy.
+
+The Context type implements filters.FilterContext, and records what the
+filters did with it: the response served by the filters and the phase
+when it happened, the custom metrics, the spans, the loopbacks and the
+split contexts. The Run function executes a filter chain the same way as
+the proxy does: the request filters in order, stopping at the first one
+that serves the request, then the backend, unless the request was
+served, and the response filters of the executed filters in reverse
+order.
+
+The filters can be created from an eskip filter chain, with the specs of
+a registry:
+
+	fs, err := filterkit.CreateFilters(registry, `setRequestHeader("X-Foo", "bar") -> myFilter(42)`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := filterkit.NewContext(httptest.NewRequest("GET", "https://www.example.org/foo", nil))
+	ctx.Backend = func(req *http.Request) (*http.Response, error) {
+		return filterkit.NewResponse(http.StatusOK, "Hello, world!"), nil
+	}
+
+	filterkit.Run(ctx, fs)
+	if ctx.Served() {
+		t.Error("unexpected serve")
+	}
+
+The Stream type can be used as a request or response body delivered in
+chunks, to test filters processing the body while it is streamed.
+*/
+package filterkit
@@ -0,0 +1,221 @@
+package filterkit
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/zalando/skipper/filters"
+	"github.com/zalando/skipper/filters/builtin"
+)
+
+type testFilter struct {
+	panic bool
+}
+
+func (f *testFilter) Name() string { return "testFilter" }
+
+func (f *testFilter) CreateFilter(args []interface{}) (filters.Filter, error) {
+	return &testFilter{panic: len(args) > 0 && args[0] == "panic"}, nil
+}
+
+func (f *testFilter) Request(ctx filters.FilterContext) {
+	if f.panic {
+		panic("test panic")
+	}
+
+	ctx.Metrics().IncCounter("requests")
+	span := ctx.Tracer().StartSpan("test")
+	span.SetTag("host", ctx.OutgoingHost())
+	span.Finish()
+}
+
+func (f *testFilter) Response(ctx filters.FilterContext) {
+	ctx.Response().Header.Set("X-Test", ctx.PathParam("id"))
+}
+
+func createFilters(t *testing.T, chain string) []Filter {
+	r := builtin.MakeRegistry()
+	r.Register(&testFilter{})
+	fs, err := CreateFilters(r, chain)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return fs
+}
+
+func TestRun(t *testing.T) {
+	fs := createFilters(t, `setRequestHeader("X-Foo", "bar") -> testFilter() -> setResponseHeader("X-Bar", "baz")`)
+	ctx := NewContext(httptest.NewRequest("GET", "https://www.example.org/items/42", nil))
+	ctx.Params["id"] = "42"
+	ctx.Backend = func(req *http.Request) (*http.Response, error) {
+		if req.Header.Get("X-Foo") != "bar" {
+			t.Error("request filter not applied")
+		}
+
+		return NewResponse(http.StatusTeapot, "Hello, world!"), nil
+	}
+
+	Run(ctx, fs)
+	if ctx.Served() || ctx.Response().StatusCode != http.StatusTeapot {
+		t.Fatalf("unexpected response: %v, %v", ctx.Served(), ctx.Response())
+	}
+
+	if ctx.Response().Header.Get("X-Test") != "42" || ctx.Response().Header.Get("X-Bar") != "baz" {
+		t.Errorf("response filters not applied: %v", ctx.Response().Header)
+	}
+
+	if ctx.OriginalResponse().Header.Get("X-Bar") != "" || ctx.OriginalRequest().Header.Get("X-Foo") != "" {
+		t.Error("original request or response modified")
+	}
+
+	ctx.FMetrics.WithCounters(func(c map[string]int64) {
+		if c["testFilter.custom.requests"] != 1 {
+			t.Errorf("unexpected counters: %v", c)
+		}
+	})
+
+	span, ok := ctx.FTracer.FindSpan("test")
+	if !ok || span.Tags["host"] != "www.example.org" {
+		t.Errorf("span not recorded: %v", span)
+	}
+
+	if len(ctx.Panics) != 0 || ctx.Phase() != PhaseNone {
+		t.Error("unexpected phase")
+	}
+}
+
+func TestRunServed(t *testing.T) {
+	fs := createFilters(t, `setResponseHeader("X-Foo", "bar") -> status(403) -> inlineContent("denied") -> setResponseHeader("X-Bar", "baz")`)
+	ctx := NewContext(httptest.NewRequest("GET", "https://www.example.org", nil))
+	ctx.Backend = func(*http.Request) (*http.Response, error) {
+		t.Error("unexpected backend call")
+		return nil, nil
+	}
+
+	Run(ctx, fs)
+	if !ctx.Served() || ctx.ServedPhase() != PhaseRequest || ctx.ServedResponse() == nil {
+		t.Fatal("failed to serve")
+	}
+
+	b, err := io.ReadAll(ctx.Response().Body)
+	if err != nil || string(b) != "denied" {
+		t.Errorf("unexpected body: %q, %v", b, err)
+	}
+
+	if ctx.Response().Header.Get("X-Foo") != "bar" || ctx.Response().Header.Get("X-Bar") != "" {
+		t.Errorf("unexpected response filters: %v", ctx.Response().Header)
+	}
+}
+
+func TestRunBackendError(t *testing.T) {
+	ctx := NewContext(httptest.NewRequest("GET", "https://www.example.org", nil))
+	ctx.Backend = func(*http.Request) (*http.Response, error) { return nil, io.ErrUnexpectedEOF }
+	Run(ctx, nil)
+	if ctx.Response().StatusCode != http.StatusBadGateway {
+		t.Errorf("unexpected status: %d", ctx.Response().StatusCode)
+	}
+}
+
+func TestRunPanic(t *testing.T) {
+	fs := createFilters(t, `testFilter("panic") -> setRequestHeader("X-Foo", "bar")`)
+	ctx := NewContext(httptest.NewRequest("GET", "https://www.example.org", nil))
+	Run(ctx, fs)
+	if len(ctx.Panics) != 1 || ctx.Request().Header.Get("X-Foo") != "bar" {
+		t.Errorf("unexpected panics: %v", ctx.Panics)
+	}
+}
+
+func TestCreateFiltersInvalid(t *testing.T) {
+	for _, chain := range []string{`noSuchFilter()`, `setPath()`, `->`} {
+		if _, err := CreateFilters(builtin.MakeRegistry(), chain); err == nil {
+			t.Errorf("failed to fail: %s", chain)
+		}
+	}
+}
+
+func TestStreamingBody(t *testing.T) {
+	fs := createFilters(t, `sed("foo", "bar")`)
+	body := NewStream("foo ")
+	ctx := NewContext(httptest.NewRequest("GET", "https://www.example.org", nil))
+	ctx.Backend = func(*http.Request) (*http.Response, error) {
+		return &http.Response{StatusCode: http.StatusOK, Body: body}, nil
+	}
+
+	Run(ctx, fs)
+
+	done := make(chan string)
+	go func() {
+		b, err := io.ReadAll(ctx.Response().Body)
+		if err != nil {
+			t.Error(err)
+		}
+
+		done <- string(b)
+	}()
+
+	select {
+	case <-done:
+		t.Fatal("body finished before the stream")
+	case <-time.After(30 * time.Millisecond):
+	}
+
+	body.Write([]byte("foo"))
+	body.Finish()
+	if b := <-done; b != "bar bar" {
+		t.Errorf("unexpected body: %q", b)
+	}
+}
+
+func TestStreamClose(t *testing.T) {
+	s := NewStream("foo", "bar")
+	p := make([]byte, 8)
+	if n, err := s.Read(p); err != nil || string(p[:n]) != "foo" {
+		t.Errorf("unexpected chunk: %q, %v", p[:n], err)
+	}
+
+	s.Close()
+	if _, err := s.Read(p); err == nil {
+		t.Error("failed to close the stream")
+	}
+}
+
+func TestSplit(t *testing.T) {
+	ctx := NewContext(httptest.NewRequest("POST", "https://www.example.org", NewStream("foo", "bar").finished()))
+	ctx.StateBag()["foo"] = "bar"
+	sc, err := ctx.Split()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(ctx.Splits) != 1 || len(sc.StateBag()) != 0 {
+		t.Fatal("unexpected split context")
+	}
+
+	b, err := io.ReadAll(ctx.Request().Body)
+	if err != nil || string(b) != "foobar" {
+		t.Fatalf("unexpected body: %q, %v", b, err)
+	}
+
+	b, err = io.ReadAll(sc.Request().Body)
+	if err != nil || string(b) != "foobar" {
+		t.Errorf("unexpected split body: %q, %v", b, err)
+	}
+}
+
+func TestLoopback(t *testing.T) {
+	ctx := NewContext(httptest.NewRequest("GET", "https://www.example.org", nil))
+	ctx.OnLoopback = func(c *Context) { c.Serve(NewResponse(http.StatusNoContent, "")) }
+	ctx.Loopback()
+	if ctx.Loopbacks != 1 || ctx.Response().StatusCode != http.StatusNoContent {
+		t.Error("failed to record the loopback")
+	}
+}
+
+func (s *Stream) finished() *Stream {
+	s.Finish()
+	return s
+}
@@ -0,0 +1,123 @@
+package filterkit
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/zalando/skipper/eskip"
+	"github.com/zalando/skipper/filters"
+)
+
+// Filter is a filter instance with the name of its spec. The name is
+// used as the prefix of the custom metrics.
+type Filter struct {
+	Name string
+	filters.Filter
+}
+
+// CreateFilters creates the filters of an eskip filter chain, e.g.
+// `setPath("/foo") -> myFilter(42)`, with the specs in the registry.
+func CreateFilters(r filters.Registry, chain string) ([]Filter, error) {
+	defs, err := eskip.ParseFilters(chain)
+	if err != nil {
+		return nil, err
+	}
+
+	var fs []Filter
+	for _, d := range defs {
+		spec, ok := r[d.Name]
+		if !ok {
+			return nil, fmt.Errorf("filter not found: %s", d.Name)
+		}
+
+		f, err := spec.CreateFilter(d.Args)
+		if err != nil {
+			return nil, err
+		}
+
+		fs = append(fs, Filter{Name: d.Name, Filter: f})
+	}
+
+	return fs, nil
+}
+
+func (c *Context) call(name string, f func()) {
+	c.FMetrics.Prefix = name + ".custom."
+	defer func() {
+		c.FMetrics.Prefix = ""
+		if err := recover(); err != nil {
+			c.Panics = append(c.Panics, err)
+		}
+	}()
+
+	f()
+}
+
+// RunRequest executes the request filters, until one of them serves the
+// request, and returns the executed filters.
+func RunRequest(c *Context, fs []Filter) []Filter {
+	c.phase = PhaseRequest
+	defer func() { c.phase = PhaseNone }()
+
+	for i, f := range fs {
+		c.call(f.Name, func() { f.Request(c) })
+		if c.served {
+			return fs[:i+1]
+		}
+	}
+
+	return fs
+}
+
+// RunResponse executes the response filters in reverse order.
+func RunResponse(c *Context, fs []Filter) {
+	c.phase = PhaseResponse
+	defer func() { c.phase = PhaseNone }()
+
+	for i := len(fs) - 1; i >= 0; i-- {
+		f := fs[i]
+		c.call(f.Name, func() { f.Response(c) })
+	}
+}
+
+func defaultBackend(*http.Request) (*http.Response, error) {
+	return NewResponse(http.StatusOK, ""), nil
+}
+
+// Run executes the filters the same way as the proxy: the request
+// filters, the backend, unless the request was served, and the response
+// filters of the executed filters. It finishes the parent span, when it
+// was started by the filters.
+func Run(c *Context, fs []Filter) {
+	executed := RunRequest(c, fs)
+	if !c.served {
+		b := c.Backend
+		if b == nil {
+			b = defaultBackend
+		}
+
+		rsp, err := b(c.request)
+		if err == nil && rsp == nil {
+			err = errors.New("backend returned no response")
+		}
+
+		if err != nil {
+			rsp = NewResponse(http.StatusBadGateway, "")
+		}
+
+		if rsp.Header == nil {
+			rsp.Header = make(http.Header)
+		}
+
+		rsp.Request = c.request
+		c.SetResponse(rsp)
+	} else if c.response != nil {
+		c.originalResponse = cloneResponseHeader(c.response)
+	}
+
+	RunResponse(c, executed)
+	if c.parentSpan != nil {
+		c.parentSpan.Finish()
+	}
+}
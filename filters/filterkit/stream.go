@@ -0,0 +1,107 @@
+package filterkit
+
+import (
+	"errors"
+	"io"
+	"sync"
+)
+
+var errStreamClosed = errors.New("stream closed")
+
+// Stream is a body whose content is written by the test while it is
+// read by the filters. Every write is returned by separate reads, and
+// Read blocks until there is data available or the stream is finished.
+// Writing never blocks. Stream is safe for concurrent use.
+type Stream struct {
+	mu     sync.Mutex
+	cond   *sync.Cond
+	chunks [][]byte
+	err    error
+	closed bool
+}
+
+// NewStream creates a stream, initialized with the chunks.
+func NewStream(chunks ...string) *Stream {
+	s := &Stream{}
+	s.cond = sync.NewCond(&s.mu)
+	for _, c := range chunks {
+		s.chunks = append(s.chunks, []byte(c))
+	}
+
+	return s
+}
+
+// Write appends a chunk to the stream.
+func (s *Stream) Write(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err != nil {
+		return 0, errStreamClosed
+	}
+
+	s.chunks = append(s.chunks, append([]byte(nil), p...))
+	s.cond.Broadcast()
+	return len(p), nil
+}
+
+// CloseWithError finishes the stream. Once the written chunks are read,
+// Read returns the error, or io.EOF when the error is nil.
+func (s *Stream) CloseWithError(err error) error {
+	if err == nil {
+		err = io.EOF
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err == nil {
+		s.err = err
+	}
+
+	s.cond.Broadcast()
+	return nil
+}
+
+// Finish finishes the stream, and the reader receives io.EOF after the
+// written chunks.
+func (s *Stream) Finish() error {
+	return s.CloseWithError(nil)
+}
+
+// Read reads from the next chunk, or blocks until a chunk is written or
+// the stream is finished.
+func (s *Stream) Read(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for {
+		if s.closed {
+			return 0, errStreamClosed
+		}
+
+		if len(s.chunks) > 0 {
+			n := copy(p, s.chunks[0])
+			if n == len(s.chunks[0]) {
+				s.chunks = s.chunks[1:]
+			} else {
+				s.chunks[0] = s.chunks[0][n:]
+			}
+
+			return n, nil
+		}
+
+		if s.err != nil {
+			return 0, s.err
+		}
+
+		s.cond.Wait()
+	}
+}
+
+// Close closes the reading side of the stream. The blocked and the
+// subsequent reads return an error.
+func (s *Stream) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closed = true
+	s.cond.Broadcast()
+	return nil
+}
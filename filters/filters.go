@@ -28,6 +28,98 @@ const (
 
 	// BackendRatelimit is the key used in the state bag to configure backend ratelimit in proxy
 	BackendRatelimit = "backend:ratelimit"
+
+	// FastCgiParams is the key used in the state bag to configure additional
+	// FastCGI params in proxy
+	FastCgiParams = "backend:fastcgi:params"
+
+	// BackendHeaderCasingKey is the key used in the state bag to pass the
+	// forced casing of the request header names to the proxy
+	BackendHeaderCasingKey = "backend:header:casing"
+
+	// BackendCloseConnectionKey is the key used in the state bag to notify
+	// the proxy to close the backend connection after the request
+	BackendCloseConnectionKey = "backend:close"
+
+	// BackendResolverKey is the key used in the state bag to pass the
+	// address of the DNS server resolving the backend host to the proxy
+	BackendResolverKey = "backend:resolver"
+
+	// RetryOnResponseKey is the key used in the state bag to pass a
+	// func(*http.Response, func(string) bool) (string, bool) to the
+	// proxy, that decides whether the backend request is retried based
+	// on the response, and optionally returns the URL of the backend to
+	// retry against. The second argument of the function tells whether
+	// a scheme://host address is a backend of the route
+	RetryOnResponseKey = "backend:retry:response"
+
+	// HedgeDelayKey is the key used in the state bag to pass the delay
+	// after which the proxy sends a hedged copy of the backend request
+	// to another endpoint of a load balanced backend
+	HedgeDelayKey = "backend:hedge:delay"
+
+	// RetryPolicyKey is the key used in the state bag to pass the
+	// *retry.Policy of the route to the proxy
+	RetryPolicyKey = "backend:retry:policy"
+
+	// BackendProxyProtocolKey is the key used in the state bag to tell
+	// the proxy to send a PROXY protocol v2 header on the backend
+	// connection
+	BackendProxyProtocolKey = "backend:proxyprotocol"
+
+	// BackendH2CKey is the key used in the state bag to tell the proxy
+	// to send the backend request over HTTP/2
+	BackendH2CKey = "backend:h2c"
+
+	// BackendTransportKey is the key used in the state bag to pass the
+	// transport.Settings of the route to the proxy
+	BackendTransportKey = "backend:transport"
+
+	// DeadlinePropagationKey is the key used in the state bag to tell
+	// the proxy to set the deadline headers of the backend request
+	DeadlinePropagationKey = "backend:deadline:propagation"
+
+	// ClientDeadlineKey is the key used in the state bag to pass the
+	// deadline of the client, as time.Time, to the proxy, that bounds
+	// the backend timeout with it
+	ClientDeadlineKey = "backend:deadline:client"
+
+	// BackendZeroCopyKey is the key used in the state bag to tell the
+	// proxy to copy the backend response body without userspace buffers
+	BackendZeroCopyKey = "backend:zerocopy"
+
+	// WebsocketInspectionKey is the key used in the state bag to pass
+	// the websocket message callbacks and limits to the proxy
+	WebsocketInspectionKey = "backend:websocket:inspection"
+
+	// MaxRequestBodySizeKey is the key used in the state bag to pass
+	// the max size of the request body, as int64, to the proxy
+	MaxRequestBodySizeKey = "backend:body:maxsize"
+
+	// EarlyHintsKey is the key used in the state bag to pass the Link
+	// header values, as []string, that the proxy sends to the client in
+	// a 103 Early Hints response before the backend request
+	EarlyHintsKey = "backend:earlyhints"
+
+	// BackendHeaderPolicyKey is the key used in the state bag to pass
+	// the headerpolicy.Policy of the route to the proxy
+	BackendHeaderPolicyKey = "backend:header:policy"
+
+	// ClientDisconnectKey is the key used in the state bag to pass the
+	// grace period, as time.Duration, after which the proxy cancels the
+	// backend request when the client disconnected. Zero means
+	// canceling immediately, and a negative value means not canceling
+	ClientDisconnectKey = "backend:client:disconnect"
+
+	// StreamingKey is the key used in the state bag to tell the proxy,
+	// that the route serves long-lived responses, e.g. Server-Sent
+	// Events or long-polling
+	StreamingKey = "backend:streaming"
+
+	// BackendClientCertificateKey is the key used in the state bag to
+	// pass the TLS client certificate of the backend connections of the
+	// route to the proxy
+	BackendClientCertificateKey = "backend:client:certificate"
 )
 
 // Context object providing state and information that is unique to a request.
@@ -163,6 +255,15 @@ type Spec interface {
 	CreateFilter(config []interface{}) (Filter, error)
 }
 
+// ArgsValidator is implemented by the filter specifications that can check
+// the arguments of a filter without creating it. Only the filters of these
+// specifications can be created lazily, on the first use of their route.
+type ArgsValidator interface {
+	// ValidateArgs returns the same error as CreateFilter would return
+	// for invalid arguments, without the expensive part of the creation.
+	ValidateArgs(config []interface{}) error
+}
+
 // Registry used to lookup Spec objects while initializing routes.
 type Registry map[string]Spec
 
@@ -189,6 +290,10 @@ const (
 	SetResponseHeaderName                      = "setResponseHeader"
 	AppendResponseHeaderName                   = "appendResponseHeader"
 	DropResponseHeaderName                     = "dropResponseHeader"
+	KeepRequestHeadersName                     = "keepRequestHeaders"
+	KeepResponseHeadersName                    = "keepResponseHeaders"
+	DropRequestHeadersRegexpName               = "dropRequestHeadersRegexp"
+	DropResponseHeadersRegexpName              = "dropResponseHeadersRegexp"
 	SetContextRequestHeaderName                = "setContextRequestHeader"
 	AppendContextRequestHeaderName             = "appendContextRequestHeader"
 	SetContextResponseHeaderName               = "setContextResponseHeader"
@@ -202,6 +307,7 @@ const (
 	StaticName                                 = "static"
 	StripQueryName                             = "stripQuery"
 	PreserveHostName                           = "preserveHost"
+	PreserveOriginalPathName                   = "preserveOriginalPath"
 	StatusName                                 = "status"
 	CompressName                               = "compress"
 	DecompressName                             = "decompress"
@@ -228,6 +334,7 @@ const (
 	NormalRequestLatencyName                   = "normalRequestLatency"
 	UniformResponseLatencyName                 = "uniformResponseLatency"
 	NormalResponseLatencyName                  = "normalResponseLatency"
+	PercentileLatencyName                      = "percentileLatency"
 	LogHeaderName                              = "logHeader"
 	TeeName                                    = "tee"
 	TeenfName                                  = "teenf"
@@ -293,6 +400,7 @@ const (
 	FifoName                                   = "fifo"
 	LifoName                                   = "lifo"
 	LifoGroupName                              = "lifoGroup"
+	BulkheadName                               = "bulkhead"
 	RfcPathName                                = "rfcPath"
 	RfcHostName                                = "rfcHost"
 	BearerInjectorName                         = "bearerinjector"
@@ -305,10 +413,61 @@ const (
 	EndpointCreatedName                        = "endpointCreated"
 	ConsistentHashKeyName                      = "consistentHashKey"
 	ConsistentHashBalanceFactorName            = "consistentHashBalanceFactor"
+	AllowedMethodsName                         = "allowedMethods"
+	MaxURLLengthName                           = "maxURLLength"
+	MaxHeaderCountName                         = "maxHeaderCount"
+	MaxHeaderSizeName                          = "maxHeaderSize"
+	MaxRequestBodySizeName                     = "maxRequestBodySize"
+	EarlyHintsName                             = "earlyHints"
+	MinTLSVersionName                          = "minTLSVersion"
+	BackendHeaderPolicyName                    = "backendHeaderPolicy"
+	ClientDisconnectName                       = "clientDisconnect"
+	StreamingName                              = "streaming"
+	QueueHeadersName                           = "queueHeaders"
+	AsyncRequestName                           = "asyncRequest"
+	AsyncResultName                            = "asyncResult"
+	BackendClientCertificateName               = "backendClientCertificate"
+	GeoEnrichName                              = "geoEnrich"
+	BotScoreName                               = "botScore"
+	BotBlockName                               = "botBlock"
+	TarpitName                                 = "tarpit"
+	ChallengeName                              = "challenge"
+	AnnotateName                               = "annotate"
+	MaintenanceModeName                        = "maintenanceMode"
+	WAFName                                    = "waf"
+	XPathToRequestHeaderName                   = "xpathToRequestHeader"
+	XPathToResponseHeaderName                  = "xpathToResponseHeader"
+	XMLTransformRequestName                    = "xmlTransformRequest"
+	XMLTransformResponseName                   = "xmlTransformResponse"
+	UseAsDictionaryName                        = "useAsDictionary"
+	DictionaryCompressName                     = "dictionaryCompress"
+	ConditionalRequestName                     = "conditionalRequest"
+	BackendHeaderCasingName                    = "backendHeaderCasing"
+	LegacyBackendName                          = "legacyBackend"
+	BackendResolverName                        = "backendResolver"
+	BackendJWTName                             = "backendJWT"
+	RetryOnResponseName                        = "retryOnResponse"
+	PrewarmConnectionsName                     = "prewarmConnections"
+	MirrorTrafficName                          = "mirrorTraffic"
+	HedgeRequestsName                          = "hedgeRequests"
+	RetryPolicyName                            = "retryPolicy"
+	CoalesceRequestsName                       = "coalesceRequests"
+	CacheName                                  = "cache"
+	BackendProxyProtocolName                   = "backendProxyProtocol"
+	GRPCHealthCheckName                        = "grpcHealthCheck"
+	OAuthClientCredentialsName                 = "oauthClientCredentials"
+	BackendH2CName                             = "backendH2C"
+	BackendTransportName                       = "backendTransport"
+	DeadlinePropagationName                    = "deadlinePropagation"
+	BackendZeroCopyName                        = "backendZeroCopy"
+	WebsocketMaxMessageSizeName                = "websocketMaxMessageSize"
+	WebsocketMessageRateName                   = "websocketMessageRate"
+	TenantKeyName                              = "tenantKey"
 
 	// Undocumented filters
 	HealthCheckName        = "healthcheck"
 	SetFastCgiFilenameName = "setFastCgiFilename"
+	SetFastCgiParamName    = "setFastCgiParam"
 	DisableRatelimitName   = "disableRatelimit"
 	UnknownRatelimitName   = "unknownRatelimit"
 )
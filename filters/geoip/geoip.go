@@ -0,0 +1,99 @@
+/*
+Package geoip provides the geoEnrich filter, that looks up the location and
+the autonomous system of the client IP in a GeoIP database, and passes them
+to the backend in request headers and to the access log as additional
+fields.
+
+The client IP is the remote host of the request, see the client IP
+resolution options of Skipper.
+
+The request headers set by the filter:
+
+	X-Geo-Country: DE
+	X-Geo-Continent: EU
+	X-Geo-Asn: 3320
+
+The fields added to the access log:
+
+	geo_country, geo_continent, geo_asn, geo_as_organization
+
+The headers sent by the client with the same names are removed, even when
+the lookup fails, so that the backends can trust them.
+*/
+package geoip
+
+import (
+	"strconv"
+
+	"github.com/zalando/skipper/filters"
+	al "github.com/zalando/skipper/filters/accesslog"
+	"github.com/zalando/skipper/geoip"
+	snet "github.com/zalando/skipper/net"
+)
+
+const (
+	CountryHeader   = "X-Geo-Country"
+	ContinentHeader = "X-Geo-Continent"
+	ASNHeader       = "X-Geo-Asn"
+)
+
+type spec struct {
+	db *geoip.DB
+}
+
+type filter struct {
+	db *geoip.DB
+}
+
+// New creates the geoEnrich filter specification, using the provided
+// database for the lookups.
+func New(db *geoip.DB) filters.Spec { return &spec{db: db} }
+
+func (*spec) Name() string { return filters.GeoEnrichName }
+
+func (s *spec) CreateFilter(args []interface{}) (filters.Filter, error) {
+	if len(args) != 0 {
+		return nil, filters.ErrInvalidFilterParameters
+	}
+
+	return &filter{db: s.db}, nil
+}
+
+func (f *filter) Request(ctx filters.FilterContext) {
+	r := ctx.Request()
+	r.Header.Del(CountryHeader)
+	r.Header.Del(ContinentHeader)
+	r.Header.Del(ASNHeader)
+
+	l, ok := f.db.Lookup(snet.RemoteHost(r))
+	if !ok {
+		return
+	}
+
+	bag := ctx.StateBag()
+	data, _ := bag[al.AccessLogAdditionalDataKey].(map[string]interface{})
+	if data == nil {
+		data = make(map[string]interface{})
+		bag[al.AccessLogAdditionalDataKey] = data
+	}
+
+	if l.Country != "" {
+		r.Header.Set(CountryHeader, l.Country)
+		data["geo_country"] = l.Country
+	}
+
+	if l.Continent != "" {
+		r.Header.Set(ContinentHeader, l.Continent)
+		data["geo_continent"] = l.Continent
+	}
+
+	if l.ASN != 0 {
+		r.Header.Set(ASNHeader, strconv.FormatUint(uint64(l.ASN), 10))
+		data["geo_asn"] = l.ASN
+		if l.ASOrganization != "" {
+			data["geo_as_organization"] = l.ASOrganization
+		}
+	}
+}
+
+func (*filter) Response(filters.FilterContext) {}
@@ -0,0 +1,77 @@
+package geoip
+
+import (
+	"net/http"
+	"path/filepath"
+	"testing"
+
+	al "github.com/zalando/skipper/filters/accesslog"
+	"github.com/zalando/skipper/filters/filtertest"
+	"github.com/zalando/skipper/geoip"
+	"github.com/zalando/skipper/geoip/geoiptest"
+)
+
+func TestEnrich(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "geo.mmdb")
+	if err := geoiptest.WriteDB(path, map[string]map[string]interface{}{
+		"1.2.3.0/24": {
+			"country":                        map[string]interface{}{"iso_code": "DE"},
+			"continent":                      map[string]interface{}{"code": "EU"},
+			"autonomous_system_number":       uint(3320),
+			"autonomous_system_organization": "Deutsche Telekom AG",
+		},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := geoip.Open(geoip.Options{Paths: []string{path}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer db.Close()
+
+	s := New(db)
+	if _, err := s.CreateFilter([]interface{}{"foo"}); err == nil {
+		t.Fatal("failed to fail")
+	}
+
+	f, err := s.CreateFilter(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("found", func(t *testing.T) {
+		r := &http.Request{RemoteAddr: "1.2.3.4:8080", Header: http.Header{CountryHeader: []string{"US"}}}
+		ctx := &filtertest.Context{FRequest: r, FStateBag: make(map[string]interface{})}
+		f.Request(ctx)
+
+		if r.Header.Get(CountryHeader) != "DE" ||
+			r.Header.Get(ContinentHeader) != "EU" ||
+			r.Header.Get(ASNHeader) != "3320" {
+			t.Fatalf("unexpected headers: %v", r.Header)
+		}
+
+		data, _ := ctx.FStateBag[al.AccessLogAdditionalDataKey].(map[string]interface{})
+		if data["geo_country"] != "DE" ||
+			data["geo_continent"] != "EU" ||
+			data["geo_asn"] != uint(3320) ||
+			data["geo_as_organization"] != "Deutsche Telekom AG" {
+			t.Fatalf("unexpected access log data: %v", data)
+		}
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		r := &http.Request{RemoteAddr: "9.9.9.9:8080", Header: http.Header{CountryHeader: []string{"US"}}}
+		ctx := &filtertest.Context{FRequest: r, FStateBag: make(map[string]interface{})}
+		f.Request(ctx)
+
+		if _, ok := r.Header[CountryHeader]; ok {
+			t.Fatal("client header not removed")
+		}
+
+		if _, ok := ctx.FStateBag[al.AccessLogAdditionalDataKey]; ok {
+			t.Fatal("unexpected access log data")
+		}
+	})
+}
@@ -0,0 +1,231 @@
+/*
+Package headerpolicy provides the sanitation policy of the backend request
+headers, and the backendHeaderPolicy filter, that overrides the global
+policy on the route level.
+
+The policy strips the configured headers from the backend requests, in
+addition to the hop-by-hop headers, optionally folds the repeated header
+fields into a single field, and limits the size of the header sent to the
+backend.
+
+Example:
+
+	r: * -> backendHeaderPolicy("strip", "X-Internal-*", "maxHeaderSize", 16384) -> "https://www.example.org";
+
+For the details of the settings, see the documentation of the filters.
+*/
+package headerpolicy
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/zalando/skipper/filters"
+)
+
+// Policy defines the sanitation of the backend request headers.
+type Policy struct {
+	// Strip lists the headers removed from the backend requests. A name
+	// ending with * removes all the headers with the given prefix.
+	Strip []string
+
+	// MaxHeaderSize limits the size of the backend request header,
+	// counted as the sum of the length of the fields in the form of
+	// "name: value\r\n". When the header is larger, the proxy responds
+	// with 431 Request Header Fields Too Large. Zero means no limit.
+	MaxHeaderSize int
+
+	// FoldDuplicates, when set, tells whether the values of the
+	// repeated header fields are joined into a single field.
+	FoldDuplicates *bool
+}
+
+type spec struct{}
+
+type filter Policy
+
+// Merge returns the policy with the settings of the route applied. The
+// headers stripped by the route are stripped in addition to the ones of
+// the global policy, while the other settings replace the global ones,
+// when set.
+func (p Policy) Merge(route Policy) Policy {
+	if len(route.Strip) > 0 {
+		strip := make([]string, 0, len(p.Strip)+len(route.Strip))
+		strip = append(strip, p.Strip...)
+		p.Strip = append(strip, route.Strip...)
+	}
+
+	if route.MaxHeaderSize > 0 {
+		p.MaxHeaderSize = route.MaxHeaderSize
+	}
+
+	if route.FoldDuplicates != nil {
+		p.FoldDuplicates = route.FoldDuplicates
+	}
+
+	return p
+}
+
+// Empty tells whether the policy has no effect on the headers.
+func (p Policy) Empty() bool {
+	return len(p.Strip) == 0 && p.MaxHeaderSize <= 0 && (p.FoldDuplicates == nil || !*p.FoldDuplicates)
+}
+
+func stripped(name string, strip []string) bool {
+	for _, s := range strip {
+		if strings.HasSuffix(s, "*") {
+			prefix := strings.TrimSuffix(s, "*")
+			if len(name) >= len(prefix) && strings.EqualFold(name[:len(prefix)], prefix) {
+				return true
+			}
+		} else if strings.EqualFold(name, s) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Apply removes the stripped headers, and folds the repeated fields when
+// configured. It returns false when the resulting header is larger than
+// the max header size.
+func (p Policy) Apply(h http.Header) bool {
+	for name, values := range h {
+		if stripped(name, p.Strip) {
+			delete(h, name)
+			continue
+		}
+
+		if p.FoldDuplicates != nil && *p.FoldDuplicates && len(values) > 1 {
+			sep := ", "
+			if name == "Cookie" {
+				sep = "; "
+			}
+
+			h[name] = []string{strings.Join(values, sep)}
+		}
+	}
+
+	return p.MaxHeaderSize <= 0 || Size(h) <= p.MaxHeaderSize
+}
+
+// Size returns the size of the header, counted as the sum of the length
+// of the fields in the form of "name: value\r\n".
+func Size(h http.Header) int {
+	var n int
+	for k, v := range h {
+		for _, vi := range v {
+			n += len(k) + len(vi) + 4
+		}
+	}
+
+	return n
+}
+
+// ParseStrip parses a comma separated list of header names.
+func ParseStrip(s string) []string {
+	var names []string
+	for _, n := range strings.Split(s, ",") {
+		if n = strings.TrimSpace(n); n != "" {
+			names = append(names, n)
+		}
+	}
+
+	return names
+}
+
+// NewBackendHeaderPolicy creates the filter specification of the
+// backendHeaderPolicy filter. The filter arguments are pairs of a setting
+// name and its value:
+//
+//   - strip, a comma separated list of header names, that can be repeated
+//   - maxHeaderSize, as a number of bytes
+//   - foldDuplicates, as "true" or "false"
+//
+// Example:
+//
+//	backendHeaderPolicy("strip", "X-Debug, X-Internal-*")
+//	backendHeaderPolicy("maxHeaderSize", 8192, "foldDuplicates", "true")
+func NewBackendHeaderPolicy() filters.Spec {
+	return spec{}
+}
+
+func (spec) Name() string { return filters.BackendHeaderPolicyName }
+
+func intArg(a interface{}) (int, bool) {
+	switch v := a.(type) {
+	case float64:
+		if v != float64(int(v)) || v <= 0 {
+			return 0, false
+		}
+
+		return int(v), true
+	case string:
+		i, err := strconv.Atoi(v)
+		if err != nil || i <= 0 {
+			return 0, false
+		}
+
+		return i, true
+	default:
+		return 0, false
+	}
+}
+
+func boolArg(a interface{}) (*bool, bool) {
+	s, ok := a.(string)
+	if !ok {
+		return nil, false
+	}
+
+	b, err := strconv.ParseBool(s)
+	if err != nil {
+		return nil, false
+	}
+
+	return &b, true
+}
+
+func (spec) CreateFilter(args []interface{}) (filters.Filter, error) {
+	if len(args) == 0 || len(args)%2 != 0 {
+		return nil, filters.ErrInvalidFilterParameters
+	}
+
+	var (
+		p  Policy
+		ok bool
+	)
+
+	for i := 0; i < len(args); i += 2 {
+		name, _ := args[i].(string)
+		value := args[i+1]
+		switch name {
+		case "strip":
+			var s string
+			if s, ok = value.(string); ok {
+				names := ParseStrip(s)
+				p.Strip = append(p.Strip, names...)
+				ok = len(names) > 0
+			}
+		case "maxHeaderSize":
+			p.MaxHeaderSize, ok = intArg(value)
+		case "foldDuplicates":
+			p.FoldDuplicates, ok = boolArg(value)
+		default:
+			ok = false
+		}
+
+		if !ok {
+			return nil, filters.ErrInvalidFilterParameters
+		}
+	}
+
+	return filter(p), nil
+}
+
+func (f filter) Request(ctx filters.FilterContext) {
+	ctx.StateBag()[filters.BackendHeaderPolicyKey] = Policy(f)
+}
+
+func (filter) Response(filters.FilterContext) {}
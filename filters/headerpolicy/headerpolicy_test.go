@@ -0,0 +1,110 @@
+package headerpolicy
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/zalando/skipper/filters"
+	"github.com/zalando/skipper/filters/filtertest"
+)
+
+func boolPtr(b bool) *bool { return &b }
+
+func TestCreateFilter(t *testing.T) {
+	for _, args := range [][]interface{}{
+		nil,
+		{"strip"},
+		{"strip", ""},
+		{"strip", " , "},
+		{"strip", 42.0},
+		{"maxHeaderSize", 0.0},
+		{"maxHeaderSize", 1.5},
+		{"maxHeaderSize", "large"},
+		{"foldDuplicates", "maybe"},
+		{"foldDuplicates", 1.0},
+		{"removeHeaders", "X-Foo"},
+	} {
+		if _, err := NewBackendHeaderPolicy().CreateFilter(args); err == nil {
+			t.Errorf("%v: failed to fail", args)
+		}
+	}
+}
+
+func TestFilter(t *testing.T) {
+	f, err := NewBackendHeaderPolicy().CreateFilter([]interface{}{
+		"strip", "X-Debug, X-Internal-*",
+		"strip", "X-Trace",
+		"maxHeaderSize", 8192.0,
+		"foldDuplicates", "false",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := &filtertest.Context{FStateBag: make(map[string]interface{})}
+	f.Request(ctx)
+
+	expected := Policy{
+		Strip:          []string{"X-Debug", "X-Internal-*", "X-Trace"},
+		MaxHeaderSize:  8192,
+		FoldDuplicates: boolPtr(false),
+	}
+
+	if p := ctx.FStateBag[filters.BackendHeaderPolicyKey]; !reflect.DeepEqual(p, expected) {
+		t.Errorf("unexpected policy: %+v", p)
+	}
+}
+
+func TestMerge(t *testing.T) {
+	global := Policy{Strip: []string{"X-Internal-*"}, MaxHeaderSize: 1024, FoldDuplicates: boolPtr(true)}
+
+	if p := global.Merge(Policy{}); !reflect.DeepEqual(p, global) {
+		t.Errorf("empty route policy changed the global one: %+v", p)
+	}
+
+	p := global.Merge(Policy{Strip: []string{"X-Debug"}, MaxHeaderSize: 4096, FoldDuplicates: boolPtr(false)})
+	expected := Policy{Strip: []string{"X-Internal-*", "X-Debug"}, MaxHeaderSize: 4096, FoldDuplicates: boolPtr(false)}
+	if !reflect.DeepEqual(p, expected) {
+		t.Errorf("unexpected merged policy: %+v", p)
+	}
+
+	if len(global.Strip) != 1 {
+		t.Errorf("global policy modified: %+v", global)
+	}
+}
+
+func TestApply(t *testing.T) {
+	h := http.Header{
+		"X-Internal-Id": []string{"42"},
+		"X-Debug":       []string{"1"},
+		"X-Forwarded":   []string{"a", "b"},
+		"Cookie":        []string{"a=1", "b=2"},
+		"Accept":        []string{"*/*"},
+	}
+
+	p := Policy{Strip: []string{"x-internal-*", "x-debug"}, FoldDuplicates: boolPtr(true)}
+	if !p.Apply(h) {
+		t.Fatal("unexpected size failure")
+	}
+
+	expected := http.Header{
+		"X-Forwarded": []string{"a, b"},
+		"Cookie":      []string{"a=1; b=2"},
+		"Accept":      []string{"*/*"},
+	}
+
+	if !reflect.DeepEqual(h, expected) {
+		t.Errorf("unexpected header: %v", h)
+	}
+
+	// Accept: */*\r\n
+	p = Policy{MaxHeaderSize: 13}
+	if !p.Apply(http.Header{"Accept": []string{"*/*"}}) {
+		t.Error("failed to accept the header within the limit")
+	}
+
+	if p.Apply(http.Header{"Accept": []string{"*/*", "*/*"}}) {
+		t.Error("failed to reject the header over the limit")
+	}
+}
@@ -0,0 +1,87 @@
+package limits
+
+import (
+	"github.com/zalando/skipper/eskip"
+	"github.com/zalando/skipper/filters"
+)
+
+// Defaults contains the request limits applied to all the routes. The
+// zero value of a field means no default limit of that kind.
+//
+// Defaults implements a routing pre-processor, that prepends the limit
+// filters to the routes that don't have a filter of the same kind, so that
+// the routes can override the defaults with their own limits.
+type Defaults struct {
+	AllowedMethods []string
+	MaxURLLength   int
+	MaxHeaderCount int
+	MaxHeaderSize  int
+}
+
+func (d *Defaults) filters() []*eskip.Filter {
+	var f []*eskip.Filter
+	if len(d.AllowedMethods) > 0 {
+		args := make([]interface{}, len(d.AllowedMethods))
+		for i, m := range d.AllowedMethods {
+			args[i] = m
+		}
+
+		f = append(f, &eskip.Filter{Name: filters.AllowedMethodsName, Args: args})
+	}
+
+	for _, l := range []struct {
+		name  string
+		limit int
+	}{
+		{filters.MaxURLLengthName, d.MaxURLLength},
+		{filters.MaxHeaderCountName, d.MaxHeaderCount},
+		{filters.MaxHeaderSizeName, d.MaxHeaderSize},
+	} {
+		if l.limit > 0 {
+			f = append(f, &eskip.Filter{Name: l.name, Args: []interface{}{float64(l.limit)}})
+		}
+	}
+
+	return f
+}
+
+// Do prepends the default limit filters to the routes.
+func (d *Defaults) Do(routes []*eskip.Route) []*eskip.Route {
+	defaults := d.filters()
+	if len(defaults) == 0 {
+		return routes
+	}
+
+	nextRoutes := make([]*eskip.Route, len(routes))
+	for i, r := range routes {
+		var prepend []*eskip.Filter
+		for _, df := range defaults {
+			if !hasFilter(r, df.Name) {
+				prepend = append(prepend, df)
+			}
+		}
+
+		if len(prepend) == 0 {
+			nextRoutes[i] = r
+			continue
+		}
+
+		nr := *r
+		nr.Filters = make([]*eskip.Filter, 0, len(prepend)+len(r.Filters))
+		nr.Filters = append(nr.Filters, prepend...)
+		nr.Filters = append(nr.Filters, r.Filters...)
+		nextRoutes[i] = &nr
+	}
+
+	return nextRoutes
+}
+
+func hasFilter(r *eskip.Route, name string) bool {
+	for _, f := range r.Filters {
+		if f.Name == name {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,48 @@
+package limits
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/zalando/skipper/eskip"
+)
+
+func mustParse(t *testing.T, doc string) []*eskip.Route {
+	routes, err := eskip.Parse(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return routes
+}
+
+func TestDefaults(t *testing.T) {
+	routes := mustParse(t, `
+		r1: * -> maxURLLength(4096) -> setPath("/") -> <shunt>;
+		r2: * -> allowedMethods("POST") -> maxHeaderCount(8) -> <shunt>;
+	`)
+
+	d := &Defaults{AllowedMethods: []string{"GET", "HEAD"}, MaxURLLength: 1024, MaxHeaderCount: 16}
+	result := d.Do(routes)
+
+	expected := mustParse(t, `
+		r1: * -> allowedMethods("GET", "HEAD") -> maxHeaderCount(16) -> maxURLLength(4096) -> setPath("/") -> <shunt>;
+		r2: * -> maxURLLength(1024) -> allowedMethods("POST") -> maxHeaderCount(8) -> <shunt>;
+	`)
+
+	if !cmp.Equal(result, expected) {
+		t.Error(cmp.Diff(result, expected))
+	}
+
+	if len(routes[0].Filters) != 2 || len(routes[1].Filters) != 2 {
+		t.Error("original routes modified")
+	}
+
+}
+
+func TestNoDefaults(t *testing.T) {
+	routes := mustParse(t, `r: * -> <shunt>`)
+	if result := (&Defaults{}).Do(routes); &result[0] != &routes[0] {
+		t.Error("unexpected copy of the routes")
+	}
+}
@@ -0,0 +1,220 @@
+/*
+Package limits provides filters enforcing the contract of the requests
+accepted by a route: the allowed HTTP methods, the maximum length of the
+request URL, the maximum number and size of the request headers, and
+the maximum size of the request body.
+
+The same limits can be set as defaults for all routes with the Defaults
+pre-processor. The routes that define their own limit of the same kind
+override the default.
+*/
+package limits
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/zalando/skipper/filters"
+)
+
+type kind int
+
+const (
+	allowedMethods kind = iota
+	maxURLLength
+	maxHeaderCount
+	maxHeaderSize
+	maxRequestBodySize
+)
+
+type spec struct {
+	kind kind
+}
+
+type methodsFilter struct {
+	methods map[string]bool
+	allow   string
+}
+
+type limitFilter struct {
+	kind  kind
+	limit int
+}
+
+// NewAllowedMethods creates a filter specification, whose instances
+// reject the requests with a method that is not in the list of arguments
+// with 405 Method Not Allowed.
+//
+// Example:
+//
+//	allowedMethods("GET", "HEAD")
+func NewAllowedMethods() filters.Spec { return &spec{kind: allowedMethods} }
+
+// NewMaxURLLength creates a filter specification, whose instances reject
+// the requests with a request target longer than the argument with 414
+// URI Too Long.
+//
+// Example:
+//
+//	maxURLLength(2048)
+func NewMaxURLLength() filters.Spec { return &spec{kind: maxURLLength} }
+
+// NewMaxHeaderCount creates a filter specification, whose instances reject
+// the requests with more header fields than the argument with 431 Request
+// Header Fields Too Large.
+//
+// Example:
+//
+//	maxHeaderCount(32)
+func NewMaxHeaderCount() filters.Spec { return &spec{kind: maxHeaderCount} }
+
+// NewMaxHeaderSize creates a filter specification, whose instances reject
+// the requests whose header fields are larger than the argument in bytes
+// with 431 Request Header Fields Too Large. The size of a header field is
+// calculated as it is sent on the wire in HTTP/1.1, including the
+// separators.
+//
+// Example:
+//
+//	maxHeaderSize(8192)
+func NewMaxHeaderSize() filters.Spec { return &spec{kind: maxHeaderSize} }
+
+// NewMaxRequestBodySize creates a filter specification, whose instances
+// override the max request body size set for the proxy. The requests
+// with a longer body are rejected by the proxy with 413 Request Entity
+// Too Large, and the reading of the body is aborted.
+//
+// Example:
+//
+//	maxRequestBodySize(1048576)
+func NewMaxRequestBodySize() filters.Spec { return &spec{kind: maxRequestBodySize} }
+
+func (s *spec) Name() string {
+	switch s.kind {
+	case allowedMethods:
+		return filters.AllowedMethodsName
+	case maxURLLength:
+		return filters.MaxURLLengthName
+	case maxHeaderCount:
+		return filters.MaxHeaderCountName
+	case maxRequestBodySize:
+		return filters.MaxRequestBodySizeName
+	default:
+		return filters.MaxHeaderSizeName
+	}
+}
+
+func (s *spec) CreateFilter(args []interface{}) (filters.Filter, error) {
+	if s.kind == allowedMethods {
+		return createMethodsFilter(args)
+	}
+
+	if len(args) != 1 {
+		return nil, filters.ErrInvalidFilterParameters
+	}
+
+	var limit int
+	switch v := args[0].(type) {
+	case int:
+		limit = v
+	case float64:
+		limit = int(v)
+	default:
+		return nil, filters.ErrInvalidFilterParameters
+	}
+
+	if limit <= 0 {
+		return nil, filters.ErrInvalidFilterParameters
+	}
+
+	return &limitFilter{kind: s.kind, limit: limit}, nil
+}
+
+func createMethodsFilter(args []interface{}) (filters.Filter, error) {
+	if len(args) == 0 {
+		return nil, filters.ErrInvalidFilterParameters
+	}
+
+	f := &methodsFilter{methods: make(map[string]bool)}
+	var allow []string
+	for _, a := range args {
+		m, ok := a.(string)
+		if !ok || m == "" {
+			return nil, filters.ErrInvalidFilterParameters
+		}
+
+		m = strings.ToUpper(m)
+		if !f.methods[m] {
+			f.methods[m] = true
+			allow = append(allow, m)
+		}
+	}
+
+	f.allow = strings.Join(allow, ", ")
+	return f, nil
+}
+
+func (f *methodsFilter) Request(ctx filters.FilterContext) {
+	if f.methods[ctx.Request().Method] {
+		return
+	}
+
+	rsp := statusResponse(http.StatusMethodNotAllowed)
+	rsp.Header.Set("Allow", f.allow)
+	ctx.Serve(rsp)
+}
+
+func (*methodsFilter) Response(filters.FilterContext) {}
+
+func headerCount(h http.Header) int {
+	var n int
+	for _, v := range h {
+		n += len(v)
+	}
+
+	return n
+}
+
+func headerSize(h http.Header) int {
+	var n int
+	for k, v := range h {
+		for _, vi := range v {
+			// name: value\r\n
+			n += len(k) + len(vi) + 4
+		}
+	}
+
+	return n
+}
+
+func (f *limitFilter) Request(ctx filters.FilterContext) {
+	r := ctx.Request()
+	switch f.kind {
+	case maxURLLength:
+		u := r.RequestURI
+		if u == "" {
+			u = r.URL.RequestURI()
+		}
+
+		if len(u) > f.limit {
+			ctx.Serve(statusResponse(http.StatusRequestURITooLong))
+		}
+	case maxHeaderCount:
+		if headerCount(r.Header) > f.limit {
+			ctx.Serve(statusResponse(http.StatusRequestHeaderFieldsTooLarge))
+		}
+	case maxHeaderSize:
+		if headerSize(r.Header) > f.limit {
+			ctx.Serve(statusResponse(http.StatusRequestHeaderFieldsTooLarge))
+		}
+	case maxRequestBodySize:
+		// enforced by the proxy, also for the streamed bodies
+		ctx.StateBag()[filters.MaxRequestBodySizeKey] = int64(f.limit)
+	}
+}
+
+func (*limitFilter) Response(filters.FilterContext) {}
+
+func statusResponse(status int) *http.Response {
+	return &http.Response{StatusCode: status, Header: make(http.Header)}
+}
@@ -0,0 +1,159 @@
+package limits
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/zalando/skipper/filters"
+	"github.com/zalando/skipper/filters/filtertest"
+)
+
+func TestCreateFilter(t *testing.T) {
+	for _, tc := range []struct {
+		spec filters.Spec
+		args []interface{}
+		fail bool
+	}{
+		{NewAllowedMethods(), nil, true},
+		{NewAllowedMethods(), []interface{}{"GET", 1}, true},
+		{NewAllowedMethods(), []interface{}{"GET", "post"}, false},
+		{NewMaxURLLength(), nil, true},
+		{NewMaxURLLength(), []interface{}{"100"}, true},
+		{NewMaxURLLength(), []interface{}{0.0}, true},
+		{NewMaxURLLength(), []interface{}{100.0}, false},
+		{NewMaxHeaderCount(), []interface{}{10, 20}, true},
+		{NewMaxHeaderCount(), []interface{}{10}, false},
+		{NewMaxHeaderSize(), []interface{}{-1.0}, true},
+		{NewMaxHeaderSize(), []interface{}{1024.0}, false},
+		{NewMaxRequestBodySize(), []interface{}{0}, true},
+		{NewMaxRequestBodySize(), []interface{}{1048576.0}, false},
+	} {
+		_, err := tc.spec.CreateFilter(tc.args)
+		if tc.fail != (err != nil) {
+			t.Errorf("%s%v: unexpected error: %v", tc.spec.Name(), tc.args, err)
+		}
+	}
+}
+
+func TestLimits(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		spec   filters.Spec
+		args   []interface{}
+		req    func() *http.Request
+		status int
+		allow  string
+	}{{
+		name: "allowed method",
+		spec: NewAllowedMethods(),
+		args: []interface{}{"get", "HEAD"},
+		req:  func() *http.Request { return httptest.NewRequest("HEAD", "/", nil) },
+	}, {
+		name:   "method not allowed",
+		spec:   NewAllowedMethods(),
+		args:   []interface{}{"get", "HEAD", "GET"},
+		req:    func() *http.Request { return httptest.NewRequest("POST", "/", nil) },
+		status: http.StatusMethodNotAllowed,
+		allow:  "GET, HEAD",
+	}, {
+		name: "short url",
+		spec: NewMaxURLLength(),
+		args: []interface{}{10.0},
+		req:  func() *http.Request { return httptest.NewRequest("GET", "/foo?q=1", nil) },
+	}, {
+		name:   "url too long",
+		spec:   NewMaxURLLength(),
+		args:   []interface{}{10.0},
+		req:    func() *http.Request { return httptest.NewRequest("GET", "/foo?q=12345", nil) },
+		status: http.StatusRequestURITooLong,
+	}, {
+		name: "header count",
+		spec: NewMaxHeaderCount(),
+		args: []interface{}{2.0},
+		req: func() *http.Request {
+			r := httptest.NewRequest("GET", "/", nil)
+			r.Header.Add("X-Foo", "1")
+			r.Header.Add("X-Foo", "2")
+			return r
+		},
+	}, {
+		name: "too many headers",
+		spec: NewMaxHeaderCount(),
+		args: []interface{}{2.0},
+		req: func() *http.Request {
+			r := httptest.NewRequest("GET", "/", nil)
+			r.Header.Add("X-Foo", "1")
+			r.Header.Add("X-Foo", "2")
+			r.Header.Add("X-Bar", "3")
+			return r
+		},
+		status: http.StatusRequestHeaderFieldsTooLarge,
+	}, {
+		name: "header size",
+		spec: NewMaxHeaderSize(),
+		args: []interface{}{64.0},
+		req: func() *http.Request {
+			r := httptest.NewRequest("GET", "/", nil)
+			r.Header.Set("X-Foo", strings.Repeat("a", 55))
+			return r
+		},
+	}, {
+		name: "headers too large",
+		spec: NewMaxHeaderSize(),
+		args: []interface{}{64.0},
+		req: func() *http.Request {
+			r := httptest.NewRequest("GET", "/", nil)
+			r.Header.Set("X-Foo", strings.Repeat("a", 56))
+			return r
+		},
+		status: http.StatusRequestHeaderFieldsTooLarge,
+	}} {
+		t.Run(tc.name, func(t *testing.T) {
+			f, err := tc.spec.CreateFilter(tc.args)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			ctx := &filtertest.Context{FRequest: tc.req()}
+			f.Request(ctx)
+			if tc.status == 0 {
+				if ctx.FServed {
+					t.Errorf("unexpected response: %d", ctx.FResponse.StatusCode)
+				}
+
+				return
+			}
+
+			if !ctx.FServed || ctx.FResponse.StatusCode != tc.status {
+				t.Fatalf("failed to reject the request: %v", ctx.FResponse)
+			}
+
+			if allow := ctx.FResponse.Header.Get("Allow"); allow != tc.allow {
+				t.Errorf("unexpected allow header: %q", allow)
+			}
+		})
+	}
+}
+
+func TestMaxRequestBodySize(t *testing.T) {
+	f, err := NewMaxRequestBodySize().CreateFilter([]interface{}{1024.0})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := &filtertest.Context{
+		FRequest:  httptest.NewRequest("POST", "/", strings.NewReader(strings.Repeat("a", 2048))),
+		FStateBag: make(map[string]interface{}),
+	}
+
+	f.Request(ctx)
+	if ctx.FServed {
+		t.Error("the request was rejected by the filter")
+	}
+
+	if limit, ok := ctx.FStateBag[filters.MaxRequestBodySizeKey].(int64); !ok || limit != 1024 {
+		t.Errorf("failed to set the limit: %v", ctx.FStateBag[filters.MaxRequestBodySizeKey])
+	}
+}
@@ -12,11 +12,13 @@ import (
 	"os"
 	"regexp"
 	"strings"
+	"time"
 
 	log "github.com/sirupsen/logrus"
 
 	"github.com/zalando/skipper/filters"
 	"github.com/zalando/skipper/jwt"
+	"github.com/zalando/skipper/logging/eventsink"
 )
 
 const (
@@ -50,6 +52,7 @@ var (
 type auditLog struct {
 	writer     io.Writer
 	maxBodyLog int
+	sink       eventsink.Sink
 }
 
 type teeBody struct {
@@ -119,6 +122,17 @@ func NewAuditLog(maxAuditBody int) filters.Spec {
 	}
 }
 
+// NewAuditLogWithSink creates an auditLog filter specification, whose
+// filters, in addition to the log entries, send the audit documents as
+// events to the sink.
+func NewAuditLogWithSink(maxAuditBody int, sink eventsink.Sink) filters.Spec {
+	return &auditLog{
+		writer:     os.Stderr,
+		maxBodyLog: maxAuditBody,
+		sink:       sink,
+	}
+}
+
 func (al *auditLog) Name() string { return filters.AuditLogName }
 
 // CreateFilter has no arguments. It creates the filter if the user
@@ -128,7 +142,7 @@ func (al *auditLog) CreateFilter(args []interface{}) (filters.Filter, error) {
 		return nil, filters.ErrInvalidFilterParameters
 	}
 
-	return &auditLog{writer: al.writer, maxBodyLog: al.maxBodyLog}, nil
+	return &auditLog{writer: al.writer, maxBodyLog: al.maxBodyLog, sink: al.sink}, nil
 }
 
 func (al *auditLog) Request(ctx filters.FilterContext) {
@@ -174,6 +188,24 @@ func (al *auditLog) Response(ctx filters.FilterContext) {
 	if err != nil {
 		log.Errorf("Failed to json encode auditDoc: %v", err)
 	}
+
+	if al.sink != nil {
+		data := map[string]interface{}{
+			"method": doc.Method,
+			"path":   doc.Path,
+			"status": doc.Status,
+		}
+
+		if doc.AuthStatus != nil {
+			data["authStatus"] = doc.AuthStatus
+		}
+
+		if doc.RequestBody != "" {
+			data["requestBody"] = doc.RequestBody
+		}
+
+		al.sink.Send(&eventsink.Event{Type: eventsink.AuditEvent, Time: time.Now(), Data: data})
+	}
 }
 
 type (
@@ -1,10 +1,14 @@
 package log
 
 import (
+	"bytes"
+	"io"
 	"net/http"
+	"strings"
 	"testing"
 
 	"github.com/zalando/skipper/filters/filtertest"
+	"github.com/zalando/skipper/logging/eventsink"
 )
 
 func TestRequest(t *testing.T) {
@@ -107,3 +111,50 @@ func TestRequest(t *testing.T) {
 		})
 	}
 }
+
+type testSink struct {
+	events []*eventsink.Event
+}
+
+func (s *testSink) Send(e *eventsink.Event) { s.events = append(s.events, e) }
+func (s *testSink) Close()                  {}
+
+func TestAuditLogSendsEvents(t *testing.T) {
+	sink := &testSink{}
+	spec := NewAuditLogWithSink(-1, sink)
+	spec.(*auditLog).writer = io.Discard
+
+	f, err := spec.CreateFilter(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req, err := http.NewRequest("POST", "http://localhost/foo", strings.NewReader("bar"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := &filtertest.Context{
+		FStateBag: map[string]interface{}{AuthUserKey: "jdoe"},
+		FRequest:  req,
+		FResponse: &http.Response{StatusCode: http.StatusCreated},
+	}
+
+	f.Request(ctx)
+	io.Copy(&bytes.Buffer{}, req.Body)
+	f.Response(ctx)
+
+	if len(sink.events) != 1 {
+		t.Fatalf("unexpected number of events: %d", len(sink.events))
+	}
+
+	e := sink.events[0]
+	if e.Type != eventsink.AuditEvent ||
+		e.Data["method"] != "POST" ||
+		e.Data["path"] != "/foo" ||
+		e.Data["status"] != http.StatusCreated ||
+		e.Data["requestBody"] != "bar" ||
+		e.Data["authStatus"].(*authStatusDoc).User != "jdoe" {
+		t.Errorf("unexpected event: %+v", e)
+	}
+}
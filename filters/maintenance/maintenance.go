@@ -0,0 +1,232 @@
+/*
+Package maintenance provides the maintenanceMode filter, that serves a
+static maintenance response for the requests of a route, except for the
+requests coming from the allowlisted source networks.
+
+Example:
+
+	r: * -> maintenanceMode(503, "/etc/skipper/maintenance.html", "10.0.0.0/8") -> "https://app.example.org";
+
+The filter is active by default. It can be switched off without changing
+the filter itself by setting the "maintenance" annotation of the route to
+"off" or "false" with the annotate filter preceding it:
+
+	r: * -> annotate("maintenance", "off") -> maintenanceMode(503, "", "10.0.0.0/8") -> "https://app.example.org";
+
+The admin API, served by the Spec, overrides the state of the filter per
+route id, regardless of the annotations:
+
+	curl -X POST 'localhost:9911/maintenance?route=r&enabled=false'
+	curl -X DELETE 'localhost:9911/maintenance?route=r'
+	curl localhost:9911/maintenance
+*/
+package maintenance
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+
+	"github.com/zalando/skipper/filters"
+	"github.com/zalando/skipper/filters/annotate"
+	snet "github.com/zalando/skipper/net"
+	"github.com/zalando/skipper/routing"
+)
+
+// AnnotationKey is the key of the route annotation toggling the
+// maintenance mode.
+const AnnotationKey = "maintenance"
+
+// Spec creates the maintenanceMode filters, and stores the overrides set
+// via the admin API.
+type Spec struct {
+	mu        sync.RWMutex
+	overrides map[string]bool
+}
+
+type filter struct {
+	spec        *Spec
+	routeId     string
+	status      int
+	body        []byte
+	contentType string
+	allow       snet.IPNets
+}
+
+type postProcessor struct {
+	spec *Spec
+}
+
+// New creates the maintenanceMode filter specification. The filter
+// expects the status code of the maintenance response, the path of a file
+// containing the response body, or an empty string for the default status
+// text, and optionally the CIDRs of the source networks that are not
+// affected by the maintenance:
+//
+//	maintenanceMode(503, "/etc/skipper/maintenance.html", "10.0.0.0/8", "192.168.1.1")
+//
+// The returned specification needs to be registered as a route
+// post-processor, too, and it serves the admin API as an http.Handler.
+func New() *Spec {
+	return &Spec{overrides: make(map[string]bool)}
+}
+
+func (*Spec) Name() string { return filters.MaintenanceModeName }
+
+func (s *Spec) CreateFilter(args []interface{}) (filters.Filter, error) {
+	if len(args) < 2 {
+		return nil, filters.ErrInvalidFilterParameters
+	}
+
+	status, ok := args[0].(float64)
+	if !ok || status != float64(int(status)) || status < 200 || status > 599 {
+		return nil, filters.ErrInvalidFilterParameters
+	}
+
+	bodyRef, ok := args[1].(string)
+	if !ok {
+		return nil, filters.ErrInvalidFilterParameters
+	}
+
+	cidrs := make([]string, 0, len(args)-2)
+	for _, a := range args[2:] {
+		cidr, ok := a.(string)
+		if !ok {
+			return nil, filters.ErrInvalidFilterParameters
+		}
+
+		cidrs = append(cidrs, cidr)
+	}
+
+	allow, err := snet.ParseCIDRs(cidrs)
+	if err != nil {
+		return nil, err
+	}
+
+	body := []byte(http.StatusText(int(status)))
+	if bodyRef != "" {
+		body, err = os.ReadFile(bodyRef)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &filter{
+		spec:        s,
+		status:      int(status),
+		body:        body,
+		contentType: http.DetectContentType(body),
+		allow:       allow,
+	}, nil
+}
+
+// PostProcessor returns the route post-processor, that makes the route ids
+// available to the filters for the admin API overrides.
+func (s *Spec) PostProcessor() routing.PostProcessor {
+	return postProcessor{spec: s}
+}
+
+// InspectedFilters implements routing.FilterInspector.
+func (postProcessor) InspectedFilters() []string {
+	return []string{filters.MaintenanceModeName}
+}
+
+func (p postProcessor) Do(routes []*routing.Route) []*routing.Route {
+	for _, r := range routes {
+		for _, f := range r.Filters {
+			if mf, ok := f.Filter.(*filter); ok {
+				mf.routeId = r.Id
+			}
+		}
+	}
+
+	return routes
+}
+
+func (s *Spec) override(routeId string) (enabled, ok bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	enabled, ok = s.overrides[routeId]
+	return
+}
+
+// ServeHTTP serves the admin API of the maintenance mode. GET lists the
+// overrides by route id, POST sets the override of a route with the route
+// and enabled query parameters, and DELETE removes the override of a route.
+func (s *Spec) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	routeId := r.URL.Query().Get("route")
+	switch r.Method {
+	case "GET":
+		s.mu.RLock()
+		b, err := json.Marshal(s.overrides)
+		s.mu.RUnlock()
+		if err != nil {
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(b)
+	case "POST":
+		enabled, err := strconv.ParseBool(r.URL.Query().Get("enabled"))
+		if routeId == "" || err != nil {
+			http.Error(w, "invalid route or enabled parameter", http.StatusBadRequest)
+			return
+		}
+
+		s.mu.Lock()
+		s.overrides[routeId] = enabled
+		s.mu.Unlock()
+		w.WriteHeader(http.StatusNoContent)
+	case "DELETE":
+		if routeId == "" {
+			http.Error(w, "missing route parameter", http.StatusBadRequest)
+			return
+		}
+
+		s.mu.Lock()
+		delete(s.overrides, routeId)
+		s.mu.Unlock()
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func (f *filter) enabled(ctx filters.FilterContext) bool {
+	if enabled, ok := f.spec.override(f.routeId); ok {
+		return enabled
+	}
+
+	switch annotate.GetAnnotations(ctx)[AnnotationKey] {
+	case "off", "false":
+		return false
+	default:
+		return true
+	}
+}
+
+func (f *filter) Request(ctx filters.FilterContext) {
+	if !f.enabled(ctx) {
+		return
+	}
+
+	if len(f.allow) > 0 && f.allow.Contain(snet.RemoteHost(ctx.Request())) {
+		return
+	}
+
+	ctx.Serve(&http.Response{
+		StatusCode: f.status,
+		Header: http.Header{
+			"Content-Type":   []string{f.contentType},
+			"Content-Length": []string{strconv.Itoa(len(f.body))},
+		},
+		Body: io.NopCloser(bytes.NewReader(f.body)),
+	})
+}
+
+func (*filter) Response(filters.FilterContext) {}
@@ -0,0 +1,170 @@
+package maintenance
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/zalando/skipper/eskip"
+	"github.com/zalando/skipper/filters"
+	"github.com/zalando/skipper/filters/annotate"
+	"github.com/zalando/skipper/filters/filtertest"
+	"github.com/zalando/skipper/routing"
+)
+
+func TestCreateFilter(t *testing.T) {
+	bodyFile := filepath.Join(t.TempDir(), "maintenance.html")
+	if err := os.WriteFile(bodyFile, []byte("<html>maintenance</html>"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range []struct {
+		title string
+		args  []interface{}
+		fail  bool
+	}{{
+		title: "no args",
+		fail:  true,
+	}, {
+		title: "missing body ref",
+		args:  []interface{}{503.0},
+		fail:  true,
+	}, {
+		title: "status not a number",
+		args:  []interface{}{"503", ""},
+		fail:  true,
+	}, {
+		title: "invalid status",
+		args:  []interface{}{99.0, ""},
+		fail:  true,
+	}, {
+		title: "missing body file",
+		args:  []interface{}{503.0, "/no/such/file"},
+		fail:  true,
+	}, {
+		title: "invalid cidr",
+		args:  []interface{}{503.0, "", "10.0.0.0/33"},
+		fail:  true,
+	}, {
+		title: "cidr not a string",
+		args:  []interface{}{503.0, "", 10.0},
+		fail:  true,
+	}, {
+		title: "default body",
+		args:  []interface{}{503.0, ""},
+	}, {
+		title: "body file and cidrs",
+		args:  []interface{}{503.0, bodyFile, "10.0.0.0/8", "192.168.1.1"},
+	}} {
+		t.Run(test.title, func(t *testing.T) {
+			_, err := New().CreateFilter(test.args)
+			if test.fail && err == nil {
+				t.Fatal("failed to fail")
+			} else if !test.fail && err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestMaintenanceMode(t *testing.T) {
+	spec := New()
+	f, err := spec.CreateFilter([]interface{}{503.0, "", "10.0.0.0/8"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	spec.PostProcessor().Do([]*routing.Route{{
+		Route:   eskip.Route{Id: "r"},
+		Filters: []*routing.RouteFilter{{Filter: f, Name: filters.MaintenanceModeName}},
+	}})
+
+	serve := func(t *testing.T, remoteAddr string, annotations ...string) *http.Response {
+		t.Helper()
+		ctx := &filtertest.Context{
+			FRequest:  &http.Request{RemoteAddr: remoteAddr, Header: make(http.Header)},
+			FStateBag: make(map[string]interface{}),
+		}
+
+		for i := 0; i+1 < len(annotations); i += 2 {
+			a, err := annotate.New().CreateFilter([]interface{}{annotations[i], annotations[i+1]})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			a.Request(ctx)
+		}
+
+		f.Request(ctx)
+		return ctx.FResponse
+	}
+
+	t.Run("serves the maintenance response", func(t *testing.T) {
+		rsp := serve(t, "192.168.0.1:1234")
+		if rsp == nil || rsp.StatusCode != http.StatusServiceUnavailable {
+			t.Fatalf("unexpected response: %v", rsp)
+		}
+
+		b, _ := io.ReadAll(rsp.Body)
+		if string(b) != http.StatusText(http.StatusServiceUnavailable) {
+			t.Errorf("unexpected body: %s", b)
+		}
+	})
+
+	t.Run("allowlisted source", func(t *testing.T) {
+		if rsp := serve(t, "10.1.2.3:1234"); rsp != nil {
+			t.Errorf("unexpected response: %v", rsp)
+		}
+	})
+
+	t.Run("switched off by annotation", func(t *testing.T) {
+		if rsp := serve(t, "192.168.0.1:1234", AnnotationKey, "off"); rsp != nil {
+			t.Errorf("unexpected response: %v", rsp)
+		}
+	})
+
+	admin := httptest.NewServer(spec)
+	defer admin.Close()
+
+	request := func(t *testing.T, method, query string, expectedStatus int) {
+		t.Helper()
+		req, _ := http.NewRequest(method, admin.URL+"?"+query, nil)
+		rsp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rsp.Body.Close()
+		if rsp.StatusCode != expectedStatus {
+			t.Fatalf("unexpected status: %d, expected: %d", rsp.StatusCode, expectedStatus)
+		}
+	}
+
+	t.Run("invalid admin requests", func(t *testing.T) {
+		request(t, "POST", "route=r", http.StatusBadRequest)
+		request(t, "POST", "enabled=true", http.StatusBadRequest)
+		request(t, "DELETE", "", http.StatusBadRequest)
+		request(t, "PUT", "route=r&enabled=true", http.StatusMethodNotAllowed)
+	})
+
+	t.Run("admin override takes precedence over the annotation", func(t *testing.T) {
+		request(t, "POST", "route=r&enabled=true", http.StatusNoContent)
+		if rsp := serve(t, "192.168.0.1:1234", AnnotationKey, "off"); rsp == nil {
+			t.Error("maintenance response expected")
+		}
+
+		request(t, "POST", "route=r&enabled=false", http.StatusNoContent)
+		if rsp := serve(t, "192.168.0.1:1234"); rsp != nil {
+			t.Errorf("unexpected response: %v", rsp)
+		}
+
+		request(t, "GET", "", http.StatusOK)
+		request(t, "DELETE", "route=r", http.StatusNoContent)
+		if rsp := serve(t, "192.168.0.1:1234"); rsp == nil {
+			t.Error("maintenance response expected")
+		}
+	})
+}
@@ -13,6 +13,17 @@ func NewFailClosedPostProcessor() *FailClosedPostProcessor {
 	return &FailClosedPostProcessor{}
 }
 
+// InspectedFilters implements routing.FilterInspector.
+func (*FailClosedPostProcessor) InspectedFilters() []string {
+	return []string{
+		filters.ClusterLeakyBucketRatelimitName,
+		filters.BackendRateLimitName,
+		filters.ClientRatelimitName,
+		filters.ClusterClientRatelimitName,
+		filters.ClusterRatelimitName,
+	}
+}
+
 // Do is implementing a PostProcessor interface to change the filter
 // configs at filter processing time. The fail open/closed decision
 // needs to be done once and can be processed before we activate the
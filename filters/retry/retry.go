@@ -0,0 +1,189 @@
+/*
+Package retry provides the retryPolicy filter, that sets the retry policy
+of the backend requests on the route level.
+
+Example:
+
+	r: * -> retryPolicy(3, "50ms", "500ms", 502, 503, 504, "GET", "HEAD") -> <roundRobin, "http://app1", "http://app2">;
+
+For the details of the policy, see the documentation of the filters.
+*/
+package retry
+
+import (
+	"math"
+	"math/rand"
+	"net/http"
+	"time"
+
+	"github.com/zalando/skipper/filters"
+)
+
+const maxAttempts = 10
+
+var (
+	defaultStatusCodes = []int{
+		http.StatusBadGateway,
+		http.StatusServiceUnavailable,
+		http.StatusGatewayTimeout,
+	}
+
+	defaultMethods = []string{
+		http.MethodGet,
+		http.MethodHead,
+		http.MethodOptions,
+	}
+)
+
+// Policy defines when and how many times the proxy retries the backend
+// requests of a route.
+type Policy struct {
+	// MaxAttempts is the maximum number of the backend requests,
+	// including the first one.
+	MaxAttempts int
+
+	// Backoff is the base of the exponential backoff between the
+	// attempts. Zero means no backoff.
+	Backoff time.Duration
+
+	// PerTryTimeout limits the time of a single attempt until receiving
+	// the response headers. Zero means no limit, other than the backend
+	// timeout of the route.
+	PerTryTimeout time.Duration
+
+	statusCodes map[int]bool
+	methods     map[string]bool
+}
+
+type spec struct{}
+
+// NewRetryPolicy creates the filter specification of the retryPolicy
+// filter. The filter arguments are:
+//
+//   - the maximum number of attempts, including the first request, at
+//     most 10
+//   - the base of the exponential backoff with jitter, as a duration
+//     string, or "0" for retrying immediately
+//   - the per-try timeout until receiving the response headers, as a
+//     duration string, or "0" for no per-try timeout
+//   - optionally, the status codes (numbers) and the methods (strings)
+//     to retry. The defaults are 502, 503, 504, and GET, HEAD and
+//     OPTIONS.
+//
+// Only the requests without a body are retried. The failed connections
+// and the per-try timeouts are always retried, while the client side
+// cancellations are not.
+//
+// Example:
+//
+//	retryPolicy(3, "50ms", "500ms")
+//	retryPolicy(5, "100ms", "0", 503, "GET", "PUT")
+func NewRetryPolicy() filters.Spec {
+	return spec{}
+}
+
+func (spec) Name() string { return filters.RetryPolicyName }
+
+func durationArg(a interface{}) (time.Duration, bool) {
+	s, ok := a.(string)
+	if !ok {
+		return 0, false
+	}
+
+	d, err := time.ParseDuration(s)
+	if err != nil || d < 0 {
+		return 0, false
+	}
+
+	return d, true
+}
+
+func (spec) CreateFilter(args []interface{}) (filters.Filter, error) {
+	if len(args) < 3 {
+		return nil, filters.ErrInvalidFilterParameters
+	}
+
+	attempts, ok := args[0].(float64)
+	if !ok || attempts != float64(int(attempts)) || attempts < 1 || attempts > maxAttempts {
+		return nil, filters.ErrInvalidFilterParameters
+	}
+
+	p := &Policy{
+		MaxAttempts: int(attempts),
+		statusCodes: make(map[int]bool),
+		methods:     make(map[string]bool),
+	}
+
+	if p.Backoff, ok = durationArg(args[1]); !ok {
+		return nil, filters.ErrInvalidFilterParameters
+	}
+
+	if p.PerTryTimeout, ok = durationArg(args[2]); !ok {
+		return nil, filters.ErrInvalidFilterParameters
+	}
+
+	for _, a := range args[3:] {
+		switch v := a.(type) {
+		case float64:
+			if v != float64(int(v)) || v < 100 || v > 599 {
+				return nil, filters.ErrInvalidFilterParameters
+			}
+
+			p.statusCodes[int(v)] = true
+		case string:
+			switch v {
+			case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete,
+				http.MethodPost, http.MethodPatch, http.MethodTrace:
+				p.methods[v] = true
+			default:
+				return nil, filters.ErrInvalidFilterParameters
+			}
+		default:
+			return nil, filters.ErrInvalidFilterParameters
+		}
+	}
+
+	if len(p.statusCodes) == 0 {
+		for _, code := range defaultStatusCodes {
+			p.statusCodes[code] = true
+		}
+	}
+
+	if len(p.methods) == 0 {
+		for _, m := range defaultMethods {
+			p.methods[m] = true
+		}
+	}
+
+	return p, nil
+}
+
+// RetryStatus tells whether the responses with the status code are
+// retried.
+func (p *Policy) RetryStatus(code int) bool {
+	return p.statusCodes[code]
+}
+
+// RetryMethod tells whether the requests with the method are retried.
+func (p *Policy) RetryMethod(method string) bool {
+	return p.methods[method]
+}
+
+// BackoffFor returns the time to wait before the given retry, starting
+// from 1, using exponential backoff with full jitter.
+func (p *Policy) BackoffFor(retry int) time.Duration {
+	if p.Backoff <= 0 {
+		return 0
+	}
+
+	max := float64(p.Backoff) * math.Pow(2, float64(retry-1))
+	// #nosec
+	return time.Duration(rand.Float64() * max)
+}
+
+// Request sets the policy for the proxy in the state bag.
+func (p *Policy) Request(ctx filters.FilterContext) {
+	ctx.StateBag()[filters.RetryPolicyKey] = p
+}
+
+func (*Policy) Response(filters.FilterContext) {}
@@ -0,0 +1,81 @@
+package retry
+
+import (
+	"testing"
+	"time"
+
+	"github.com/zalando/skipper/filters"
+	"github.com/zalando/skipper/filters/filtertest"
+)
+
+func TestCreateFilter(t *testing.T) {
+	for _, args := range [][]interface{}{
+		nil,
+		{3.0, "50ms"},
+		{0.0, "50ms", "0"},
+		{11.0, "50ms", "0"},
+		{2.5, "50ms", "0"},
+		{3.0, "fast", "0"},
+		{3.0, "-50ms", "0"},
+		{3.0, "50ms", 100.0},
+		{3.0, "50ms", "0", 99.0},
+		{3.0, "50ms", "0", 503.5},
+		{3.0, "50ms", "0", "get"},
+		{3.0, "50ms", "0", true},
+	} {
+		if _, err := NewRetryPolicy().CreateFilter(args); err == nil {
+			t.Errorf("%v: failed to fail", args)
+		}
+	}
+}
+
+func TestPolicy(t *testing.T) {
+	f, err := NewRetryPolicy().CreateFilter([]interface{}{3.0, "50ms", "200ms"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p := f.(*Policy)
+	if p.MaxAttempts != 3 || p.Backoff != 50*time.Millisecond || p.PerTryTimeout != 200*time.Millisecond {
+		t.Errorf("unexpected policy: %+v", p)
+	}
+
+	if !p.RetryStatus(503) || p.RetryStatus(500) || !p.RetryMethod("GET") || p.RetryMethod("POST") {
+		t.Errorf("unexpected defaults: %+v", p)
+	}
+
+	f, err = NewRetryPolicy().CreateFilter([]interface{}{2.0, "0", "0", 500.0, "POST"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p = f.(*Policy)
+	if p.RetryStatus(503) || !p.RetryStatus(500) || p.RetryMethod("GET") || !p.RetryMethod("POST") {
+		t.Errorf("unexpected status codes or methods: %+v", p)
+	}
+
+	if d := p.BackoffFor(3); d != 0 {
+		t.Errorf("unexpected backoff: %v", d)
+	}
+
+	ctx := &filtertest.Context{FStateBag: make(map[string]interface{})}
+	p.Request(ctx)
+	if ctx.FStateBag[filters.RetryPolicyKey] != p {
+		t.Error("failed to set the policy")
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	p := &Policy{Backoff: 10 * time.Millisecond}
+	for retry, max := range map[int]time.Duration{
+		1: 10 * time.Millisecond,
+		2: 20 * time.Millisecond,
+		4: 80 * time.Millisecond,
+	} {
+		for i := 0; i < 100; i++ {
+			if d := p.BackoffFor(retry); d < 0 || d >= max {
+				t.Fatalf("unexpected backoff for retry %d: %v", retry, d)
+			}
+		}
+	}
+}
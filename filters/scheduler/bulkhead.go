@@ -0,0 +1,116 @@
+package scheduler
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/zalando/skipper/filters"
+	"github.com/zalando/skipper/scheduler"
+)
+
+type (
+	bulkheadSpec   struct{}
+	bulkheadFilter struct {
+		config scheduler.Config
+		queue  *scheduler.FifoQueue
+	}
+)
+
+// NewBulkhead creates the filter spec of the bulkhead filter. The
+// bulkhead filter reserves an isolated concurrency limit for the backend
+// of the route, shared by all the routes with the same backend, so that a
+// slow backend can't take all the capacity of the proxy. The bulkheads
+// are not affected by the global scheduler settings, e.g. load shedding.
+//
+// Example:
+//
+//	bulkhead(20, 40)
+//	bulkhead(20, 40, "2s")
+func NewBulkhead() filters.Spec {
+	return &bulkheadSpec{}
+}
+
+func (*bulkheadSpec) Name() string {
+	return filters.BulkheadName
+}
+
+// CreateFilter creates a bulkheadFilter. The first parameter is
+// maxConcurrent, the second maxQueued, and the optional third one is the
+// timeout of waiting in the queue. Without the timeout, the queued
+// requests wait until the request is canceled.
+func (s *bulkheadSpec) CreateFilter(args []interface{}) (filters.Filter, error) {
+	if len(args) < 2 || len(args) > 3 {
+		return nil, filters.ErrInvalidFilterParameters
+	}
+
+	cc, err := intArg(args[0])
+	if err != nil {
+		return nil, err
+	}
+	if cc < 1 {
+		return nil, fmt.Errorf("maxconcurrent requires value >0, %w", filters.ErrInvalidFilterParameters)
+	}
+
+	qs, err := intArg(args[1])
+	if err != nil {
+		return nil, err
+	}
+	if qs < 0 {
+		return nil, fmt.Errorf("maxqueued requires value >=0, %w", filters.ErrInvalidFilterParameters)
+	}
+
+	var d time.Duration
+	if len(args) == 3 {
+		d, err = durationArg(args[2])
+		if err != nil {
+			return nil, err
+		}
+		if d < 1*time.Millisecond {
+			return nil, fmt.Errorf("timeout requires value >=1ms, %w", filters.ErrInvalidFilterParameters)
+		}
+	}
+
+	return &bulkheadFilter{
+		config: scheduler.Config{
+			MaxConcurrency: cc,
+			MaxQueueSize:   qs,
+			Timeout:        d,
+		},
+	}, nil
+}
+
+func (*bulkheadFilter) Bulkhead() {}
+
+func (f *bulkheadFilter) Config() scheduler.Config {
+	return f.config
+}
+
+func (f *bulkheadFilter) GetQueue() *scheduler.FifoQueue {
+	return f.queue
+}
+
+func (f *bulkheadFilter) SetQueue(fq *scheduler.FifoQueue) {
+	f.queue = fq
+}
+
+// QueuedRequests returns the number of the requests waiting in the
+// queue, reported in the route status.
+func (f *bulkheadFilter) QueuedRequests() int {
+	if f.queue == nil {
+		return 0
+	}
+
+	return f.queue.Status().QueuedRequests
+}
+
+// Request waits for a free slot in the bulkhead of the backend, and
+// responds the same way as the fifo filter, when the bulkhead is full or
+// waiting times out.
+func (f *bulkheadFilter) Request(ctx filters.FilterContext) {
+	waitFifo(f.GetQueue(), scheduler.BulkheadKey, "bulkhead", ctx)
+}
+
+// Response releases the reserved slot of the bulkhead.
+func (f *bulkheadFilter) Response(ctx filters.FilterContext) {
+	releaseFifo(scheduler.BulkheadKey, ctx)
+}
@@ -0,0 +1,141 @@
+package scheduler
+
+import (
+	"fmt"
+	"net/http"
+	stdlibhttptest "net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/zalando/skipper/filters"
+	"github.com/zalando/skipper/proxy"
+	"github.com/zalando/skipper/routing"
+	"github.com/zalando/skipper/routing/testdataclient"
+	"github.com/zalando/skipper/scheduler"
+)
+
+func TestCreateBulkheadFilter(t *testing.T) {
+	for _, tt := range []struct {
+		name         string
+		args         []interface{}
+		wantConfig   scheduler.Config
+		wantParseErr bool
+	}{{
+		name:         "no args",
+		wantParseErr: true,
+	}, {
+		name:         "1 arg",
+		args:         []interface{}{3},
+		wantParseErr: true,
+	}, {
+		name:       "without timeout",
+		args:       []interface{}{3, 5},
+		wantConfig: scheduler.Config{MaxConcurrency: 3, MaxQueueSize: 5},
+	}, {
+		name:       "with timeout",
+		args:       []interface{}{3, 0, "2s"},
+		wantConfig: scheduler.Config{MaxConcurrency: 3, Timeout: 2 * time.Second},
+	}, {
+		name:         "zero concurrency",
+		args:         []interface{}{0, 5},
+		wantParseErr: true,
+	}, {
+		name:         "negative queue",
+		args:         []interface{}{3, -1},
+		wantParseErr: true,
+	}, {
+		name:         "invalid timeout",
+		args:         []interface{}{3, 5, "foo"},
+		wantParseErr: true,
+	}, {
+		name:         "too many args",
+		args:         []interface{}{3, 5, "1s", 7},
+		wantParseErr: true,
+	}} {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := NewBulkhead().CreateFilter(tt.args)
+			if tt.wantParseErr {
+				if err == nil {
+					t.Fatal("failed to fail")
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if c := f.(*bulkheadFilter).Config(); c != tt.wantConfig {
+				t.Errorf("unexpected config, got: %v, want: %v", c, tt.wantConfig)
+			}
+		})
+	}
+}
+
+func TestBulkheadSharedByBackend(t *testing.T) {
+	inBackend := make(chan struct{}, 1)
+	release := make(chan struct{})
+	slow := stdlibhttptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		inBackend <- struct{}{}
+		<-release
+	}))
+	defer slow.Close()
+
+	fast := stdlibhttptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer fast.Close()
+
+	reg := scheduler.RegistryWith(scheduler.Options{})
+	defer reg.Close()
+
+	fr := make(filters.Registry)
+	fr.Register(NewBulkhead())
+
+	dc, err := testdataclient.NewDoc(fmt.Sprintf(`
+		slow1: Path("/slow1") -> bulkhead(1, 0) -> "%s";
+		slow2: Path("/slow2") -> bulkhead(1, 0) -> "%s";
+		fast: Path("/fast") -> bulkhead(1, 0) -> "%s";
+	`, slow.URL, slow.URL, fast.URL))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rt := routing.New(routing.Options{
+		SignalFirstLoad: true,
+		FilterRegistry:  fr,
+		DataClients:     []routing.DataClient{dc},
+		PostProcessors:  []routing.PostProcessor{reg},
+	})
+	defer rt.Close()
+	<-rt.FirstLoad()
+
+	pr := proxy.WithParams(proxy.Params{Routing: rt})
+	defer pr.Close()
+
+	serve := func(path string) int {
+		w := stdlibhttptest.NewRecorder()
+		pr.ServeHTTP(w, stdlibhttptest.NewRequest("GET", path, nil))
+		return w.Code
+	}
+
+	done := make(chan int)
+	go func() { done <- serve("/slow1") }()
+	<-inBackend
+
+	if status := serve("/slow2"); status != http.StatusServiceUnavailable {
+		t.Errorf("unexpected status of the route sharing the bulkhead: %d", status)
+	}
+
+	if status := serve("/fast"); status != http.StatusOK {
+		t.Errorf("unexpected status of the route with another backend: %d", status)
+	}
+
+	close(release)
+	if status := <-done; status != http.StatusOK {
+		t.Errorf("unexpected status of the first request: %d", status)
+	}
+
+	if status := serve("/slow2"); status != http.StatusOK {
+		t.Errorf("failed to release the bulkhead: %d", status)
+	}
+}
@@ -86,6 +86,16 @@ func (f *fifoFilter) SetQueue(fq *scheduler.FifoQueue) {
 	f.queue = fq
 }
 
+// QueuedRequests returns the number of the requests waiting in the
+// queue, reported in the route status.
+func (f *fifoFilter) QueuedRequests() int {
+	if f.queue == nil {
+		return 0
+	}
+
+	return f.queue.Status().QueuedRequests
+}
+
 // Request is the filter.Filter interface implementation. Request will
 // increase the number of inflight requests and respond to the caller,
 // if the bounded queue returns an error. Status code by Error:
@@ -94,15 +104,25 @@ func (f *fifoFilter) SetQueue(fq *scheduler.FifoQueue) {
 // - 502 if queue timeout
 // - 500 if error unknown
 func (f *fifoFilter) Request(ctx filters.FilterContext) {
-	q := f.GetQueue()
+	waitFifo(f.GetQueue(), fifoKey, "fifo", ctx)
+}
+
+// Response will decrease the number of inflight requests to release
+// the concurrency reservation for the request.
+func (f *fifoFilter) Response(ctx filters.FilterContext) {
+	releaseFifo(fifoKey, ctx)
+}
+
+func waitFifo(q *scheduler.FifoQueue, key, name string, ctx filters.FilterContext) {
 	c := ctx.Request().Context()
-	done, err := q.Wait(c)
+	done, info, err := q.WaitInfo(c)
+	storeQueueInfo(ctx, info)
 	if err != nil {
 		if span := opentracing.SpanFromContext(c); span != nil {
 			ext.Error.Set(span, true)
-			span.LogKV("fifo error", fmt.Sprintf("Failed to wait for fifo queue: %v", err))
+			span.LogKV(name+" error", fmt.Sprintf("Failed to wait for %s queue: %v", name, err))
 		}
-		log.Debugf("Failed to wait for fifo queue: %v", err)
+		log.Debugf("Failed to wait for %s queue: %v", name, err)
 
 		switch err {
 		case scheduler.ErrQueueFull:
@@ -122,10 +142,10 @@ func (f *fifoFilter) Request(ctx filters.FilterContext) {
 			return
 
 		default:
-			log.Errorf("Unknown error in fifo() please create an issue https://github.com/zalando/skipper/issues/new/choose: %v", err)
+			log.Errorf("Unknown error in %s() please create an issue https://github.com/zalando/skipper/issues/new/choose: %v", name, err)
 			ctx.Serve(&http.Response{
 				StatusCode: http.StatusInternalServerError,
-				Status:     "Unknown error in fifo https://opensource.zalando.com/skipper/operation/operation/#scheduler, please create an issue https://github.com/zalando/skipper/issues/new/choose",
+				Status:     "Unknown error in " + name + " https://opensource.zalando.com/skipper/operation/operation/#scheduler, please create an issue https://github.com/zalando/skipper/issues/new/choose",
 			})
 			return
 
@@ -133,14 +153,12 @@ func (f *fifoFilter) Request(ctx filters.FilterContext) {
 	}
 
 	// ok
-	pending, _ := ctx.StateBag()[fifoKey].([]func())
-	ctx.StateBag()[fifoKey] = append(pending, done)
+	pending, _ := ctx.StateBag()[key].([]func())
+	ctx.StateBag()[key] = append(pending, done)
 }
 
-// Response will decrease the number of inflight requests to release
-// the concurrency reservation for the request.
-func (f *fifoFilter) Response(ctx filters.FilterContext) {
-	pending, ok := ctx.StateBag()[fifoKey].([]func())
+func releaseFifo(key string, ctx filters.FilterContext) {
+	pending, ok := ctx.StateBag()[key].([]func())
 	if !ok {
 		return
 	}
@@ -149,5 +167,5 @@ func (f *fifoFilter) Response(ctx filters.FilterContext) {
 		return
 	}
 	pending[last]()
-	ctx.StateBag()[fifoKey] = pending[:last]
+	ctx.StateBag()[key] = pending[:last]
 }
@@ -234,6 +234,16 @@ func (l *lifoFilter) GetQueue() *scheduler.Queue {
 	return l.queue
 }
 
+// QueuedRequests returns the number of the requests waiting in the
+// queue, reported in the route status.
+func (l *lifoFilter) QueuedRequests() int {
+	if l.queue == nil {
+		return 0
+	}
+
+	return l.queue.Status().QueuedRequests
+}
+
 // Request is the filter.Filter interface implementation. Request will
 // increase the number of inflight requests and respond to the caller,
 // if the bounded queue returns an error. Status code by Error:
@@ -273,6 +283,16 @@ func (l *lifoGroupFilter) GetQueue() *scheduler.Queue {
 	return l.queue
 }
 
+// QueuedRequests returns the number of the requests waiting in the
+// queue, shared by all the routes of the group.
+func (l *lifoGroupFilter) QueuedRequests() int {
+	if l.queue == nil {
+		return 0
+	}
+
+	return l.queue.Status().QueuedRequests
+}
+
 // Request is the filter.Filter interface implementation. Request will
 // increase the number of inflight requests and respond to the caller,
 // if the bounded queue returns an error. Status code by Error:
@@ -295,7 +315,8 @@ func request(q *scheduler.Queue, key string, ctx filters.FilterContext) {
 		return
 	}
 
-	done, err := q.Wait()
+	done, info, err := q.WaitInfo()
+	storeQueueInfo(ctx, info)
 	if err != nil {
 		if span := opentracing.SpanFromContext(ctx.Request().Context()); span != nil {
 			ext.Error.Set(span, true)
@@ -0,0 +1,78 @@
+package scheduler
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/zalando/skipper/filters"
+	"github.com/zalando/skipper/scheduler"
+)
+
+const (
+	// the scheduler.QueueInfo of the last queue, that the request had to
+	// wait in
+	queueInfoKey = "scheduler:queueinfo"
+
+	queuePositionHeader      = "X-Queue-Position"
+	queueEstimatedWaitHeader = "X-Queue-Estimated-Wait"
+	queueWaitHeader          = "X-Queue-Wait"
+)
+
+type queueHeaders struct{}
+
+// NewQueueHeaders creates a filter specification, whose instances set
+// the response headers describing the waiting of the request in the
+// queue of the fifo, lifo, lifoGroup or bulkhead filters, when the
+// request had to wait:
+//
+//	X-Queue-Position: the position in the queue when the request was queued
+//	X-Queue-Estimated-Wait: the waiting time estimated when the request was queued
+//	X-Queue-Wait: the time the request spent in the queue
+//
+// The headers are set also when the request is rejected due to the queue
+// timeout. The filter needs to precede the scheduler filters.
+//
+// Example:
+//
+//	queueHeaders() -> fifo(10, 1000, "5m")
+func NewQueueHeaders() filters.Spec {
+	return queueHeaders{}
+}
+
+func (queueHeaders) Name() string { return filters.QueueHeadersName }
+
+func (queueHeaders) CreateFilter(args []interface{}) (filters.Filter, error) {
+	if len(args) != 0 {
+		return nil, filters.ErrInvalidFilterParameters
+	}
+
+	return queueHeaders{}, nil
+}
+
+func (queueHeaders) Request(filters.FilterContext) {}
+
+func formatWait(d time.Duration) string {
+	return d.Round(time.Millisecond).String()
+}
+
+func (queueHeaders) Response(ctx filters.FilterContext) {
+	info, ok := ctx.StateBag()[queueInfoKey].(scheduler.QueueInfo)
+	if !ok || ctx.Response() == nil {
+		return
+	}
+
+	h := ctx.Response().Header
+	h.Set(queuePositionHeader, strconv.Itoa(info.Position))
+	if info.EstimatedWait > 0 {
+		h.Set(queueEstimatedWaitHeader, formatWait(info.EstimatedWait))
+	}
+
+	h.Set(queueWaitHeader, formatWait(info.Waited))
+}
+
+// storeQueueInfo stores the queue info of the request, when it had to wait
+func storeQueueInfo(ctx filters.FilterContext, info scheduler.QueueInfo) {
+	if info.Position > 0 {
+		ctx.StateBag()[queueInfoKey] = info
+	}
+}
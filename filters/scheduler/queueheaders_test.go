@@ -0,0 +1,147 @@
+package scheduler
+
+import (
+	"fmt"
+	"net/http"
+	stdlibhttptest "net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/zalando/skipper/filters"
+	"github.com/zalando/skipper/proxy"
+	"github.com/zalando/skipper/routing"
+	"github.com/zalando/skipper/routing/testdataclient"
+	"github.com/zalando/skipper/scheduler"
+)
+
+func TestCreateQueueHeadersFilter(t *testing.T) {
+	spec := NewQueueHeaders()
+	if spec.Name() != filters.QueueHeadersName {
+		t.Errorf("unexpected name: %s", spec.Name())
+	}
+
+	if _, err := spec.CreateFilter(nil); err != nil {
+		t.Errorf("failed to create filter: %v", err)
+	}
+
+	if _, err := spec.CreateFilter([]interface{}{1}); err == nil {
+		t.Error("failed to fail on arguments")
+	}
+}
+
+func TestQueueHeaders(t *testing.T) {
+	inBackend := make(chan struct{}, 1)
+	release := make(chan struct{})
+	backend := stdlibhttptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		inBackend <- struct{}{}
+		<-release
+	}))
+	defer backend.Close()
+
+	reg := scheduler.RegistryWith(scheduler.Options{})
+	defer reg.Close()
+
+	fr := make(filters.Registry)
+	fr.Register(NewFifo())
+	fr.Register(NewQueueHeaders())
+
+	dc, err := testdataclient.NewDoc(fmt.Sprintf(`
+		fifo: Path("/fifo") -> queueHeaders() -> fifo(1, 1, "1m") -> "%s";
+		timeout: Path("/timeout") -> queueHeaders() -> fifo(1, 1, "10ms") -> "%s";
+	`, backend.URL, backend.URL))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rt := routing.New(routing.Options{
+		SignalFirstLoad: true,
+		FilterRegistry:  fr,
+		DataClients:     []routing.DataClient{dc},
+		PostProcessors:  []routing.PostProcessor{reg},
+	})
+	defer rt.Close()
+	<-rt.FirstLoad()
+
+	pr := proxy.WithParams(proxy.Params{Routing: rt})
+	defer pr.Close()
+
+	serve := func(path string) *stdlibhttptest.ResponseRecorder {
+		w := stdlibhttptest.NewRecorder()
+		pr.ServeHTTP(w, stdlibhttptest.NewRequest("GET", path, nil))
+		return w
+	}
+
+	first := make(chan *stdlibhttptest.ResponseRecorder)
+	go func() { first <- serve("/fifo") }()
+	<-inBackend
+
+	second := make(chan *stdlibhttptest.ResponseRecorder)
+	go func() { second <- serve("/fifo") }()
+
+	const waited = 30 * time.Millisecond
+	time.Sleep(waited)
+	release <- struct{}{}
+
+	rsp := <-first
+	if rsp.Code != http.StatusOK {
+		t.Fatalf("unexpected status of the first request: %d", rsp.Code)
+	}
+
+	if h := rsp.Header().Get(queuePositionHeader); h != "" {
+		t.Errorf("unexpected queue position of the request that did not wait: %s", h)
+	}
+
+	<-inBackend
+	release <- struct{}{}
+	rsp = <-second
+	if rsp.Code != http.StatusOK {
+		t.Fatalf("unexpected status of the second request: %d", rsp.Code)
+	}
+
+	if h := rsp.Header().Get(queuePositionHeader); h != "1" {
+		t.Errorf("unexpected queue position: %q", h)
+	}
+
+	if h := rsp.Header().Get(queueEstimatedWaitHeader); h != "" {
+		t.Errorf("unexpected estimated wait before the first completed request: %s", h)
+	}
+
+	d, err := time.ParseDuration(rsp.Header().Get(queueWaitHeader))
+	if err != nil || d < waited/2 {
+		t.Errorf("unexpected wait: %q, %v", rsp.Header().Get(queueWaitHeader), err)
+	}
+
+	// with the processing time known, the wait is estimated
+	go func() { first <- serve("/fifo") }()
+	<-inBackend
+	go func() { second <- serve("/fifo") }()
+	time.Sleep(waited)
+	release <- struct{}{}
+	<-first
+	<-inBackend
+	release <- struct{}{}
+	rsp = <-second
+	if h := rsp.Header().Get(queueEstimatedWaitHeader); h == "" {
+		t.Error("missing estimated wait")
+	}
+
+	go func() { first <- serve("/timeout") }()
+	<-inBackend
+
+	rsp = serve("/timeout")
+	if rsp.Code != http.StatusBadGateway {
+		t.Errorf("unexpected status of the timed out request: %d", rsp.Code)
+	}
+
+	if p, err := strconv.Atoi(rsp.Header().Get(queuePositionHeader)); err != nil || p != 1 {
+		t.Errorf("unexpected queue position of the timed out request: %q", rsp.Header().Get(queuePositionHeader))
+	}
+
+	if rsp.Header().Get(queueWaitHeader) == "" {
+		t.Error("missing wait of the timed out request")
+	}
+
+	release <- struct{}{}
+	<-first
+}
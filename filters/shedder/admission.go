@@ -133,6 +133,11 @@ type admissionControlPost struct {
 	filters map[string]*admissionControl
 }
 
+// InspectedFilters implements routing.FilterInspector.
+func (*admissionControlPost) InspectedFilters() []string {
+	return []string{filters.AdmissionControlName}
+}
+
 // Do implements routing.PostProcessor and makes it possible to close goroutines.
 func (spec *admissionControlPost) Do(routes []*routing.Route) []*routing.Route {
 	inUse := make(map[string]struct{})
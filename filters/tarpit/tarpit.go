@@ -0,0 +1,175 @@
+/*
+Package tarpit provides the tarpit filter, that slows down the responses
+rejecting abusive clients, e.g. by the ratelimit or the botBlock filters,
+to raise the cost of the scraping without blocking the clients harder.
+
+The filter needs to precede the rejecting filters in the filter chain. It
+sends the response headers immediately, and then it drips the response
+body with one byte per second, until the configured duration elapses:
+
+	r: * -> tarpit("30s") -> clientRatelimit(10, "1m") -> "https://app.example.org";
+	r: * -> tarpit("30s") -> botScore() -> botBlock(80) -> "https://app.example.org";
+
+By default, the responses with the 403 and 429 status codes are slowed
+down, which can be changed by listing the status codes:
+
+	r: * -> tarpit("1m", 429) -> clientRatelimit(10, "1m") -> "https://app.example.org";
+
+To slow down every response of a route, e.g. for the requests matched by
+the BotScore predicate, use it together with the status filter:
+
+	bots: BotScore(80) -> tarpit("30s") -> status(429) -> <shunt>;
+*/
+package tarpit
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/zalando/skipper/filters"
+)
+
+const dripInterval = time.Second
+
+var defaultStatusCodes = []int{http.StatusForbidden, http.StatusTooManyRequests}
+
+type spec struct{}
+
+type filter struct {
+	duration    time.Duration
+	statusCodes map[int]bool
+}
+
+// returns the original body one byte at a time, and delays the end of
+// the body until the deadline
+type dripBody struct {
+	ctx      context.Context
+	body     io.ReadCloser
+	deadline time.Time
+	interval time.Duration
+	eof      bool
+}
+
+// New creates the tarpit filter specification.
+func New() filters.Spec { return spec{} }
+
+func (spec) Name() string { return filters.TarpitName }
+
+func (spec) CreateFilter(args []interface{}) (filters.Filter, error) {
+	if len(args) == 0 {
+		return nil, filters.ErrInvalidFilterParameters
+	}
+
+	s, ok := args[0].(string)
+	if !ok {
+		return nil, filters.ErrInvalidFilterParameters
+	}
+
+	d, err := time.ParseDuration(s)
+	if err != nil || d <= 0 {
+		return nil, filters.ErrInvalidFilterParameters
+	}
+
+	f := &filter{duration: d, statusCodes: make(map[int]bool)}
+	if len(args) == 1 {
+		for _, c := range defaultStatusCodes {
+			f.statusCodes[c] = true
+		}
+
+		return f, nil
+	}
+
+	for _, a := range args[1:] {
+		c, ok := a.(float64)
+		if !ok || c < 100 || c > 599 {
+			return nil, filters.ErrInvalidFilterParameters
+		}
+
+		f.statusCodes[int(c)] = true
+	}
+
+	return f, nil
+}
+
+func (*filter) Request(filters.FilterContext) {}
+
+func (f *filter) Response(ctx filters.FilterContext) {
+	rsp := ctx.Response()
+	if !f.statusCodes[rsp.StatusCode] {
+		return
+	}
+
+	body := rsp.Body
+	if body == nil {
+		body = http.NoBody
+	}
+
+	rsp.Body = &dripBody{
+		ctx:      ctx.Request().Context(),
+		body:     body,
+		deadline: time.Now().Add(f.duration),
+		interval: dripInterval,
+	}
+}
+
+func (b *dripBody) wait(d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+
+	select {
+	case <-t.C:
+		return nil
+	case <-b.ctx.Done():
+		return b.ctx.Err()
+	}
+}
+
+func (b *dripBody) Read(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+
+	remaining := time.Until(b.deadline)
+	if remaining <= 0 {
+		if b.eof {
+			return 0, io.EOF
+		}
+
+		return b.body.Read(p)
+	}
+
+	if b.eof {
+		if err := b.wait(remaining); err != nil {
+			return 0, err
+		}
+
+		return 0, io.EOF
+	}
+
+	wait := b.interval
+	if remaining < wait {
+		wait = remaining
+	}
+
+	if err := b.wait(wait); err != nil {
+		return 0, err
+	}
+
+	n, err := b.body.Read(p[:1])
+	if err == io.EOF {
+		b.eof = true
+		if n > 0 {
+			return n, nil
+		}
+
+		return b.Read(p)
+	}
+
+	return n, err
+}
+
+func (b *dripBody) Close() error {
+	return b.body.Close()
+}
@@ -0,0 +1,146 @@
+package tarpit
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/zalando/skipper/filters"
+	"github.com/zalando/skipper/filters/filtertest"
+)
+
+func TestCreateFilter(t *testing.T) {
+	spec := New()
+	if spec.Name() != filters.TarpitName {
+		t.Errorf("invalid name: %s", spec.Name())
+	}
+
+	for _, tt := range []struct {
+		name string
+		args []interface{}
+		err  bool
+	}{
+		{name: "no args", err: true},
+		{name: "invalid duration", args: []interface{}{"foo"}, err: true},
+		{name: "non-positive duration", args: []interface{}{"0s"}, err: true},
+		{name: "invalid status", args: []interface{}{"1s", "429"}, err: true},
+		{name: "status out of range", args: []interface{}{"1s", 42.0}, err: true},
+		{name: "duration", args: []interface{}{"1s"}},
+		{name: "status codes", args: []interface{}{"1s", 429.0, 503.0}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := spec.CreateFilter(tt.args)
+			if tt.err && err == nil {
+				t.Error("failed to fail")
+			} else if !tt.err && err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestStatusCodes(t *testing.T) {
+	for _, tt := range []struct {
+		name   string
+		args   []interface{}
+		status int
+		tarpit bool
+	}{
+		{name: "default forbidden", args: []interface{}{"1s"}, status: http.StatusForbidden, tarpit: true},
+		{name: "default too many requests", args: []interface{}{"1s"}, status: http.StatusTooManyRequests, tarpit: true},
+		{name: "default ok", args: []interface{}{"1s"}, status: http.StatusOK},
+		{name: "custom", args: []interface{}{"1s", 503.0}, status: http.StatusServiceUnavailable, tarpit: true},
+		{name: "not listed", args: []interface{}{"1s", 503.0}, status: http.StatusTooManyRequests},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := New().CreateFilter(tt.args)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			ctx := &filtertest.Context{
+				FRequest:  httptest.NewRequest("GET", "/", nil),
+				FResponse: &http.Response{StatusCode: tt.status, Body: io.NopCloser(strings.NewReader("foo"))},
+			}
+
+			f.Response(ctx)
+			if _, ok := ctx.FResponse.Body.(*dripBody); ok != tt.tarpit {
+				t.Errorf("unexpected tarpit: %t", ok)
+			}
+		})
+	}
+}
+
+func TestDrip(t *testing.T) {
+	b := &dripBody{
+		ctx:      context.Background(),
+		body:     io.NopCloser(strings.NewReader("foo")),
+		deadline: time.Now().Add(120 * time.Millisecond),
+		interval: 10 * time.Millisecond,
+	}
+
+	start := time.Now()
+	p := make([]byte, 16)
+	n, err := b.Read(p)
+	if n != 1 || err != nil {
+		t.Fatalf("failed to drip a single byte: %d, %v", n, err)
+	}
+
+	content, err := io.ReadAll(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(p[:1])+string(content) != "foo" {
+		t.Errorf("unexpected body: %s", string(p[:1])+string(content))
+	}
+
+	if d := time.Since(start); d < 120*time.Millisecond {
+		t.Errorf("body ended before the deadline: %v", d)
+	}
+}
+
+func TestAfterDeadline(t *testing.T) {
+	b := &dripBody{
+		ctx:      context.Background(),
+		body:     io.NopCloser(strings.NewReader("foobarbaz")),
+		deadline: time.Now().Add(-time.Second),
+		interval: time.Hour,
+	}
+
+	p := make([]byte, 16)
+	n, err := b.Read(p)
+	if n != 9 || err != nil || string(p[:n]) != "foobarbaz" {
+		t.Errorf("failed to read normally after the deadline: %d, %v", n, err)
+	}
+}
+
+func TestCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	b := &dripBody{
+		ctx:      ctx,
+		body:     io.NopCloser(strings.NewReader("foo")),
+		deadline: time.Now().Add(time.Hour),
+		interval: time.Hour,
+	}
+
+	done := make(chan error)
+	go func() {
+		_, err := io.ReadAll(b)
+		done <- err
+	}()
+
+	cancel()
+	select {
+	case err := <-done:
+		if err != context.Canceled {
+			t.Errorf("unexpected error: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Error("failed to stop on cancellation")
+	}
+}
@@ -21,5 +21,10 @@ It is possible to change the path of the tee request, in a similar way to the mo
 	Path("/api/v1") -> tee("https://api.example.org", "^/v1", "/v2" ) -> "http://api.example.org"
 
 In the above example, one can test how a new version of an API would behave on incoming requests.
+
+The mirrorTraffic filter sends a copy of only a sampled ratio of the requests, asynchronously,
+after the request was handled, and with the request bodies buffered up to a maximum size:
+
+	r: * -> mirrorTraffic("https://shadow.example.org", 0.1) -> "https://foo.example.org";
 */
 package tee
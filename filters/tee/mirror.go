@@ -0,0 +1,320 @@
+package tee
+
+import (
+	"bytes"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/zalando/skipper/filters"
+)
+
+const (
+	defaultMirrorMaxBody       = 1 << 20
+	defaultMirrorMaxConcurrent = 256
+	mirrorBodyKey              = "tee:mirror:body"
+
+	// the request body is typically read completely by the transport
+	// before the backend response arrives, but the transport may
+	// finish the copy only slightly later
+	mirrorBodyWait = 100 * time.Millisecond
+)
+
+// MirrorOptions for the mirrorTraffic filter.
+type MirrorOptions struct {
+	// Timeout specifies a time limit for the mirrored requests.
+	// Defaults to 1s.
+	Timeout time.Duration
+
+	// MaxConcurrent limits the number of the mirrored requests in
+	// flight. The requests exceeding the limit are not mirrored.
+	// Defaults to 256.
+	MaxConcurrent int
+}
+
+type mirrorSpec struct {
+	client *http.Client
+	slots  chan struct{}
+}
+
+type mirror struct {
+	spec    *mirrorSpec
+	scheme  string
+	host    string
+	ratio   float64
+	maxBody int64
+	rand    func() float64
+
+	// how long the background goroutine waits for the body copy
+	bodyWait time.Duration
+
+	mirrorDone func() // test hook
+}
+
+// records the request body, while it is read by the proxy, up to the
+// maximum size of the mirrored bodies. The body may be read and closed by
+// the transport goroutine, so the recorded state is guarded by the mutex,
+// and finished signals when the body was read to the end or closed.
+type mirrorBody struct {
+	req      *http.Request
+	body     io.ReadCloser
+	max      int64
+	finished chan struct{}
+	once     sync.Once
+
+	mu       sync.Mutex
+	buf      bytes.Buffer
+	exceeded bool
+	eof      bool
+	detached bool
+}
+
+// NewMirrorTraffic returns a filter specification, whose instances send
+// a copy of a sampled ratio of the requests to a shadow backend,
+// asynchronously, and discard its responses. The mirrored requests don't
+// slow down the proxied requests: the request bodies are recorded while
+// the proxy sends them to the route backend, up to a maximum size, and
+// the mirrored request is sent only after the request was handled. The
+// requests with larger bodies, or whose bodies were not completely read,
+// are not mirrored.
+//
+// Arguments: shadow backend URL, ratio of the mirrored requests between
+// 0 and 1, and optionally the maximum body size in bytes, default 1MiB.
+//
+// Example:
+//
+//	r: * -> mirrorTraffic("https://shadow.example.org", 0.1) -> "https://www.example.org";
+//
+// Name: "mirrorTraffic".
+func NewMirrorTraffic() filters.Spec {
+	return NewMirrorTrafficWithOptions(MirrorOptions{})
+}
+
+// NewMirrorTrafficWithOptions returns the mirrorTraffic filter
+// specification with custom options. See NewMirrorTraffic.
+func NewMirrorTrafficWithOptions(o MirrorOptions) filters.Spec {
+	if o.Timeout <= 0 {
+		o.Timeout = defaultTeeTimeout
+	}
+
+	if o.MaxConcurrent <= 0 {
+		o.MaxConcurrent = defaultMirrorMaxConcurrent
+	}
+
+	return &mirrorSpec{
+		client: &http.Client{
+			Timeout: o.Timeout,
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+		slots: make(chan struct{}, o.MaxConcurrent),
+	}
+}
+
+func (*mirrorSpec) Name() string { return filters.MirrorTrafficName }
+
+func (s *mirrorSpec) CreateFilter(args []interface{}) (filters.Filter, error) {
+	if len(args) < 2 || len(args) > 3 {
+		return nil, filters.ErrInvalidFilterParameters
+	}
+
+	backend, ok := args[0].(string)
+	if !ok {
+		return nil, filters.ErrInvalidFilterParameters
+	}
+
+	u, err := url.Parse(backend)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, filters.ErrInvalidFilterParameters
+	}
+
+	ratio, ok := args[1].(float64)
+	if !ok || ratio <= 0 || ratio > 1 {
+		return nil, filters.ErrInvalidFilterParameters
+	}
+
+	m := &mirror{
+		spec:     s,
+		scheme:   u.Scheme,
+		host:     u.Host,
+		ratio:    ratio,
+		maxBody:  defaultMirrorMaxBody,
+		rand:     rand.Float64,
+		bodyWait: mirrorBodyWait,
+	}
+
+	if len(args) == 3 {
+		maxBody, ok := args[2].(float64)
+		if !ok || maxBody < 0 || maxBody != float64(int64(maxBody)) {
+			return nil, filters.ErrInvalidFilterParameters
+		}
+
+		m.maxBody = int64(maxBody)
+	}
+
+	return m, nil
+}
+
+func (b *mirrorBody) finish() {
+	b.once.Do(func() { close(b.finished) })
+}
+
+func (b *mirrorBody) Read(p []byte) (int, error) {
+	n, err := b.body.Read(p)
+
+	b.mu.Lock()
+	if n > 0 && !b.exceeded && !b.detached {
+		if int64(b.buf.Len()+n) > b.max {
+			b.exceeded = true
+			b.buf = bytes.Buffer{}
+		} else {
+			b.buf.Write(p[:n])
+		}
+	}
+
+	if err == io.EOF {
+		b.eof = true
+	}
+	b.mu.Unlock()
+
+	if err == io.EOF {
+		b.finish()
+	}
+
+	return n, err
+}
+
+func (b *mirrorBody) Close() error {
+	b.finish()
+	return b.body.Close()
+}
+
+// waits until the body copy finished, and returns the recorded body, or
+// false, when the body was not completely read or it was too large. The
+// later reads of the body are not recorded anymore.
+func (b *mirrorBody) recorded(wait time.Duration) ([]byte, bool) {
+	t := time.NewTimer(wait)
+	defer t.Stop()
+	select {
+	case <-b.finished:
+	case <-t.C:
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.detached = true
+	if !b.eof || b.exceeded {
+		return nil, false
+	}
+
+	return b.buf.Bytes(), true
+}
+
+func (m *mirror) Request(ctx filters.FilterContext) {
+	if m.rand() >= m.ratio {
+		return
+	}
+
+	req := ctx.Request()
+	if req.ContentLength > m.maxBody {
+		return
+	}
+
+	mreq, err := m.newRequest(req)
+	if err != nil {
+		log.Warnf("mirrorTraffic: failed to create the mirrored request: %v", err)
+		return
+	}
+
+	mb := &mirrorBody{req: mreq, max: m.maxBody, finished: make(chan struct{})}
+	if req.Body == nil || req.Body == http.NoBody {
+		mb.eof = true
+		mb.finish()
+	} else {
+		mb.body = req.Body
+		req.Body = mb
+	}
+
+	ctx.StateBag()[mirrorBodyKey] = mb
+}
+
+func (m *mirror) Response(ctx filters.FilterContext) {
+	mb, ok := ctx.StateBag()[mirrorBodyKey].(*mirrorBody)
+	if !ok {
+		return
+	}
+
+	delete(ctx.StateBag(), mirrorBodyKey)
+	m.send(mb)
+}
+
+// copies the request with the shadow backend as the target, without the
+// body
+func (m *mirror) newRequest(req *http.Request) (*http.Request, error) {
+	u := new(url.URL)
+	*u = *req.URL
+	u.Scheme = m.scheme
+	u.Host = m.host
+
+	mreq, err := http.NewRequest(req.Method, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	mreq.Header = req.Header.Clone()
+	for _, k := range hopHeaders {
+		mreq.Header.Del(k)
+	}
+
+	mreq.Host = m.host
+	return mreq, nil
+}
+
+// sends the mirrored request in the background, unless the maximum
+// number of the mirrored requests are already in flight. The background
+// goroutine waits for the body copy, so that the response is not delayed.
+func (m *mirror) send(mb *mirrorBody) {
+	select {
+	case m.spec.slots <- struct{}{}:
+	default:
+		log.Debugf("mirrorTraffic: too many requests in flight, skipping %s", m.host)
+		return
+	}
+
+	go func() {
+		defer func() {
+			<-m.spec.slots
+			if m.mirrorDone != nil {
+				m.mirrorDone()
+			}
+		}()
+
+		body, ok := mb.recorded(m.bodyWait)
+		if !ok {
+			return
+		}
+
+		req := mb.req
+		if len(body) > 0 {
+			req.Body = io.NopCloser(bytes.NewReader(body))
+			req.ContentLength = int64(len(body))
+			req.GetBody = func() (io.ReadCloser, error) {
+				return io.NopCloser(bytes.NewReader(body)), nil
+			}
+		}
+
+		rsp, err := m.spec.client.Do(req)
+		if err != nil {
+			log.Debugf("mirrorTraffic: error while sending the mirrored request: %v", err)
+			return
+		}
+
+		io.Copy(io.Discard, rsp.Body)
+		rsp.Body.Close()
+	}()
+}
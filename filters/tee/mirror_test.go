@@ -0,0 +1,354 @@
+package tee
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/zalando/skipper/eskip"
+	"github.com/zalando/skipper/filters"
+	"github.com/zalando/skipper/filters/filtertest"
+	"github.com/zalando/skipper/proxy/proxytest"
+)
+
+type mirroredRequest struct {
+	method, path, host, header, body string
+}
+
+func shadowBackend() (*httptest.Server, <-chan mirroredRequest) {
+	received := make(chan mirroredRequest, 16)
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		received <- mirroredRequest{
+			method: r.Method,
+			path:   r.URL.RequestURI(),
+			host:   r.Host,
+			header: r.Header.Get("X-Test"),
+			body:   string(b),
+		}
+
+		w.WriteHeader(http.StatusTeapot)
+	}))
+
+	return s, received
+}
+
+func TestMirrorTrafficArgs(t *testing.T) {
+	spec := NewMirrorTraffic()
+	for _, ti := range []struct {
+		msg  string
+		args []interface{}
+		fail bool
+	}{{
+		msg:  "no args",
+		fail: true,
+	}, {
+		msg:  "no ratio",
+		args: []interface{}{"https://shadow.example.org"},
+		fail: true,
+	}, {
+		msg:  "too many args",
+		args: []interface{}{"https://shadow.example.org", 0.1, 1024.0, 1.0},
+		fail: true,
+	}, {
+		msg:  "invalid url",
+		args: []interface{}{"shadow.example.org", 0.1},
+		fail: true,
+	}, {
+		msg:  "invalid scheme",
+		args: []interface{}{"ftp://shadow.example.org", 0.1},
+		fail: true,
+	}, {
+		msg:  "zero ratio",
+		args: []interface{}{"https://shadow.example.org", 0.0},
+		fail: true,
+	}, {
+		msg:  "ratio too large",
+		args: []interface{}{"https://shadow.example.org", 1.5},
+		fail: true,
+	}, {
+		msg:  "ratio not a number",
+		args: []interface{}{"https://shadow.example.org", "0.1"},
+		fail: true,
+	}, {
+		msg:  "negative body size",
+		args: []interface{}{"https://shadow.example.org", 0.1, -1.0},
+		fail: true,
+	}, {
+		msg:  "fractional body size",
+		args: []interface{}{"https://shadow.example.org", 0.1, 1.5},
+		fail: true,
+	}, {
+		msg:  "ratio",
+		args: []interface{}{"https://shadow.example.org", 0.1},
+	}, {
+		msg:  "all",
+		args: []interface{}{"http://shadow.example.org:9090", 1.0, 65536.0},
+	}} {
+		t.Run(ti.msg, func(t *testing.T) {
+			_, err := spec.CreateFilter(ti.args)
+			if ti.fail && err == nil {
+				t.Error("failed to fail")
+			} else if !ti.fail && err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func createMirror(t *testing.T, args ...interface{}) (*mirror, <-chan struct{}) {
+	f, err := NewMirrorTraffic().CreateFilter(args)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan struct{}, 16)
+	m := f.(*mirror)
+	m.mirrorDone = func() { done <- struct{}{} }
+	return m, done
+}
+
+func runMirror(t *testing.T, m *mirror, r *http.Request) {
+	ctx := &filtertest.Context{FRequest: r, FStateBag: make(map[string]interface{})}
+	m.Request(ctx)
+	if r.Body != nil {
+		if _, err := io.Copy(io.Discard, r.Body); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	m.Response(ctx)
+}
+
+func waitMirrored(t *testing.T, received <-chan mirroredRequest) mirroredRequest {
+	select {
+	case r := <-received:
+		return r
+	case <-time.After(3 * time.Second):
+		t.Fatal("timeout waiting for the mirrored request")
+		return mirroredRequest{}
+	}
+}
+
+func TestMirrorTraffic(t *testing.T) {
+	shadow, received := shadowBackend()
+	defer shadow.Close()
+
+	m, done := createMirror(t, shadow.URL, 1.0, 8.0)
+
+	r, _ := http.NewRequest("POST", "https://www.example.org/foo?bar=baz", strings.NewReader("hello"))
+	r.Header.Set("X-Test", "test-value")
+	r.Header.Set("Connection", "close")
+	runMirror(t, m, r)
+
+	mr := waitMirrored(t, received)
+	if mr.method != "POST" || mr.path != "/foo?bar=baz" || mr.header != "test-value" || mr.body != "hello" {
+		t.Errorf("unexpected mirrored request: %+v", mr)
+	}
+
+	if mr.host != strings.TrimPrefix(shadow.URL, "http://") {
+		t.Errorf("unexpected host of the mirrored request: %s", mr.host)
+	}
+
+	<-done
+
+	r, _ = http.NewRequest("GET", "https://www.example.org/bar", nil)
+	runMirror(t, m, r)
+	if mr = waitMirrored(t, received); mr.method != "GET" || mr.path != "/bar" || mr.body != "" {
+		t.Errorf("unexpected mirrored request: %+v", mr)
+	}
+
+	<-done
+}
+
+func TestMirrorTrafficSkipped(t *testing.T) {
+	shadow, received := shadowBackend()
+	defer shadow.Close()
+
+	m, _ := createMirror(t, shadow.URL, 0.1, 8.0)
+
+	t.Run("not sampled", func(t *testing.T) {
+		m.rand = func() float64 { return 0.1 }
+		r, _ := http.NewRequest("GET", "https://www.example.org", nil)
+		runMirror(t, m, r)
+	})
+
+	m.rand = func() float64 { return 0.05 }
+
+	t.Run("content length exceeds the limit", func(t *testing.T) {
+		r, _ := http.NewRequest("POST", "https://www.example.org", strings.NewReader("hello world"))
+		runMirror(t, m, r)
+	})
+
+	t.Run("chunked body exceeds the limit", func(t *testing.T) {
+		r, _ := http.NewRequest("POST", "https://www.example.org", io.NopCloser(strings.NewReader("hello world")))
+		runMirror(t, m, r)
+	})
+
+	t.Run("body not read", func(t *testing.T) {
+		r, _ := http.NewRequest("POST", "https://www.example.org", strings.NewReader("hello"))
+		ctx := &filtertest.Context{FRequest: r, FStateBag: make(map[string]interface{})}
+		m.Request(ctx)
+		m.Response(ctx)
+	})
+
+	select {
+	case mr := <-received:
+		t.Errorf("unexpected mirrored request: %+v", mr)
+	case <-time.After(30 * time.Millisecond):
+	}
+}
+
+func TestMirrorTrafficSentAfterResponse(t *testing.T) {
+	shadow, received := shadowBackend()
+	defer shadow.Close()
+
+	m, done := createMirror(t, shadow.URL, 1.0)
+	r, _ := http.NewRequest("GET", "https://www.example.org/foo", nil)
+	ctx := &filtertest.Context{FRequest: r, FStateBag: make(map[string]interface{})}
+	m.Request(ctx)
+
+	select {
+	case mr := <-received:
+		t.Fatalf("mirrored request sent before the response: %+v", mr)
+	case <-time.After(30 * time.Millisecond):
+	}
+
+	m.Response(ctx)
+	if mr := waitMirrored(t, received); mr.path != "/foo" {
+		t.Errorf("unexpected mirrored request: %+v", mr)
+	}
+
+	<-done
+}
+
+// the transport may read and close the request body in its own goroutine,
+// while the response is already being handled
+func TestMirrorTrafficConcurrentBodyCopy(t *testing.T) {
+	shadow, received := shadowBackend()
+	defer shadow.Close()
+
+	m, done := createMirror(t, shadow.URL, 1.0)
+	r, _ := http.NewRequest("POST", "https://www.example.org/foo", strings.NewReader("hello"))
+	ctx := &filtertest.Context{FRequest: r, FStateBag: make(map[string]interface{})}
+	m.Request(ctx)
+
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		io.Copy(io.Discard, r.Body)
+		r.Body.Close()
+	}()
+
+	m.Response(ctx)
+	if mr := waitMirrored(t, received); mr.body != "hello" {
+		t.Errorf("unexpected mirrored request: %+v", mr)
+	}
+
+	<-done
+}
+
+func TestMirrorTrafficResponseNotDelayed(t *testing.T) {
+	shadow, received := shadowBackend()
+	defer shadow.Close()
+
+	m, done := createMirror(t, shadow.URL, 1.0)
+	m.bodyWait = time.Hour
+
+	r, _ := http.NewRequest("POST", "https://www.example.org/foo", strings.NewReader("hello"))
+	ctx := &filtertest.Context{FRequest: r, FStateBag: make(map[string]interface{})}
+	m.Request(ctx)
+
+	returned := make(chan struct{})
+	go func() {
+		m.Response(ctx)
+		close(returned)
+	}()
+
+	select {
+	case <-returned:
+	case <-time.After(time.Second):
+		t.Fatal("the response was delayed by the unread body")
+	}
+
+	// the unread body is closed, e.g. by the server
+	r.Body.Close()
+	<-done
+
+	select {
+	case mr := <-received:
+		t.Errorf("unexpected mirrored request: %+v", mr)
+	case <-time.After(30 * time.Millisecond):
+	}
+}
+
+func TestMirrorTrafficMaxConcurrent(t *testing.T) {
+	release := make(chan struct{})
+	shadow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer shadow.Close()
+
+	f, err := NewMirrorTrafficWithOptions(MirrorOptions{MaxConcurrent: 1}).CreateFilter([]interface{}{shadow.URL, 1.0})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m := f.(*mirror)
+	done := make(chan struct{}, 2)
+	m.mirrorDone = func() { done <- struct{}{} }
+
+	for i := 0; i < 2; i++ {
+		r, _ := http.NewRequest("GET", "https://www.example.org", nil)
+		runMirror(t, m, r)
+	}
+
+	close(release)
+	<-done
+
+	select {
+	case <-done:
+		t.Error("failed to limit the mirrored requests in flight")
+	case <-time.After(30 * time.Millisecond):
+	}
+}
+
+func TestMirrorTrafficProxy(t *testing.T) {
+	shadow, received := shadowBackend()
+	defer shadow.Close()
+
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		w.Write(b)
+	}))
+	defer backend.Close()
+
+	routes, err := eskip.Parse(fmt.Sprintf(`* -> mirrorTraffic("%s", 1) -> "%s"`, shadow.URL, backend.URL))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fr := make(filters.Registry)
+	fr.Register(NewMirrorTraffic())
+	p := proxytest.New(fr, routes...)
+	defer p.Close()
+
+	rsp, err := http.Post(p.URL+"/foo", "text/plain", bytes.NewBufferString("hello"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer rsp.Body.Close()
+	b, _ := io.ReadAll(rsp.Body)
+	if rsp.StatusCode != http.StatusOK || string(b) != "hello" {
+		t.Errorf("unexpected response: %d %s", rsp.StatusCode, b)
+	}
+
+	if mr := waitMirrored(t, received); mr.path != "/foo" || mr.body != "hello" {
+		t.Errorf("unexpected mirrored request: %+v", mr)
+	}
+}
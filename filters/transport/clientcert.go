@@ -0,0 +1,134 @@
+package transport
+
+import (
+	"bytes"
+	"crypto/tls"
+	"fmt"
+	"path"
+	"sync"
+
+	"github.com/zalando/skipper/filters"
+	"github.com/zalando/skipper/secrets"
+)
+
+// ClientCertificate provides the TLS client certificate of the backend
+// connections of a route, read from the secrets reader. The certificate
+// is parsed again when the secrets change, so that it can be rotated
+// without restarting skipper.
+type ClientCertificate struct {
+	secretsReader secrets.SecretsReader
+	certName      string
+	keyName       string
+
+	mu      sync.Mutex
+	certRaw []byte
+	keyRaw  []byte
+	cert    *tls.Certificate
+}
+
+type clientCertificateSpec struct {
+	secretsReader secrets.SecretsReader
+
+	mu    sync.Mutex
+	certs map[[2]string]*ClientCertificate
+}
+
+type clientCertificateFilter struct {
+	cert *ClientCertificate
+}
+
+// NewBackendClientCertificate creates the filter specification of the
+// backendClientCertificate filter, that sets the TLS client certificate
+// of the backend connections of the route, for mutual TLS, instead of the
+// global one. The certificate and the key are read from the secrets
+// reader, PEM encoded. The filter arguments are the name of the
+// certificate and the name of the key, or the name of a directory
+// containing them as tls.crt and tls.key, as mounted from a Kubernetes
+// TLS secret.
+//
+// The routes with the same certificate share the backend connections.
+//
+// Example:
+//
+//	backendClientCertificate("/meta/credentials/backend/tls.crt", "/meta/credentials/backend/tls.key")
+//	backendClientCertificate("/meta/credentials/backend")
+func NewBackendClientCertificate(sr secrets.SecretsReader) filters.Spec {
+	return &clientCertificateSpec{
+		secretsReader: sr,
+		certs:         make(map[[2]string]*ClientCertificate),
+	}
+}
+
+func (*clientCertificateSpec) Name() string { return filters.BackendClientCertificateName }
+
+func (s *clientCertificateSpec) CreateFilter(args []interface{}) (filters.Filter, error) {
+	var names [2]string
+	switch len(args) {
+	case 1:
+		dir, ok := args[0].(string)
+		if !ok || dir == "" {
+			return nil, filters.ErrInvalidFilterParameters
+		}
+
+		names = [2]string{path.Join(dir, "tls.crt"), path.Join(dir, "tls.key")}
+	case 2:
+		for i, a := range args {
+			name, ok := a.(string)
+			if !ok || name == "" {
+				return nil, filters.ErrInvalidFilterParameters
+			}
+
+			names[i] = name
+		}
+	default:
+		return nil, filters.ErrInvalidFilterParameters
+	}
+
+	// the routes with the same certificate get the same instance, so
+	// that they share the transport
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	c, ok := s.certs[names]
+	if !ok {
+		c = &ClientCertificate{secretsReader: s.secretsReader, certName: names[0], keyName: names[1]}
+		s.certs[names] = c
+	}
+
+	return &clientCertificateFilter{cert: c}, nil
+}
+
+func (f *clientCertificateFilter) Request(ctx filters.FilterContext) {
+	ctx.StateBag()[filters.BackendClientCertificateKey] = f.cert
+}
+
+func (*clientCertificateFilter) Response(filters.FilterContext) {}
+
+// GetClientCertificate returns the certificate, and it can be used as the
+// GetClientCertificate function of tls.Config.
+func (c *ClientCertificate) GetClientCertificate(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+	certRaw, ok := c.secretsReader.GetSecret(c.certName)
+	if !ok {
+		return nil, fmt.Errorf("client certificate not found: %s", c.certName)
+	}
+
+	keyRaw, ok := c.secretsReader.GetSecret(c.keyName)
+	if !ok {
+		return nil, fmt.Errorf("client certificate key not found: %s", c.keyName)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.cert != nil && bytes.Equal(c.certRaw, certRaw) && bytes.Equal(c.keyRaw, keyRaw) {
+		return c.cert, nil
+	}
+
+	cert, err := tls.X509KeyPair(certRaw, keyRaw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid client certificate %s: %w", c.certName, err)
+	}
+
+	c.certRaw, c.keyRaw, c.cert = certRaw, keyRaw, &cert
+	return c.cert, nil
+}
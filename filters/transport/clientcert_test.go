@@ -0,0 +1,163 @@
+package transport
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/zalando/skipper/filters"
+	"github.com/zalando/skipper/filters/filtertest"
+)
+
+type testSecrets struct {
+	mu      sync.Mutex
+	secrets map[string][]byte
+}
+
+func (s *testSecrets) GetSecret(name string) ([]byte, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	b, ok := s.secrets[name]
+	return b, ok
+}
+
+func (s *testSecrets) set(name string, b []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.secrets[name] = b
+}
+
+func (*testSecrets) Close() {}
+
+func generateCert(t *testing.T, cn string) ([]byte, []byte) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: cn},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+}
+
+func TestCreateClientCertificateFilter(t *testing.T) {
+	spec := NewBackendClientCertificate(&testSecrets{})
+	for _, args := range [][]interface{}{
+		nil,
+		{""},
+		{42.0},
+		{"/meta/credentials/tls.crt", ""},
+		{"/meta/credentials/tls.crt", 42.0},
+		{"/meta/credentials/tls.crt", "/meta/credentials/tls.key", "foo"},
+	} {
+		if _, err := spec.CreateFilter(args); err == nil {
+			t.Errorf("%v: failed to fail", args)
+		}
+	}
+}
+
+func TestClientCertificateShared(t *testing.T) {
+	spec := NewBackendClientCertificate(&testSecrets{})
+	certOf := func(args ...interface{}) *ClientCertificate {
+		f, err := spec.CreateFilter(args)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		ctx := &filtertest.Context{FStateBag: make(map[string]interface{})}
+		f.Request(ctx)
+		return ctx.FStateBag[filters.BackendClientCertificateKey].(*ClientCertificate)
+	}
+
+	c := certOf("/meta/credentials/backend")
+	if c.certName != "/meta/credentials/backend/tls.crt" || c.keyName != "/meta/credentials/backend/tls.key" {
+		t.Errorf("unexpected secret names: %s, %s", c.certName, c.keyName)
+	}
+
+	if certOf("/meta/credentials/backend/tls.crt", "/meta/credentials/backend/tls.key") != c {
+		t.Error("failed to share the certificate")
+	}
+
+	if certOf("/meta/credentials/other") == c {
+		t.Error("unexpected shared certificate")
+	}
+}
+
+func TestGetClientCertificate(t *testing.T) {
+	certPEM, keyPEM := generateCert(t, "foo")
+	sr := &testSecrets{secrets: map[string][]byte{
+		"/certs/tls.crt": certPEM,
+		"/certs/tls.key": keyPEM,
+	}}
+
+	f, err := NewBackendClientCertificate(sr).CreateFilter([]interface{}{"/certs"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := f.(*clientCertificateFilter).cert
+	cert, err := c.GetClientCertificate(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if again, err := c.GetClientCertificate(nil); err != nil || again != cert {
+		t.Errorf("failed to reuse the parsed certificate: %v", err)
+	}
+
+	certPEM, keyPEM = generateCert(t, "bar")
+	sr.set("/certs/tls.crt", certPEM)
+	sr.set("/certs/tls.key", keyPEM)
+	rotated, err := c.GetClientCertificate(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	leaf, err := x509.ParseCertificate(rotated.Certificate[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if leaf.Subject.CommonName != "bar" {
+		t.Errorf("failed to rotate the certificate: %s", leaf.Subject.CommonName)
+	}
+
+	sr.set("/certs/tls.key", []byte("invalid"))
+	if _, err := c.GetClientCertificate(nil); err == nil {
+		t.Error("failed to fail on an invalid key")
+	}
+
+	missing, err := NewBackendClientCertificate(sr).CreateFilter([]interface{}{"/missing"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := missing.(*clientCertificateFilter).cert.GetClientCertificate(nil); err == nil {
+		t.Error("failed to fail on a missing certificate")
+	}
+}
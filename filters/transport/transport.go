@@ -0,0 +1,160 @@
+/*
+Package transport provides the backendTransport filter, that overrides
+the transport settings of the backend connections on the route level, and
+the backendClientCertificate filter, that sets the TLS client certificate
+of the backend connections on the route level.
+
+Example:
+
+	r: * -> backendTransport("dialTimeout", "500ms", "responseHeaderTimeout", "5m") -> "https://reports.example.org";
+	m: * -> backendClientCertificate("/meta/credentials/payments") -> "https://payments.example.org";
+
+For the details of the settings, see the documentation of the filters.
+*/
+package transport
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/zalando/skipper/filters"
+)
+
+// Settings overrides the transport settings of the proxy for the backend
+// requests of a route. The zero values keep the global settings.
+type Settings struct {
+	// DialTimeout limits the time of establishing the TCP connection.
+	// It can't be longer than the global timeout of the dialer.
+	DialTimeout time.Duration
+
+	// TLSHandshakeTimeout limits the time of the TLS handshake.
+	TLSHandshakeTimeout time.Duration
+
+	// ResponseHeaderTimeout limits the time of waiting for the response
+	// headers after sending the request. A negative value disables the
+	// timeout.
+	ResponseHeaderTimeout time.Duration
+
+	// ExpectContinueTimeout limits the time of waiting for the 100
+	// Continue response, when the request has the Expect header.
+	ExpectContinueTimeout time.Duration
+
+	// IdleConnTimeout is the time after the idle connections are closed.
+	IdleConnTimeout time.Duration
+
+	// MaxIdleConnsPerHost limits the number of the idle connections
+	// kept for each backend host.
+	MaxIdleConnsPerHost int
+
+	// MaxConnsPerHost limits the number of the connections for each
+	// backend host, including the connections in use.
+	MaxConnsPerHost int
+
+	// ClientCertificate replaces the TLS client certificate of the
+	// backend connections, set by the backendClientCertificate filter.
+	ClientCertificate *ClientCertificate
+}
+
+type spec struct{}
+
+type filter Settings
+
+// NewBackendTransport creates the filter specification of the
+// backendTransport filter. The filter arguments are pairs of a setting
+// name and its value:
+//
+//   - dialTimeout, tlsHandshakeTimeout, responseHeaderTimeout,
+//     expectContinueTimeout and idleConnTimeout, as duration strings
+//   - maxIdleConnsPerHost and maxConnsPerHost, as numbers
+//
+// The routes with the same settings share the backend connections.
+//
+// Example:
+//
+//	backendTransport("responseHeaderTimeout", "5m")
+//	backendTransport("dialTimeout", "200ms", "tlsHandshakeTimeout", "500ms", "maxIdleConnsPerHost", 16)
+func NewBackendTransport() filters.Spec {
+	return spec{}
+}
+
+func (spec) Name() string { return filters.BackendTransportName }
+
+func durationArg(a interface{}) (time.Duration, bool) {
+	s, ok := a.(string)
+	if !ok {
+		return 0, false
+	}
+
+	d, err := time.ParseDuration(s)
+	if err != nil || d <= 0 {
+		return 0, false
+	}
+
+	return d, true
+}
+
+func intArg(a interface{}) (int, bool) {
+	switch v := a.(type) {
+	case float64:
+		if v != float64(int(v)) || v <= 0 {
+			return 0, false
+		}
+
+		return int(v), true
+	case string:
+		i, err := strconv.Atoi(v)
+		if err != nil || i <= 0 {
+			return 0, false
+		}
+
+		return i, true
+	default:
+		return 0, false
+	}
+}
+
+func (spec) CreateFilter(args []interface{}) (filters.Filter, error) {
+	if len(args) == 0 || len(args)%2 != 0 {
+		return nil, filters.ErrInvalidFilterParameters
+	}
+
+	var (
+		s  Settings
+		ok bool
+	)
+
+	for i := 0; i < len(args); i += 2 {
+		name, _ := args[i].(string)
+		value := args[i+1]
+		switch name {
+		case "dialTimeout":
+			s.DialTimeout, ok = durationArg(value)
+		case "tlsHandshakeTimeout":
+			s.TLSHandshakeTimeout, ok = durationArg(value)
+		case "responseHeaderTimeout":
+			s.ResponseHeaderTimeout, ok = durationArg(value)
+		case "expectContinueTimeout":
+			s.ExpectContinueTimeout, ok = durationArg(value)
+		case "idleConnTimeout":
+			s.IdleConnTimeout, ok = durationArg(value)
+		case "maxIdleConnsPerHost":
+			s.MaxIdleConnsPerHost, ok = intArg(value)
+		case "maxConnsPerHost":
+			s.MaxConnsPerHost, ok = intArg(value)
+		default:
+			ok = false
+		}
+
+		if !ok {
+			return nil, filters.ErrInvalidFilterParameters
+		}
+	}
+
+	return filter(s), nil
+}
+
+func (f filter) Request(ctx filters.FilterContext) {
+	ctx.StateBag()[filters.BackendTransportKey] = Settings(f)
+}
+
+func (filter) Response(filters.FilterContext) {}
@@ -0,0 +1,72 @@
+package transport
+
+import (
+	"testing"
+	"time"
+
+	"github.com/zalando/skipper/filters"
+	"github.com/zalando/skipper/filters/filtertest"
+)
+
+func TestCreateFilter(t *testing.T) {
+	for _, args := range [][]interface{}{
+		nil,
+		{"dialTimeout"},
+		{"dialTimeout", "fast"},
+		{"dialTimeout", "-1s"},
+		{"dialTimeout", "0"},
+		{"readTimeout", "1s"},
+		{42.0, "1s"},
+		{"maxIdleConnsPerHost", 2.5},
+		{"maxIdleConnsPerHost", 0.0},
+		{"maxIdleConnsPerHost", "many"},
+		{"maxConnsPerHost", -1.0},
+		{"responseHeaderTimeout", 60.0},
+	} {
+		if _, err := NewBackendTransport().CreateFilter(args); err == nil {
+			t.Errorf("%v: failed to fail", args)
+		}
+	}
+}
+
+func TestSettings(t *testing.T) {
+	f, err := NewBackendTransport().CreateFilter([]interface{}{
+		"dialTimeout", "100ms",
+		"tlsHandshakeTimeout", "200ms",
+		"responseHeaderTimeout", "5m",
+		"expectContinueTimeout", "1s",
+		"idleConnTimeout", "30s",
+		"maxIdleConnsPerHost", 16.0,
+		"maxConnsPerHost", 32.0,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := &filtertest.Context{FStateBag: make(map[string]interface{})}
+	f.Request(ctx)
+
+	expected := Settings{
+		DialTimeout:           100 * time.Millisecond,
+		TLSHandshakeTimeout:   200 * time.Millisecond,
+		ResponseHeaderTimeout: 5 * time.Minute,
+		ExpectContinueTimeout: time.Second,
+		IdleConnTimeout:       30 * time.Second,
+		MaxIdleConnsPerHost:   16,
+		MaxConnsPerHost:       32,
+	}
+
+	if s := ctx.FStateBag[filters.BackendTransportKey]; s != expected {
+		t.Errorf("unexpected settings: %+v", s)
+	}
+
+	f, err = NewBackendTransport().CreateFilter([]interface{}{"maxIdleConnsPerHost", "4"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f.Request(ctx)
+	if s := ctx.FStateBag[filters.BackendTransportKey]; s != (Settings{MaxIdleConnsPerHost: 4}) {
+		t.Errorf("unexpected settings: %+v", s)
+	}
+}
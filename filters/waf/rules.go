@@ -0,0 +1,969 @@
+package waf
+
+import (
+	"bufio"
+	"encoding/base64"
+	"fmt"
+	"html"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+type phase int
+
+const (
+	phaseHeaders phase = 1
+	phaseBody    phase = 2
+)
+
+// the value of a variable, e.g. a single header
+type target struct {
+	name  string
+	value string
+}
+
+// a variable of a rule. The key selects a single member of a collection,
+// or, when enclosed in slashes, the members matching a regular expression.
+// The counted variables evaluate to the number of the selected members, and
+// the excluded variables remove the selected members from the preceding
+// variables of the rule.
+type variable struct {
+	collection string
+	key        string
+	keyRx      *regexp.Regexp
+	count      bool
+	exclude    bool
+}
+
+// the operators with an argument containing macros, e.g.
+// @ge %{tx.inbound_anomaly_score_threshold}, are created during the
+// evaluation
+type operator struct {
+	name    string
+	arg     string
+	negate  bool
+	dynamic bool
+	match   func(string) bool
+}
+
+// sets, increments, decrements or deletes a transaction variable
+type setvar struct {
+	name  string
+	op    byte
+	value string
+}
+
+// a single parsed SecRule or SecAction, or a SecMarker
+type rule struct {
+	id            int
+	msg           string
+	phase         phase
+	variables     []variable
+	operator      operator
+	unconditional bool
+	transforms    []func(string) string
+	deny          bool
+	block         bool
+	status        int
+	nolog         bool
+	setvars       []setvar
+	skipAfter     string
+	marker        string
+
+	// the rules chained to this rule, all of them need to match
+	chain     []*rule
+	chainNext bool
+}
+
+type ruleSet struct {
+	rules []*rule
+}
+
+// the actions without effect on the evaluation
+var ignoredActions = map[string]bool{
+	"accuracy":   true,
+	"auditlog":   true,
+	"capture":    true,
+	"logdata":    true,
+	"maturity":   true,
+	"multiMatch": true,
+	"noauditlog": true,
+	"rev":        true,
+	"severity":   true,
+	"tag":        true,
+	"ver":        true,
+}
+
+var macroRx = regexp.MustCompile(`%\{([^}]*)\}`)
+
+var collections = map[string]bool{
+	"ARGS":                  true,
+	"ARGS_GET":              true,
+	"ARGS_NAMES":            true,
+	"ARGS_POST":             true,
+	"QUERY_STRING":          true,
+	"REQUEST_BASENAME":      true,
+	"REQUEST_BODY":          true,
+	"REQUEST_COOKIES":       true,
+	"REQUEST_COOKIES_NAMES": true,
+	"REQUEST_FILENAME":      true,
+	"REQUEST_HEADERS":       true,
+	"REQUEST_HEADERS_NAMES": true,
+	"REQUEST_LINE":          true,
+	"REQUEST_METHOD":        true,
+	"REQUEST_PROTOCOL":      true,
+	"REQUEST_URI":           true,
+	"TX":                    true,
+}
+
+var transforms = map[string]func(string) string{
+	"base64Decode": func(s string) string {
+		if b, err := base64.StdEncoding.DecodeString(s); err == nil {
+			return string(b)
+		}
+
+		return s
+	},
+	"compressWhitespace": func(s string) string { return strings.Join(strings.Fields(s), " ") },
+	"htmlEntityDecode":   html.UnescapeString,
+	"lowercase":          strings.ToLower,
+	"removeNulls":        func(s string) string { return strings.ReplaceAll(s, "\x00", "") },
+	"removeWhitespace": func(s string) string {
+		return strings.Map(func(r rune) rune {
+			if unicode.IsSpace(r) {
+				return -1
+			}
+
+			return r
+		}, s)
+	},
+	"trim":         strings.TrimSpace,
+	"urlDecode":    urlDecode,
+	"urlDecodeUni": urlDecode,
+}
+
+func urlDecode(s string) string {
+	if d, err := url.QueryUnescape(s); err == nil {
+		return d
+	}
+
+	return s
+}
+
+// splits a directive line to its arguments, respecting the double
+// quotes
+func splitDirective(line string) ([]string, error) {
+	var (
+		args    []string
+		current strings.Builder
+		quoted  bool
+		inArg   bool
+	)
+
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case c == '\\' && i+1 < len(line) && line[i+1] == '"':
+			current.WriteByte('"')
+			inArg = true
+			i++
+		case c == '"':
+			quoted = !quoted
+			inArg = true
+		case !quoted && (c == ' ' || c == '\t'):
+			if inArg {
+				args = append(args, current.String())
+				current.Reset()
+				inArg = false
+			}
+		default:
+			current.WriteByte(c)
+			inArg = true
+		}
+	}
+
+	if quoted {
+		return nil, fmt.Errorf("unterminated quote")
+	}
+
+	if inArg {
+		args = append(args, current.String())
+	}
+
+	return args, nil
+}
+
+// splits the actions by the commas outside of the single quotes
+func splitActions(s string) []string {
+	var (
+		actions []string
+		start   int
+		quoted  bool
+	)
+
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\'':
+			quoted = !quoted
+		case ',':
+			if !quoted {
+				actions = append(actions, strings.TrimSpace(s[start:i]))
+				start = i + 1
+			}
+		}
+	}
+
+	return append(actions, strings.TrimSpace(s[start:]))
+}
+
+// checks that the macros are supported: the transaction variables, the
+// id and the message of the rule, and the matched variable
+func checkMacros(s string) error {
+	for _, m := range macroRx.FindAllStringSubmatch(s, -1) {
+		name := strings.ToLower(m[1])
+		switch {
+		case strings.HasPrefix(name, "tx.") && len(name) > 3:
+		case name == "rule.id", name == "rule.msg", name == "matched_var", name == "matched_var_name":
+		default:
+			return fmt.Errorf("unsupported macro: %s", m[0])
+		}
+	}
+
+	return nil
+}
+
+func parseVariables(s string) ([]variable, error) {
+	var vars []variable
+	for _, v := range strings.Split(s, "|") {
+		var count, exclude bool
+		name := v
+		switch {
+		case strings.HasPrefix(name, "&"):
+			count = true
+			name = name[1:]
+		case strings.HasPrefix(name, "!"):
+			exclude = true
+			name = name[1:]
+		}
+
+		collection, key, _ := strings.Cut(name, ":")
+		collection = strings.ToUpper(collection)
+		if !collections[collection] || exclude && (key == "" || len(vars) == 0) {
+			return nil, fmt.Errorf("unsupported variable: %s", v)
+		}
+
+		va := variable{collection: collection, key: key, count: count, exclude: exclude}
+		if len(key) > 1 && strings.HasPrefix(key, "/") && strings.HasSuffix(key, "/") {
+			rx, err := regexp.Compile(key[1 : len(key)-1])
+			if err != nil {
+				return nil, err
+			}
+
+			va.key, va.keyRx = "", rx
+		}
+
+		vars = append(vars, va)
+	}
+
+	return vars, nil
+}
+
+func parseOperator(s string) (operator, error) {
+	var op operator
+	if strings.HasPrefix(s, "!") {
+		op.negate = true
+		s = s[1:]
+	}
+
+	if !strings.HasPrefix(s, "@") {
+		op.name, op.arg = "rx", s
+	} else {
+		op.name, op.arg, _ = strings.Cut(s[1:], " ")
+	}
+
+	if op.name != "rx" && op.name != "pm" && strings.Contains(op.arg, "%{") {
+		if err := checkMacros(op.arg); err != nil {
+			return op, err
+		}
+
+		// checks only the operator name
+		_, err := newMatcher(op.name, "0")
+		op.dynamic = true
+		return op, err
+	}
+
+	var err error
+	op.match, err = newMatcher(op.name, op.arg)
+	return op, err
+}
+
+func newMatcher(name, arg string) (func(string) bool, error) {
+	var match func(string) bool
+	switch name {
+	case "rx":
+		rx, err := regexp.Compile(arg)
+		if err != nil {
+			return nil, err
+		}
+
+		match = rx.MatchString
+	case "pm":
+		phrases := strings.Fields(strings.ToLower(arg))
+		match = func(v string) bool {
+			v = strings.ToLower(v)
+			for _, p := range phrases {
+				if strings.Contains(v, p) {
+					return true
+				}
+			}
+
+			return false
+		}
+	case "contains":
+		match = func(v string) bool { return strings.Contains(v, arg) }
+	case "streq":
+		match = func(v string) bool { return v == arg }
+	case "beginsWith":
+		match = func(v string) bool { return strings.HasPrefix(v, arg) }
+	case "endsWith":
+		match = func(v string) bool { return strings.HasSuffix(v, arg) }
+	case "within":
+		match = func(v string) bool { return strings.Contains(arg, v) }
+	case "unconditionalMatch":
+		match = func(string) bool { return true }
+	case "eq", "gt", "ge", "lt", "le":
+		n, err := strconv.Atoi(strings.TrimSpace(arg))
+		if err != nil {
+			return nil, fmt.Errorf("invalid numeric argument: %s", arg)
+		}
+
+		match = func(v string) bool {
+			i, err := strconv.Atoi(strings.TrimSpace(v))
+			if err != nil {
+				return false
+			}
+
+			switch name {
+			case "eq":
+				return i == n
+			case "gt":
+				return i > n
+			case "ge":
+				return i >= n
+			case "lt":
+				return i < n
+			default:
+				return i <= n
+			}
+		}
+	default:
+		return nil, fmt.Errorf("unsupported operator: @%s", name)
+	}
+
+	return match, nil
+}
+
+func parseSetvar(s string) (setvar, error) {
+	var sv setvar
+	if strings.HasPrefix(s, "!") {
+		sv.op = '!'
+		s = s[1:]
+	}
+
+	name, value, hasValue := strings.Cut(s, "=")
+	collection, key, _ := strings.Cut(name, ".")
+	if !strings.EqualFold(collection, "tx") || key == "" || sv.op == '!' && hasValue {
+		return sv, fmt.Errorf("unsupported setvar: %s", s)
+	}
+
+	if err := checkMacros(s); err != nil {
+		return sv, err
+	}
+
+	sv.name = key
+	if sv.op == '!' {
+		return sv, nil
+	}
+
+	sv.op = '='
+	if !hasValue {
+		sv.value = "1"
+		return sv, nil
+	}
+
+	if strings.HasPrefix(value, "+") || strings.HasPrefix(value, "-") {
+		sv.op = value[0]
+		value = value[1:]
+	}
+
+	sv.value = value
+	return sv, nil
+}
+
+// parses the actions of a rule, and of the SecDefaultAction directive
+func parseActions(r *rule, s string) error {
+	for _, a := range splitActions(s) {
+		if a == "" {
+			continue
+		}
+
+		name, value, _ := strings.Cut(a, ":")
+		value = strings.Trim(value, "'")
+		switch name {
+		case "id":
+			id, err := strconv.Atoi(value)
+			if err != nil || id <= 0 {
+				return fmt.Errorf("invalid rule id: %s", value)
+			}
+
+			r.id = id
+		case "msg":
+			if err := checkMacros(value); err != nil {
+				return err
+			}
+
+			r.msg = value
+		case "phase":
+			switch value {
+			case "1":
+				r.phase = phaseHeaders
+			case "2", "request":
+				r.phase = phaseBody
+			default:
+				return fmt.Errorf("unsupported phase: %s", value)
+			}
+		case "t":
+			if value == "none" {
+				r.transforms = nil
+				continue
+			}
+
+			t, ok := transforms[value]
+			if !ok {
+				return fmt.Errorf("unsupported transformation: %s", value)
+			}
+
+			r.transforms = append(r.transforms, t)
+		case "deny", "drop":
+			r.deny = true
+			r.block = false
+		case "block":
+			r.block = true
+		case "pass":
+			r.deny = false
+			r.block = false
+		case "log":
+			r.nolog = false
+		case "nolog":
+			r.nolog = true
+		case "chain":
+			r.chainNext = true
+		case "setvar":
+			sv, err := parseSetvar(value)
+			if err != nil {
+				return err
+			}
+
+			r.setvars = append(r.setvars, sv)
+		case "skipAfter":
+			if value == "" {
+				return fmt.Errorf("missing marker")
+			}
+
+			r.skipAfter = value
+		case "status":
+			status, err := strconv.Atoi(value)
+			if err != nil || status < 100 || status > 599 {
+				return fmt.Errorf("invalid status: %s", value)
+			}
+
+			r.status = status
+		default:
+			if !ignoredActions[name] {
+				return fmt.Errorf("unsupported action: %s", name)
+			}
+		}
+	}
+
+	return nil
+}
+
+func parseRule(args []string) (*rule, error) {
+	// the actions are optional in the chained rules
+	if len(args) != 3 && len(args) != 4 {
+		return nil, fmt.Errorf("expected variables, operator and actions")
+	}
+
+	vars, err := parseVariables(args[1])
+	if err != nil {
+		return nil, err
+	}
+
+	op, err := parseOperator(args[2])
+	if err != nil {
+		return nil, err
+	}
+
+	r := &rule{phase: phaseBody, variables: vars, operator: op}
+	if len(args) == 3 {
+		return r, nil
+	}
+
+	if err := parseActions(r, args[3]); err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+// parses a SecAction directive, a rule matching every request
+func parseAction(args []string) (*rule, error) {
+	if len(args) != 2 {
+		return nil, fmt.Errorf("expected actions")
+	}
+
+	r := &rule{phase: phaseBody, unconditional: true}
+	if err := parseActions(r, args[1]); err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+// parseRules parses a rule set in the SecRule format. Only a subset of
+// the ModSecurity rule language is supported, the unsupported
+// directives, variables, operators and actions result in an error.
+//
+// The rules with the block action follow the SecDefaultAction of their
+// phase, and without it, they deny the request. The markers of the
+// SecMarker directive are added to both phases, because the rules are
+// ordered by phase, and the skipAfter action skips the rules of the same
+// phase.
+func parseRules(r io.Reader) (*ruleSet, error) {
+	var (
+		rs       ruleSet
+		line     strings.Builder
+		lineNum  int
+		start    int
+		chaining *rule
+	)
+
+	ids := make(map[int]bool)
+	blockDenies := map[phase]bool{phaseHeaders: true, phaseBody: true}
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 1<<20)
+	for scanner.Scan() {
+		lineNum++
+		l := strings.TrimSpace(scanner.Text())
+		if line.Len() == 0 {
+			start = lineNum
+			if l == "" || strings.HasPrefix(l, "#") {
+				continue
+			}
+		}
+
+		if strings.HasSuffix(l, "\\") {
+			line.WriteString(strings.TrimSuffix(l, "\\"))
+			line.WriteByte(' ')
+			continue
+		}
+
+		line.WriteString(l)
+		args, err := splitDirective(line.String())
+		line.Reset()
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", start, err)
+		}
+
+		if chaining != nil && args[0] != "SecRule" && args[0] != "SecAction" {
+			return nil, fmt.Errorf("line %d: unterminated chain", start)
+		}
+
+		switch args[0] {
+		case "SecRule", "SecAction":
+			var (
+				rule *rule
+				err  error
+			)
+
+			if args[0] == "SecRule" {
+				rule, err = parseRule(args)
+			} else {
+				rule, err = parseAction(args)
+			}
+
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", start, err)
+			}
+
+			if chaining != nil {
+				if rule.id != 0 || rule.deny || rule.block || rule.skipAfter != "" {
+					return nil, fmt.Errorf("line %d: chained rule with id or flow action", start)
+				}
+
+				rule.phase = chaining.phase
+				chaining.chain = append(chaining.chain, rule)
+				if !rule.chainNext {
+					chaining = nil
+				}
+
+				continue
+			}
+
+			if rule.id == 0 {
+				return nil, fmt.Errorf("line %d: missing rule id", start)
+			}
+
+			if ids[rule.id] {
+				return nil, fmt.Errorf("line %d: duplicate rule id: %d", start, rule.id)
+			}
+
+			if rule.block {
+				rule.deny = blockDenies[rule.phase]
+			}
+
+			ids[rule.id] = true
+			rs.rules = append(rs.rules, rule)
+			if rule.chainNext {
+				chaining = rule
+			}
+		case "SecMarker":
+			if len(args) != 2 || args[1] == "" {
+				return nil, fmt.Errorf("line %d: expected marker", start)
+			}
+
+			rs.rules = append(rs.rules, &rule{marker: args[1], phase: phaseHeaders}, &rule{marker: args[1], phase: phaseBody})
+		case "SecDefaultAction":
+			d := &rule{phase: phaseBody}
+			if len(args) != 2 {
+				return nil, fmt.Errorf("line %d: expected actions", start)
+			}
+
+			if err := parseActions(d, args[1]); err != nil {
+				return nil, fmt.Errorf("line %d: %w", start, err)
+			}
+
+			blockDenies[d.phase] = d.deny
+		case "SecRuleEngine", "SecRequestBodyAccess", "SecComponentSignature":
+			// the mode and the body access are configured by the filter
+		default:
+			return nil, fmt.Errorf("line %d: unsupported directive: %s", start, args[0])
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	if line.Len() > 0 {
+		return nil, fmt.Errorf("line %d: unterminated directive", start)
+	}
+
+	if chaining != nil {
+		return nil, fmt.Errorf("rule %d: unterminated chain", chaining.id)
+	}
+
+	// the header rules are evaluated before the body is read
+	sort.SliceStable(rs.rules, func(i, j int) bool { return rs.rules[i].phase < rs.rules[j].phase })
+	return &rs, nil
+}
+
+// request holds the inspected parts of an HTTP request, and the
+// transaction variables set by the rules
+type request struct {
+	req      *http.Request
+	body     []byte
+	bodyArgs url.Values
+	tx       map[string]string
+}
+
+func namedValues(prefix string, values url.Values, key string) []target {
+	var t []target
+	for name, vs := range values {
+		if key != "" && name != key {
+			continue
+		}
+
+		for _, v := range vs {
+			t = append(t, target{name: prefix + ":" + name, value: v})
+		}
+	}
+
+	return t
+}
+
+func names(collection string, keys []string) []target {
+	t := make([]target, len(keys))
+	for i, k := range keys {
+		t[i] = target{name: collection, value: k}
+	}
+
+	return t
+}
+
+func (r *request) targets(v variable) []target {
+	req := r.req
+	switch v.collection {
+	case "ARGS":
+		return append(
+			namedValues("ARGS", req.URL.Query(), v.key),
+			namedValues("ARGS", r.bodyArgs, v.key)...,
+		)
+	case "ARGS_GET":
+		return namedValues("ARGS_GET", req.URL.Query(), v.key)
+	case "ARGS_POST":
+		return namedValues("ARGS_POST", r.bodyArgs, v.key)
+	case "ARGS_NAMES":
+		var keys []string
+		for k := range req.URL.Query() {
+			keys = append(keys, k)
+		}
+
+		for k := range r.bodyArgs {
+			keys = append(keys, k)
+		}
+
+		return names(v.collection, keys)
+	case "QUERY_STRING":
+		return []target{{name: v.collection, value: req.URL.RawQuery}}
+	case "REQUEST_BASENAME":
+		p := req.URL.Path
+		return []target{{name: v.collection, value: p[strings.LastIndex(p, "/")+1:]}}
+	case "REQUEST_BODY":
+		if len(r.body) == 0 {
+			return nil
+		}
+
+		return []target{{name: v.collection, value: string(r.body)}}
+	case "REQUEST_COOKIES", "REQUEST_COOKIES_NAMES":
+		var t []target
+		for _, c := range req.Cookies() {
+			if v.key != "" && c.Name != v.key {
+				continue
+			}
+
+			if v.collection == "REQUEST_COOKIES" {
+				t = append(t, target{name: "REQUEST_COOKIES:" + c.Name, value: c.Value})
+			} else {
+				t = append(t, target{name: v.collection, value: c.Name})
+			}
+		}
+
+		return t
+	case "REQUEST_FILENAME":
+		return []target{{name: v.collection, value: req.URL.Path}}
+	case "REQUEST_HEADERS":
+		var t []target
+		key := http.CanonicalHeaderKey(v.key)
+		for name, vs := range req.Header {
+			if key != "" && name != key {
+				continue
+			}
+
+			for _, hv := range vs {
+				t = append(t, target{name: "REQUEST_HEADERS:" + name, value: hv})
+			}
+		}
+
+		if host := req.Host; host != "" && (key == "" || key == "Host") {
+			t = append(t, target{name: "REQUEST_HEADERS:Host", value: host})
+		}
+
+		return t
+	case "REQUEST_HEADERS_NAMES":
+		var keys []string
+		for k := range req.Header {
+			keys = append(keys, k)
+		}
+
+		return names(v.collection, keys)
+	case "REQUEST_LINE":
+		uri := req.RequestURI
+		if uri == "" {
+			uri = req.URL.RequestURI()
+		}
+
+		return []target{{name: v.collection, value: req.Method + " " + uri + " " + req.Proto}}
+	case "REQUEST_METHOD":
+		return []target{{name: v.collection, value: req.Method}}
+	case "REQUEST_PROTOCOL":
+		return []target{{name: v.collection, value: req.Proto}}
+	case "REQUEST_URI":
+		return []target{{name: v.collection, value: req.URL.RequestURI()}}
+	case "TX":
+		var t []target
+		for name, value := range r.tx {
+			if v.key == "" || strings.EqualFold(v.key, name) {
+				t = append(t, target{name: "TX:" + name, value: value})
+			}
+		}
+
+		return t
+	default:
+		return nil
+	}
+}
+
+// returns the members of the collection selected by the key of the
+// variable, or by the count of them
+func (r *request) selected(v variable) []target {
+	t := r.targets(variable{collection: v.collection, key: v.key})
+	if v.keyRx != nil {
+		var matching []target
+		for _, ti := range t {
+			if _, key, _ := strings.Cut(ti.name, ":"); v.keyRx.MatchString(key) {
+				matching = append(matching, ti)
+			}
+		}
+
+		t = matching
+	}
+
+	if v.count {
+		name := "&" + v.collection
+		if v.key != "" {
+			name += ":" + v.key
+		}
+
+		return []target{{name: name, value: strconv.Itoa(len(t))}}
+	}
+
+	return t
+}
+
+// replaces the macros with the transaction variables, the id and the
+// message of the rule, or the matched variable
+func (r *request) expand(s string, rl *rule, matched target) string {
+	if !strings.Contains(s, "%{") {
+		return s
+	}
+
+	return macroRx.ReplaceAllStringFunc(s, func(m string) string {
+		name := strings.ToLower(m[2 : len(m)-1])
+		switch {
+		case strings.HasPrefix(name, "tx."):
+			return r.tx[name[3:]]
+		case name == "rule.id":
+			return strconv.Itoa(rl.id)
+		case name == "rule.msg":
+			return rl.msg
+		case name == "matched_var":
+			return matched.value
+		case name == "matched_var_name":
+			return matched.name
+		default:
+			return ""
+		}
+	})
+}
+
+func (op operator) matches(r *request, rl *rule, value string) bool {
+	match := op.match
+	if op.dynamic {
+		var err error
+		if match, err = newMatcher(op.name, r.expand(op.arg, rl, target{})); err != nil {
+			return false
+		}
+	}
+
+	return match(value) != op.negate
+}
+
+// evaluates a single rule, without the chained rules, and returns the
+// matching variable
+func (rl *rule) eval(r *request) (target, bool) {
+	if rl.unconditional {
+		return target{}, true
+	}
+
+	var excluded map[string]bool
+	for _, v := range rl.variables {
+		if !v.exclude {
+			continue
+		}
+
+		if excluded == nil {
+			excluded = make(map[string]bool)
+		}
+
+		for _, t := range r.selected(v) {
+			excluded[t.name] = true
+		}
+	}
+
+	for _, v := range rl.variables {
+		if v.exclude {
+			continue
+		}
+
+		for _, t := range r.selected(v) {
+			if excluded[t.name] {
+				continue
+			}
+
+			value := t.value
+			for _, tf := range rl.transforms {
+				value = tf(value)
+			}
+
+			if rl.operator.matches(r, rl, value) {
+				return target{name: t.name, value: t.value}, true
+			}
+		}
+	}
+
+	return target{}, false
+}
+
+// evaluates a rule with the chained rules, and when all of them match,
+// applies their setvar actions. It returns the variable matched by the
+// first rule.
+func (rl *rule) evalChain(r *request) (target, bool) {
+	matched, ok := rl.eval(r)
+	if !ok {
+		return target{}, false
+	}
+
+	for _, c := range rl.chain {
+		if _, ok := c.eval(r); !ok {
+			return target{}, false
+		}
+	}
+
+	rl.apply(r, rl, matched)
+	for _, c := range rl.chain {
+		c.apply(r, rl, matched)
+	}
+
+	return matched, true
+}
+
+func (rl *rule) apply(r *request, chainStart *rule, matched target) {
+	for _, sv := range rl.setvars {
+		name := strings.ToLower(r.expand(sv.name, chainStart, matched))
+		switch sv.op {
+		case '!':
+			delete(r.tx, name)
+		case '=':
+			r.tx[name] = r.expand(sv.value, chainStart, matched)
+		default:
+			d, err := strconv.Atoi(strings.TrimSpace(r.expand(sv.value, chainStart, matched)))
+			if err != nil {
+				continue
+			}
+
+			if sv.op == '-' {
+				d = -d
+			}
+
+			current, _ := strconv.Atoi(r.tx[name])
+			r.tx[name] = strconv.Itoa(current + d)
+		}
+	}
+}
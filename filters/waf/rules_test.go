@@ -0,0 +1,265 @@
+package waf
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestParseRules(t *testing.T) {
+	for _, tt := range []struct {
+		name  string
+		rules string
+		err   bool
+		ids   []int
+	}{{
+		name: "comments and empty lines",
+		rules: `
+# scanners
+SecRuleEngine On
+
+SecRule REQUEST_HEADERS:User-Agent "@pm sqlmap nikto" "id:1,phase:1,deny,msg:'scanner, detected'"
+`,
+		ids: []int{1},
+	}, {
+		name: "continuation lines",
+		rules: `SecRule ARGS "@rx union\s+select" \
+	"id:2,\
+	t:lowercase,deny"`,
+		ids: []int{2},
+	}, {
+		name: "header rules first",
+		rules: `SecRule REQUEST_BODY "foo" "id:3,phase:2"
+SecRule REQUEST_URI "foo" "id:4,phase:1"`,
+		ids: []int{4, 3},
+	}, {
+		name:  "missing id",
+		rules: `SecRule ARGS "foo" "deny"`,
+		err:   true,
+	}, {
+		name:  "missing actions",
+		rules: `SecRule ARGS "foo"`,
+		err:   true,
+	}, {
+		name: "duplicate id",
+		rules: `SecRule ARGS "foo" "id:5"
+SecRule ARGS "bar" "id:5"`,
+		err: true,
+	}, {
+		name:  "unsupported directive",
+		rules: `SecAuditEngine On`,
+		err:   true,
+	}, {
+		name: "chain and markers",
+		rules: `SecAction "id:6,phase:1,pass,nolog,setvar:tx.score=0"
+SecRule REQUEST_METHOD "@streq GET" "id:14,chain"
+	SecRule ARGS "foo" "setvar:tx.score=+1"
+SecMarker "END"`,
+		ids: []int{6, 0, 14, 0},
+	}, {
+		name:  "unterminated chain",
+		rules: `SecRule REQUEST_METHOD "@streq GET" "id:15,chain"`,
+		err:   true,
+	}, {
+		name: "chained rule with id",
+		rules: `SecRule REQUEST_METHOD "@streq GET" "id:16,chain"
+SecRule ARGS "foo" "id:17"`,
+		err: true,
+	}, {
+		name:  "unsupported setvar collection",
+		rules: `SecRule ARGS "foo" "id:18,setvar:ip.score=+1"`,
+		err:   true,
+	}, {
+		name:  "unsupported macro",
+		rules: `SecRule ARGS "@eq %{request_headers.host}" "id:19"`,
+		err:   true,
+	}, {
+		name:  "unsupported variable",
+		rules: `SecRule RESPONSE_BODY "foo" "id:7"`,
+		err:   true,
+	}, {
+		name:  "unsupported operator",
+		rules: `SecRule ARGS "@detectSQLi" "id:8"`,
+		err:   true,
+	}, {
+		name:  "unsupported action",
+		rules: `SecRule ARGS "foo" "id:9,ctl:ruleRemoveById=1"`,
+		err:   true,
+	}, {
+		name:  "unsupported transformation",
+		rules: `SecRule ARGS "foo" "id:10,t:sqlHexDecode"`,
+		err:   true,
+	}, {
+		name:  "unsupported phase",
+		rules: `SecRule ARGS "foo" "id:11,phase:4"`,
+		err:   true,
+	}, {
+		name:  "invalid regexp",
+		rules: `SecRule ARGS "(?=foo)" "id:12"`,
+		err:   true,
+	}, {
+		name:  "unterminated quote",
+		rules: `SecRule ARGS "foo "id:13"`,
+		err:   true,
+	}} {
+		t.Run(tt.name, func(t *testing.T) {
+			rs, err := parseRules(strings.NewReader(tt.rules))
+			if tt.err {
+				if err == nil {
+					t.Fatal("failed to fail")
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(rs.rules) != len(tt.ids) {
+				t.Fatalf("unexpected number of rules: %d", len(rs.rules))
+			}
+
+			for i, id := range tt.ids {
+				if rs.rules[i].id != id {
+					t.Errorf("unexpected rule id: %d, expected: %d", rs.rules[i].id, id)
+				}
+			}
+		})
+	}
+}
+
+func TestSetvar(t *testing.T) {
+	rs, err := parseRules(strings.NewReader(`
+SecAction "id:1,phase:1,setvar:tx.score=2,setvar:tx.flag,setvar:tx.removed=1"
+SecRule ARGS "foo" "id:2,phase:1,chain,setvar:'tx.score=+%{tx.score}'"
+	SecRule REQUEST_METHOD "GET" "setvar:tx.score=-1,setvar:!tx.removed,setvar:'tx.%{rule.id}-%{matched_var_name}=%{matched_var}'"
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := &request{req: httptest.NewRequest("GET", "/?q=foo", nil), tx: make(map[string]string)}
+	for _, rl := range rs.rules {
+		rl.evalChain(r)
+	}
+
+	expected := map[string]string{"score": "3", "flag": "1", "2-args:q": "foo"}
+	if len(r.tx) != len(expected) {
+		t.Errorf("unexpected transaction variables: %v", r.tx)
+	}
+
+	for k, v := range expected {
+		if r.tx[k] != v {
+			t.Errorf("unexpected transaction variable %s: %q, expected: %q", k, r.tx[k], v)
+		}
+	}
+}
+
+func TestEvalRules(t *testing.T) {
+	for _, tt := range []struct {
+		name     string
+		rule     string
+		url      string
+		header   http.Header
+		body     string
+		variable string
+	}{{
+		name:     "regexp on the args",
+		rule:     `SecRule ARGS "(?i)union\s+select" "id:1"`,
+		url:      "/search?q=1+UNION+SELECT+password",
+		variable: "ARGS:q",
+	}, {
+		name: "no match",
+		rule: `SecRule ARGS "(?i)union\s+select" "id:1"`,
+		url:  "/search?q=unions",
+	}, {
+		name:     "phrase match on a header",
+		rule:     `SecRule REQUEST_HEADERS:User-Agent "@pm sqlmap nikto" "id:1"`,
+		url:      "/",
+		header:   http.Header{"User-Agent": []string{"Mozilla/5.0 (Nikto/2.1.6)"}},
+		variable: "REQUEST_HEADERS:User-Agent",
+	}, {
+		name:     "transformations",
+		rule:     `SecRule REQUEST_URI "@contains <script>" "id:1,t:urlDecode,t:lowercase"`,
+		url:      "/?x=%3CSCRIPT%3E",
+		variable: "REQUEST_URI",
+	}, {
+		name:     "negated operator",
+		rule:     `SecRule REQUEST_METHOD "!@within HEAD POST PUT" "id:1"`,
+		url:      "/",
+		variable: "REQUEST_METHOD",
+	}, {
+		name:     "numeric operator",
+		rule:     `SecRule REQUEST_HEADERS:Content-Length "@gt 10" "id:1"`,
+		url:      "/",
+		header:   http.Header{"Content-Length": []string{"42"}},
+		variable: "REQUEST_HEADERS:Content-Length",
+	}, {
+		name:     "form body args",
+		rule:     `SecRule ARGS_POST:comment "@contains <script" "id:1"`,
+		url:      "/",
+		body:     "comment=%3Cscript%3Ealert(1)",
+		variable: "ARGS_POST:comment",
+	}, {
+		name:     "cookies",
+		rule:     `SecRule REQUEST_COOKIES "@beginsWith ../" "id:1"`,
+		url:      "/",
+		header:   http.Header{"Cookie": []string{"path=../etc/passwd"}},
+		variable: "REQUEST_COOKIES:path",
+	}, {
+		name:   "excluded cookie",
+		rule:   `SecRule REQUEST_COOKIES|!REQUEST_COOKIES:/^__utm/ "@beginsWith ../" "id:1"`,
+		url:    "/",
+		header: http.Header{"Cookie": []string{"__utmz=../etc/passwd"}},
+	}, {
+		name:     "counted variable",
+		rule:     `SecRule &REQUEST_HEADERS:X-Forwarded-For "@gt 1" "id:1"`,
+		url:      "/",
+		header:   http.Header{"X-Forwarded-For": []string{"10.0.0.1", "10.0.0.2"}},
+		variable: "&REQUEST_HEADERS:X-Forwarded-For",
+	}, {
+		name:     "transaction variable with macro",
+		rule:     `SecRule TX:SCORE "@lt %{tx.score}0" "id:1"`,
+		url:      "/",
+		variable: "TX:score",
+	}, {
+		name: "chain",
+		rule: `SecRule REQUEST_METHOD "@streq GET" "id:1,chain"
+SecRule ARGS "foo"`,
+		url: "/?q=bar",
+	}, {
+		name: "matching chain",
+		rule: `SecRule REQUEST_METHOD "@streq GET" "id:1,chain"
+SecRule ARGS "foo"`,
+		url:      "/?q=foo",
+		variable: "REQUEST_METHOD",
+	}} {
+		t.Run(tt.name, func(t *testing.T) {
+			rs, err := parseRules(strings.NewReader(tt.rule))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			method := "GET"
+			if tt.body != "" {
+				method = "PUT"
+			}
+
+			req := httptest.NewRequest(method, tt.url, nil)
+			for k, v := range tt.header {
+				req.Header[k] = v
+			}
+
+			r := &request{req: req, body: []byte(tt.body), tx: map[string]string{"score": "3"}}
+			r.bodyArgs, _ = url.ParseQuery(tt.body)
+
+			matched, ok := rs.rules[0].evalChain(r)
+			if ok != (tt.variable != "") || matched.name != tt.variable {
+				t.Errorf("unexpected result: %t, %s", ok, matched.name)
+			}
+		})
+	}
+}
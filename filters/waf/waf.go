@@ -0,0 +1,344 @@
+/*
+Package waf provides the waf filter, a web application firewall
+evaluating a rule set against the request line, the headers and the
+beginning of the body of the requests.
+
+The rules are loaded from files in the SecRule format of ModSecurity and
+Coraza. Only a subset of the rule language is supported, enough to run the
+anomaly scoring rule sets, e.g. a subset of the OWASP Core Rule Set, with
+chained rules, setvar on the TX collection, SecAction and SecMarker. The
+files containing unsupported directives, variables, operators,
+transformations or actions, e.g. ctl, are rejected:
+
+	SecRule REQUEST_HEADERS:User-Agent "@pm sqlmap nikto" "id:913100,phase:1,deny,status:403,msg:'Security scanner'"
+	SecRule ARGS "@rx (?i)union\s+select" "id:942100,phase:2,t:urlDecode,block,setvar:'tx.anomaly_score=+5'"
+	SecRule TX:ANOMALY_SCORE "@ge 5" "id:949110,phase:2,deny,msg:'Anomaly score exceeded'"
+
+The filter runs in blocking mode by default, where the requests matching
+a rule with a deny or block action are rejected. In detection mode, the
+matches are only logged and counted:
+
+	r: * -> waf("/etc/skipper/waf/rules.conf") -> "https://app.example.org";
+	r: * -> waf("/etc/skipper/waf/rules.conf", "detect") -> "https://app.example.org";
+
+Individual rules or ranges of rules can be excluded per route:
+
+	r: Path("/search") -> waf("/etc/skipper/waf/rules.conf", "block", 942100, "920000-920999") -> "https://app.example.org";
+
+The matches are counted per rule ID with the waf.rule.<id>.detected and
+waf.rule.<id>.blocked filter metrics.
+*/
+package waf
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/zalando/skipper/filters"
+	"github.com/zalando/skipper/logging/eventsink"
+)
+
+const (
+	blockMode  = "block"
+	detectMode = "detect"
+
+	defaultMaxBodySize = 8192
+)
+
+// Options for the waf filter specification.
+type Options struct {
+
+	// MaxBodySize sets how many bytes of the request bodies are
+	// inspected. The rest of the body is passed to the backend
+	// without inspection. Defaults to 8192.
+	MaxBodySize int64
+
+	// Sink, when set, receives the detections as events.
+	Sink eventsink.Sink
+}
+
+type cachedRuleSet struct {
+	modTime time.Time
+	rules   *ruleSet
+}
+
+type spec struct {
+	options Options
+	mu      sync.Mutex
+	cache   map[string]cachedRuleSet
+}
+
+type idRange struct {
+	from, to int
+}
+
+type filter struct {
+	options     Options
+	rules       []*rule
+	detect      bool
+	inspectBody bool
+}
+
+// New creates the waf filter specification.
+func New(o Options) filters.Spec {
+	if o.MaxBodySize <= 0 {
+		o.MaxBodySize = defaultMaxBodySize
+	}
+
+	return &spec{options: o, cache: make(map[string]cachedRuleSet)}
+}
+
+func (*spec) Name() string { return filters.WAFName }
+
+// the parsed rule sets are shared between the routes, and they are
+// parsed again only when the file changes
+func (s *spec) loadRules(path string) (*ruleSet, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if c, ok := s.cache[path]; ok && c.modTime.Equal(info.ModTime()) {
+		return c.rules, nil
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	defer f.Close()
+	rs, err := parseRules(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	s.cache[path] = cachedRuleSet{modTime: info.ModTime(), rules: rs}
+	return rs, nil
+}
+
+func parseExclusion(arg interface{}) (idRange, error) {
+	switch v := arg.(type) {
+	case float64:
+		return idRange{int(v), int(v)}, nil
+	case string:
+		from, to, isRange := strings.Cut(v, "-")
+		f, err := strconv.Atoi(from)
+		if err != nil {
+			return idRange{}, filters.ErrInvalidFilterParameters
+		}
+
+		if !isRange {
+			return idRange{f, f}, nil
+		}
+
+		t, err := strconv.Atoi(to)
+		if err != nil || t < f {
+			return idRange{}, filters.ErrInvalidFilterParameters
+		}
+
+		return idRange{f, t}, nil
+	default:
+		return idRange{}, filters.ErrInvalidFilterParameters
+	}
+}
+
+func (s *spec) CreateFilter(args []interface{}) (filters.Filter, error) {
+	if len(args) == 0 {
+		return nil, filters.ErrInvalidFilterParameters
+	}
+
+	path, ok := args[0].(string)
+	if !ok || path == "" {
+		return nil, filters.ErrInvalidFilterParameters
+	}
+
+	f := &filter{options: s.options}
+	if len(args) > 1 {
+		mode, ok := args[1].(string)
+		if !ok {
+			return nil, filters.ErrInvalidFilterParameters
+		}
+
+		switch mode {
+		case blockMode:
+		case detectMode:
+			f.detect = true
+		default:
+			return nil, filters.ErrInvalidFilterParameters
+		}
+	}
+
+	var exclusions []idRange
+	if len(args) > 2 {
+		for _, a := range args[2:] {
+			r, err := parseExclusion(a)
+			if err != nil {
+				return nil, err
+			}
+
+			exclusions = append(exclusions, r)
+		}
+	}
+
+	rs, err := s.loadRules(path)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, r := range rs.rules {
+		excluded := false
+		for _, e := range exclusions {
+			if r.id >= e.from && r.id <= e.to {
+				excluded = true
+				break
+			}
+		}
+
+		if excluded && r.marker == "" {
+			continue
+		}
+
+		f.rules = append(f.rules, r)
+		if r.phase == phaseBody && r.inspectsBody() {
+			f.inspectBody = true
+		}
+	}
+
+	return f, nil
+}
+
+func (r *rule) inspectsBody() bool {
+	for _, v := range r.variables {
+		switch v.collection {
+		case "ARGS", "ARGS_POST", "ARGS_NAMES", "REQUEST_BODY":
+			return true
+		}
+	}
+
+	for _, c := range r.chain {
+		if c.inspectsBody() {
+			return true
+		}
+	}
+
+	return false
+}
+
+// reads the beginning of the body, and restores the body of the request
+// for the backend
+func (f *filter) readBody(req *http.Request) ([]byte, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil, nil
+	}
+
+	b, err := io.ReadAll(io.LimitReader(req.Body, f.options.MaxBodySize))
+	req.Body = &struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(b), req.Body), req.Body}
+
+	return b, err
+}
+
+func (f *filter) matched(ctx filters.FilterContext, r *rule, msg, variable string, blocked bool) {
+	req := ctx.Request()
+	ctx.Metrics().IncCounter(fmt.Sprintf("waf.rule.%d.detected", r.id))
+	if blocked {
+		ctx.Metrics().IncCounter(fmt.Sprintf("waf.rule.%d.blocked", r.id))
+	}
+
+	log.Infof("waf: rule %d matched %s in %s %s%s, blocked: %t: %s", r.id, variable, req.Method, req.Host, req.URL.Path, blocked, msg)
+	if f.options.Sink != nil {
+		f.options.Sink.Send(&eventsink.Event{
+			Type: eventsink.DetectionEvent,
+			Time: time.Now(),
+			Data: map[string]interface{}{
+				"ruleId":   r.id,
+				"msg":      msg,
+				"variable": variable,
+				"method":   req.Method,
+				"host":     req.Host,
+				"path":     req.URL.Path,
+				"blocked":  blocked,
+			},
+		})
+	}
+}
+
+func (f *filter) Request(ctx filters.FilterContext) {
+	r := &request{req: ctx.Request(), tx: make(map[string]string)}
+	bodyRead := false
+	currentPhase := phaseHeaders
+	skipAfter := ""
+	for _, rl := range f.rules {
+		// skipping ends at the marker, or at the end of the phase
+		if rl.phase != currentPhase {
+			currentPhase = rl.phase
+			skipAfter = ""
+		}
+
+		if skipAfter != "" {
+			if rl.marker == skipAfter {
+				skipAfter = ""
+			}
+
+			continue
+		}
+
+		if rl.marker != "" {
+			continue
+		}
+
+		if rl.phase == phaseBody && f.inspectBody && !bodyRead {
+			bodyRead = true
+			body, err := f.readBody(r.req)
+			if err != nil {
+				log.Errorf("waf: failed to read request body: %v", err)
+			}
+
+			r.body = body
+			ct, _, _ := mime.ParseMediaType(r.req.Header.Get("Content-Type"))
+			if ct == "application/x-www-form-urlencoded" {
+				r.bodyArgs, _ = url.ParseQuery(string(body))
+			}
+		}
+
+		matched, ok := rl.evalChain(r)
+		if !ok {
+			continue
+		}
+
+		skipAfter = rl.skipAfter
+		block := rl.deny && !f.detect
+		if !rl.nolog || block {
+			f.matched(ctx, rl, r.expand(rl.msg, rl, matched), matched.name, block)
+		}
+
+		if block {
+			status := rl.status
+			if status == 0 {
+				status = http.StatusForbidden
+			}
+
+			ctx.Serve(&http.Response{StatusCode: status})
+			return
+		}
+	}
+}
+
+func (*filter) Response(filters.FilterContext) {}
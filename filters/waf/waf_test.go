@@ -0,0 +1,299 @@
+package waf
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/zalando/skipper/filters"
+	"github.com/zalando/skipper/filters/filtertest"
+	"github.com/zalando/skipper/logging/eventsink"
+	"github.com/zalando/skipper/metrics/metricstest"
+)
+
+const testRules = `
+SecRule REQUEST_HEADERS:User-Agent "@pm sqlmap" "id:913100,phase:1,deny,status:406,msg:'scanner'"
+SecRule REQUEST_BODY|ARGS "(?i)union\s+select" "id:942100,phase:2,t:urlDecode,deny,msg:'SQL injection'"
+SecRule REQUEST_FILENAME "@endsWith .bak" "id:920440,phase:1,pass,msg:'backup file'"
+`
+
+type testSink struct {
+	events []*eventsink.Event
+}
+
+func (s *testSink) Send(e *eventsink.Event) { s.events = append(s.events, e) }
+func (s *testSink) Close()                  {}
+
+func writeRules(t *testing.T, rules string) string {
+	p := filepath.Join(t.TempDir(), "rules.conf")
+	if err := os.WriteFile(p, []byte(rules), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	return p
+}
+
+func TestCreateFilter(t *testing.T) {
+	p := writeRules(t, testRules)
+	invalid := writeRules(t, `SecRule ARGS "@detectXSS" "id:1"`)
+	s := New(Options{})
+	if s.Name() != filters.WAFName {
+		t.Errorf("invalid name: %s", s.Name())
+	}
+
+	for _, tt := range []struct {
+		name string
+		args []interface{}
+		err  bool
+	}{
+		{name: "no args", err: true},
+		{name: "rules", args: []interface{}{p}},
+		{name: "detect mode", args: []interface{}{p, "detect"}},
+		{name: "exclusions", args: []interface{}{p, "block", 942100.0, "920000-920999"}},
+		{name: "invalid path type", args: []interface{}{42.0}, err: true},
+		{name: "missing file", args: []interface{}{p + ".missing"}, err: true},
+		{name: "invalid rules", args: []interface{}{invalid}, err: true},
+		{name: "invalid mode", args: []interface{}{p, "log"}, err: true},
+		{name: "invalid exclusion", args: []interface{}{p, "block", "foo"}, err: true},
+		{name: "invalid range", args: []interface{}{p, "block", "920999-920000"}, err: true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := s.CreateFilter(tt.args)
+			if (err != nil) != tt.err {
+				t.Errorf("unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestWAF(t *testing.T) {
+	p := writeRules(t, testRules)
+	for _, tt := range []struct {
+		name      string
+		args      []interface{}
+		path      string
+		userAgent string
+		body      string
+		status    int
+		detected  []string
+		blocked   []string
+	}{{
+		name: "clean request",
+		args: []interface{}{p},
+		path: "/search?q=shoes",
+		body: "unrelated",
+	}, {
+		name:      "blocked by header rule",
+		args:      []interface{}{p},
+		path:      "/",
+		userAgent: "sqlmap/1.5",
+		status:    http.StatusNotAcceptable,
+		detected:  []string{"waf.rule.913100.detected"},
+		blocked:   []string{"waf.rule.913100.blocked"},
+	}, {
+		name:     "blocked by body rule",
+		args:     []interface{}{p},
+		path:     "/search",
+		body:     "1 UNION SELECT password FROM users",
+		status:   http.StatusForbidden,
+		detected: []string{"waf.rule.942100.detected"},
+		blocked:  []string{"waf.rule.942100.blocked"},
+	}, {
+		name:     "pass rule only detects",
+		args:     []interface{}{p},
+		path:     "/index.php.bak",
+		detected: []string{"waf.rule.920440.detected"},
+	}, {
+		name:      "detection mode",
+		args:      []interface{}{p, "detect"},
+		path:      "/search?q=1%20union%20select",
+		userAgent: "sqlmap/1.5",
+		detected:  []string{"waf.rule.913100.detected", "waf.rule.942100.detected"},
+	}, {
+		name:      "excluded rules",
+		args:      []interface{}{p, "block", 913100.0, "942000-942999"},
+		path:      "/search?q=1%20union%20select",
+		userAgent: "sqlmap/1.5",
+	}, {
+		name: "body inspection is capped",
+		args: []interface{}{p},
+		path: "/search",
+		body: strings.Repeat("x", 16) + " UNION SELECT password",
+	}} {
+		t.Run(tt.name, func(t *testing.T) {
+			sink := &testSink{}
+			f, err := New(Options{MaxBodySize: 16, Sink: sink}).CreateFilter(tt.args)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			req := httptest.NewRequest("POST", tt.path, strings.NewReader(tt.body))
+			if tt.userAgent != "" {
+				req.Header.Set("User-Agent", tt.userAgent)
+			}
+
+			m := &metricstest.MockMetrics{}
+			ctx := &filtertest.Context{FRequest: req, FMetrics: m}
+			f.Request(ctx)
+
+			if tt.status == 0 {
+				if ctx.FServed {
+					t.Errorf("unexpected response: %d", ctx.FResponse.StatusCode)
+				}
+
+				b, err := io.ReadAll(req.Body)
+				if err != nil || string(b) != tt.body {
+					t.Errorf("failed to preserve the body: %q, %v", b, err)
+				}
+			} else if !ctx.FServed || ctx.FResponse.StatusCode != tt.status {
+				t.Errorf("failed to block the request, served: %t", ctx.FServed)
+			}
+
+			m.WithCounters(func(c map[string]int64) {
+				if len(c) != len(tt.detected)+len(tt.blocked) {
+					t.Errorf("unexpected counters: %v", c)
+				}
+
+				for _, k := range append(tt.detected, tt.blocked...) {
+					if c[k] != 1 {
+						t.Errorf("missing counter: %s, got: %v", k, c)
+					}
+				}
+			})
+
+			if len(sink.events) != len(tt.detected) {
+				t.Errorf("unexpected number of events: %d", len(sink.events))
+			}
+
+			for _, e := range sink.events {
+				if e.Type != eventsink.DetectionEvent || e.Data["blocked"] != (tt.status != 0) {
+					t.Errorf("unexpected event: %+v", e)
+				}
+			}
+		})
+	}
+}
+
+// testdata/crs.conf is derived from the OWASP Core Rule Set, with the
+// anomaly scoring of the rule set, where the detection rules increase the
+// score, and the blocking evaluation rule rejects the requests above the
+// threshold
+func TestCoreRuleSetAnomalyScoring(t *testing.T) {
+	for _, tt := range []struct {
+		name     string
+		args     []interface{}
+		method   string
+		path     string
+		header   http.Header
+		noHost   bool
+		status   int
+		detected []string
+	}{{
+		name: "clean request",
+		path: "/search?q=shoes",
+	}, {
+		name:     "scanner",
+		path:     "/",
+		header:   http.Header{"User-Agent": {"sqlmap/1.5"}},
+		status:   http.StatusForbidden,
+		detected: []string{"913100", "949110"},
+	}, {
+		name:     "sql injection",
+		path:     "/search?q=1%20UNION%20SELECT%20password%20FROM%20users",
+		status:   http.StatusForbidden,
+		detected: []string{"942270", "949110"},
+	}, {
+		name:     "blind sql injection",
+		path:     "/search?q=sleep(5)",
+		status:   http.StatusForbidden,
+		detected: []string{"942160", "949110"},
+	}, {
+		name:     "chained rule",
+		path:     "/",
+		header:   http.Header{"Content-Length": {"3"}},
+		status:   http.StatusForbidden,
+		detected: []string{"920170", "949110"},
+	}, {
+		name:   "chained rule not matching",
+		method: "POST",
+		path:   "/",
+		header: http.Header{"Content-Length": {"3"}},
+	}, {
+		name:     "score below the threshold",
+		path:     "/",
+		noHost:   true,
+		detected: []string{"920280"},
+	}, {
+		name:     "path traversal",
+		path:     "/download?file=../../etc/passwd",
+		status:   http.StatusForbidden,
+		detected: []string{"930110", "949110"},
+	}, {
+		name:   "excluded variable",
+		path:   "/",
+		header: http.Header{"Referer": {"https://example.org/../"}},
+	}, {
+		name: "rule of a higher paranoia level",
+		path: "/search?q=" + url.QueryEscape("!@#$%^&*()-+={}[]"),
+	}, {
+		name:     "detection mode",
+		args:     []interface{}{"detect"},
+		path:     "/search?q=sleep(5)",
+		detected: []string{"942160", "949110"},
+	}, {
+		name: "excluded rule",
+		args: []interface{}{"block", 942160.0},
+		path: "/search?q=sleep(5)",
+	}} {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := New(Options{}).CreateFilter(append([]interface{}{"testdata/crs.conf"}, tt.args...))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			method := tt.method
+			if method == "" {
+				method = "GET"
+			}
+
+			req := httptest.NewRequest(method, tt.path, nil)
+			for k, v := range tt.header {
+				req.Header[k] = v
+			}
+
+			if tt.noHost {
+				req.Host = ""
+			}
+
+			m := &metricstest.MockMetrics{}
+			ctx := &filtertest.Context{FRequest: req, FMetrics: m}
+			f.Request(ctx)
+
+			if tt.status == 0 && ctx.FServed {
+				t.Errorf("unexpected response: %d", ctx.FResponse.StatusCode)
+			} else if tt.status != 0 && (!ctx.FServed || ctx.FResponse.StatusCode != tt.status) {
+				t.Errorf("failed to block the request, served: %t", ctx.FServed)
+			}
+
+			m.WithCounters(func(c map[string]int64) {
+				var detected []string
+				for k := range c {
+					if strings.HasSuffix(k, ".detected") {
+						detected = append(detected, strings.TrimSuffix(strings.TrimPrefix(k, "waf.rule."), ".detected"))
+					}
+				}
+
+				sort.Strings(detected)
+				if strings.Join(detected, ",") != strings.Join(tt.detected, ",") {
+					t.Errorf("unexpected detections: %v, expected: %v", detected, tt.detected)
+				}
+			})
+		})
+	}
+}
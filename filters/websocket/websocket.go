@@ -0,0 +1,233 @@
+/*
+Package websocket provides the hooks for inspecting the messages of the
+proxied websocket connections, and the filters limiting the size and the
+rate of the messages.
+
+The filters register the callbacks and the limits in their request
+phase, and the proxy applies them to every message of the connection
+after the upgrade. The callbacks can inspect, mutate or drop the
+messages, or close the connection. The inspection requires the
+-experimental-upgrade flag of the proxy.
+
+Example:
+
+	r: Path("/ws") -> websocketMaxMessageSize(65536) -> websocketMessageRate(100, "1s") -> "http://chat.example.org";
+
+A custom filter can register a callback the following way:
+
+	func (f *filter) Request(ctx filters.FilterContext) {
+		websocket.Inspect(ctx, func(m *websocket.Message) websocket.Action {
+			if m.Direction == websocket.ClientToBackend && bytes.Contains(m.Payload, []byte("forbidden")) {
+				return websocket.Drop
+			}
+
+			return websocket.Forward
+		})
+	}
+*/
+package websocket
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/zalando/skipper/filters"
+)
+
+// Direction tells which peer of the connection sent a message.
+type Direction int
+
+const (
+	// ClientToBackend is the direction of the messages sent by the client.
+	ClientToBackend Direction = iota
+
+	// BackendToClient is the direction of the messages sent by the backend.
+	BackendToClient
+)
+
+// Opcode is the type of a websocket data message.
+type Opcode byte
+
+const (
+	// Text messages contain UTF-8 encoded text.
+	Text Opcode = 1
+
+	// Binary messages contain arbitrary data.
+	Binary Opcode = 2
+)
+
+// Message is a complete websocket data message. Fragmented messages are
+// passed to the callbacks after all of their fragments were received. The
+// control frames (ping, pong and close) are forwarded without calling the
+// callbacks.
+type Message struct {
+	Direction Direction
+	Opcode    Opcode
+
+	// Payload contains the unmasked message. The callbacks can replace
+	// it, and the proxy forwards the changed payload.
+	Payload []byte
+}
+
+// Action tells the proxy what to do with a message after a callback.
+type Action int
+
+const (
+	// Forward passes the message to the next callback, or to the peer.
+	Forward Action = iota
+
+	// Drop discards the message, the following callbacks are not called.
+	Drop
+
+	// Close closes the connection with the policy violation status.
+	Close
+)
+
+// Handler is the callback called with the data messages of a websocket
+// connection. The callbacks of a connection are called from two
+// goroutines, one for each direction.
+type Handler func(*Message) Action
+
+// Inspection holds the callbacks and the limits that the proxy applies
+// to the messages of a websocket connection.
+type Inspection struct {
+	// Handlers are called in the order of the registration.
+	Handlers []Handler
+
+	// MaxMessageSize limits the size of the messages in both directions.
+	// When a message exceeds it, the connection is closed with the status
+	// 1009 (message too big). Zero means no limit.
+	MaxMessageSize int64
+
+	// MaxMessages and Period limit the rate of the messages sent by the
+	// client. When the rate is exceeded, the connection is closed with the
+	// status 1008 (policy violation). Zero means no limit.
+	MaxMessages int
+	Period      time.Duration
+}
+
+type maxMessageSizeSpec struct{}
+
+type maxMessageSize int64
+
+type messageRateSpec struct{}
+
+type messageRate struct {
+	maxMessages int
+	period      time.Duration
+}
+
+// Get returns the inspection settings of the current request, and
+// creates them when they don't exist yet.
+func Get(ctx filters.FilterContext) *Inspection {
+	if i, ok := ctx.StateBag()[filters.WebsocketInspectionKey].(*Inspection); ok {
+		return i
+	}
+
+	i := &Inspection{}
+	ctx.StateBag()[filters.WebsocketInspectionKey] = i
+	return i
+}
+
+// Inspect registers a callback for the messages of the websocket
+// connection, when the current request is upgraded.
+func Inspect(ctx filters.FilterContext, h Handler) {
+	i := Get(ctx)
+	i.Handlers = append(i.Handlers, h)
+}
+
+func intArg(a interface{}) (int64, bool) {
+	switch v := a.(type) {
+	case float64:
+		if v != float64(int64(v)) || v <= 0 {
+			return 0, false
+		}
+
+		return int64(v), true
+	case string:
+		i, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || i <= 0 {
+			return 0, false
+		}
+
+		return i, true
+	default:
+		return 0, false
+	}
+}
+
+// NewMaxMessageSize creates the filter specification of the
+// websocketMaxMessageSize filter, that limits the size of the websocket
+// messages in bytes, in both directions.
+//
+// Example:
+//
+//	websocketMaxMessageSize(65536)
+func NewMaxMessageSize() filters.Spec { return maxMessageSizeSpec{} }
+
+func (maxMessageSizeSpec) Name() string { return filters.WebsocketMaxMessageSizeName }
+
+func (maxMessageSizeSpec) CreateFilter(args []interface{}) (filters.Filter, error) {
+	if len(args) != 1 {
+		return nil, filters.ErrInvalidFilterParameters
+	}
+
+	size, ok := intArg(args[0])
+	if !ok {
+		return nil, filters.ErrInvalidFilterParameters
+	}
+
+	return maxMessageSize(size), nil
+}
+
+// the strictest limit applies
+func (f maxMessageSize) Request(ctx filters.FilterContext) {
+	i := Get(ctx)
+	if i.MaxMessageSize == 0 || int64(f) < i.MaxMessageSize {
+		i.MaxMessageSize = int64(f)
+	}
+}
+
+func (maxMessageSize) Response(filters.FilterContext) {}
+
+// NewMessageRate creates the filter specification of the
+// websocketMessageRate filter, that limits the number of the messages
+// that the client can send on a websocket connection in a period.
+//
+// Example:
+//
+//	websocketMessageRate(100, "1s")
+func NewMessageRate() filters.Spec { return messageRateSpec{} }
+
+func (messageRateSpec) Name() string { return filters.WebsocketMessageRateName }
+
+func (messageRateSpec) CreateFilter(args []interface{}) (filters.Filter, error) {
+	if len(args) != 2 {
+		return nil, filters.ErrInvalidFilterParameters
+	}
+
+	n, ok := intArg(args[0])
+	if !ok {
+		return nil, filters.ErrInvalidFilterParameters
+	}
+
+	s, ok := args[1].(string)
+	if !ok {
+		return nil, filters.ErrInvalidFilterParameters
+	}
+
+	period, err := time.ParseDuration(s)
+	if err != nil || period <= 0 {
+		return nil, filters.ErrInvalidFilterParameters
+	}
+
+	return &messageRate{maxMessages: int(n), period: period}, nil
+}
+
+func (f *messageRate) Request(ctx filters.FilterContext) {
+	i := Get(ctx)
+	i.MaxMessages = f.maxMessages
+	i.Period = f.period
+}
+
+func (*messageRate) Response(filters.FilterContext) {}
@@ -0,0 +1,97 @@
+package websocket
+
+import (
+	"testing"
+	"time"
+
+	"github.com/zalando/skipper/filters"
+	"github.com/zalando/skipper/filters/filtertest"
+)
+
+func TestCreateFilters(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		spec filters.Spec
+		args []interface{}
+		fail bool
+	}{{
+		name: "max message size",
+		spec: NewMaxMessageSize(),
+		args: []interface{}{65536.0},
+	}, {
+		name: "max message size as string",
+		spec: NewMaxMessageSize(),
+		args: []interface{}{"1024"},
+	}, {
+		name: "max message size missing",
+		spec: NewMaxMessageSize(),
+		fail: true,
+	}, {
+		name: "max message size invalid",
+		spec: NewMaxMessageSize(),
+		args: []interface{}{-1.0},
+		fail: true,
+	}, {
+		name: "message rate",
+		spec: NewMessageRate(),
+		args: []interface{}{100.0, "1s"},
+	}, {
+		name: "message rate without period",
+		spec: NewMessageRate(),
+		args: []interface{}{100.0},
+		fail: true,
+	}, {
+		name: "message rate invalid period",
+		spec: NewMessageRate(),
+		args: []interface{}{100.0, "foo"},
+		fail: true,
+	}, {
+		name: "message rate invalid count",
+		spec: NewMessageRate(),
+		args: []interface{}{1.5, "1s"},
+		fail: true,
+	}} {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := tt.spec.CreateFilter(tt.args)
+			if tt.fail && err == nil {
+				t.Error("failed to fail")
+			} else if !tt.fail && err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestInspection(t *testing.T) {
+	ctx := &filtertest.Context{FStateBag: make(map[string]interface{})}
+
+	for _, size := range []float64{4096, 1024, 2048} {
+		f, err := NewMaxMessageSize().CreateFilter([]interface{}{size})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		f.Request(ctx)
+	}
+
+	f, err := NewMessageRate().CreateFilter([]interface{}{10.0, "1m"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f.Request(ctx)
+	Inspect(ctx, func(*Message) Action { return Drop })
+
+	i := Get(ctx)
+	if i.MaxMessageSize != 1024 {
+		t.Errorf("unexpected max message size: %d", i.MaxMessageSize)
+	}
+
+	if i.MaxMessages != 10 || i.Period != time.Minute {
+		t.Errorf("unexpected rate: %d/%v", i.MaxMessages, i.Period)
+	}
+
+	if len(i.Handlers) != 1 || i.Handlers[0](&Message{}) != Drop {
+		t.Error("failed to register the handler")
+	}
+}
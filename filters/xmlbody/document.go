@@ -0,0 +1,267 @@
+package xmlbody
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+const (
+	maxDepth = 128
+	maxNodes = 100000
+)
+
+var (
+	errDirective     = errors.New("xml: DOCTYPE and other directives are not allowed")
+	errTooDeep       = fmt.Errorf("xml: nesting deeper than %d", maxDepth)
+	errTooManyNodes  = fmt.Errorf("xml: more than %d nodes", maxNodes)
+	errUnclosed      = errors.New("xml: unclosed element")
+	errNoRootElement = errors.New("xml: no root element")
+)
+
+type nodeKind int
+
+const (
+	elementNode nodeKind = iota
+	textNode
+	commentNode
+	procInstNode
+)
+
+// node of a parsed XML document. The names of the elements and the
+// attributes keep the original namespace prefixes in the Space field,
+// so that the document is written back as it was received.
+type node struct {
+	kind     nodeKind
+	name     xml.Name
+	attrs    []xml.Attr
+	children []*node
+	parent   *node
+	text     string
+	target   string
+}
+
+type document struct {
+	nodes []*node
+}
+
+// parses a document or a fragment. It rejects the DOCTYPE declarations,
+// to prevent the entity expansion, and limits the depth and the number
+// of the nodes.
+func parse(r io.Reader, fragment bool) (*document, error) {
+	d := xml.NewDecoder(r)
+	d.Strict = true
+
+	doc := &document{}
+	var (
+		current *node
+		count   int
+	)
+
+	appendNode := func(n *node) error {
+		count++
+		if count > maxNodes {
+			return errTooManyNodes
+		}
+
+		if current == nil {
+			doc.nodes = append(doc.nodes, n)
+			return nil
+		}
+
+		n.parent = current
+		current.children = append(current.children, n)
+		return nil
+	}
+
+	depth := 0
+	for {
+		t, err := d.RawToken()
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		switch tt := t.(type) {
+		case xml.StartElement:
+			depth++
+			if depth > maxDepth {
+				return nil, errTooDeep
+			}
+
+			n := &node{kind: elementNode, name: tt.Name, attrs: append([]xml.Attr(nil), tt.Attr...)}
+			if err := appendNode(n); err != nil {
+				return nil, err
+			}
+
+			current = n
+		case xml.EndElement:
+			if current == nil || current.name != tt.Name {
+				return nil, fmt.Errorf("xml: unexpected end element </%s>", qualified(tt.Name))
+			}
+
+			depth--
+			current = current.parent
+		case xml.CharData:
+			if err := appendNode(&node{kind: textNode, text: string(tt)}); err != nil {
+				return nil, err
+			}
+		case xml.Comment:
+			if err := appendNode(&node{kind: commentNode, text: string(tt)}); err != nil {
+				return nil, err
+			}
+		case xml.ProcInst:
+			if err := appendNode(&node{kind: procInstNode, target: tt.Target, text: string(tt.Inst)}); err != nil {
+				return nil, err
+			}
+		case xml.Directive:
+			return nil, errDirective
+		}
+	}
+
+	if current != nil {
+		return nil, errUnclosed
+	}
+
+	if !fragment && doc.root() == nil {
+		return nil, errNoRootElement
+	}
+
+	return doc, nil
+}
+
+func (doc *document) root() *node {
+	for _, n := range doc.nodes {
+		if n.kind == elementNode {
+			return n
+		}
+	}
+
+	return nil
+}
+
+func qualified(n xml.Name) string {
+	if n.Space == "" {
+		return n.Local
+	}
+
+	return n.Space + ":" + n.Local
+}
+
+var (
+	textEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", "\r", "&#xD;")
+	attrEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", "\"", "&quot;", "\n", "&#xA;", "\t", "&#x9;", "\r", "&#xD;")
+)
+
+func (n *node) write(b *strings.Builder) {
+	switch n.kind {
+	case textNode:
+		textEscaper.WriteString(b, n.text)
+	case commentNode:
+		b.WriteString("<!--")
+		b.WriteString(n.text)
+		b.WriteString("-->")
+	case procInstNode:
+		b.WriteString("<?")
+		b.WriteString(n.target)
+		if n.text != "" {
+			b.WriteString(" ")
+			b.WriteString(n.text)
+		}
+
+		b.WriteString("?>")
+	default:
+		b.WriteString("<")
+		b.WriteString(qualified(n.name))
+		for _, a := range n.attrs {
+			b.WriteString(" ")
+			b.WriteString(qualified(a.Name))
+			b.WriteString(`="`)
+			attrEscaper.WriteString(b, a.Value)
+			b.WriteString(`"`)
+		}
+
+		if len(n.children) == 0 {
+			b.WriteString("/>")
+			return
+		}
+
+		b.WriteString(">")
+		for _, c := range n.children {
+			c.write(b)
+		}
+
+		b.WriteString("</")
+		b.WriteString(qualified(n.name))
+		b.WriteString(">")
+	}
+}
+
+func (doc *document) bytes() []byte {
+	var b strings.Builder
+	for _, n := range doc.nodes {
+		n.write(&b)
+	}
+
+	return []byte(b.String())
+}
+
+// returns the concatenated text content of a node
+func (n *node) textContent() string {
+	if n.kind != elementNode {
+		return n.text
+	}
+
+	var b strings.Builder
+	for _, c := range n.children {
+		if c.kind == elementNode || c.kind == textNode {
+			b.WriteString(c.textContent())
+		}
+	}
+
+	return b.String()
+}
+
+func (n *node) attr(name string) (int, bool) {
+	for i, a := range n.attrs {
+		if a.Name.Local == name && a.Name.Space != "xmlns" {
+			return i, true
+		}
+	}
+
+	return -1, false
+}
+
+func (n *node) clone() *node {
+	c := *n
+	c.attrs = append([]xml.Attr(nil), n.attrs...)
+	c.children = nil
+	for _, ch := range n.children {
+		ch = ch.clone()
+		ch.parent = &c
+		c.children = append(c.children, ch)
+	}
+
+	return &c
+}
+
+func (n *node) remove() {
+	if n.parent == nil {
+		return
+	}
+
+	siblings := n.parent.children
+	for i, c := range siblings {
+		if c == n {
+			n.parent.children = append(siblings[:i:i], siblings[i+1:]...)
+			break
+		}
+	}
+
+	n.parent = nil
+}
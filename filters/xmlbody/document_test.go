@@ -0,0 +1,83 @@
+package xmlbody
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	for _, tt := range []struct {
+		name     string
+		doc      string
+		fragment bool
+		err      bool
+	}{{
+		name: "document",
+		doc:  `<?xml version="1.0" encoding="UTF-8"?><a><b x="1">foo</b><!-- bar --></a>`,
+	}, {
+		name: "entity expansion",
+		doc:  `<?xml version="1.0"?><!DOCTYPE a [<!ENTITY x "xxxxxxxx">]><a>&x;</a>`,
+		err:  true,
+	}, {
+		name: "external entity",
+		doc:  `<?xml version="1.0"?><!DOCTYPE a [<!ENTITY x SYSTEM "file:///etc/passwd">]><a>&x;</a>`,
+		err:  true,
+	}, {
+		name: "undefined entity",
+		doc:  `<a>&x;</a>`,
+		err:  true,
+	}, {
+		name: "too deep",
+		doc:  strings.Repeat("<a>", maxDepth+1) + strings.Repeat("</a>", maxDepth+1),
+		err:  true,
+	}, {
+		name: "too many nodes",
+		doc:  "<a>" + strings.Repeat("<b/>", maxNodes) + "</a>",
+		err:  true,
+	}, {
+		name: "mismatched end element",
+		doc:  `<a><b></a></b>`,
+		err:  true,
+	}, {
+		name: "unclosed",
+		doc:  `<a><b></b>`,
+		err:  true,
+	}, {
+		name: "no root element",
+		doc:  `<!-- foo -->`,
+		err:  true,
+	}, {
+		name:     "fragment",
+		doc:      `<a/><b/>`,
+		fragment: true,
+	}} {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parse(strings.NewReader(tt.doc), tt.fragment)
+			if tt.err && err == nil {
+				t.Error("failed to fail")
+			} else if !tt.err && err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	const doc = `<?xml version="1.0" encoding="UTF-8"?>
+<soap:Envelope xmlns:soap="http://schemas.xmlsoap.org/soap/envelope/" xmlns:u="urn:users">
+	<soap:Header/>
+	<soap:Body>
+		<!-- comment -->
+		<u:GetUser id="1 &amp; 2">Tom &amp; Jerry &lt;3</u:GetUser>
+	</soap:Body>
+</soap:Envelope>`
+
+	d, err := parse(strings.NewReader(doc), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if s := string(d.bytes()); s != doc {
+		t.Errorf("unexpected document:\n%s\nexpected:\n%s", s, doc)
+	}
+}
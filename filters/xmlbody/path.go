@@ -0,0 +1,280 @@
+package xmlbody
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// step of a path, e.g. //soap:Body/GetUser[2] or /Envelope/Header/@id
+type step struct {
+	descendant bool
+	name       string
+	attr       string
+	position   int
+	attrName   string
+	attrValue  *string
+}
+
+type path struct {
+	steps []step
+}
+
+// selected element, or selected attribute of an element
+type selection struct {
+	node *node
+	attr string
+}
+
+func invalidPath(p, reason string) error {
+	return fmt.Errorf("xml: invalid path %q: %s", p, reason)
+}
+
+// localName drops the namespace prefix, the names are matched by their
+// local part
+func localName(name string) string {
+	if i := strings.IndexByte(name, ':'); i >= 0 {
+		return name[i+1:]
+	}
+
+	return name
+}
+
+func parsePredicate(p string, s *step, pred string) error {
+	if n, err := strconv.Atoi(pred); err == nil {
+		if n < 1 {
+			return invalidPath(p, "position must be positive")
+		}
+
+		s.position = n
+		return nil
+	}
+
+	if !strings.HasPrefix(pred, "@") {
+		return invalidPath(p, "unsupported predicate "+pred)
+	}
+
+	name, value, hasValue := strings.Cut(pred[1:], "=")
+	s.attrName = localName(strings.TrimSpace(name))
+	if s.attrName == "" {
+		return invalidPath(p, "missing attribute name")
+	}
+
+	if !hasValue {
+		return nil
+	}
+
+	value = strings.TrimSpace(value)
+	if len(value) < 2 || (value[0] != '\'' && value[0] != '"') || value[len(value)-1] != value[0] {
+		return invalidPath(p, "attribute value must be quoted")
+	}
+
+	value = value[1 : len(value)-1]
+	s.attrValue = &value
+	return nil
+}
+
+func parseStep(p, expression string, s *step) error {
+	for {
+		i := strings.IndexByte(expression, '[')
+		if i < 0 {
+			break
+		}
+
+		j := strings.IndexByte(expression[i:], ']')
+		if j < 0 || i+j != len(expression)-1 && expression[i+j+1] != '[' {
+			return invalidPath(p, "unterminated predicate")
+		}
+
+		if err := parsePredicate(p, s, strings.TrimSpace(expression[i+1:i+j])); err != nil {
+			return err
+		}
+
+		expression = expression[:i] + expression[i+j+1:]
+	}
+
+	if strings.HasPrefix(expression, "@") {
+		s.attr = localName(expression[1:])
+		if s.attr == "" {
+			return invalidPath(p, "missing attribute name")
+		}
+
+		return nil
+	}
+
+	if expression == "" {
+		return invalidPath(p, "empty step")
+	}
+
+	s.name = localName(expression)
+	return nil
+}
+
+// parsePath parses an absolute path in a subset of XPath: the child (/)
+// and the descendant (//) steps, the wildcard (*), the position ([2])
+// and attribute ([@id] or [@id='1']) predicates, and an attribute as
+// the last step (/@id). The namespace prefixes are ignored.
+func parsePath(p string) (*path, error) {
+	if !strings.HasPrefix(p, "/") {
+		return nil, invalidPath(p, "must be absolute")
+	}
+
+	var steps []step
+	for i := 0; i < len(p); {
+		var s step
+		if strings.HasPrefix(p[i:], "//") {
+			s.descendant = true
+			i += 2
+		} else {
+			i++
+		}
+
+		// the step ends at the next slash outside of the predicates
+		start := i
+		var brackets int
+		var quote byte
+		for ; i < len(p); i++ {
+			c := p[i]
+			if quote != 0 {
+				if c == quote {
+					quote = 0
+				}
+
+				continue
+			}
+
+			if c == '\'' || c == '"' {
+				quote = c
+			} else if c == '[' {
+				brackets++
+			} else if c == ']' {
+				brackets--
+			} else if c == '/' && brackets == 0 {
+				break
+			}
+		}
+
+		expression := p[start:i]
+		if err := parseStep(p, strings.TrimSpace(expression), &s); err != nil {
+			return nil, err
+		}
+
+		if len(steps) > 0 && steps[len(steps)-1].attr != "" {
+			return nil, invalidPath(p, "attribute must be the last step")
+		}
+
+		if s.attr != "" && (s.position > 0 || s.attrName != "") {
+			return nil, invalidPath(p, "predicates of attributes are not supported")
+		}
+
+		steps = append(steps, s)
+	}
+
+	return &path{steps: steps}, nil
+}
+
+func (s step) matches(n *node) bool {
+	if n.kind != elementNode || (s.name != "*" && n.name.Local != s.name) {
+		return false
+	}
+
+	if s.attrName == "" {
+		return true
+	}
+
+	i, ok := n.attr(s.attrName)
+	return ok && (s.attrValue == nil || n.attrs[i].Value == *s.attrValue)
+}
+
+func (s step) children(parent *node) []*node {
+	var matches []*node
+	for _, c := range parent.children {
+		if s.matches(c) {
+			matches = append(matches, c)
+		}
+	}
+
+	if s.position == 0 {
+		return matches
+	}
+
+	if s.position > len(matches) {
+		return nil
+	}
+
+	return matches[s.position-1 : s.position]
+}
+
+func descendantsOrSelf(n *node, f func(*node)) {
+	f(n)
+	for _, c := range n.children {
+		if c.kind == elementNode {
+			descendantsOrSelf(c, f)
+		}
+	}
+}
+
+func (s step) apply(context []*node) []*node {
+	var result []*node
+	seen := make(map[*node]bool)
+	add := func(parent *node) {
+		for _, m := range s.children(parent) {
+			if !seen[m] {
+				seen[m] = true
+				result = append(result, m)
+			}
+		}
+	}
+
+	for _, c := range context {
+		if s.descendant {
+			descendantsOrSelf(c, add)
+		} else {
+			add(c)
+		}
+	}
+
+	return result
+}
+
+// selects the matching elements or attributes of a document in the
+// document order
+func (p *path) selectFrom(doc *document) []selection {
+	context := []*node{{kind: elementNode, children: doc.nodes}}
+	for _, s := range p.steps {
+		if s.attr != "" {
+			var result []selection
+			for _, c := range context {
+				if s.descendant {
+					descendantsOrSelf(c, func(n *node) {
+						if _, ok := n.attr(s.attr); ok {
+							result = append(result, selection{node: n, attr: s.attr})
+						}
+					})
+				} else if _, ok := c.attr(s.attr); ok {
+					result = append(result, selection{node: c, attr: s.attr})
+				}
+			}
+
+			return result
+		}
+
+		context = s.apply(context)
+	}
+
+	result := make([]selection, len(context))
+	for i, n := range context {
+		result[i] = selection{node: n}
+	}
+
+	return result
+}
+
+func (s selection) value() string {
+	if s.attr == "" {
+		return s.node.textContent()
+	}
+
+	i, _ := s.node.attr(s.attr)
+	return s.node.attrs[i].Value
+}
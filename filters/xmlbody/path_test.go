@@ -0,0 +1,80 @@
+package xmlbody
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const testDocument = `<?xml version="1.0"?>
+<soap:Envelope xmlns:soap="http://schemas.xmlsoap.org/soap/envelope/">
+	<soap:Header><Trace id="t1">abc</Trace></soap:Header>
+	<soap:Body>
+		<GetUsers>
+			<User id="1" role="admin"><Name>Tom</Name></User>
+			<User id="2"><Name>Jerry</Name></User>
+			<User id="3/4"><Name>Spike</Name></User>
+		</GetUsers>
+	</soap:Body>
+</soap:Envelope>`
+
+func TestParsePath(t *testing.T) {
+	for _, p := range []string{
+		"",
+		"Envelope",
+		"/Envelope//",
+		"/Envelope/@id/Body",
+		"/Envelope[0]",
+		"/Envelope[foo]",
+		"/Envelope[@id=1]",
+		"/Envelope[1",
+		"/@id[1]",
+		"/@",
+	} {
+		if _, err := parsePath(p); err == nil {
+			t.Errorf("failed to fail: %q", p)
+		}
+	}
+}
+
+func TestSelect(t *testing.T) {
+	doc, err := parse(strings.NewReader(testDocument), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		path   string
+		values []string
+	}{
+		{path: "/Envelope/Header/Trace", values: []string{"abc"}},
+		{path: "/soap:Envelope/soap:Header/Trace/@id", values: []string{"t1"}},
+		{path: "/Envelope/Body/GetUsers/User/Name", values: []string{"Tom", "Jerry", "Spike"}},
+		{path: "//User[2]/Name", values: []string{"Jerry"}},
+		{path: "//Name", values: []string{"Tom", "Jerry", "Spike"}},
+		{path: "//User[@role]/Name", values: []string{"Tom"}},
+		{path: "//User[@id='2']/Name", values: []string{"Jerry"}},
+		{path: `//User[@id="3/4"]/Name`, values: []string{"Spike"}},
+		{path: "//User/@id", values: []string{"1", "2", "3/4"}},
+		{path: "//@role", values: []string{"admin"}},
+		{path: "/Envelope/*[1]/Trace", values: []string{"abc"}},
+		{path: "/Envelope/Body/*/User[4]"},
+		{path: "/Body"},
+	} {
+		t.Run(tt.path, func(t *testing.T) {
+			p, err := parsePath(tt.path)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var values []string
+			for _, s := range p.selectFrom(doc) {
+				values = append(values, s.value())
+			}
+
+			if !reflect.DeepEqual(values, tt.values) {
+				t.Errorf("unexpected values: %v, expected: %v", values, tt.values)
+			}
+		})
+	}
+}
@@ -0,0 +1,395 @@
+/*
+Package xmlbody provides filters to extract values from XML request and
+response bodies into headers, and to apply simple transformations on
+them, e.g. to adjust the envelopes of the requests sent to legacy SOAP
+services.
+
+The values are selected with paths in a subset of XPath: the child (/)
+and the descendant (//) steps, the wildcard (*), the position ([2]) and
+attribute ([@id] or [@id='1']) predicates, and an attribute as the last
+step (/@id). The namespace prefixes in the paths are ignored, and the
+elements are matched by their local names.
+
+Extracting the text of the first matching element or attribute into a
+header:
+
+	r: * -> xpathToRequestHeader("/Envelope/Body/*[1]/CustomerId", "X-Customer-Id") -> "https://soap.example.org";
+	r: * -> xpathToResponseHeader("//Fault/faultcode", "X-Soap-Fault") -> "https://soap.example.org";
+
+Transforming the matching elements or attributes, with the operations:
+set, to replace the text of the elements or the value of the
+attributes; delete, to remove them; rename, to change the local name of
+the elements; and append, to append an XML fragment to the content of
+the elements:
+
+	r: * -> xmlTransformRequest("set", "/Envelope/Header/Security/Username", "service") -> "https://soap.example.org";
+	r: * -> xmlTransformRequest("delete", "//Header/Trace") -> "https://soap.example.org";
+	r: * -> xmlTransformResponse("rename", "//GetUserResult", "GetUserResponse") -> "https://soap.example.org";
+	r: * -> xmlTransformRequest("append", "/Envelope/Header", "<ns:Tenant xmlns:ns=\"urn:tenant\">eu</ns:Tenant>") -> "https://soap.example.org";
+
+The bodies are parsed strictly: the documents with DOCTYPE declarations
+are rejected, to prevent the entity expansion attacks, and the depth and
+the number of the nodes are limited. The requests with bodies that
+can't be parsed are rejected by the transform filter with 400 Bad
+Request, and the requests with bodies larger than the configured limit
+with 413 Payload Too Large. The extracting filters and the response
+transformation leave these bodies unchanged.
+*/
+package xmlbody
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/net/http/httpguts"
+
+	"github.com/zalando/skipper/filters"
+)
+
+const defaultMaxBodySize = 1 << 20
+
+type kind int
+
+const (
+	extractRequest kind = iota
+	extractResponse
+	transformRequest
+	transformResponse
+)
+
+type operation string
+
+const (
+	setOperation    operation = "set"
+	deleteOperation operation = "delete"
+	renameOperation operation = "rename"
+	appendOperation operation = "append"
+)
+
+// Options for the filter specifications of the package.
+type Options struct {
+
+	// MaxBodySize sets the maximum size of the processed bodies in
+	// bytes. Defaults to 1MiB.
+	MaxBodySize int64
+}
+
+type spec struct {
+	kind    kind
+	options Options
+}
+
+type extractFilter struct {
+	response    bool
+	path        *path
+	header      string
+	maxBodySize int64
+}
+
+type transformFilter struct {
+	response    bool
+	operation   operation
+	path        *path
+	value       string
+	fragment    *document
+	maxBodySize int64
+}
+
+func newSpec(k kind, o Options) filters.Spec {
+	if o.MaxBodySize <= 0 {
+		o.MaxBodySize = defaultMaxBodySize
+	}
+
+	return &spec{kind: k, options: o}
+}
+
+// NewXPathToRequestHeader creates a filter specification, whose instances
+// set a request header to the text of the first element or attribute of
+// the request body matching a path.
+func NewXPathToRequestHeader(o Options) filters.Spec { return newSpec(extractRequest, o) }
+
+// NewXPathToResponseHeader creates a filter specification, whose
+// instances set a response header to the text of the first element or
+// attribute of the response body matching a path.
+func NewXPathToResponseHeader(o Options) filters.Spec { return newSpec(extractResponse, o) }
+
+// NewTransformRequest creates a filter specification, whose instances
+// transform the elements or attributes of the request body matching a
+// path.
+func NewTransformRequest(o Options) filters.Spec { return newSpec(transformRequest, o) }
+
+// NewTransformResponse creates a filter specification, whose instances
+// transform the elements or attributes of the response body matching a
+// path.
+func NewTransformResponse(o Options) filters.Spec { return newSpec(transformResponse, o) }
+
+func (s *spec) Name() string {
+	switch s.kind {
+	case extractRequest:
+		return filters.XPathToRequestHeaderName
+	case extractResponse:
+		return filters.XPathToResponseHeaderName
+	case transformRequest:
+		return filters.XMLTransformRequestName
+	default:
+		return filters.XMLTransformResponseName
+	}
+}
+
+func stringArgs(args []interface{}) ([]string, bool) {
+	s := make([]string, len(args))
+	for i, a := range args {
+		var ok bool
+		if s[i], ok = a.(string); !ok {
+			return nil, false
+		}
+	}
+
+	return s, true
+}
+
+func (s *spec) CreateFilter(args []interface{}) (filters.Filter, error) {
+	sargs, ok := stringArgs(args)
+	if !ok {
+		return nil, filters.ErrInvalidFilterParameters
+	}
+
+	if s.kind == extractRequest || s.kind == extractResponse {
+		if len(sargs) != 2 || !httpguts.ValidHeaderFieldName(sargs[1]) {
+			return nil, filters.ErrInvalidFilterParameters
+		}
+
+		p, err := parsePath(sargs[0])
+		if err != nil {
+			return nil, err
+		}
+
+		return &extractFilter{
+			response:    s.kind == extractResponse,
+			path:        p,
+			header:      sargs[1],
+			maxBodySize: s.options.MaxBodySize,
+		}, nil
+	}
+
+	if len(sargs) < 2 || len(sargs) > 3 {
+		return nil, filters.ErrInvalidFilterParameters
+	}
+
+	op := operation(sargs[0])
+	switch op {
+	case deleteOperation:
+		if len(sargs) != 2 {
+			return nil, filters.ErrInvalidFilterParameters
+		}
+	case setOperation, renameOperation, appendOperation:
+		if len(sargs) != 3 {
+			return nil, filters.ErrInvalidFilterParameters
+		}
+	default:
+		return nil, filters.ErrInvalidFilterParameters
+	}
+
+	p, err := parsePath(sargs[1])
+	if err != nil {
+		return nil, err
+	}
+
+	f := &transformFilter{
+		response:    s.kind == transformResponse,
+		operation:   op,
+		path:        p,
+		maxBodySize: s.options.MaxBodySize,
+	}
+
+	if len(sargs) == 3 {
+		f.value = sargs[2]
+	}
+
+	switch op {
+	case renameOperation:
+		if p.steps[len(p.steps)-1].attr != "" || f.value == "" || strings.ContainsAny(f.value, " <>&\"'/") {
+			return nil, filters.ErrInvalidFilterParameters
+		}
+	case appendOperation:
+		if p.steps[len(p.steps)-1].attr != "" {
+			return nil, filters.ErrInvalidFilterParameters
+		}
+
+		if f.fragment, err = parse(strings.NewReader(f.value), true); err != nil {
+			return nil, err
+		}
+	}
+
+	return f, nil
+}
+
+// reads a body up to the limit. When the body is larger, it returns
+// false and the body that can be read again.
+func readBody(body io.ReadCloser, limit int64) ([]byte, io.ReadCloser, bool, error) {
+	if body == nil || body == http.NoBody {
+		return nil, body, true, nil
+	}
+
+	b, err := io.ReadAll(io.LimitReader(body, limit+1))
+	if err != nil {
+		return nil, body, false, err
+	}
+
+	if int64(len(b)) > limit {
+		return nil, &struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(bytes.NewReader(b), body), body}, false, nil
+	}
+
+	body.Close()
+	return b, io.NopCloser(bytes.NewReader(b)), true, nil
+}
+
+func (f *extractFilter) extract(body io.ReadCloser, h http.Header) io.ReadCloser {
+	b, body, ok, err := readBody(body, f.maxBodySize)
+	if err != nil {
+		log.Errorf("xml: failed to read body: %v", err)
+		return body
+	}
+
+	if !ok || len(b) == 0 {
+		return body
+	}
+
+	doc, err := parse(bytes.NewReader(b), false)
+	if err != nil {
+		log.Debugf("xml: failed to parse body: %v", err)
+		return body
+	}
+
+	if s := f.path.selectFrom(doc); len(s) > 0 {
+		if v := strings.TrimSpace(s[0].value()); httpguts.ValidHeaderFieldValue(v) {
+			h.Set(f.header, v)
+		}
+	}
+
+	return body
+}
+
+func (f *extractFilter) Request(ctx filters.FilterContext) {
+	if f.response {
+		return
+	}
+
+	req := ctx.Request()
+	req.Body = f.extract(req.Body, req.Header)
+}
+
+func (f *extractFilter) Response(ctx filters.FilterContext) {
+	if !f.response {
+		return
+	}
+
+	rsp := ctx.Response()
+	rsp.Body = f.extract(rsp.Body, rsp.Header)
+}
+
+func (f *transformFilter) apply(doc *document) {
+	for _, s := range f.path.selectFrom(doc) {
+		n := s.node
+		switch f.operation {
+		case setOperation:
+			if s.attr != "" {
+				i, _ := n.attr(s.attr)
+				n.attrs[i].Value = f.value
+				continue
+			}
+
+			n.children = []*node{{kind: textNode, text: f.value, parent: n}}
+		case deleteOperation:
+			if s.attr != "" {
+				i, _ := n.attr(s.attr)
+				n.attrs = append(n.attrs[:i:i], n.attrs[i+1:]...)
+				continue
+			}
+
+			n.remove()
+		case renameOperation:
+			n.name.Local = f.value
+		case appendOperation:
+			for _, c := range f.fragment.nodes {
+				c = c.clone()
+				c.parent = n
+				n.children = append(n.children, c)
+			}
+		}
+	}
+}
+
+// transforms a body, and returns the transformed body, or the original
+// when it is too large or it can't be parsed, with the status code of
+// the rejection
+func (f *transformFilter) transform(body io.ReadCloser) (io.ReadCloser, int64, int) {
+	b, body, ok, err := readBody(body, f.maxBodySize)
+	if err != nil {
+		log.Errorf("xml: failed to read body: %v", err)
+		return body, -1, http.StatusBadRequest
+	}
+
+	if !ok {
+		return body, -1, http.StatusRequestEntityTooLarge
+	}
+
+	if len(b) == 0 {
+		return body, -1, 0
+	}
+
+	doc, err := parse(bytes.NewReader(b), false)
+	if err != nil {
+		log.Debugf("xml: failed to parse body: %v", err)
+		return body, -1, http.StatusBadRequest
+	}
+
+	f.apply(doc)
+	b = doc.bytes()
+	return io.NopCloser(bytes.NewReader(b)), int64(len(b)), 0
+}
+
+func (f *transformFilter) Request(ctx filters.FilterContext) {
+	if f.response {
+		return
+	}
+
+	req := ctx.Request()
+	body, n, status := f.transform(req.Body)
+	req.Body = body
+	if status != 0 {
+		ctx.Serve(&http.Response{StatusCode: status})
+		return
+	}
+
+	if n >= 0 {
+		req.ContentLength = n
+		req.Header.Set("Content-Length", strconv.FormatInt(n, 10))
+	}
+}
+
+func (f *transformFilter) Response(ctx filters.FilterContext) {
+	if !f.response {
+		return
+	}
+
+	rsp := ctx.Response()
+	body, n, status := f.transform(rsp.Body)
+	rsp.Body = body
+	if status != 0 {
+		log.Debugf("xml: failed to transform the response body of %s, status: %d", ctx.Request().URL.Path, status)
+		return
+	}
+
+	if n >= 0 {
+		rsp.ContentLength = n
+		rsp.Header.Set("Content-Length", strconv.FormatInt(n, 10))
+	}
+}
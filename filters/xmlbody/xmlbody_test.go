@@ -0,0 +1,211 @@
+package xmlbody
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/zalando/skipper/filters"
+	"github.com/zalando/skipper/filters/filtertest"
+)
+
+const envelope = `<soap:Envelope xmlns:soap="http://schemas.xmlsoap.org/soap/envelope/">` +
+	`<soap:Header><Trace>abc</Trace></soap:Header>` +
+	`<soap:Body><GetUser id="1"><CustomerId> 42 </CustomerId></GetUser></soap:Body>` +
+	`</soap:Envelope>`
+
+func TestCreateFilter(t *testing.T) {
+	for _, tt := range []struct {
+		spec filters.Spec
+		args []interface{}
+		err  bool
+	}{
+		{spec: NewXPathToRequestHeader(Options{}), args: []interface{}{"//CustomerId"}, err: true},
+		{spec: NewXPathToRequestHeader(Options{}), args: []interface{}{"//CustomerId", "X Customer"}, err: true},
+		{spec: NewXPathToRequestHeader(Options{}), args: []interface{}{"CustomerId", "X-Customer"}, err: true},
+		{spec: NewXPathToResponseHeader(Options{}), args: []interface{}{"//CustomerId", "X-Customer"}},
+		{spec: NewTransformRequest(Options{}), args: []interface{}{"replace", "//Trace", "foo"}, err: true},
+		{spec: NewTransformRequest(Options{}), args: []interface{}{"delete", "//Trace", "foo"}, err: true},
+		{spec: NewTransformRequest(Options{}), args: []interface{}{"set", "//Trace"}, err: true},
+		{spec: NewTransformRequest(Options{}), args: []interface{}{"rename", "//Trace/@id", "foo"}, err: true},
+		{spec: NewTransformRequest(Options{}), args: []interface{}{"rename", "//Trace", "<foo>"}, err: true},
+		{spec: NewTransformRequest(Options{}), args: []interface{}{"append", "//Header", "<foo>"}, err: true},
+		{spec: NewTransformRequest(Options{}), args: []interface{}{"append", "//Header", `<!DOCTYPE foo><foo/>`}, err: true},
+		{spec: NewTransformRequest(Options{}), args: []interface{}{"set", "//Trace", 42}, err: true},
+		{spec: NewTransformRequest(Options{}), args: []interface{}{"delete", "//Trace"}},
+		{spec: NewTransformResponse(Options{}), args: []interface{}{"append", "//Header", `<a/><b/>`}},
+	} {
+		_, err := tt.spec.CreateFilter(tt.args)
+		if tt.err && err == nil {
+			t.Errorf("%s%v: failed to fail", tt.spec.Name(), tt.args)
+		} else if !tt.err && err != nil {
+			t.Errorf("%s%v: %v", tt.spec.Name(), tt.args, err)
+		}
+	}
+}
+
+func TestXPathToHeader(t *testing.T) {
+	for _, tt := range []struct {
+		name   string
+		body   string
+		path   string
+		header string
+	}{
+		{name: "element", body: envelope, path: "//CustomerId", header: "42"},
+		{name: "attribute", body: envelope, path: "/Envelope/Body/GetUser/@id", header: "1"},
+		{name: "no match", body: envelope, path: "//Customer"},
+		{name: "invalid header value", body: "<a>foo\nbar</a>", path: "/a"},
+		{name: "invalid document", body: "<a>", path: "/a"},
+		{name: "too large", body: "<a>" + strings.Repeat("x", 1024) + "</a>", path: "/a"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := NewXPathToRequestHeader(Options{MaxBodySize: 1024}).CreateFilter([]interface{}{tt.path, "X-Value"})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			req := httptest.NewRequest("POST", "/", strings.NewReader(tt.body))
+			f.Request(&filtertest.Context{FRequest: req})
+
+			if h := req.Header.Get("X-Value"); h != tt.header {
+				t.Errorf("unexpected header: %q, expected: %q", h, tt.header)
+			}
+
+			if b, err := io.ReadAll(req.Body); err != nil || string(b) != tt.body {
+				t.Errorf("failed to preserve the body: %s, %v", b, err)
+			}
+		})
+	}
+
+	t.Run("response", func(t *testing.T) {
+		f, err := NewXPathToResponseHeader(Options{}).CreateFilter([]interface{}{"//Trace", "X-Trace"})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rsp := &http.Response{Header: make(http.Header), Body: io.NopCloser(strings.NewReader(envelope))}
+		f.Response(&filtertest.Context{FRequest: httptest.NewRequest("GET", "/", nil), FResponse: rsp})
+		if h := rsp.Header.Get("X-Trace"); h != "abc" {
+			t.Errorf("unexpected header: %q", h)
+		}
+	})
+}
+
+func TestTransformRequest(t *testing.T) {
+	for _, tt := range []struct {
+		name   string
+		args   []interface{}
+		body   string
+		status int
+		result string
+	}{{
+		name:   "set element",
+		args:   []interface{}{"set", "//CustomerId", "<43>"},
+		body:   envelope,
+		result: strings.Replace(envelope, " 42 ", "&lt;43&gt;", 1),
+	}, {
+		name:   "set attribute",
+		args:   []interface{}{"set", "//GetUser/@id", `"2"`},
+		body:   envelope,
+		result: strings.Replace(envelope, `id="1"`, `id="&quot;2&quot;"`, 1),
+	}, {
+		name:   "delete element",
+		args:   []interface{}{"delete", "/Envelope/Header/Trace"},
+		body:   envelope,
+		result: strings.Replace(envelope, "<soap:Header><Trace>abc</Trace></soap:Header>", "<soap:Header/>", 1),
+	}, {
+		name:   "delete attribute",
+		args:   []interface{}{"delete", "//@id"},
+		body:   envelope,
+		result: strings.Replace(envelope, ` id="1"`, "", 1),
+	}, {
+		name:   "rename",
+		args:   []interface{}{"rename", "//soap:Body/*", "GetCustomer"},
+		body:   envelope,
+		result: strings.ReplaceAll(envelope, "GetUser", "GetCustomer"),
+	}, {
+		name:   "append",
+		args:   []interface{}{"append", "/Envelope/Header", `<ns:Tenant xmlns:ns="urn:tenant">eu</ns:Tenant>`},
+		body:   envelope,
+		result: strings.Replace(envelope, "</Trace>", `</Trace><ns:Tenant xmlns:ns="urn:tenant">eu</ns:Tenant>`, 1),
+	}, {
+		name:   "no match",
+		args:   []interface{}{"delete", "//Foo"},
+		body:   envelope,
+		result: envelope,
+	}, {
+		name:   "entity expansion",
+		args:   []interface{}{"delete", "//Foo"},
+		body:   `<!DOCTYPE a [<!ENTITY x "xxxxxxxx">]><a>&x;</a>`,
+		status: http.StatusBadRequest,
+	}, {
+		name:   "too large",
+		args:   []interface{}{"delete", "//Foo"},
+		body:   "<a>" + strings.Repeat("x", 1024) + "</a>",
+		status: http.StatusRequestEntityTooLarge,
+	}} {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := NewTransformRequest(Options{MaxBodySize: 1024}).CreateFilter(tt.args)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			req := httptest.NewRequest("POST", "/", strings.NewReader(tt.body))
+			ctx := &filtertest.Context{FRequest: req}
+			f.Request(ctx)
+
+			if tt.status != 0 {
+				if !ctx.FServed || ctx.FResponse.StatusCode != tt.status {
+					t.Errorf("failed to reject the request with %d", tt.status)
+				}
+
+				return
+			}
+
+			b, err := io.ReadAll(req.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if string(b) != tt.result {
+				t.Errorf("unexpected body:\n%s\nexpected:\n%s", b, tt.result)
+			}
+
+			if req.ContentLength != int64(len(b)) {
+				t.Errorf("unexpected content length: %d, expected: %d", req.ContentLength, len(b))
+			}
+		})
+	}
+}
+
+func TestTransformResponse(t *testing.T) {
+	f, err := NewTransformResponse(Options{}).CreateFilter([]interface{}{"delete", "//Trace"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		name   string
+		body   string
+		result string
+	}{
+		{name: "transformed", body: envelope, result: strings.Replace(envelope, "<soap:Header><Trace>abc</Trace></soap:Header>", "<soap:Header/>", 1)},
+		{name: "not xml", body: `{"foo": "bar"}`, result: `{"foo": "bar"}`},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			rsp := &http.Response{Header: make(http.Header), Body: io.NopCloser(strings.NewReader(tt.body))}
+			f.Response(&filtertest.Context{FRequest: httptest.NewRequest("GET", "/", nil), FResponse: rsp})
+
+			b, err := io.ReadAll(rsp.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if string(b) != tt.result {
+				t.Errorf("unexpected body:\n%s\nexpected:\n%s", b, tt.result)
+			}
+		})
+	}
+}
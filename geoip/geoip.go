@@ -0,0 +1,220 @@
+/*
+Package geoip implements the lookup of the geographical location and the
+autonomous system of the client IP addresses, from databases in the
+MaxMind DB format, e.g. GeoLite2-Country and GeoLite2-ASN.
+
+The database files are reloaded when they change, without interrupting
+the lookups.
+*/
+package geoip
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/oschwald/maxminddb-golang"
+	log "github.com/sirupsen/logrus"
+)
+
+const defaultReloadInterval = time.Minute
+
+// Options are used to open the databases.
+type Options struct {
+
+	// Paths contains the paths of the database files. When the same
+	// field is found in multiple databases, the first one is used.
+	Paths []string
+
+	// ReloadInterval defines how often the database files are checked
+	// for changes. Defaults to one minute.
+	ReloadInterval time.Duration
+}
+
+// Location contains the information found about an IP address.
+type Location struct {
+	// Country is the ISO 3166-1 code of the country, e.g. DE.
+	Country string
+
+	// Continent is the code of the continent, e.g. EU.
+	Continent string
+
+	// ASN is the number of the autonomous system.
+	ASN uint
+
+	// ASOrganization is the name of the organization of the autonomous
+	// system.
+	ASOrganization string
+}
+
+type record struct {
+	Country struct {
+		ISOCode string `maxminddb:"iso_code"`
+	} `maxminddb:"country"`
+
+	Continent struct {
+		Code string `maxminddb:"code"`
+	} `maxminddb:"continent"`
+
+	ASN            uint   `maxminddb:"autonomous_system_number"`
+	ASOrganization string `maxminddb:"autonomous_system_organization"`
+}
+
+type database struct {
+	path    string
+	modTime time.Time
+	size    int64
+	reader  *maxminddb.Reader
+}
+
+// DB looks up the IP addresses in the databases.
+type DB struct {
+	mx        sync.RWMutex
+	databases []*database
+	quit      chan struct{}
+	once      sync.Once
+}
+
+// ErrNoDatabase is returned when no database path was provided.
+var ErrNoDatabase = errors.New("no geoip database")
+
+func load(path string) (*database, error) {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	r, err := maxminddb.FromBytes(b)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load geoip database %s: %w", path, err)
+	}
+
+	return &database{path: path, modTime: fi.ModTime(), size: fi.Size(), reader: r}, nil
+}
+
+// Open loads the databases, and starts watching them for changes. The DB
+// needs to be closed when not used anymore.
+func Open(o Options) (*DB, error) {
+	if len(o.Paths) == 0 {
+		return nil, ErrNoDatabase
+	}
+
+	if o.ReloadInterval <= 0 {
+		o.ReloadInterval = defaultReloadInterval
+	}
+
+	db := &DB{quit: make(chan struct{})}
+	for _, p := range o.Paths {
+		d, err := load(p)
+		if err != nil {
+			return nil, err
+		}
+
+		db.databases = append(db.databases, d)
+	}
+
+	go db.watch(o.ReloadInterval)
+	return db, nil
+}
+
+func (db *DB) reload() {
+	db.mx.RLock()
+	current := db.databases
+	db.mx.RUnlock()
+
+	next := make([]*database, len(current))
+	var changed bool
+	for i, d := range current {
+		next[i] = d
+		fi, err := os.Stat(d.path)
+		if err != nil {
+			log.Errorf("Failed to check geoip database %s: %v", d.path, err)
+			continue
+		}
+
+		if fi.ModTime().Equal(d.modTime) && fi.Size() == d.size {
+			continue
+		}
+
+		nd, err := load(d.path)
+		if err != nil {
+			// keep using the previous version
+			log.Errorf("Failed to reload geoip database: %v", err)
+			continue
+		}
+
+		log.Infof("geoip database reloaded: %s", d.path)
+		next[i] = nd
+		changed = true
+	}
+
+	if changed {
+		db.mx.Lock()
+		db.databases = next
+		db.mx.Unlock()
+	}
+}
+
+func (db *DB) watch(interval time.Duration) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-t.C:
+			db.reload()
+		case <-db.quit:
+			return
+		}
+	}
+}
+
+// Lookup returns the location of an IP address. It returns false, when
+// the address was not found in any of the databases.
+func (db *DB) Lookup(ip net.IP) (Location, bool) {
+	var l Location
+	if ip == nil {
+		return l, false
+	}
+
+	db.mx.RLock()
+	databases := db.databases
+	db.mx.RUnlock()
+
+	var found bool
+	for _, d := range databases {
+		var r record
+		_, ok, err := d.reader.LookupNetwork(ip, &r)
+		if err != nil || !ok {
+			continue
+		}
+
+		found = true
+		if l.Country == "" {
+			l.Country = r.Country.ISOCode
+		}
+
+		if l.Continent == "" {
+			l.Continent = r.Continent.Code
+		}
+
+		if l.ASN == 0 {
+			l.ASN = r.ASN
+			l.ASOrganization = r.ASOrganization
+		}
+	}
+
+	return l, found
+}
+
+// Close stops watching the database files.
+func (db *DB) Close() {
+	db.once.Do(func() { close(db.quit) })
+}
@@ -0,0 +1,128 @@
+package geoip_test
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/zalando/skipper/geoip"
+	"github.com/zalando/skipper/geoip/geoiptest"
+)
+
+func country(code, continent string) map[string]interface{} {
+	return map[string]interface{}{
+		"country":   map[string]interface{}{"iso_code": code},
+		"continent": map[string]interface{}{"code": continent},
+	}
+}
+
+func asn(n uint, org string) map[string]interface{} {
+	return map[string]interface{}{
+		"autonomous_system_number":       n,
+		"autonomous_system_organization": org,
+	}
+}
+
+func TestLookup(t *testing.T) {
+	dir := t.TempDir()
+	countries := filepath.Join(dir, "country.mmdb")
+	asns := filepath.Join(dir, "asn.mmdb")
+
+	if err := geoiptest.WriteDB(countries, map[string]map[string]interface{}{
+		"1.2.3.0/24":  country("DE", "EU"),
+		"5.6.0.0/16":  country("US", "NA"),
+		"10.0.0.1/32": country("AT", "EU"),
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := geoiptest.WriteDB(asns, map[string]map[string]interface{}{
+		"1.2.0.0/16": asn(3320, "Deutsche Telekom AG"),
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := geoip.Open(geoip.Options{Paths: []string{countries, asns}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer db.Close()
+
+	for ip, expected := range map[string]geoip.Location{
+		"1.2.3.4":   {Country: "DE", Continent: "EU", ASN: 3320, ASOrganization: "Deutsche Telekom AG"},
+		"1.2.4.4":   {ASN: 3320, ASOrganization: "Deutsche Telekom AG"},
+		"5.6.255.1": {Country: "US", Continent: "NA"},
+		"10.0.0.1":  {Country: "AT", Continent: "EU"},
+	} {
+		l, ok := db.Lookup(net.ParseIP(ip))
+		if !ok || l != expected {
+			t.Errorf("unexpected location for %s: %v, %v", ip, l, ok)
+		}
+	}
+
+	for _, ip := range []string{"10.0.0.2", "8.8.8.8"} {
+		if l, ok := db.Lookup(net.ParseIP(ip)); ok {
+			t.Errorf("unexpected location for %s: %v", ip, l)
+		}
+	}
+
+	if _, ok := db.Lookup(nil); ok {
+		t.Error("unexpected location for nil IP")
+	}
+}
+
+func TestOpenFails(t *testing.T) {
+	if _, err := geoip.Open(geoip.Options{}); err != geoip.ErrNoDatabase {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	path := filepath.Join(t.TempDir(), "invalid.mmdb")
+	os.WriteFile(path, []byte("foo"), 0644)
+	if _, err := geoip.Open(geoip.Options{Paths: []string{path}}); err == nil {
+		t.Error("failed to fail")
+	}
+}
+
+func TestReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "country.mmdb")
+	if err := geoiptest.WriteDB(path, map[string]map[string]interface{}{"1.2.3.0/24": country("DE", "EU")}); err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := geoip.Open(geoip.Options{Paths: []string{path}, ReloadInterval: 10 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer db.Close()
+
+	// invalid content is ignored
+	os.WriteFile(path, []byte("foo"), 0644)
+	time.Sleep(50 * time.Millisecond)
+	if l, _ := db.Lookup(net.ParseIP("1.2.3.4")); l.Country != "DE" {
+		t.Fatalf("unexpected location: %v", l)
+	}
+
+	if err := geoiptest.WriteDB(path, map[string]map[string]interface{}{"1.2.3.0/24": country("FR", "EU")}); err != nil {
+		t.Fatal(err)
+	}
+
+	// make sure the change is detected with coarse file time
+	// resolution
+	future := time.Now().Add(time.Hour)
+	os.Chtimes(path, future, future)
+
+	deadline := time.Now().Add(3 * time.Second)
+	for time.Now().Before(deadline) {
+		if l, _ := db.Lookup(net.ParseIP("1.2.3.4")); l.Country == "FR" {
+			return
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	t.Error("failed to reload the database")
+}
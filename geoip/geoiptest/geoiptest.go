@@ -0,0 +1,227 @@
+/*
+Package geoiptest provides helpers to create MaxMind DB format databases
+for testing.
+*/
+package geoiptest
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"os"
+	"sort"
+	"time"
+)
+
+const metadataMarker = "\xab\xcd\xefMaxMind.com"
+
+type record struct {
+	kind  int
+	node  *node
+	index int
+}
+
+const (
+	emptyRecord = iota
+	nodeRecord
+	dataRecord
+)
+
+type node struct {
+	records [2]record
+	id      int
+}
+
+// WriteDB writes an IPv4 database to the path. The keys of the networks
+// are CIDRs, the values are the data of the networks, where the values can
+// be maps, strings, unsigned integers or slices of them. The networks must
+// not overlap.
+func WriteDB(path string, networks map[string]map[string]interface{}) error {
+	b, err := Build(networks)
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, b, 0644)
+}
+
+// Build returns the binary form of an IPv4 database. See WriteDB.
+func Build(networks map[string]map[string]interface{}) ([]byte, error) {
+	root := &node{}
+
+	var (
+		data    bytes.Buffer
+		offsets []int
+		cidrs   []string
+	)
+
+	for cidr := range networks {
+		cidrs = append(cidrs, cidr)
+	}
+
+	sort.Strings(cidrs)
+	for _, cidr := range cidrs {
+		_, n, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, err
+		}
+
+		ip := n.IP.To4()
+		ones, _ := n.Mask.Size()
+		if ip == nil || ones == 0 {
+			return nil, fmt.Errorf("unsupported network: %s", cidr)
+		}
+
+		offsets = append(offsets, data.Len())
+		if err := encode(&data, networks[cidr]); err != nil {
+			return nil, err
+		}
+
+		current := root
+		for i := 0; i < ones; i++ {
+			bit := ip[i/8] >> (7 - uint(i%8)) & 1
+			if i == ones-1 {
+				current.records[bit] = record{kind: dataRecord, index: len(offsets) - 1}
+				break
+			}
+
+			if current.records[bit].kind != nodeRecord {
+				current.records[bit] = record{kind: nodeRecord, node: &node{}}
+			}
+
+			current = current.records[bit].node
+		}
+	}
+
+	// number the nodes in breadth first order
+	nodes := []*node{root}
+	for i := 0; i < len(nodes); i++ {
+		nodes[i].id = i
+		for _, r := range nodes[i].records {
+			if r.kind == nodeRecord {
+				nodes = append(nodes, r.node)
+			}
+		}
+	}
+
+	var out bytes.Buffer
+	nodeCount := len(nodes)
+	for _, n := range nodes {
+		for _, r := range n.records {
+			var v int
+			switch r.kind {
+			case emptyRecord:
+				v = nodeCount
+			case nodeRecord:
+				v = r.node.id
+			case dataRecord:
+				v = nodeCount + 16 + offsets[r.index]
+			}
+
+			out.Write([]byte{byte(v >> 16), byte(v >> 8), byte(v)})
+		}
+	}
+
+	out.Write(make([]byte, 16))
+	out.Write(data.Bytes())
+	out.WriteString(metadataMarker)
+	err := encode(&out, map[string]interface{}{
+		"binary_format_major_version": uint16(2),
+		"binary_format_minor_version": uint16(0),
+		"build_epoch":                 uint64(time.Now().Unix()),
+		"database_type":               "Test",
+		"description":                 map[string]interface{}{"en": "Test database"},
+		"ip_version":                  uint16(4),
+		"languages":                   []interface{}{"en"},
+		"node_count":                  uint32(nodeCount),
+		"record_size":                 uint16(24),
+	})
+
+	return out.Bytes(), err
+}
+
+func writeControl(b *bytes.Buffer, typ, size int) {
+	var first byte
+	if typ <= 7 {
+		first = byte(typ << 5)
+	}
+
+	var ext []byte
+	switch {
+	case size < 29:
+		first |= byte(size)
+	case size < 29+256:
+		first |= 29
+		ext = []byte{byte(size - 29)}
+	default:
+		first |= 30
+		s := size - 285
+		ext = []byte{byte(s >> 8), byte(s)}
+	}
+
+	b.WriteByte(first)
+	if typ > 7 {
+		b.WriteByte(byte(typ - 7))
+	}
+
+	b.Write(ext)
+}
+
+func writeUint(b *bytes.Buffer, typ int, v uint64) {
+	var buf [8]byte
+	binary.BigEndian.PutUint64(buf[:], v)
+	p := buf[:]
+	for len(p) > 0 && p[0] == 0 {
+		p = p[1:]
+	}
+
+	writeControl(b, typ, len(p))
+	b.Write(p)
+}
+
+func encode(b *bytes.Buffer, v interface{}) error {
+	switch vt := v.(type) {
+	case string:
+		writeControl(b, 2, len(vt))
+		b.WriteString(vt)
+	case uint16:
+		writeUint(b, 5, uint64(vt))
+	case uint32:
+		writeUint(b, 6, uint64(vt))
+	case uint:
+		writeUint(b, 6, uint64(vt))
+	case int:
+		writeUint(b, 6, uint64(vt))
+	case uint64:
+		writeUint(b, 9, vt)
+	case map[string]interface{}:
+		keys := make([]string, 0, len(vt))
+		for k := range vt {
+			keys = append(keys, k)
+		}
+
+		sort.Strings(keys)
+		writeControl(b, 7, len(keys))
+		for _, k := range keys {
+			if err := encode(b, k); err != nil {
+				return err
+			}
+
+			if err := encode(b, vt[k]); err != nil {
+				return err
+			}
+		}
+	case []interface{}:
+		writeControl(b, 11, len(vt))
+		for _, vi := range vt {
+			if err := encode(b, vi); err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("unsupported type: %T", v)
+	}
+
+	return nil
+}
@@ -444,9 +444,14 @@ func parseEndpoints(r *routing.Route) error {
 			return err
 		}
 
+		host, err := routing.EndpointHost(eu)
+		if err != nil {
+			return err
+		}
+
 		r.LBEndpoints[i] = routing.LBEndpoint{
 			Scheme:  eu.Scheme,
-			Host:    eu.Host,
+			Host:    host,
 			Metrics: &routing.LBMetrics{},
 		}
 	}
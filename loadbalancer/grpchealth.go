@@ -0,0 +1,228 @@
+package loadbalancer
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/zalando/skipper/eskip"
+	"github.com/zalando/skipper/filters"
+	"golang.org/x/net/http2"
+)
+
+const (
+	grpcHealthCheckPath    = "/grpc.health.v1.Health/Check"
+	grpcHealthCheckTimeout = 10 * time.Second
+	grpcMaxResponseSize    = 1 << 10
+
+	// grpc.health.v1.HealthCheckResponse.ServingStatus
+	grpcServing = 1
+
+	grpcStatusOK            = "0"
+	grpcStatusUnimplemented = "12"
+)
+
+// healthCheck defines how the backend of a route is checked
+type healthCheck struct {
+	grpc    bool
+	service string
+}
+
+// returns the health check of the route, configured by the
+// grpcHealthCheck filter
+func routeHealthCheck(r *eskip.Route) healthCheck {
+	for _, f := range r.Filters {
+		if f.Name != filters.GRPCHealthCheckName {
+			continue
+		}
+
+		hc := healthCheck{grpc: true}
+		if len(f.Args) > 0 {
+			hc.service, _ = f.Args[0].(string)
+		}
+
+		return hc
+	}
+
+	return healthCheck{}
+}
+
+// grpcTransport sends the health checks over HTTP/2, using h2c (HTTP/2
+// without TLS) for the http backends.
+type grpcTransport struct {
+	h2c, h2 *http2.Transport
+}
+
+func newGRPCTransport() *grpcTransport {
+	dialer := &net.Dialer{Timeout: 3000 * time.Millisecond}
+	return &grpcTransport{
+		h2c: &http2.Transport{
+			AllowHTTP: true,
+			DialTLSContext: func(ctx context.Context, network, addr string, _ *tls.Config) (net.Conn, error) {
+				return dialer.DialContext(ctx, network, addr)
+			},
+		},
+		h2: &http2.Transport{},
+	}
+}
+
+func (t *grpcTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.URL.Scheme == "http" {
+		return t.h2c.RoundTrip(req)
+	}
+
+	return t.h2.RoundTrip(req)
+}
+
+// encodes the grpc.health.v1.HealthCheckRequest message with the service
+// name as a length-prefixed gRPC message
+func grpcHealthCheckRequest(service string) []byte {
+	var msg []byte
+	if service != "" {
+		msg = append(msg, 0x0a) // field 1, length-delimited
+		msg = binary.AppendUvarint(msg, uint64(len(service)))
+		msg = append(msg, service...)
+	}
+
+	b := make([]byte, 5, 5+len(msg))
+	binary.BigEndian.PutUint32(b[1:], uint32(len(msg)))
+	return append(b, msg...)
+}
+
+// parses the status field of the grpc.health.v1.HealthCheckResponse
+// message from a length-prefixed, uncompressed gRPC message
+func grpcHealthStatus(b []byte) (uint64, bool) {
+	if len(b) < 5 || b[0] != 0 {
+		return 0, false
+	}
+
+	n := binary.BigEndian.Uint32(b[1:5])
+	if uint64(len(b)-5) < uint64(n) {
+		return 0, false
+	}
+
+	var status uint64
+	msg := b[5 : 5+n]
+	for len(msg) > 0 {
+		tag, l := binary.Uvarint(msg)
+		if l <= 0 {
+			return 0, false
+		}
+
+		msg = msg[l:]
+		switch tag & 7 {
+		case 0:
+			v, l := binary.Uvarint(msg)
+			if l <= 0 {
+				return 0, false
+			}
+
+			msg = msg[l:]
+			if tag>>3 == 1 {
+				status = v
+			}
+		case 1:
+			if len(msg) < 8 {
+				return 0, false
+			}
+
+			msg = msg[8:]
+		case 2:
+			size, l := binary.Uvarint(msg)
+			if l <= 0 || uint64(len(msg)-l) < size {
+				return 0, false
+			}
+
+			msg = msg[l+int(size):]
+		case 5:
+			if len(msg) < 4 {
+				return 0, false
+			}
+
+			msg = msg[4:]
+		default:
+			return 0, false
+		}
+	}
+
+	return status, true
+}
+
+func doGRPCHealthCheck(rt http.RoundTripper, backend, service string) state {
+	u, err := url.Parse(backend)
+	if err != nil {
+		log.Errorf("Failed to parse route backend %s: %v", backend, err)
+		return unknown
+	}
+
+	u.Path = grpcHealthCheckPath
+	u.RawQuery = ""
+
+	ctx, cancel := context.WithTimeout(context.Background(), grpcHealthCheckTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, "POST", u.String(), bytes.NewReader(grpcHealthCheckRequest(service)))
+	if err != nil {
+		log.Errorf("Failed to create gRPC health check request: %v", err)
+		return unknown
+	}
+
+	req.Header.Set("Content-Type", "application/grpc")
+	req.Header.Set("Te", "trailers")
+
+	resp, err := rt.RoundTrip(req)
+	if err != nil {
+		return roundTripErrorState(backend, err)
+	}
+
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, grpcMaxResponseSize))
+	if err == nil {
+		// the trailers are available after reading the whole body
+		_, err = io.Copy(io.Discard, resp.Body)
+	}
+
+	if err != nil {
+		log.Infof("Backend %v failed to respond to the gRPC health check: %v -> mark as unhealthy", backend, err)
+		return unhealthy
+	}
+
+	grpcStatus := resp.Trailer.Get("Grpc-Status")
+	if grpcStatus == "" {
+		// trailers-only response
+		grpcStatus = resp.Header.Get("Grpc-Status")
+	}
+
+	switch {
+	case resp.StatusCode != http.StatusOK:
+		log.Infof("Backend %v responded to the gRPC health check with status %d -> mark as unhealthy", backend, resp.StatusCode)
+		return unhealthy
+	case grpcStatus == grpcStatusUnimplemented:
+		log.Errorf("Backend %v doesn't implement the gRPC health checking protocol", backend)
+		return unknown
+	case grpcStatus != grpcStatusOK:
+		log.Infof("Backend %v responded to the gRPC health check with gRPC status %s -> mark as unhealthy", backend, grpcStatus)
+		return unhealthy
+	}
+
+	status, ok := grpcHealthStatus(body)
+	if !ok {
+		log.Infof("Backend %v sent an invalid gRPC health check response -> mark as unhealthy", backend)
+		return unhealthy
+	}
+
+	if status != grpcServing {
+		log.Infof("Backend %v service %q is not serving, status: %d -> mark as unhealthy", backend, service, status)
+		return unhealthy
+	}
+
+	log.Infof("Backend %v is healthy again", backend)
+	return healthy
+}
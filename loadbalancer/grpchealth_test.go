@@ -0,0 +1,177 @@
+package loadbalancer
+
+import (
+	"encoding/binary"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/zalando/skipper/eskip"
+	"github.com/zalando/skipper/routing"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+)
+
+func grpcHealthCheckResponse(status byte) []byte {
+	return []byte{0, 0, 0, 0, 2, 0x08, status}
+}
+
+// serves the grpc.health.v1.Health/Check calls, responding with the
+// status of the requested service
+func grpcHealthServer(t *testing.T, statuses map[string]byte) *httptest.Server {
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != grpcHealthCheckPath || r.ProtoMajor != 2 {
+			w.Header().Set("Content-Type", "application/grpc")
+			w.Header().Set("Grpc-Status", grpcStatusUnimplemented)
+			return
+		}
+
+		b, _ := io.ReadAll(r.Body)
+		var service string
+		if len(b) > 7 && b[5] == 0x0a {
+			l, n := binary.Uvarint(b[6:])
+			service = string(b[6+n : 6+n+int(l)])
+		}
+
+		w.Header().Set("Content-Type", "application/grpc")
+		status, ok := statuses[service]
+		if !ok {
+			// NOT_FOUND
+			w.Header().Set("Grpc-Status", "5")
+			return
+		}
+
+		w.Header().Set("Trailer", "Grpc-Status")
+		w.Write(grpcHealthCheckResponse(status))
+		w.Header().Set("Grpc-Status", grpcStatusOK)
+	})
+
+	s := httptest.NewServer(h2c.NewHandler(h, &http2.Server{}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func TestGRPCHealthCheck(t *testing.T) {
+	s := grpcHealthServer(t, map[string]byte{
+		"":            grpcServing,
+		"app.Serving": grpcServing,
+		"app.Stopped": 2,
+	})
+
+	rt := newGRPCTransport()
+	for _, tt := range []struct {
+		title    string
+		backend  string
+		service  string
+		expected state
+	}{{
+		title:    "server",
+		backend:  s.URL,
+		expected: healthy,
+	}, {
+		title:    "serving service",
+		backend:  s.URL,
+		service:  "app.Serving",
+		expected: healthy,
+	}, {
+		title:    "not serving service",
+		backend:  s.URL,
+		service:  "app.Stopped",
+		expected: unhealthy,
+	}, {
+		title:    "unknown service",
+		backend:  s.URL,
+		service:  "app.Unknown",
+		expected: unhealthy,
+	}, {
+		title:    "connection refused",
+		backend:  "http://127.0.0.1:1333",
+		expected: dead,
+	}} {
+		t.Run(tt.title, func(t *testing.T) {
+			if st := doGRPCHealthCheck(rt, tt.backend, tt.service); st != tt.expected {
+				t.Errorf("unexpected state, expected: %d, got: %d", tt.expected, st)
+			}
+		})
+	}
+}
+
+func TestGRPCHealthCheckUnimplemented(t *testing.T) {
+	s := httptest.NewServer(h2c.NewHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/grpc")
+		w.Header().Set("Grpc-Status", grpcStatusUnimplemented)
+	}), &http2.Server{}))
+	defer s.Close()
+
+	if st := doGRPCHealthCheck(newGRPCTransport(), s.URL, ""); st != unknown {
+		t.Errorf("unexpected state: %d", st)
+	}
+}
+
+func TestGRPCHealthStatus(t *testing.T) {
+	for _, tt := range []struct {
+		title  string
+		msg    []byte
+		status uint64
+		ok     bool
+	}{
+		{"serving", grpcHealthCheckResponse(1), 1, true},
+		{"default status", []byte{0, 0, 0, 0, 0}, 0, true},
+		{"truncated field", []byte{0, 0, 0, 0, 9, 0x12, 2, 'a', 'b', 0x08, 2, 0x1d, 0, 0}, 0, false},
+		{"skipped fields", []byte{0, 0, 0, 0, 13, 0x12, 2, 'a', 'b', 0x08, 2, 0x1d, 0, 0, 0, 0, 0x18, 1}, 2, true},
+		{"compressed", []byte{1, 0, 0, 0, 2, 0x08, 1}, 0, false},
+		{"short", []byte{0, 0, 0, 0, 3, 0x08, 1}, 0, false},
+	} {
+		t.Run(tt.title, func(t *testing.T) {
+			status, ok := grpcHealthStatus(tt.msg)
+			if ok != tt.ok || ok && status != tt.status {
+				t.Errorf("unexpected status: %d, %v", status, ok)
+			}
+		})
+	}
+}
+
+func TestRouteHealthCheck(t *testing.T) {
+	routes, err := eskip.Parse(`
+		http: * -> "http://10.0.0.1";
+		server: * -> grpcHealthCheck() -> "http://10.0.0.2";
+		service: * -> grpcHealthCheck("app.Service") -> "http://10.0.0.3";
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []healthCheck{{}, {grpc: true}, {grpc: true, service: "app.Service"}}
+	for i, r := range routes {
+		if hc := routeHealthCheck(r); hc != expected[i] {
+			t.Errorf("%s: unexpected health check: %+v", r.Id, hc)
+		}
+	}
+}
+
+func TestFilterHealthyMemberRoutesRecordsGRPCHealthChecks(t *testing.T) {
+	routes, err := eskip.Parse(`
+		http: * -> "http://10.0.0.1";
+		grpc: * -> grpcHealthCheck("app.Service") -> "http://10.0.0.2";
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var rr []*routing.Route
+	for _, r := range routes {
+		rr = append(rr, &routing.Route{Route: *r})
+	}
+
+	lb := &LB{routeState: make(map[string]state), checks: make(map[string]healthCheck)}
+	lb.FilterHealthyMemberRoutes(rr)
+	if len(lb.checks) != 1 || lb.checks["http://10.0.0.2"] != (healthCheck{grpc: true, service: "app.Service"}) {
+		t.Errorf("unexpected health checks: %+v", lb.checks)
+	}
+
+	lb.FilterHealthyMemberRoutes(rr[:1])
+	if len(lb.checks) != 0 {
+		t.Errorf("failed to remove the health check: %+v", lb.checks)
+	}
+}
@@ -39,6 +39,7 @@ type LB struct {
 	stop                bool
 	healthcheckInterval time.Duration
 	routeState          map[string]state
+	checks              map[string]healthCheck
 }
 
 // HealthcheckPostProcessor wraps the LB structure implementing the
@@ -66,6 +67,7 @@ func New(healthcheckInterval time.Duration) *LB {
 		stop:                false,
 		healthcheckInterval: healthcheckInterval,
 		routeState:          make(map[string]state),
+		checks:              make(map[string]healthCheck),
 	}
 	go lb.populateChecks()
 	go lb.startDoHealthChecks()
@@ -108,8 +110,13 @@ func (lb *LB) FilterHealthyMemberRoutes(routes []*routing.Route) []*routing.Rout
 	}
 	var result []*routing.Route
 	knownBackends := make(map[string]bool)
+	checks := make(map[string]healthCheck)
 	for _, r := range routes {
 		knownBackends[r.Backend] = true
+		if hc := routeHealthCheck(&r.Route); hc.grpc && r.Backend != "" {
+			checks[r.Backend] = hc
+		}
+
 		if r.BackendType == eskip.LBBackend {
 			var st state
 			lb.RLock()
@@ -134,6 +141,8 @@ func (lb *LB) FilterHealthyMemberRoutes(routes []*routing.Route) []*routing.Rout
 			delete(lb.routeState, b)
 		}
 	}
+
+	lb.checks = checks
 	lb.Unlock()
 
 	log.Debugf("filterRoutes incoming=%d outgoing=%d", len(routes), len(result))
@@ -159,6 +168,8 @@ func (lb *LB) startDoHealthChecks() {
 		IdleConnTimeout:       10 * time.Second,
 	}
 
+	grpcRT := newGRPCTransport()
+
 	for {
 		select {
 		case <-healthTicker.C:
@@ -166,13 +177,21 @@ func (lb *LB) startDoHealthChecks() {
 
 			lb.RLock()
 			backends := make([]string, 0, len(lb.routeState))
+			checks := make(map[string]healthCheck)
 			for b := range lb.routeState {
 				backends = append(backends, b)
+				checks[b] = lb.checks[b]
 			}
 			lb.RUnlock()
 
 			for _, backend := range backends {
-				st := doActiveHealthCheck(rt, backend)
+				var st state
+				if hc := checks[backend]; hc.grpc {
+					st = doGRPCHealthCheck(grpcRT, backend, hc.service)
+				} else {
+					st = doActiveHealthCheck(rt, backend)
+				}
+
 				switch st {
 				case unknown:
 					continue
@@ -214,17 +233,7 @@ func doActiveHealthCheck(rt http.RoundTripper, backend string) state {
 
 	resp, err := rt.RoundTrip(req)
 	if err != nil {
-		perr, ok := err.(net.Error)
-		//lint:ignore SA1019 Temporary is deprecated in Go 1.18, but keep it for now (https://github.com/zalando/skipper/issues/1992)
-		if ok && !perr.Temporary() {
-			log.Infof("Backend %v connection refused -> mark as dead", backend)
-			return dead
-		} else if ok {
-			log.Infof("Backend %v with temporary error '%v' -> mark as unhealthy", backend, perr)
-			return unhealthy
-		}
-		log.Errorf("Failed to do health check, but no network error: %v", err)
-		return unknown
+		return roundTripErrorState(backend, err)
 	}
 
 	// we only check StatusCode
@@ -235,3 +244,19 @@ func doActiveHealthCheck(rt http.RoundTripper, backend string) state {
 	log.Infof("Backend %v is healthy again", backend)
 	return healthy
 }
+
+// returns the state of the backend based on the error of the health
+// check request
+func roundTripErrorState(backend string, err error) state {
+	perr, ok := err.(net.Error)
+	//lint:ignore SA1019 Temporary is deprecated in Go 1.18, but keep it for now (https://github.com/zalando/skipper/issues/1992)
+	if ok && !perr.Temporary() {
+		log.Infof("Backend %v connection refused -> mark as dead", backend)
+		return dead
+	} else if ok {
+		log.Infof("Backend %v with temporary error '%v' -> mark as unhealthy", backend, perr)
+		return unhealthy
+	}
+	log.Errorf("Failed to do health check, but no network error: %v", err)
+	return unknown
+}
@@ -1,6 +1,8 @@
 package logging
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"net"
 	"net/http"
@@ -9,8 +11,10 @@ import (
 
 	"github.com/sirupsen/logrus"
 
+	al "github.com/zalando/skipper/filters/accesslog"
 	flowidFilter "github.com/zalando/skipper/filters/flowid"
 	logFilter "github.com/zalando/skipper/filters/log"
+	"github.com/zalando/skipper/logging/eventsink"
 )
 
 const (
@@ -44,16 +48,43 @@ type AccessEntry struct {
 
 	// The time that the request was received.
 	RequestTime time.Time
+
+	// The resolved IP address of the client, when available. It is
+	// logged instead of the remote host of the request.
+	ClientIP net.IP
+
+	// The identity of the caller published by the auth filters, when
+	// available. Only the fields set in the AccessLogIdentityFields
+	// option are logged.
+	Identity *al.Identity
+
+	// The class of the backend error, when the request to the backend
+	// failed, e.g. dns, dial_timeout or response_header_timeout.
+	BackendError string
+}
+
+type identityField struct {
+	key     string
+	value   func(*al.Identity) string
+	masking string
 }
 
 // TODO: create individual instances from the access log and
 // delegate the ownership from the package level to the user
 // code.
 var (
-	accessLog  *logrus.Logger
-	stripQuery bool
+	accessLog      *logrus.Logger
+	stripQuery     bool
+	identityFields []identityField
+	eventSink      eventsink.Sink
 )
 
+// SetEventSink sets a sink that receives the access log entries as
+// events, in addition to the access log. When nil, no events are sent.
+func SetEventSink(s eventsink.Sink) {
+	eventSink = s
+}
+
 // strip port from addresses with hostname, ipv4 or ipv6
 func stripPort(address string) string {
 	if h, _, err := net.SplitHostPort(address); err == nil {
@@ -106,6 +137,53 @@ func stripQueryString(u string) string {
 	}
 }
 
+// parses the identity fields in the form of <field>[:hash|:mask], where field
+// is one of subject, realm or client-id. Invalid fields are skipped.
+func parseIdentityFields(fields []string) []identityField {
+	var parsed []identityField
+	for _, f := range fields {
+		name, masking, _ := strings.Cut(f, ":")
+		if masking != "" && masking != "hash" && masking != "mask" {
+			continue
+		}
+
+		var value func(*al.Identity) string
+		switch name {
+		case "subject":
+			value = func(id *al.Identity) string { return id.Subject }
+		case "realm":
+			value = func(id *al.Identity) string { return id.Realm }
+		case "client-id":
+			value = func(id *al.Identity) string { return id.ClientID }
+		default:
+			continue
+		}
+
+		parsed = append(parsed, identityField{key: "auth-" + name, value: value, masking: masking})
+	}
+
+	return parsed
+}
+
+// hash: the first 16 hexadecimal characters of the SHA-256 hash of the
+// value, mask: only the first and the last characters of the value are kept
+func maskIdentityValue(v, masking string) string {
+	switch masking {
+	case "hash":
+		h := sha256.Sum256([]byte(v))
+		return hex.EncodeToString(h[:])[:16]
+	case "mask":
+		r := []rune(v)
+		if len(r) <= 2 {
+			return strings.Repeat("*", len(r))
+		}
+
+		return string(r[0]) + strings.Repeat("*", len(r)-2) + string(r[len(r)-1])
+	default:
+		return v
+	}
+}
+
 // Logs an access event in Apache combined log format (with a minor customization with the duration).
 // Additional allows to provide extra data that may be also logged, depending on the specific log format.
 func LogAccess(entry *AccessEntry, additional map[string]interface{}) {
@@ -130,7 +208,11 @@ func LogAccess(entry *AccessEntry, additional map[string]interface{}) {
 	duration := int64(entry.Duration / time.Millisecond)
 
 	if entry.Request != nil {
-		host = remoteHost(entry.Request)
+		if entry.ClientIP != nil {
+			host = entry.ClientIP.String()
+		} else {
+			host = remoteHost(entry.Request)
+		}
 		method = entry.Request.Method
 		proto = entry.Request.Proto
 		referer = entry.Request.Referer()
@@ -162,9 +244,29 @@ func LogAccess(entry *AccessEntry, additional map[string]interface{}) {
 		"audit":          auditHeader,
 	}
 
+	if entry.Identity != nil {
+		for _, f := range identityFields {
+			if v := f.value(entry.Identity); v != "" {
+				logData[f.key] = maskIdentityValue(v, f.masking)
+			}
+		}
+	}
+
+	if entry.BackendError != "" {
+		logData["backend-error"] = entry.BackendError
+	}
+
 	for k, v := range additional {
 		logData[k] = v
 	}
 
 	accessLog.WithFields(logData).Infoln()
+
+	if eventSink != nil {
+		eventSink.Send(&eventsink.Event{
+			Type: eventsink.AccessEvent,
+			Time: entry.RequestTime,
+			Data: logData,
+		})
+	}
 }
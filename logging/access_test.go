@@ -2,12 +2,14 @@ package logging
 
 import (
 	"bytes"
+	"net"
 	"net/http"
 	"testing"
 	"time"
 
 	"github.com/sirupsen/logrus"
 
+	al "github.com/zalando/skipper/filters/accesslog"
 	logFilter "github.com/zalando/skipper/filters/log"
 )
 
@@ -97,6 +99,17 @@ func TestUseXForwarded(t *testing.T) {
 	)
 }
 
+func TestUseResolvedClientIP(t *testing.T) {
+	entry := testAccessEntry()
+	entry.Request.Header.Set("X-Forwarded-For", "192.168.3.3, 10.0.0.1")
+	entry.ClientIP = net.ParseIP("192.168.3.3")
+	testAccessLogDefault(
+		t,
+		entry,
+		`192.168.3.3 - - [10/Oct/2000:13:55:36 -0700] "GET /apache_pb.gif HTTP/1.1" 418 2326 "-" "-" 42 example.com - -`,
+	)
+}
+
 func TestUseXForwardedList(t *testing.T) {
 	entry := testAccessEntry()
 	entry.Request.Header.Set("X-Forwarded-For", "192.168.3.3, 192.168.4.4")
@@ -199,6 +212,17 @@ func TestPresentFlowIdJSON(t *testing.T) {
 	)
 }
 
+func TestPresentBackendErrorJSON(t *testing.T) {
+	entry := testAccessEntry()
+	entry.BackendError = "dial_timeout"
+	testAccessLog(
+		t,
+		entry,
+		`{"audit":"","backend-error":"dial_timeout","duration":42,"flow-id":"","host":"127.0.0.1","level":"info","method":"GET","msg":"","proto":"HTTP/1.1","referer":"","requested-host":"example.com","response-size":2326,"status":418,"timestamp":"10/Oct/2000:13:55:36 -0700","uri":"/apache_pb.gif","user-agent":""}`,
+		Options{AccessLogJSONEnabled: true},
+	)
+}
+
 func TestPresentAudit(t *testing.T) {
 	entry := testAccessEntry()
 	entry.Request.Header.Set(logFilter.UnverifiedAuditHeader, "c4ddfe9d-a0d3-4afb-bf26-24b9588731a0")
@@ -237,6 +261,44 @@ func TestPresentAuditJSONWithCustomFormatter(t *testing.T) {
 	)
 }
 
+func TestAccessLogIdentity(t *testing.T) {
+	entry := testAccessEntry()
+	entry.Identity = &al.Identity{Subject: "jdoe", Realm: "/employees", ClientID: "my-client"}
+
+	for _, ti := range []struct {
+		msg      string
+		fields   []string
+		expected string
+	}{{
+		msg:      "no fields",
+		expected: logJSONOutput,
+	}, {
+		msg:      "plain fields",
+		fields:   []string{"subject", "realm", "client-id"},
+		expected: `{"audit":"","auth-client-id":"my-client","auth-realm":"/employees","auth-subject":"jdoe",` + logJSONOutput[len(`{"audit":"",`):],
+	}, {
+		msg:      "masked fields",
+		fields:   []string{"subject:hash", "client-id:mask"},
+		expected: `{"audit":"","auth-client-id":"m*******t","auth-subject":"d30a5f57532a6036",` + logJSONOutput[len(`{"audit":"",`):],
+	}, {
+		msg:      "invalid fields",
+		fields:   []string{"email", "realm:encrypt"},
+		expected: logJSONOutput,
+	}} {
+		t.Run(ti.msg, func(t *testing.T) {
+			testAccessLog(t, entry, ti.expected, Options{AccessLogJSONEnabled: true, AccessLogIdentityFields: ti.fields})
+		})
+	}
+
+	entry.Identity = &al.Identity{Subject: "jd"}
+	testAccessLog(
+		t,
+		entry,
+		`{"audit":"","auth-subject":"**",`+logJSONOutput[len(`{"audit":"",`):],
+		Options{AccessLogJSONEnabled: true, AccessLogIdentityFields: []string{"subject:mask", "realm"}},
+	)
+}
+
 func TestAccessLogStripQuery(t *testing.T) {
 	entry := testAccessEntry()
 	entry.Request.RequestURI += "?foo=bar"
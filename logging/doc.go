@@ -41,9 +41,14 @@ This is specially useful when more request/response information is needed when l
 
 # Output Files
 
-To set a custom file output for the application log or the access log is
-currently not recommended in production environment, because neither the
-proper handling of system errors, or a log rolling mechanism is
-implemented at the current stage.
+To set a custom file output for the application log is currently not
+recommended in production environment, because neither the proper
+handling of system errors, or a log rolling mechanism is implemented at
+the current stage.
+
+The access log output is created with NewOutput. Besides a file, it can
+be a syslog server, receiving the entries as RFC5424 messages, or an
+HTTP endpoint, receiving the entries in batches. The access log files
+can be rotated based on their size and age.
 */
 package logging
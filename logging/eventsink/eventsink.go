@@ -0,0 +1,232 @@
+/*
+Package eventsink implements streaming the access, audit and detection
+events to external systems, e.g. to a Kafka topic.
+
+The events are sent asynchronously: the Async sink buffers them in
+memory, and forwards them in batches to a Writer. When the buffer is
+full, the sink either drops the new events, or, when configured so,
+waits for a limited time for free space in the buffer. This way a slow
+or unavailable event backend never blocks the proxied requests for
+longer than the configured time.
+*/
+package eventsink
+
+import (
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/zalando/skipper/metrics"
+)
+
+const (
+	// AccessEvent is the type of the events representing the
+	// access log entries.
+	AccessEvent = "access"
+
+	// AuditEvent is the type of the events created by the auditLog
+	// filter.
+	AuditEvent = "audit"
+
+	// DetectionEvent is the type of the events representing
+	// detected malicious requests, e.g. by WAF-style filters.
+	DetectionEvent = "detection"
+)
+
+const (
+	defaultBufferSize    = 1024
+	defaultBatchSize     = 100
+	defaultFlushInterval = time.Second
+
+	sentMetric    = "eventsink.sent"
+	droppedMetric = "eventsink.dropped"
+	failedMetric  = "eventsink.failed"
+)
+
+// Event represents a single event streamed to the sink.
+type Event struct {
+
+	// Type of the event, e.g. AccessEvent.
+	Type string `json:"type"`
+
+	// Time when the event happened.
+	Time time.Time `json:"time"`
+
+	// Data contains the event specific fields.
+	Data map[string]interface{} `json:"data,omitempty"`
+}
+
+// Sink receives the events. Implementations must be safe for
+// concurrent use, and Send must not block for an unbounded time.
+type Sink interface {
+	Send(*Event)
+	Close()
+}
+
+// Writer writes batches of events to an external system.
+type Writer interface {
+	Write([]*Event) error
+	Close() error
+}
+
+// Options for the Async sink.
+type Options struct {
+
+	// BufferSize sets how many events can be buffered before they
+	// are written. Defaults to 1024.
+	BufferSize int
+
+	// BatchSize sets the maximum number of events written at once.
+	// Defaults to 100.
+	BatchSize int
+
+	// FlushInterval sets how often the buffered events are written
+	// when there are less than BatchSize of them. Defaults to 1s.
+	FlushInterval time.Duration
+
+	// BlockTimeout, when set, makes Send wait for free space in a
+	// full buffer for at most the configured duration, before
+	// dropping the event. By default, the events are dropped
+	// immediately.
+	BlockTimeout time.Duration
+
+	// Metrics, when set, is used to count the sent, dropped and
+	// failed events.
+	Metrics metrics.Metrics
+}
+
+// Async is a sink that buffers the events and writes them in batches in
+// the background.
+type Async struct {
+	options Options
+	writer  Writer
+	events  chan *Event
+	quit    chan struct{}
+	done    chan struct{}
+	once    sync.Once
+}
+
+// NewAsync creates a sink forwarding the events to w.
+func NewAsync(w Writer, o Options) *Async {
+	if o.BufferSize <= 0 {
+		o.BufferSize = defaultBufferSize
+	}
+
+	if o.BatchSize <= 0 {
+		o.BatchSize = defaultBatchSize
+	}
+
+	if o.FlushInterval <= 0 {
+		o.FlushInterval = defaultFlushInterval
+	}
+
+	if o.Metrics == nil {
+		o.Metrics = metrics.Void
+	}
+
+	s := &Async{
+		options: o,
+		writer:  w,
+		events:  make(chan *Event, o.BufferSize),
+		quit:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+
+	go s.run()
+	return s
+}
+
+// Send buffers an event. When the buffer is full, the event is dropped,
+// optionally after waiting for the configured BlockTimeout.
+func (s *Async) Send(e *Event) {
+	select {
+	case <-s.quit:
+		s.options.Metrics.IncCounter(droppedMetric)
+		return
+	default:
+	}
+
+	select {
+	case s.events <- e:
+		return
+	default:
+	}
+
+	if s.options.BlockTimeout > 0 {
+		t := time.NewTimer(s.options.BlockTimeout)
+		defer t.Stop()
+		select {
+		case s.events <- e:
+			return
+		case <-t.C:
+		case <-s.quit:
+		}
+	}
+
+	s.options.Metrics.IncCounter(droppedMetric)
+}
+
+func (s *Async) write(batch []*Event) {
+	if len(batch) == 0 {
+		return
+	}
+
+	if err := s.writer.Write(batch); err != nil {
+		log.Errorf("Failed to write %d events: %v", len(batch), err)
+		s.options.Metrics.IncCounterBy(failedMetric, int64(len(batch)))
+		return
+	}
+
+	s.options.Metrics.IncCounterBy(sentMetric, int64(len(batch)))
+}
+
+func (s *Async) run() {
+	defer close(s.done)
+
+	ticker := time.NewTicker(s.options.FlushInterval)
+	defer ticker.Stop()
+
+	batch := make([]*Event, 0, s.options.BatchSize)
+	flush := func() {
+		s.write(batch)
+		batch = make([]*Event, 0, s.options.BatchSize)
+	}
+
+	for {
+		select {
+		case e := <-s.events:
+			batch = append(batch, e)
+			if len(batch) >= s.options.BatchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		case <-s.quit:
+			for {
+				select {
+				case e := <-s.events:
+					batch = append(batch, e)
+					if len(batch) >= s.options.BatchSize {
+						flush()
+					}
+				default:
+					flush()
+					return
+				}
+			}
+		}
+	}
+}
+
+// Close writes the buffered events, and closes the underlying writer.
+// The events sent after Close are dropped.
+func (s *Async) Close() {
+	s.once.Do(func() {
+		close(s.quit)
+		<-s.done
+		if err := s.writer.Close(); err != nil {
+			log.Errorf("Failed to close event writer: %v", err)
+		}
+	})
+}
@@ -0,0 +1,165 @@
+package eventsink
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/zalando/skipper/metrics/metricstest"
+)
+
+type testWriter struct {
+	mu      sync.Mutex
+	batches [][]*Event
+	block   chan struct{}
+	fail    bool
+	closed  bool
+}
+
+func (w *testWriter) Write(events []*Event) error {
+	if w.block != nil {
+		<-w.block
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.fail {
+		return errors.New("test error")
+	}
+
+	w.batches = append(w.batches, events)
+	return nil
+}
+
+func (w *testWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.closed = true
+	return nil
+}
+
+func (w *testWriter) count() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	var n int
+	for _, b := range w.batches {
+		n += len(b)
+	}
+
+	return n
+}
+
+func TestAsyncBatches(t *testing.T) {
+	w := &testWriter{}
+	m := &metricstest.MockMetrics{}
+	s := NewAsync(w, Options{BatchSize: 3, FlushInterval: time.Hour, Metrics: m})
+
+	for i := 0; i < 7; i++ {
+		s.Send(&Event{Type: AccessEvent})
+	}
+
+	s.Close()
+
+	if !w.closed {
+		t.Error("writer not closed")
+	}
+
+	if len(w.batches) != 3 || len(w.batches[0]) != 3 || len(w.batches[2]) != 1 {
+		t.Errorf("unexpected batches: %v", w.batches)
+	}
+
+	m.WithCounters(func(c map[string]int64) {
+		if c[sentMetric] != 7 {
+			t.Errorf("unexpected sent count: %d", c[sentMetric])
+		}
+	})
+}
+
+func TestAsyncFlushInterval(t *testing.T) {
+	w := &testWriter{}
+	s := NewAsync(w, Options{BatchSize: 100, FlushInterval: 10 * time.Millisecond})
+	defer s.Close()
+
+	s.Send(&Event{Type: AuditEvent})
+	deadline := time.Now().Add(time.Second)
+	for w.count() != 1 {
+		if time.Now().After(deadline) {
+			t.Fatal("event not flushed")
+		}
+
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestAsyncDropsWhenFull(t *testing.T) {
+	w := &testWriter{block: make(chan struct{})}
+	m := &metricstest.MockMetrics{}
+	s := NewAsync(w, Options{BufferSize: 2, BatchSize: 1, FlushInterval: time.Hour, Metrics: m})
+
+	// the first event is taken by the blocked writer, the next two fill the buffer
+	s.Send(&Event{})
+	deadline := time.Now().Add(time.Second)
+	for len(s.events) != 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("event not taken by the writer")
+		}
+
+		time.Sleep(time.Millisecond)
+	}
+
+	for i := 0; i < 4; i++ {
+		s.Send(&Event{})
+	}
+
+	close(w.block)
+	s.Close()
+
+	if w.count() != 3 {
+		t.Errorf("unexpected number of written events: %d", w.count())
+	}
+
+	m.WithCounters(func(c map[string]int64) {
+		if c[droppedMetric] != 2 {
+			t.Errorf("unexpected dropped count: %d", c[droppedMetric])
+		}
+	})
+}
+
+func TestAsyncBlockTimeout(t *testing.T) {
+	w := &testWriter{block: make(chan struct{})}
+	s := NewAsync(w, Options{BufferSize: 1, BatchSize: 1, FlushInterval: time.Hour, BlockTimeout: time.Second})
+
+	s.Send(&Event{})
+	s.Send(&Event{})
+
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		close(w.block)
+	}()
+
+	// waits for the writer instead of dropping the event
+	s.Send(&Event{})
+	s.Close()
+
+	if w.count() != 3 {
+		t.Errorf("unexpected number of written events: %d", w.count())
+	}
+}
+
+func TestAsyncCountsFailures(t *testing.T) {
+	w := &testWriter{fail: true}
+	m := &metricstest.MockMetrics{}
+	s := NewAsync(w, Options{Metrics: m})
+
+	s.Send(&Event{})
+	s.Send(&Event{})
+	s.Close()
+	s.Send(&Event{})
+
+	m.WithCounters(func(c map[string]int64) {
+		if c[failedMetric] != 2 || c[droppedMetric] != 1 {
+			t.Errorf("unexpected counters: %v", c)
+		}
+	})
+}
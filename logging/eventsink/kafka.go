@@ -0,0 +1,559 @@
+package eventsink
+
+import (
+	"bufio"
+	"crypto/tls"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Kafka protocol API keys and versions used by the writer. The
+// Produce v3 request is the oldest one supported by the current
+// brokers, and the first one using the v2 record batch format. With
+// the SaslHandshake v1 and SaslAuthenticate v0 requests, the writer
+// requires Kafka 1.0 or newer.
+const (
+	apiKeyProduce       int16 = 0
+	apiKeyMetadata      int16 = 3
+	apiVersionProduce   int16 = 3
+	apiVersionMetadata  int16 = 4
+	recordBatchMagic    int8  = 2
+	defaultKafkaTimeout       = 10 * time.Second
+	defaultKafkaClient        = "skipper"
+	errorCodeNone       int16 = 0
+)
+
+var crc32c = crc32.MakeTable(crc32.Castagnoli)
+
+// KafkaOptions for the Kafka event writer.
+type KafkaOptions struct {
+
+	// Brokers are the addresses of the bootstrap brokers, in
+	// host:port format.
+	Brokers []string
+
+	// Topic where the events are produced. The topic needs to
+	// exist.
+	Topic string
+
+	// ClientID is sent to the brokers. Defaults to "skipper".
+	ClientID string
+
+	// RequiredAcks sets how many replicas need to acknowledge a
+	// batch, with the same semantics as the acks setting of the
+	// Kafka producers: 0, 1 or -1 for all the in-sync replicas.
+	// Defaults to 1, when not set, the leader acknowledges the
+	// batches.
+	RequiredAcks *int16
+
+	// Timeout for connecting to the brokers and for the requests.
+	// Defaults to 10s.
+	Timeout time.Duration
+
+	// TLS, when set, the connections to the brokers use TLS with
+	// this configuration. When the ServerName is not set, the host
+	// of the broker address is verified.
+	TLS *tls.Config
+
+	// SASL, when set, the connections to the brokers are
+	// authenticated with these credentials.
+	SASL *KafkaSASL
+}
+
+type kafkaPartition struct {
+	id     int32
+	leader string
+}
+
+// KafkaWriter writes the events as JSON encoded records to a Kafka topic.
+// The batches are distributed between the partitions of the topic in
+// round-robin order. The partition leaders are discovered from the
+// bootstrap brokers, and they are rediscovered after a failed write.
+type KafkaWriter struct {
+	options    KafkaOptions
+	acks       int16
+	mu         sync.Mutex
+	conns      map[string]*kafkaConn
+	partitions []kafkaPartition
+	next       int
+	correlID   int32
+}
+
+type kafkaConn struct {
+	conn net.Conn
+	r    *bufio.Reader
+}
+
+// NewKafkaWriter creates a Kafka event writer. The brokers are
+// contacted only when the first batch of events is written.
+func NewKafkaWriter(o KafkaOptions) (*KafkaWriter, error) {
+	if len(o.Brokers) == 0 {
+		return nil, errors.New("kafka event writer: missing brokers")
+	}
+
+	if o.Topic == "" {
+		return nil, errors.New("kafka event writer: missing topic")
+	}
+
+	if o.ClientID == "" {
+		o.ClientID = defaultKafkaClient
+	}
+
+	if o.Timeout <= 0 {
+		o.Timeout = defaultKafkaTimeout
+	}
+
+	acks := int16(1)
+	if o.RequiredAcks != nil {
+		acks = *o.RequiredAcks
+	}
+
+	if acks < -1 || acks > 1 {
+		return nil, fmt.Errorf("kafka event writer: invalid required acks: %d", acks)
+	}
+
+	if o.SASL != nil {
+		if err := o.SASL.validate(); err != nil {
+			return nil, fmt.Errorf("kafka event writer: %w", err)
+		}
+	}
+
+	return &KafkaWriter{
+		options: o,
+		acks:    acks,
+		conns:   make(map[string]*kafkaConn),
+	}, nil
+}
+
+// encoding helpers for the Kafka protocol primitives
+
+type kafkaEncoder struct {
+	b []byte
+}
+
+func (e *kafkaEncoder) int8(v int8)   { e.b = append(e.b, byte(v)) }
+func (e *kafkaEncoder) int16(v int16) { e.b = binary.BigEndian.AppendUint16(e.b, uint16(v)) }
+func (e *kafkaEncoder) int32(v int32) { e.b = binary.BigEndian.AppendUint32(e.b, uint32(v)) }
+func (e *kafkaEncoder) int64(v int64) { e.b = binary.BigEndian.AppendUint64(e.b, uint64(v)) }
+func (e *kafkaEncoder) varint(v int64) {
+	e.b = binary.AppendVarint(e.b, v)
+}
+
+func (e *kafkaEncoder) string(s string) {
+	e.int16(int16(len(s)))
+	e.b = append(e.b, s...)
+}
+
+func (e *kafkaEncoder) nullString() { e.int16(-1) }
+
+func (e *kafkaEncoder) bytes(b []byte) {
+	e.int32(int32(len(b)))
+	e.b = append(e.b, b...)
+}
+
+type kafkaDecoder struct {
+	b   []byte
+	err error
+}
+
+func (d *kafkaDecoder) take(n int) []byte {
+	if d.err != nil {
+		return nil
+	}
+
+	if n < 0 || len(d.b) < n {
+		d.err = io.ErrUnexpectedEOF
+		return nil
+	}
+
+	b := d.b[:n]
+	d.b = d.b[n:]
+	return b
+}
+
+func (d *kafkaDecoder) int8() int8 {
+	if b := d.take(1); b != nil {
+		return int8(b[0])
+	}
+
+	return 0
+}
+
+func (d *kafkaDecoder) int16() int16 {
+	if b := d.take(2); b != nil {
+		return int16(binary.BigEndian.Uint16(b))
+	}
+
+	return 0
+}
+
+func (d *kafkaDecoder) int32() int32 {
+	if b := d.take(4); b != nil {
+		return int32(binary.BigEndian.Uint32(b))
+	}
+
+	return 0
+}
+
+func (d *kafkaDecoder) int64() int64 {
+	if b := d.take(8); b != nil {
+		return int64(binary.BigEndian.Uint64(b))
+	}
+
+	return 0
+}
+
+func (d *kafkaDecoder) string() string {
+	n := d.int16()
+	if n < 0 {
+		return ""
+	}
+
+	return string(d.take(int(n)))
+}
+
+func (d *kafkaDecoder) int32s() {
+	n := d.int32()
+	for i := int32(0); i < n && d.err == nil; i++ {
+		d.int32()
+	}
+}
+
+// encodes the events in the v2 record batch format
+func encodeRecordBatch(events []*Event) ([]byte, error) {
+	now := time.Now()
+	first := now.UnixMilli()
+	max := first
+
+	var records kafkaEncoder
+	for i, e := range events {
+		value, err := json.Marshal(e)
+		if err != nil {
+			return nil, err
+		}
+
+		ts := now.UnixMilli()
+		if !e.Time.IsZero() {
+			ts = e.Time.UnixMilli()
+		}
+
+		if i == 0 {
+			first, max = ts, ts
+		} else if ts > max {
+			max = ts
+		}
+
+		var r kafkaEncoder
+		r.int8(0) // attributes
+		r.varint(ts - first)
+		r.varint(int64(i)) // offset delta
+		r.varint(-1)       // null key
+		r.varint(int64(len(value)))
+		r.b = append(r.b, value...)
+		r.varint(0) // no headers
+
+		records.varint(int64(len(r.b)))
+		records.b = append(records.b, r.b...)
+	}
+
+	// the part covered by the checksum
+	var c kafkaEncoder
+	c.int16(0) // attributes: no compression, create time
+	c.int32(int32(len(events) - 1))
+	c.int64(first)
+	c.int64(max)
+	c.int64(-1) // producer id
+	c.int16(-1) // producer epoch
+	c.int32(-1) // base sequence
+	c.int32(int32(len(events)))
+	c.b = append(c.b, records.b...)
+
+	var b kafkaEncoder
+	b.int64(0) // base offset
+	b.int32(int32(4 + 1 + 4 + len(c.b)))
+	b.int32(-1) // partition leader epoch
+	b.int8(recordBatchMagic)
+	b.b = binary.BigEndian.AppendUint32(b.b, crc32.Checksum(c.b, crc32c))
+	b.b = append(b.b, c.b...)
+	return b.b, nil
+}
+
+func (w *KafkaWriter) connection(addr string) (*kafkaConn, error) {
+	if c, ok := w.conns[addr]; ok {
+		return c, nil
+	}
+
+	conn, err := net.DialTimeout("tcp", addr, w.options.Timeout)
+	if err != nil {
+		return nil, err
+	}
+
+	if w.options.TLS != nil {
+		conn, err = w.tlsHandshake(conn, addr)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	c := &kafkaConn{conn: conn, r: bufio.NewReader(conn)}
+	if w.options.SASL != nil {
+		if err := w.authenticate(c); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+
+	w.conns[addr] = c
+	return c, nil
+}
+
+func (w *KafkaWriter) tlsHandshake(conn net.Conn, addr string) (net.Conn, error) {
+	config := w.options.TLS
+	if config.ServerName == "" {
+		host, _, err := net.SplitHostPort(addr)
+		if err != nil {
+			conn.Close()
+			return nil, err
+		}
+
+		config = config.Clone()
+		config.ServerName = host
+	}
+
+	tc := tls.Client(conn, config)
+	tc.SetDeadline(time.Now().Add(w.options.Timeout))
+	if err := tc.Handshake(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	return tc, nil
+}
+
+func (w *KafkaWriter) closeConnection(addr string) {
+	if c, ok := w.conns[addr]; ok {
+		c.conn.Close()
+		delete(w.conns, addr)
+	}
+}
+
+// sends a request, and when expectResponse is set, returns the body of
+// the response
+func (w *KafkaWriter) roundTrip(addr string, apiKey, apiVersion int16, body []byte, expectResponse bool) ([]byte, error) {
+	c, err := w.connection(addr)
+	if err != nil {
+		return nil, err
+	}
+
+	rsp, err := w.send(c, apiKey, apiVersion, body, expectResponse)
+	if err != nil {
+		w.closeConnection(addr)
+	}
+
+	return rsp, err
+}
+
+func (w *KafkaWriter) send(c *kafkaConn, apiKey, apiVersion int16, body []byte, expectResponse bool) ([]byte, error) {
+	w.correlID++
+	correlID := w.correlID
+
+	var h kafkaEncoder
+	h.int32(0) // size placeholder
+	h.int16(apiKey)
+	h.int16(apiVersion)
+	h.int32(correlID)
+	h.string(w.options.ClientID)
+	h.b = append(h.b, body...)
+	binary.BigEndian.PutUint32(h.b, uint32(len(h.b)-4))
+
+	c.conn.SetDeadline(time.Now().Add(w.options.Timeout))
+	if _, err := c.conn.Write(h.b); err != nil {
+		return nil, err
+	}
+
+	if !expectResponse {
+		return nil, nil
+	}
+
+	var size [4]byte
+	if _, err := io.ReadFull(c.r, size[:]); err != nil {
+		return nil, err
+	}
+
+	rsp := make([]byte, binary.BigEndian.Uint32(size[:]))
+	if _, err := io.ReadFull(c.r, rsp); err != nil {
+		return nil, err
+	}
+
+	d := &kafkaDecoder{b: rsp}
+	if id := d.int32(); d.err != nil || id != correlID {
+		return nil, fmt.Errorf("kafka: unexpected correlation id: %d, expected: %d", id, correlID)
+	}
+
+	return d.b, nil
+}
+
+func (w *KafkaWriter) fetchMetadata() error {
+	var req kafkaEncoder
+	req.int32(1)
+	req.string(w.options.Topic)
+	req.int8(0) // don't create the topic
+
+	var lastErr error
+	for _, broker := range w.options.Brokers {
+		rsp, err := w.roundTrip(broker, apiKeyMetadata, apiVersionMetadata, req.b, true)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+
+		partitions, err := w.decodeMetadata(rsp)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+
+		w.partitions = partitions
+		return nil
+	}
+
+	return fmt.Errorf("kafka: failed to fetch metadata: %w", lastErr)
+}
+
+func (w *KafkaWriter) decodeMetadata(rsp []byte) ([]kafkaPartition, error) {
+	d := &kafkaDecoder{b: rsp}
+	d.int32() // throttle time
+
+	brokers := make(map[int32]string)
+	for i, n := int32(0), d.int32(); i < n && d.err == nil; i++ {
+		id := d.int32()
+		host := d.string()
+		port := d.int32()
+		d.string() // rack
+		brokers[id] = net.JoinHostPort(host, strconv.Itoa(int(port)))
+	}
+
+	d.string() // cluster id
+	d.int32()  // controller id
+
+	var partitions []kafkaPartition
+	for i, n := int32(0), d.int32(); i < n && d.err == nil; i++ {
+		topicErr := d.int16()
+		name := d.string()
+		d.int8() // internal
+		for j, m := int32(0), d.int32(); j < m && d.err == nil; j++ {
+			partitionErr := d.int16()
+			id := d.int32()
+			leader := d.int32()
+			d.int32s() // replicas
+			d.int32s() // in-sync replicas
+			if name != w.options.Topic || topicErr != errorCodeNone || partitionErr != errorCodeNone {
+				continue
+			}
+
+			if addr, ok := brokers[leader]; ok {
+				partitions = append(partitions, kafkaPartition{id: id, leader: addr})
+			}
+		}
+
+		if name == w.options.Topic && topicErr != errorCodeNone {
+			return nil, fmt.Errorf("kafka: topic %s not available, error code: %d", name, topicErr)
+		}
+	}
+
+	if d.err != nil {
+		return nil, d.err
+	}
+
+	if len(partitions) == 0 {
+		return nil, fmt.Errorf("kafka: no available partitions for topic %s", w.options.Topic)
+	}
+
+	return partitions, nil
+}
+
+func (w *KafkaWriter) produce(p kafkaPartition, batch []byte) error {
+	var req kafkaEncoder
+	req.nullString() // transactional id
+	req.int16(w.acks)
+	req.int32(int32(w.options.Timeout / time.Millisecond))
+	req.int32(1)
+	req.string(w.options.Topic)
+	req.int32(1)
+	req.int32(p.id)
+	req.bytes(batch)
+
+	rsp, err := w.roundTrip(p.leader, apiKeyProduce, apiVersionProduce, req.b, w.acks != 0)
+	if err != nil || w.acks == 0 {
+		return err
+	}
+
+	d := &kafkaDecoder{b: rsp}
+	for i, n := int32(0), d.int32(); i < n && d.err == nil; i++ {
+		d.string()
+		for j, m := int32(0), d.int32(); j < m && d.err == nil; j++ {
+			d.int32() // partition
+			code := d.int16()
+			d.int64() // base offset
+			d.int64() // log append time
+			if d.err == nil && code != errorCodeNone {
+				return fmt.Errorf("kafka: failed to produce to partition %d, error code: %d", p.id, code)
+			}
+		}
+	}
+
+	return d.err
+}
+
+// Write produces the events to one of the partitions of the topic.
+func (w *KafkaWriter) Write(events []*Event) error {
+	if len(events) == 0 {
+		return nil
+	}
+
+	batch, err := encodeRecordBatch(events)
+	if err != nil {
+		return err
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if len(w.partitions) == 0 {
+		if err := w.fetchMetadata(); err != nil {
+			return err
+		}
+	}
+
+	p := w.partitions[w.next%len(w.partitions)]
+	w.next++
+
+	if err := w.produce(p, batch); err != nil {
+		// the leaders may have changed, rediscover them for the
+		// next batch
+		w.partitions = nil
+		return err
+	}
+
+	return nil
+}
+
+// Close closes the connections to the brokers.
+func (w *KafkaWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	for addr := range w.conns {
+		w.closeConnection(addr)
+	}
+
+	return nil
+}
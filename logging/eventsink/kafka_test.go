@@ -0,0 +1,563 @@
+package eventsink
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"hash"
+	"hash/crc32"
+	"io"
+	"math/big"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/pbkdf2"
+)
+
+// testBroker implements the metadata and the produce requests of a
+// single Kafka broker leading all the partitions of a topic. When the
+// SASL credentials are set, it requires the clients to authenticate.
+type testBroker struct {
+	t          *testing.T
+	listener   net.Listener
+	topic      string
+	partitions int32
+	errorCode  int16
+	sasl       *KafkaSASL
+
+	mu       sync.Mutex
+	produced map[int32][]*Event
+}
+
+func newTestBroker(t *testing.T, topic string, partitions int32) *testBroker {
+	return newSecureTestBroker(t, topic, partitions, nil, nil)
+}
+
+func newSecureTestBroker(t *testing.T, topic string, partitions int32, tlsConfig *tls.Config, sasl *KafkaSASL) *testBroker {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if tlsConfig != nil {
+		l = tls.NewListener(l, tlsConfig)
+	}
+
+	b := &testBroker{
+		t:          t,
+		listener:   l,
+		topic:      topic,
+		partitions: partitions,
+		sasl:       sasl,
+		produced:   make(map[int32][]*Event),
+	}
+
+	go b.serve()
+	return b
+}
+
+func (b *testBroker) addr() string { return b.listener.Addr().String() }
+func (b *testBroker) close()       { b.listener.Close() }
+
+func (b *testBroker) serve() {
+	for {
+		conn, err := b.listener.Accept()
+		if err != nil {
+			return
+		}
+
+		go b.handle(conn)
+	}
+}
+
+func (b *testBroker) handle(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	authenticated := b.sasl == nil
+	session := &testSASLServer{credentials: b.sasl}
+	for {
+		var size [4]byte
+		if _, err := io.ReadFull(r, size[:]); err != nil {
+			return
+		}
+
+		req := make([]byte, binary.BigEndian.Uint32(size[:]))
+		if _, err := io.ReadFull(r, req); err != nil {
+			return
+		}
+
+		d := &kafkaDecoder{b: req}
+		apiKey := d.int16()
+		apiVersion := d.int16()
+		correlID := d.int32()
+		d.string() // client id
+
+		var rsp kafkaEncoder
+		rsp.int32(0)
+		rsp.int32(correlID)
+		switch {
+		case b.sasl != nil && apiKey == apiKeySaslHandshake && apiVersion == apiVersionSaslHandshake:
+			if d.string() != b.sasl.Mechanism {
+				rsp.int16(33) // unsupported mechanism
+			} else {
+				rsp.int16(0)
+			}
+
+			rsp.int32(1)
+			rsp.string(b.sasl.Mechanism)
+		case b.sasl != nil && apiKey == apiKeySaslAuthenticate && apiVersion == apiVersionSaslAuthenticate:
+			challenge, done, err := session.next(d.take(int(d.int32())))
+			if err != nil {
+				rsp.int16(58) // authentication failed
+				rsp.string(err.Error())
+				rsp.bytes(nil)
+			} else {
+				rsp.int16(0)
+				rsp.nullString()
+				rsp.bytes(challenge)
+				authenticated = done
+			}
+		case !authenticated:
+			b.t.Errorf("unauthenticated request: %d", apiKey)
+			return
+		case apiKey == apiKeyMetadata && apiVersion == apiVersionMetadata:
+			b.metadata(&rsp)
+		case apiKey == apiKeyProduce && apiVersion == apiVersionProduce:
+			b.produce(d, &rsp)
+		default:
+			b.t.Errorf("unexpected request: %d, version: %d", apiKey, apiVersion)
+			return
+		}
+
+		binary.BigEndian.PutUint32(rsp.b, uint32(len(rsp.b)-4))
+		if _, err := conn.Write(rsp.b); err != nil {
+			return
+		}
+	}
+}
+
+func (b *testBroker) metadata(rsp *kafkaEncoder) {
+	host, port, _ := net.SplitHostPort(b.addr())
+	p, _ := strconv.Atoi(port)
+
+	rsp.int32(0) // throttle time
+	rsp.int32(1)
+	rsp.int32(42)
+	rsp.string(host)
+	rsp.int32(int32(p))
+	rsp.nullString()
+	rsp.nullString() // cluster id
+	rsp.int32(42)
+	rsp.int32(1)
+	rsp.int16(0)
+	rsp.string(b.topic)
+	rsp.int8(0)
+	rsp.int32(b.partitions)
+	for i := int32(0); i < b.partitions; i++ {
+		rsp.int16(0)
+		rsp.int32(i)
+		rsp.int32(42)
+		rsp.int32(1)
+		rsp.int32(42)
+		rsp.int32(1)
+		rsp.int32(42)
+	}
+}
+
+func (b *testBroker) produce(d *kafkaDecoder, rsp *kafkaEncoder) {
+	d.string() // transactional id
+	d.int16()  // acks
+	d.int32()  // timeout
+	d.int32()  // topics, always 1
+	topic := d.string()
+	d.int32() // partitions, always 1
+	partition := d.int32()
+	batch := d.take(int(d.int32()))
+	if d.err != nil {
+		b.t.Errorf("failed to decode produce request: %v", d.err)
+		return
+	}
+
+	if topic != b.topic {
+		b.t.Errorf("unexpected topic: %s", topic)
+	}
+
+	events := b.decodeBatch(batch)
+	b.mu.Lock()
+	b.produced[partition] = append(b.produced[partition], events...)
+	b.mu.Unlock()
+
+	rsp.int32(1)
+	rsp.string(topic)
+	rsp.int32(1)
+	rsp.int32(partition)
+	rsp.int16(b.errorCode)
+	rsp.int64(0)
+	rsp.int64(-1)
+	rsp.int32(0) // throttle time
+}
+
+func (b *testBroker) decodeBatch(batch []byte) []*Event {
+	d := &kafkaDecoder{b: batch}
+	d.int64() // base offset
+	if l := d.int32(); int(l) != len(d.b) {
+		b.t.Errorf("invalid batch length: %d, expected: %d", l, len(d.b))
+	}
+
+	d.int32() // leader epoch
+	if magic := d.int8(); magic != recordBatchMagic {
+		b.t.Errorf("invalid magic byte: %d", magic)
+	}
+
+	crc := uint32(d.int32())
+	if crc != crc32.Checksum(d.b, crc32c) {
+		b.t.Error("invalid checksum")
+	}
+
+	d.int16() // attributes
+	d.int32() // last offset delta
+	d.int64() // first timestamp
+	d.int64() // max timestamp
+	d.int64() // producer id
+	d.int16() // producer epoch
+	d.int32() // base sequence
+	n := d.int32()
+
+	var events []*Event
+	for i := int32(0); i < n; i++ {
+		l, k := binary.Varint(d.b)
+		d.take(k)
+		r := d.take(int(l))
+		r = r[1:] // attributes
+		for j := 0; j < 3; j++ {
+			// timestamp delta, offset delta, key length
+			_, k := binary.Varint(r)
+			r = r[k:]
+		}
+
+		vl, k := binary.Varint(r)
+		r = r[k:]
+
+		var e Event
+		if err := json.Unmarshal(r[:vl], &e); err != nil {
+			b.t.Errorf("failed to decode event: %v", err)
+		}
+
+		events = append(events, &e)
+	}
+
+	if d.err != nil {
+		b.t.Errorf("failed to decode batch: %v", d.err)
+	}
+
+	return events
+}
+
+// testSASLServer implements the server side of the PLAIN and the SCRAM
+// mechanisms for the test broker
+type testSASLServer struct {
+	credentials     *KafkaSASL
+	clientFirstBare string
+	serverFirst     string
+}
+
+func (s *testSASLServer) next(msg []byte) ([]byte, bool, error) {
+	if s.credentials.Mechanism == SASLPlain {
+		if string(msg) != "\x00"+s.credentials.Username+"\x00"+s.credentials.Password {
+			return nil, false, errors.New("invalid credentials")
+		}
+
+		return nil, true, nil
+	}
+
+	h := sha256.New
+	if s.credentials.Mechanism == SASLScramSHA512 {
+		h = sha512.New
+	}
+
+	mac := func(key []byte, message string) []byte {
+		m := hmac.New(h, key)
+		m.Write([]byte(message))
+		return m.Sum(nil)
+	}
+
+	if s.serverFirst == "" {
+		s.clientFirstBare = strings.TrimPrefix(string(msg), "n,,")
+		attrs := scramAttributes(s.clientFirstBare)
+		if attrs['n'] != s.credentials.Username {
+			return nil, false, errors.New("unknown user")
+		}
+
+		s.serverFirst = "r=" + attrs['r'] + "server-nonce,s=" + base64.StdEncoding.EncodeToString([]byte("salt")) + ",i=4096"
+		return []byte(s.serverFirst), false, nil
+	}
+
+	withoutProof, proof, _ := strings.Cut(string(msg), ",p=")
+	clientProof, _ := base64.StdEncoding.DecodeString(proof)
+	salted := pbkdf2.Key([]byte(s.credentials.Password), []byte("salt"), 4096, h().Size(), h)
+	authMessage := s.clientFirstBare + "," + s.serverFirst + "," + withoutProof
+	storedKey := hashOf(h, mac(salted, "Client Key"))
+	clientSignature := mac(storedKey, authMessage)
+	if len(clientProof) != len(clientSignature) {
+		return nil, false, errors.New("invalid proof")
+	}
+
+	for i := range clientProof {
+		clientProof[i] ^= clientSignature[i]
+	}
+
+	if !bytes.Equal(hashOf(h, clientProof), storedKey) {
+		return nil, false, errors.New("invalid credentials")
+	}
+
+	return []byte("v=" + base64.StdEncoding.EncodeToString(mac(mac(salted, "Server Key"), authMessage))), true, nil
+}
+
+func hashOf(h func() hash.Hash, b []byte) []byte {
+	hh := h()
+	hh.Write(b)
+	return hh.Sum(nil)
+}
+
+// generates a self-signed certificate for 127.0.0.1, and returns the
+// server and the client configuration
+func testTLSConfig(t *testing.T) (*tls.Config, *tls.Config) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "kafka"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pool := x509.NewCertPool()
+	pool.AddCert(cert)
+	return &tls.Config{Certificates: []tls.Certificate{{Certificate: [][]byte{der}, PrivateKey: key}}},
+		&tls.Config{RootCAs: pool}
+}
+
+func TestKafkaWriter(t *testing.T) {
+	b := newTestBroker(t, "events", 2)
+	defer b.close()
+
+	w, err := NewKafkaWriter(KafkaOptions{Brokers: []string{b.addr()}, Topic: "events", Timeout: time.Second})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer w.Close()
+
+	now := time.Now()
+	for i := 0; i < 3; i++ {
+		if err := w.Write([]*Event{
+			{Type: AccessEvent, Time: now, Data: map[string]interface{}{"status": 200}},
+			{Type: AuditEvent, Time: now.Add(time.Millisecond)},
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if len(b.produced[0]) != 4 || len(b.produced[1]) != 2 {
+		t.Fatalf("unexpected distribution between the partitions: %d, %d", len(b.produced[0]), len(b.produced[1]))
+	}
+
+	e := b.produced[1][0]
+	if e.Type != AccessEvent || e.Data["status"] != float64(200) || e.Time.UnixMilli() != now.UnixMilli() {
+		t.Errorf("unexpected event: %+v", e)
+	}
+
+	if b.produced[1][1].Type != AuditEvent {
+		t.Errorf("unexpected event type: %s", b.produced[1][1].Type)
+	}
+}
+
+func TestKafkaWriterProduceError(t *testing.T) {
+	b := newTestBroker(t, "events", 1)
+	defer b.close()
+	b.errorCode = 6 // not leader for partition
+
+	w, err := NewKafkaWriter(KafkaOptions{Brokers: []string{b.addr()}, Topic: "events", Timeout: time.Second})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer w.Close()
+	if err := w.Write([]*Event{{Type: AccessEvent}}); err == nil {
+		t.Error("failed to fail")
+	}
+
+	if w.partitions != nil {
+		t.Error("failed to reset the partition leaders")
+	}
+}
+
+func TestKafkaWriterUnavailable(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	addr := l.Addr().String()
+	l.Close()
+
+	w, err := NewKafkaWriter(KafkaOptions{Brokers: []string{addr}, Topic: "events", Timeout: time.Second})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := w.Write([]*Event{{Type: AccessEvent}}); err == nil {
+		t.Error("failed to fail")
+	}
+}
+
+func TestKafkaWriterOptions(t *testing.T) {
+	invalidAcks := int16(2)
+	for _, o := range []KafkaOptions{
+		{Topic: "events"},
+		{Brokers: []string{"localhost:9092"}},
+		{Brokers: []string{"localhost:9092"}, Topic: "events", RequiredAcks: &invalidAcks},
+		{Brokers: []string{"localhost:9092"}, Topic: "events", SASL: &KafkaSASL{Mechanism: "GSSAPI", Username: "skipper"}},
+		{Brokers: []string{"localhost:9092"}, Topic: "events", SASL: &KafkaSASL{Mechanism: SASLPlain}},
+	} {
+		if _, err := NewKafkaWriter(o); err == nil {
+			t.Errorf("failed to fail for: %+v", o)
+		}
+	}
+}
+
+func TestKafkaWriterTLSAndSASL(t *testing.T) {
+	serverTLS, clientTLS := testTLSConfig(t)
+	for _, mechanism := range []string{SASLPlain, SASLScramSHA256, SASLScramSHA512} {
+		t.Run(mechanism, func(t *testing.T) {
+			credentials := &KafkaSASL{Mechanism: mechanism, Username: "skipper", Password: "secret,=password"}
+			b := newSecureTestBroker(t, "events", 1, serverTLS, credentials)
+			defer b.close()
+
+			w, err := NewKafkaWriter(KafkaOptions{
+				Brokers: []string{b.addr()},
+				Topic:   "events",
+				Timeout: time.Second,
+				TLS:     clientTLS,
+				SASL:    credentials,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			defer w.Close()
+			for i := 0; i < 2; i++ {
+				if err := w.Write([]*Event{{Type: AccessEvent}}); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			b.mu.Lock()
+			defer b.mu.Unlock()
+			if len(b.produced[0]) != 2 {
+				t.Errorf("unexpected number of events: %d", len(b.produced[0]))
+			}
+
+			invalid := *credentials
+			invalid.Password = "invalid"
+			iw, err := NewKafkaWriter(KafkaOptions{
+				Brokers: []string{b.addr()},
+				Topic:   "events",
+				Timeout: time.Second,
+				TLS:     clientTLS,
+				SASL:    &invalid,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			defer iw.Close()
+			if err := iw.Write([]*Event{{Type: AccessEvent}}); err == nil {
+				t.Error("failed to fail with invalid credentials")
+			}
+		})
+	}
+}
+
+func TestKafkaWriterUntrustedBroker(t *testing.T) {
+	serverTLS, _ := testTLSConfig(t)
+	b := newSecureTestBroker(t, "events", 1, serverTLS, nil)
+	defer b.close()
+
+	_, clientTLS := testTLSConfig(t)
+	w, err := NewKafkaWriter(KafkaOptions{Brokers: []string{b.addr()}, Topic: "events", Timeout: time.Second, TLS: clientTLS})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer w.Close()
+	if err := w.Write([]*Event{{Type: AccessEvent}}); err == nil {
+		t.Error("failed to fail with an untrusted certificate")
+	}
+}
+
+// RFC 7677 3
+func TestScramSHA256(t *testing.T) {
+	s := &scramSession{
+		hash:            sha256.New,
+		username:        "user",
+		password:        "pencil",
+		step:            1,
+		nonce:           "rOprNGfwEbeRWgbNEkqO",
+		clientFirstBare: "n=user,r=rOprNGfwEbeRWgbNEkqO",
+	}
+
+	clientFinal, err := s.next([]byte("r=rOprNGfwEbeRWgbNEkqO%hvYDpWUa2RaTCAfuxFIlj)hNlF$k0,s=W22ZaJ0SNY7soEsUEjb6gQ==,i=4096"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if expected := "c=biws,r=rOprNGfwEbeRWgbNEkqO%hvYDpWUa2RaTCAfuxFIlj)hNlF$k0,p=dHzbZapWIk4jUhN+Ute9ytag9zjfMHgsqmmiz7AndVQ="; string(clientFinal) != expected {
+		t.Errorf("unexpected client final message: %s", clientFinal)
+	}
+
+	if _, err := s.next([]byte("v=invalid")); err == nil {
+		t.Error("failed to fail on an invalid server signature")
+	}
+
+	s.step = 2
+	if msg, err := s.next([]byte("v=6rriTRBi23WpRR/wtup+mMhUZUn/dB5nLTJRsjl95G4=")); err != nil || msg != nil {
+		t.Errorf("failed to verify the server signature: %v", err)
+	}
+}
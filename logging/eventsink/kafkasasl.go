@@ -0,0 +1,246 @@
+package eventsink
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"hash"
+	"strconv"
+	"strings"
+
+	"golang.org/x/crypto/pbkdf2"
+)
+
+// Supported SASL mechanisms.
+const (
+	SASLPlain       = "PLAIN"
+	SASLScramSHA256 = "SCRAM-SHA-256"
+	SASLScramSHA512 = "SCRAM-SHA-512"
+)
+
+const (
+	apiKeySaslHandshake        int16 = 17
+	apiKeySaslAuthenticate     int16 = 36
+	apiVersionSaslHandshake    int16 = 1
+	apiVersionSaslAuthenticate int16 = 0
+	scramNonceSize                   = 24
+)
+
+// KafkaSASL contains the SASL credentials used to authenticate the
+// connections to the brokers.
+type KafkaSASL struct {
+
+	// Mechanism is one of PLAIN, SCRAM-SHA-256 or SCRAM-SHA-512.
+	Mechanism string
+
+	Username string
+	Password string
+}
+
+// saslSession implements the client side of a SASL mechanism. It is
+// called first with a nil challenge, then with each response of the
+// broker, and it returns nil when the authentication is complete.
+type saslSession interface {
+	next(challenge []byte) ([]byte, error)
+}
+
+func (s *KafkaSASL) validate() error {
+	switch s.Mechanism {
+	case SASLPlain, SASLScramSHA256, SASLScramSHA512:
+	default:
+		return fmt.Errorf("unsupported SASL mechanism: %s", s.Mechanism)
+	}
+
+	if s.Username == "" {
+		return errors.New("missing SASL username")
+	}
+
+	return nil
+}
+
+func (s *KafkaSASL) session() saslSession {
+	switch s.Mechanism {
+	case SASLScramSHA256:
+		return &scramSession{hash: sha256.New, username: s.Username, password: s.Password}
+	case SASLScramSHA512:
+		return &scramSession{hash: sha512.New, username: s.Username, password: s.Password}
+	default:
+		return &plainSession{username: s.Username, password: s.Password}
+	}
+}
+
+// RFC 4616
+type plainSession struct {
+	username, password string
+	sent               bool
+}
+
+func (s *plainSession) next([]byte) ([]byte, error) {
+	if s.sent {
+		return nil, nil
+	}
+
+	s.sent = true
+	return []byte("\x00" + s.username + "\x00" + s.password), nil
+}
+
+// RFC 5802, without channel binding. The password is used as is,
+// without SASLprep normalization.
+type scramSession struct {
+	hash               func() hash.Hash
+	username, password string
+	step               int
+	nonce              string
+	clientFirstBare    string
+	serverSignature    []byte
+}
+
+func (s *scramSession) hmac(key []byte, message string) []byte {
+	h := hmac.New(s.hash, key)
+	h.Write([]byte(message))
+	return h.Sum(nil)
+}
+
+func scramName(s string) string {
+	return strings.NewReplacer("=", "=3D", ",", "=2C").Replace(s)
+}
+
+func scramAttributes(message string) map[byte]string {
+	attrs := make(map[byte]string)
+	for _, a := range strings.Split(message, ",") {
+		if len(a) >= 2 && a[1] == '=' {
+			attrs[a[0]] = a[2:]
+		}
+	}
+
+	return attrs
+}
+
+func (s *scramSession) next(challenge []byte) ([]byte, error) {
+	s.step++
+	switch s.step {
+	case 1:
+		return s.clientFirst()
+	case 2:
+		return s.clientFinal(string(challenge))
+	case 3:
+		return nil, s.verifyServer(string(challenge))
+	default:
+		return nil, nil
+	}
+}
+
+func (s *scramSession) clientFirst() ([]byte, error) {
+	nonce := make([]byte, scramNonceSize)
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	s.nonce = base64.RawStdEncoding.EncodeToString(nonce)
+	s.clientFirstBare = "n=" + scramName(s.username) + ",r=" + s.nonce
+	return []byte("n,," + s.clientFirstBare), nil
+}
+
+func (s *scramSession) clientFinal(serverFirst string) ([]byte, error) {
+	attrs := scramAttributes(serverFirst)
+	if e, ok := attrs['e']; ok {
+		return nil, fmt.Errorf("SCRAM authentication failed: %s", e)
+	}
+
+	nonce := attrs['r']
+	if !strings.HasPrefix(nonce, s.nonce) || len(nonce) == len(s.nonce) {
+		return nil, errors.New("SCRAM authentication failed: invalid server nonce")
+	}
+
+	salt, err := base64.StdEncoding.DecodeString(attrs['s'])
+	if err != nil || len(salt) == 0 {
+		return nil, errors.New("SCRAM authentication failed: invalid salt")
+	}
+
+	iterations, err := strconv.Atoi(attrs['i'])
+	if err != nil || iterations <= 0 {
+		return nil, errors.New("SCRAM authentication failed: invalid iteration count")
+	}
+
+	salted := pbkdf2.Key([]byte(s.password), salt, iterations, s.hash().Size(), s.hash)
+	clientKey := s.hmac(salted, "Client Key")
+	h := s.hash()
+	h.Write(clientKey)
+	storedKey := h.Sum(nil)
+
+	withoutProof := "c=biws,r=" + nonce
+	authMessage := s.clientFirstBare + "," + serverFirst + "," + withoutProof
+	proof := s.hmac(storedKey, authMessage)
+	for i := range proof {
+		proof[i] ^= clientKey[i]
+	}
+
+	s.serverSignature = s.hmac(s.hmac(salted, "Server Key"), authMessage)
+	return []byte(withoutProof + ",p=" + base64.StdEncoding.EncodeToString(proof)), nil
+}
+
+func (s *scramSession) verifyServer(serverFinal string) error {
+	attrs := scramAttributes(serverFinal)
+	if e, ok := attrs['e']; ok {
+		return fmt.Errorf("SCRAM authentication failed: %s", e)
+	}
+
+	signature, err := base64.StdEncoding.DecodeString(attrs['v'])
+	if err != nil || !hmac.Equal(signature, s.serverSignature) {
+		return errors.New("SCRAM authentication failed: invalid server signature")
+	}
+
+	return nil
+}
+
+// authenticates a new connection with the SaslHandshake and the
+// SaslAuthenticate requests
+func (w *KafkaWriter) authenticate(c *kafkaConn) error {
+	var req kafkaEncoder
+	req.string(w.options.SASL.Mechanism)
+	rsp, err := w.send(c, apiKeySaslHandshake, apiVersionSaslHandshake, req.b, true)
+	if err != nil {
+		return err
+	}
+
+	d := &kafkaDecoder{b: rsp}
+	if code := d.int16(); d.err != nil || code != errorCodeNone {
+		return fmt.Errorf("kafka: SASL handshake failed, error code: %d", code)
+	}
+
+	s := w.options.SASL.session()
+	var challenge []byte
+	for {
+		msg, err := s.next(challenge)
+		if err != nil || msg == nil {
+			return err
+		}
+
+		var req kafkaEncoder
+		req.bytes(msg)
+		rsp, err := w.send(c, apiKeySaslAuthenticate, apiVersionSaslAuthenticate, req.b, true)
+		if err != nil {
+			return err
+		}
+
+		d := &kafkaDecoder{b: rsp}
+		code := d.int16()
+		message := d.string()
+		challenge = nil
+		if n := d.int32(); n > 0 {
+			challenge = d.take(int(n))
+		}
+
+		if d.err != nil {
+			return d.err
+		}
+
+		if code != errorCodeNone {
+			return fmt.Errorf("kafka: SASL authentication failed, error code: %d: %s", code, message)
+		}
+	}
+}
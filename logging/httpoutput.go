@@ -0,0 +1,153 @@
+package logging
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	defaultHTTPOutputBatchSize     = 100
+	defaultHTTPOutputFlushInterval = time.Second
+	defaultHTTPOutputBufferSize    = 1024
+	httpOutputTimeout              = 10 * time.Second
+)
+
+// httpOutput buffers the log lines, and posts them in batches to an HTTP
+// endpoint in the background, separated by new lines. When the buffer is
+// full, the new lines are dropped, so that an unavailable endpoint doesn't
+// block the requests.
+type httpOutput struct {
+	url           string
+	client        *http.Client
+	batchSize     int
+	flushInterval time.Duration
+	lines         chan []byte
+	quit          chan struct{}
+	done          chan struct{}
+	once          sync.Once
+}
+
+func newHTTPOutput(url string, o OutputOptions) *httpOutput {
+	if o.BatchSize <= 0 {
+		o.BatchSize = defaultHTTPOutputBatchSize
+	}
+
+	if o.FlushInterval <= 0 {
+		o.FlushInterval = defaultHTTPOutputFlushInterval
+	}
+
+	if o.BufferSize <= 0 {
+		o.BufferSize = defaultHTTPOutputBufferSize
+	}
+
+	h := &httpOutput{
+		url:           url,
+		client:        &http.Client{Timeout: httpOutputTimeout},
+		batchSize:     o.BatchSize,
+		flushInterval: o.FlushInterval,
+		lines:         make(chan []byte, o.BufferSize),
+		quit:          make(chan struct{}),
+		done:          make(chan struct{}),
+	}
+
+	go h.run()
+	return h
+}
+
+// Write doesn't fail when the line is dropped, to avoid reporting the
+// error for every request.
+func (h *httpOutput) Write(p []byte) (int, error) {
+	select {
+	case <-h.quit:
+		return 0, io.ErrClosedPipe
+	default:
+	}
+
+	// the logger reuses the buffer
+	line := make([]byte, len(p))
+	copy(line, p)
+
+	select {
+	case h.lines <- line:
+	default:
+	}
+
+	return len(p), nil
+}
+
+func (h *httpOutput) post(batch [][]byte) {
+	if len(batch) == 0 {
+		return
+	}
+
+	var body bytes.Buffer
+	for _, l := range batch {
+		body.Write(l)
+		if len(l) == 0 || l[len(l)-1] != '\n' {
+			body.WriteByte('\n')
+		}
+	}
+
+	rsp, err := h.client.Post(h.url, "text/plain; charset=utf-8", &body)
+	if err != nil {
+		log.Errorf("Failed to send %d access log lines: %v", len(batch), err)
+		return
+	}
+
+	defer rsp.Body.Close()
+	io.Copy(io.Discard, rsp.Body)
+	if rsp.StatusCode >= http.StatusMultipleChoices {
+		log.Errorf("Failed to send %d access log lines, unexpected status: %d", len(batch), rsp.StatusCode)
+	}
+}
+
+func (h *httpOutput) run() {
+	defer close(h.done)
+
+	ticker := time.NewTicker(h.flushInterval)
+	defer ticker.Stop()
+
+	batch := make([][]byte, 0, h.batchSize)
+	add := func(l []byte) {
+		batch = append(batch, l)
+		if len(batch) >= h.batchSize {
+			h.post(batch)
+			batch = make([][]byte, 0, h.batchSize)
+		}
+	}
+
+	for {
+		select {
+		case l := <-h.lines:
+			add(l)
+		case <-ticker.C:
+			h.post(batch)
+			batch = make([][]byte, 0, h.batchSize)
+		case <-h.quit:
+			for {
+				select {
+				case l := <-h.lines:
+					add(l)
+				default:
+					h.post(batch)
+					return
+				}
+			}
+		}
+	}
+}
+
+// Close sends the buffered lines.
+func (h *httpOutput) Close() error {
+	h.once.Do(func() {
+		close(h.quit)
+		<-h.done
+	})
+
+	return nil
+}
@@ -0,0 +1,56 @@
+package logging
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestHTTPOutput(t *testing.T) {
+	var (
+		mu      sync.Mutex
+		batches []string
+	)
+
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		batches = append(batches, string(b))
+		mu.Unlock()
+	}))
+	defer s.Close()
+
+	o, err := NewOutput(s.URL, OutputOptions{BatchSize: 2, FlushInterval: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the writer buffer is reused by the logger
+	line := []byte("GET /foo\n")
+	for _, l := range []string{"GET /foo\n", "GET /bar\n", "GET /baz"} {
+		copy(line, l)
+		if _, err := o.Write(line[:len(l)]); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := o.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	expected := []string{"GET /foo\nGET /bar\n", "GET /baz\n"}
+	if strings.Join(batches, "|") != strings.Join(expected, "|") {
+		t.Errorf("unexpected batches: %q", batches)
+	}
+
+	if _, err := o.Write([]byte("GET /qux\n")); err == nil {
+		t.Error("failed to fail after close")
+	}
+}
@@ -45,6 +45,12 @@ type Options struct {
 	// AccessLogJsonFormatter, when set and JSON logging is enabled, is passed along to to the underlying
 	// Logrus logger for access logs. To enable structured logging, use AccessLogJSONEnabled.
 	AccessLogJsonFormatter *logrus.JSONFormatter
+
+	// AccessLogIdentityFields sets the fields of the caller identity, published by the auth filters,
+	// that are included in the access log as auth-<field>. The possible fields are subject, realm and
+	// client-id, optionally followed by :hash, to log the truncated SHA-256 hash of the value, or by
+	// :mask, to log only the first and the last characters of the value. E.g. subject:hash,realm.
+	AccessLogIdentityFields []string
 }
 
 func (f *prefixFormatter) Format(e *logrus.Entry) ([]byte, error) {
@@ -87,6 +93,7 @@ func initAccessLog(o Options) {
 	l.Level = logrus.InfoLevel
 	accessLog = l
 	stripQuery = o.AccessLogStripQuery
+	identityFields = parseIdentityFields(o.AccessLogIdentityFields)
 }
 
 // Initializes logging.
@@ -3,6 +3,7 @@ package logging
 import (
 	"bufio"
 	"fmt"
+	"io"
 	"net"
 	"net/http"
 )
@@ -23,8 +24,33 @@ func (lw *LoggingWriter) Write(data []byte) (count int, err error) {
 	return
 }
 
+type writerOnly struct {
+	io.Writer
+}
+
+// ReadFrom copies the data from r to the underlying writer, using its
+// io.ReaderFrom implementation when available, e.g. in case of the
+// net/http response writer.
+func (lw *LoggingWriter) ReadFrom(r io.Reader) (n int64, err error) {
+	if rf, ok := lw.writer.(io.ReaderFrom); ok {
+		n, err = rf.ReadFrom(r)
+	} else {
+		n, err = io.Copy(writerOnly{lw.writer}, r)
+	}
+
+	lw.bytes += n
+	return
+}
+
 func (lw *LoggingWriter) WriteHeader(code int) {
 	lw.writer.WriteHeader(code)
+
+	// the informational responses, e.g. 103 Early Hints, are followed
+	// by the final response, except for 101 Switching Protocols
+	if code >= 100 && code < 200 && code != http.StatusSwitchingProtocols {
+		return
+	}
+
 	if code == 0 {
 		code = 200
 	}
@@ -3,6 +3,7 @@ package logging
 import (
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 )
 
@@ -23,6 +24,25 @@ func TestWrites(t *testing.T) {
 	}
 }
 
+func TestReadFrom(t *testing.T) {
+	rr := httptest.NewRecorder()
+	w := &LoggingWriter{writer: rr}
+
+	body := strings.Repeat("Hello, world!", 1000)
+	n, err := w.ReadFrom(strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if rr.Body.String() != body || n != int64(len(body)) {
+		t.Error("failed to copy body")
+	}
+
+	if w.GetBytes() != int64(len(body)) {
+		t.Error("failed to count bytes")
+	}
+}
+
 func TestWritesAndStoresStatusCode(t *testing.T) {
 	rr := httptest.NewRecorder()
 	w := &LoggingWriter{writer: rr}
@@ -37,6 +57,17 @@ func TestWritesAndStoresStatusCode(t *testing.T) {
 	}
 }
 
+func TestIgnoresInformationalStatusCode(t *testing.T) {
+	rr := httptest.NewRecorder()
+	w := &LoggingWriter{writer: rr}
+	w.WriteHeader(http.StatusEarlyHints)
+	w.WriteHeader(http.StatusOK)
+
+	if w.GetCode() != http.StatusOK {
+		t.Errorf("failed to get the final status code: %d", w.GetCode())
+	}
+}
+
 func TestReturnsUnderlyingHeader(t *testing.T) {
 	rr := httptest.NewRecorder()
 	w := &LoggingWriter{writer: rr}
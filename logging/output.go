@@ -0,0 +1,89 @@
+package logging
+
+import (
+	"io"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+	"time"
+)
+
+// OutputOptions configure the log outputs created by NewOutput.
+type OutputOptions struct {
+
+	// MaxSize, when set, makes the file outputs rotated when their size
+	// would exceed it, in bytes.
+	MaxSize int64
+
+	// MaxAge, when set, makes the file outputs rotated when they were
+	// opened for longer than it.
+	MaxAge time.Duration
+
+	// MaxBackups limits the number of the rotated files kept. When not
+	// set, all the rotated files are kept.
+	MaxBackups int
+
+	// BatchSize sets the maximum number of the log lines sent in a
+	// single request by the HTTP outputs. Defaults to 100.
+	BatchSize int
+
+	// FlushInterval sets how often the HTTP outputs send the buffered
+	// log lines, when there are less than BatchSize of them. Defaults
+	// to 1s.
+	FlushInterval time.Duration
+
+	// BufferSize sets how many log lines the HTTP outputs buffer. When
+	// the buffer is full, the new lines are dropped. Defaults to 1024.
+	BufferSize int
+}
+
+type nopCloser struct {
+	io.Writer
+}
+
+func (nopCloser) Close() error { return nil }
+
+// NewOutput creates a log output from its destination:
+//
+//   - /dev/stdout and /dev/stderr
+//   - syslog://host:port, syslog+tcp://host:port and
+//     syslog+unix:///dev/log, for sending the log lines as RFC5424
+//     syslog messages via UDP, TCP or a unix socket
+//   - http:// and https:// URLs, for posting the log lines in batches
+//   - any other value is used as a file path, appending to the file
+//
+// The outputs must be closed to flush the buffered lines.
+func NewOutput(dest string, o OutputOptions) (io.WriteCloser, error) {
+	switch {
+	case strings.HasPrefix(dest, "syslog://"),
+		strings.HasPrefix(dest, "syslog+tcp://"),
+		strings.HasPrefix(dest, "syslog+unix://"):
+		u, err := url.Parse(dest)
+		if err != nil {
+			return nil, err
+		}
+
+		return newSyslogOutput(u)
+	case strings.HasPrefix(dest, "http://"), strings.HasPrefix(dest, "https://"):
+		if _, err := url.Parse(dest); err != nil {
+			return nil, err
+		}
+
+		return newHTTPOutput(dest, o), nil
+	}
+
+	name := path.Clean(dest)
+	switch name {
+	case "/dev/stdout":
+		return nopCloser{os.Stdout}, nil
+	case "/dev/stderr":
+		return nopCloser{os.Stderr}, nil
+	}
+
+	if o.MaxSize > 0 || o.MaxAge > 0 {
+		return newRotatingFile(name, o)
+	}
+
+	return os.OpenFile(name, os.O_APPEND|os.O_CREATE|os.O_WRONLY, os.ModePerm)
+}
@@ -0,0 +1,127 @@
+package logging
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+const rotatedSuffixFormat = "20060102T150405.000"
+
+// rotatingFile renames the log file with the current time as suffix,
+// when it reaches the maximum size or age, and continues writing to a
+// new file with the original name.
+type rotatingFile struct {
+	name       string
+	maxSize    int64
+	maxAge     time.Duration
+	maxBackups int
+	now        func() time.Time
+
+	mu     sync.Mutex
+	file   *os.File
+	size   int64
+	opened time.Time
+}
+
+func newRotatingFile(name string, o OutputOptions) (*rotatingFile, error) {
+	f := &rotatingFile{
+		name:       name,
+		maxSize:    o.MaxSize,
+		maxAge:     o.MaxAge,
+		maxBackups: o.MaxBackups,
+		now:        time.Now,
+	}
+
+	if err := f.open(); err != nil {
+		return nil, err
+	}
+
+	return f, nil
+}
+
+func (f *rotatingFile) open() error {
+	file, err := os.OpenFile(f.name, os.O_APPEND|os.O_CREATE|os.O_WRONLY, os.ModePerm)
+	if err != nil {
+		return err
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+
+	f.file = file
+	f.size = info.Size()
+	f.opened = f.now()
+	return nil
+}
+
+func (f *rotatingFile) rotate() error {
+	if err := f.file.Close(); err != nil {
+		return err
+	}
+
+	f.file = nil
+	if err := os.Rename(f.name, f.name+"."+f.now().Format(rotatedSuffixFormat)); err != nil {
+		return err
+	}
+
+	f.removeBackups()
+	return f.open()
+}
+
+// the suffix of the rotated files sorts them by time
+func (f *rotatingFile) removeBackups() {
+	if f.maxBackups <= 0 {
+		return
+	}
+
+	backups, err := filepath.Glob(f.name + ".*")
+	if err != nil || len(backups) <= f.maxBackups {
+		return
+	}
+
+	sort.Strings(backups)
+	for _, b := range backups[:len(backups)-f.maxBackups] {
+		os.Remove(b)
+	}
+}
+
+func (f *rotatingFile) Write(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.file == nil {
+		if err := f.open(); err != nil {
+			return 0, err
+		}
+	}
+
+	if f.size > 0 && (f.maxSize > 0 && f.size+int64(len(p)) > f.maxSize ||
+		f.maxAge > 0 && f.now().Sub(f.opened) >= f.maxAge) {
+		if err := f.rotate(); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := f.file.Write(p)
+	f.size += int64(n)
+	return n, err
+}
+
+func (f *rotatingFile) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.file == nil {
+		return nil
+	}
+
+	err := f.file.Close()
+	f.file = nil
+	return err
+}
@@ -0,0 +1,103 @@
+package logging
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRotatingFile(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "access.log")
+	now := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	f := &rotatingFile{
+		name:       name,
+		maxSize:    10,
+		maxAge:     time.Hour,
+		maxBackups: 2,
+		now:        func() time.Time { return now },
+	}
+
+	if err := f.open(); err != nil {
+		t.Fatal(err)
+	}
+
+	defer f.Close()
+
+	write := func(s string) {
+		if _, err := f.Write([]byte(s)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	backups := func() []string {
+		b, _ := filepath.Glob(name + ".*")
+		return b
+	}
+
+	write("12345\n")
+	write("1234\n")
+	if b := backups(); len(b) != 1 {
+		t.Fatalf("failed to rotate by size: %v", b)
+	}
+
+	now = now.Add(time.Second)
+	write("1\n")
+	if b := backups(); len(b) != 1 {
+		t.Fatalf("unexpected rotation: %v", b)
+	}
+
+	now = now.Add(time.Hour)
+	write("2\n")
+	if b := backups(); len(b) != 2 {
+		t.Fatalf("failed to rotate by age: %v", b)
+	}
+
+	now = now.Add(time.Hour)
+	write("3\n")
+	b := backups()
+	if len(b) != 2 {
+		t.Fatalf("failed to remove the old backups: %v", b)
+	}
+
+	last, err := os.ReadFile(b[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(last) != "2\n" {
+		t.Errorf("unexpected content of the last backup: %q", last)
+	}
+
+	current, err := os.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(current) != "3\n" {
+		t.Errorf("unexpected content of the log: %q", current)
+	}
+}
+
+func TestNewOutputFile(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "access.log")
+	o, err := NewOutput(name, OutputOptions{MaxSize: 1 << 20})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer o.Close()
+	if _, ok := o.(*rotatingFile); !ok {
+		t.Errorf("unexpected output: %T", o)
+	}
+
+	o, err = NewOutput(name, OutputOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer o.Close()
+	if _, ok := o.(*os.File); !ok {
+		t.Errorf("unexpected output: %T", o)
+	}
+}
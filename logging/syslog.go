@@ -0,0 +1,120 @@
+package logging
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	// local0.info
+	syslogPriority   = 16*8 + 6
+	syslogAppName    = "skipper"
+	syslogMsgID      = "access"
+	syslogWriteLimit = time.Second
+)
+
+// syslogOutput sends each log line as an RFC5424 message. Via TCP, the
+// messages are framed with octet counting, as defined by RFC6587. When
+// sending fails, the connection is established again for the next
+// message.
+type syslogOutput struct {
+	network  string
+	addr     string
+	hostname string
+	pid      string
+
+	mu   sync.Mutex
+	conn net.Conn
+}
+
+func newSyslogOutput(u *url.URL) (*syslogOutput, error) {
+	s := &syslogOutput{pid: strconv.Itoa(os.Getpid())}
+	switch u.Scheme {
+	case "syslog":
+		s.network, s.addr = "udp", u.Host
+	case "syslog+tcp":
+		s.network, s.addr = "tcp", u.Host
+	case "syslog+unix":
+		s.network, s.addr = "unixgram", u.Path
+	}
+
+	if s.addr == "" {
+		return nil, fmt.Errorf("missing syslog address: %s", u)
+	}
+
+	s.hostname, _ = os.Hostname()
+	if s.hostname == "" {
+		s.hostname = "-"
+	}
+
+	conn, err := net.Dial(s.network, s.addr)
+	if err != nil {
+		return nil, err
+	}
+
+	s.conn = conn
+	return s, nil
+}
+
+func (s *syslogOutput) format(p []byte, now time.Time) []byte {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "<%d>1 %s %s %s %s %s - ",
+		syslogPriority,
+		now.UTC().Format(time.RFC3339Nano),
+		s.hostname,
+		syslogAppName,
+		s.pid,
+		syslogMsgID,
+	)
+
+	b.Write(bytes.TrimRight(p, "\n"))
+	if s.network != "tcp" {
+		return b.Bytes()
+	}
+
+	return append([]byte(strconv.Itoa(b.Len())+" "), b.Bytes()...)
+}
+
+func (s *syslogOutput) Write(p []byte) (int, error) {
+	msg := s.format(p, time.Now())
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.conn == nil {
+		conn, err := net.Dial(s.network, s.addr)
+		if err != nil {
+			return 0, err
+		}
+
+		s.conn = conn
+	}
+
+	s.conn.SetWriteDeadline(time.Now().Add(syslogWriteLimit))
+	if _, err := s.conn.Write(msg); err != nil {
+		s.conn.Close()
+		s.conn = nil
+		return 0, err
+	}
+
+	return len(p), nil
+}
+
+func (s *syslogOutput) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.conn == nil {
+		return nil
+	}
+
+	err := s.conn.Close()
+	s.conn = nil
+	return err
+}
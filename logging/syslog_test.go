@@ -0,0 +1,109 @@
+package logging
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+var syslogMessage = regexp.MustCompile(`^<134>1 \S+ \S+ skipper \d+ access - GET /foo$`)
+
+func TestSyslogOutputUDP(t *testing.T) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer pc.Close()
+
+	o, err := NewOutput("syslog://"+pc.LocalAddr().String(), OutputOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer o.Close()
+
+	if _, err := o.Write([]byte("GET /foo\n")); err != nil {
+		t.Fatal(err)
+	}
+
+	pc.SetReadDeadline(time.Now().Add(3 * time.Second))
+	b := make([]byte, 1024)
+	n, _, err := pc.ReadFrom(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !syslogMessage.Match(b[:n]) {
+		t.Errorf("unexpected message: %q", b[:n])
+	}
+}
+
+func TestSyslogOutputTCP(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer l.Close()
+
+	messages := make(chan string, 2)
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		for {
+			length, err := r.ReadString(' ')
+			if err != nil {
+				return
+			}
+
+			n, _ := strconv.Atoi(strings.TrimSpace(length))
+			b := make([]byte, n)
+			if _, err := io.ReadFull(r, b); err != nil {
+				return
+			}
+
+			messages <- string(b)
+		}
+	}()
+
+	o, err := NewOutput("syslog+tcp://"+l.Addr().String(), OutputOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer o.Close()
+
+	for i := 0; i < 2; i++ {
+		if _, err := o.Write([]byte("GET /foo\n")); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for i := 0; i < 2; i++ {
+		select {
+		case m := <-messages:
+			if !syslogMessage.MatchString(m) {
+				t.Errorf("unexpected message: %q", m)
+			}
+		case <-time.After(3 * time.Second):
+			t.Fatal("timeout")
+		}
+	}
+}
+
+func TestSyslogOutputMissingAddress(t *testing.T) {
+	if _, err := NewOutput("syslog://", OutputOptions{}); err == nil {
+		t.Error("failed to fail")
+	}
+}
@@ -0,0 +1,197 @@
+// Package memorywatch implements a watchdog that observes the memory used by
+// the process, and signals memory pressure before the process reaches its
+// memory limit and gets killed.
+//
+// Under memory pressure, the components supporting it shed load, e.g. the
+// scheduler queues reject the requests that would need to wait in a queue,
+// or the cache filter doesn't buffer the responses, and the registered
+// hooks are called once per pressure period to release memory held by
+// caches and buffer pools.
+package memorywatch
+
+import (
+	"math"
+	"runtime/debug"
+	"runtime/metrics"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	skpmetrics "github.com/zalando/skipper/metrics"
+)
+
+const (
+	defaultThreshold     = 0.9
+	defaultCheckInterval = time.Second
+
+	totalMemoryKey    = "/memory/classes/total:bytes"
+	releasedMemoryKey = "/memory/classes/heap/released:bytes"
+
+	pressureMetricsKey = "memorywatch.pressure"
+	usageMetricsKey    = "memorywatch.usage.bytes"
+	shedMetricsKey     = "memorywatch.shed"
+)
+
+// Options are used to initialize the watchdog.
+type Options struct {
+
+	// Limit sets the memory limit in bytes. When not set, the soft limit of
+	// the Go runtime is used, as set by GOMEMLIMIT or debug.SetMemoryLimit.
+	// When no limit is available, the watchdog never signals pressure.
+	Limit int64
+
+	// Threshold sets the ratio of the limit above which the memory is
+	// considered under pressure. Defaults to 0.9.
+	Threshold float64
+
+	// CheckInterval sets how often the memory usage is checked. Defaults
+	// to 1s.
+	CheckInterval time.Duration
+
+	// Metrics, when set, is used to report the memory usage, the pressure
+	// state and the shed requests.
+	Metrics skpmetrics.Metrics
+
+	// used in tests
+	usage func() uint64
+}
+
+// Watchdog observes the memory usage of the process.
+type Watchdog struct {
+	options  Options
+	limit    uint64
+	pressure int32
+	quit     chan struct{}
+	once     sync.Once
+
+	mx    sync.Mutex
+	hooks []func()
+}
+
+// New creates and starts a watchdog. It needs to be closed when not used
+// anymore.
+func New(o Options) *Watchdog {
+	if o.Threshold <= 0 || o.Threshold > 1 {
+		o.Threshold = defaultThreshold
+	}
+
+	if o.CheckInterval <= 0 {
+		o.CheckInterval = defaultCheckInterval
+	}
+
+	if o.usage == nil {
+		o.usage = readUsage
+	}
+
+	limit := o.Limit
+	if limit <= 0 {
+		// negative input only reads the current setting
+		limit = debug.SetMemoryLimit(-1)
+	}
+
+	w := &Watchdog{
+		options: o,
+		quit:    make(chan struct{}),
+	}
+
+	if limit > 0 && limit < math.MaxInt64 {
+		w.limit = uint64(float64(limit) * o.Threshold)
+		go w.watch()
+	} else {
+		log.Info("memorywatch: no memory limit set, memory pressure is not detected")
+	}
+
+	return w
+}
+
+func readUsage() uint64 {
+	samples := []metrics.Sample{{Name: totalMemoryKey}, {Name: releasedMemoryKey}}
+	metrics.Read(samples)
+	if samples[0].Value.Kind() != metrics.KindUint64 || samples[1].Value.Kind() != metrics.KindUint64 {
+		return 0
+	}
+
+	return samples[0].Value.Uint64() - samples[1].Value.Uint64()
+}
+
+// OnPressure registers a function that is called every time when the
+// memory gets under pressure, e.g. to shrink caches.
+func (w *Watchdog) OnPressure(f func()) {
+	w.mx.Lock()
+	defer w.mx.Unlock()
+	w.hooks = append(w.hooks, f)
+}
+
+func (w *Watchdog) check() {
+	usage := w.options.usage()
+	pressure := usage > w.limit
+	previous := atomic.SwapInt32(&w.pressure, boolToInt32(pressure)) == 1
+
+	if w.options.Metrics != nil {
+		w.options.Metrics.UpdateGauge(usageMetricsKey, float64(usage))
+		w.options.Metrics.UpdateGauge(pressureMetricsKey, float64(boolToInt32(pressure)))
+	}
+
+	if !pressure || previous {
+		return
+	}
+
+	log.Warnf("memorywatch: memory under pressure, usage: %d, limit: %d", usage, w.limit)
+
+	w.mx.Lock()
+	hooks := w.hooks
+	w.mx.Unlock()
+	for _, h := range hooks {
+		h()
+	}
+
+	debug.FreeOSMemory()
+}
+
+func (w *Watchdog) watch() {
+	t := time.NewTicker(w.options.CheckInterval)
+	defer t.Stop()
+	for {
+		select {
+		case <-t.C:
+			w.check()
+		case <-w.quit:
+			return
+		}
+	}
+}
+
+func boolToInt32(b bool) int32 {
+	if b {
+		return 1
+	}
+
+	return 0
+}
+
+// UnderPressure returns true when the memory usage is above the threshold.
+// It is safe to call on a nil watchdog.
+func (w *Watchdog) UnderPressure() bool {
+	return w != nil && atomic.LoadInt32(&w.pressure) == 1
+}
+
+// Shed returns true when the memory is under pressure, and the caller
+// should reject the work. Every positive decision is counted in the
+// metrics.
+func (w *Watchdog) Shed() bool {
+	if !w.UnderPressure() {
+		return false
+	}
+
+	if w.options.Metrics != nil {
+		w.options.Metrics.IncCounter(shedMetricsKey)
+	}
+
+	return true
+}
+
+// Close stops the watchdog.
+func (w *Watchdog) Close() {
+	w.once.Do(func() { close(w.quit) })
+}
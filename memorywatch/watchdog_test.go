@@ -0,0 +1,74 @@
+package memorywatch
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/zalando/skipper/metrics/metricstest"
+)
+
+func TestWatchdog(t *testing.T) {
+	var usage uint64 = 50
+	m := &metricstest.MockMetrics{}
+	w := New(Options{
+		Limit:         100,
+		Threshold:     0.8,
+		CheckInterval: time.Hour,
+		Metrics:       m,
+		usage:         func() uint64 { return atomic.LoadUint64(&usage) },
+	})
+	defer w.Close()
+
+	var hookCalls int
+	w.OnPressure(func() { hookCalls++ })
+
+	w.check()
+	if w.UnderPressure() || w.Shed() {
+		t.Fatal("unexpected memory pressure")
+	}
+
+	atomic.StoreUint64(&usage, 90)
+	w.check()
+	w.check()
+	if !w.UnderPressure() || !w.Shed() || !w.Shed() {
+		t.Fatal("failed to detect memory pressure")
+	}
+
+	if hookCalls != 1 {
+		t.Errorf("unexpected number of hook calls: %d", hookCalls)
+	}
+
+	m.WithCounters(func(c map[string]int64) {
+		if c[shedMetricsKey] != 2 {
+			t.Errorf("unexpected shed count: %d", c[shedMetricsKey])
+		}
+	})
+
+	m.WithGauges(func(g map[string]float64) {
+		if g[pressureMetricsKey] != 1 || g[usageMetricsKey] != 90 {
+			t.Errorf("unexpected gauges: %v", g)
+		}
+	})
+
+	atomic.StoreUint64(&usage, 70)
+	w.check()
+	if w.UnderPressure() {
+		t.Error("failed to detect released memory pressure")
+	}
+}
+
+func TestWatchdogWithoutLimit(t *testing.T) {
+	w := New(Options{usage: func() uint64 { return 1 << 40 }})
+	defer w.Close()
+
+	time.Sleep(10 * time.Millisecond)
+	if w.UnderPressure() {
+		t.Error("unexpected memory pressure without limit")
+	}
+
+	var nilWatchdog *Watchdog
+	if nilWatchdog.Shed() {
+		t.Error("unexpected shedding by nil watchdog")
+	}
+}
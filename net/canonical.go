@@ -0,0 +1,198 @@
+package net
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Canonicalization defines how the target of the incoming requests is
+// canonicalized before routing. The canonical form makes the route
+// matching and the path forwarded to the backends predictable, and it
+// prevents the path-confusion attacks, where the proxy and the backend
+// interpret the same path differently.
+type Canonicalization struct {
+	// MergeSlashes replaces the consecutive slashes of the path with a
+	// single one.
+	MergeSlashes bool
+
+	// RemoveDotSegments resolves the "." and ".." segments of the path as
+	// described in RFC 3986, section 5.2.4.
+	RemoveDotSegments bool
+
+	// NormalizePercentEncoding decodes the percent-encoded unreserved
+	// characters of the path, and converts the hexadecimal digits of the
+	// remaining percent-encoded characters to uppercase, as described in
+	// RFC 3986, section 6.2.2. Encoded reserved characters, e.g. %2F, are
+	// preserved.
+	NormalizePercentEncoding bool
+
+	// LowercaseHost converts the request host to lowercase.
+	LowercaseHost bool
+}
+
+type originalURLKey struct{}
+
+// Apply canonicalizes the request target. It returns false if the request
+// was not changed.
+func (c *Canonicalization) Apply(r *http.Request) bool {
+	var changed bool
+	if c.LowercaseHost {
+		if h := strings.ToLower(r.Host); h != r.Host {
+			r.Host = h
+			changed = true
+		}
+	}
+
+	original := r.URL.EscapedPath()
+	p := original
+	if c.NormalizePercentEncoding {
+		p = normalizePercentEncoding(p)
+	}
+
+	if c.MergeSlashes {
+		p = mergeSlashes(p)
+	}
+
+	if c.RemoveDotSegments {
+		p = removeDotSegments(p)
+	}
+
+	if p == original {
+		return changed
+	}
+
+	decoded, err := url.PathUnescape(p)
+	if err != nil {
+		return changed
+	}
+
+	r.URL.Path = decoded
+	r.URL.RawPath = p
+	return true
+}
+
+func isUnreserved(c byte) bool {
+	return 'a' <= c && c <= 'z' ||
+		'A' <= c && c <= 'Z' ||
+		'0' <= c && c <= '9' ||
+		c == '-' || c == '.' || c == '_' || c == '~'
+}
+
+func unhex(c byte) (byte, bool) {
+	switch {
+	case '0' <= c && c <= '9':
+		return c - '0', true
+	case 'a' <= c && c <= 'f':
+		return c - 'a' + 10, true
+	case 'A' <= c && c <= 'F':
+		return c - 'A' + 10, true
+	}
+
+	return 0, false
+}
+
+func normalizePercentEncoding(p string) string {
+	if strings.IndexByte(p, '%') < 0 {
+		return p
+	}
+
+	var b strings.Builder
+	b.Grow(len(p))
+	for i := 0; i < len(p); i++ {
+		if p[i] != '%' || i+2 >= len(p) {
+			b.WriteByte(p[i])
+			continue
+		}
+
+		hi, okHi := unhex(p[i+1])
+		lo, okLo := unhex(p[i+2])
+		if !okHi || !okLo {
+			b.WriteByte(p[i])
+			continue
+		}
+
+		if c := hi<<4 | lo; isUnreserved(c) {
+			b.WriteByte(c)
+		} else {
+			b.WriteByte('%')
+			b.WriteString(strings.ToUpper(p[i+1 : i+3]))
+		}
+
+		i += 2
+	}
+
+	return b.String()
+}
+
+func mergeSlashes(p string) string {
+	if !strings.Contains(p, "//") {
+		return p
+	}
+
+	var b strings.Builder
+	b.Grow(len(p))
+	for i := 0; i < len(p); i++ {
+		if p[i] == '/' && i > 0 && p[i-1] == '/' {
+			continue
+		}
+
+		b.WriteByte(p[i])
+	}
+
+	return b.String()
+}
+
+func removeDotSegments(p string) string {
+	segments := strings.Split(p, "/")
+	output := make([]string, 0, len(segments))
+	for i, s := range segments {
+		last := i == len(segments)-1
+		switch s {
+		case ".":
+			if last {
+				output = append(output, "")
+			}
+		case "..":
+			// the leading empty segment of an absolute path is
+			// never removed
+			if len(output) > 1 {
+				output = output[:len(output)-1]
+			}
+
+			if last {
+				output = append(output, "")
+			}
+		default:
+			output = append(output, s)
+		}
+	}
+
+	return strings.Join(output, "/")
+}
+
+// OriginalURL returns the request URL as it was received, before the
+// canonicalization changed it. It returns false, when the request was not
+// changed by the canonicalization.
+func OriginalURL(r *http.Request) (*url.URL, bool) {
+	u, ok := r.Context().Value(originalURLKey{}).(*url.URL)
+	return u, ok
+}
+
+// CanonicalizationHandler canonicalizes the target of the incoming requests
+// before passing them to the wrapped handler. When a request was changed, the
+// original URL is available to the wrapped handler via OriginalURL.
+type CanonicalizationHandler struct {
+	Canonicalization Canonicalization
+	Handler          http.Handler
+}
+
+func (h *CanonicalizationHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	original := *r.URL
+	if h.Canonicalization.Apply(r) {
+		r = r.WithContext(context.WithValue(r.Context(), originalURLKey{}, &original))
+	}
+
+	h.Handler.ServeHTTP(w, r)
+}
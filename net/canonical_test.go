@@ -0,0 +1,159 @@
+package net
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+func TestCanonicalization(t *testing.T) {
+	all := Canonicalization{
+		MergeSlashes:             true,
+		RemoveDotSegments:        true,
+		NormalizePercentEncoding: true,
+		LowercaseHost:            true,
+	}
+
+	for _, tc := range []struct {
+		name             string
+		canonicalization Canonicalization
+		target           string
+		host             string
+		expectedPath     string
+		expectedRawPath  string
+		expectedHost     string
+		changed          bool
+	}{{
+		name:         "nothing enabled",
+		target:       "/foo//bar/../baz",
+		host:         "Example.ORG",
+		expectedPath: "/foo//bar/../baz",
+		expectedHost: "Example.ORG",
+	}, {
+		name:             "canonical path",
+		canonicalization: all,
+		target:           "/foo/bar",
+		host:             "example.org",
+		expectedPath:     "/foo/bar",
+		expectedHost:     "example.org",
+	}, {
+		name:             "merge slashes",
+		canonicalization: Canonicalization{MergeSlashes: true},
+		target:           "//foo///bar/",
+		expectedPath:     "/foo/bar/",
+		expectedRawPath:  "/foo/bar/",
+		changed:          true,
+	}, {
+		name:             "remove dot segments",
+		canonicalization: Canonicalization{RemoveDotSegments: true},
+		target:           "/foo/./bar/../baz",
+		expectedPath:     "/foo/baz",
+		expectedRawPath:  "/foo/baz",
+		changed:          true,
+	}, {
+		name:             "remove dot segments with trailing dot",
+		canonicalization: Canonicalization{RemoveDotSegments: true},
+		target:           "/foo/bar/..",
+		expectedPath:     "/foo/",
+		expectedRawPath:  "/foo/",
+		changed:          true,
+	}, {
+		name:             "remove dot segments above root",
+		canonicalization: Canonicalization{RemoveDotSegments: true},
+		target:           "/../../etc/passwd",
+		expectedPath:     "/etc/passwd",
+		expectedRawPath:  "/etc/passwd",
+		changed:          true,
+	}, {
+		name:             "dot segments without merging slashes",
+		canonicalization: Canonicalization{RemoveDotSegments: true},
+		target:           "/foo//../bar",
+		expectedPath:     "/foo/bar",
+		expectedRawPath:  "/foo/bar",
+		changed:          true,
+	}, {
+		name:             "normalize percent encoding",
+		canonicalization: Canonicalization{NormalizePercentEncoding: true},
+		target:           "/%7euser/%2f/%3a",
+		expectedPath:     "/~user///:",
+		expectedRawPath:  "/~user/%2F/%3A",
+		changed:          true,
+	}, {
+		name:             "encoded dot segments",
+		canonicalization: all,
+		target:           "/foo/%2e%2e/bar",
+		expectedPath:     "/bar",
+		expectedRawPath:  "/bar",
+		changed:          true,
+	}, {
+		name:             "all",
+		canonicalization: all,
+		target:           "/api//v1/./%7Euser/../%61dmin%2f",
+		host:             "Example.ORG:9090",
+		expectedPath:     "/api/v1/admin/",
+		expectedRawPath:  "/api/v1/admin%2F",
+		expectedHost:     "example.org:9090",
+		changed:          true,
+	}, {
+		name:             "lowercase host only",
+		canonicalization: Canonicalization{LowercaseHost: true},
+		target:           "/foo",
+		host:             "Example.ORG",
+		expectedPath:     "/foo",
+		expectedHost:     "example.org",
+		changed:          true,
+	}} {
+		t.Run(tc.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", tc.target, nil)
+			r.Host = tc.host
+
+			changed := tc.canonicalization.Apply(r)
+			if changed != tc.changed {
+				t.Errorf("unexpected change: %v", changed)
+			}
+
+			if r.URL.Path != tc.expectedPath {
+				t.Errorf("unexpected path: %s, expected: %s", r.URL.Path, tc.expectedPath)
+			}
+
+			if r.URL.RawPath != tc.expectedRawPath {
+				t.Errorf("unexpected raw path: %s, expected: %s", r.URL.RawPath, tc.expectedRawPath)
+			}
+
+			if r.Host != tc.expectedHost {
+				t.Errorf("unexpected host: %s, expected: %s", r.Host, tc.expectedHost)
+			}
+		})
+	}
+}
+
+func TestCanonicalizationHandler(t *testing.T) {
+	var (
+		path     string
+		original string
+		ok       bool
+	)
+
+	h := &CanonicalizationHandler{
+		Canonicalization: Canonicalization{MergeSlashes: true},
+		Handler: http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+			var u *url.URL
+			path = r.URL.Path
+			u, ok = OriginalURL(r)
+			if ok {
+				original = u.EscapedPath()
+			}
+		}),
+	}
+
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/foo//bar", nil))
+	if path != "/foo/bar" || !ok || original != "/foo//bar" {
+		t.Errorf("unexpected paths: %s, %s, %v", path, original, ok)
+	}
+
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/foo/bar", nil))
+	if path != "/foo/bar" || ok {
+		t.Errorf("unexpected original url for canonical request: %v", ok)
+	}
+}
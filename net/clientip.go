@@ -0,0 +1,180 @@
+package net
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+const (
+	xForwardedForHeader = "X-Forwarded-For"
+	forwardedHeader     = "Forwarded"
+)
+
+// ClientIPOptions defines how the IP address of the client is resolved
+// from the incoming requests.
+type ClientIPOptions struct {
+	// TrustedProxies contains the networks of the proxies, that are
+	// trusted to report the address of their client in the forwarded
+	// header. The chain of the forwarded addresses is walked from the
+	// right, starting from the remote address of the connection, and
+	// the first address that is not trusted is used as the client IP.
+	TrustedProxies IPNets
+
+	// TrustedHops, when set, defines the fixed number of proxies in
+	// front of Skipper, including the one connecting to Skipper. The
+	// client IP is the address reported by the farthest trusted proxy,
+	// regardless of the proxy addresses. It takes precedence over the
+	// TrustedProxies.
+	TrustedHops int
+
+	// Header defines the forwarded header used to resolve the chain of
+	// the proxies, either X-Forwarded-For or Forwarded. Defaults to
+	// X-Forwarded-For.
+	Header string
+}
+
+// ClientIPResolver resolves the IP address of the clients. The remote
+// address of the connection is used as the address of the nearest hop,
+// which, when the PROXY protocol is used, is already the address reported
+// by the PROXY protocol header, and it takes precedence over the forwarded
+// headers.
+type ClientIPResolver struct {
+	options ClientIPOptions
+}
+
+type clientIPKey struct{}
+
+// NewClientIPResolver creates a client IP resolver.
+func NewClientIPResolver(o ClientIPOptions) (*ClientIPResolver, error) {
+	switch http.CanonicalHeaderKey(o.Header) {
+	case "":
+		o.Header = xForwardedForHeader
+	case xForwardedForHeader, forwardedHeader:
+		o.Header = http.CanonicalHeaderKey(o.Header)
+	default:
+		return nil, fmt.Errorf("invalid client IP header: %s", o.Header)
+	}
+
+	if o.TrustedHops < 0 {
+		return nil, fmt.Errorf("invalid number of trusted hops: %d", o.TrustedHops)
+	}
+
+	return &ClientIPResolver{options: o}, nil
+}
+
+// parseForwardedFor returns the addresses of the for parameters of the
+// Forwarded header as defined in RFC 7239. Obfuscated or unknown addresses
+// are returned as nil.
+func parseForwardedFor(values []string) []net.IP {
+	var ips []net.IP
+	for _, v := range values {
+		for _, element := range strings.Split(v, ",") {
+			var ip net.IP
+			for _, pair := range strings.Split(element, ";") {
+				key, value, ok := strings.Cut(strings.TrimSpace(pair), "=")
+				if !ok || !strings.EqualFold(key, "for") {
+					continue
+				}
+
+				value = strings.Trim(value, `"`)
+				if strings.HasPrefix(value, "[") {
+					if end := strings.IndexByte(value, ']'); end > 0 {
+						value = value[1:end]
+					}
+				}
+
+				ip = parse(value)
+			}
+
+			ips = append(ips, ip)
+		}
+	}
+
+	return ips
+}
+
+func parseForwardedForList(values []string) []net.IP {
+	var ips []net.IP
+	for _, v := range values {
+		for _, a := range strings.Split(v, ",") {
+			ips = append(ips, parse(strings.TrimSpace(a)))
+		}
+	}
+
+	return ips
+}
+
+// Resolve returns the IP address of the client of the request.
+func (cr *ClientIPResolver) Resolve(r *http.Request) net.IP {
+	peer := parse(r.RemoteAddr)
+
+	var hops []net.IP
+	if cr.options.Header == forwardedHeader {
+		hops = parseForwardedFor(r.Header.Values(forwardedHeader))
+	} else {
+		hops = parseForwardedForList(r.Header.Values(xForwardedForHeader))
+	}
+
+	if cr.options.TrustedHops > 0 {
+		client := peer
+		for i := 0; i < cr.options.TrustedHops && i < len(hops); i++ {
+			ip := hops[len(hops)-1-i]
+			if ip == nil {
+				break
+			}
+
+			client = ip
+		}
+
+		return client
+	}
+
+	if !cr.options.TrustedProxies.Contain(peer) {
+		return peer
+	}
+
+	client := peer
+	for i := len(hops) - 1; i >= 0; i-- {
+		ip := hops[i]
+		if ip == nil {
+			// we can't tell who sent the request to the last
+			// trusted proxy
+			break
+		}
+
+		client = ip
+		if !cr.options.TrustedProxies.Contain(ip) {
+			break
+		}
+	}
+
+	return client
+}
+
+// ClientIP returns the client IP address of the request resolved by the
+// ClientIPHandler. It returns false when the address was not resolved.
+func ClientIP(r *http.Request) (net.IP, bool) {
+	ip, ok := r.Context().Value(clientIPKey{}).(net.IP)
+	return ip, ok
+}
+
+// ClientIPHandler resolves the client IP address of the incoming requests
+// once, before passing them to the wrapped handler. The resolved address is
+// returned by RemoteHost, RemoteHostFromLast and ClientIP, and, this way,
+// it is used consistently by every component, e.g. by the predicates, the
+// rate limits, the load balancer and the access log.
+type ClientIPHandler struct {
+	Resolver *ClientIPResolver
+	Handler  http.Handler
+}
+
+func (h *ClientIPHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if ip := h.Resolver.Resolve(r); ip != nil {
+		r = r.WithContext(context.WithValue(r.Context(), clientIPKey{}, ip))
+	}
+
+	h.Handler.ServeHTTP(w, r)
+}
@@ -0,0 +1,154 @@
+package net
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestNewClientIPResolver(t *testing.T) {
+	for _, o := range []ClientIPOptions{
+		{Header: "X-Real-Ip"},
+		{TrustedHops: -1},
+	} {
+		if _, err := NewClientIPResolver(o); err == nil {
+			t.Errorf("failed to fail for %v", o)
+		}
+	}
+}
+
+func TestClientIPResolve(t *testing.T) {
+	trusted, err := ParseCIDRs([]string{"10.0.0.0/8", "192.168.1.1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		name       string
+		options    ClientIPOptions
+		remoteAddr string
+		header     string
+		values     []string
+		expected   string
+	}{{
+		name:       "untrusted peer",
+		options:    ClientIPOptions{TrustedProxies: trusted},
+		remoteAddr: "1.2.3.4:5678",
+		values:     []string{"5.6.7.8"},
+		expected:   "1.2.3.4",
+	}, {
+		name:       "trusted peer",
+		options:    ClientIPOptions{TrustedProxies: trusted},
+		remoteAddr: "10.0.0.1:5678",
+		values:     []string{"5.6.7.8"},
+		expected:   "5.6.7.8",
+	}, {
+		name:       "spoofed chain",
+		options:    ClientIPOptions{TrustedProxies: trusted},
+		remoteAddr: "10.0.0.1:5678",
+		values:     []string{"6.6.6.6, 5.6.7.8, 192.168.1.1"},
+		expected:   "5.6.7.8",
+	}, {
+		name:       "multiple header lines",
+		options:    ClientIPOptions{TrustedProxies: trusted},
+		remoteAddr: "10.0.0.1:5678",
+		values:     []string{"6.6.6.6, 5.6.7.8", "10.1.1.1"},
+		expected:   "5.6.7.8",
+	}, {
+		name:       "all trusted",
+		options:    ClientIPOptions{TrustedProxies: trusted},
+		remoteAddr: "10.0.0.1:5678",
+		values:     []string{"10.0.0.3, 10.0.0.2"},
+		expected:   "10.0.0.3",
+	}, {
+		name:       "invalid hop",
+		options:    ClientIPOptions{TrustedProxies: trusted},
+		remoteAddr: "10.0.0.1:5678",
+		values:     []string{"5.6.7.8, foo, 10.0.0.2"},
+		expected:   "10.0.0.2",
+	}, {
+		name:       "no header",
+		options:    ClientIPOptions{TrustedProxies: trusted},
+		remoteAddr: "10.0.0.1:5678",
+		expected:   "10.0.0.1",
+	}, {
+		name:       "trusted hops",
+		options:    ClientIPOptions{TrustedHops: 2},
+		remoteAddr: "1.2.3.4:5678",
+		values:     []string{"6.6.6.6, 5.6.7.8, 9.9.9.9"},
+		expected:   "5.6.7.8",
+	}, {
+		name:       "more trusted hops than forwarded",
+		options:    ClientIPOptions{TrustedHops: 3},
+		remoteAddr: "1.2.3.4:5678",
+		values:     []string{"5.6.7.8"},
+		expected:   "5.6.7.8",
+	}, {
+		name:       "forwarded header",
+		options:    ClientIPOptions{TrustedProxies: trusted, Header: "forwarded"},
+		remoteAddr: "10.0.0.1:5678",
+		header:     "Forwarded",
+		values:     []string{`for=6.6.6.6, for="[2001:db8:cafe::17]:4711";proto=https, For=10.0.0.2;by=10.0.0.1`},
+		expected:   "2001:db8:cafe::17",
+	}, {
+		name:       "obfuscated forwarded",
+		options:    ClientIPOptions{TrustedProxies: trusted, Header: "Forwarded"},
+		remoteAddr: "10.0.0.1:5678",
+		header:     "Forwarded",
+		values:     []string{`for=5.6.7.8, for=_hidden, for=10.0.0.2`},
+		expected:   "10.0.0.2",
+	}} {
+		t.Run(tc.name, func(t *testing.T) {
+			r, err := NewClientIPResolver(tc.options)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			req := httptest.NewRequest("GET", "/", nil)
+			req.RemoteAddr = tc.remoteAddr
+			header := tc.header
+			if header == "" {
+				header = "X-Forwarded-For"
+			}
+
+			for _, v := range tc.values {
+				req.Header.Add(header, v)
+			}
+
+			if ip := r.Resolve(req); !ip.Equal(net.ParseIP(tc.expected)) {
+				t.Errorf("unexpected client IP: %v, expected: %s", ip, tc.expected)
+			}
+		})
+	}
+}
+
+func TestClientIPHandler(t *testing.T) {
+	trusted, _ := ParseCIDRs([]string{"10.0.0.0/8"})
+	r, _ := NewClientIPResolver(ClientIPOptions{TrustedProxies: trusted})
+
+	var remoteHost, remoteHostFromLast, clientIP net.IP
+	h := &ClientIPHandler{
+		Resolver: r,
+		Handler: http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+			remoteHost = RemoteHost(r)
+			remoteHostFromLast = RemoteHostFromLast(r)
+			clientIP, _ = ClientIP(r)
+		}),
+	}
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.RemoteAddr = "10.0.0.1:5678"
+	req.Header.Set("X-Forwarded-For", "6.6.6.6, 5.6.7.8")
+	h.ServeHTTP(httptest.NewRecorder(), req)
+
+	expected := net.ParseIP("5.6.7.8")
+	if !remoteHost.Equal(expected) || !remoteHostFromLast.Equal(expected) || !clientIP.Equal(expected) {
+		t.Errorf("inconsistent client IP: %v, %v, %v", remoteHost, remoteHostFromLast, clientIP)
+	}
+
+	// without the resolution, the legacy behavior is preserved
+	if ip := RemoteHost(req); !ip.Equal(net.ParseIP("6.6.6.6")) {
+		t.Errorf("unexpected remote host: %v", ip)
+	}
+}
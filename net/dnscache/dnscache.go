@@ -0,0 +1,298 @@
+/*
+Package dnscache provides a caching DNS resolver for the backend
+connections, that keeps using the last resolved addresses when the
+resolution fails, so that the transient DNS outages don't turn
+immediately into failing requests.
+
+The resolved addresses are used for the configured TTL. When the TTL has
+elapsed, the name is resolved again on the next connection. When this
+resolution fails, the previous addresses are used, until the stale TTL
+elapses, too.
+
+The addresses can be cached for the TTL of their DNS records instead, when
+the resolver reports it, see TTLResolver and ServerResolver.
+
+The DNS servers used for the resolution can be overridden per request by
+the context, see WithResolver.
+
+The following counters are collected:
+
+	dnscache.hit
+	dnscache.miss
+	dnscache.failure
+	dnscache.stale
+*/
+package dnscache
+
+import (
+	"context"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/zalando/skipper/metrics"
+)
+
+// DefaultStaleTTL is used when Options.StaleTTL is not set.
+const DefaultStaleTTL = 10 * time.Minute
+
+const lookupTimeout = 10 * time.Second
+
+// Resolver is implemented by *net.Resolver.
+type Resolver interface {
+	LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error)
+}
+
+// DialFunc is the signature of net.Dialer.DialContext.
+type DialFunc func(ctx context.Context, network, addr string) (net.Conn, error)
+
+// Options for the DNS cache.
+type Options struct {
+
+	// TTL sets how long the resolved addresses are used without
+	// resolving the names again. When zero, the names are resolved
+	// for every connection, except for the requests with a resolver
+	// override, whose results are cached and served when stale, too.
+	TTL time.Duration
+
+	// StaleTTL sets how long the addresses are used after the TTL,
+	// when the resolution fails. Defaults to DefaultStaleTTL.
+	StaleTTL time.Duration
+
+	// RespectTTL makes the cache use the TTL of the DNS records instead
+	// of TTL, for the addresses resolved by a TTLResolver, including
+	// the resolver overrides. When set, the addresses are cached even
+	// when TTL is zero.
+	RespectTTL bool
+
+	// Resolver used by default. Defaults to net.DefaultResolver.
+	Resolver Resolver
+
+	// Dial is used to connect to the resolved addresses and to the DNS
+	// servers of the resolver overrides. Defaults to
+	// (&net.Dialer{}).DialContext.
+	Dial DialFunc
+
+	// Metrics collects the cache hits and misses, the failed
+	// resolutions and the stale responses. Defaults to metrics.Default.
+	Metrics metrics.Metrics
+}
+
+type entry struct {
+	addrs    []net.IPAddr
+	resolved time.Time
+	ttl      time.Duration
+}
+
+type key struct {
+	server string
+	host   string
+}
+
+type lookup struct {
+	done  chan struct{}
+	addrs []net.IPAddr
+	ttl   time.Duration
+	err   error
+}
+
+// Cache resolves and caches the addresses of the backend hosts.
+type Cache struct {
+	options   Options
+	now       func() time.Time
+	mu        sync.Mutex
+	entries   map[key]*entry
+	inflight  map[key]*lookup
+	resolvers map[string]Resolver
+}
+
+type resolverKey struct{}
+
+// WithResolver returns a context that makes the cache resolve the
+// names with the DNS server at the given address, in the host:port
+// format. Multiple servers, tried in order, can be set as a comma
+// separated list.
+func WithResolver(ctx context.Context, server string) context.Context {
+	return context.WithValue(ctx, resolverKey{}, server)
+}
+
+func resolverFromContext(ctx context.Context) string {
+	s, _ := ctx.Value(resolverKey{}).(string)
+	return s
+}
+
+// New creates a DNS cache.
+func New(o Options) *Cache {
+	if o.StaleTTL <= 0 {
+		o.StaleTTL = DefaultStaleTTL
+	}
+
+	if o.Resolver == nil {
+		o.Resolver = net.DefaultResolver
+	}
+
+	if o.Dial == nil {
+		o.Dial = (&net.Dialer{}).DialContext
+	}
+
+	if o.Metrics == nil {
+		o.Metrics = metrics.Default
+	}
+
+	return &Cache{
+		options:   o,
+		now:       time.Now,
+		entries:   make(map[key]*entry),
+		inflight:  make(map[key]*lookup),
+		resolvers: make(map[string]Resolver),
+	}
+}
+
+func (c *Cache) resolver(server string) Resolver {
+	if server == "" {
+		return c.options.Resolver
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	r, ok := c.resolvers[server]
+	if !ok {
+		r = NewServerResolver(strings.Split(server, ","), c.options.Dial)
+		c.resolvers[server] = r
+	}
+
+	return r
+}
+
+// tells whether the addresses resolved with the server are cached
+func (c *Cache) caching(server string) bool {
+	if c.options.TTL > 0 || server != "" {
+		return true
+	}
+
+	_, ok := c.options.Resolver.(TTLResolver)
+	return ok && c.options.RespectTTL
+}
+
+func (c *Cache) lookup(k key, l *lookup) {
+	// not canceled by the request that happened to start the lookup
+	ctx, cancel := context.WithTimeout(context.Background(), lookupTimeout)
+	defer cancel()
+
+	l.ttl = c.options.TTL
+	r := c.resolver(k.server)
+	if tr, ok := r.(TTLResolver); ok && c.options.RespectTTL {
+		l.addrs, l.ttl, l.err = tr.LookupIPAddrTTL(ctx, k.host)
+	} else {
+		l.addrs, l.err = r.LookupIPAddr(ctx, k.host)
+	}
+
+	if l.err == nil && len(l.addrs) == 0 {
+		l.err = &net.DNSError{Err: "no such host", Name: k.host, IsNotFound: true}
+	}
+
+	c.mu.Lock()
+	delete(c.inflight, k)
+	if l.err == nil {
+		c.entries[k] = &entry{addrs: l.addrs, resolved: c.now(), ttl: l.ttl}
+	}
+
+	c.mu.Unlock()
+	close(l.done)
+}
+
+// resolves the host once for the concurrent requests
+func (c *Cache) resolve(ctx context.Context, k key) ([]net.IPAddr, error) {
+	c.mu.Lock()
+	l, ok := c.inflight[k]
+	if !ok {
+		l = &lookup{done: make(chan struct{})}
+		c.inflight[k] = l
+		go c.lookup(k, l)
+	}
+
+	c.mu.Unlock()
+	select {
+	case <-l.done:
+		return l.addrs, l.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// LookupIPAddr returns the cached addresses of a host, or resolves them.
+// When the TTL is zero, the TTL of the records is not respected, and the
+// context has no resolver override, the host is resolved without the
+// cache.
+func (c *Cache) LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error) {
+	k := key{server: resolverFromContext(ctx), host: host}
+	if !c.caching(k.server) {
+		return c.options.Resolver.LookupIPAddr(ctx, host)
+	}
+
+	c.mu.Lock()
+	e := c.entries[k]
+	c.mu.Unlock()
+
+	now := c.now()
+	if e != nil && now.Sub(e.resolved) < e.ttl {
+		c.options.Metrics.IncCounter("dnscache.hit")
+		return e.addrs, nil
+	}
+
+	c.options.Metrics.IncCounter("dnscache.miss")
+	addrs, err := c.resolve(ctx, k)
+	if err == nil {
+		return addrs, nil
+	}
+
+	c.options.Metrics.IncCounter("dnscache.failure")
+	if e != nil && now.Sub(e.resolved) < e.ttl+c.options.StaleTTL {
+		c.options.Metrics.IncCounter("dnscache.stale")
+		return e.addrs, nil
+	}
+
+	if err == nil {
+		err = &net.AddrError{Err: "no suitable address", Addr: host}
+	}
+
+	return nil, err
+}
+
+// DialContext connects to the address, resolving the host name with the
+// cache. The resolved addresses are tried one by one.
+func (c *Cache) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+
+	if !c.caching(resolverFromContext(ctx)) || net.ParseIP(host) != nil {
+		return c.options.Dial(ctx, network, addr)
+	}
+
+	addrs, err := c.LookupIPAddr(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, a := range addrs {
+		if network == "tcp4" && a.IP.To4() == nil || network == "tcp6" && a.IP.To4() != nil {
+			continue
+		}
+
+		var conn net.Conn
+		conn, err = c.options.Dial(ctx, network, net.JoinHostPort(a.String(), port))
+		if err == nil || ctx.Err() != nil {
+			return conn, err
+		}
+	}
+
+	if err == nil {
+		err = &net.AddrError{Err: "no suitable address", Addr: host}
+	}
+
+	return nil, err
+}
@@ -0,0 +1,265 @@
+package dnscache
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/zalando/skipper/metrics/metricstest"
+)
+
+type testResolver struct {
+	mu      sync.Mutex
+	addrs   map[string][]net.IPAddr
+	fail    bool
+	lookups int
+	block   chan struct{}
+}
+
+func (r *testResolver) LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error) {
+	if r.block != nil {
+		<-r.block
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.lookups++
+	if r.fail {
+		return nil, &net.DNSError{Err: "server misbehaving", Name: host, IsTemporary: true}
+	}
+
+	return r.addrs[host], nil
+}
+
+func (r *testResolver) setFail(fail bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.fail = fail
+}
+
+func ipAddrs(ips ...string) []net.IPAddr {
+	var addrs []net.IPAddr
+	for _, ip := range ips {
+		addrs = append(addrs, net.IPAddr{IP: net.ParseIP(ip)})
+	}
+
+	return addrs
+}
+
+func newTestCache(r Resolver, ttl time.Duration) (*Cache, *metricstest.MockMetrics, *time.Time) {
+	m := &metricstest.MockMetrics{}
+	c := New(Options{TTL: ttl, StaleTTL: time.Minute, Resolver: r, Metrics: m})
+	now := time.Now()
+	c.now = func() time.Time { return now }
+	return c, m, &now
+}
+
+func TestLookup(t *testing.T) {
+	r := &testResolver{addrs: map[string][]net.IPAddr{"app.example.org": ipAddrs("10.0.0.1")}}
+	c, m, now := newTestCache(r, 10*time.Second)
+	ctx := context.Background()
+
+	lookup := func() error {
+		addrs, err := c.LookupIPAddr(ctx, "app.example.org")
+		if err == nil && (len(addrs) != 1 || addrs[0].String() != "10.0.0.1") {
+			t.Errorf("unexpected addresses: %v", addrs)
+		}
+
+		return err
+	}
+
+	if err := lookup(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := lookup(); err != nil || r.lookups != 1 {
+		t.Fatalf("failed to cache: %v, lookups: %d", err, r.lookups)
+	}
+
+	r.setFail(true)
+	*now = now.Add(30 * time.Second)
+	if err := lookup(); err != nil {
+		t.Fatalf("failed to serve stale: %v", err)
+	}
+
+	if r.lookups != 2 {
+		t.Errorf("failed to resolve the expired entry, lookups: %d", r.lookups)
+	}
+
+	*now = now.Add(time.Minute)
+	if err := lookup(); err == nil {
+		t.Error("failed to fail after the stale TTL")
+	}
+
+	m.WithCounters(func(counters map[string]int64) {
+		for key, expected := range map[string]int64{
+			"dnscache.hit":     1,
+			"dnscache.miss":    3,
+			"dnscache.failure": 2,
+			"dnscache.stale":   1,
+		} {
+			if counters[key] != expected {
+				t.Errorf("unexpected %s: %d, expected: %d", key, counters[key], expected)
+			}
+		}
+	})
+}
+
+func TestNotFound(t *testing.T) {
+	c, _, _ := newTestCache(&testResolver{}, time.Second)
+	if _, err := c.LookupIPAddr(context.Background(), "missing.example.org"); err == nil {
+		t.Error("failed to fail")
+	}
+}
+
+func TestLookupWithoutCache(t *testing.T) {
+	r := &testResolver{addrs: map[string][]net.IPAddr{"app.example.org": ipAddrs("10.0.0.1")}}
+	c, _, _ := newTestCache(r, 0)
+
+	for i := 0; i < 2; i++ {
+		if _, err := c.LookupIPAddr(context.Background(), "app.example.org"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if r.lookups != 2 {
+		t.Errorf("expected resolving every time, lookups: %d", r.lookups)
+	}
+
+	r.setFail(true)
+	if _, err := c.LookupIPAddr(context.Background(), "app.example.org"); err == nil {
+		t.Error("failed to fail without cache")
+	}
+}
+
+func TestConcurrentLookups(t *testing.T) {
+	r := &testResolver{
+		addrs: map[string][]net.IPAddr{"app.example.org": ipAddrs("10.0.0.1")},
+		block: make(chan struct{}),
+	}
+
+	c, _, _ := newTestCache(r, time.Second)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := c.LookupIPAddr(context.Background(), "app.example.org"); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+
+	// lets the goroutines wait for the first lookup
+	time.Sleep(10 * time.Millisecond)
+	close(r.block)
+	wg.Wait()
+
+	if r.lookups != 1 {
+		t.Errorf("unexpected number of lookups: %d", r.lookups)
+	}
+}
+
+func TestDialContext(t *testing.T) {
+	r := &testResolver{addrs: map[string][]net.IPAddr{"app.example.org": ipAddrs("10.0.0.1", "10.0.0.2", "::1")}}
+
+	var dialed []string
+	c := New(Options{
+		TTL:      time.Second,
+		Resolver: r,
+		Metrics:  &metricstest.MockMetrics{},
+		Dial: func(_ context.Context, network, addr string) (net.Conn, error) {
+			dialed = append(dialed, network+"/"+addr)
+			if addr != "10.0.0.2:80" {
+				return nil, errors.New("connection refused")
+			}
+
+			conn, _ := net.Pipe()
+			return conn, nil
+		},
+	})
+
+	conn, err := c.DialContext(context.Background(), "tcp4", "app.example.org:80")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	conn.Close()
+	expected := []string{"tcp4/10.0.0.1:80", "tcp4/10.0.0.2:80"}
+	if len(dialed) != len(expected) || dialed[0] != expected[0] || dialed[1] != expected[1] {
+		t.Errorf("unexpected dials: %v, expected: %v", dialed, expected)
+	}
+
+	dialed = nil
+	if _, err := c.DialContext(context.Background(), "tcp6", "app.example.org:80"); err == nil {
+		t.Error("failed to fail")
+	}
+
+	if len(dialed) != 1 || dialed[0] != "tcp6/[::1]:80" {
+		t.Errorf("unexpected dials: %v", dialed)
+	}
+
+	dialed = nil
+	c.DialContext(context.Background(), "tcp", "10.0.0.3:80")
+	if len(dialed) != 1 || dialed[0] != "tcp/10.0.0.3:80" || r.lookups != 1 {
+		t.Errorf("unexpected dials of an IP address: %v", dialed)
+	}
+}
+
+func TestDialWithoutCache(t *testing.T) {
+	r := &testResolver{}
+
+	var dialed string
+	c := New(Options{
+		Resolver: r,
+		Dial: func(_ context.Context, _, addr string) (net.Conn, error) {
+			dialed = addr
+			return nil, errors.New("connection refused")
+		},
+	})
+
+	c.DialContext(context.Background(), "tcp", "app.example.org:80")
+	if dialed != "app.example.org:80" || r.lookups != 0 {
+		t.Errorf("unexpected dial: %s, lookups: %d", dialed, r.lookups)
+	}
+}
+
+func TestResolverOverride(t *testing.T) {
+	// a DNS server that doesn't respond
+	server, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer server.Close()
+
+	var dialed []string
+	var mu sync.Mutex
+	c := New(Options{
+		Resolver: &testResolver{addrs: map[string][]net.IPAddr{"app.example.org": ipAddrs("10.0.0.1")}},
+		Metrics:  &metricstest.MockMetrics{},
+		Dial: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			mu.Lock()
+			dialed = append(dialed, addr)
+			mu.Unlock()
+			return (&net.Dialer{}).DialContext(ctx, network, addr)
+		},
+	})
+
+	ctx, cancel := context.WithTimeout(WithResolver(context.Background(), server.LocalAddr().String()), 100*time.Millisecond)
+	defer cancel()
+
+	if _, err := c.LookupIPAddr(ctx, "app.example.org"); err == nil {
+		t.Error("failed to use the resolver override")
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(dialed) == 0 || dialed[0] != server.LocalAddr().String() {
+		t.Errorf("failed to dial the DNS server: %v", dialed)
+	}
+}
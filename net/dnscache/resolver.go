@@ -0,0 +1,192 @@
+package dnscache
+
+import (
+	"context"
+	"errors"
+	"net"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+const queryTimeout = 2 * time.Second
+
+// TTLResolver is implemented by the resolvers that report how long the
+// resolved addresses are valid.
+type TTLResolver interface {
+	Resolver
+
+	// LookupIPAddrTTL returns the addresses of the host, and the lowest
+	// TTL of the DNS records used to resolve them.
+	LookupIPAddrTTL(ctx context.Context, host string) ([]net.IPAddr, time.Duration, error)
+}
+
+// ServerResolver resolves the names with the configured DNS servers,
+// instead of the ones of the host. The servers are tried in order, until
+// one of them responds. The names are resolved as fully qualified names,
+// without the search domains of the host.
+type ServerResolver struct {
+	servers []string
+	dial    DialFunc
+}
+
+var errNoServers = errors.New("no DNS servers")
+
+// ServerAddress returns the address of a DNS server in the host:port
+// format, given as an IP address, optionally with the port, which
+// defaults to 53.
+func ServerAddress(s string) (string, bool) {
+	host, port, err := net.SplitHostPort(s)
+	if err != nil {
+		host, port = s, "53"
+	}
+
+	if net.ParseIP(host) == nil {
+		return "", false
+	}
+
+	return net.JoinHostPort(host, port), true
+}
+
+// NewServerResolver creates a resolver using the DNS servers at the given
+// addresses, in the host:port format. The connections to the servers are
+// opened with dial, defaults to (&net.Dialer{}).DialContext.
+func NewServerResolver(servers []string, dial DialFunc) *ServerResolver {
+	if dial == nil {
+		dial = (&net.Dialer{}).DialContext
+	}
+
+	return &ServerResolver{servers: servers, dial: dial}
+}
+
+func (r *ServerResolver) exchange(ctx context.Context, network, server string, m *dns.Msg) (*dns.Msg, error) {
+	conn, err := r.dial(ctx, network, server)
+	if err != nil {
+		return nil, err
+	}
+
+	defer conn.Close()
+
+	// not waiting for a single server until the deadline of the lookup,
+	// so that the next ones can be tried
+	deadline := time.Now().Add(queryTimeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+
+	conn.SetDeadline(deadline)
+	co := &dns.Conn{Conn: conn}
+	if err := co.WriteMsg(m); err != nil {
+		return nil, err
+	}
+
+	for {
+		rsp, err := co.ReadMsg()
+		if err != nil {
+			return nil, err
+		}
+
+		// late responses of earlier queries are skipped
+		if rsp.Id == m.Id {
+			return rsp, nil
+		}
+	}
+}
+
+func (r *ServerResolver) query(ctx context.Context, server, host string, qtype uint16) (*dns.Msg, error) {
+	m := new(dns.Msg)
+	m.SetQuestion(dns.Fqdn(host), qtype)
+	rsp, err := r.exchange(ctx, "udp", server, m)
+	if err == nil && rsp.Truncated {
+		rsp, err = r.exchange(ctx, "tcp", server, m)
+	}
+
+	return rsp, err
+}
+
+// collects the addresses of the answer, and the lowest TTL of the
+// records, including the CNAME records
+func answer(rsp *dns.Msg, addrs []net.IPAddr, ttl uint32) ([]net.IPAddr, uint32) {
+	for _, rr := range rsp.Answer {
+		switch v := rr.(type) {
+		case *dns.A:
+			addrs = append(addrs, net.IPAddr{IP: v.A})
+		case *dns.AAAA:
+			addrs = append(addrs, net.IPAddr{IP: v.AAAA})
+		case *dns.CNAME:
+		default:
+			continue
+		}
+
+		if rr.Header().Ttl < ttl {
+			ttl = rr.Header().Ttl
+		}
+	}
+
+	return addrs, ttl
+}
+
+func (r *ServerResolver) lookup(ctx context.Context, server, host string) ([]net.IPAddr, time.Duration, error) {
+	var (
+		addrs    []net.IPAddr
+		ttl      uint32 = 1<<32 - 1
+		notFound bool
+	)
+
+	for _, qtype := range []uint16{dns.TypeA, dns.TypeAAAA} {
+		rsp, err := r.query(ctx, server, host, qtype)
+		if err != nil {
+			return nil, 0, &net.DNSError{Err: err.Error(), Name: host, Server: server, IsTemporary: true}
+		}
+
+		switch rsp.Rcode {
+		case dns.RcodeSuccess:
+			addrs, ttl = answer(rsp, addrs, ttl)
+		case dns.RcodeNameError:
+			notFound = true
+		default:
+			return nil, 0, &net.DNSError{Err: dns.RcodeToString[rsp.Rcode], Name: host, Server: server, IsTemporary: true}
+		}
+	}
+
+	if len(addrs) == 0 {
+		if notFound {
+			return nil, 0, &net.DNSError{Err: "no such host", Name: host, Server: server, IsNotFound: true}
+		}
+
+		return nil, 0, &net.DNSError{Err: "no suitable address", Name: host, Server: server}
+	}
+
+	return addrs, time.Duration(ttl) * time.Second, nil
+}
+
+// LookupIPAddrTTL returns the addresses of the host, and the lowest TTL
+// of the DNS records used to resolve them. When a server fails to
+// respond, the next one is tried.
+func (r *ServerResolver) LookupIPAddrTTL(ctx context.Context, host string) ([]net.IPAddr, time.Duration, error) {
+	err := errNoServers
+	for _, s := range r.servers {
+		var (
+			addrs []net.IPAddr
+			ttl   time.Duration
+		)
+
+		addrs, ttl, err = r.lookup(ctx, s, host)
+		if err == nil {
+			return addrs, ttl, nil
+		}
+
+		var dnsErr *net.DNSError
+		if errors.As(err, &dnsErr) && !dnsErr.IsTemporary || ctx.Err() != nil {
+			break
+		}
+	}
+
+	return nil, 0, err
+}
+
+// LookupIPAddr returns the addresses of the host.
+func (r *ServerResolver) LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error) {
+	addrs, _, err := r.LookupIPAddrTTL(ctx, host)
+	return addrs, err
+}
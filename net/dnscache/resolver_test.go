@@ -0,0 +1,150 @@
+package dnscache
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+func startDNSServer(t *testing.T) string {
+	mux := dns.NewServeMux()
+	mux.HandleFunc(".", func(w dns.ResponseWriter, r *dns.Msg) {
+		rsp := new(dns.Msg)
+		q := r.Question[0]
+		if q.Name != "app.example.org." {
+			rsp.SetRcode(r, dns.RcodeNameError)
+			w.WriteMsg(rsp)
+			return
+		}
+
+		rsp.SetReply(r)
+		if q.Qtype == dns.TypeA {
+			rsp.Answer = []dns.RR{
+				&dns.CNAME{
+					Hdr:    dns.RR_Header{Name: q.Name, Rrtype: dns.TypeCNAME, Class: dns.ClassINET, Ttl: 300},
+					Target: "lb.example.org.",
+				},
+				&dns.A{
+					Hdr: dns.RR_Header{Name: "lb.example.org.", Rrtype: dns.TypeA, Class: dns.ClassINET, Ttl: 30},
+					A:   net.IPv4(10, 0, 0, 1),
+				},
+			}
+		}
+
+		w.WriteMsg(rsp)
+	})
+
+	ready := make(chan error, 1)
+	server := &dns.Server{
+		Addr:              "127.0.0.1:0",
+		Net:               "udp",
+		Handler:           mux,
+		NotifyStartedFunc: func() { ready <- nil },
+	}
+
+	go func() { ready <- server.ListenAndServe() }()
+	if err := <-ready; err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() { server.Shutdown() })
+	return server.PacketConn.LocalAddr().String()
+}
+
+// returns the address of a closed UDP port
+func closedPort(t *testing.T) string {
+	c, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	address := c.LocalAddr().String()
+	c.Close()
+	return address
+}
+
+func TestServerAddress(t *testing.T) {
+	for _, tt := range []struct {
+		server, address string
+	}{
+		{"10.0.0.53", "10.0.0.53:53"},
+		{"10.0.0.53:5353", "10.0.0.53:5353"},
+		{"fd00::53", "[fd00::53]:53"},
+		{"[fd00::53]:5353", "[fd00::53]:5353"},
+		{"dns.example.org", ""},
+		{"", ""},
+	} {
+		address, ok := ServerAddress(tt.server)
+		if address != tt.address || ok != (tt.address != "") {
+			t.Errorf("%s: unexpected address: %s, %v", tt.server, address, ok)
+		}
+	}
+}
+
+func TestServerResolver(t *testing.T) {
+	r := NewServerResolver([]string{closedPort(t), startDNSServer(t)}, nil)
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	addrs, ttl, err := r.LookupIPAddrTTL(ctx, "app.example.org")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(addrs) != 1 || !addrs[0].IP.Equal(net.IPv4(10, 0, 0, 1)) {
+		t.Errorf("unexpected addresses: %v", addrs)
+	}
+
+	if ttl != 30*time.Second {
+		t.Errorf("unexpected TTL: %v", ttl)
+	}
+
+	_, err = r.LookupIPAddr(ctx, "unknown.example.org")
+	var dnsErr *net.DNSError
+	if !errors.As(err, &dnsErr) || !dnsErr.IsNotFound {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	if _, err := NewServerResolver(nil, nil).LookupIPAddr(ctx, "app.example.org"); err == nil {
+		t.Error("failed to fail without servers")
+	}
+}
+
+func TestRespectTTL(t *testing.T) {
+	server := startDNSServer(t)
+	c := New(Options{RespectTTL: true, StaleTTL: time.Minute, Resolver: NewServerResolver([]string{server}, nil)})
+	now := time.Now()
+	c.now = func() time.Time { return now }
+
+	ctx := context.Background()
+	if _, err := c.LookupIPAddr(ctx, "app.example.org"); err != nil {
+		t.Fatal(err)
+	}
+
+	k := key{host: "app.example.org"}
+	if e := c.entries[k]; e == nil || e.ttl != 30*time.Second {
+		t.Fatalf("failed to cache with the TTL of the records: %v", e)
+	}
+
+	// the overrides are cached with the TTL of the records, too
+	if _, err := c.LookupIPAddr(WithResolver(ctx, server), "app.example.org"); err != nil {
+		t.Fatal(err)
+	}
+
+	if e := c.entries[key{server: server, host: "app.example.org"}]; e == nil || e.ttl != 30*time.Second {
+		t.Errorf("failed to cache the override with the TTL of the records: %v", e)
+	}
+
+	c = New(Options{StaleTTL: time.Minute, Resolver: NewServerResolver([]string{server}, nil)})
+	if _, err := c.LookupIPAddr(ctx, "app.example.org"); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(c.entries) != 0 {
+		t.Error("unexpected caching without the TTL and respecting the TTL of the records")
+	}
+}
@@ -31,6 +31,23 @@ func LoopbackNames(t *testing.T, first string, rest ...string) {
 	})
 }
 
+// Starts a DNS server that resolves the configured names to 127.0.0.1,
+// and fails to resolve any other name, and returns its address. Uses
+// t.Cleanup to stop the server after the test.
+func LoopbackServer(t *testing.T, first string, rest ...string) string {
+	mux := dns.NewServeMux()
+	mux.HandleFunc(".", newDnsHandler(append(rest, first)))
+
+	s, err := startServer(mux)
+	if err != nil {
+		t.Fatal(err)
+		return ""
+	}
+
+	t.Cleanup(func() { s.Shutdown() })
+	return s.PacketConn.LocalAddr().String()
+}
+
 type dnsHandler struct {
 	names map[string]struct{}
 }
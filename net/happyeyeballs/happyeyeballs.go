@@ -0,0 +1,216 @@
+/*
+Package happyeyeballs provides a dialer for dual-stack hosts, that
+connects to the resolved addresses of both address families in parallel,
+as described by RFC 8305, Happy Eyeballs Version 2.
+
+The addresses are ordered by alternating the address families, starting
+with the preferred one. The connection attempts are started one by one,
+waiting for the fallback delay between them, or less when an attempt
+fails. The first established connection is used, and the other attempts
+are canceled. This way a broken network path of one of the address
+families delays the connections only by the fallback delay, instead of
+the whole dial timeout.
+*/
+package happyeyeballs
+
+import (
+	"context"
+	"net"
+	"time"
+)
+
+// DefaultFallbackDelay is used when Options.FallbackDelay is not set. It
+// is the recommended connection attempt delay of RFC 8305.
+const DefaultFallbackDelay = 250 * time.Millisecond
+
+// Resolver is implemented by *net.Resolver.
+type Resolver interface {
+	LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error)
+}
+
+// DialFunc is the signature of net.Dialer.DialContext.
+type DialFunc func(ctx context.Context, network, addr string) (net.Conn, error)
+
+// Options for the dialer.
+type Options struct {
+
+	// PreferIPv4 makes the dialer try the IPv4 addresses first. By
+	// default, the IPv6 addresses are tried first.
+	PreferIPv4 bool
+
+	// FallbackDelay is the time waited for a connection attempt, before
+	// starting the next one. Defaults to DefaultFallbackDelay.
+	FallbackDelay time.Duration
+
+	// Resolver resolves the host names. Defaults to net.DefaultResolver.
+	Resolver Resolver
+
+	// Dial is used to connect to the resolved addresses. Defaults to
+	// (&net.Dialer{}).DialContext.
+	Dial DialFunc
+}
+
+// Dialer connects to the hosts with the Happy Eyeballs algorithm.
+type Dialer struct {
+	options Options
+}
+
+type result struct {
+	conn net.Conn
+	err  error
+}
+
+// New creates a dialer.
+func New(o Options) *Dialer {
+	if o.FallbackDelay <= 0 {
+		o.FallbackDelay = DefaultFallbackDelay
+	}
+
+	if o.Resolver == nil {
+		o.Resolver = net.DefaultResolver
+	}
+
+	if o.Dial == nil {
+		o.Dial = (&net.Dialer{}).DialContext
+	}
+
+	return &Dialer{options: o}
+}
+
+// sortAddrs filters the addresses by the network, and orders them by
+// alternating the address families, starting with the preferred one
+func sortAddrs(network string, addrs []net.IPAddr, preferIPv4 bool) []net.IPAddr {
+	var primary, fallback []net.IPAddr
+	for _, a := range addrs {
+		ipv4 := a.IP.To4() != nil
+		if network == "tcp4" && !ipv4 || network == "tcp6" && ipv4 {
+			continue
+		}
+
+		if ipv4 == preferIPv4 {
+			primary = append(primary, a)
+		} else {
+			fallback = append(fallback, a)
+		}
+	}
+
+	sorted := make([]net.IPAddr, 0, len(primary)+len(fallback))
+	for i := 0; i < len(primary) || i < len(fallback); i++ {
+		if i < len(primary) {
+			sorted = append(sorted, primary[i])
+		}
+
+		if i < len(fallback) {
+			sorted = append(sorted, fallback[i])
+		}
+	}
+
+	return sorted
+}
+
+// closes the connections of the attempts finishing after the race was
+// decided
+func drain(results <-chan result, pending int) {
+	for ; pending > 0; pending-- {
+		if r := <-results; r.conn != nil {
+			r.conn.Close()
+		}
+	}
+}
+
+// DialContext connects to the address, with the host name resolved to
+// the addresses of both families. The IP addresses are dialed directly.
+func (d *Dialer) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+
+	if net.ParseIP(host) != nil {
+		return d.options.Dial(ctx, network, addr)
+	}
+
+	resolved, err := d.options.Resolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+
+	addrs := sortAddrs(network, resolved, d.options.PreferIPv4)
+	if len(addrs) == 0 {
+		return nil, &net.AddrError{Err: "no suitable address", Addr: host}
+	}
+
+	if len(addrs) == 1 {
+		return d.options.Dial(ctx, network, net.JoinHostPort(addrs[0].String(), port))
+	}
+
+	return d.race(ctx, network, addrs, port)
+}
+
+func (d *Dialer) race(ctx context.Context, network string, addrs []net.IPAddr, port string) (net.Conn, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// buffered, so that the attempts never block
+	results := make(chan result, len(addrs))
+	var next, pending int
+	start := func() {
+		a := net.JoinHostPort(addrs[next].String(), port)
+		next++
+		pending++
+		go func() {
+			conn, err := d.options.Dial(ctx, network, a)
+			results <- result{conn: conn, err: err}
+		}()
+	}
+
+	var firstErr error
+	start()
+	for {
+		var (
+			timer    *time.Timer
+			fallback <-chan time.Time
+		)
+
+		if next < len(addrs) {
+			timer = time.NewTimer(d.options.FallbackDelay)
+			fallback = timer.C
+		}
+
+		select {
+		case r := <-results:
+			pending--
+			if r.err == nil {
+				stopTimer(timer)
+				go drain(results, pending)
+				return r.conn, nil
+			}
+
+			if firstErr == nil {
+				firstErr = r.err
+			}
+
+			if next < len(addrs) {
+				// the next attempt is started without waiting for the
+				// rest of the delay
+				start()
+			} else if pending == 0 {
+				return nil, firstErr
+			}
+		case <-fallback:
+			start()
+		case <-ctx.Done():
+			stopTimer(timer)
+			go drain(results, pending)
+			return nil, ctx.Err()
+		}
+
+		stopTimer(timer)
+	}
+}
+
+func stopTimer(t *time.Timer) {
+	if t != nil {
+		t.Stop()
+	}
+}
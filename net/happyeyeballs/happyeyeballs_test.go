@@ -0,0 +1,282 @@
+package happyeyeballs
+
+import (
+	"context"
+	"errors"
+	"net"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+type testResolver map[string][]net.IPAddr
+
+func (r testResolver) LookupIPAddr(_ context.Context, host string) ([]net.IPAddr, error) {
+	addrs, ok := r[host]
+	if !ok {
+		return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+	}
+
+	return addrs, nil
+}
+
+type behavior int
+
+const (
+	connect behavior = iota
+	fail
+	hang
+)
+
+// testDial simulates the connections to the addresses, and records the
+// dialed addresses and the closed connections
+type testDial struct {
+	mu        sync.Mutex
+	behaviors map[string]behavior
+	delays    map[string]time.Duration
+	dialed    []string
+	closed    []string
+}
+
+type testConn struct {
+	net.Conn
+	addr string
+	dial *testDial
+}
+
+func (c *testConn) Close() error {
+	c.dial.mu.Lock()
+	defer c.dial.mu.Unlock()
+	c.dial.closed = append(c.dial.closed, c.addr)
+	return nil
+}
+
+func (d *testDial) dial(ctx context.Context, _, addr string) (net.Conn, error) {
+	d.mu.Lock()
+	d.dialed = append(d.dialed, addr)
+	b := d.behaviors[addr]
+	delay := d.delays[addr]
+	d.mu.Unlock()
+
+	if delay > 0 {
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	switch b {
+	case fail:
+		return nil, errors.New("connection refused: " + addr)
+	case hang:
+		<-ctx.Done()
+		return nil, ctx.Err()
+	default:
+		return &testConn{addr: addr, dial: d}, nil
+	}
+}
+
+func (d *testDial) dialedAddrs() []string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return append([]string(nil), d.dialed...)
+}
+
+func ipAddrs(ips ...string) []net.IPAddr {
+	var addrs []net.IPAddr
+	for _, ip := range ips {
+		addrs = append(addrs, net.IPAddr{IP: net.ParseIP(ip)})
+	}
+
+	return addrs
+}
+
+func ipStrings(addrs []net.IPAddr) []string {
+	var s []string
+	for _, a := range addrs {
+		s = append(s, a.String())
+	}
+
+	return s
+}
+
+func TestSortAddrs(t *testing.T) {
+	addrs := ipAddrs("2001:db8::1", "2001:db8::2", "2001:db8::3", "192.0.2.1", "192.0.2.2")
+
+	for _, tc := range []struct {
+		network    string
+		preferIPv4 bool
+		expected   []string
+	}{{
+		network:  "tcp",
+		expected: []string{"2001:db8::1", "192.0.2.1", "2001:db8::2", "192.0.2.2", "2001:db8::3"},
+	}, {
+		network:    "tcp",
+		preferIPv4: true,
+		expected:   []string{"192.0.2.1", "2001:db8::1", "192.0.2.2", "2001:db8::2", "2001:db8::3"},
+	}, {
+		network:  "tcp4",
+		expected: []string{"192.0.2.1", "192.0.2.2"},
+	}, {
+		network:    "tcp6",
+		preferIPv4: true,
+		expected:   []string{"2001:db8::1", "2001:db8::2", "2001:db8::3"},
+	}} {
+		if got := ipStrings(sortAddrs(tc.network, addrs, tc.preferIPv4)); !reflect.DeepEqual(got, tc.expected) {
+			t.Errorf("%s, prefer IPv4: %v: expected %v, got %v", tc.network, tc.preferIPv4, tc.expected, got)
+		}
+	}
+}
+
+func TestDial(t *testing.T) {
+	const (
+		v6 = "[2001:db8::1]:80"
+		v4 = "192.0.2.1:80"
+	)
+
+	resolver := testResolver{"dual.example.org": ipAddrs("2001:db8::1", "192.0.2.1")}
+
+	for _, tc := range []struct {
+		name       string
+		addr       string
+		preferIPv4 bool
+		behaviors  map[string]behavior
+		delays     map[string]time.Duration
+		expected   string
+		dialed     []string
+		fails      bool
+		maxTime    time.Duration
+	}{{
+		name:     "preferred family connects",
+		addr:     "dual.example.org:80",
+		expected: v6,
+		dialed:   []string{v6},
+	}, {
+		name:       "preferred IPv4 connects",
+		addr:       "dual.example.org:80",
+		preferIPv4: true,
+		expected:   v4,
+		dialed:     []string{v4},
+	}, {
+		name:      "falls back after the delay, when the preferred family hangs",
+		addr:      "dual.example.org:80",
+		behaviors: map[string]behavior{v6: hang},
+		expected:  v4,
+		dialed:    []string{v6, v4},
+		maxTime:   time.Second,
+	}, {
+		name:      "falls back immediately, when the preferred family fails",
+		addr:      "dual.example.org:80",
+		behaviors: map[string]behavior{v6: fail},
+		expected:  v4,
+		dialed:    []string{v6, v4},
+		maxTime:   40 * time.Millisecond,
+	}, {
+		name:     "the first connection wins",
+		addr:     "dual.example.org:80",
+		delays:   map[string]time.Duration{v6: 300 * time.Millisecond},
+		expected: v4,
+		dialed:   []string{v6, v4},
+	}, {
+		name:      "all fail",
+		addr:      "dual.example.org:80",
+		behaviors: map[string]behavior{v6: fail, v4: fail},
+		dialed:    []string{v6, v4},
+		fails:     true,
+	}, {
+		name:     "IP address dialed directly",
+		addr:     v4,
+		expected: v4,
+		dialed:   []string{v4},
+	}, {
+		name:  "unknown host",
+		addr:  "unknown.example.org:80",
+		fails: true,
+	}} {
+		t.Run(tc.name, func(t *testing.T) {
+			td := &testDial{behaviors: tc.behaviors, delays: tc.delays}
+			d := New(Options{
+				PreferIPv4:    tc.preferIPv4,
+				FallbackDelay: 100 * time.Millisecond,
+				Resolver:      resolver,
+				Dial:          td.dial,
+			})
+
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			start := time.Now()
+			conn, err := d.DialContext(ctx, "tcp", tc.addr)
+			elapsed := time.Since(start)
+			if tc.fails {
+				if err == nil {
+					t.Fatal("failed to fail")
+				}
+			} else if err != nil {
+				t.Fatal(err)
+			} else if addr := conn.(*testConn).addr; addr != tc.expected {
+				t.Errorf("expected connection to %s, got %s", tc.expected, addr)
+			}
+
+			if tc.maxTime > 0 && elapsed > tc.maxTime {
+				t.Errorf("dial took too long: %v", elapsed)
+			}
+
+			if dialed := td.dialedAddrs(); !reflect.DeepEqual(dialed, tc.dialed) {
+				t.Errorf("expected dialed %v, got %v", tc.dialed, dialed)
+			}
+		})
+	}
+}
+
+func TestLateConnectionsClosed(t *testing.T) {
+	const (
+		v6 = "[2001:db8::1]:80"
+		v4 = "192.0.2.1:80"
+	)
+
+	// the IPv6 connection succeeds right after the IPv4 one, but it is
+	// not canceled by the test dial
+	td := &testDial{delays: map[string]time.Duration{v6: 60 * time.Millisecond}}
+	d := New(Options{
+		FallbackDelay: 50 * time.Millisecond,
+		Resolver:      testResolver{"dual.example.org": ipAddrs("2001:db8::1", "192.0.2.1")},
+		Dial: func(_ context.Context, network, addr string) (net.Conn, error) {
+			return td.dial(context.Background(), network, addr)
+		},
+	})
+
+	conn, err := d.DialContext(context.Background(), "tcp", "dual.example.org:80")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if addr := conn.(*testConn).addr; addr != v4 {
+		t.Fatalf("expected %s, got %s", v4, addr)
+	}
+
+	time.Sleep(100 * time.Millisecond)
+	td.mu.Lock()
+	defer td.mu.Unlock()
+	if !reflect.DeepEqual(td.closed, []string{v6}) {
+		t.Errorf("expected the late connection closed, got %v", td.closed)
+	}
+}
+
+func TestDialCanceled(t *testing.T) {
+	td := &testDial{behaviors: map[string]behavior{"[2001:db8::1]:80": hang, "192.0.2.1:80": hang}}
+	d := New(Options{
+		FallbackDelay: 10 * time.Millisecond,
+		Resolver:      testResolver{"dual.example.org": ipAddrs("2001:db8::1", "192.0.2.1")},
+		Dial:          td.dial,
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	if _, err := d.DialContext(ctx, "tcp", "dual.example.org:80"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected deadline exceeded, got %v", err)
+	}
+}
@@ -31,7 +31,14 @@ func parse(addr string) net.IP {
 // Example:
 //
 //	X-Forwarded-For: client, proxy1, proxy2
+//
+// When the client IP was resolved by the ClientIPHandler, then the
+// resolved address is returned.
 func RemoteHost(r *http.Request) net.IP {
+	if ip, ok := ClientIP(r); ok {
+		return ip
+	}
+
 	ffs := r.Header.Get("X-Forwarded-For")
 	ff, _, _ := strings.Cut(ffs, ",")
 	if ffh := parse(ff); ffh != nil {
@@ -49,7 +56,14 @@ func RemoteHost(r *http.Request) net.IP {
 // Example:
 //
 //	X-Forwarded-For: ip-address-1, ip-address-2, client-ip-address
+//
+// When the client IP was resolved by the ClientIPHandler, then the
+// resolved address is returned.
 func RemoteHostFromLast(r *http.Request) net.IP {
+	if ip, ok := ClientIP(r); ok {
+		return ip
+	}
+
 	ffs := r.Header.Get("X-Forwarded-For")
 	ffa := strings.Split(ffs, ",")
 	ff := ffa[len(ffa)-1]
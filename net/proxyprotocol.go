@@ -0,0 +1,287 @@
+package net
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/zalando/skipper/metrics"
+)
+
+const (
+	defaultProxyProtocolTimeout = 10 * time.Second
+
+	// the maximum length of a v1 header, including the CRLF
+	maxProxyProtocolV1Length = 107
+
+	proxyProtocolInvalidKey = "proxyprotocol.invalid"
+)
+
+var (
+	proxyProtocolV1Prefix    = []byte("PROXY ")
+	proxyProtocolV2Signature = []byte("\r\n\r\n\x00\r\nQUIT\n")
+
+	// ErrInvalidProxyProtocolHeader is returned when reading from a
+	// connection with an invalid PROXY protocol header. The HTTP server
+	// responds to it with 400 Bad Request, and closes the connection.
+	ErrInvalidProxyProtocolHeader = errors.New("invalid PROXY protocol header")
+)
+
+// ProxyProtocolOptions are used to create a PROXY protocol listener.
+type ProxyProtocolOptions struct {
+	// TrustedNetworks contains the networks of the load balancers, that
+	// are trusted to send the PROXY protocol header. The connections from
+	// other addresses are used as they are, and they are not checked for
+	// the header.
+	TrustedNetworks IPNets
+
+	// Timeout limits the time of receiving the header after accepting a
+	// connection. Defaults to 10s.
+	Timeout time.Duration
+
+	// Metrics, when set, is used to count the invalid headers.
+	Metrics metrics.Metrics
+}
+
+type proxyProtocolListener struct {
+	net.Listener
+	options ProxyProtocolOptions
+}
+
+// NewProxyProtocolListener wraps a listener, and reads the PROXY protocol
+// v1 or v2 header of the connections accepted from the trusted networks.
+// The remote and local addresses of these connections are the ones
+// reported in the header, e.g. the address of the client behind an L4
+// load balancer, and they are used by the HTTP server as the remote
+// address of the requests.
+//
+// The header is optional: without it, or with the LOCAL command, the
+// addresses of the connection are used. The connections with an invalid
+// header are rejected.
+//
+// The header is read when the remote address or the data of the
+// connection is first accessed, not in Accept, therefore the listener
+// needs to be wrapped by the TLS listener, and not the other way around.
+func NewProxyProtocolListener(l net.Listener, o ProxyProtocolOptions) net.Listener {
+	if o.Timeout <= 0 {
+		o.Timeout = defaultProxyProtocolTimeout
+	}
+
+	return &proxyProtocolListener{Listener: l, options: o}
+}
+
+func (l *proxyProtocolListener) Accept() (net.Conn, error) {
+	c, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+
+	if a, ok := c.RemoteAddr().(*net.TCPAddr); !ok || !l.options.TrustedNetworks.Contain(a.IP) {
+		return c, nil
+	}
+
+	return &proxyProtocolConn{Conn: c, options: &l.options}, nil
+}
+
+type proxyProtocolConn struct {
+	net.Conn
+	options *ProxyProtocolOptions
+
+	once   sync.Once
+	reader *bufio.Reader
+	err    error
+	remote net.Addr
+	local  net.Addr
+}
+
+func (c *proxyProtocolConn) init() {
+	c.once.Do(c.readHeader)
+}
+
+func (c *proxyProtocolConn) readHeader() {
+	c.reader = bufio.NewReader(c.Conn)
+	c.Conn.SetReadDeadline(time.Now().Add(c.options.Timeout))
+	defer c.Conn.SetReadDeadline(time.Time{})
+
+	var err error
+	c.remote, c.local, err = readProxyProtocolHeader(c.reader)
+	if err == nil {
+		return
+	}
+
+	if errors.Is(err, ErrInvalidProxyProtocolHeader) {
+		if c.options.Metrics != nil {
+			c.options.Metrics.IncCounter(proxyProtocolInvalidKey)
+		}
+
+		log.Warnf("%v from %v", err, c.Conn.RemoteAddr())
+	}
+
+	c.err = err
+}
+
+// Read returns the data of the connection following the header.
+func (c *proxyProtocolConn) Read(p []byte) (int, error) {
+	c.init()
+	if c.err != nil {
+		return 0, c.err
+	}
+
+	if c.reader.Buffered() > 0 {
+		return c.reader.Read(p)
+	}
+
+	return c.Conn.Read(p)
+}
+
+// RemoteAddr returns the source address reported in the header.
+func (c *proxyProtocolConn) RemoteAddr() net.Addr {
+	c.init()
+	if c.remote != nil {
+		return c.remote
+	}
+
+	return c.Conn.RemoteAddr()
+}
+
+// LocalAddr returns the destination address reported in the header.
+func (c *proxyProtocolConn) LocalAddr() net.Addr {
+	c.init()
+	if c.local != nil {
+		return c.local
+	}
+
+	return c.Conn.LocalAddr()
+}
+
+// returns the source and the destination addresses of the header, or
+// nil, when there is no header, or it doesn't carry TCP addresses
+func readProxyProtocolHeader(r *bufio.Reader) (net.Addr, net.Addr, error) {
+	b, err := r.Peek(1)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	switch b[0] {
+	case proxyProtocolV1Prefix[0]:
+		if b, _ := r.Peek(len(proxyProtocolV1Prefix)); bytes.Equal(b, proxyProtocolV1Prefix) {
+			return readProxyProtocolV1(r)
+		}
+	case proxyProtocolV2Signature[0]:
+		if b, _ := r.Peek(len(proxyProtocolV2Signature)); bytes.Equal(b, proxyProtocolV2Signature) {
+			return readProxyProtocolV2(r)
+		}
+	}
+
+	return nil, nil, nil
+}
+
+func invalidProxyProtocolHeader(reason string) error {
+	return fmt.Errorf("%w: %s", ErrInvalidProxyProtocolHeader, reason)
+}
+
+func parseProxyProtocolV1Addr(ip, port string, v4 bool) (*net.TCPAddr, bool) {
+	a := net.ParseIP(ip)
+	if a == nil || strings.Contains(ip, ":") == v4 {
+		return nil, false
+	}
+
+	p, err := strconv.ParseUint(port, 10, 16)
+	if err != nil {
+		return nil, false
+	}
+
+	return &net.TCPAddr{IP: a, Port: int(p)}, true
+}
+
+func readProxyProtocolV1(r *bufio.Reader) (net.Addr, net.Addr, error) {
+	line, err := r.ReadSlice('\n')
+	if err != nil || len(line) > maxProxyProtocolV1Length || !bytes.HasSuffix(line, []byte("\r\n")) {
+		return nil, nil, invalidProxyProtocolHeader("v1 header line")
+	}
+
+	fields := strings.Split(string(line[:len(line)-2]), " ")
+	if len(fields) >= 2 && fields[1] == "UNKNOWN" {
+		return nil, nil, nil
+	}
+
+	if len(fields) != 6 || fields[1] != "TCP4" && fields[1] != "TCP6" {
+		return nil, nil, invalidProxyProtocolHeader("v1 protocol")
+	}
+
+	v4 := fields[1] == "TCP4"
+	src, ok := parseProxyProtocolV1Addr(fields[2], fields[4], v4)
+	if !ok {
+		return nil, nil, invalidProxyProtocolHeader("v1 source address")
+	}
+
+	dst, ok := parseProxyProtocolV1Addr(fields[3], fields[5], v4)
+	if !ok {
+		return nil, nil, invalidProxyProtocolHeader("v1 destination address")
+	}
+
+	return src, dst, nil
+}
+
+func readProxyProtocolV2(r *bufio.Reader) (net.Addr, net.Addr, error) {
+	h := make([]byte, 16)
+	if _, err := io.ReadFull(r, h); err != nil {
+		return nil, nil, invalidProxyProtocolHeader("v2 header")
+	}
+
+	if h[12]>>4 != 2 {
+		return nil, nil, invalidProxyProtocolHeader("v2 version")
+	}
+
+	command := h[12] & 0xf
+	if command > 1 {
+		return nil, nil, invalidProxyProtocolHeader("v2 command")
+	}
+
+	// the address block, including the TLVs, that are ignored
+	addrs := make([]byte, binary.BigEndian.Uint16(h[14:]))
+	if _, err := io.ReadFull(r, addrs); err != nil {
+		return nil, nil, invalidProxyProtocolHeader("v2 addresses")
+	}
+
+	// LOCAL command, e.g. health checks of the load balancer
+	if command == 0 {
+		return nil, nil, nil
+	}
+
+	var ipLength int
+	switch h[13] {
+	case 0x11:
+		ipLength = net.IPv4len
+	case 0x21:
+		ipLength = net.IPv6len
+	default:
+		// not TCP, the addresses of the connection are used
+		return nil, nil, nil
+	}
+
+	if len(addrs) < 2*ipLength+4 {
+		return nil, nil, invalidProxyProtocolHeader("v2 address length")
+	}
+
+	src := &net.TCPAddr{
+		IP:   net.IP(append([]byte(nil), addrs[:ipLength]...)),
+		Port: int(binary.BigEndian.Uint16(addrs[2*ipLength:])),
+	}
+
+	dst := &net.TCPAddr{
+		IP:   net.IP(append([]byte(nil), addrs[ipLength:2*ipLength]...)),
+		Port: int(binary.BigEndian.Uint16(addrs[2*ipLength+2:])),
+	}
+
+	return src, dst, nil
+}
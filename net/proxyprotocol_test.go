@@ -0,0 +1,199 @@
+package net
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/zalando/skipper/metrics/metricstest"
+)
+
+func serveProxyProtocol(t *testing.T, trusted string, m *metricstest.MockMetrics) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	nets, err := ParseCIDRs([]string{trusted})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srv := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Remote-Addr", r.RemoteAddr)
+		w.Header().Set("X-Local-Addr", r.Context().Value(http.LocalAddrContextKey).(net.Addr).String())
+	})}
+
+	go srv.Serve(NewProxyProtocolListener(l, ProxyProtocolOptions{TrustedNetworks: nets, Timeout: time.Second, Metrics: m}))
+	t.Cleanup(func() { srv.Close() })
+	return l.Addr().String()
+}
+
+func proxyProtocolV2(command, family byte, addrs ...byte) string {
+	h := append([]byte(nil), proxyProtocolV2Signature...)
+	h = append(h, command, family, byte(len(addrs)>>8), byte(len(addrs)))
+	return string(append(h, addrs...))
+}
+
+func TestProxyProtocol(t *testing.T) {
+	const request = "GET / HTTP/1.1\r\nHost: www.example.org\r\n\r\n"
+
+	for _, tt := range []struct {
+		title   string
+		trusted string
+		header  string
+		remote  string
+		local   string
+		status  int
+		invalid bool
+	}{{
+		title:   "v1 tcp4",
+		trusted: "127.0.0.0/8",
+		header:  "PROXY TCP4 192.0.2.1 198.51.100.1 56324 443\r\n",
+		remote:  "192.0.2.1:56324",
+		local:   "198.51.100.1:443",
+		status:  http.StatusOK,
+	}, {
+		title:   "v1 tcp6",
+		trusted: "127.0.0.0/8",
+		header:  "PROXY TCP6 2001:db8::1 2001:db8::2 56324 443\r\n",
+		remote:  "[2001:db8::1]:56324",
+		local:   "[2001:db8::2]:443",
+		status:  http.StatusOK,
+	}, {
+		title:   "v1 unknown",
+		trusted: "127.0.0.0/8",
+		header:  "PROXY UNKNOWN\r\n",
+		remote:  "127.0.0.1:",
+		status:  http.StatusOK,
+	}, {
+		title:   "v2 tcp4",
+		trusted: "127.0.0.0/8",
+		header:  proxyProtocolV2(0x21, 0x11, 192, 0, 2, 1, 198, 51, 100, 1, 0xdc, 0x04, 0x01, 0xbb),
+		remote:  "192.0.2.1:56324",
+		local:   "198.51.100.1:443",
+		status:  http.StatusOK,
+	}, {
+		title:   "v2 tcp6 with TLV",
+		trusted: "127.0.0.0/8",
+		header: proxyProtocolV2(0x21, 0x21,
+			0x20, 0x01, 0x0d, 0xb8, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 1,
+			0x20, 0x01, 0x0d, 0xb8, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 2,
+			0xdc, 0x04, 0x01, 0xbb,
+			0x04, 0, 1, 0,
+		),
+		remote: "[2001:db8::1]:56324",
+		local:  "[2001:db8::2]:443",
+		status: http.StatusOK,
+	}, {
+		title:   "v2 local",
+		trusted: "127.0.0.0/8",
+		header:  proxyProtocolV2(0x20, 0),
+		remote:  "127.0.0.1:",
+		status:  http.StatusOK,
+	}, {
+		title:   "no header",
+		trusted: "127.0.0.0/8",
+		remote:  "127.0.0.1:",
+		status:  http.StatusOK,
+	}, {
+		title:   "invalid v1 address",
+		trusted: "127.0.0.0/8",
+		header:  "PROXY TCP4 2001:db8::1 198.51.100.1 56324 443\r\n",
+		status:  http.StatusBadRequest,
+		invalid: true,
+	}, {
+		title:   "invalid v2 version",
+		trusted: "127.0.0.0/8",
+		header:  proxyProtocolV2(0x11, 0x11, 192, 0, 2, 1, 198, 51, 100, 1, 0xdc, 0x04, 0x01, 0xbb),
+		status:  http.StatusBadRequest,
+		invalid: true,
+	}, {
+		title:   "short v2 addresses",
+		trusted: "127.0.0.0/8",
+		header:  proxyProtocolV2(0x21, 0x21, 192, 0, 2, 1, 198, 51, 100, 1, 0xdc, 0x04, 0x01, 0xbb),
+		status:  http.StatusBadRequest,
+		invalid: true,
+	}, {
+		title:   "untrusted",
+		trusted: "10.0.0.0/8",
+		header:  "PROXY TCP4 192.0.2.1 198.51.100.1 56324 443\r\n",
+		status:  http.StatusBadRequest,
+	}} {
+		t.Run(tt.title, func(t *testing.T) {
+			m := &metricstest.MockMetrics{}
+			addr := serveProxyProtocol(t, tt.trusted, m)
+
+			c, err := net.Dial("tcp", addr)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			defer c.Close()
+			c.SetDeadline(time.Now().Add(3 * time.Second))
+			if _, err := c.Write([]byte(tt.header + request)); err != nil {
+				t.Fatal(err)
+			}
+
+			rsp, err := http.ReadResponse(bufio.NewReader(c), nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			io.Copy(io.Discard, rsp.Body)
+			rsp.Body.Close()
+			if rsp.StatusCode != tt.status {
+				t.Fatalf("unexpected status: %d", rsp.StatusCode)
+			}
+
+			if tt.status == http.StatusOK && !strings.HasPrefix(rsp.Header.Get("X-Remote-Addr"), tt.remote) {
+				t.Errorf("unexpected remote address, expected: %s, got: %s", tt.remote, rsp.Header.Get("X-Remote-Addr"))
+			}
+
+			if tt.local != "" && rsp.Header.Get("X-Local-Addr") != tt.local {
+				t.Errorf("unexpected local address, expected: %s, got: %s", tt.local, rsp.Header.Get("X-Local-Addr"))
+			}
+
+			m.WithCounters(func(counters map[string]int64) {
+				if (counters[proxyProtocolInvalidKey] == 1) != tt.invalid {
+					t.Errorf("unexpected invalid header counter: %d", counters[proxyProtocolInvalidKey])
+				}
+			})
+		})
+	}
+}
+
+func TestProxyProtocolKeepAlive(t *testing.T) {
+	addr := serveProxyProtocol(t, "127.0.0.0/8", &metricstest.MockMetrics{})
+	c, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer c.Close()
+	c.SetDeadline(time.Now().Add(3 * time.Second))
+	if _, err := c.Write([]byte("PROXY TCP4 192.0.2.1 198.51.100.1 56324 443\r\n")); err != nil {
+		t.Fatal(err)
+	}
+
+	br := bufio.NewReader(c)
+	for i := 0; i < 3; i++ {
+		if _, err := c.Write([]byte("GET / HTTP/1.1\r\nHost: www.example.org\r\n\r\n")); err != nil {
+			t.Fatal(err)
+		}
+
+		rsp, err := http.ReadResponse(br, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rsp.Body.Close()
+		if rsp.Header.Get("X-Remote-Addr") != "192.0.2.1:56324" {
+			t.Errorf("unexpected remote address: %s", rsp.Header.Get("X-Remote-Addr"))
+		}
+	}
+}
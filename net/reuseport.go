@@ -0,0 +1,64 @@
+package net
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+)
+
+// ErrReusePortNotSupported is returned when SO_REUSEPORT is not
+// supported on the platform.
+var ErrReusePortNotSupported = errors.New("SO_REUSEPORT is not supported on this platform")
+
+// ReusePortOptions are used to create multiple listeners on the same
+// address.
+type ReusePortOptions struct {
+
+	// Network of the listeners, e.g. tcp. Same as for net.Listen().
+	Network string
+
+	// Address of the listeners, e.g. :9090. Same as for net.Listen().
+	Address string
+
+	// Acceptors sets the number of the listening sockets. Defaults to
+	// 1.
+	Acceptors int
+}
+
+// ListenReusePort opens multiple sockets on the same address with the
+// SO_REUSEPORT option. The kernel distributes the incoming connections
+// between the sockets. The connections of each socket are expected to
+// be accepted by a separate goroutine, e.g. by calling Serve of the same
+// http.Server with each listener, spreading the work of accepting them
+// across the CPU cores.
+func ListenReusePort(o ReusePortOptions) ([]net.Listener, error) {
+	if o.Acceptors <= 0 {
+		o.Acceptors = 1
+	}
+
+	lc := net.ListenConfig{Control: reusePortControl}
+
+	var listeners []net.Listener
+	address := o.Address
+	for i := 0; i < o.Acceptors; i++ {
+		l, err := lc.Listen(context.Background(), o.Network, address)
+		if err != nil {
+			for _, li := range listeners {
+				li.Close()
+			}
+
+			return nil, fmt.Errorf("failed to open listener %d on %s: %w", i, address, err)
+		}
+
+		// when the port is chosen by the system, the rest of the
+		// sockets need to use the same port
+		if i == 0 {
+			address = l.Addr().String()
+		}
+
+		listeners = append(listeners, l)
+	}
+
+	return listeners, nil
+}
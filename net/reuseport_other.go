@@ -0,0 +1,10 @@
+//go:build !linux && !darwin && !dragonfly && !freebsd && !netbsd && !openbsd
+// +build !linux,!darwin,!dragonfly,!freebsd,!netbsd,!openbsd
+
+package net
+
+import "syscall"
+
+func reusePortControl(string, string, syscall.RawConn) error {
+	return ErrReusePortNotSupported
+}
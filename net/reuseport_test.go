@@ -0,0 +1,81 @@
+package net
+
+import (
+	"io"
+	"net"
+	"runtime"
+	"sync"
+	"testing"
+)
+
+func TestReusePort(t *testing.T) {
+	switch runtime.GOOS {
+	case "linux", "darwin", "dragonfly", "freebsd", "netbsd", "openbsd":
+	default:
+		t.Skip("SO_REUSEPORT is not supported")
+	}
+
+	ls, err := ListenReusePort(ReusePortOptions{Network: "tcp", Address: "127.0.0.1:0", Acceptors: 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(ls) != 4 {
+		t.Fatalf("unexpected number of sockets: %d", len(ls))
+	}
+
+	for _, l := range ls {
+		defer l.Close()
+		if l.Addr().String() != ls[0].Addr().String() {
+			t.Fatalf("unexpected address: %v, expected: %v", l.Addr(), ls[0].Addr())
+		}
+
+		// each socket is accepted independently
+		go func(l net.Listener) {
+			for {
+				c, err := l.Accept()
+				if err != nil {
+					return
+				}
+
+				go func() {
+					defer c.Close()
+					io.Copy(c, c)
+				}()
+			}
+		}(l)
+	}
+
+	const connections = 32
+	var wg sync.WaitGroup
+	for i := 0; i < connections; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			c, err := net.Dial("tcp", ls[0].Addr().String())
+			if err != nil {
+				t.Errorf("Failed to dial: %v", err)
+				return
+			}
+
+			defer c.Close()
+			if _, err := c.Write([]byte("ping")); err != nil {
+				t.Errorf("Failed to write: %v", err)
+				return
+			}
+
+			b := make([]byte, 4)
+			if _, err := io.ReadFull(c, b); err != nil || string(b) != "ping" {
+				t.Errorf("Failed to read: %q, %v", b, err)
+			}
+		}()
+	}
+
+	wg.Wait()
+}
+
+func TestReusePortInvalidAddress(t *testing.T) {
+	if _, err := ListenReusePort(ReusePortOptions{Network: "tcp", Address: ":foo", Acceptors: 2}); err == nil {
+		t.Error("failed to fail")
+	}
+}
@@ -0,0 +1,500 @@
+package net
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/zalando/skipper/metrics"
+)
+
+// StrictParsingLevel defines how the violations found by the strict HTTP
+// parsing are enforced.
+type StrictParsingLevel int
+
+const (
+	// StrictParsingOff disables the strict HTTP parsing.
+	StrictParsingOff StrictParsingLevel = iota
+
+	// StrictParsingLog logs and counts the violations, but the requests
+	// are handled as usual.
+	StrictParsingLog
+
+	// StrictParsingReject rejects the requests with violations with 400
+	// Bad Request, and closes the connection.
+	StrictParsingReject
+)
+
+const (
+	violationObsFold                           = "obs-fold"
+	violationBareLF                            = "bare-lf"
+	violationWhitespaceBeforeColon             = "whitespace-before-colon"
+	violationInvalidHeader                     = "invalid-header"
+	violationMultipleContentLength             = "multiple-content-length"
+	violationInvalidContentLength              = "invalid-content-length"
+	violationInvalidTransferEncoding           = "invalid-transfer-encoding"
+	violationContentLengthWithTransferEncoding = "content-length-with-transfer-encoding"
+
+	strictParsingViolationKey = "strictparsing.violation."
+	strictParsingRejectedKey  = "strictparsing.rejected"
+
+	maxChunkLine = 4096
+)
+
+// ErrStrictParsingRejected is returned when reading a request that has been
+// rejected by the strict HTTP parsing. The HTTP server responds to it with
+// 400 Bad Request.
+var ErrStrictParsingRejected = errors.New("request rejected by strict HTTP parsing")
+
+// ParseStrictParsingLevel parses the strict parsing level from one of the
+// strings: "off", "log" or "reject".
+func ParseStrictParsingLevel(s string) (StrictParsingLevel, error) {
+	switch s {
+	case "", "off":
+		return StrictParsingOff, nil
+	case "log":
+		return StrictParsingLog, nil
+	case "reject":
+		return StrictParsingReject, nil
+	default:
+		return StrictParsingOff, fmt.Errorf("invalid strict parsing level: %s", s)
+	}
+}
+
+func (l StrictParsingLevel) String() string {
+	switch l {
+	case StrictParsingLog:
+		return "log"
+	case StrictParsingReject:
+		return "reject"
+	default:
+		return "off"
+	}
+}
+
+// StrictParsingOptions are used to create a strict parsing listener.
+type StrictParsingOptions struct {
+	// Level defines how the violations are enforced.
+	Level StrictParsingLevel
+
+	// MaxHeaderBytes defines the max size of the request head that is
+	// checked. Larger request heads are passed on unchecked, and they
+	// are expected to be rejected by the HTTP server. Defaults to
+	// http.DefaultMaxHeaderBytes.
+	MaxHeaderBytes int
+
+	// Metrics, when set, is used to count the violations and the
+	// rejected requests.
+	Metrics metrics.Metrics
+}
+
+type strictParsingListener struct {
+	net.Listener
+	options StrictParsingOptions
+}
+
+// NewStrictParsingListener wraps a listener, and inspects the raw HTTP/1.x
+// requests received on its connections, before they are parsed by the HTTP
+// server. It detects the request framing ambiguities that can be used for
+// request smuggling, which the HTTP server would otherwise resolve
+// silently, e.g. a Content-Length together with Transfer-Encoding, or
+// obsolete line folding.
+//
+// The listener needs to receive the plain HTTP traffic, it cannot inspect
+// the connections of a TLS listener. HTTP/2 and upgraded connections are
+// not inspected.
+func NewStrictParsingListener(l net.Listener, o StrictParsingOptions) net.Listener {
+	if o.Level == StrictParsingOff {
+		return l
+	}
+
+	if o.MaxHeaderBytes <= 0 {
+		o.MaxHeaderBytes = http.DefaultMaxHeaderBytes
+	}
+
+	return &strictParsingListener{Listener: l, options: o}
+}
+
+func (l *strictParsingListener) Accept() (net.Conn, error) {
+	c, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+
+	return &strictConn{Conn: c, options: &l.options}, nil
+}
+
+type strictState int
+
+const (
+	stateHead strictState = iota
+	stateBody
+	stateChunkSize
+	stateChunkData
+	stateChunkEnd
+	stateTrailer
+	statePassthrough
+	stateRejected
+)
+
+type strictConn struct {
+	net.Conn
+	options *StrictParsingOptions
+
+	state     strictState
+	remaining int64
+	head      []byte
+	lineStart int
+	line      []byte
+	buf       []byte
+	out       []byte
+	next      []byte
+}
+
+// Read returns the bytes of the connection only after the complete head of
+// a request was received and checked.
+func (c *strictConn) Read(p []byte) (int, error) {
+	for len(c.next) == 0 {
+		if c.state == stateRejected {
+			return 0, ErrStrictParsingRejected
+		}
+
+		if c.buf == nil {
+			c.buf = make([]byte, 4096)
+		}
+
+		n, err := c.Conn.Read(c.buf)
+		c.out = c.out[:0]
+		c.feed(c.buf[:n])
+		if err != nil && !isTimeout(err) && c.state == stateHead && len(c.head) > 0 {
+			// the connection won't deliver the rest of the head,
+			// let the HTTP server handle the partial request
+			c.passthrough()
+		}
+
+		c.next = c.out
+		if len(c.next) == 0 && err != nil {
+			return 0, err
+		}
+	}
+
+	n := copy(p, c.next)
+	c.next = c.next[n:]
+	return n, nil
+}
+
+func isTimeout(err error) bool {
+	var nerr net.Error
+	return errors.As(err, &nerr) && nerr.Timeout()
+}
+
+func (c *strictConn) feed(data []byte) {
+	for len(data) > 0 {
+		switch c.state {
+		case stateHead:
+			data = c.feedHead(data)
+		case stateBody, stateChunkData:
+			n := int64(len(data))
+			if n > c.remaining {
+				n = c.remaining
+			}
+
+			c.out = append(c.out, data[:n]...)
+			data = data[n:]
+			c.remaining -= n
+			if c.remaining == 0 {
+				if c.state == stateBody {
+					c.state = stateHead
+				} else {
+					c.state = stateChunkEnd
+				}
+			}
+		case stateChunkSize, stateChunkEnd, stateTrailer:
+			data = c.feedChunkLine(data)
+		case statePassthrough:
+			c.out = append(c.out, data...)
+			return
+		case stateRejected:
+			return
+		}
+	}
+}
+
+func (c *strictConn) passthrough() {
+	c.out = append(c.out, c.head...)
+	c.head = c.head[:0]
+	c.lineStart = 0
+	c.state = statePassthrough
+}
+
+func (c *strictConn) feedHead(data []byte) []byte {
+	i := bytes.IndexByte(data, '\n')
+	if i < 0 {
+		c.head = append(c.head, data...)
+		if len(c.head) > c.options.MaxHeaderBytes {
+			c.passthrough()
+		}
+
+		return nil
+	}
+
+	c.head = append(c.head, data[:i+1]...)
+	data = data[i+1:]
+	if len(c.head) > c.options.MaxHeaderBytes {
+		c.passthrough()
+		return data
+	}
+
+	line := c.head[c.lineStart:]
+	if len(line) > 2 || len(line) == 2 && line[0] != '\r' {
+		c.lineStart = len(c.head)
+		return data
+	}
+
+	if c.lineStart == 0 {
+		// empty lines before the request line are ignored
+		c.out = append(c.out, c.head...)
+		c.head = c.head[:0]
+		return data
+	}
+
+	c.checkHead()
+	return data
+}
+
+func (c *strictConn) feedChunkLine(data []byte) []byte {
+	i := bytes.IndexByte(data, '\n')
+	if i < 0 {
+		c.out = append(c.out, data...)
+		c.line = append(c.line, data...)
+		if len(c.line) > maxChunkLine {
+			c.state = statePassthrough
+		}
+
+		return nil
+	}
+
+	c.out = append(c.out, data[:i+1]...)
+	c.line = append(c.line, data[:i+1]...)
+	data = data[i+1:]
+	line := strings.TrimRight(string(c.line), "\r\n")
+	c.line = c.line[:0]
+
+	switch c.state {
+	case stateChunkSize:
+		if semi := strings.IndexByte(line, ';'); semi >= 0 {
+			line = line[:semi]
+		}
+
+		size, err := strconv.ParseInt(strings.TrimSpace(line), 16, 64)
+		switch {
+		case err != nil || size < 0:
+			c.state = statePassthrough
+		case size == 0:
+			c.state = stateTrailer
+		default:
+			c.remaining = size
+			c.state = stateChunkData
+		}
+	case stateChunkEnd:
+		if line == "" {
+			c.state = stateChunkSize
+		} else {
+			c.state = statePassthrough
+		}
+	case stateTrailer:
+		if line == "" {
+			c.state = stateHead
+		}
+	}
+
+	return data
+}
+
+type requestHead struct {
+	violations       []string
+	passthrough      bool
+	chunked          bool
+	contentLength    int64
+	hasContentLength bool
+}
+
+func (h *requestHead) violation(v string) {
+	for _, vi := range h.violations {
+		if vi == v {
+			return
+		}
+	}
+
+	h.violations = append(h.violations, v)
+}
+
+func parseRequestHead(head []byte) requestHead {
+	var (
+		h                    requestHead
+		contentLengths       int
+		transferEncodings    []string
+		connectionUpgrade    bool
+		upgrade              bool
+		invalidContentLength bool
+		http10               bool
+	)
+
+	lines := bytes.Split(head[:len(head)-1], []byte("\n"))
+	for i, line := range lines {
+		if !bytes.HasSuffix(line, []byte("\r")) {
+			h.violation(violationBareLF)
+		} else {
+			line = line[:len(line)-1]
+		}
+
+		if i == 0 {
+			fields := strings.Fields(string(line))
+			if len(fields) != 3 {
+				h.passthrough = true
+				continue
+			}
+
+			if fields[0] == "PRI" || fields[0] == "CONNECT" {
+				// HTTP/2 prior knowledge or a tunnel
+				h.passthrough = true
+				return h
+			}
+
+			http10 = fields[2] == "HTTP/1.0"
+
+			continue
+		}
+
+		if len(line) == 0 {
+			continue
+		}
+
+		if line[0] == ' ' || line[0] == '\t' {
+			h.violation(violationObsFold)
+			continue
+		}
+
+		colon := bytes.IndexByte(line, ':')
+		if colon <= 0 {
+			h.violation(violationInvalidHeader)
+			continue
+		}
+
+		name := string(line[:colon])
+		if strings.TrimRight(name, " \t") != name {
+			h.violation(violationWhitespaceBeforeColon)
+			name = strings.TrimRight(name, " \t")
+		}
+
+		value := strings.Trim(string(line[colon+1:]), " \t")
+		switch {
+		case strings.EqualFold(name, "Content-Length"):
+			contentLengths++
+			n, err := strconv.ParseUint(value, 10, 63)
+			if err != nil {
+				h.violation(violationInvalidContentLength)
+				invalidContentLength = true
+				continue
+			}
+
+			if h.hasContentLength && h.contentLength != int64(n) {
+				invalidContentLength = true
+			}
+
+			h.contentLength = int64(n)
+			h.hasContentLength = true
+		case strings.EqualFold(name, "Transfer-Encoding"):
+			transferEncodings = append(transferEncodings, value)
+		case strings.EqualFold(name, "Connection"):
+			for _, t := range strings.Split(value, ",") {
+				if strings.EqualFold(strings.TrimSpace(t), "upgrade") {
+					connectionUpgrade = true
+				}
+			}
+		case strings.EqualFold(name, "Upgrade"):
+			upgrade = true
+		}
+	}
+
+	if contentLengths > 1 {
+		h.violation(violationMultipleContentLength)
+	}
+
+	if len(transferEncodings) > 0 {
+		if contentLengths > 0 {
+			h.violation(violationContentLengthWithTransferEncoding)
+		}
+
+		if len(transferEncodings) > 1 || !strings.EqualFold(transferEncodings[0], "chunked") {
+			h.violation(violationInvalidTransferEncoding)
+			h.passthrough = true
+		}
+
+		// the Transfer-Encoding is ignored by the server for HTTP/1.0
+		// requests, it's reported, but the Content-Length is followed
+		h.chunked = !http10
+	}
+
+	if invalidContentLength && !h.chunked || connectionUpgrade && upgrade {
+		h.passthrough = true
+	}
+
+	return h
+}
+
+func (c *strictConn) checkHead() {
+	h := parseRequestHead(c.head)
+	if len(h.violations) > 0 {
+		c.report(h.violations)
+		if c.options.Level == StrictParsingReject {
+			c.head = c.head[:0]
+			c.lineStart = 0
+			c.state = stateRejected
+			return
+		}
+	}
+
+	if h.passthrough {
+		c.passthrough()
+		return
+	}
+
+	c.out = append(c.out, c.head...)
+	c.head = c.head[:0]
+	c.lineStart = 0
+
+	switch {
+	case h.chunked:
+		c.state = stateChunkSize
+	case h.contentLength > 0:
+		c.remaining = h.contentLength
+		c.state = stateBody
+	default:
+		c.state = stateHead
+	}
+}
+
+func (c *strictConn) report(violations []string) {
+	if m := c.options.Metrics; m != nil {
+		for _, v := range violations {
+			m.IncCounter(strictParsingViolationKey + v)
+		}
+
+		if c.options.Level == StrictParsingReject {
+			m.IncCounter(strictParsingRejectedKey)
+		}
+	}
+
+	log.Warnf(
+		"Strict HTTP parsing violations from %v: %s, level: %v",
+		c.RemoteAddr(),
+		strings.Join(violations, ", "),
+		c.options.Level,
+	)
+}
@@ -0,0 +1,160 @@
+package net
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/zalando/skipper/metrics/metricstest"
+)
+
+func serveStrict(t *testing.T, level StrictParsingLevel, m *metricstest.MockMetrics) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srv := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		w.Header().Set("X-Body", string(b))
+	})}
+
+	go srv.Serve(NewStrictParsingListener(l, StrictParsingOptions{Level: level, Metrics: m}))
+	t.Cleanup(func() { srv.Close() })
+	return l.Addr().String()
+}
+
+func sendRaw(t *testing.T, addr, raw string, responses int) []*http.Response {
+	c, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer c.Close()
+	c.SetDeadline(time.Now().Add(3 * time.Second))
+	if _, err := c.Write([]byte(raw)); err != nil {
+		t.Fatal(err)
+	}
+
+	var rsps []*http.Response
+	br := bufio.NewReader(c)
+	for i := 0; i < responses; i++ {
+		rsp, err := http.ReadResponse(br, nil)
+		if err != nil {
+			break
+		}
+
+		io.Copy(io.Discard, rsp.Body)
+		rsp.Body.Close()
+		rsps = append(rsps, rsp)
+	}
+
+	return rsps
+}
+
+func TestParseStrictParsingLevel(t *testing.T) {
+	for s, expected := range map[string]StrictParsingLevel{
+		"":       StrictParsingOff,
+		"off":    StrictParsingOff,
+		"log":    StrictParsingLog,
+		"reject": StrictParsingReject,
+	} {
+		if l, err := ParseStrictParsingLevel(s); err != nil || l != expected {
+			t.Errorf("failed to parse %q: %v, %v", s, l, err)
+		}
+	}
+
+	if _, err := ParseStrictParsingLevel("foo"); err == nil {
+		t.Error("failed to fail")
+	}
+}
+
+func TestStrictParsingValidRequests(t *testing.T) {
+	addr := serveStrict(t, StrictParsingReject, nil)
+
+	raw := "POST /a HTTP/1.1\r\nHost: example.org\r\nContent-Length: 5\r\n\r\nhello" +
+		"POST /b HTTP/1.1\r\nHost: example.org\r\nTransfer-Encoding: chunked\r\n\r\n" +
+		"3;ext=1\r\nfoo\r\n3\r\nbar\r\n0\r\nX-Trailer: baz\r\n\r\n" +
+		"\r\nGET /c HTTP/1.1\r\nHost: example.org\r\n\r\n"
+
+	rsps := sendRaw(t, addr, raw, 3)
+	if len(rsps) != 3 {
+		t.Fatalf("unexpected number of responses: %d", len(rsps))
+	}
+
+	for i, body := range []string{"hello", "foobar", ""} {
+		if rsps[i].StatusCode != http.StatusOK || rsps[i].Header.Get("X-Body") != body {
+			t.Errorf("unexpected response %d: %d, %q", i, rsps[i].StatusCode, rsps[i].Header.Get("X-Body"))
+		}
+	}
+}
+
+func TestStrictParsingViolations(t *testing.T) {
+	for _, tc := range []struct {
+		name      string
+		request   string
+		violation string
+	}{{
+		name:      "content length with transfer encoding",
+		request:   "POST / HTTP/1.1\r\nHost: example.org\r\nContent-Length: 4\r\nTransfer-Encoding: chunked\r\n\r\n0\r\n\r\n",
+		violation: violationContentLengthWithTransferEncoding,
+	}, {
+		name:      "multiple content length",
+		request:   "POST / HTTP/1.1\r\nHost: example.org\r\nContent-Length: 3\r\nContent-Length: 3\r\n\r\nfoo",
+		violation: violationMultipleContentLength,
+	}, {
+		name:      "obs-fold",
+		request:   "GET / HTTP/1.1\r\nHost: example.org\r\nX-Foo: bar\r\n baz\r\n\r\n",
+		violation: violationObsFold,
+	}, {
+		name:      "bare LF",
+		request:   "GET / HTTP/1.1\nHost: example.org\n\n",
+		violation: violationBareLF,
+	}, {
+		name:      "chunked 1.0 request",
+		request:   "POST / HTTP/1.0\r\nHost: example.org\r\nTransfer-Encoding: chunked\r\nContent-Length: 3\r\n\r\nfoo",
+		violation: violationContentLengthWithTransferEncoding,
+	}} {
+		t.Run(tc.name, func(t *testing.T) {
+			m := &metricstest.MockMetrics{}
+			rsps := sendRaw(t, serveStrict(t, StrictParsingReject, m), tc.request, 1)
+			if len(rsps) != 1 || rsps[0].StatusCode != http.StatusBadRequest {
+				t.Errorf("failed to reject the request: %v", rsps)
+			}
+
+			m.WithCounters(func(c map[string]int64) {
+				if c[strictParsingViolationKey+tc.violation] != 1 || c[strictParsingRejectedKey] != 1 {
+					t.Errorf("unexpected counters: %v", c)
+				}
+			})
+
+			// the log level only counts the violations
+			m = &metricstest.MockMetrics{}
+			rsps = sendRaw(t, serveStrict(t, StrictParsingLog, m), tc.request, 1)
+			if len(rsps) != 1 || rsps[0].StatusCode != http.StatusOK {
+				t.Errorf("unexpected response with log level: %v", rsps)
+			}
+
+			m.WithCounters(func(c map[string]int64) {
+				if c[strictParsingViolationKey+tc.violation] != 1 || c[strictParsingRejectedKey] != 0 {
+					t.Errorf("unexpected counters: %v", c)
+				}
+			})
+		})
+	}
+}
+
+func TestStrictParsingOff(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer l.Close()
+	if NewStrictParsingListener(l, StrictParsingOptions{}) != l {
+		t.Error("unexpected listener wrapper")
+	}
+}
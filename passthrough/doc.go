@@ -0,0 +1,15 @@
+/*
+Package passthrough implements the forwarding of the raw TLS connections
+to the TCP backends of the routes, selected by the server name (SNI) of
+the ClientHello, without terminating TLS.
+
+The routes with a TCP backend are defined with the tcp scheme, and they
+are matched by the Host predicate, applied to the server name:
+
+	db: Host(/^db[.]example[.]org$/) -> "tcp://10.0.0.1:5432";
+
+The Proxy takes these routes from the routing table as a routing
+post-processor, and wraps the listener of the HTTP server, so that the
+connections without a matching TCP route are served by the HTTP routes.
+*/
+package passthrough
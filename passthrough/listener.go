@@ -0,0 +1,196 @@
+package passthrough
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/tls"
+	"errors"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// the content type of the TLS handshake records
+	recordTypeHandshake = 0x16
+
+	initialBounceDelay = 5 * time.Millisecond
+	maxBounceDelay     = time.Second
+)
+
+var errHelloReceived = errors.New("client hello received")
+
+type listener struct {
+	net.Listener
+	proxy    *Proxy
+	accepted chan net.Conn
+	err      chan error
+	quit     chan struct{}
+	once     sync.Once
+}
+
+// peekedConn replays the bytes read while looking for the ClientHello.
+type peekedConn struct {
+	net.Conn
+	reader io.Reader
+}
+
+// helloConn is used to receive the ClientHello with the TLS
+// implementation of the standard library. It fails all writes, so that
+// nothing is sent to the client.
+type helloConn struct {
+	reader io.Reader
+}
+
+// Listener wraps a listener, and checks the first bytes of the accepted
+// connections. The TLS connections with a server name matching one of
+// the TCP routes are forwarded to the backend of the route, while all
+// the other connections are returned by Accept, including the ones with
+// an invalid or incomplete ClientHello. The connections are checked in
+// the background, without blocking Accept.
+//
+// When TLS is terminated by the HTTP server, the returned listener needs
+// to be wrapped by the TLS listener, and not the other way around.
+func (p *Proxy) Listener(l net.Listener) net.Listener {
+	pl := &listener{
+		Listener: l,
+		proxy:    p,
+		accepted: make(chan net.Conn),
+		err:      make(chan error),
+		quit:     make(chan struct{}),
+	}
+
+	go pl.acceptLoop()
+	return pl
+}
+
+func bounce(delay time.Duration) time.Duration {
+	if delay == 0 {
+		return initialBounceDelay
+	}
+
+	delay *= 2
+	if delay > maxBounceDelay {
+		delay = maxBounceDelay
+	}
+
+	return delay
+}
+
+func (l *listener) acceptLoop() {
+	var delay time.Duration
+	for {
+		c, err := l.Listener.Accept()
+		if err != nil {
+			// based on net/http.Server.Serve():
+			//lint:ignore SA1019 Temporary is deprecated in Go 1.18, but keep it for now (https://github.com/zalando/skipper/issues/1992)
+			if nerr, ok := err.(net.Error); ok && nerr.Temporary() {
+				delay = bounce(delay)
+				log.Errorf("Passthrough listener: accept error: %v, retrying in %v", err, delay)
+				select {
+				case <-time.After(delay):
+					continue
+				case <-l.quit:
+					return
+				}
+			}
+
+			select {
+			case l.err <- err:
+			case <-l.quit:
+			}
+
+			return
+		}
+
+		delay = 0
+		go l.handle(c)
+	}
+}
+
+// returns the server name from the ClientHello, and the bytes read
+func readServerName(r io.Reader) (string, []byte) {
+	var (
+		received   bytes.Buffer
+		serverName string
+	)
+
+	hc := &helloConn{reader: io.TeeReader(r, &received)}
+	tls.Server(hc, &tls.Config{
+		GetConfigForClient: func(hello *tls.ClientHelloInfo) (*tls.Config, error) {
+			serverName = hello.ServerName
+			return nil, errHelloReceived
+		},
+	}).Handshake()
+
+	return serverName, received.Bytes()
+}
+
+func (l *listener) handle(c net.Conn) {
+	br := bufio.NewReader(c)
+	pc := &peekedConn{Conn: c, reader: br}
+
+	c.SetReadDeadline(time.Now().Add(l.proxy.options.HandshakeTimeout))
+	if b, err := br.Peek(1); err == nil && b[0] == recordTypeHandshake {
+		serverName, received := readServerName(br)
+		pc.reader = io.MultiReader(bytes.NewReader(received), br)
+		if r := l.proxy.match(serverName); r != nil {
+			c.SetReadDeadline(time.Time{})
+			l.proxy.serve(pc, r)
+			return
+		}
+	}
+
+	c.SetReadDeadline(time.Time{})
+	select {
+	case l.accepted <- pc:
+	case <-l.quit:
+		c.Close()
+	}
+}
+
+func (l *listener) Accept() (net.Conn, error) {
+	select {
+	case c := <-l.accepted:
+		return c, nil
+	case err := <-l.err:
+		return nil, err
+	case <-l.quit:
+		return nil, net.ErrClosed
+	}
+}
+
+func (l *listener) Close() error {
+	var err error
+	l.once.Do(func() {
+		close(l.quit)
+		err = l.Listener.Close()
+	})
+
+	return err
+}
+
+func (c *peekedConn) Read(p []byte) (int, error) {
+	return c.reader.Read(p)
+}
+
+// CloseWrite shuts down the writing side of the TCP connections.
+func (c *peekedConn) CloseWrite() error {
+	if cw, ok := c.Conn.(interface{ CloseWrite() error }); ok {
+		return cw.CloseWrite()
+	}
+
+	return c.Conn.Close()
+}
+
+func (c *helloConn) Read(p []byte) (int, error)     { return c.reader.Read(p) }
+func (*helloConn) Write([]byte) (int, error)        { return 0, io.ErrClosedPipe }
+func (*helloConn) Close() error                     { return nil }
+func (*helloConn) LocalAddr() net.Addr              { return nil }
+func (*helloConn) RemoteAddr() net.Addr             { return nil }
+func (*helloConn) SetDeadline(time.Time) error      { return nil }
+func (*helloConn) SetReadDeadline(time.Time) error  { return nil }
+func (*helloConn) SetWriteDeadline(time.Time) error { return nil }
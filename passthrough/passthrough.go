@@ -0,0 +1,258 @@
+package passthrough
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"regexp"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/zalando/skipper/eskip"
+	"github.com/zalando/skipper/metrics"
+	"github.com/zalando/skipper/routing"
+)
+
+const (
+	defaultDialTimeout      = 10 * time.Second
+	defaultHandshakeTimeout = 10 * time.Second
+
+	connectionsKey     = "passthrough.connections"
+	openConnectionsKey = "passthrough.connections.open"
+	dialErrorsKey      = "passthrough.dial.errors"
+)
+
+var errClosed = errors.New("passthrough proxy closed")
+
+// Options for the passthrough proxy.
+type Options struct {
+
+	// DialTimeout limits the time of connecting to the backends.
+	// Defaults to 10s.
+	DialTimeout time.Duration
+
+	// HandshakeTimeout limits the time of receiving the TLS ClientHello
+	// after accepting a connection. Defaults to 10s.
+	HandshakeTimeout time.Duration
+
+	// Metrics, when set, is used to count the passthrough connections.
+	Metrics metrics.Metrics
+}
+
+type route struct {
+	id      string
+	hosts   []*regexp.Regexp
+	address string
+}
+
+// Proxy forwards the TLS connections of the routes with a TCP backend,
+// selected by the server name (SNI) of the ClientHello, without
+// terminating TLS. The other connections are passed to the HTTP server.
+//
+// The Proxy needs to be set both as a routing post-processor, and to wrap
+// the listener of the HTTP server.
+type Proxy struct {
+	options Options
+	routes  atomic.Value // []*route
+	mu      sync.Mutex
+	conns   map[net.Conn]struct{}
+	open    int64
+	closed  bool
+}
+
+var _ routing.PostProcessor = &Proxy{}
+
+// New creates a passthrough proxy.
+func New(o Options) *Proxy {
+	if o.DialTimeout <= 0 {
+		o.DialTimeout = defaultDialTimeout
+	}
+
+	if o.HandshakeTimeout <= 0 {
+		o.HandshakeTimeout = defaultHandshakeTimeout
+	}
+
+	p := &Proxy{
+		options: o,
+		conns:   make(map[net.Conn]struct{}),
+	}
+
+	p.routes.Store([]*route(nil))
+	return p
+}
+
+func newRoute(r *routing.Route) (*route, error) {
+	if len(r.Predicates) > 0 || len(r.Route.Predicates) > 0 || r.Path != "" || len(r.PathRegexps) > 0 ||
+		r.Method != "" || len(r.Headers) > 0 || len(r.HeaderRegexps) > 0 {
+		return nil, errors.New("only the Host predicate is supported")
+	}
+
+	if len(r.HostRegexps) == 0 {
+		return nil, errors.New("the Host predicate is required")
+	}
+
+	if r.Scheme != eskip.TCPScheme || r.Host == "" {
+		return nil, fmt.Errorf("invalid TCP backend address: %s", r.Backend)
+	}
+
+	if _, _, err := net.SplitHostPort(r.Host); err != nil {
+		return nil, fmt.Errorf("invalid TCP backend address: %w", err)
+	}
+
+	tr := &route{id: r.Id, address: r.Host}
+	for _, h := range r.HostRegexps {
+		rx, err := regexp.Compile(h)
+		if err != nil {
+			return nil, err
+		}
+
+		tr.hosts = append(tr.hosts, rx)
+	}
+
+	return tr, nil
+}
+
+// Do implements routing.PostProcessor. It takes the routes with a TCP
+// backend, and returns the rest for the HTTP routing table.
+func (p *Proxy) Do(routes []*routing.Route) []*routing.Route {
+	var (
+		tcp  []*route
+		rest []*routing.Route
+	)
+
+	for _, r := range routes {
+		if r.BackendType != eskip.TCPBackend {
+			rest = append(rest, r)
+			continue
+		}
+
+		tr, err := newRoute(r)
+		if err != nil {
+			log.Errorf("Invalid TCP route %s: %v", r.Id, err)
+			continue
+		}
+
+		if len(r.Filters) > 0 {
+			log.Warnf("The filters of the TCP route %s are not applied", r.Id)
+		}
+
+		tcp = append(tcp, tr)
+	}
+
+	p.routes.Store(tcp)
+	return rest
+}
+
+// returns the first route matching the server name
+func (p *Proxy) match(serverName string) *route {
+	serverName = strings.ToLower(serverName)
+	for _, r := range p.routes.Load().([]*route) {
+		for _, rx := range r.hosts {
+			if rx.MatchString(serverName) {
+				return r
+			}
+		}
+	}
+
+	return nil
+}
+
+func (p *Proxy) incCounter(key string) {
+	if p.options.Metrics != nil {
+		p.options.Metrics.IncCounter(key)
+	}
+}
+
+func (p *Proxy) updateOpen(delta int64) {
+	n := atomic.AddInt64(&p.open, delta)
+	if p.options.Metrics != nil {
+		p.options.Metrics.UpdateGauge(openConnectionsKey, float64(n))
+	}
+}
+
+func (p *Proxy) track(c net.Conn) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return errClosed
+	}
+
+	p.conns[c] = struct{}{}
+	return nil
+}
+
+func (p *Proxy) untrack(c net.Conn) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	delete(p.conns, c)
+}
+
+func closeWrite(c net.Conn) {
+	if cw, ok := c.(interface{ CloseWrite() error }); ok {
+		cw.CloseWrite()
+	} else {
+		c.Close()
+	}
+}
+
+// forwards the data in both directions, until both sides are done
+func pipe(client, backend net.Conn) {
+	done := make(chan struct{})
+	go func() {
+		io.Copy(backend, client)
+		closeWrite(backend)
+		close(done)
+	}()
+
+	io.Copy(client, backend)
+	closeWrite(client)
+	<-done
+}
+
+// serve forwards the connection to the backend of the route. The client
+// connection contains the already received ClientHello.
+func (p *Proxy) serve(client net.Conn, r *route) {
+	defer client.Close()
+
+	p.incCounter(connectionsKey)
+	backend, err := net.DialTimeout("tcp", r.address, p.options.DialTimeout)
+	if err != nil {
+		p.incCounter(dialErrorsKey)
+		log.Errorf("Failed to connect to the backend of the TCP route %s: %v", r.id, err)
+		return
+	}
+
+	defer backend.Close()
+	if err := p.track(client); err != nil {
+		return
+	}
+
+	defer p.untrack(client)
+	if err := p.track(backend); err != nil {
+		return
+	}
+
+	defer p.untrack(backend)
+	p.updateOpen(1)
+	defer p.updateOpen(-1)
+
+	pipe(client, backend)
+}
+
+// Close closes the open passthrough connections.
+func (p *Proxy) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.closed = true
+	for c := range p.conns {
+		c.Close()
+	}
+
+	return nil
+}
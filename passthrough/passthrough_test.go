@@ -0,0 +1,177 @@
+package passthrough
+
+import (
+	"crypto/tls"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/zalando/skipper/eskip"
+	"github.com/zalando/skipper/routing"
+)
+
+func processRoutes(t *testing.T, doc string) []*routing.Route {
+	t.Helper()
+	defs, err := eskip.Parse(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var routes []*routing.Route
+	for _, d := range defs {
+		u, err := url.Parse(d.Backend)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		routes = append(routes, &routing.Route{Route: *d, Scheme: u.Scheme, Host: u.Host})
+	}
+
+	return routes
+}
+
+func testHandler(body string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(body))
+	})
+}
+
+func get(t *testing.T, client *http.Client, url string) string {
+	t.Helper()
+	rsp, err := client.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer rsp.Body.Close()
+	b, err := io.ReadAll(rsp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return string(b)
+}
+
+func TestDo(t *testing.T) {
+	p := New(Options{})
+	routes := processRoutes(t, `
+		db: Host(/^db[.]example[.]org$/) -> "tcp://10.0.0.1:5432";
+		mqtt: Host(/^mqtt[.]example[.]org$/) -> setPath("/") -> "tcp://10.0.0.2:8883";
+		noHost: * -> "tcp://10.0.0.3:5432";
+		noPort: Host(/^foo[.]example[.]org$/) -> "tcp://10.0.0.4";
+		web: Host(/^www[.]example[.]org$/) -> "https://www.example.org";
+	`)
+
+	rest := p.Do(routes)
+	if len(rest) != 1 || rest[0].Id != "web" {
+		t.Fatalf("unexpected HTTP routes: %v", rest)
+	}
+
+	if r := p.match("DB.example.org"); r == nil || r.id != "db" || r.address != "10.0.0.1:5432" {
+		t.Errorf("failed to match the db route: %v", r)
+	}
+
+	if r := p.match("mqtt.example.org"); r == nil || r.id != "mqtt" {
+		t.Errorf("failed to match the mqtt route: %v", r)
+	}
+
+	for _, name := range []string{"", "www.example.org", "foo.example.org"} {
+		if r := p.match(name); r != nil {
+			t.Errorf("unexpected match for %q: %s", name, r.id)
+		}
+	}
+
+	p.Do(nil)
+	if r := p.match("db.example.org"); r != nil {
+		t.Error("failed to remove the TCP routes")
+	}
+}
+
+func TestListener(t *testing.T) {
+	backend := httptest.NewTLSServer(testHandler("backend"))
+	defer backend.Close()
+
+	p := New(Options{})
+	defer p.Close()
+
+	p.Do(processRoutes(t, `db: Host(/^db[.]example[.]org$/) -> "tcp://`+backend.Listener.Addr().String()+`"`))
+
+	front := httptest.NewUnstartedServer(testHandler("front"))
+	front.Listener = p.Listener(front.Listener)
+	front.StartTLS()
+	defer front.Close()
+
+	client := func(serverName string) *http.Client {
+		return &http.Client{Transport: &http.Transport{
+			TLSClientConfig: &tls.Config{ServerName: serverName, InsecureSkipVerify: true},
+		}}
+	}
+
+	if b := get(t, client("db.example.org"), front.URL); b != "backend" {
+		t.Errorf("failed to pass through the connection, got: %s", b)
+	}
+
+	if b := get(t, client("www.example.org"), front.URL); b != "front" {
+		t.Errorf("failed to serve the HTTP request, got: %s", b)
+	}
+
+	if b := get(t, client(""), front.URL); b != "front" {
+		t.Errorf("failed to serve the HTTP request without SNI, got: %s", b)
+	}
+}
+
+func TestListenerPlainHTTP(t *testing.T) {
+	p := New(Options{})
+	defer p.Close()
+
+	front := httptest.NewUnstartedServer(testHandler("front"))
+	front.Listener = p.Listener(front.Listener)
+	front.Start()
+	defer front.Close()
+
+	if b := get(t, &http.Client{}, front.URL); b != "front" {
+		t.Errorf("failed to serve the HTTP request, got: %s", b)
+	}
+}
+
+func TestListenerDialError(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	address := l.Addr().String()
+	l.Close()
+
+	p := New(Options{})
+	defer p.Close()
+
+	p.Do(processRoutes(t, `db: Host(/^db[.]example[.]org$/) -> "tcp://`+address+`"`))
+
+	front := httptest.NewUnstartedServer(testHandler("front"))
+	front.Listener = p.Listener(front.Listener)
+	front.StartTLS()
+	defer front.Close()
+
+	conn, err := tls.Dial("tcp", front.Listener.Addr().String(), &tls.Config{ServerName: "db.example.org", InsecureSkipVerify: true})
+	if err == nil {
+		conn.Close()
+		t.Fatal("failed to fail")
+	}
+}
+
+func TestClose(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pl := New(Options{}).Listener(l)
+	pl.Close()
+	if _, err := pl.Accept(); err == nil {
+		t.Error("failed to fail after closing")
+	}
+}
@@ -13,6 +13,12 @@ Examples:
 	    // all key value pairs have to match
 	    example2: * && JWTPayloadAllKV("iss", "https://accounts.google.com", "email", "skipper-router@googlegroups.com")
 		-> "http://example.org/";
+
+The JWTVerifiedPayload* variants verify the signature of the token with
+the keys of the issuer in the first argument, before matching the claims:
+
+	    example3: JWTVerifiedPayloadAllKV("https://accounts.google.com", "email", "skipper-router@googlegroups.com")
+		-> "http://example.org/";
 */
 package auth
 
@@ -103,6 +109,18 @@ func (s *spec) Name() string {
 }
 
 func (s *spec) Create(args []interface{}) (routing.Predicate, error) {
+	kv, err := createMatchers(s.matchMode, args)
+	if err != nil {
+		return nil, err
+	}
+
+	return &predicate{
+		kv:            kv,
+		matchBehavior: s.matchBehavior,
+	}, nil
+}
+
+func createMatchers(mode matchMode, args []interface{}) (map[string][]valueMatcher, error) {
 	if len(args) == 0 || len(args)%2 != 0 {
 		return nil, predicates.ErrInvalidPredicateParameters
 	}
@@ -116,7 +134,7 @@ func (s *spec) Create(args []interface{}) (routing.Predicate, error) {
 		}
 
 		var matcher valueMatcher
-		switch s.matchMode {
+		switch mode {
 		case matchModeExact:
 			matcher = exactMatcher{expected: value}
 		case matchModeRegexp:
@@ -131,10 +149,7 @@ func (s *spec) Create(args []interface{}) (routing.Predicate, error) {
 		kv[key] = append(kv[key], matcher)
 	}
 
-	return &predicate{
-		kv:            kv,
-		matchBehavior: s.matchBehavior,
-	}, nil
+	return kv, nil
 }
 
 func (m exactMatcher) Match(jwtValue string) bool {
@@ -145,10 +160,15 @@ func (m regexMatcher) Match(jwtValue string) bool {
 	return m.regexp.MatchString(jwtValue)
 }
 
-func (p *predicate) Match(r *http.Request) bool {
+func bearerToken(r *http.Request) (string, bool) {
 	ahead := r.Header.Get(authHeaderName)
 	tv := strings.TrimPrefix(ahead, authHeaderPrefix)
-	if tv == ahead {
+	return tv, tv != ahead
+}
+
+func (p *predicate) Match(r *http.Request) bool {
+	tv, ok := bearerToken(r)
+	if !ok {
 		return false
 	}
 
@@ -157,11 +177,15 @@ func (p *predicate) Match(r *http.Request) bool {
 		return false
 	}
 
+	return p.matchClaims(token.Claims)
+}
+
+func (p *predicate) matchClaims(claims map[string]interface{}) bool {
 	switch p.matchBehavior {
 	case matchBehaviorAll:
-		return allMatch(p.kv, token.Claims)
+		return allMatch(p.kv, claims)
 	case matchBehaviorAny:
-		return anyMatch(p.kv, token.Claims)
+		return anyMatch(p.kv, claims)
 	default:
 		return false
 	}
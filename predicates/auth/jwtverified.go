@@ -0,0 +1,213 @@
+package auth
+
+import (
+	"container/list"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/zalando/skipper/filters/auth"
+	"github.com/zalando/skipper/predicates"
+	"github.com/zalando/skipper/routing"
+)
+
+const (
+	// the claims of the verified tokens are cached for at most this
+	// duration, to avoid verifying the signature of the same token for
+	// every route and every request
+	maxClaimsCacheTTL  = time.Minute
+	maxClaimsCacheSize = 4096
+)
+
+type (
+	verifiedSpec struct {
+		name          string
+		matchBehavior matchBehavior
+		matchMode     matchMode
+		cache         *claimsCache
+	}
+	verifiedPredicate struct {
+		predicate
+		jwksURL string
+		cache   *claimsCache
+	}
+	cachedClaims struct {
+		key     string
+		claims  map[string]interface{}
+		expires time.Time
+	}
+
+	// claimsCache is shared by the predicates created by the same spec, so
+	// that a token is verified only once, even if it is matched by
+	// multiple routes. When full, it evicts the least recently used
+	// entry.
+	claimsCache struct {
+		maxSize int
+		mu      sync.Mutex
+		entries map[string]*list.Element
+		lru     *list.List
+		now     func() time.Time
+	}
+)
+
+func newClaimsCache(maxSize int) *claimsCache {
+	return &claimsCache{
+		maxSize: maxSize,
+		entries: make(map[string]*list.Element),
+		lru:     list.New(),
+		now:     time.Now,
+	}
+}
+
+// NewJWTVerifiedPayloadAnyKV creates a predicate specification, whose
+// instances match the requests with a JWT token, that was signed by the
+// issuer in the first argument, and contains any of the key value pairs of
+// the remaining arguments. The signing keys of the issuer are resolved
+// through its OpenID configuration, and they are shared with the
+// jwtValidation filter.
+//
+// Example:
+//
+//	JWTVerifiedPayloadAnyKV("https://accounts.example.org", "realm", "employees", "realm", "services")
+func NewJWTVerifiedPayloadAnyKV() routing.PredicateSpec {
+	return &verifiedSpec{
+		name:          predicates.JWTVerifiedPayloadAnyKVName,
+		matchBehavior: matchBehaviorAny,
+		matchMode:     matchModeExact,
+		cache:         newClaimsCache(maxClaimsCacheSize),
+	}
+}
+
+// NewJWTVerifiedPayloadAllKV creates a predicate specification like
+// NewJWTVerifiedPayloadAnyKV, but all the key value pairs need to match.
+func NewJWTVerifiedPayloadAllKV() routing.PredicateSpec {
+	return &verifiedSpec{
+		name:          predicates.JWTVerifiedPayloadAllKVName,
+		matchBehavior: matchBehaviorAll,
+		matchMode:     matchModeExact,
+		cache:         newClaimsCache(maxClaimsCacheSize),
+	}
+}
+
+// NewJWTVerifiedPayloadAnyKVRegexp creates a predicate specification like
+// NewJWTVerifiedPayloadAnyKV, but the values are regular expressions.
+func NewJWTVerifiedPayloadAnyKVRegexp() routing.PredicateSpec {
+	return &verifiedSpec{
+		name:          predicates.JWTVerifiedPayloadAnyKVRegexpName,
+		matchBehavior: matchBehaviorAny,
+		matchMode:     matchModeRegexp,
+		cache:         newClaimsCache(maxClaimsCacheSize),
+	}
+}
+
+// NewJWTVerifiedPayloadAllKVRegexp creates a predicate specification like
+// NewJWTVerifiedPayloadAllKV, but the values are regular expressions.
+func NewJWTVerifiedPayloadAllKVRegexp() routing.PredicateSpec {
+	return &verifiedSpec{
+		name:          predicates.JWTVerifiedPayloadAllKVRegexpName,
+		matchBehavior: matchBehaviorAll,
+		matchMode:     matchModeRegexp,
+		cache:         newClaimsCache(maxClaimsCacheSize),
+	}
+}
+
+func (s *verifiedSpec) Name() string {
+	return s.name
+}
+
+func (s *verifiedSpec) Create(args []interface{}) (routing.Predicate, error) {
+	if len(args) == 0 {
+		return nil, predicates.ErrInvalidPredicateParameters
+	}
+
+	issuerURL, ok := args[0].(string)
+	if !ok || issuerURL == "" {
+		return nil, predicates.ErrInvalidPredicateParameters
+	}
+
+	kv, err := createMatchers(s.matchMode, args[1:])
+	if err != nil {
+		return nil, err
+	}
+
+	jwksURL, err := auth.RegisterIssuerKeys(issuerURL)
+	if err != nil {
+		return nil, err
+	}
+
+	return &verifiedPredicate{
+		predicate: predicate{
+			kv:            kv,
+			matchBehavior: s.matchBehavior,
+		},
+		jwksURL: jwksURL,
+		cache:   s.cache,
+	}, nil
+}
+
+func (p *verifiedPredicate) Match(r *http.Request) bool {
+	tv, ok := bearerToken(r)
+	if !ok || tv == "" {
+		return false
+	}
+
+	claims, ok := p.cache.get(p.jwksURL, tv)
+	if !ok {
+		var err error
+		claims, err = auth.ParseVerifiedToken(tv, p.jwksURL)
+		if err != nil {
+			return false
+		}
+
+		p.cache.put(p.jwksURL, tv, claims)
+	}
+
+	return p.matchClaims(claims)
+}
+
+func (c *claimsCache) remove(e *list.Element) {
+	cc := c.lru.Remove(e).(*cachedClaims)
+	delete(c.entries, cc.key)
+}
+
+func (c *claimsCache) get(jwksURL, token string) (map[string]interface{}, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e, ok := c.entries[jwksURL+" "+token]
+	if !ok {
+		return nil, false
+	}
+
+	cc := e.Value.(*cachedClaims)
+	if !c.now().Before(cc.expires) {
+		c.remove(e)
+		return nil, false
+	}
+
+	c.lru.MoveToFront(e)
+	return cc.claims, true
+}
+
+func (c *claimsCache) put(jwksURL, token string, claims map[string]interface{}) {
+	expires := c.now().Add(maxClaimsCacheTTL)
+	if exp, ok := claims["exp"].(float64); ok {
+		if t := time.Unix(int64(exp), 0); t.Before(expires) {
+			expires = t
+		}
+	}
+
+	key := jwksURL + " " + token
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if e, ok := c.entries[key]; ok {
+		c.remove(e)
+	}
+
+	c.entries[key] = c.lru.PushFront(&cachedClaims{key: key, claims: claims, expires: expires})
+	if c.lru.Len() > c.maxSize {
+		c.remove(c.lru.Back())
+	}
+}
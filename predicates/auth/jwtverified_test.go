@@ -0,0 +1,215 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	jwtv4 "github.com/golang-jwt/jwt/v4"
+	"github.com/stretchr/testify/require"
+	"github.com/zalando/skipper/predicates"
+	"github.com/zalando/skipper/routing"
+)
+
+const testKID = "test-kid"
+
+func issuerServer(t *testing.T, key *rsa.PrivateKey) *httptest.Server {
+	mux := http.NewServeMux()
+	var s *httptest.Server
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"issuer": "%s", "jwks_uri": "%s/jwks"}`, s.URL, s.URL)
+	})
+
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"keys":[{"kty":"RSA", "alg":"RS256", "kid": "%s", "n":"%s","e":"AQAB"}]}`,
+			testKID, base64.RawURLEncoding.EncodeToString(key.PublicKey.N.Bytes()))
+	})
+
+	s = httptest.NewServer(mux)
+	t.Cleanup(s.Close)
+	return s
+}
+
+func signedToken(t *testing.T, key *rsa.PrivateKey, claims jwtv4.MapClaims) string {
+	token := jwtv4.NewWithClaims(jwtv4.SigningMethodRS256, claims)
+	token.Header["kid"] = testKID
+	s, err := token.SignedString(key)
+	require.NoError(t, err)
+	return s
+}
+
+func TestJWTVerifiedSpec(t *testing.T) {
+	for _, tc := range []struct {
+		spec routing.PredicateSpec
+		name string
+	}{
+		{spec: NewJWTVerifiedPayloadAllKV(), name: predicates.JWTVerifiedPayloadAllKVName},
+		{spec: NewJWTVerifiedPayloadAnyKV(), name: predicates.JWTVerifiedPayloadAnyKVName},
+		{spec: NewJWTVerifiedPayloadAllKVRegexp(), name: predicates.JWTVerifiedPayloadAllKVRegexpName},
+		{spec: NewJWTVerifiedPayloadAnyKVRegexp(), name: predicates.JWTVerifiedPayloadAnyKVRegexpName},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			require.Equal(t, tc.name, tc.spec.Name())
+		})
+	}
+}
+
+func TestJWTVerifiedCreate(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	issuer := issuerServer(t, key)
+
+	for _, tc := range []struct {
+		name string
+		args []interface{}
+	}{{
+		name: "no args",
+	}, {
+		name: "issuer only",
+		args: []interface{}{issuer.URL},
+	}, {
+		name: "issuer not a string",
+		args: []interface{}{42, "sub", "foo"},
+	}, {
+		name: "odd key values",
+		args: []interface{}{issuer.URL, "sub"},
+	}, {
+		name: "unreachable issuer",
+		args: []interface{}{"http://127.0.0.1:1", "sub", "foo"},
+	}} {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := NewJWTVerifiedPayloadAllKV().Create(tc.args)
+			require.Error(t, err)
+		})
+	}
+}
+
+func TestJWTVerifiedMatch(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	issuer := issuerServer(t, key)
+
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	exp := time.Now().Add(time.Hour).Unix()
+	valid := signedToken(t, key, jwtv4.MapClaims{"sub": "jdoe", "realm": "employees", "exp": exp})
+	expired := signedToken(t, key, jwtv4.MapClaims{"sub": "jdoe", "realm": "employees", "exp": time.Now().Add(-time.Hour).Unix()})
+	forged := signedToken(t, otherKey, jwtv4.MapClaims{"sub": "jdoe", "realm": "employees", "exp": exp})
+
+	for _, tc := range []struct {
+		name     string
+		spec     routing.PredicateSpec
+		args     []interface{}
+		token    string
+		expected bool
+	}{{
+		name:     "all match",
+		spec:     NewJWTVerifiedPayloadAllKV(),
+		args:     []interface{}{issuer.URL, "sub", "jdoe", "realm", "employees"},
+		token:    valid,
+		expected: true,
+	}, {
+		name:  "all, one does not match",
+		spec:  NewJWTVerifiedPayloadAllKV(),
+		args:  []interface{}{issuer.URL, "sub", "jdoe", "realm", "services"},
+		token: valid,
+	}, {
+		name:     "any match",
+		spec:     NewJWTVerifiedPayloadAnyKV(),
+		args:     []interface{}{issuer.URL, "realm", "services", "realm", "employees"},
+		token:    valid,
+		expected: true,
+	}, {
+		name:     "regexp match",
+		spec:     NewJWTVerifiedPayloadAllKVRegexp(),
+		args:     []interface{}{issuer.URL, "sub", "^j"},
+		token:    valid,
+		expected: true,
+	}, {
+		name:  "no token",
+		spec:  NewJWTVerifiedPayloadAllKV(),
+		args:  []interface{}{issuer.URL, "sub", "jdoe"},
+		token: "",
+	}, {
+		name:  "expired token",
+		spec:  NewJWTVerifiedPayloadAllKV(),
+		args:  []interface{}{issuer.URL, "sub", "jdoe"},
+		token: expired,
+	}, {
+		name:  "invalid signature",
+		spec:  NewJWTVerifiedPayloadAllKV(),
+		args:  []interface{}{issuer.URL, "sub", "jdoe"},
+		token: forged,
+	}, {
+		name:  "not a jwt",
+		spec:  NewJWTVerifiedPayloadAllKV(),
+		args:  []interface{}{issuer.URL, "sub", "jdoe"},
+		token: "foo",
+	}} {
+		t.Run(tc.name, func(t *testing.T) {
+			p, err := tc.spec.Create(tc.args)
+			require.NoError(t, err)
+
+			r, err := http.NewRequest("GET", "https://www.example.org", nil)
+			require.NoError(t, err)
+			if tc.token != "" {
+				r.Header.Set(authHeaderName, authHeaderPrefix+tc.token)
+			}
+
+			// the second match is served from the cache
+			require.Equal(t, tc.expected, p.Match(r))
+			require.Equal(t, tc.expected, p.Match(r))
+		})
+	}
+}
+
+func TestClaimsCache(t *testing.T) {
+	c := newClaimsCache(2)
+	now := time.Now()
+	c.now = func() time.Time { return now }
+
+	claims := func(sub string) map[string]interface{} { return map[string]interface{}{"sub": sub} }
+	c.put("jwks", "token1", claims("foo"))
+	c.put("jwks", "token2", claims("bar"))
+
+	// token1 is used more recently than token2
+	_, ok := c.get("jwks", "token1")
+	require.True(t, ok)
+
+	c.put("jwks", "token3", claims("baz"))
+	_, ok = c.get("jwks", "token2")
+	require.False(t, ok, "failed to evict the least recently used entry")
+
+	for _, token := range []string{"token1", "token3"} {
+		_, ok = c.get("jwks", token)
+		require.True(t, ok, "unexpected eviction of %s", token)
+	}
+
+	_, ok = c.get("other-jwks", "token1")
+	require.False(t, ok)
+
+	c.put("jwks", "expiring", map[string]interface{}{"exp": float64(now.Add(time.Second).Unix())})
+	_, ok = c.get("jwks", "expiring")
+	require.True(t, ok)
+
+	now = now.Add(2 * time.Second)
+	_, ok = c.get("jwks", "expiring")
+	require.False(t, ok, "failed to expire the claims")
+	require.Equal(t, 1, c.lru.Len())
+
+	now = now.Add(maxClaimsCacheTTL)
+	_, ok = c.get("jwks", "token3")
+	require.False(t, ok, "failed to expire the claims after the max TTL")
+}
+
+func TestClaimsCacheScopedToSpec(t *testing.T) {
+	all := NewJWTVerifiedPayloadAllKV().(*verifiedSpec)
+	anyKV := NewJWTVerifiedPayloadAnyKV().(*verifiedSpec)
+	require.NotSame(t, all.cache, anyKV.cache)
+}
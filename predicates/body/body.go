@@ -0,0 +1,316 @@
+/*
+Package body implements custom predicates to match routes based on the
+content of the request body, e.g. to route the SOAP or GraphQL requests,
+that share the same path, by their operation.
+
+The request body is buffered up to a size limit, and it is replayed
+unchanged to the backend. The requests with a longer body, including the
+ones whose Content-Length exceeds the limit, don't match. The default
+limit is 64KiB, and it can be changed with the last, optional argument of
+the predicates. The body is read only once, even when it is matched by
+multiple routes.
+
+BodyRegexp matches the body against a regular expression.
+
+BodyJSONPath matches the value found at a JSONPath in a JSON body. The
+path supports the dot and the bracket notation, e.g. $.operationName,
+$.variables.ids[0] or $['user-name']. The expected value can be a string,
+a number or a boolean, and it is compared to the found value by type and
+value.
+
+Examples:
+
+	getUser: Path("/graphql") && BodyJSONPath("$.operationName", "getUser") -> "https://users.internal";
+	order: Path("/soap") && BodyRegexp("<(\\w+:)?CreateOrder[ >]", 1048576) -> "https://orders.internal";
+*/
+package body
+
+import (
+	"io"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/tidwall/gjson"
+	"github.com/zalando/skipper/predicates"
+	"github.com/zalando/skipper/routing"
+)
+
+const defaultMaxBodySize = 64 * 1024
+
+type kind int
+
+const (
+	bodyRegexp kind = iota
+	bodyJSONPath
+)
+
+type spec struct {
+	kind kind
+}
+
+type regexpPredicate struct {
+	maxBodySize int64
+	rx          *regexp.Regexp
+}
+
+type jsonPathPredicate struct {
+	maxBodySize int64
+	path        string
+	value       interface{}
+}
+
+// bufferedBody replaces the request body, when it is buffered for
+// matching. It replays the buffered part, and then the rest of the original
+// body.
+type bufferedBody struct {
+	data     []byte
+	offset   int
+	consumed bool
+	original io.ReadCloser
+	eof      bool
+	err      error
+}
+
+// NewRegexp creates the BodyRegexp predicate specification.
+func NewRegexp() routing.PredicateSpec { return &spec{kind: bodyRegexp} }
+
+// NewJSONPath creates the BodyJSONPath predicate specification.
+func NewJSONPath() routing.PredicateSpec { return &spec{kind: bodyJSONPath} }
+
+func (s *spec) Name() string {
+	if s.kind == bodyRegexp {
+		return predicates.BodyRegexpName
+	}
+
+	return predicates.BodyJSONPathName
+}
+
+func maxBodySizeArg(args []interface{}, required int) (int64, error) {
+	switch len(args) {
+	case required:
+		return defaultMaxBodySize, nil
+	case required + 1:
+		v, ok := args[required].(float64)
+		if !ok || v < 1 {
+			return 0, predicates.ErrInvalidPredicateParameters
+		}
+
+		return int64(v), nil
+	default:
+		return 0, predicates.ErrInvalidPredicateParameters
+	}
+}
+
+func (s *spec) Create(args []interface{}) (routing.Predicate, error) {
+	if s.kind == bodyRegexp {
+		return createRegexp(args)
+	}
+
+	return createJSONPath(args)
+}
+
+func createRegexp(args []interface{}) (routing.Predicate, error) {
+	max, err := maxBodySizeArg(args, 1)
+	if err != nil {
+		return nil, err
+	}
+
+	expr, ok := args[0].(string)
+	if !ok {
+		return nil, predicates.ErrInvalidPredicateParameters
+	}
+
+	rx, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, predicates.ErrInvalidPredicateParameters
+	}
+
+	return &regexpPredicate{maxBodySize: max, rx: rx}, nil
+}
+
+func createJSONPath(args []interface{}) (routing.Predicate, error) {
+	max, err := maxBodySizeArg(args, 2)
+	if err != nil {
+		return nil, err
+	}
+
+	jsonPath, ok := args[0].(string)
+	if !ok {
+		return nil, predicates.ErrInvalidPredicateParameters
+	}
+
+	path, ok := convertJSONPath(jsonPath)
+	if !ok {
+		return nil, predicates.ErrInvalidPredicateParameters
+	}
+
+	switch args[1].(type) {
+	case string, float64, bool:
+	default:
+		return nil, predicates.ErrInvalidPredicateParameters
+	}
+
+	return &jsonPathPredicate{maxBodySize: max, path: path, value: args[1]}, nil
+}
+
+func escapePathKey(key string) string {
+	var b strings.Builder
+	for i := 0; i < len(key); i++ {
+		switch key[i] {
+		case '.', '*', '?', '|', '#', '@', '\\', '!', '=', '<', '>', '%':
+			b.WriteByte('\\')
+		}
+
+		b.WriteByte(key[i])
+	}
+
+	return b.String()
+}
+
+// convertJSONPath converts the supported subset of the JSONPath syntax to
+// the path syntax of gjson.
+func convertJSONPath(p string) (string, bool) {
+	if !strings.HasPrefix(p, "$") {
+		return "", false
+	}
+
+	p = p[1:]
+	var keys []string
+	for p != "" {
+		switch p[0] {
+		case '.':
+			p = p[1:]
+			end := strings.IndexAny(p, ".[")
+			if end < 0 {
+				end = len(p)
+			}
+
+			if end == 0 {
+				return "", false
+			}
+
+			keys = append(keys, escapePathKey(p[:end]))
+			p = p[end:]
+		case '[':
+			end := strings.IndexByte(p, ']')
+			if end < 0 {
+				return "", false
+			}
+
+			inner := p[1:end]
+			p = p[end+1:]
+			if len(inner) >= 2 && (inner[0] == '\'' || inner[0] == '"') && inner[len(inner)-1] == inner[0] {
+				keys = append(keys, escapePathKey(inner[1:len(inner)-1]))
+				continue
+			}
+
+			if _, err := strconv.ParseUint(inner, 10, 32); err != nil {
+				return "", false
+			}
+
+			keys = append(keys, inner)
+		default:
+			return "", false
+		}
+	}
+
+	if len(keys) == 0 {
+		return "", false
+	}
+
+	return strings.Join(keys, "."), true
+}
+
+func (b *bufferedBody) fill(max int64) {
+	if b.eof || b.consumed || int64(len(b.data)) > max {
+		return
+	}
+
+	n := max + 1 - int64(len(b.data))
+	more, err := io.ReadAll(io.LimitReader(b.original, n))
+	b.data = append(b.data, more...)
+	if err != nil {
+		b.err = err
+		b.eof = true
+	} else if int64(len(more)) < n {
+		b.eof = true
+	}
+}
+
+func (b *bufferedBody) Read(p []byte) (int, error) {
+	b.consumed = true
+	if b.offset < len(b.data) {
+		n := copy(p, b.data[b.offset:])
+		b.offset += n
+		return n, nil
+	}
+
+	if b.eof {
+		if b.err != nil {
+			return 0, b.err
+		}
+
+		return 0, io.EOF
+	}
+
+	return b.original.Read(p)
+}
+
+func (b *bufferedBody) Close() error {
+	return b.original.Close()
+}
+
+// readBody returns the request body, up to the max size. It returns false,
+// when the body is longer than the max size, or it could not be read.
+func readBody(r *http.Request, max int64) ([]byte, bool) {
+	if r.Body == nil || r.Body == http.NoBody {
+		return nil, true
+	}
+
+	if r.ContentLength > max {
+		return nil, false
+	}
+
+	b, ok := r.Body.(*bufferedBody)
+	if !ok {
+		b = &bufferedBody{original: r.Body}
+		r.Body = b
+	}
+
+	b.fill(max)
+	if !b.eof || b.err != nil || int64(len(b.data)) > max {
+		return nil, false
+	}
+
+	return b.data, true
+}
+
+func (p *regexpPredicate) Match(r *http.Request) bool {
+	body, ok := readBody(r, p.maxBodySize)
+	if !ok {
+		return false
+	}
+
+	return p.rx.Match(body)
+}
+
+func (p *jsonPathPredicate) Match(r *http.Request) bool {
+	body, ok := readBody(r, p.maxBodySize)
+	if !ok || !gjson.ValidBytes(body) {
+		return false
+	}
+
+	result := gjson.GetBytes(body, p.path)
+	switch v := p.value.(type) {
+	case string:
+		return result.Type == gjson.String && result.Str == v
+	case float64:
+		return result.Type == gjson.Number && result.Num == v
+	case bool:
+		return v && result.Type == gjson.True || !v && result.Type == gjson.False
+	default:
+		return false
+	}
+}
@@ -0,0 +1,241 @@
+package body
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/zalando/skipper/eskip"
+	"github.com/zalando/skipper/filters/builtin"
+	"github.com/zalando/skipper/proxy/proxytest"
+	"github.com/zalando/skipper/routing"
+)
+
+func TestConvertJSONPath(t *testing.T) {
+	for path, expected := range map[string]string{
+		"$.operationName":       "operationName",
+		"$.variables.ids[0]":    "variables.ids.0",
+		"$['user-name']":        "user-name",
+		`$["a.b"].c`:            `a\.b.c`,
+		"$.items[12]['x*'].y":   `items.12.x\*.y`,
+		"$.data.user.addresses": "data.user.addresses",
+	} {
+		p, ok := convertJSONPath(path)
+		if !ok || p != expected {
+			t.Errorf("%s: expected %s, got: %s, %v", path, expected, p, ok)
+		}
+	}
+
+	for _, path := range []string{"", "$", "operationName", "$.", "$..a", "$.a[", "$.a[x]", "$.a[-1]", "$a"} {
+		if _, ok := convertJSONPath(path); ok {
+			t.Errorf("%s: failed to fail", path)
+		}
+	}
+}
+
+func TestCreate(t *testing.T) {
+	for _, test := range []struct {
+		title string
+		spec  routing.PredicateSpec
+		args  []interface{}
+		fail  bool
+	}{{
+		title: "regexp without args",
+		spec:  NewRegexp(),
+		fail:  true,
+	}, {
+		title: "invalid regexp",
+		spec:  NewRegexp(),
+		args:  []interface{}{"("},
+		fail:  true,
+	}, {
+		title: "invalid max size",
+		spec:  NewRegexp(),
+		args:  []interface{}{"foo", "1024"},
+		fail:  true,
+	}, {
+		title: "regexp with max size",
+		spec:  NewRegexp(),
+		args:  []interface{}{"foo", 1024.0},
+	}, {
+		title: "json path without value",
+		spec:  NewJSONPath(),
+		args:  []interface{}{"$.foo"},
+		fail:  true,
+	}, {
+		title: "invalid json path",
+		spec:  NewJSONPath(),
+		args:  []interface{}{"foo", "bar"},
+		fail:  true,
+	}, {
+		title: "invalid json value",
+		spec:  NewJSONPath(),
+		args:  []interface{}{"$.foo", []interface{}{"bar"}},
+		fail:  true,
+	}, {
+		title: "json path",
+		spec:  NewJSONPath(),
+		args:  []interface{}{"$.foo", true, 1024.0},
+	}} {
+		t.Run(test.title, func(t *testing.T) {
+			_, err := test.spec.Create(test.args)
+			if test.fail && err == nil {
+				t.Fatal("failed to fail")
+			} else if !test.fail && err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestMatch(t *testing.T) {
+	const graphql = `{"operationName": "getUser", "variables": {"id": 42, "admin": false}}`
+	for _, test := range []struct {
+		title         string
+		spec          routing.PredicateSpec
+		args          []interface{}
+		body          string
+		contentLength int64
+		expected      bool
+	}{{
+		title:    "regexp",
+		spec:     NewRegexp(),
+		args:     []interface{}{"<(\\w+:)?CreateOrder[ >]"},
+		body:     `<soap:Body><m:CreateOrder xmlns:m="urn:orders"/></soap:Body>`,
+		expected: true,
+	}, {
+		title: "regexp does not match",
+		spec:  NewRegexp(),
+		args:  []interface{}{"CancelOrder"},
+		body:  `<soap:Body><m:CreateOrder/></soap:Body>`,
+	}, {
+		title:         "content length over the limit",
+		spec:          NewRegexp(),
+		args:          []interface{}{"foo", 8.0},
+		body:          "foo",
+		contentLength: 9,
+	}, {
+		title:         "body over the limit",
+		spec:          NewRegexp(),
+		args:          []interface{}{"foo", 8.0},
+		body:          "foo-bar-baz",
+		contentLength: -1,
+	}, {
+		title:         "body at the limit",
+		spec:          NewRegexp(),
+		args:          []interface{}{"foo", 11.0},
+		body:          "foo-bar-baz",
+		contentLength: -1,
+		expected:      true,
+	}, {
+		title:    "json string",
+		spec:     NewJSONPath(),
+		args:     []interface{}{"$.operationName", "getUser"},
+		body:     graphql,
+		expected: true,
+	}, {
+		title:    "json number",
+		spec:     NewJSONPath(),
+		args:     []interface{}{"$.variables.id", 42.0},
+		body:     graphql,
+		expected: true,
+	}, {
+		title:    "json bool",
+		spec:     NewJSONPath(),
+		args:     []interface{}{"$.variables.admin", false},
+		body:     graphql,
+		expected: true,
+	}, {
+		title: "json type mismatch",
+		spec:  NewJSONPath(),
+		args:  []interface{}{"$.variables.id", "42"},
+		body:  graphql,
+	}, {
+		title: "json path not found",
+		spec:  NewJSONPath(),
+		args:  []interface{}{"$.query", "getUser"},
+		body:  graphql,
+	}, {
+		title: "invalid json",
+		spec:  NewJSONPath(),
+		args:  []interface{}{"$.operationName", "getUser"},
+		body:  `{"operationName": "getUser"`,
+	}} {
+		t.Run(test.title, func(t *testing.T) {
+			p, err := test.spec.Create(test.args)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			r := httptest.NewRequest("POST", "/", strings.NewReader(test.body))
+			if test.contentLength != 0 {
+				r.ContentLength = test.contentLength
+			}
+
+			if m := p.Match(r); m != test.expected {
+				t.Fatalf("unexpected match result: %v", m)
+			}
+
+			// matching again uses the buffered body
+			if m := p.Match(r); m != test.expected {
+				t.Fatalf("unexpected match result on the second match: %v", m)
+			}
+
+			b, err := io.ReadAll(r.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if string(b) != test.body {
+				t.Fatalf("body not replayed, got: %s", b)
+			}
+		})
+	}
+}
+
+func TestBodyForwardedToBackend(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(w, r.Body)
+	}))
+	defer backend.Close()
+
+	routes, err := eskip.Parse(`
+		getUser: BodyJSONPath("$.operationName", "getUser") -> setResponseHeader("X-Route", "getUser") -> "` + backend.URL + `";
+		other: * -> setResponseHeader("X-Route", "other") -> "` + backend.URL + `";
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p := proxytest.WithRoutingOptions(builtin.MakeRegistry(), routing.Options{
+		Predicates: []routing.PredicateSpec{NewJSONPath()},
+	}, routes...)
+	defer p.Close()
+
+	for body, route := range map[string]string{
+		`{"operationName": "getUser"}`:    "getUser",
+		`{"operationName": "deleteUser"}`: "other",
+	} {
+		rsp, err := http.Post(p.URL, "application/json", bytes.NewBufferString(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		b, err := io.ReadAll(rsp.Body)
+		rsp.Body.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if rsp.Header.Get("X-Route") != route {
+			t.Errorf("unexpected route for %s: %s", body, rsp.Header.Get("X-Route"))
+		}
+
+		if string(b) != body {
+			t.Errorf("unexpected body forwarded to the backend: %s", b)
+		}
+	}
+}
@@ -0,0 +1,71 @@
+/*
+Package botdetect implements the BotScore predicate, that matches the
+requests whose bot score is at or above a threshold, see the botdetect
+package for the signals. Optionally, it also accepts an upper limit.
+
+The predicate doesn't count the request for the request rate of the
+client, only the botScore filter does.
+
+Examples:
+
+	challenge: BotScore(50) -> redirectTo(302, "/challenge") -> <shunt>;
+	suspicious: BotScore(30, 49) -> botScore() -> "https://slow.example.org";
+*/
+package botdetect
+
+import (
+	"net/http"
+
+	"github.com/zalando/skipper/botdetect"
+	"github.com/zalando/skipper/predicates"
+	"github.com/zalando/skipper/routing"
+)
+
+type spec struct {
+	detector *botdetect.Detector
+}
+
+type predicate struct {
+	detector *botdetect.Detector
+	min, max int
+}
+
+// New creates the BotScore predicate specification, using the provided
+// detector.
+func New(d *botdetect.Detector) routing.PredicateSpec { return &spec{detector: d} }
+
+func (*spec) Name() string { return predicates.BotScoreName }
+
+func scoreArg(a interface{}) (int, bool) {
+	v, ok := a.(float64)
+	if !ok || v < 0 || v > 100 {
+		return 0, false
+	}
+
+	return int(v), true
+}
+
+func (s *spec) Create(args []interface{}) (routing.Predicate, error) {
+	if len(args) == 0 || len(args) > 2 {
+		return nil, predicates.ErrInvalidPredicateParameters
+	}
+
+	p := &predicate{detector: s.detector, max: 100}
+	var ok bool
+	if p.min, ok = scoreArg(args[0]); !ok {
+		return nil, predicates.ErrInvalidPredicateParameters
+	}
+
+	if len(args) == 2 {
+		if p.max, ok = scoreArg(args[1]); !ok || p.max < p.min {
+			return nil, predicates.ErrInvalidPredicateParameters
+		}
+	}
+
+	return p, nil
+}
+
+func (p *predicate) Match(r *http.Request) bool {
+	score := p.detector.Score(r).Score
+	return score >= p.min && score <= p.max
+}
@@ -0,0 +1,64 @@
+package botdetect
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/zalando/skipper/botdetect"
+	"github.com/zalando/skipper/predicates"
+)
+
+func TestBotScore(t *testing.T) {
+	d := botdetect.New(botdetect.Options{})
+	defer d.Close()
+
+	spec := New(d)
+	if spec.Name() != predicates.BotScoreName {
+		t.Errorf("invalid name: %s", spec.Name())
+	}
+
+	curl := httptest.NewRequest("GET", "/", nil)
+	curl.Header.Set("User-Agent", "curl/8.1.2")
+
+	browser := httptest.NewRequest("GET", "/", nil)
+	browser.Header.Set("User-Agent", "Mozilla/5.0 (X11; Linux x86_64; rv:109.0) Gecko/20100101 Firefox/118.0")
+	browser.Header.Set("Accept", "text/html")
+	browser.Header.Set("Accept-Language", "de-DE")
+	browser.Header.Set("Accept-Encoding", "gzip")
+
+	for _, tt := range []struct {
+		name    string
+		args    []interface{}
+		err     bool
+		curl    bool
+		browser bool
+	}{
+		{name: "no args", err: true},
+		{name: "invalid min", args: []interface{}{"50"}, err: true},
+		{name: "min out of range", args: []interface{}{-1.0}, err: true},
+		{name: "max below min", args: []interface{}{50.0, 40.0}, err: true},
+		{name: "too many args", args: []interface{}{0.0, 50.0, 100.0}, err: true},
+		{name: "min", args: []interface{}{50.0}, curl: true},
+		{name: "range", args: []interface{}{0.0, 50.0}, browser: true},
+		{name: "any", args: []interface{}{0.0}, curl: true, browser: true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := spec.Create(tt.args)
+			if tt.err {
+				if err == nil {
+					t.Error("failed to fail")
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if p.Match(curl) != tt.curl || p.Match(browser) != tt.browser {
+				t.Errorf("unexpected match: curl %t, browser %t", p.Match(curl), p.Match(browser))
+			}
+		})
+	}
+}
@@ -0,0 +1,245 @@
+/*
+Package clientcert implements custom predicates to match routes based on
+the attributes of the verified TLS client certificate of a request.
+
+The predicates only match the requests received on a TLS listener, that
+verified the client certificate against the configured client CAs. The
+unverified client certificates are never matched.
+
+ClientCertSAN matches the subject alternative names of the certificate:
+the DNS names, the email addresses, the IP addresses and the URIs. The
+arguments are matched exactly, except for the ones with a leading "*.",
+that match a single DNS label at their position.
+
+ClientCertSubject matches the distinguished name of the subject of the
+certificate, in the RFC 2253 format, against regular expressions.
+
+ClientCertSPIFFE matches the SPIFFE ID of the certificate, the URI SAN with
+the spiffe scheme. The arguments ending with "/*" match all the IDs under
+the path.
+
+Examples:
+
+	// match the clients of the billing service in any zone
+	billing: ClientCertSAN("billing.example.org", "*.billing.example.org") -> "https://billing.internal";
+
+	// match the clients of the Example organization
+	example: ClientCertSubject(",O=Example,") -> "https://example.internal";
+
+	// match the workloads of the prod namespace
+	prod: ClientCertSPIFFE("spiffe://example.org/ns/prod/*") -> "https://prod.internal";
+*/
+package clientcert
+
+import (
+	"crypto/x509"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/zalando/skipper/predicates"
+	"github.com/zalando/skipper/routing"
+)
+
+type kind int
+
+const (
+	san kind = iota
+	subject
+	spiffe
+)
+
+type spec struct {
+	kind kind
+}
+
+type sanPredicate struct {
+	names []string
+}
+
+type subjectPredicate struct {
+	patterns []*regexp.Regexp
+}
+
+type spiffePredicate struct {
+	ids      map[string]bool
+	prefixes []string
+}
+
+// NewSAN creates the ClientCertSAN predicate specification.
+func NewSAN() routing.PredicateSpec { return &spec{kind: san} }
+
+// NewSubject creates the ClientCertSubject predicate specification.
+func NewSubject() routing.PredicateSpec { return &spec{kind: subject} }
+
+// NewSPIFFE creates the ClientCertSPIFFE predicate specification.
+func NewSPIFFE() routing.PredicateSpec { return &spec{kind: spiffe} }
+
+func (s *spec) Name() string {
+	switch s.kind {
+	case san:
+		return predicates.ClientCertSANName
+	case subject:
+		return predicates.ClientCertSubjectName
+	default:
+		return predicates.ClientCertSPIFFEName
+	}
+}
+
+func stringArgs(args []interface{}) ([]string, error) {
+	if len(args) == 0 {
+		return nil, predicates.ErrInvalidPredicateParameters
+	}
+
+	var s []string
+	for _, a := range args {
+		v, ok := a.(string)
+		if !ok || v == "" {
+			return nil, predicates.ErrInvalidPredicateParameters
+		}
+
+		s = append(s, v)
+	}
+
+	return s, nil
+}
+
+func (s *spec) Create(args []interface{}) (routing.Predicate, error) {
+	values, err := stringArgs(args)
+	if err != nil {
+		return nil, err
+	}
+
+	switch s.kind {
+	case san:
+		p := &sanPredicate{}
+		for _, v := range values {
+			p.names = append(p.names, strings.ToLower(v))
+		}
+
+		return p, nil
+	case subject:
+		p := &subjectPredicate{}
+		for _, v := range values {
+			rx, err := regexp.Compile(v)
+			if err != nil {
+				return nil, predicates.ErrInvalidPredicateParameters
+			}
+
+			p.patterns = append(p.patterns, rx)
+		}
+
+		return p, nil
+	default:
+		p := &spiffePredicate{ids: make(map[string]bool)}
+		for _, v := range values {
+			u, err := url.Parse(strings.TrimSuffix(v, "*"))
+			if err != nil || u.Scheme != "spiffe" || u.Host == "" {
+				return nil, predicates.ErrInvalidPredicateParameters
+			}
+
+			if strings.HasSuffix(v, "/*") {
+				p.prefixes = append(p.prefixes, strings.TrimSuffix(v, "*"))
+			} else if strings.HasSuffix(v, "*") {
+				return nil, predicates.ErrInvalidPredicateParameters
+			} else {
+				p.ids[v] = true
+			}
+		}
+
+		return p, nil
+	}
+}
+
+// verifiedCert returns the leaf certificate of the first verified chain.
+func verifiedCert(r *http.Request) (*x509.Certificate, bool) {
+	if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 || len(r.TLS.VerifiedChains[0]) == 0 {
+		return nil, false
+	}
+
+	return r.TLS.VerifiedChains[0][0], true
+}
+
+func matchName(pattern, name string) bool {
+	if !strings.HasPrefix(pattern, "*.") {
+		return pattern == name
+	}
+
+	i := strings.IndexByte(name, '.')
+	return i > 0 && name[i:] == pattern[1:]
+}
+
+func (p *sanPredicate) Match(r *http.Request) bool {
+	c, ok := verifiedCert(r)
+	if !ok {
+		return false
+	}
+
+	var names []string
+	names = append(names, c.DNSNames...)
+	names = append(names, c.EmailAddresses...)
+	for _, ip := range c.IPAddresses {
+		names = append(names, ip.String())
+	}
+
+	for _, u := range c.URIs {
+		names = append(names, u.String())
+	}
+
+	for _, n := range names {
+		n = strings.ToLower(n)
+		for _, pattern := range p.names {
+			if matchName(pattern, n) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func (p *subjectPredicate) Match(r *http.Request) bool {
+	c, ok := verifiedCert(r)
+	if !ok {
+		return false
+	}
+
+	dn := c.Subject.String()
+	for _, rx := range p.patterns {
+		if rx.MatchString(dn) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (p *spiffePredicate) Match(r *http.Request) bool {
+	c, ok := verifiedCert(r)
+	if !ok {
+		return false
+	}
+
+	for _, u := range c.URIs {
+		if u.Scheme != "spiffe" {
+			continue
+		}
+
+		// a SPIFFE certificate contains exactly one SPIFFE ID
+		id := u.String()
+		if p.ids[id] {
+			return true
+		}
+
+		for _, prefix := range p.prefixes {
+			if strings.HasPrefix(id, prefix) {
+				return true
+			}
+		}
+
+		return false
+	}
+
+	return false
+}
@@ -0,0 +1,185 @@
+package clientcert
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"net"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/zalando/skipper/routing"
+)
+
+func mustParseURL(s string) *url.URL {
+	u, err := url.Parse(s)
+	if err != nil {
+		panic(err)
+	}
+
+	return u
+}
+
+var testCert = &x509.Certificate{
+	Subject: pkix.Name{
+		CommonName:         "client-a",
+		Organization:       []string{"Example"},
+		OrganizationalUnit: []string{"Billing"},
+		Country:            []string{"DE"},
+	},
+	DNSNames:       []string{"eu-1.billing.example.org"},
+	EmailAddresses: []string{"billing@example.org"},
+	IPAddresses:    []net.IP{net.ParseIP("10.0.0.1")},
+	URIs:           []*url.URL{mustParseURL("spiffe://example.org/ns/prod/sa/billing")},
+}
+
+func TestCreate(t *testing.T) {
+	for _, test := range []struct {
+		title string
+		spec  routing.PredicateSpec
+		args  []interface{}
+		fail  bool
+	}{{
+		title: "san without args",
+		spec:  NewSAN(),
+		fail:  true,
+	}, {
+		title: "san with a non-string arg",
+		spec:  NewSAN(),
+		args:  []interface{}{42},
+		fail:  true,
+	}, {
+		title: "san",
+		spec:  NewSAN(),
+		args:  []interface{}{"*.example.org"},
+	}, {
+		title: "subject with an invalid regexp",
+		spec:  NewSubject(),
+		args:  []interface{}{"CN=("},
+		fail:  true,
+	}, {
+		title: "subject",
+		spec:  NewSubject(),
+		args:  []interface{}{"^CN=client-a,"},
+	}, {
+		title: "spiffe with a different scheme",
+		spec:  NewSPIFFE(),
+		args:  []interface{}{"https://example.org/ns/prod"},
+		fail:  true,
+	}, {
+		title: "spiffe without trust domain",
+		spec:  NewSPIFFE(),
+		args:  []interface{}{"spiffe:///ns/prod"},
+		fail:  true,
+	}, {
+		title: "spiffe with a wildcard in the middle of a segment",
+		spec:  NewSPIFFE(),
+		args:  []interface{}{"spiffe://example.org/ns/prod*"},
+		fail:  true,
+	}, {
+		title: "spiffe",
+		spec:  NewSPIFFE(),
+		args:  []interface{}{"spiffe://example.org/ns/prod/*", "spiffe://example.org/ns/dev/sa/billing"},
+	}} {
+		t.Run(test.title, func(t *testing.T) {
+			_, err := test.spec.Create(test.args)
+			if test.fail && err == nil {
+				t.Fatal("failed to fail")
+			} else if !test.fail && err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestMatch(t *testing.T) {
+	verified := &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{testCert}}}
+	unverified := &tls.ConnectionState{PeerCertificates: []*x509.Certificate{testCert}}
+
+	for _, test := range []struct {
+		title    string
+		spec     routing.PredicateSpec
+		args     []interface{}
+		tls      *tls.ConnectionState
+		expected bool
+	}{{
+		title: "no tls",
+		spec:  NewSAN(),
+		args:  []interface{}{"eu-1.billing.example.org"},
+	}, {
+		title: "unverified certificate",
+		spec:  NewSAN(),
+		args:  []interface{}{"eu-1.billing.example.org"},
+		tls:   unverified,
+	}, {
+		title:    "dns san",
+		spec:     NewSAN(),
+		args:     []interface{}{"EU-1.billing.example.org"},
+		tls:      verified,
+		expected: true,
+	}, {
+		title:    "wildcard san",
+		spec:     NewSAN(),
+		args:     []interface{}{"*.billing.example.org"},
+		tls:      verified,
+		expected: true,
+	}, {
+		title: "wildcard san matches a single label",
+		spec:  NewSAN(),
+		args:  []interface{}{"*.example.org"},
+		tls:   verified,
+	}, {
+		title:    "email san",
+		spec:     NewSAN(),
+		args:     []interface{}{"billing@example.org"},
+		tls:      verified,
+		expected: true,
+	}, {
+		title:    "ip san",
+		spec:     NewSAN(),
+		args:     []interface{}{"10.0.0.1"},
+		tls:      verified,
+		expected: true,
+	}, {
+		title:    "subject",
+		spec:     NewSubject(),
+		args:     []interface{}{"^CN=client-a,OU=Billing,O=Example,C=DE$"},
+		tls:      verified,
+		expected: true,
+	}, {
+		title: "subject does not match",
+		spec:  NewSubject(),
+		args:  []interface{}{"O=Other"},
+		tls:   verified,
+	}, {
+		title:    "spiffe id",
+		spec:     NewSPIFFE(),
+		args:     []interface{}{"spiffe://example.org/ns/prod/sa/billing"},
+		tls:      verified,
+		expected: true,
+	}, {
+		title:    "spiffe path",
+		spec:     NewSPIFFE(),
+		args:     []interface{}{"spiffe://example.org/ns/prod/*"},
+		tls:      verified,
+		expected: true,
+	}, {
+		title: "spiffe other trust domain",
+		spec:  NewSPIFFE(),
+		args:  []interface{}{"spiffe://example.com/ns/prod/*"},
+		tls:   verified,
+	}} {
+		t.Run(test.title, func(t *testing.T) {
+			p, err := test.spec.Create(test.args)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			r := &http.Request{TLS: test.tls}
+			if m := p.Match(r); m != test.expected {
+				t.Fatalf("unexpected match result: %v", m)
+			}
+		})
+	}
+}
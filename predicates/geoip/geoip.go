@@ -0,0 +1,116 @@
+/*
+Package geoip implements the ClientGeo predicate, that matches the
+requests based on the location or the autonomous system of the client IP,
+looked up in a GeoIP database.
+
+The client IP is the remote host of the request, see the client IP
+resolution options of Skipper.
+
+The first argument is the field to match, "country", "continent" or "asn",
+followed by the accepted values. The countries are identified by their ISO
+3166-1 codes, the continents by their two letter codes.
+
+Examples:
+
+	eu: ClientGeo("continent", "EU") -> "https://eu.example.org";
+	dach: ClientGeo("country", "DE", "AT", "CH") -> "https://dach.example.org";
+	telekom: ClientGeo("asn", 3320) -> "https://isp.example.org";
+*/
+package geoip
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/zalando/skipper/geoip"
+	snet "github.com/zalando/skipper/net"
+	"github.com/zalando/skipper/predicates"
+	"github.com/zalando/skipper/routing"
+)
+
+type field int
+
+const (
+	country field = iota
+	continent
+	asn
+)
+
+type spec struct {
+	db *geoip.DB
+}
+
+type predicate struct {
+	db     *geoip.DB
+	field  field
+	values map[string]bool
+}
+
+// New creates the ClientGeo predicate specification, using the provided
+// database for the lookups.
+func New(db *geoip.DB) routing.PredicateSpec { return &spec{db: db} }
+
+func (*spec) Name() string { return predicates.ClientGeoName }
+
+func (s *spec) Create(args []interface{}) (routing.Predicate, error) {
+	if len(args) < 2 {
+		return nil, predicates.ErrInvalidPredicateParameters
+	}
+
+	name, ok := args[0].(string)
+	if !ok {
+		return nil, predicates.ErrInvalidPredicateParameters
+	}
+
+	p := &predicate{db: s.db, values: make(map[string]bool)}
+	switch name {
+	case "country":
+		p.field = country
+	case "continent":
+		p.field = continent
+	case "asn":
+		p.field = asn
+	default:
+		return nil, predicates.ErrInvalidPredicateParameters
+	}
+
+	for _, a := range args[1:] {
+		switch v := a.(type) {
+		case string:
+			if p.field == asn {
+				if _, err := strconv.ParseUint(v, 10, 32); err != nil {
+					return nil, predicates.ErrInvalidPredicateParameters
+				}
+			}
+
+			p.values[strings.ToUpper(v)] = true
+		case float64:
+			if p.field != asn || v < 0 {
+				return nil, predicates.ErrInvalidPredicateParameters
+			}
+
+			p.values[strconv.FormatUint(uint64(v), 10)] = true
+		default:
+			return nil, predicates.ErrInvalidPredicateParameters
+		}
+	}
+
+	return p, nil
+}
+
+func (p *predicate) Match(r *http.Request) bool {
+	l, ok := p.db.Lookup(snet.RemoteHost(r))
+	if !ok {
+		return false
+	}
+
+	switch p.field {
+	case country:
+		return l.Country != "" && p.values[l.Country]
+	case continent:
+		return l.Continent != "" && p.values[l.Continent]
+	default:
+		return l.ASN != 0 && p.values[strconv.FormatUint(uint64(l.ASN), 10)]
+	}
+}
@@ -0,0 +1,131 @@
+package geoip
+
+import (
+	"net/http"
+	"path/filepath"
+	"testing"
+
+	"github.com/zalando/skipper/geoip"
+	"github.com/zalando/skipper/geoip/geoiptest"
+)
+
+func testDB(t *testing.T) *geoip.DB {
+	path := filepath.Join(t.TempDir(), "geo.mmdb")
+	if err := geoiptest.WriteDB(path, map[string]map[string]interface{}{
+		"1.2.3.0/24": {
+			"country":                  map[string]interface{}{"iso_code": "DE"},
+			"continent":                map[string]interface{}{"code": "EU"},
+			"autonomous_system_number": uint(3320),
+		},
+		"5.6.0.0/16": {
+			"country":   map[string]interface{}{"iso_code": "US"},
+			"continent": map[string]interface{}{"code": "NA"},
+		},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := geoip.Open(geoip.Options{Paths: []string{path}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(db.Close)
+	return db
+}
+
+func TestCreate(t *testing.T) {
+	s := New(nil)
+	for _, test := range []struct {
+		title string
+		args  []interface{}
+		fail  bool
+	}{{
+		title: "no args",
+		fail:  true,
+	}, {
+		title: "no values",
+		args:  []interface{}{"country"},
+		fail:  true,
+	}, {
+		title: "unknown field",
+		args:  []interface{}{"city", "Berlin"},
+		fail:  true,
+	}, {
+		title: "field not a string",
+		args:  []interface{}{42, "DE"},
+		fail:  true,
+	}, {
+		title: "numeric country",
+		args:  []interface{}{"country", 42.0},
+		fail:  true,
+	}, {
+		title: "invalid asn",
+		args:  []interface{}{"asn", "AS3320"},
+		fail:  true,
+	}, {
+		title: "countries",
+		args:  []interface{}{"country", "DE", "at"},
+	}, {
+		title: "asn as number and string",
+		args:  []interface{}{"asn", 3320.0, "8075"},
+	}} {
+		t.Run(test.title, func(t *testing.T) {
+			_, err := s.Create(test.args)
+			if test.fail && err == nil {
+				t.Fatal("failed to fail")
+			} else if !test.fail && err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestMatch(t *testing.T) {
+	s := New(testDB(t))
+	for _, test := range []struct {
+		title      string
+		args       []interface{}
+		remoteAddr string
+		expected   bool
+	}{{
+		title:      "country matches",
+		args:       []interface{}{"country", "AT", "de"},
+		remoteAddr: "1.2.3.4:8080",
+		expected:   true,
+	}, {
+		title:      "country does not match",
+		args:       []interface{}{"country", "DE"},
+		remoteAddr: "5.6.7.8:8080",
+	}, {
+		title:      "continent matches",
+		args:       []interface{}{"continent", "NA"},
+		remoteAddr: "5.6.7.8:8080",
+		expected:   true,
+	}, {
+		title:      "asn matches",
+		args:       []interface{}{"asn", 3320.0},
+		remoteAddr: "1.2.3.4:8080",
+		expected:   true,
+	}, {
+		title:      "asn not found",
+		args:       []interface{}{"asn", 3320.0},
+		remoteAddr: "5.6.7.8:8080",
+	}, {
+		title:      "address not found",
+		args:       []interface{}{"continent", "EU"},
+		remoteAddr: "9.9.9.9:8080",
+	}} {
+		t.Run(test.title, func(t *testing.T) {
+			p, err := s.Create(test.args)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			r := &http.Request{RemoteAddr: test.remoteAddr, Header: make(http.Header)}
+			if m := p.Match(r); m != test.expected {
+				t.Fatalf("unexpected match result: %v", m)
+			}
+		})
+	}
+}
@@ -0,0 +1,153 @@
+package host
+
+import (
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/zalando/skipper/predicates"
+	"github.com/zalando/skipper/routing"
+)
+
+type wildcardSpec struct{}
+
+// trie of the host patterns, keyed by the domain labels in reverse order
+type hostTrie struct {
+	children map[string]*hostTrie
+
+	// the pattern matches the domain of the node
+	exact bool
+
+	// the pattern matches any subdomain of the node, at any depth
+	subdomains bool
+}
+
+type wildcardPredicate struct {
+	patterns *hostTrie
+}
+
+// NewWildcard creates a predicate specification, whose instances match the
+// request host against a list of host patterns:
+//
+// - "www.example.org" matches only www.example.org,
+// - "*.example.org" matches the subdomains of example.org at any depth,
+// e.g. www.example.org or api.eu.example.org, but not example.org,
+// - ".example.org" matches example.org and all its subdomains.
+//
+// The comparison is case-insensitive, and ignores the port and the trailing
+// dot of the request host. The patterns are looked up in a trie, without
+// evaluating regular expressions.
+//
+// The predicate has a lower weight than the other predicates, so when two
+// routes have otherwise the same predicates, a route with an exact host
+// predicate, e.g. HostAny or Host, takes precedence over a route with
+// HostWildcard.
+func NewWildcard() routing.PredicateSpec { return &wildcardSpec{} }
+
+func (*wildcardSpec) Name() string {
+	return predicates.HostWildcardName
+}
+
+// Weight decreases the weight of the routes using HostWildcard, compensating
+// the weight of the predicate itself.
+func (*wildcardSpec) Weight() int {
+	return -1
+}
+
+func (*wildcardSpec) Create(args []interface{}) (routing.Predicate, error) {
+	if len(args) == 0 {
+		return nil, predicates.ErrInvalidPredicateParameters
+	}
+
+	t := &hostTrie{}
+	for _, arg := range args {
+		pattern, ok := arg.(string)
+		if !ok {
+			return nil, predicates.ErrInvalidPredicateParameters
+		}
+
+		if err := t.insert(pattern); err != nil {
+			return nil, err
+		}
+	}
+
+	return &wildcardPredicate{patterns: t}, nil
+}
+
+func (t *hostTrie) insert(pattern string) error {
+	var exact, subdomains bool
+	switch {
+	case strings.HasPrefix(pattern, "*."):
+		pattern = pattern[2:]
+		subdomains = true
+	case strings.HasPrefix(pattern, "."):
+		pattern = pattern[1:]
+		exact, subdomains = true, true
+	default:
+		exact = true
+	}
+
+	labels := strings.Split(strings.ToLower(pattern), ".")
+	n := t
+	for i := len(labels) - 1; i >= 0; i-- {
+		if labels[i] == "" || strings.Contains(labels[i], "*") {
+			return predicates.ErrInvalidPredicateParameters
+		}
+
+		c, ok := n.children[labels[i]]
+		if !ok {
+			if n.children == nil {
+				n.children = make(map[string]*hostTrie)
+			}
+
+			c = &hostTrie{}
+			n.children[labels[i]] = c
+		}
+
+		n = c
+	}
+
+	n.exact = n.exact || exact
+	n.subdomains = n.subdomains || subdomains
+	return nil
+}
+
+func (t *hostTrie) match(host string) bool {
+	n := t
+	for host != "" {
+		var label string
+		if i := strings.LastIndexByte(host, '.'); i >= 0 {
+			label, host = host[i+1:], host[:i]
+		} else {
+			label, host = host, ""
+		}
+
+		n = n.children[label]
+		if n == nil {
+			return false
+		}
+
+		if host != "" && n.subdomains {
+			return true
+		}
+	}
+
+	return n.exact
+}
+
+func normalizeHost(host string) string {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+
+	return strings.ToLower(strings.TrimSuffix(host, "."))
+}
+
+func (p *wildcardPredicate) Match(r *http.Request) bool {
+	host := normalizeHost(r.Host)
+	if host == "" {
+		return false
+	}
+
+	return p.patterns.match(host)
+}
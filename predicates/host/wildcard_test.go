@@ -0,0 +1,133 @@
+package host
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+func TestHostWildcardArgs(t *testing.T) {
+	s := NewWildcard()
+	for _, tc := range []struct {
+		args []interface{}
+	}{
+		{
+			args: []interface{}{},
+		},
+		{
+			args: []interface{}{1.2},
+		},
+		{
+			args: []interface{}{""},
+		},
+		{
+			args: []interface{}{"*"},
+		},
+		{
+			args: []interface{}{"api.*.example.org"},
+		},
+		{
+			args: []interface{}{"*.*.example.org"},
+		},
+		{
+			args: []interface{}{"example..org"},
+		},
+		{
+			args: []interface{}{"example.org", 3.4},
+		},
+	} {
+		if _, err := s.Create(tc.args); err == nil {
+			t.Errorf("expected error for arguments: %v", tc.args)
+		}
+	}
+}
+
+func TestHostWildcardMatch(t *testing.T) {
+	s := NewWildcard()
+	for _, tc := range []struct {
+		host  string
+		args  []interface{}
+		match bool
+	}{
+		{
+			host:  "example.org",
+			args:  []interface{}{"example.com"},
+			match: false,
+		},
+		{
+			host:  "www.example.org",
+			args:  []interface{}{"example.org"},
+			match: false,
+		},
+		{
+			host:  "example.org",
+			args:  []interface{}{"*.example.org"},
+			match: false,
+		},
+		{
+			host:  "wwwexample.org",
+			args:  []interface{}{".example.org"},
+			match: false,
+		},
+		{
+			host:  "api.example.com",
+			args:  []interface{}{"*.api.example.org"},
+			match: false,
+		},
+		{
+			host:  "",
+			args:  []interface{}{".example.org"},
+			match: false,
+		},
+		{
+			host:  "example.org",
+			args:  []interface{}{"example.org"},
+			match: true,
+		},
+		{
+			host:  "Example.ORG.:8080",
+			args:  []interface{}{"example.org"},
+			match: true,
+		},
+		{
+			host:  "www.example.org",
+			args:  []interface{}{"*.example.org"},
+			match: true,
+		},
+		{
+			host:  "v1.eu.api.example.org",
+			args:  []interface{}{"*.api.example.org"},
+			match: true,
+		},
+		{
+			host:  "example.org",
+			args:  []interface{}{".example.org"},
+			match: true,
+		},
+		{
+			host:  "www.example.org:443",
+			args:  []interface{}{".example.org"},
+			match: true,
+		},
+		{
+			host:  "www.example.org",
+			args:  []interface{}{"example.com", "*.example.org"},
+			match: true,
+		},
+		{
+			host:  "example.org",
+			args:  []interface{}{"*.example.org", "example.org"},
+			match: true,
+		},
+	} {
+		t.Run(fmt.Sprintf("%s->%v", tc.host, tc.args), func(t *testing.T) {
+			p, err := s.Create(tc.args)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if p.Match(&http.Request{Host: tc.host}) != tc.match {
+				t.Errorf("expected match: %v", tc.match)
+			}
+		})
+	}
+}
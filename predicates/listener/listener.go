@@ -0,0 +1,82 @@
+/*
+Package listener implements the Listener predicate, that matches the
+requests received on one of the named proxy listeners. It allows serving
+a subset of the routes only on some of the listeners, e.g. on an internal
+listener, when skipper is started with additional listeners.
+
+The requests received on the main proxy listener have the name "main".
+The routes without the Listener predicate match the requests received on
+any listener. The requests split from an incoming request by the proxy,
+e.g. by the teeLoopback filter, keep the listener name of the incoming
+request, so that they cannot match the routes of other listeners.
+
+Examples:
+
+	// served only on the internal listener
+	admin: Path("/admin") && Listener("internal") -> "https://admin.internal";
+
+	// served on the main and the public listeners
+	app: Listener("main", "public") -> "https://app.example.org";
+*/
+package listener
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/zalando/skipper/predicates"
+	"github.com/zalando/skipper/routing"
+)
+
+// Main is the name of the main proxy listener.
+const Main = "main"
+
+type contextKey struct{}
+
+type spec struct{}
+
+type predicate struct {
+	names map[string]bool
+}
+
+// NewContext returns a context carrying the name of the listener that
+// received the request. The proxy listeners set it as the base context
+// of the requests.
+func NewContext(ctx context.Context, name string) context.Context {
+	return context.WithValue(ctx, contextKey{}, name)
+}
+
+// FromContext returns the name of the listener that received the
+// request, or an empty string.
+func FromContext(ctx context.Context) string {
+	name, _ := ctx.Value(contextKey{}).(string)
+	return name
+}
+
+// New creates the Listener predicate specification. The predicate
+// accepts one or more listener names.
+func New() routing.PredicateSpec { return spec{} }
+
+func (spec) Name() string { return predicates.ListenerName }
+
+func (spec) Create(args []interface{}) (routing.Predicate, error) {
+	if len(args) == 0 {
+		return nil, predicates.ErrInvalidPredicateParameters
+	}
+
+	p := &predicate{names: make(map[string]bool)}
+	for _, a := range args {
+		name, ok := a.(string)
+		if !ok || name == "" {
+			return nil, predicates.ErrInvalidPredicateParameters
+		}
+
+		p.names[name] = true
+	}
+
+	return p, nil
+}
+
+func (p *predicate) Match(r *http.Request) bool {
+	return p.names[FromContext(r.Context())]
+}
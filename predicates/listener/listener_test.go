@@ -0,0 +1,45 @@
+package listener
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestCreate(t *testing.T) {
+	for _, args := range [][]interface{}{
+		nil,
+		{""},
+		{42.0},
+		{"internal", 42.0},
+	} {
+		if _, err := New().Create(args); err == nil {
+			t.Errorf("%v: failed to fail", args)
+		}
+	}
+}
+
+func TestMatch(t *testing.T) {
+	p, err := New().Create([]interface{}{"internal", Main})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range []struct {
+		listener string
+		match    bool
+	}{
+		{"", false},
+		{"public", false},
+		{"internal", true},
+		{Main, true},
+	} {
+		r, _ := http.NewRequest("GET", "https://www.example.org", nil)
+		if test.listener != "" {
+			r = r.WithContext(NewContext(r.Context(), test.listener))
+		}
+
+		if m := p.Match(r); m != test.match {
+			t.Errorf("%q: unexpected match: %v", test.listener, m)
+		}
+	}
+}
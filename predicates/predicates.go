@@ -18,6 +18,7 @@ const (
 	PathRegexpName            = "PathRegexp"
 	HostName                  = "Host"
 	HostAnyName               = "HostAny"
+	HostWildcardName          = "HostWildcard"
 	ForwardedHostName         = "ForwardedHost"
 	ForwardedProtocolName     = "ForwardedProtocol"
 	WeightName                = "Weight"
@@ -38,9 +39,29 @@ const (
 	BetweenName               = "Between"
 	CronName                  = "Cron"
 	QueryParamName            = "QueryParam"
+	QueryParamAnyName         = "QueryParamAny"
+	QueryParamAllName         = "QueryParamAll"
+	QueryParamAnyRegexpName   = "QueryParamAnyRegexp"
+	QueryParamAllRegexpName   = "QueryParamAllRegexp"
 	SourceName                = "Source"
 	SourceFromLastName        = "SourceFromLast"
+	SourceFromFileName        = "SourceFromFile"
 	ClientIPName              = "ClientIP"
 	TeeName                   = "Tee"
 	TrafficName               = "Traffic"
+	TrafficStickyName         = "TrafficSticky"
+	NotName                   = "Not"
+	ClientGeoName             = "ClientGeo"
+	ClientCertSANName         = "ClientCertSAN"
+	ClientCertSubjectName     = "ClientCertSubject"
+	ClientCertSPIFFEName      = "ClientCertSPIFFE"
+	BodyRegexpName            = "BodyRegexp"
+	BodyJSONPathName          = "BodyJSONPath"
+	BotScoreName              = "BotScore"
+	ListenerName              = "Listener"
+
+	JWTVerifiedPayloadAnyKVName       = "JWTVerifiedPayloadAnyKV"
+	JWTVerifiedPayloadAllKVName       = "JWTVerifiedPayloadAllKV"
+	JWTVerifiedPayloadAnyKVRegexpName = "JWTVerifiedPayloadAnyKVRegexp"
+	JWTVerifiedPayloadAllKVRegexpName = "JWTVerifiedPayloadAllKVRegexp"
 )
@@ -22,6 +22,20 @@ Examples:
 	// matches with regexp and multiple values of query param
 	// matches http://example.org?bb=a&query=testing&query=example
 	example1: QueryParam("query", "^example$") -> "http://example.org";
+
+The QueryParamAny and QueryParamAll predicates match the repeated query
+params against multiple values. QueryParamAny matches when any of the
+values is found, QueryParamAll when all of them are found. The Regexp
+variants match regular expressions instead of exact values.
+
+	// matches http://example.org?action=list and http://example.org?action=search
+	example2: QueryParamAny("action", "list", "search") -> "http://example.org";
+
+	// matches http://example.org?fields=id&fields=name&fields=email
+	example3: QueryParamAll("fields", "id", "name") -> "http://example.org";
+
+	// matches http://example.org?v=2.1&beta=true
+	example4: QueryParamAnyRegexp("v", "^2[.]") -> "http://example.org";
 */
 package query
 
@@ -47,9 +61,49 @@ type predicate struct {
 }
 type spec struct{}
 
+type valueMatcher func(string) bool
+
+type multiPredicate struct {
+	paramName string
+	all       bool
+	matchers  []valueMatcher
+}
+
+type multiSpec struct {
+	name   string
+	all    bool
+	regexp bool
+}
+
 // New creates a new QueryParam predicate specification.
 func New() routing.PredicateSpec { return &spec{} }
 
+// NewAny creates a new QueryParamAny predicate specification, matching
+// when any of the values of the query param is equal to any of the
+// arguments.
+func NewAny() routing.PredicateSpec {
+	return &multiSpec{name: predicates.QueryParamAnyName}
+}
+
+// NewAll creates a new QueryParamAll predicate specification, matching
+// when each of the arguments is equal to at least one of the values of the
+// query param.
+func NewAll() routing.PredicateSpec {
+	return &multiSpec{name: predicates.QueryParamAllName, all: true}
+}
+
+// NewAnyRegexp creates a new QueryParamAnyRegexp predicate specification,
+// the same as QueryParamAny, but the arguments are regular expressions.
+func NewAnyRegexp() routing.PredicateSpec {
+	return &multiSpec{name: predicates.QueryParamAnyRegexpName, regexp: true}
+}
+
+// NewAllRegexp creates a new QueryParamAllRegexp predicate specification,
+// the same as QueryParamAll, but the arguments are regular expressions.
+func NewAllRegexp() routing.PredicateSpec {
+	return &multiSpec{name: predicates.QueryParamAllRegexpName, all: true, regexp: true}
+}
+
 func (s *spec) Name() string {
 	return predicates.QueryParamName
 }
@@ -105,3 +159,70 @@ func (p *predicate) Match(r *http.Request) bool {
 
 	return false
 }
+
+func (s *multiSpec) Name() string {
+	return s.name
+}
+
+func (s *multiSpec) Create(args []interface{}) (routing.Predicate, error) {
+	if len(args) < 2 {
+		return nil, predicates.ErrInvalidPredicateParameters
+	}
+
+	name, ok := args[0].(string)
+	if !ok {
+		return nil, predicates.ErrInvalidPredicateParameters
+	}
+
+	p := &multiPredicate{paramName: name, all: s.all}
+	for _, a := range args[1:] {
+		value, ok := a.(string)
+		if !ok {
+			return nil, predicates.ErrInvalidPredicateParameters
+		}
+
+		if !s.regexp {
+			p.matchers = append(p.matchers, func(v string) bool { return v == value })
+			continue
+		}
+
+		rx, err := regexp.Compile(value)
+		if err != nil {
+			return nil, err
+		}
+
+		p.matchers = append(p.matchers, rx.MatchString)
+	}
+
+	return p, nil
+}
+
+func matchAnyValue(m valueMatcher, vals []string) bool {
+	for _, v := range vals {
+		if m(v) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (p *multiPredicate) Match(r *http.Request) bool {
+	vals, ok := r.URL.Query()[p.paramName]
+	if !ok {
+		return false
+	}
+
+	for _, m := range p.matchers {
+		found := matchAnyValue(m, vals)
+		if p.all && !found {
+			return false
+		}
+
+		if !p.all && found {
+			return true
+		}
+	}
+
+	return p.all
+}
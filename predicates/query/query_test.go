@@ -3,6 +3,8 @@ package query
 import (
 	"net/http"
 	"testing"
+
+	"github.com/zalando/skipper/routing"
 )
 
 func TestQueryArgs(t *testing.T) {
@@ -155,3 +157,130 @@ func TestMatchArgs(t *testing.T) {
 		}()
 	}
 }
+
+func TestMultiArgs(t *testing.T) {
+	for _, ti := range []struct {
+		msg  string
+		spec routing.PredicateSpec
+		args []interface{}
+		err  bool
+	}{{
+		"too few args",
+		NewAny(),
+		[]interface{}{"key"},
+		true,
+	}, {
+		"invalid type key",
+		NewAll(),
+		[]interface{}{5, "value"},
+		true,
+	}, {
+		"invalid type value",
+		NewAny(),
+		[]interface{}{"key", "value", 5},
+		true,
+	}, {
+		"invalid regexp",
+		NewAnyRegexp(),
+		[]interface{}{"key", `\`},
+		true,
+	}, {
+		"exact values are not compiled",
+		NewAll(),
+		[]interface{}{"key", `\`},
+		false,
+	}, {
+		"multiple regexps",
+		NewAllRegexp(),
+		[]interface{}{"key", "^a", "b$"},
+		false,
+	}} {
+		_, err := ti.spec.Create(ti.args)
+		if ti.err && err == nil {
+			t.Error(ti.msg, "failed to fail")
+		} else if !ti.err && err != nil {
+			t.Error(ti.msg, err)
+		}
+	}
+}
+
+func TestMultiMatch(t *testing.T) {
+	for _, ti := range []struct {
+		msg   string
+		spec  routing.PredicateSpec
+		args  []interface{}
+		query string
+		match bool
+	}{{
+		"any, one of the values",
+		NewAny(),
+		[]interface{}{"action", "list", "search"},
+		"action=search",
+		true,
+	}, {
+		"any, none of the values",
+		NewAny(),
+		[]interface{}{"action", "list", "search"},
+		"action=delete",
+		false,
+	}, {
+		"any, repeated param",
+		NewAny(),
+		[]interface{}{"action", "list", "search"},
+		"action=delete&action=list",
+		true,
+	}, {
+		"any, missing param",
+		NewAny(),
+		[]interface{}{"action", "list"},
+		"foo=list",
+		false,
+	}, {
+		"all, every value found",
+		NewAll(),
+		[]interface{}{"fields", "id", "name"},
+		"fields=name&fields=email&fields=id",
+		true,
+	}, {
+		"all, a value missing",
+		NewAll(),
+		[]interface{}{"fields", "id", "name"},
+		"fields=name&fields=email",
+		false,
+	}, {
+		"all, exact match",
+		NewAll(),
+		[]interface{}{"fields", "id"},
+		"fields=ids",
+		false,
+	}, {
+		"any regexp",
+		NewAnyRegexp(),
+		[]interface{}{"v", "^1[.]", "^2[.]"},
+		"v=2.1",
+		true,
+	}, {
+		"all regexp",
+		NewAllRegexp(),
+		[]interface{}{"tag", "^team-", "^env-"},
+		"tag=env-prod&tag=team-a",
+		true,
+	}, {
+		"all regexp, one not matched",
+		NewAllRegexp(),
+		[]interface{}{"tag", "^team-", "^env-"},
+		"tag=team-a&tag=team-b",
+		false,
+	}} {
+		p, err := ti.spec.Create(ti.args)
+		if err != nil {
+			t.Error(ti.msg, err)
+			continue
+		}
+
+		req, _ := http.NewRequest("GET", "http://example.com/?"+ti.query, nil)
+		if m := p.Match(req); m != ti.match {
+			t.Error(ti.msg, "failed to match", m, ti.match)
+		}
+	}
+}
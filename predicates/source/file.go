@@ -0,0 +1,215 @@
+package source
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	snet "github.com/zalando/skipper/net"
+	"github.com/zalando/skipper/predicates"
+	"github.com/zalando/skipper/routing"
+)
+
+const defaultFileReloadInterval = 10 * time.Second
+
+// FromFileOptions are used to create the SourceFromFile predicate
+// specification.
+type FromFileOptions struct {
+
+	// ReloadInterval defines how often the files are checked for changes.
+	// Defaults to 10 seconds.
+	ReloadInterval time.Duration
+}
+
+// FromFileSpec is the specification of the SourceFromFile predicate. It
+// needs to be closed, when not used anymore.
+type FromFileSpec struct {
+	options FromFileOptions
+	mu      sync.Mutex
+	files   map[string]*ipSetFile
+	started bool
+	quit    chan struct{}
+	once    sync.Once
+}
+
+type ipSetFile struct {
+	path    string
+	nets    atomic.Value // snet.IPNets
+	modTime time.Time
+	size    int64
+}
+
+type filePredicate struct {
+	file *ipSetFile
+}
+
+// NewFromFile creates the SourceFromFile predicate specification. The
+// predicate matches the source IP of the requests, like the Source
+// predicate, against the networks listed in a file. The file contains one
+// IP address or CIDR per line, and the lines starting with # are ignored.
+// The file is reloaded when it changes, without updating the routes. The
+// routes referencing the same file share the same network set.
+//
+// Example:
+//
+//	SourceFromFile("/etc/skipper/office-networks.txt")
+func NewFromFile(o FromFileOptions) *FromFileSpec {
+	if o.ReloadInterval <= 0 {
+		o.ReloadInterval = defaultFileReloadInterval
+	}
+
+	return &FromFileSpec{
+		options: o,
+		files:   make(map[string]*ipSetFile),
+		quit:    make(chan struct{}),
+	}
+}
+
+func parseIPSet(b []byte) (snet.IPNets, error) {
+	var nets snet.IPNets
+	s := bufio.NewScanner(bytes.NewReader(b))
+	for line := 1; s.Scan(); line++ {
+		v := strings.TrimSpace(s.Text())
+		if v == "" || strings.HasPrefix(v, "#") {
+			continue
+		}
+
+		if !strings.Contains(v, "/") {
+			ip := net.ParseIP(v)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid IP address in line %d: %s", line, v)
+			}
+
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				bits = 8 * net.IPv4len
+			}
+
+			nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+
+		_, n, err := net.ParseCIDR(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CIDR in line %d: %s", line, v)
+		}
+
+		nets = append(nets, n)
+	}
+
+	return nets, s.Err()
+}
+
+func (f *ipSetFile) load() error {
+	fi, err := os.Stat(f.path)
+	if err != nil {
+		return err
+	}
+
+	b, err := os.ReadFile(f.path)
+	if err != nil {
+		return err
+	}
+
+	nets, err := parseIPSet(b)
+	if err != nil {
+		return fmt.Errorf("failed to load %s: %w", f.path, err)
+	}
+
+	f.nets.Store(nets)
+	f.modTime = fi.ModTime()
+	f.size = fi.Size()
+	return nil
+}
+
+func (f *ipSetFile) reload() {
+	fi, err := os.Stat(f.path)
+	if err != nil {
+		log.Errorf("Failed to check source file %s: %v", f.path, err)
+		return
+	}
+
+	if fi.ModTime().Equal(f.modTime) && fi.Size() == f.size {
+		return
+	}
+
+	if err := f.load(); err != nil {
+		// keep using the previous version
+		log.Errorf("Failed to reload source file: %v", err)
+		return
+	}
+
+	log.Infof("Source file reloaded: %s", f.path)
+}
+
+func (s *FromFileSpec) watch() {
+	t := time.NewTicker(s.options.ReloadInterval)
+	defer t.Stop()
+	for {
+		select {
+		case <-t.C:
+			s.mu.Lock()
+			files := make([]*ipSetFile, 0, len(s.files))
+			for _, f := range s.files {
+				files = append(files, f)
+			}
+			s.mu.Unlock()
+
+			for _, f := range files {
+				f.reload()
+			}
+		case <-s.quit:
+			return
+		}
+	}
+}
+
+func (*FromFileSpec) Name() string { return predicates.SourceFromFileName }
+
+func (s *FromFileSpec) Create(args []interface{}) (routing.Predicate, error) {
+	if len(args) != 1 {
+		return nil, InvalidArgsError
+	}
+
+	path, ok := args[0].(string)
+	if !ok || path == "" {
+		return nil, InvalidArgsError
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if f, ok := s.files[path]; ok {
+		return &filePredicate{file: f}, nil
+	}
+
+	f := &ipSetFile{path: path}
+	if err := f.load(); err != nil {
+		return nil, err
+	}
+
+	s.files[path] = f
+	if !s.started {
+		s.started = true
+		go s.watch()
+	}
+
+	return &filePredicate{file: f}, nil
+}
+
+// Close stops watching the files.
+func (s *FromFileSpec) Close() {
+	s.once.Do(func() { close(s.quit) })
+}
+
+func (p *filePredicate) Match(r *http.Request) bool {
+	return p.file.nets.Load().(snet.IPNets).Contain(snet.RemoteHost(r))
+}
@@ -0,0 +1,131 @@
+package source
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/zalando/skipper/predicates"
+)
+
+func writeFile(t *testing.T, path, content string, modTime time.Time) {
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.Chtimes(path, modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFromFileCreate(t *testing.T) {
+	dir := t.TempDir()
+	valid := filepath.Join(dir, "valid.txt")
+	writeFile(t, valid, "# office\n10.0.0.0/8\n\n192.168.1.1\n2001:db8::/32\n", time.Now())
+	invalid := filepath.Join(dir, "invalid.txt")
+	writeFile(t, invalid, "10.0.0.0/8\nfoo\n", time.Now())
+
+	s := NewFromFile(FromFileOptions{})
+	defer s.Close()
+
+	if s.Name() != predicates.SourceFromFileName {
+		t.Fatalf("unexpected name: %s", s.Name())
+	}
+
+	for _, test := range []struct {
+		title string
+		args  []interface{}
+		fail  bool
+	}{{
+		title: "no args",
+		fail:  true,
+	}, {
+		title: "too many args",
+		args:  []interface{}{valid, valid},
+		fail:  true,
+	}, {
+		title: "not a string",
+		args:  []interface{}{42},
+		fail:  true,
+	}, {
+		title: "file not found",
+		args:  []interface{}{filepath.Join(dir, "missing.txt")},
+		fail:  true,
+	}, {
+		title: "invalid file",
+		args:  []interface{}{invalid},
+		fail:  true,
+	}, {
+		title: "valid file",
+		args:  []interface{}{valid},
+	}} {
+		t.Run(test.title, func(t *testing.T) {
+			_, err := s.Create(test.args)
+			if test.fail && err == nil {
+				t.Fatal("failed to fail")
+			} else if !test.fail && err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestFromFileMatchAndReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "networks.txt")
+	writeFile(t, path, "10.0.0.0/8\n192.168.1.1\n2001:db8::/32\n", time.Now().Add(-time.Hour))
+
+	s := NewFromFile(FromFileOptions{ReloadInterval: 10 * time.Millisecond})
+	defer s.Close()
+
+	p1, err := s.Create([]interface{}{path})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p2, err := s.Create([]interface{}{path})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	match := func(remoteAddr string) bool {
+		r := &http.Request{RemoteAddr: remoteAddr, Header: make(http.Header)}
+		m1, m2 := p1.Match(r), p2.Match(r)
+		if m1 != m2 {
+			t.Fatalf("predicates of the same file don't match the same: %s", remoteAddr)
+		}
+
+		return m1
+	}
+
+	for addr, expected := range map[string]bool{
+		"10.1.2.3:8080":      true,
+		"192.168.1.1:8080":   true,
+		"192.168.1.2:8080":   false,
+		"[2001:db8::1]:8080": true,
+		"[2001:db9::1]:8080": false,
+	} {
+		if m := match(addr); m != expected {
+			t.Errorf("unexpected match result for %s: %v", addr, m)
+		}
+	}
+
+	// invalid content is ignored, the previous version is used
+	writeFile(t, path, "foo\n", time.Now().Add(-time.Minute))
+	time.Sleep(50 * time.Millisecond)
+	if !match("10.1.2.3:8080") {
+		t.Fatal("invalid file replaced the networks")
+	}
+
+	writeFile(t, path, "172.16.0.0/12\n", time.Now())
+	for i := 0; i < 100; i++ {
+		if match("172.16.1.1:8080") && !match("10.1.2.3:8080") {
+			return
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	t.Fatal("failed to reload the file")
+}
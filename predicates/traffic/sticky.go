@@ -0,0 +1,122 @@
+package traffic
+
+import (
+	"net/http"
+
+	"github.com/cespare/xxhash/v2"
+
+	snet "github.com/zalando/skipper/net"
+	"github.com/zalando/skipper/predicates"
+	"github.com/zalando/skipper/routing"
+)
+
+const (
+	stickyKeyCookie = "cookie"
+	stickyKeyHeader = "header"
+	stickyKeyIP     = "ip"
+)
+
+type stickySpec struct{}
+
+type stickyPredicate struct {
+	chance  float64
+	keyType string
+	keyName string
+}
+
+// NewSticky creates the TrafficSticky predicate specification. Unlike
+// Traffic, it doesn't make a random decision for every request, but it
+// hashes a stable client key into a bucket between 0.0 and 1.0, and
+// matches when the bucket is lower than the chance argument. This way
+// the same clients always match the route, and when the chance is
+// increased, the clients matching with the lower chance keep matching.
+//
+// The key can be taken from a cookie, a header or the source IP of the
+// request. When the key is not present in the request, the predicate
+// doesn't match.
+//
+// Examples:
+//
+//	TrafficSticky(.1, "cookie", "session-id")
+//	TrafficSticky(.25, "header", "X-User-Id")
+//	TrafficSticky(.05, "ip")
+func NewSticky() routing.PredicateSpec { return &stickySpec{} }
+
+func (*stickySpec) Name() string { return predicates.TrafficStickyName }
+
+func (*stickySpec) Create(args []interface{}) (routing.Predicate, error) {
+	if len(args) < 2 || len(args) > 3 {
+		return nil, predicates.ErrInvalidPredicateParameters
+	}
+
+	chance, ok := args[0].(float64)
+	if !ok || chance < 0 || chance > 1 {
+		return nil, predicates.ErrInvalidPredicateParameters
+	}
+
+	keyType, ok := args[1].(string)
+	if !ok {
+		return nil, predicates.ErrInvalidPredicateParameters
+	}
+
+	p := &stickyPredicate{chance: chance, keyType: keyType}
+	switch keyType {
+	case stickyKeyCookie, stickyKeyHeader:
+		if len(args) != 3 {
+			return nil, predicates.ErrInvalidPredicateParameters
+		}
+
+		if p.keyName, ok = args[2].(string); !ok || p.keyName == "" {
+			return nil, predicates.ErrInvalidPredicateParameters
+		}
+	case stickyKeyIP:
+		if len(args) != 2 {
+			return nil, predicates.ErrInvalidPredicateParameters
+		}
+	default:
+		return nil, predicates.ErrInvalidPredicateParameters
+	}
+
+	return p, nil
+}
+
+func (p *stickyPredicate) key(r *http.Request) string {
+	switch p.keyType {
+	case stickyKeyCookie:
+		if c, err := r.Cookie(p.keyName); err == nil {
+			return c.Value
+		}
+
+		return ""
+	case stickyKeyHeader:
+		return r.Header.Get(p.keyName)
+	default:
+		if ip := snet.RemoteHost(r); ip != nil {
+			return ip.String()
+		}
+
+		return ""
+	}
+}
+
+// maps the key to [0.0, 1.0) using the upper 53 bits of the hash
+func bucket(key string) float64 {
+	return float64(xxhash.Sum64String(key)>>11) / (1 << 53)
+}
+
+func (p *stickyPredicate) Match(r *http.Request) bool {
+	if p.chance == 0 {
+		return false
+	}
+
+	if p.chance == 1 {
+		return true
+	}
+
+	k := p.key(r)
+	if k == "" {
+		return false
+	}
+
+	return bucket(k) < p.chance
+}
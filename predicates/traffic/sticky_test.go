@@ -0,0 +1,151 @@
+package traffic
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"testing"
+
+	"github.com/zalando/skipper/predicates"
+)
+
+func TestStickyCreate(t *testing.T) {
+	for _, test := range []struct {
+		title string
+		args  []interface{}
+		fail  bool
+	}{{
+		title: "no args",
+		fail:  true,
+	}, {
+		title: "only chance",
+		args:  []interface{}{.3},
+		fail:  true,
+	}, {
+		title: "chance not a number",
+		args:  []interface{}{"0.3", "ip"},
+		fail:  true,
+	}, {
+		title: "chance out of range",
+		args:  []interface{}{1.3, "ip"},
+		fail:  true,
+	}, {
+		title: "unknown key type",
+		args:  []interface{}{.3, "query", "user"},
+		fail:  true,
+	}, {
+		title: "cookie without name",
+		args:  []interface{}{.3, "cookie"},
+		fail:  true,
+	}, {
+		title: "header with empty name",
+		args:  []interface{}{.3, "header", ""},
+		fail:  true,
+	}, {
+		title: "ip with name",
+		args:  []interface{}{.3, "ip", "foo"},
+		fail:  true,
+	}, {
+		title: "cookie",
+		args:  []interface{}{.3, "cookie", "session"},
+	}, {
+		title: "header",
+		args:  []interface{}{.3, "header", "X-User-Id"},
+	}, {
+		title: "ip",
+		args:  []interface{}{0.0, "ip"},
+	}} {
+		t.Run(test.title, func(t *testing.T) {
+			s := NewSticky()
+			if s.Name() != predicates.TrafficStickyName {
+				t.Fatalf("unexpected name: %s", s.Name())
+			}
+
+			_, err := s.Create(test.args)
+			if test.fail && err == nil {
+				t.Fatal("failed to fail")
+			} else if !test.fail && err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestStickyMatch(t *testing.T) {
+	create := func(args ...interface{}) *stickyPredicate {
+		p, err := NewSticky().Create(args)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return p.(*stickyPredicate)
+	}
+
+	t.Run("missing key", func(t *testing.T) {
+		p := create(.5, "header", "X-User-Id")
+		if p.Match(&http.Request{Header: http.Header{}}) {
+			t.Fatal("unexpected match without key")
+		}
+	})
+
+	t.Run("consistent per key", func(t *testing.T) {
+		for _, p := range []*stickyPredicate{
+			create(.5, "cookie", "session"),
+			create(.5, "header", "X-User-Id"),
+			create(.5, "ip"),
+		} {
+			for i := 0; i < 100; i++ {
+				r := &http.Request{
+					Header: http.Header{
+						"Cookie":    []string{fmt.Sprintf("session=s%d", i)},
+						"X-User-Id": []string{fmt.Sprintf("u%d", i)},
+					},
+					RemoteAddr: fmt.Sprintf("10.0.0.%d:4242", i),
+				}
+
+				m := p.Match(r)
+				for j := 0; j < 10; j++ {
+					if p.Match(r) != m {
+						t.Fatalf("%s: inconsistent match for request %d", p.keyType, i)
+					}
+				}
+			}
+		}
+	})
+
+	t.Run("distribution and ramp up", func(t *testing.T) {
+		const n = 10000
+		p10, p30 := create(.1, "header", "X-User-Id"), create(.3, "header", "X-User-Id")
+		var m10, m30 int
+		for i := 0; i < n; i++ {
+			r := &http.Request{Header: http.Header{"X-User-Id": []string{fmt.Sprintf("user-%d", i)}}}
+			a, b := p10.Match(r), p30.Match(r)
+			if a && !b {
+				t.Fatalf("client matching with the lower chance doesn't match with the higher: %d", i)
+			}
+
+			if a {
+				m10++
+			}
+
+			if b {
+				m30++
+			}
+		}
+
+		for _, c := range []struct {
+			chance  float64
+			matched int
+		}{{.1, m10}, {.3, m30}} {
+			if math.Abs(float64(c.matched)/n-c.chance) > .02 {
+				t.Errorf("unexpected distribution for %v: %d of %d", c.chance, c.matched, n)
+			}
+		}
+	})
+
+	t.Run("full chance without key", func(t *testing.T) {
+		if !create(1.0, "cookie", "session").Match(&http.Request{Header: http.Header{}}) {
+			t.Fatal("failed to match with full chance")
+		}
+	})
+}
@@ -0,0 +1,471 @@
+/*
+Package profiles implements named configuration profiles, that bundle
+the timeouts, the filters and the TLS policy of the routes. The routes
+reference a profile instead of repeating the same settings, so that a
+platform wide policy change touches only the profile.
+
+The profiles are loaded from a YAML file, together with the hosts and the
+Kubernetes route groups they are attached to:
+
+	# profiles.yaml
+	profiles:
+	  standard:
+	    timeouts:
+	      backend: 30s
+	      dial: 500ms
+	      responseHeader: 10s
+	    filters: enableAccessLog(4, 5) -> fifo(1000, 100, "10s")
+	    tls:
+	      minVersion: "1.2"
+	      hsts: max-age=31536000; includeSubDomains
+	  internal:
+	    timeouts:
+	      backend: 5m
+	hosts:
+	  api.example.org: standard
+	routeGroups:
+	  team-a/reports: internal
+
+A route gets the settings of a profile in one of the following ways, in
+the order of precedence:
+
+  - it contains the profile filter, e.g. profile("standard"), that is
+    replaced by the filters of the profile
+  - it was created from a route group attached to the profile, referenced
+    as namespace/name
+  - one of its Host or HostAny predicates matches a host attached to the
+    profile
+
+Only a single profile is applied to a route. The filters of an attached
+profile are prepended to the filters of the route, so that the settings
+of the route itself, e.g. its own backendTimeout, override the profile.
+The settings are converted to the backendTimeout, backendTransport,
+minTLSVersion and setResponseHeader filters. The file is reloaded when it
+changes, and the changed profiles are applied to all the routes.
+*/
+package profiles
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v2"
+
+	"github.com/zalando/skipper/eskip"
+	"github.com/zalando/skipper/filters"
+	"github.com/zalando/skipper/predicates"
+	"github.com/zalando/skipper/routing"
+)
+
+// FilterName is the name of the filter referencing a profile in a route.
+// It is replaced by the filters of the profile.
+const FilterName = "profile"
+
+// the routing reprocesses all the routes when a data client reports a
+// change, and the profiles report the reload as the deletion of this
+// id, which never matches a route
+const reloadID = "__profiles_reload"
+
+// Timeouts of a profile.
+type Timeouts struct {
+	// Backend sets the backendTimeout filter.
+	Backend string `yaml:"backend"`
+
+	// Dial, TLSHandshake, ResponseHeader and IdleConn set the settings
+	// of the backendTransport filter.
+	Dial           string `yaml:"dial"`
+	TLSHandshake   string `yaml:"tlsHandshake"`
+	ResponseHeader string `yaml:"responseHeader"`
+	IdleConn       string `yaml:"idleConn"`
+}
+
+// TLS policy of a profile.
+type TLS struct {
+	// MinVersion sets the minTLSVersion filter, e.g. "1.2".
+	MinVersion string `yaml:"minVersion"`
+
+	// HSTS sets the value of the Strict-Transport-Security response
+	// header.
+	HSTS string `yaml:"hsts"`
+}
+
+// Profile is the definition of a profile in the profiles file.
+type Profile struct {
+	Timeouts Timeouts `yaml:"timeouts"`
+
+	// Filters is an eskip filter chain, e.g. `enableAccessLog(4, 5)`.
+	Filters string `yaml:"filters"`
+
+	TLS TLS `yaml:"tls"`
+}
+
+// Config is the content of the profiles file.
+type Config struct {
+	// Profiles contains the profiles by name.
+	Profiles map[string]Profile `yaml:"profiles"`
+
+	// Hosts attaches the profiles to the hosts, e.g. api.example.org.
+	Hosts map[string]string `yaml:"hosts"`
+
+	// RouteGroups attaches the profiles to the Kubernetes route groups,
+	// referenced as namespace/name.
+	RouteGroups map[string]string `yaml:"routeGroups"`
+}
+
+type routeGroup struct {
+	prefix  string
+	profile string
+}
+
+type config struct {
+	profiles    map[string][]*eskip.Filter
+	hosts       map[string]string
+	routeGroups []routeGroup
+}
+
+// Profiles applies the profiles loaded from a file to the routes. It
+// needs to be set both as a routing pre-processor, and as a data client,
+// through which the changes of the file are detected.
+type Profiles struct {
+	file    string
+	config  atomic.Value // *config
+	mu      sync.Mutex
+	content []byte
+}
+
+var (
+	_ routing.PreProcessor = &Profiles{}
+	_ routing.DataClient   = &Profiles{}
+)
+
+func checkDuration(name, d string) error {
+	if _, err := time.ParseDuration(d); err != nil {
+		return fmt.Errorf("invalid %s timeout: %w", name, err)
+	}
+
+	return nil
+}
+
+// returns the filters implementing the settings of a profile
+func profileFilters(p Profile) ([]*eskip.Filter, error) {
+	var f []*eskip.Filter
+	t := p.Timeouts
+	if t.Backend != "" {
+		if err := checkDuration("backend", t.Backend); err != nil {
+			return nil, err
+		}
+
+		f = append(f, &eskip.Filter{Name: filters.BackendTimeoutName, Args: []interface{}{t.Backend}})
+	}
+
+	var transport []interface{}
+	for _, s := range []struct{ name, arg, value string }{
+		{"dial", "dialTimeout", t.Dial},
+		{"tlsHandshake", "tlsHandshakeTimeout", t.TLSHandshake},
+		{"responseHeader", "responseHeaderTimeout", t.ResponseHeader},
+		{"idleConn", "idleConnTimeout", t.IdleConn},
+	} {
+		if s.value == "" {
+			continue
+		}
+
+		if err := checkDuration(s.name, s.value); err != nil {
+			return nil, err
+		}
+
+		transport = append(transport, s.arg, s.value)
+	}
+
+	if len(transport) > 0 {
+		f = append(f, &eskip.Filter{Name: filters.BackendTransportName, Args: transport})
+	}
+
+	if p.TLS.MinVersion != "" {
+		f = append(f, &eskip.Filter{Name: filters.MinTLSVersionName, Args: []interface{}{p.TLS.MinVersion}})
+	}
+
+	if p.TLS.HSTS != "" {
+		f = append(f, &eskip.Filter{
+			Name: filters.SetResponseHeaderName,
+			Args: []interface{}{"Strict-Transport-Security", p.TLS.HSTS},
+		})
+	}
+
+	cf, err := eskip.ParseFilters(p.Filters)
+	if err != nil {
+		return nil, fmt.Errorf("invalid filters: %w", err)
+	}
+
+	return append(f, cf...), nil
+}
+
+// the prefix of the ids of the routes created from a route group by the
+// Kubernetes data client
+func routeGroupPrefix(namespace, name string) string {
+	symbol := func(s string) string {
+		return strings.Map(func(r rune) rune {
+			if r == '_' || r >= '0' && r <= '9' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' {
+				return r
+			}
+
+			return '_'
+		}, s)
+	}
+
+	return fmt.Sprintf("__%s__%s__", symbol(namespace), symbol(name))
+}
+
+func parse(data []byte) (*config, error) {
+	var cfg Config
+	if err := yaml.UnmarshalStrict(data, &cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse profiles: %w", err)
+	}
+
+	c := &config{
+		profiles: make(map[string][]*eskip.Filter),
+		hosts:    make(map[string]string),
+	}
+
+	for name, p := range cfg.Profiles {
+		f, err := profileFilters(p)
+		if err != nil {
+			return nil, fmt.Errorf("invalid profile %s: %w", name, err)
+		}
+
+		c.profiles[name] = f
+	}
+
+	for host, name := range cfg.Hosts {
+		if _, ok := c.profiles[name]; !ok {
+			return nil, fmt.Errorf("profile of host %s not found: %s", host, name)
+		}
+
+		c.hosts[strings.ToLower(host)] = name
+	}
+
+	for rg, name := range cfg.RouteGroups {
+		if _, ok := c.profiles[name]; !ok {
+			return nil, fmt.Errorf("profile of route group %s not found: %s", rg, name)
+		}
+
+		ns, n, ok := strings.Cut(rg, "/")
+		if !ok || ns == "" || n == "" {
+			return nil, fmt.Errorf("invalid route group reference, expected namespace/name: %s", rg)
+		}
+
+		c.routeGroups = append(c.routeGroups, routeGroup{prefix: routeGroupPrefix(ns, n), profile: name})
+	}
+
+	// different route groups can have the same prefix after replacing
+	// the special characters, e.g. my-app and my.app, and the first one
+	// is taken in a deterministic order
+	sort.Slice(c.routeGroups, func(i, j int) bool {
+		ri, rj := c.routeGroups[i], c.routeGroups[j]
+		return ri.prefix < rj.prefix || ri.prefix == rj.prefix && ri.profile < rj.profile
+	})
+
+	return c, nil
+}
+
+// New loads the profiles from a file. It fails when the file cannot be
+// read, or it contains invalid profiles.
+func New(file string) (*Profiles, error) {
+	if file == "" {
+		return nil, errors.New("missing profiles file")
+	}
+
+	p := &Profiles{file: file}
+	if _, err := p.reload(); err != nil {
+		return nil, err
+	}
+
+	return p, nil
+}
+
+// reload reads the file, and replaces the profiles when it has changed.
+// It keeps the previous profiles when the file cannot be read, or is
+// invalid.
+func (p *Profiles) reload() (bool, error) {
+	data, err := os.ReadFile(p.file)
+	if err != nil {
+		return false, fmt.Errorf("failed to read profiles: %w", err)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.content != nil && bytes.Equal(data, p.content) {
+		return false, nil
+	}
+
+	c, err := parse(data)
+	if err != nil {
+		return false, err
+	}
+
+	p.content = data
+	p.config.Store(c)
+	return true, nil
+}
+
+// LoadAll implements routing.DataClient. It returns no routes, it only
+// checks the file for changes.
+func (p *Profiles) LoadAll() ([]*eskip.Route, error) {
+	if _, err := p.reload(); err != nil {
+		log.Errorf("Failed to reload profiles from %s, keeping the previous profiles: %v", p.file, err)
+	}
+
+	return nil, nil
+}
+
+// LoadUpdate implements routing.DataClient. When the file has changed, it
+// reports an update, so that the routes are processed with the changed
+// profiles.
+func (p *Profiles) LoadUpdate() ([]*eskip.Route, []string, error) {
+	changed, err := p.reload()
+	if err != nil {
+		log.Errorf("Failed to reload profiles from %s, keeping the previous profiles: %v", p.file, err)
+		return nil, nil, nil
+	}
+
+	if !changed {
+		return nil, nil, nil
+	}
+
+	log.Infof("Profiles reloaded from %s", p.file)
+	return nil, []string{reloadID}, nil
+}
+
+func (c *config) routeGroupProfile(id string) (string, bool) {
+	if !strings.HasPrefix(id, "kube_rg__") {
+		return "", false
+	}
+
+	id = strings.TrimPrefix(id, "kube_rg")
+	id = strings.Replace(id, "__internal_", "__", 1)
+	for _, rg := range c.routeGroups {
+		if strings.HasPrefix(id, rg.prefix) {
+			return rg.profile, true
+		}
+	}
+
+	return "", false
+}
+
+func (c *config) hostProfile(r *eskip.Route) (string, bool) {
+	for _, p := range eskip.Canonical(r).Predicates {
+		for _, a := range p.Args {
+			h, ok := a.(string)
+			if !ok {
+				continue
+			}
+
+			switch p.Name {
+			case predicates.HostAnyName:
+				if name, ok := c.hosts[strings.ToLower(h)]; ok {
+					return name, true
+				}
+			case predicates.HostName:
+				rx, err := regexp.Compile(h)
+				if err != nil {
+					continue
+				}
+
+				// the order of the hosts is not defined, so the
+				// first match in the sorted order is taken
+				var matched []string
+				for host := range c.hosts {
+					if rx.MatchString(host) {
+						matched = append(matched, host)
+					}
+				}
+
+				if len(matched) > 0 {
+					sort.Strings(matched)
+					return c.hosts[matched[0]], true
+				}
+			}
+		}
+	}
+
+	return "", false
+}
+
+// expands the profile filters of the route, and tells whether it had
+// any
+func (c *config) expand(r *eskip.Route) ([]*eskip.Filter, bool, error) {
+	var (
+		result []*eskip.Filter
+		found  bool
+	)
+
+	for _, f := range r.Filters {
+		if f.Name != FilterName {
+			result = append(result, f)
+			continue
+		}
+
+		if len(f.Args) != 1 {
+			return nil, true, errors.New("invalid profile filter arguments")
+		}
+
+		name, _ := f.Args[0].(string)
+		pf, ok := c.profiles[name]
+		if !ok {
+			return nil, true, fmt.Errorf("profile not found: %v", f.Args[0])
+		}
+
+		found = true
+		result = append(result, eskip.CopyFilters(pf)...)
+	}
+
+	return result, found, nil
+}
+
+// Do implements routing.PreProcessor. It returns the routes with the
+// filters of their profiles. The routes referencing a missing profile
+// are dropped. The input routes are not modified.
+func (p *Profiles) Do(routes []*eskip.Route) []*eskip.Route {
+	c, _ := p.config.Load().(*config)
+	if c == nil {
+		return routes
+	}
+
+	result := make([]*eskip.Route, 0, len(routes))
+	for _, r := range routes {
+		filters, found, err := c.expand(r)
+		if err != nil {
+			log.Errorf("Failed to apply the profile of route %s: %v", r.Id, err)
+			continue
+		}
+
+		if !found {
+			name, ok := c.routeGroupProfile(r.Id)
+			if !ok {
+				name, ok = c.hostProfile(r)
+			}
+
+			if !ok {
+				result = append(result, r)
+				continue
+			}
+
+			filters = append(eskip.CopyFilters(c.profiles[name]), r.Filters...)
+		}
+
+		next := new(eskip.Route)
+		*next = *r
+		next.Filters = filters
+		result = append(result, next)
+	}
+
+	return result
+}
@@ -0,0 +1,180 @@
+package profiles
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/zalando/skipper/eskip"
+)
+
+const testProfiles = `
+profiles:
+  standard:
+    timeouts:
+      backend: 30s
+      dial: 500ms
+      responseHeader: 10s
+    filters: enableAccessLog(4, 5)
+    tls:
+      minVersion: "1.2"
+      hsts: max-age=31536000
+  internal:
+    timeouts:
+      backend: 5m
+hosts:
+  api.example.org: standard
+  www.example.org: internal
+routeGroups:
+  team-a/reports: internal
+`
+
+func writeProfiles(t *testing.T, file, profiles string) {
+	t.Helper()
+	if err := os.WriteFile(file, []byte(profiles), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func filterChains(routes []*eskip.Route) map[string]string {
+	m := make(map[string]string)
+	for _, r := range routes {
+		m[r.Id] = eskip.Print(eskip.PrettyPrintInfo{}, &eskip.Route{Filters: r.Filters, BackendType: eskip.ShuntBackend})
+	}
+
+	return m
+}
+
+func TestProfiles(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "profiles.yaml")
+	writeProfiles(t, file, testProfiles)
+
+	p, err := New(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	routes, err := eskip.Parse(`
+		explicit: Host("^api[.]example[.]org$") -> setPath("/foo") -> profile("internal") -> <shunt>;
+		host: Host("^api[.]example[.]org$") -> backendTimeout("1s") -> <shunt>;
+		hostAny: HostAny("other.example.org", "www.example.org") -> <shunt>;
+		kube_rg__team_a__reports__get__0_0: Host("^reports[.]example[.]org$") -> <shunt>;
+		kube_rg__internal_team_a__reports__get__0_0: * -> <shunt>;
+		kube_rg__team_a__reports_v2__get__0_0: * -> <shunt>;
+		none: Host("^other[.]example[.]org$") -> <shunt>;
+		missing: * -> profile("missing") -> <shunt>;
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	original := eskip.Print(eskip.PrettyPrintInfo{}, routes...)
+	processed := p.Do(routes)
+	if eskip.Print(eskip.PrettyPrintInfo{}, routes...) != original {
+		t.Error("the input routes were modified")
+	}
+
+	const (
+		standard = `backendTimeout("30s") -> backendTransport("dialTimeout", "500ms", "responseHeaderTimeout", "10s") -> minTLSVersion("1.2") -> setResponseHeader("Strict-Transport-Security", "max-age=31536000") -> enableAccessLog(4, 5)`
+		internal = `backendTimeout("5m")`
+	)
+
+	expected := map[string]string{
+		"explicit":                           `* -> setPath("/foo") -> ` + internal + ` -> <shunt>`,
+		"host":                               `* -> ` + standard + ` -> backendTimeout("1s") -> <shunt>`,
+		"hostAny":                            `* -> ` + internal + ` -> <shunt>`,
+		"kube_rg__team_a__reports__get__0_0": `* -> ` + internal + ` -> <shunt>`,
+		"kube_rg__internal_team_a__reports__get__0_0": `* -> ` + internal + ` -> <shunt>`,
+		"kube_rg__team_a__reports_v2__get__0_0":       `* -> <shunt>`,
+		"none":                                        `* -> <shunt>`,
+	}
+
+	got := filterChains(processed)
+	if len(got) != len(expected) {
+		t.Errorf("expected %d routes, got %d", len(expected), len(got))
+	}
+
+	for id, e := range expected {
+		if got[id] != e {
+			t.Errorf("%s: expected %s, got %s", id, e, got[id])
+		}
+	}
+}
+
+func TestProfilesInvalid(t *testing.T) {
+	for _, test := range []struct {
+		title    string
+		profiles string
+	}{{
+		title:    "invalid yaml",
+		profiles: "profiles: [",
+	}, {
+		title:    "unknown field",
+		profiles: "profiles:\n  p:\n    timeout:\n      backend: 1s",
+	}, {
+		title:    "invalid timeout",
+		profiles: "profiles:\n  p:\n    timeouts:\n      dial: 1",
+	}, {
+		title:    "invalid filters",
+		profiles: "profiles:\n  p:\n    filters: status(",
+	}, {
+		title:    "missing host profile",
+		profiles: "hosts:\n  www.example.org: p",
+	}, {
+		title:    "missing route group profile",
+		profiles: "routeGroups:\n  default/foo: p",
+	}, {
+		title:    "invalid route group",
+		profiles: "profiles:\n  p:\n    filters: status(200)\nrouteGroups:\n  foo: p",
+	}} {
+		t.Run(test.title, func(t *testing.T) {
+			file := filepath.Join(t.TempDir(), "profiles.yaml")
+			writeProfiles(t, file, test.profiles)
+			if _, err := New(file); err == nil {
+				t.Error("failed to fail")
+			}
+		})
+	}
+}
+
+func TestProfilesReload(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "profiles.yaml")
+	writeProfiles(t, file, "profiles:\n  p:\n    timeouts:\n      backend: 1s")
+
+	p, err := New(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	routes, err := eskip.Parse(`r: * -> profile("p") -> <shunt>`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	check := func(expected string) {
+		t.Helper()
+		if got := filterChains(p.Do(routes))["r"]; got != expected {
+			t.Errorf("expected %s, got %s", expected, got)
+		}
+	}
+
+	if _, deleted, err := p.LoadUpdate(); err != nil || len(deleted) != 0 {
+		t.Fatalf("unexpected update without change: %v, %v", deleted, err)
+	}
+
+	check(`* -> backendTimeout("1s") -> <shunt>`)
+
+	writeProfiles(t, file, "profiles:\n  p:\n    timeouts:\n      backend: 2s")
+	if _, deleted, err := p.LoadUpdate(); err != nil || len(deleted) != 1 {
+		t.Fatalf("failed to report the change: %v, %v", deleted, err)
+	}
+
+	check(`* -> backendTimeout("2s") -> <shunt>`)
+
+	writeProfiles(t, file, "profiles:\n  p:\n    timeouts:\n      backend: foo")
+	if _, deleted, err := p.LoadUpdate(); err != nil || len(deleted) != 0 {
+		t.Fatalf("unexpected update with invalid profiles: %v, %v", deleted, err)
+	}
+
+	check(`* -> backendTimeout("2s") -> <shunt>`)
+}
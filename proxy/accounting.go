@@ -0,0 +1,58 @@
+package proxy
+
+import (
+	"io"
+	"net/http"
+
+	"github.com/zalando/skipper/accounting"
+)
+
+// countingBody counts the bytes of the request body read by the proxy.
+type countingBody struct {
+	io.ReadCloser
+	n int64
+}
+
+func (b *countingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.n += int64(n)
+	return n, err
+}
+
+// countRequestBody wraps the request body, when the cost accounting is
+// enabled. The requests without a body are not changed, because the
+// proxy checks them for http.NoBody.
+func (p *Proxy) countRequestBody(r *http.Request) *countingBody {
+	if p.accounting == nil || r.Body == nil || r.Body == http.NoBody {
+		return nil
+	}
+
+	b := &countingBody{ReadCloser: r.Body}
+	r.Body = b
+	return b
+}
+
+// account adds the usage of the request to its tenant, when the tenant
+// was set by the filters.
+func (p *Proxy) account(ctx *context, bytesOut int64, in *countingBody) {
+	if p.accounting == nil {
+		return
+	}
+
+	tenant, _ := ctx.stateBag[accounting.TenantKey].(string)
+	if tenant == "" {
+		return
+	}
+
+	u := accounting.Usage{
+		Requests:    1,
+		BytesOut:    bytesOut,
+		BackendTime: ctx.backendTime,
+	}
+
+	if in != nil {
+		u.BytesIn = in.n
+	}
+
+	p.accounting.Add(tenant, u)
+}
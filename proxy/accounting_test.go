@@ -0,0 +1,56 @@
+package proxy
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/zalando/skipper/accounting"
+)
+
+func TestCostAccounting(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(10 * time.Millisecond)
+		w.Write([]byte("Hello, world!"))
+	}))
+	defer backend.Close()
+
+	registry := accounting.NewRegistry(accounting.Options{})
+	defer registry.Close()
+
+	tp, err := newTestProxyWithParams(fmt.Sprintf(`
+		tenant: Path("/tenant") -> tenantKey("${request.header.X-Tenant-Id}") -> "%s";
+		other: * -> "%s";
+	`, backend.URL, backend.URL), Params{Accounting: registry})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer tp.close()
+
+	for _, r := range []*http.Request{
+		httptest.NewRequest("POST", "/tenant", strings.NewReader("foo=bar")),
+		httptest.NewRequest("GET", "/tenant", nil),
+		httptest.NewRequest("GET", "/other", nil),
+		httptest.NewRequest("GET", "/tenant", nil),
+	} {
+		r.Header.Set("X-Tenant-Id", "foo")
+		tp.proxy.ServeHTTP(httptest.NewRecorder(), r)
+	}
+
+	// without tenant
+	tp.proxy.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/tenant", nil))
+
+	totals := registry.Totals()
+	if len(totals) != 1 {
+		t.Fatalf("unexpected tenants: %+v", totals)
+	}
+
+	u := totals["foo"]
+	if u.Requests != 3 || u.BytesIn != 7 || u.BytesOut != 3*13 || u.BackendTime < 30*time.Millisecond {
+		t.Errorf("unexpected usage: %+v", u)
+	}
+}
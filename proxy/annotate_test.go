@@ -0,0 +1,47 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/zalando/skipper/filters"
+	"github.com/zalando/skipper/filters/annotate"
+	"github.com/zalando/skipper/filters/builtin"
+)
+
+// records the team annotation visible in the request phase
+type annotationRecorder struct{}
+
+func (annotationRecorder) Name() string { return "recordTeam" }
+
+func (annotationRecorder) CreateFilter([]interface{}) (filters.Filter, error) {
+	return annotationRecorder{}, nil
+}
+
+func (annotationRecorder) Request(ctx filters.FilterContext) {
+	ctx.StateBag()["test:team"] = annotate.GetAnnotations(ctx)["team"]
+}
+
+func (annotationRecorder) Response(ctx filters.FilterContext) {
+	team, _ := ctx.StateBag()["test:team"].(string)
+	ctx.Response().Header.Set("X-Team", team)
+}
+
+func TestRouteAnnotationsBeforeAnnotateFilter(t *testing.T) {
+	fr := builtin.MakeRegistry()
+	fr.Register(annotationRecorder{})
+
+	tp, err := newTestProxyWithFilters(fr, `* -> recordTeam() -> annotate("team", "checkout") -> inlineContent("ok") -> <shunt>`, FlagsNone)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer tp.close()
+
+	w := httptest.NewRecorder()
+	tp.proxy.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	if w.Code != http.StatusOK || w.Header().Get("X-Team") != "checkout" {
+		t.Errorf("failed to set the annotations before the filters: %d, %q", w.Code, w.Header().Get("X-Team"))
+	}
+}
@@ -0,0 +1,133 @@
+package proxy
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Reasons of the request anomalies, used in the names of the
+// anomaly counters.
+const (
+	AnomalyInvalidEncoding   = "invalid_encoding"
+	AnomalyOversizedHeaders  = "oversized_headers"
+	AnomalyBadContentLength  = "bad_content_length"
+	AnomalyUnsupportedMethod = "unsupported_method"
+)
+
+const (
+	defaultAnomalyHeaderSize = 8192
+	anomalyUnknownRoute      = "_unknown"
+	anomalyCounterPrefix     = "anomaly."
+)
+
+var standardMethods = map[string]bool{
+	http.MethodGet:     true,
+	http.MethodHead:    true,
+	http.MethodPost:    true,
+	http.MethodPut:     true,
+	http.MethodPatch:   true,
+	http.MethodDelete:  true,
+	http.MethodConnect: true,
+	http.MethodOptions: true,
+	http.MethodTrace:   true,
+}
+
+func invalidEncoding(r *http.Request) bool {
+	if !utf8.ValidString(r.URL.Path) || strings.ContainsRune(r.URL.Path, 0) {
+		return true
+	}
+
+	q, err := url.ParseQuery(r.URL.RawQuery)
+	if err != nil {
+		return true
+	}
+
+	for k, v := range q {
+		if !utf8.ValidString(k) {
+			return true
+		}
+
+		for _, vi := range v {
+			if !utf8.ValidString(vi) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func oversizedHeaders(r *http.Request, maxSize int) bool {
+	var n int
+	for k, v := range r.Header {
+		for _, vi := range v {
+			// name: value\r\n
+			n += len(k) + len(vi) + 4
+		}
+	}
+
+	return n > maxSize
+}
+
+// the server rejects most of the invalid Content-Length headers, but
+// the body of the methods without a defined meaning of a body is also
+// considered abnormal
+func badContentLength(r *http.Request) bool {
+	cl := r.Header.Values("Content-Length")
+	if len(cl) > 1 {
+		return true
+	}
+
+	if len(cl) == 1 {
+		if n, err := strconv.ParseInt(cl[0], 10, 64); err != nil || n < 0 {
+			return true
+		}
+	}
+
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodTrace:
+		return r.ContentLength > 0 || len(r.TransferEncoding) > 0
+	default:
+		return false
+	}
+}
+
+// requestAnomalies returns the reasons why a request is considered
+// malformed or abnormal, or nil.
+func requestAnomalies(r *http.Request, maxHeaderSize int) []string {
+	var reasons []string
+	if invalidEncoding(r) {
+		reasons = append(reasons, AnomalyInvalidEncoding)
+	}
+
+	if oversizedHeaders(r, maxHeaderSize) {
+		reasons = append(reasons, AnomalyOversizedHeaders)
+	}
+
+	if badContentLength(r) {
+		reasons = append(reasons, AnomalyBadContentLength)
+	}
+
+	if !standardMethods[r.Method] {
+		reasons = append(reasons, AnomalyUnsupportedMethod)
+	}
+
+	return reasons
+}
+
+// counts the anomalies by the reason, and by the reason and the route,
+// after the request was routed
+func (p *Proxy) countAnomalies(reasons []string, ctx *context) {
+	routeID := anomalyUnknownRoute
+	if ctx != nil && ctx.route != nil && ctx.route.Id != "" {
+		routeID = ctx.route.Id
+	}
+
+	for _, reason := range reasons {
+		p.metrics.IncCounter(anomalyCounterPrefix + reason)
+		p.metrics.IncCounter(anomalyCounterPrefix + reason + ".route." + routeID)
+	}
+}
@@ -0,0 +1,152 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/zalando/skipper/metrics"
+	"github.com/zalando/skipper/metrics/metricstest"
+)
+
+// records only the counters, and ignores the rest of the metrics
+type counterMetrics struct {
+	metrics.Metrics
+	mock *metricstest.MockMetrics
+}
+
+func (m counterMetrics) IncCounter(key string) { m.mock.IncCounter(key) }
+
+func TestRequestAnomalies(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		request func() *http.Request
+		reasons []string
+	}{{
+		name:    "valid",
+		request: func() *http.Request { return httptest.NewRequest("GET", "/foo?bar=baz", nil) },
+	}, {
+		name:    "invalid utf-8 in the path",
+		request: func() *http.Request { return httptest.NewRequest("GET", "/foo%ff", nil) },
+		reasons: []string{AnomalyInvalidEncoding},
+	}, {
+		name:    "null byte in the path",
+		request: func() *http.Request { return httptest.NewRequest("GET", "/foo%00", nil) },
+		reasons: []string{AnomalyInvalidEncoding},
+	}, {
+		name:    "invalid query escape",
+		request: func() *http.Request { return httptest.NewRequest("GET", "/foo?bar=%zz", nil) },
+		reasons: []string{AnomalyInvalidEncoding},
+	}, {
+		name: "oversized headers",
+		request: func() *http.Request {
+			r := httptest.NewRequest("GET", "/", nil)
+			r.Header.Set("X-Foo", strings.Repeat("x", 128))
+			return r
+		},
+		reasons: []string{AnomalyOversizedHeaders},
+	}, {
+		name: "multiple content lengths",
+		request: func() *http.Request {
+			r := httptest.NewRequest("POST", "/", strings.NewReader("foo"))
+			r.Header["Content-Length"] = []string{"3", "4"}
+			return r
+		},
+		reasons: []string{AnomalyBadContentLength},
+	}, {
+		name: "invalid content length",
+		request: func() *http.Request {
+			r := httptest.NewRequest("POST", "/", strings.NewReader("foo"))
+			r.Header.Set("Content-Length", "-3")
+			return r
+		},
+		reasons: []string{AnomalyBadContentLength},
+	}, {
+		name:    "body of get",
+		request: func() *http.Request { return httptest.NewRequest("GET", "/", strings.NewReader("foo")) },
+		reasons: []string{AnomalyBadContentLength},
+	}, {
+		name:    "body of post",
+		request: func() *http.Request { return httptest.NewRequest("POST", "/", strings.NewReader("foo")) },
+	}, {
+		name:    "unsupported method",
+		request: func() *http.Request { return httptest.NewRequest("PROPFIND", "/", nil) },
+		reasons: []string{AnomalyUnsupportedMethod},
+	}, {
+		name:    "multiple reasons",
+		request: func() *http.Request { return httptest.NewRequest("FOO", "/%ff", nil) },
+		reasons: []string{AnomalyInvalidEncoding, AnomalyUnsupportedMethod},
+	}} {
+		t.Run(tt.name, func(t *testing.T) {
+			if reasons := requestAnomalies(tt.request(), 64); !reflect.DeepEqual(reasons, tt.reasons) {
+				t.Errorf("unexpected reasons: %v, expected: %v", reasons, tt.reasons)
+			}
+		})
+	}
+}
+
+func TestAnomalyMetrics(t *testing.T) {
+	for _, tt := range []struct {
+		name     string
+		params   Params
+		method   string
+		path     string
+		counters map[string]int64
+	}{{
+		name:   "disabled",
+		method: "FOO",
+		path:   "/foo",
+	}, {
+		name:   "routed",
+		params: Params{EnableRequestAnomalyMetrics: true},
+		method: "FOO",
+		path:   "/foo",
+		counters: map[string]int64{
+			"anomaly.unsupported_method":           1,
+			"anomaly.unsupported_method.route.foo": 1,
+		},
+	}, {
+		name:   "not routed",
+		params: Params{EnableRequestAnomalyMetrics: true},
+		method: "GET",
+		path:   "/bar%ff",
+		counters: map[string]int64{
+			"anomaly.invalid_encoding":                1,
+			"anomaly.invalid_encoding.route._unknown": 1,
+		},
+	}, {
+		name:   "valid",
+		params: Params{EnableRequestAnomalyMetrics: true},
+		method: "GET",
+		path:   "/foo",
+	}} {
+		t.Run(tt.name, func(t *testing.T) {
+			tp, err := newTestProxyWithParams(`foo: Path("/foo") -> status(204) -> <shunt>`, tt.params)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer tp.close()
+
+			m := &metricstest.MockMetrics{}
+			tp.proxy.metrics = counterMetrics{Metrics: metrics.Void, mock: m}
+
+			tp.proxy.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(tt.method, tt.path, nil))
+
+			m.WithCounters(func(counters map[string]int64) {
+				for k, v := range counters {
+					if strings.HasPrefix(k, anomalyCounterPrefix) && tt.counters[k] != v {
+						t.Errorf("unexpected counter %s: %d", k, v)
+					}
+				}
+
+				for k, v := range tt.counters {
+					if counters[k] != v {
+						t.Errorf("missing counter %s: %d, expected: %d", k, counters[k], v)
+					}
+				}
+			})
+		})
+	}
+}
@@ -0,0 +1,116 @@
+package proxy
+
+import (
+	stdlibcontext "context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"syscall"
+)
+
+// The classes of the backend errors, reported in the metrics and in the
+// access log.
+const (
+	backendErrorDNS                   = "dns"
+	backendErrorDialTimeout           = "dial_timeout"
+	backendErrorDial                  = "dial"
+	backendErrorTLS                   = "tls"
+	backendErrorReset                 = "reset"
+	backendErrorResponseHeaderTimeout = "response_header_timeout"
+	backendErrorTimeout               = "timeout"
+	backendErrorBodyRead              = "body_read"
+	backendErrorCanceled              = "canceled"
+	backendErrorOther                 = "other"
+
+	backendErrorRouteKey = "backenderrors.%s.route.%s"
+	backendErrorHostKey  = "backenderrors.%s.host.%s"
+)
+
+func hostKey(h string) string {
+	h = strings.ReplaceAll(h, ".", "_")
+	h = strings.ReplaceAll(h, ":", "__")
+	h = strings.ReplaceAll(h, "/", "_")
+	return h
+}
+
+// classifyBackendError maps the error of a backend roundtrip to one of
+// the backend error classes.
+func classifyBackendError(ctx stdlibcontext.Context, err error) string {
+	switch ctx.Err() {
+	case stdlibcontext.Canceled:
+		return backendErrorCanceled
+	case stdlibcontext.DeadlineExceeded:
+		return backendErrorTimeout
+	}
+
+	// the proxy errors don't unwrap the original error
+	var (
+		perr          *proxyError
+		dialingFailed bool
+	)
+
+	if errors.As(err, &perr) {
+		dialingFailed = perr.dialingFailed
+		if perr.err != nil {
+			err = perr.err
+		}
+	}
+
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return backendErrorDNS
+	}
+
+	var nerr net.Error
+	timeout := errors.As(err, &nerr) && nerr.Timeout()
+
+	var opErr *net.OpError
+	if dialingFailed || errors.As(err, &opErr) && opErr.Op == "dial" {
+		if timeout {
+			return backendErrorDialTimeout
+		}
+
+		return backendErrorDial
+	}
+
+	var (
+		recordErr    tls.RecordHeaderError
+		authorityErr x509.UnknownAuthorityError
+		hostnameErr  x509.HostnameError
+		invalidErr   x509.CertificateInvalidError
+	)
+
+	msg := err.Error()
+	if errors.As(err, &recordErr) || errors.As(err, &authorityErr) || errors.As(err, &hostnameErr) ||
+		errors.As(err, &invalidErr) || strings.Contains(msg, "tls: ") || strings.Contains(msg, "TLS handshake") {
+		return backendErrorTLS
+	}
+
+	if errors.Is(err, syscall.ECONNRESET) || errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return backendErrorReset
+	}
+
+	if strings.Contains(msg, "timeout awaiting response headers") {
+		return backendErrorResponseHeaderTimeout
+	}
+
+	if timeout {
+		return backendErrorTimeout
+	}
+
+	return backendErrorOther
+}
+
+// recordBackendError stores the class of the backend error for the access
+// log, and counts it for the route and for the backend host.
+func (p *Proxy) recordBackendError(ctx *context, host, class string) {
+	ctx.backendError = class
+	p.metrics.IncCounter(fmt.Sprintf(backendErrorRouteKey, class, ctx.route.Id))
+	if host != "" {
+		p.metrics.IncCounter(fmt.Sprintf(backendErrorHostKey, class, hostKey(host)))
+	}
+}
@@ -0,0 +1,145 @@
+package proxy
+
+import (
+	stdlibcontext "context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/zalando/skipper/metrics"
+	"github.com/zalando/skipper/metrics/metricstest"
+)
+
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+func TestClassifyBackendError(t *testing.T) {
+	canceled, cancel := stdlibcontext.WithCancel(stdlibcontext.Background())
+	cancel()
+
+	for _, tt := range []struct {
+		name     string
+		ctx      stdlibcontext.Context
+		err      error
+		expected string
+	}{{
+		name:     "dns",
+		err:      &proxyError{err: &net.OpError{Op: "dial", Err: &net.DNSError{Name: "foo.example.org"}}, dialingFailed: true},
+		expected: backendErrorDNS,
+	}, {
+		name:     "dial timeout",
+		err:      &proxyError{err: &net.OpError{Op: "dial", Err: timeoutError{}}, dialingFailed: true},
+		expected: backendErrorDialTimeout,
+	}, {
+		name:     "dial",
+		err:      &proxyError{err: &net.OpError{Op: "dial", Err: os.NewSyscallError("connect", syscall.ECONNREFUSED)}, dialingFailed: true},
+		expected: backendErrorDial,
+	}, {
+		name:     "tls",
+		err:      fmt.Errorf("roundtrip: %w", tls.RecordHeaderError{Msg: "first record does not look like a TLS handshake"}),
+		expected: backendErrorTLS,
+	}, {
+		name:     "tls handshake timeout",
+		err:      errors.New("net/http: TLS handshake timeout"),
+		expected: backendErrorTLS,
+	}, {
+		name:     "reset",
+		err:      &url.Error{Err: &net.OpError{Op: "read", Err: os.NewSyscallError("read", syscall.ECONNRESET)}},
+		expected: backendErrorReset,
+	}, {
+		name:     "closed",
+		err:      &url.Error{Err: io.EOF},
+		expected: backendErrorReset,
+	}, {
+		name:     "response header timeout",
+		err:      errors.New("net/http: timeout awaiting response headers"),
+		expected: backendErrorResponseHeaderTimeout,
+	}, {
+		name:     "timeout",
+		err:      &net.OpError{Op: "read", Err: timeoutError{}},
+		expected: backendErrorTimeout,
+	}, {
+		name:     "canceled",
+		ctx:      canceled,
+		err:      errors.New("context canceled"),
+		expected: backendErrorCanceled,
+	}, {
+		name:     "other",
+		err:      errors.New("foo"),
+		expected: backendErrorOther,
+	}} {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := tt.ctx
+			if ctx == nil {
+				ctx = stdlibcontext.Background()
+			}
+
+			if class := classifyBackendError(ctx, tt.err); class != tt.expected {
+				t.Errorf("unexpected class: %s, expected: %s", class, tt.expected)
+			}
+		})
+	}
+}
+
+func TestBackendErrorMetrics(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	closedAddr := l.Addr().String()
+	l.Close()
+
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(300 * time.Millisecond)
+	}))
+	defer slow.Close()
+
+	slowURL, _ := url.Parse(slow.URL)
+
+	tp, err := newTestProxyWithParams(fmt.Sprintf(`
+		closed: Path("/closed") -> "http://%s";
+		slow: Path("/slow") -> "%s";
+	`, closedAddr, slow.URL), Params{ResponseHeaderTimeout: 50 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer tp.close()
+
+	m := &metricstest.MockMetrics{}
+	tp.proxy.metrics = counterMetrics{Metrics: metrics.Void, mock: m}
+
+	for _, path := range []string{"/closed", "/slow"} {
+		w := httptest.NewRecorder()
+		tp.proxy.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		if w.Code < http.StatusInternalServerError {
+			t.Errorf("%s: unexpected status: %d", path, w.Code)
+		}
+	}
+
+	m.WithCounters(func(counters map[string]int64) {
+		for _, key := range []string{
+			"backenderrors.dial.route.closed",
+			"backenderrors.dial.host." + hostKey(closedAddr),
+			"backenderrors.response_header_timeout.route.slow",
+			"backenderrors.response_header_timeout.host." + hostKey(slowURL.Host),
+		} {
+			if counters[key] != 1 {
+				t.Errorf("unexpected counter %s: %d", key, counters[key])
+			}
+		}
+	})
+}
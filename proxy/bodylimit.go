@@ -0,0 +1,78 @@
+package proxy
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/zalando/skipper/filters"
+)
+
+var errRequestBodyTooLarge = errors.New("request body too large")
+
+// limitedBody fails the reads when the body is longer than the limit,
+// so that the backend request is aborted, instead of streaming
+// unbounded data to the backend.
+type limitedBody struct {
+	body      io.ReadCloser
+	remaining int64
+}
+
+// returns the max request body size of the route, or the default
+func (p *Proxy) requestBodyLimit(ctx *context) int64 {
+	if limit, ok := ctx.StateBag()[filters.MaxRequestBodySizeKey].(int64); ok {
+		return limit
+	}
+
+	return p.maxRequestBodySize
+}
+
+// limitRequestBody checks the request body size against the limit. It
+// rejects the requests with a longer content length right away, and
+// limits the reads of the requests with a chunked body.
+func (p *Proxy) limitRequestBody(ctx *context, req *http.Request) *proxyError {
+	limit := p.requestBodyLimit(ctx)
+	if limit <= 0 || req.Body == nil || req.Body == http.NoBody {
+		return nil
+	}
+
+	if req.ContentLength > limit {
+		return bodyTooLargeError(fmt.Errorf("%w: content length %d, limit %d", errRequestBodyTooLarge, req.ContentLength, limit))
+	}
+
+	req.Body = &limitedBody{body: req.Body, remaining: limit}
+	return nil
+}
+
+func bodyTooLargeError(err error) *proxyError {
+	// the rest of the body is not read, the client connection is closed
+	return &proxyError{
+		err:              err,
+		code:             http.StatusRequestEntityTooLarge,
+		additionalHeader: http.Header{"Connection": []string{"close"}},
+	}
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	if b.remaining < 0 {
+		return 0, errRequestBodyTooLarge
+	}
+
+	// reading one byte more than the limit, to detect the longer bodies
+	if int64(len(p)) > b.remaining+1 {
+		p = p[:b.remaining+1]
+	}
+
+	n, err := b.body.Read(p)
+	b.remaining -= int64(n)
+	if b.remaining < 0 {
+		return n + int(b.remaining), errRequestBodyTooLarge
+	}
+
+	return n, err
+}
+
+func (b *limitedBody) Close() error {
+	return b.body.Close()
+}
@@ -0,0 +1,110 @@
+package proxy
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestMaxRequestBodySize(t *testing.T) {
+	var received int64
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, err := io.ReadAll(r.Body)
+		if err != nil {
+			return
+		}
+
+		atomic.AddInt64(&received, 1)
+		w.Write(b)
+	}))
+	defer backend.Close()
+
+	doc := fmt.Sprintf(`
+		default: Path("/default") -> "%s";
+		override: Path("/override") -> maxRequestBodySize(64) -> "%s";
+	`, backend.URL, backend.URL)
+
+	tp, err := newTestProxyWithParams(doc, Params{MaxRequestBodySize: 16})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tp.close()
+
+	ps := httptest.NewServer(tp.proxy)
+	defer ps.Close()
+
+	for _, tc := range []struct {
+		name     string
+		path     string
+		size     int
+		chunked  bool
+		status   int
+		received bool
+	}{
+		{name: "within the limit", path: "/default", size: 16, status: http.StatusOK, received: true},
+		{name: "content length over the limit", path: "/default", size: 17, status: http.StatusRequestEntityTooLarge},
+		{name: "chunked within the limit", path: "/default", size: 16, chunked: true, status: http.StatusOK, received: true},
+		{name: "chunked over the limit", path: "/default", size: 17, chunked: true, status: http.StatusRequestEntityTooLarge},
+		{name: "route override within the limit", path: "/override", size: 64, status: http.StatusOK, received: true},
+		{name: "route override over the limit", path: "/override", size: 65, status: http.StatusRequestEntityTooLarge},
+		{name: "route override chunked over the limit", path: "/override", size: 65, chunked: true, status: http.StatusRequestEntityTooLarge},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			atomic.StoreInt64(&received, 0)
+
+			var body io.Reader = strings.NewReader(strings.Repeat("a", tc.size))
+			if tc.chunked {
+				// hides the length, so that the body is sent chunked
+				body = io.MultiReader(body)
+			}
+
+			rsp, err := http.Post(ps.URL+tc.path, "text/plain", body)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			defer rsp.Body.Close()
+			if rsp.StatusCode != tc.status {
+				t.Fatalf("expected %d, got %d", tc.status, rsp.StatusCode)
+			}
+
+			if tc.status == http.StatusRequestEntityTooLarge && !rsp.Close {
+				t.Error("expected to close the connection")
+			}
+
+			if r := atomic.LoadInt64(&received) == 1; r != tc.received {
+				t.Errorf("expected the full body received by the backend: %v, got: %v", tc.received, r)
+			}
+		})
+	}
+}
+
+func TestMaxRequestBodySizeDisabled(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+	}))
+	defer backend.Close()
+
+	tp, err := newTestProxyWithParams(fmt.Sprintf(`* -> "%s"`, backend.URL), Params{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tp.close()
+
+	ps := httptest.NewServer(tp.proxy)
+	defer ps.Close()
+
+	rsp, err := http.Post(ps.URL, "text/plain", strings.NewReader(strings.Repeat("a", 1<<20)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer rsp.Body.Close()
+	if rsp.StatusCode != http.StatusOK {
+		t.Errorf("expected 200, got %d", rsp.StatusCode)
+	}
+}
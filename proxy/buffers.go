@@ -0,0 +1,56 @@
+package proxy
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// bufferPool reuses the buffers of the same size between the requests. The
+// pooled buffers can be released, e.g. when the memory is under pressure.
+type bufferPool struct {
+	size int
+	pool atomic.Pointer[sync.Pool]
+}
+
+var (
+	// streamBuffers are used for copying the response bodies. The
+	// buffers are owned exclusively by the copying functions, they are
+	// not passed to the filters.
+	streamBuffers = newBufferPool(proxyBufferSize)
+
+	passthroughBuffers = newBufferPool(passthroughBufferSize)
+)
+
+func newBufferPool(size int) *bufferPool {
+	p := &bufferPool{size: size}
+	p.release()
+	return p
+}
+
+func (p *bufferPool) get() *[]byte {
+	return p.pool.Load().Get().(*[]byte)
+}
+
+// the buffers taken before a release are put back to the new pool
+func (p *bufferPool) put(b *[]byte) {
+	p.pool.Load().Put(b)
+}
+
+func (p *bufferPool) release() {
+	size := p.size
+	p.pool.Store(&sync.Pool{
+		New: func() interface{} {
+			b := make([]byte, size)
+			return &b
+		},
+	})
+}
+
+// ReleaseBuffers drops the buffers pooled for copying the bodies, so that
+// the next garbage collection can free them, instead of keeping them until
+// the pool is cleared by the runtime. It is used when the memory is under
+// pressure.
+func ReleaseBuffers() {
+	streamBuffers.release()
+	passthroughBuffers.release()
+}
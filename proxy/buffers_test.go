@@ -0,0 +1,21 @@
+package proxy
+
+import "testing"
+
+func TestBufferPoolRelease(t *testing.T) {
+	p := newBufferPool(8)
+	b := p.get()
+	if len(*b) != 8 {
+		t.Fatalf("unexpected buffer size: %d", len(*b))
+	}
+
+	p.put(b)
+	p.release()
+	if p.get() == b {
+		t.Error("failed to release the pooled buffer")
+	}
+
+	// the buffers taken before the release are reused
+	p.put(b)
+	ReleaseBuffers()
+}
@@ -0,0 +1,159 @@
+package proxy
+
+import (
+	stdlibcontext "context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"sync"
+
+	"github.com/zalando/skipper/metrics"
+)
+
+const (
+	connPoolOpenKey         = "backendconns.%s.open"
+	connPoolInUseKey        = "backendconns.%s.inuse"
+	connPoolIdleKey         = "backendconns.%s.idle"
+	connPoolDialFailuresKey = "backendconns.%s.dialfailures"
+)
+
+// connPool tracks the backend connections of the proxy for each backend
+// address, and reports the number of the open, in-use and idle
+// connections, and the dial failures.
+type connPool struct {
+	metrics metrics.Metrics
+
+	mu    sync.Mutex
+	hosts map[string]*hostConns
+}
+
+type hostConns struct {
+	open, inUse int
+}
+
+type poolConn struct {
+	net.Conn
+	pool *connPool
+	addr string
+
+	// guarded by the mutex of the pool
+	inUse, closed bool
+}
+
+func newConnPool(m metrics.Metrics) *connPool {
+	return &connPool{
+		metrics: m,
+		hosts:   make(map[string]*hostConns),
+	}
+}
+
+func connPoolKey(format, addr string) string {
+	return fmt.Sprintf(format, hostKey(addr))
+}
+
+// call with the mutex held
+func (p *connPool) update(addr string, h *hostConns) {
+	idle := h.open - h.inUse
+	if idle < 0 {
+		idle = 0
+	}
+
+	p.metrics.UpdateGauge(connPoolKey(connPoolOpenKey, addr), float64(h.open))
+	p.metrics.UpdateGauge(connPoolKey(connPoolInUseKey, addr), float64(h.inUse))
+	p.metrics.UpdateGauge(connPoolKey(connPoolIdleKey, addr), float64(idle))
+
+	if h.open == 0 {
+		delete(p.hosts, addr)
+	}
+}
+
+// dialContext wraps a dial function, counting the established
+// connections and the dial failures.
+func (p *connPool) dialContext(dial dialFunc) dialFunc {
+	return func(ctx stdlibcontext.Context, network, addr string) (net.Conn, error) {
+		conn, err := dial(ctx, network, addr)
+		if err != nil {
+			p.metrics.IncCounter(connPoolKey(connPoolDialFailuresKey, addr))
+			return nil, err
+		}
+
+		p.mu.Lock()
+		defer p.mu.Unlock()
+
+		h, ok := p.hosts[addr]
+		if !ok {
+			h = &hostConns{}
+			p.hosts[addr] = h
+		}
+
+		h.open++
+		p.update(addr, h)
+		return &poolConn{Conn: conn, pool: p, addr: addr}, nil
+	}
+}
+
+func (p *connPool) setInUse(c *poolConn, inUse bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if c.closed || c.inUse == inUse {
+		return
+	}
+
+	h := p.hosts[c.addr]
+	c.inUse = inUse
+	if inUse {
+		h.inUse++
+	} else {
+		h.inUse--
+	}
+
+	p.update(c.addr, h)
+}
+
+func (c *poolConn) Close() error {
+	c.pool.mu.Lock()
+	if !c.closed {
+		c.closed = true
+		h := c.pool.hosts[c.addr]
+		h.open--
+		if c.inUse {
+			h.inUse--
+		}
+
+		c.pool.update(c.addr, h)
+	}
+
+	c.pool.mu.Unlock()
+	return c.Conn.Close()
+}
+
+func trackedConn(c net.Conn) (*poolConn, bool) {
+	if tc, ok := c.(*tls.Conn); ok {
+		c = tc.NetConn()
+	}
+
+	pc, ok := c.(*poolConn)
+	return pc, ok
+}
+
+// trace marks the connection used by the request in-use until it is
+// put back to the idle connections of the transport, or closed.
+func (p *connPool) trace(req *http.Request) *http.Request {
+	var conn *poolConn
+	return req.WithContext(httptrace.WithClientTrace(req.Context(), &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) {
+			if c, ok := trackedConn(info.Conn); ok {
+				conn = c
+				p.setInUse(c, true)
+			}
+		},
+		PutIdleConn: func(err error) {
+			if conn != nil && err == nil {
+				p.setInUse(conn, false)
+			}
+		},
+	}))
+}
@@ -0,0 +1,102 @@
+package proxy
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/zalando/skipper/metrics/metricstest"
+)
+
+func waitForGauge(t *testing.T, m *metricstest.MockMetrics, key string, value float64) {
+	t.Helper()
+	var v float64
+	for i := 0; i < 100; i++ {
+		if v, _ = m.Gauge(key); v == value {
+			return
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	t.Errorf("unexpected gauge %s: %v, expected: %v", key, v, value)
+}
+
+func TestConnPoolMetrics(t *testing.T) {
+	inHandler := make(chan struct{})
+	release := make(chan struct{})
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		inHandler <- struct{}{}
+		<-release
+		w.Write([]byte("Hello, world!"))
+	}))
+	defer backend.Close()
+
+	u, _ := url.Parse(backend.URL)
+	openKey := connPoolKey(connPoolOpenKey, u.Host)
+	inUseKey := connPoolKey(connPoolInUseKey, u.Host)
+	idleKey := connPoolKey(connPoolIdleKey, u.Host)
+
+	m := &metricstest.MockMetrics{}
+	pool := newConnPool(m)
+	tr := &http.Transport{DialContext: pool.dialContext((&net.Dialer{}).DialContext)}
+	defer tr.CloseIdleConnections()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		req, _ := http.NewRequest("GET", backend.URL, nil)
+		rsp, err := tr.RoundTrip(pool.trace(req))
+		if err != nil {
+			t.Error(err)
+			return
+		}
+
+		io.Copy(io.Discard, rsp.Body)
+		rsp.Body.Close()
+	}()
+
+	<-inHandler
+	waitForGauge(t, m, openKey, 1)
+	waitForGauge(t, m, inUseKey, 1)
+	waitForGauge(t, m, idleKey, 0)
+
+	close(release)
+	<-done
+	waitForGauge(t, m, openKey, 1)
+	waitForGauge(t, m, inUseKey, 0)
+	waitForGauge(t, m, idleKey, 1)
+
+	tr.CloseIdleConnections()
+	waitForGauge(t, m, openKey, 0)
+	waitForGauge(t, m, idleKey, 0)
+}
+
+func TestConnPoolDialFailures(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	addr := l.Addr().String()
+	l.Close()
+
+	m := &metricstest.MockMetrics{}
+	pool := newConnPool(m)
+	tr := &http.Transport{DialContext: pool.dialContext((&net.Dialer{}).DialContext)}
+
+	req, _ := http.NewRequest("GET", "http://"+addr, nil)
+	if _, err := tr.RoundTrip(pool.trace(req)); err == nil {
+		t.Fatal("failed to fail")
+	}
+
+	m.WithCounters(func(counters map[string]int64) {
+		if n := counters[connPoolKey(connPoolDialFailuresKey, addr)]; n != 1 {
+			t.Errorf("unexpected dial failures: %d", n)
+		}
+	})
+}
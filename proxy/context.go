@@ -7,11 +7,13 @@ import (
 	"io"
 	"net/http"
 	"net/url"
+	"reflect"
 	"time"
 
 	"github.com/opentracing/opentracing-go"
 	"github.com/zalando/skipper/filters"
 	"github.com/zalando/skipper/metrics"
+	listenerpredicate "github.com/zalando/skipper/predicates/listener"
 	"github.com/zalando/skipper/routing"
 )
 
@@ -40,6 +42,7 @@ type context struct {
 	executionCounter     int
 	startServe           time.Time
 	metrics              *filterMetrics
+	ownMetrics           filterMetrics
 	tracer               opentracing.Tracer
 	initialSpan          opentracing.Span
 	proxySpan            opentracing.Span
@@ -47,6 +50,14 @@ type context struct {
 	proxy                *Proxy
 	routeLookup          *routing.RouteLookup
 	cancelBackendContext stdlibcontext.CancelFunc
+	backendBody          io.ReadCloser
+	retryBackend         *url.URL
+	routeStats           *routing.RouteStats
+	backendError         string
+	backendTime          time.Duration
+	earlyHintsSent       bool
+	streaming            bool
+	cancelStream         stdlibcontext.CancelFunc
 }
 
 type filterMetrics struct {
@@ -138,11 +149,14 @@ func newContext(
 		request:        r,
 		stateBag:       make(map[string]interface{}),
 		outgoingHost:   r.Host,
-		metrics:        &filterMetrics{impl: p.metrics},
 		proxy:          p,
 		routeLookup:    p.routing.Get(),
 	}
 
+	// saves a separate allocation for the filter metrics
+	c.ownMetrics.impl = p.metrics
+	c.metrics = &c.ownMetrics
+
 	if p.flags.PreserveOriginal() {
 		c.originalRequest = cloneRequestMetadata(r)
 	}
@@ -176,6 +190,19 @@ func (c *context) ensureDefaultResponse() {
 	}
 }
 
+// checks whether the response body is the original backend response
+// body, not replaced by any filter. Only pointers are compared, to avoid
+// panics with non-comparable body types.
+func (c *context) unmodifiedBackendBody() bool {
+	if c.backendBody == nil || c.response == nil || c.response.Body == nil {
+		return false
+	}
+
+	bb, rb := reflect.ValueOf(c.backendBody), reflect.ValueOf(c.response.Body)
+	return bb.Kind() == reflect.Ptr && rb.Kind() == reflect.Ptr &&
+		bb.Type() == rb.Type() && bb.Pointer() == rb.Pointer()
+}
+
 func (c *context) deprecatedShunted() bool {
 	return c.deprecatedServed
 }
@@ -269,6 +296,13 @@ func (c *context) Split() (filters.FilterContext, error) {
 	}
 	serverSpan := opentracing.SpanFromContext(originalRequest.Context())
 	cr = cr.WithContext(opentracing.ContextWithSpan(cr.Context(), serverSpan))
+
+	// the split requests are bound to the same listener as the original,
+	// so that they cannot match the routes of other listeners
+	if name := listenerpredicate.FromContext(originalRequest.Context()); name != "" {
+		cr = cr.WithContext(listenerpredicate.NewContext(cr.Context(), name))
+	}
+
 	originalRequest.Body = body
 	cc.request = cr
 	return cc, nil
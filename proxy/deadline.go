@@ -0,0 +1,98 @@
+package proxy
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/zalando/skipper/filters"
+)
+
+const (
+	grpcTimeoutHeader    = "Grpc-Timeout"
+	requestTimeoutHeader = "X-Request-Timeout"
+
+	// the grpc-timeout header value has at most 8 digits
+	grpcTimeoutMaxValue = 1e8
+)
+
+var grpcTimeoutUnits = []struct {
+	unit time.Duration
+	name string
+}{
+	{time.Nanosecond, "n"},
+	{time.Microsecond, "u"},
+	{time.Millisecond, "m"},
+	{time.Second, "S"},
+	{time.Minute, "M"},
+	{time.Hour, "H"},
+}
+
+// returns the deadline of the backend request, set by the backend timeout
+// of the route, and bounded by the deadline of the client
+func backendDeadline(ctx *context) (time.Time, bool) {
+	var (
+		deadline time.Time
+		ok       bool
+	)
+
+	// the streaming requests are limited by the idle timeout instead
+	if timeout, hasTimeout := ctx.StateBag()[filters.BackendTimeout]; hasTimeout && !ctx.streaming {
+		deadline, ok = time.Now().Add(timeout.(time.Duration)), true
+	}
+
+	if cd, hasClientDeadline := ctx.StateBag()[filters.ClientDeadlineKey].(time.Time); hasClientDeadline && (!ok || cd.Before(deadline)) {
+		deadline, ok = cd, true
+	}
+
+	return deadline, ok
+}
+
+func propagateDeadline(ctx *context) bool {
+	v, _ := ctx.StateBag()[filters.DeadlinePropagationKey].(bool)
+	return v
+}
+
+// formats the timeout with the finest unit, that fits in the 8 digits of
+// the grpc-timeout header
+func formatGRPCTimeout(d time.Duration) string {
+	if d <= 0 {
+		return "1n"
+	}
+
+	for _, u := range grpcTimeoutUnits {
+		if v := d / u.unit; v < grpcTimeoutMaxValue {
+			if v == 0 {
+				v = 1
+			}
+
+			return strconv.FormatInt(int64(v), 10) + u.name
+		}
+	}
+
+	return strconv.Itoa(grpcTimeoutMaxValue-1) + "H"
+}
+
+func formatRequestTimeout(d time.Duration) string {
+	if d < 0 {
+		d = 0
+	}
+
+	return strconv.FormatFloat(d.Seconds(), 'f', 3, 64)
+}
+
+// sets the deadline headers of the backend request to the remaining time
+// of the request
+func setDeadlineHeaders(req *http.Request) {
+	deadline, ok := req.Context().Deadline()
+	if !ok {
+		return
+	}
+
+	remaining := time.Until(deadline)
+	if isGRPCRequest(req) {
+		req.Header.Set(grpcTimeoutHeader, formatGRPCTimeout(remaining))
+	}
+
+	req.Header.Set(requestTimeoutHeader, formatRequestTimeout(remaining))
+}
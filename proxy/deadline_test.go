@@ -0,0 +1,125 @@
+package proxy
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+)
+
+func TestFormatGRPCTimeout(t *testing.T) {
+	for _, tt := range []struct {
+		timeout  time.Duration
+		expected string
+	}{
+		{-time.Second, "1n"},
+		{0, "1n"},
+		{500 * time.Nanosecond, "500n"},
+		{1500 * time.Millisecond, "1500000u"},
+		{2 * time.Minute, "120000m"},
+		{30 * time.Hour, "108000S"},
+		{time.Duration(1<<63 - 1), "2562047H"},
+	} {
+		if v := formatGRPCTimeout(tt.timeout); v != tt.expected {
+			t.Errorf("%v: unexpected value: %s, expected: %s", tt.timeout, v, tt.expected)
+		}
+	}
+}
+
+func TestDeadlinePropagation(t *testing.T) {
+	// h2c, because the gRPC requests are sent over HTTP/2
+	backend := httptest.NewServer(h2c.NewHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if d, err := time.ParseDuration(r.URL.Query().Get("sleep")); err == nil {
+			time.Sleep(d)
+		}
+
+		w.Header().Set("X-Grpc-Timeout", r.Header.Get("Grpc-Timeout"))
+		w.Header().Set("X-Request-Timeout", r.Header.Get("X-Request-Timeout"))
+	}), &http2.Server{}))
+	defer backend.Close()
+
+	tp, err := newTestProxy(fmt.Sprintf(`
+		plain: Path("/plain") -> backendTimeout("10s") -> "%s";
+		propagated: Path("/propagated") -> deadlinePropagation() -> backendTimeout("10s") -> "%s";
+		nolimit: Path("/nolimit") -> deadlinePropagation() -> "%s";
+	`, backend.URL, backend.URL, backend.URL), FlagsNone)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer tp.close()
+
+	serve := func(path string, header http.Header) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", path, nil)
+		for k, v := range header {
+			req.Header[k] = v
+		}
+
+		w := httptest.NewRecorder()
+		tp.proxy.ServeHTTP(w, req)
+		return w
+	}
+
+	remaining := func(w *httptest.ResponseRecorder) float64 {
+		v, err := strconv.ParseFloat(w.Header().Get("X-Request-Timeout"), 64)
+		if err != nil {
+			t.Fatalf("invalid request timeout header: %q", w.Header().Get("X-Request-Timeout"))
+		}
+
+		return v
+	}
+
+	t.Run("not propagated", func(t *testing.T) {
+		w := serve("/plain", nil)
+		if w.Header().Get("X-Request-Timeout") != "" {
+			t.Errorf("unexpected deadline header: %s", w.Header().Get("X-Request-Timeout"))
+		}
+	})
+
+	t.Run("route timeout", func(t *testing.T) {
+		w := serve("/propagated", nil)
+		if r := remaining(w); r <= 9 || r > 10 {
+			t.Errorf("unexpected remaining time: %v", r)
+		}
+	})
+
+	t.Run("client deadline", func(t *testing.T) {
+		w := serve("/propagated", http.Header{"X-Request-Timeout": []string{"2"}})
+		if r := remaining(w); r <= 1 || r > 2 {
+			t.Errorf("unexpected remaining time: %v", r)
+		}
+	})
+
+	t.Run("client deadline longer than the route timeout", func(t *testing.T) {
+		w := serve("/propagated", http.Header{"X-Request-Timeout": []string{"60"}})
+		if r := remaining(w); r <= 9 || r > 10 {
+			t.Errorf("unexpected remaining time: %v", r)
+		}
+	})
+
+	t.Run("client deadline without route timeout", func(t *testing.T) {
+		w := serve("/nolimit", http.Header{"Grpc-Timeout": []string{"3S"}})
+		if r := remaining(w); r <= 2 || r > 3 {
+			t.Errorf("unexpected remaining time: %v", r)
+		}
+	})
+
+	t.Run("grpc timeout", func(t *testing.T) {
+		w := serve("/propagated", http.Header{"Content-Type": []string{"application/grpc"}, "Grpc-Timeout": []string{"5S"}})
+		if v := w.Header().Get("X-Grpc-Timeout"); len(v) < 2 || v[len(v)-1] != 'u' {
+			t.Errorf("unexpected grpc timeout: %q", v)
+		}
+	})
+
+	t.Run("client deadline exceeded", func(t *testing.T) {
+		w := serve("/propagated?sleep=200ms", http.Header{"Grpc-Timeout": []string{"50m"}})
+		if w.Code != http.StatusGatewayTimeout {
+			t.Errorf("unexpected status: %d", w.Code)
+		}
+	})
+}
@@ -0,0 +1,91 @@
+package proxy
+
+import (
+	stdlibcontext "context"
+	"time"
+
+	"github.com/zalando/skipper/filters"
+)
+
+// detachedContext keeps the values of the incoming request context, e.g.
+// the tracing span, but it is not canceled when the client disconnects
+type detachedContext struct {
+	parent stdlibcontext.Context
+}
+
+func (detachedContext) Deadline() (time.Time, bool)         { return time.Time{}, false }
+func (detachedContext) Done() <-chan struct{}               { return nil }
+func (detachedContext) Err() error                          { return nil }
+func (c detachedContext) Value(key interface{}) interface{} { return c.parent.Value(key) }
+
+// returns the grace period of the route, or the default
+func (p *Proxy) disconnectGracePeriod(ctx *context) time.Duration {
+	if d, ok := ctx.StateBag()[filters.ClientDisconnectKey].(time.Duration); ok {
+		return d
+	}
+
+	return p.disconnectGrace
+}
+
+// backendContext returns the context of the backend request. By default,
+// it is the context of the incoming request, which is canceled when the
+// client disconnects. With a grace period, the backend request is
+// canceled only when it doesn't finish within the grace period after the
+// disconnect, and with a negative grace period, it is not canceled. The
+// backend timeouts apply in every case. The context is released at the
+// end of serving the request.
+func (p *Proxy) backendContext(ctx *context) stdlibcontext.Context {
+	var cancels []stdlibcontext.CancelFunc
+
+	backendContext := ctx.request.Context()
+	if grace := p.disconnectGracePeriod(ctx); grace != 0 {
+		var cancel stdlibcontext.CancelFunc
+		client := backendContext
+		backendContext, cancel = stdlibcontext.WithCancel(detachedContext{parent: client})
+		cancels = append(cancels, cancel)
+		if grace > 0 {
+			go cancelAfterDisconnect(client, backendContext, cancel, grace)
+		}
+	}
+
+	if deadline, ok := backendDeadline(ctx); ok {
+		var cancel stdlibcontext.CancelFunc
+		backendContext, cancel = stdlibcontext.WithDeadline(backendContext, deadline)
+		cancels = append(cancels, cancel)
+	}
+
+	backendContext, cancel := p.streamContext(ctx, backendContext)
+	if cancel != nil {
+		cancels = append(cancels, cancel)
+	}
+
+	switch len(cancels) {
+	case 0:
+	case 1:
+		ctx.cancelBackendContext = cancels[0]
+	default:
+		ctx.cancelBackendContext = func() {
+			for i := len(cancels) - 1; i >= 0; i-- {
+				cancels[i]()
+			}
+		}
+	}
+
+	return backendContext
+}
+
+func cancelAfterDisconnect(client, backend stdlibcontext.Context, cancel stdlibcontext.CancelFunc, grace time.Duration) {
+	select {
+	case <-client.Done():
+	case <-backend.Done():
+		return
+	}
+
+	t := time.NewTimer(grace)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		cancel()
+	case <-backend.Done():
+	}
+}
@@ -0,0 +1,81 @@
+package proxy
+
+import (
+	stdlibcontext "context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestClientDisconnect(t *testing.T) {
+	canceled := make(chan bool, 1)
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+			canceled <- true
+		case <-time.After(300 * time.Millisecond):
+			canceled <- false
+		}
+	}))
+	defer backend.Close()
+
+	doc := fmt.Sprintf(`
+		default: Path("/default") -> "%s";
+		cancel: Path("/cancel") -> clientDisconnect("cancel") -> "%s";
+		ignore: Path("/ignore") -> clientDisconnect("ignore") -> "%s";
+		shortGrace: Path("/short-grace") -> clientDisconnect("grace", "50ms") -> "%s";
+		longGrace: Path("/long-grace") -> clientDisconnect("grace", "2s") -> "%s";
+		timeout: Path("/timeout") -> clientDisconnect("ignore") -> backendTimeout("100ms") -> "%s";
+	`, backend.URL, backend.URL, backend.URL, backend.URL, backend.URL, backend.URL)
+
+	for _, tc := range []struct {
+		name     string
+		grace    time.Duration
+		path     string
+		canceled bool
+	}{
+		{name: "cancels by default", path: "/default", canceled: true},
+		{name: "global grace period", grace: 2 * time.Second, path: "/default", canceled: false},
+		{name: "global ignore", grace: -1, path: "/default", canceled: false},
+		{name: "route cancels", grace: -1, path: "/cancel", canceled: true},
+		{name: "route ignores", path: "/ignore", canceled: false},
+		{name: "route grace period expires", path: "/short-grace", canceled: true},
+		{name: "route grace period", path: "/long-grace", canceled: false},
+		{name: "backend timeout applies when ignored", path: "/timeout", canceled: true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			tp, err := newTestProxyWithParams(doc, Params{ClientDisconnectGracePeriod: tc.grace})
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer tp.close()
+
+			ps := httptest.NewServer(tp.proxy)
+			defer ps.Close()
+
+			ctx, cancel := stdlibcontext.WithTimeout(stdlibcontext.Background(), 30*time.Millisecond)
+			defer cancel()
+
+			req, err := http.NewRequestWithContext(ctx, "GET", ps.URL+tc.path, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if rsp, err := http.DefaultClient.Do(req); err == nil {
+				rsp.Body.Close()
+				t.Fatal("expected the client to disconnect")
+			}
+
+			select {
+			case c := <-canceled:
+				if c != tc.canceled {
+					t.Errorf("expected canceled: %v, got: %v", tc.canceled, c)
+				}
+			case <-time.After(time.Second):
+				t.Fatal("backend request not received")
+			}
+		})
+	}
+}
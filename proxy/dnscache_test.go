@@ -0,0 +1,89 @@
+package proxy
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/zalando/skipper/net/dnstest"
+)
+
+func TestDNSCache(t *testing.T) {
+	dnstest.LoopbackNames(t, "app.dnscache.test")
+
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer backend.Close()
+
+	u, _ := url.Parse(backend.URL)
+	tp, err := newTestProxyWithParams(
+		`* -> "http://`+net.JoinHostPort("app.dnscache.test", u.Port())+`"`,
+		Params{DNSCacheTTL: time.Minute, CloseIdleConnsPeriod: -1, Flags: FlagsNone},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer tp.close()
+
+	for i := 0; i < 2; i++ {
+		w := httptest.NewRecorder()
+		tp.proxy.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("unexpected status: %d", w.Code)
+		}
+
+		// forces a new connection
+		tp.proxy.roundTripper.(*http.Transport).CloseIdleConnections()
+	}
+}
+
+func TestDNSServers(t *testing.T) {
+	server := dnstest.LoopbackServer(t, "app.dnsservers.test")
+
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer backend.Close()
+
+	u, _ := url.Parse(backend.URL)
+	tp, err := newTestProxyWithParams(
+		`* -> "http://`+net.JoinHostPort("app.dnsservers.test", u.Port())+`"`,
+		Params{DNSServers: []string{server}, DNSRespectTTL: true, CloseIdleConnsPeriod: -1, Flags: FlagsNone},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer tp.close()
+
+	w := httptest.NewRecorder()
+	tp.proxy.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("unexpected status: %d", w.Code)
+	}
+}
+
+func TestHappyEyeballs(t *testing.T) {
+	dnstest.LoopbackNames(t, "app.happyeyeballs.test")
+
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer backend.Close()
+
+	u, _ := url.Parse(backend.URL)
+	tp, err := newTestProxyWithParams(
+		`* -> "http://`+net.JoinHostPort("app.happyeyeballs.test", u.Port())+`"`,
+		Params{HappyEyeballs: true, DialFallbackDelay: 10 * time.Millisecond, CloseIdleConnsPeriod: -1, Flags: FlagsNone},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer tp.close()
+
+	w := httptest.NewRecorder()
+	tp.proxy.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("unexpected status: %d", w.Code)
+	}
+}
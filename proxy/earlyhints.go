@@ -0,0 +1,100 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptrace"
+	"net/textproto"
+	"sync"
+
+	"github.com/zalando/skipper/filters"
+)
+
+// earlyHintsForwarder forwards the 103 Early Hints responses of a backend
+// request to the client. It stops forwarding when the backend request is
+// done, so that the hints of a losing hedged request are not written
+// concurrently with the final response.
+type earlyHintsForwarder struct {
+	mu   sync.Mutex
+	w    http.ResponseWriter
+	done bool
+}
+
+// writeEarlyHints sends a 103 response with the header to the client. The
+// net/http server sends the header of the response writer with the 1xx
+// responses, so the fields are set only temporarily.
+func writeEarlyHints(w http.ResponseWriter, h http.Header) {
+	wh := w.Header()
+	saved := make(http.Header)
+	for k, v := range h {
+		if sv, ok := wh[k]; ok {
+			saved[k] = sv
+		}
+
+		wh[k] = v
+	}
+
+	w.WriteHeader(http.StatusEarlyHints)
+	for k := range h {
+		if sv, ok := saved[k]; ok {
+			wh[k] = sv
+		} else {
+			delete(wh, k)
+		}
+	}
+}
+
+// supportsEarlyHints tells whether the client understands the 1xx
+// responses
+func supportsEarlyHints(ctx *context) bool {
+	return ctx.request.ProtoAtLeast(1, 1)
+}
+
+// sendEarlyHints sends the links set by the earlyHints filter, once per
+// request.
+func (p *Proxy) sendEarlyHints(ctx *context) {
+	links, ok := ctx.StateBag()[filters.EarlyHintsKey].([]string)
+	if !ok || len(links) == 0 || ctx.earlyHintsSent || !supportsEarlyHints(ctx) {
+		return
+	}
+
+	ctx.earlyHintsSent = true
+	writeEarlyHints(ctx.responseWriter, http.Header{"Link": links})
+}
+
+// earlyHintsTrace returns the request with a client trace forwarding
+// the 103 responses of the backend, and the function that stops the
+// forwarding.
+func (p *Proxy) earlyHintsTrace(ctx *context, req *http.Request) (*http.Request, func()) {
+	if !p.forwardEarlyHints || !supportsEarlyHints(ctx) {
+		return req, func() {}
+	}
+
+	f := &earlyHintsForwarder{w: ctx.responseWriter}
+	trace := &httptrace.ClientTrace{
+		Got1xxResponse: func(code int, h textproto.MIMEHeader) error {
+			if code == http.StatusEarlyHints {
+				f.forward(http.Header(h))
+			}
+
+			return nil
+		},
+	}
+
+	return req.WithContext(httptrace.WithClientTrace(req.Context(), trace)), f.stop
+}
+
+func (f *earlyHintsForwarder) forward(h http.Header) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.done {
+		return
+	}
+
+	writeEarlyHints(f.w, cloneHeaderExcluding(h, hopHeaders))
+}
+
+func (f *earlyHintsForwarder) stop() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.done = true
+}
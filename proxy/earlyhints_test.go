@@ -0,0 +1,130 @@
+package proxy
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httptrace"
+	"net/textproto"
+	"reflect"
+	"testing"
+)
+
+func getWithEarlyHints(t *testing.T, u string) (*http.Response, [][]string) {
+	t.Helper()
+
+	var hints [][]string
+	trace := &httptrace.ClientTrace{
+		Got1xxResponse: func(code int, h textproto.MIMEHeader) error {
+			if code == http.StatusEarlyHints {
+				hints = append(hints, h["Link"])
+			}
+
+			return nil
+		},
+	}
+
+	req, err := http.NewRequest("GET", u, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req = req.WithContext(httptrace.WithClientTrace(req.Context(), trace))
+	rsp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rsp.Body.Close()
+	return rsp, hints
+}
+
+func TestEarlyHints(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/backend-hints" {
+			w.Header().Set("Link", "</backend.css>; rel=preload; as=style")
+			w.WriteHeader(http.StatusEarlyHints)
+			w.Header().Del("Link")
+		}
+
+		w.Header().Set("X-Final", "true")
+	}))
+	defer backend.Close()
+
+	doc := fmt.Sprintf(`
+		filter: Path("/filter-hints") -> earlyHints("</style.css>; rel=preload; as=style", "</app.js>; rel=preload; as=script") -> "%s";
+		backend: Path("/backend-hints") -> "%s";
+		both: Path("/both") -> earlyHints("</style.css>; rel=preload; as=style") -> setPath("/backend-hints") -> "%s";
+	`, backend.URL, backend.URL, backend.URL)
+
+	for _, tc := range []struct {
+		name     string
+		forward  bool
+		path     string
+		expected [][]string
+	}{{
+		name:     "filter",
+		path:     "/filter-hints",
+		expected: [][]string{{"</style.css>; rel=preload; as=style", "</app.js>; rel=preload; as=script"}},
+	}, {
+		name: "backend not forwarded",
+		path: "/backend-hints",
+	}, {
+		name:     "backend forwarded",
+		forward:  true,
+		path:     "/backend-hints",
+		expected: [][]string{{"</backend.css>; rel=preload; as=style"}},
+	}, {
+		name:    "filter and backend",
+		forward: true,
+		path:    "/both",
+		expected: [][]string{
+			{"</style.css>; rel=preload; as=style"},
+			{"</backend.css>; rel=preload; as=style"},
+		},
+	}} {
+		t.Run(tc.name, func(t *testing.T) {
+			tp, err := newTestProxyWithParams(doc, Params{ForwardEarlyHints: tc.forward})
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer tp.close()
+
+			ps := httptest.NewServer(tp.proxy)
+			defer ps.Close()
+
+			rsp, hints := getWithEarlyHints(t, ps.URL+tc.path)
+			if rsp.StatusCode != http.StatusOK || rsp.Header.Get("X-Final") != "true" {
+				t.Fatalf("unexpected final response: %d %v", rsp.StatusCode, rsp.Header)
+			}
+
+			if link := rsp.Header.Get("Link"); link != "" {
+				t.Errorf("unexpected link in the final response: %s", link)
+			}
+
+			if !reflect.DeepEqual(hints, tc.expected) {
+				t.Errorf("expected %v, got %v", tc.expected, hints)
+			}
+		})
+	}
+}
+
+func TestEarlyHintsHTTP10(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer backend.Close()
+
+	tp, err := newTestProxy(fmt.Sprintf(`* -> earlyHints("</style.css>; rel=preload") -> "%s"`, backend.URL), FlagsNone)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tp.close()
+
+	r := httptest.NewRequest("GET", "/", nil)
+	r.Proto, r.ProtoMajor, r.ProtoMinor = "HTTP/1.0", 1, 0
+
+	w := httptest.NewRecorder()
+	tp.proxy.ServeHTTP(w, r)
+	if w.Code != http.StatusOK {
+		t.Errorf("expected 200, got %d", w.Code)
+	}
+}
@@ -0,0 +1,54 @@
+package proxy
+
+import (
+	"testing"
+
+	"github.com/zalando/skipper/eskip"
+	"github.com/zalando/skipper/loadbalancer"
+	"github.com/zalando/skipper/routing"
+)
+
+func TestSelectEndpointHonorsState(t *testing.T) {
+	for _, algorithm := range []string{"roundRobin", "random", "consistentHash", "powerOfRandomNChoices"} {
+		t.Run(algorithm, func(t *testing.T) {
+			r := &routing.Route{Route: eskip.Route{
+				BackendType: eskip.LBBackend,
+				LBAlgorithm: algorithm,
+				LBEndpoints: []string{"http://10.0.0.1:80", "http://10.0.0.2:80", "http://10.0.0.3:80"},
+			}}
+
+			routes := loadbalancer.NewAlgorithmProvider().Do([]*routing.Route{r})
+			if len(routes) != 1 {
+				t.Fatal("failed to initialize the route")
+			}
+
+			r = routes[0]
+			ctx := &routing.LBContext{Route: r, Params: map[string]interface{}{loadbalancer.ConsistentHashKey: "foo"}}
+			selected := func() map[string]int {
+				counts := make(map[string]int)
+				for i := 0; i < 300; i++ {
+					counts[selectEndpoint(r, ctx).Host]++
+				}
+
+				return counts
+			}
+
+			r.LBEndpoints[0].Metrics.SetState(routing.EndpointDrained)
+			r.LBEndpoints[1].Metrics.SetState(routing.EndpointUnhealthy)
+			if counts := selected(); counts["10.0.0.3:80"] != 300 {
+				t.Errorf("failed to select the healthy endpoint: %v", counts)
+			}
+
+			r.LBEndpoints[2].Metrics.SetState(routing.EndpointDrained)
+			if counts := selected(); counts["10.0.0.2:80"] != 300 {
+				t.Errorf("failed to select the unhealthy endpoint: %v", counts)
+			}
+
+			r.LBEndpoints[0].Metrics.SetState(routing.EndpointHealthy)
+			r.LBEndpoints[1].Metrics.SetState(routing.EndpointHealthy)
+			if counts := selected(); counts["10.0.0.3:80"] != 0 || algorithm != "consistentHash" && (counts["10.0.0.1:80"] == 0 || counts["10.0.0.2:80"] == 0) {
+				t.Errorf("unexpected distribution: %v", counts)
+			}
+		})
+	}
+}
@@ -14,16 +14,44 @@ import (
 
 type RoundTripper struct {
 	log     logging.Logger
+	addr    string
+	pool    *ClientPool
 	client  gofast.Client
 	handler gofast.SessionHandler
 }
 
+// Options for the FastCGI round tripper.
+type Options struct {
+
+	// Pool, when set, is used to reuse the connections to the backend
+	// across the requests. Without a pool, every request uses a new
+	// connection.
+	Pool *ClientPool
+
+	// Params contains additional FastCGI params sent to the backend,
+	// e.g. PHP_VALUE or PHP_ADMIN_VALUE to override the PHP settings
+	// per route. They take precedence over the params derived from the
+	// request.
+	Params map[string]string
+}
+
 func NewRoundTripper(log logging.Logger, addr, filename string) (*RoundTripper, error) {
-	connFactory := gofast.SimpleConnFactory("tcp", addr)
+	return NewRoundTripperWithOptions(log, addr, filename, Options{})
+}
 
-	client, err := gofast.SimpleClientFactory(connFactory)()
-	if err != nil {
-		return nil, fmt.Errorf("gofast: failed creating client: %w", err)
+// NewRoundTripperWithOptions creates a round tripper to the FastCGI
+// backend at addr, executing filename, with the connection pool and the
+// additional params set in the options.
+func NewRoundTripperWithOptions(log logging.Logger, addr, filename string, o Options) (*RoundTripper, error) {
+	var client gofast.Client
+	if o.Pool == nil {
+		connFactory := gofast.SimpleConnFactory("tcp", addr)
+
+		var err error
+		client, err = gofast.SimpleClientFactory(connFactory)()
+		if err != nil {
+			return nil, fmt.Errorf("gofast: failed creating client: %w", err)
+		}
 	}
 
 	chain := gofast.Chain(
@@ -38,6 +66,10 @@ func NewRoundTripper(log logging.Logger, addr, filename string) (*RoundTripper,
 				// Gofast sets this param to `fastcgi` which is not what the backend will expect.
 				delete(req.Params, "REQUEST_SCHEME")
 
+				for k, v := range o.Params {
+					req.Params[k] = v
+				}
+
 				return handler(client, req)
 			}
 		},
@@ -45,12 +77,30 @@ func NewRoundTripper(log logging.Logger, addr, filename string) (*RoundTripper,
 
 	return &RoundTripper{
 		log:     log,
+		addr:    addr,
+		pool:    o.Pool,
 		client:  client,
 		handler: chain(gofast.BasicSession),
 	}, nil
 }
 
 func (rt *RoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	if rt.pool != nil {
+		client, err := rt.pool.get(rt.addr)
+		if err != nil {
+			return nil, fmt.Errorf("gofast: failed creating client: %w", err)
+		}
+
+		rsp, err := rt.roundTrip(client, req)
+		if err == nil {
+			rt.pool.put(rt.addr, client)
+		} else if cerr := client.Close(); cerr != nil {
+			rt.log.Errorf("gofast: error closing client: %s", cerr.Error())
+		}
+
+		return rsp, err
+	}
+
 	defer func() {
 		if rt.client == nil {
 			return
@@ -61,7 +111,11 @@ func (rt *RoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
 		}
 	}()
 
-	resp, err := rt.handler(rt.client, gofast.NewRequest(req))
+	return rt.roundTrip(rt.client, req)
+}
+
+func (rt *RoundTripper) roundTrip(client gofast.Client, req *http.Request) (*http.Response, error) {
+	resp, err := rt.handler(client, gofast.NewRequest(req))
 	if err != nil {
 		return nil, fmt.Errorf("gofast: failed to process request: %w", err)
 	}
@@ -0,0 +1,106 @@
+package fastcgi
+
+import (
+	"sync"
+	"time"
+
+	"github.com/yookoala/gofast"
+)
+
+type idleClient struct {
+	client gofast.Client
+	since  time.Time
+}
+
+// ClientPool keeps the idle connections to the FastCGI backends, e.g.
+// PHP-FPM, by their address, and reuses them for the subsequent requests.
+// Only the connections of the successful requests are returned to the
+// pool.
+type ClientPool struct {
+	mu          sync.Mutex
+	maxIdle     int
+	idleTimeout time.Duration
+	idle        map[string][]idleClient
+	closed      bool
+	now         func() time.Time
+	dial        func(addr string) (gofast.Client, error)
+}
+
+// NewClientPool creates a connection pool keeping at most maxIdle idle
+// connections per backend address. The idle connections older than
+// idleTimeout are closed instead of reused. When idleTimeout is not
+// positive, the idle connections don't expire.
+func NewClientPool(maxIdle int, idleTimeout time.Duration) *ClientPool {
+	return &ClientPool{
+		maxIdle:     maxIdle,
+		idleTimeout: idleTimeout,
+		idle:        make(map[string][]idleClient),
+		now:         time.Now,
+		dial: func(addr string) (gofast.Client, error) {
+			return gofast.SimpleClientFactory(gofast.SimpleConnFactory("tcp", addr))()
+		},
+	}
+}
+
+func (p *ClientPool) expired(c idleClient) bool {
+	return p.idleTimeout > 0 && p.now().Sub(c.since) > p.idleTimeout
+}
+
+// returns an idle connection to the address, or dials a new one
+func (p *ClientPool) get(addr string) (gofast.Client, error) {
+	var expired []gofast.Client
+
+	p.mu.Lock()
+	var client gofast.Client
+	for client == nil && len(p.idle[addr]) > 0 {
+		clients := p.idle[addr]
+		last := clients[len(clients)-1]
+		p.idle[addr] = clients[:len(clients)-1]
+		if p.expired(last) {
+			expired = append(expired, last.client)
+		} else {
+			client = last.client
+		}
+	}
+
+	p.mu.Unlock()
+
+	for _, c := range expired {
+		c.Close()
+	}
+
+	if client != nil {
+		return client, nil
+	}
+
+	return p.dial(addr)
+}
+
+// returns a connection to the pool, or closes it when the pool is full
+func (p *ClientPool) put(addr string, c gofast.Client) {
+	p.mu.Lock()
+	if p.closed || len(p.idle[addr]) >= p.maxIdle {
+		p.mu.Unlock()
+		c.Close()
+		return
+	}
+
+	p.idle[addr] = append(p.idle[addr], idleClient{client: c, since: p.now()})
+	p.mu.Unlock()
+}
+
+// Close closes the idle connections. The connections used at the time of
+// the call are closed when they are returned.
+func (p *ClientPool) Close() {
+	p.mu.Lock()
+	idle := p.idle
+	p.idle = make(map[string][]idleClient)
+	p.closed = true
+	p.mu.Unlock()
+
+	for _, clients := range idle {
+		for _, c := range clients {
+			c.client.Close()
+		}
+	}
+}
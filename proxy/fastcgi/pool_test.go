@@ -0,0 +1,121 @@
+package fastcgi
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"net/http/fcgi"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/yookoala/gofast"
+	"github.com/zalando/skipper/logging"
+)
+
+type closeRecorder struct {
+	closed bool
+}
+
+func (*closeRecorder) Do(*gofast.Request) (*gofast.ResponsePipe, error) { return nil, nil }
+
+func (c *closeRecorder) Close() error {
+	c.closed = true
+	return nil
+}
+
+type countingListener struct {
+	net.Listener
+	accepted int64
+}
+
+func (l *countingListener) Accept() (net.Conn, error) {
+	c, err := l.Listener.Accept()
+	if err == nil {
+		atomic.AddInt64(&l.accepted, 1)
+	}
+
+	return c, err
+}
+
+func TestPooledRoundTripperWithParams(t *testing.T) {
+	tl, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	l := &countingListener{Listener: tl}
+	defer l.Close()
+
+	go fcgi.Serve(l, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(fcgi.ProcessEnv(r)["PHP_VALUE"]))
+	}))
+
+	pool := NewClientPool(2, time.Minute)
+	defer pool.Close()
+
+	rt, err := NewRoundTripperWithOptions(&logging.DefaultLog{}, l.Addr().String(), "index.php", Options{
+		Pool:   pool,
+		Params: map[string]string{"PHP_VALUE": "memory_limit=256M"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	u, _ := url.ParseRequestURI("http://www.example.org/")
+	for i := 0; i < 3; i++ {
+		rsp, err := rt.RoundTrip(&http.Request{URL: u, Method: "GET", Proto: "HTTP/1.0"})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		b, err := io.ReadAll(rsp.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if rsp.StatusCode != http.StatusOK || string(b) != "memory_limit=256M" {
+			t.Fatalf("unexpected response: %d, %s", rsp.StatusCode, b)
+		}
+	}
+
+	if n := atomic.LoadInt64(&l.accepted); n != 1 {
+		t.Errorf("expected a single reused connection, got: %d", n)
+	}
+}
+
+func TestClientPoolExpiresIdleConnections(t *testing.T) {
+	var dialed int
+	now := time.Now()
+	pool := NewClientPool(1, time.Second)
+	pool.now = func() time.Time { return now }
+	pool.dial = func(string) (gofast.Client, error) {
+		dialed++
+		return &closeRecorder{}, nil
+	}
+
+	c, _ := pool.get("backend")
+	pool.put("backend", c)
+
+	if reused, _ := pool.get("backend"); reused != c {
+		t.Error("idle connection not reused")
+	}
+
+	pool.put("backend", c)
+	now = now.Add(2 * time.Second)
+	if fresh, _ := pool.get("backend"); fresh == c || !c.(*closeRecorder).closed {
+		t.Error("expired connection reused or not closed")
+	}
+
+	extra := &closeRecorder{}
+	pool.put("backend", &closeRecorder{})
+	pool.put("backend", extra)
+	if !extra.closed {
+		t.Error("connection exceeding the idle limit not closed")
+	}
+
+	if dialed != 2 {
+		t.Errorf("unexpected number of dials: %d", dialed)
+	}
+}
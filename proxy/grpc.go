@@ -0,0 +1,263 @@
+package proxy
+
+import (
+	stdlibcontext "context"
+	"crypto/tls"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+
+	"golang.org/x/net/http2"
+
+	"github.com/zalando/skipper/filters"
+)
+
+// gRPC status codes, see
+// https://github.com/grpc/grpc/blob/master/doc/statuscodes.md
+const (
+	grpcCanceled          = 1
+	grpcUnknown           = 2
+	grpcDeadlineExceeded  = 4
+	grpcPermissionDenied  = 7
+	grpcUnimplemented     = 12
+	grpcInternal          = 13
+	grpcUnavailable       = 14
+	grpcUnauthenticated   = 16
+	grpcStatusHeader      = "Grpc-Status"
+	grpcMessageHeader     = "Grpc-Message"
+	grpcContentType       = "application/grpc"
+	grpcBrokenStreamError = "backend stream broken"
+)
+
+func useH2C(ctx *context) bool {
+	v, _ := ctx.StateBag()[filters.BackendH2CKey].(bool)
+	return v
+}
+
+// isGRPCRequest returns true for the requests with the application/grpc
+// content type, including its subtypes, e.g. application/grpc+proto. The
+// gRPC-Web requests are proxied as regular HTTP requests.
+func isGRPCRequest(r *http.Request) bool {
+	return isGRPCContentType(r.Header.Get("Content-Type"))
+}
+
+func isGRPCContentType(ct string) bool {
+	if !strings.HasPrefix(ct, grpcContentType) {
+		return false
+	}
+
+	ct = ct[len(grpcContentType):]
+	return ct == "" || ct[0] == '+' || ct[0] == ';'
+}
+
+// maps the status codes of the proxy errors to gRPC status codes, based
+// on https://github.com/grpc/grpc/blob/master/doc/http-grpc-status-mapping.md,
+// with the client cancellation and the backend timeouts mapped to their
+// specific gRPC counterparts
+func grpcStatusFromHTTP(code int) int {
+	switch code {
+	case 499:
+		return grpcCanceled
+	case http.StatusGatewayTimeout:
+		return grpcDeadlineExceeded
+	case http.StatusBadRequest, http.StatusInternalServerError:
+		return grpcInternal
+	case http.StatusUnauthorized:
+		return grpcUnauthenticated
+	case http.StatusForbidden:
+		return grpcPermissionDenied
+	case http.StatusNotFound:
+		return grpcUnimplemented
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable:
+		return grpcUnavailable
+	default:
+		return grpcUnknown
+	}
+}
+
+// sends the proxy error as a trailers-only gRPC response
+func (p *Proxy) sendGRPCError(c *context, id string, code int) {
+	addBranding(c.responseWriter.Header())
+
+	status := grpcStatusFromHTTP(code)
+	h := c.responseWriter.Header()
+	h.Set("Content-Type", grpcContentType)
+	h.Set(grpcStatusHeader, strconv.Itoa(status))
+	h.Set(grpcMessageHeader, url.PathEscape(http.StatusText(code)))
+	c.responseWriter.WriteHeader(http.StatusOK)
+
+	p.metrics.MeasureServe(
+		id,
+		c.metricsHost(),
+		c.request.Method,
+		code,
+		c.startServe,
+	)
+
+	p.measureGRPC(c, status, false)
+}
+
+// sets the trailers of the backend response for the client, and when
+// the streaming of the response body failed, reports the failure with
+// the gRPC status UNAVAILABLE, instead of leaving the stream unfinished
+func (p *Proxy) finishGRPCResponse(c *context, streamErr error) {
+	var status int
+	if streamErr != nil {
+		h := c.responseWriter.Header()
+		h.Set(http.TrailerPrefix+grpcStatusHeader, strconv.Itoa(grpcUnavailable))
+		h.Set(http.TrailerPrefix+grpcMessageHeader, url.PathEscape(grpcBrokenStreamError))
+		status = grpcUnavailable
+	} else {
+		s := c.response.Trailer.Get(grpcStatusHeader)
+		if s == "" {
+			// trailers-only response
+			s = c.response.Header.Get(grpcStatusHeader)
+		}
+
+		var err error
+		if status, err = strconv.Atoi(s); err != nil {
+			status = grpcUnknown
+		}
+	}
+
+	p.measureGRPC(c, status, isGRPCContentType(c.response.Header.Get("Content-Type")))
+}
+
+// measures the gRPC calls by service, method and status code. The service
+// and the method are taken from the request path only when a gRPC backend
+// answered the call with a status other than UNIMPLEMENTED, otherwise the
+// calls are measured under the unknown service and method, to avoid that
+// the clients create metrics for arbitrary request paths.
+func (p *Proxy) measureGRPC(c *context, status int, backendAnswered bool) {
+	service, method := "unknown", "unknown"
+	if backendAnswered && status != grpcUnimplemented {
+		if s, m, ok := grpcMethod(c.request.URL.Path); ok {
+			service, method = s, m
+		}
+	}
+
+	key := "grpc." + service + "." + method
+	p.metrics.MeasureSince(key, c.startServe)
+	p.metrics.IncCounter(key + "." + strconv.Itoa(status))
+}
+
+// parses the gRPC service and method from the request path in the form of
+// /package.Service/Method. The dots in the service name are replaced with
+// underscores, to keep them as a single segment of the metrics keys.
+func grpcMethod(path string) (service, method string, ok bool) {
+	service, method, ok = strings.Cut(strings.TrimPrefix(path, "/"), "/")
+	if !ok || service == "" || method == "" || strings.Contains(method, "/") {
+		return "", "", false
+	}
+
+	return strings.ReplaceAll(service, ".", "_"), method, true
+}
+
+// grpcTransport proxies the gRPC requests, and the requests of the routes
+// with the backendH2C filter, over HTTP/2, using h2c (HTTP/2 without TLS)
+// for the http backends. It is derived from a regular backend transport:
+// the https backends are reached by a copy of it with HTTP/2 enabled, and
+// the h2c connections use its dialer and its per-host connection limit.
+// The other settings, e.g. the timeouts, don't apply to the h2c
+// connections.
+type grpcTransport struct {
+	h2c *http2.Transport
+	h2  *http.Transport
+}
+
+func newGRPCTransport(tr *http.Transport) *grpcTransport {
+	h2 := tr.Clone()
+	h2.ForceAttemptHTTP2 = true
+
+	// the prewarmed TLS connections don't negotiate HTTP/2
+	h2.DialTLSContext = nil
+
+	dial := newConnLimit(tr.MaxConnsPerHost).dialContext(tr.DialContext)
+	h2c := &http2.Transport{
+		AllowHTTP:          true,
+		DisableCompression: tr.DisableCompression,
+		DialTLSContext: func(ctx stdlibcontext.Context, network, addr string, _ *tls.Config) (net.Conn, error) {
+			return dial(ctx, network, addr)
+		},
+	}
+
+	return &grpcTransport{h2c: h2c, h2: h2}
+}
+
+func (t *grpcTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.URL.Scheme == "http" {
+		return t.h2c.RoundTrip(req)
+	}
+
+	return t.h2.RoundTrip(req)
+}
+
+func (t *grpcTransport) CloseIdleConnections() {
+	t.h2c.CloseIdleConnections()
+	t.h2.CloseIdleConnections()
+}
+
+// connLimit limits the number of open connections to each address, the
+// same way as the MaxConnsPerHost of net/http.Transport, for the
+// transports that don't support it. The dials wait until a connection to
+// the same address is closed, or the context is done.
+type connLimit struct {
+	max   int
+	mu    sync.Mutex
+	slots map[string]chan struct{}
+}
+
+type limitedConn struct {
+	net.Conn
+	once    sync.Once
+	release func()
+}
+
+func newConnLimit(max int) *connLimit {
+	return &connLimit{max: max, slots: make(map[string]chan struct{})}
+}
+
+func (l *connLimit) addressSlots(addr string) chan struct{} {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	s, ok := l.slots[addr]
+	if !ok {
+		s = make(chan struct{}, l.max)
+		l.slots[addr] = s
+	}
+
+	return s
+}
+
+func (l *connLimit) dialContext(dial dialFunc) dialFunc {
+	if l.max <= 0 {
+		return dial
+	}
+
+	return func(ctx stdlibcontext.Context, network, addr string) (net.Conn, error) {
+		s := l.addressSlots(addr)
+		select {
+		case s <- struct{}{}:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+
+		conn, err := dial(ctx, network, addr)
+		if err != nil {
+			<-s
+			return nil, err
+		}
+
+		return &limitedConn{Conn: conn, release: func() { <-s }}, nil
+	}
+}
+
+func (c *limitedConn) Close() error {
+	err := c.Conn.Close()
+	c.once.Do(c.release)
+	return err
+}
@@ -0,0 +1,352 @@
+package proxy
+
+import (
+	"bytes"
+	stdlibcontext "context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+
+	"github.com/zalando/skipper/filters/builtin"
+	"github.com/zalando/skipper/logging/loggingtest"
+	"github.com/zalando/skipper/metrics"
+	"github.com/zalando/skipper/metrics/metricstest"
+	"github.com/zalando/skipper/routing"
+	"github.com/zalando/skipper/routing/testdataclient"
+)
+
+func TestIsGRPCRequest(t *testing.T) {
+	for _, ti := range []struct {
+		contentType string
+		expected    bool
+	}{
+		{"application/grpc", true},
+		{"application/grpc+proto", true},
+		{"application/grpc;charset=utf-8", true},
+		{"application/grpc-web", false},
+		{"application/grpcfoo", false},
+		{"application/json", false},
+		{"", false},
+	} {
+		r := &http.Request{Header: http.Header{"Content-Type": []string{ti.contentType}}}
+		if got := isGRPCRequest(r); got != ti.expected {
+			t.Errorf("%q: got %v, expected %v", ti.contentType, got, ti.expected)
+		}
+	}
+}
+
+func TestGRPCProxy(t *testing.T) {
+	backend := httptest.NewServer(h2c.NewHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.ProtoMajor != 2 || !isGRPCRequest(r) {
+			w.WriteHeader(http.StatusHTTPVersionNotSupported)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/grpc")
+		if r.URL.Path == "/test.Echo/Missing" {
+			w.Header().Set("Grpc-Status", "12")
+			return
+		}
+
+		w.Write([]byte("hello"))
+		w.(http.Flusher).Flush()
+
+		if r.URL.Path == "/test.Echo/Broken" {
+			panic(http.ErrAbortHandler)
+		}
+
+		w.Header().Set(http.TrailerPrefix+"Grpc-Status", "0")
+	}), &http2.Server{}))
+	defer backend.Close()
+
+	dc, err := testdataclient.NewDoc(fmt.Sprintf(`
+		echo: PathSubtree("/test.Echo") -> "%s";
+		down: Path("/test.Echo/Down") -> "http://127.0.0.1:1";
+	`, backend.URL))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tl := loggingtest.New()
+	defer tl.Close()
+
+	rt := routing.New(routing.Options{
+		FilterRegistry: builtin.MakeRegistry(),
+		DataClients:    []routing.DataClient{dc},
+		PollTimeout:    sourcePollTimeout,
+		Log:            tl,
+	})
+	defer rt.Close()
+
+	p := WithParams(Params{Routing: rt})
+	defer p.Close()
+
+	m := &metricstest.MockMetrics{}
+	p.metrics = counterMetrics{Metrics: metrics.Void, mock: m}
+
+	if err := tl.WaitFor("route settings applied", time.Second); err != nil {
+		t.Fatal(err)
+	}
+
+	ps := httptest.NewUnstartedServer(p)
+	ps.EnableHTTP2 = true
+	ps.StartTLS()
+	defer ps.Close()
+
+	call := func(path string) (*http.Response, []byte) {
+		req, err := http.NewRequest("POST", ps.URL+path, bytes.NewBufferString("hi"))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req.Header.Set("Content-Type", "application/grpc+proto")
+		rsp, err := ps.Client().Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		defer rsp.Body.Close()
+		b, err := io.ReadAll(rsp.Body)
+		if err != nil {
+			t.Fatalf("%s: failed to read the response body: %v", path, err)
+		}
+
+		if rsp.ProtoMajor != 2 || rsp.StatusCode != http.StatusOK {
+			t.Errorf("%s: unexpected response: %s %d", path, rsp.Proto, rsp.StatusCode)
+		}
+
+		return rsp, b
+	}
+
+	rsp, b := call("/test.Echo/Say")
+	if string(b) != "hello" || rsp.Trailer.Get("Grpc-Status") != "0" {
+		t.Errorf("failed to proxy the response with the trailers: %s, %v", b, rsp.Trailer)
+	}
+
+	rsp, b = call("/test.Echo/Broken")
+	if string(b) != "hello" || rsp.Trailer.Get("Grpc-Status") != "14" || rsp.Trailer.Get("Grpc-Message") == "" {
+		t.Errorf("failed to report the broken stream: %s, %v", b, rsp.Trailer)
+	}
+
+	for path, status := range map[string]string{
+		"/test.Echo/Down":    "14",
+		"/test.Echo/Missing": "12",
+		"/missing/Method":    "12",
+	} {
+		rsp, b = call(path)
+		if len(b) != 0 || rsp.Header.Get("Content-Type") != "application/grpc" || rsp.Header.Get("Grpc-Status") != status {
+			t.Errorf("%s: unexpected error response: %s, %v", path, b, rsp.Header)
+		}
+	}
+
+	m.WithCounters(func(counters map[string]int64) {
+		// the failed calls, that the backend didn't answer, and the
+		// unimplemented calls are measured without the path
+		for key, count := range map[string]int64{
+			"grpc.test_Echo.Say.0":     1,
+			"grpc.test_Echo.Broken.14": 1,
+			"grpc.unknown.unknown.14":  1,
+			"grpc.unknown.unknown.12":  2,
+		} {
+			if counters[key] != count {
+				t.Errorf("unexpected counter %s: %d, expected: %d", key, counters[key], count)
+			}
+		}
+
+		for key := range counters {
+			if key == "grpc.test_Echo.Down.14" || key == "grpc.test_Echo.Missing.12" || key == "grpc.missing.Method.12" {
+				t.Errorf("unexpected counter %s", key)
+			}
+		}
+	})
+}
+
+func TestTrailerPropagation(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Trailer", "X-Checksum")
+		w.Write([]byte("hello"))
+		w.Header().Set("X-Checksum", "42")
+	}))
+	defer backend.Close()
+
+	dc, err := testdataclient.NewDoc(fmt.Sprintf(`* -> "%s"`, backend.URL))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tl := loggingtest.New()
+	defer tl.Close()
+
+	rt := routing.New(routing.Options{
+		FilterRegistry: builtin.MakeRegistry(),
+		DataClients:    []routing.DataClient{dc},
+		PollTimeout:    sourcePollTimeout,
+		Log:            tl,
+	})
+	defer rt.Close()
+
+	p := WithParams(Params{Routing: rt})
+	defer p.Close()
+
+	if err := tl.WaitFor("route settings applied", time.Second); err != nil {
+		t.Fatal(err)
+	}
+
+	ps := httptest.NewServer(p)
+	defer ps.Close()
+
+	rsp, err := http.Get(ps.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer rsp.Body.Close()
+	b, err := io.ReadAll(rsp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(b) != "hello" || rsp.Trailer.Get("X-Checksum") != "42" {
+		t.Errorf("failed to propagate the trailers: %s, %v", b, rsp.Trailer)
+	}
+}
+
+func TestBackendH2C(t *testing.T) {
+	backend := httptest.NewServer(h2c.NewHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Proto))
+	}), &http2.Server{}))
+	defer backend.Close()
+
+	tp, err := newTestProxy(fmt.Sprintf(`
+		h1: Path("/h1") -> "%s";
+		h2c: Path("/h2c") -> backendH2C() -> "%s";
+	`, backend.URL, backend.URL), FlagsNone)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer tp.close()
+
+	ps := httptest.NewServer(tp.proxy)
+	defer ps.Close()
+
+	for path, proto := range map[string]string{"/h1": "HTTP/1.1", "/h2c": "HTTP/2.0"} {
+		rsp, err := http.Get(ps.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		b, err := io.ReadAll(rsp.Body)
+		rsp.Body.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if string(b) != proto {
+			t.Errorf("%s: unexpected backend protocol: %s", path, b)
+		}
+	}
+}
+
+type countingRoundTripper struct {
+	http.RoundTripper
+	count *int32
+}
+
+func (rt countingRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	atomic.AddInt32(rt.count, 1)
+	return rt.RoundTripper.RoundTrip(req)
+}
+
+func TestGRPCTransportWrapped(t *testing.T) {
+	backend := httptest.NewServer(h2c.NewHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Proto))
+	}), &http2.Server{}))
+	defer backend.Close()
+
+	var wrapped int32
+	tp, err := newTestProxyWithParams(fmt.Sprintf(`
+		h2c: Path("/h2c") -> backendH2C() -> "%s";
+		limited: Path("/limited") -> backendTransport("maxConnsPerHost", 1) -> backendH2C() -> "%s";
+	`, backend.URL, backend.URL), Params{
+		CustomHttpRoundTripperWrap: func(rt http.RoundTripper) http.RoundTripper {
+			return countingRoundTripper{RoundTripper: rt, count: &wrapped}
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer tp.close()
+
+	ps := httptest.NewServer(tp.proxy)
+	defer ps.Close()
+
+	for _, path := range []string{"/h2c", "/limited", "/limited"} {
+		rsp, err := http.Get(ps.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		b, err := io.ReadAll(rsp.Body)
+		rsp.Body.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if string(b) != "HTTP/2.0" {
+			t.Errorf("%s: unexpected backend protocol: %s", path, b)
+		}
+	}
+
+	if n := atomic.LoadInt32(&wrapped); n != 3 {
+		t.Errorf("failed to wrap the gRPC transports: %d", n)
+	}
+
+	if n := len(tp.proxy.routeTransports.grpc); n != 1 {
+		t.Errorf("unexpected number of route gRPC transports: %d", n)
+	}
+}
+
+func TestConnLimit(t *testing.T) {
+	dial := newConnLimit(1).dialContext(func(stdlibcontext.Context, string, string) (net.Conn, error) {
+		c, _ := net.Pipe()
+		return c, nil
+	})
+
+	c1, err := dial(stdlibcontext.Background(), "tcp", "10.0.0.1:80")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c2, err := dial(stdlibcontext.Background(), "tcp", "10.0.0.2:80")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer c2.Close()
+
+	ctx, cancel := stdlibcontext.WithTimeout(stdlibcontext.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := dial(ctx, "tcp", "10.0.0.1:80"); err == nil {
+		t.Fatal("failed to limit the connections")
+	}
+
+	c1.Close()
+	c1.Close()
+
+	c3, err := dial(stdlibcontext.Background(), "tcp", "10.0.0.1:80")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c3.Close()
+}
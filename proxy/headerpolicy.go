@@ -0,0 +1,37 @@
+package proxy
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/zalando/skipper/filters"
+	"github.com/zalando/skipper/filters/headerpolicy"
+)
+
+// returns the header policy of the route merged into the global one
+func (p *Proxy) backendHeaderPolicy(ctx *context) headerpolicy.Policy {
+	if route, ok := ctx.StateBag()[filters.BackendHeaderPolicyKey].(headerpolicy.Policy); ok {
+		return p.headerPolicy.Merge(route)
+	}
+
+	return p.headerPolicy
+}
+
+// sanitizeRequestHeader applies the header policy to the backend request.
+// It is applied after the filters, so the headers set by the filters are
+// sanitized, too.
+func (p *Proxy) sanitizeRequestHeader(ctx *context, req *http.Request) *proxyError {
+	policy := p.backendHeaderPolicy(ctx)
+	if policy.Empty() {
+		return nil
+	}
+
+	if !policy.Apply(req.Header) {
+		return &proxyError{
+			err:  fmt.Errorf("backend request header too large: %d bytes, limit %d", headerpolicy.Size(req.Header), policy.MaxHeaderSize),
+			code: http.StatusRequestHeaderFieldsTooLarge,
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,119 @@
+package proxy
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/zalando/skipper/filters/headerpolicy"
+)
+
+func TestBackendHeaderPolicy(t *testing.T) {
+	var received http.Header
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r.Header.Clone()
+	}))
+	defer backend.Close()
+
+	doc := fmt.Sprintf(`
+		default: Path("/default") -> setRequestHeader("X-Internal-Route", "default") -> "%s";
+		override: Path("/override") -> backendHeaderPolicy("strip", "X-Debug", "maxHeaderSize", 4096, "foldDuplicates", "false") -> "%s";
+	`, backend.URL, backend.URL)
+
+	fold := true
+	tp, err := newTestProxyWithParams(doc, Params{
+		BackendHeaderPolicy: headerpolicy.Policy{
+			Strip:          []string{"X-Internal-*"},
+			MaxHeaderSize:  512,
+			FoldDuplicates: &fold,
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tp.close()
+
+	ps := httptest.NewServer(tp.proxy)
+	defer ps.Close()
+
+	for _, tc := range []struct {
+		name     string
+		path     string
+		header   http.Header
+		status   int
+		expected http.Header
+		missing  []string
+	}{{
+		name:     "strips the global and the filter set headers",
+		path:     "/default",
+		header:   http.Header{"X-Internal-Id": {"42"}, "X-Debug": {"1"}},
+		status:   http.StatusOK,
+		expected: http.Header{"X-Debug": {"1"}},
+		missing:  []string{"X-Internal-Id", "X-Internal-Route"},
+	}, {
+		name:     "folds the duplicates",
+		path:     "/default",
+		header:   http.Header{"X-Tag": {"a", "b"}},
+		status:   http.StatusOK,
+		expected: http.Header{"X-Tag": {"a, b"}},
+	}, {
+		name:   "rejects the large header",
+		path:   "/default",
+		header: http.Header{"X-Large": {strings.Repeat("a", 512)}},
+		status: http.StatusRequestHeaderFieldsTooLarge,
+	}, {
+		name:     "route override",
+		path:     "/override",
+		header:   http.Header{"X-Internal-Id": {"42"}, "X-Debug": {"1"}, "X-Tag": {"a", "b"}, "X-Large": {strings.Repeat("a", 512)}},
+		status:   http.StatusOK,
+		expected: http.Header{"X-Tag": {"a", "b"}, "X-Large": {strings.Repeat("a", 512)}},
+		missing:  []string{"X-Internal-Id", "X-Debug"},
+	}, {
+		name:   "rejects the large header with the route override",
+		path:   "/override",
+		header: http.Header{"X-Large": {strings.Repeat("a", 4096)}},
+		status: http.StatusRequestHeaderFieldsTooLarge,
+	}} {
+		t.Run(tc.name, func(t *testing.T) {
+			received = nil
+
+			req, err := http.NewRequest("GET", ps.URL+tc.path, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			req.Header = tc.header
+			rsp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			defer rsp.Body.Close()
+			if rsp.StatusCode != tc.status {
+				t.Fatalf("expected %d, got %d", tc.status, rsp.StatusCode)
+			}
+
+			if tc.status != http.StatusOK {
+				if received != nil {
+					t.Error("unexpected backend request")
+				}
+
+				return
+			}
+
+			for name, values := range tc.expected {
+				if got := received[name]; strings.Join(got, "|") != strings.Join(values, "|") {
+					t.Errorf("expected %s: %v, got: %v", name, values, got)
+				}
+			}
+
+			for _, name := range tc.missing {
+				if _, ok := received[name]; ok {
+					t.Errorf("unexpected header: %s", name)
+				}
+			}
+		})
+	}
+}
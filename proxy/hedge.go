@@ -0,0 +1,206 @@
+package proxy
+
+import (
+	stdlibcontext "context"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/zalando/skipper/filters"
+	"github.com/zalando/skipper/routing"
+)
+
+type hedgeResult struct {
+	rsp      *http.Response
+	err      error
+	index    int
+	cancel   stdlibcontext.CancelFunc
+	endpoint *routing.LBEndpoint // nil for the primary request
+}
+
+// closes the response body of the winning request together with
+// cancelling its context
+type cancelBody struct {
+	io.ReadCloser
+	cancel stdlibcontext.CancelFunc
+}
+
+func (b cancelBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
+
+// hedgeDelay returns the delay set by the hedgeRequests filter, when the
+// request can be hedged: it is sent to a load balanced backend with more
+// than one endpoint, it has an idempotent method and no body.
+func hedgeDelay(ctx *context, req *http.Request, endpoint *routing.LBEndpoint) (time.Duration, bool) {
+	delay, ok := ctx.StateBag()[filters.HedgeDelayKey].(time.Duration)
+	if !ok || endpoint == nil || len(ctx.route.LBEndpoints) < 2 {
+		return 0, false
+	}
+
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+	default:
+		return 0, false
+	}
+
+	if req.Body != nil && req.Body != http.NoBody {
+		return 0, false
+	}
+
+	return delay, true
+}
+
+// selects the endpoint for the hedged request. When the LB algorithm
+// selects the same endpoint as for the primary request, e.g. in case of
+// consistent hashing, the next endpoint of the route is used.
+func hedgeEndpoint(ctx *context, primary *routing.LBEndpoint) *routing.LBEndpoint {
+	rt := ctx.route
+	e := selectEndpoint(rt, &routing.LBContext{Request: ctx.request, Route: rt, Params: ctx.StateBag()})
+	if e.Host != primary.Host {
+		return &e
+	}
+
+	for i, c := range rt.LBEndpoints {
+		if c.Host == primary.Host {
+			next := rt.LBEndpoints[(i+1)%len(rt.LBEndpoints)]
+			return &next
+		}
+	}
+
+	return nil
+}
+
+// hedgedRoundTrip sends the request, and when it doesn't succeed within
+// the delay, sends a copy of it to another endpoint. The first successful
+// response is returned, and the other request is cancelled. When both of
+// the requests fail, the result of the primary request is returned. When
+// the primary request fails before the delay, its result is returned
+// without hedging, leaving the decision to the regular retry logic.
+func (p *Proxy) hedgedRoundTrip(
+	ctx *context,
+	rt http.RoundTripper,
+	req *http.Request,
+	primary *routing.LBEndpoint,
+	delay time.Duration,
+) (*http.Response, error) {
+	// buffered to let the losing requests finish without a receiver
+	results := make(chan hedgeResult, 2)
+	var cancels []stdlibcontext.CancelFunc
+	send := func(r *http.Request, e *routing.LBEndpoint) {
+		rctx, cancel := stdlibcontext.WithCancel(r.Context())
+		index := len(cancels)
+		cancels = append(cancels, cancel)
+		if e != nil {
+			e.Metrics.IncInflightRequest()
+		}
+
+		go func() {
+			rsp, err := rt.RoundTrip(r.WithContext(rctx))
+			if e != nil {
+				e.Metrics.DecInflightRequest()
+			}
+
+			results <- hedgeResult{rsp: rsp, err: err, index: index, cancel: cancel, endpoint: e}
+		}()
+	}
+
+	send(req, nil)
+	pending := 1
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	timeout := timer.C
+
+	var failedPrimary *hedgeResult
+	for pending > 0 {
+		select {
+		case <-timeout:
+			timeout = nil
+			e := hedgeEndpoint(ctx, primary)
+			if e == nil {
+				continue
+			}
+
+			hreq := req.Clone(req.Context())
+			hreq.URL.Scheme = e.Scheme
+			hreq.URL.Host = e.Host
+			if ctx.proxySpan != nil {
+				ctx.proxySpan.LogKV("hedge", e.Host)
+			}
+
+			p.metrics.IncCounter("hedge.requests")
+			send(hreq, e)
+			pending++
+		case r := <-results:
+			pending--
+			if r.err == nil && r.rsp.StatusCode < http.StatusInternalServerError {
+				for i, cancel := range cancels {
+					if i != r.index {
+						cancel()
+					}
+				}
+
+				go discardHedgeResults(results, pending)
+				if failedPrimary != nil {
+					discardHedgeResult(*failedPrimary, primary)
+				}
+
+				if r.endpoint != nil {
+					p.metrics.IncCounter("hedge.wins")
+				}
+
+				return hedgeResponse(r)
+			}
+
+			if r.endpoint == nil {
+				if timeout != nil {
+					// failed before hedging
+					return hedgeResponse(r)
+				}
+
+				failedPrimary = &r
+				continue
+			}
+
+			discardHedgeResult(r, r.endpoint)
+		}
+	}
+
+	return hedgeResponse(*failedPrimary)
+}
+
+// returns the result of a request, cancelling its context only when
+// its response body is closed
+func hedgeResponse(r hedgeResult) (*http.Response, error) {
+	if r.err != nil {
+		r.cancel()
+		return nil, r.err
+	}
+
+	r.rsp.Body = cancelBody{ReadCloser: r.rsp.Body, cancel: r.cancel}
+	return r.rsp, nil
+}
+
+// discards a failed request, that lost the race
+func discardHedgeResult(r hedgeResult, e *routing.LBEndpoint) {
+	if r.err != nil {
+		e.Metrics.IncFailedRequest()
+	} else {
+		r.rsp.Body.Close()
+	}
+
+	r.cancel()
+}
+
+// discards the cancelled requests, that lost the race
+func discardHedgeResults(results <-chan hedgeResult, pending int) {
+	for i := 0; i < pending; i++ {
+		r := <-results
+		if r.err == nil {
+			r.rsp.Body.Close()
+		}
+	}
+}
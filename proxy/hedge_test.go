@@ -0,0 +1,126 @@
+package proxy
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/zalando/skipper/metrics"
+	"github.com/zalando/skipper/metrics/metricstest"
+)
+
+func TestHedgeRequests(t *testing.T) {
+	var cancelled int32
+	slow := newCountingBackend(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+			atomic.AddInt32(&cancelled, 1)
+		case <-time.After(300 * time.Millisecond):
+			w.Write([]byte("slow"))
+		}
+	})
+	defer slow.Close()
+
+	fast := newCountingBackend(respond("fast", http.StatusOK))
+	defer fast.Close()
+
+	tp, err := newTestProxy(fmt.Sprintf(`* -> hedgeRequests("10ms") -> <roundRobin, "%s", "%s">`, slow.URL, fast.URL), FlagsNone)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer tp.close()
+
+	m := &metricstest.MockMetrics{}
+	tp.proxy.metrics = counterMetrics{Metrics: metrics.Void, mock: m}
+
+	ps := httptest.NewServer(tp.proxy)
+	defer ps.Close()
+
+	for i := 0; i < 4; i++ {
+		start := time.Now()
+		rsp, err := http.Get(ps.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		b, _ := io.ReadAll(rsp.Body)
+		rsp.Body.Close()
+		if rsp.StatusCode != http.StatusOK || string(b) != "fast" {
+			t.Errorf("unexpected response: %d %s", rsp.StatusCode, b)
+		}
+
+		if d := time.Since(start); d >= 300*time.Millisecond {
+			t.Errorf("failed to hedge the request, took: %v", d)
+		}
+	}
+
+	if slow.count() == 0 {
+		t.Fatal("failed to send requests to the slow endpoint")
+	}
+
+	m.WithCounters(func(counters map[string]int64) {
+		if counters["hedge.requests"] != int64(slow.count()) || counters["hedge.wins"] != counters["hedge.requests"] {
+			t.Errorf("unexpected counters, requests to the slow endpoint: %d, counters: %v", slow.count(), counters)
+		}
+	})
+
+	deadline := time.Now().Add(time.Second)
+	for atomic.LoadInt32(&cancelled) != int32(slow.count()) {
+		if time.Now().After(deadline) {
+			t.Fatalf("failed to cancel the slow requests, cancelled: %d, sent: %d", atomic.LoadInt32(&cancelled), slow.count())
+		}
+
+		time.Sleep(time.Millisecond)
+	}
+
+	// requests with a body are not hedged
+	sent := fast.count() + slow.count()
+	for i := 0; i < 2; i++ {
+		rsp, err := http.Post(ps.URL, "text/plain", strings.NewReader("hello"))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rsp.Body.Close()
+	}
+
+	if fast.count()+slow.count() != sent+2 {
+		t.Errorf("unexpected number of backend requests: %d, %d", fast.count(), slow.count())
+	}
+}
+
+func TestHedgeRequestsFailing(t *testing.T) {
+	failing := newCountingBackend(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(20 * time.Millisecond)
+		w.WriteHeader(http.StatusServiceUnavailable)
+		w.Write([]byte("failing"))
+	})
+	defer failing.Close()
+
+	tp, err := newTestProxy(fmt.Sprintf(`* -> hedgeRequests("1ms") -> <roundRobin, "%s", "%s">`, failing.URL, failing.URL+"/"), FlagsNone)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer tp.close()
+
+	ps := httptest.NewServer(tp.proxy)
+	defer ps.Close()
+
+	rsp, err := http.Get(ps.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b, _ := io.ReadAll(rsp.Body)
+	rsp.Body.Close()
+	if rsp.StatusCode != http.StatusServiceUnavailable || string(b) != "failing" {
+		t.Errorf("unexpected response: %d %s", rsp.StatusCode, b)
+	}
+}
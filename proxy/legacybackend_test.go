@@ -0,0 +1,118 @@
+package proxy
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/textproto"
+	"strings"
+	"testing"
+)
+
+// accepts a single connection, and returns the raw request head and body
+func rawBackend(t *testing.T) (string, <-chan []string) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	received := make(chan []string, 1)
+	go func() {
+		defer l.Close()
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+
+		defer conn.Close()
+		r := textproto.NewReader(bufio.NewReader(conn))
+		var lines []string
+		for {
+			line, err := r.ReadLine()
+			if err != nil || line == "" {
+				break
+			}
+
+			lines = append(lines, line)
+		}
+
+		body := ""
+		for _, line := range lines {
+			if strings.HasPrefix(line, "Content-Length: ") {
+				var n int
+				fmt.Sscanf(line, "Content-Length: %d", &n)
+				b := make([]byte, n)
+				io.ReadFull(r.R, b)
+				body = string(b)
+			}
+		}
+
+		received <- append(lines, "body:"+body)
+		conn.Write([]byte("HTTP/1.0 200 OK\r\nContent-Length: 2\r\n\r\nok"))
+	}()
+
+	return "http://" + l.Addr().String(), received
+}
+
+func TestLegacyBackendRequest(t *testing.T) {
+	backend, received := rawBackend(t)
+	tp, err := newTestProxy(fmt.Sprintf(`* -> backendHeaderCasing("X-API-KEY") -> legacyBackend() -> "%s"`, backend), FlagsNone)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer tp.close()
+
+	ps := httptest.NewServer(tp.proxy)
+	defer ps.Close()
+
+	pr, pw := io.Pipe()
+	go func() {
+		pw.Write([]byte("foo"))
+		pw.Write([]byte("bar"))
+		pw.Close()
+	}()
+
+	req, err := http.NewRequest("POST", ps.URL, pr)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req.Header.Set("X-Api-Key", "secret")
+	req.Header.Set("Expect", "100-continue")
+	rsp, err := ps.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rsp.Body.Close()
+	if rsp.StatusCode != http.StatusOK {
+		t.Fatalf("unexpected status: %d", rsp.StatusCode)
+	}
+
+	lines := <-received
+	has := func(line string) bool {
+		for _, l := range lines {
+			if l == line {
+				return true
+			}
+		}
+
+		return false
+	}
+
+	for _, line := range []string{"X-API-KEY: secret", "Content-Length: 6", "Connection: close", "body:foobar"} {
+		if !has(line) {
+			t.Errorf("missing %q in the backend request: %v", line, lines)
+		}
+	}
+
+	for _, l := range lines {
+		if strings.HasPrefix(l, "Transfer-Encoding:") || strings.HasPrefix(l, "Expect:") || strings.HasPrefix(l, "X-Api-Key:") {
+			t.Errorf("unexpected header in the backend request: %q", l)
+		}
+	}
+}
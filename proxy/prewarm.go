@@ -0,0 +1,338 @@
+package proxy
+
+import (
+	stdlibcontext "context"
+	"crypto/tls"
+	"net"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/zalando/skipper/eskip"
+	"github.com/zalando/skipper/filters"
+	"github.com/zalando/skipper/routing"
+)
+
+// DefaultPrewarmMaxIdle is used when PrewarmerOptions.MaxIdle is not set.
+const DefaultPrewarmMaxIdle = 30 * time.Second
+
+// PrewarmCounter is implemented by the filters that set the number of
+// the connections to be established to each endpoint of their route,
+// see the prewarmConnections filter.
+type PrewarmCounter interface {
+	PrewarmConnections() int
+}
+
+// PrewarmerOptions for the connection prewarming.
+type PrewarmerOptions struct {
+
+	// MaxIdle sets how long the prewarmed connections are kept, when
+	// they are not used. Defaults to DefaultPrewarmMaxIdle.
+	MaxIdle time.Duration
+}
+
+type prewarmTarget struct {
+	tls  bool
+	addr string
+}
+
+type prewarmedConn struct {
+	net.Conn
+	created time.Time
+}
+
+type dialFunc func(ctx stdlibcontext.Context, network, addr string) (net.Conn, error)
+
+// Prewarmer resolves the backend hosts and establishes the connections,
+// including the TLS handshake, to the endpoints of the routes with the
+// prewarmConnections filter, right after the routes are loaded, and
+// when their endpoints change. This way the first requests after a
+// deployment don't need to wait for dialing.
+//
+// The Prewarmer needs to be set both as a routing post-processor and
+// in the proxy Params.
+type Prewarmer struct {
+	options          PrewarmerOptions
+	mu               sync.Mutex
+	dial             dialFunc
+	tlsConfig        *tls.Config
+	handshakeTimeout time.Duration
+	targets          map[prewarmTarget]int
+	pool             map[prewarmTarget][]*prewarmedConn
+	closed           bool
+}
+
+// NewPrewarmer creates a connection prewarmer.
+func NewPrewarmer(o PrewarmerOptions) *Prewarmer {
+	if o.MaxIdle <= 0 {
+		o.MaxIdle = DefaultPrewarmMaxIdle
+	}
+
+	return &Prewarmer{
+		options: o,
+		targets: make(map[prewarmTarget]int),
+		pool:    make(map[prewarmTarget][]*prewarmedConn),
+	}
+}
+
+func prewarmCount(r *routing.Route) int {
+	var n int
+	for _, f := range r.Filters {
+		if pc, ok := f.Filter.(PrewarmCounter); ok && pc.PrewarmConnections() > n {
+			n = pc.PrewarmConnections()
+		}
+	}
+
+	return n
+}
+
+func newPrewarmTarget(scheme, host string) (prewarmTarget, bool) {
+	var port string
+	switch scheme {
+	case "http":
+		port = "80"
+	case "https":
+		port = "443"
+	default:
+		return prewarmTarget{}, false
+	}
+
+	if _, _, err := net.SplitHostPort(host); err != nil {
+		host = net.JoinHostPort(host, port)
+	}
+
+	return prewarmTarget{tls: scheme == "https", addr: host}, true
+}
+
+// InspectedFilters implements routing.FilterInspector.
+func (*Prewarmer) InspectedFilters() []string {
+	return []string{filters.PrewarmConnectionsName}
+}
+
+// Do implements routing.PostProcessor. It collects the endpoints of the
+// routes with the prewarmConnections filter, and establishes the
+// connections to the new ones.
+func (p *Prewarmer) Do(routes []*routing.Route) []*routing.Route {
+	targets := make(map[prewarmTarget]int)
+	add := func(scheme, host string, n int) {
+		if t, ok := newPrewarmTarget(scheme, host); ok && n > targets[t] {
+			targets[t] = n
+		}
+	}
+
+	for _, r := range routes {
+		n := prewarmCount(r)
+		if n <= 0 {
+			continue
+		}
+
+		switch r.BackendType {
+		case eskip.NetworkBackend:
+			add(r.Scheme, r.Host, n)
+		case eskip.LBBackend:
+			for _, e := range r.LBEndpoints {
+				add(e.Scheme, e.Host, n)
+			}
+		}
+	}
+
+	p.mu.Lock()
+	previous := p.targets
+	p.targets = targets
+	for t, conns := range p.pool {
+		if _, ok := targets[t]; !ok {
+			closeConns(conns)
+			delete(p.pool, t)
+		}
+	}
+
+	attached := p.dial != nil
+	p.mu.Unlock()
+
+	if attached {
+		for t, n := range targets {
+			if n > previous[t] {
+				go p.warm(t, n)
+			}
+		}
+	}
+
+	return routes
+}
+
+// attach is called by the proxy, and it starts warming the targets of
+// the routes that were loaded before the proxy was created.
+func (p *Prewarmer) attach(dial dialFunc, tlsConfig *tls.Config, handshakeTimeout time.Duration) {
+	p.mu.Lock()
+	p.dial = dial
+	p.tlsConfig = tlsConfig
+	p.handshakeTimeout = handshakeTimeout
+	targets := make(map[prewarmTarget]int, len(p.targets))
+	for t, n := range p.targets {
+		targets[t] = n
+	}
+
+	p.mu.Unlock()
+	for t, n := range targets {
+		go p.warm(t, n)
+	}
+}
+
+func (p *Prewarmer) handshake(ctx stdlibcontext.Context, conn net.Conn, addr string) (net.Conn, error) {
+	var cfg *tls.Config
+	if p.tlsConfig != nil {
+		cfg = p.tlsConfig.Clone()
+	} else {
+		cfg = &tls.Config{}
+	}
+
+	if cfg.ServerName == "" {
+		host, _, err := net.SplitHostPort(addr)
+		if err != nil {
+			host = addr
+		}
+
+		cfg.ServerName = host
+	}
+
+	if p.handshakeTimeout > 0 {
+		var cancel stdlibcontext.CancelFunc
+		ctx, cancel = stdlibcontext.WithTimeout(ctx, p.handshakeTimeout)
+		defer cancel()
+	}
+
+	tc := tls.Client(conn, cfg)
+	if err := tc.HandshakeContext(ctx); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	return tc, nil
+}
+
+func (p *Prewarmer) connect(t prewarmTarget) (net.Conn, error) {
+	ctx := stdlibcontext.Background()
+	conn, err := p.dial(ctx, "tcp", t.addr)
+	if err != nil || !t.tls {
+		return conn, err
+	}
+
+	return p.handshake(ctx, conn, t.addr)
+}
+
+func (p *Prewarmer) warm(t prewarmTarget, n int) {
+	p.mu.Lock()
+	missing := n - len(p.pool[t])
+	p.mu.Unlock()
+
+	var wg sync.WaitGroup
+	for i := 0; i < missing; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			conn, err := p.connect(t)
+			if err != nil {
+				log.Errorf("Failed to prewarm connection to %s: %v.", t.addr, err)
+				return
+			}
+
+			p.mu.Lock()
+			defer p.mu.Unlock()
+
+			// the target may have been removed or the connections may
+			// have been added by a concurrent warm up in the meantime
+			if p.closed || len(p.pool[t]) >= p.targets[t] {
+				conn.Close()
+				return
+			}
+
+			p.pool[t] = append(p.pool[t], &prewarmedConn{Conn: conn, created: time.Now()})
+		}()
+	}
+
+	wg.Wait()
+}
+
+// alive checks that the peer hasn't closed the idle connection in the
+// meantime. It can consume only TLS records not carrying application
+// data, e.g. session tickets.
+func alive(conn net.Conn) bool {
+	if err := conn.SetReadDeadline(time.Now().Add(time.Millisecond)); err != nil {
+		return false
+	}
+
+	var b [1]byte
+	_, err := conn.Read(b[:])
+	if nerr, ok := err.(net.Error); !ok || !nerr.Timeout() {
+		return false
+	}
+
+	return conn.SetReadDeadline(time.Time{}) == nil
+}
+
+// take returns a prewarmed connection to the target, or nil.
+func (p *Prewarmer) take(t prewarmTarget) net.Conn {
+	for {
+		p.mu.Lock()
+		conns := p.pool[t]
+		if len(conns) == 0 {
+			p.mu.Unlock()
+			return nil
+		}
+
+		c := conns[len(conns)-1]
+		p.pool[t] = conns[:len(conns)-1]
+		p.mu.Unlock()
+
+		if time.Since(c.created) < p.options.MaxIdle && alive(c.Conn) {
+			return c.Conn
+		}
+
+		c.Close()
+	}
+}
+
+func (p *Prewarmer) dialContext(dial dialFunc) dialFunc {
+	return func(ctx stdlibcontext.Context, network, addr string) (net.Conn, error) {
+		if conn := p.take(prewarmTarget{addr: addr}); conn != nil {
+			return conn, nil
+		}
+
+		return dial(ctx, network, addr)
+	}
+}
+
+// dialTLSContext is used by the transport instead of its own TLS
+// handshake, so that the prewarmed TLS connections can be used.
+func (p *Prewarmer) dialTLSContext(dial dialFunc) dialFunc {
+	return func(ctx stdlibcontext.Context, network, addr string) (net.Conn, error) {
+		if conn := p.take(prewarmTarget{tls: true, addr: addr}); conn != nil {
+			return conn, nil
+		}
+
+		conn, err := dial(ctx, network, addr)
+		if err != nil {
+			return nil, err
+		}
+
+		return p.handshake(ctx, conn, addr)
+	}
+}
+
+func closeConns(conns []*prewarmedConn) {
+	for _, c := range conns {
+		c.Close()
+	}
+}
+
+// Close closes the prewarmed connections that were not used.
+func (p *Prewarmer) Close() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.closed = true
+	for t, conns := range p.pool {
+		closeConns(conns)
+		delete(p.pool, t)
+	}
+}
@@ -0,0 +1,218 @@
+package proxy
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/zalando/skipper/eskip"
+	"github.com/zalando/skipper/filters"
+	"github.com/zalando/skipper/filters/builtin"
+	"github.com/zalando/skipper/loadbalancer"
+	"github.com/zalando/skipper/logging/loggingtest"
+	"github.com/zalando/skipper/routing"
+	"github.com/zalando/skipper/routing/testdataclient"
+)
+
+type connCountingBackend struct {
+	*httptest.Server
+	conns int32
+}
+
+func newConnCountingBackend(tls bool) *connCountingBackend {
+	b := &connCountingBackend{}
+	b.Server = httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+
+	b.Config.ConnState = func(_ net.Conn, s http.ConnState) {
+		if s == http.StateNew {
+			atomic.AddInt32(&b.conns, 1)
+		}
+	}
+
+	if tls {
+		b.StartTLS()
+	} else {
+		b.Start()
+	}
+
+	return b
+}
+
+func (b *connCountingBackend) count() int {
+	return int(atomic.LoadInt32(&b.conns))
+}
+
+func (p *Prewarmer) pooled(scheme, host string) int {
+	t, _ := newPrewarmTarget(scheme, host)
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.pool[t])
+}
+
+func waitForPooled(t *testing.T, p *Prewarmer, u string, n int) {
+	t.Helper()
+	s, h := urlSchemeHost(t, u)
+	for i := 0; i < 100; i++ {
+		if p.pooled(s, h) == n {
+			return
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	t.Fatalf("timeout waiting for %d prewarmed connections to %s, got: %d", n, u, p.pooled(s, h))
+}
+
+func urlSchemeHost(t *testing.T, u string) (string, string) {
+	r, err := http.NewRequest("GET", u, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return r.URL.Scheme, r.URL.Host
+}
+
+func TestPrewarmConnections(t *testing.T) {
+	plain1 := newConnCountingBackend(false)
+	defer plain1.Close()
+
+	plain2 := newConnCountingBackend(false)
+	defer plain2.Close()
+
+	secure := newConnCountingBackend(true)
+	defer secure.Close()
+
+	cold := newConnCountingBackend(false)
+	defer cold.Close()
+
+	dc, err := testdataclient.NewDoc(fmt.Sprintf(`
+		lb: Path("/lb") -> prewarmConnections(3) -> <roundRobin, "%s", "%s">;
+		secure: Path("/secure") -> prewarmConnections(2) -> "%s";
+		cold: Path("/cold") -> "%s";
+	`, plain1.URL, plain2.URL, secure.URL, cold.URL))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	prewarmer := NewPrewarmer(PrewarmerOptions{})
+	defer prewarmer.Close()
+
+	tl := loggingtest.New()
+	defer tl.Close()
+
+	rt := routing.New(routing.Options{
+		FilterRegistry: builtin.MakeRegistry(),
+		DataClients:    []routing.DataClient{dc},
+		PollTimeout:    sourcePollTimeout,
+		PostProcessors: []routing.PostProcessor{loadbalancer.NewAlgorithmProvider(), prewarmer},
+		Log:            tl,
+	})
+	defer rt.Close()
+
+	p := WithParams(Params{Routing: rt, Flags: Insecure, Prewarmer: prewarmer})
+	defer p.Close()
+
+	if err := tl.WaitFor("route settings applied", time.Second); err != nil {
+		t.Fatal(err)
+	}
+
+	waitForPooled(t, prewarmer, plain1.URL, 3)
+	waitForPooled(t, prewarmer, plain2.URL, 3)
+	waitForPooled(t, prewarmer, secure.URL, 2)
+	if prewarmer.pooled(urlSchemeHost(t, cold.URL)) != 0 {
+		t.Error("unexpected prewarmed connections")
+	}
+
+	counts := func() []int {
+		return []int{plain1.count(), plain2.count(), secure.count(), cold.count()}
+	}
+
+	if c := counts(); fmt.Sprint(c) != "[3 3 2 0]" {
+		t.Fatalf("unexpected number of connections: %v", c)
+	}
+
+	ps := httptest.NewServer(p)
+	defer ps.Close()
+
+	for _, path := range []string{"/lb", "/lb", "/secure", "/cold"} {
+		rsp, err := http.Get(ps.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		b, _ := io.ReadAll(rsp.Body)
+		rsp.Body.Close()
+		if rsp.StatusCode != http.StatusOK || string(b) != "ok" {
+			t.Fatalf("unexpected response: %d, %s", rsp.StatusCode, b)
+		}
+	}
+
+	// the requests used the prewarmed connections
+	if c := counts(); fmt.Sprint(c) != "[3 3 2 1]" {
+		t.Errorf("unexpected number of connections: %v", c)
+	}
+
+	if prewarmer.pooled(urlSchemeHost(t, plain1.URL)) != 2 || prewarmer.pooled(urlSchemeHost(t, secure.URL)) != 1 {
+		t.Error("failed to take the prewarmed connections")
+	}
+
+	// new endpoint
+	if err := dc.UpdateDoc(fmt.Sprintf(`lb: Path("/lb") -> prewarmConnections(3) -> <roundRobin, "%s", "%s", "%s">;`, plain1.URL, plain2.URL, cold.URL), nil); err != nil {
+		t.Fatal(err)
+	}
+
+	waitForPooled(t, prewarmer, cold.URL, 3)
+	if c := counts(); fmt.Sprint(c) != "[3 3 2 4]" {
+		t.Errorf("unexpected number of connections: %v", c)
+	}
+
+	// removed route
+	tl.Reset()
+	if err := dc.UpdateDoc("", []string{"lb"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := tl.WaitFor("route settings applied", time.Second); err != nil {
+		t.Fatal(err)
+	}
+
+	if prewarmer.pooled(urlSchemeHost(t, cold.URL)) != 0 {
+		t.Error("failed to close the prewarmed connections")
+	}
+}
+
+func TestPrewarmedConnectionExpiry(t *testing.T) {
+	b := newConnCountingBackend(false)
+	defer b.Close()
+
+	prewarmer := NewPrewarmer(PrewarmerOptions{MaxIdle: time.Millisecond})
+	defer prewarmer.Close()
+
+	prewarmer.Do([]*routing.Route{{
+		Route:   eskip.Route{BackendType: eskip.NetworkBackend},
+		Scheme:  "http",
+		Host:    b.Listener.Addr().String(),
+		Filters: []*routing.RouteFilter{{Filter: prewarmFilter(1)}},
+	}})
+
+	prewarmer.attach((&net.Dialer{}).DialContext, nil, 0)
+	waitForPooled(t, prewarmer, b.URL, 1)
+
+	time.Sleep(2 * time.Millisecond)
+	if conn := prewarmer.take(prewarmTarget{addr: b.Listener.Addr().String()}); conn != nil {
+		t.Error("unexpected expired connection")
+	}
+}
+
+type prewarmFilter int
+
+func (f prewarmFilter) PrewarmConnections() int      { return int(f) }
+func (prewarmFilter) Request(filters.FilterContext)  {}
+func (prewarmFilter) Response(filters.FilterContext) {}
@@ -8,6 +8,7 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"math/rand"
 	"net"
 	"net/http"
 	"net/http/httptrace"
@@ -21,17 +22,25 @@ import (
 
 	ot "github.com/opentracing/opentracing-go"
 	"github.com/opentracing/opentracing-go/ext"
+	"github.com/zalando/skipper/accounting"
 	"github.com/zalando/skipper/circuit"
 	"github.com/zalando/skipper/eskip"
 	"github.com/zalando/skipper/filters"
 	al "github.com/zalando/skipper/filters/accesslog"
+	"github.com/zalando/skipper/filters/annotate"
 	circuitfilters "github.com/zalando/skipper/filters/circuit"
 	flowidFilter "github.com/zalando/skipper/filters/flowid"
+	"github.com/zalando/skipper/filters/headerpolicy"
 	ratelimitfilters "github.com/zalando/skipper/filters/ratelimit"
+	"github.com/zalando/skipper/filters/retry"
 	tracingfilter "github.com/zalando/skipper/filters/tracing"
+	"github.com/zalando/skipper/filters/websocket"
 	"github.com/zalando/skipper/loadbalancer"
 	"github.com/zalando/skipper/logging"
 	"github.com/zalando/skipper/metrics"
+	snet "github.com/zalando/skipper/net"
+	"github.com/zalando/skipper/net/dnscache"
+	"github.com/zalando/skipper/net/happyeyeballs"
 	"github.com/zalando/skipper/proxy/fastcgi"
 	"github.com/zalando/skipper/ratelimit"
 	"github.com/zalando/skipper/rfc"
@@ -42,6 +51,7 @@ import (
 
 const (
 	proxyBufferSize         = 8192
+	passthroughBufferSize   = 64 * 1024
 	unknownRouteID          = "_unknownroute_"
 	unknownRouteBackendType = "<unknown>"
 	unknownRouteBackend     = "<unknown>"
@@ -166,6 +176,21 @@ type Params struct {
 	// DualStack sets if the proxy TCP connections to the backend should be dual stack
 	DualStack bool
 
+	// HappyEyeballs enables connecting to the backends resolved to both
+	// IPv4 and IPv6 addresses with the Happy Eyeballs algorithm of RFC
+	// 8305, starting with the preferred address family, and falling back
+	// to the other one after the DialFallbackDelay.
+	HappyEyeballs bool
+
+	// PreferIPv4 makes the Happy Eyeballs dialing try the IPv4 addresses
+	// first. By default, the IPv6 addresses are tried first.
+	PreferIPv4 bool
+
+	// DialFallbackDelay is the time waited for a connection attempt of
+	// the Happy Eyeballs dialing, before starting the next one. Defaults
+	// to happyeyeballs.DefaultFallbackDelay.
+	DialFallbackDelay time.Duration
+
 	// DefaultHTTPStatus is the HTTP status used when no routes are found
 	// for a request.
 	DefaultHTTPStatus int
@@ -176,6 +201,19 @@ type Params struct {
 	// wrong routing depending on the current configuration.
 	MaxLoopbacks int
 
+	// RetryBudget limits the retries of the routes with a retry policy
+	// to a ratio of their requests, across all the routes, to prevent
+	// retry storms. If 0, the default (0.2) is applied. To disable the
+	// budget, set it to -1.
+	RetryBudget float64
+
+	// RetryAfterMaxBackoff enables backing off the load balanced
+	// endpoints that respond with 429 or 503 and a Retry-After header.
+	// The proxy avoids these endpoints, like the unhealthy ones, for the
+	// time requested by the header, at most for RetryAfterMaxBackoff.
+	// Zero disables the backoff.
+	RetryAfterMaxBackoff time.Duration
+
 	// Same as net/http.Transport.MaxIdleConnsPerHost, but the default
 	// is 64. This value supports scenarios with relatively few remote
 	// hosts. When the routing table contains different hosts in the
@@ -186,6 +224,26 @@ type Params struct {
 	// MaxIdleConns limits the number of idle connections to all backends, 0 means no limit
 	MaxIdleConns int
 
+	// MaxConnsPerHost limits the number of connections to each backend
+	// host, including the connections in use. When the limit is reached,
+	// the backend requests wait for a free connection. 0 means no limit.
+	// It can be overridden per route with the backendTransport filter.
+	MaxConnsPerHost int
+
+	// EnableBackendConnectionMetrics enables reporting the number of
+	// the open, in-use and idle connections, and the dial failures, for
+	// each backend host.
+	EnableBackendConnectionMetrics bool
+
+	// Accounting, when set, receives the usage of the requests, whose
+	// tenant was set by the tenantKey filter.
+	Accounting *accounting.Registry
+
+	// SSEKeepAliveInterval, when set, makes the proxy send a comment to
+	// the clients of the Server-Sent Events responses, when the backend
+	// didn't send an event for the interval.
+	SSEKeepAliveInterval time.Duration
+
 	// DisableHTTPKeepalives forces backend to always create a new connection
 	DisableHTTPKeepalives bool
 
@@ -240,6 +298,98 @@ type Params struct {
 	// It allows to add additional logic (for example tracing) by providing a wrapper function
 	// which accepts original skipper http.RoundTripper as an argument and returns a wrapped roundtripper
 	CustomHttpRoundTripperWrap func(http.RoundTripper) http.RoundTripper
+
+	// FastCgiMaxIdleConns sets the maximum number of idle connections
+	// kept per FastCGI backend, e.g. PHP-FPM, and reused by the
+	// subsequent requests. When 0, every FastCGI request uses a new
+	// connection.
+	FastCgiMaxIdleConns int
+
+	// FastCgiIdleConnTimeout sets the time after the idle FastCGI
+	// connections are closed instead of reused. When 0, the idle
+	// connections don't expire.
+	FastCgiIdleConnTimeout time.Duration
+
+	// DNSCacheTTL sets how long the resolved addresses of the backend
+	// hosts are cached. When 0, the addresses are not cached, except
+	// for the routes with a resolver override.
+	DNSCacheTTL time.Duration
+
+	// DNSStaleTTL sets how long the cached addresses of the backend
+	// hosts are used after DNSCacheTTL, when the resolution fails.
+	// Defaults to dnscache.DefaultStaleTTL.
+	DNSStaleTTL time.Duration
+
+	// DNSServers sets the addresses of the DNS servers resolving the
+	// backend hosts instead of the ones of the host, in the host:port
+	// format. The servers are tried in order.
+	DNSServers []string
+
+	// DNSRespectTTL makes the DNS cache keep the addresses for the TTL
+	// of their DNS records instead of DNSCacheTTL, when the resolver
+	// reports it, see dnscache.TTLResolver.
+	DNSRespectTTL bool
+
+	// DNSResolver, when set, resolves the backend hosts instead of the
+	// DNS servers of the host or DNSServers.
+	DNSResolver dnscache.Resolver
+
+	// Prewarmer, when set, establishes the backend connections of the
+	// routes with the prewarmConnections filter in advance. It needs
+	// to be set as a routing post-processor, too.
+	Prewarmer *Prewarmer
+
+	// EnableRequestAnomalyMetrics enables counting the malformed or
+	// abnormal requests, e.g. with invalid encoding, oversized headers,
+	// bad content length or an unsupported method. The counters are
+	// named as anomaly.<reason> and anomaly.<reason>.route.<route ID>.
+	EnableRequestAnomalyMetrics bool
+
+	// RequestAnomalyMaxHeaderSize sets the size of the request headers
+	// in bytes, above which the request is counted as an anomaly with
+	// oversized headers. Defaults to 8192.
+	RequestAnomalyMaxHeaderSize int
+
+	// MaxRequestBodySize sets the max size of the request bodies in
+	// bytes forwarded to the backends. The longer requests are rejected
+	// with 413 Request Entity Too Large, and the reading of the body is
+	// aborted. The routes can override it with the maxRequestBodySize
+	// filter. When 0, the size of the request bodies is not limited.
+	MaxRequestBodySize int64
+
+	// ForwardEarlyHints enables forwarding the 103 Early Hints responses
+	// of the backends to the clients.
+	ForwardEarlyHints bool
+
+	// BackendHeaderPolicy defines the sanitation of the backend request
+	// headers: the stripped headers, the max header size, and the
+	// folding of the repeated fields. The routes can override it with
+	// the backendHeaderPolicy filter.
+	BackendHeaderPolicy headerpolicy.Policy
+
+	// ClientDisconnectGracePeriod defines how the backend requests are
+	// handled, when the client disconnects. When 0, the backend request
+	// is canceled immediately. When positive, it is canceled when it
+	// doesn't finish within the grace period after the disconnect. When
+	// negative, it is not canceled. The routes can override it with the
+	// clientDisconnect filter.
+	ClientDisconnectGracePeriod time.Duration
+
+	// DetectStreaming enables treating the requests accepting Server-Sent
+	// Events, based on the Accept header, as streaming requests, in
+	// addition to the routes marked with the streaming filter.
+	DetectStreaming bool
+
+	// StreamingResponseHeaderTimeout replaces the response header timeout
+	// of the backend transport for the streaming requests. When 0, the
+	// streaming requests wait for the response headers without a timeout.
+	StreamingResponseHeaderTimeout time.Duration
+
+	// StreamingIdleTimeout limits the time of waiting for the next data
+	// from the backend in the response body of the streaming requests.
+	// It replaces the backend timeout for these requests. When 0, the
+	// streaming responses are not limited by a timeout.
+	StreamingIdleTimeout time.Duration
 }
 
 type (
@@ -313,28 +463,49 @@ type PriorityRoute interface {
 // Proxy instances implement Skipper proxying functionality. For
 // initializing, see the WithParams the constructor and Params.
 type Proxy struct {
-	experimentalUpgrade      bool
-	experimentalUpgradeAudit bool
-	accessLogDisabled        bool
-	maxLoops                 int
-	defaultHTTPStatus        int
-	routing                  *routing.Routing
-	roundTripper             http.RoundTripper
-	priorityRoutes           []PriorityRoute
-	flags                    Flags
-	metrics                  metrics.Metrics
-	quit                     chan struct{}
-	flushInterval            time.Duration
-	breakers                 *circuit.Registry
-	limiters                 *ratelimit.Registry
-	log                      logging.Logger
-	tracing                  *proxyTracing
-	lb                       *loadbalancer.LB
-	upgradeAuditLogOut       io.Writer
-	upgradeAuditLogErr       io.Writer
-	auditLogHook             chan struct{}
-	clientTLS                *tls.Config
-	hostname                 string
+	experimentalUpgrade       bool
+	experimentalUpgradeAudit  bool
+	accessLogDisabled         bool
+	maxLoops                  int
+	retryBudget               *retryBudget
+	retryAfterMaxBackoff      time.Duration
+	defaultHTTPStatus         int
+	routing                   *routing.Routing
+	roundTripper              http.RoundTripper
+	grpcRoundTripper          http.RoundTripper
+	proxyProtocolRoundTripper http.RoundTripper
+	unixRoundTripper          http.RoundTripper
+	zeroCopyRoundTripper      http.RoundTripper
+	routeTransports           *routeTransports
+	priorityRoutes            []PriorityRoute
+	flags                     Flags
+	metrics                   metrics.Metrics
+	quit                      chan struct{}
+	flushInterval             time.Duration
+	breakers                  *circuit.Registry
+	limiters                  *ratelimit.Registry
+	log                       logging.Logger
+	tracing                   *proxyTracing
+	lb                        *loadbalancer.LB
+	upgradeAuditLogOut        io.Writer
+	upgradeAuditLogErr        io.Writer
+	auditLogHook              chan struct{}
+	clientTLS                 *tls.Config
+	hostname                  string
+	fastCgiPool               *fastcgi.ClientPool
+	anomalyMetrics            bool
+	anomalyMaxHeaderSize      int
+	maxRequestBodySize        int64
+	forwardEarlyHints         bool
+	headerPolicy              headerpolicy.Policy
+	disconnectGrace           time.Duration
+	detectStreaming           bool
+	streamingHeaderTimeout    time.Duration
+	streamingIdleTimeout      time.Duration
+	connPool                  *connPool
+	sseKeepAlive              time.Duration
+	sseStreams                int64
+	accounting                *accounting.Registry
 }
 
 // proxyError is used to wrap errors during proxying and to indicate
@@ -391,13 +562,13 @@ func copyHeaderExcluding(to, from http.Header, excludeHeaders map[string]bool) {
 }
 
 func cloneHeader(h http.Header) http.Header {
-	hh := make(http.Header)
+	hh := make(http.Header, len(h))
 	copyHeader(hh, h)
 	return hh
 }
 
 func cloneHeaderExcluding(h http.Header, excludeList map[string]bool) http.Header {
-	hh := make(http.Header)
+	hh := make(http.Header, len(h))
 	copyHeaderExcluding(hh, h, excludeList)
 	return hh
 }
@@ -415,9 +586,33 @@ func (f *flusher) Write(p []byte) (n int, err error) {
 }
 
 func copyStream(to flushedResponseWriter, from io.Reader) (int64, error) {
-	b := make([]byte, proxyBufferSize)
+	b := streamBuffers.get()
+	defer streamBuffers.put(b)
 
-	return io.CopyBuffer(&flusher{to}, from, b)
+	return io.CopyBuffer(&flusher{to}, from, *b)
+}
+
+type writerOnly struct {
+	io.Writer
+}
+
+// copyPassthrough copies a backend response body, that was not touched by
+// any filter, with minimal copying and without flushing after every write.
+// When the response writer supports it, the copying is delegated to its
+// io.ReaderFrom implementation.
+func copyPassthrough(to io.Writer, from io.Reader) (int64, error) {
+	if zb, ok := from.(*zeroCopyBody); ok {
+		return zb.writeTo(to)
+	}
+
+	if rf, ok := to.(io.ReaderFrom); ok {
+		return rf.ReadFrom(from)
+	}
+
+	b := passthroughBuffers.get()
+	defer passthroughBuffers.put(b)
+
+	return io.CopyBuffer(writerOnly{to}, from, *b)
 }
 
 func schemeFromRequest(r *http.Request) string {
@@ -457,8 +652,38 @@ func setRequestURLForDynamicBackend(u *url.URL, stateBag map[string]interface{})
 	}
 }
 
-func setRequestURLForLoadBalancedBackend(u *url.URL, rt *routing.Route, lbctx *routing.LBContext) *routing.LBEndpoint {
+// selectEndpoint applies the LB algorithm, and when it selects an endpoint
+// that was marked unhealthy or drained, or that is backed off after a
+// Retry-After response, it selects randomly one of the
+// endpoints in the best available state instead.
+func selectEndpoint(rt *routing.Route, lbctx *routing.LBContext) routing.LBEndpoint {
 	e := rt.LBAlgorithm.Apply(lbctx)
+	now := time.Now()
+	best := e.Metrics.EffectiveState(now)
+	if best == routing.EndpointHealthy {
+		return e
+	}
+
+	var n int
+	for _, c := range rt.LBEndpoints {
+		s := c.Metrics.EffectiveState(now)
+		switch {
+		case s < best:
+			best, n, e = s, 1, c
+		case s == best:
+			n++
+			// #nosec
+			if rand.Intn(n) == 0 {
+				e = c
+			}
+		}
+	}
+
+	return e
+}
+
+func setRequestURLForLoadBalancedBackend(u *url.URL, rt *routing.Route, lbctx *routing.LBContext) *routing.LBEndpoint {
+	e := selectEndpoint(rt, lbctx)
 	u.Scheme = e.Scheme
 	u.Host = e.Host
 	return &e
@@ -485,19 +710,33 @@ func mapRequest(ctx *context, requestContext stdlibcontext.Context, removeHopHea
 		u.Host = rt.Host
 	}
 
+	if ctx.retryBackend != nil {
+		u.Scheme = ctx.retryBackend.Scheme
+		u.Host = ctx.retryBackend.Host
+		endpoint = nil
+	}
+
 	body := r.Body
 	if r.ContentLength == 0 {
 		body = nil
 	}
 
-	rr, err := http.NewRequestWithContext(requestContext, r.Method, u.String(), body)
+	rr, err := newBackendRequest(requestContext, r.Method, u, body)
 	if err != nil {
 		return nil, endpoint, err
 	}
 
 	rr.ContentLength = r.ContentLength
+	rr.Trailer = r.Trailer
 	if removeHopHeaders {
 		rr.Header = cloneHeaderExcluding(r.Header, hopHeaders)
+
+		// the TE header is hop-by-hop, but the proxy forwards the
+		// response trailers, so it can accept them from the backend,
+		// e.g. as required by gRPC
+		if acceptsTrailers(r.Header) {
+			rr.Header.Set("Te", "trailers")
+		}
 	} else {
 		rr.Header = cloneHeader(r.Header)
 	}
@@ -519,6 +758,18 @@ func mapRequest(ctx *context, requestContext stdlibcontext.Context, removeHopHea
 		rr = rr.WithContext(ot.ContextWithSpan(rr.Context(), ctxspan))
 	}
 
+	if casing, ok := stateBag[filters.BackendHeaderCasingKey].(map[string]string); ok {
+		applyHeaderCasing(rr.Header, casing)
+	}
+
+	if _, ok := stateBag[filters.BackendCloseConnectionKey]; ok {
+		rr.Close = true
+	}
+
+	if server, ok := stateBag[filters.BackendResolverKey].(string); ok {
+		rr = rr.WithContext(dnscache.WithResolver(rr.Context(), server))
+	}
+
 	if _, ok := stateBag[filters.BackendIsProxyKey]; ok {
 		rr = forwardToProxy(r, rr)
 	}
@@ -526,6 +777,17 @@ func mapRequest(ctx *context, requestContext stdlibcontext.Context, removeHopHea
 	return rr, endpoint, nil
 }
 
+// The http package writes the header names as they are stored in the
+// header map, so renaming the keys changes the casing on the wire.
+func applyHeaderCasing(h http.Header, casing map[string]string) {
+	for canonical, name := range casing {
+		if v, ok := h[canonical]; ok && canonical != name {
+			delete(h, canonical)
+			h[name] = v
+		}
+	}
+}
+
 type proxyUrlContextKey struct{}
 
 func forwardToProxy(incoming, outgoing *http.Request) *http.Request {
@@ -625,22 +887,99 @@ func WithParams(p Params) *Proxy {
 		}
 	}
 
+	m := metrics.Default
+	if p.Flags.Debug() {
+		m = metrics.Void
+	}
+
+	dialer := newSkipperDialer(net.Dialer{
+		Timeout:   p.Timeout,
+		KeepAlive: p.KeepAlive,
+		DualStack: p.DualStack,
+	})
+
+	resolver := p.DNSResolver
+	if resolver == nil && len(p.DNSServers) > 0 {
+		resolver = dnscache.NewServerResolver(p.DNSServers, dialer.Dialer.DialContext)
+	}
+
+	dnsCache := dnscache.New(dnscache.Options{
+		TTL:        p.DNSCacheTTL,
+		StaleTTL:   p.DNSStaleTTL,
+		RespectTTL: p.DNSRespectTTL,
+		Resolver:   resolver,
+		Dial:       dialer.Dialer.DialContext,
+		Metrics:    m,
+	})
+
+	dialer.f = dnsCache.DialContext
+	if p.HappyEyeballs {
+		dialer.f = happyeyeballs.New(happyeyeballs.Options{
+			PreferIPv4:    p.PreferIPv4,
+			FallbackDelay: p.DialFallbackDelay,
+			Resolver:      dnsCache,
+			Dial:          dialer.Dialer.DialContext,
+		}).DialContext
+	}
+
+	unixDial := dialer.Dialer.DialContext
+	var pool *connPool
+	if p.EnableBackendConnectionMetrics {
+		pool = newConnPool(m)
+		dialer.f = pool.dialContext(dialer.f)
+		unixDial = pool.dialContext(unixDial)
+	}
+
 	tr := &http.Transport{
-		DialContext: newSkipperDialer(net.Dialer{
-			Timeout:   p.Timeout,
-			KeepAlive: p.KeepAlive,
-			DualStack: p.DualStack,
-		}).DialContext,
+		DialContext:           dialer.DialContext,
 		TLSHandshakeTimeout:   p.TLSHandshakeTimeout,
 		ResponseHeaderTimeout: p.ResponseHeaderTimeout,
 		ExpectContinueTimeout: p.ExpectContinueTimeout,
 		MaxIdleConns:          p.MaxIdleConns,
 		MaxIdleConnsPerHost:   p.IdleConnectionsPerHost,
+		MaxConnsPerHost:       p.MaxConnsPerHost,
 		IdleConnTimeout:       p.CloseIdleConnsPeriod,
 		DisableKeepAlives:     p.DisableHTTPKeepalives,
 		Proxy:                 proxyFromContext,
 	}
 
+	if p.ClientTLS != nil {
+		tr.TLSClientConfig = p.ClientTLS
+	}
+
+	if p.Flags.Insecure() {
+		if tr.TLSClientConfig == nil {
+			/* #nosec */
+			tr.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+		} else {
+			/* #nosec */
+			tr.TLSClientConfig.InsecureSkipVerify = true
+		}
+	}
+
+	// created before attaching the prewarmer, because the prewarmed
+	// connections don't have the PROXY protocol header
+	proxyProtocolDialer := &skipperDialer{Dialer: dialer.Dialer, f: dialer.f}
+	proxyProtocolTr := newProxyProtocolTransport(tr, proxyProtocolDialer.DialContext)
+
+	// the unix domain socket addresses are not resolved
+	unixDialer := &skipperDialer{Dialer: dialer.Dialer, f: unixDial}
+	unixTr := newUnixTransport(tr, unixDialer.DialContext)
+
+	// created before attaching the prewarmer, because the prewarmed
+	// connections don't have the settings of the routes
+	routeTransportDialer := &skipperDialer{Dialer: dialer.Dialer, f: dialer.f}
+	routeTrs := newRouteTransports(tr.Clone(), routeTransportDialer.DialContext, p.CustomHttpRoundTripperWrap)
+
+	if p.Prewarmer != nil {
+		p.Prewarmer.attach(dialer.f, tr.TLSClientConfig, p.TLSHandshakeTimeout)
+		dialer.f = p.Prewarmer.dialContext(dialer.f)
+		tr.DialTLSContext = p.Prewarmer.dialTLSContext(dialer.DialContext)
+	}
+
+	grpcTr := newGRPCTransport(tr)
+	zeroCopyTr := newZeroCopyTransport(dialer.DialContext, p.ResponseHeaderTimeout, tr)
+
 	quit := make(chan struct{})
 	// We need this to reliably fade on DNS change, which is right
 	// now not fixed with IdleConnTimeout in the http.Transport.
@@ -651,6 +990,9 @@ func WithParams(p Params) *Proxy {
 				select {
 				case <-time.After(p.CloseIdleConnsPeriod):
 					tr.CloseIdleConnections()
+					grpcTr.CloseIdleConnections()
+					unixTr.CloseIdleConnections()
+					routeTrs.CloseIdleConnections()
 				case <-quit:
 					return
 				}
@@ -658,25 +1000,6 @@ func WithParams(p Params) *Proxy {
 		}()
 	}
 
-	if p.ClientTLS != nil {
-		tr.TLSClientConfig = p.ClientTLS
-	}
-
-	if p.Flags.Insecure() {
-		if tr.TLSClientConfig == nil {
-			/* #nosec */
-			tr.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
-		} else {
-			/* #nosec */
-			tr.TLSClientConfig.InsecureSkipVerify = true
-		}
-	}
-
-	m := metrics.Default
-	if p.Flags.Debug() {
-		m = metrics.Void
-	}
-
 	if p.MaxLoopbacks == 0 {
 		p.MaxLoopbacks = DefaultMaxLoopbacks
 	} else if p.MaxLoopbacks < 0 {
@@ -691,28 +1014,58 @@ func WithParams(p Params) *Proxy {
 
 	hostname := os.Getenv("HOSTNAME")
 
+	var fastCgiPool *fastcgi.ClientPool
+	if p.FastCgiMaxIdleConns > 0 {
+		fastCgiPool = fastcgi.NewClientPool(p.FastCgiMaxIdleConns, p.FastCgiIdleConnTimeout)
+	}
+
+	anomalyMaxHeaderSize := p.RequestAnomalyMaxHeaderSize
+	if anomalyMaxHeaderSize <= 0 {
+		anomalyMaxHeaderSize = defaultAnomalyHeaderSize
+	}
+
 	return &Proxy{
-		routing:                  p.Routing,
-		roundTripper:             p.CustomHttpRoundTripperWrap(tr),
-		priorityRoutes:           p.PriorityRoutes,
-		flags:                    p.Flags,
-		metrics:                  m,
-		quit:                     quit,
-		flushInterval:            p.FlushInterval,
-		experimentalUpgrade:      p.ExperimentalUpgrade,
-		experimentalUpgradeAudit: p.ExperimentalUpgradeAudit,
-		maxLoops:                 p.MaxLoopbacks,
-		breakers:                 p.CircuitBreakers,
-		lb:                       p.LoadBalancer,
-		limiters:                 p.RateLimiters,
-		log:                      &logging.DefaultLog{},
-		defaultHTTPStatus:        defaultHTTPStatus,
-		tracing:                  newProxyTracing(p.OpenTracing),
-		accessLogDisabled:        p.AccessLogDisabled,
-		upgradeAuditLogOut:       os.Stdout,
-		upgradeAuditLogErr:       os.Stderr,
-		clientTLS:                tr.TLSClientConfig,
-		hostname:                 hostname,
+		routing:                   p.Routing,
+		roundTripper:              p.CustomHttpRoundTripperWrap(tr),
+		grpcRoundTripper:          p.CustomHttpRoundTripperWrap(grpcTr),
+		proxyProtocolRoundTripper: p.CustomHttpRoundTripperWrap(proxyProtocolTr),
+		unixRoundTripper:          p.CustomHttpRoundTripperWrap(unixTr),
+		zeroCopyRoundTripper:      p.CustomHttpRoundTripperWrap(zeroCopyTr),
+		routeTransports:           routeTrs,
+		priorityRoutes:            p.PriorityRoutes,
+		flags:                     p.Flags,
+		metrics:                   m,
+		quit:                      quit,
+		flushInterval:             p.FlushInterval,
+		experimentalUpgrade:       p.ExperimentalUpgrade,
+		experimentalUpgradeAudit:  p.ExperimentalUpgradeAudit,
+		maxLoops:                  p.MaxLoopbacks,
+		retryBudget:               newRetryBudget(p.RetryBudget),
+		retryAfterMaxBackoff:      p.RetryAfterMaxBackoff,
+		breakers:                  p.CircuitBreakers,
+		lb:                        p.LoadBalancer,
+		limiters:                  p.RateLimiters,
+		log:                       &logging.DefaultLog{},
+		defaultHTTPStatus:         defaultHTTPStatus,
+		tracing:                   newProxyTracing(p.OpenTracing),
+		accessLogDisabled:         p.AccessLogDisabled,
+		upgradeAuditLogOut:        os.Stdout,
+		upgradeAuditLogErr:        os.Stderr,
+		clientTLS:                 tr.TLSClientConfig,
+		hostname:                  hostname,
+		fastCgiPool:               fastCgiPool,
+		anomalyMetrics:            p.EnableRequestAnomalyMetrics,
+		anomalyMaxHeaderSize:      anomalyMaxHeaderSize,
+		maxRequestBodySize:        p.MaxRequestBodySize,
+		forwardEarlyHints:         p.ForwardEarlyHints,
+		headerPolicy:              p.BackendHeaderPolicy,
+		disconnectGrace:           p.ClientDisconnectGracePeriod,
+		detectStreaming:           p.DetectStreaming,
+		streamingHeaderTimeout:    p.StreamingResponseHeaderTimeout,
+		streamingIdleTimeout:      p.StreamingIdleTimeout,
+		connPool:                  pool,
+		sseKeepAlive:              p.SSEKeepAliveInterval,
+		accounting:                p.Accounting,
 	}
 }
 
@@ -864,6 +1217,7 @@ func (p *Proxy) makeUpgradeRequest(ctx *context, req *http.Request) error {
 		auditLogHook:    p.auditLogHook,
 	}
 
+	upgradeProxy.inspection, _ = ctx.StateBag()[filters.WebsocketInspectionKey].(*websocket.Inspection)
 	upgradeProxy.serveHTTP(ctx.responseWriter, req)
 	ctx.successfulUpgrade = true
 	p.log.Debugf("finished upgraded protocol %s session", getUpgradeRequest(ctx.request))
@@ -876,10 +1230,24 @@ func (p *Proxy) makeBackendRequest(ctx *context, requestContext stdlibcontext.Co
 		return nil, &proxyError{err: fmt.Errorf("could not map backend request: %w", err)}
 	}
 
+	if perr := p.limitRequestBody(ctx, req); perr != nil {
+		return nil, perr
+	}
+
+	if perr := p.sanitizeRequestHeader(ctx, req); perr != nil {
+		return nil, perr
+	}
+
 	if res, ok := p.rejectBackend(ctx, req); ok {
 		return res, nil
 	}
 
+	p.sendEarlyHints(ctx)
+
+	if propagateDeadline(ctx) {
+		setDeadlineHeaders(req)
+	}
+
 	if endpoint != nil {
 		endpoint.Metrics.IncInflightRequest()
 		defer endpoint.Metrics.DecInflightRequest()
@@ -896,6 +1264,10 @@ func (p *Proxy) makeBackendRequest(ctx *context, requestContext stdlibcontext.Co
 
 	roundTripper, err := p.getRoundTripper(ctx, req)
 	if err != nil {
+		if endpoint != nil {
+			endpoint.Metrics.IncFailedRequest()
+		}
+
 		return nil, &proxyError{err: fmt.Errorf("failed to get roundtripper: %w", err), code: http.StatusBadGateway}
 	}
 
@@ -918,16 +1290,33 @@ func (p *Proxy) makeBackendRequest(ctx *context, requestContext stdlibcontext.Co
 	_ = p.tracing.tracer.Inject(ctx.proxySpan.Context(), ot.HTTPHeaders, carrier)
 
 	req = req.WithContext(ot.ContextWithSpan(req.Context(), ctx.proxySpan))
+	req = withProxyProtocolAddrs(ctx, req)
 
 	p.metrics.IncCounter("outgoing." + req.Proto)
 	ctx.proxySpan.LogKV("http_roundtrip", StartEvent)
 	req = injectClientTrace(req, ctx.proxySpan)
+	if p.connPool != nil {
+		req = p.connPool.trace(req)
+	}
 
-	response, err := roundTripper.RoundTrip(req)
+	req, stopEarlyHints := p.earlyHintsTrace(ctx, req)
+	defer stopEarlyHints()
+
+	var response *http.Response
+	if delay, ok := hedgeDelay(ctx, req, endpoint); ok {
+		response, err = p.hedgedRoundTrip(ctx, roundTripper, req, endpoint, delay)
+	} else {
+		response, err = roundTripper.RoundTrip(req)
+	}
 
 	ctx.proxySpan.LogKV("http_roundtrip", EndEvent)
 	if err != nil {
 
+		if errors.Is(err, errRequestBodyTooLarge) {
+			p.tracing.setTag(ctx.proxySpan, HTTPStatusCodeTag, uint16(http.StatusRequestEntityTooLarge))
+			return nil, bodyTooLargeError(err)
+		}
+
 		if errors.Is(err, ErrBlocked) {
 			p.tracing.setTag(ctx.proxySpan, BlockTag, true)
 			p.tracing.setTag(ctx.proxySpan, HTTPStatusCodeTag, uint16(http.StatusBadRequest))
@@ -936,6 +1325,8 @@ func (p *Proxy) makeBackendRequest(ctx *context, requestContext stdlibcontext.Co
 			p.tracing.setTag(ctx.proxySpan, ErrorTag, true)
 		}
 
+		p.recordBackendError(ctx, req.URL.Host, classifyBackendError(req.Context(), err))
+
 		// Check if the request has been cancelled or timed out
 		// The roundtrip error `err` may be different:
 		// - for `Canceled` it could be either the same `context canceled` or `unexpected EOF` (net.OpError)
@@ -950,6 +1341,9 @@ func (p *Proxy) makeBackendRequest(ctx *context, requestContext stdlibcontext.Co
 		}
 
 		ctx.proxySpan.LogKV("event", "error", "message", err.Error())
+		if endpoint != nil {
+			endpoint.Metrics.IncFailedRequest()
+		}
 
 		if perr, ok := err.(*proxyError); ok {
 			//p.lb.AddHealthcheck(ctx.route.Backend)
@@ -972,6 +1366,7 @@ func (p *Proxy) makeBackendRequest(ctx *context, requestContext stdlibcontext.Co
 		return nil, &proxyError{err: fmt.Errorf("unexpected error from Go stdlib net/http package during roundtrip: %w", err)}
 	}
 	p.tracing.setTag(ctx.proxySpan, HTTPStatusCodeTag, uint16(response.StatusCode))
+	p.backoffEndpoint(endpoint, response)
 	return response, nil
 }
 
@@ -984,7 +1379,11 @@ func (p *Proxy) getRoundTripper(ctx *context, req *http.Request) (http.RoundTrip
 		} else if len(req.URL.Path) > 1 && req.URL.Path != "/" {
 			f = req.URL.Path[1:]
 		}
-		rt, err := fastcgi.NewRoundTripper(p.log, req.URL.Host, f)
+		params, _ := ctx.StateBag()[filters.FastCgiParams].(map[string]string)
+		rt, err := fastcgi.NewRoundTripperWithOptions(p.log, req.URL.Host, f, fastcgi.Options{
+			Pool:   p.fastCgiPool,
+			Params: params,
+		})
 		if err != nil {
 			return nil, err
 		}
@@ -999,7 +1398,29 @@ func (p *Proxy) getRoundTripper(ctx *context, req *http.Request) (http.RoundTrip
 		req.RemoteAddr = ctx.request.RemoteAddr
 
 		return rt, nil
+	case unixScheme:
+		return p.unixRoundTripper, nil
 	default:
+		if p.grpcRoundTripper != nil && (isGRPCRequest(req) || useH2C(ctx)) {
+			if s, ok := p.transportSettings(ctx); ok && p.routeTransports != nil {
+				return p.routeTransports.grpcRoundTripper(s), nil
+			}
+
+			return p.grpcRoundTripper, nil
+		}
+
+		if p.proxyProtocolRoundTripper != nil && useProxyProtocol(ctx) {
+			return p.proxyProtocolRoundTripper, nil
+		}
+
+		if p.zeroCopyRoundTripper != nil && useZeroCopy(ctx) {
+			return p.zeroCopyRoundTripper, nil
+		}
+
+		if s, ok := p.transportSettings(ctx); ok && p.routeTransports != nil {
+			return p.routeTransports.roundTripper(s), nil
+		}
+
 		return p.roundTripper, nil
 	}
 }
@@ -1033,6 +1454,8 @@ func (p *Proxy) checkBreaker(c *context) (func(bool), bool) {
 		return nil, true
 	}
 
+	c.route.Stats.SetBreaker(b)
+
 	done, ok := b.Allow()
 	if !ok && c.request.Body != nil {
 		// consume the body to prevent goroutine leaks
@@ -1071,10 +1494,16 @@ func (p *Proxy) do(ctx *context) error {
 			done()
 		}
 
+		pendingBulkhead, _ := stateBag[scheduler.BulkheadKey].([]func())
+		for _, done := range pendingBulkhead {
+			done()
+		}
+
 		// Cleanup state bag to avoid double call of done()
 		// because do() could be called for loopback backend
 		delete(stateBag, scheduler.FIFOKey)
 		delete(stateBag, scheduler.LIFOKey)
+		delete(stateBag, scheduler.BulkheadKey)
 	}()
 
 	// proxy global setting
@@ -1100,6 +1529,16 @@ func (p *Proxy) do(ctx *context) error {
 	}
 
 	ctx.applyRoute(route, params, p.flags.PreserveHost())
+	if len(route.Annotations) > 0 {
+		annotate.Set(ctx.StateBag(), route.Annotations)
+	}
+
+	if ctx.routeStats == nil {
+		// the request is counted for the first matching route only,
+		// when passing through loopback routes
+		ctx.routeStats = route.Stats
+		ctx.routeStats.Begin()
+	}
 
 	processedFilters := p.applyFiltersToRequest(ctx.route.Filters, ctx)
 
@@ -1138,13 +1577,19 @@ func (p *Proxy) do(ctx *context) error {
 			return errCircuitBreakerOpen
 		}
 
-		backendContext := ctx.request.Context()
-		if timeout, ok := ctx.StateBag()[filters.BackendTimeout]; ok {
-			backendContext, ctx.cancelBackendContext = stdlibcontext.WithTimeout(backendContext, timeout.(time.Duration))
-		}
+		ctx.streaming = p.isStreaming(ctx)
+		backendContext := p.backendContext(ctx)
 
 		backendStart := time.Now()
-		rsp, perr := p.makeBackendRequest(ctx, backendContext)
+		var rsp *http.Response
+		var perr *proxyError
+		policy, hasPolicy := ctx.StateBag()[filters.RetryPolicyKey].(*retry.Policy)
+		if hasPolicy {
+			rsp, perr = p.makeBackendRequestWithRetries(ctx, backendContext, policy)
+		} else {
+			rsp, perr = p.makeBackendRequest(ctx, backendContext)
+		}
+
 		if perr != nil {
 			if done != nil {
 				done(false)
@@ -1152,12 +1597,15 @@ func (p *Proxy) do(ctx *context) error {
 
 			p.metrics.IncErrorsBackend(ctx.route.Id)
 
-			if retryable(ctx, perr) {
+			// the routes with a retry policy are not retried
+			// implicitly
+			if !hasPolicy && retryable(ctx, perr) {
 				if ctx.proxySpan != nil {
 					ctx.proxySpan.Finish()
 					ctx.proxySpan = nil
 				}
 
+				p.metrics.IncCounter("retries")
 				tracing.LogKV("retry", ctx.route.Id, ctx.Request().Context())
 
 				perr = nil
@@ -1175,6 +1623,30 @@ func (p *Proxy) do(ctx *context) error {
 			}
 		}
 
+		if backend, ok := retryOnResponse(ctx, rsp); ok {
+			// the response is discarded, the request is sent again
+			rsp.Body.Close()
+			if ctx.proxySpan != nil {
+				ctx.proxySpan.Finish()
+				ctx.proxySpan = nil
+			}
+
+			p.metrics.IncCounter("retries")
+			tracing.LogKV("retry", ctx.route.Id, ctx.Request().Context())
+
+			ctx.retryBackend = backend
+			rsp, perr = p.makeBackendRequest(ctx, backendContext)
+			if perr != nil {
+				if done != nil {
+					done(false)
+				}
+
+				p.metrics.IncErrorsBackend(ctx.route.Id)
+				p.log.Errorf("Failed to retry backend request on response: %v", perr)
+				return perr
+			}
+		}
+
 		if rsp.StatusCode >= http.StatusInternalServerError {
 			p.metrics.MeasureBackend5xx(backendStart)
 		}
@@ -1183,7 +1655,10 @@ func (p *Proxy) do(ctx *context) error {
 			done(rsp.StatusCode < http.StatusInternalServerError)
 		}
 
+		p.limitStreamIdle(ctx, rsp)
 		ctx.setResponse(rsp, p.flags.PreserveOriginal())
+		ctx.backendBody = rsp.Body
+		ctx.backendTime += time.Since(backendStart)
 		p.metrics.MeasureBackend(ctx.route.Id, backendStart)
 		p.metrics.MeasureBackendHost(ctx.route.Host, backendStart)
 	}
@@ -1200,6 +1675,58 @@ func retryable(ctx *context, perr *proxyError) bool {
 		req != nil && (req.Body == nil || req.Body == http.NoBody)
 }
 
+// routeBackend returns whether the scheme://host address is the network
+// backend or one of the load balanced endpoints of the route
+func routeBackend(r *routing.Route) func(string) bool {
+	return func(b string) bool {
+		switch r.BackendType {
+		case eskip.NetworkBackend:
+			return r.Scheme+"://"+r.Host == b
+		case eskip.LBBackend:
+			for _, ep := range r.LBEndpoints {
+				if ep.Scheme+"://"+ep.Host == b {
+					return true
+				}
+			}
+		}
+
+		return false
+	}
+}
+
+// retryOnResponse returns whether the request needs to be retried based
+// on the response, and the backend to retry against, when it differs
+// from the route backend. Only the requests without a body are retried.
+func retryOnResponse(ctx *context, rsp *http.Response) (*url.URL, bool) {
+	retry, ok := ctx.StateBag()[filters.RetryOnResponseKey].(func(*http.Response, func(string) bool) (string, bool))
+	if !ok {
+		return nil, false
+	}
+
+	req := ctx.Request()
+	if req.Body != nil && req.Body != http.NoBody {
+		return nil, false
+	}
+
+	backend, ok := retry(rsp, routeBackend(ctx.route))
+	if !ok {
+		return nil, false
+	}
+
+	// retry once
+	delete(ctx.StateBag(), filters.RetryOnResponseKey)
+	if backend == "" {
+		return nil, true
+	}
+
+	u, err := url.Parse(backend)
+	if err != nil {
+		return nil, false
+	}
+
+	return u, true
+}
+
 func (p *Proxy) serveResponse(ctx *context) {
 	if p.flags.Debug() {
 		dbgResponse(ctx.responseWriter, &debugInfo{
@@ -1216,6 +1743,7 @@ func (p *Proxy) serveResponse(ctx *context) {
 	start := time.Now()
 	p.tracing.logStreamEvent(ctx.proxySpan, StreamHeadersEvent, StartEvent)
 	copyHeader(ctx.responseWriter.Header(), ctx.response.Header)
+	announceTrailers(ctx.responseWriter.Header(), ctx.response)
 
 	if err := ctx.Request().Context().Err(); err != nil {
 		// deadline exceeded or canceled in stdlib, client closed request
@@ -1231,9 +1759,40 @@ func (p *Proxy) serveResponse(ctx *context) {
 	ctx.responseWriter.Flush()
 	p.tracing.logStreamEvent(ctx.proxySpan, StreamHeadersEvent, EndEvent)
 
-	n, err := copyStream(ctx.responseWriter, ctx.response.Body)
+	var (
+		n   int64
+		err error
+	)
+
+	// the streaming responses of unknown length, and the responses of
+	// the streaming requests, are flushed after every write, the
+	// Server-Sent Events after every event
+	if isEventStream(ctx.response) {
+		n, err = p.copyEventStream(ctx.responseWriter, ctx.response.Body)
+	} else if ctx.response.ContentLength > 0 && ctx.unmodifiedBackendBody() && !ctx.streaming {
+		n, err = copyPassthrough(ctx.responseWriter, ctx.response.Body)
+	} else {
+		n, err = copyStream(ctx.responseWriter, ctx.response.Body)
+	}
+
+	copyTrailer(ctx.responseWriter.Header(), ctx.response.Trailer)
+	if isGRPCRequest(ctx.request) {
+		p.finishGRPCResponse(ctx, err)
+	}
+
 	p.tracing.logStreamEvent(ctx.proxySpan, StreamBodyEvent, strconv.FormatInt(n, 10))
 	if err != nil {
+		class := backendErrorBodyRead
+		if ctx.request.Context().Err() != nil {
+			class = backendErrorCanceled
+		}
+
+		var host string
+		if ctx.response.Request != nil {
+			host = ctx.response.Request.URL.Host
+		}
+
+		p.recordBackendError(ctx, host, class)
 		p.metrics.IncErrorsStreaming(ctx.route.Id)
 		p.log.Debugf("error while copying the response stream: %v", err)
 		p.tracing.setTag(ctx.proxySpan, ErrorTag, true)
@@ -1331,6 +1890,11 @@ func (p *Proxy) errorResponse(ctx *context, err error) {
 		req.UserAgent(),
 	)
 
+	if isGRPCRequest(req) {
+		p.sendGRPCError(ctx, id, code)
+		return
+	}
+
 	p.sendError(ctx, id, code)
 }
 
@@ -1355,6 +1919,10 @@ func remoteAddr(r *http.Request) string {
 }
 
 func remoteHost(r *http.Request) string {
+	if ip, ok := snet.ClientIP(r); ok {
+		return ip.String()
+	}
+
 	a := remoteAddr(r)
 	return stripPort(a)
 }
@@ -1387,6 +1955,12 @@ func (p *Proxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	p.metrics.IncCounter("incoming." + r.Proto)
 	var ctx *context
 
+	if p.anomalyMetrics {
+		if reasons := requestAnomalies(r, p.anomalyMaxHeaderSize); len(reasons) > 0 {
+			defer func() { p.countAnomalies(reasons, ctx) }()
+		}
+	}
+
 	var span ot.Span
 	if wireContext, err := p.tracing.tracer.Extract(ot.HTTPHeaders, ot.HTTPHeadersCarrier(r.Header)); err != nil {
 		span = p.tracing.tracer.StartSpan(p.tracing.initialOperationName)
@@ -1421,6 +1995,13 @@ func (p *Proxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 				Duration:     time.Since(ctx.startServe),
 			}
 
+			if ip, ok := snet.ClientIP(r); ok {
+				entry.ClientIP = ip
+			}
+
+			entry.Identity, _ = ctx.stateBag[al.AccessLogIdentityKey].(*al.Identity)
+			entry.BackendError = ctx.backendError
+
 			additionalData, _ := ctx.stateBag[al.AccessLogAdditionalDataKey].(map[string]interface{})
 
 			logging.LogAccess(entry, additionalData)
@@ -1442,6 +2023,7 @@ func (p *Proxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	p.setCommonSpanInfo(r.URL, r, span)
 	r = r.WithContext(ot.ContextWithSpan(r.Context(), span))
 
+	requestBody := p.countRequestBody(r)
 	ctx = newContext(lw, r, p)
 	ctx.startServe = time.Now()
 	ctx.tracer = p.tracing.tracer
@@ -1464,6 +2046,9 @@ func (p *Proxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		p.serveResponse(ctx)
 	}
 
+	ctx.routeStats.End(lw.GetCode() >= http.StatusInternalServerError)
+	p.account(ctx, lw.GetBytes(), requestBody)
+
 	if ctx.cancelBackendContext != nil {
 		ctx.cancelBackendContext()
 	}
@@ -1474,6 +2059,10 @@ func (p *Proxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 // It's primary purpose is to support testing.
 func (p *Proxy) Close() error {
 	close(p.quit)
+	if p.fastCgiPool != nil {
+		p.fastCgiPool.Close()
+	}
+
 	return nil
 }
 
@@ -2240,3 +2240,146 @@ func BenchmarkAccessLogEnablePrint(b *testing.B) {
 	benchmarkAccessLog(b, "enableAccessLog(1,200,3)", 200)
 }
 func BenchmarkAccessLogEnable(b *testing.B) { benchmarkAccessLog(b, "enableAccessLog(1,3)", 200) }
+
+func BenchmarkProxyBackendAllocations(b *testing.B) {
+	payload := bytes.Repeat([]byte("x"), 3*proxyBufferSize)
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Test", "foo")
+		w.Write(payload)
+	}))
+	defer backend.Close()
+
+	tp, err := newTestProxyWithParams(fmt.Sprintf(`* -> setRequestHeader("X-Test", "bar") -> %q`, backend.URL), Params{})
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	defer tp.close()
+
+	r := &http.Request{
+		URL:    &url.URL{Path: "/"},
+		Method: "GET",
+		Header: http.Header{"Accept": []string{"*/*"}, "User-Agent": []string{"benchmark"}},
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			tp.proxy.ServeHTTP(httptest.NewRecorder(), r)
+		}
+	})
+}
+
+func TestCopyStreamReusesBuffers(t *testing.T) {
+	var allocated int
+	streamBuffers.pool.Store(&sync.Pool{
+		New: func() interface{} {
+			allocated++
+			b := make([]byte, proxyBufferSize)
+			return &b
+		},
+	})
+	defer streamBuffers.release()
+
+	const runs = 100
+	payload := bytes.Repeat([]byte("0123456789"), proxyBufferSize/2)
+	for i := 0; i < runs; i++ {
+		rec := httptest.NewRecorder()
+
+		// hides the io.WriterTo implementation, to copy through the buffer
+		n, err := copyStream(rec, struct{ io.Reader }{bytes.NewReader(payload)})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if n != int64(len(payload)) || !bytes.Equal(rec.Body.Bytes(), payload) {
+			t.Fatal("failed to copy the stream")
+		}
+	}
+
+	// sync.Pool may drop some of the buffers, e.g. with the race
+	// detector, but most of them need to be reused
+	if allocated == 0 || allocated > runs/2 {
+		t.Errorf("failed to reuse the buffers: %d allocated for %d copies", allocated, runs)
+	}
+}
+
+func TestPassthroughUnmodifiedBody(t *testing.T) {
+	payload := bytes.Repeat([]byte("0123456789"), 3*passthroughBufferSize/10)
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("chunked") == "" {
+			w.Header().Set("Content-Length", strconv.Itoa(len(payload)))
+		}
+
+		w.Write(payload)
+	}))
+	defer backend.Close()
+
+	tp, err := newTestProxy(fmt.Sprintf(`
+		passthrough: Path("/passthrough") -> %q;
+		modified: Path("/modified") -> sed("0123", "abcd") -> %q`,
+		backend.URL, backend.URL), FlagsNone)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer tp.close()
+
+	ps := httptest.NewServer(tp.proxy)
+	defer ps.Close()
+
+	for _, ti := range []struct {
+		path     string
+		expected []byte
+	}{{
+		path:     "/passthrough",
+		expected: payload,
+	}, {
+		path:     "/passthrough?chunked=true",
+		expected: payload,
+	}, {
+		path:     "/modified",
+		expected: bytes.ReplaceAll(payload, []byte("0123"), []byte("abcd")),
+	}} {
+		t.Run(ti.path, func(t *testing.T) {
+			rsp, err := http.Get(ps.URL + ti.path)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			defer rsp.Body.Close()
+			b, err := io.ReadAll(rsp.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !bytes.Equal(b, ti.expected) {
+				t.Errorf("unexpected body, got %d bytes, expected %d", len(b), len(ti.expected))
+			}
+		})
+	}
+}
+
+func TestUnmodifiedBackendBody(t *testing.T) {
+	body := io.NopCloser(bytes.NewBufferString("foo"))
+	ctx := &context{backendBody: body, response: &http.Response{Body: body}}
+	if ctx.unmodifiedBackendBody() {
+		t.Error("non-pointer bodies should not be compared")
+	}
+
+	pbody := &nopBody{}
+	ctx = &context{backendBody: pbody, response: &http.Response{Body: pbody}}
+	if !ctx.unmodifiedBackendBody() {
+		t.Error("failed to detect unmodified body")
+	}
+
+	ctx.response.Body = &nopBody{}
+	if ctx.unmodifiedBackendBody() {
+		t.Error("failed to detect modified body")
+	}
+}
+
+type nopBody struct{ bytes.Buffer }
+
+func (*nopBody) Close() error { return nil }
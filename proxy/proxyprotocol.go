@@ -0,0 +1,135 @@
+package proxy
+
+import (
+	stdlibcontext "context"
+	"encoding/binary"
+	"net"
+	"net/http"
+	"strconv"
+
+	"github.com/zalando/skipper/filters"
+)
+
+const (
+	proxyProtocolCommandLocal = 0x20
+	proxyProtocolCommandProxy = 0x21
+	proxyProtocolTCP4         = 0x11
+	proxyProtocolTCP6         = 0x21
+)
+
+var proxyProtocolSignature = []byte("\r\n\r\n\x00\r\nQUIT\n")
+
+type proxyProtocolAddrsKey struct{}
+
+// the addresses sent in the PROXY protocol header: the address of the
+// client, and the address of the listener that accepted the incoming
+// connection
+type proxyProtocolAddrs struct {
+	src, dst *net.TCPAddr
+}
+
+func useProxyProtocol(ctx *context) bool {
+	v, _ := ctx.StateBag()[filters.BackendProxyProtocolKey].(bool)
+	return v
+}
+
+func parseTCPAddr(addr string) *net.TCPAddr {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil
+	}
+
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return nil
+	}
+
+	p, err := strconv.Atoi(port)
+	if err != nil || p < 0 || p > 65535 {
+		return nil
+	}
+
+	return &net.TCPAddr{IP: ip, Port: p}
+}
+
+// withProxyProtocolAddrs stores the addresses of the incoming request in
+// the context of the backend request, when the backendProxyProtocol
+// filter is set, so that the dialer of the proxy protocol transport can
+// send them to the backend.
+func withProxyProtocolAddrs(ctx *context, req *http.Request) *http.Request {
+	if !useProxyProtocol(ctx) {
+		return req
+	}
+
+	var a proxyProtocolAddrs
+	a.src = parseTCPAddr(ctx.request.RemoteAddr)
+	if local, ok := ctx.request.Context().Value(http.LocalAddrContextKey).(net.Addr); ok {
+		a.dst = parseTCPAddr(local.String())
+	}
+
+	return req.WithContext(stdlibcontext.WithValue(req.Context(), proxyProtocolAddrsKey{}, a))
+}
+
+// proxyProtocolHeader returns a PROXY protocol v2 header. Without a
+// valid client address, it uses the LOCAL command, and the backend
+// uses the address of the connection itself. When the local address
+// is not known, e.g. in case of the split requests, the unspecified
+// address is sent instead.
+func proxyProtocolHeader(a proxyProtocolAddrs) []byte {
+	h := append([]byte(nil), proxyProtocolSignature...)
+	if a.src == nil {
+		return append(h, proxyProtocolCommandLocal, 0, 0, 0)
+	}
+
+	dst := a.dst
+	if dst == nil {
+		dst = &net.TCPAddr{IP: net.IPv4zero}
+		if a.src.IP.To4() == nil {
+			dst.IP = net.IPv6unspecified
+		}
+	}
+
+	var addrs []byte
+	if src4, dst4 := a.src.IP.To4(), dst.IP.To4(); src4 != nil && dst4 != nil {
+		h = append(h, proxyProtocolCommandProxy, proxyProtocolTCP4)
+		addrs = append(append(addrs, src4...), dst4...)
+	} else {
+		h = append(h, proxyProtocolCommandProxy, proxyProtocolTCP6)
+		addrs = append(append(addrs, a.src.IP.To16()...), dst.IP.To16()...)
+	}
+
+	addrs = binary.BigEndian.AppendUint16(addrs, uint16(a.src.Port))
+	addrs = binary.BigEndian.AppendUint16(addrs, uint16(dst.Port))
+	h = binary.BigEndian.AppendUint16(h, uint16(len(addrs)))
+	return append(h, addrs...)
+}
+
+// proxyProtocolDialContext writes the PROXY protocol v2 header on the
+// new connections, before the TLS handshake, if any.
+func proxyProtocolDialContext(dial dialFunc) dialFunc {
+	return func(ctx stdlibcontext.Context, network, addr string) (net.Conn, error) {
+		conn, err := dial(ctx, network, addr)
+		if err != nil {
+			return nil, err
+		}
+
+		a, _ := ctx.Value(proxyProtocolAddrsKey{}).(proxyProtocolAddrs)
+		if _, err := conn.Write(proxyProtocolHeader(a)); err != nil {
+			conn.Close()
+			return nil, err
+		}
+
+		return conn, nil
+	}
+}
+
+// newProxyProtocolTransport creates the transport used for the routes
+// with the backendProxyProtocol filter. The connections are bound to
+// the client address, therefore they are not reused.
+func newProxyProtocolTransport(tr *http.Transport, dial dialFunc) *http.Transport {
+	ptr := tr.Clone()
+	ptr.DialContext = proxyProtocolDialContext(dial)
+	ptr.DialTLSContext = nil
+	ptr.DisableKeepAlives = true
+	return ptr
+}
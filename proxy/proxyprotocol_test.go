@@ -0,0 +1,171 @@
+package proxy
+
+import (
+	"bytes"
+	stdlibcontext "context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+// proxyProtocolListener reads the PROXY protocol v2 header of the
+// accepted connections and records the source addresses
+type proxyProtocolListener struct {
+	net.Listener
+	mu      sync.Mutex
+	sources []string
+}
+
+func (l *proxyProtocolListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+
+	h := make([]byte, 16)
+	if _, err := io.ReadFull(conn, h); err != nil || !bytes.Equal(h[:12], proxyProtocolSignature) || h[12] != proxyProtocolCommandProxy {
+		l.record("invalid")
+		return conn, nil
+	}
+
+	addrs := make([]byte, binary.BigEndian.Uint16(h[14:]))
+	if _, err := io.ReadFull(conn, addrs); err != nil {
+		l.record("invalid")
+		return conn, nil
+	}
+
+	switch {
+	case h[13] == proxyProtocolTCP4 && len(addrs) == 12:
+		l.record(fmt.Sprintf("%s:%d", net.IP(addrs[:4]), binary.BigEndian.Uint16(addrs[8:])))
+	case h[13] == proxyProtocolTCP6 && len(addrs) == 36:
+		l.record(fmt.Sprintf("[%s]:%d", net.IP(addrs[:16]), binary.BigEndian.Uint16(addrs[32:])))
+	default:
+		l.record("invalid")
+	}
+
+	return conn, nil
+}
+
+func (l *proxyProtocolListener) record(s string) {
+	l.mu.Lock()
+	l.sources = append(l.sources, s)
+	l.mu.Unlock()
+}
+
+func (l *proxyProtocolListener) recorded() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]string(nil), l.sources...)
+}
+
+func TestBackendProxyProtocol(t *testing.T) {
+	nl, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	l := &proxyProtocolListener{Listener: nl}
+	backend := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("hello"))
+	})}
+
+	go backend.Serve(l)
+	defer backend.Close()
+
+	tp, err := newTestProxy(fmt.Sprintf(`* -> backendProxyProtocol() -> "http://%s"`, nl.Addr()), FlagsNone)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer tp.close()
+
+	ps := httptest.NewServer(tp.proxy)
+	defer ps.Close()
+
+	var clients []string
+	for i := 0; i < 2; i++ {
+		var client string
+		c := &http.Client{Transport: &http.Transport{
+			DialContext: func(ctx stdlibcontext.Context, network, addr string) (net.Conn, error) {
+				conn, err := (&net.Dialer{}).DialContext(ctx, network, addr)
+				if err == nil {
+					client = conn.LocalAddr().String()
+				}
+
+				return conn, err
+			},
+		}}
+
+		rsp, err := c.Get(ps.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		b, _ := io.ReadAll(rsp.Body)
+		rsp.Body.Close()
+		if rsp.StatusCode != http.StatusOK || string(b) != "hello" {
+			t.Fatalf("unexpected response: %d %s", rsp.StatusCode, b)
+		}
+
+		clients = append(clients, client)
+	}
+
+	sources := l.recorded()
+	if len(sources) != len(clients) {
+		t.Fatalf("expected a new backend connection per request, got: %v", sources)
+	}
+
+	for i := range clients {
+		if sources[i] != clients[i] {
+			t.Errorf("unexpected source address, expected: %s, got: %s", clients[i], sources[i])
+		}
+	}
+}
+
+func TestProxyProtocolHeader(t *testing.T) {
+	for _, tt := range []struct {
+		title    string
+		addrs    proxyProtocolAddrs
+		expected []byte
+	}{{
+		title:    "local",
+		expected: []byte{0x20, 0, 0, 0},
+	}, {
+		title: "tcp4",
+		addrs: proxyProtocolAddrs{
+			src: parseTCPAddr("10.0.0.1:1234"),
+			dst: parseTCPAddr("10.0.0.2:443"),
+		},
+		expected: []byte{0x21, 0x11, 0, 12, 10, 0, 0, 1, 10, 0, 0, 2, 0x04, 0xd2, 0x01, 0xbb},
+	}, {
+		title: "tcp4 without local address",
+		addrs: proxyProtocolAddrs{
+			src: parseTCPAddr("10.0.0.1:1234"),
+		},
+		expected: []byte{0x21, 0x11, 0, 12, 10, 0, 0, 1, 0, 0, 0, 0, 0x04, 0xd2, 0, 0},
+	}, {
+		title: "mixed families",
+		addrs: proxyProtocolAddrs{
+			src: parseTCPAddr("[2001:db8::1]:1234"),
+			dst: parseTCPAddr("10.0.0.2:443"),
+		},
+		expected: append(append(append(
+			[]byte{0x21, 0x21, 0, 36},
+			net.ParseIP("2001:db8::1").To16()...),
+			net.ParseIP("10.0.0.2").To16()...),
+			0x04, 0xd2, 0x01, 0xbb,
+		),
+	}} {
+		t.Run(tt.title, func(t *testing.T) {
+			h := proxyProtocolHeader(tt.addrs)
+			if !bytes.Equal(h[:12], proxyProtocolSignature) || !bytes.Equal(h[12:], tt.expected) {
+				t.Errorf("unexpected header: %x", h)
+			}
+		})
+	}
+}
@@ -0,0 +1,175 @@
+package proxy
+
+import (
+	stdlibcontext "context"
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/zalando/skipper/filters/retry"
+	"github.com/zalando/skipper/tracing"
+)
+
+// DefaultRetryBudget is the default ratio of the retries to the requests
+// of the routes with a retry policy.
+const DefaultRetryBudget = 0.2
+
+// the number of retries allowed in a burst, and at startup
+const retryBudgetBurst = 10
+
+// retryBudget limits the retries to a ratio of the requests with a retry
+// policy, to prevent retry storms when the backends are overloaded. Every
+// request deposits the ratio, and every retry withdraws one from the
+// balance, that is capped to allow only short bursts. The nil budget
+// allows all the retries.
+type retryBudget struct {
+	mu      sync.Mutex
+	ratio   float64
+	balance float64
+}
+
+func newRetryBudget(ratio float64) *retryBudget {
+	if ratio == 0 {
+		ratio = DefaultRetryBudget
+	} else if ratio < 0 {
+		return nil
+	}
+
+	return &retryBudget{ratio: ratio, balance: retryBudgetBurst}
+}
+
+func (b *retryBudget) deposit() {
+	if b == nil {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.balance += b.ratio
+	if b.balance > retryBudgetBurst {
+		b.balance = retryBudgetBurst
+	}
+}
+
+func (b *retryBudget) withdraw() bool {
+	if b == nil {
+		return true
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.balance < 1 {
+		return false
+	}
+
+	b.balance--
+	return true
+}
+
+func contextError(ctx stdlibcontext.Context) *proxyError {
+	err := ctx.Err()
+	if err == stdlibcontext.DeadlineExceeded {
+		return &proxyError{err: err, code: http.StatusGatewayTimeout}
+	}
+
+	return &proxyError{err: err, code: 499}
+}
+
+// makes a single backend request, and when the per-try timeout is set,
+// fails it if the response headers are not received within the timeout
+func (p *Proxy) makeBackendAttempt(ctx *context, backendContext stdlibcontext.Context, timeout time.Duration) (*http.Response, *proxyError) {
+	if timeout <= 0 {
+		return p.makeBackendRequest(ctx, backendContext)
+	}
+
+	tryContext, cancel := stdlibcontext.WithCancel(backendContext)
+	var timedOut int32
+	t := time.AfterFunc(timeout, func() {
+		atomic.StoreInt32(&timedOut, 1)
+		cancel()
+	})
+
+	rsp, perr := p.makeBackendRequest(ctx, tryContext)
+	t.Stop()
+	if atomic.LoadInt32(&timedOut) == 0 {
+		if perr != nil {
+			cancel()
+			return nil, perr
+		}
+
+		// the context of the attempt is cancelled, when the response
+		// body is closed
+		rsp.Body = cancelBody{ReadCloser: rsp.Body, cancel: cancel}
+		return rsp, nil
+	}
+
+	cancel()
+	if rsp != nil {
+		rsp.Body.Close()
+	}
+
+	if backendContext.Err() != nil {
+		return nil, contextError(backendContext)
+	}
+
+	err := fmt.Errorf("per-try timeout of %v exceeded", timeout)
+	if perr != nil && perr.err != nil {
+		err = fmt.Errorf("%v: %w", err, perr.err)
+	}
+
+	return nil, &proxyError{err: err, code: http.StatusGatewayTimeout}
+}
+
+// makes the backend request, and retries it according to the retry policy
+// of the route, within the limits of the global retry budget
+func (p *Proxy) makeBackendRequestWithRetries(ctx *context, backendContext stdlibcontext.Context, policy *retry.Policy) (*http.Response, *proxyError) {
+	p.retryBudget.deposit()
+
+	req := ctx.Request()
+	canRetry := policy.RetryMethod(req.Method) && (req.Body == nil || req.Body == http.NoBody)
+	for attempt := 1; ; attempt++ {
+		rsp, perr := p.makeBackendAttempt(ctx, backendContext, policy.PerTryTimeout)
+		if !canRetry || attempt >= policy.MaxAttempts || backendContext.Err() != nil {
+			return rsp, perr
+		}
+
+		if perr != nil {
+			if perr.handled || perr.code == 499 || perr.code == http.StatusBadRequest {
+				return nil, perr
+			}
+		} else if !policy.RetryStatus(rsp.StatusCode) {
+			return rsp, nil
+		}
+
+		if !p.retryBudget.withdraw() {
+			p.metrics.IncCounter("retry.budget.exhausted")
+			return rsp, perr
+		}
+
+		if rsp != nil {
+			rsp.Body.Close()
+		} else {
+			p.log.Debugf("retrying failed backend request: %v", perr)
+		}
+
+		if ctx.proxySpan != nil {
+			ctx.proxySpan.Finish()
+			ctx.proxySpan = nil
+		}
+
+		p.metrics.IncCounter("retries")
+		tracing.LogKV("retry", ctx.route.Id, req.Context())
+
+		if backoff := policy.BackoffFor(attempt); backoff > 0 {
+			t := time.NewTimer(backoff)
+			select {
+			case <-t.C:
+			case <-backendContext.Done():
+				t.Stop()
+				return nil, contextError(backendContext)
+			}
+		}
+	}
+}
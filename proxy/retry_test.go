@@ -0,0 +1,234 @@
+package proxy
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/zalando/skipper/metrics"
+	"github.com/zalando/skipper/metrics/metricstest"
+)
+
+// responds with the status codes in order, and then with 200
+func respondInOrder(codes ...int) http.HandlerFunc {
+	var n int32
+	return func(w http.ResponseWriter, r *http.Request) {
+		i := int(atomic.AddInt32(&n, 1)) - 1
+		if i < len(codes) {
+			w.WriteHeader(codes[i])
+			return
+		}
+
+		w.Write([]byte("ok"))
+	}
+}
+
+func TestRetryBudget(t *testing.T) {
+	if b := newRetryBudget(-1); b != nil || !b.withdraw() {
+		t.Fatal("failed to disable the budget")
+	}
+
+	b := newRetryBudget(0)
+	if b.ratio != DefaultRetryBudget {
+		t.Errorf("failed to set the default ratio: %v", b.ratio)
+	}
+
+	for i := 0; i < retryBudgetBurst; i++ {
+		if !b.withdraw() {
+			t.Fatalf("failed to allow the burst: %d", i)
+		}
+	}
+
+	if b.withdraw() {
+		t.Fatal("failed to exhaust the budget")
+	}
+
+	for i := 0; i < 5; i++ {
+		b.deposit()
+	}
+
+	if !b.withdraw() || b.withdraw() {
+		t.Error("failed to allow one retry after five requests")
+	}
+}
+
+func TestRetryPolicy(t *testing.T) {
+	for _, tt := range []struct {
+		name     string
+		filter   string
+		handler  http.HandlerFunc
+		method   string
+		status   int
+		requests int
+	}{{
+		name:     "retried until success",
+		filter:   `retryPolicy(3, "1ms", "0")`,
+		handler:  respondInOrder(503, 502),
+		status:   http.StatusOK,
+		requests: 3,
+	}, {
+		name:     "max attempts",
+		filter:   `retryPolicy(2, "1ms", "0")`,
+		handler:  respondInOrder(503, 503, 503),
+		status:   http.StatusServiceUnavailable,
+		requests: 2,
+	}, {
+		name:     "status not retried",
+		filter:   `retryPolicy(3, "0", "0", 503)`,
+		handler:  respondInOrder(502),
+		status:   http.StatusBadGateway,
+		requests: 1,
+	}, {
+		name:     "method not retried",
+		filter:   `retryPolicy(3, "0", "0")`,
+		handler:  respondInOrder(503),
+		method:   "DELETE",
+		status:   http.StatusServiceUnavailable,
+		requests: 1,
+	}, {
+		name:     "method retried",
+		filter:   `retryPolicy(3, "0", "0", "DELETE")`,
+		handler:  respondInOrder(503),
+		method:   "DELETE",
+		status:   http.StatusOK,
+		requests: 2,
+	}, {
+		name:   "per-try timeout",
+		filter: `retryPolicy(3, "0", "20ms")`,
+		handler: func() http.HandlerFunc {
+			var n int32
+			return func(w http.ResponseWriter, r *http.Request) {
+				if atomic.AddInt32(&n, 1) == 1 {
+					select {
+					case <-r.Context().Done():
+					case <-time.After(time.Second):
+					}
+
+					return
+				}
+
+				w.Write([]byte("ok"))
+			}
+		}(),
+		status:   http.StatusOK,
+		requests: 2,
+	}} {
+		t.Run(tt.name, func(t *testing.T) {
+			backend := newCountingBackend(tt.handler)
+			defer backend.Close()
+
+			tp, err := newTestProxy(fmt.Sprintf(`* -> %s -> "%s"`, tt.filter, backend.URL), FlagsNone)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			defer tp.close()
+
+			m := &metricstest.MockMetrics{}
+			tp.proxy.metrics = counterMetrics{Metrics: metrics.Void, mock: m}
+
+			ps := httptest.NewServer(tp.proxy)
+			defer ps.Close()
+
+			method := tt.method
+			if method == "" {
+				method = "GET"
+			}
+
+			req, _ := http.NewRequest(method, ps.URL, nil)
+			rsp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			b, _ := io.ReadAll(rsp.Body)
+			rsp.Body.Close()
+			if rsp.StatusCode != tt.status || tt.status == http.StatusOK && string(b) != "ok" {
+				t.Errorf("unexpected response: %d %s", rsp.StatusCode, b)
+			}
+
+			if backend.count() != tt.requests {
+				t.Errorf("unexpected number of backend requests: %d, expected: %d", backend.count(), tt.requests)
+			}
+
+			m.WithCounters(func(counters map[string]int64) {
+				if counters["retries"] != int64(tt.requests-1) {
+					t.Errorf("unexpected retries counter: %d", counters["retries"])
+				}
+			})
+		})
+	}
+}
+
+func TestRetryPolicyRequestBody(t *testing.T) {
+	backend := newCountingBackend(respondInOrder(503))
+	defer backend.Close()
+
+	tp, err := newTestProxy(fmt.Sprintf(`* -> retryPolicy(3, "0", "0", "POST") -> "%s"`, backend.URL), FlagsNone)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer tp.close()
+
+	ps := httptest.NewServer(tp.proxy)
+	defer ps.Close()
+
+	rsp, err := http.Post(ps.URL, "text/plain", strings.NewReader("hello"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rsp.Body.Close()
+	if rsp.StatusCode != http.StatusServiceUnavailable || backend.count() != 1 {
+		t.Errorf("unexpected retry of a request with a body: %d, %d", rsp.StatusCode, backend.count())
+	}
+}
+
+func TestRetryPolicyBudget(t *testing.T) {
+	backend := newCountingBackend(respond("failing", http.StatusServiceUnavailable))
+	defer backend.Close()
+
+	tp, err := newTestProxyWithParams(
+		fmt.Sprintf(`* -> retryPolicy(2, "0", "0") -> "%s"`, backend.URL),
+		Params{RetryBudget: 0.1},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer tp.close()
+
+	m := &metricstest.MockMetrics{}
+	tp.proxy.metrics = counterMetrics{Metrics: metrics.Void, mock: m}
+
+	ps := httptest.NewServer(tp.proxy)
+	defer ps.Close()
+
+	const requests = 20
+	for i := 0; i < requests; i++ {
+		rsp, err := http.Get(ps.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rsp.Body.Close()
+	}
+
+	// the burst is used up by the first 10 requests, after that, the
+	// 10 requests deposit 1 retry
+	if retries := backend.count() - requests; retries != retryBudgetBurst+1 {
+		t.Errorf("unexpected number of retries: %d", retries)
+	}
+
+	m.WithCounters(func(counters map[string]int64) {
+		if counters["retry.budget.exhausted"] != requests-retryBudgetBurst-1 {
+			t.Errorf("unexpected budget exhausted counter: %d", counters["retry.budget.exhausted"])
+		}
+	})
+}
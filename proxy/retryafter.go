@@ -0,0 +1,62 @@
+package proxy
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/zalando/skipper/routing"
+)
+
+const retryAfterBackoffKey = "retryafter.backoff"
+
+// parseRetryAfter parses the value of the Retry-After header, either in
+// delay seconds or as an HTTP date.
+func parseRetryAfter(v string, now time.Time) (time.Duration, bool) {
+	v = strings.TrimSpace(v)
+	if v == "" {
+		return 0, false
+	}
+
+	if s, err := strconv.ParseUint(v, 10, 32); err == nil {
+		return time.Duration(s) * time.Second, true
+	}
+
+	t, err := http.ParseTime(v)
+	if err != nil {
+		return 0, false
+	}
+
+	return t.Sub(now), true
+}
+
+// backoffEndpoint avoids the load balanced endpoint for the time requested
+// by the Retry-After header of its 429 or 503 response, bounded by the
+// configured maximum.
+func (p *Proxy) backoffEndpoint(endpoint *routing.LBEndpoint, rsp *http.Response) {
+	if p.retryAfterMaxBackoff <= 0 || endpoint == nil || endpoint.Metrics == nil {
+		return
+	}
+
+	if rsp.StatusCode != http.StatusTooManyRequests && rsp.StatusCode != http.StatusServiceUnavailable {
+		return
+	}
+
+	now := time.Now()
+	d, ok := parseRetryAfter(rsp.Header.Get("Retry-After"), now)
+	if !ok || d <= 0 {
+		return
+	}
+
+	if d > p.retryAfterMaxBackoff {
+		d = p.retryAfterMaxBackoff
+	}
+
+	until := now.Add(d)
+	if until.After(endpoint.Metrics.BackoffUntil()) {
+		endpoint.Metrics.SetBackoffUntil(until)
+		p.metrics.IncCounter(retryAfterBackoffKey)
+		p.log.Debugf("Backing off endpoint %s://%s for %v", endpoint.Scheme, endpoint.Host, d)
+	}
+}
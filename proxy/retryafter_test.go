@@ -0,0 +1,127 @@
+package proxy
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	for _, tt := range []struct {
+		value    string
+		expected time.Duration
+		ok       bool
+	}{
+		{"", 0, false},
+		{"120", 2 * time.Minute, true},
+		{" 0 ", 0, true},
+		{"-1", 0, false},
+		{"soon", 0, false},
+		{"Mon, 01 Jan 2024 12:00:30 GMT", 30 * time.Second, true},
+		{"Mon, 01 Jan 2024 11:59:00 GMT", -time.Minute, true},
+	} {
+		d, ok := parseRetryAfter(tt.value, now)
+		if ok != tt.ok || d != tt.expected {
+			t.Errorf("%q: unexpected result: %v, %v", tt.value, d, ok)
+		}
+	}
+}
+
+func TestRetryAfterBackoff(t *testing.T) {
+	limited := newCountingBackend(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "3600")
+		w.WriteHeader(http.StatusTooManyRequests)
+	})
+	defer limited.Close()
+
+	ok := newCountingBackend(respond("ok", http.StatusOK))
+	defer ok.Close()
+
+	tp, err := newTestProxy(fmt.Sprintf(`* -> <roundRobin, "%s", "%s">`, limited.URL, ok.URL), FlagsNone)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer tp.close()
+
+	const maxBackoff = 300 * time.Millisecond
+	tp.proxy.retryAfterMaxBackoff = maxBackoff
+
+	ps := httptest.NewServer(tp.proxy)
+	defer ps.Close()
+
+	get := func() int {
+		rsp, err := http.Get(ps.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		io.Copy(io.Discard, rsp.Body)
+		rsp.Body.Close()
+		return rsp.StatusCode
+	}
+
+	// the round robin starts at a random endpoint
+	for limited.count() == 0 {
+		get()
+	}
+
+	start := time.Now()
+	for i := 0; i < 10; i++ {
+		if status := get(); status != http.StatusOK && time.Since(start) < maxBackoff {
+			t.Fatalf("failed to back off the endpoint, status: %d", status)
+		}
+	}
+
+	if limited.count() != 1 && time.Since(start) < maxBackoff {
+		t.Fatalf("unexpected requests to the backed off endpoint: %d", limited.count())
+	}
+
+	// the backoff is bounded
+	time.Sleep(maxBackoff)
+	for i := 0; i < 4; i++ {
+		get()
+	}
+
+	if limited.count() < 2 {
+		t.Error("failed to send requests to the endpoint after the backoff")
+	}
+}
+
+func TestRetryAfterBackoffDisabled(t *testing.T) {
+	limited := newCountingBackend(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "3600")
+		w.WriteHeader(http.StatusServiceUnavailable)
+	})
+	defer limited.Close()
+
+	ok := newCountingBackend(respond("ok", http.StatusOK))
+	defer ok.Close()
+
+	tp, err := newTestProxy(fmt.Sprintf(`* -> <roundRobin, "%s", "%s">`, limited.URL, ok.URL), FlagsNone)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer tp.close()
+
+	ps := httptest.NewServer(tp.proxy)
+	defer ps.Close()
+
+	for i := 0; i < 6; i++ {
+		rsp, err := http.Get(ps.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rsp.Body.Close()
+	}
+
+	if limited.count() != 3 {
+		t.Errorf("unexpected requests to the endpoint: %d", limited.count())
+	}
+}
@@ -0,0 +1,213 @@
+package proxy
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+type countingBackend struct {
+	*httptest.Server
+	requests int32
+}
+
+func newCountingBackend(h http.HandlerFunc) *countingBackend {
+	b := &countingBackend{}
+	b.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&b.requests, 1)
+		h(w, r)
+	}))
+
+	return b
+}
+
+func (b *countingBackend) count() int {
+	return int(atomic.LoadInt32(&b.requests))
+}
+
+func respond(name string, status int, header ...string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		for i := 0; i+1 < len(header); i += 2 {
+			w.Header().Set(header[i], header[i+1])
+		}
+
+		w.WriteHeader(status)
+		w.Write([]byte(name))
+	}
+}
+
+func TestRetryOnResponse(t *testing.T) {
+	secondary := newCountingBackend(respond("secondary", http.StatusOK))
+	defer secondary.Close()
+
+	shard := newCountingBackend(respond("shard", http.StatusOK))
+	defer shard.Close()
+
+	for _, tt := range []struct {
+		name      string
+		primary   http.HandlerFunc
+		filter    string
+		body      string
+		expected  string
+		secondary int
+		shard     int
+	}{{
+		name:     "no match",
+		primary:  respond("primary", http.StatusOK),
+		filter:   fmt.Sprintf(`retryOnResponse(503, "%s")`, secondary.URL),
+		expected: "primary",
+	}, {
+		name:      "status to secondary",
+		primary:   respond("primary", http.StatusServiceUnavailable),
+		filter:    fmt.Sprintf(`retryOnResponse(503, "%s")`, secondary.URL),
+		expected:  "secondary",
+		secondary: 1,
+	}, {
+		name:     "header to shard",
+		primary:  respond("primary", http.StatusMisdirectedRequest, "X-Shard-Moved", shard.URL),
+		filter:   fmt.Sprintf(`retryOnResponse("X-Shard-Moved", "%s", "%s")`, secondary.URL, shard.URL),
+		expected: "shard",
+		shard:    1,
+	}, {
+		name:     "header to not allowed host",
+		primary:  respond("primary", http.StatusMisdirectedRequest, "X-Shard-Moved", shard.URL),
+		filter:   fmt.Sprintf(`retryOnResponse("X-Shard-Moved", "%s")`, secondary.URL),
+		expected: "primary",
+	}, {
+		name:      "header without url",
+		primary:   respond("primary", http.StatusOK, "X-Shard-Moved", "true"),
+		filter:    fmt.Sprintf(`retryOnResponse("X-Shard-Moved", "%s")`, secondary.URL),
+		expected:  "secondary",
+		secondary: 1,
+	}, {
+		name:     "request with body",
+		primary:  respond("primary", http.StatusServiceUnavailable),
+		filter:   fmt.Sprintf(`retryOnResponse(503, "%s")`, secondary.URL),
+		body:     "foo",
+		expected: "primary",
+	}, {
+		name:     "retried once",
+		primary:  respond("primary", http.StatusOK, "X-Shard-Moved", "true"),
+		filter:   `retryOnResponse("X-Shard-Moved")`,
+		expected: "primary",
+	}} {
+		t.Run(tt.name, func(t *testing.T) {
+			primary := newCountingBackend(tt.primary)
+			defer primary.Close()
+
+			secondaryBefore, shardBefore := secondary.count(), shard.count()
+			tp, err := newTestProxy(fmt.Sprintf(`* -> %s -> "%s"`, tt.filter, primary.URL), FlagsNone)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			defer tp.close()
+
+			ps := httptest.NewServer(tp.proxy)
+			defer ps.Close()
+
+			method, body := "GET", io.Reader(nil)
+			if tt.body != "" {
+				method, body = "POST", strings.NewReader(tt.body)
+			}
+
+			req, err := http.NewRequest(method, ps.URL, body)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			rsp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			defer rsp.Body.Close()
+			b, err := io.ReadAll(rsp.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if string(b) != tt.expected {
+				t.Errorf("unexpected response: %s, expected: %s", b, tt.expected)
+			}
+
+			if n := secondary.count() - secondaryBefore; n != tt.secondary {
+				t.Errorf("unexpected number of secondary requests: %d, expected: %d", n, tt.secondary)
+			}
+
+			if n := shard.count() - shardBefore; n != tt.shard {
+				t.Errorf("unexpected number of shard requests: %d, expected: %d", n, tt.shard)
+			}
+
+			if tt.name == "retried once" && primary.count() != 2 {
+				t.Errorf("unexpected number of primary requests: %d", primary.count())
+			}
+		})
+	}
+}
+
+func TestRetryOnResponseLoadBalanced(t *testing.T) {
+	moved := newCountingBackend(respond("moved", http.StatusMisdirectedRequest))
+	defer moved.Close()
+
+	owner := newCountingBackend(respond("owner", http.StatusOK))
+	defer owner.Close()
+
+	tp, err := newTestProxy(fmt.Sprintf(`* -> retryOnResponse(421) -> <roundRobin, "%s", "%s">`, moved.URL, owner.URL), FlagsNone)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer tp.close()
+
+	ps := httptest.NewServer(tp.proxy)
+	defer ps.Close()
+
+	for i := 0; i < 4; i++ {
+		rsp, err := http.Get(ps.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		b, _ := io.ReadAll(rsp.Body)
+		rsp.Body.Close()
+		if string(b) != "owner" {
+			t.Errorf("unexpected response: %s", b)
+		}
+	}
+}
+
+func TestRetryOnResponseHeaderToEndpoint(t *testing.T) {
+	owner := newCountingBackend(respond("owner", http.StatusOK))
+	defer owner.Close()
+
+	moved := newCountingBackend(respond("moved", http.StatusMisdirectedRequest, "X-Shard-Moved", owner.URL))
+	defer moved.Close()
+
+	tp, err := newTestProxy(fmt.Sprintf(`* -> retryOnResponse("X-Shard-Moved") -> <roundRobin, "%s", "%s">`, moved.URL, owner.URL), FlagsNone)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer tp.close()
+
+	ps := httptest.NewServer(tp.proxy)
+	defer ps.Close()
+
+	for i := 0; i < 4; i++ {
+		rsp, err := http.Get(ps.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		b, _ := io.ReadAll(rsp.Body)
+		rsp.Body.Close()
+		if string(b) != "owner" {
+			t.Errorf("unexpected response: %s", b)
+		}
+	}
+}
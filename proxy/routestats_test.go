@@ -0,0 +1,90 @@
+package proxy
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/zalando/skipper/circuit"
+	"github.com/zalando/skipper/filters/builtin"
+	"github.com/zalando/skipper/logging/loggingtest"
+	"github.com/zalando/skipper/routing"
+	"github.com/zalando/skipper/routing/testdataclient"
+)
+
+func TestRouteStats(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/fail" {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer backend.Close()
+
+	dc, err := testdataclient.NewDoc(fmt.Sprintf(`
+		ok: Path("/ok") -> "%s";
+		fail: Path("/fail") -> consecutiveBreaker(3) -> "%s";
+		loop: Path("/loop") -> setPath("/ok") -> <loopback>;
+	`, backend.URL, backend.URL))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tl := loggingtest.New()
+	defer tl.Close()
+
+	rt := routing.New(routing.Options{
+		FilterRegistry: builtin.MakeRegistry(),
+		DataClients:    []routing.DataClient{dc},
+		PollTimeout:    sourcePollTimeout,
+		Log:            tl,
+	})
+	defer rt.Close()
+
+	p := WithParams(Params{Routing: rt, CircuitBreakers: circuit.NewRegistry()})
+	defer p.Close()
+
+	if err := tl.WaitFor("route settings applied", time.Second); err != nil {
+		t.Fatal(err)
+	}
+
+	ps := httptest.NewServer(p)
+	defer ps.Close()
+
+	for _, path := range []string{"/ok", "/ok", "/fail", "/loop"} {
+		rsp, err := http.Get(ps.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rsp.Body.Close()
+	}
+
+	// the requests are counted after the response was sent
+	checkStatus := func(path string, expected routing.RouteStatus) {
+		t.Helper()
+		r, _ := rt.Route(&http.Request{URL: &url.URL{Path: path}})
+		if r == nil {
+			t.Fatalf("route not found for %s", path)
+		}
+
+		var st routing.RouteStatus
+		for i := 0; i < 100; i++ {
+			if st = r.Status(); st == expected {
+				return
+			}
+
+			time.Sleep(10 * time.Millisecond)
+		}
+
+		t.Errorf("unexpected status: %+v, expected: %+v", st, expected)
+	}
+
+	checkStatus("/ok", routing.RouteStatus{Id: "ok", Requests: 2})
+	checkStatus("/fail", routing.RouteStatus{Id: "fail", Breaker: "closed", Requests: 1, Errors: 1, ErrorRate: 1})
+
+	// the request is counted only for the route matched first
+	checkStatus("/loop", routing.RouteStatus{Id: "loop", Requests: 1})
+}
@@ -3,15 +3,20 @@ package proxy_test
 import (
 	"io"
 	"net/http"
+	"net/http/httptest"
 	"testing"
 	"time"
 
 	"github.com/zalando/skipper/eskip"
 	"github.com/zalando/skipper/filters"
 	"github.com/zalando/skipper/filters/builtin"
+	"github.com/zalando/skipper/logging/loggingtest"
+	"github.com/zalando/skipper/predicates/listener"
 	"github.com/zalando/skipper/predicates/tee"
+	"github.com/zalando/skipper/proxy"
 	"github.com/zalando/skipper/proxy/proxytest"
 	"github.com/zalando/skipper/routing"
+	"github.com/zalando/skipper/routing/testdataclient"
 )
 
 // test filter used in TestRequestURIClonedOnSplit
@@ -60,3 +65,83 @@ func TestRequestURIClonedOnSplit(t *testing.T) {
 		t.Fatal("test timeout")
 	}
 }
+
+// test filter used in TestListenerPreservedOnSplit
+type matchedFilter chan string
+
+type matchedRoute struct {
+	matched matchedFilter
+	name    string
+}
+
+func (f matchedFilter) Name() string { return "matched" }
+func (f matchedFilter) CreateFilter(args []interface{}) (filters.Filter, error) {
+	name, _ := args[0].(string)
+	return matchedRoute{matched: f, name: name}, nil
+}
+
+func (r matchedRoute) Request(filters.FilterContext)  { r.matched <- r.name }
+func (r matchedRoute) Response(filters.FilterContext) {}
+
+func TestListenerPreservedOnSplit(t *testing.T) {
+	l := loggingtest.New()
+	defer l.Close()
+
+	dc, err := testdataclient.NewDoc(`
+		main: Path("/foo") -> teeLoopback("test") -> <shunt>;
+		shadowInternal: Path("/foo") && Tee("test") && Listener("internal") -> matched("internal") -> <shunt>;
+		shadowOther: Path("/foo") && Tee("test") -> matched("other") -> <shunt>;
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	matched := make(matchedFilter, 1)
+	fr := builtin.MakeRegistry()
+	fr.Register(matched)
+
+	rt := routing.New(routing.Options{
+		FilterRegistry: fr,
+		DataClients:    []routing.DataClient{dc},
+		Predicates:     []routing.PredicateSpec{tee.New(), listener.New()},
+		Log:            l,
+	})
+	defer rt.Close()
+
+	p := proxy.WithParams(proxy.Params{Routing: rt, CloseIdleConnsPeriod: -time.Second})
+	defer p.Close()
+
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p.ServeHTTP(w, r.WithContext(listener.NewContext(r.Context(), r.Header.Get("X-Listener"))))
+	}))
+	defer s.Close()
+
+	if err := l.WaitFor("route settings applied", time.Second); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{"internal", "public"} {
+		req, _ := http.NewRequest("GET", s.URL+"/foo", nil)
+		req.Header.Set("X-Listener", name)
+		rsp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rsp.Body.Close()
+
+		expected := "internal"
+		if name != "internal" {
+			expected = "other"
+		}
+
+		select {
+		case m := <-matched:
+			if m != expected {
+				t.Errorf("%s: unexpected shadow route matched: %s", name, m)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("%s: test timeout", name)
+		}
+	}
+}
@@ -0,0 +1,211 @@
+package proxy
+
+import (
+	"bytes"
+	"io"
+	"mime"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	sseStreamsOpenKey = "sse.streams.open"
+	sseKeepAlivesKey  = "sse.keepalives"
+)
+
+var sseKeepAlive = []byte(": keep-alive\n\n")
+
+// eventStream copies a Server-Sent Events response body, and flushes
+// the response writer only after complete events, instead of after every
+// write. The incomplete events are buffered until the rest arrives, up to
+// the size of the stream buffer. When the keep-alive interval is set, a
+// comment is written to the client between the events, when the backend
+// didn't send anything for the interval.
+type eventStream struct {
+	to        flushedResponseWriter
+	keepAlive time.Duration
+	onKeep    func()
+	pending   []byte
+	written   int64
+}
+
+type sseChunk struct {
+	b   []byte
+	err error
+}
+
+func isEventStream(rsp *http.Response) bool {
+	mt, _, err := mime.ParseMediaType(rsp.Header.Get("Content-Type"))
+	return err == nil && mt == "text/event-stream"
+}
+
+// sseEventEnd returns the position after the last blank line in b, or -1
+// when there is none. The lines can be terminated by CRLF, LF or CR.
+func sseEventEnd(b []byte) int {
+	end := -1
+	for _, sep := range []string{"\n\n", "\r\r", "\n\r\n"} {
+		if i := bytes.LastIndex(b, []byte(sep)); i >= 0 && i+len(sep) > end {
+			end = i + len(sep)
+		}
+	}
+
+	return end
+}
+
+func (s *eventStream) write(b []byte) error {
+	n, err := s.to.Write(b)
+	s.written += int64(n)
+	return err
+}
+
+// handle writes the complete events of the received bytes, and keeps the
+// rest pending
+func (s *eventStream) handle(b []byte) error {
+	s.pending = append(s.pending, b...)
+	end := sseEventEnd(s.pending)
+	complete := end >= 0
+	if !complete {
+		if len(s.pending) < proxyBufferSize {
+			return nil
+		}
+
+		// too long event, written without flushing
+		end = len(s.pending)
+	}
+
+	if err := s.write(s.pending[:end]); err != nil {
+		return err
+	}
+
+	if complete {
+		s.to.Flush()
+	}
+
+	s.pending = append(s.pending[:0], s.pending[end:]...)
+	return nil
+}
+
+func (s *eventStream) finish(err error) (int64, error) {
+	if len(s.pending) > 0 {
+		if werr := s.write(s.pending); werr != nil && err == nil {
+			err = werr
+		}
+
+		s.to.Flush()
+	}
+
+	if err == io.EOF {
+		err = nil
+	}
+
+	return s.written, err
+}
+
+func (s *eventStream) copy(from io.Reader) (int64, error) {
+	if s.keepAlive <= 0 {
+		b := streamBuffers.get()
+		defer streamBuffers.put(b)
+
+		for {
+			n, err := from.Read(*b)
+			if n > 0 {
+				if werr := s.handle((*b)[:n]); werr != nil {
+					return s.written, werr
+				}
+			}
+
+			if err != nil {
+				return s.finish(err)
+			}
+		}
+	}
+
+	// the body is read in a separate goroutine, to be able to send the
+	// keep-alive comments while waiting for the backend. The buffer is
+	// reused only after the received chunk was handled. It is not taken
+	// from the pool, because the goroutine may still be reading into it
+	// after returning.
+	b := make([]byte, proxyBufferSize)
+	chunks := make(chan sseChunk)
+	next := make(chan struct{})
+	quit := make(chan struct{})
+	defer close(quit)
+
+	go func() {
+		for {
+			n, err := from.Read(b)
+			select {
+			case chunks <- sseChunk{b: b[:n], err: err}:
+			case <-quit:
+				return
+			}
+
+			if err != nil {
+				return
+			}
+
+			select {
+			case <-next:
+			case <-quit:
+				return
+			}
+		}
+	}()
+
+	timer := time.NewTimer(s.keepAlive)
+	defer timer.Stop()
+
+	for {
+		select {
+		case c := <-chunks:
+			if len(c.b) > 0 {
+				if err := s.handle(c.b); err != nil {
+					return s.written, err
+				}
+			}
+
+			if c.err != nil {
+				return s.finish(c.err)
+			}
+
+			next <- struct{}{}
+		case <-timer.C:
+			// only between the events
+			if len(s.pending) == 0 {
+				if _, err := s.to.Write(sseKeepAlive); err != nil {
+					return s.written, err
+				}
+
+				s.to.Flush()
+				s.onKeep()
+			}
+		}
+
+		if !timer.Stop() {
+			select {
+			case <-timer.C:
+			default:
+			}
+		}
+
+		timer.Reset(s.keepAlive)
+	}
+}
+
+// copyEventStream copies a Server-Sent Events response, tracking the
+// number of the open streams.
+func (p *Proxy) copyEventStream(to flushedResponseWriter, from io.Reader) (int64, error) {
+	p.metrics.UpdateGauge(sseStreamsOpenKey, float64(atomic.AddInt64(&p.sseStreams, 1)))
+	defer func() {
+		p.metrics.UpdateGauge(sseStreamsOpenKey, float64(atomic.AddInt64(&p.sseStreams, -1)))
+	}()
+
+	s := &eventStream{
+		to:        to,
+		keepAlive: p.sseKeepAlive,
+		onKeep:    func() { p.metrics.IncCounter(sseKeepAlivesKey) },
+	}
+
+	return s.copy(from)
+}
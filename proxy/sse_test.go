@@ -0,0 +1,142 @@
+package proxy
+
+import (
+	"bufio"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/zalando/skipper/metrics"
+	"github.com/zalando/skipper/metrics/metricstest"
+)
+
+type gaugeMetrics struct {
+	counterMetrics
+}
+
+func (m gaugeMetrics) UpdateGauge(key string, v float64) { m.mock.UpdateGauge(key, v) }
+
+type flushRecorder struct {
+	*httptest.ResponseRecorder
+	flushed []string
+}
+
+func (r *flushRecorder) Flush() {
+	r.flushed = append(r.flushed, r.Body.String())
+}
+
+func TestSSEEventEnd(t *testing.T) {
+	for _, tt := range []struct {
+		input    string
+		expected int
+	}{
+		{"data: foo", -1},
+		{"data: foo\n", -1},
+		{"data: foo\n\n", 11},
+		{"data: foo\n\ndata: bar", 11},
+		{"data: foo\r\n\r\ndata: bar", 13},
+		{"data: foo\r\rdata: bar", 11},
+		{"data: foo\n\ndata: bar\n\n", 22},
+	} {
+		if end := sseEventEnd([]byte(tt.input)); end != tt.expected {
+			t.Errorf("%q: unexpected end: %d, expected: %d", tt.input, end, tt.expected)
+		}
+	}
+}
+
+func TestEventStreamFlushesEvents(t *testing.T) {
+	w := &flushRecorder{ResponseRecorder: httptest.NewRecorder()}
+	s := &eventStream{to: w}
+
+	for _, chunk := range []string{"data: f", "oo\n", "\ndata: bar\n\nda", "ta: baz\n\n"} {
+		if err := s.handle([]byte(chunk)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// the events completed by the same chunk are flushed together
+	expected := []string{
+		"data: foo\n\ndata: bar\n\n",
+		"data: foo\n\ndata: bar\n\ndata: baz\n\n",
+	}
+
+	if strings.Join(w.flushed, "|") != strings.Join(expected, "|") {
+		t.Errorf("unexpected flushes: %q", w.flushed)
+	}
+
+	s.handle([]byte("data: incomplete"))
+	if n, err := s.finish(nil); err != nil || n != int64(w.Body.Len()) {
+		t.Errorf("failed to finish: %d, %v", n, err)
+	}
+
+	if !strings.HasSuffix(w.Body.String(), "data: incomplete") {
+		t.Errorf("failed to write the incomplete event: %q", w.Body.String())
+	}
+}
+
+func TestEventStreamKeepAlive(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream; charset=utf-8")
+		w.Write([]byte("data: foo\n\n"))
+		w.(http.Flusher).Flush()
+		time.Sleep(300 * time.Millisecond)
+		w.Write([]byte("data: bar\n\n"))
+	}))
+	defer backend.Close()
+
+	tp, err := newTestProxyWithParams(fmt.Sprintf(`* -> "%s"`, backend.URL), Params{SSEKeepAliveInterval: 50 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer tp.close()
+
+	m := &metricstest.MockMetrics{}
+	tp.proxy.metrics = gaugeMetrics{counterMetrics{Metrics: metrics.Void, mock: m}}
+
+	ps := httptest.NewServer(tp.proxy)
+	defer ps.Close()
+
+	rsp, err := http.Get(ps.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer rsp.Body.Close()
+
+	r := bufio.NewReader(rsp.Body)
+	first, err := r.ReadString('\n')
+	if err != nil || first != "data: foo\n" {
+		t.Fatalf("unexpected first line: %q, %v", first, err)
+	}
+
+	if v, _ := m.Gauge(sseStreamsOpenKey); v != 1 {
+		t.Errorf("unexpected open streams: %v", v)
+	}
+
+	var keepAlives int
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if line == ": keep-alive\n" {
+			keepAlives++
+		}
+
+		if line == "data: bar\n" {
+			break
+		}
+	}
+
+	if keepAlives == 0 {
+		t.Error("failed to send keep-alive comments")
+	}
+
+	rsp.Body.Close()
+	waitForGauge(t, m, sseStreamsOpenKey, 0)
+}
@@ -0,0 +1,80 @@
+package proxy
+
+import (
+	stdlibcontext "context"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/zalando/skipper/filters"
+)
+
+// idleTimeoutBody cancels the backend request, when the backend doesn't
+// send anything for the idle timeout
+type idleTimeoutBody struct {
+	body    io.ReadCloser
+	timeout time.Duration
+	timer   *time.Timer
+}
+
+// tells whether the request accepts a Server-Sent Events response
+func acceptsEventStream(r *http.Request) bool {
+	for _, h := range r.Header.Values("Accept") {
+		for _, v := range strings.Split(h, ",") {
+			if mt, _, err := mime.ParseMediaType(v); err == nil && mt == "text/event-stream" {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// isStreaming tells whether the request expects a long-lived response,
+// either because its route is marked with the streaming filter, or,
+// when the detection is enabled, because it accepts Server-Sent Events.
+func (p *Proxy) isStreaming(ctx *context) bool {
+	if s, _ := ctx.StateBag()[filters.StreamingKey].(bool); s {
+		return true
+	}
+
+	return p.detectStreaming && acceptsEventStream(ctx.request)
+}
+
+// limitStreamIdle applies the idle timeout to the response body of the
+// streaming requests
+func (p *Proxy) limitStreamIdle(ctx *context, rsp *http.Response) {
+	if !ctx.streaming || ctx.cancelStream == nil || rsp.Body == nil {
+		return
+	}
+
+	rsp.Body = &idleTimeoutBody{
+		body:    rsp.Body,
+		timeout: p.streamingIdleTimeout,
+		timer:   time.AfterFunc(p.streamingIdleTimeout, ctx.cancelStream),
+	}
+}
+
+// returns the context of the streaming backend requests, that can be
+// canceled by the idle timeout
+func (p *Proxy) streamContext(ctx *context, backendContext stdlibcontext.Context) (stdlibcontext.Context, stdlibcontext.CancelFunc) {
+	if !ctx.streaming || p.streamingIdleTimeout <= 0 {
+		return backendContext, nil
+	}
+
+	backendContext, ctx.cancelStream = stdlibcontext.WithCancel(backendContext)
+	return backendContext, ctx.cancelStream
+}
+
+func (b *idleTimeoutBody) Read(p []byte) (int, error) {
+	n, err := b.body.Read(p)
+	b.timer.Reset(b.timeout)
+	return n, err
+}
+
+func (b *idleTimeoutBody) Close() error {
+	b.timer.Stop()
+	return b.body.Close()
+}
@@ -0,0 +1,151 @@
+package proxy
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestAcceptsEventStream(t *testing.T) {
+	for _, tc := range []struct {
+		accept   []string
+		expected bool
+	}{
+		{accept: nil, expected: false},
+		{accept: []string{"text/html"}, expected: false},
+		{accept: []string{"text/event-stream"}, expected: true},
+		{accept: []string{"application/json, text/event-stream;q=0.9"}, expected: true},
+		{accept: []string{"text/html", "text/event-stream"}, expected: true},
+	} {
+		r := &http.Request{Header: http.Header{"Accept": tc.accept}}
+		if got := acceptsEventStream(r); got != tc.expected {
+			t.Errorf("%v: expected %v, got %v", tc.accept, tc.expected, got)
+		}
+	}
+}
+
+func TestStreamingTimeouts(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Query().Get("scenario") {
+		case "slow-headers":
+			time.Sleep(150 * time.Millisecond)
+			w.Write([]byte("done"))
+		case "slow-body":
+			// longer in total than the backend timeout, but never idle
+			// for longer than the idle timeout
+			for i := 0; i < 5; i++ {
+				w.Write([]byte("data"))
+				w.(http.Flusher).Flush()
+				time.Sleep(40 * time.Millisecond)
+			}
+		case "stalled":
+			w.Write([]byte("data"))
+			w.(http.Flusher).Flush()
+			time.Sleep(400 * time.Millisecond)
+			w.Write([]byte("late"))
+		}
+	}))
+	defer backend.Close()
+
+	doc := fmt.Sprintf(`
+		regular: Path("/regular") -> backendTimeout("100ms") -> "%s";
+		streaming: Path("/streaming") -> streaming() -> backendTimeout("100ms") -> "%s";
+	`, backend.URL, backend.URL)
+
+	tp, err := newTestProxyWithParams(doc, Params{
+		ResponseHeaderTimeout: 50 * time.Millisecond,
+		DetectStreaming:       true,
+		StreamingIdleTimeout:  100 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tp.close()
+
+	ps := httptest.NewServer(tp.proxy)
+	defer ps.Close()
+
+	for _, tc := range []struct {
+		name      string
+		path      string
+		scenario  string
+		accept    string
+		status    int
+		body      string
+		truncated bool
+	}{{
+		name:     "regular response header timeout",
+		path:     "/regular",
+		scenario: "slow-headers",
+		status:   http.StatusGatewayTimeout,
+	}, {
+		name:     "streaming response header timeout",
+		path:     "/streaming",
+		scenario: "slow-headers",
+		status:   http.StatusOK,
+		body:     "done",
+	}, {
+		name:     "detected response header timeout",
+		path:     "/regular",
+		scenario: "slow-headers",
+		accept:   "text/event-stream",
+		status:   http.StatusOK,
+		body:     "done",
+	}, {
+		name:      "regular backend timeout",
+		path:      "/regular",
+		scenario:  "slow-body",
+		status:    http.StatusOK,
+		truncated: true,
+	}, {
+		name:     "streaming idle timeout not reached",
+		path:     "/streaming",
+		scenario: "slow-body",
+		status:   http.StatusOK,
+		body:     "datadatadatadatadata",
+	}, {
+		name:     "streaming idle timeout",
+		path:     "/streaming",
+		scenario: "stalled",
+		status:   http.StatusOK,
+		body:     "data",
+	}} {
+		t.Run(tc.name, func(t *testing.T) {
+			req, err := http.NewRequest("GET", ps.URL+tc.path+"?scenario="+tc.scenario, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if tc.accept != "" {
+				req.Header.Set("Accept", tc.accept)
+			}
+
+			rsp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			defer rsp.Body.Close()
+			if rsp.StatusCode != tc.status {
+				t.Fatalf("expected %d, got %d", tc.status, rsp.StatusCode)
+			}
+
+			if tc.status != http.StatusOK {
+				return
+			}
+
+			// the body may be truncated by the timeouts
+			b, _ := io.ReadAll(rsp.Body)
+			if tc.truncated {
+				if len(b) >= len("datadatadatadatadata") {
+					t.Errorf("expected truncated body, got %q", b)
+				}
+			} else if string(b) != tc.body {
+				t.Errorf("expected body %q, got %q", tc.body, b)
+			}
+		})
+	}
+}
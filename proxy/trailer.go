@@ -0,0 +1,48 @@
+package proxy
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// announceTrailers declares the trailers of the backend response in the
+// Trailer header of the client response. The backend response can have
+// a content length, e.g. over HTTP/2, but the trailers can be sent to
+// the HTTP/1.1 clients only with the chunked encoding, so the content
+// length is removed.
+func announceTrailers(h http.Header, rsp *http.Response) {
+	if len(rsp.Trailer) == 0 {
+		return
+	}
+
+	keys := make([]string, 0, len(rsp.Trailer))
+	for k := range rsp.Trailer {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+	h["Trailer"] = []string{strings.Join(keys, ", ")}
+	h.Del("Content-Length")
+}
+
+// copies the trailers received from the backend to the response writer
+func copyTrailer(to, from http.Header) {
+	for k, v := range from {
+		to[http.TrailerPrefix+k] = v
+	}
+}
+
+// acceptsTrailers tells whether the client accepts the response trailers,
+// with the TE: trailers request header
+func acceptsTrailers(h http.Header) bool {
+	for _, v := range h.Values("Te") {
+		for _, t := range strings.Split(v, ",") {
+			if t, _, _ := strings.Cut(t, ";"); strings.EqualFold(strings.TrimSpace(t), "trailers") {
+				return true
+			}
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,127 @@
+package proxy
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestResponseTrailers(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Trailer", "X-Checksum, X-Count")
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("hello"))
+		w.(http.Flusher).Flush()
+		w.Header().Set("X-Checksum", "abc")
+		w.Header().Set("X-Count", "1")
+	}))
+	defer backend.Close()
+
+	tp, err := newTestProxy(fmt.Sprintf(`* -> "%s"`, backend.URL), FlagsNone)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tp.close()
+
+	ps := httptest.NewServer(tp.proxy)
+	defer ps.Close()
+
+	rsp, err := http.Get(ps.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rsp.Body.Close()
+
+	// the announced trailers are known before reading the body
+	for _, k := range []string{"X-Checksum", "X-Count"} {
+		if _, ok := rsp.Trailer[k]; !ok {
+			t.Errorf("trailer not announced: %s", k)
+		}
+	}
+
+	b, err := io.ReadAll(rsp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(b) != "hello" {
+		t.Errorf("unexpected body: %s", b)
+	}
+
+	if v := rsp.Trailer.Get("X-Checksum"); v != "abc" {
+		t.Errorf("unexpected trailer value: %q", v)
+	}
+
+	if v := rsp.Trailer.Get("X-Count"); v != "1" {
+		t.Errorf("unexpected trailer value: %q", v)
+	}
+}
+
+func TestRequestTrailers(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, err := io.ReadAll(r.Body); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		w.Header().Set("X-Te", r.Header.Get("Te"))
+		w.Header().Set("X-Request-Checksum", r.Trailer.Get("X-Checksum"))
+	}))
+	defer backend.Close()
+
+	tp, err := newTestProxy(fmt.Sprintf(`* -> "%s"`, backend.URL), FlagsNone)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tp.close()
+
+	ps := httptest.NewServer(tp.proxy)
+	defer ps.Close()
+
+	// the body is sent chunked, because the length is unknown
+	req, err := http.NewRequest("POST", ps.URL, io.MultiReader(strings.NewReader("hello")))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req.Header.Set("Te", "trailers")
+	req.Trailer = http.Header{"X-Checksum": []string{"abc"}}
+
+	rsp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rsp.Body.Close()
+
+	if rsp.StatusCode != http.StatusOK {
+		t.Fatalf("unexpected status: %d", rsp.StatusCode)
+	}
+
+	if v := rsp.Header.Get("X-Request-Checksum"); v != "abc" {
+		t.Errorf("request trailer not forwarded: %q", v)
+	}
+
+	if v := rsp.Header.Get("X-Te"); v != "trailers" {
+		t.Errorf("TE: trailers not forwarded: %q", v)
+	}
+}
+
+func TestAcceptsTrailers(t *testing.T) {
+	for _, tc := range []struct {
+		te       []string
+		expected bool
+	}{
+		{nil, false},
+		{[]string{"gzip"}, false},
+		{[]string{"trailers"}, true},
+		{[]string{"gzip, Trailers"}, true},
+		{[]string{"gzip;q=0.5", "trailers;q=1"}, true},
+	} {
+		if got := acceptsTrailers(http.Header{"Te": tc.te}); got != tc.expected {
+			t.Errorf("%v: expected %v, got %v", tc.te, tc.expected, got)
+		}
+	}
+}
@@ -0,0 +1,162 @@
+package proxy
+
+import (
+	stdlibcontext "context"
+	"crypto/tls"
+	"net"
+	"net/http"
+	"sync"
+
+	"github.com/zalando/skipper/filters"
+	"github.com/zalando/skipper/filters/transport"
+)
+
+// routeTransports creates and keeps the transports of the routes
+// overriding the transport settings with the backendTransport filter. The
+// routes with the same settings share the transport, and the gRPC
+// transport derived from it.
+type routeTransports struct {
+	base *http.Transport
+	dial dialFunc
+	wrap func(http.RoundTripper) http.RoundTripper
+
+	mu          sync.Mutex
+	transports  map[transport.Settings]*http.Transport
+	wrapped     map[transport.Settings]http.RoundTripper
+	grpc        map[transport.Settings]*grpcTransport
+	grpcWrapped map[transport.Settings]http.RoundTripper
+}
+
+func newRouteTransports(tr *http.Transport, dial dialFunc, wrap func(http.RoundTripper) http.RoundTripper) *routeTransports {
+	return &routeTransports{
+		base:        tr,
+		dial:        dial,
+		wrap:        wrap,
+		transports:  make(map[transport.Settings]*http.Transport),
+		wrapped:     make(map[transport.Settings]http.RoundTripper),
+		grpc:        make(map[transport.Settings]*grpcTransport),
+		grpcWrapped: make(map[transport.Settings]http.RoundTripper),
+	}
+}
+
+// returns the transport settings of the route, with the client
+// certificate of the route, and the response header timeout of the
+// streaming requests
+func (p *Proxy) transportSettings(ctx *context) (transport.Settings, bool) {
+	s, ok := ctx.StateBag()[filters.BackendTransportKey].(transport.Settings)
+	if c, hasCert := ctx.StateBag()[filters.BackendClientCertificateKey].(*transport.ClientCertificate); hasCert {
+		s.ClientCertificate = c
+		ok = true
+	}
+
+	if !ctx.streaming {
+		return s, ok
+	}
+
+	if p.streamingHeaderTimeout > 0 {
+		s.ResponseHeaderTimeout = p.streamingHeaderTimeout
+	} else {
+		s.ResponseHeaderTimeout = -1
+	}
+
+	return s, true
+}
+
+func (t *routeTransports) roundTripper(s transport.Settings) http.RoundTripper {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if rt, ok := t.wrapped[s]; ok {
+		return rt
+	}
+
+	rt := t.wrap(t.transport(s))
+	t.wrapped[s] = rt
+	return rt
+}
+
+func (t *routeTransports) grpcRoundTripper(s transport.Settings) http.RoundTripper {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if rt, ok := t.grpcWrapped[s]; ok {
+		return rt
+	}
+
+	gt := newGRPCTransport(t.transport(s))
+	rt := t.wrap(gt)
+	t.grpc[s] = gt
+	t.grpcWrapped[s] = rt
+	return rt
+}
+
+// returns the unwrapped transport with the settings, it needs to be called
+// with the lock held
+func (t *routeTransports) transport(s transport.Settings) *http.Transport {
+	if tr, ok := t.transports[s]; ok {
+		return tr
+	}
+
+	tr := t.base.Clone()
+	if s.DialTimeout > 0 {
+		tr.DialContext = func(ctx stdlibcontext.Context, network, addr string) (net.Conn, error) {
+			ctx, cancel := stdlibcontext.WithTimeout(ctx, s.DialTimeout)
+			defer cancel()
+			return t.dial(ctx, network, addr)
+		}
+	}
+
+	if s.TLSHandshakeTimeout > 0 {
+		tr.TLSHandshakeTimeout = s.TLSHandshakeTimeout
+	}
+
+	if s.ResponseHeaderTimeout > 0 {
+		tr.ResponseHeaderTimeout = s.ResponseHeaderTimeout
+	} else if s.ResponseHeaderTimeout < 0 {
+		tr.ResponseHeaderTimeout = 0
+	}
+
+	if s.ExpectContinueTimeout > 0 {
+		tr.ExpectContinueTimeout = s.ExpectContinueTimeout
+	}
+
+	if s.IdleConnTimeout > 0 {
+		tr.IdleConnTimeout = s.IdleConnTimeout
+	}
+
+	if s.MaxIdleConnsPerHost > 0 {
+		tr.MaxIdleConnsPerHost = s.MaxIdleConnsPerHost
+	}
+
+	if s.MaxConnsPerHost > 0 {
+		tr.MaxConnsPerHost = s.MaxConnsPerHost
+	}
+
+	if s.ClientCertificate != nil {
+		if tr.TLSClientConfig == nil {
+			tr.TLSClientConfig = &tls.Config{}
+		}
+
+		tr.TLSClientConfig.Certificates = nil
+		tr.TLSClientConfig.GetClientCertificate = s.ClientCertificate.GetClientCertificate
+
+		// the prewarmed connections use the global certificate
+		tr.DialTLSContext = nil
+	}
+
+	t.transports[s] = tr
+	return tr
+}
+
+func (t *routeTransports) CloseIdleConnections() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for _, tr := range t.transports {
+		tr.CloseIdleConnections()
+	}
+
+	for _, gt := range t.grpc {
+		gt.CloseIdleConnections()
+	}
+}
@@ -0,0 +1,138 @@
+package proxy
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/zalando/skipper/filters/builtin"
+	"github.com/zalando/skipper/filters/transport"
+	"github.com/zalando/skipper/secrets"
+)
+
+func TestBackendTransportResponseHeaderTimeout(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(100 * time.Millisecond)
+	}))
+	defer backend.Close()
+
+	tp, err := newTestProxy(fmt.Sprintf(`
+		slow: Path("/slow") -> "%s";
+		fast: Path("/fast") -> backendTransport("responseHeaderTimeout", "20ms") -> "%s";
+	`, backend.URL, backend.URL), FlagsNone)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer tp.close()
+
+	for path, status := range map[string]int{"/slow": http.StatusOK, "/fast": http.StatusGatewayTimeout} {
+		w := httptest.NewRecorder()
+		tp.proxy.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		if w.Code != status {
+			t.Errorf("%s: unexpected status: %d", path, w.Code)
+		}
+	}
+}
+
+func TestRouteTransportsShared(t *testing.T) {
+	tr := &http.Transport{ResponseHeaderTimeout: time.Minute, MaxIdleConnsPerHost: 64}
+	rts := newRouteTransports(tr, nil, func(rt http.RoundTripper) http.RoundTripper { return rt })
+
+	s := transport.Settings{ResponseHeaderTimeout: 5 * time.Minute}
+	rt := rts.roundTripper(s)
+	if rts.roundTripper(s) != rt {
+		t.Error("failed to share the transport")
+	}
+
+	if rts.roundTripper(transport.Settings{MaxIdleConnsPerHost: 2}) == rt {
+		t.Error("unexpected shared transport")
+	}
+
+	str := rt.(*http.Transport)
+	if str.ResponseHeaderTimeout != 5*time.Minute || str.MaxIdleConnsPerHost != 64 {
+		t.Errorf("unexpected transport settings: %v, %d", str.ResponseHeaderTimeout, str.MaxIdleConnsPerHost)
+	}
+
+	if tr.ResponseHeaderTimeout != time.Minute {
+		t.Error("the base transport was changed")
+	}
+
+	str = rts.roundTripper(transport.Settings{MaxConnsPerHost: 8}).(*http.Transport)
+	if str.MaxConnsPerHost != 8 || tr.MaxConnsPerHost != 0 {
+		t.Errorf("unexpected connection limit: %d, %d", str.MaxConnsPerHost, tr.MaxConnsPerHost)
+	}
+}
+
+// returns a self-signed certificate and its key, PEM encoded in a single
+// secret
+func clientCertificatePEM(t *testing.T) []byte {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "skipper"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return append(
+		pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})...,
+	)
+}
+
+func TestBackendClientCertificate(t *testing.T) {
+	backend := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if len(r.TLS.PeerCertificates) == 0 || r.TLS.PeerCertificates[0].Subject.CommonName != "skipper" {
+			w.WriteHeader(http.StatusForbidden)
+		}
+	}))
+	backend.TLS = &tls.Config{ClientAuth: tls.RequireAnyClientCert}
+	backend.StartTLS()
+	defer backend.Close()
+
+	fr := builtin.MakeRegistry()
+	fr.Register(transport.NewBackendClientCertificate(secrets.StaticSecret(clientCertificatePEM(t))))
+	tp, err := newTestProxyWithFilters(fr, fmt.Sprintf(`
+		mtls: Path("/mtls") -> backendClientCertificate("/meta/credentials/backend") -> "%s";
+		plain: Path("/plain") -> "%s";
+	`, backend.URL, backend.URL), Insecure)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer tp.close()
+
+	for path, status := range map[string]int{"/mtls": http.StatusOK, "/plain": http.StatusServiceUnavailable} {
+		w := httptest.NewRecorder()
+		tp.proxy.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		if w.Code != status {
+			t.Errorf("%s: unexpected status: %d", path, w.Code)
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package proxy
+
+import (
+	stdlibcontext "context"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+)
+
+const (
+	unixScheme = "unix"
+
+	// the URL host and the default Host header of the requests sent to
+	// the unix domain socket backends
+	unixSocketHost = "localhost"
+)
+
+// newBackendRequest creates the outgoing request. The path of a unix domain
+// socket is not a valid URL host, so it is set only after parsing the URL.
+func newBackendRequest(ctx stdlibcontext.Context, method string, u *url.URL, body io.Reader) (*http.Request, error) {
+	if u.Scheme != unixScheme {
+		return http.NewRequestWithContext(ctx, method, u.String(), body)
+	}
+
+	su := *u
+	su.Host = unixSocketHost
+	req, err := http.NewRequestWithContext(ctx, method, su.String(), body)
+	if err != nil {
+		return nil, err
+	}
+
+	req.URL.Host = u.Host
+	return req, nil
+}
+
+// unixTransport sends the requests with the unix scheme to the unix domain
+// socket in the host field of the URL. It uses a separate transport for
+// each socket, all of them with the settings of the main transport.
+type unixTransport struct {
+	base *http.Transport
+	dial dialFunc
+
+	mu         sync.Mutex
+	transports map[string]*http.Transport
+}
+
+func newUnixTransport(tr *http.Transport, dial dialFunc) *unixTransport {
+	return &unixTransport{
+		base:       tr,
+		dial:       dial,
+		transports: make(map[string]*http.Transport),
+	}
+}
+
+func (t *unixTransport) transport(socket string) *http.Transport {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if tr, ok := t.transports[socket]; ok {
+		return tr
+	}
+
+	tr := t.base.Clone()
+	tr.Proxy = nil
+	tr.DialTLSContext = nil
+	tr.DialContext = func(ctx stdlibcontext.Context, _, _ string) (net.Conn, error) {
+		return t.dial(ctx, "unix", socket)
+	}
+
+	t.transports[socket] = tr
+	return tr
+}
+
+func (t *unixTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	socket := req.URL.Host
+	u := *req.URL
+	u.Scheme = "http"
+	u.Host = unixSocketHost
+
+	r := *req
+	r.URL = &u
+	if r.Host == "" || r.Host == socket {
+		r.Host = unixSocketHost
+	}
+
+	rsp, err := t.transport(socket).RoundTrip(&r)
+	if rsp != nil {
+		rsp.Request = req
+	}
+
+	return rsp, err
+}
+
+func (t *unixTransport) CloseIdleConnections() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for _, tr := range t.transports {
+		tr.CloseIdleConnections()
+	}
+}
@@ -0,0 +1,106 @@
+package proxy
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+)
+
+func newUnixBackend(t *testing.T, name string) string {
+	socket := filepath.Join(t.TempDir(), name)
+	l, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "%s %s %s", name, r.Host, r.URL.Path)
+	})}
+
+	go s.Serve(l)
+	t.Cleanup(func() { s.Close() })
+	return socket
+}
+
+func TestUnixSocketBackend(t *testing.T) {
+	app := newUnixBackend(t, "app.sock")
+	other := newUnixBackend(t, "other.sock")
+
+	tp, err := newTestProxy(fmt.Sprintf(`
+		app: Path("/app") -> "unix://%s";
+		preserved: Path("/preserved") -> preserveHost("true") -> "unix://%s";
+		lb: Path("/lb") -> <roundRobin, "unix://%s", "unix://%s">;
+	`, app, app, app, other), FlagsNone)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer tp.close()
+
+	ps := httptest.NewServer(tp.proxy)
+	defer ps.Close()
+
+	get := func(path string) string {
+		t.Helper()
+
+		req, err := http.NewRequest("GET", ps.URL+path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req.Host = "www.example.org"
+		rsp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		defer rsp.Body.Close()
+		b, err := io.ReadAll(rsp.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if rsp.StatusCode != http.StatusOK {
+			t.Fatalf("unexpected status: %d", rsp.StatusCode)
+		}
+
+		return string(b)
+	}
+
+	if b := get("/app"); b != "app.sock localhost /app" {
+		t.Errorf("unexpected response: %s", b)
+	}
+
+	if b := get("/preserved"); b != "app.sock www.example.org /preserved" {
+		t.Errorf("unexpected response: %s", b)
+	}
+
+	responses := make(map[string]bool)
+	for i := 0; i < 4; i++ {
+		responses[get("/lb")] = true
+	}
+
+	if !responses["app.sock localhost /lb"] || !responses["other.sock localhost /lb"] {
+		t.Errorf("failed to balance the requests between the sockets: %v", responses)
+	}
+}
+
+func TestUnixSocketBackendNotListening(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "missing.sock")
+	tp, err := newTestProxy(fmt.Sprintf(`* -> "unix://%s"`, socket), FlagsNone)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer tp.close()
+
+	w := httptest.NewRecorder()
+	tp.proxy.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	if w.Code != http.StatusBadGateway {
+		t.Errorf("unexpected status: %d", w.Code)
+	}
+}
@@ -13,6 +13,8 @@ import (
 	"strings"
 
 	log "github.com/sirupsen/logrus"
+
+	"github.com/zalando/skipper/filters/websocket"
 )
 
 // isUpgradeRequest returns true if and only if there is a "Connection"
@@ -46,6 +48,10 @@ type upgradeProxy struct {
 	auditLogOut     io.Writer
 	auditLogErr     io.Writer
 	auditLogHook    chan struct{}
+
+	// set when the filters registered websocket message callbacks
+	// or limits
+	inspection *websocket.Inspection
 }
 
 // TODO: add user here
@@ -73,6 +79,13 @@ func (p *upgradeProxy) serveHTTP(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 
+	// the messages can be inspected only without the compression
+	// extension
+	inspect := p.inspection != nil && isWebsocketUpgrade(req)
+	if inspect {
+		req.Header.Del("Sec-WebSocket-Extensions")
+	}
+
 	backendConn, err := p.dialBackend(req)
 	if err != nil {
 		log.Errorf("Error connecting to backend: %s", err)
@@ -105,7 +118,8 @@ func (p *upgradeProxy) serveHTTP(w http.ResponseWriter, req *http.Request) {
 		}
 	}
 
-	resp, err := http.ReadResponse(bufio.NewReader(backendConn), req)
+	backendReader := bufio.NewReader(backendConn)
+	resp, err := http.ReadResponse(backendReader, req)
 	if err != nil {
 		log.Errorf("Error reading response from backend: %s", err)
 		w.WriteHeader(http.StatusInternalServerError)
@@ -139,7 +153,7 @@ func (p *upgradeProxy) serveHTTP(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 
-	requestHijackedConn, _, err := w.(http.Hijacker).Hijack()
+	requestHijackedConn, requestBuffer, err := w.(http.Hijacker).Hijack()
 	if err != nil {
 		log.Errorf("Error hijacking request connection: %s", err)
 		w.WriteHeader(http.StatusInternalServerError)
@@ -159,14 +173,23 @@ func (p *upgradeProxy) serveHTTP(w http.ResponseWriter, req *http.Request) {
 
 	done := make(chan struct{}, 2)
 
-	if p.useAuditLog {
+	if inspect {
+		client := &wsWriter{w: requestHijackedConn}
+		if p.useAuditLog {
+			client.w = io.MultiWriter(requestHijackedConn, p.auditLogOut)
+		}
+
+		backend := &wsWriter{w: backendConn, mask: true}
+		inspectWSAsync(p.inspection, websocket.BackendToClient, backendReader, client, backend, done)
+		inspectWSAsync(p.inspection, websocket.ClientToBackend, hijackedReader(requestBuffer, requestHijackedConn), backend, client, done)
+	} else if p.useAuditLog {
 		copyAsync("backend->request+audit", backendConn, io.MultiWriter(requestHijackedConn, p.auditLogOut), done)
+		copyAsync("request->backend", requestHijackedConn, backendConn, done)
 	} else {
 		copyAsync("backend->request", backendConn, requestHijackedConn, done)
+		copyAsync("request->backend", requestHijackedConn, backendConn, done)
 	}
 
-	copyAsync("request->backend", requestHijackedConn, backendConn, done)
-
 	log.Debugf("Successfully upgraded to protocol %s by user request", getUpgradeRequest(req))
 
 	// Wait for either copyAsync to complete.
@@ -0,0 +1,347 @@
+package proxy
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/zalando/skipper/filters/websocket"
+)
+
+// websocket frame opcodes, https://tools.ietf.org/html/rfc6455#section-5.2
+const (
+	wsContinuation = 0x0
+	wsClose        = 0x8
+
+	wsMaxControlPayload = 125
+)
+
+// websocket close status codes, https://tools.ietf.org/html/rfc6455#section-7.4.1
+const (
+	wsStatusProtocolError   = 1002
+	wsStatusPolicyViolation = 1008
+	wsStatusMessageTooBig   = 1009
+)
+
+type wsFrame struct {
+	fin     bool
+	rsv     byte
+	opcode  byte
+	payload []byte
+}
+
+// wsCloseError ends the inspection of a connection, and is sent to both
+// peers in a close frame.
+type wsCloseError struct {
+	status int
+	reason string
+}
+
+// wsWriter serializes the frames written to the same peer by the two
+// directions of the connection. The frames sent to the backend are masked.
+type wsWriter struct {
+	mu   sync.Mutex
+	w    io.Writer
+	mask bool
+}
+
+// wsRate is a token bucket, limiting the messages of the client.
+type wsRate struct {
+	max    float64
+	rate   float64
+	tokens float64
+	last   time.Time
+}
+
+func (err *wsCloseError) Error() string {
+	return fmt.Sprintf("websocket closed with %d: %s", err.status, err.reason)
+}
+
+// send closes the connection of both peers with the status
+func (err *wsCloseError) send(dst, peer *wsWriter) error {
+	peer.writeClose(err.status, err.reason)
+	dst.writeClose(err.status, err.reason)
+	return err
+}
+
+func isWebsocketUpgrade(req *http.Request) bool {
+	return strings.EqualFold(req.Header.Get("Upgrade"), "websocket")
+}
+
+func isWSControl(opcode byte) bool {
+	return opcode&0x8 != 0
+}
+
+// readWSFrame reads a frame, and unmasks its payload. It fails without
+// reading the payload, when a data frame is longer than maxPayload. A
+// negative maxPayload means no limit.
+func readWSFrame(r io.Reader, maxPayload int64) (wsFrame, error) {
+	var (
+		f wsFrame
+		h [8]byte
+	)
+
+	if _, err := io.ReadFull(r, h[:2]); err != nil {
+		return f, err
+	}
+
+	f.fin = h[0]&0x80 != 0
+	f.rsv = h[0] & 0x70
+	f.opcode = h[0] & 0x0f
+	masked := h[1]&0x80 != 0
+
+	length := int64(h[1] & 0x7f)
+	switch length {
+	case 126:
+		if _, err := io.ReadFull(r, h[:2]); err != nil {
+			return f, err
+		}
+
+		length = int64(binary.BigEndian.Uint16(h[:2]))
+	case 127:
+		if _, err := io.ReadFull(r, h[:8]); err != nil {
+			return f, err
+		}
+
+		l := binary.BigEndian.Uint64(h[:8])
+		if l > 1<<63-1 {
+			return f, &wsCloseError{status: wsStatusProtocolError, reason: "invalid frame length"}
+		}
+
+		length = int64(l)
+	}
+
+	if isWSControl(f.opcode) && (length > wsMaxControlPayload || !f.fin) {
+		return f, &wsCloseError{status: wsStatusProtocolError, reason: "invalid control frame"}
+	}
+
+	if !isWSControl(f.opcode) && maxPayload >= 0 && length > maxPayload {
+		return f, &wsCloseError{status: wsStatusMessageTooBig, reason: "message too big"}
+	}
+
+	var key [4]byte
+	if masked {
+		if _, err := io.ReadFull(r, key[:]); err != nil {
+			return f, err
+		}
+	}
+
+	f.payload = make([]byte, length)
+	if _, err := io.ReadFull(r, f.payload); err != nil {
+		return f, err
+	}
+
+	if masked {
+		for i := range f.payload {
+			f.payload[i] ^= key[i%4]
+		}
+	}
+
+	return f, nil
+}
+
+func (w *wsWriter) writeFrame(opcode byte, payload []byte) error {
+	h := make([]byte, 2, 14)
+	h[0] = 0x80 | opcode
+
+	length := len(payload)
+	switch {
+	case length < 126:
+		h[1] = byte(length)
+	case length <= 0xffff:
+		h[1] = 126
+		h = binary.BigEndian.AppendUint16(h, uint16(length))
+	default:
+		h[1] = 127
+		h = binary.BigEndian.AppendUint64(h, uint64(length))
+	}
+
+	if w.mask {
+		var key [4]byte
+		if _, err := rand.Read(key[:]); err != nil {
+			return err
+		}
+
+		h[1] |= 0x80
+		h = append(h, key[:]...)
+
+		masked := make([]byte, length)
+		for i := range payload {
+			masked[i] = payload[i] ^ key[i%4]
+		}
+
+		payload = masked
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if _, err := w.w.Write(h); err != nil {
+		return err
+	}
+
+	_, err := w.w.Write(payload)
+	return err
+}
+
+func (w *wsWriter) writeClose(status int, reason string) error {
+	payload := binary.BigEndian.AppendUint16(nil, uint16(status))
+	payload = append(payload, reason...)
+	if len(payload) > wsMaxControlPayload {
+		payload = payload[:wsMaxControlPayload]
+	}
+
+	return w.writeFrame(wsClose, payload)
+}
+
+func newWSRate(i *websocket.Inspection) *wsRate {
+	if i.MaxMessages <= 0 || i.Period <= 0 {
+		return nil
+	}
+
+	max := float64(i.MaxMessages)
+	return &wsRate{
+		max:    max,
+		rate:   max / float64(i.Period),
+		tokens: max,
+		last:   time.Now(),
+	}
+}
+
+func (r *wsRate) allow(now time.Time) bool {
+	r.tokens += float64(now.Sub(r.last)) * r.rate
+	if r.tokens > r.max {
+		r.tokens = r.max
+	}
+
+	r.last = now
+	if r.tokens < 1 {
+		return false
+	}
+
+	r.tokens--
+	return true
+}
+
+// inspectWS reads the frames of one direction of a websocket
+// connection, assembles the fragmented messages, calls the registered
+// callbacks with them, and forwards the messages as single frames. The
+// control frames are forwarded as they are. When a limit is exceeded or a
+// callback closes the connection, it sends a close frame to both peers.
+func inspectWS(i *websocket.Inspection, dir websocket.Direction, src io.Reader, dst, peer *wsWriter) error {
+	var rate *wsRate
+	if dir == websocket.ClientToBackend {
+		rate = newWSRate(i)
+	}
+
+	var (
+		msg     *websocket.Message
+		current int64
+	)
+
+	for {
+		maxPayload := int64(-1)
+		if i.MaxMessageSize > 0 {
+			maxPayload = i.MaxMessageSize - current
+		}
+
+		f, err := readWSFrame(src, maxPayload)
+		if err != nil {
+			var cerr *wsCloseError
+			if errors.As(err, &cerr) {
+				return cerr.send(dst, peer)
+			}
+
+			return err
+		}
+
+		// the extensions are removed from the upgrade request
+		if f.rsv != 0 {
+			return (&wsCloseError{status: wsStatusProtocolError, reason: "unexpected reserved bits"}).send(dst, peer)
+		}
+
+		if isWSControl(f.opcode) {
+			if err := dst.writeFrame(f.opcode, f.payload); err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		if f.opcode == wsContinuation {
+			if msg == nil {
+				return (&wsCloseError{status: wsStatusProtocolError, reason: "unexpected continuation frame"}).send(dst, peer)
+			}
+
+			msg.Payload = append(msg.Payload, f.payload...)
+		} else {
+			if msg != nil {
+				return (&wsCloseError{status: wsStatusProtocolError, reason: "incomplete fragmented message"}).send(dst, peer)
+			}
+
+			msg = &websocket.Message{Direction: dir, Opcode: websocket.Opcode(f.opcode), Payload: f.payload}
+		}
+
+		current += int64(len(f.payload))
+		if !f.fin {
+			continue
+		}
+
+		m := msg
+		msg, current = nil, 0
+
+		if rate != nil && !rate.allow(time.Now()) {
+			return (&wsCloseError{status: wsStatusPolicyViolation, reason: "message rate exceeded"}).send(dst, peer)
+		}
+
+		action := websocket.Forward
+		for _, h := range i.Handlers {
+			if action = h(m); action != websocket.Forward {
+				break
+			}
+		}
+
+		switch action {
+		case websocket.Drop:
+			continue
+		case websocket.Close:
+			return (&wsCloseError{status: wsStatusPolicyViolation, reason: "message rejected"}).send(dst, peer)
+		}
+
+		if err := dst.writeFrame(byte(m.Opcode), m.Payload); err != nil {
+			return err
+		}
+	}
+}
+
+func inspectWSAsync(i *websocket.Inspection, dir websocket.Direction, src io.Reader, dst, peer *wsWriter, done chan<- struct{}) {
+	go func() {
+		err := inspectWS(i, dir, src, dst, peer)
+		var cerr *wsCloseError
+		if errors.As(err, &cerr) {
+			log.Debugf("websocket connection closed by the proxy: %v", err)
+		} else if err != nil && err != io.EOF && !strings.Contains(err.Error(), "use of closed network connection") {
+			log.Errorf("error inspecting websocket messages: %v", err)
+		}
+
+		done <- struct{}{}
+	}()
+}
+
+// the buffered bytes of the hijacked client connection
+func hijackedReader(rw *bufio.ReadWriter, conn io.Reader) io.Reader {
+	if rw == nil || rw.Reader.Buffered() == 0 {
+		return conn
+	}
+
+	return io.MultiReader(io.LimitReader(rw.Reader, int64(rw.Reader.Buffered())), conn)
+}
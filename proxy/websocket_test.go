@@ -0,0 +1,247 @@
+package proxy
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/zalando/skipper/filters"
+	"github.com/zalando/skipper/filters/builtin"
+	"github.com/zalando/skipper/filters/websocket"
+)
+
+type wsInspectSpec struct{}
+
+func (wsInspectSpec) Name() string { return "wsInspect" }
+
+func (s wsInspectSpec) CreateFilter([]interface{}) (filters.Filter, error) { return s, nil }
+
+// drops "drop", changes "upper" to "UPPER", and closes the connection
+// on "close"
+func (wsInspectSpec) Request(ctx filters.FilterContext) {
+	websocket.Inspect(ctx, func(m *websocket.Message) websocket.Action {
+		if m.Direction != websocket.ClientToBackend {
+			return websocket.Forward
+		}
+
+		switch string(m.Payload) {
+		case "drop":
+			return websocket.Drop
+		case "close":
+			return websocket.Close
+		case "upper":
+			m.Payload = bytes.ToUpper(m.Payload)
+		}
+
+		return websocket.Forward
+	})
+}
+
+func (wsInspectSpec) Response(filters.FilterContext) {}
+
+type wsTestClient struct {
+	t      *testing.T
+	conn   net.Conn
+	reader *bufio.Reader
+	writer *wsWriter
+}
+
+func wsEchoBackend(t *testing.T, extensions *atomic.Value) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		extensions.Store(r.Header.Get("Sec-WebSocket-Extensions"))
+		w.WriteHeader(http.StatusSwitchingProtocols)
+		conn, rw, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+
+		defer conn.Close()
+		ww := &wsWriter{w: conn}
+		for {
+			f, err := readWSFrame(rw, -1)
+			if err != nil {
+				return
+			}
+
+			if err := ww.writeFrame(f.opcode, f.payload); err != nil || f.opcode == wsClose {
+				return
+			}
+		}
+	}))
+}
+
+func dialWS(t *testing.T, proxyURL, path string) *wsTestClient {
+	u, _ := url.Parse(proxyURL)
+	conn, err := net.Dial("tcp", u.Host)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req, _ := http.NewRequest("GET", proxyURL+path, nil)
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Sec-WebSocket-Extensions", "permessage-deflate")
+	if err := req.Write(conn); err != nil {
+		t.Fatal(err)
+	}
+
+	reader := bufio.NewReader(conn)
+	rsp, err := http.ReadResponse(reader, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if rsp.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("unexpected status: %d", rsp.StatusCode)
+	}
+
+	conn.SetDeadline(time.Now().Add(3 * time.Second))
+	return &wsTestClient{t: t, conn: conn, reader: reader, writer: &wsWriter{w: conn, mask: true}}
+}
+
+func (c *wsTestClient) send(msg string) {
+	if err := c.writer.writeFrame(byte(websocket.Text), []byte(msg)); err != nil {
+		c.t.Fatal(err)
+	}
+}
+
+// sends unmasked frames, with the fin bit set only on the last one
+func (c *wsTestClient) sendFragments(fragments ...string) {
+	for i, f := range fragments {
+		var h [2]byte
+		if i == 0 {
+			h[0] = byte(websocket.Text)
+		}
+
+		if i == len(fragments)-1 {
+			h[0] |= 0x80
+		}
+
+		h[1] = byte(len(f))
+		if _, err := c.conn.Write(append(h[:], f...)); err != nil {
+			c.t.Fatal(err)
+		}
+	}
+}
+
+func (c *wsTestClient) expectMessage(msg string) {
+	f, err := readWSFrame(c.reader, -1)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+
+	if f.opcode != byte(websocket.Text) || string(f.payload) != msg {
+		c.t.Errorf("unexpected frame: %d, %q, expected: %q", f.opcode, f.payload, msg)
+	}
+}
+
+func (c *wsTestClient) expectClose(status int) {
+	f, err := readWSFrame(c.reader, -1)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+
+	if f.opcode != wsClose || len(f.payload) < 2 || int(binary.BigEndian.Uint16(f.payload)) != status {
+		c.t.Errorf("unexpected frame: %d, %q, expected close with: %d", f.opcode, f.payload, status)
+	}
+}
+
+func TestWebsocketInspection(t *testing.T) {
+	var extensions atomic.Value
+	backend := wsEchoBackend(t, &extensions)
+	defer backend.Close()
+
+	fr := builtin.MakeRegistry()
+	fr.Register(wsInspectSpec{})
+
+	tp, err := newTestProxyWithFiltersAndParams(fr, fmt.Sprintf(`
+		inspect: Path("/inspect") -> wsInspect() -> websocketMaxMessageSize(16) -> "%s";
+		rate: Path("/rate") -> websocketMessageRate(2, "1h") -> "%s";
+	`, backend.URL, backend.URL), Params{ExperimentalUpgrade: true}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer tp.close()
+
+	ps := httptest.NewServer(tp.proxy)
+	defer ps.Close()
+
+	t.Run("messages", func(t *testing.T) {
+		c := dialWS(t, ps.URL, "/inspect")
+		defer c.conn.Close()
+
+		if e, _ := extensions.Load().(string); e != "" {
+			t.Errorf("the extensions were not removed: %s", e)
+		}
+
+		c.send("hello")
+		c.expectMessage("hello")
+
+		c.send("upper")
+		c.expectMessage("UPPER")
+
+		c.send("drop")
+		c.send("after drop")
+		c.expectMessage("after drop")
+
+		c.sendFragments("frag", "men", "ted")
+		c.expectMessage("fragmented")
+
+		c.send("too long for the limit")
+		c.expectClose(wsStatusMessageTooBig)
+	})
+
+	t.Run("fragments too long", func(t *testing.T) {
+		c := dialWS(t, ps.URL, "/inspect")
+		defer c.conn.Close()
+
+		c.sendFragments("0123456789", "0123456789")
+		c.expectClose(wsStatusMessageTooBig)
+	})
+
+	t.Run("closed by callback", func(t *testing.T) {
+		c := dialWS(t, ps.URL, "/inspect")
+		defer c.conn.Close()
+
+		c.send("close")
+		c.expectClose(wsStatusPolicyViolation)
+	})
+
+	t.Run("rate", func(t *testing.T) {
+		c := dialWS(t, ps.URL, "/rate")
+		defer c.conn.Close()
+
+		c.send("one")
+		c.expectMessage("one")
+		c.send("two")
+		c.expectMessage("two")
+		c.send("three")
+		c.expectClose(wsStatusPolicyViolation)
+	})
+}
+
+func TestWebsocketRate(t *testing.T) {
+	r := newWSRate(&websocket.Inspection{MaxMessages: 2, Period: time.Second})
+	now := r.last
+	if !r.allow(now) || !r.allow(now) || r.allow(now) {
+		t.Error("failed to limit the burst")
+	}
+
+	if !r.allow(now.Add(500*time.Millisecond)) || r.allow(now.Add(500*time.Millisecond)) {
+		t.Error("failed to refill the tokens")
+	}
+
+	if newWSRate(&websocket.Inspection{}) != nil {
+		t.Error("unexpected rate without limit")
+	}
+}
@@ -0,0 +1,205 @@
+package proxy
+
+import (
+	"bufio"
+	stdlibcontext "context"
+	"io"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/zalando/skipper/filters"
+)
+
+// zeroCopyTransport sends the backend requests of the routes with the
+// backendZeroCopy filter on dedicated connections, and returns the
+// response bodies of known length reading directly from the connection.
+// When such a body is not changed by the filters, the proxy passes the
+// connection to the io.ReaderFrom of the response writer, which copies
+// the bytes between the sockets with splice on Linux. The connections are
+// closed after the response, because they can't be returned to a pool.
+type zeroCopyTransport struct {
+	dial                  dialFunc
+	responseHeaderTimeout time.Duration
+
+	// used for the https backends and the requests via an HTTP proxy
+	fallback http.RoundTripper
+}
+
+type zeroCopyConn struct {
+	net.Conn
+	once sync.Once
+	done chan struct{}
+}
+
+// zeroCopyBody is returned as the response body, when the length of the
+// body is known. It reads first the bytes already buffered while reading
+// the response header, and then directly from the connection.
+type zeroCopyBody struct {
+	conn     *zeroCopyConn
+	buffered io.Reader
+	rest     *io.LimitedReader
+	reader   io.Reader
+}
+
+// zeroCopyStreamBody closes the connection after the body of unknown
+// length.
+type zeroCopyStreamBody struct {
+	io.ReadCloser
+	conn *zeroCopyConn
+}
+
+func useZeroCopy(ctx *context) bool {
+	v, _ := ctx.StateBag()[filters.BackendZeroCopyKey].(bool)
+	return v
+}
+
+func newZeroCopyTransport(dial dialFunc, responseHeaderTimeout time.Duration, fallback http.RoundTripper) *zeroCopyTransport {
+	return &zeroCopyTransport{
+		dial:                  dial,
+		responseHeaderTimeout: responseHeaderTimeout,
+		fallback:              fallback,
+	}
+}
+
+// closes the connection when the request is canceled before the
+// response body was closed
+func newZeroCopyConn(ctx stdlibcontext.Context, conn net.Conn) *zeroCopyConn {
+	c := &zeroCopyConn{Conn: conn, done: make(chan struct{})}
+	go func() {
+		select {
+		case <-ctx.Done():
+			c.Conn.Close()
+		case <-c.done:
+		}
+	}()
+
+	return c
+}
+
+func (c *zeroCopyConn) Close() error {
+	c.once.Do(func() { close(c.done) })
+	return c.Conn.Close()
+}
+
+// the connection that the splice can be used with
+func (c *zeroCopyConn) raw() net.Conn {
+	if pc, ok := c.Conn.(*poolConn); ok {
+		return pc.Conn
+	}
+
+	return c.Conn
+}
+
+func (t *zeroCopyTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if proxyURL, _ := proxyFromContext(req); req.URL.Scheme != "http" || proxyURL != nil {
+		return t.fallback.RoundTrip(req)
+	}
+
+	addr := req.URL.Host
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		addr = net.JoinHostPort(addr, "80")
+	}
+
+	ctx := req.Context()
+	conn, err := t.dial(ctx, "tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	zc := newZeroCopyConn(ctx, conn)
+
+	// the connection is not reused
+	outreq := *req
+	outreq.Close = true
+	if err := outreq.Write(zc); err != nil {
+		zc.Close()
+		return nil, err
+	}
+
+	if t.responseHeaderTimeout > 0 {
+		zc.SetReadDeadline(time.Now().Add(t.responseHeaderTimeout))
+	}
+
+	br := bufio.NewReader(zc)
+	rsp, err := http.ReadResponse(br, req)
+
+	// skipping the informational responses
+	for err == nil && rsp.StatusCode >= 100 && rsp.StatusCode < 200 && rsp.StatusCode != http.StatusSwitchingProtocols {
+		rsp, err = http.ReadResponse(br, req)
+	}
+
+	if err != nil {
+		zc.Close()
+		if cerr := ctx.Err(); cerr != nil {
+			return nil, cerr
+		}
+
+		return nil, err
+	}
+
+	zc.SetReadDeadline(time.Time{})
+
+	if rsp.Body == http.NoBody || rsp.ContentLength <= 0 || len(rsp.TransferEncoding) > 0 {
+		rsp.Body = &zeroCopyStreamBody{ReadCloser: rsp.Body, conn: zc}
+		return rsp, nil
+	}
+
+	buffered := int64(br.Buffered())
+	if buffered > rsp.ContentLength {
+		buffered = rsp.ContentLength
+	}
+
+	b := &zeroCopyBody{
+		conn:     zc,
+		buffered: io.LimitReader(br, buffered),
+		rest:     &io.LimitedReader{R: zc.raw(), N: rsp.ContentLength - buffered},
+	}
+
+	b.reader = io.MultiReader(b.buffered, b.rest)
+	rsp.Body = b
+	return rsp, nil
+}
+
+func (b *zeroCopyBody) Read(p []byte) (int, error) {
+	n, err := b.reader.Read(p)
+	if err == io.EOF && b.rest.N > 0 {
+		err = io.ErrUnexpectedEOF
+	}
+
+	return n, err
+}
+
+// writeTo copies the body to the response writer. When it is possible,
+// the response writer reads the rest of the body directly from the
+// backend connection, using splice on Linux.
+func (b *zeroCopyBody) writeTo(w io.Writer) (int64, error) {
+	n, err := io.Copy(writerOnly{w}, b.buffered)
+	if err != nil {
+		return n, err
+	}
+
+	var m int64
+	if rf, ok := w.(io.ReaderFrom); ok {
+		m, err = rf.ReadFrom(b.rest)
+	} else {
+		m, err = io.Copy(writerOnly{w}, b.rest)
+	}
+
+	n += m
+	if err == nil && b.rest.N > 0 {
+		err = io.ErrUnexpectedEOF
+	}
+
+	return n, err
+}
+
+func (b *zeroCopyBody) Close() error {
+	return b.conn.Close()
+}
+
+func (b *zeroCopyStreamBody) Close() error {
+	b.ReadCloser.Close()
+	return b.conn.Close()
+}
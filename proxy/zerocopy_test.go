@@ -0,0 +1,134 @@
+package proxy
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestZeroCopyTransport(t *testing.T) {
+	payload := make([]byte, 1<<20)
+	rand.New(rand.NewSource(42)).Read(payload)
+
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !r.Close {
+			t.Error("the backend connection is not closed")
+		}
+
+		w.Header().Set("Content-Length", strconv.Itoa(len(payload)))
+		w.Write(payload)
+	}))
+	defer backend.Close()
+
+	tr := newZeroCopyTransport((&net.Dialer{}).DialContext, time.Second, http.DefaultTransport)
+	req, _ := http.NewRequest("GET", backend.URL, nil)
+	rsp, err := tr.RoundTrip(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer rsp.Body.Close()
+	zb, ok := rsp.Body.(*zeroCopyBody)
+	if !ok {
+		t.Fatalf("unexpected body: %T", rsp.Body)
+	}
+
+	if _, ok := zb.rest.R.(*net.TCPConn); !ok {
+		t.Fatalf("unexpected connection: %T", zb.rest.R)
+	}
+
+	var buf bytes.Buffer
+	n, err := zb.writeTo(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if n != int64(len(payload)) || !bytes.Equal(buf.Bytes(), payload) {
+		t.Errorf("unexpected body, length: %d", n)
+	}
+}
+
+func TestBackendZeroCopy(t *testing.T) {
+	payload := make([]byte, 1<<20)
+	rand.New(rand.NewSource(42)).Read(payload)
+
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/download":
+			w.Header().Set("Content-Length", strconv.Itoa(len(payload)))
+			w.Write(payload)
+		case "/stream":
+			w.Write(payload[:1024])
+			w.(http.Flusher).Flush()
+			w.Write(payload[1024:2048])
+		case "/truncated":
+			w.Header().Set("Content-Length", "4096")
+			w.WriteHeader(http.StatusOK)
+			w.Write(payload[:1024])
+			w.(http.Flusher).Flush()
+			conn, _, _ := w.(http.Hijacker).Hijack()
+			conn.Close()
+		}
+	}))
+	defer backend.Close()
+
+	tp, err := newTestProxy(fmt.Sprintf(`* -> backendZeroCopy() -> "%s"`, backend.URL), FlagsNone)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer tp.close()
+
+	ps := httptest.NewServer(tp.proxy)
+	defer ps.Close()
+
+	get := func(method, path string) (*http.Response, []byte, error) {
+		req, _ := http.NewRequest(method, ps.URL+path, nil)
+		rsp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		defer rsp.Body.Close()
+		b, err := io.ReadAll(rsp.Body)
+		return rsp, b, err
+	}
+
+	rsp, b, err := get("GET", "/download")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if rsp.ContentLength != int64(len(payload)) || !bytes.Equal(b, payload) {
+		t.Errorf("unexpected download, length: %d, received: %d", rsp.ContentLength, len(b))
+	}
+
+	_, b, err = get("GET", "/stream")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(b, payload[:2048]) {
+		t.Errorf("unexpected stream, received: %d", len(b))
+	}
+
+	rsp, b, err = get("HEAD", "/download")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if rsp.StatusCode != http.StatusOK || len(b) != 0 {
+		t.Errorf("unexpected HEAD response: %d, %d", rsp.StatusCode, len(b))
+	}
+
+	if _, b, err = get("GET", "/truncated"); err == nil {
+		t.Errorf("failed to fail with a truncated body, received: %d", len(b))
+	}
+}
@@ -46,6 +46,10 @@ type Options struct {
 	// Address sets the listener address, e.g. :9090. Same as for net.Listen().
 	Address string
 
+	// Listener, when set, is used to accept the connections, instead of
+	// listening on Network and Address.
+	Listener net.Listener
+
 	// MaxConcurrency sets the maximum accepted connections.
 	MaxConcurrency int
 
@@ -72,6 +76,11 @@ type Options struct {
 	// concurrent connections and the number of connections in the queue.
 	Metrics metrics.Metrics
 
+	// MetricsKeySuffix is appended to the keys of the concurrency and queue
+	// size gauges, to distinguish between multiple queue listeners, e.g.
+	// when listening on the same address with multiple sockets.
+	MetricsKeySuffix string
+
 	// Log is used to log unexpected, non-fatal errors. It defaults to logging.DefaultLog.
 	Log logging.Logger
 
@@ -82,6 +91,8 @@ type listener struct {
 	options           Options
 	maxConcurrency    int
 	maxQueueSize      int
+	acceptedKey       string
+	queuedKey         string
 	externalListener  net.Listener
 	acceptExternal    chan *external
 	externalError     chan error
@@ -157,6 +168,8 @@ func listenWith(nl net.Listener, o Options) (net.Listener, error) {
 		options:           o,
 		maxConcurrency:    o.maxConcurrency(),
 		maxQueueSize:      o.maxQueueSize(),
+		acceptedKey:       acceptedConnectionsKey + o.MetricsKeySuffix,
+		queuedKey:         queuedConnectionsKey + o.MetricsKeySuffix,
 		externalListener:  nl,
 		acceptExternal:    make(chan *external),
 		externalError:     make(chan error),
@@ -189,6 +202,10 @@ func listenWith(nl net.Listener, o Options) (net.Listener, error) {
 //
 // See type Options for info about the configuration of the listener.
 func Listen(o Options) (net.Listener, error) {
+	if o.Listener != nil {
+		return listenWith(o.Listener, o)
+	}
+
 	nl, err := net.Listen(o.Network, o.Address)
 	if err != nil {
 		return nil, err
@@ -310,8 +327,8 @@ func (l *listener) listenInternal() {
 		}
 
 		if l.options.Metrics != nil {
-			l.options.Metrics.UpdateGauge(acceptedConnectionsKey, float64(concurrency))
-			l.options.Metrics.UpdateGauge(queuedConnectionsKey, float64(queue.size))
+			l.options.Metrics.UpdateGauge(l.acceptedKey, float64(concurrency))
+			l.options.Metrics.UpdateGauge(l.queuedKey, float64(queue.size))
 		}
 
 		select {
@@ -1181,6 +1181,45 @@ func TestListen(t *testing.T) {
 
 }
 
+func TestListenWithListener(t *testing.T) {
+	nl, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m := &metricstest.MockMetrics{}
+	l, err := Listen(Options{Listener: nl, Metrics: m, MetricsKeySuffix: ".1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer l.Close()
+	if l.Addr().String() != nl.Addr().String() {
+		t.Fatalf("unexpected address: %v", l.Addr())
+	}
+
+	go func() {
+		conn, err := net.Dial("tcp", nl.Addr().String())
+		if err != nil {
+			t.Errorf("Failed to dial: %v", err)
+			return
+		}
+
+		conn.Close()
+	}()
+
+	conn, err := l.Accept()
+	if err != nil {
+		t.Fatalf("Failed to accept: %v", err)
+	}
+
+	if err := waitForGauge(m, acceptedConnectionsKey+".1", 1); err != nil {
+		t.Errorf("failed to measure with the key suffix: %v", err)
+	}
+
+	conn.Close()
+}
+
 func TestQueue1(t *testing.T) {
 	for _, tt := range []struct {
 		name            string
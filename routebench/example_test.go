@@ -0,0 +1,26 @@
+package routebench_test
+
+import (
+	"log"
+	"os"
+
+	"github.com/zalando/skipper/routebench"
+)
+
+func Example() {
+	report, err := routebench.Run(routebench.Options{
+		Routes: `
+			api: Path("/api/*path") -> setRequestHeader("X-Api", "1") -> "https://api.example.org";
+			web: * -> "https://www.example.org";
+		`,
+		Requests: []routebench.Request{
+			{URL: "https://www.example.org/api/items", Weight: 9},
+			{URL: "https://www.example.org/index.html"},
+		},
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	report.WriteTo(os.Stdout)
+}
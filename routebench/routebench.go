@@ -0,0 +1,570 @@
+/*
+Package routebench implements a benchmark harness for routing tables, to
+evaluate the changes of the routes before rolling them out.
+
+The harness loads the routes of an eskip document, generates a sequence
+of synthetic requests from a weighted distribution, and measures the
+route lookup throughput, and the time and the allocations of each filter
+of the matched routes:
+
+	report, err := routebench.Run(routebench.Options{
+		Routes: `
+			api: Path("/api/*path") -> setRequestHeader("X-Api", "1") -> "https://api.example.org";
+			web: * -> "https://www.example.org";
+		`,
+		Requests: []routebench.Request{
+			{URL: "https://www.example.org/api/items", Weight: 9},
+			{URL: "https://www.example.org/index.html"},
+		},
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	report.WriteTo(os.Stdout)
+
+The filters are executed in the same order as in the proxy: the request
+filters of the matched route, followed by the response filters in reverse
+order, with an empty 200 OK response in place of the backend response.
+The filters calling external services, e.g. the authorization filters,
+are measured together with these calls.
+*/
+package routebench
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/zalando/skipper/eskip"
+	"github.com/zalando/skipper/filters"
+	"github.com/zalando/skipper/filters/builtin"
+	"github.com/zalando/skipper/filters/filtertest"
+	"github.com/zalando/skipper/loadbalancer"
+	"github.com/zalando/skipper/routing"
+	"github.com/zalando/skipper/routing/testdataclient"
+)
+
+const (
+	defaultRequests     = 10000
+	defaultAllocSamples = 1000
+	loadTimeout         = 30 * time.Second
+)
+
+// Request is a synthetic request of the distribution.
+type Request struct {
+
+	// Method of the request. Defaults to GET.
+	Method string
+
+	// URL of the request, including the host.
+	URL string
+
+	// Header of the request.
+	Header http.Header
+
+	// Weight of the request in the distribution. Defaults to 1.
+	Weight float64
+}
+
+// Options of a benchmark run.
+type Options struct {
+
+	// Routes contains the eskip document of the benchmarked routes.
+	Routes string
+
+	// Requests contains the distribution of the requests.
+	Requests []Request
+
+	// N sets the number of the generated requests. Defaults to 10000.
+	N int
+
+	// AllocSamples sets the number of the requests used to measure the
+	// allocations of the filters. Measuring the allocations is slower
+	// than the time, therefore it is done on a subset of the requests.
+	// Defaults to 1000.
+	AllocSamples int
+
+	// Seed of the random sequence of the requests. The runs with the
+	// same seed use the same sequence.
+	Seed int64
+
+	// FilterRegistry contains the filters available for the routes.
+	// Defaults to the builtin filters.
+	FilterRegistry filters.Registry
+
+	// Predicates contains the custom predicates available for the
+	// routes.
+	Predicates []routing.PredicateSpec
+
+	// SkipFilters disables measuring the filters.
+	SkipFilters bool
+}
+
+// FilterStats contains the measurements of a filter, aggregated by the
+// filter name across all the routes.
+type FilterStats struct {
+	Name string
+
+	// Calls contains the number of the calls of the Request and the
+	// Response methods.
+	Calls int64
+
+	// Duration contains the total time spent in the filter.
+	Duration time.Duration
+
+	// AllocCalls, Allocs and AllocBytes contain the number of the
+	// calls, and the total allocations measured on the allocation
+	// samples.
+	AllocCalls int64
+	Allocs     uint64
+	AllocBytes uint64
+}
+
+// Report of a benchmark run.
+type Report struct {
+
+	// Requests contains the number of the generated requests.
+	Requests int
+
+	// InvalidRoutes contains the errors of the routes that could not be
+	// loaded, by route id.
+	InvalidRoutes map[string]string
+
+	// Unmatched contains the number of the requests without a matching
+	// route.
+	Unmatched int
+
+	// RouteHits contains the number of the matched requests by route
+	// id.
+	RouteHits map[string]int
+
+	// MatchDuration contains the total time of the route lookups.
+	MatchDuration time.Duration
+
+	// MatchAllocs and MatchAllocBytes contain the total allocations of
+	// the route lookups.
+	MatchAllocs     uint64
+	MatchAllocBytes uint64
+
+	// Filters contains the measurements of the filters, sorted by the
+	// total time, in descending order.
+	Filters []FilterStats
+}
+
+type noopMetrics struct{}
+
+// the route errors are returned in the report
+type discardLog struct{}
+
+func (noopMetrics) MeasureSince(string, time.Time)    {}
+func (noopMetrics) IncCounter(string)                 {}
+func (noopMetrics) IncCounterBy(string, int64)        {}
+func (noopMetrics) IncFloatCounterBy(string, float64) {}
+
+func (discardLog) Error(...interface{})          {}
+func (discardLog) Errorf(string, ...interface{}) {}
+func (discardLog) Warn(...interface{})           {}
+func (discardLog) Warnf(string, ...interface{})  {}
+func (discardLog) Info(...interface{})           {}
+func (discardLog) Infof(string, ...interface{})  {}
+func (discardLog) Debug(...interface{})          {}
+func (discardLog) Debugf(string, ...interface{}) {}
+
+func newRequest(r Request) (*http.Request, error) {
+	method := r.Method
+	if method == "" {
+		method = http.MethodGet
+	}
+
+	req, err := http.NewRequest(method, r.URL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	for k, v := range r.Header {
+		req.Header[k] = append([]string(nil), v...)
+	}
+
+	req.RemoteAddr = "192.0.2.1:12345"
+	return req, nil
+}
+
+// returns the sequence of the requests, sampled from the weighted
+// distribution
+func generate(o Options) ([]*http.Request, error) {
+	if len(o.Requests) == 0 {
+		return nil, errors.New("no requests")
+	}
+
+	var (
+		templates []*http.Request
+		weights   []float64
+		total     float64
+	)
+
+	for _, r := range o.Requests {
+		req, err := newRequest(r)
+		if err != nil {
+			return nil, err
+		}
+
+		w := r.Weight
+		if w == 0 {
+			w = 1
+		}
+
+		if w < 0 {
+			return nil, fmt.Errorf("invalid weight of %s: %v", r.URL, w)
+		}
+
+		total += w
+		templates = append(templates, req)
+		weights = append(weights, total)
+	}
+
+	rnd := rand.New(rand.NewSource(o.Seed)) // #nosec
+	requests := make([]*http.Request, o.N)
+	for i := range requests {
+		v := rnd.Float64() * total
+		j := sort.SearchFloat64s(weights, v)
+		if j >= len(templates) {
+			j = len(templates) - 1
+		}
+
+		requests[i] = templates[j]
+	}
+
+	return requests, nil
+}
+
+func load(o Options) (*routing.Routing, error) {
+	defs, err := eskip.Parse(o.Routes)
+	if err != nil {
+		return nil, err
+	}
+
+	rt := routing.New(routing.Options{
+		FilterRegistry:  o.FilterRegistry,
+		Predicates:      o.Predicates,
+		DataClients:     []routing.DataClient{testdataclient.New(defs)},
+		PostProcessors:  []routing.PostProcessor{loadbalancer.NewAlgorithmProvider()},
+		SignalFirstLoad: true,
+		Log:             discardLog{},
+	})
+
+	select {
+	case <-rt.FirstLoad():
+	case <-time.After(loadTimeout):
+		rt.Close()
+		return nil, errors.New("timeout while loading the routes")
+	}
+
+	return rt, nil
+}
+
+// returns the errors of the invalid routes, using the routes API
+func routeErrors(rt *routing.Routing) (map[string]string, error) {
+	req := httptest.NewRequest("GET", "/routes?errors", nil)
+	req.Header.Set("Accept", "application/json")
+	rsp := httptest.NewRecorder()
+	rt.ServeHTTP(rsp, req)
+
+	var errs map[string]string
+	if err := json.Unmarshal(rsp.Body.Bytes(), &errs); err != nil {
+		return nil, fmt.Errorf("failed to get the route errors: %w", err)
+	}
+
+	return errs, nil
+}
+
+func readAllocs() (uint64, uint64) {
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+	return m.Mallocs, m.TotalAlloc
+}
+
+type recorder struct {
+	stats       map[string]*FilterStats
+	measureMems bool
+}
+
+func (rec *recorder) call(name string, f func()) {
+	s, ok := rec.stats[name]
+	if !ok {
+		s = &FilterStats{Name: name}
+		rec.stats[name] = s
+	}
+
+	if rec.measureMems {
+		allocs, bytes := readAllocs()
+		f()
+		allocsAfter, bytesAfter := readAllocs()
+		s.AllocCalls++
+		s.Allocs += allocsAfter - allocs
+		s.AllocBytes += bytesAfter - bytes
+		return
+	}
+
+	start := time.Now()
+	f()
+	s.Duration += time.Since(start)
+	s.Calls++
+}
+
+// executes the filters of a route in the same order as the proxy
+func (rec *recorder) run(r *routing.Route, params map[string]string, req *http.Request) {
+	ctx := &filtertest.Context{
+		FRequest:        req.Clone(req.Context()),
+		FParams:         params,
+		FStateBag:       make(map[string]interface{}),
+		FBackendUrl:     r.Backend,
+		FOutgoingHost:   req.Host,
+		FMetrics:        noopMetrics{},
+		FResponseWriter: discardResponseWriter{header: make(http.Header)},
+	}
+
+	var executed []*routing.RouteFilter
+	for _, f := range r.Filters {
+		rec.call(f.Name, func() { f.Request(ctx) })
+		executed = append(executed, f)
+		if ctx.Served() {
+			break
+		}
+	}
+
+	if ctx.FResponse == nil {
+		ctx.FResponse = &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     make(http.Header),
+			Body:       http.NoBody,
+			Request:    ctx.FRequest,
+		}
+	}
+
+	for i := len(executed) - 1; i >= 0; i-- {
+		f := executed[i]
+		rec.call(f.Name, func() { f.Response(ctx) })
+	}
+}
+
+// Run executes a benchmark.
+func Run(o Options) (*Report, error) {
+	if o.N <= 0 {
+		o.N = defaultRequests
+	}
+
+	if o.AllocSamples <= 0 {
+		o.AllocSamples = defaultAllocSamples
+	}
+
+	if o.AllocSamples > o.N {
+		o.AllocSamples = o.N
+	}
+
+	if o.FilterRegistry == nil {
+		o.FilterRegistry = builtin.MakeRegistry()
+	}
+
+	requests, err := generate(o)
+	if err != nil {
+		return nil, err
+	}
+
+	rt, err := load(o)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rt.Close()
+	invalid, err := routeErrors(rt)
+	if err != nil {
+		return nil, err
+	}
+
+	lookup := rt.Get()
+
+	type match struct {
+		route  *routing.Route
+		params map[string]string
+	}
+
+	report := &Report{
+		Requests:      len(requests),
+		InvalidRoutes: invalid,
+		RouteHits:     make(map[string]int),
+	}
+
+	matches := make([]match, len(requests))
+
+	runtime.GC()
+	allocs, bytes := readAllocs()
+	start := time.Now()
+	for i, req := range requests {
+		matches[i].route, matches[i].params = lookup.Do(req)
+	}
+
+	report.MatchDuration = time.Since(start)
+	allocsAfter, bytesAfter := readAllocs()
+	report.MatchAllocs = allocsAfter - allocs
+	report.MatchAllocBytes = bytesAfter - bytes
+
+	for _, m := range matches {
+		if m.route == nil {
+			report.Unmatched++
+		} else {
+			report.RouteHits[m.route.Id]++
+		}
+	}
+
+	if o.SkipFilters {
+		return report, nil
+	}
+
+	rec := &recorder{stats: make(map[string]*FilterStats)}
+	for i, m := range matches {
+		if m.route != nil {
+			rec.run(m.route, m.params, requests[i])
+		}
+	}
+
+	rec.measureMems = true
+	for i, m := range matches[:o.AllocSamples] {
+		if m.route != nil {
+			rec.run(m.route, m.params, requests[i])
+		}
+	}
+
+	for _, s := range rec.stats {
+		report.Filters = append(report.Filters, *s)
+	}
+
+	sort.Slice(report.Filters, func(i, j int) bool {
+		if report.Filters[i].Duration == report.Filters[j].Duration {
+			return report.Filters[i].Name < report.Filters[j].Name
+		}
+
+		return report.Filters[i].Duration > report.Filters[j].Duration
+	})
+
+	return report, nil
+}
+
+// MatchesPerSecond returns the throughput of the route lookups.
+func (r *Report) MatchesPerSecond() float64 {
+	if r.MatchDuration <= 0 {
+		return 0
+	}
+
+	return float64(r.Requests) / r.MatchDuration.Seconds()
+}
+
+// NsPerCall returns the average time of a filter call.
+func (s FilterStats) NsPerCall() float64 {
+	if s.Calls == 0 {
+		return 0
+	}
+
+	return float64(s.Duration.Nanoseconds()) / float64(s.Calls)
+}
+
+// AllocsPerCall returns the average number of the allocations of a
+// filter call.
+func (s FilterStats) AllocsPerCall() float64 {
+	if s.AllocCalls == 0 {
+		return 0
+	}
+
+	return float64(s.Allocs) / float64(s.AllocCalls)
+}
+
+// BytesPerCall returns the average allocated bytes of a filter call.
+func (s FilterStats) BytesPerCall() float64 {
+	if s.AllocCalls == 0 {
+		return 0
+	}
+
+	return float64(s.AllocBytes) / float64(s.AllocCalls)
+}
+
+// WriteTo writes the report as text tables.
+func (r *Report) WriteTo(w io.Writer) (int64, error) {
+	var b strings.Builder
+	perRequest := func(v uint64) float64 {
+		if r.Requests == 0 {
+			return 0
+		}
+
+		return float64(v) / float64(r.Requests)
+	}
+
+	invalid := make([]string, 0, len(r.InvalidRoutes))
+	for id := range r.InvalidRoutes {
+		invalid = append(invalid, id)
+	}
+
+	sort.Strings(invalid)
+	for _, id := range invalid {
+		fmt.Fprintf(&b, "invalid route %s: %s\n", id, r.InvalidRoutes[id])
+	}
+
+	fmt.Fprintf(&b, "requests: %d, unmatched: %d\n", r.Requests, r.Unmatched)
+	fmt.Fprintf(
+		&b,
+		"route lookup: %.0f/s, %.0f ns/op, %.1f allocs/op, %.0f B/op\n\n",
+		r.MatchesPerSecond(),
+		float64(r.MatchDuration.Nanoseconds())/float64(r.Requests),
+		perRequest(r.MatchAllocs),
+		perRequest(r.MatchAllocBytes),
+	)
+
+	ids := make([]string, 0, len(r.RouteHits))
+	for id := range r.RouteHits {
+		ids = append(ids, id)
+	}
+
+	sort.Slice(ids, func(i, j int) bool {
+		if r.RouteHits[ids[i]] == r.RouteHits[ids[j]] {
+			return ids[i] < ids[j]
+		}
+
+		return r.RouteHits[ids[i]] > r.RouteHits[ids[j]]
+	})
+
+	tw := tabwriter.NewWriter(&b, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "route\thits\t")
+	for _, id := range ids {
+		fmt.Fprintf(tw, "%s\t%d\t\n", id, r.RouteHits[id])
+	}
+
+	tw.Flush()
+	if len(r.Filters) > 0 {
+		b.WriteString("\n")
+		fmt.Fprintln(tw, "filter\tcalls\ttotal\tns/call\tallocs/call\tB/call\t")
+		for _, s := range r.Filters {
+			fmt.Fprintf(tw, "%s\t%d\t%v\t%.0f\t%.1f\t%.0f\t\n", s.Name, s.Calls, s.Duration, s.NsPerCall(), s.AllocsPerCall(), s.BytesPerCall())
+		}
+
+		tw.Flush()
+	}
+
+	n, err := io.WriteString(w, b.String())
+	return int64(n), err
+}
+
+type discardResponseWriter struct {
+	header http.Header
+}
+
+func (w discardResponseWriter) Header() http.Header       { return w.header }
+func (discardResponseWriter) Write(b []byte) (int, error) { return len(b), nil }
+func (discardResponseWriter) WriteHeader(int)             {}
@@ -0,0 +1,117 @@
+package routebench
+
+import (
+	"bytes"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+const testRoutes = `
+	api: Path("/api/*path") -> setRequestHeader("X-Api", "1") -> setResponseHeader("X-Api", "1") -> "https://api.example.org";
+	blocked: Path("/blocked") -> status(403) -> <shunt>;
+	post: Method("POST") && Path("/items") -> setRequestHeader("X-Post", "1") -> "https://www.example.org";
+	invalid: Path("/invalid") -> noSuchFilter() -> "https://www.example.org";
+`
+
+func TestRun(t *testing.T) {
+	r, err := Run(Options{
+		Routes: testRoutes,
+		Requests: []Request{
+			{URL: "https://www.example.org/api/items", Weight: 3},
+			{URL: "https://www.example.org/blocked"},
+			{Method: "POST", URL: "https://www.example.org/items", Header: http.Header{"Content-Type": []string{"application/json"}}},
+			{URL: "https://www.example.org/unknown"},
+		},
+		N:            600,
+		AllocSamples: 100,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if r.Requests != 600 {
+		t.Errorf("unexpected number of requests: %d", r.Requests)
+	}
+
+	if _, ok := r.InvalidRoutes["invalid"]; !ok || len(r.InvalidRoutes) != 1 {
+		t.Errorf("unexpected invalid routes: %v", r.InvalidRoutes)
+	}
+
+	if r.RouteHits["api"]+r.RouteHits["blocked"]+r.RouteHits["post"]+r.Unmatched != 600 {
+		t.Errorf("unexpected hits: %v, unmatched: %d", r.RouteHits, r.Unmatched)
+	}
+
+	if r.RouteHits["api"] < r.RouteHits["blocked"] || r.RouteHits["blocked"] == 0 || r.RouteHits["post"] == 0 || r.Unmatched == 0 {
+		t.Errorf("unexpected distribution: %v, unmatched: %d", r.RouteHits, r.Unmatched)
+	}
+
+	if r.MatchDuration <= 0 || r.MatchesPerSecond() <= 0 {
+		t.Errorf("failed to measure the lookups: %v", r.MatchDuration)
+	}
+
+	calls := make(map[string]FilterStats)
+	for _, s := range r.Filters {
+		calls[s.Name] = s
+	}
+
+	// request and response
+	if s := calls["setRequestHeader"]; s.Calls != 2*int64(r.RouteHits["api"]+r.RouteHits["post"]) {
+		t.Errorf("unexpected calls of setRequestHeader: %d", s.Calls)
+	}
+
+	if s := calls["status"]; s.Calls != 2*int64(r.RouteHits["blocked"]) || s.AllocCalls == 0 {
+		t.Errorf("unexpected calls of status: %d, %d", s.Calls, s.AllocCalls)
+	}
+
+	var b bytes.Buffer
+	if _, err := r.WriteTo(&b); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, s := range []string{"invalid route invalid:", "requests: 600", "route lookup:", "setResponseHeader"} {
+		if !strings.Contains(b.String(), s) {
+			t.Errorf("missing from the report: %q\n%s", s, b.String())
+		}
+	}
+}
+
+func TestRunSameSeed(t *testing.T) {
+	o := Options{
+		Routes: testRoutes,
+		Requests: []Request{
+			{URL: "https://www.example.org/api/items"},
+			{URL: "https://www.example.org/blocked"},
+		},
+		N:           100,
+		Seed:        42,
+		SkipFilters: true,
+	}
+
+	r1, err := Run(o)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r2, err := Run(o)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if r1.RouteHits["api"] != r2.RouteHits["api"] || len(r1.Filters) != 0 {
+		t.Errorf("unexpected results: %v, %v, %d", r1.RouteHits, r2.RouteHits, len(r1.Filters))
+	}
+}
+
+func TestRunInvalid(t *testing.T) {
+	for _, o := range []Options{
+		{Routes: testRoutes},
+		{Routes: "foo", Requests: []Request{{URL: "https://www.example.org"}}},
+		{Routes: testRoutes, Requests: []Request{{URL: "https://www.example.org", Weight: -1}}},
+		{Routes: testRoutes, Requests: []Request{{URL: "://"}}},
+	} {
+		if _, err := Run(o); err == nil {
+			t.Errorf("failed to fail: %+v", o)
+		}
+	}
+}
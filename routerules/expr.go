@@ -0,0 +1,384 @@
+package routerules
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/zalando/skipper/eskip"
+)
+
+type tokenType int
+
+const (
+	tokenEOF tokenType = iota
+	tokenIdent
+	tokenString
+	tokenAnd
+	tokenOr
+	tokenNot
+	tokenEq
+	tokenNeq
+	tokenMatch
+	tokenNotMatch
+	tokenOpen
+	tokenClose
+)
+
+type token struct {
+	typ tokenType
+	val string
+	pos int
+}
+
+type fieldFunc func(*eskip.Route) []string
+
+// evaluates a condition on a canonical route
+type expr func(*eskip.Route) bool
+
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+var fields = map[string]fieldFunc{
+	"id":          func(r *eskip.Route) []string { return []string{r.Id} },
+	"host":        predicateArgs("Host", "HostAny"),
+	"path":        predicateArgs("Path", "PathSubtree", "PathRegexp"),
+	"method":      predicateArgs("Method", "Methods"),
+	"backend":     backendAddresses,
+	"backendType": func(r *eskip.Route) []string { return []string{r.BackendType.String()} },
+}
+
+var functions = map[string]func(string) expr{
+	"hasFilter": func(name string) expr {
+		return func(r *eskip.Route) bool {
+			for _, f := range r.Filters {
+				if f.Name == name {
+					return true
+				}
+			}
+
+			return false
+		}
+	},
+	"hasPredicate": func(name string) expr {
+		return func(r *eskip.Route) bool {
+			for _, p := range r.Predicates {
+				if p.Name == name {
+					return true
+				}
+			}
+
+			return false
+		}
+	},
+}
+
+func predicateArgs(names ...string) fieldFunc {
+	return func(r *eskip.Route) []string {
+		var values []string
+		for _, p := range r.Predicates {
+			for _, n := range names {
+				if p.Name != n {
+					continue
+				}
+
+				for _, a := range p.Args {
+					if s, ok := a.(string); ok {
+						values = append(values, s)
+					}
+				}
+			}
+		}
+
+		return values
+	}
+}
+
+func backendAddresses(r *eskip.Route) []string {
+	if r.BackendType == eskip.LBBackend {
+		return r.LBEndpoints
+	}
+
+	if r.Backend == "" {
+		return nil
+	}
+
+	return []string{r.Backend}
+}
+
+func isIdentRune(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+func lex(s string) ([]token, error) {
+	var tokens []token
+	operators := []struct {
+		val string
+		typ tokenType
+	}{
+		{"&&", tokenAnd},
+		{"||", tokenOr},
+		{"==", tokenEq},
+		{"!=", tokenNeq},
+		{"=~", tokenMatch},
+		{"!~", tokenNotMatch},
+		{"!", tokenNot},
+		{"(", tokenOpen},
+		{")", tokenClose},
+	}
+
+	for i := 0; i < len(s); {
+		c := rune(s[i])
+		switch {
+		case unicode.IsSpace(c):
+			i++
+			continue
+		case c == '"' || c == '`':
+			end := i + 1
+			for end < len(s) && rune(s[end]) != c {
+				if c == '"' && s[end] == '\\' {
+					end++
+				}
+
+				end++
+			}
+
+			if end >= len(s) {
+				return nil, fmt.Errorf("unterminated string at %d", i)
+			}
+
+			v, err := strconv.Unquote(s[i : end+1])
+			if err != nil {
+				return nil, fmt.Errorf("invalid string at %d: %w", i, err)
+			}
+
+			tokens = append(tokens, token{typ: tokenString, val: v, pos: i})
+			i = end + 1
+			continue
+		case isIdentRune(c):
+			end := i
+			for end < len(s) && isIdentRune(rune(s[end])) {
+				end++
+			}
+
+			tokens = append(tokens, token{typ: tokenIdent, val: s[i:end], pos: i})
+			i = end
+			continue
+		}
+
+		var found bool
+		for _, o := range operators {
+			if strings.HasPrefix(s[i:], o.val) {
+				tokens = append(tokens, token{typ: o.typ, val: o.val, pos: i})
+				i += len(o.val)
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			return nil, fmt.Errorf("unexpected character at %d: %q", i, c)
+		}
+	}
+
+	return append(tokens, token{typ: tokenEOF, pos: len(s)}), nil
+}
+
+// parseExpr parses a match expression, e.g.
+// `host =~ "example" && !hasFilter("oauthTokeninfoAnyScope")`.
+func parseExpr(s string) (expr, error) {
+	tokens, err := lex(s)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &parser{tokens: tokens}
+	e, err := p.or()
+	if err != nil {
+		return nil, err
+	}
+
+	if t := p.peek(); t.typ != tokenEOF {
+		return nil, fmt.Errorf("unexpected %q at %d", t.val, t.pos)
+	}
+
+	return e, nil
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	if t.typ != tokenEOF {
+		p.pos++
+	}
+
+	return t
+}
+
+func (p *parser) expect(typ tokenType, what string) (token, error) {
+	t := p.next()
+	if t.typ != typ {
+		if t.typ == tokenEOF {
+			return t, fmt.Errorf("expected %s at the end of the expression", what)
+		}
+
+		return t, fmt.Errorf("expected %s at %d, got %q", what, t.pos, t.val)
+	}
+
+	return t, nil
+}
+
+func (p *parser) or() (expr, error) {
+	left, err := p.and()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.peek().typ == tokenOr {
+		p.next()
+		right, err := p.and()
+		if err != nil {
+			return nil, err
+		}
+
+		l := left
+		left = func(r *eskip.Route) bool { return l(r) || right(r) }
+	}
+
+	return left, nil
+}
+
+func (p *parser) and() (expr, error) {
+	left, err := p.unary()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.peek().typ == tokenAnd {
+		p.next()
+		right, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+
+		l := left
+		left = func(r *eskip.Route) bool { return l(r) && right(r) }
+	}
+
+	return left, nil
+}
+
+func (p *parser) unary() (expr, error) {
+	if p.peek().typ == tokenNot {
+		p.next()
+		e, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+
+		return func(r *eskip.Route) bool { return !e(r) }, nil
+	}
+
+	return p.primary()
+}
+
+func (p *parser) primary() (expr, error) {
+	t := p.next()
+	switch t.typ {
+	case tokenOpen:
+		e, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+
+		if _, err := p.expect(tokenClose, "')'"); err != nil {
+			return nil, err
+		}
+
+		return e, nil
+	case tokenIdent:
+	case tokenEOF:
+		return nil, fmt.Errorf("unexpected end of the expression")
+	default:
+		return nil, fmt.Errorf("unexpected %q at %d", t.val, t.pos)
+	}
+
+	switch t.val {
+	case "true":
+		return func(*eskip.Route) bool { return true }, nil
+	case "false":
+		return func(*eskip.Route) bool { return false }, nil
+	}
+
+	if f, ok := functions[t.val]; ok {
+		if _, err := p.expect(tokenOpen, "'('"); err != nil {
+			return nil, err
+		}
+
+		arg, err := p.expect(tokenString, "string argument")
+		if err != nil {
+			return nil, err
+		}
+
+		if _, err := p.expect(tokenClose, "')'"); err != nil {
+			return nil, err
+		}
+
+		return f(arg.val), nil
+	}
+
+	field, ok := fields[t.val]
+	if !ok {
+		return nil, fmt.Errorf("unknown field or function at %d: %s", t.pos, t.val)
+	}
+
+	return p.comparison(field)
+}
+
+func (p *parser) comparison(field fieldFunc) (expr, error) {
+	op := p.next()
+	value, err := p.expect(tokenString, "string value")
+	if err != nil {
+		return nil, err
+	}
+
+	var match func(string) bool
+	switch op.typ {
+	case tokenEq, tokenNeq:
+		match = func(s string) bool { return s == value.val }
+	case tokenMatch, tokenNotMatch:
+		rx, err := regexp.Compile(value.val)
+		if err != nil {
+			return nil, fmt.Errorf("invalid regular expression at %d: %w", value.pos, err)
+		}
+
+		match = rx.MatchString
+	default:
+		return nil, fmt.Errorf("expected comparison operator at %d, got %q", op.pos, op.val)
+	}
+
+	// the fields can have multiple values, e.g. multiple Host predicates,
+	// and the comparison is true when any of the values matches
+	anyMatch := func(r *eskip.Route) bool {
+		for _, v := range field(r) {
+			if match(v) {
+				return true
+			}
+		}
+
+		return false
+	}
+
+	if op.typ == tokenNeq || op.typ == tokenNotMatch {
+		return func(r *eskip.Route) bool { return !anyMatch(r) }, nil
+	}
+
+	return anyMatch, nil
+}
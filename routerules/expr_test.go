@@ -0,0 +1,96 @@
+package routerules
+
+import (
+	"testing"
+
+	"github.com/zalando/skipper/eskip"
+)
+
+func TestExpr(t *testing.T) {
+	routes, err := eskip.Parse(`
+		r1: Host("^www[.]example[.]org$") && Path("/foo") && Method("GET") -> setPath("/bar") -> "https://backend.example.org";
+		r2: HostAny("api.example.org", "api.example.com") && PathSubtree("/api") -> <roundRobin, "http://10.0.0.1", "http://10.0.0.2">;
+		r3: * -> status(404) -> <shunt>;
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range []struct {
+		expr    string
+		matches []string
+	}{
+		{`true`, []string{"r1", "r2", "r3"}},
+		{`false`, nil},
+		{`id == "r2"`, []string{"r2"}},
+		{`id != "r2"`, []string{"r1", "r3"}},
+		{`host =~ "org"`, []string{"r1", "r2"}},
+		{`host !~ "org"`, []string{"r3"}},
+		{`host == "api.example.com"`, []string{"r2"}},
+		{`path == "/foo"`, []string{"r1"}},
+		{`path =~ "^/api"`, []string{"r2"}},
+		{`method == "GET"`, []string{"r1"}},
+		{`backend == "http://10.0.0.2"`, []string{"r2"}},
+		{`backend =~ "^https://"`, []string{"r1"}},
+		{`backendType == "shunt"`, []string{"r3"}},
+		{`backendType == "lb"`, []string{"r2"}},
+		{`hasFilter("status")`, []string{"r3"}},
+		{`!hasFilter("status")`, []string{"r1", "r2"}},
+		{`hasPredicate("PathSubtree")`, []string{"r2"}},
+		{`id == "r1" || id == "r3"`, []string{"r1", "r3"}},
+		{`host =~ "example" && !(path == "/foo")`, []string{"r2"}},
+		{`!id == "r1" || false`, []string{"r2", "r3"}},
+		{"id == `r1`", []string{"r1"}},
+		{`id == "r\x31"`, []string{"r1"}},
+	} {
+		t.Run(test.expr, func(t *testing.T) {
+			e, err := parseExpr(test.expr)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var matches []string
+			for _, r := range routes {
+				if e(eskip.Canonical(r)) {
+					matches = append(matches, r.Id)
+				}
+			}
+
+			if len(matches) != len(test.matches) {
+				t.Fatalf("expected %v, got %v", test.matches, matches)
+			}
+
+			for i := range matches {
+				if matches[i] != test.matches[i] {
+					t.Fatalf("expected %v, got %v", test.matches, matches)
+				}
+			}
+		})
+	}
+}
+
+func TestExprInvalid(t *testing.T) {
+	for _, expr := range []string{
+		``,
+		`id`,
+		`id ==`,
+		`id == r1`,
+		`id == "r1`,
+		`id < "r1"`,
+		`foo == "bar"`,
+		`host =~ "("`,
+		`hasFilter()`,
+		`hasFilter("status"`,
+		`(true`,
+		`true)`,
+		`true &&`,
+		`true false`,
+		`id == "r1" # comment`,
+	} {
+		t.Run(expr, func(t *testing.T) {
+			if _, err := parseExpr(expr); err == nil {
+				t.Error("failed to fail")
+			}
+		})
+	}
+}
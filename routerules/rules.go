@@ -0,0 +1,236 @@
+/*
+Package routerules implements editing the routes with rules loaded from
+a file. The rules select the routes with a small expression language,
+and prepend or append filters to the selected routes. The file is
+reloaded when it changes, and the new rules are applied to all the
+routes, without restarting skipper.
+
+The rules file is in YAML format, and the rules are applied in order:
+
+	# rules.yaml
+	- name: access-log
+	  match: host =~ "example" && !hasFilter("enableAccessLog")
+	  prepend: enableAccessLog(4, 5)
+	- name: shunted
+	  match: backendType == "shunt"
+	  append: status(404)
+
+The match expressions support the following fields, compared to string
+literals with the ==, !=, =~ (regular expression match) and !~
+operators:
+
+	id           the route id
+	host         the arguments of the Host and HostAny predicates
+	path         the arguments of the Path, PathSubtree and PathRegexp predicates
+	method       the arguments of the Method and Methods predicates
+	backend      the backend address, or the endpoints of a load balanced backend
+	backendType  one of network, shunt, loopback, dynamic, lb or tcp
+
+When a field has multiple values, == and =~ are true if any of the values
+matches, while != and !~ are true if none of them matches. The values are
+the predicate arguments as they appear in the routes, e.g. the regular
+expressions of the Host predicates. The functions hasFilter("name") and
+hasPredicate("name") check whether the route contains a filter or a
+predicate with the given name. The conditions can be combined with &&, ||
+and !, and grouped with parentheses. A rule without a match expression
+applies to all the routes.
+*/
+package routerules
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"sync/atomic"
+
+	log "github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v2"
+
+	"github.com/zalando/skipper/eskip"
+	"github.com/zalando/skipper/routing"
+)
+
+// the routing reprocesses all the routes when a data client reports a
+// change, and the rules report the reload as the deletion of this id,
+// which never matches a route
+const reloadID = "__routerules_reload"
+
+// Rule is the definition of a rule in the rules file.
+type Rule struct {
+	Name    string `yaml:"name"`
+	Match   string `yaml:"match"`
+	Prepend string `yaml:"prepend"`
+	Append  string `yaml:"append"`
+}
+
+type rule struct {
+	name    string
+	match   expr
+	prepend []*eskip.Filter
+	append  []*eskip.Filter
+}
+
+// Rules edits the routes with the rules loaded from a file. It needs to
+// be set both as a routing pre-processor, and as a data client, through
+// which the changes of the file are detected.
+type Rules struct {
+	file    string
+	rules   atomic.Value // []*rule
+	mu      sync.Mutex
+	content []byte
+}
+
+var (
+	_ routing.PreProcessor = &Rules{}
+	_ routing.DataClient   = &Rules{}
+)
+
+func parse(data []byte) ([]*rule, error) {
+	var defs []Rule
+	if err := yaml.UnmarshalStrict(data, &defs); err != nil {
+		return nil, fmt.Errorf("failed to parse route rules: %w", err)
+	}
+
+	rules := make([]*rule, len(defs))
+	for i, d := range defs {
+		name := d.Name
+		if name == "" {
+			name = fmt.Sprintf("#%d", i)
+		}
+
+		r := &rule{name: name}
+		if d.Match == "" {
+			r.match = func(*eskip.Route) bool { return true }
+		} else {
+			m, err := parseExpr(d.Match)
+			if err != nil {
+				return nil, fmt.Errorf("invalid match expression in rule %s: %w", name, err)
+			}
+
+			r.match = m
+		}
+
+		var err error
+		if r.prepend, err = eskip.ParseFilters(d.Prepend); err != nil {
+			return nil, fmt.Errorf("invalid prepend filters in rule %s: %w", name, err)
+		}
+
+		if r.append, err = eskip.ParseFilters(d.Append); err != nil {
+			return nil, fmt.Errorf("invalid append filters in rule %s: %w", name, err)
+		}
+
+		if len(r.prepend) == 0 && len(r.append) == 0 {
+			return nil, fmt.Errorf("rule %s has no filters to prepend or append", name)
+		}
+
+		rules[i] = r
+	}
+
+	return rules, nil
+}
+
+// New loads the rules from a file. It fails when the file cannot be read,
+// or it contains invalid rules.
+func New(file string) (*Rules, error) {
+	if file == "" {
+		return nil, errors.New("missing route rules file")
+	}
+
+	r := &Rules{file: file}
+	if _, err := r.reload(); err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+// reload reads the file, and replaces the rules when it has changed. It
+// keeps the previous rules when the file cannot be read, or is invalid.
+func (r *Rules) reload() (bool, error) {
+	data, err := os.ReadFile(r.file)
+	if err != nil {
+		return false, fmt.Errorf("failed to read route rules: %w", err)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.content != nil && bytes.Equal(data, r.content) {
+		return false, nil
+	}
+
+	rules, err := parse(data)
+	if err != nil {
+		return false, err
+	}
+
+	r.content = data
+	r.rules.Store(rules)
+	return true, nil
+}
+
+// LoadAll implements routing.DataClient. It returns no routes, it only
+// checks the file for changes.
+func (r *Rules) LoadAll() ([]*eskip.Route, error) {
+	if _, err := r.reload(); err != nil {
+		log.Errorf("Failed to reload route rules from %s, keeping the previous rules: %v", r.file, err)
+	}
+
+	return nil, nil
+}
+
+// LoadUpdate implements routing.DataClient. When the file has changed, it
+// reports an update, so that the routes are processed with the new
+// rules.
+func (r *Rules) LoadUpdate() ([]*eskip.Route, []string, error) {
+	changed, err := r.reload()
+	if err != nil {
+		log.Errorf("Failed to reload route rules from %s, keeping the previous rules: %v", r.file, err)
+		return nil, nil, nil
+	}
+
+	if !changed {
+		return nil, nil, nil
+	}
+
+	log.Infof("Route rules reloaded from %s", r.file)
+	return nil, []string{reloadID}, nil
+}
+
+// Do implements routing.PreProcessor. It returns the routes with the
+// filters of the matching rules prepended and appended. The matched
+// routes are copied, the input routes are not modified.
+func (r *Rules) Do(routes []*eskip.Route) []*eskip.Route {
+	rules, _ := r.rules.Load().([]*rule)
+	if len(rules) == 0 {
+		return routes
+	}
+
+	result := make([]*eskip.Route, len(routes))
+	for i, route := range routes {
+		for _, rl := range rules {
+			// the expressions are evaluated on the canonical form, so
+			// that e.g. the legacy shunt flag is seen as the shunt
+			// backend type
+			if !rl.match(eskip.Canonical(route)) {
+				continue
+			}
+
+			next := new(eskip.Route)
+			*next = *route
+
+			filters := make([]*eskip.Filter, 0, len(rl.prepend)+len(route.Filters)+len(rl.append))
+			filters = append(filters, eskip.CopyFilters(rl.prepend)...)
+			filters = append(filters, route.Filters...)
+			filters = append(filters, eskip.CopyFilters(rl.append)...)
+			next.Filters = filters
+
+			route = next
+		}
+
+		result[i] = route
+	}
+
+	return result
+}
@@ -0,0 +1,161 @@
+package routerules
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/zalando/skipper/eskip"
+)
+
+const testRules = `
+- name: access-log
+  match: host =~ "example" && !hasFilter("enableAccessLog")
+  prepend: enableAccessLog(4, 5)
+- match: backendType == "shunt"
+  append: status(404)
+- name: all
+  append: setResponseHeader("X-Rules", "applied")
+`
+
+func writeRules(t *testing.T, file, rules string) {
+	t.Helper()
+	if err := os.WriteFile(file, []byte(rules), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func routeFilters(t *testing.T, routes []*eskip.Route) map[string]string {
+	t.Helper()
+	m := make(map[string]string)
+	for _, r := range routes {
+		m[r.Id] = eskip.Print(eskip.PrettyPrintInfo{}, &eskip.Route{Filters: r.Filters, BackendType: eskip.ShuntBackend})
+	}
+
+	return m
+}
+
+func TestRules(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "rules.yaml")
+	writeRules(t, file, testRules)
+
+	r, err := New(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	routes, err := eskip.Parse(`
+		r1: Host("www[.]example[.]org$") -> setPath("/foo") -> "https://backend.example.org";
+		r2: Host("www[.]example[.]org$") && Path("/log") -> enableAccessLog() -> "https://backend.example.org";
+		r3: * -> <shunt>;
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	original := eskip.Print(eskip.PrettyPrintInfo{}, routes...)
+	processed := r.Do(routes)
+	if eskip.Print(eskip.PrettyPrintInfo{}, routes...) != original {
+		t.Error("the input routes were modified")
+	}
+
+	got := routeFilters(t, processed)
+	expected := map[string]string{
+		"r1": `* -> enableAccessLog(4, 5) -> setPath("/foo") -> setResponseHeader("X-Rules", "applied") -> <shunt>`,
+		"r2": `* -> enableAccessLog() -> setResponseHeader("X-Rules", "applied") -> <shunt>`,
+		"r3": `* -> status(404) -> setResponseHeader("X-Rules", "applied") -> <shunt>`,
+	}
+
+	for id, e := range expected {
+		if got[id] != e {
+			t.Errorf("%s: expected %s, got %s", id, e, got[id])
+		}
+	}
+}
+
+func TestRulesInvalid(t *testing.T) {
+	for _, test := range []struct {
+		title string
+		rules string
+	}{{
+		title: "invalid yaml",
+		rules: "- name: [",
+	}, {
+		title: "unknown field",
+		rules: "- name: foo\n  prepand: status(200)",
+	}, {
+		title: "invalid match",
+		rules: "- match: host ==\n  append: status(200)",
+	}, {
+		title: "invalid filters",
+		rules: "- append: status(",
+	}, {
+		title: "no filters",
+		rules: "- match: 'true'",
+	}} {
+		t.Run(test.title, func(t *testing.T) {
+			file := filepath.Join(t.TempDir(), "rules.yaml")
+			writeRules(t, file, test.rules)
+			if _, err := New(file); err == nil {
+				t.Error("failed to fail")
+			}
+		})
+	}
+
+	if _, err := New(filepath.Join(t.TempDir(), "missing.yaml")); err == nil {
+		t.Error("failed to fail with missing file")
+	}
+}
+
+func TestRulesReload(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "rules.yaml")
+	writeRules(t, file, "- append: status(200)")
+
+	r, err := New(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	routes, err := eskip.Parse(`r: * -> <shunt>`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	check := func(expected string) {
+		t.Helper()
+		if got := routeFilters(t, r.Do(routes))["r"]; got != expected {
+			t.Errorf("expected %s, got %s", expected, got)
+		}
+	}
+
+	if routes, err := r.LoadAll(); err != nil || len(routes) != 0 {
+		t.Fatalf("unexpected result: %v, %v", routes, err)
+	}
+
+	if _, deleted, err := r.LoadUpdate(); err != nil || len(deleted) != 0 {
+		t.Fatalf("unexpected update without change: %v, %v", deleted, err)
+	}
+
+	check(`* -> status(200) -> <shunt>`)
+
+	writeRules(t, file, "- append: status(201)")
+	if _, deleted, err := r.LoadUpdate(); err != nil || len(deleted) != 1 {
+		t.Fatalf("failed to report the change: %v, %v", deleted, err)
+	}
+
+	check(`* -> status(201) -> <shunt>`)
+
+	writeRules(t, file, "- append: status(")
+	if _, deleted, err := r.LoadUpdate(); err != nil || len(deleted) != 0 {
+		t.Fatalf("unexpected update with invalid rules: %v, %v", deleted, err)
+	}
+
+	check(`* -> status(201) -> <shunt>`)
+
+	os.Remove(file)
+	if _, deleted, err := r.LoadUpdate(); err != nil || len(deleted) != 0 {
+		t.Fatalf("unexpected update with missing file: %v, %v", deleted, err)
+	}
+
+	check(`* -> status(201) -> <shunt>`)
+}
@@ -0,0 +1,76 @@
+package routing_test
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/zalando/skipper/filters/builtin"
+	"github.com/zalando/skipper/logging/loggingtest"
+	"github.com/zalando/skipper/metrics/metricstest"
+	"github.com/zalando/skipper/routing"
+	"github.com/zalando/skipper/routing/testdataclient"
+)
+
+func TestRouteAnnotations(t *testing.T) {
+	l := loggingtest.New()
+	defer l.Close()
+
+	dc, err := testdataclient.NewDoc(`
+		foo: Path("/foo") -> annotate("team", "checkout") -> annotate("slo", "silver") -> annotate("slo", "gold") -> <shunt>;
+		bar: Path("/bar") -> <shunt>;
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m := &metricstest.MockMetrics{}
+	rt := routing.New(routing.Options{
+		FilterRegistry: builtin.MakeRegistry(),
+		DataClients:    []routing.DataClient{dc},
+		PollTimeout:    12 * time.Millisecond,
+		Log:            l,
+		Metrics:        m,
+	})
+	defer rt.Close()
+
+	if err := l.WaitFor("route settings applied", 120*time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+
+	r, _ := rt.Route(&http.Request{URL: &url.URL{Path: "/foo"}})
+	if r == nil || len(r.Annotations) != 2 || r.Annotations["team"] != "checkout" || r.Annotations["slo"] != "gold" {
+		t.Fatalf("unexpected annotations: %v", r)
+	}
+
+	if r, _ := rt.Route(&http.Request{URL: &url.URL{Path: "/bar"}}); r == nil || r.Annotations != nil {
+		t.Fatalf("unexpected annotations: %v", r)
+	}
+
+	checkGauge := func(t *testing.T, key string, expected float64) {
+		t.Helper()
+		if v, ok := m.Gauge(key); !ok || v != expected {
+			t.Errorf("unexpected gauge %s: %v, %v", key, v, ok)
+		}
+	}
+
+	checkGauge(t, "route.metadata.foo.team.checkout", 1)
+	checkGauge(t, "route.metadata.foo.slo.gold", 1)
+	if _, ok := m.Gauge("route.metadata.foo.slo.silver"); ok {
+		t.Error("unexpected gauge of an overridden annotation")
+	}
+
+	l.Reset()
+	if err := dc.UpdateDoc(`foo: Path("/foo") -> annotate("team", "payment") -> <shunt>`, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := l.WaitFor("route settings applied", 120*time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+
+	checkGauge(t, "route.metadata.foo.team.payment", 1)
+	checkGauge(t, "route.metadata.foo.team.checkout", 0)
+	checkGauge(t, "route.metadata.foo.slo.gold", 0)
+}
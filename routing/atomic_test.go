@@ -0,0 +1,137 @@
+package routing_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/zalando/skipper/filters/builtin"
+	"github.com/zalando/skipper/logging/loggingtest"
+	"github.com/zalando/skipper/metrics/metricstest"
+	"github.com/zalando/skipper/routing"
+	"github.com/zalando/skipper/routing/testdataclient"
+)
+
+func TestAtomicRouteUpdates(t *testing.T) {
+	l := loggingtest.New()
+	defer l.Close()
+
+	dc, err := testdataclient.NewDoc(`
+		foo: Path("/foo") -> setPath("/") -> "https://foo.example.org";
+		bar: Path("/bar") -> setPath("/") -> "https://bar.example.org";
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m := &metricstest.MockMetrics{}
+	rt := routing.New(routing.Options{
+		FilterRegistry:     builtin.MakeRegistry(),
+		DataClients:        []routing.DataClient{dc},
+		PollTimeout:        12 * time.Millisecond,
+		Log:                l,
+		AtomicRouteUpdates: true,
+		Metrics:            m,
+	})
+	defer rt.Close()
+
+	server := httptest.NewServer(rt)
+	defer server.Close()
+
+	checkBackend := func(t *testing.T, path, backend string) {
+		t.Helper()
+		r, _ := rt.Route(&http.Request{URL: &url.URL{Path: path}})
+		if r == nil {
+			t.Fatalf("route not found for %s", path)
+		}
+
+		if r.Backend != backend {
+			t.Errorf("invalid backend for %s, got: %s, expected: %s", path, r.Backend, backend)
+		}
+	}
+
+	getErrors := func(t *testing.T) (map[string]string, http.Header) {
+		t.Helper()
+		req, _ := http.NewRequest("GET", server.URL+"?errors", nil)
+		req.Header.Set("Accept", "application/json")
+		rsp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		defer rsp.Body.Close()
+		var errs map[string]string
+		if err := json.NewDecoder(rsp.Body).Decode(&errs); err != nil {
+			t.Fatal(err)
+		}
+
+		return errs, rsp.Header
+	}
+
+	if err := l.WaitFor("route settings applied", 120*time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+
+	checkBackend(t, "/foo", "https://foo.example.org")
+	checkBackend(t, "/bar", "https://bar.example.org")
+
+	l.Reset()
+	if err := dc.UpdateDoc(`
+		foo: Path("/foo") -> setPath("/") -> "https://foo-new.example.org";
+		bar: Path("/bar") -> setPath("/") -> noSuchFilter() -> "https://bar-new.example.org";
+	`, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := l.WaitFor("route settings rejected", 120*time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+
+	checkBackend(t, "/foo", "https://foo.example.org")
+	checkBackend(t, "/bar", "https://bar.example.org")
+
+	errs, h := getErrors(t)
+	if len(errs) != 1 || errs["bar"] == "" {
+		t.Errorf("unexpected route errors: %v", errs)
+	}
+
+	if h.Get("X-Update-Rejected") != "true" || h.Get("X-Invalid-Count") != "1" {
+		t.Errorf("unexpected headers: %v", h)
+	}
+
+	if v, ok := m.Gauge("route.invalid.bar"); !ok || v != 1 {
+		t.Errorf("invalid route not reported, got: %v", v)
+	}
+
+	m.WithCounters(func(c map[string]int64) {
+		if c["routes.update.rejected"] != 1 {
+			t.Errorf("rejected update not reported, got: %d", c["routes.update.rejected"])
+		}
+	})
+
+	l.Reset()
+	if err := dc.UpdateDoc(`
+		bar: Path("/bar") -> setPath("/") -> "https://bar-new.example.org";
+	`, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := l.WaitFor("route settings applied", 120*time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+
+	checkBackend(t, "/foo", "https://foo-new.example.org")
+	checkBackend(t, "/bar", "https://bar-new.example.org")
+
+	errs, h = getErrors(t)
+	if len(errs) != 0 || h.Get("X-Update-Rejected") != "" {
+		t.Errorf("unexpected route errors after recovery: %v, %v", errs, h)
+	}
+
+	if v, _ := m.Gauge("route.invalid.bar"); v != 0 {
+		t.Errorf("invalid route gauge not reset, got: %v", v)
+	}
+}
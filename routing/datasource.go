@@ -4,12 +4,16 @@ import (
 	"errors"
 	"fmt"
 	"net/url"
+	"runtime"
 	"sort"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/zalando/skipper/eskip"
 	"github.com/zalando/skipper/filters"
 	"github.com/zalando/skipper/logging"
+	"github.com/zalando/skipper/metrics"
 	"github.com/zalando/skipper/predicates"
 )
 
@@ -20,7 +24,22 @@ const (
 	incomingUpdate
 )
 
-var errInvalidWeightParams = errors.New("invalid argument for the Weight predicate")
+const (
+	invalidRouteMetricPrefix = "route.invalid."
+	routeMetadataPrefix      = "route.metadata."
+	invalidRoutesMetric      = "routes.invalid"
+	rejectedUpdatesMetric    = "routes.update.rejected"
+
+	// route definition sets smaller than this are always processed
+	// sequentially
+	minConcurrentProcessSize = 1 << 10
+	processBatchSize         = 64
+)
+
+var (
+	errInvalidWeightParams = errors.New("invalid argument for the Weight predicate")
+	errInvalidPriority     = errors.New("invalid route priority")
+)
 
 func (it incomingType) String() string {
 	switch it {
@@ -63,8 +82,9 @@ func (d *incomingData) log(l logging.Logger, suppress bool) {
 // whole current set of routes, and continues polling for the subsequent updates. When a
 // communication error occurs, it re-requests the whole valid set, and continues polling.
 // Currently, the routes with the same id coming from different sources are merged in an
-// undeterministic way, but this may change in the future.
-func receiveFromClient(c DataClient, o Options, out chan<- *incomingData, quit <-chan struct{}) {
+// undeterministic way, but this may change in the future. The received definitions
+// are passed on as copies with interned strings.
+func receiveFromClient(c DataClient, o Options, interned *interner, out chan<- *incomingData, quit <-chan struct{}) {
 	initial := true
 	for {
 		var (
@@ -89,6 +109,8 @@ func receiveFromClient(c DataClient, o Options, out chan<- *incomingData, quit <
 			initial = true
 			to = 0
 		case initial || len(routes) > 0 || len(deletedIDs) > 0:
+			routes = interned.defs(routes)
+
 			var incoming *incomingData
 			if initial {
 				incoming = &incomingData{incomingReset, c, routes, nil}
@@ -112,38 +134,33 @@ func receiveFromClient(c DataClient, o Options, out chan<- *incomingData, quit <
 	}
 }
 
-// applies incoming route definitions to key/route map, where
-// the keys are the route ids.
-func applyIncoming(defs routeDefs, d *incomingData) routeDefs {
-	if d.typ == incomingReset || defs == nil {
-		defs = make(routeDefs)
-	}
+// applies incoming route definitions to the copy-on-write shards of a
+// data client, where the keys are the route ids.
+func applyIncoming(defs *routeShards, d *incomingData) *routeShards {
+	return defs.apply(d)
+}
 
-	if d.typ == incomingUpdate {
-		for _, id := range d.deletedIds {
-			delete(defs, id)
-		}
-	}
+// merges the route definitions from multiple data clients by route id
+func mergeDefs(defsByClient map[DataClient]*routeShards) []*eskip.Route {
+	if len(defsByClient) == 1 {
+		for _, defs := range defsByClient {
+			all := make([]*eskip.Route, 0, defs.len())
+			defs.each(func(_ string, def *eskip.Route) {
+				all = append(all, def)
+			})
 
-	if d.typ == incomingReset || d.typ == incomingUpdate {
-		for _, def := range d.upsertedRoutes {
-			defs[def.Id] = def
+			return all
 		}
 	}
 
-	return defs
-}
-
-// merges the route definitions from multiple data clients by route id
-func mergeDefs(defsByClient map[DataClient]routeDefs) []*eskip.Route {
 	mergeByID := make(routeDefs)
 	for _, defs := range defsByClient {
-		for id, def := range defs {
+		defs.each(func(id string, def *eskip.Route) {
 			mergeByID[id] = def
-		}
+		})
 	}
 
-	var all []*eskip.Route
+	all := make([]*eskip.Route, 0, len(mergeByID))
 	for _, def := range mergeByID {
 		all = append(all, def)
 	}
@@ -160,10 +177,11 @@ func mergeDefs(defsByClient map[DataClient]routeDefs) []*eskip.Route {
 func receiveRouteDefs(o Options, quit <-chan struct{}) <-chan []*eskip.Route {
 	in := make(chan *incomingData)
 	out := make(chan []*eskip.Route)
-	defsByClient := make(map[DataClient]routeDefs)
+	defsByClient := make(map[DataClient]*routeShards)
+	interned := newInterner()
 
 	for _, c := range o.DataClients {
-		go receiveFromClient(c, o, in, quit)
+		go receiveFromClient(c, o, interned, in, quit)
 	}
 
 	go func() {
@@ -178,9 +196,11 @@ func receiveRouteDefs(o Options, quit <-chan struct{}) <-chan []*eskip.Route {
 			incoming.log(o.Log, o.SuppressLogs)
 			c := incoming.client
 			defsByClient[c] = applyIncoming(defsByClient[c], incoming)
+			defs := mergeDefs(defsByClient)
+			interned.retain(defs)
 
 			select {
-			case out <- mergeDefs(defsByClient):
+			case out <- defs:
 			case <-quit:
 				return
 			}
@@ -203,12 +223,38 @@ func splitBackend(r *eskip.Route) (string, string, error) {
 		return "", "", err
 	}
 
-	return bu.Scheme, bu.Host, nil
+	host, err := EndpointHost(bu)
+	if err != nil {
+		return "", "", err
+	}
+
+	return bu.Scheme, host, nil
+}
+
+// EndpointHost returns the host part of a backend address. For the unix
+// domain socket backends, e.g. unix:///var/run/app.sock, it returns the
+// path of the socket.
+func EndpointHost(u *url.URL) (string, error) {
+	if u.Scheme != "unix" {
+		return u.Host, nil
+	}
+
+	if u.Host != "" || u.Path == "" {
+		return "", fmt.Errorf("invalid unix domain socket address: %s", u)
+	}
+
+	return u.Path, nil
 }
 
 // creates a filter instance based on its definition and its
 // specification in the filter registry.
 func createFilter(fr filters.Registry, def *eskip.Filter, cpm map[string]PredicateSpec) (filters.Filter, error) {
+	return createFilterLazy(fr, def, cpm, nil, "")
+}
+
+// creates a filter instance, or defers the creation when the filter is
+// configured as lazy. The existence of the filter is always checked.
+func createFilterLazy(fr filters.Registry, def *eskip.Filter, cpm map[string]PredicateSpec, lazy *lazyFilters, routeId string) (filters.Filter, error) {
 	spec, ok := fr[def.Name]
 	if !ok {
 		if isTreePredicate(def.Name) || def.Name == predicates.HostName || def.Name == predicates.PathRegexpName || def.Name == predicates.MethodName || def.Name == predicates.HeaderName || def.Name == predicates.HeaderRegexpName {
@@ -222,6 +268,14 @@ func createFilter(fr filters.Registry, def *eskip.Filter, cpm map[string]Predica
 		return nil, fmt.Errorf("filter %q not found", def.Name)
 	}
 
+	if lazy.deferred(def.Name) {
+		if err := spec.(filters.ArgsValidator).ValidateArgs(def.Args); err != nil {
+			return nil, fmt.Errorf("failed to create filter %q: %w", spec.Name(), err)
+		}
+
+		return lazy.create(spec, routeId, def), nil
+	}
+
 	f, err := spec.CreateFilter(def.Args)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create filter %q: %w", spec.Name(), err)
@@ -231,10 +285,10 @@ func createFilter(fr filters.Registry, def *eskip.Filter, cpm map[string]Predica
 
 // creates filter instances based on their definition
 // and the filter registry.
-func createFilters(fr filters.Registry, defs []*eskip.Filter, cpm map[string]PredicateSpec) ([]*RouteFilter, error) {
+func createFilters(fr filters.Registry, defs []*eskip.Filter, cpm map[string]PredicateSpec, lazy *lazyFilters, routeId string) ([]*RouteFilter, error) {
 	var fs []*RouteFilter
 	for i, def := range defs {
-		f, err := createFilter(fr, def, cpm)
+		f, err := createFilterLazy(fr, def, cpm, lazy, routeId)
 		if err != nil {
 			return nil, err
 		}
@@ -360,15 +414,25 @@ func parseWeightPredicateArgs(args []interface{}) (int, error) {
 
 // initialize predicate instances from their spec with the concrete arguments
 func processPredicates(cpm map[string]PredicateSpec, defs []*eskip.Predicate) ([]Predicate, int, error) {
+	cps, _, weight, err := processPredicatesWithCosts(cpm, nil, defs)
+	return cps, weight, err
+}
+
+// initialize predicate instances, and collect their evaluation costs
+func processPredicatesWithCosts(cpm map[string]PredicateSpec, costs map[string]int, defs []*eskip.Predicate) ([]Predicate, []int, int, error) {
 	cps := make([]Predicate, 0, len(defs))
-	var weight int
+	var (
+		cpCosts []int
+		weight  int
+	)
+
 	for _, def := range defs {
 		if def.Name == predicates.WeightName {
 			var w int
 			var err error
 
 			if w, err = parseWeightPredicateArgs(def.Args); err != nil {
-				return nil, 0, err
+				return nil, nil, 0, err
 			}
 
 			weight += w
@@ -380,14 +444,25 @@ func processPredicates(cpm map[string]PredicateSpec, defs []*eskip.Predicate) ([
 			continue
 		}
 
+		if def.Name == predicates.NotName {
+			cp, err := createNotPredicate(cpm, def.Args)
+			if err != nil {
+				return nil, nil, 0, fmt.Errorf("failed to create predicate %q: %w", def.Name, err)
+			}
+
+			cps = append(cps, cp)
+			cpCosts = append(cpCosts, predicateCost(costs, def.Name))
+			continue
+		}
+
 		spec, ok := cpm[def.Name]
 		if !ok {
-			return nil, 0, fmt.Errorf("predicate %q not found", def.Name)
+			return nil, nil, 0, fmt.Errorf("predicate %q not found", def.Name)
 		}
 
 		cp, err := spec.Create(def.Args)
 		if err != nil {
-			return nil, 0, fmt.Errorf("failed to create predicate %q: %w", spec.Name(), err)
+			return nil, nil, 0, fmt.Errorf("failed to create predicate %q: %w", spec.Name(), err)
 		}
 
 		if ws, ok := spec.(WeightedPredicateSpec); ok {
@@ -395,9 +470,10 @@ func processPredicates(cpm map[string]PredicateSpec, defs []*eskip.Predicate) ([
 		}
 
 		cps = append(cps, cp)
+		cpCosts = append(cpCosts, predicateCost(costs, def.Name))
 	}
 
-	return cps, weight, nil
+	return cps, cpCosts, weight, nil
 }
 
 // returns the subtree path if it is a valid definition
@@ -460,12 +536,22 @@ func processTreePredicates(r *Route, predicateList []*eskip.Predicate) error {
 
 // processes a route definition for the routing table
 func processRouteDef(cpm map[string]PredicateSpec, fr filters.Registry, def *eskip.Route) (*Route, error) {
+	return processRouteDefWith(cpm, fr, processOptions{}, def)
+}
+
+// options used while processing the route definitions
+type processOptions struct {
+	lazy           *lazyFilters
+	predicateCosts map[string]int
+}
+
+func processRouteDefWith(cpm map[string]PredicateSpec, fr filters.Registry, po processOptions, def *eskip.Route) (*Route, error) {
 	scheme, host, err := splitBackend(def)
 	if err != nil {
 		return nil, err
 	}
 
-	fs, err := createFilters(fr, def.Filters, cpm)
+	fs, err := createFilters(fr, def.Filters, cpm, po.lazy, def.Id)
 	if err != nil {
 		return nil, err
 	}
@@ -475,12 +561,17 @@ func processRouteDef(cpm map[string]PredicateSpec, fr filters.Registry, def *esk
 		return nil, err
 	}
 
-	cps, weight, err := processPredicates(cpm, def.Predicates)
+	cps, costs, weight, err := processPredicatesWithCosts(cpm, po.predicateCosts, def.Predicates)
 	if err != nil {
 		return nil, err
 	}
 
-	r := &Route{Route: *def, Scheme: scheme, Host: host, Predicates: cps, Filters: fs, weight: weight}
+	if def.Priority < 0 {
+		return nil, errInvalidPriority
+	}
+
+	r := &Route{Route: *def, Scheme: scheme, Host: host, Predicates: cps, Filters: fs, weight: weight, predicateCosts: costs}
+	r.Annotations = routeAnnotations(def.Filters)
 	if err := processTreePredicates(r, def.Predicates); err != nil {
 		return nil, err
 	}
@@ -488,6 +579,31 @@ func processRouteDef(cpm map[string]PredicateSpec, fr filters.Registry, def *esk
 	return r, nil
 }
 
+// collects the metadata set by the annotate filters, the later ones
+// overriding the earlier ones
+func routeAnnotations(fs []*eskip.Filter) map[string]string {
+	var annotations map[string]string
+	for _, f := range fs {
+		if f.Name != filters.AnnotateName || len(f.Args) != 2 {
+			continue
+		}
+
+		key, kok := f.Args[0].(string)
+		value, vok := f.Args[1].(string)
+		if !kok || !vok || key == "" {
+			continue
+		}
+
+		if annotations == nil {
+			annotations = make(map[string]string)
+		}
+
+		annotations[key] = value
+	}
+
+	return annotations
+}
+
 // convert a slice of predicate specs to a map keyed by their names
 func mapPredicates(cps []PredicateSpec) map[string]PredicateSpec {
 	cpm := make(map[string]PredicateSpec)
@@ -500,24 +616,162 @@ func mapPredicates(cps []PredicateSpec) map[string]PredicateSpec {
 
 // processes a set of route definitions for the routing table
 func processRouteDefs(o Options, fr filters.Registry, defs []*eskip.Route) (routes []*Route, invalidDefs []*eskip.Route) {
+	routes, invalidDefs, _ = processRouteDefsWithErrors(o, fr, defs)
+	return
+}
+
+// processes a set of route definitions for the routing table, and collects
+// the processing errors of the invalid definitions by route id
+func processRouteDefsWithErrors(o Options, fr filters.Registry, defs []*eskip.Route) (routes []*Route, invalidDefs []*eskip.Route, errs map[string]string) {
+	errs = make(map[string]string)
 	cpm := mapPredicates(o.Predicates)
-	for _, def := range defs {
-		route, err := processRouteDef(cpm, fr, def)
-		if err == nil {
-			routes = append(routes, route)
+	po := processOptions{
+		lazy:           newLazyFilters(o, fr),
+		predicateCosts: o.PredicateCosts,
+	}
+
+	for i, p := range processRouteDefsConcurrently(cpm, fr, po, defs, runtime.GOMAXPROCS(0)) {
+		if p.err == nil {
+			routes = append(routes, p.route)
 		} else {
+			def := defs[i]
 			invalidDefs = append(invalidDefs, def)
-			o.Log.Errorf("failed to process route %s: %v", def.Id, err)
+			errs[def.Id] = p.err.Error()
+			o.Log.Errorf("failed to process route %s: %v", def.Id, p.err)
 		}
 	}
 	return
 }
 
+type processedRoute struct {
+	route *Route
+	err   error
+}
+
+// processes the route definitions with multiple goroutines, taking the
+// definitions in small batches, and returns the results in the order of
+// the definitions. The filter and predicate specs are called concurrently,
+// the same way as during the warm-up of the lazy filters, or by the
+// routing tables of the separate listeners.
+func processRouteDefsConcurrently(cpm map[string]PredicateSpec, fr filters.Registry, po processOptions, defs []*eskip.Route, workers int) []processedRoute {
+	results := make([]processedRoute, len(defs))
+	process := func(from, to int) {
+		for i := from; i < to; i++ {
+			results[i].route, results[i].err = processRouteDefWith(cpm, fr, po, defs[i])
+		}
+	}
+
+	if workers < 2 || len(defs) < minConcurrentProcessSize {
+		process(0, len(defs))
+		return results
+	}
+
+	var (
+		next int64
+		wg   sync.WaitGroup
+	)
+
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				to := int(atomic.AddInt64(&next, processBatchSize))
+				from := to - processBatchSize
+				if from >= len(defs) {
+					return
+				}
+
+				if to > len(defs) {
+					to = len(defs)
+				}
+
+				process(from, to)
+			}
+		}()
+	}
+
+	wg.Wait()
+	return results
+}
+
 type routeTable struct {
 	m             *matcher
 	validRoutes   []*eskip.Route
 	invalidRoutes []*eskip.Route
 	created       time.Time
+
+	// valid routes by id, for the route status
+	routes map[string]*Route
+
+	// errors of the invalid routes in the last update, by route id
+	errors map[string]string
+
+	// set when the last update was rejected, and the table contains
+	// the previously applied routes
+	rejected bool
+}
+
+// returns a copy of the routing table, marking it as the result of a
+// rejected update with the errors of the rejected update
+func (rt *routeTable) rejectedWith(errors map[string]string) *routeTable {
+	c := *rt
+	c.errors = errors
+	c.rejected = true
+	return &c
+}
+
+// reports the invalid routes of an update as gauges, and resets the gauges
+// of the routes reported previously that became valid or were removed
+func reportInvalidRoutes(m metrics.Metrics, keys *metricKeys, reported map[string]struct{}, errors map[string]string) {
+	if m == nil {
+		return
+	}
+
+	for id := range reported {
+		if _, ok := errors[id]; !ok {
+			m.UpdateGauge(keys.key(invalidRouteMetricPrefix, id), 0)
+			delete(reported, id)
+		}
+	}
+
+	for id := range errors {
+		m.UpdateGauge(keys.key(invalidRouteMetricPrefix, id), 1)
+		reported[id] = struct{}{}
+	}
+
+	m.UpdateGauge(invalidRoutesMetric, float64(len(errors)))
+}
+
+// reports the metadata of the routes as gauges, with the value 1, named
+// route.metadata.<route id>.<key>.<value>, so that the route metrics can
+// be grouped by the metadata. The gauges of the metadata not present
+// anymore are reset to 0.
+func reportRouteMetadata(m metrics.Metrics, keys *metricKeys, reported map[string]struct{}, routes []*Route) {
+	if m == nil {
+		return
+	}
+
+	current := make(map[string]struct{})
+	for _, r := range routes {
+		for k, v := range r.Annotations {
+			current[keys.key(routeMetadataPrefix, r.Id, ".", k, ".", v)] = struct{}{}
+		}
+	}
+
+	for key := range reported {
+		if _, ok := current[key]; !ok {
+			m.UpdateGauge(key, 0)
+			delete(reported, key)
+		}
+	}
+
+	for key := range current {
+		if _, ok := reported[key]; !ok {
+			m.UpdateGauge(key, 1)
+			reported[key] = struct{}{}
+		}
+	}
 }
 
 // receives the next version of the routing table on the output channel,
@@ -526,6 +780,11 @@ func receiveRouteMatcher(o Options, out chan<- *routeTable, quit <-chan struct{}
 	updates := receiveRouteDefs(o, quit)
 	var (
 		rt           *routeTable
+		previous     *matcher
+		reported     = make(map[string]struct{})
+		metadata     = make(map[string]struct{})
+		keys         = newMetricKeys()
+		stats        map[string]*RouteStats
 		outRelay     chan<- *routeTable
 		updatesRelay <-chan []*eskip.Route
 	)
@@ -539,13 +798,13 @@ func receiveRouteMatcher(o Options, out chan<- *routeTable, quit <-chan struct{}
 				defs = o.PreProcessors[i].Do(defs)
 			}
 
-			routes, invalidRoutes := processRouteDefs(o, o.FilterRegistry, defs)
+			routes, invalidRoutes, routeErrors := processRouteDefsWithErrors(o, o.FilterRegistry, defs)
 
 			for i := range o.PostProcessors {
 				routes = o.PostProcessors[i].Do(routes)
 			}
 
-			m, errs := newMatcher(routes, o.MatchingOptions)
+			m, errs := newMatcherFrom(previous, routes, o.MatchingOptions)
 
 			invalidRouteIds := make(map[string]struct{})
 			validRoutes := []*eskip.Route{}
@@ -553,13 +812,35 @@ func receiveRouteMatcher(o Options, out chan<- *routeTable, quit <-chan struct{}
 			for _, err := range errs {
 				o.Log.Error(err)
 				invalidRouteIds[err.ID] = struct{}{}
+				routeErrors[err.ID] = err.Original.Error()
 			}
 
+			keys.rotate()
+			reportInvalidRoutes(o.Metrics, keys, reported, routeErrors)
+
+			if o.AtomicRouteUpdates && len(routeErrors) > 0 {
+				o.Log.Errorf("route settings rejected, invalid routes: %d", len(routeErrors))
+				if o.Metrics != nil {
+					o.Metrics.IncCounter(rejectedUpdatesMetric)
+				}
+
+				rt = &routeTable{errors: routeErrors, rejected: true}
+				updatesRelay = nil
+				outRelay = out
+				continue
+			}
+
+			previous = m
+			stats = assignRouteStats(stats, routes)
+			reportRouteMetadata(o.Metrics, keys, metadata, routes)
+
+			byId := make(map[string]*Route, len(routes))
 			for _, r := range routes {
 				if _, found := invalidRouteIds[r.Id]; found {
 					invalidRoutes = append(invalidRoutes, &r.Route)
 				} else {
 					validRoutes = append(validRoutes, &r.Route)
+					byId[r.Id] = r
 				}
 			}
 
@@ -572,9 +853,15 @@ func receiveRouteMatcher(o Options, out chan<- *routeTable, quit <-chan struct{}
 				validRoutes:   validRoutes,
 				invalidRoutes: invalidRoutes,
 				created:       time.Now().UTC(),
+				errors:        routeErrors,
+				routes:        byId,
 			}
 			updatesRelay = nil
 			outRelay = out
+
+			if o.WarmUpLazyFilters {
+				go warmUpLazyFilters(routes)
+			}
 		case outRelay <- rt:
 			rt = nil
 			updatesRelay = updates
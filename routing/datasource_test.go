@@ -2,9 +2,11 @@ package routing_test
 
 import (
 	"net/http"
+	"net/url"
 	"testing"
 	"time"
 
+	"github.com/zalando/skipper/eskip"
 	"github.com/zalando/skipper/filters"
 	"github.com/zalando/skipper/filters/builtin"
 	"github.com/zalando/skipper/logging"
@@ -94,6 +96,9 @@ func TestProcessRouteDefErrors(t *testing.T) {
 		}, {
 			`* -> setPath() -> <shunt>`,
 			`failed to create filter "setPath": invalid filter parameters`,
+		}, {
+			`* -> "unix://localhost/var/run/app.sock"`,
+			`invalid unix domain socket address: unix://localhost/var/run/app.sock`,
 		},
 	} {
 		func() {
@@ -304,3 +309,72 @@ func (w weightedPredicateSpec) Create([]interface{}) (routing.Predicate, error)
 func (w weightedPredicateSpec) Weight() int {
 	return 10
 }
+
+func TestEndpointHost(t *testing.T) {
+	for _, tt := range []struct {
+		endpoint string
+		host     string
+		fail     bool
+	}{
+		{"http://10.0.0.1:8080", "10.0.0.1:8080", false},
+		{"unix:///var/run/app.sock", "/var/run/app.sock", false},
+		{"unix://localhost/var/run/app.sock", "", true},
+		{"unix://", "", true},
+	} {
+		u, err := url.Parse(tt.endpoint)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		host, err := routing.EndpointHost(u)
+		if (err != nil) != tt.fail || host != tt.host {
+			t.Errorf("%s: unexpected result: %q, %v", tt.endpoint, host, err)
+		}
+	}
+}
+
+// returns the same route definitions on every load, to be shared by
+// multiple routing instances
+type sharedDataClient struct {
+	routes []*eskip.Route
+}
+
+func (c *sharedDataClient) LoadAll() ([]*eskip.Route, error) {
+	return c.routes, nil
+}
+
+func (c *sharedDataClient) LoadUpdate() ([]*eskip.Route, []string, error) {
+	time.Sleep(time.Millisecond)
+	return c.routes, nil, nil
+}
+
+func TestDataClientSharedByRoutings(t *testing.T) {
+	routes, err := eskip.Parse(`
+		foo: Host("foo.example.org") && Header("X-Foo", "bar") && Path("/foo") -> setPath("/") -> "https://foo.example.org";
+		bar: Path("/bar") -> <roundRobin, "http://10.0.0.1:8080", "http://10.0.0.2:8080">;
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dc := &sharedDataClient{routes: routes}
+	for i := 0; i < 2; i++ {
+		rt := routing.New(routing.Options{
+			FilterRegistry:  builtin.MakeRegistry(),
+			DataClients:     []routing.DataClient{dc},
+			PollTimeout:     time.Millisecond,
+			SignalFirstLoad: true,
+		})
+		defer rt.Close()
+		<-rt.FirstLoad()
+	}
+
+	// the routings keep receiving the same definitions concurrently,
+	// and the race detector reports it when any of them modifies them
+	time.Sleep(100 * time.Millisecond)
+	for _, r := range dc.routes {
+		if len(r.Filters) > 0 && r.Filters[0].Args[0] != "/" {
+			t.Error("the received definitions were modified")
+		}
+	}
+}
@@ -0,0 +1,318 @@
+package routing
+
+import (
+	"encoding/json"
+	"hash/fnv"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	endpointRegistryShards = 32
+
+	defaultLastSeenTimeout = time.Minute
+)
+
+// RegistryOptions contains the options of the endpoint registry.
+type RegistryOptions struct {
+
+	// LastSeenTimeout defines how long the metrics of an endpoint are
+	// preserved after it was removed from all the routes. Defaults to
+	// one minute.
+	LastSeenTimeout time.Duration
+}
+
+type endpointSeen struct {
+	update uint64
+	at     time.Time
+}
+
+type endpointEntry struct {
+	metrics *LBMetrics
+
+	// the last update of each routing table that used the endpoint, by
+	// the index of the routing table. The index 0 is used by SetState.
+	seen []endpointSeen
+}
+
+type endpointShard struct {
+	mx        sync.RWMutex
+	endpoints map[string]*endpointEntry
+}
+
+// EndpointRegistry shares the metrics of the load balanced endpoints
+// across the routes and across the routing table updates, so that the
+// in-flight and failed request counters of an endpoint reflect all the
+// requests made to it.
+//
+// The endpoints are stored in shards selected by the hash of the
+// endpoint, and the counters are updated atomically, the request
+// handling doesn't need to acquire any locks of the registry.
+//
+// The registry also holds the state of the endpoints signaled by an
+// external source, e.g. a deployment controller draining an endpoint
+// before terminating it, see SetState. The state can be set by the Go
+// API, or by the HTTP API of the registry.
+//
+// EndpointRegistry implements the PostProcessor interface, and it needs
+// to be applied after the load balanced endpoints of the routes were
+// created. When the registry is shared by multiple routing tables, each
+// additional routing table needs to use its own post-processor returned
+// by PostProcessor, so that the endpoints used by one routing table are
+// not removed by the updates of the other.
+type EndpointRegistry struct {
+	lastSeenTimeout time.Duration
+	shards          [endpointRegistryShards]endpointShard
+
+	// the current update of each routing table, by the index of the
+	// routing table. The index 1 is used by the registry itself.
+	mx      sync.Mutex
+	updates []uint64
+
+	// used in tests
+	now func() time.Time
+}
+
+var _ PostProcessor = &EndpointRegistry{}
+
+// NewEndpointRegistry creates an endpoint registry.
+func NewEndpointRegistry(o RegistryOptions) *EndpointRegistry {
+	if o.LastSeenTimeout <= 0 {
+		o.LastSeenTimeout = defaultLastSeenTimeout
+	}
+
+	r := &EndpointRegistry{
+		lastSeenTimeout: o.LastSeenTimeout,
+		updates:         make([]uint64, 2),
+		now:             time.Now,
+	}
+
+	for i := range r.shards {
+		r.shards[i].endpoints = make(map[string]*endpointEntry)
+	}
+
+	return r
+}
+
+func endpointRegistryKey(scheme, host string) string {
+	return scheme + "://" + host
+}
+
+func (r *EndpointRegistry) shard(key string) *endpointShard {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return &r.shards[h.Sum32()%endpointRegistryShards]
+}
+
+// Metrics returns the shared metrics of an endpoint. It returns nil, when
+// the endpoint is not known by the registry.
+func (r *EndpointRegistry) Metrics(scheme, host string) *LBMetrics {
+	key := endpointRegistryKey(scheme, host)
+	s := r.shard(key)
+
+	s.mx.RLock()
+	defer s.mx.RUnlock()
+	if e, ok := s.endpoints[key]; ok {
+		return e.metrics
+	}
+
+	return nil
+}
+
+// Each calls f for every endpoint known by the registry. The order of
+// the endpoints is not defined.
+func (r *EndpointRegistry) Each(f func(scheme, host string, m *LBMetrics)) {
+	for i := range r.shards {
+		s := &r.shards[i]
+		s.mx.RLock()
+		for key, e := range s.endpoints {
+			scheme, host := splitEndpointRegistryKey(key)
+			f(scheme, host, e.metrics)
+		}
+
+		s.mx.RUnlock()
+	}
+}
+
+func splitEndpointRegistryKey(key string) (string, string) {
+	scheme, host, _ := strings.Cut(key, "://")
+	return scheme, host
+}
+
+func (r *EndpointRegistry) metricsFor(now time.Time, routing int, update uint64, scheme, host string) *LBMetrics {
+	key := endpointRegistryKey(scheme, host)
+	s := r.shard(key)
+
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	e, ok := s.endpoints[key]
+	if !ok {
+		e = &endpointEntry{metrics: &LBMetrics{}}
+		s.endpoints[key] = e
+	}
+
+	for len(e.seen) <= routing {
+		e.seen = append(e.seen, endpointSeen{})
+	}
+
+	e.seen[routing] = endpointSeen{update: update, at: now}
+	return e.metrics
+}
+
+// tells whether an endpoint is not used by the current routes of any
+// routing table, and it was not seen for longer than the last seen timeout
+func (r *EndpointRegistry) expired(now time.Time, e *endpointEntry) bool {
+	for i, s := range e.seen {
+		if s.at.IsZero() {
+			continue
+		}
+
+		if i > 0 && s.update == r.updates[i] {
+			return false
+		}
+
+		if now.Sub(s.at) <= r.lastSeenTimeout {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (r *EndpointRegistry) do(routing int, routes []*Route) []*Route {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+
+	now := r.now()
+	r.updates[routing]++
+	update := r.updates[routing]
+	for _, route := range routes {
+		for i := range route.LBEndpoints {
+			ep := &route.LBEndpoints[i]
+			ep.Metrics = r.metricsFor(now, routing, update, ep.Scheme, ep.Host)
+		}
+	}
+
+	for i := range r.shards {
+		s := &r.shards[i]
+		s.mx.Lock()
+		for key, e := range s.endpoints {
+			if r.expired(now, e) && e.metrics.GetInflightRequests() == 0 {
+				delete(s.endpoints, key)
+			}
+		}
+
+		s.mx.Unlock()
+	}
+
+	return routes
+}
+
+// Do sets the shared metrics for the load balanced endpoints of the routes,
+// and removes the endpoints that were not used by the current routes of any
+// routing table for longer than the last seen timeout.
+func (r *EndpointRegistry) Do(routes []*Route) []*Route {
+	return r.do(1, routes)
+}
+
+type endpointRegistryPostProcessor struct {
+	registry *EndpointRegistry
+	routing  int
+}
+
+func (p endpointRegistryPostProcessor) Do(routes []*Route) []*Route {
+	return p.registry.do(p.routing, routes)
+}
+
+// PostProcessor returns a post-processor for an additional routing table
+// sharing the registry. The endpoints of the routing table are kept as
+// long as its current routes use them, regardless of the updates of the
+// other routing tables.
+func (r *EndpointRegistry) PostProcessor() PostProcessor {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+	r.updates = append(r.updates, 0)
+	return endpointRegistryPostProcessor{registry: r, routing: len(r.updates) - 1}
+}
+
+// SetState sets the state of an endpoint, that the proxy honors starting
+// with the next request. When the endpoint is not used by any route yet,
+// the state is applied when it appears within the last seen timeout.
+func (r *EndpointRegistry) SetState(scheme, host string, s EndpointState) {
+	r.metricsFor(r.now(), 0, 0, scheme, host).SetState(s)
+}
+
+type endpointInfo struct {
+	Endpoint     string     `json:"endpoint"`
+	State        string     `json:"state"`
+	Inflight     int        `json:"inflight"`
+	Failed       int64      `json:"failed"`
+	BackoffUntil *time.Time `json:"backoffUntil,omitempty"`
+}
+
+// ServeHTTP serves the HTTP API of the registry. GET lists the endpoints
+// with their state and metrics, POST sets the state of an endpoint with
+// the endpoint and state query parameters, e.g.:
+//
+//	POST /endpoints?endpoint=http://10.2.0.1:8080&state=drained
+//
+// The valid states are healthy, unhealthy and drained.
+func (r *EndpointRegistry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	switch req.Method {
+	case "GET":
+		list := []endpointInfo{}
+		now := r.now()
+		r.Each(func(scheme, host string, m *LBMetrics) {
+			info := endpointInfo{
+				Endpoint: endpointRegistryKey(scheme, host),
+				State:    m.State().String(),
+				Inflight: m.GetInflightRequests(),
+				Failed:   m.GetFailedRequests(),
+			}
+
+			if until := m.BackoffUntil(); until.After(now) {
+				info.BackoffUntil = &until
+			}
+
+			list = append(list, info)
+		})
+
+		sort.Slice(list, func(i, j int) bool { return list[i].Endpoint < list[j].Endpoint })
+		b, err := json.Marshal(list)
+		if err != nil {
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(b)
+	case "POST":
+		q := req.URL.Query()
+		u, err := url.ParseRequestURI(q.Get("endpoint"))
+		if err != nil {
+			http.Error(w, "invalid endpoint parameter", http.StatusBadRequest)
+			return
+		}
+
+		host, err := EndpointHost(u)
+		if err != nil || host == "" {
+			http.Error(w, "invalid endpoint parameter", http.StatusBadRequest)
+			return
+		}
+
+		s, err := ParseEndpointState(q.Get("state"))
+		if err != nil {
+			http.Error(w, "invalid state parameter", http.StatusBadRequest)
+			return
+		}
+
+		r.SetState(u.Scheme, host, s)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
@@ -0,0 +1,287 @@
+package routing_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/zalando/skipper/routing"
+)
+
+func lbRoute(id string, hosts ...string) *routing.Route {
+	r := &routing.Route{}
+	r.Id = id
+	for _, h := range hosts {
+		r.LBEndpoints = append(r.LBEndpoints, routing.LBEndpoint{Scheme: "http", Host: h, Metrics: &routing.LBMetrics{}})
+	}
+
+	return r
+}
+
+func TestEndpointRegistrySharesMetrics(t *testing.T) {
+	reg := routing.NewEndpointRegistry(routing.RegistryOptions{})
+	routes := reg.Do([]*routing.Route{
+		lbRoute("r1", "10.0.0.1:80", "10.0.0.2:80"),
+		lbRoute("r2", "10.0.0.2:80", "10.0.0.3:80"),
+	})
+
+	if routes[0].LBEndpoints[1].Metrics != routes[1].LBEndpoints[0].Metrics {
+		t.Fatal("failed to share the metrics between the routes")
+	}
+
+	routes[0].LBEndpoints[1].Metrics.IncInflightRequest()
+	routes[0].LBEndpoints[1].Metrics.IncFailedRequest()
+
+	next := reg.Do([]*routing.Route{lbRoute("r1", "10.0.0.2:80")})
+	m := next[0].LBEndpoints[0].Metrics
+	if m.GetInflightRequests() != 1 || m.GetFailedRequests() != 1 {
+		t.Errorf("failed to preserve the metrics across updates: %d, %d", m.GetInflightRequests(), m.GetFailedRequests())
+	}
+
+	if reg.Metrics("http", "10.0.0.2:80") != m {
+		t.Error("failed to look up the metrics")
+	}
+
+	if reg.Metrics("http", "10.0.0.4:80") != nil {
+		t.Error("unexpected metrics for unknown endpoint")
+	}
+
+	count := 0
+	reg.Each(func(scheme, host string, _ *routing.LBMetrics) {
+		if scheme != "http" {
+			t.Errorf("unexpected scheme: %s", scheme)
+		}
+
+		count++
+	})
+
+	if count != 3 {
+		t.Errorf("unexpected number of endpoints: %d", count)
+	}
+}
+
+func TestEndpointRegistryRemovesUnusedEndpoints(t *testing.T) {
+	reg := routing.NewEndpointRegistry(routing.RegistryOptions{LastSeenTimeout: time.Millisecond})
+	reg.Do([]*routing.Route{lbRoute("r1", "10.0.0.1:80", "10.0.0.2:80")})
+	reg.Metrics("http", "10.0.0.2:80").IncInflightRequest()
+
+	time.Sleep(10 * time.Millisecond)
+	reg.Do(nil)
+
+	if reg.Metrics("http", "10.0.0.1:80") != nil {
+		t.Error("failed to remove unused endpoint")
+	}
+
+	if reg.Metrics("http", "10.0.0.2:80") == nil {
+		t.Error("endpoint with in-flight requests removed")
+	}
+}
+
+func TestEndpointRegistryMultipleRoutingTables(t *testing.T) {
+	reg := routing.NewEndpointRegistry(routing.RegistryOptions{LastSeenTimeout: time.Millisecond})
+	other := reg.PostProcessor()
+
+	reg.Do([]*routing.Route{lbRoute("r1", "10.0.0.1:80")})
+	routes := other.Do([]*routing.Route{lbRoute("r2", "10.0.0.2:80", "10.0.0.3:80")})
+	m := routes[0].LBEndpoints[0].Metrics
+
+	time.Sleep(10 * time.Millisecond)
+	reg.Do([]*routing.Route{lbRoute("r1", "10.0.0.1:80")})
+	if reg.Metrics("http", "10.0.0.2:80") != m {
+		t.Fatal("endpoint of the other routing table removed")
+	}
+
+	other.Do([]*routing.Route{lbRoute("r2", "10.0.0.2:80")})
+	time.Sleep(10 * time.Millisecond)
+	reg.Do(nil)
+
+	if reg.Metrics("http", "10.0.0.2:80") != m {
+		t.Error("endpoint of the other routing table removed")
+	}
+
+	if reg.Metrics("http", "10.0.0.1:80") != nil || reg.Metrics("http", "10.0.0.3:80") != nil {
+		t.Error("failed to remove unused endpoints")
+	}
+}
+
+func TestEndpointRegistryConcurrentAccess(t *testing.T) {
+	reg := routing.NewEndpointRegistry(routing.RegistryOptions{})
+
+	var hosts []string
+	for i := 0; i < 100; i++ {
+		hosts = append(hosts, fmt.Sprintf("10.0.0.%d:80", i))
+	}
+
+	routes := reg.Do([]*routing.Route{lbRoute("r1", hosts...)})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 1000; j++ {
+				m := routes[0].LBEndpoints[j%len(hosts)].Metrics
+				m.IncInflightRequest()
+				m.DecInflightRequest()
+			}
+		}()
+	}
+
+	for i := 0; i < 10; i++ {
+		reg.Do([]*routing.Route{lbRoute("r1", hosts...)})
+	}
+
+	wg.Wait()
+	reg.Each(func(_, host string, m *routing.LBMetrics) {
+		if m.GetInflightRequests() != 0 {
+			t.Errorf("unexpected in-flight requests for %s: %d", host, m.GetInflightRequests())
+		}
+	})
+}
+
+func TestEndpointRegistryState(t *testing.T) {
+	reg := routing.NewEndpointRegistry(routing.RegistryOptions{})
+
+	// signaled before the endpoint appears in the routes
+	reg.SetState("http", "10.0.0.2:80", routing.EndpointDrained)
+
+	routes := reg.Do([]*routing.Route{lbRoute("r1", "10.0.0.1:80", "10.0.0.2:80")})
+	if s := routes[0].LBEndpoints[1].Metrics.State(); s != routing.EndpointDrained {
+		t.Errorf("unexpected state: %v", s)
+	}
+
+	reg.SetState("http", "10.0.0.1:80", routing.EndpointUnhealthy)
+	if s := routes[0].LBEndpoints[0].Metrics.State(); s != routing.EndpointUnhealthy {
+		t.Errorf("failed to apply the state immediately: %v", s)
+	}
+
+	next := reg.Do([]*routing.Route{lbRoute("r1", "10.0.0.1:80", "10.0.0.2:80")})
+	if s := next[0].LBEndpoints[0].Metrics.State(); s != routing.EndpointUnhealthy {
+		t.Errorf("failed to preserve the state across updates: %v", s)
+	}
+
+	reg.SetState("http", "10.0.0.1:80", routing.EndpointHealthy)
+	if s := next[0].LBEndpoints[0].Metrics.State(); s != routing.EndpointHealthy {
+		t.Errorf("failed to reset the state: %v", s)
+	}
+
+	var m *routing.LBMetrics
+	if m.State() != routing.EndpointHealthy {
+		t.Error("unexpected state of nil metrics")
+	}
+}
+
+func TestEndpointBackoff(t *testing.T) {
+	now := time.Now()
+	m := &routing.LBMetrics{}
+	if !m.BackoffUntil().IsZero() || m.EffectiveState(now) != routing.EndpointHealthy {
+		t.Fatal("unexpected backoff")
+	}
+
+	m.SetBackoffUntil(now.Add(time.Second))
+	if !m.BackoffUntil().Equal(now.Add(time.Second)) {
+		t.Errorf("unexpected backoff: %v", m.BackoffUntil())
+	}
+
+	if s := m.EffectiveState(now); s != routing.EndpointUnhealthy {
+		t.Errorf("unexpected state during the backoff: %v", s)
+	}
+
+	if s := m.EffectiveState(now.Add(2 * time.Second)); s != routing.EndpointHealthy {
+		t.Errorf("unexpected state after the backoff: %v", s)
+	}
+
+	m.SetState(routing.EndpointDrained)
+	if s := m.EffectiveState(now); s != routing.EndpointDrained {
+		t.Errorf("unexpected state of a drained endpoint: %v", s)
+	}
+
+	var nilMetrics *routing.LBMetrics
+	if !nilMetrics.BackoffUntil().IsZero() || nilMetrics.EffectiveState(now) != routing.EndpointHealthy {
+		t.Error("unexpected backoff of nil metrics")
+	}
+}
+
+func TestEndpointRegistryHTTP(t *testing.T) {
+	reg := routing.NewEndpointRegistry(routing.RegistryOptions{})
+	routes := reg.Do([]*routing.Route{lbRoute("r1", "10.0.0.1:80", "10.0.0.2:80")})
+	backoffUntil := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+	routes[0].LBEndpoints[0].Metrics.SetBackoffUntil(backoffUntil)
+
+	s := httptest.NewServer(reg)
+	defer s.Close()
+
+	for _, tt := range []struct {
+		method string
+		query  string
+		status int
+	}{
+		{"POST", "?endpoint=http://10.0.0.2:80&state=drained", http.StatusNoContent},
+		{"POST", "?endpoint=http://10.0.0.3:80&state=unhealthy", http.StatusNoContent},
+		{"POST", "?endpoint=10.0.0.2:80&state=drained", http.StatusBadRequest},
+		{"POST", "?endpoint=http://10.0.0.2:80&state=foo", http.StatusBadRequest},
+		{"POST", "?state=drained", http.StatusBadRequest},
+		{"DELETE", "?endpoint=http://10.0.0.2:80", http.StatusMethodNotAllowed},
+	} {
+		req, err := http.NewRequest(tt.method, s.URL+tt.query, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rsp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rsp.Body.Close()
+		if rsp.StatusCode != tt.status {
+			t.Errorf("%s %s: unexpected status: %d, expected: %d", tt.method, tt.query, rsp.StatusCode, tt.status)
+		}
+	}
+
+	rsp, err := http.Get(s.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer rsp.Body.Close()
+	var list []struct {
+		Endpoint     string     `json:"endpoint"`
+		State        string     `json:"state"`
+		BackoffUntil *time.Time `json:"backoffUntil"`
+	}
+
+	if err := json.NewDecoder(rsp.Body).Decode(&list); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(list) != 3 || list[0].BackoffUntil == nil || !list[0].BackoffUntil.Equal(backoffUntil) || list[1].BackoffUntil != nil {
+		t.Fatalf("unexpected backoff: %+v", list)
+	}
+
+	for i := range list {
+		list[i].BackoffUntil = nil
+	}
+
+	if fmt.Sprint(list) != "[{http://10.0.0.1:80 healthy <nil>} {http://10.0.0.2:80 drained <nil>} {http://10.0.0.3:80 unhealthy <nil>}]" {
+		t.Errorf("unexpected endpoints: %v", list)
+	}
+}
+
+func BenchmarkEndpointRegistryDo(b *testing.B) {
+	reg := routing.NewEndpointRegistry(routing.RegistryOptions{})
+
+	var routes []*routing.Route
+	for i := 0; i < 1000; i++ {
+		routes = append(routes, lbRoute(fmt.Sprintf("r%d", i), fmt.Sprintf("10.0.%d.1:80", i%256), fmt.Sprintf("10.1.%d.2:80", i%256)))
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		reg.Do(routes)
+	}
+}
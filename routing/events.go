@@ -0,0 +1,169 @@
+package routing
+
+import (
+	"sync"
+
+	"github.com/zalando/skipper/eskip"
+)
+
+// RouteEventType tells how a route changed in a routing update.
+type RouteEventType int
+
+const (
+	// RouteAdded indicates a route that was not present in the
+	// previous routing table.
+	RouteAdded RouteEventType = iota
+
+	// RouteUpdated indicates a route whose definition changed.
+	RouteUpdated
+
+	// RouteDeleted indicates a route that was removed from the
+	// routing table.
+	RouteDeleted
+)
+
+func (t RouteEventType) String() string {
+	switch t {
+	case RouteAdded:
+		return "added"
+	case RouteUpdated:
+		return "updated"
+	case RouteDeleted:
+		return "deleted"
+	default:
+		return "unknown"
+	}
+}
+
+// RouteEvent describes the change of a single route in a routing update.
+// In case of RouteDeleted, Route contains the last applied definition of
+// the route.
+type RouteEvent struct {
+	Type  RouteEventType
+	Route *eskip.Route
+}
+
+// subscribers receiving the route events, keyed by their registration
+type subscribers struct {
+	mx     sync.Mutex
+	nextID int
+	funcs  map[int]func([]RouteEvent)
+}
+
+func (s *subscribers) add(f func([]RouteEvent)) func() {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+
+	if s.funcs == nil {
+		s.funcs = make(map[int]func([]RouteEvent))
+	}
+
+	id := s.nextID
+	s.nextID++
+	s.funcs[id] = f
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			s.mx.Lock()
+			defer s.mx.Unlock()
+			delete(s.funcs, id)
+		})
+	}
+}
+
+func (s *subscribers) list() []func([]RouteEvent) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+
+	fs := make([]func([]RouteEvent), 0, len(s.funcs))
+	for _, f := range s.funcs {
+		fs = append(fs, f)
+	}
+
+	return fs
+}
+
+// notify sends the differences between two routing tables to the
+// subscribers. It does nothing when there are no subscribers or no
+// changes.
+func (s *subscribers) notify(previous, next []*eskip.Route) {
+	fs := s.list()
+	if len(fs) == 0 {
+		return
+	}
+
+	events := diffRoutes(previous, next)
+	if len(events) == 0 {
+		return
+	}
+
+	for _, f := range fs {
+		f(events)
+	}
+}
+
+// diffRoutes returns the events turning the previous set of routes into
+// the next one. The added and updated routes follow the order of next,
+// the deleted ones the order of previous.
+func diffRoutes(previous, next []*eskip.Route) []RouteEvent {
+	previousByID := make(map[string]*eskip.Route, len(previous))
+	for _, r := range previous {
+		previousByID[r.Id] = r
+	}
+
+	var events []RouteEvent
+	nextIDs := make(map[string]struct{}, len(next))
+	for _, r := range next {
+		nextIDs[r.Id] = struct{}{}
+		p, ok := previousByID[r.Id]
+		switch {
+		case !ok:
+			events = append(events, RouteEvent{Type: RouteAdded, Route: r})
+		case !eskip.Eq(p, r):
+			events = append(events, RouteEvent{Type: RouteUpdated, Route: r})
+		}
+	}
+
+	for _, r := range previous {
+		if _, ok := nextIDs[r.Id]; !ok {
+			events = append(events, RouteEvent{Type: RouteDeleted, Route: r})
+		}
+	}
+
+	return events
+}
+
+// stores the next routing table, and notifies the subscribers about the
+// changes
+func (r *Routing) applyTable(rt *routeTable) {
+	r.eventsMx.Lock()
+	defer r.eventsMx.Unlock()
+
+	previous := r.routeTable.Load().(*routeTable)
+	r.routeTable.Store(rt)
+	r.subscribers.notify(previous.validRoutes, rt.validRoutes)
+}
+
+// Subscribe registers a function receiving the route events of every
+// applied routing update, e.g. to mirror the current routes into an
+// external system. On registration, the function receives the routes of
+// the current routing table as RouteAdded events. The events of a single
+// update are delivered in one call.
+//
+// The function is called synchronously while applying the updates, so it
+// should return quickly, must not modify the routes, and must not call
+// Subscribe. Rejected updates don't produce events.
+//
+// The returned function cancels the subscription.
+func (r *Routing) Subscribe(f func([]RouteEvent)) (unsubscribe func()) {
+	r.eventsMx.Lock()
+	defer r.eventsMx.Unlock()
+
+	rt := r.routeTable.Load().(*routeTable)
+	if len(rt.validRoutes) > 0 {
+		f(diffRoutes(nil, rt.validRoutes))
+	}
+
+	return r.subscribers.add(f)
+}
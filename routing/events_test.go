@@ -0,0 +1,110 @@
+package routing_test
+
+import (
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/zalando/skipper/filters/builtin"
+	"github.com/zalando/skipper/logging/loggingtest"
+	"github.com/zalando/skipper/routing"
+	"github.com/zalando/skipper/routing/testdataclient"
+)
+
+type eventRecorder struct {
+	mx     sync.Mutex
+	events [][]routing.RouteEvent
+}
+
+func (r *eventRecorder) record(e []routing.RouteEvent) {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+	r.events = append(r.events, e)
+}
+
+func (r *eventRecorder) last() []string {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+	if len(r.events) == 0 {
+		return nil
+	}
+
+	var s []string
+	for _, e := range r.events[len(r.events)-1] {
+		s = append(s, e.Type.String()+":"+e.Route.Id)
+	}
+
+	sort.Strings(s)
+	return s
+}
+
+func (r *eventRecorder) count() int {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+	return len(r.events)
+}
+
+func TestRouteEvents(t *testing.T) {
+	l := loggingtest.New()
+	defer l.Close()
+
+	dc, err := testdataclient.NewDoc(`
+		foo: Path("/foo") -> "https://foo.example.org";
+		bar: Path("/bar") -> "https://bar.example.org";
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rt := routing.New(routing.Options{
+		FilterRegistry: builtin.MakeRegistry(),
+		DataClients:    []routing.DataClient{dc},
+		PollTimeout:    12 * time.Millisecond,
+		Log:            l,
+	})
+	defer rt.Close()
+
+	if err := l.WaitFor("route settings applied", 120*time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+
+	var rec eventRecorder
+	unsubscribe := rt.Subscribe(rec.record)
+
+	if got := rec.last(); !stringsAreSame(got, []string{"added:bar", "added:foo"}) {
+		t.Fatalf("unexpected initial events: %v", got)
+	}
+
+	l.Reset()
+	if err := dc.UpdateDoc(`
+		foo: Path("/foo") -> "https://foo-new.example.org";
+		baz: Path("/baz") -> "https://baz.example.org";
+	`, []string{"bar"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := l.WaitFor("route settings applied", 120*time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := rec.last(); !stringsAreSame(got, []string{"added:baz", "deleted:bar", "updated:foo"}) {
+		t.Fatalf("unexpected update events: %v", got)
+	}
+
+	unsubscribe()
+	n := rec.count()
+
+	l.Reset()
+	if err := dc.UpdateDoc(`qux: Path("/qux") -> "https://qux.example.org";`, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := l.WaitFor("route settings applied", 120*time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+
+	if rec.count() != n {
+		t.Error("received events after unsubscribing")
+	}
+}
@@ -1,8 +1,20 @@
 package routing
 
+import "time"
+
 var (
 	ExportProcessRouteDef   = processRouteDef
 	ExportNewMatcher        = newMatcher
 	ExportMatch             = (*matcher).match
 	ExportProcessPredicates = processPredicates
+	ExportNewRouteStats     = newRouteStats
 )
+
+func SetRouteStatsClock(s *RouteStats, now func() time.Time) {
+	s.now = now
+	s.since = now()
+}
+
+func SetUnusedRoutesReportInterval(d time.Duration) {
+	unusedRoutesReportInterval = d
+}
@@ -87,3 +87,45 @@ func benchmarkPredicateHost(b *testing.B, predicateFmt string) {
 	benchmarkRouteSink = route
 	benchmarkParamSink = param
 }
+
+func TestHostWildcardPrecedence(t *testing.T) {
+	ha := host.NewAny()
+	hw := host.NewWildcard()
+	pr := map[string]routing.PredicateSpec{ha.Name(): ha, hw.Name(): hw}
+
+	defs, err := eskip.Parse(`
+		wildcard: HostWildcard("*.example.org") -> <shunt>;
+		exact: HostAny("www.example.org") -> <shunt>;
+		regexp: Host("^api[.]example[.]org$") -> <shunt>;
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var routes []*routing.Route
+	for _, def := range defs {
+		r, err := routing.ExportProcessRouteDef(pr, filters.Registry{}, def)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		routes = append(routes, r)
+	}
+
+	m, errs := routing.ExportNewMatcher(routes, routing.MatchingOptionsNone)
+	if len(errs) > 0 {
+		t.Fatal(errs)
+	}
+
+	for host, id := range map[string]string{
+		"www.example.org":    "exact",
+		"api.example.org":    "regexp",
+		"v1.api.example.org": "wildcard",
+	} {
+		req, _ := http.NewRequest("GET", "https://"+host, nil)
+		r, _ := routing.ExportMatch(m, req)
+		if r == nil || r.Id != id {
+			t.Errorf("unexpected route for %s: %v, expected: %s", host, r, id)
+		}
+	}
+}
@@ -0,0 +1,234 @@
+package routing
+
+import (
+	"sync"
+
+	"github.com/zalando/skipper/eskip"
+)
+
+// interner deduplicates the strings of the route definitions, e.g. the
+// backend addresses, the filter names and arguments or the endpoint
+// addresses. The definitions received from the data clients are owned by
+// the data clients, and they may be shared with other routing instances,
+// so they are not modified. Instead, the routing stores interned copies
+// of them, so the stored definitions and the processed routes of all the
+// table generations share a single copy of the strings. It keeps only the
+// strings used by the current definitions.
+type interner struct {
+	mu      sync.Mutex
+	strings map[string]string
+}
+
+func newInterner() *interner {
+	return &interner{strings: make(map[string]string)}
+}
+
+func (i *interner) intern(s string) string {
+	if s == "" {
+		return s
+	}
+
+	if is, ok := i.strings[s]; ok {
+		return is
+	}
+
+	i.strings[s] = s
+	return s
+}
+
+func (i *interner) internArgs(args []interface{}) []interface{} {
+	if args == nil {
+		return nil
+	}
+
+	c := make([]interface{}, len(args))
+	for j, a := range args {
+		if s, ok := a.(string); ok {
+			a = i.intern(s)
+		}
+
+		c[j] = a
+	}
+
+	return c
+}
+
+func (i *interner) internSlice(s []string) []string {
+	if s == nil {
+		return nil
+	}
+
+	c := make([]string, len(s))
+	for j := range s {
+		c[j] = i.intern(s[j])
+	}
+
+	return c
+}
+
+func (i *interner) route(r *eskip.Route) *eskip.Route {
+	c := *r
+	c.Id = i.intern(r.Id)
+	c.Path = i.intern(r.Path)
+	c.Method = i.intern(r.Method)
+	c.Backend = i.intern(r.Backend)
+	c.LBAlgorithm = i.intern(r.LBAlgorithm)
+	c.Name = i.intern(r.Name)
+	c.Namespace = i.intern(r.Namespace)
+	c.HostRegexps = i.internSlice(r.HostRegexps)
+	c.PathRegexps = i.internSlice(r.PathRegexps)
+	c.LBEndpoints = i.internSlice(r.LBEndpoints)
+
+	if r.Headers != nil {
+		c.Headers = make(map[string]string, len(r.Headers))
+		for k, v := range r.Headers {
+			c.Headers[i.intern(k)] = i.intern(v)
+		}
+	}
+
+	if r.HeaderRegexps != nil {
+		c.HeaderRegexps = make(map[string][]string, len(r.HeaderRegexps))
+		for k, v := range r.HeaderRegexps {
+			c.HeaderRegexps[i.intern(k)] = i.internSlice(v)
+		}
+	}
+
+	if r.Predicates != nil {
+		c.Predicates = make([]*eskip.Predicate, len(r.Predicates))
+		for j, p := range r.Predicates {
+			c.Predicates[j] = &eskip.Predicate{Name: i.intern(p.Name), Args: i.internArgs(p.Args)}
+		}
+	}
+
+	if r.Filters != nil {
+		c.Filters = make([]*eskip.Filter, len(r.Filters))
+		for j, f := range r.Filters {
+			c.Filters[j] = &eskip.Filter{Name: i.intern(f.Name), Args: i.internArgs(f.Args)}
+		}
+	}
+
+	return &c
+}
+
+// returns copies of the route definitions with interned strings. The
+// received definitions are only read.
+func (i *interner) defs(routes []*eskip.Route) []*eskip.Route {
+	if routes == nil {
+		return nil
+	}
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	c := make([]*eskip.Route, len(routes))
+	for j, r := range routes {
+		c[j] = i.route(r)
+	}
+
+	return c
+}
+
+// drops the strings that are not used by the current definitions of all
+// the data clients. The definitions are only read.
+func (i *interner) retain(routes []*eskip.Route) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	next := make(map[string]string, len(i.strings))
+	keep := func(s string) {
+		if is, ok := i.strings[s]; ok {
+			next[s] = is
+		}
+	}
+
+	keepArgs := func(args []interface{}) {
+		for _, a := range args {
+			if s, ok := a.(string); ok {
+				keep(s)
+			}
+		}
+	}
+
+	for _, r := range routes {
+		for _, s := range []string{r.Id, r.Path, r.Method, r.Backend, r.LBAlgorithm, r.Name, r.Namespace} {
+			keep(s)
+		}
+
+		for _, s := range r.HostRegexps {
+			keep(s)
+		}
+
+		for _, s := range r.PathRegexps {
+			keep(s)
+		}
+
+		for _, s := range r.LBEndpoints {
+			keep(s)
+		}
+
+		for k, v := range r.Headers {
+			keep(k)
+			keep(v)
+		}
+
+		for k, v := range r.HeaderRegexps {
+			keep(k)
+			for _, s := range v {
+				keep(s)
+			}
+		}
+
+		for _, p := range r.Predicates {
+			keep(p.Name)
+			keepArgs(p.Args)
+		}
+
+		for _, f := range r.Filters {
+			keep(f.Name)
+			keepArgs(f.Args)
+		}
+	}
+
+	i.strings = next
+}
+
+// metricKeys interns the keys of the gauges reported for the individual
+// routes, e.g. route.invalid.<route id>, so that they are not allocated
+// again for every new routing table generation. It keeps only the keys
+// used since the previous rotation.
+type metricKeys struct {
+	buf  []byte
+	keys map[string]string
+	next map[string]string
+}
+
+func newMetricKeys() *metricKeys {
+	return &metricKeys{keys: make(map[string]string), next: make(map[string]string)}
+}
+
+// returns the key made of the concatenated parts. It allocates only
+// when the key was not used since the previous rotation.
+func (k *metricKeys) key(parts ...string) string {
+	k.buf = k.buf[:0]
+	for _, p := range parts {
+		k.buf = append(k.buf, p...)
+	}
+
+	if s, ok := k.next[string(k.buf)]; ok {
+		return s
+	}
+
+	s, ok := k.keys[string(k.buf)]
+	if !ok {
+		s = string(k.buf)
+	}
+
+	k.next[s] = s
+	return s
+}
+
+// drops the keys that were not used since the previous rotation. It is
+// called before reporting a new routing table generation.
+func (k *metricKeys) rotate() {
+	k.keys, k.next = k.next, make(map[string]string, len(k.next))
+}
@@ -0,0 +1,141 @@
+package routing
+
+import (
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/zalando/skipper/eskip"
+)
+
+// creates a string with the same content, but a different backing array
+func cloneString(s string) string {
+	return string([]byte(s))
+}
+
+func internTestDefs(n int, backend string) []*eskip.Route {
+	var defs []*eskip.Route
+	for i := 0; i < n; i++ {
+		defs = append(defs, &eskip.Route{
+			Id:          cloneString("route"),
+			Filters:     []*eskip.Filter{{Name: cloneString("setPath"), Args: []interface{}{cloneString(backend)}}},
+			BackendType: eskip.LBBackend,
+			LBEndpoints: []string{cloneString(backend)},
+		})
+	}
+
+	return defs
+}
+
+func heapAlloc() uint64 {
+	runtime.GC()
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+	return m.HeapAlloc
+}
+
+func TestInternRetainedHeap(t *testing.T) {
+	const n = 256
+	backend := "https://" + strings.Repeat("x", 1<<16) + ".example.org"
+
+	before := heapAlloc()
+	i := newInterner()
+
+	// two generations, the second one received after the first one
+	// was stored, while the received definitions are not kept
+	defs := i.defs(internTestDefs(n/2, backend))
+	i.retain(defs)
+	defs = append(defs, i.defs(internTestDefs(n/2, backend))...)
+	i.retain(defs)
+
+	var retained uint64
+	if after := heapAlloc(); after > before {
+		retained = after - before
+	}
+
+	runtime.KeepAlive(defs)
+
+	// without interning, n copies of the backend string are retained
+	if retained > 4*uint64(len(backend)) {
+		t.Errorf("too much retained heap: %d bytes, for strings of %d bytes", retained, len(backend))
+	}
+
+	for _, d := range defs {
+		if d.LBEndpoints[0] != backend || d.Filters[0].Args[0] != backend {
+			t.Fatal("interning changed the definitions")
+		}
+	}
+}
+
+func TestInternDoesNotModifyReceived(t *testing.T) {
+	newDefs := func() []*eskip.Route {
+		defs := internTestDefs(1, "http://10.0.0.1:8080")
+		defs[0].Headers = map[string]string{"X-Foo": "bar"}
+		return defs
+	}
+
+	original := newDefs()
+	received := newDefs()
+
+	defs := newInterner().defs(received)
+	if !reflect.DeepEqual(defs, original) {
+		t.Error("interning changed the definitions")
+	}
+
+	defs[0].Filters[0].Args[0] = "http://10.0.0.2:8080"
+	defs[0].LBEndpoints[0] = "http://10.0.0.2:8080"
+	defs[0].Headers["X-Foo"] = "baz"
+	if !reflect.DeepEqual(received, original) {
+		t.Error("the interned definitions share state with the received ones")
+	}
+}
+
+func TestInternRetain(t *testing.T) {
+	i := newInterner()
+	defs := i.defs(internTestDefs(2, "http://10.0.0.1:8080"))
+	if len(i.strings) != 3 {
+		t.Errorf("unexpected number of interned strings: %d", len(i.strings))
+	}
+
+	i.retain(defs[:1])
+	if len(i.strings) != 3 {
+		t.Errorf("failed to keep the used strings: %d", len(i.strings))
+	}
+
+	i.retain(nil)
+	if len(i.strings) != 0 {
+		t.Errorf("failed to drop unused strings: %d", len(i.strings))
+	}
+}
+
+func TestMetricKeys(t *testing.T) {
+	k := newMetricKeys()
+	key := k.key(invalidRouteMetricPrefix, cloneString("route1"))
+	if key != "route.invalid.route1" {
+		t.Fatalf("unexpected key: %s", key)
+	}
+
+	k.rotate()
+	if allocs := testing.AllocsPerRun(100, func() {
+		key = k.key(invalidRouteMetricPrefix, "route1")
+	}); allocs != 0 {
+		t.Errorf("the key was allocated again: %v", allocs)
+	}
+
+	k.rotate()
+	k.rotate()
+	if len(k.keys) != 0 || len(k.next) != 0 {
+		t.Error("failed to drop the unused keys")
+	}
+}
+
+func BenchmarkInternDefs(b *testing.B) {
+	defs := internTestDefs(1000, "http://10.0.0.1:8080")
+	in := newInterner()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		in.retain(in.defs(defs))
+	}
+}
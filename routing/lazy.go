@@ -0,0 +1,178 @@
+package routing
+
+import (
+	"net/http"
+	"sync"
+	"sync/atomic"
+
+	"github.com/zalando/skipper/eskip"
+	"github.com/zalando/skipper/filters"
+	"github.com/zalando/skipper/logging"
+)
+
+// maximum number of lazy filters created concurrently during the
+// background warm-up
+const lazyWarmUpConcurrency = 4
+
+// lazyFilters holds the names of the filters, whose creation is deferred
+// until a route containing them first matches.
+type lazyFilters struct {
+	names map[string]bool
+	log   logging.Logger
+}
+
+// lazyFilter defers the creation of an expensive filter instance, e.g.
+// a Lua script state or a filter fetching remote keys, until it is first
+// used by a request, or until it is warmed up in the background.
+type lazyFilter struct {
+	once    sync.Once
+	ready   int32
+	name    string
+	spec    filters.Spec
+	args    []interface{}
+	routeId string
+	log     logging.Logger
+	filter  filters.Filter
+}
+
+// returns the configured lazy filters that need to be created eagerly,
+// with the reason
+func refusedLazyFilters(o Options, fr filters.Registry) map[string]string {
+	if len(o.LazyFilters) == 0 {
+		return nil
+	}
+
+	inspected := make(map[string]bool)
+	for _, pp := range o.PostProcessors {
+		if fi, ok := pp.(FilterInspector); ok {
+			for _, n := range fi.InspectedFilters() {
+				inspected[n] = true
+			}
+		}
+	}
+
+	refused := make(map[string]string)
+	for _, n := range o.LazyFilters {
+		spec, ok := fr[n]
+		switch {
+		case !ok:
+			// reported with the routes using it
+		case inspected[n]:
+			refused[n] = "inspected by a route post-processor"
+		default:
+			if _, ok := spec.(filters.ArgsValidator); !ok {
+				refused[n] = "its arguments can't be validated without creating it"
+			}
+		}
+	}
+
+	return refused
+}
+
+func newLazyFilters(o Options, fr filters.Registry) *lazyFilters {
+	if len(o.LazyFilters) == 0 {
+		return nil
+	}
+
+	refused := refusedLazyFilters(o, fr)
+	m := make(map[string]bool)
+	for _, n := range o.LazyFilters {
+		if _, ok := refused[n]; !ok {
+			m[n] = true
+		}
+	}
+
+	return &lazyFilters{names: m, log: o.Log}
+}
+
+func (lf *lazyFilters) deferred(name string) bool {
+	return lf != nil && lf.names[name]
+}
+
+func (lf *lazyFilters) create(spec filters.Spec, routeId string, def *eskip.Filter) *lazyFilter {
+	return &lazyFilter{
+		name:    def.Name,
+		spec:    spec,
+		args:    def.Args,
+		routeId: routeId,
+		log:     lf.log,
+	}
+}
+
+func (f *lazyFilter) init() filters.Filter {
+	f.once.Do(func() {
+		fi, err := f.spec.CreateFilter(f.args)
+		if err != nil {
+			if f.log != nil {
+				f.log.Errorf("failed to create lazy filter %q of route %s: %v", f.name, f.routeId, err)
+			}
+
+			return
+		}
+
+		f.filter = fi
+		f.args = nil
+		atomic.StoreInt32(&f.ready, 1)
+	})
+
+	return f.filter
+}
+
+// returns the filter instance, without creating it, or the filter itself
+// when it is not lazy. It returns nil, when the lazy filter was not created
+// yet.
+func createdFilter(f filters.Filter) filters.Filter {
+	lf, ok := f.(*lazyFilter)
+	if !ok {
+		return f
+	}
+
+	if atomic.LoadInt32(&lf.ready) == 0 {
+		return nil
+	}
+
+	return lf.filter
+}
+
+func (f *lazyFilter) Request(ctx filters.FilterContext) {
+	fi := f.init()
+	if fi == nil {
+		ctx.Serve(&http.Response{StatusCode: http.StatusInternalServerError})
+		return
+	}
+
+	fi.Request(ctx)
+}
+
+func (f *lazyFilter) Response(ctx filters.FilterContext) {
+	if fi := f.init(); fi != nil {
+		fi.Response(ctx)
+	}
+}
+
+// warmUpLazyFilters creates the deferred filter instances of the routes in
+// the background, after the routing table took effect. Filters already
+// created by a matching request are skipped.
+func warmUpLazyFilters(routes []*Route) {
+	var lazy []*lazyFilter
+	for _, r := range routes {
+		for _, f := range r.Filters {
+			if lf, ok := f.Filter.(*lazyFilter); ok {
+				lazy = append(lazy, lf)
+			}
+		}
+	}
+
+	if len(lazy) == 0 {
+		return
+	}
+
+	sem := make(chan struct{}, lazyWarmUpConcurrency)
+	for _, lf := range lazy {
+		sem <- struct{}{}
+		go func(lf *lazyFilter) {
+			defer func() { <-sem }()
+			lf.init()
+		}(lf)
+	}
+}
@@ -0,0 +1,185 @@
+package routing
+
+import (
+	"errors"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/zalando/skipper/eskip"
+	"github.com/zalando/skipper/filters"
+	"github.com/zalando/skipper/filters/filtertest"
+	"github.com/zalando/skipper/logging/loggingtest"
+)
+
+type countingSpec struct {
+	created int32
+	fail    bool
+}
+
+type countingFilter struct{}
+
+// the same filter without argument validation
+type notValidatedSpec struct {
+	spec countingSpec
+}
+
+type inspector struct{}
+
+func (s *countingSpec) Name() string { return "counting" }
+
+func (s *countingSpec) ValidateArgs(args []interface{}) error {
+	if len(args) != 0 {
+		return filters.ErrInvalidFilterParameters
+	}
+
+	return nil
+}
+
+func (s *notValidatedSpec) Name() string { return s.spec.Name() }
+
+func (s *notValidatedSpec) CreateFilter(args []interface{}) (filters.Filter, error) {
+	return s.spec.CreateFilter(args)
+}
+
+func (inspector) Do(r []*Route) []*Route { return r }
+
+func (inspector) InspectedFilters() []string { return []string{"counting"} }
+
+func (s *countingSpec) CreateFilter([]interface{}) (filters.Filter, error) {
+	atomic.AddInt32(&s.created, 1)
+	if s.fail {
+		return nil, errors.New("failed")
+	}
+
+	return countingFilter{}, nil
+}
+
+func (countingFilter) Request(ctx filters.FilterContext) {
+	ctx.Request().Header.Set("X-Counting", "true")
+}
+
+func (countingFilter) Response(filters.FilterContext) {}
+
+func lazyTestRoutesOf(t *testing.T, spec filters.Spec, o Options, doc string) ([]*Route, []*eskip.Route) {
+	r, err := eskip.Parse(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	o.Log = loggingtest.New()
+	defer o.Log.(*loggingtest.Logger).Close()
+
+	return processRouteDefs(o, filters.Registry{spec.Name(): spec}, r)
+}
+
+func lazyTestRoutes(t *testing.T, spec *countingSpec, o Options) []*Route {
+	routes, invalid := lazyTestRoutesOf(t, spec, o, `r1: * -> counting() -> <shunt>; r2: * -> counting() -> <shunt>`)
+	if len(invalid) != 0 {
+		t.Fatal("unexpected invalid routes")
+	}
+
+	return routes
+}
+
+func TestLazyFilterCreatedOnFirstUse(t *testing.T) {
+	spec := &countingSpec{}
+	routes := lazyTestRoutes(t, spec, Options{LazyFilters: []string{"counting"}})
+	if spec.created != 0 {
+		t.Fatal("filter created eagerly")
+	}
+
+	for i := 0; i < 3; i++ {
+		ctx := &filtertest.Context{FRequest: &http.Request{Header: http.Header{}}}
+		routes[0].Filters[0].Request(ctx)
+		if ctx.FRequest.Header.Get("X-Counting") != "true" {
+			t.Fatal("lazy filter not executed")
+		}
+	}
+
+	if spec.created != 1 {
+		t.Errorf("unexpected number of created filters: %d", spec.created)
+	}
+
+	if _, ok := createdFilter(routes[0].Filters[0].Filter).(countingFilter); !ok {
+		t.Error("failed to return the created filter")
+	}
+
+	if createdFilter(routes[1].Filters[0].Filter) != nil {
+		t.Error("unexpected created filter")
+	}
+}
+
+func TestLazyFilterInvalidArgs(t *testing.T) {
+	spec := &countingSpec{}
+	routes, invalid := lazyTestRoutesOf(t, spec, Options{LazyFilters: []string{"counting"}}, `
+		valid: * -> counting() -> <shunt>;
+		invalid: * -> counting("foo") -> <shunt>;
+	`)
+
+	if len(routes) != 1 || routes[0].Id != "valid" || len(invalid) != 1 || invalid[0].Id != "invalid" {
+		t.Errorf("failed to reject the invalid route: %d, %d", len(routes), len(invalid))
+	}
+
+	if spec.created != 0 {
+		t.Error("filter created eagerly")
+	}
+}
+
+func TestLazyFilterRefused(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		spec    filters.Spec
+		options Options
+	}{{
+		name:    "no argument validation",
+		spec:    &notValidatedSpec{},
+		options: Options{LazyFilters: []string{"counting"}},
+	}, {
+		name:    "inspected by a post-processor",
+		spec:    &countingSpec{},
+		options: Options{LazyFilters: []string{"counting"}, PostProcessors: []PostProcessor{inspector{}}},
+	}} {
+		t.Run(tt.name, func(t *testing.T) {
+			routes, _ := lazyTestRoutesOf(t, tt.spec, tt.options, `r: * -> counting() -> <shunt>`)
+			if _, ok := routes[0].Filters[0].Filter.(countingFilter); !ok {
+				t.Error("failed to create the filter eagerly")
+			}
+		})
+	}
+}
+
+func TestLazyFilterFailure(t *testing.T) {
+	spec := &countingSpec{fail: true}
+	routes := lazyTestRoutes(t, spec, Options{LazyFilters: []string{"counting"}})
+
+	ctx := &filtertest.Context{FRequest: &http.Request{Header: http.Header{}}}
+	routes[0].Filters[0].Request(ctx)
+	if !ctx.FServed || ctx.FResponse.StatusCode != http.StatusInternalServerError {
+		t.Error("failed to respond with an error")
+	}
+}
+
+func TestNotLazyFilter(t *testing.T) {
+	spec := &countingSpec{}
+	lazyTestRoutes(t, spec, Options{LazyFilters: []string{"other"}})
+	if spec.created != 2 {
+		t.Errorf("unexpected number of created filters: %d", spec.created)
+	}
+}
+
+func TestLazyFilterWarmUp(t *testing.T) {
+	spec := &countingSpec{}
+	routes := lazyTestRoutes(t, spec, Options{LazyFilters: []string{"counting"}})
+	warmUpLazyFilters(routes)
+
+	timeout := time.After(time.Second)
+	for atomic.LoadInt32(&spec.created) != 2 {
+		select {
+		case <-timeout:
+			t.Fatal("failed to warm up the lazy filters")
+		case <-time.After(time.Millisecond):
+		}
+	}
+}
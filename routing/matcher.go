@@ -6,6 +6,7 @@ import (
 	"net/http"
 	"regexp"
 	"sort"
+	"strconv"
 	"strings"
 
 	"github.com/dimfeld/httppath"
@@ -24,7 +25,13 @@ func (m *leafRequestMatcher) Match(value interface{}) (bool, interface{}) {
 		return false, nil
 	}
 
-	l := matchLeaves(v.leaves, m.r, m.path, m.exactPath)
+	var l *leafMatcher
+	if v.index != nil {
+		l = v.index.match(m.r, m.path, m.exactPath)
+	} else {
+		l = matchLeaves(v.leaves, m.r, m.path, m.exactPath)
+	}
+
 	return l != nil, l
 }
 
@@ -36,6 +43,7 @@ type leafMatcher struct {
 	weight               int
 	hostRxs              []*regexp.Regexp
 	pathRxs              []*regexp.Regexp
+	pathRxCaptures       bool
 	headersExact         map[string]string
 	headersRegexp        map[string][]*regexp.Regexp
 	predicates           []Predicate
@@ -67,13 +75,24 @@ func (ls leafMatchers) Less(i, j int) bool { return leafWeight(ls[i]) > leafWeig
 
 type pathMatcher struct {
 	leaves leafMatchers
+	index  *leafIndex
 }
 
 // root structure representing the routing tree.
 type matcher struct {
 	paths           *pathmux.Tree
 	rootLeaves      leafMatchers
+	rootIndex       *leafIndex
 	matchingOptions MatchingOptions
+
+	// matchers of the routes with an explicit priority, in descending
+	// order of the priority. They are evaluated before this one, which
+	// contains the routes with the default priority.
+	prioritized []*matcher
+
+	// the compiled regular expressions used by this
+	// generation, inherited by the next one
+	compiledRxs map[string]*regexp.Regexp
 }
 
 // An error created if a route definition cannot be processed.
@@ -94,8 +113,9 @@ func (err *definitionError) Error() string {
 // rx identifying the 'free form' wildcards at the end of the paths
 var freeWildcardRx = regexp.MustCompile("/[*][^/]*$")
 
-// compiles all rxs or fails
-func getCompiledRxs(compiled map[string]*regexp.Regexp, exps []string) ([]*regexp.Regexp, error) {
+// compiles all rxs or fails. When available, it takes the
+// compiled instance from the previous generation.
+func getCompiledRxs(compiled, previous map[string]*regexp.Regexp, exps []string) ([]*regexp.Regexp, error) {
 	rxs := make([]*regexp.Regexp, 0, len(exps))
 	for _, exp := range exps {
 		if rx, ok := compiled[exp]; ok {
@@ -103,6 +123,12 @@ func getCompiledRxs(compiled map[string]*regexp.Regexp, exps []string) ([]*regex
 			continue
 		}
 
+		if rx, ok := previous[exp]; ok {
+			compiled[exp] = rx
+			rxs = append(rxs, rx)
+			continue
+		}
+
 		rx, err := regexp.Compile(exp)
 		if err != nil {
 			return nil, err
@@ -209,15 +235,16 @@ func normalizePath(r *Route) (string, error) {
 // conditions.
 //
 // Using a set of regular expressions shared in
-// the current generation to preserve the
-// compiled instances.
-func newLeaf(r *Route, rxs map[string]*regexp.Regexp) (*leafMatcher, error) {
-	hostRxs, err := getCompiledRxs(rxs, r.HostRegexps)
+// the current generation, and the ones from the
+// previous generation, to preserve the compiled
+// instances.
+func newLeaf(r *Route, rxs, previousRxs map[string]*regexp.Regexp) (*leafMatcher, error) {
+	hostRxs, err := getCompiledRxs(rxs, previousRxs, r.HostRegexps)
 	if err != nil {
 		return nil, err
 	}
 
-	pathRxs, err := getCompiledRxs(rxs, r.PathRegexps)
+	pathRxs, err := getCompiledRxs(rxs, previousRxs, r.PathRegexps)
 	if err != nil {
 		return nil, err
 	}
@@ -225,7 +252,7 @@ func newLeaf(r *Route, rxs map[string]*regexp.Regexp) (*leafMatcher, error) {
 	headerExps := r.HeaderRegexps
 	allHeaderRxs := make(map[string][]*regexp.Regexp)
 	for k, exps := range headerExps {
-		headerRxs, err := getCompiledRxs(rxs, exps)
+		headerRxs, err := getCompiledRxs(rxs, previousRxs, exps)
 		if err != nil {
 			return nil, err
 		}
@@ -233,18 +260,27 @@ func newLeaf(r *Route, rxs map[string]*regexp.Regexp) (*leafMatcher, error) {
 		allHeaderRxs[k] = headerRxs
 	}
 
+	var pathRxCaptures bool
+	for _, rx := range pathRxs {
+		if rx.NumSubexp() > 0 {
+			pathRxCaptures = true
+			break
+		}
+	}
+
 	return &leafMatcher{
 		wildcardParamNames:   extractWildcardParamNames(r),
 		hasFreeWildcardParam: hasFreeWildcardParam(r),
 
-		weight:        r.weight,
-		method:        r.Method,
-		hostRxs:       hostRxs,
-		pathRxs:       pathRxs,
-		headersExact:  canonicalizeHeaders(r.Headers),
-		headersRegexp: canonicalizeHeaderRegexps(allHeaderRxs),
-		predicates:    r.Predicates,
-		route:         r}, nil
+		weight:         r.weight,
+		method:         r.Method,
+		hostRxs:        hostRxs,
+		pathRxs:        pathRxs,
+		pathRxCaptures: pathRxCaptures,
+		headersExact:   canonicalizeHeaders(r.Headers),
+		headersRegexp:  canonicalizeHeaderRegexps(allHeaderRxs),
+		predicates:     orderedPredicates(r),
+		route:          r}, nil
 }
 
 func trimTrailingSlash(path string) string {
@@ -263,6 +299,7 @@ func addTreeMatchers(pathTree *pathmux.Tree, matchers map[string]*pathMatcher) [
 
 		// sort leaves during construction time, based on their priority
 		sort.Stable(m.leaves)
+		m.index = newLeafIndex(m.leaves)
 
 		if err := pathTree.Add(p, m); err != nil {
 			errors = append(errors, &definitionError{Index: -1, Original: err})
@@ -309,16 +346,64 @@ func addSubtreeLeafsToPath(pms map[string]*pathMatcher, path string, l *leafMatc
 // on the rest of the conditions so that most strict route
 // definition matches first.
 func newMatcher(rs []*Route, o MatchingOptions) (*matcher, []*definitionError) {
+	return newMatcherFrom(nil, rs, o)
+}
+
+// constructs a matcher the same way as newMatcher, but reuses the
+// compiled regular expressions of the previous generation, if any.
+//
+// The routes with an explicit priority are put into separate matchers,
+// one for each priority, so that they are evaluated before all the
+// candidate routes with a lower priority, regardless of their path and
+// the rest of their conditions.
+func newMatcherFrom(previous *matcher, rs []*Route, o MatchingOptions) (*matcher, []*definitionError) {
+	compiledRxs := make(map[string]*regexp.Regexp)
+
+	var previousRxs map[string]*regexp.Regexp
+	if previous != nil {
+		previousRxs = previous.compiledRxs
+	}
+
+	var priorities []int
+	seen := make(map[int]bool)
+	for _, r := range rs {
+		if r.Priority != 0 && !seen[r.Priority] {
+			seen[r.Priority] = true
+			priorities = append(priorities, r.Priority)
+		}
+	}
+
+	sort.Sort(sort.Reverse(sort.IntSlice(priorities)))
+
+	m, errors := newPriorityMatcher(rs, 0, compiledRxs, previousRxs, o)
+	for _, p := range priorities {
+		pm, errs := newPriorityMatcher(rs, p, compiledRxs, previousRxs, o)
+		errors = append(errors, errs...)
+		m.prioritized = append(m.prioritized, pm)
+	}
+
+	return m, errors
+}
+
+// constructs a matcher from the routes with the provided priority.
+func newPriorityMatcher(
+	rs []*Route,
+	priority int,
+	compiledRxs, previousRxs map[string]*regexp.Regexp,
+	o MatchingOptions,
+) (*matcher, []*definitionError) {
 	var (
 		errors     []*definitionError
 		rootLeaves leafMatchers
 	)
 
 	pathMatchers := make(map[string]*pathMatcher)
-	compiledRxs := make(map[string]*regexp.Regexp)
-
 	for i, r := range rs {
-		l, err := newLeaf(r, compiledRxs)
+		if r.Priority != priority {
+			continue
+		}
+
+		l, err := newLeaf(r, compiledRxs, previousRxs)
 		if err != nil {
 			errors = append(errors, &definitionError{r.Id, i, err})
 			continue
@@ -352,7 +437,13 @@ func newMatcher(rs []*Route, o MatchingOptions) (*matcher, []*definitionError) {
 	// sort root leaves during construction time, based on their priority
 	sort.Stable(rootLeaves)
 
-	return &matcher{pathTree, rootLeaves, o}, errors
+	return &matcher{
+		paths:           pathTree,
+		rootLeaves:      rootLeaves,
+		rootIndex:       newLeafIndex(rootLeaves),
+		matchingOptions: o,
+		compiledRxs:     compiledRxs,
+	}, errors
 }
 
 // matches a path in the path trie structure.
@@ -385,6 +476,46 @@ func matchPathTree(tree *pathmux.Tree, path string, lrm *leafRequestMatcher) (ma
 	return paramsMap, lm
 }
 
+// adds the capture groups of the path regexps to the path params. Named
+// groups are added by their name, and all groups by their number, counted
+// across the path regexps of the route in the order of their definition,
+// starting from 1. The wildcard params of the path take precedence.
+func appendPathCaptures(params map[string]string, l *leafMatcher, exactPath string) map[string]string {
+	if !l.pathRxCaptures {
+		return params
+	}
+
+	if params == nil {
+		params = make(map[string]string)
+	}
+
+	set := func(key, value string) {
+		if _, ok := params[key]; !ok {
+			params[key] = value
+		}
+	}
+
+	n := 0
+	for _, rx := range l.pathRxs {
+		m := rx.FindStringSubmatch(exactPath)
+		if m == nil {
+			n += rx.NumSubexp()
+			continue
+		}
+
+		names := rx.SubexpNames()
+		for i := 1; i < len(m); i++ {
+			n++
+			set(strconv.Itoa(n), m[i])
+			if names[i] != "" {
+				set(names[i], m[i])
+			}
+		}
+	}
+
+	return params
+}
+
 // matches the path regexp conditions in a leaf matcher.
 func matchRegexps(rxs []*regexp.Regexp, s string) bool {
 	for _, rx := range rxs {
@@ -495,17 +626,41 @@ func (m *matcher) match(r *http.Request) (*Route, map[string]string) {
 	}
 	lrm := &leafRequestMatcher{r: r, path: path, exactPath: exact}
 
+	// the routes with a higher explicit priority take precedence over
+	// all the other candidates
+	for _, pm := range m.prioritized {
+		if l, params := pm.matchLevel(lrm); l != nil {
+			return l.route, params
+		}
+	}
+
+	if l, params := m.matchLevel(lrm); l != nil {
+		return l.route, params
+	}
+
+	return nil, nil
+}
+
+// matches a request against the routes of a single priority.
+func (m *matcher) matchLevel(lrm *leafRequestMatcher) (*leafMatcher, map[string]string) {
+	r, path, exact := lrm.r, lrm.path, lrm.exactPath
+
 	// first match fixed and wildcard paths
 	params, l := matchPathTree(m.paths, path, lrm)
 
 	if l != nil {
-		return l.route, params
+		return l, appendPathCaptures(params, l, exact)
 	}
 
 	// if no path match, match root leaves for other conditions
-	l = matchLeaves(m.rootLeaves, r, path, exact)
+	if m.rootIndex != nil {
+		l = m.rootIndex.match(r, path, exact)
+	} else {
+		l = matchLeaves(m.rootLeaves, r, path, exact)
+	}
+
 	if l != nil {
-		return l.route, nil
+		return l, appendPathCaptures(nil, l, exact)
 	}
 
 	return nil, nil
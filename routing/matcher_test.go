@@ -785,14 +785,14 @@ func TestMatchWildcardPaths(t *testing.T) {
 }
 
 func TestCompileRegexpsError(t *testing.T) {
-	_, err := getCompiledRxs(make(map[string]*regexp.Regexp), []string{"**"})
+	_, err := getCompiledRxs(make(map[string]*regexp.Regexp), nil, []string{"**"})
 	if err == nil {
 		t.Error("failed to fail")
 	}
 }
 
 func TestCompileRegexps(t *testing.T) {
-	rxs, err := getCompiledRxs(make(map[string]*regexp.Regexp), []string{"some", "expressions"})
+	rxs, err := getCompiledRxs(make(map[string]*regexp.Regexp), nil, []string{"some", "expressions"})
 	if err != nil || len(rxs) != 2 {
 		t.Error("failed to compile regexps", err, len(rxs))
 	}
@@ -805,7 +805,7 @@ func TestMakeLeafInvalidHostRx(t *testing.T) {
 		t.Error(err)
 	}
 
-	_, err = newLeaf(r, make(map[string]*regexp.Regexp))
+	_, err = newLeaf(r, make(map[string]*regexp.Regexp), nil)
 	if err == nil {
 		t.Error("failed to fail")
 	}
@@ -818,7 +818,7 @@ func TestMakeLeafInvalidPathRx(t *testing.T) {
 		t.Error(err)
 	}
 
-	_, err = newLeaf(r, make(map[string]*regexp.Regexp))
+	_, err = newLeaf(r, make(map[string]*regexp.Regexp), nil)
 	if err == nil {
 		t.Error("failed to fail")
 	}
@@ -831,7 +831,7 @@ func TestMakeLeafInvalidHeaderRegexp(t *testing.T) {
 		t.Error(err)
 	}
 
-	_, err = newLeaf(r, make(map[string]*regexp.Regexp))
+	_, err = newLeaf(r, make(map[string]*regexp.Regexp), nil)
 	if err == nil {
 		t.Error("failed to fail")
 	}
@@ -850,7 +850,7 @@ func TestMakeLeaf(t *testing.T) {
 		t.Error(err)
 	}
 
-	l, err := newLeaf(r, make(map[string]*regexp.Regexp))
+	l, err := newLeaf(r, make(map[string]*regexp.Regexp), nil)
 	if err != nil || l.method != "PUT" ||
 		len(l.hostRxs) != 1 || len(l.pathRxs) != 1 ||
 		len(l.headersExact) != 1 || len(l.headersRegexp) != 1 ||
@@ -1028,6 +1028,64 @@ func TestWildcardParamToSlash(t *testing.T) {
 	}
 }
 
+func TestPathRegexpCaptures(t *testing.T) {
+	m, err := docToMatcher(`
+		rx: PathRegexp("^/users/(?P<id>[0-9]+)/") && PathRegexp("/(orders|invoices)$") -> "https://rx.example.org";
+		wildcard: Path("/items/:id") && PathRegexp("^/items/(?P<id>[a-z]+)(?P<suffix>[0-9]*)$") -> "https://wildcard.example.org";
+		noCaptures: PathRegexp("^/static/") -> "https://static.example.org";
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range []struct {
+		path     string
+		backend  string
+		expected map[string]string
+	}{{
+		path:     "/users/42/orders",
+		backend:  "https://rx.example.org",
+		expected: map[string]string{"id": "42", "1": "42", "2": "orders"},
+	}, {
+		path:     "/items/foo",
+		backend:  "https://wildcard.example.org",
+		expected: map[string]string{"id": "foo", "1": "foo", "2": "", "suffix": ""},
+	}, {
+		path:     "/static/app.js",
+		backend:  "https://static.example.org",
+		expected: map[string]string{},
+	}} {
+		r, params := m.match(&http.Request{URL: &url.URL{Path: test.path}})
+		if r == nil || r.Backend != test.backend {
+			t.Errorf("%s: failed to match", test.path)
+			continue
+		}
+
+		if len(params) != len(test.expected) {
+			t.Errorf("%s: unexpected params: %v", test.path, params)
+			continue
+		}
+
+		for k, v := range test.expected {
+			if params[k] != v {
+				t.Errorf("%s: unexpected param %s, expected %q, got %q", test.path, k, v, params[k])
+			}
+		}
+	}
+}
+
+func TestPathRegexpCapturesWildcardPrecedence(t *testing.T) {
+	m, err := docToMatcher(`Path("/items/:id") && PathRegexp("^/items/(?P<id>[a-z])") -> "https://example.org"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r, params := m.match(&http.Request{URL: &url.URL{Path: "/items/foo"}})
+	if r == nil || params["id"] != "foo" || params["1"] != "f" {
+		t.Errorf("unexpected params: %v", params)
+	}
+}
+
 func TestFreeWildcardParam(t *testing.T) {
 	m, err := docToMatcher(`Path("/some/*wildcard") -> "https://example.org"`)
 	if err != nil {
@@ -0,0 +1,186 @@
+package routing
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/dimfeld/httppath"
+	"github.com/zalando/skipper/eskip"
+	"github.com/zalando/skipper/predicates"
+)
+
+// notPredicate negates the conjunction of its predicates.
+type notPredicate struct {
+	predicates []Predicate
+}
+
+type predicateFunc func(*http.Request) bool
+
+func (f predicateFunc) Match(r *http.Request) bool { return f(r) }
+
+func (p *notPredicate) Match(r *http.Request) bool {
+	return !matchPredicates(p.predicates, r)
+}
+
+func isWildcardPath(p string) bool {
+	for _, s := range strings.Split(p, "/") {
+		if strings.HasPrefix(s, ":") || strings.HasPrefix(s, "*") {
+			return true
+		}
+	}
+
+	return false
+}
+
+func stringArg(def *eskip.Predicate) (string, error) {
+	a, err := getFreeStringArgs(1, def)
+	if err != nil {
+		return "", err
+	}
+
+	return a[0], nil
+}
+
+func regexpArg(def *eskip.Predicate) (*regexp.Regexp, error) {
+	a, err := stringArg(def)
+	if err != nil {
+		return nil, err
+	}
+
+	return regexp.Compile(a)
+}
+
+func cleanRequestPath(r *http.Request) string {
+	return httppath.Clean(r.URL.Path)
+}
+
+// creates the built-in predicates, that are otherwise handled by the
+// matcher, as standalone predicates, to be used in the Not predicate.
+func createBuiltinPredicate(def *eskip.Predicate) (Predicate, bool, error) {
+	switch def.Name {
+	case predicates.HostName:
+		rx, err := regexpArg(def)
+		if err != nil {
+			return nil, true, err
+		}
+
+		return predicateFunc(func(r *http.Request) bool { return rx.MatchString(r.Host) }), true, nil
+	case predicates.PathRegexpName:
+		rx, err := regexpArg(def)
+		if err != nil {
+			return nil, true, err
+		}
+
+		return predicateFunc(func(r *http.Request) bool { return rx.MatchString(cleanRequestPath(r)) }), true, nil
+	case predicates.PathName, predicates.PathSubtreeName:
+		p, err := stringArg(def)
+		if err != nil {
+			return nil, true, err
+		}
+
+		if isWildcardPath(p) {
+			return nil, true, fmt.Errorf("wildcards are not supported in %s within %s", def.Name, predicates.NotName)
+		}
+
+		p = httppath.Clean(p)
+		if def.Name == predicates.PathName {
+			return predicateFunc(func(r *http.Request) bool { return cleanRequestPath(r) == p }), true, nil
+		}
+
+		prefix := strings.TrimSuffix(p, "/") + "/"
+		return predicateFunc(func(r *http.Request) bool {
+			rp := cleanRequestPath(r)
+			return rp == p || strings.HasPrefix(rp, prefix)
+		}), true, nil
+	case predicates.MethodName:
+		m, err := stringArg(def)
+		if err != nil {
+			return nil, true, err
+		}
+
+		m = strings.ToUpper(m)
+		return predicateFunc(func(r *http.Request) bool { return r.Method == m }), true, nil
+	case predicates.HeaderName:
+		a, err := getFreeStringArgs(2, def)
+		if err != nil {
+			return nil, true, err
+		}
+
+		key, value := http.CanonicalHeaderKey(a[0]), a[1]
+		return predicateFunc(func(r *http.Request) bool {
+			return matchHeader(r.Header, key, func(v string) bool { return v == value })
+		}), true, nil
+	case predicates.HeaderRegexpName:
+		a, err := getFreeStringArgs(2, def)
+		if err != nil {
+			return nil, true, err
+		}
+
+		rx, err := regexp.Compile(a[1])
+		if err != nil {
+			return nil, true, err
+		}
+
+		key := http.CanonicalHeaderKey(a[0])
+		return predicateFunc(func(r *http.Request) bool { return matchHeader(r.Header, key, rx.MatchString) }), true, nil
+	default:
+		return nil, false, nil
+	}
+}
+
+// creates the Not predicate. Its only argument is a predicate expression
+// in the eskip format, e.g. `Host(/^www[.]example[.]org$/) && Method("GET")`,
+// and it matches the requests, that are not matched by the expression.
+func createNotPredicate(cpm map[string]PredicateSpec, args []interface{}) (Predicate, error) {
+	if len(args) != 1 {
+		return nil, predicates.ErrInvalidPredicateParameters
+	}
+
+	expr, ok := args[0].(string)
+	if !ok {
+		return nil, predicates.ErrInvalidPredicateParameters
+	}
+
+	defs, err := eskip.ParsePredicates(expr)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(defs) == 0 {
+		return nil, predicates.ErrInvalidPredicateParameters
+	}
+
+	p := &notPredicate{}
+	for _, def := range defs {
+		var cp Predicate
+		switch def.Name {
+		case predicates.WeightName:
+			return nil, fmt.Errorf("%s cannot be used within %s", def.Name, predicates.NotName)
+		case predicates.NotName:
+			cp, err = createNotPredicate(cpm, def.Args)
+		default:
+			var builtin bool
+			cp, builtin, err = createBuiltinPredicate(def)
+			if builtin {
+				break
+			}
+
+			spec, ok := cpm[def.Name]
+			if !ok {
+				return nil, fmt.Errorf("predicate %q not found", def.Name)
+			}
+
+			cp, err = spec.Create(def.Args)
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("failed to create predicate %q: %w", def.Name, err)
+		}
+
+		p.predicates = append(p.predicates, cp)
+	}
+
+	return p, nil
+}
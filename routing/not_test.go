@@ -0,0 +1,147 @@
+package routing_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/zalando/skipper/routing"
+	"github.com/zalando/skipper/routing/testdataclient"
+)
+
+func TestNotPredicate(t *testing.T) {
+	dc, err := testdataclient.NewDoc(`
+		notAPI: Host(/^a[.]/) && Not("PathSubtree(\"/api\")") -> "https://not-api.example.org";
+		notGet: Host(/^b[.]/) && Not("Method(\"GET\")") && Not("Header(\"X-Debug\", \"true\")") -> "https://not-get.example.org";
+		notCustom: Host(/^c[.]/) && Not("CustomPredicate(\"custom1\") && HeaderRegexp(\"X-Env\", /^prod/)") -> "https://not-custom.example.org";
+		nested: Host(/^d[.]/) && Not("Not(\"PathRegexp(/[.]html$/)\")") -> "https://nested.example.org";
+		notWWW: Path("/www-only") && Not("Host(/^www[.]/)") -> "https://not-www.example.org";
+		catchAll: * -> "https://catch-all.example.org";
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tr, err := newTestRoutingWithPredicates([]routing.PredicateSpec{&predicate{}}, dc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer tr.close()
+
+	const catchAll = "https://catch-all.example.org"
+	for _, test := range []struct {
+		method   string
+		url      string
+		header   http.Header
+		expected string
+	}{{
+		method:   "GET",
+		url:      "https://a.example.org/foo",
+		expected: "https://not-api.example.org",
+	}, {
+		method:   "GET",
+		url:      "https://a.example.org/apifoo",
+		expected: "https://not-api.example.org",
+	}, {
+		method:   "GET",
+		url:      "https://a.example.org/api",
+		expected: catchAll,
+	}, {
+		method:   "GET",
+		url:      "https://a.example.org/api/foo",
+		expected: catchAll,
+	}, {
+		method:   "PUT",
+		url:      "https://b.example.org/foo",
+		expected: "https://not-get.example.org",
+	}, {
+		method:   "PUT",
+		url:      "https://b.example.org/foo",
+		header:   http.Header{"X-Debug": []string{"true"}},
+		expected: catchAll,
+	}, {
+		method:   "GET",
+		url:      "https://b.example.org/foo",
+		expected: catchAll,
+	}, {
+		method:   "GET",
+		url:      "https://c.example.org/foo",
+		header:   http.Header{"X-Env": []string{"prod-1"}},
+		expected: "https://not-custom.example.org",
+	}, {
+		method:   "GET",
+		url:      "https://c.example.org/foo",
+		header:   http.Header{"X-Custom-Predicate": []string{"custom1"}},
+		expected: "https://not-custom.example.org",
+	}, {
+		method:   "GET",
+		url:      "https://c.example.org/foo",
+		header:   http.Header{"X-Env": []string{"prod-1"}, "X-Custom-Predicate": []string{"custom1"}},
+		expected: catchAll,
+	}, {
+		method:   "GET",
+		url:      "https://d.example.org/index.html",
+		expected: "https://nested.example.org",
+	}, {
+		method:   "GET",
+		url:      "https://d.example.org/index.txt",
+		expected: catchAll,
+	}, {
+		method:   "GET",
+		url:      "https://x.example.org/www-only",
+		expected: "https://not-www.example.org",
+	}, {
+		method:   "GET",
+		url:      "https://www.example.org/www-only",
+		expected: catchAll,
+	}} {
+		req, err := http.NewRequest(test.method, test.url, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for k, v := range test.header {
+			req.Header[k] = v
+		}
+
+		r, err := tr.checkRequest(req)
+		if err != nil {
+			t.Errorf("%s %s: %v", test.method, test.url, err)
+			continue
+		}
+
+		if r.Backend != test.expected {
+			t.Errorf("%s %s %v: expected %s, got %s", test.method, test.url, test.header, test.expected, r.Backend)
+		}
+	}
+}
+
+func TestNotPredicateInvalid(t *testing.T) {
+	for _, doc := range []string{
+		`Not() -> <shunt>`,
+		`Not(42) -> <shunt>`,
+		`Not("Host(/foo/)", "Method(\"GET\")") -> <shunt>`,
+		`Not("") -> <shunt>`,
+		`Not("Host(") -> <shunt>`,
+		`Not("Weight(10)") -> <shunt>`,
+		`Not("Path(\"/foo/:id\")") -> <shunt>`,
+		`Not("Unknown()") -> <shunt>`,
+		`Not("Host(/[/)") -> <shunt>`,
+	} {
+		dc, err := testdataclient.NewDoc("invalid: " + doc)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		tr, err := newTestRoutingWithPredicates(nil, dc)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if r, err := tr.checkGetRequest("https://www.example.org"); err == nil {
+			t.Errorf("%s: failed to fail, got route %s", doc, r.Id)
+		}
+
+		tr.close()
+	}
+}
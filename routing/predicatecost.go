@@ -0,0 +1,94 @@
+package routing
+
+import (
+	"sort"
+
+	"github.com/zalando/skipper/predicates"
+)
+
+const (
+	cheapPredicateCost     = 1
+	defaultPredicateCost   = 5
+	expensivePredicateCost = 10
+
+	// the body predicates buffer the request body
+	bodyPredicateCost = 20
+)
+
+// default relative evaluation costs of the known custom predicates
+var defaultPredicateCosts = map[string]int{
+	predicates.TrueName:                  cheapPredicateCost,
+	predicates.FalseName:                 cheapPredicateCost,
+	predicates.MethodsName:               cheapPredicateCost,
+	predicates.HostAnyName:               cheapPredicateCost,
+	predicates.ShutdownName:              cheapPredicateCost,
+	predicates.AfterName:                 cheapPredicateCost,
+	predicates.BeforeName:                cheapPredicateCost,
+	predicates.BetweenName:               cheapPredicateCost,
+	predicates.CookieName:                expensivePredicateCost,
+	predicates.QueryParamName:            expensivePredicateCost,
+	predicates.QueryParamAnyName:         expensivePredicateCost,
+	predicates.QueryParamAllName:         expensivePredicateCost,
+	predicates.QueryParamAnyRegexpName:   expensivePredicateCost,
+	predicates.QueryParamAllRegexpName:   expensivePredicateCost,
+	predicates.ClientGeoName:             expensivePredicateCost,
+	predicates.ForwardedHostName:         expensivePredicateCost,
+	predicates.JWTPayloadAnyKVName:       expensivePredicateCost,
+	predicates.JWTPayloadAllKVName:       expensivePredicateCost,
+	predicates.JWTPayloadAnyKVRegexpName: expensivePredicateCost,
+	predicates.JWTPayloadAllKVRegexpName: expensivePredicateCost,
+
+	predicates.JWTVerifiedPayloadAnyKVName:       expensivePredicateCost,
+	predicates.JWTVerifiedPayloadAllKVName:       expensivePredicateCost,
+	predicates.JWTVerifiedPayloadAnyKVRegexpName: expensivePredicateCost,
+	predicates.JWTVerifiedPayloadAllKVRegexpName: expensivePredicateCost,
+
+	predicates.BodyRegexpName:   bodyPredicateCost,
+	predicates.BodyJSONPathName: bodyPredicateCost,
+}
+
+// returns the relative evaluation cost of a predicate, preferring the
+// configured hints over the defaults.
+func predicateCost(hints map[string]int, name string) int {
+	if c, ok := hints[name]; ok {
+		return c
+	}
+
+	if c, ok := defaultPredicateCosts[name]; ok {
+		return c
+	}
+
+	return defaultPredicateCost
+}
+
+// returns the custom predicates of a route in the order of their
+// evaluation cost. The predicates are combined by logical AND, so the
+// order doesn't change the result of the matching.
+func orderedPredicates(r *Route) []Predicate {
+	if len(r.Predicates) < 2 || len(r.predicateCosts) != len(r.Predicates) {
+		return r.Predicates
+	}
+
+	if sort.IntsAreSorted(r.predicateCosts) {
+		return r.Predicates
+	}
+
+	type costPredicate struct {
+		cost      int
+		predicate Predicate
+	}
+
+	cps := make([]costPredicate, len(r.Predicates))
+	for i, p := range r.Predicates {
+		cps[i] = costPredicate{cost: r.predicateCosts[i], predicate: p}
+	}
+
+	sort.SliceStable(cps, func(i, j int) bool { return cps[i].cost < cps[j].cost })
+
+	ps := make([]Predicate, len(cps))
+	for i := range cps {
+		ps[i] = cps[i].predicate
+	}
+
+	return ps
+}
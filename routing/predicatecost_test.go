@@ -0,0 +1,107 @@
+package routing
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/zalando/skipper/eskip"
+	"github.com/zalando/skipper/logging/loggingtest"
+	"github.com/zalando/skipper/predicates"
+)
+
+type namedPredicateSpec string
+
+type namedPredicate struct {
+	name  string
+	calls *[]string
+}
+
+func (s namedPredicateSpec) Name() string { return string(s) }
+
+func (s namedPredicateSpec) Create([]interface{}) (Predicate, error) {
+	return &namedPredicate{name: string(s)}, nil
+}
+
+func (p *namedPredicate) Match(*http.Request) bool {
+	*p.calls = append(*p.calls, p.name)
+	return true
+}
+
+func TestPredicateEvaluationOrder(t *testing.T) {
+	for _, ti := range []struct {
+		title    string
+		route    string
+		hints    map[string]int
+		expected []string
+	}{{
+		title:    "default costs",
+		expected: []string{predicates.MethodsName, "Custom", predicates.CookieName},
+	}, {
+		title:    "body predicates last",
+		route:    `r: BodyRegexp("foo") && Cookie("foo", "bar") && JWTVerifiedPayloadAnyKV("iss", "foo") && QueryParamAnyRegexp("foo", "bar") && ClientGeo("DE") && Custom() && Methods("GET") -> <shunt>`,
+		expected: []string{predicates.MethodsName, "Custom", predicates.CookieName, predicates.JWTVerifiedPayloadAnyKVName, predicates.QueryParamAnyRegexpName, predicates.ClientGeoName, predicates.BodyRegexpName},
+	}, {
+		title:    "explicit hints",
+		hints:    map[string]int{predicates.CookieName: 0, "Custom": 20},
+		expected: []string{predicates.CookieName, predicates.MethodsName, "Custom"},
+	}} {
+		t.Run(ti.title, func(t *testing.T) {
+			route := ti.route
+			if route == "" {
+				route = `r: Cookie("foo", "bar") && Custom() && Methods("GET") -> <shunt>`
+			}
+
+			defs, err := eskip.Parse(route)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			log := loggingtest.New()
+			defer log.Close()
+
+			o := Options{
+				Predicates: []PredicateSpec{
+					namedPredicateSpec(predicates.CookieName),
+					namedPredicateSpec("Custom"),
+					namedPredicateSpec(predicates.MethodsName),
+					namedPredicateSpec(predicates.BodyRegexpName),
+					namedPredicateSpec(predicates.JWTVerifiedPayloadAnyKVName),
+					namedPredicateSpec(predicates.QueryParamAnyRegexpName),
+					namedPredicateSpec(predicates.ClientGeoName),
+				},
+				PredicateCosts: ti.hints,
+				Log:            log,
+			}
+
+			routes, invalid := processRouteDefs(o, nil, defs)
+			if len(invalid) != 0 {
+				t.Fatal("failed to process routes")
+			}
+
+			var calls []string
+			for _, p := range routes[0].Predicates {
+				p.(*namedPredicate).calls = &calls
+			}
+
+			if routes[0].Predicates[0].(*namedPredicate).name != defs[0].Predicates[0].Name {
+				t.Error("the order of the route predicates was changed")
+			}
+
+			m, errs := newMatcher(routes, MatchingOptionsNone)
+			if len(errs) != 0 {
+				t.Fatal(errs)
+			}
+
+			r, _ := m.match(&http.Request{Method: "GET", URL: &url.URL{Path: "/"}})
+			if r == nil {
+				t.Fatal("failed to match the route")
+			}
+
+			if !cmp.Equal(calls, ti.expected) {
+				t.Error(cmp.Diff(calls, ti.expected))
+			}
+		})
+	}
+}
@@ -0,0 +1,341 @@
+package routing
+
+import (
+	"net/http"
+	"regexp/syntax"
+	"strings"
+)
+
+const (
+	// below this number of leaves, the linear evaluation is cheaper than
+	// the lookup in the pre-filter
+	minIndexedLeaves = 16
+
+	// limits the number of literal strings expanded from a single
+	// host expression
+	maxHostExpansion = 64
+)
+
+// leafIndex pre-filters the leaf matchers of the same path, or the root
+// leaves, based on their literal Host conditions and their literal
+// PathRegexp prefixes, so that only a small candidate set needs to be
+// evaluated with the full set of conditions.
+//
+// Every leaf matcher is indexed in exactly one way, the candidates are
+// evaluated in the same order as they were sorted by their priority.
+type leafIndex struct {
+	all   leafMatchers
+	hosts map[string][]indexedLeaf
+	paths *prefixTree
+	rest  []indexedLeaf
+}
+
+// the same leaf can be added to multiple paths, so the priority is
+// stored together with the leaf in each index
+type indexedLeaf struct {
+	leaf     *leafMatcher
+	priority int
+}
+
+// prefixTree is a simple byte trie storing the leaves by the literal
+// prefix of their anchored PathRegexp conditions.
+type prefixTree struct {
+	leaves   []indexedLeaf
+	children map[byte]*prefixTree
+}
+
+func (t *prefixTree) add(prefix string, l indexedLeaf) {
+	n := t
+	for i := 0; i < len(prefix); i++ {
+		if n.children == nil {
+			n.children = make(map[byte]*prefixTree)
+		}
+
+		c, ok := n.children[prefix[i]]
+		if !ok {
+			c = &prefixTree{}
+			n.children[prefix[i]] = c
+		}
+
+		n = c
+	}
+
+	n.leaves = append(n.leaves, l)
+}
+
+// collects the leaves of every node on the path of s.
+func (t *prefixTree) collect(s string, lists [][]indexedLeaf) [][]indexedLeaf {
+	n := t
+	for i := 0; ; i++ {
+		if len(n.leaves) > 0 {
+			lists = append(lists, n.leaves)
+		}
+
+		if i == len(s) {
+			return lists
+		}
+
+		n = n.children[s[i]]
+		if n == nil {
+			return lists
+		}
+	}
+}
+
+// strips the port and the trailing dot from a host.
+func normalizeIndexedHost(h string) string {
+	if i := strings.LastIndexByte(h, ':'); i >= 0 && i < len(h)-1 && isDigits(h[i+1:]) {
+		h = h[:i]
+	}
+
+	return strings.TrimSuffix(h, ".")
+}
+
+func isDigits(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+
+	return true
+}
+
+// checks if an expression is an optional port definition, e.g. (:[0-9]+)?
+func isOptionalPort(re *syntax.Regexp) bool {
+	if re.Op != syntax.OpQuest {
+		return false
+	}
+
+	sub := re.Sub[0]
+	for sub.Op == syntax.OpCapture {
+		sub = sub.Sub[0]
+	}
+
+	return sub.Op == syntax.OpConcat &&
+		len(sub.Sub) > 0 &&
+		sub.Sub[0].Op == syntax.OpLiteral &&
+		len(sub.Sub[0].Rune) > 0 &&
+		sub.Sub[0].Rune[0] == ':'
+}
+
+// expands an expression into the finite set of strings that it can match,
+// or returns false, when it cannot be done within the limits.
+func expandLiterals(re *syntax.Regexp) ([]string, bool) {
+	switch re.Op {
+	case syntax.OpEmptyMatch:
+		return []string{""}, true
+	case syntax.OpLiteral:
+		if re.Flags&syntax.FoldCase != 0 {
+			return nil, false
+		}
+
+		return []string{string(re.Rune)}, true
+	case syntax.OpCharClass:
+		var s []string
+		for i := 0; i < len(re.Rune); i += 2 {
+			for r := re.Rune[i]; r <= re.Rune[i+1]; r++ {
+				if len(s) == maxHostExpansion {
+					return nil, false
+				}
+
+				s = append(s, string(r))
+			}
+		}
+
+		return s, true
+	case syntax.OpCapture:
+		return expandLiterals(re.Sub[0])
+	case syntax.OpQuest:
+		if isOptionalPort(re) {
+			return []string{""}, true
+		}
+
+		s, ok := expandLiterals(re.Sub[0])
+		if !ok || len(s) == maxHostExpansion {
+			return nil, false
+		}
+
+		return append(s, ""), true
+	case syntax.OpAlternate:
+		var s []string
+		for _, sub := range re.Sub {
+			si, ok := expandLiterals(sub)
+			if !ok || len(s)+len(si) > maxHostExpansion {
+				return nil, false
+			}
+
+			s = append(s, si...)
+		}
+
+		return s, true
+	case syntax.OpConcat:
+		s := []string{""}
+		for _, sub := range re.Sub {
+			si, ok := expandLiterals(sub)
+			if !ok || len(s)*len(si) > maxHostExpansion {
+				return nil, false
+			}
+
+			var next []string
+			for _, prefix := range s {
+				for _, suffix := range si {
+					next = append(next, prefix+suffix)
+				}
+			}
+
+			s = next
+		}
+
+		return s, true
+	default:
+		return nil, false
+	}
+}
+
+// returns the normalized literal hosts that a fully anchored host
+// expression can match.
+func literalHosts(exp string) ([]string, bool) {
+	re, err := syntax.Parse(exp, syntax.Perl)
+	if err != nil {
+		return nil, false
+	}
+
+	re = re.Simplify()
+	if re.Op != syntax.OpConcat || len(re.Sub) < 3 ||
+		re.Sub[0].Op != syntax.OpBeginText ||
+		re.Sub[len(re.Sub)-1].Op != syntax.OpEndText {
+		return nil, false
+	}
+
+	body := &syntax.Regexp{Op: syntax.OpConcat, Sub: re.Sub[1 : len(re.Sub)-1]}
+	hosts, ok := expandLiterals(body)
+	if !ok {
+		return nil, false
+	}
+
+	for i, h := range hosts {
+		if h == "" || strings.ContainsRune(h, ':') {
+			return nil, false
+		}
+
+		hosts[i] = strings.TrimSuffix(h, ".")
+	}
+
+	return hosts, true
+}
+
+// returns the literal prefix of an anchored path expression.
+func literalPathPrefix(exp string) (string, bool) {
+	re, err := syntax.Parse(exp, syntax.Perl)
+	if err != nil {
+		return "", false
+	}
+
+	re = re.Simplify()
+	if re.Op != syntax.OpConcat || len(re.Sub) < 2 || re.Sub[0].Op != syntax.OpBeginText {
+		return "", false
+	}
+
+	var prefix strings.Builder
+	for _, sub := range re.Sub[1:] {
+		if sub.Op != syntax.OpLiteral || sub.Flags&syntax.FoldCase != 0 {
+			break
+		}
+
+		prefix.WriteString(string(sub.Rune))
+	}
+
+	return prefix.String(), prefix.Len() > 0
+}
+
+func (l *leafMatcher) indexHosts() ([]string, bool) {
+	for _, exp := range l.route.HostRegexps {
+		if hosts, ok := literalHosts(exp); ok {
+			return hosts, true
+		}
+	}
+
+	return nil, false
+}
+
+func (l *leafMatcher) indexPathPrefix() (string, bool) {
+	for _, exp := range l.route.PathRegexps {
+		if prefix, ok := literalPathPrefix(exp); ok {
+			return prefix, true
+		}
+	}
+
+	return "", false
+}
+
+// creates the pre-filter index from the leaves sorted by their priority.
+func newLeafIndex(leaves leafMatchers) *leafIndex {
+	li := &leafIndex{all: leaves}
+	if len(leaves) < minIndexedLeaves {
+		return li
+	}
+
+	li.hosts = make(map[string][]indexedLeaf)
+	li.paths = &prefixTree{}
+	for i, l := range leaves {
+		il := indexedLeaf{leaf: l, priority: i}
+		if hosts, ok := l.indexHosts(); ok {
+			added := make(map[string]bool)
+			for _, h := range hosts {
+				if !added[h] {
+					li.hosts[h] = append(li.hosts[h], il)
+					added[h] = true
+				}
+			}
+
+			continue
+		}
+
+		if prefix, ok := l.indexPathPrefix(); ok {
+			li.paths.add(prefix, il)
+			continue
+		}
+
+		li.rest = append(li.rest, il)
+	}
+
+	return li
+}
+
+// evaluates the candidate leaves in the order of their priority.
+func (li *leafIndex) match(req *http.Request, path, exactPath string) *leafMatcher {
+	if li.hosts == nil {
+		return matchLeaves(li.all, req, path, exactPath)
+	}
+
+	var buf [8][]indexedLeaf
+	lists := buf[:0]
+	if hl := li.hosts[normalizeIndexedHost(req.Host)]; len(hl) > 0 {
+		lists = append(lists, hl)
+	}
+
+	lists = li.paths.collect(exactPath, lists)
+	if len(li.rest) > 0 {
+		lists = append(lists, li.rest)
+	}
+
+	for {
+		next := -1
+		for i, l := range lists {
+			if len(l) > 0 && (next < 0 || l[0].priority < lists[next][0].priority) {
+				next = i
+			}
+		}
+
+		if next < 0 {
+			return nil
+		}
+
+		l := lists[next][0].leaf
+		lists[next] = lists[next][1:]
+		if matchLeaf(l, req, path, exactPath) {
+			return l
+		}
+	}
+}
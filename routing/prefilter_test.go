@@ -0,0 +1,124 @@
+package routing
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/zalando/skipper/eskip"
+)
+
+func TestLiteralHosts(t *testing.T) {
+	for _, ti := range []struct {
+		exp   string
+		hosts []string
+		ok    bool
+	}{{
+		exp:   "^www[.]example[.]org$",
+		hosts: []string{"www.example.org"},
+		ok:    true,
+	}, {
+		exp:   "^(www[.]example[.]org[.]?(:[0-9]+)?|api[.]example[.]org[.]?(:[0-9]+)?)$",
+		hosts: []string{"www.example.org", "www.example.org", "api.example.org", "api.example.org"},
+		ok:    true,
+	}, {
+		exp:   "^(a|b)[.]example[.]org$",
+		hosts: []string{"a.example.org", "b.example.org"},
+		ok:    true,
+	}, {
+		exp: "www[.]example[.]org",
+	}, {
+		exp: "^www[.]example[.]org",
+	}, {
+		exp: "^.*[.]example[.]org$",
+	}, {
+		exp: "^[a-z]+[.]example[.]org$",
+	}, {
+		exp: "^(?i)www[.]example[.]org$",
+	}, {
+		exp: "^www[.]example[.]org:8080$",
+	}} {
+		t.Run(ti.exp, func(t *testing.T) {
+			hosts, ok := literalHosts(ti.exp)
+			if ok != ti.ok {
+				t.Fatalf("unexpected result: %v, expected: %v", ok, ti.ok)
+			}
+
+			if !cmp.Equal(hosts, ti.hosts) {
+				t.Error(cmp.Diff(hosts, ti.hosts))
+			}
+		})
+	}
+}
+
+func TestLiteralPathPrefix(t *testing.T) {
+	for exp, prefix := range map[string]string{
+		"^/api/":         "/api/",
+		"^/api/v[0-9]+$": "/api/v",
+		"/api":           "",
+		"^.*/api":        "",
+	} {
+		p, ok := literalPathPrefix(exp)
+		if p != prefix || ok != (prefix != "") {
+			t.Errorf("unexpected prefix for %s: %q", exp, p)
+		}
+	}
+}
+
+func TestPrefilterMatchesSameAsLinear(t *testing.T) {
+	var routes []*Route
+	add := func(r eskip.Route) {
+		r.Id = fmt.Sprintf("route%d", len(routes))
+		routes = append(routes, &Route{Route: r})
+	}
+
+	for i := 0; i < 20; i++ {
+		add(eskip.Route{HostRegexps: []string{fmt.Sprintf("^host%d[.]example[.]org[.]?(:[0-9]+)?$", i)}})
+		add(eskip.Route{
+			HostRegexps: []string{fmt.Sprintf("^host%d[.]example[.]org$", i)},
+			Method:      "POST",
+		})
+		add(eskip.Route{PathRegexps: []string{fmt.Sprintf("^/api%d/", i)}})
+		add(eskip.Route{
+			HostRegexps: []string{fmt.Sprintf("host%d", i)},
+			PathRegexps: []string{fmt.Sprintf("^/api%d/", i)},
+			Headers:     map[string]string{"X-Test": "foo"},
+		})
+	}
+
+	add(eskip.Route{Headers: map[string]string{"X-Test": "bar"}})
+	add(eskip.Route{})
+
+	indexed, errs := newMatcher(routes, MatchingOptionsNone)
+	if len(errs) != 0 {
+		t.Fatal(errs)
+	}
+
+	if indexed.rootIndex.hosts == nil {
+		t.Fatal("failed to create the index")
+	}
+
+	linear := *indexed
+	linear.rootIndex = nil
+
+	for _, method := range []string{"GET", "POST"} {
+		for _, host := range []string{"host3.example.org", "host3.example.org:9090", "host3.example.org.", "host15.example.org", "www.example.org"} {
+			for _, path := range []string{"/", "/api3/foo", "/api15/foo", "/api"} {
+				for _, header := range []string{"", "foo", "bar"} {
+					req := &http.Request{Method: method, Host: host, URL: &url.URL{Path: path}, Header: http.Header{}}
+					if header != "" {
+						req.Header.Set("X-Test", header)
+					}
+
+					expected, _ := linear.match(req)
+					got, _ := indexed.match(req)
+					if got != expected {
+						t.Errorf("%s %s%s %s: got %v, expected %v", method, host, path, header, got.Id, expected.Id)
+					}
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package routing_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/zalando/skipper/eskip"
+	"github.com/zalando/skipper/routing"
+	"github.com/zalando/skipper/routing/testdataclient"
+)
+
+func TestPriority(t *testing.T) {
+	dc, err := testdataclient.NewDoc(`
+		specific: Path("/api") && Method("GET") && Header("X-Test", "true") -> "https://specific.example.org";
+		preferred: [10] Path("/api") -> "https://preferred.example.org";
+		rootPreferred: [5] Host(/^root[.]/) -> "https://root-preferred.example.org";
+		rootPath: [1] Path("/root-api") && Host(/^root[.]/) -> "https://root-path.example.org";
+		heavy: Host(/^heavy[.]/) && Weight(100) -> "https://heavy.example.org";
+		light: [1] Host(/^heavy[.]/) -> "https://light.example.org";
+		exact: Path("/docs/index.html") -> "https://exact.example.org";
+		subtree: [2] PathSubtree("/docs") && Header("X-Preview", "true") -> "https://subtree.example.org";
+		wildcard: [3] Path("/docs/:name") && Method("POST") -> "https://wildcard.example.org";
+		catchAll: * -> "https://catch-all.example.org";
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tr, err := newTestRoutingWithPredicates(nil, dc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer tr.close()
+
+	for _, test := range []struct {
+		method   string
+		url      string
+		header   http.Header
+		expected string
+	}{{
+		url:      "https://www.example.org/api",
+		header:   http.Header{"X-Test": []string{"true"}},
+		expected: "https://preferred.example.org",
+	}, {
+		url:      "https://root.example.org/root-api",
+		expected: "https://root-preferred.example.org",
+	}, {
+		url:      "https://heavy.example.org/foo",
+		expected: "https://light.example.org",
+	}, {
+		url:      "https://www.example.org/docs/index.html",
+		expected: "https://exact.example.org",
+	}, {
+		url:      "https://www.example.org/docs/index.html",
+		header:   http.Header{"X-Preview": []string{"true"}},
+		expected: "https://subtree.example.org",
+	}, {
+		method:   "POST",
+		url:      "https://www.example.org/docs/index.html",
+		header:   http.Header{"X-Preview": []string{"true"}},
+		expected: "https://wildcard.example.org",
+	}, {
+		url:      "https://www.example.org/foo",
+		expected: "https://catch-all.example.org",
+	}} {
+		method := test.method
+		if method == "" {
+			method = "GET"
+		}
+
+		req, err := http.NewRequest(method, test.url, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req.Header = test.header
+		if req.Header == nil {
+			req.Header = make(http.Header)
+		}
+
+		r, err := tr.checkRequest(req)
+		if err != nil {
+			t.Errorf("%s %s: %v", method, test.url, err)
+			continue
+		}
+
+		if r.Backend != test.expected {
+			t.Errorf("%s %s: expected %s, got %s", method, test.url, test.expected, r.Backend)
+		}
+	}
+}
+
+func TestPriorityInvalid(t *testing.T) {
+	dc := testdataclient.New([]*eskip.Route{{
+		Id:          "invalid",
+		Priority:    -1,
+		BackendType: eskip.ShuntBackend,
+	}})
+
+	tr, err := newTestRoutingWithPredicates([]routing.PredicateSpec{}, dc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer tr.close()
+
+	if r, err := tr.checkGetRequest("https://www.example.org"); err == nil {
+		t.Errorf("failed to fail, got route %s", r.Id)
+	}
+}
@@ -0,0 +1,289 @@
+package routing
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/zalando/skipper/eskip"
+)
+
+const (
+	// the error rate of the routes is measured over the last minute, in
+	// one second buckets
+	routeStatsWindow = 60
+
+	// the usage of the routes is measured over the last hour, in one
+	// minute buckets
+	routeUsageWindow = 60
+)
+
+// QueueReporter is implemented by the filters queueing the requests of
+// a route, e.g. fifo() and lifo(), to report the number of the waiting
+// requests in the route status.
+type QueueReporter interface {
+	QueuedRequests() int
+}
+
+// BreakerStateReporter is implemented by the circuit breakers, to report
+// their current state in the route status.
+type BreakerStateReporter interface {
+	State() string
+}
+
+type routeStatsBucket struct {
+	slot     int64
+	requests int
+	errors   int
+}
+
+// RouteStats contains live operational data of a route, reported by
+// the routes API. The stats are kept across the routing updates, as
+// long as the route id doesn't change. The methods are safe to call on
+// nil stats.
+type RouteStats struct {
+	inflight int64
+	breaker  atomic.Value // of BreakerStateReporter
+
+	mu      sync.Mutex
+	buckets [routeStatsWindow]routeStatsBucket
+	minutes [routeUsageWindow]routeStatsBucket
+	lastHit time.Time
+	since   time.Time
+	now     func() time.Time
+}
+
+// RouteStatus is the live status of a route.
+type RouteStatus struct {
+	Id        string  `json:"id"`
+	Inflight  int     `json:"inflight"`
+	Queued    int     `json:"queued"`
+	Breaker   string  `json:"breaker,omitempty"`
+	Requests  int     `json:"requests"`
+	Errors    int     `json:"errors"`
+	ErrorRate float64 `json:"errorRate"`
+}
+
+func newRouteStats() *RouteStats {
+	return &RouteStats{since: time.Now(), now: time.Now}
+}
+
+// Begin registers a request being handled by the route.
+func (s *RouteStats) Begin() {
+	if s == nil {
+		return
+	}
+
+	atomic.AddInt64(&s.inflight, 1)
+}
+
+// End registers that a request was finished by the route, counting it
+// for the error rate when failed is true.
+func (s *RouteStats) End(failed bool) {
+	if s == nil {
+		return
+	}
+
+	atomic.AddInt64(&s.inflight, -1)
+
+	now := s.now()
+	sec := now.Unix()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	b := &s.buckets[sec%routeStatsWindow]
+	if b.slot != sec {
+		*b = routeStatsBucket{slot: sec}
+	}
+
+	b.requests++
+	if failed {
+		b.errors++
+	}
+
+	min := sec / 60
+	m := &s.minutes[min%routeUsageWindow]
+	if m.slot != min {
+		*m = routeStatsBucket{slot: min}
+	}
+
+	m.requests++
+	s.lastHit = now
+}
+
+// SetBreaker stores the circuit breaker last used by the route.
+func (s *RouteStats) SetBreaker(b BreakerStateReporter) {
+	if s == nil || b == nil {
+		return
+	}
+
+	s.breaker.Store(b)
+}
+
+// Inflight returns the number of the requests currently handled by the
+// route.
+func (s *RouteStats) Inflight() int {
+	if s == nil {
+		return 0
+	}
+
+	return int(atomic.LoadInt64(&s.inflight))
+}
+
+// LastMinute returns the number of the finished and the failed requests
+// of the route in the last minute.
+func (s *RouteStats) LastMinute() (requests, errors int) {
+	if s == nil {
+		return 0, 0
+	}
+
+	sec := s.now().Unix()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, b := range s.buckets {
+		if sec-b.slot < routeStatsWindow {
+			requests += b.requests
+			errors += b.errors
+		}
+	}
+
+	return
+}
+
+// LastHour returns the number of the finished requests of the route in
+// the last hour.
+func (s *RouteStats) LastHour() (requests int) {
+	if s == nil {
+		return 0
+	}
+
+	min := s.now().Unix() / 60
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, m := range s.minutes {
+		if min-m.slot < routeUsageWindow {
+			requests += m.requests
+		}
+	}
+
+	return
+}
+
+// LastHit returns the time when the last request of the route was
+// finished, or the zero time if there was none.
+func (s *RouteStats) LastHit() time.Time {
+	if s == nil {
+		return time.Time{}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.lastHit
+}
+
+// Since returns the time since when the route is tracked, i.e. when the
+// route with the same id first appeared in the routing table.
+func (s *RouteStats) Since() time.Time {
+	if s == nil {
+		return time.Time{}
+	}
+
+	return s.since
+}
+
+// BreakerState returns the current state of the circuit breaker last
+// used by the route, or an empty string if the route didn't use a
+// breaker.
+func (s *RouteStats) BreakerState() string {
+	if s == nil {
+		return ""
+	}
+
+	b, _ := s.breaker.Load().(BreakerStateReporter)
+	if b == nil {
+		return ""
+	}
+
+	return b.State()
+}
+
+// Status returns the live status of the route.
+func (r *Route) Status() RouteStatus {
+	st := RouteStatus{
+		Id:       r.Id,
+		Inflight: r.Stats.Inflight(),
+		Breaker:  r.Stats.BreakerState(),
+	}
+
+	for _, f := range r.Filters {
+		if q, ok := createdFilter(f.Filter).(QueueReporter); ok {
+			st.Queued += q.QueuedRequests()
+		}
+	}
+
+	st.Requests, st.Errors = r.Stats.LastMinute()
+	if st.Requests > 0 {
+		st.ErrorRate = float64(st.Errors) / float64(st.Requests)
+	}
+
+	return st
+}
+
+// sets the stats of the routes, reusing the stats of the routes from the
+// previous update with the same id
+func assignRouteStats(previous map[string]*RouteStats, routes []*Route) map[string]*RouteStats {
+	current := make(map[string]*RouteStats, len(routes))
+	for _, r := range routes {
+		s := previous[r.Id]
+		if s == nil {
+			s = newRouteStats()
+		}
+
+		r.Stats = s
+		current[r.Id] = s
+	}
+
+	return current
+}
+
+// renders the live status of the listed routes
+func serveRouteStatus(w http.ResponseWriter, req *http.Request, rt *routeTable, list []*eskip.Route) {
+	status := make([]RouteStatus, 0, len(list))
+	for _, r := range list {
+		if route, ok := rt.routes[r.Id]; ok {
+			status = append(status, route.Status())
+		}
+	}
+
+	if strings.Contains(req.Header.Get("Accept"), "application/json") {
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(status); err != nil {
+			http.Error(
+				w,
+				http.StatusText(http.StatusInternalServerError),
+				http.StatusInternalServerError,
+			)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain")
+	for _, st := range status {
+		breaker := st.Breaker
+		if breaker == "" {
+			breaker = "none"
+		}
+
+		fmt.Fprintf(
+			w,
+			"%s: inflight=%d queued=%d breaker=%s requests=%d errors=%d errorRate=%.3f\n",
+			st.Id, st.Inflight, st.Queued, breaker, st.Requests, st.Errors, st.ErrorRate,
+		)
+	}
+}
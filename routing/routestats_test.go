@@ -0,0 +1,164 @@
+package routing_test
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/zalando/skipper/filters"
+	"github.com/zalando/skipper/filters/builtin"
+	"github.com/zalando/skipper/logging/loggingtest"
+	"github.com/zalando/skipper/routing"
+	"github.com/zalando/skipper/routing/testdataclient"
+)
+
+type queueFilter struct{ queued int }
+
+func (*queueFilter) Request(filters.FilterContext)  {}
+func (*queueFilter) Response(filters.FilterContext) {}
+func (f *queueFilter) QueuedRequests() int          { return f.queued }
+
+type breakerState string
+
+func (s breakerState) State() string { return string(s) }
+
+func TestRouteStatus(t *testing.T) {
+	now := time.Unix(1000, 0)
+	s := routing.ExportNewRouteStats()
+	routing.SetRouteStatsClock(s, func() time.Time { return now })
+
+	r := &routing.Route{
+		Filters: []*routing.RouteFilter{{Filter: &queueFilter{queued: 2}}, {Filter: &queueFilter{queued: 3}}},
+		Stats:   s,
+	}
+	r.Id = "foo"
+
+	for i := 0; i < 4; i++ {
+		s.Begin()
+	}
+
+	s.End(false)
+	s.End(true)
+
+	now = now.Add(30 * time.Second)
+	s.End(false)
+	s.SetBreaker(breakerState("half-open"))
+
+	st := r.Status()
+	if st.Id != "foo" || st.Inflight != 1 || st.Queued != 5 || st.Breaker != "half-open" ||
+		st.Requests != 3 || st.Errors != 1 {
+		t.Errorf("unexpected status: %+v", st)
+	}
+
+	now = now.Add(45 * time.Second)
+	st = r.Status()
+	if st.Requests != 1 || st.Errors != 0 || st.ErrorRate != 0 {
+		t.Errorf("failed to drop the requests older than a minute: %+v", st)
+	}
+
+	now = now.Add(time.Hour)
+	s.End(true)
+	st = r.Status()
+	if st.Inflight != 0 || st.Requests != 1 || st.Errors != 1 || st.ErrorRate != 1 {
+		t.Errorf("unexpected status: %+v", st)
+	}
+
+	if st := (&routing.Route{}).Status(); st.Inflight != 0 || st.Requests != 0 || st.Breaker != "" {
+		t.Errorf("unexpected status of a route without stats: %+v", st)
+	}
+}
+
+func TestRouteStatusAPI(t *testing.T) {
+	l := loggingtest.New()
+	defer l.Close()
+
+	dc, err := testdataclient.NewDoc(`
+		foo: Path("/foo") -> "https://foo.example.org";
+		bar: Path("/bar") -> "https://bar.example.org";
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rt := routing.New(routing.Options{
+		FilterRegistry: builtin.MakeRegistry(),
+		DataClients:    []routing.DataClient{dc},
+		PollTimeout:    12 * time.Millisecond,
+		Log:            l,
+	})
+	defer rt.Close()
+
+	server := httptest.NewServer(rt)
+	defer server.Close()
+
+	if err := l.WaitFor("route settings applied", 120*time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+
+	lookup := func(path string) *routing.Route {
+		r, _ := rt.Route(&http.Request{URL: &url.URL{Path: path}})
+		if r == nil {
+			t.Fatalf("route not found for %s", path)
+		}
+
+		return r
+	}
+
+	foo := lookup("/foo")
+	foo.Stats.Begin()
+	foo.Stats.Begin()
+	foo.Stats.End(true)
+	foo.Stats.SetBreaker(breakerState("open"))
+
+	// the stats are kept for the updated route
+	l.Reset()
+	if err := dc.UpdateDoc(`foo: Path("/foo") -> "https://foo-new.example.org"`, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := l.WaitFor("route settings applied", 120*time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+
+	if r := lookup("/foo"); r.Backend != "https://foo-new.example.org" || r.Stats != foo.Stats {
+		t.Fatal("failed to keep the stats of the updated route")
+	}
+
+	get := func(accept string) string {
+		req, _ := http.NewRequest("GET", server.URL+"?status", nil)
+		req.Header.Set("Accept", accept)
+		rsp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		defer rsp.Body.Close()
+		b, err := io.ReadAll(rsp.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return string(b)
+	}
+
+	var status []routing.RouteStatus
+	if err := json.Unmarshal([]byte(get("application/json")), &status); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(status) != 2 ||
+		status[0] != (routing.RouteStatus{Id: "bar"}) ||
+		status[1] != (routing.RouteStatus{Id: "foo", Inflight: 1, Breaker: "open", Requests: 1, Errors: 1, ErrorRate: 1}) {
+		t.Errorf("unexpected status: %+v", status)
+	}
+
+	expected := "bar: inflight=0 queued=0 breaker=none requests=0 errors=0 errorRate=0.000\n" +
+		"foo: inflight=1 queued=0 breaker=open requests=1 errors=1 errorRate=1.000\n"
+	if text := get("text/plain"); text != expected {
+		t.Errorf("unexpected status, got:\n%s\nexpected:\n%s", text, expected)
+	}
+}
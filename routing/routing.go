@@ -4,14 +4,17 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"sync/atomic"
 	"time"
 
 	"github.com/zalando/skipper/eskip"
 	"github.com/zalando/skipper/filters"
 	"github.com/zalando/skipper/logging"
+	"github.com/zalando/skipper/metrics"
 	"github.com/zalando/skipper/predicates"
 )
 
@@ -27,6 +30,8 @@ const (
 
 	routesTimestampName      = "X-Timestamp"
 	RoutesCountName          = "X-Count"
+	routesInvalidCountName   = "X-Invalid-Count"
+	routesRejectedName       = "X-Update-Rejected"
 	defaultRouteListingLimit = 1024
 )
 
@@ -132,6 +137,51 @@ type Options struct {
 	// SignalFirstLoad enables signaling on the first load
 	// of the routing configuration during the startup.
 	SignalFirstLoad bool
+
+	// LazyFilters contains the names of the filters whose instances
+	// are created only when a route containing them first matches a
+	// request, e.g. filters running Lua scripts or fetching remote keys
+	// during their creation. This shortens the time of applying large
+	// routing tables.
+	//
+	// The arguments of the lazy filters are validated when the routes
+	// are loaded, so only the filters whose specification implements
+	// filters.ArgsValidator can be lazy. The filters inspected by a
+	// post-processor implementing FilterInspector are always created
+	// when the routes are loaded.
+	LazyFilters []string
+
+	// WarmUpLazyFilters enables creating the instances of the lazy
+	// filters in the background, after the routing table took effect.
+	WarmUpLazyFilters bool
+
+	// PredicateCosts overrides the relative evaluation cost of the
+	// custom predicates, by predicate name. The custom predicates of a
+	// route are evaluated in the order of their cost, the cheaper first,
+	// keeping the original order for the predicates of the same cost.
+	// Without an override, the predicates known to be expensive, e.g.
+	// the ones evaluating regular expressions or decoding JWT tokens,
+	// are evaluated last.
+	PredicateCosts map[string]int
+
+	// AtomicRouteUpdates enables validating the complete routing table
+	// before applying it. When any of the routes in an update is invalid,
+	// e.g. one of its filters cannot be created, the whole update is
+	// rejected and the previous routing table is kept until the next
+	// update. The errors of the invalid routes are reported via the
+	// metrics and the routes API. Lazy filters are not validated.
+	AtomicRouteUpdates bool
+
+	// Metrics is used to report the invalid routes, the rejected
+	// updates and the unused routes. Optional.
+	Metrics metrics.Metrics
+
+	// UnusedRouteThreshold sets the time after which a route that didn't
+	// handle any request is considered unused. When set, and Metrics is
+	// set, the number of the unused routes is reported periodically. It
+	// is also the default threshold of the unused routes listed by the
+	// routes API.
+	UnusedRouteThreshold time.Duration
 }
 
 // RouteFilter contains extensions to generic filter
@@ -145,9 +195,101 @@ type RouteFilter struct {
 	Index int
 }
 
+// EndpointState is the state of a load balanced endpoint signaled by
+// an external source, e.g. a deployment controller. The proxy sends the
+// requests to the endpoints that are not healthy only when there is no
+// healthy endpoint of the route, and to the drained endpoints only when
+// all the endpoints are drained.
+type EndpointState int32
+
+const (
+	EndpointHealthy EndpointState = iota
+	EndpointUnhealthy
+	EndpointDrained
+)
+
+// ParseEndpointState parses the string representation of an endpoint
+// state.
+func ParseEndpointState(s string) (EndpointState, error) {
+	switch s {
+	case "healthy":
+		return EndpointHealthy, nil
+	case "unhealthy":
+		return EndpointUnhealthy, nil
+	case "drained":
+		return EndpointDrained, nil
+	default:
+		return EndpointHealthy, fmt.Errorf("invalid endpoint state: %s", s)
+	}
+}
+
+// String returns the string representation of an endpoint state.
+func (s EndpointState) String() string {
+	switch s {
+	case EndpointUnhealthy:
+		return "unhealthy"
+	case EndpointDrained:
+		return "drained"
+	default:
+		return "healthy"
+	}
+}
+
 // LBMetrics contains metrics used by LB algorithms
 type LBMetrics struct {
 	inflightRequests int64
+	failedRequests   int64
+	backoffUntil     int64
+	state            int32
+}
+
+// SetState sets the externally signaled state of the endpoint.
+func (m *LBMetrics) SetState(s EndpointState) {
+	atomic.StoreInt32(&m.state, int32(s))
+}
+
+// State returns the externally signaled state of the endpoint. Returns
+// EndpointHealthy for nil metrics.
+func (m *LBMetrics) State() EndpointState {
+	if m == nil {
+		return EndpointHealthy
+	}
+
+	return EndpointState(atomic.LoadInt32(&m.state))
+}
+
+// SetBackoffUntil makes the proxy avoid the endpoint until the given
+// time, like an unhealthy endpoint, e.g. when the endpoint responded
+// with a Retry-After header.
+func (m *LBMetrics) SetBackoffUntil(t time.Time) {
+	atomic.StoreInt64(&m.backoffUntil, t.UnixNano())
+}
+
+// BackoffUntil returns the time until the proxy avoids the endpoint.
+// Returns the zero time when the endpoint was not backed off, or for nil
+// metrics.
+func (m *LBMetrics) BackoffUntil() time.Time {
+	if m == nil {
+		return time.Time{}
+	}
+
+	if n := atomic.LoadInt64(&m.backoffUntil); n != 0 {
+		return time.Unix(0, n)
+	}
+
+	return time.Time{}
+}
+
+// EffectiveState returns the state of the endpoint used by the proxy:
+// the healthy endpoints are treated as unhealthy while they are backed
+// off.
+func (m *LBMetrics) EffectiveState(now time.Time) EndpointState {
+	s := m.State()
+	if s == EndpointHealthy && now.Before(m.BackoffUntil()) {
+		return EndpointUnhealthy
+	}
+
+	return s
 }
 
 // IncInflightRequest increments the number of outstanding requests from the proxy to a given backend.
@@ -165,6 +307,16 @@ func (m *LBMetrics) GetInflightRequests() int {
 	return int(atomic.LoadInt64(&m.inflightRequests))
 }
 
+// IncFailedRequest increments the number of failed requests from the proxy to a given backend.
+func (m *LBMetrics) IncFailedRequest() {
+	atomic.AddInt64(&m.failedRequests, 1)
+}
+
+// GetFailedRequests returns the number of failed requests from the proxy to a given backend.
+func (m *LBMetrics) GetFailedRequests() int64 {
+	return atomic.LoadInt64(&m.failedRequests)
+}
+
 // LBEndpoint represents the scheme and the host of load balanced
 // backends.
 type LBEndpoint struct {
@@ -209,6 +361,10 @@ type Route struct {
 	// weight used internally, received from the Weight() predicates.
 	weight int
 
+	// evaluation costs of the custom predicates, in the same order as
+	// the predicates
+	predicateCosts []int
+
 	// path predicate matching a subtree
 	path string
 
@@ -244,6 +400,15 @@ type Route struct {
 	// configured by the post-processor found in the filters/fadein
 	// package.
 	LBFadeInExponent float64
+
+	// Stats contain the live operational data of the route, kept
+	// across the routing updates by route id.
+	Stats *RouteStats
+
+	// Annotations contain the key-value metadata of the route, set by
+	// the annotate filters of the route definition. They are available
+	// to all the filters of the route, independent of their order.
+	Annotations map[string]string
 }
 
 // PostProcessor is an interface for custom post-processors applying changes
@@ -253,6 +418,13 @@ type PostProcessor interface {
 	Do([]*Route) []*Route
 }
 
+// FilterInspector is implemented by the post-processors that inspect the
+// filter instances of the routes, e.g. by their type. The filters that it
+// names are not created lazily, see Options.LazyFilters.
+type FilterInspector interface {
+	InspectedFilters() []string
+}
+
 // PreProcessor is an interface for custom pre-processors applying changes
 // to the routes before they were created from eskip.Route representation.
 type PreProcessor interface {
@@ -267,6 +439,9 @@ type Routing struct {
 	firstLoad         chan struct{}
 	firstLoadSignaled bool
 	quit              chan struct{}
+	unusedThreshold   time.Duration
+	subscribers       subscribers
+	eventsMx          sync.Mutex
 }
 
 // New initializes a routing instance, and starts listening for route
@@ -276,7 +451,13 @@ func New(o Options) *Routing {
 		o.Log = &logging.DefaultLog{}
 	}
 
-	r := &Routing{log: o.Log, firstLoad: make(chan struct{}), quit: make(chan struct{})}
+	r := &Routing{
+		log:             o.Log,
+		firstLoad:       make(chan struct{}),
+		quit:            make(chan struct{}),
+		unusedThreshold: o.UnusedRouteThreshold,
+	}
+
 	if !o.SignalFirstLoad {
 		close(r.firstLoad)
 		r.firstLoadSignaled = true
@@ -288,7 +469,15 @@ func New(o Options) *Routing {
 		created: time.Now().UTC(),
 	}
 	r.routeTable.Store(rt)
+	for name, reason := range refusedLazyFilters(o, o.FilterRegistry) {
+		o.Log.Errorf("filter %q is not created lazily: %s", name, reason)
+	}
+
 	r.startReceivingUpdates(o)
+	if o.Metrics != nil && o.UnusedRouteThreshold > 0 {
+		go r.reportUnusedRoutes(o.Metrics)
+	}
+
 	return r
 }
 
@@ -309,8 +498,7 @@ func (r *Routing) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 	}
 
 	if req.Method == "HEAD" {
-		w.Header().Set(routesTimestampName, createdUnix)
-		w.Header().Set(RoutesCountName, strconv.Itoa(len(rt.validRoutes)))
+		setRoutesHeaders(w, rt, createdUnix)
 
 		if strings.Contains(req.Header.Get("Accept"), "application/json") {
 			w.Header().Set("Content-Type", "application/json")
@@ -333,10 +521,35 @@ func (r *Routing) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 
-	w.Header().Set(routesTimestampName, createdUnix)
-	w.Header().Set(RoutesCountName, strconv.Itoa(len(rt.validRoutes)))
+	setRoutesHeaders(w, rt, createdUnix)
+
+	if _, ok := req.Form["errors"]; ok {
+		serveRouteErrors(w, req, rt.errors)
+		return
+	}
+
+	if _, ok := req.Form["unused"]; ok {
+		threshold, err := r.parseUnusedThreshold(req.Form.Get("unused"))
+		if err != nil {
+			http.Error(w, "invalid unused threshold", http.StatusBadRequest)
+			return
+		}
+
+		serveUnusedRoutes(w, req, rt, slice(rt.unusedRoutes(time.Now(), threshold), offset, limit))
+		return
+	}
 
 	routes := slice(rt.validRoutes, offset, limit)
+	if _, ok := req.Form["status"]; ok {
+		serveRouteStatus(w, req, rt, routes)
+		return
+	}
+
+	if _, ok := req.Form["usage"]; ok {
+		serveRouteUsage(w, req, rt, routes)
+		return
+	}
+
 	if strings.Contains(req.Header.Get("Accept"), "application/json") {
 		w.Header().Set("Content-Type", "application/json")
 		if err := json.NewEncoder(w).Encode(routes); err != nil {
@@ -353,6 +566,45 @@ func (r *Routing) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 	eskip.Fprint(w, extractPretty(req), routes...)
 }
 
+func setRoutesHeaders(w http.ResponseWriter, rt *routeTable, createdUnix string) {
+	w.Header().Set(routesTimestampName, createdUnix)
+	w.Header().Set(RoutesCountName, strconv.Itoa(len(rt.validRoutes)))
+	w.Header().Set(routesInvalidCountName, strconv.Itoa(len(rt.errors)))
+	if rt.rejected {
+		w.Header().Set(routesRejectedName, "true")
+	}
+}
+
+// renders the errors of the invalid routes from the last update, by route id
+func serveRouteErrors(w http.ResponseWriter, req *http.Request, errors map[string]string) {
+	if errors == nil {
+		errors = map[string]string{}
+	}
+
+	if strings.Contains(req.Header.Get("Accept"), "application/json") {
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(errors); err != nil {
+			http.Error(
+				w,
+				http.StatusText(http.StatusInternalServerError),
+				http.StatusInternalServerError,
+			)
+		}
+		return
+	}
+
+	ids := make([]string, 0, len(errors))
+	for id := range errors {
+		ids = append(ids, id)
+	}
+
+	sort.Strings(ids)
+	w.Header().Set("Content-Type", "text/plain")
+	for _, id := range ids {
+		fmt.Fprintf(w, "%s: %s\n", id, errors[id])
+	}
+}
+
 func (r *Routing) startReceivingUpdates(o Options) {
 	dc := len(o.DataClients)
 	c := make(chan *routeTable)
@@ -361,7 +613,13 @@ func (r *Routing) startReceivingUpdates(o Options) {
 		for {
 			select {
 			case rt := <-c:
-				r.routeTable.Store(rt)
+				if rt.rejected {
+					current := r.routeTable.Load().(*routeTable)
+					r.routeTable.Store(current.rejectedWith(rt.errors))
+				} else {
+					r.applyTable(rt)
+				}
+
 				if !r.firstLoadSignaled {
 					dc--
 					if dc == 0 {
@@ -369,7 +627,12 @@ func (r *Routing) startReceivingUpdates(o Options) {
 						r.firstLoadSignaled = true
 					}
 				}
-				r.log.Info("route settings applied")
+
+				if rt.rejected {
+					r.log.Error("route settings rejected, keeping the previous routes")
+				} else {
+					r.log.Info("route settings applied")
+				}
 			case <-r.quit:
 				return
 			}
@@ -420,6 +683,23 @@ func (r *Routing) Get() *RouteLookup {
 	return &RouteLookup{matcher: rt.m}
 }
 
+// Snapshot contains the valid routes of a routing table, after the
+// pre-processing, e.g. with the default filters and the route editing
+// applied, and the time when the table was created.
+type Snapshot struct {
+	Created time.Time      `json:"created"`
+	Routes  []*eskip.Route `json:"routes"`
+}
+
+// Snapshot returns the valid routes of the current routing table.
+func (r *Routing) Snapshot() *Snapshot {
+	rt := r.routeTable.Load().(*routeTable)
+	return &Snapshot{
+		Created: rt.created,
+		Routes:  append([]*eskip.Route(nil), rt.validRoutes...),
+	}
+}
+
 // Close closes routing, stops receiving routes.
 func (r *Routing) Close() {
 	close(r.quit)
@@ -1,10 +1,14 @@
 package routing
 
 import (
+	"errors"
+	"fmt"
+	"runtime"
 	"testing"
 
 	"github.com/google/go-cmp/cmp"
 	"github.com/zalando/skipper/eskip"
+	"github.com/zalando/skipper/filters"
 )
 
 func TestSlice(t *testing.T) {
@@ -75,3 +79,73 @@ func TestSlice(t *testing.T) {
 		})
 	}
 }
+
+type processTestFilter struct{}
+
+func (processTestFilter) Name() string                   { return "processTest" }
+func (processTestFilter) Request(filters.FilterContext)  {}
+func (processTestFilter) Response(filters.FilterContext) {}
+func (f processTestFilter) CreateFilter(args []interface{}) (filters.Filter, error) {
+	if len(args) != 1 || args[0] == "invalid" {
+		return nil, errors.New("invalid argument")
+	}
+
+	return f, nil
+}
+
+func processTestDefs(n int) []*eskip.Route {
+	defs := make([]*eskip.Route, n)
+	for i := range defs {
+		arg := "valid"
+		if i%7 == 0 {
+			arg = "invalid"
+		}
+
+		defs[i] = &eskip.Route{
+			Id:          fmt.Sprintf("route%d", i),
+			HostRegexps: []string{fmt.Sprintf("^www[.]example%d[.]org$", i)},
+			Predicates:  []*eskip.Predicate{{Name: "Path", Args: []interface{}{fmt.Sprintf("/foo/%d/bar", i)}}},
+			Filters:     []*eskip.Filter{{Name: "processTest", Args: []interface{}{arg}}},
+			Backend:     fmt.Sprintf("https://backend%d.example.org", i),
+		}
+	}
+
+	return defs
+}
+
+func TestProcessRouteDefsConcurrently(t *testing.T) {
+	fr := filters.Registry{"processTest": processTestFilter{}}
+	defs := processTestDefs(3*minConcurrentProcessSize + 5)
+	sequential := processRouteDefsConcurrently(nil, fr, processOptions{}, defs, 1)
+	concurrent := processRouteDefsConcurrently(nil, fr, processOptions{}, defs, 8)
+	if len(sequential) != len(defs) || len(concurrent) != len(defs) {
+		t.Fatalf("unexpected number of results: %d, %d", len(sequential), len(concurrent))
+	}
+
+	for i, def := range defs {
+		s, c := sequential[i], concurrent[i]
+		if (s.err == nil) != (i%7 != 0) || (c.err == nil) != (s.err == nil) {
+			t.Fatalf("unexpected error of route %s: %v, %v", def.Id, s.err, c.err)
+		}
+
+		if s.err == nil && (c.route.Id != def.Id || c.route.Host != s.route.Host || len(c.route.Filters) != 1) {
+			t.Fatalf("unexpected result for route %s: %v", def.Id, c.route)
+		}
+	}
+}
+
+func BenchmarkProcessRouteDefs(b *testing.B) {
+	fr := filters.Registry{"processTest": processTestFilter{}}
+	defs := processTestDefs(100_000)
+	bench := func(workers int) func(*testing.B) {
+		return func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				processRouteDefsConcurrently(nil, fr, processOptions{}, defs, workers)
+			}
+		}
+	}
+
+	b.Run("sequential", bench(1))
+	b.Run("concurrent", bench(runtime.GOMAXPROCS(0)))
+}
@@ -655,6 +655,29 @@ func TestRoutingHandlerFilterInvalidRoutes(t *testing.T) {
 	}
 }
 
+func TestSnapshot(t *testing.T) {
+	dc, _ := testdataclient.NewDoc(`
+		route1: Path("/foo") -> "https://route1.example.org";
+		route2: FooBar("custom2") -> "https://route2.example.org";
+		catchAll: * -> "https://route.example.org"`)
+	tr, _ := newTestRouting(dc)
+	defer tr.close()
+
+	s := tr.routing.Snapshot()
+	if s.Created.IsZero() {
+		t.Error("missing creation time")
+	}
+
+	routeIds := []string{}
+	for _, r := range s.Routes {
+		routeIds = append(routeIds, r.Id)
+	}
+
+	if !stringsAreSame(routeIds, []string{"route1", "catchAll"}) {
+		t.Errorf("unexpected routes: %v", routeIds)
+	}
+}
+
 func TestRoutingHandlerPagination(t *testing.T) {
 	dc, _ := testdataclient.NewDoc(`
 		route1: CustomPredicate("custom1") -> "https://route1.example.org";
@@ -0,0 +1,91 @@
+package routing
+
+import (
+	"hash/fnv"
+
+	"github.com/zalando/skipper/eskip"
+)
+
+// number of shards the route definitions of a single data client are
+// distributed over. Updates copy only the shards that contain changed
+// routes, the rest is shared with the previous generation.
+const routeShardCount = 64
+
+// routeShards stores the route definitions of a data client in
+// copy-on-write shards. A routeShards value, once created, is never
+// modified. Applying an update returns a new value that references the
+// untouched shards of the previous one, this way the memory used by the
+// definitions doesn't double during large updates.
+type routeShards [routeShardCount]routeDefs
+
+func routeShardIndex(id string) int {
+	h := fnv.New32a()
+	h.Write([]byte(id))
+	return int(h.Sum32() % routeShardCount)
+}
+
+// applies incoming route definitions to the shards, copying only the
+// shards that contain a change.
+func (s *routeShards) apply(d *incomingData) *routeShards {
+	next := &routeShards{}
+	if d.typ != incomingReset && s != nil {
+		*next = *s
+	}
+
+	copied := make(map[int]bool)
+	shard := func(i int) routeDefs {
+		if !copied[i] {
+			c := make(routeDefs, len(next[i])+1)
+			for id, def := range next[i] {
+				c[id] = def
+			}
+
+			next[i] = c
+			copied[i] = true
+		}
+
+		return next[i]
+	}
+
+	if d.typ == incomingUpdate {
+		for _, id := range d.deletedIds {
+			i := routeShardIndex(id)
+			if _, ok := next[i][id]; ok {
+				delete(shard(i), id)
+			}
+		}
+	}
+
+	if d.typ == incomingReset || d.typ == incomingUpdate {
+		for _, def := range d.upsertedRoutes {
+			shard(routeShardIndex(def.Id))[def.Id] = def
+		}
+	}
+
+	return next
+}
+
+func (s *routeShards) len() int {
+	if s == nil {
+		return 0
+	}
+
+	var n int
+	for _, shard := range s {
+		n += len(shard)
+	}
+
+	return n
+}
+
+func (s *routeShards) each(f func(string, *eskip.Route)) {
+	if s == nil {
+		return
+	}
+
+	for _, shard := range s {
+		for id, def := range shard {
+			f(id, def)
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package routing
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/zalando/skipper/eskip"
+)
+
+func testRouteDefs(n int) []*eskip.Route {
+	var r []*eskip.Route
+	for i := 0; i < n; i++ {
+		r = append(r, &eskip.Route{Id: fmt.Sprintf("route%d", i), Backend: "https://www.example.org"})
+	}
+
+	return r
+}
+
+func TestRouteShardsApply(t *testing.T) {
+	defs := testRouteDefs(1000)
+
+	var s *routeShards
+	s = s.apply(&incomingData{typ: incomingReset, upsertedRoutes: defs})
+	if s.len() != len(defs) {
+		t.Fatalf("failed to apply reset, got %d routes, expected %d", s.len(), len(defs))
+	}
+
+	changed := &eskip.Route{Id: "route42", Backend: "https://changed.example.org"}
+	next := s.apply(&incomingData{
+		typ:            incomingUpdate,
+		upsertedRoutes: []*eskip.Route{changed},
+		deletedIds:     []string{"route7", "not-existing"},
+	})
+
+	if next.len() != len(defs)-1 {
+		t.Fatalf("failed to apply update, got %d routes, expected %d", next.len(), len(defs)-1)
+	}
+
+	if s.len() != len(defs) {
+		t.Fatal("previous generation was modified")
+	}
+
+	changedShard := routeShardIndex("route42")
+	deletedShard := routeShardIndex("route7")
+	for i := range next {
+		shared := fmt.Sprintf("%p", next[i]) == fmt.Sprintf("%p", s[i])
+		touched := i == changedShard || i == deletedShard
+		if shared == touched {
+			t.Errorf("unexpected sharing of shard %d, shared: %v, touched: %v", i, shared, touched)
+		}
+	}
+
+	if next[changedShard]["route42"] != changed {
+		t.Error("failed to upsert route")
+	}
+
+	if _, ok := next[deletedShard]["route7"]; ok {
+		t.Error("failed to delete route")
+	}
+
+	reset := next.apply(&incomingData{typ: incomingReset, upsertedRoutes: defs[:3]})
+	if reset.len() != 3 {
+		t.Errorf("failed to reset, got %d routes, expected 3", reset.len())
+	}
+}
+
+func TestMergeDefsFromShards(t *testing.T) {
+	var c1, c2 *routeShards
+	c1 = c1.apply(&incomingData{typ: incomingReset, upsertedRoutes: testRouteDefs(10)})
+	c2 = c2.apply(&incomingData{typ: incomingReset, upsertedRoutes: []*eskip.Route{{Id: "route3"}, {Id: "other"}}})
+
+	if all := mergeDefs(map[DataClient]*routeShards{nil: c1}); len(all) != 10 {
+		t.Errorf("unexpected number of routes from a single client: %d", len(all))
+	}
+
+	type client struct{ DataClient }
+	all := mergeDefs(map[DataClient]*routeShards{&client{}: c1, &client{}: c2})
+	if len(all) != 11 {
+		t.Errorf("unexpected number of merged routes: %d", len(all))
+	}
+}
+
+func TestMatcherReusesCompiledRegexps(t *testing.T) {
+	r := &Route{Route: eskip.Route{Id: "r", HostRegexps: []string{"^www[.]example[.]org$"}}}
+	m1, errs := newMatcher([]*Route{r}, MatchingOptionsNone)
+	if len(errs) != 0 {
+		t.Fatal(errs)
+	}
+
+	m2, errs := newMatcherFrom(m1, []*Route{r}, MatchingOptionsNone)
+	if len(errs) != 0 {
+		t.Fatal(errs)
+	}
+
+	if m1.rootLeaves[0].hostRxs[0] != m2.rootLeaves[0].hostRxs[0] {
+		t.Error("failed to reuse compiled regexp")
+	}
+
+	m3, _ := newMatcherFrom(m2, nil, MatchingOptionsNone)
+	if len(m3.compiledRxs) != 0 {
+		t.Error("failed to drop unused regexps")
+	}
+}
@@ -0,0 +1,145 @@
+package routing
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/zalando/skipper/eskip"
+	"github.com/zalando/skipper/metrics"
+)
+
+const (
+	unusedRoutesMetric = "routes.unused"
+
+	// the default threshold of the routes API, when not set in the
+	// request or in the options
+	defaultUnusedRouteThreshold = 30 * 24 * time.Hour
+)
+
+var unusedRoutesReportInterval = time.Minute
+
+// UnusedRoute is a route that didn't handle any request for longer than
+// the requested threshold.
+type UnusedRoute struct {
+	Id           string     `json:"id"`
+	LastHit      *time.Time `json:"lastHit,omitempty"`
+	TrackedSince time.Time  `json:"trackedSince"`
+}
+
+// Unused tells whether the route didn't handle any request since the
+// threshold before now. The routes that never handled a request are
+// considered unused only when they are tracked for longer than the
+// threshold, so that the new routes are not reported.
+func (s *RouteStats) Unused(now time.Time, threshold time.Duration) bool {
+	if s == nil {
+		return false
+	}
+
+	last := s.LastHit()
+	if last.IsZero() {
+		last = s.since
+	}
+
+	return now.Sub(last) > threshold
+}
+
+// returns the valid routes unused for longer than the threshold, ordered
+// by id
+func (rt *routeTable) unusedRoutes(now time.Time, threshold time.Duration) []*eskip.Route {
+	var unused []*eskip.Route
+	for _, r := range rt.validRoutes {
+		if route, ok := rt.routes[r.Id]; ok && route.Stats.Unused(now, threshold) {
+			unused = append(unused, r)
+		}
+	}
+
+	return unused
+}
+
+// parses the threshold of the unused routes in the routes API. Besides
+// the Go duration format, it accepts the number of days, e.g. 30d.
+func (r *Routing) parseUnusedThreshold(v string) (time.Duration, error) {
+	if v == "" {
+		if r.unusedThreshold > 0 {
+			return r.unusedThreshold, nil
+		}
+
+		return defaultUnusedRouteThreshold, nil
+	}
+
+	if strings.HasSuffix(v, "d") {
+		n, err := strconv.Atoi(strings.TrimSuffix(v, "d"))
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("invalid number of days: %s", v)
+		}
+
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+
+	d, err := time.ParseDuration(v)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("invalid duration: %s", v)
+	}
+
+	return d, nil
+}
+
+// reports the number of the unused routes periodically, until routing
+// is closed
+func (r *Routing) reportUnusedRoutes(m metrics.Metrics) {
+	t := time.NewTicker(unusedRoutesReportInterval)
+	defer t.Stop()
+	for {
+		select {
+		case now := <-t.C:
+			rt := r.routeTable.Load().(*routeTable)
+			m.UpdateGauge(unusedRoutesMetric, float64(len(rt.unusedRoutes(now, r.unusedThreshold))))
+		case <-r.quit:
+			return
+		}
+	}
+}
+
+// renders the listed unused routes
+func serveUnusedRoutes(w http.ResponseWriter, req *http.Request, rt *routeTable, list []*eskip.Route) {
+	unused := make([]UnusedRoute, 0, len(list))
+	for _, r := range list {
+		route, ok := rt.routes[r.Id]
+		if !ok {
+			continue
+		}
+
+		u := UnusedRoute{Id: r.Id, TrackedSince: route.Stats.Since()}
+		if t := route.Stats.LastHit(); !t.IsZero() {
+			u.LastHit = &t
+		}
+
+		unused = append(unused, u)
+	}
+
+	if strings.Contains(req.Header.Get("Accept"), "application/json") {
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(unused); err != nil {
+			http.Error(
+				w,
+				http.StatusText(http.StatusInternalServerError),
+				http.StatusInternalServerError,
+			)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain")
+	for _, u := range unused {
+		lastHit := "never"
+		if u.LastHit != nil {
+			lastHit = u.LastHit.UTC().Format(time.RFC3339)
+		}
+
+		fmt.Fprintf(w, "%s: lastHit=%s trackedSince=%s\n", u.Id, lastHit, u.TrackedSince.UTC().Format(time.RFC3339))
+	}
+}
@@ -0,0 +1,184 @@
+package routing_test
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/zalando/skipper/filters/builtin"
+	"github.com/zalando/skipper/logging/loggingtest"
+	"github.com/zalando/skipper/metrics/metricstest"
+	"github.com/zalando/skipper/routing"
+	"github.com/zalando/skipper/routing/testdataclient"
+)
+
+func TestRouteUnused(t *testing.T) {
+	now := time.Unix(3600, 0)
+	s := routing.ExportNewRouteStats()
+	routing.SetRouteStatsClock(s, func() time.Time { return now })
+
+	if s.Unused(now.Add(24*time.Hour), 48*time.Hour) {
+		t.Error("new route reported as unused")
+	}
+
+	if !s.Unused(now.Add(72*time.Hour), 48*time.Hour) {
+		t.Error("failed to report route never used")
+	}
+
+	now = now.Add(48 * time.Hour)
+	s.Begin()
+	s.End(false)
+
+	if s.Unused(now.Add(24*time.Hour), 48*time.Hour) {
+		t.Error("used route reported as unused")
+	}
+
+	if !s.Unused(now.Add(72*time.Hour), 48*time.Hour) {
+		t.Error("failed to report route not used recently")
+	}
+
+	var nilStats *routing.RouteStats
+	if nilStats.Unused(now, 0) {
+		t.Error("nil stats reported as unused")
+	}
+}
+
+func TestUnusedRoutesAPI(t *testing.T) {
+	l := loggingtest.New()
+	defer l.Close()
+
+	dc, err := testdataclient.NewDoc(`
+		foo: Path("/foo") -> "https://foo.example.org";
+		bar: Path("/bar") -> "https://bar.example.org";
+		baz: Path("/baz") -> <shunt>;
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rt := routing.New(routing.Options{
+		FilterRegistry: builtin.MakeRegistry(),
+		DataClients:    []routing.DataClient{dc},
+		PollTimeout:    12 * time.Millisecond,
+		Log:            l,
+	})
+	defer rt.Close()
+
+	server := httptest.NewServer(rt)
+	defer server.Close()
+
+	if err := l.WaitFor("route settings applied", 120*time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+
+	get := func(query, accept string, expectedStatus int) string {
+		req, _ := http.NewRequest("GET", server.URL+"?"+query, nil)
+		req.Header.Set("Accept", accept)
+		rsp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		defer rsp.Body.Close()
+		if rsp.StatusCode != expectedStatus {
+			t.Fatalf("unexpected status: %d", rsp.StatusCode)
+		}
+
+		b, err := io.ReadAll(rsp.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return string(b)
+	}
+
+	if text := get("unused", "text/plain", http.StatusOK); text != "" {
+		t.Errorf("unexpected unused routes with the default threshold: %s", text)
+	}
+
+	for _, q := range []string{"unused=foo", "unused=-1h", "unused=xd"} {
+		get(q, "text/plain", http.StatusBadRequest)
+	}
+
+	time.Sleep(30 * time.Millisecond)
+	foo, _ := rt.Route(&http.Request{URL: &url.URL{Path: "/foo"}})
+	if foo == nil {
+		t.Fatal("route not found")
+	}
+
+	foo.Stats.Begin()
+	foo.Stats.End(false)
+
+	var unused []routing.UnusedRoute
+	if err := json.Unmarshal([]byte(get("unused=20ms", "application/json", http.StatusOK)), &unused); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(unused) != 2 || unused[0].Id != "bar" || unused[1].Id != "baz" ||
+		unused[0].LastHit != nil || unused[0].TrackedSince.IsZero() {
+		t.Errorf("unexpected unused routes: %+v", unused)
+	}
+
+	text := get("unused=20ms&limit=1", "text/plain", http.StatusOK)
+	if !strings.HasPrefix(text, "bar: lastHit=never trackedSince=") || strings.Count(text, "\n") != 1 {
+		t.Errorf("unexpected unused routes: %s", text)
+	}
+
+	if text := get("unused=1d", "text/plain", http.StatusOK); text != "" {
+		t.Errorf("unexpected unused routes: %s", text)
+	}
+}
+
+func TestUnusedRoutesMetric(t *testing.T) {
+	routing.SetUnusedRoutesReportInterval(10 * time.Millisecond)
+	defer routing.SetUnusedRoutesReportInterval(time.Minute)
+
+	l := loggingtest.New()
+	defer l.Close()
+
+	dc, err := testdataclient.NewDoc(`
+		foo: Path("/foo") -> "https://foo.example.org";
+		bar: Path("/bar") -> "https://bar.example.org";
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m := &metricstest.MockMetrics{}
+	rt := routing.New(routing.Options{
+		FilterRegistry:       builtin.MakeRegistry(),
+		DataClients:          []routing.DataClient{dc},
+		PollTimeout:          12 * time.Millisecond,
+		Log:                  l,
+		Metrics:              m,
+		UnusedRouteThreshold: 20 * time.Millisecond,
+	})
+	defer rt.Close()
+
+	if err := l.WaitFor("route settings applied", 120*time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for {
+		foo, _ := rt.Route(&http.Request{URL: &url.URL{Path: "/foo"}})
+		foo.Stats.Begin()
+		foo.Stats.End(false)
+
+		if v, ok := m.Gauge("routes.unused"); ok && v == 1 {
+			return
+		}
+
+		if time.Now().After(deadline) {
+			v, _ := m.Gauge("routes.unused")
+			t.Fatalf("failed to report the unused routes: %v", v)
+		}
+
+		time.Sleep(time.Millisecond)
+	}
+}
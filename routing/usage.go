@@ -0,0 +1,116 @@
+package routing
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/zalando/skipper/eskip"
+)
+
+// RouteUsage contains how often a route was used.
+type RouteUsage struct {
+	Id       string     `json:"id"`
+	LastHour int        `json:"lastHour"`
+	LastHit  *time.Time `json:"lastHit,omitempty"`
+}
+
+// Usage reports how many routes use each filter and predicate, and how
+// often the routes were used.
+type Usage struct {
+	Filters    map[string]int `json:"filters"`
+	Predicates map[string]int `json:"predicates"`
+	Routes     []RouteUsage   `json:"routes"`
+}
+
+// counts the routes using each filter and predicate, including the
+// predicates set with the legacy fields of the routes, e.g. Path or
+// Method
+func countUsage(routes []*eskip.Route) (filters, predicates map[string]int) {
+	filters = make(map[string]int)
+	predicates = make(map[string]int)
+	for _, r := range routes {
+		seen := make(map[string]bool)
+		for _, f := range r.Filters {
+			if !seen["f:"+f.Name] {
+				seen["f:"+f.Name] = true
+				filters[f.Name]++
+			}
+		}
+
+		for _, p := range eskip.Canonical(r).Predicates {
+			if !seen["p:"+p.Name] {
+				seen["p:"+p.Name] = true
+				predicates[p.Name]++
+			}
+		}
+	}
+
+	return
+}
+
+// Usage returns the usage of the route.
+func (r *Route) Usage() RouteUsage {
+	u := RouteUsage{Id: r.Id, LastHour: r.Stats.LastHour()}
+	if t := r.Stats.LastHit(); !t.IsZero() {
+		u.LastHit = &t
+	}
+
+	return u
+}
+
+func sortedKeys(m map[string]int) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+	return keys
+}
+
+// renders the usage of the filters and the predicates in the whole
+// routing table, and the usage of the listed routes
+func serveRouteUsage(w http.ResponseWriter, req *http.Request, rt *routeTable, list []*eskip.Route) {
+	var u Usage
+	u.Filters, u.Predicates = countUsage(rt.validRoutes)
+	u.Routes = make([]RouteUsage, 0, len(list))
+	for _, r := range list {
+		if route, ok := rt.routes[r.Id]; ok {
+			u.Routes = append(u.Routes, route.Usage())
+		}
+	}
+
+	if strings.Contains(req.Header.Get("Accept"), "application/json") {
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(u); err != nil {
+			http.Error(
+				w,
+				http.StatusText(http.StatusInternalServerError),
+				http.StatusInternalServerError,
+			)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain")
+	for _, name := range sortedKeys(u.Filters) {
+		fmt.Fprintf(w, "filter %s: %d\n", name, u.Filters[name])
+	}
+
+	for _, name := range sortedKeys(u.Predicates) {
+		fmt.Fprintf(w, "predicate %s: %d\n", name, u.Predicates[name])
+	}
+
+	for _, r := range u.Routes {
+		lastHit := "never"
+		if r.LastHit != nil {
+			lastHit = r.LastHit.UTC().Format(time.RFC3339)
+		}
+
+		fmt.Fprintf(w, "route %s: lastHour=%d lastHit=%s\n", r.Id, r.LastHour, lastHit)
+	}
+}
@@ -0,0 +1,136 @@
+package routing_test
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/zalando/skipper/filters/builtin"
+	"github.com/zalando/skipper/logging/loggingtest"
+	"github.com/zalando/skipper/predicates/query"
+	"github.com/zalando/skipper/routing"
+	"github.com/zalando/skipper/routing/testdataclient"
+)
+
+func TestRouteUsage(t *testing.T) {
+	now := time.Unix(3600, 0)
+	s := routing.ExportNewRouteStats()
+	routing.SetRouteStatsClock(s, func() time.Time { return now })
+
+	r := &routing.Route{Stats: s}
+	r.Id = "foo"
+	if u := r.Usage(); u.LastHour != 0 || u.LastHit != nil {
+		t.Errorf("unexpected usage of an unused route: %+v", u)
+	}
+
+	s.Begin()
+	s.End(false)
+	now = now.Add(30 * time.Minute)
+	s.Begin()
+	s.End(true)
+
+	if u := r.Usage(); u.Id != "foo" || u.LastHour != 2 || u.LastHit == nil || !u.LastHit.Equal(now) {
+		t.Errorf("unexpected usage: %+v", u)
+	}
+
+	now = now.Add(45 * time.Minute)
+	if u := r.Usage(); u.LastHour != 1 {
+		t.Errorf("failed to drop the requests older than an hour: %+v", u)
+	}
+
+	now = now.Add(time.Hour)
+	if u := r.Usage(); u.LastHour != 0 || u.LastHit == nil {
+		t.Errorf("unexpected usage: %+v", u)
+	}
+}
+
+func TestRouteUsageAPI(t *testing.T) {
+	l := loggingtest.New()
+	defer l.Close()
+
+	dc, err := testdataclient.NewDoc(`
+		foo: Path("/foo") && Method("GET") -> setPath("/") -> setPath("/bar") -> "https://foo.example.org";
+		bar: Path("/bar") && Header("X-Test", "1") -> setPath("/") -> status(204) -> <shunt>;
+		baz: QueryParam("q") -> "https://baz.example.org";
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rt := routing.New(routing.Options{
+		FilterRegistry: builtin.MakeRegistry(),
+		DataClients:    []routing.DataClient{dc},
+		PollTimeout:    12 * time.Millisecond,
+		Predicates:     []routing.PredicateSpec{query.New()},
+		Log:            l,
+	})
+	defer rt.Close()
+
+	server := httptest.NewServer(rt)
+	defer server.Close()
+
+	if err := l.WaitFor("route settings applied", 120*time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+
+	foo, _ := rt.Route(&http.Request{Method: "GET", URL: &url.URL{Path: "/foo"}})
+	if foo == nil {
+		t.Fatal("route not found")
+	}
+
+	foo.Stats.Begin()
+	foo.Stats.End(false)
+
+	get := func(accept string) string {
+		req, _ := http.NewRequest("GET", server.URL+"?usage", nil)
+		req.Header.Set("Accept", accept)
+		rsp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		defer rsp.Body.Close()
+		b, err := io.ReadAll(rsp.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return string(b)
+	}
+
+	var u routing.Usage
+	if err := json.Unmarshal([]byte(get("application/json")), &u); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(u.Filters) != 2 || u.Filters["setPath"] != 2 || u.Filters["status"] != 1 {
+		t.Errorf("unexpected filter usage: %v", u.Filters)
+	}
+
+	if len(u.Predicates) != 4 || u.Predicates["Path"] != 2 || u.Predicates["Method"] != 1 ||
+		u.Predicates["Header"] != 1 || u.Predicates["QueryParam"] != 1 {
+		t.Errorf("unexpected predicate usage: %v", u.Predicates)
+	}
+
+	if len(u.Routes) != 3 || u.Routes[2].Id != "foo" || u.Routes[2].LastHour != 1 || u.Routes[2].LastHit == nil ||
+		u.Routes[0].LastHour != 0 || u.Routes[0].LastHit != nil {
+		t.Errorf("unexpected route usage: %+v", u.Routes)
+	}
+
+	text := get("text/plain")
+	for _, line := range []string{
+		"filter setPath: 2\n",
+		"predicate Path: 2\n",
+		"route bar: lastHour=0 lastHit=never\n",
+		"route foo: lastHour=1 lastHit=",
+	} {
+		if !strings.Contains(text, line) {
+			t.Errorf("missing line %q in:\n%s", line, text)
+		}
+	}
+}
@@ -84,3 +84,20 @@ func TestFifo(t *testing.T) {
 	})
 
 }
+
+func TestProcessingTimeEstimate(t *testing.T) {
+	var p processingTime
+	if d := p.estimate(3, 1); d != 0 {
+		t.Errorf("unexpected estimate without observations: %v", d)
+	}
+
+	p.observe(100 * time.Millisecond)
+	if d := p.estimate(3, 2); d != 150*time.Millisecond {
+		t.Errorf("unexpected estimate: %v", d)
+	}
+
+	p.observe(200 * time.Millisecond)
+	if d := p.estimate(1, 1); d != 120*time.Millisecond {
+		t.Errorf("unexpected moving average: %v", d)
+	}
+}
@@ -6,6 +6,8 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"sort"
+	"strings"
 	"sync"
 	"time"
 
@@ -26,6 +28,8 @@ const (
 	LIFOKey = "lifo"
 	// FIFOKey used during routing to pass fifo values from the filters to the proxy.
 	FIFOKey = "fifo"
+	// BulkheadKey used during routing to pass bulkhead values from the filters to the proxy.
+	BulkheadKey = "bulkhead"
 )
 
 var (
@@ -67,12 +71,75 @@ type QueueStatus struct {
 	Closed bool
 }
 
+// QueueInfo describes the waiting of a request in a queue.
+type QueueInfo struct {
+
+	// Position is the position of the request in the queue, when it was
+	// queued. In the LIFO queues, the new requests are always at the
+	// first position. It is 0, when the request didn't need to wait.
+	Position int
+
+	// EstimatedWait is the waiting time estimated when the request was
+	// queued, based on its position, the concurrency and the average
+	// processing time of the recent requests. It is 0, when unknown.
+	EstimatedWait time.Duration
+
+	// Waited is the time the request spent in the queue.
+	Waited time.Duration
+}
+
+// the weight of the latest processing time in the moving average
+const processingTimeWeight = 0.2
+
+// processingTime tracks the exponential moving average of the processing
+// time of the requests, measured from leaving the queue until done
+type processingTime struct {
+	mu      sync.Mutex
+	average time.Duration
+}
+
+func (p *processingTime) observe(d time.Duration) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.average == 0 {
+		p.average = d
+		return
+	}
+
+	p.average = time.Duration(processingTimeWeight*float64(d) + (1-processingTimeWeight)*float64(p.average))
+}
+
+// estimate returns the expected waiting time at the given position
+func (p *processingTime) estimate(position, concurrency int) time.Duration {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	return p.average * time.Duration(position) / time.Duration(concurrency)
+}
+
+// done returns the function that releases the queue, and measures the
+// processing time
+func (p *processingTime) done(release func()) func() {
+	start := time.Now()
+	return func() {
+		p.observe(time.Since(start))
+		release()
+	}
+}
+
 // Queue objects implement a LIFO queue for handling requests, with a maximum allowed
 // concurrency and queue size. Currently, they can be used from the lifo and lifoGroup
 // filters in the filters/scheduler package only.
 type Queue struct {
 	queue                    *jobqueue.Stack
 	config                   Config
+	shedder                  Shedder
+	processing               processingTime
 	metrics                  metrics.Metrics
 	activeRequestsMetricsKey string
 	errorFullMetricsKey      string
@@ -87,6 +154,7 @@ type Queue struct {
 // only.
 type FifoQueue struct {
 	queue                    *fifoQueue
+	processing               processingTime
 	config                   Config
 	metrics                  metrics.Metrics
 	activeRequestsMetricsKey string
@@ -98,6 +166,7 @@ type FifoQueue struct {
 
 type fifoQueue struct {
 	mu             sync.RWMutex
+	shedder        Shedder
 	counter        *atomic.Uint64
 	sem            *semaphore.Weighted
 	timeout        time.Duration
@@ -146,7 +215,8 @@ func (fq *fifoQueue) reconfigure(c Config) {
 	fq.counter = atomic.NewUint64(0)
 }
 
-func (fq *fifoQueue) wait(ctx context.Context) (func(), error) {
+func (fq *fifoQueue) wait(ctx context.Context, p *processingTime) (func(), QueueInfo, error) {
+	var info QueueInfo
 	fq.mu.RLock()
 	maxConcurrency := fq.maxConcurrency
 	maxQueueSize := fq.maxQueueSize
@@ -160,32 +230,53 @@ func (fq *fifoQueue) wait(ctx context.Context) (func(), error) {
 	// queue full?
 	if all > maxConcurrency+maxQueueSize {
 		cnt.Dec()
-		return nil, ErrQueueFull
+		return nil, info, ErrQueueFull
+	}
+
+	// shedding the requests that would need to wait
+	if all > maxConcurrency && fq.shedder != nil && fq.shedder.Shed() {
+		cnt.Dec()
+		return nil, info, ErrQueueFull
+	}
+
+	if all > maxConcurrency {
+		info.Position = int(all - maxConcurrency)
+		info.EstimatedWait = p.estimate(info.Position, int(maxConcurrency))
 	}
 
 	// set timeout
-	c, done := context.WithTimeout(ctx, timeout)
-	defer done()
+	c := ctx
+	if timeout > 0 {
+		var done func()
+		c, done = context.WithTimeout(ctx, timeout)
+		defer done()
+	}
 
 	// limit concurrency
-	if err := sem.Acquire(c, 1); err != nil {
+	start := time.Now()
+	err := sem.Acquire(c, 1)
+	if info.Position > 0 {
+		info.Waited = time.Since(start)
+	}
+
+	if err != nil {
 		cnt.Dec()
 		switch err {
 		case context.DeadlineExceeded:
-			return nil, ErrQueueTimeout
+			return nil, info, ErrQueueTimeout
 		case context.Canceled:
-			return nil, ErrClientCanceled
+			return nil, info, ErrClientCanceled
 		default:
 			// does not exist yet in Go stdlib as of Go1.18.4
-			return nil, err
+			return nil, info, err
 		}
 	}
 
-	return func() {
+	return p.done(func() {
 		// postpone release to Response() filter
 		cnt.Dec()
 		sem.Release(1)
-	}, nil
+	}), info, nil
 
 }
 
@@ -205,6 +296,17 @@ type Options struct {
 
 	// Metrics must be provided to the registry in order to collect the FIFO and LIFO metrics.
 	Metrics metrics.Metrics
+
+	// Shedder, when set, can reject the requests that would need to wait
+	// in a queue, e.g. when the process is under memory pressure. The
+	// rejected requests are handled the same way as when the queue is
+	// full.
+	Shedder Shedder
+}
+
+// Shedder decides whether new work should be rejected.
+type Shedder interface {
+	Shed() bool
 }
 
 // Registry maintains a set of LIFO queues. It is used to preserve LIFO queue instances
@@ -229,8 +331,9 @@ type Registry struct {
 }
 
 type queueId struct {
-	name    string
-	grouped bool
+	name     string
+	grouped  bool
+	bulkhead bool
 }
 
 // Amount of time to wait before closing the deleted queues
@@ -252,6 +355,17 @@ type FIFOFilter interface {
 	Config() Config
 }
 
+// BulkheadFilter is the interface that needs to be implemented by the
+// filters that use a FIFO queue maintained by the registry, shared by the
+// routes with the same backend. The bulkhead queues are not affected by
+// the Shedder of the registry.
+type BulkheadFilter interface {
+	FIFOFilter
+
+	// Bulkhead marks the filter as a bulkhead filter.
+	Bulkhead()
+}
+
 // LIFOFilter is the interface that needs to be implemented by the filters that
 // use a LIFO queue maintained by the registry.
 type LIFOFilter interface {
@@ -288,7 +402,14 @@ type GroupedLIFOFilter interface {
 // request needs to be rejected, an error will be returned and done
 // will be nil.
 func (fq *FifoQueue) Wait(ctx context.Context) (func(), error) {
-	f, err := fq.queue.wait(ctx)
+	f, _, err := fq.WaitInfo(ctx)
+	return f, err
+}
+
+// WaitInfo is like Wait, but it also returns the information about the
+// waiting of the request in the queue, also when it was rejected.
+func (fq *FifoQueue) WaitInfo(ctx context.Context) (func(), QueueInfo, error) {
+	f, info, err := fq.queue.wait(ctx, &fq.processing)
 	if err != nil && fq.metrics != nil {
 		switch err {
 		case ErrQueueFull:
@@ -301,7 +422,7 @@ func (fq *FifoQueue) Wait(ctx context.Context) (func(), error) {
 			fq.metrics.IncCounter(fq.errorOtherMetricsKey)
 		}
 	}
-	return f, err
+	return f, info, err
 }
 
 // Status returns the current status of a queue.
@@ -330,7 +451,34 @@ func (fq *FifoQueue) close() {
 // It is mandatory to call done() the request was processed. When the
 // request needs to be rejected, an error will be returned.
 func (q *Queue) Wait() (done func(), err error) {
-	done, err = q.queue.Wait()
+	done, _, err = q.WaitInfo()
+	return
+}
+
+// WaitInfo is like Wait, but it also returns the information about the
+// waiting of the request in the queue, also when it was rejected.
+func (q *Queue) WaitInfo() (done func(), info QueueInfo, err error) {
+	queued := q.queue.Status().ActiveJobs >= q.config.MaxConcurrency
+	if queued && q.shedder != nil && q.shedder.Shed() {
+		done, err = nil, jobqueue.ErrStackFull
+	} else {
+		if queued {
+			// the last queued request is served first
+			info.Position = 1
+			info.EstimatedWait = q.processing.estimate(1, q.config.MaxConcurrency)
+		}
+
+		start := time.Now()
+		done, err = q.queue.Wait()
+		if queued {
+			info.Waited = time.Since(start)
+		}
+
+		if err == nil {
+			done = q.processing.done(done)
+		}
+	}
+
 	if q.metrics != nil && err != nil {
 		switch err {
 		case jobqueue.ErrStackFull:
@@ -342,7 +490,7 @@ func (q *Queue) Wait() (done func(), err error) {
 		}
 	}
 
-	return done, err
+	return done, info, err
 }
 
 // Status returns the current status of a queue.
@@ -403,16 +551,26 @@ func (r *Registry) getFifoQueue(id queueId, c Config) *FifoQueue {
 			fq.Reconfigure(c)
 		}
 	} else {
-		fq = r.newFifoQueue(id.name, c)
+		if id.bulkhead {
+			fq = r.newFifoQueueWith("bulkhead", id.name, c, nil)
+		} else {
+			fq = r.newFifoQueue(id.name, c)
+		}
+
 		r.fifoQueues[id] = fq
 	}
 	return fq
 }
 
 func (r *Registry) newFifoQueue(name string, c Config) *FifoQueue {
+	return r.newFifoQueueWith("fifo", name, c, r.options.Shedder)
+}
+
+func (r *Registry) newFifoQueueWith(prefix, name string, c Config, shedder Shedder) *FifoQueue {
 	q := &FifoQueue{
 		config: c,
 		queue: &fifoQueue{
+			shedder:        shedder,
 			counter:        atomic.NewUint64(0),
 			sem:            semaphore.NewWeighted(int64(c.MaxConcurrency)),
 			maxConcurrency: uint64(c.MaxConcurrency),
@@ -426,11 +584,11 @@ func (r *Registry) newFifoQueue(name string, c Config) *FifoQueue {
 			name = "unknown"
 		}
 
-		q.activeRequestsMetricsKey = fmt.Sprintf("fifo.%s.active", name)
-		q.queuedRequestsMetricsKey = fmt.Sprintf("fifo.%s.queued", name)
-		q.errorFullMetricsKey = fmt.Sprintf("fifo.%s.error.full", name)
-		q.errorOtherMetricsKey = fmt.Sprintf("fifo.%s.error.other", name)
-		q.errorTimeoutMetricsKey = fmt.Sprintf("fifo.%s.error.timeout", name)
+		q.activeRequestsMetricsKey = fmt.Sprintf("%s.%s.active", prefix, name)
+		q.queuedRequestsMetricsKey = fmt.Sprintf("%s.%s.queued", prefix, name)
+		q.errorFullMetricsKey = fmt.Sprintf("%s.%s.error.full", prefix, name)
+		q.errorOtherMetricsKey = fmt.Sprintf("%s.%s.error.other", prefix, name)
+		q.errorTimeoutMetricsKey = fmt.Sprintf("%s.%s.error.timeout", prefix, name)
 		q.metrics = r.options.Metrics
 		r.measure()
 	}
@@ -457,7 +615,8 @@ func (r *Registry) getQueue(id queueId, c Config) *Queue {
 
 func (r *Registry) newQueue(name string, c Config) *Queue {
 	q := &Queue{
-		config: c,
+		config:  c,
+		shedder: r.options.Shedder,
 		// renaming Stack -> Queue in the jobqueue project will follow
 		queue: jobqueue.With(jobqueue.Options{
 			MaxConcurrency: c.MaxConcurrency,
@@ -532,44 +691,66 @@ func (registryPreProcessor) Do(routes []*eskip.Route) []*eskip.Route {
 	for _, r := range routes {
 		lifoCount := 0
 		fifoCount := 0
+		bulkheadCount := 0
 		for _, f := range r.Filters {
 			switch f.Name {
 			case filters.FifoName:
 				fifoCount++
 			case filters.LifoName:
 				lifoCount++
+			case filters.BulkheadName:
+				bulkheadCount++
 			}
 		}
 		// remove all but last fifo instances
 		if fifoCount > 1 {
-			old := r.Filters
-			r.Filters = make([]*eskip.Filter, 0, len(old)-fifoCount+1)
-			for _, f := range old {
-				if fifoCount > 1 && f.Name == filters.FifoName {
-					log.Debugf("Removing non-last %v from %s", f, r.Id)
-					fifoCount--
-				} else {
-					r.Filters = append(r.Filters, f)
-				}
-			}
+			removeNonLast(r, filters.FifoName, fifoCount)
 		}
 		// remove all but last lifo instances
 		if lifoCount > 1 {
-			old := r.Filters
-			r.Filters = make([]*eskip.Filter, 0, len(old)-lifoCount+1)
-			for _, f := range old {
-				if lifoCount > 1 && f.Name == filters.LifoName {
-					log.Debugf("Removing non-last %v from %s", f, r.Id)
-					lifoCount--
-				} else {
-					r.Filters = append(r.Filters, f)
-				}
-			}
+			removeNonLast(r, filters.LifoName, lifoCount)
+		}
+		// remove all but last bulkhead instances
+		if bulkheadCount > 1 {
+			removeNonLast(r, filters.BulkheadName, bulkheadCount)
 		}
 	}
 	return routes
 }
 
+func removeNonLast(r *eskip.Route, name string, count int) {
+	old := r.Filters
+	r.Filters = make([]*eskip.Filter, 0, len(old)-count+1)
+	for _, f := range old {
+		if count > 1 && f.Name == name {
+			log.Debugf("Removing non-last %v from %s", f, r.Id)
+			count--
+		} else {
+			r.Filters = append(r.Filters, f)
+		}
+	}
+}
+
+// bulkheadName returns the backend of the route, identifying the bulkhead
+// shared by the routes with the same backend.
+func bulkheadName(r *routing.Route) string {
+	switch r.BackendType {
+	case eskip.NetworkBackend:
+		return r.Backend
+	case eskip.LBBackend:
+		eps := append([]string(nil), r.Route.LBEndpoints...)
+		sort.Strings(eps)
+		return strings.Join(eps, ",")
+	default:
+		return r.Id
+	}
+}
+
+// InspectedFilters implements routing.FilterInspector.
+func (*Registry) InspectedFilters() []string {
+	return []string{filters.BulkheadName, filters.FifoName, filters.LifoName, filters.LifoGroupName}
+}
+
 // Do implements routing.PostProcessor and sets the queue for the scheduler filters.
 //
 // It preserves the existing queue when available.
@@ -577,12 +758,19 @@ func (r *Registry) Do(routes []*routing.Route) []*routing.Route {
 	rr := make([]*routing.Route, len(routes))
 	inUse := make(map[queueId]struct{})
 	groups := make(map[string][]GroupedLIFOFilter)
+	bulkheads := make(map[string][]BulkheadFilter)
 
 	for i, ri := range routes {
 		rr[i] = ri
 		for _, fi := range ri.Filters {
+			if bf, ok := fi.Filter.(BulkheadFilter); ok {
+				name := bulkheadName(ri)
+				bulkheads[name] = append(bulkheads[name], bf)
+				continue
+			}
+
 			if ff, ok := fi.Filter.(FIFOFilter); ok {
-				id := queueId{ri.Id, false}
+				id := queueId{name: ri.Id}
 				inUse[id] = struct{}{}
 				fq := r.getFifoQueue(id, ff.Config())
 				ff.SetQueue(fq)
@@ -600,7 +788,7 @@ func (r *Registry) Do(routes []*routing.Route) []*routing.Route {
 				continue
 			}
 
-			id := queueId{ri.Id, false}
+			id := queueId{name: ri.Id}
 			inUse[id] = struct{}{}
 
 			q := r.getQueue(id, lf.Config())
@@ -629,7 +817,7 @@ func (r *Registry) Do(routes []*routing.Route) []*routing.Route {
 			foundConfig = true
 		}
 
-		id := queueId{name, true}
+		id := queueId{name: name, grouped: true}
 		inUse[id] = struct{}{}
 
 		q := r.getQueue(id, c)
@@ -639,6 +827,26 @@ func (r *Registry) Do(routes []*routing.Route) []*routing.Route {
 		}
 	}
 
+	// the routes with the same backend share the bulkhead, and the
+	// configuration of the first route is used
+	for name, group := range bulkheads {
+		c := group[0].Config()
+		for _, bf := range group[1:] {
+			if bf.Config() != c {
+				log.Warnf("Found mismatching configuration for the bulkhead of the backend: %s", name)
+				break
+			}
+		}
+
+		id := queueId{name: name, bulkhead: true}
+		inUse[id] = struct{}{}
+
+		fq := r.getFifoQueue(id, c)
+		for _, bf := range group {
+			bf.SetQueue(fq)
+		}
+	}
+
 	r.deleteUnused(inUse)
 
 	return rr
@@ -0,0 +1,74 @@
+package scheduler
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aryszka/jobqueue"
+)
+
+type testShedder bool
+
+func (s testShedder) Shed() bool { return bool(s) }
+
+func TestShedQueuedRequests(t *testing.T) {
+	cfg := Config{MaxConcurrency: 1, MaxQueueSize: 10}
+
+	t.Run("fifo", func(t *testing.T) {
+		reg := RegistryWith(Options{Shedder: testShedder(true)})
+		defer reg.Close()
+
+		fq := reg.newFifoQueue("test", cfg)
+		done, err := fq.Wait(context.Background())
+		if err != nil {
+			t.Fatalf("failed to accept request below the concurrency limit: %v", err)
+		}
+
+		defer done()
+		if _, err := fq.Wait(context.Background()); err != ErrQueueFull {
+			t.Errorf("failed to shed queued request: %v", err)
+		}
+	})
+
+	t.Run("lifo", func(t *testing.T) {
+		reg := RegistryWith(Options{Shedder: testShedder(true)})
+		defer reg.Close()
+
+		q := reg.newQueue("test", cfg)
+		done, err := q.Wait()
+		if err != nil {
+			t.Fatalf("failed to accept request below the concurrency limit: %v", err)
+		}
+
+		defer done()
+		if _, err := q.Wait(); err != jobqueue.ErrStackFull {
+			t.Errorf("failed to shed queued request: %v", err)
+		}
+	})
+
+	t.Run("no pressure", func(t *testing.T) {
+		reg := RegistryWith(Options{Shedder: testShedder(false)})
+		defer reg.Close()
+
+		fq := reg.newFifoQueue("test", cfg)
+		done, err := fq.Wait(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		queued := make(chan error)
+		go func() {
+			done, err := fq.Wait(context.Background())
+			if err == nil {
+				done()
+			}
+
+			queued <- err
+		}()
+
+		done()
+		if err := <-queued; err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+	})
+}
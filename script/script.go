@@ -59,24 +59,33 @@ func (ls *luaScript) Name() string {
 	return filters.LuaName
 }
 
-// CreateFilter creates the filter
-func (ls *luaScript) CreateFilter(config []interface{}) (filters.Filter, error) {
+func parseArgs(config []interface{}) (string, []string, error) {
 	if len(config) == 0 {
-		return nil, filters.ErrInvalidFilterParameters
+		return "", nil, filters.ErrInvalidFilterParameters
 	}
 	src, ok := config[0].(string)
 	if !ok {
-		return nil, filters.ErrInvalidFilterParameters
+		return "", nil, filters.ErrInvalidFilterParameters
 	}
 	var params []string
 	for _, p := range config[1:] {
 		ps, ok := p.(string)
 		if !ok {
-			return nil, filters.ErrInvalidFilterParameters
+			return "", nil, filters.ErrInvalidFilterParameters
 		}
 		params = append(params, ps)
 	}
 
+	return src, params, nil
+}
+
+// CreateFilter creates the filter
+func (ls *luaScript) CreateFilter(config []interface{}) (filters.Filter, error) {
+	src, params, err := parseArgs(config)
+	if err != nil {
+		return nil, err
+	}
+
 	s := &script{source: src, routeParams: params}
 	if err := s.initScript(ls.modules); err != nil {
 		return nil, err
@@ -84,6 +93,18 @@ func (ls *luaScript) CreateFilter(config []interface{}) (filters.Filter, error)
 	return s, nil
 }
 
+// ValidateArgs checks the arguments and compiles the script, without
+// creating the Lua states.
+func (ls *luaScript) ValidateArgs(config []interface{}) error {
+	src, _, err := parseArgs(config)
+	if err != nil {
+		return err
+	}
+
+	_, err = compile(src)
+	return err
+}
+
 type script struct {
 	source      string
 	routeParams []string
@@ -141,32 +162,35 @@ func (s *script) newState() (*lua.LState, error) {
 	return L, nil
 }
 
-func (s *script) initScript(modules []string) error {
-	// Compile
+func compile(source string) (*lua.FunctionProto, error) {
 	var reader io.Reader
 	var name string
 
-	if strings.HasSuffix(s.source, ".lua") {
-		file, err := os.Open(s.source)
+	if strings.HasSuffix(source, ".lua") {
+		file, err := os.Open(source)
 		if err != nil {
-			return err
+			return nil, err
 		}
 		defer func() {
 			if err = file.Close(); err != nil {
-				log.Errorf("Failed to close lua file %s: %v", s.source, err)
+				log.Errorf("Failed to close lua file %s: %v", source, err)
 			}
 		}()
 		reader = bufio.NewReader(file)
-		name = s.source
+		name = source
 	} else {
-		reader = strings.NewReader(s.source)
+		reader = strings.NewReader(source)
 		name = "<script>"
 	}
 	chunk, err := lua_parse.Parse(reader, name)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	proto, err := lua.Compile(chunk, name)
+	return lua.Compile(chunk, name)
+}
+
+func (s *script) initScript(modules []string) error {
+	proto, err := compile(s.source)
 	if err != nil {
 		return err
 	}
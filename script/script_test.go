@@ -886,3 +886,22 @@ func BenchmarkScriptRandomPath(b *testing.B) {
 func BenchmarkScriptCopyRequestHeader(b *testing.B) {
 	benchmarkRequest(b, "testdata/copy_request_header.lua", "X-Foo", "X-Bar")
 }
+
+func TestValidateArgs(t *testing.T) {
+	spec := NewLuaScript().(filters.ArgsValidator)
+	for _, tt := range []struct {
+		args  []interface{}
+		valid bool
+	}{
+		{args: []interface{}{"function request(ctx, params); end"}, valid: true},
+		{args: []interface{}{"function request(ctx, params); end", "foo=bar"}, valid: true},
+		{args: nil},
+		{args: []interface{}{42}},
+		{args: []interface{}{"function request(ctx, params); end", 42}},
+		{args: []interface{}{"function request(ctx, params)"}},
+	} {
+		if err := spec.ValidateArgs(tt.args); (err == nil) != tt.valid {
+			t.Errorf("%v: unexpected validation result: %v", tt.args, err)
+		}
+	}
+}
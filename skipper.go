@@ -1,8 +1,11 @@
 package skipper
 
 import (
+	"bytes"
 	"context"
 	"crypto/tls"
+	"crypto/x509"
+	"errors"
 	"fmt"
 	"io"
 	"net"
@@ -22,41 +25,72 @@ import (
 	"github.com/prometheus/client_golang/prometheus"
 	log "github.com/sirupsen/logrus"
 
+	"github.com/zalando/skipper/accounting"
+	"github.com/zalando/skipper/adminschedule"
+	"github.com/zalando/skipper/botdetect"
 	"github.com/zalando/skipper/circuit"
 	"github.com/zalando/skipper/dataclients/kubernetes"
 	"github.com/zalando/skipper/dataclients/routestring"
+	"github.com/zalando/skipper/dataclients/snapshot"
+	"github.com/zalando/skipper/drain"
 	"github.com/zalando/skipper/eskip"
 	"github.com/zalando/skipper/eskipfile"
 	"github.com/zalando/skipper/etcd"
 	"github.com/zalando/skipper/filters"
 	"github.com/zalando/skipper/filters/apiusagemonitoring"
+	"github.com/zalando/skipper/filters/async"
 	"github.com/zalando/skipper/filters/auth"
 	block "github.com/zalando/skipper/filters/block"
+	botdetectfilters "github.com/zalando/skipper/filters/botdetect"
 	"github.com/zalando/skipper/filters/builtin"
+	"github.com/zalando/skipper/filters/cache"
+	"github.com/zalando/skipper/filters/challenge"
+	"github.com/zalando/skipper/filters/conditional"
+	"github.com/zalando/skipper/filters/dictionary"
 	"github.com/zalando/skipper/filters/fadein"
+	geoipfilters "github.com/zalando/skipper/filters/geoip"
+	"github.com/zalando/skipper/filters/headerpolicy"
+	"github.com/zalando/skipper/filters/limits"
 	logfilter "github.com/zalando/skipper/filters/log"
+	"github.com/zalando/skipper/filters/maintenance"
 	ratelimitfilters "github.com/zalando/skipper/filters/ratelimit"
 	"github.com/zalando/skipper/filters/shedder"
+	"github.com/zalando/skipper/filters/tarpit"
+	"github.com/zalando/skipper/filters/transport"
+	"github.com/zalando/skipper/filters/waf"
+	"github.com/zalando/skipper/filters/xmlbody"
+	"github.com/zalando/skipper/geoip"
 	"github.com/zalando/skipper/innkeeper"
 	"github.com/zalando/skipper/loadbalancer"
 	"github.com/zalando/skipper/logging"
+	"github.com/zalando/skipper/logging/eventsink"
+	"github.com/zalando/skipper/memorywatch"
 	"github.com/zalando/skipper/metrics"
 	skpnet "github.com/zalando/skipper/net"
+	"github.com/zalando/skipper/net/dnscache"
+	"github.com/zalando/skipper/passthrough"
 	pauth "github.com/zalando/skipper/predicates/auth"
+	"github.com/zalando/skipper/predicates/body"
+	botdetectpredicates "github.com/zalando/skipper/predicates/botdetect"
+	"github.com/zalando/skipper/predicates/clientcert"
 	"github.com/zalando/skipper/predicates/cookie"
 	"github.com/zalando/skipper/predicates/cron"
 	"github.com/zalando/skipper/predicates/forwarded"
+	geoippredicates "github.com/zalando/skipper/predicates/geoip"
 	"github.com/zalando/skipper/predicates/host"
 	"github.com/zalando/skipper/predicates/interval"
+	"github.com/zalando/skipper/predicates/listener"
 	"github.com/zalando/skipper/predicates/methods"
 	"github.com/zalando/skipper/predicates/primitive"
 	"github.com/zalando/skipper/predicates/query"
 	"github.com/zalando/skipper/predicates/source"
 	"github.com/zalando/skipper/predicates/tee"
 	"github.com/zalando/skipper/predicates/traffic"
+	"github.com/zalando/skipper/profiles"
 	"github.com/zalando/skipper/proxy"
 	"github.com/zalando/skipper/queuelistener"
 	"github.com/zalando/skipper/ratelimit"
+	"github.com/zalando/skipper/routerules"
 	"github.com/zalando/skipper/routing"
 	"github.com/zalando/skipper/scheduler"
 	"github.com/zalando/skipper/script"
@@ -78,6 +112,71 @@ type testOptions struct {
 	redisUpdateInterval      time.Duration
 }
 
+// ListenerOptions configures an additional proxy listener, serving the
+// same routing table as the main listener, or its own routing table,
+// when its own routes are set. The routes of the shared table can be
+// restricted to some of the listeners with the Listener predicate. The zero values
+// of the timeouts and the header size default to the settings of the
+// main listener.
+type ListenerOptions struct {
+	// Name of the listener, used by the Listener predicate.
+	Name string `yaml:"name"`
+
+	// Address of the listener.
+	Address string `yaml:"address"`
+
+	// MaxHeaderBytes of the listener.
+	MaxHeaderBytes int `yaml:"max-header-bytes"`
+
+	// ReadTimeout of the listener.
+	ReadTimeout time.Duration `yaml:"read-timeout"`
+
+	// ReadHeaderTimeout of the listener.
+	ReadHeaderTimeout time.Duration `yaml:"read-header-timeout"`
+
+	// WriteTimeout of the listener.
+	WriteTimeout time.Duration `yaml:"write-timeout"`
+
+	// IdleTimeout of the listener.
+	IdleTimeout time.Duration `yaml:"idle-timeout"`
+
+	// CertPathTLS and KeyPathTLS enable TLS on the listener, with the
+	// comma separated lists of the certificate and key files. The
+	// listener doesn't use TLS when not set.
+	CertPathTLS string `yaml:"tls-cert"`
+	KeyPathTLS  string `yaml:"tls-key"`
+
+	// DisableHTTP2 disables HTTP/2 on the TLS listener.
+	DisableHTTP2 bool `yaml:"disable-http2"`
+
+	// ProxyProtocol enables reading the PROXY protocol headers on the
+	// listener. Unlike the other settings, it is not inherited from the
+	// main listener.
+	ProxyProtocol bool `yaml:"proxy-protocol"`
+
+	// DrainDuration of the listener, see Options.DrainDuration.
+	DrainDuration time.Duration `yaml:"drain-duration"`
+
+	// DrainCloseConnections, DrainRejectRequests and DrainRetryAfter
+	// configure the listener during the drain, see the same settings
+	// in Options. The flags are not inherited from the main listener.
+	DrainCloseConnections bool          `yaml:"drain-close-connections"`
+	DrainRejectRequests   bool          `yaml:"drain-reject-requests"`
+	DrainRetryAfter       time.Duration `yaml:"drain-retry-after"`
+
+	// RoutesFile sets a comma separated list of eskip files, that are
+	// loaded into the own routing table of the listener.
+	RoutesFile string `yaml:"routes-file"`
+
+	// InlineRoutes sets routes in eskip format, that are loaded into the
+	// own routing table of the listener.
+	InlineRoutes string `yaml:"inline-routes"`
+
+	// CustomDataClients sets the data clients of the own routing table of
+	// the listener.
+	CustomDataClients []routing.DataClient `yaml:"-"`
+}
+
 // Options to start skipper.
 type Options struct {
 	// WaitForHealthcheckInterval sets the time that skipper waits
@@ -85,6 +184,23 @@ type Options struct {
 	// to 0.
 	WaitForHealthcheckInterval time.Duration
 
+	// DrainDuration sets how long the main listener keeps serving the
+	// requests after the shutdown signal or the drain API request,
+	// before it is shut down. Defaults to WaitForHealthcheckInterval.
+	DrainDuration time.Duration
+
+	// DrainCloseConnections enables closing the client connections of
+	// the main listener after the current requests during the drain.
+	DrainCloseConnections bool
+
+	// DrainRejectRequests enables responding to the requests of the main
+	// listener during the drain with 503 Service Unavailable.
+	DrainRejectRequests bool
+
+	// DrainRetryAfter, when set, is sent in the Retry-After header of
+	// the requests rejected during the drain.
+	DrainRetryAfter time.Duration
+
 	// StatusChecks is an experimental feature. It defines a
 	// comma separated list of HTTP URLs to do GET requests to,
 	// that have to return 200 before skipper becomes ready
@@ -99,6 +215,19 @@ type Options struct {
 	// Insecure network address skipper should listen on when TLS is enabled
 	InsecureAddress string
 
+	// Listeners are the additional proxy listeners, with their own
+	// settings. They serve the routing table of the main listener,
+	// unless their own routes are set.
+	Listeners []ListenerOptions
+
+	// ReusePortAcceptors, when greater than 1, opens the set number of
+	// sockets with the SO_REUSEPORT option on the address of each proxy
+	// listener, and serves each socket with a separate accepting loop.
+	// The kernel distributes the incoming connections between the
+	// sockets. With the TCP queue enabled, the limits of the queue are
+	// split between the sockets. Only supported on Linux and BSD systems.
+	ReusePortAcceptors int
+
 	// EnableTCPQueue enables controlling the
 	// concurrently processed requests at the TCP listener.
 	EnableTCPQueue bool
@@ -127,6 +256,32 @@ type Options struct {
 	// If defines the maximum number of pending connection waiting in the queue.
 	MaxTCPListenerQueue int
 
+	// StrictHTTPParsing enables checking the raw HTTP/1.x requests for
+	// the framing ambiguities used for request smuggling, e.g. conflicting
+	// Content-Length and Transfer-Encoding headers or obsolete line
+	// folding. The violations are either only logged and counted, or the
+	// requests are rejected. It is applied only to the plain HTTP
+	// listeners.
+	StrictHTTPParsing skpnet.StrictParsingLevel
+
+	// ProxyProtocol enables reading the PROXY protocol v1 and v2 headers
+	// on the main proxy listener, sent by the L4 load balancers in front
+	// of Skipper. The client address reported in the header is used as
+	// the remote address of the requests.
+	ProxyProtocol bool
+
+	// ProxyProtocolTrustedNetworks contains the networks of the load
+	// balancers that are trusted to send the PROXY protocol header. It is
+	// required when the PROXY protocol is enabled on any listener.
+	ProxyProtocolTrustedNetworks skpnet.IPNets
+
+	// EnableTLSPassthrough enables forwarding the TLS connections on the
+	// main proxy listener to the TCP backends of the routes, selected by
+	// the server name (SNI), without terminating TLS. The backends of
+	// these routes are defined with the tcp scheme, e.g.
+	// "tcp://10.0.0.1:5432".
+	EnableTLSPassthrough bool
+
 	// List of custom filter specifications.
 	CustomFilters []filters.Spec
 
@@ -317,6 +472,12 @@ type Options struct {
 	// InlineRoutes can define routes as eskip text.
 	InlineRoutes string
 
+	// EnableRouteSnapshotImport enables importing the snapshots of the
+	// routing table, exported by another instance, on the support
+	// listener. The imported routes are added to the routes of the
+	// other data clients.
+	EnableRouteSnapshotImport bool
+
 	// Polling timeout of the routing data sources.
 	SourcePollTimeout time.Duration
 
@@ -326,6 +487,95 @@ type Options struct {
 	// DisabledFilters is a list of filters unavailable for use
 	DisabledFilters []string
 
+	// RequestLimits defines the allowed methods and the request limits
+	// applied to all routes, that don't define their own limits of the
+	// same kind.
+	RequestLimits *limits.Defaults
+
+	// MaxRequestBodySize sets the max size of the request bodies in bytes
+	// forwarded to the backends. The longer requests are rejected with
+	// 413 Request Entity Too Large. The routes can override it with the
+	// maxRequestBodySize filter.
+	MaxRequestBodySize int64
+
+	// ForwardEarlyHints enables forwarding the 103 Early Hints responses
+	// of the backends to the clients.
+	ForwardEarlyHints bool
+
+	// BackendStripHeaders lists the headers removed from the backend
+	// requests. A name ending with * removes all the headers with the
+	// given prefix.
+	BackendStripHeaders []string
+
+	// BackendMaxHeaderSize limits the size of the backend request header
+	// in bytes. Larger requests are rejected with 431 Request Header
+	// Fields Too Large.
+	BackendMaxHeaderSize int
+
+	// BackendFoldHeaders enables joining the values of the repeated
+	// header fields of the backend requests into a single field.
+	BackendFoldHeaders bool
+
+	// ClientDisconnectGracePeriod defines when the backend requests are
+	// canceled after the client disconnects. When 0, they are canceled
+	// immediately, and when negative, they are not canceled.
+	ClientDisconnectGracePeriod time.Duration
+
+	// DetectStreaming enables treating the requests accepting Server-Sent
+	// Events as streaming requests, like the routes with the streaming
+	// filter.
+	DetectStreaming bool
+
+	// StreamingHeaderTimeout is the response header timeout of the
+	// streaming requests. When 0, it is not limited.
+	StreamingHeaderTimeout time.Duration
+
+	// StreamingIdleTimeout limits the time of waiting for the next data
+	// of the streaming responses, instead of the backend timeout. When 0,
+	// it is not limited.
+	StreamingIdleTimeout time.Duration
+
+	// GeoIPDatabases contains the paths of the GeoIP databases in the
+	// MaxMind DB format. When set, the ClientGeo predicate and the
+	// geoEnrich filter are enabled.
+	GeoIPDatabases []string
+
+	// GeoIPReloadInterval defines how often the GeoIP databases are
+	// checked for changes. Defaults to one minute.
+	GeoIPReloadInterval time.Duration
+
+	// EnableBotDetection enables the BotScore predicate and the
+	// botScore and botBlock filters.
+	EnableBotDetection bool
+
+	// BotDetectionRateWindow sets the time window of counting the
+	// requests of the clients. Defaults to 10s.
+	BotDetectionRateWindow time.Duration
+
+	// BotDetectionRateThreshold sets how many requests of a client
+	// are expected in a time window, before the client is considered
+	// a likely bot. Defaults to 50.
+	BotDetectionRateThreshold int
+
+	// BotDetectionJA3Header sets the request header containing the
+	// JA3 TLS fingerprint of the client, set by a TLS terminating
+	// load balancer.
+	BotDetectionJA3Header string
+
+	// BotDetectionJA3Fingerprints contains the JA3 TLS fingerprints
+	// of known bots.
+	BotDetectionJA3Fingerprints []string
+
+	// ChallengeSecretFile contains the path of the file with the secret
+	// used to sign the challenges and the pass cookies of the challenge
+	// filter. When not set, a random secret is generated on startup.
+	ChallengeSecretFile string
+
+	// SourceFromFileReloadInterval defines how often the files of the
+	// SourceFromFile predicates are checked for changes. Defaults to 10
+	// seconds.
+	SourceFromFileReloadInterval time.Duration
+
 	// CloneRoute is a slice of PreProcessors that will be applied to all routes
 	// automatically. They will clone all matching routes and apply changes to the
 	// cloned routes.
@@ -335,6 +585,18 @@ type Options struct {
 	// will apply changes to all matching routes.
 	EditRoute []*eskip.Editor
 
+	// RouteRulesFile sets a YAML file with rules that prepend or append
+	// filters to the routes matching an expression. The file is reloaded
+	// when it changes. See the routerules package.
+	RouteRulesFile string
+
+	// ProfilesFile sets a YAML file with named configuration profiles,
+	// bundling the timeouts, the filters and the TLS policy of the
+	// routes, attached to the routes, the hosts or the Kubernetes route
+	// groups. The file is reloaded when it changes. See the profiles
+	// package.
+	ProfilesFile string
+
 	// A list of custom routing pre-processor implementations that will
 	// be applied to all routes.
 	CustomRoutingPreProcessors []routing.PreProcessor
@@ -354,6 +616,48 @@ type Options struct {
 	// by the proxy are closed.
 	CloseIdleConnsPeriod time.Duration
 
+	// FastCgiMaxIdleConns sets the maximum number of idle connections
+	// kept per FastCGI backend. When 0, the FastCGI connections are not
+	// reused.
+	FastCgiMaxIdleConns int
+
+	// FastCgiIdleConnTimeout sets the time after the idle FastCGI
+	// connections are closed.
+	FastCgiIdleConnTimeout time.Duration
+
+	// DNSCacheTTL sets how long the resolved addresses of the backend
+	// hosts are cached. When 0, the addresses are not cached.
+	DNSCacheTTL time.Duration
+
+	// DNSStaleTTL sets how long the cached addresses of the backend
+	// hosts are used after DNSCacheTTL, when the resolution fails.
+	// Defaults to 10 minutes.
+	DNSStaleTTL time.Duration
+
+	// DNSServers sets the DNS servers resolving the backend hosts,
+	// instead of the ones of the host, as IP addresses, optionally with
+	// the port, which defaults to 53. The servers are tried in order.
+	DNSServers []string
+
+	// DNSRespectTTL makes the DNS cache keep the addresses of the
+	// backend hosts for the TTL of their DNS records, instead of
+	// DNSCacheTTL, when resolved with DNSServers, with the
+	// backendResolver filter, or with a DNSResolver reporting the TTL.
+	DNSRespectTTL bool
+
+	// DNSResolver, when set, resolves the backend hosts instead of the
+	// DNS servers of the host or DNSServers. The resolved addresses are
+	// cached as set by the other DNS options.
+	DNSResolver dnscache.Resolver
+
+	// ConnectionPrewarming enables establishing the backend connections
+	// in advance for the routes with the prewarmConnections filter.
+	ConnectionPrewarming bool
+
+	// PrewarmMaxIdle sets how long the prewarmed connections are kept
+	// when not used. Defaults to proxy.DefaultPrewarmMaxIdle.
+	PrewarmMaxIdle time.Duration
+
 	// Defines ReadTimeoutServer for server http connections.
 	ReadTimeoutServer time.Duration
 
@@ -393,6 +697,19 @@ type Options struct {
 	// backend should be dual stack.
 	DualStackBackend bool
 
+	// HappyEyeballsBackend enables connecting to the backends with both
+	// IPv4 and IPv6 addresses with the Happy Eyeballs algorithm.
+	HappyEyeballsBackend bool
+
+	// PreferIPv4Backend makes the Happy Eyeballs dialing try the IPv4
+	// addresses first.
+	PreferIPv4Backend bool
+
+	// DialFallbackDelayBackend is the time waited for a connection
+	// attempt of the Happy Eyeballs dialing, before trying the next
+	// address.
+	DialFallbackDelayBackend time.Duration
+
 	// TLSHandshakeTimeoutBackend sets the TLS handshake timeout
 	// for proxy connections to the backend.
 	TLSHandshakeTimeoutBackend time.Duration
@@ -402,6 +719,40 @@ type Options struct {
 	// limit.
 	MaxIdleConnsBackend int
 
+	// MaxConnsPerHostBackend sets MaxConnsPerHost, which limits the
+	// number of connections to each backend host, including the
+	// connections in use, 0 means no limit. It can be overridden per
+	// route with the backendTransport filter.
+	MaxConnsPerHostBackend int
+
+	// BackendConnectionMetrics enables reporting the number of the
+	// open, in-use and idle connections, and the dial failures, for
+	// each backend host.
+	BackendConnectionMetrics bool
+
+	// SSEKeepAliveInterval, when set, makes the proxy send a comment to
+	// the clients of the Server-Sent Events responses, when the backend
+	// didn't send an event for the interval.
+	SSEKeepAliveInterval time.Duration
+
+	// CostAccounting enables accounting the usage of the tenants set by
+	// the tenantKey filter. When the Prometheus metrics are enabled, the
+	// total usage of the tenants is exposed as metrics.
+	CostAccounting bool
+
+	// CostAccountingExport, when set, receives the usage of the tenants
+	// periodically. It can be a file path, or an http or https URL, see
+	// accounting.NewSink.
+	CostAccountingExport string
+
+	// CostAccountingExportInterval sets how often the usage is exported.
+	// Defaults to 1m.
+	CostAccountingExportInterval time.Duration
+
+	// CostAccountingMaxTenants limits the number of the accounted
+	// tenants. Defaults to 10000.
+	CostAccountingMaxTenants int
+
 	// DisableHTTPKeepalives sets DisableKeepAlives, which forces
 	// a backend to always create a new connection.
 	DisableHTTPKeepalives bool
@@ -432,6 +783,11 @@ type Options struct {
 	// which accepts original skipper http.RoundTripper as an argument and returns a wrapped roundtripper
 	CustomHttpRoundTripperWrap func(http.RoundTripper) http.RoundTripper
 
+	// EndpointRegistry shares the metrics of the load balanced endpoints
+	// across the routes. When not set, a registry with the default
+	// options is used.
+	EndpointRegistry *routing.EndpointRegistry
+
 	// WaitFirstRouteLoad prevents starting the listener before the first batch
 	// of routes were applied.
 	WaitFirstRouteLoad bool
@@ -440,6 +796,30 @@ type Options struct {
 	// instead of full details of the updated/deleted routes.
 	SuppressRouteUpdateLogs bool
 
+	// LazyFilters contains the names of the filters whose instances are
+	// created only when a route containing them first matches. Only the
+	// filters that can validate their arguments without being created,
+	// e.g. lua and jwtValidation, and that are not inspected by a route
+	// post-processor, are created lazily.
+	LazyFilters []string
+
+	// WarmUpLazyFilters enables creating the lazy filter instances in the
+	// background after a routing update took effect.
+	WarmUpLazyFilters bool
+
+	// PredicateCosts overrides the relative evaluation costs of the
+	// custom predicates, by predicate name. The cheaper predicates of a
+	// route are evaluated first.
+	PredicateCosts map[string]int
+
+	// AtomicRouteUpdates enables rejecting the routing updates containing
+	// invalid routes, keeping the previous routing table instead.
+	AtomicRouteUpdates bool
+
+	// UnusedRouteThreshold sets the time after which the routes that
+	// didn't handle any request are reported as unused.
+	UnusedRouteThreshold time.Duration
+
 	// Dev mode. Currently this flag disables prioritization of the
 	// consumer side over the feeding side during the routing updates to
 	// populate the updated routes faster.
@@ -542,6 +922,15 @@ type Options struct {
 	// EnableRouteCreationMetrics enables the OriginMarker to track route creation times. Disabled by default
 	EnableRouteCreationMetrics bool
 
+	// EnableRequestAnomalyMetrics enables counting the malformed or
+	// abnormal requests by the reason and the route.
+	EnableRequestAnomalyMetrics bool
+
+	// RequestAnomalyMaxHeaderSize sets the size of the request headers,
+	// above which the requests are counted as anomalies. Defaults to
+	// 8192.
+	RequestAnomalyMaxHeaderSize int
+
 	// When set, makes the histograms use an exponentially decaying sample
 	// instead of the default uniform one.
 	MetricsUseExpDecaySample bool
@@ -582,17 +971,37 @@ type Options struct {
 	// Logrus logger for application logs. To enable structured logging, use ApplicationLogJSONEnabled.
 	ApplicationLogJsonFormatter *log.JSONFormatter
 
-	// Output file for the access log. Default value: /dev/stderr.
+	// Output for the access log. Default value: /dev/stderr.
 	//
 	// When /dev/stderr or /dev/stdout is passed in, it will be resolved
-	// to os.Stderr or os.Stdout.
-	//
-	// Warning: passing an arbitrary file will try to open for append
-	// it on start and use it, or fail on start, but the current
-	// implementation doesn't support any more proper handling
-	// of temporary failures or log-rolling.
+	// to os.Stderr or os.Stdout. The syslog://, syslog+tcp:// and
+	// syslog+unix:// destinations send the entries as RFC5424 syslog
+	// messages, and the http:// and https:// destinations post them
+	// in batches, see logging.NewOutput. Other values are used as a
+	// file path, opened for append on start. The files are rotated
+	// when AccessLogMaxSize or AccessLogMaxAge is set.
 	AccessLogOutput string
 
+	// AccessLogMaxSize, when set, makes the access log file rotated
+	// when its size would exceed it, in bytes.
+	AccessLogMaxSize int64
+
+	// AccessLogMaxAge, when set, makes the access log file rotated
+	// after it was written for longer than it.
+	AccessLogMaxAge time.Duration
+
+	// AccessLogMaxBackups limits the number of the rotated access log
+	// files kept. By default, all of them are kept.
+	AccessLogMaxBackups int
+
+	// AccessLogBatchSize sets the maximum number of the access log
+	// entries posted at once to an HTTP destination. Defaults to 100.
+	AccessLogBatchSize int
+
+	// AccessLogFlushInterval sets how often the buffered access log
+	// entries are posted to an HTTP destination. Defaults to 1s.
+	AccessLogFlushInterval time.Duration
+
 	// Disables the access log.
 	AccessLogDisabled bool
 
@@ -603,10 +1012,66 @@ type Options struct {
 	// from the request URI in the access logs.
 	AccessLogStripQuery bool
 
+	// AccessLogIdentityFields sets the fields of the caller identity,
+	// published by the auth filters, that are included in the access
+	// log, see logging.Options.
+	AccessLogIdentityFields []string
+
 	// AccessLogJsonFormatter, when set and JSON logging is enabled, is passed along to to the underlying
 	// Logrus logger for access logs. To enable structured logging, use AccessLogJSONEnabled.
 	AccessLogJsonFormatter *log.JSONFormatter
 
+	// EventSink, when set, receives the access log entries and the
+	// audit log documents as events. When not set, and
+	// EventSinkKafkaBrokers is set, a Kafka event sink is created.
+	EventSink eventsink.Sink
+
+	// EventSinkKafkaBrokers sets the Kafka brokers used to stream the
+	// access and audit events to EventSinkKafkaTopic.
+	EventSinkKafkaBrokers []string
+
+	// EventSinkKafkaTopic sets the Kafka topic receiving the events.
+	EventSinkKafkaTopic string
+
+	// EventSinkKafkaTLS enables TLS for the connections to the Kafka
+	// brokers.
+	EventSinkKafkaTLS bool
+
+	// EventSinkKafkaTLSCAFile sets the file with the PEM encoded CA
+	// certificates used to verify the Kafka brokers. When not set,
+	// the system CAs are used.
+	EventSinkKafkaTLSCAFile string
+
+	// EventSinkKafkaSASLMechanism sets the SASL mechanism used to
+	// authenticate with the Kafka brokers: PLAIN, SCRAM-SHA-256 or
+	// SCRAM-SHA-512. When not set, the connections are not
+	// authenticated.
+	EventSinkKafkaSASLMechanism string
+
+	// EventSinkKafkaSASLUsername sets the SASL username.
+	EventSinkKafkaSASLUsername string
+
+	// EventSinkKafkaSASLPasswordFile sets the file containing the
+	// SASL password.
+	EventSinkKafkaSASLPasswordFile string
+
+	// EventSinkBufferSize sets the maximum number of the buffered
+	// events. Defaults to 1024.
+	EventSinkBufferSize int
+
+	// EventSinkBatchSize sets the maximum number of events sent at
+	// once. Defaults to 100.
+	EventSinkBatchSize int
+
+	// EventSinkFlushInterval sets how often the buffered events are
+	// sent. Defaults to 1s.
+	EventSinkFlushInterval time.Duration
+
+	// EventSinkBlockTimeout sets how long the requests wait for free
+	// space in a full event buffer. By default, the events are
+	// dropped immediately when the buffer is full.
+	EventSinkBlockTimeout time.Duration
+
 	DebugListener string
 
 	// Path of certificate(s) when using TLS, mutiple may be given comma separated
@@ -615,6 +1080,15 @@ type Options struct {
 	// multiple keys, the order must match the one given in CertPathTLS
 	KeyPathTLS string
 
+	// Path of the CA certificate(s) used to verify the client certificates
+	// of the TLS connections, multiple may be given comma separated
+	ClientCAPathTLS string
+
+	// ClientAuthTLS defines the policy of the TLS server for the client
+	// certificates. The verified client certificates can be matched by the
+	// ClientCertSAN, ClientCertSubject and ClientCertSPIFFE predicates.
+	ClientAuthTLS tls.ClientAuthType
+
 	// TLS Settings for Proxy Server
 	ProxyTLS *tls.Config
 
@@ -638,6 +1112,16 @@ type Options struct {
 	// contains loop backends (<loopback>).
 	MaxLoopbacks int
 
+	// RetryBudget limits the retries of the routes with the retryPolicy
+	// filter to a ratio of their requests. If 0, the default (0.2) is
+	// applied. To disable the budget, set it to -1.
+	RetryBudget float64
+
+	// RetryAfterMaxBackoff enables avoiding the load balanced endpoints
+	// that respond with 429 or 503 and a Retry-After header, for the
+	// requested time, at most for RetryAfterMaxBackoff. Zero disables it.
+	RetryAfterMaxBackoff time.Duration
+
 	// EnableBreakers enables the usage of the breakers in the route definitions without initializing any
 	// by default. It is a shortcut for setting the BreakerSettings to:
 	//
@@ -664,6 +1148,16 @@ type Options struct {
 	// EnableRouteLIFOMetrics enables metrics for the individual route LIFO queues, if any.
 	EnableRouteLIFOMetrics bool
 
+	// EnableMemoryWatchdog enables shedding load when the memory usage gets
+	// close to the limit of the Go runtime, as set by GOMEMLIMIT. Under
+	// memory pressure, the requests that would wait in a FIFO or LIFO
+	// queue are rejected.
+	EnableMemoryWatchdog bool
+
+	// MemoryWatchdogThreshold sets the ratio of the memory limit above
+	// which the memory is considered under pressure. Defaults to 0.9.
+	MemoryWatchdogThreshold float64
+
 	// OpenTracing enables opentracing
 	OpenTracing []string
 
@@ -810,6 +1304,20 @@ type Options struct {
 	// CompressEncodings, if not empty replace default compression encodings
 	CompressEncodings []string
 
+	// CompressMinSize sets the default minimum size of the response
+	// bodies compressed by the compress filter. Used only together with
+	// CompressEncodings.
+	CompressMinSize int
+
+	// CompressMinRatio sets the default minimum ratio of the size saved
+	// by the compress filter. Used only together with CompressEncodings.
+	CompressMinRatio float64
+
+	// CompressBufferSize sets the maximum number of bytes buffered by the
+	// compress filter to decide whether to compress a response body. Used
+	// only together with CompressEncodings.
+	CompressBufferSize int
+
 	// OIDCSecretsFile path to the file containing key to encrypt OpenID token
 	OIDCSecretsFile string
 
@@ -845,6 +1353,43 @@ type Options struct {
 	// MaxMatcherBufferSize sets the maximum read buffer size of blockContent filter defaults to 2MiB
 	MaxMatcherBufferSize uint64
 
+	// WAFMaxBodySize sets how many bytes of the request bodies are
+	// inspected by the waf filter, defaults to 8KiB
+	WAFMaxBodySize int64
+
+	// XMLMaxBodySize sets the maximum size of the bodies processed by
+	// the xpathToRequestHeader, xpathToResponseHeader,
+	// xmlTransformRequest and xmlTransformResponse filters, defaults
+	// to 1MiB
+	XMLMaxBodySize int64
+
+	// CacheStore sets the store of the responses cached by the cache
+	// filter, memory or redis. The redis store uses the swarm redis
+	// settings. Defaults to memory.
+	CacheStore string
+
+	// CacheMemorySize sets the maximum total size of the responses
+	// cached in memory, defaults to 64MiB
+	CacheMemorySize int64
+
+	// CacheMaxBodySize sets the maximum size of the cached responses,
+	// defaults to 1MiB
+	CacheMaxBodySize int64
+
+	// AsyncStore sets the store of the results of the asyncRequest
+	// filter, memory or redis. The redis store uses the swarm redis
+	// settings. Defaults to memory.
+	AsyncStore string
+
+	// AsyncMaxBodySize sets the maximum size of the requests accepted,
+	// and of the responses stored, by the asyncRequest filter, defaults
+	// to 1MiB
+	AsyncMaxBodySize int64
+
+	// AsyncResultTTL sets how long the results of the asyncRequest
+	// filter are kept, defaults to 1h
+	AsyncResultTTL time.Duration
+
 	// EnableSwarm enables skipper fleet communication, required by e.g.
 	// the cluster ratelimiter
 	EnableSwarm bool
@@ -881,6 +1426,9 @@ type Options struct {
 
 	LuaModules []string
 
+	// the proxies of the listeners with their own routing table
+	listenerHandlers map[string]http.Handler
+
 	testOptions
 }
 
@@ -1037,24 +1585,32 @@ func getLogOutput(name string) (io.Writer, error) {
 	return os.OpenFile(name, os.O_APPEND|os.O_CREATE|os.O_WRONLY, os.ModePerm)
 }
 
-func initLog(o Options) error {
+// initLog initializes the application and the access log, and returns
+// the access log output, that needs to be closed on shutdown, when set.
+func initLog(o Options) (io.WriteCloser, error) {
 	var (
 		logOutput       io.Writer
-		accessLogOutput io.Writer
+		accessLogOutput io.WriteCloser
 		err             error
 	)
 
 	if o.ApplicationLogOutput != "" {
 		logOutput, err = getLogOutput(o.ApplicationLogOutput)
 		if err != nil {
-			return err
+			return nil, err
 		}
 	}
 
 	if !o.AccessLogDisabled && o.AccessLogOutput != "" {
-		accessLogOutput, err = getLogOutput(o.AccessLogOutput)
+		accessLogOutput, err = logging.NewOutput(o.AccessLogOutput, logging.OutputOptions{
+			MaxSize:       o.AccessLogMaxSize,
+			MaxAge:        o.AccessLogMaxAge,
+			MaxBackups:    o.AccessLogMaxBackups,
+			BatchSize:     o.AccessLogBatchSize,
+			FlushInterval: o.AccessLogFlushInterval,
+		})
 		if err != nil {
-			return err
+			return nil, err
 		}
 	}
 
@@ -1067,9 +1623,10 @@ func initLog(o Options) error {
 		AccessLogJSONEnabled:        o.AccessLogJSONEnabled,
 		AccessLogStripQuery:         o.AccessLogStripQuery,
 		AccessLogJsonFormatter:      o.AccessLogJsonFormatter,
+		AccessLogIdentityFields:     o.AccessLogIdentityFields,
 	})
 
-	return nil
+	return accessLogOutput, nil
 }
 
 // filterRegistry creates a filter registry with the builtin and
@@ -1112,7 +1669,7 @@ func (o *Options) tlsConfig(cr *certregistry.CertRegistry) (*tls.Config, error)
 			MinVersion:     o.TLSMinVersion,
 			GetCertificate: cr.GetCertFromHello,
 		}
-		return config, nil
+		return config, o.setClientAuth(config)
 	}
 
 	crts := strings.Split(o.CertPathTLS, ",")
@@ -1138,16 +1695,174 @@ func (o *Options) tlsConfig(cr *certregistry.CertRegistry) (*tls.Config, error)
 		}
 		config.Certificates = append(config.Certificates, keypair)
 	}
-	return config, nil
+	return config, o.setClientAuth(config)
+}
+
+func (o *Options) setClientAuth(config *tls.Config) error {
+	config.ClientAuth = o.ClientAuthTLS
+	if o.ClientCAPathTLS == "" {
+		return nil
+	}
+
+	pool := x509.NewCertPool()
+	for _, path := range strings.Split(o.ClientCAPathTLS, ",") {
+		pem, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("failed to read client CA file %s: %w", path, err)
+		}
+
+		if !pool.AppendCertsFromPEM(pem) {
+			return fmt.Errorf("failed to load client CA certificates from %s", path)
+		}
+	}
+
+	config.ClientCAs = pool
+	return nil
+}
+
+func (o *Options) eventSinkKafkaOptions() (eventsink.KafkaOptions, error) {
+	ko := eventsink.KafkaOptions{
+		Brokers: o.EventSinkKafkaBrokers,
+		Topic:   o.EventSinkKafkaTopic,
+	}
+
+	if o.EventSinkKafkaTLS || o.EventSinkKafkaTLSCAFile != "" {
+		ko.TLS = &tls.Config{MinVersion: tls.VersionTLS12}
+		if o.EventSinkKafkaTLSCAFile != "" {
+			pem, err := os.ReadFile(o.EventSinkKafkaTLSCAFile)
+			if err != nil {
+				return ko, fmt.Errorf("failed to read Kafka CA file %s: %w", o.EventSinkKafkaTLSCAFile, err)
+			}
+
+			pool := x509.NewCertPool()
+			if !pool.AppendCertsFromPEM(pem) {
+				return ko, fmt.Errorf("failed to load Kafka CA certificates from %s", o.EventSinkKafkaTLSCAFile)
+			}
+
+			ko.TLS.RootCAs = pool
+		}
+	}
+
+	if o.EventSinkKafkaSASLMechanism != "" {
+		ko.SASL = &eventsink.KafkaSASL{
+			Mechanism: o.EventSinkKafkaSASLMechanism,
+			Username:  o.EventSinkKafkaSASLUsername,
+		}
+
+		if o.EventSinkKafkaSASLPasswordFile != "" {
+			password, err := os.ReadFile(o.EventSinkKafkaSASLPasswordFile)
+			if err != nil {
+				return ko, fmt.Errorf("failed to read Kafka SASL password file: %w", err)
+			}
+
+			ko.SASL.Password = strings.TrimSpace(string(password))
+		}
+	}
+
+	return ko, nil
 }
 
-func listen(o *Options, address string, mtr metrics.Metrics) (net.Listener, error) {
+func listen(o *Options, address string, mtr metrics.Metrics) ([]net.Listener, error) {
+	return listenPassthrough(o, address, mtr, nil)
+}
+
+// listens on a plain HTTP address, forwarding the TLS connections of the
+// TCP routes, when the passthrough proxy is set
+func listenPassthrough(o *Options, address string, mtr metrics.Metrics, pt *passthrough.Proxy) ([]net.Listener, error) {
 	if address == "" {
 		address = ":http"
 	}
 
+	ls, err := listenTCP(o, address, mtr)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, l := range ls {
+		if pt != nil {
+			l = pt.Listener(l)
+		}
+
+		ls[i] = skpnet.NewStrictParsingListener(l, skpnet.StrictParsingOptions{
+			Level:          o.StrictHTTPParsing,
+			MaxHeaderBytes: o.MaxHeaderBytes,
+			Metrics:        mtr,
+		})
+	}
+
+	return ls, nil
+}
+
+// opens the listening socket, or multiple sockets with SO_REUSEPORT on the
+// same address, one for each acceptor. The returned listeners need to be
+// served independently.
+func listenTCP(o *Options, address string, mtr metrics.Metrics) ([]net.Listener, error) {
+	var (
+		ls  []net.Listener
+		err error
+	)
+
+	if o.ReusePortAcceptors > 1 {
+		ls, err = skpnet.ListenReusePort(skpnet.ReusePortOptions{
+			Network:   "tcp",
+			Address:   address,
+			Acceptors: o.ReusePortAcceptors,
+		})
+	} else {
+		var l net.Listener
+		l, err = net.Listen("tcp", address)
+		ls = []net.Listener{l}
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range ls {
+		// on error, the failed listener is already closed
+		if ls[i], err = wrapTCP(o, ls[i], mtr, i, len(ls)); err != nil {
+			for j, l := range ls {
+				if j != i {
+					l.Close()
+				}
+			}
+
+			return nil, err
+		}
+	}
+
+	return ls, nil
+}
+
+// applies the TCP queue and the PROXY protocol to a listening socket
+func wrapTCP(o *Options, l net.Listener, mtr metrics.Metrics, index, acceptors int) (net.Listener, error) {
+	l, err := listenTCPQueue(o, l, mtr, index, acceptors)
+	if err != nil || !o.ProxyProtocol {
+		return l, err
+	}
+
+	if len(o.ProxyProtocolTrustedNetworks) == 0 {
+		l.Close()
+		return nil, errors.New("PROXY protocol requires trusted networks")
+	}
+
+	timeout := o.ReadHeaderTimeoutServer
+	if timeout <= 0 {
+		timeout = o.ReadTimeoutServer
+	}
+
+	return skpnet.NewProxyProtocolListener(l, skpnet.ProxyProtocolOptions{
+		TrustedNetworks: o.ProxyProtocolTrustedNetworks,
+		Timeout:         timeout,
+		Metrics:         mtr,
+	}), nil
+}
+
+// with multiple acceptors, every socket gets its own queue, and the limits
+// are split between them evenly
+func listenTCPQueue(o *Options, l net.Listener, mtr metrics.Metrics, index, acceptors int) (net.Listener, error) {
 	if !o.EnableTCPQueue {
-		return net.Listen("tcp", address)
+		return l, nil
 	}
 
 	var memoryLimit int
@@ -1186,56 +1901,97 @@ func listen(o *Options, address string, mtr metrics.Metrics) (net.Listener, erro
 		qto = o.ReadTimeoutServer
 	}
 
-	return queuelistener.Listen(queuelistener.Options{
-		Network:          "tcp",
-		Address:          address,
+	qo := queuelistener.Options{
+		Listener:         l,
 		MaxConcurrency:   o.MaxTCPListenerConcurrency,
 		MaxQueueSize:     o.MaxTCPListenerQueue,
 		MemoryLimitBytes: memoryLimit,
 		ConnectionBytes:  o.ExpectedBytesPerRequest,
 		QueueTimeout:     qto,
 		Metrics:          mtr,
-	})
+	}
+
+	if acceptors > 1 {
+		qo.MaxConcurrency = splitLimit(qo.MaxConcurrency, acceptors)
+		qo.MaxQueueSize = splitLimit(qo.MaxQueueSize, acceptors)
+		qo.MemoryLimitBytes = splitLimit(qo.MemoryLimitBytes, acceptors)
+		qo.MetricsKeySuffix = fmt.Sprintf(".%d", index)
+	}
+
+	ql, err := queuelistener.Listen(qo)
+	if err != nil {
+		l.Close()
+		return nil, err
+	}
+
+	return ql, nil
+}
+
+// splits a configured limit, keeping 0 as not set
+func splitLimit(limit, n int) int {
+	if limit <= 0 {
+		return limit
+	}
+
+	limit /= n
+	if limit == 0 {
+		limit = 1
+	}
+
+	return limit
 }
 
-func listenAndServeQuit(
-	proxy http.Handler,
-	o *Options,
-	sigs chan os.Signal,
-	idleConnsCH chan struct{},
-	mtr metrics.Metrics,
-	cr *certregistry.CertRegistry,
-) error {
-	tlsConfig, err := o.tlsConfig(cr)
+// serverDeps contains the dependencies of the proxy listeners. All of them
+// are optional, to be able to start and tear down the listeners from the
+// tests.
+type serverDeps struct {
+	// sigs receives the shutdown signal
+	sigs chan os.Signal
+
+	// idleConnsCH is closed when the listeners were drained
+	idleConnsCH chan struct{}
+
+	metrics      metrics.Metrics
+	certRegistry *certregistry.CertRegistry
+	passthrough  *passthrough.Proxy
+	drain        *drain.Registry
+}
+
+func listenAndServeQuit(proxy http.Handler, o *Options, deps serverDeps) error {
+	tlsConfig, err := o.tlsConfig(deps.certRegistry)
 	if err != nil {
 		return err
 	}
 
-	srv := &http.Server{
-		Addr:              o.Address,
-		TLSConfig:         tlsConfig,
-		Handler:           proxy,
-		ReadTimeout:       o.ReadTimeoutServer,
-		ReadHeaderTimeout: o.ReadHeaderTimeoutServer,
-		WriteTimeout:      o.WriteTimeoutServer,
-		IdleTimeout:       o.IdleTimeoutServer,
-		MaxHeaderBytes:    o.MaxHeaderBytes,
-		ErrorLog:          newServerErrorLog(),
+	srv := newServer(proxy, o, listener.Main, tlsConfig)
+
+	dr := deps.drain
+	if dr == nil {
+		dr = drain.NewRegistry()
 	}
 
-	if o.EnableConnMetricsServer {
-		m := metrics.Default
-		srv.ConnState = func(conn net.Conn, state http.ConnState) {
-			m.IncCounter(fmt.Sprintf("lb-conn-%s", state))
+	dr.Add(listener.Main, srv, o.drainOptions())
+
+	var additional []*http.Server
+	for _, lo := range o.Listeners {
+		s, err := startListener(proxy, o, lo, deps.metrics, dr)
+		if err != nil {
+			for _, s := range additional {
+				s.Close()
+			}
+
+			return err
 		}
+
+		additional = append(additional, s)
 	}
 
-	// making idleConnsCH and sigs optional parameters is required to be able to tear down a server
-	// from the tests
+	idleConnsCH := deps.idleConnsCH
 	if idleConnsCH == nil {
 		idleConnsCH = make(chan struct{})
 	}
 
+	sigs := deps.sigs
 	if sigs == nil {
 		sigs = make(chan os.Signal, 1)
 	}
@@ -1243,48 +1999,74 @@ func listenAndServeQuit(
 	go func() {
 		signal.Notify(sigs, syscall.SIGTERM)
 
-		<-sigs
-
-		log.Infof("Got shutdown signal, wait %v for health check", o.WaitForHealthcheckInterval)
-		time.Sleep(o.WaitForHealthcheckInterval)
-
-		log.Info("Start shutdown")
-		if err := srv.Shutdown(context.Background()); err != nil {
-			log.Errorf("Failed to graceful shutdown: %v", err)
+		select {
+		case <-sigs:
+			log.Info("Got shutdown signal, draining the listeners")
+		case <-dr.Triggered():
+			log.Info("Got drain request, draining the listeners")
 		}
+
+		dr.DrainAll()
 		close(idleConnsCH)
 	}()
 
 	log.Infof("proxy listener on %v", o.Address)
 
 	if srv.TLSConfig != nil {
+		if o.StrictHTTPParsing != skpnet.StrictParsingOff {
+			log.Warn("Strict HTTP parsing is not applied to the TLS listener")
+		}
+
 		if o.InsecureAddress != "" {
 			log.Infof("insecure listener on %v", o.InsecureAddress)
 
 			go func() {
-				l, err := listen(o, o.InsecureAddress, mtr)
+				ls, err := listen(o, o.InsecureAddress, deps.metrics)
 				if err != nil {
 					log.Errorf("Failed to start insecure listener on %s: %v", o.Address, err)
+					return
 				}
 
-				if err := srv.Serve(l); err != http.ErrServerClosed {
+				if err := serveListeners(srv, ls, srv.Serve); err != http.ErrServerClosed {
 					log.Errorf("Insecure listener serve failed: %v", err)
 				}
 			}()
 		}
 
-		if err := srv.ListenAndServeTLS("", ""); err != http.ErrServerClosed {
+		if o.ProxyProtocol || deps.passthrough != nil || o.ReusePortAcceptors > 1 {
+			address := o.Address
+			if address == "" {
+				address = ":https"
+			}
+
+			ls, err := listenTCP(o, address, deps.metrics)
+			if err != nil {
+				return err
+			}
+
+			if deps.passthrough != nil {
+				for i := range ls {
+					ls[i] = deps.passthrough.Listener(ls[i])
+				}
+			}
+
+			serveTLS := func(l net.Listener) error { return srv.ServeTLS(l, "", "") }
+			if err := serveListeners(srv, ls, serveTLS); err != http.ErrServerClosed {
+				log.Errorf("ServeTLS failed: %v", err)
+				return err
+			}
+		} else if err := srv.ListenAndServeTLS("", ""); err != http.ErrServerClosed {
 			log.Errorf("ListenAndServeTLS failed: %v", err)
 			return err
 		}
 	} else {
 		log.Infof("TLS settings not found, defaulting to HTTP")
-		l, err := listen(o, o.Address, mtr)
+		ls, err := listenPassthrough(o, o.Address, deps.metrics, deps.passthrough)
 		if err != nil {
 			return err
 		}
 
-		if err := srv.Serve(l); err != http.ErrServerClosed {
+		if err := serveListeners(srv, ls, srv.Serve); err != http.ErrServerClosed {
 			log.Errorf("Serve failed: %v", err)
 			return err
 		}
@@ -1295,8 +2077,226 @@ func listenAndServeQuit(
 	return nil
 }
 
+// serves each listener in a separate goroutine, with the same server. It
+// returns when all of them returned. When serving any of the listeners
+// fails, it closes the server, and returns the error, otherwise it returns
+// http.ErrServerClosed.
+func serveListeners(srv *http.Server, ls []net.Listener, serve func(net.Listener) error) error {
+	if len(ls) == 1 {
+		return serve(ls[0])
+	}
+
+	errs := make(chan error, len(ls))
+	for _, l := range ls {
+		go func(l net.Listener) { errs <- serve(l) }(l)
+	}
+
+	err := http.ErrServerClosed
+	for range ls {
+		if serr := <-errs; serr != http.ErrServerClosed && err == http.ErrServerClosed {
+			err = serr
+			srv.Close()
+		}
+	}
+
+	return err
+}
+
+func newServer(proxy http.Handler, o *Options, name string, tlsConfig *tls.Config) *http.Server {
+	srv := &http.Server{
+		Addr:              o.Address,
+		TLSConfig:         tlsConfig,
+		Handler:           proxy,
+		ReadTimeout:       o.ReadTimeoutServer,
+		ReadHeaderTimeout: o.ReadHeaderTimeoutServer,
+		WriteTimeout:      o.WriteTimeoutServer,
+		IdleTimeout:       o.IdleTimeoutServer,
+		MaxHeaderBytes:    o.MaxHeaderBytes,
+		ErrorLog:          newServerErrorLog(),
+		BaseContext: func(net.Listener) context.Context {
+			return listener.NewContext(context.Background(), name)
+		},
+	}
+
+	if o.EnableConnMetricsServer {
+		m := metrics.Default
+		srv.ConnState = func(conn net.Conn, state http.ConnState) {
+			m.IncCounter(fmt.Sprintf("lb-conn-%s", state))
+		}
+	}
+
+	return srv
+}
+
+// returns the options of an additional listener, using the settings of
+// the main listener as defaults
+func (o *Options) listenerOptions(lo ListenerOptions) *Options {
+	c := *o
+	c.Address = lo.Address
+	c.CertPathTLS = lo.CertPathTLS
+	c.KeyPathTLS = lo.KeyPathTLS
+	c.ProxyTLS = nil
+	c.KubernetesEnableTLS = false
+	c.ProxyProtocol = lo.ProxyProtocol
+	c.DrainCloseConnections = lo.DrainCloseConnections
+	c.DrainRejectRequests = lo.DrainRejectRequests
+
+	if lo.MaxHeaderBytes > 0 {
+		c.MaxHeaderBytes = lo.MaxHeaderBytes
+	}
+
+	if lo.ReadTimeout > 0 {
+		c.ReadTimeoutServer = lo.ReadTimeout
+	}
+
+	if lo.ReadHeaderTimeout > 0 {
+		c.ReadHeaderTimeoutServer = lo.ReadHeaderTimeout
+	}
+
+	if lo.WriteTimeout > 0 {
+		c.WriteTimeoutServer = lo.WriteTimeout
+	}
+
+	if lo.IdleTimeout > 0 {
+		c.IdleTimeoutServer = lo.IdleTimeout
+	}
+
+	if lo.DrainDuration > 0 {
+		c.DrainDuration = lo.DrainDuration
+	}
+
+	if lo.DrainRetryAfter > 0 {
+		c.DrainRetryAfter = lo.DrainRetryAfter
+	}
+
+	return &c
+}
+
+// returns the drain settings of a listener
+func (o *Options) drainOptions() drain.Options {
+	d := o.DrainDuration
+	if d <= 0 {
+		d = o.WaitForHealthcheckInterval
+	}
+
+	return drain.Options{
+		Duration:         d,
+		CloseConnections: o.DrainCloseConnections,
+		RejectRequests:   o.DrainRejectRequests,
+		RetryAfter:       o.DrainRetryAfter,
+	}
+}
+
+func (o *Options) backendHeaderPolicy() headerpolicy.Policy {
+	p := headerpolicy.Policy{
+		Strip:         o.BackendStripHeaders,
+		MaxHeaderSize: o.BackendMaxHeaderSize,
+	}
+
+	if o.BackendFoldHeaders {
+		p.FoldDuplicates = &o.BackendFoldHeaders
+	}
+
+	return p
+}
+
+// returns the addresses of the DNS servers in the host:port format
+func (o *Options) dnsServers() ([]string, error) {
+	var servers []string
+	for _, s := range o.DNSServers {
+		address, ok := dnscache.ServerAddress(s)
+		if !ok {
+			return nil, fmt.Errorf("invalid DNS server address: %s", s)
+		}
+
+		servers = append(servers, address)
+	}
+
+	return servers, nil
+}
+
+// tells whether the listener has its own routing table
+func (lo ListenerOptions) hasRoutes() bool {
+	return lo.RoutesFile != "" || lo.InlineRoutes != "" || len(lo.CustomDataClients) > 0
+}
+
+// returns the data clients of the own routing table of the listener
+func (lo ListenerOptions) dataClients() ([]routing.DataClient, error) {
+	var clients []routing.DataClient
+	if lo.RoutesFile != "" {
+		for _, rf := range strings.Split(lo.RoutesFile, ",") {
+			f, err := eskipfile.Open(rf)
+			if err != nil {
+				return nil, fmt.Errorf("failed to open the routes file of listener %s: %w", lo.Name, err)
+			}
+
+			clients = append(clients, f)
+		}
+	}
+
+	if lo.InlineRoutes != "" {
+		ir, err := routestring.New(lo.InlineRoutes)
+		if err != nil {
+			return nil, fmt.Errorf("invalid inline routes of listener %s: %w", lo.Name, err)
+		}
+
+		clients = append(clients, ir)
+	}
+
+	return append(clients, lo.CustomDataClients...), nil
+}
+
+// starts an additional proxy listener
+func startListener(proxy http.Handler, o *Options, lo ListenerOptions, mtr metrics.Metrics, dr *drain.Registry) (*http.Server, error) {
+	if lo.Name == "" || lo.Name == listener.Main || lo.Address == "" {
+		return nil, fmt.Errorf("invalid listener, name: %q, address: %q", lo.Name, lo.Address)
+	}
+
+	if h, ok := o.listenerHandlers[lo.Name]; ok {
+		proxy = h
+	}
+
+	lopts := o.listenerOptions(lo)
+	tlsConfig, err := lopts.tlsConfig(nil)
+	if err != nil {
+		return nil, fmt.Errorf("invalid TLS settings of listener %s: %w", lo.Name, err)
+	}
+
+	srv := newServer(proxy, lopts, lo.Name, tlsConfig)
+	if lo.DisableHTTP2 {
+		srv.TLSNextProto = make(map[string]func(*http.Server, *tls.Conn, http.Handler))
+	}
+
+	dr.Add(lo.Name, srv, lopts.drainOptions())
+
+	var (
+		ls    []net.Listener
+		serve = srv.Serve
+	)
+
+	if tlsConfig != nil {
+		ls, err = listenTCP(lopts, lo.Address, mtr)
+		serve = func(l net.Listener) error { return srv.ServeTLS(l, "", "") }
+	} else {
+		ls, err = listen(lopts, lo.Address, mtr)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	go func() {
+		if err := serveListeners(srv, ls, serve); err != http.ErrServerClosed {
+			log.Errorf("Listener %s serve failed: %v", lo.Name, err)
+		}
+	}()
+
+	log.Infof("%s listener on %v", lo.Name, lo.Address)
+	return srv, nil
+}
+
 func listenAndServe(proxy http.Handler, o *Options) error {
-	return listenAndServeQuit(proxy, o, nil, nil, nil, nil)
+	return listenAndServeQuit(proxy, o, serverDeps{})
 }
 
 func findKubernetesDataclient(dataClients []routing.DataClient) *kubernetes.Client {
@@ -1326,11 +2326,15 @@ func getRedisUpdaterFunc(namespace, name string, kdc *kubernetes.Client) func()
 
 func run(o Options, sig chan os.Signal, idleConnsCH chan struct{}) error {
 	// init log
-	err := initLog(o)
+	accessLogOutput, err := initLog(o)
 	if err != nil {
 		return err
 	}
 
+	if accessLogOutput != nil {
+		defer accessLogOutput.Close()
+	}
+
 	if o.EnablePrometheusMetrics {
 		o.MetricsFlavours = append(o.MetricsFlavours, "prometheus")
 	}
@@ -1350,6 +2354,11 @@ func run(o Options, sig chan os.Signal, idleConnsCH chan struct{}) error {
 		metricsKind = metrics.CodaHaleKind
 	}
 
+	// the accounting metrics are registered in the Prometheus registry
+	if o.CostAccounting && metricsKind&metrics.PrometheusKind != 0 && o.PrometheusRegistry == nil && o.MetricsBackend == nil {
+		o.PrometheusRegistry = prometheus.NewRegistry()
+	}
+
 	log.Infof("Expose metrics in %s format", metricsKind)
 	mtrOpts := metrics.Options{
 		Format:                             metricsKind,
@@ -1385,6 +2394,54 @@ func run(o Options, sig chan os.Signal, idleConnsCH chan struct{}) error {
 	}
 	metrics.Default = mtr
 
+	var accountingRegistry *accounting.Registry
+	if o.CostAccounting {
+		var sink accounting.Sink
+		if o.CostAccountingExport != "" {
+			sink, err = accounting.NewSink(o.CostAccountingExport)
+			if err != nil {
+				return err
+			}
+		}
+
+		accountingRegistry = accounting.NewRegistry(accounting.Options{
+			Sink:           sink,
+			ExportInterval: o.CostAccountingExportInterval,
+			MaxTenants:     o.CostAccountingMaxTenants,
+		})
+		defer accountingRegistry.Close()
+
+		if o.PrometheusRegistry != nil {
+			o.PrometheusRegistry.MustRegister(accountingRegistry)
+		}
+	}
+
+	if o.EventSink == nil && len(o.EventSinkKafkaBrokers) > 0 {
+		ko, err := o.eventSinkKafkaOptions()
+		if err != nil {
+			return err
+		}
+
+		kw, err := eventsink.NewKafkaWriter(ko)
+		if err != nil {
+			return err
+		}
+
+		sink := eventsink.NewAsync(kw, eventsink.Options{
+			BufferSize:    o.EventSinkBufferSize,
+			BatchSize:     o.EventSinkBatchSize,
+			FlushInterval: o.EventSinkFlushInterval,
+			BlockTimeout:  o.EventSinkBlockTimeout,
+			Metrics:       mtr,
+		})
+		defer sink.Close()
+		o.EventSink = sink
+	}
+
+	if o.EventSink != nil {
+		logging.SetEventSink(o.EventSink)
+	}
+
 	// *DEPRECATED* client tracking parameter
 	if o.ApiUsageMonitoringDefaultClientTrackingPattern != "" {
 		log.Warn(`"ApiUsageMonitoringDefaultClientTrackingPattern" option is deprecated`)
@@ -1420,6 +2477,34 @@ func run(o Options, sig chan os.Signal, idleConnsCH chan struct{}) error {
 	// append custom data clients
 	dataClients = append(dataClients, o.CustomDataClients...)
 
+	var snapshotClient *snapshot.Client
+	if o.EnableRouteSnapshotImport {
+		snapshotClient = snapshot.New()
+		dataClients = append(dataClients, snapshotClient)
+	}
+
+	// the rules are also a data client, that reports the changes of the
+	// rules file
+	var routeRules *routerules.Rules
+	if o.RouteRulesFile != "" {
+		routeRules, err = routerules.New(o.RouteRulesFile)
+		if err != nil {
+			return err
+		}
+
+		dataClients = append(dataClients, routeRules)
+	}
+
+	var configProfiles *profiles.Profiles
+	if o.ProfilesFile != "" {
+		configProfiles, err = profiles.New(o.ProfilesFile)
+		if err != nil {
+			return err
+		}
+
+		dataClients = append(dataClients, configProfiles)
+	}
+
 	if len(dataClients) == 0 {
 		log.Warning("no route source specified")
 	}
@@ -1493,10 +2578,31 @@ func run(o Options, sig chan os.Signal, idleConnsCH chan struct{}) error {
 		log.Fatal("Failed to cast admission control filter to spec")
 	}
 
+	maintenanceSpec := maintenance.New()
+	backendJWTSpec := auth.NewBackendJWT(sp)
+
+	clientCredentials := auth.NewClientCredentialsProvider(sp, auth.ClientCredentialsOptions{
+		MaxIdleConns: o.IdleConnectionsPerHost,
+		Tracer:       tracer,
+	})
+	defer clientCredentials.Close()
+
 	o.CustomFilters = append(o.CustomFilters,
-		logfilter.NewAuditLog(o.MaxAuditBody),
+		maintenanceSpec,
+		logfilter.NewAuditLogWithSink(o.MaxAuditBody, o.EventSink),
 		block.NewBlockFilter(o.MaxMatcherBufferSize),
+		waf.New(waf.Options{MaxBodySize: o.WAFMaxBodySize, Sink: o.EventSink}),
+		xmlbody.NewXPathToRequestHeader(xmlbody.Options{MaxBodySize: o.XMLMaxBodySize}),
+		xmlbody.NewXPathToResponseHeader(xmlbody.Options{MaxBodySize: o.XMLMaxBodySize}),
+		xmlbody.NewTransformRequest(xmlbody.Options{MaxBodySize: o.XMLMaxBodySize}),
+		xmlbody.NewTransformResponse(xmlbody.Options{MaxBodySize: o.XMLMaxBodySize}),
+		dictionary.NewUseAsDictionary(),
+		dictionary.NewCompress(),
+		conditional.New(),
 		auth.NewBearerInjector(sp),
+		backendJWTSpec,
+		transport.NewBackendClientCertificate(sp),
+		auth.NewOAuthClientCredentials(clientCredentials),
 		auth.NewJwtValidationWithOptions(tio),
 		auth.TokenintrospectionWithOptions(auth.NewOAuthTokenintrospectionAnyClaims, tio),
 		auth.TokenintrospectionWithOptions(auth.NewOAuthTokenintrospectionAllClaims, tio),
@@ -1636,6 +2742,69 @@ func run(o Options, sig chan os.Signal, idleConnsCH chan struct{}) error {
 		}
 	}
 
+	var watchdog *memorywatch.Watchdog
+	if o.EnableMemoryWatchdog {
+		watchdog = memorywatch.New(memorywatch.Options{
+			Threshold: o.MemoryWatchdogThreshold,
+			Metrics:   mtr,
+		})
+		defer watchdog.Close()
+		watchdog.OnPressure(proxy.ReleaseBuffers)
+	}
+
+	cacheStore := cache.NewMemoryStore(o.CacheMemorySize)
+	switch o.CacheStore {
+	case "", "memory":
+	case "redis":
+		if redisOptions == nil {
+			log.Error("Redis cache store requires the swarm redis settings, using memory store")
+		} else {
+			cacheRing := skpnet.NewRedisRingClient(redisOptions)
+			defer cacheRing.Close()
+			cacheStore = cache.NewRedisStore(cacheRing)
+		}
+	default:
+		log.Errorf("Unknown cache store %q, using memory store", o.CacheStore)
+	}
+
+	cacheOptions := cache.Options{
+		Store:       cacheStore,
+		MaxBodySize: o.CacheMaxBodySize,
+	}
+
+	if watchdog != nil {
+		if r, ok := cacheStore.(cache.Releaser); ok {
+			watchdog.OnPressure(r.Release)
+		}
+
+		cacheOptions.MemoryPressure = watchdog.UnderPressure
+	}
+
+	o.CustomFilters = append(o.CustomFilters, cache.NewWithOptions(cacheOptions))
+
+	asyncStore := async.NewMemoryStore()
+	switch o.AsyncStore {
+	case "", "memory":
+	case "redis":
+		if redisOptions == nil {
+			log.Error("Redis async store requires the swarm redis settings, using memory store")
+		} else {
+			asyncRing := skpnet.NewRedisRingClient(redisOptions)
+			defer asyncRing.Close()
+			asyncStore = async.NewRedisStore(asyncRing)
+		}
+	default:
+		log.Errorf("Unknown async store %q, using memory store", o.AsyncStore)
+	}
+
+	asyncOptions := async.Options{
+		Store:       asyncStore,
+		MaxBodySize: o.AsyncMaxBodySize,
+		TTL:         o.AsyncResultTTL,
+	}
+
+	o.CustomFilters = append(o.CustomFilters, async.NewRequest(asyncOptions), async.NewResult(asyncOptions))
+
 	if o.TLSMinVersion == 0 {
 		o.TLSMinVersion = tls.VersionTLS12
 	}
@@ -1679,7 +2848,12 @@ func run(o Options, sig chan os.Signal, idleConnsCH chan struct{}) error {
 	}
 
 	if len(o.CompressEncodings) > 0 {
-		compress, err := builtin.NewCompressWithOptions(builtin.CompressOptions{Encodings: o.CompressEncodings})
+		compress, err := builtin.NewCompressWithOptions(builtin.CompressOptions{
+			Encodings:  o.CompressEncodings,
+			MinSize:    o.CompressMinSize,
+			MinRatio:   o.CompressMinRatio,
+			BufferSize: o.CompressBufferSize,
+		})
 		if err != nil {
 			log.Errorf("Failed to create compress filter: %v.", err)
 			return err
@@ -1696,6 +2870,54 @@ func run(o Options, sig chan os.Signal, idleConnsCH chan struct{}) error {
 		o.CustomFilters = append(o.CustomFilters, lua)
 	}
 
+	if len(o.GeoIPDatabases) > 0 {
+		geoDB, err := geoip.Open(geoip.Options{
+			Paths:          o.GeoIPDatabases,
+			ReloadInterval: o.GeoIPReloadInterval,
+		})
+		if err != nil {
+			log.Errorf("Failed to open geoip databases: %v.", err)
+			return err
+		}
+		defer geoDB.Close()
+
+		o.CustomFilters = append(o.CustomFilters, geoipfilters.New(geoDB))
+		o.CustomPredicates = append(o.CustomPredicates, geoippredicates.New(geoDB))
+	}
+
+	if o.EnableBotDetection {
+		botDetector := botdetect.New(botdetect.Options{
+			RateWindow:      o.BotDetectionRateWindow,
+			RateThreshold:   o.BotDetectionRateThreshold,
+			JA3Header:       o.BotDetectionJA3Header,
+			JA3Fingerprints: o.BotDetectionJA3Fingerprints,
+		})
+		defer botDetector.Close()
+
+		o.CustomFilters = append(o.CustomFilters, botdetectfilters.NewScore(botDetector), botdetectfilters.NewBlock())
+		o.CustomPredicates = append(o.CustomPredicates, botdetectpredicates.New(botDetector))
+	}
+
+	var challengeOptions challenge.Options
+	if o.ChallengeSecretFile != "" {
+		secret, err := os.ReadFile(o.ChallengeSecretFile)
+		if err != nil {
+			log.Errorf("Failed to read challenge secret file: %v.", err)
+			return err
+		}
+		challengeOptions.Secret = bytes.TrimSpace(secret)
+	}
+
+	challengeSpec, err := challenge.New(challengeOptions)
+	if err != nil {
+		log.Errorf("Failed to create challenge filter: %v.", err)
+		return err
+	}
+	o.CustomFilters = append(o.CustomFilters, challengeSpec, tarpit.New())
+
+	sourceFromFile := source.NewFromFile(source.FromFileOptions{ReloadInterval: o.SourceFromFileReloadInterval})
+	defer sourceFromFile.Close()
+
 	// create routing
 	// create the proxy instance
 	var mo routing.MatchingOptions
@@ -1719,13 +2941,25 @@ func run(o Options, sig chan os.Signal, idleConnsCH chan struct{}) error {
 		source.New(),
 		source.NewFromLast(),
 		source.NewClientIP(),
+		sourceFromFile,
+		clientcert.NewSAN(),
+		clientcert.NewSubject(),
+		clientcert.NewSPIFFE(),
+		listener.New(),
+		body.NewRegexp(),
+		body.NewJSONPath(),
 		interval.NewBetween(),
 		interval.NewBefore(),
 		interval.NewAfter(),
 		cron.New(),
 		cookie.New(),
 		query.New(),
+		query.NewAny(),
+		query.NewAll(),
+		query.NewAnyRegexp(),
+		query.NewAllRegexp(),
 		traffic.New(),
+		traffic.NewSticky(),
 		primitive.NewTrue(),
 		primitive.NewFalse(),
 		primitive.NewShutdown(),
@@ -1733,11 +2967,16 @@ func run(o Options, sig chan os.Signal, idleConnsCH chan struct{}) error {
 		pauth.NewJWTPayloadAnyKV(),
 		pauth.NewJWTPayloadAllKVRegexp(),
 		pauth.NewJWTPayloadAnyKVRegexp(),
+		pauth.NewJWTVerifiedPayloadAllKV(),
+		pauth.NewJWTVerifiedPayloadAnyKV(),
+		pauth.NewJWTVerifiedPayloadAllKVRegexp(),
+		pauth.NewJWTVerifiedPayloadAnyKVRegexp(),
 		methods.New(),
 		tee.New(),
 		forwarded.NewForwardedHost(),
 		forwarded.NewForwardedProto(),
 		host.NewAny(),
+		host.NewWildcard(),
 	)
 
 	// provide default value for wrapper if not defined
@@ -1747,34 +2986,87 @@ func run(o Options, sig chan os.Signal, idleConnsCH chan struct{}) error {
 		}
 	}
 
+	var shedder scheduler.Shedder
+	if watchdog != nil {
+		shedder = watchdog
+	}
+
 	schedulerRegistry := scheduler.RegistryWith(scheduler.Options{
 		Metrics:                mtr,
 		EnableRouteFIFOMetrics: o.EnableRouteFIFOMetrics,
 		EnableRouteLIFOMetrics: o.EnableRouteLIFOMetrics,
+		Shedder:                shedder,
 	})
 	defer schedulerRegistry.Close()
 
-	// create a routing engine
-	ro := routing.Options{
-		FilterRegistry:  o.filterRegistry(),
-		MatchingOptions: mo,
-		PollTimeout:     o.SourcePollTimeout,
-		DataClients:     dataClients,
-		Predicates:      o.CustomPredicates,
-		UpdateBuffer:    updateBuffer,
-		SuppressLogs:    o.SuppressRouteUpdateLogs,
-		PostProcessors: []routing.PostProcessor{
+	endpointRegistry := o.EndpointRegistry
+	if endpointRegistry == nil {
+		endpointRegistry = routing.NewEndpointRegistry(routing.RegistryOptions{})
+	}
+
+	// some of the post-processors keep the state of the routes, so they
+	// are created for each routing table
+	postProcessors := func(sr *scheduler.Registry, er routing.PostProcessor) []routing.PostProcessor {
+		pp := []routing.PostProcessor{
 			loadbalancer.HealthcheckPostProcessor{LB: lbInstance},
 			loadbalancer.NewAlgorithmProvider(),
-			schedulerRegistry,
+			er,
+			sr,
 			builtin.NewRouteCreationMetrics(mtr),
 			fadein.NewPostProcessor(),
 			admissionControlSpec.PostProcessor(),
-		},
-		SignalFirstLoad: o.WaitFirstRouteLoad,
+			maintenanceSpec.PostProcessor(),
+			backendJWTSpec.PostProcessor(),
+		}
+
+		if failClosedRatelimitPostProcessor != nil {
+			pp = append(pp, failClosedRatelimitPostProcessor)
+		}
+
+		return pp
 	}
-	if failClosedRatelimitPostProcessor != nil {
-		ro.PostProcessors = append(ro.PostProcessors, failClosedRatelimitPostProcessor)
+
+	// create a routing engine
+	ro := routing.Options{
+		FilterRegistry:       o.filterRegistry(),
+		MatchingOptions:      mo,
+		PollTimeout:          o.SourcePollTimeout,
+		DataClients:          dataClients,
+		Predicates:           o.CustomPredicates,
+		UpdateBuffer:         updateBuffer,
+		SuppressLogs:         o.SuppressRouteUpdateLogs,
+		PostProcessors:       postProcessors(schedulerRegistry, endpointRegistry),
+		SignalFirstLoad:      o.WaitFirstRouteLoad,
+		LazyFilters:          o.LazyFilters,
+		WarmUpLazyFilters:    o.WarmUpLazyFilters,
+		PredicateCosts:       o.PredicateCosts,
+		AtomicRouteUpdates:   o.AtomicRouteUpdates,
+		UnusedRouteThreshold: o.UnusedRouteThreshold,
+		Metrics:              mtr,
+	}
+
+	var passthroughProxy *passthrough.Proxy
+	if o.EnableTLSPassthrough {
+		handshakeTimeout := o.ReadHeaderTimeoutServer
+		if handshakeTimeout <= 0 {
+			handshakeTimeout = o.ReadTimeoutServer
+		}
+
+		passthroughProxy = passthrough.New(passthrough.Options{
+			DialTimeout:      o.TimeoutBackend,
+			HandshakeTimeout: handshakeTimeout,
+			Metrics:          mtr,
+		})
+		defer passthroughProxy.Close()
+
+		// the TCP routes are taken before the other post-processors
+		ro.PostProcessors = append([]routing.PostProcessor{passthroughProxy}, ro.PostProcessors...)
+	}
+
+	// the profiles are expanded first, so that the other pre-processors
+	// see the filters of the profiles
+	if configProfiles != nil {
+		ro.PreProcessors = append(ro.PreProcessors, configProfiles)
 	}
 
 	if o.DefaultFilters != nil {
@@ -1793,6 +3085,14 @@ func run(o Options, sig chan os.Signal, idleConnsCH chan struct{}) error {
 		}
 	}
 
+	if routeRules != nil {
+		ro.PreProcessors = append(ro.PreProcessors, routeRules)
+	}
+
+	if o.RequestLimits != nil {
+		ro.PreProcessors = append(ro.PreProcessors, o.RequestLimits)
+	}
+
 	ro.PreProcessors = append(ro.PreProcessors, schedulerRegistry.PreProcessor())
 
 	if o.EnableOAuth2GrantFlow /* explicitly enable grant flow when callback route was not disabled */ {
@@ -1805,34 +3105,138 @@ func run(o Options, sig chan os.Signal, idleConnsCH chan struct{}) error {
 
 	ro.PreProcessors = append(ro.PreProcessors, admissionControlSpec.PreProcessor())
 
+	var prewarmer *proxy.Prewarmer
+	if o.ConnectionPrewarming {
+		prewarmer = proxy.NewPrewarmer(proxy.PrewarmerOptions{MaxIdle: o.PrewarmMaxIdle})
+		defer prewarmer.Close()
+		ro.PostProcessors = append(ro.PostProcessors, prewarmer)
+	}
+
+	// the listeners with their own routing table get the same routing
+	// settings as the main listener, except for the data clients, and the
+	// post-processors bound to the main listener
+	listenerRoutings := make(map[string]*routing.Routing)
+	for _, lo := range o.Listeners {
+		if !lo.hasRoutes() {
+			continue
+		}
+
+		dcs, err := lo.dataClients()
+		if err != nil {
+			return err
+		}
+
+		lsr := scheduler.RegistryWith(scheduler.Options{
+			Metrics:                mtr,
+			EnableRouteFIFOMetrics: o.EnableRouteFIFOMetrics,
+			EnableRouteLIFOMetrics: o.EnableRouteLIFOMetrics,
+			Shedder:                shedder,
+		})
+		defer lsr.Close()
+
+		lro := ro
+		lro.PostProcessors = postProcessors(lsr, endpointRegistry.PostProcessor())
+
+		// separate instances of the file based pre-processors, because
+		// their reload is reported only to the routing polling them
+		var (
+			lrr *routerules.Rules
+			lcp *profiles.Profiles
+		)
+
+		if routeRules != nil {
+			if lrr, err = routerules.New(o.RouteRulesFile); err != nil {
+				return err
+			}
+
+			dcs = append(dcs, lrr)
+		}
+
+		if configProfiles != nil {
+			if lcp, err = profiles.New(o.ProfilesFile); err != nil {
+				return err
+			}
+
+			dcs = append(dcs, lcp)
+		}
+
+		lro.PreProcessors = make([]routing.PreProcessor, len(ro.PreProcessors))
+		for i, pp := range ro.PreProcessors {
+			switch {
+			case routeRules != nil && pp == routing.PreProcessor(routeRules):
+				pp = lrr
+			case configProfiles != nil && pp == routing.PreProcessor(configProfiles):
+				pp = lcp
+			}
+
+			lro.PreProcessors[i] = pp
+		}
+
+		lro.DataClients = dcs
+		lr := routing.New(lro)
+		defer lr.Close()
+		listenerRoutings[lo.Name] = lr
+	}
+
 	routing := routing.New(ro)
 	defer routing.Close()
 
+	dnsServers, err := o.dnsServers()
+	if err != nil {
+		return err
+	}
+
 	proxyFlags := proxy.Flags(o.ProxyOptions) | o.ProxyFlags
 	proxyParams := proxy.Params{
-		Routing:                    routing,
-		Flags:                      proxyFlags,
-		PriorityRoutes:             o.PriorityRoutes,
-		IdleConnectionsPerHost:     o.IdleConnectionsPerHost,
-		CloseIdleConnsPeriod:       o.CloseIdleConnsPeriod,
-		FlushInterval:              o.BackendFlushInterval,
-		ExperimentalUpgrade:        o.ExperimentalUpgrade,
-		ExperimentalUpgradeAudit:   o.ExperimentalUpgradeAudit,
-		MaxLoopbacks:               o.MaxLoopbacks,
-		DefaultHTTPStatus:          o.DefaultHTTPStatus,
-		LoadBalancer:               lbInstance,
-		Timeout:                    o.TimeoutBackend,
-		ResponseHeaderTimeout:      o.ResponseHeaderTimeoutBackend,
-		ExpectContinueTimeout:      o.ExpectContinueTimeoutBackend,
-		KeepAlive:                  o.KeepAliveBackend,
-		DualStack:                  o.DualStackBackend,
-		TLSHandshakeTimeout:        o.TLSHandshakeTimeoutBackend,
-		MaxIdleConns:               o.MaxIdleConnsBackend,
-		DisableHTTPKeepalives:      o.DisableHTTPKeepalives,
-		AccessLogDisabled:          o.AccessLogDisabled,
-		ClientTLS:                  o.ClientTLS,
-		CustomHttpRoundTripperWrap: o.CustomHttpRoundTripperWrap,
-		RateLimiters:               ratelimitRegistry,
+		Routing:                        routing,
+		Flags:                          proxyFlags,
+		PriorityRoutes:                 o.PriorityRoutes,
+		IdleConnectionsPerHost:         o.IdleConnectionsPerHost,
+		CloseIdleConnsPeriod:           o.CloseIdleConnsPeriod,
+		FastCgiMaxIdleConns:            o.FastCgiMaxIdleConns,
+		FastCgiIdleConnTimeout:         o.FastCgiIdleConnTimeout,
+		DNSCacheTTL:                    o.DNSCacheTTL,
+		DNSStaleTTL:                    o.DNSStaleTTL,
+		DNSServers:                     dnsServers,
+		DNSRespectTTL:                  o.DNSRespectTTL,
+		DNSResolver:                    o.DNSResolver,
+		Prewarmer:                      prewarmer,
+		EnableRequestAnomalyMetrics:    o.EnableRequestAnomalyMetrics,
+		RequestAnomalyMaxHeaderSize:    o.RequestAnomalyMaxHeaderSize,
+		MaxRequestBodySize:             o.MaxRequestBodySize,
+		ForwardEarlyHints:              o.ForwardEarlyHints,
+		BackendHeaderPolicy:            o.backendHeaderPolicy(),
+		ClientDisconnectGracePeriod:    o.ClientDisconnectGracePeriod,
+		DetectStreaming:                o.DetectStreaming,
+		StreamingResponseHeaderTimeout: o.StreamingHeaderTimeout,
+		StreamingIdleTimeout:           o.StreamingIdleTimeout,
+		FlushInterval:                  o.BackendFlushInterval,
+		ExperimentalUpgrade:            o.ExperimentalUpgrade,
+		ExperimentalUpgradeAudit:       o.ExperimentalUpgradeAudit,
+		MaxLoopbacks:                   o.MaxLoopbacks,
+		RetryBudget:                    o.RetryBudget,
+		RetryAfterMaxBackoff:           o.RetryAfterMaxBackoff,
+		DefaultHTTPStatus:              o.DefaultHTTPStatus,
+		LoadBalancer:                   lbInstance,
+		Timeout:                        o.TimeoutBackend,
+		ResponseHeaderTimeout:          o.ResponseHeaderTimeoutBackend,
+		ExpectContinueTimeout:          o.ExpectContinueTimeoutBackend,
+		KeepAlive:                      o.KeepAliveBackend,
+		DualStack:                      o.DualStackBackend,
+		HappyEyeballs:                  o.HappyEyeballsBackend,
+		PreferIPv4:                     o.PreferIPv4Backend,
+		DialFallbackDelay:              o.DialFallbackDelayBackend,
+		TLSHandshakeTimeout:            o.TLSHandshakeTimeoutBackend,
+		MaxIdleConns:                   o.MaxIdleConnsBackend,
+		MaxConnsPerHost:                o.MaxConnsPerHostBackend,
+		EnableBackendConnectionMetrics: o.BackendConnectionMetrics,
+		SSEKeepAliveInterval:           o.SSEKeepAliveInterval,
+		Accounting:                     accountingRegistry,
+		DisableHTTPKeepalives:          o.DisableHTTPKeepalives,
+		AccessLogDisabled:              o.AccessLogDisabled,
+		ClientTLS:                      o.ClientTLS,
+		CustomHttpRoundTripperWrap:     o.CustomHttpRoundTripperWrap,
+		RateLimiters:                   ratelimitRegistry,
 	}
 
 	if o.EnableBreakers || len(o.BreakerSettings) > 0 {
@@ -1842,6 +3246,7 @@ func run(o Options, sig chan os.Signal, idleConnsCH chan struct{}) error {
 	if o.DebugListener != "" {
 		do := proxyParams
 		do.Flags |= proxy.Debug
+		do.Prewarmer = nil
 		dbg := proxy.WithParams(do)
 		log.Infof("debug listener on %v", o.DebugListener)
 		go func() { http.ListenAndServe(o.DebugListener, dbg) /* #nosec */ }()
@@ -1855,10 +3260,22 @@ func run(o Options, sig chan os.Signal, idleConnsCH chan struct{}) error {
 		supportListener = o.MetricsListener
 	}
 
+	drainRegistry := drain.NewRegistry()
 	if supportListener != "" {
 		mux := http.NewServeMux()
 		mux.Handle("/routes", routing)
 		mux.Handle("/routes/", routing)
+		mux.Handle("/maintenance", maintenanceSpec)
+		mux.Handle("/endpoints", endpointRegistry)
+		mux.Handle(drain.Path, drainRegistry)
+		mux.Handle(snapshot.Path, &snapshot.Handler{Source: routing, Client: snapshotClient})
+		for name, lr := range listenerRoutings {
+			mux.Handle("/listeners/"+name+"/routes", lr)
+		}
+
+		schedule := adminschedule.New(adminschedule.Options{Handler: mux})
+		defer schedule.Close()
+		mux.Handle(adminschedule.Path, schedule)
 
 		metricsHandler := metrics.NewHandler(mtrOpts, mtr)
 		mux.Handle("/metrics", metricsHandler)
@@ -1886,6 +3303,16 @@ func run(o Options, sig chan os.Signal, idleConnsCH chan struct{}) error {
 		LogStreamEvents:    o.OpenTracingLogStreamEvents,
 	}
 
+	o.listenerHandlers = make(map[string]http.Handler)
+	for name, lr := range listenerRoutings {
+		lp := proxyParams
+		lp.Routing = lr
+		lp.Prewarmer = nil
+		p := proxy.WithParams(lp)
+		defer p.Close()
+		o.listenerHandlers[name] = o.CustomHttpHandlerWrap(p)
+	}
+
 	// create the proxy
 	proxy := proxy.WithParams(proxyParams)
 	defer proxy.Close()
@@ -1911,9 +3338,20 @@ func run(o Options, sig chan os.Signal, idleConnsCH chan struct{}) error {
 
 	// wait for the first route configuration to be loaded if enabled:
 	<-routing.FirstLoad()
+	for _, lr := range listenerRoutings {
+		<-lr.FirstLoad()
+	}
+
 	log.Info("Dataclients are updated once, first load complete")
 
-	return listenAndServeQuit(o.CustomHttpHandlerWrap(proxy), &o, sig, idleConnsCH, mtr, cr)
+	return listenAndServeQuit(o.CustomHttpHandlerWrap(proxy), &o, serverDeps{
+		sigs:         sig,
+		idleConnsCH:  idleConnsCH,
+		metrics:      mtr,
+		certRegistry: cr,
+		passthrough:  passthroughProxy,
+		drain:        drainRegistry,
+	})
 }
 
 // Run skipper.
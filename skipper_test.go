@@ -1,6 +1,7 @@
 package skipper
 
 import (
+	"bufio"
 	"bytes"
 	"crypto/tls"
 	"fmt"
@@ -10,6 +11,9 @@ import (
 	stdlibhttptest "net/http/httptest"
 	"net/url"
 	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
 	"syscall"
 	"testing"
 	"time"
@@ -26,8 +30,11 @@ import (
 	"github.com/zalando/skipper/innkeeper"
 	"github.com/zalando/skipper/loadbalancer"
 	"github.com/zalando/skipper/metrics/metricstest"
+	skpnet "github.com/zalando/skipper/net"
 	"github.com/zalando/skipper/net/httptest"
 	"github.com/zalando/skipper/net/redistest"
+	"github.com/zalando/skipper/predicates/listener"
+	"github.com/zalando/skipper/predicates/source"
 	"github.com/zalando/skipper/proxy"
 	"github.com/zalando/skipper/ratelimit"
 	"github.com/zalando/skipper/routing"
@@ -158,6 +165,13 @@ func TestOptionsTLSConfig(t *testing.T) {
 	require.NoError(t, err)
 	require.Equal(t, uint16(tls.VersionTLS13), c.MinVersion)
 	require.Equal(t, []tls.Certificate{cert, cert2}, c.Certificates)
+
+	// client certificates
+	o = &Options{CertPathTLS: "fixtures/test.crt", KeyPathTLS: "fixtures/test.key", ClientCAPathTLS: "fixtures/test.crt,fixtures/test2.crt", ClientAuthTLS: tls.RequireAndVerifyClientCert}
+	c, err = o.tlsConfig(cr)
+	require.NoError(t, err)
+	require.Equal(t, tls.RequireAndVerifyClientCert, c.ClientAuth)
+	require.NotNil(t, c.ClientCAs)
 }
 
 func TestOptionsTLSConfigInvalidPaths(t *testing.T) {
@@ -174,6 +188,8 @@ func TestOptionsTLSConfigInvalidPaths(t *testing.T) {
 		{"cert key mismatch", &Options{CertPathTLS: "fixtures/test.crt", KeyPathTLS: "fixtures/test2.key"}},
 		{"multiple cert key count mismatch", &Options{CertPathTLS: "fixtures/test.crt,fixtures/test2.crt", KeyPathTLS: "fixtures/test.key"}},
 		{"multiple cert key mismatch", &Options{CertPathTLS: "fixtures/test.crt,fixtures/test2.crt", KeyPathTLS: "fixtures/test2.key,fixtures/test.key"}},
+		{"wrong client ca path", &Options{CertPathTLS: "fixtures/test.crt", KeyPathTLS: "fixtures/test.key", ClientCAPathTLS: "fixtures/notFound.crt"}},
+		{"invalid client ca", &Options{CertPathTLS: "fixtures/test.crt", KeyPathTLS: "fixtures/test.key", ClientCAPathTLS: "fixtures/test.key"}},
 	} {
 		t.Run(tt.name, func(t *testing.T) {
 			_, err := tt.options.tlsConfig(cr)
@@ -298,6 +314,93 @@ func TestHTTPSServerShutdown(t *testing.T) {
 	testServerShutdown(t, o, "https")
 }
 
+func TestReusePortServerShutdown(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("SO_REUSEPORT test requires linux")
+	}
+
+	o := &Options{ReusePortAcceptors: 4}
+	testServerShutdown(t, o, "http")
+}
+
+func TestReusePortTCPQueueServerShutdown(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("SO_REUSEPORT test requires linux")
+	}
+
+	o := &Options{ReusePortAcceptors: 4, EnableTCPQueue: true}
+	testServerShutdown(t, o, "http")
+}
+
+func TestReusePortHTTPSServerShutdown(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("SO_REUSEPORT test requires linux")
+	}
+
+	o := &Options{
+		CertPathTLS:        "fixtures/test.crt",
+		KeyPathTLS:         "fixtures/test.key",
+		ReusePortAcceptors: 4,
+	}
+	testServerShutdown(t, o, "https")
+}
+
+type failingListener struct {
+	net.Listener
+	err error
+}
+
+func (l failingListener) Accept() (net.Conn, error) { return nil, l.err }
+
+func TestServeListeners(t *testing.T) {
+	var ls []net.Listener
+	for i := 0; i < 3; i++ {
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		ls = append(ls, l)
+	}
+
+	srv := &http.Server{Handler: http.HandlerFunc(func(http.ResponseWriter, *http.Request) {})}
+	done := make(chan error)
+	go func() { done <- serveListeners(srv, ls, srv.Serve) }()
+
+	// every listener is served
+	for _, l := range ls {
+		rsp, err := http.Get("http://" + l.Addr().String())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rsp.Body.Close()
+	}
+
+	srv.Close()
+	if err := <-done; err != http.ErrServerClosed {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	failing, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer failing.Close()
+	acceptErr := fmt.Errorf("test error")
+	srv = &http.Server{}
+	err = serveListeners(srv, []net.Listener{l, failingListener{Listener: failing, err: acceptErr}}, srv.Serve)
+	if err != acceptErr {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
 func testServerShutdown(t *testing.T, o *Options, scheme string) {
 	const shutdownDelay = 1 * time.Second
 
@@ -322,7 +425,7 @@ func testServerShutdown(t *testing.T, o *Options, scheme string) {
 
 	sigs := make(chan os.Signal, 1)
 	go func() {
-		err := listenAndServeQuit(proxy, o, sigs, nil, nil, nil)
+		err := listenAndServeQuit(proxy, o, serverDeps{sigs: sigs})
 		require.NoError(t, err)
 	}()
 
@@ -349,6 +452,400 @@ func testServerShutdown(t *testing.T, o *Options, scheme string) {
 	require.Error(t, err)
 }
 
+func TestAdditionalListeners(t *testing.T) {
+	mainAddress, err := findAddress()
+	require.NoError(t, err)
+
+	internalAddress, err := findAddress()
+	require.NoError(t, err)
+
+	dc, err := testdataclient.NewDoc(`
+		internal: Path("/internal") && Listener("internal") -> inlineContent("internal") -> <shunt>;
+		all: * -> inlineContent("all") -> <shunt>;
+	`)
+	require.NoError(t, err)
+
+	rt := routing.New(routing.Options{
+		FilterRegistry: builtin.MakeRegistry(),
+		DataClients:    []routing.DataClient{dc},
+		Predicates:     []routing.PredicateSpec{listener.New()},
+	})
+	defer rt.Close()
+
+	proxy := proxy.New(rt, proxy.OptionsNone)
+	defer proxy.Close()
+
+	o := &Options{
+		Address: mainAddress,
+		Listeners: []ListenerOptions{{
+			Name:           "internal",
+			Address:        internalAddress,
+			MaxHeaderBytes: 1024,
+		}},
+	}
+
+	sigs := make(chan os.Signal, 1)
+	done := make(chan error, 1)
+	go func() { done <- listenAndServeQuit(proxy, o, serverDeps{sigs: sigs}) }()
+
+	get := func(address string, header string) (int, string) {
+		r, err := waitConn(func() (*http.Response, error) {
+			req, _ := http.NewRequest("GET", "http://"+address+"/internal", nil)
+			req.Header.Set("X-Test", header)
+			return http.DefaultClient.Do(req)
+		})
+		require.NoError(t, err)
+		defer r.Body.Close()
+
+		b, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		return r.StatusCode, string(b)
+	}
+
+	_, body := get(mainAddress, "")
+	assert.Equal(t, "all", body)
+
+	_, body = get(internalAddress, "")
+	assert.Equal(t, "internal", body)
+
+	largeHeader := strings.Repeat("x", 16384)
+	status, _ := get(internalAddress, largeHeader)
+	assert.Equal(t, http.StatusRequestHeaderFieldsTooLarge, status)
+
+	status, _ = get(mainAddress, largeHeader)
+	assert.Equal(t, http.StatusOK, status)
+
+	sigs <- syscall.SIGTERM
+	require.NoError(t, <-done)
+
+	_, err = http.Get("http://" + internalAddress)
+	assert.Error(t, err)
+}
+
+func TestListenerRoutingTables(t *testing.T) {
+	mainAddress, err := findAddress()
+	require.NoError(t, err)
+
+	internalAddress, err := findAddress()
+	require.NoError(t, err)
+
+	supportAddress, err := findAddress()
+	require.NoError(t, err)
+
+	o := Options{
+		Address:            mainAddress,
+		SupportListener:    supportAddress,
+		InlineRoutes:       `public: * -> inlineContent("public") -> <shunt>`,
+		WaitFirstRouteLoad: true,
+		Listeners: []ListenerOptions{{
+			Name:         "internal",
+			Address:      internalAddress,
+			InlineRoutes: `admin: Path("/admin") -> inlineContent("admin") -> <shunt>`,
+		}},
+	}
+
+	sigs := make(chan os.Signal, 1)
+	done := make(chan error, 1)
+	go func() { done <- run(o, sigs, nil) }()
+
+	get := func(u string) (int, string) {
+		r, err := waitConnGet(u)
+		require.NoError(t, err)
+		defer r.Body.Close()
+
+		b, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		return r.StatusCode, string(b)
+	}
+
+	_, body := get("http://" + mainAddress + "/admin")
+	assert.Equal(t, "public", body)
+
+	_, body = get("http://" + internalAddress + "/admin")
+	assert.Equal(t, "admin", body)
+
+	status, _ := get("http://" + internalAddress + "/foo")
+	assert.Equal(t, http.StatusNotFound, status)
+
+	_, body = get("http://" + supportAddress + "/listeners/internal/routes")
+	assert.Contains(t, body, "admin:")
+	assert.NotContains(t, body, "public:")
+
+	sigs <- syscall.SIGTERM
+	require.NoError(t, <-done)
+}
+
+func TestListenerRoutingTablesShareEndpointRegistry(t *testing.T) {
+	const lastSeenTimeout = 50 * time.Millisecond
+
+	mainAddress, err := findAddress()
+	require.NoError(t, err)
+
+	internalAddress, err := findAddress()
+	require.NoError(t, err)
+
+	dc, err := testdataclient.NewDoc(`public: * -> <roundRobin, "http://10.0.0.1:8080">`)
+	require.NoError(t, err)
+
+	registry := routing.NewEndpointRegistry(routing.RegistryOptions{LastSeenTimeout: lastSeenTimeout})
+	o := Options{
+		Address:           mainAddress,
+		CustomDataClients: []routing.DataClient{dc},
+		SourcePollTimeout: 10 * time.Millisecond,
+		EndpointRegistry:  registry,
+		Listeners: []ListenerOptions{{
+			Name:         "internal",
+			Address:      internalAddress,
+			InlineRoutes: `admin: * -> <roundRobin, "http://10.0.0.2:8080">`,
+		}},
+	}
+
+	sigs := make(chan os.Signal, 1)
+	done := make(chan error, 1)
+	go func() { done <- run(o, sigs, nil) }()
+
+	waitEndpoint := func(host string) *routing.LBMetrics {
+		t.Helper()
+		for i := 0; i < 100; i++ {
+			if m := registry.Metrics("http", host); m != nil {
+				return m
+			}
+
+			time.Sleep(10 * time.Millisecond)
+		}
+
+		t.Fatalf("endpoint not registered: %s", host)
+		return nil
+	}
+
+	waitEndpoint("10.0.0.1:8080")
+	internal := waitEndpoint("10.0.0.2:8080")
+	registry.SetState("http", "10.0.0.2:8080", routing.EndpointDrained)
+
+	// the main routing table is updated after the last seen timeout of
+	// the endpoints of the internal listener
+	time.Sleep(2 * lastSeenTimeout)
+	require.NoError(t, dc.UpdateDoc(`public: * -> <roundRobin, "http://10.0.0.3:8080">`, nil))
+	waitEndpoint("10.0.0.3:8080")
+
+	assert.Same(t, internal, registry.Metrics("http", "10.0.0.2:8080"))
+	assert.Equal(t, routing.EndpointDrained, internal.State())
+	assert.Nil(t, registry.Metrics("http", "10.0.0.1:8080"))
+
+	sigs <- syscall.SIGTERM
+	require.NoError(t, <-done)
+}
+
+func TestInvalidListenerRoutes(t *testing.T) {
+	o := Options{
+		Address: ":0",
+		Listeners: []ListenerOptions{{
+			Name:       "internal",
+			Address:    ":0",
+			RoutesFile: "/does-not-exist.eskip",
+		}},
+	}
+
+	assert.Error(t, run(o, nil, nil))
+}
+
+func TestRouteRules(t *testing.T) {
+	mainAddress, err := findAddress()
+	require.NoError(t, err)
+
+	internalAddress, err := findAddress()
+	require.NoError(t, err)
+
+	rulesFile := filepath.Join(t.TempDir(), "rules.yaml")
+	writeRules := func(value string) {
+		rules := fmt.Sprintf("- match: path == \"/foo\"\n  append: setResponseHeader(\"X-Rule\", %q)", value)
+		require.NoError(t, os.WriteFile(rulesFile, []byte(rules), 0644))
+	}
+
+	writeRules("v1")
+	o := Options{
+		Address:            mainAddress,
+		InlineRoutes:       `foo: Path("/foo") -> <shunt>; bar: Path("/bar") -> <shunt>`,
+		RouteRulesFile:     rulesFile,
+		SourcePollTimeout:  10 * time.Millisecond,
+		WaitFirstRouteLoad: true,
+		Listeners: []ListenerOptions{{
+			Name:         "internal",
+			Address:      internalAddress,
+			InlineRoutes: `foo: Path("/foo") -> <shunt>`,
+		}},
+	}
+
+	sigs := make(chan os.Signal, 1)
+	done := make(chan error, 1)
+	go func() { done <- run(o, sigs, nil) }()
+
+	header := func(u string) string {
+		r, err := waitConnGet(u)
+		require.NoError(t, err)
+		r.Body.Close()
+		return r.Header.Get("X-Rule")
+	}
+
+	assert.Equal(t, "v1", header("http://"+mainAddress+"/foo"))
+	assert.Equal(t, "", header("http://"+mainAddress+"/bar"))
+	assert.Equal(t, "v1", header("http://"+internalAddress+"/foo"))
+
+	writeRules("v2")
+	assert.Eventually(t, func() bool {
+		return header("http://"+mainAddress+"/foo") == "v2" && header("http://"+internalAddress+"/foo") == "v2"
+	}, 3*time.Second, 20*time.Millisecond)
+
+	sigs <- syscall.SIGTERM
+	require.NoError(t, <-done)
+}
+
+func TestInvalidRouteRules(t *testing.T) {
+	o := Options{
+		Address:        ":0",
+		RouteRulesFile: "/does-not-exist.yaml",
+	}
+
+	assert.Error(t, run(o, nil, nil))
+}
+
+func TestConfigProfiles(t *testing.T) {
+	address, err := findAddress()
+	require.NoError(t, err)
+
+	profilesFile := filepath.Join(t.TempDir(), "profiles.yaml")
+	require.NoError(t, os.WriteFile(profilesFile, []byte(`
+profiles:
+  standard:
+    tls:
+      hsts: max-age=31536000
+hosts:
+  www.example.org: standard
+`), 0644))
+
+	o := Options{
+		Address:            address,
+		InlineRoutes:       `www: Host("^www[.]example[.]org$") -> <shunt>; other: * -> <shunt>`,
+		ProfilesFile:       profilesFile,
+		WaitFirstRouteLoad: true,
+	}
+
+	sigs := make(chan os.Signal, 1)
+	done := make(chan error, 1)
+	go func() { done <- run(o, sigs, nil) }()
+
+	hsts := func(host string) string {
+		req, err := http.NewRequest("GET", "http://"+address, nil)
+		require.NoError(t, err)
+		req.Host = host
+
+		r, err := waitConnGet("http://" + address)
+		require.NoError(t, err)
+		r.Body.Close()
+
+		r, err = http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		r.Body.Close()
+		return r.Header.Get("Strict-Transport-Security")
+	}
+
+	assert.Equal(t, "max-age=31536000", hsts("www.example.org"))
+	assert.Equal(t, "", hsts("other.example.org"))
+
+	sigs <- syscall.SIGTERM
+	require.NoError(t, <-done)
+}
+
+func TestInvalidAdditionalListener(t *testing.T) {
+	o := &Options{Listeners: []ListenerOptions{{Name: "main", Address: ":0"}}}
+	assert.Error(t, listenAndServeQuit(http.NotFoundHandler(), o, serverDeps{}))
+}
+
+func TestProxyProtocolListener(t *testing.T) {
+	mainAddress, err := findAddress()
+	require.NoError(t, err)
+
+	nlbAddress, err := findAddress()
+	require.NoError(t, err)
+
+	dc, err := testdataclient.NewDoc(`
+		client: Source("192.0.2.1") -> inlineContent("client") -> <shunt>;
+		all: * -> inlineContent("all") -> <shunt>;
+	`)
+	require.NoError(t, err)
+
+	rt := routing.New(routing.Options{
+		FilterRegistry: builtin.MakeRegistry(),
+		DataClients:    []routing.DataClient{dc},
+		Predicates:     []routing.PredicateSpec{source.New()},
+	})
+	defer rt.Close()
+	<-rt.FirstLoad()
+
+	proxy := proxy.New(rt, proxy.OptionsNone)
+	defer proxy.Close()
+
+	trusted, err := skpnet.ParseCIDRs([]string{"127.0.0.0/8"})
+	require.NoError(t, err)
+
+	o := &Options{
+		Address:                      mainAddress,
+		ProxyProtocolTrustedNetworks: trusted,
+		Listeners: []ListenerOptions{{
+			Name:          "nlb",
+			Address:       nlbAddress,
+			ProxyProtocol: true,
+		}},
+	}
+
+	sigs := make(chan os.Signal, 1)
+	done := make(chan error, 1)
+	go func() { done <- listenAndServeQuit(proxy, o, serverDeps{sigs: sigs}) }()
+
+	get := func(address string) string {
+		r, err := waitConn(func() (*http.Response, error) {
+			c, err := net.Dial("tcp", address)
+			if err != nil {
+				return nil, err
+			}
+
+			defer c.Close()
+			_, err = c.Write([]byte("PROXY TCP4 192.0.2.1 198.51.100.1 56324 443\r\nGET / HTTP/1.1\r\nHost: www.example.org\r\nConnection: close\r\n\r\n"))
+			if err != nil {
+				return nil, err
+			}
+
+			rsp, err := http.ReadResponse(bufio.NewReader(c), nil)
+			if err != nil {
+				return nil, err
+			}
+
+			b, err := io.ReadAll(rsp.Body)
+			rsp.Body = io.NopCloser(bytes.NewReader(b))
+			return rsp, err
+		})
+		require.NoError(t, err)
+		defer r.Body.Close()
+
+		b, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		return string(b)
+	}
+
+	assert.Equal(t, "client", get(nlbAddress))
+
+	// the main listener doesn't expect the header
+	assert.NotEqual(t, "client", get(mainAddress))
+
+	sigs <- syscall.SIGTERM
+	require.NoError(t, <-done)
+}
+
+func TestProxyProtocolWithoutTrustedNetworks(t *testing.T) {
+	o := &Options{Address: ":0", ProxyProtocol: true}
+	assert.Error(t, listenAndServeQuit(http.NotFoundHandler(), o, serverDeps{}))
+}
+
 type (
 	customRatelimitSpec   struct{ registry *ratelimit.Registry }
 	customRatelimitFilter struct{}